@@ -0,0 +1,231 @@
+// Package contexis embeds the Contexis CMP runtime in Go applications.
+//
+// An Engine serves chat, memory search and prompt rendering for a project
+// directory without starting the HTTP server. Calls run in-process through the
+// same pipeline as POST /api/v1/chat, so contexts, guardrails, PII and
+// prompt-injection policies, budgets and audit logging behave as they do in
+// `ctx serve`:
+//
+//	eng, err := contexis.New("/srv/support", contexis.WithProvider(provider))
+//	if err != nil {
+//		return err
+//	}
+//	defer eng.Close()
+//	resp, err := eng.Chat(ctx, contexis.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "Where is my order?"})
+package contexis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+type (
+	// ChatRequest is the input of Engine.Chat.
+	ChatRequest = runtimeserver.ChatRequest
+	// ChatResponse is the output of Engine.Chat.
+	ChatResponse = runtimeserver.ChatResponse
+	// ModelParams are the sampling parameters a ChatRequest may override.
+	ModelParams = runtimeserver.ModelParams
+	// MemorySearchRequest is the input of Engine.MemorySearch.
+	MemorySearchRequest = runtimeserver.MemorySearchRequest
+	// SearchResult is a retrieved memory chunk.
+	SearchResult = runtimememory.SearchResult
+	// Provider generates model output.
+	Provider = runtimemodel.Provider
+	// MemoryStore stores and searches a component's memory.
+	MemoryStore = runtimememory.MemoryStore
+	// Middleware hooks into the chat pipeline.
+	Middleware = runtimeserver.Middleware
+	// AuditSink receives audit events.
+	AuditSink = runtimesecurity.AuditSink
+)
+
+// StoreFunc opens the memory store of a component for a tenant. The engine
+// closes the store once the call is done.
+type StoreFunc func(component, tenantID string) (MemoryStore, error)
+
+// Option configures an Engine.
+type Option func(*options)
+
+type options struct {
+	provider    Provider
+	hasProvider bool
+	openStore   StoreFunc
+	middleware  []Middleware
+	auditSink   AuditSink
+}
+
+// WithProvider generates answers with p instead of the provider configured by
+// the environment (OLLAMA_HOST, CMP_LOCAL_MODELS, HF_TOKEN, ...). A nil
+// provider renders prompts without inference.
+func WithProvider(p Provider) Option {
+	return func(o *options) { o.provider, o.hasProvider = p, true }
+}
+
+// WithStore serves memory from the stores opened by open instead of the
+// sqlite stores under the project root.
+func WithStore(open StoreFunc) Option {
+	return func(o *options) { o.openStore = open }
+}
+
+// WithMiddleware adds chat pipeline hooks, after registered middleware.
+func WithMiddleware(m ...Middleware) Option {
+	return func(o *options) { o.middleware = append(o.middleware, m...) }
+}
+
+// WithAuditSink sends audit events to sink instead of the sinks configured in
+// config/audit.yaml. The caller owns the sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(o *options) { o.auditSink = sink }
+}
+
+// Engine runs the CMP runtime of a project in-process. It is safe for
+// concurrent use.
+type Engine struct {
+	root    string
+	handler http.Handler
+	prompts *runtimeprompt.Engine
+	closers []io.Closer
+}
+
+// New returns an engine for the project at root, the directory holding
+// contexts/, prompts/, memory/ and config/.
+func New(root string, opts ...Option) (*Engine, error) {
+	if fi, err := os.Stat(root); err != nil {
+		return nil, fmt.Errorf("project root: %w", err)
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("project root %s is not a directory", root)
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	e := &Engine{root: root, prompts: runtimeprompt.NewEngine(root)}
+	provider := o.provider
+	if !o.hasProvider {
+		prov, err := runtimemodel.FromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("model provider: %w", err)
+		}
+		provider = prov
+		// Stops managed model servers such as llama-server
+		if c, ok := prov.(io.Closer); ok {
+			e.closers = append(e.closers, c)
+		}
+	}
+	sink := o.auditSink
+	if sink == nil {
+		sink = runtimeserver.NewAuditSink(root)
+		// Owned here so batched audit events are flushed on Close
+		if c, ok := sink.(io.Closer); ok {
+			e.closers = append(e.closers, c)
+		}
+	}
+	serverOpts := []runtimeserver.Option{runtimeserver.WithAuditSink(sink), runtimeserver.WithMiddleware(o.middleware...)}
+	if o.openStore != nil {
+		open := o.openStore
+		serverOpts = append(serverOpts, runtimeserver.WithStoreOpener(func(cfg runtimememory.Config) (runtimememory.MemoryStore, error) {
+			return open(cfg.ComponentName, cfg.TenantID)
+		}))
+	}
+	e.handler = runtimeserver.NewHandlerWithProvider(root, provider, serverOpts...)
+	return e, nil
+}
+
+// Handler returns the engine's HTTP API, for applications that mount it on
+// their own server.
+func (e *Engine) Handler() http.Handler { return e.handler }
+
+// Close releases the model provider and audit sinks the engine created.
+func (e *Engine) Close() error {
+	var first error
+	for _, c := range e.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	e.closers = nil
+	return first
+}
+
+// Chat answers a query with a context, like POST /api/v1/chat.
+func (e *Engine) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var resp ChatResponse
+	if err := e.dispatch(ctx, "/api/v1/chat", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// MemorySearch searches a component's memory, like
+// POST /api/v1/memory/{component}/search.
+func (e *Engine) MemorySearch(ctx context.Context, component string, req MemorySearchRequest) ([]SearchResult, error) {
+	var resp runtimeserver.MemorySearchResponse
+	if err := e.dispatch(ctx, "/api/v1/memory/"+url.PathEscape(component)+"/search", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// RenderPrompt renders prompts/<component>/<file> with data, without memory
+// search or inference.
+func (e *Engine) RenderPrompt(component, file string, data map[string]interface{}) (string, error) {
+	return e.prompts.RenderFile(component, file, data)
+}
+
+// Error is a request the runtime rejected, e.g. a blocked query or an
+// exceeded budget. Status is the HTTP status the server would have returned.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// dispatch serves an in-process request and decodes the JSON response into out.
+func (e *Engine) dispatch(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	e.handler.ServeHTTP(rec, r)
+	if rec.status >= 300 {
+		return &Error{Status: rec.status, Message: strings.TrimSpace(rec.body.String())}
+	}
+	if err := json.Unmarshal(rec.body.Bytes(), out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// bufferedResponse is a minimal in-memory http.ResponseWriter.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(code int)        { b.status = code }
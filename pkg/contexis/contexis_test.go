@@ -0,0 +1,103 @@
+package contexis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+type echoProvider struct{}
+
+func (echoProvider) Generate(_ context.Context, prompt string, _ runtimemodel.Params) (string, error) {
+	return "answer from: " + prompt, nil
+}
+
+// fixedStore returns the same chunk for every search.
+type fixedStore struct{ opened []string }
+
+func (s *fixedStore) IngestDocuments(context.Context, []string) (string, error) { return "", nil }
+func (s *fixedStore) Optimize(context.Context, string) error                    { return nil }
+func (s *fixedStore) Close() error                                              { return nil }
+func (s *fixedStore) Search(_ context.Context, query string, topK int) ([]SearchResult, error) {
+	return []SearchResult{{ID: "faq_1", Content: "Refunds take 14 days.", Score: 0.9}}, nil
+}
+
+type discardSink struct{}
+
+func (discardSink) Write(runtimesecurity.AuditEvent) error { return nil }
+
+func newProject(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"contexts/SupportBot/support_bot.ctx":  "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\n",
+		"prompts/SupportBot/agent_response.md": "{{range .results}}- {{.Content}}\n{{end}}Q: {{.user_input}}",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestEngine_ChatSearchAndRender(t *testing.T) {
+	store := &fixedStore{}
+	eng, err := New(newProject(t), WithProvider(echoProvider{}), WithAuditSink(discardSink{}),
+		WithStore(func(component, tenantID string) (MemoryStore, error) {
+			store.opened = append(store.opened, component+"/"+tenantID)
+			return store, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	ctx := context.Background()
+
+	resp, err := eng.Chat(ctx, ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot", Query: "How long do refunds take?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.Rendered, "answer from: - Refunds take 14 days.") {
+		t.Fatalf("the injected store was not searched: %q", resp.Rendered)
+	}
+	if len(store.opened) != 1 || store.opened[0] != "SupportBot/acme" {
+		t.Fatalf("unexpected stores opened %v", store.opened)
+	}
+
+	results, err := eng.MemorySearch(ctx, "SupportBot", MemorySearchRequest{Query: "refunds", TopK: 3})
+	if err != nil || len(results) != 1 || results[0].ID != "faq_1" {
+		t.Fatalf("MemorySearch = %+v, %v", results, err)
+	}
+
+	out, err := eng.RenderPrompt("SupportBot", "agent_response.md", map[string]interface{}{"user_input": "hi"})
+	if err != nil || out != "Q: hi" {
+		t.Fatalf("RenderPrompt = %q, %v", out, err)
+	}
+}
+
+func TestEngine_RejectedRequest(t *testing.T) {
+	eng, err := New(newProject(t), WithProvider(nil), WithAuditSink(discardSink{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = eng.Chat(context.Background(), ChatRequest{Context: "Missing", Query: "hi"})
+	var rejected *Error
+	if !errors.As(err, &rejected) || rejected.Status != http.StatusBadRequest {
+		t.Fatalf("expected a 400 error, got %v", err)
+	}
+	if _, err := New(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error for a missing project root")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
 	"go.uber.org/zap"
 )
 
@@ -35,6 +36,8 @@ type AgentConfig struct {
 	MaxHistory     int
 	Privacy        string
 	DriftThreshold float64
+	// APITools are HTTP tools generated from an OpenAPI spec
+	APITools []Tool
 }
 
 // Tool represents a tool that can be used by the agent
@@ -42,10 +45,21 @@ type Tool struct {
 	Name        string
 	URI         string
 	Description string
+	// Method and Parameters are set for HTTP tools generated from an OpenAPI spec
+	Method     string
+	Parameters []corectx.ToolParameter
+	// Database is set for SQL tools the runtime executes itself
+	Database *corectx.DatabaseConfig
 }
 
 // generateAgent creates a conversational agent with tools and episodic memory
 func GenerateAgent(ctx context.Context, name, tools, memory string) error {
+	return generateAgent(ctx, name, tools, memory, nil)
+}
+
+// generateAgent generates the agent; apiTools are added to the context's tools
+// section after the built-in ones.
+func generateAgent(ctx context.Context, name, tools, memory string, apiTools []Tool) error {
 	log := logger.WithContext(ctx)
 
 	// Validate agent name early to match test expectations
@@ -76,6 +90,7 @@ func GenerateAgent(ctx context.Context, name, tools, memory string) error {
 	config := AgentConfig{
 		Name:           name,
 		Tools:          toolList,
+		APITools:       apiTools,
 		Memory:         memory,
 		Description:    fmt.Sprintf("Conversational agent for %s", name),
 		Version:        "1.0.0",
@@ -197,46 +212,47 @@ func ValidateAgentConfig(tools []string, memory string) error {
 	return nil
 }
 
+// agentToolCatalog holds the built-in tools an agent can be generated with
+var agentToolCatalog = map[string]Tool{
+	"web_search": {
+		Name:        "web_search",
+		URI:         "mcp://web.search",
+		Description: "Search the web for current information",
+	},
+	"database": {
+		Name:        "database",
+		Description: "Query database for user and order information",
+		Database:    &corectx.DatabaseConfig{Driver: "sqlite", DSN: "data/app.db"},
+	},
+	"api": {
+		Name:        "api",
+		URI:         "mcp://api.call",
+		Description: "Make API calls to external services",
+	},
+	"file_system": {
+		Name:        "file_system",
+		URI:         "mcp://file.read",
+		Description: "Read and write files",
+	},
+	"email": {
+		Name:        "email",
+		URI:         "mcp://email.send",
+		Description: "Send and read emails",
+	},
+}
+
 // generateAgentContext creates the agent context file
 func generateAgentContext(ctx context.Context, config AgentConfig) error {
 	log := logger.WithContext(ctx)
 
-	// Define available tools
-	availableTools := map[string]Tool{
-		"web_search": {
-			Name:        "web_search",
-			URI:         "mcp://web.search",
-			Description: "Search the web for current information",
-		},
-		"database": {
-			Name:        "database",
-			URI:         "mcp://database.query",
-			Description: "Query database for user and order information",
-		},
-		"api": {
-			Name:        "api",
-			URI:         "mcp://api.call",
-			Description: "Make API calls to external services",
-		},
-		"file_system": {
-			Name:        "file_system",
-			URI:         "mcp://file.read",
-			Description: "Read and write files",
-		},
-		"email": {
-			Name:        "email",
-			URI:         "mcp://email.send",
-			Description: "Send and read emails",
-		},
-	}
-
 	// Filter tools based on configuration
 	var selectedTools []Tool
 	for _, toolName := range config.Tools {
-		if tool, exists := availableTools[toolName]; exists {
+		if tool, exists := agentToolCatalog[toolName]; exists {
 			selectedTools = append(selectedTools, tool)
 		}
 	}
+	selectedTools = append(selectedTools, config.APITools...)
 
 	// Create context template data
 	templateData := struct {
@@ -319,8 +335,12 @@ func generateAgentPrompts(ctx context.Context, config AgentConfig) error {
 func generateAgentTools(ctx context.Context, config AgentConfig) error {
 	log := logger.WithContext(ctx)
 
-	// Copy tool templates based on selected tools
+	// Copy tool templates based on selected tools; the runtime executes
+	// database tools itself
 	for _, tool := range config.Tools {
+		if agentToolCatalog[tool].Database != nil {
+			continue
+		}
 		if err := copyToolTemplate(ctx, tool, config.Name); err != nil {
 			log.Error("failed to copy tool template", zap.String("tool", tool), zap.Error(err))
 			return fmt.Errorf("failed to copy tool %s: %w", tool, err)
@@ -338,7 +358,6 @@ func copyToolTemplate(ctx context.Context, toolName, agentName string) error {
 	// Define tool template mappings
 	toolTemplates := map[string]string{
 		"web_search":  "templates/agent/web_search.py",
-		"database":    "templates/agent/database.py",
 		"api":         "templates/agent/api.py",
 		"file_system": "templates/agent/file_system.py",
 		"email":       "templates/agent/email.py",
@@ -419,7 +438,7 @@ memory_type: "%s"
 max_history: 50
 privacy: "user_isolated"
 retention_days: 30
-encryption: true
+encryption: false  # true encrypts memory at rest with CMP_EPISODIC_KEY
 
 # Episodic Memory Settings
 episodic:
@@ -479,8 +498,10 @@ func generateAgentRequirements(ctx context.Context, config AgentConfig) error {
 	return nil
 }
 
-// resolveTemplatePath tries to find a template by checking cwd, repo root (go.mod), and source-relative
+// resolveTemplatePath tries to find a template by checking cwd, repo root (go.mod), and source-relative.
+// rel is slash-separated, e.g. templates/agent/support_bot.ctx.
 func resolveTemplatePath(rel string) (string, error) {
+	rel = filepath.FromSlash(rel)
 	// 1) CWD
 	if p := filepath.Clean(rel); fileExists(p) {
 		abs, _ := filepath.Abs(p)
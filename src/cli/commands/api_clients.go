@@ -0,0 +1,528 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// APIClientLanguages are the languages `ctx api clients` generates.
+var APIClientLanguages = []string{"python", "ts"}
+
+// apiField is a property of a generated model.
+type apiField struct {
+	Name, PyType, TSType string
+	// TSKey is the property name, quoted when it is not an identifier.
+	TSKey    string
+	Required bool
+}
+
+// apiModel is a component schema of the OpenAPI document.
+type apiModel struct {
+	Name   string
+	Fields []apiField
+	// Functional is set when a field name is not a Python identifier, so the
+	// TypedDict must use the functional syntax.
+	Functional bool
+}
+
+// apiParam is a path or query parameter of an operation.
+type apiParam struct {
+	Name, PyArg string
+}
+
+// apiClientOp is an operation of the generated clients.
+type apiClientOp struct {
+	ID, PyName, Method, Summary string
+	Path, PyPath, TSPath        string
+	PathParams, Query           []apiParam
+	PyBody, TSBody              string
+	BodyOptional                bool
+	PyResult, TSResult          string
+	Text                        bool
+}
+
+type apiClientData struct {
+	Title, Version, PackageVersion string
+	Models                         []apiModel
+	Operations                     []apiClientOp
+}
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// GenerateAPIClients writes typed clients for the operations and schemas of
+// an OpenAPI 3 document: a Python package in <out>/python and a TypeScript
+// package in <out>/typescript. It returns the files written.
+func GenerateAPIClients(doc map[string]interface{}, langs []string, out, packageVersion string) ([]string, error) {
+	data, err := apiClientModel(doc)
+	if err != nil {
+		return nil, err
+	}
+	data.PackageVersion = packageVersion
+	var files map[string]string
+	written := []string{}
+	for _, lang := range langs {
+		switch lang {
+		case "python", "py":
+			files = map[string]string{
+				"python/pyproject.toml":              pyProjectTemplate,
+				"python/contexis_client/__init__.py": pyInitTemplate,
+				"python/contexis_client/models.py":   pyModelsTemplate,
+				"python/contexis_client/client.py":   pyClientTemplate,
+			}
+		case "ts", "typescript":
+			files = map[string]string{
+				"typescript/package.json":  tsPackageTemplate,
+				"typescript/tsconfig.json": tsConfigTemplate,
+				"typescript/src/index.ts":  tsIndexTemplate,
+				"typescript/src/models.ts": tsModelsTemplate,
+				"typescript/src/client.ts": tsClientTemplate,
+			}
+		default:
+			return written, fmt.Errorf("unsupported client language %q (want %s)", lang, strings.Join(APIClientLanguages, ", "))
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			tmpl, err := template.New(name).Parse(files[name])
+			if err != nil {
+				return written, err
+			}
+			path := filepath.Join(out, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return written, err
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return written, err
+			}
+			err = tmpl.Execute(f, data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return written, fmt.Errorf("write %s: %w", path, err)
+			}
+			written = append(written, path)
+		}
+	}
+	return written, nil
+}
+
+// apiClientModel collects the models and operations of an OpenAPI document.
+func apiClientModel(doc map[string]interface{}) (apiClientData, error) {
+	var data apiClientData
+	if info, ok := doc["info"].(map[string]interface{}); ok {
+		data.Title, _ = info["title"].(string)
+		data.Version, _ = info["version"].(string)
+	}
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for _, name := range sortedKeys(schemas) {
+		schema, _ := schemas[name].(map[string]interface{})
+		m := apiModel{Name: name}
+		required := map[string]bool{}
+		for _, r := range asList(schema["required"]) {
+			if s, ok := r.(string); ok {
+				required[s] = true
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for _, prop := range sortedKeys(props) {
+			ps, _ := props[prop].(map[string]interface{})
+			f := apiField{Name: prop, PyType: pyType(ps), TSType: tsType(ps, ""), TSKey: prop, Required: required[prop]}
+			if !identRe.MatchString(prop) {
+				f.TSKey = fmt.Sprintf("%q", prop)
+			}
+			if !identRe.MatchString(prop) || pythonKeywords[prop] {
+				m.Functional = true
+			}
+			m.Fields = append(m.Fields, f)
+		}
+		data.Models = append(data.Models, m)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		return data, fmt.Errorf("the OpenAPI document has no paths")
+	}
+	for _, path := range sortedKeys(paths) {
+		item, _ := paths[path].(map[string]interface{})
+		for _, method := range openAPIMethods {
+			raw, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := apiClientOp{Method: strings.ToUpper(method), Path: path, PyPath: path, TSPath: path}
+			op.ID, _ = raw["operationId"].(string)
+			if op.ID == "" {
+				op.ID = snakeCase(method + "_" + path)
+			}
+			op.PyName = pythonIdent(op.ID)
+			op.Summary, _ = raw["summary"].(string)
+			for _, p := range openAPIParams(doc, raw["parameters"]) {
+				ap := apiParam{Name: p.Name, PyArg: pythonIdent(p.Name)}
+				switch p.In {
+				case "path":
+					op.PathParams = append(op.PathParams, ap)
+					op.PyPath = strings.ReplaceAll(op.PyPath, "{"+p.Name+"}", "{_q("+ap.PyArg+")}")
+					op.TSPath = strings.ReplaceAll(op.TSPath, "{"+p.Name+"}", "${encodeURIComponent("+p.Name+")}")
+				case "query":
+					op.Query = append(op.Query, ap)
+				}
+			}
+			if body, ok := raw["requestBody"].(map[string]interface{}); ok {
+				schema := contentSchema(body, "application/json")
+				op.PyBody, op.TSBody = pyType(schema), tsType(schema, "m.")
+				op.BodyOptional = body["required"] != true
+			}
+			op.PyResult, op.TSResult = "None", "void"
+			responses, _ := raw["responses"].(map[string]interface{})
+			for _, code := range sortedKeys(responses) {
+				if !strings.HasPrefix(code, "2") {
+					continue
+				}
+				resp, _ := responses[code].(map[string]interface{})
+				if schema := contentSchema(resp, "application/json"); schema != nil {
+					op.PyResult, op.TSResult = pyType(schema), tsType(schema, "m.")
+				} else if contentSchema(resp, "text/plain") != nil {
+					op.PyResult, op.TSResult, op.Text = "str", "string", true
+				}
+				break
+			}
+			data.Operations = append(data.Operations, op)
+		}
+	}
+	sort.SliceStable(data.Operations, func(i, j int) bool { return data.Operations[i].ID < data.Operations[j].ID })
+	return data, nil
+}
+
+func contentSchema(m map[string]interface{}, mediaType string) map[string]interface{} {
+	content, _ := m["content"].(map[string]interface{})
+	media, _ := content[mediaType].(map[string]interface{})
+	if media == nil {
+		return nil
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	if schema == nil {
+		schema = map[string]interface{}{}
+	}
+	return schema
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func asList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case []string:
+		out := make([]interface{}, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+func refName(schema map[string]interface{}) string {
+	ref, _ := schema["$ref"].(string)
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// pyType is the Python annotation of a schema; models are forward references.
+func pyType(schema map[string]interface{}) string {
+	if schema == nil {
+		return "Any"
+	}
+	if name := refName(schema); name != "" {
+		return fmt.Sprintf("%q", name)
+	}
+	switch schema["type"] {
+	case "string":
+		return "str"
+	case "integer":
+		return "int"
+	case "number":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return "List[" + pyType(items) + "]"
+	case "object":
+		if add, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			return "Dict[str, " + pyType(add) + "]"
+		}
+		return "Dict[str, Any]"
+	}
+	return "Any"
+}
+
+// tsType is the TypeScript type of a schema; models are qualified with ns.
+func tsType(schema map[string]interface{}, ns string) string {
+	if schema == nil {
+		return "unknown"
+	}
+	if name := refName(schema); name != "" {
+		return ns + name
+	}
+	switch schema["type"] {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		t := tsType(items, ns)
+		if strings.ContainsAny(t, "<| ") {
+			return "Array<" + t + ">"
+		}
+		return t + "[]"
+	case "object":
+		if add, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			return "Record<string, " + tsType(add, ns) + ">"
+		}
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+const pyProjectTemplate = `[project]
+name = "contexis-client"
+version = "{{ .PackageVersion }}"
+description = "Typed client for the {{ .Title }} API"
+requires-python = ">=3.11"
+dependencies = []
+
+[build-system]
+requires = ["setuptools>=68"]
+build-backend = "setuptools.build_meta"
+`
+
+const pyInitTemplate = `"""Typed client for the {{ .Title }} API {{ .Version }}.
+
+Generated by ctx api clients from the OpenAPI document; do not edit.
+"""
+
+from .client import APIError, Client
+from .models import *  # noqa: F401,F403
+`
+
+const pyModelsTemplate = `"""Models of the {{ .Title }} API {{ .Version }}.
+
+Generated by ctx api clients from the OpenAPI document; do not edit.
+"""
+
+from typing import Any, Dict, List, NotRequired, TypedDict
+{{ range .Models }}
+{{ if .Functional }}
+{{ .Name }} = TypedDict("{{ .Name }}", {
+{{- range .Fields }}
+    "{{ .Name }}": {{ if .Required }}{{ .PyType }}{{ else }}NotRequired[{{ .PyType }}]{{ end }},
+{{- end }}
+})
+{{ else }}
+class {{ .Name }}(TypedDict):
+{{- range .Fields }}
+    {{ .Name }}: {{ if .Required }}{{ .PyType }}{{ else }}NotRequired[{{ .PyType }}]{{ end }}
+{{- else }}
+    pass
+{{- end }}
+{{ end }}{{ end }}`
+
+const pyClientTemplate = `"""Client for the {{ .Title }} API {{ .Version }}.
+
+Generated by ctx api clients from the OpenAPI document; do not edit.
+"""
+
+import json
+import urllib.error
+import urllib.parse
+import urllib.request
+from typing import Any, Dict, List, Optional
+
+from .models import *  # noqa: F401,F403
+
+
+class APIError(Exception):
+    """A request the runtime rejected; status is the HTTP status code."""
+
+    def __init__(self, status: int, message: str):
+        super().__init__(f"{message} (HTTP {status})")
+        self.status = status
+        self.message = message
+
+
+def _q(value: Any) -> str:
+    return urllib.parse.quote(str(value), safe="")
+
+
+class Client:
+    """Calls the runtime at base_url, e.g. http://localhost:8000. token is sent
+    as a bearer token when authentication is enabled."""
+
+    def __init__(self, base_url: str = "http://localhost:8000", token: Optional[str] = None, timeout: float = 60.0):
+        self.base_url = base_url.rstrip("/")
+        self.token = token
+        self.timeout = timeout
+
+    def _request(self, method: str, path: str, query: Optional[Dict[str, Any]] = None, body: Any = None, text: bool = False) -> Any:
+        url = self.base_url + path
+        params = {k: v for k, v in (query or {}).items() if v is not None}
+        if params:
+            url += "?" + urllib.parse.urlencode(params)
+        headers = {"Accept": "application/json"}
+        data = None
+        if body is not None:
+            data = json.dumps(body).encode()
+            headers["Content-Type"] = "application/json"
+        if self.token:
+            headers["Authorization"] = "Bearer " + self.token
+        req = urllib.request.Request(url, data=data, method=method, headers=headers)
+        try:
+            with urllib.request.urlopen(req, timeout=self.timeout) as resp:
+                payload = resp.read().decode()
+        except urllib.error.HTTPError as err:
+            raise APIError(err.code, err.read().decode().strip()) from None
+        if text:
+            return payload
+        return json.loads(payload) if payload else None
+{{ range .Operations }}
+    def {{ .PyName }}(self
+        {{- range .PathParams }}, {{ .PyArg }}: str{{ end }}
+        {{- if .PyBody }}{{ if .BodyOptional }}, body: Optional[{{ .PyBody }}] = None{{ else }}, body: {{ .PyBody }}{{ end }}{{ end }}
+        {{- range .Query }}, {{ .PyArg }}: Optional[str] = None{{ end }}) -> {{ .PyResult }}:
+        """{{ if .Summary }}{{ .Summary }}: {{ end }}{{ .Method }} {{ .Path }}"""
+        return self._request("{{ .Method }}", {{ if .PathParams }}f{{ end }}"{{ .PyPath }}"
+            {{- if .Query }}, query={ {{- range $i, $q := .Query }}{{ if $i }}, {{ end }}"{{ $q.Name }}": {{ $q.PyArg }}{{ end }}}{{ end }}
+            {{- if .PyBody }}, body=body{{ end }}
+            {{- if .Text }}, text=True{{ end }})
+{{ end }}`
+
+const tsPackageTemplate = `{
+  "name": "contexis-client",
+  "version": "{{ .PackageVersion }}",
+  "description": "Typed client for the {{ .Title }} API",
+  "type": "module",
+  "main": "dist/index.js",
+  "types": "dist/index.d.ts",
+  "files": ["dist"],
+  "scripts": {
+    "build": "tsc"
+  },
+  "devDependencies": {
+    "typescript": "^5.4.0"
+  }
+}
+`
+
+const tsConfigTemplate = `{
+  "compilerOptions": {
+    "target": "ES2020",
+    "module": "ES2020",
+    "moduleResolution": "bundler",
+    "lib": ["ES2020", "DOM"],
+    "declaration": true,
+    "strict": true,
+    "outDir": "dist"
+  },
+  "include": ["src"]
+}
+`
+
+const tsIndexTemplate = `// Typed client for the {{ .Title }} API {{ .Version }}.
+// Generated by ctx api clients from the OpenAPI document; do not edit.
+export * from "./models.js";
+export * from "./client.js";
+`
+
+const tsModelsTemplate = `// Models of the {{ .Title }} API {{ .Version }}.
+// Generated by ctx api clients from the OpenAPI document; do not edit.
+{{ range .Models }}
+export interface {{ .Name }} {
+{{- range .Fields }}
+  {{ .TSKey }}{{ if not .Required }}?{{ end }}: {{ .TSType }};
+{{- end }}
+}
+{{ end }}`
+
+const tsClientTemplate = `// Client for the {{ .Title }} API {{ .Version }}.
+// Generated by ctx api clients from the OpenAPI document; do not edit.
+import type * as m from "./models.js";
+
+/** A request the runtime rejected; status is the HTTP status code. */
+export class APIError extends Error {
+  constructor(public status: number, message: string) {
+    super(` + "`${message} (HTTP ${status})`" + `);
+    this.name = "APIError";
+  }
+}
+
+export interface ClientOptions {
+  /** Runtime URL, default http://localhost:8000 */
+  baseUrl?: string;
+  /** Bearer token, sent when authentication is enabled */
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+export class Client {
+  private baseUrl: string;
+  private token?: string;
+  private fetchImpl: typeof fetch;
+
+  constructor(options: ClientOptions = {}) {
+    this.baseUrl = (options.baseUrl ?? "http://localhost:8000").replace(/\/+$/, "");
+    this.token = options.token;
+    this.fetchImpl = options.fetch ?? fetch;
+  }
+
+  private async request<T>(method: string, path: string, query: Record<string, string | undefined> = {}, body?: unknown, text = false): Promise<T> {
+    const params = new URLSearchParams();
+    for (const [k, v] of Object.entries(query)) {
+      if (v !== undefined) params.set(k, v);
+    }
+    const qs = params.toString();
+    const headers: Record<string, string> = { Accept: "application/json" };
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+    if (this.token) headers["Authorization"] = ` + "`Bearer ${this.token}`" + `;
+    const resp = await this.fetchImpl(this.baseUrl + path + (qs ? "?" + qs : ""), {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const payload = await resp.text();
+    if (!resp.ok) throw new APIError(resp.status, payload.trim());
+    if (text) return payload as T;
+    return (payload ? JSON.parse(payload) : undefined) as T;
+  }
+{{ range .Operations }}
+  /** {{ if .Summary }}{{ .Summary }}: {{ end }}{{ .Method }} {{ .Path }} */
+  {{ .ID }}(
+    {{- range $i, $p := .PathParams }}{{ if $i }}, {{ end }}{{ $p.Name }}: string{{ end }}
+    {{- if .TSBody }}{{ if .PathParams }}, {{ end }}body{{ if .BodyOptional }}?{{ end }}: {{ .TSBody }}{{ end }}
+    {{- if .Query }}{{ if or .PathParams .TSBody }}, {{ end }}query: { {{ range $i, $q := .Query }}{{ if $i }}; {{ end }}{{ printf "%q" $q.Name }}?: string{{ end }} } = {}{{ end }}): Promise<{{ .TSResult }}> {
+    return this.request("{{ .Method }}", {{ if .PathParams }}` + "`{{ .TSPath }}`" + `{{ else }}"{{ .Path }}"{{ end }}
+      {{- if or .Query .TSBody .Text }}, {{ if .Query }}query{{ else }}{}{{ end }}{{ end }}
+      {{- if or .TSBody .Text }}, {{ if .TSBody }}body{{ else }}undefined{{ end }}{{ end }}
+      {{- if .Text }}, true{{ end }});
+  }
+{{ end }}}
+`
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// GetAPICommand returns the `api` command for the runtime's OpenAPI document
+// and generated clients.
+func GetAPICommand() *cobra.Command {
+	apiCmd := &cobra.Command{Use: "api", Short: "OpenAPI document and typed clients of the HTTP runtime"}
+	apiCmd.AddCommand(newAPISpecCmd(), newAPIClientsCmd())
+	return apiCmd
+}
+
+// newAPISpecCmd returns the `spec` subcommand which prints the OpenAPI
+// document served at /openapi.json.
+func newAPISpecCmd() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "spec",
+		Short: "Print the OpenAPI 3.1 document of the HTTP runtime",
+		Example: `  ctx api spec
+  ctx api spec --out openapi.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			by, err := json.MarshalIndent(runtimeserver.OpenAPI(), "", "  ")
+			if err != nil {
+				return err
+			}
+			by = append(by, '\n')
+			if out == "" {
+				_, err = cmd.OutOrStdout().Write(by)
+				return err
+			}
+			return os.WriteFile(out, by, 0o644)
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "Write the document to this file instead of stdout")
+	return cmd
+}
+
+// newAPIClientsCmd returns the `clients` subcommand which generates typed
+// Python and TypeScript client packages.
+func newAPIClientsCmd() *cobra.Command {
+	var (
+		langs   []string
+		out     string
+		spec    string
+		version string
+	)
+	cmd := &cobra.Command{
+		Use:   "clients",
+		Short: "Generate typed API clients for application teams",
+		Long: `Generate typed client packages from the runtime's OpenAPI document: a Python
+package (clients/python) and a TypeScript package (clients/typescript). By default
+the document of this ctx build is used; --spec reads it from a file or from a
+running server's /openapi.json.`,
+		Example: `  ctx api clients --lang python --out clients/
+  ctx api clients --lang python,ts --spec http://localhost:8000/openapi.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			doc, err := loadAPIDocument(spec)
+			if err != nil {
+				return err
+			}
+			files, err := GenerateAPIClients(doc, langs, out, version)
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				fmt.Fprintln(cmd.OutOrStdout(), "wrote", f)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&langs, "lang", nil, "Client languages: "+strings.Join(APIClientLanguages, ", "))
+	cmd.Flags().StringVar(&out, "out", "clients", "Output directory")
+	cmd.Flags().StringVar(&spec, "spec", "", "OpenAPI document (file or URL); default: the document of this build")
+	cmd.Flags().StringVar(&version, "package-version", "0.1.0", "Version of the generated packages")
+	_ = cmd.MarkFlagRequired("lang")
+	_ = cmd.RegisterFlagCompletionFunc("lang", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return APIClientLanguages, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// loadAPIDocument reads an OpenAPI document (JSON or YAML) from a file or URL,
+// or returns the runtime's own document when src is empty.
+func loadAPIDocument(src string) (map[string]interface{}, error) {
+	var by []byte
+	var err error
+	switch {
+	case src == "":
+		// Round-trip so the document has the shape of a parsed file
+		if by, err = json.Marshal(runtimeserver.OpenAPI()); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://"):
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(src)
+		if err != nil {
+			return nil, fmt.Errorf("fetch OpenAPI document: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch OpenAPI document: %s", resp.Status)
+		}
+		if by, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	default:
+		if by, err = os.ReadFile(src); err != nil {
+			return nil, err
+		}
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(by, &doc); err != nil {
+		return nil, fmt.Errorf("parse OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	runtimeapproval "github.com/contexis-cmp/contexis/src/runtime/approval"
+	"github.com/spf13/cobra"
+)
+
+// GetApprovalsCommand returns the `approvals` command for deciding sensitive
+// actions parked by the server (CMP_OOB_REQUIRED_ACTIONS). It works on the
+// project's data/approvals directory, so it must run where the server runs.
+func GetApprovalsCommand() *cobra.Command {
+	approvalsCmd := &cobra.Command{Use: "approvals", Short: "Review out-of-band approval requests for sensitive actions"}
+	approvalsCmd.AddCommand(newApprovalsListCmd(), newApprovalsDecideCmd(true), newApprovalsDecideCmd(false))
+	return approvalsCmd
+}
+
+func newApprovalsListCmd() *cobra.Command {
+	var (
+		status string
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List approval requests (pending by default)",
+		Example: `  ctx approvals list
+  ctx approvals list --status all --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if status == "all" {
+				status = ""
+			}
+			list, err := runtimeapproval.NewStore(mustGetwd()).List(status)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				if list == nil {
+					list = []runtimeapproval.Request{}
+				}
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(list)
+			}
+			if len(list) == 0 {
+				fmt.Fprintln(out, "no approval requests")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tACTION\tTENANT\tCONTEXT\tSTATUS\tCREATED\tQUERY")
+			for _, a := range list {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Action, orDash(a.TenantID), orDash(a.Context),
+					a.Status, a.CreatedAt.Local().Format(time.DateTime), orDash(a.Query))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&status, "status", runtimeapproval.StatusPending, "Filter by status (pending, approved, denied, expired, cancelled, all)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print requests as JSON")
+	return cmd
+}
+
+// newApprovalsDecideCmd returns `approve` or `deny`; the waiting chat request
+// resumes or fails as soon as the server next polls the request.
+func newApprovalsDecideCmd(approve bool) *cobra.Command {
+	var reason, by string
+	use, short := "approve <id>", "Approve a pending request"
+	if !approve {
+		use, short = "deny <id>", "Deny a pending request"
+	}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if by == "" {
+				by = os.Getenv("USER")
+			}
+			if by == "" {
+				by = "cli"
+			}
+			a, err := runtimeapproval.NewStore(mustGetwd()).Decide(args[0], approve, by, reason)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s %s (%s)\n", a.ID, a.Status, a.Action)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "Reason recorded with the decision")
+	cmd.Flags().StringVar(&by, "by", "", "Approver recorded with the decision (default $USER)")
+	return cmd
+}
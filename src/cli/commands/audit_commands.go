@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"github.com/spf13/cobra"
+)
+
+// AuditFormats are the --format values of `ctx audit search`.
+var AuditFormats = []string{"table", "json", "csv"}
+
+// GetAuditCommand returns the `audit` command for querying the audit log.
+func GetAuditCommand() *cobra.Command {
+	auditCmd := &cobra.Command{Use: "audit", Short: "Search and follow the audit log"}
+	auditCmd.AddCommand(newAuditSearchCmd())
+	return auditCmd
+}
+
+// newAuditSearchCmd returns the `search` subcommand which filters the events of
+// the file sinks in config/audit.yaml (or audit.log), including rotated files.
+func newAuditSearchCmd() *cobra.Command {
+	var (
+		q            runtimesecurity.AuditQuery
+		since, until string
+		limit        int
+		format       string
+		follow       bool
+		pollInterval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "search",
+		Short: "Filter audit events by tenant, action, result and time",
+		Long: `Search the audit events written by the file sinks of config/audit.yaml (or
+audit.log when none is configured), rotated files included. Events sent only to
+syslog, webhook or Kafka sinks must be searched where they are delivered.
+
+--since and --until take a duration back from now (30m, 24h, 7d), a date
+(2026-01-31) or an RFC 3339 time. --action accepts a trailing * (chat:*).
+With --follow, the last --limit matches are printed and new matching events
+are streamed until interrupted.`,
+		Example: `  ctx audit search --tenant acme --action chat:invoke --since 24h --result denied
+  ctx audit search --reason model_override --format json
+  ctx audit search --action 'keys:*' --follow`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w, err := newAuditWriter(cmd.OutOrStdout(), format)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			if q.Since, err = parseAuditTime(since, now); err != nil {
+				return fmt.Errorf("--since: %w", err)
+			}
+			if q.Until, err = parseAuditTime(until, now); err != nil {
+				return fmt.Errorf("--until: %w", err)
+			}
+			root := mustGetwd()
+			events, err := runtimesecurity.SearchAudit(root, q, limit)
+			if err != nil {
+				return err
+			}
+			if !follow {
+				if ok, err := EmitResult(cmd, SchemaAuditSearch, events, nil); ok {
+					return err
+				}
+			}
+			if len(events) == 0 && !follow && format == "table" {
+				fmt.Fprintln(cmd.OutOrStdout(), "no matching audit events")
+				return nil
+			}
+			if err := w.write(events...); err != nil {
+				return err
+			}
+			if !follow {
+				return nil
+			}
+			ctx, stop := signal.NotifyContext(cmdContext(cmd), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			var writeErr error
+			err = runtimesecurity.FollowAudit(ctx, root, q, pollInterval, func(e runtimesecurity.AuditEvent) {
+				if writeErr == nil {
+					writeErr = w.write(e)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return writeErr
+		},
+	}
+	cmd.Flags().StringVar(&q.TenantID, "tenant", "", "Only events of this tenant")
+	cmd.Flags().StringVar(&q.Action, "action", "", "Only this action, e.g. chat:invoke (trailing * matches a prefix)")
+	cmd.Flags().StringVar(&q.Resource, "resource", "", "Only this resource")
+	cmd.Flags().StringVar(&q.Result, "result", "", "Only this result or decision, e.g. denied, allow")
+	cmd.Flags().StringVar(&q.Reason, "reason", "", "Only this reason, e.g. quota_exceeded")
+	cmd.Flags().StringVar(&q.ActorKeyID, "key", "", "Only events of this API key ID")
+	cmd.Flags().StringVar(&q.RequestID, "request-id", "", "Only events of this request")
+	cmd.Flags().StringVar(&since, "since", "", "Start of the time range (24h, 7d, 2026-01-31 or RFC 3339)")
+	cmd.Flags().StringVar(&until, "until", "", "End of the time range, exclusive")
+	cmd.Flags().IntVar(&limit, "limit", 100, "Print at most the last N matches (0: all)")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: "+strings.Join(AuditFormats, ", "))
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep streaming new matching events")
+	cmd.Flags().DurationVar(&pollInterval, "poll-interval", time.Second, "How often --follow checks for new events")
+	_ = cmd.RegisterFlagCompletionFunc("format", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return AuditFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func cmdContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// parseAuditTime parses a duration back from now ("24h", "7d"), a date or an
+// RFC 3339 time. An empty string is the zero time.
+func parseAuditTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want a duration (24h, 7d), a date (2006-01-02) or RFC 3339", s)
+}
+
+// auditWriter prints events in one of the AuditFormats.
+type auditWriter struct {
+	out    io.Writer
+	format string
+	header bool
+}
+
+func newAuditWriter(out io.Writer, format string) (*auditWriter, error) {
+	switch format {
+	case "table", "json", "csv":
+		return &auditWriter{out: out, format: format}, nil
+	}
+	return nil, fmt.Errorf("invalid --format %q: must be %s", format, strings.Join(AuditFormats, ", "))
+}
+
+var auditColumns = []string{"TIME", "TENANT", "ACTOR", "ACTION", "RESOURCE", "RESULT", "REASON", "REQUEST"}
+
+func auditRow(e runtimesecurity.AuditEvent) []string {
+	return []string{e.Timestamp.UTC().Format(time.RFC3339), e.TenantID, e.ActorKeyID, e.Action, e.Resource, e.Result, e.Reason, e.RequestID}
+}
+
+// write prints events; the table and CSV header is printed once.
+func (w *auditWriter) write(events ...runtimesecurity.AuditEvent) error {
+	switch w.format {
+	case "json":
+		enc := json.NewEncoder(w.out)
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w.out)
+		if !w.header {
+			w.header = true
+			header := make([]string, len(auditColumns))
+			for i, c := range auditColumns {
+				header[i] = strings.ToLower(c)
+			}
+			_ = cw.Write(header)
+		}
+		for _, e := range events {
+			_ = cw.Write(auditRow(e))
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w.out, 0, 4, 2, ' ', 0)
+	if !w.header {
+		w.header = true
+		fmt.Fprintln(tw, strings.Join(auditColumns, "\t"))
+	}
+	for _, e := range events {
+		row := auditRow(e)
+		for i := range row {
+			row[i] = orDash(row[i])
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,415 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	"github.com/spf13/cobra"
+)
+
+// BenchOptions configures RunBench.
+type BenchOptions struct {
+	// Addr is the base URL of a running server; empty serves the requests
+	// in-process through the runtime handler.
+	Addr      string
+	Context   string
+	Component string
+	TenantID  string
+	// Queries are sent round-robin.
+	Queries     []string
+	Concurrency int
+	Duration    time.Duration
+	// Requests stops the run after this many requests; 0 runs for Duration.
+	Requests int
+	// Handler overrides the in-process handler (used by tests).
+	Handler http.Handler
+}
+
+// LatencyStats summarizes a set of durations in milliseconds.
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// BenchReport is the ctx.bench/v1 payload, also written to tests/reports/bench.json.
+type BenchReport struct {
+	Target      string  `json:"target"` // in-process or the server URL
+	Context     string  `json:"context"`
+	Component   string  `json:"component"`
+	Concurrency int     `json:"concurrency"`
+	DurationMs  int64   `json:"duration_ms"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	ErrorRate   float64 `json:"error_rate"`
+	Throughput  float64 `json:"requests_per_sec"`
+	// TokensPerSec counts completion tokens reported by the server.
+	TokensPerSec float64        `json:"tokens_per_sec"`
+	Latency      LatencyStats   `json:"latency"`
+	StatusCodes  map[string]int `json:"status_codes"`
+	// Stages breaks successful requests down by pipeline stage (memory,
+	// render, inference) from the server's Server-Timing header.
+	Stages map[string]LatencyStats `json:"stages,omitempty"`
+	// ErrorSamples holds the first distinct error messages.
+	ErrorSamples []string `json:"error_samples,omitempty"`
+}
+
+// BenchGates fail a run whose results exceed them; zero values are not checked.
+type BenchGates struct {
+	MaxP95       time.Duration
+	MaxErrorRate float64
+}
+
+// Check returns an error naming every gate the report fails.
+func (g BenchGates) Check(rep BenchReport) error {
+	var failed []string
+	if g.MaxP95 > 0 && rep.Latency.P95 > float64(g.MaxP95.Microseconds())/1000 {
+		failed = append(failed, fmt.Sprintf("p95 latency %.1fms exceeds %s", rep.Latency.P95, g.MaxP95))
+	}
+	if g.MaxErrorRate > 0 && rep.ErrorRate > g.MaxErrorRate {
+		failed = append(failed, fmt.Sprintf("error rate %.3f exceeds %.3f", rep.ErrorRate, g.MaxErrorRate))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("performance gate failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// GetBenchCommand returns the `bench` command.
+func GetBenchCommand() *cobra.Command {
+	var (
+		opts        BenchOptions
+		queriesPath string
+		outPath     string
+		gates       BenchGates
+	)
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Load-test the chat endpoint and report latency percentiles",
+		Long: `Send the queries of a file (one per line) to the chat endpoint from
+--concurrency workers for --duration, or until --requests were sent, and report
+latency percentiles, error rate, throughput and tokens per second, broken down by
+pipeline stage. Requests are served in-process unless --addr names a running server
+(set CMP_API_KEY when it requires authentication).
+
+The report is written to tests/reports/bench.json; --max-p95 and --max-error-rate
+fail the command for CI performance gates.`,
+		Example: `  ctx bench --component SupportBot --concurrency 20 --duration 60s --queries queries.txt
+  ctx bench --component SupportBot --queries queries.txt --addr http://localhost:8000 --max-p95 800ms`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := mustGetwd()
+			queries, err := readBenchQueries(queriesPath)
+			if err != nil {
+				return err
+			}
+			opts.Queries = queries
+			rep, err := RunBench(cmd.Context(), root, opts)
+			if err != nil {
+				return err
+			}
+			if outPath == "" {
+				outPath = filepath.Join(root, "tests", "reports", "bench.json")
+			}
+			if err := writeJSONFile(outPath, rep); err != nil {
+				return err
+			}
+			gateErr := gates.Check(rep)
+			if ok, err := EmitResult(cmd, SchemaBench, rep, gateErr); ok {
+				if err != nil {
+					return err
+				}
+				return gateErr
+			}
+			printBenchReport(cmd.OutOrStdout(), rep)
+			fmt.Fprintf(cmd.OutOrStdout(), "Report written to %s\n", outPath)
+			return gateErr
+		},
+	}
+	cmd.Flags().StringVar(&opts.Addr, "addr", "", "Base URL of a running server (default: serve in-process)")
+	cmd.Flags().StringVar(&opts.Context, "context", "", "Context name (default: the component)")
+	cmd.Flags().StringVar(&opts.Component, "component", "", "Component to query")
+	cmd.Flags().StringVar(&opts.TenantID, "tenant", "", "Tenant ID")
+	cmd.Flags().StringVar(&queriesPath, "queries", "", "File with one query per line (# starts a comment)")
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 10, "Concurrent workers")
+	cmd.Flags().DurationVar(&opts.Duration, "duration", 30*time.Second, "How long to send requests")
+	cmd.Flags().IntVar(&opts.Requests, "requests", 0, "Stop after this many requests (0: run for --duration)")
+	cmd.Flags().StringVar(&outPath, "out", "", "Report path (default tests/reports/bench.json)")
+	cmd.Flags().DurationVar(&gates.MaxP95, "max-p95", 0, "Fail when the p95 latency exceeds this")
+	cmd.Flags().Float64Var(&gates.MaxErrorRate, "max-error-rate", 0, "Fail when the error rate exceeds this fraction")
+	_ = cmd.MarkFlagRequired("queries")
+	return cmd
+}
+
+// readBenchQueries returns the non-empty, non-comment lines of path.
+func readBenchQueries(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var queries []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			queries = append(queries, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries in %s", path)
+	}
+	return queries, nil
+}
+
+// benchSample is the outcome of one request.
+type benchSample struct {
+	latency time.Duration
+	status  int
+	err     string
+	tokens  int
+	stages  map[string]float64
+}
+
+// RunBench sends opts.Queries to the chat endpoint until opts.Duration
+// elapses or opts.Requests were sent, and summarizes the responses.
+func RunBench(ctx context.Context, projectRoot string, opts BenchOptions) (BenchReport, error) {
+	if len(opts.Queries) == 0 {
+		return BenchReport{}, fmt.Errorf("no queries to send")
+	}
+	if opts.Component == "" && opts.Context == "" {
+		return BenchReport{}, fmt.Errorf("--component or --context is required")
+	}
+	if opts.Context == "" {
+		opts.Context = opts.Component
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return BenchReport{}, fmt.Errorf("--duration or --requests must be positive")
+	}
+	send, target := benchSender(projectRoot, opts)
+
+	runCtx := ctx
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	var (
+		next    int64 = -1
+		mu      sync.Mutex
+		samples []benchSample
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				n := atomic.AddInt64(&next, 1)
+				if opts.Requests > 0 && n >= int64(opts.Requests) {
+					return
+				}
+				s := send(runCtx, opts.Queries[n%int64(len(opts.Queries))])
+				// Requests still running at the end of the run are not counted
+				if runCtx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				samples = append(samples, s)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	rep := summarizeBench(samples, elapsed)
+	rep.Target, rep.Context, rep.Component, rep.Concurrency = target, opts.Context, opts.Component, opts.Concurrency
+	return rep, nil
+}
+
+// benchSender returns the function sending one query, in-process or to opts.Addr.
+func benchSender(projectRoot string, opts BenchOptions) (func(context.Context, string) benchSample, string) {
+	do := func(req *http.Request) (*http.Response, error) { return http.DefaultClient.Do(req) }
+	target := strings.TrimRight(opts.Addr, "/")
+	if target == "" {
+		handler := opts.Handler
+		if handler == nil {
+			handler = runtimeserver.NewHandler(projectRoot)
+		}
+		do = func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec.Result(), nil
+		}
+	}
+	send := func(ctx context.Context, query string) benchSample {
+		body, _ := json.Marshal(runtimeserver.ChatRequest{
+			TenantID: opts.TenantID, Context: opts.Context, Component: opts.Component, Query: query,
+			Data: map[string]interface{}{"user_input": query}, PromptFile: "agent_response.md",
+		})
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target+"/api/v1/chat", bytes.NewReader(body))
+		if err != nil {
+			return benchSample{err: err.Error()}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key := os.Getenv("CMP_API_KEY"); key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		start := time.Now()
+		resp, err := do(req)
+		if err != nil {
+			return benchSample{latency: time.Since(start), err: err.Error()}
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		s := benchSample{latency: time.Since(start), status: resp.StatusCode}
+		if resp.StatusCode != http.StatusOK {
+			s.err = fmt.Sprintf("%d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+			return s
+		}
+		var chat runtimeserver.ChatResponse
+		if json.Unmarshal(respBody, &chat) == nil && chat.Usage != nil {
+			s.tokens = chat.Usage.CompletionTokens
+		}
+		s.stages = parseServerTiming(resp.Header.Get("Server-Timing"))
+		return s
+	}
+	if target == "" {
+		return send, "in-process"
+	}
+	return send, target
+}
+
+// parseServerTiming returns the dur of each metric of a Server-Timing header.
+func parseServerTiming(header string) map[string]float64 {
+	out := map[string]float64{}
+	for _, metric := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(metric), ";")
+		for _, p := range params[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "dur="); ok {
+				if d, err := strconv.ParseFloat(v, 64); err == nil && params[0] != "" {
+					out[params[0]] = d
+				}
+			}
+		}
+	}
+	return out
+}
+
+func summarizeBench(samples []benchSample, elapsed time.Duration) BenchReport {
+	rep := BenchReport{DurationMs: elapsed.Milliseconds(), Requests: len(samples), StatusCodes: map[string]int{}}
+	var latencies []float64
+	stages := map[string][]float64{}
+	tokens := 0
+	for _, s := range samples {
+		latencies = append(latencies, float64(s.latency.Microseconds())/1000)
+		code := "error"
+		if s.status != 0 {
+			code = strconv.Itoa(s.status)
+		}
+		rep.StatusCodes[code]++
+		if s.err != "" {
+			rep.Errors++
+			if len(rep.ErrorSamples) < 5 && !slices.Contains(rep.ErrorSamples, s.err) {
+				rep.ErrorSamples = append(rep.ErrorSamples, s.err)
+			}
+			continue
+		}
+		tokens += s.tokens
+		for name, d := range s.stages {
+			stages[name] = append(stages[name], d)
+		}
+	}
+	rep.Latency = latencyStats(latencies)
+	if len(stages) > 0 {
+		rep.Stages = make(map[string]LatencyStats, len(stages))
+		for name, ds := range stages {
+			rep.Stages[name] = latencyStats(ds)
+		}
+	}
+	if rep.Requests > 0 {
+		rep.ErrorRate = float64(rep.Errors) / float64(rep.Requests)
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		rep.Throughput = float64(rep.Requests) / secs
+		rep.TokensPerSec = float64(tokens) / secs
+	}
+	return rep
+}
+
+// latencyStats returns nearest-rank percentiles of ms.
+func latencyStats(ms []float64) LatencyStats {
+	st := LatencyStats{Count: len(ms)}
+	if len(ms) == 0 {
+		return st
+	}
+	sorted := append([]float64(nil), ms...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	st.Mean = sum / float64(len(sorted))
+	st.P50, st.P90, st.P95, st.P99 = rank(0.50), rank(0.90), rank(0.95), rank(0.99)
+	st.Max = sorted[len(sorted)-1]
+	return st
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	by, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, by, 0o644)
+}
+
+func printBenchReport(out io.Writer, rep BenchReport) {
+	fmt.Fprintf(out, "Benchmark: %s (%s), %d workers, %s\n", rep.Component, rep.Target, rep.Concurrency, time.Duration(rep.DurationMs)*time.Millisecond)
+	fmt.Fprintf(out, "   Requests: %d (%.1f/s), errors: %d (%.1f%%)\n", rep.Requests, rep.Throughput, rep.Errors, rep.ErrorRate*100)
+	fmt.Fprintf(out, "   Tokens: %.1f/s\n", rep.TokensPerSec)
+	fmt.Fprintf(out, "   Latency: p50 %.1fms  p90 %.1fms  p95 %.1fms  p99 %.1fms  max %.1fms\n", rep.Latency.P50, rep.Latency.P90, rep.Latency.P95, rep.Latency.P99, rep.Latency.Max)
+	for _, name := range []string{"memory", "render", "inference"} {
+		if st, ok := rep.Stages[name]; ok {
+			fmt.Fprintf(out, "   %-9s  p50 %.1fms  p95 %.1fms  max %.1fms\n", name+":", st.P50, st.P95, st.Max)
+		}
+	}
+	for _, e := range rep.ErrorSamples {
+		fmt.Fprintf(out, "   error: %s\n", e)
+	}
+}
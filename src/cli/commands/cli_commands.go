@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
 	"github.com/spf13/cobra"
 )
 
@@ -118,15 +119,33 @@ func GetWorkflowCommand() *cobra.Command {
 	return workflowCmd
 }
 
-// GetVersionCommand returns the version command
+// GetVersionCommand returns the `version` command, which prints the build
+// metadata also served at /version.
 func GetVersionCommand() *cobra.Command {
-	versionCmd := &cobra.Command{
+	return &cobra.Command{
 		Use:   "version",
 		Short: "Show version information",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Contexis CMP Framework v0.2.0")
+		Long: `Show the version, commit, build date and Go version of this ctx binary.
+Use --output json or yaml for the same fields a running server returns from /version.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := buildinfo.Get()
+			if ok, err := EmitResult(cmd, SchemaVersion, info, nil); ok {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			commit := info.Commit
+			if info.Modified {
+				commit += " (modified)"
+			}
+			fmt.Fprintf(out, "Contexis CMP Framework v%s\n", info.Version)
+			fmt.Fprintf(out, "  commit:     %s\n", commit)
+			fmt.Fprintf(out, "  built:      %s\n", info.Date)
+			fmt.Fprintf(out, "  go:         %s %s\n", info.GoVersion, info.Platform)
+			if info.FrameworkVersion != info.Version {
+				fmt.Fprintf(out, "  framework:  %s\n", info.FrameworkVersion)
+			}
+			return nil
 		},
 	}
-
-	return versionCmd
 }
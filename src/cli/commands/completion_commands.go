@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	"github.com/spf13/cobra"
+)
+
+// GetCompletionCommand returns `ctx completion`, which prints the shell
+// completion script. It replaces cobra's default completion command so the
+// help can show how to install the script.
+func GetCompletionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion [bash|zsh|fish|powershell]",
+		Short: "Generate the shell completion script",
+		Long: `Print the completion script for your shell. Besides commands and flags it
+completes context and component names from the project in the current directory,
+tenants, memory and migration providers, and generator types.
+
+Bash (requires the bash-completion package):
+  source <(ctx completion bash)
+  ctx completion bash > /etc/bash_completion.d/ctx
+
+Zsh:
+  ctx completion zsh > "${fpath[1]}/_ctx"
+  (run "autoload -U compinit; compinit" once if completion is not enabled yet)
+
+Fish:
+  ctx completion fish > ~/.config/fish/completions/ctx.fish
+
+PowerShell:
+  ctx completion powershell | Out-String | Invoke-Expression
+  (add the line to $PROFILE to load it in every session)`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, out := cmd.Root(), cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			default:
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+		},
+	}
+}
+
+// completer lists the candidate values of an argument or flag.
+type completer func(cmd *cobra.Command) []string
+
+// argCompletions maps a command path below the root to the completer of
+// each positional argument.
+var argCompletions = map[string][]completer{
+	"run":                   {completeContexts},
+	"destroy":               {completeComponents},
+	"generate":              {staticValues("rag", "agent", "workflow", "plugin", "context")},
+	"context validate":      {completeContexts},
+	"context explain":       {completeContexts},
+	"context tenant add":    {completeTenants, completeContexts},
+	"context tenant list":   {completeTenants},
+	"context tenant diff":   {completeTenants, completeContexts},
+	"context tenant remove": {completeTenants, completeContexts},
+}
+
+// flagCompletions maps a top-level command to the completers of its flags
+// whose values depend on the command, such as --provider.
+var flagCompletions = map[string]map[string]completer{
+	"memory":   {"provider": staticValues("sqlite", "episodic"), "context": completeContexts},
+	"migrate":  {"provider": staticValues("openai", "anthropic", "huggingface")},
+	"generate": {"db": staticValues("sqlite", "postgres", "chroma")},
+}
+
+// RegisterCompletions adds dynamic completion to root and every command
+// below it. --component and --tenant complete from the project in the
+// working directory wherever they appear.
+func RegisterCompletions(root *cobra.Command) {
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		path := strings.TrimPrefix(strings.TrimPrefix(c.CommandPath(), root.Name()), " ")
+		if args, ok := argCompletions[path]; ok && c.ValidArgsFunction == nil {
+			c.ValidArgsFunction = completeArgs(args)
+		}
+		flags := map[string]completer{"component": completeComponents, "tenant": completeTenants}
+		for name, fn := range flagCompletions[strings.SplitN(path, " ", 2)[0]] {
+			flags[name] = fn
+		}
+		for name, fn := range flags {
+			if c.Flags().Lookup(name) == nil {
+				continue
+			}
+			fn := fn
+			// Fails only when a completion is already registered, which wins
+			_ = c.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return withPrefix(fn(cmd), toComplete), cobra.ShellCompDirectiveNoFileComp
+			})
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
+
+// completeArgs completes the positional argument at len(args) with its
+// completer; arguments past the list get no suggestions.
+func completeArgs(completers []completer) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) >= len(completers) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return withPrefix(completers[len(args)](cmd), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func staticValues(values ...string) completer {
+	return func(*cobra.Command) []string { return values }
+}
+
+// completeContexts lists the contexts `ctx run` can resolve, including the
+// overrides of the tenant given with --tenant.
+func completeContexts(cmd *cobra.Command) []string {
+	tenant := ""
+	if f := cmd.Flags().Lookup("tenant"); f != nil {
+		tenant = f.Value.String()
+	}
+	names, _ := runtimecontext.NewContextService(mustGetwd()).ContextNames(tenant)
+	return names
+}
+
+// completeComponents lists the components generated into the project: the
+// directories under contexts/, prompts/ and memory/.
+func completeComponents(*cobra.Command) []string {
+	root := mustGetwd()
+	seen := map[string]bool{}
+	for _, dir := range []string{"contexts", "prompts", "memory"} {
+		for _, name := range subdirs(filepath.Join(root, dir)) {
+			if _, reserved := reservedComponentDirs[strings.ToLower(name)]; !reserved {
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeTenants lists the tenants with overrides under contexts/tenants.
+func completeTenants(*cobra.Command) []string {
+	return subdirs(filepath.Join(mustGetwd(), "contexts", "tenants"))
+}
+
+func subdirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// withPrefix keeps the candidates starting with toComplete; completion
+// matches are case-sensitive like the names on disk.
+func withPrefix(candidates []string, toComplete string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, toComplete) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
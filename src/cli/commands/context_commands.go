@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
 	"github.com/spf13/cobra"
@@ -13,11 +15,15 @@ import (
 func GetContextCommand(projectRoot string) *cobra.Command {
 	ctxCmd := &cobra.Command{
 		Use:   "context",
-		Short: "Context operations (validate, reload)",
+		Short: "Context operations (validate, explain, reload, tenant, rollout, migrate-schema)",
 	}
 
 	ctxCmd.AddCommand(newContextValidateCmd(projectRoot))
+	ctxCmd.AddCommand(newContextExplainCmd(projectRoot))
 	ctxCmd.AddCommand(newContextReloadCmd(projectRoot))
+	ctxCmd.AddCommand(newContextTenantCmd(projectRoot))
+	ctxCmd.AddCommand(newContextRolloutCmd(projectRoot))
+	ctxCmd.AddCommand(newContextMigrateSchemaCmd(projectRoot))
 	return ctxCmd
 }
 
@@ -47,6 +53,72 @@ func newContextValidateCmd(projectRoot string) *cobra.Command {
 	return cmd
 }
 
+// newContextExplainCmd prints a context after extends/include merging, with the
+// file behind each field and any conflicting overrides.
+func newContextExplainCmd(projectRoot string) *cobra.Command {
+	var (
+		tenantID string
+		asJSON   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "explain [contextName]",
+		Short: "Show the merged context and which file set each field",
+		Args:  cobra.ExactArgs(1),
+		Example: `  ctx context explain SupportBot
+  ctx context explain SupportBot --tenant acme --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if projectRoot == "" {
+				cwd, _ := os.Getwd()
+				projectRoot = cwd
+			}
+			ex, err := runtimecontext.NewContextService(projectRoot).Explain(tenantID, args[0])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(ex)
+			}
+			merged, err := ex.AnnotatedYAML()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Context '%s'", ex.Context)
+			if ex.TenantID != "" {
+				fmt.Fprintf(out, " (tenant %s)", ex.TenantID)
+			}
+			fmt.Fprintf(out, " resolved from %s\n\nFiles:\n", ex.File)
+			depth := map[string]int{}
+			for _, f := range ex.Files {
+				if f.Parent != "" {
+					depth[f.File] = depth[f.Parent] + 1
+				}
+				if f.Via == "context" {
+					fmt.Fprintf(out, "  %s\n", f.File)
+					continue
+				}
+				fmt.Fprintf(out, "  %s%s %s\n", strings.Repeat("  ", depth[f.File]), f.Via, f.File)
+			}
+			fmt.Fprintf(out, "\nMerged context:\n%s", merged)
+			if len(ex.Conflicts) > 0 {
+				fmt.Fprintln(out, "\nConflicts:")
+				for _, c := range ex.Conflicts {
+					fmt.Fprintf(out, "  %s: %s\n", c.Path, c.Message)
+				}
+			}
+			if ex.Error != "" {
+				return fmt.Errorf("context '%s' is invalid: %s", ex.Context, ex.Error)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenantID, "tenant", "", "Tenant ID for tenant-specific context resolution")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the explanation as JSON")
+	return cmd
+}
+
 func newContextReloadCmd(projectRoot string) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "reload",
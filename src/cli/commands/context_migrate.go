@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	coreval "github.com/contexis-cmp/contexis/src/core/schema"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// newContextMigrateSchemaCmd upgrades .ctx files written for older schema
+// versions, like `ctx upgrade` does for the scaffolded config files.
+func newContextMigrateSchemaCmd(projectRoot string) *cobra.Command {
+	var dryRun, yes bool
+	cmd := &cobra.Command{
+		Use:   "migrate-schema [contextName...]",
+		Short: "Upgrade .ctx files to the current schema version",
+		Long: fmt.Sprintf(`Rewrites the .ctx files under contexts/ (tenant overrides included) that
+declare an older schema_version, or none, to schema_version %d. Keys the
+older schema accepted but ignored are removed and each change is explained;
+comments and formatting are kept. Without arguments every context is
+migrated. The diff is shown and confirmed before any file is written.`, coreval.CurrentContextVersion),
+		Example: `  ctx context migrate-schema --dry-run
+  ctx context migrate-schema SupportBot --yes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if projectRoot == "" {
+				cwd, _ := os.Getwd()
+				projectRoot = cwd
+			}
+			out := cmd.OutOrStdout()
+			plan, err := PlanContextSchemaMigration(projectRoot, args)
+			if err != nil {
+				return err
+			}
+			for _, n := range plan.Notes {
+				fmt.Fprintf(out, "       note  %s\n", n)
+			}
+			changes := plan.Changes()
+			if len(changes) == 0 {
+				fmt.Fprintf(out, "contexts are up to date (schema_version %d)\n", coreval.CurrentContextVersion)
+				return nil
+			}
+			fmt.Fprint(out, plan.Diff())
+			if dryRun {
+				fmt.Fprintln(out, "dry run: no files were changed")
+				return nil
+			}
+			if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("Migrate %d context file(s) to schema_version %d? [y/N]: ", len(changes), coreval.CurrentContextVersion)) {
+				fmt.Fprintln(out, "aborted")
+				return nil
+			}
+			if err := plan.Apply(); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Migrated %d context file(s) to schema_version %d\n", len(changes), coreval.CurrentContextVersion)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the diff without changing any file")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
+
+// PlanContextSchemaMigration stages the migration of the .ctx files under
+// contexts/ to coreval.CurrentContextVersion, limited to the named contexts
+// when names is not empty. Notes are prefixed with the file they concern and
+// include migrated files that still fail validation. The plan's From and To
+// are not set.
+func PlanContextSchemaMigration(projectRoot string, names []string) (*UpgradePlan, error) {
+	p := &UpgradePlan{Root: projectRoot, files: map[string]*FileChange{}}
+	dir := filepath.Join(projectRoot, "contexts")
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("no contexts/ directory in %s", projectRoot)
+	}
+	matched := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() || filepath.Ext(path) != ".ctx" {
+			return err
+		}
+		rel := relPath(projectRoot, path)
+		tenant := strings.HasPrefix(rel, "contexts/tenants/")
+		if len(names) > 0 {
+			name := matchContextName(names, rel, tenant)
+			if name == "" {
+				return nil
+			}
+			matched[name] = true
+		}
+		by, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		res, err := coreval.MigrateContext(by)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if res.From == res.To {
+			return nil
+		}
+		for _, n := range res.Notes {
+			p.Notes = append(p.Notes, rel+": "+n)
+		}
+		// Tenant overrides are partial, so only full contexts are validated
+		if !tenant {
+			var m map[string]interface{}
+			err := yaml.Unmarshal(res.Content, &m)
+			if err == nil {
+				err = coreval.ValidateContextMap(m)
+			}
+			if err != nil {
+				p.Notes = append(p.Notes, rel+": still invalid after migration: "+err.Error())
+			}
+		}
+		return p.write(rel, res.Content)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if !matched[strings.ToLower(n)] {
+			return nil, fmt.Errorf("no .ctx files found for context %q", n)
+		}
+	}
+	return p, nil
+}
+
+// matchContextName returns the lowercased name of names that a context file
+// belongs to: its contexts/<Name>/ directory, or the file name of a tenant
+// override.
+func matchContextName(names []string, rel string, tenant bool) string {
+	name := strings.TrimSuffix(strings.Split(rel, "/")[1], ".ctx")
+	if tenant {
+		name = strings.TrimSuffix(filepath.Base(rel), ".ctx")
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return strings.ToLower(n)
+		}
+	}
+	return ""
+}
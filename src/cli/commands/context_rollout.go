@@ -0,0 +1,266 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	runtimerollout "github.com/contexis-cmp/contexis/src/runtime/rollout"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+	"github.com/spf13/cobra"
+)
+
+// newContextRolloutCmd returns the `rollout` subcommand for blue/green
+// rollouts of registered context versions (config/rollouts.yaml).
+func newContextRolloutCmd(projectRoot string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "Roll out context versions (register, start, shift, status, promote, rollback)",
+		Long: `A rollout serves a registered version of a context (contexts/<Name>/versions)
+and sends a percentage of chat traffic to a candidate version. Running servers
+pick up changes to ` + runtimerollout.ConfigFile + ` without a restart.`,
+	}
+	root := func() string {
+		if projectRoot == "" {
+			cwd, _ := os.Getwd()
+			return cwd
+		}
+		return projectRoot
+	}
+	cmd.AddCommand(newContextRolloutRegisterCmd(root))
+	cmd.AddCommand(newContextRolloutStartCmd(root))
+	cmd.AddCommand(newContextRolloutShiftCmd(root))
+	cmd.AddCommand(newContextRolloutStatusCmd(root))
+	cmd.AddCommand(newContextRolloutPromoteCmd(root))
+	cmd.AddCommand(newContextRolloutRollbackCmd(root))
+	return cmd
+}
+
+func newContextRolloutRegisterCmd(root func() string) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "register [contextName] [file]",
+		Short: "Register a context file as a version (default: the current context file)",
+		Args:  cobra.RangeArgs(1, 2),
+		Example: `  ctx context rollout register SupportBot
+  ctx context rollout register SupportBot drafts/support_bot_v1.3.ctx`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file := ""
+			if len(args) == 2 {
+				file = args[1]
+			}
+			svc := runtimecontext.NewContextService(root())
+			version, err := svc.RegisterVersion(args[0], file, force)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Registered version %s of %s in %s\n", version, args[0], svc.VersionPath(args[0], version))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Replace a version that is already registered")
+	return cmd
+}
+
+func newContextRolloutStartCmd(root func() string) *cobra.Command {
+	var (
+		stable    string
+		candidate string
+		percent   string
+		assignBy  string
+	)
+	cmd := &cobra.Command{
+		Use:   "start [contextName]",
+		Short: "Send a percentage of traffic to a candidate version",
+		Args:  cobra.ExactArgs(1),
+		Example: `  ctx context rollout start SupportBot --stable 1.2.0 --candidate 1.3.0 --percent 10
+  ctx context rollout start SupportBot --candidate 1.4.0 --percent 5 --assign-by user`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pct, err := parsePercent(percent)
+			if err != nil {
+				return err
+			}
+			return updateRollouts(cmd, root(), args[0], func(cfg *runtimerollout.Config) error {
+				return cfg.Start(args[0], stable, candidate, pct, assignBy, time.Now())
+			})
+		},
+	}
+	cmd.Flags().StringVar(&stable, "stable", "", "Version serving the remaining traffic (default: the current stable version)")
+	cmd.Flags().StringVar(&candidate, "candidate", "", "Version to roll out")
+	cmd.Flags().StringVar(&percent, "percent", "10", "Share of traffic sent to the candidate")
+	cmd.Flags().StringVar(&assignBy, "assign-by", "", "Request attribute that keeps a caller on one version: session, user or tenant")
+	_ = cmd.MarkFlagRequired("candidate")
+	return cmd
+}
+
+func newContextRolloutShiftCmd(root func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "shift [contextName] [percent]",
+		Short:   "Change the share of traffic sent to the candidate",
+		Args:    cobra.ExactArgs(2),
+		Example: `  ctx context rollout shift SupportBot 50%`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pct, err := parsePercent(args[1])
+			if err != nil {
+				return err
+			}
+			return updateRollouts(cmd, root(), args[0], func(cfg *runtimerollout.Config) error {
+				return cfg.Shift(args[0], pct, time.Now())
+			})
+		},
+	}
+}
+
+func newContextRolloutPromoteCmd(root func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "promote [contextName]",
+		Short: "Make the candidate the stable version for all traffic",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateRollouts(cmd, root(), args[0], func(cfg *runtimerollout.Config) error {
+				_, err := cfg.Promote(args[0], time.Now())
+				return err
+			})
+		},
+	}
+}
+
+func newContextRolloutRollbackCmd(root func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback [contextName]",
+		Short: "Drop the candidate and serve the stable version to all traffic",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateRollouts(cmd, root(), args[0], func(cfg *runtimerollout.Config) error {
+				_, err := cfg.Rollback(args[0], time.Now())
+				return err
+			})
+		},
+	}
+}
+
+// updateRollouts applies change to config/rollouts.yaml and prints the
+// resulting rollout of contextName.
+func updateRollouts(cmd *cobra.Command, root, contextName string, change func(*runtimerollout.Config) error) error {
+	cfg, err := runtimerollout.LoadConfig(root)
+	if err != nil {
+		return err
+	}
+	if err := change(cfg); err != nil {
+		return err
+	}
+	if err := cfg.Save(root); err != nil {
+		return err
+	}
+	r := cfg.Get(contextName)
+	out := cmd.OutOrStdout()
+	if r.Candidate == "" {
+		fmt.Fprintf(out, "%s serves version %s to all traffic\n", r.Context, r.Stable)
+		return nil
+	}
+	fmt.Fprintf(out, "%s serves version %s to %d%% and %s to %d%% of traffic\n", r.Context, r.Candidate, r.Percent, r.Stable, 100-r.Percent)
+	return nil
+}
+
+// parsePercent accepts "25" and "25%".
+func parsePercent(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("invalid percent %q (want 0-100)", s)
+	}
+	return n, nil
+}
+
+// rolloutStatus is the JSON form of `ctx context rollout status`.
+type rolloutStatus struct {
+	Rollouts []runtimerollout.Rollout `json:"rollouts"`
+	Versions []runtimerollout.Summary `json:"versions"`
+}
+
+func newContextRolloutStatusCmd(root func() string) *cobra.Command {
+	var (
+		from   string
+		to     string
+		asJSON bool
+	)
+	cmd := &cobra.Command{
+		Use:   "status [contextName]",
+		Short: "Show rollouts with the error rate, latency and eval score of each version",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `  ctx context rollout status
+  ctx context rollout status SupportBot --from 2025-01-01 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			contextName := ""
+			if len(args) == 1 {
+				contextName = args[0]
+			}
+			start, end, err := runtimeusage.ParseRange(from, to)
+			if err != nil {
+				return err
+			}
+			cfg, err := runtimerollout.LoadConfig(root())
+			if err != nil {
+				return err
+			}
+			outcomes, err := runtimerollout.NewLog(root()).Query(runtimerollout.Filter{From: start, To: end, Context: contextName})
+			if err != nil {
+				return err
+			}
+			status := rolloutStatus{Rollouts: []runtimerollout.Rollout{}, Versions: runtimerollout.Summarize(outcomes)}
+			for _, r := range cfg.Rollouts {
+				if contextName == "" || r.Context == contextName {
+					status.Rollouts = append(status.Rollouts, r)
+				}
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(status)
+			}
+			if len(status.Rollouts) == 0 {
+				fmt.Fprintf(out, "no rollouts in %s\n", runtimerollout.ConfigFile)
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "CONTEXT\tSTABLE\tCANDIDATE\tCANDIDATE TRAFFIC\tUPDATED")
+			for _, r := range status.Rollouts {
+				updated := "-"
+				if !r.Updated.IsZero() {
+					updated = r.Updated.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d%%\t%s\n", r.Context, r.Stable, orDash(r.Candidate), r.Percent, updated)
+			}
+			if len(status.Versions) > 0 {
+				fmt.Fprintln(tw, "\nCONTEXT\tVERSION\tROLE\tREQUESTS\tERROR RATE\tAVG LATENCY\tEVAL SCORE")
+				for _, s := range status.Versions {
+					role := "-"
+					if r := cfg.Get(s.Context); r != nil {
+						switch s.Version {
+						case r.Stable:
+							role = runtimerollout.Stable
+						case r.Candidate:
+							role = runtimerollout.Candidate
+						}
+					}
+					score := "-"
+					if s.Scored > 0 {
+						score = fmt.Sprintf("%.3f (%d)", s.AvgEvalScore, s.Scored)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f%%\t%.0fms\t%s\n",
+						s.Context, s.Version, role, s.Requests, s.ErrorRate*100, s.AvgLatencyMS, score)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Start date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "End date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the status as JSON")
+	return cmd
+}
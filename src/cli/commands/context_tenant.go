@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	"github.com/spf13/cobra"
+)
+
+// newContextTenantCmd returns the `tenant` subcommand for per-tenant context
+// overrides in contexts/tenants/<tenant>/<context>.ctx.
+func newContextTenantCmd(projectRoot string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tenant",
+		Short: "Manage per-tenant context overrides (add, list, diff, remove)",
+		Long: `Tenant overrides extend a base context and may only change these fields:
+  ` + strings.Join(runtimecontext.TenantOverridableFields, ", "),
+	}
+	root := func() string {
+		if projectRoot == "" {
+			cwd, _ := os.Getwd()
+			return cwd
+		}
+		return projectRoot
+	}
+	cmd.AddCommand(newContextTenantAddCmd(root))
+	cmd.AddCommand(newContextTenantListCmd(root))
+	cmd.AddCommand(newContextTenantDiffCmd(root))
+	cmd.AddCommand(newContextTenantRemoveCmd(root))
+	return cmd
+}
+
+func newContextTenantAddCmd(root func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "add [tenant] [contextName]",
+		Short:   "Scaffold a tenant override that extends the base context",
+		Args:    cobra.ExactArgs(2),
+		Example: `  ctx context tenant add acme SupportBot`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc := runtimecontext.NewContextService(root())
+			path, err := svc.ScaffoldTenantOverride(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Created %s\nEdit it, then check the result with: ctx context tenant diff %s %s\n", path, args[0], args[1])
+			return nil
+		},
+	}
+}
+
+func newContextTenantListCmd(root func() string) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "list [tenant]",
+		Short: "List tenant overrides and check that each is valid",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tenantID := ""
+			if len(args) == 1 {
+				tenantID = args[0]
+			}
+			overrides, err := runtimecontext.NewContextService(root()).ListTenantOverrides(tenantID)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(overrides)
+			}
+			if len(overrides) == 0 {
+				fmt.Fprintln(out, "no tenant overrides in contexts/tenants")
+				return nil
+			}
+			invalid := 0
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TENANT\tCONTEXT\tOVERRIDES\tSTATUS")
+			for _, o := range overrides {
+				status := "ok"
+				switch {
+				case o.Error != "":
+					status = "invalid: " + o.Error
+				case len(o.Violations) > 0:
+					status = "not allowed: " + strings.Join(o.Violations, ", ")
+				}
+				if !o.Valid() {
+					invalid++
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", o.TenantID, o.Context, len(o.Fields), status)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if invalid > 0 {
+				return fmt.Errorf("%d of %d tenant overrides are invalid", invalid, len(overrides))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the overrides as JSON")
+	return cmd
+}
+
+func newContextTenantDiffCmd(root func() string) *cobra.Command {
+	var (
+		asJSON    bool
+		effective bool
+	)
+	cmd := &cobra.Command{
+		Use:   "diff [tenant] [contextName]",
+		Short: "Show how a tenant's effective context differs from the base",
+		Args:  cobra.ExactArgs(2),
+		Example: `  ctx context tenant diff acme SupportBot
+  ctx context tenant diff acme SupportBot --effective`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc := runtimecontext.NewContextService(root())
+			o, err := svc.CheckTenantOverride(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			changes, err := svc.DiffTenant(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(struct {
+					*runtimecontext.TenantOverride
+					Changes []runtimecontext.FieldChange `json:"changes"`
+				}{o, changes}); err != nil {
+					return err
+				}
+			} else {
+				if len(changes) == 0 {
+					fmt.Fprintf(out, "Tenant %s uses '%s' unchanged\n", o.TenantID, o.Context)
+				}
+				for _, c := range changes {
+					switch c.Kind {
+					case "added":
+						fmt.Fprintf(out, "+ %s: %s\n", c.Path, diffValue(c.Tenant))
+					case "removed":
+						fmt.Fprintf(out, "- %s: %s\n", c.Path, diffValue(c.Base))
+					default:
+						fmt.Fprintf(out, "~ %s: %s -> %s\n", c.Path, diffValue(c.Base), diffValue(c.Tenant))
+					}
+				}
+				if effective {
+					ex, err := svc.Explain(args[0], args[1])
+					if err != nil {
+						return err
+					}
+					merged, err := ex.AnnotatedYAML()
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(out, "\nEffective context:\n%s", merged)
+				}
+			}
+			if o.Error != "" {
+				return fmt.Errorf("tenant override %s is invalid: %s", o.File, o.Error)
+			}
+			if len(o.Violations) > 0 {
+				return fmt.Errorf("tenant override %s sets fields tenants may not override: %s", o.File, strings.Join(o.Violations, ", "))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the override check and changes as JSON")
+	cmd.Flags().BoolVar(&effective, "effective", false, "Also print the tenant's effective context")
+	return cmd
+}
+
+func diffValue(v interface{}) string {
+	by, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(by)
+}
+
+func newContextTenantRemoveCmd(root func() string) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "remove [tenant] [contextName]",
+		Short: "Delete a tenant override; the tenant falls back to the base context",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("Remove the '%s' override for tenant %s? [y/N]: ", args[1], args[0])) {
+				fmt.Fprintln(out, "aborted")
+				return nil
+			}
+			if err := runtimecontext.NewContextService(root()).RemoveTenantOverride(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed the '%s' override for tenant %s\n", args[1], args[0])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
@@ -0,0 +1,386 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	coreval "github.com/contexis-cmp/contexis/src/core/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// wizardContext is the .ctx document written by the context wizard. It
+// mirrors corectx.Context without the timestamps, in the usual field order.
+type wizardContext struct {
+	SchemaVersion int                   `yaml:"schema_version"`
+	Name          string                `yaml:"name"`
+	Version       string                `yaml:"version"`
+	Description   string                `yaml:"description,omitempty"`
+	Role          corectx.Role          `yaml:"role"`
+	Tools         []corectx.Tool        `yaml:"tools,omitempty"`
+	Guardrails    corectx.Guardrails    `yaml:"guardrails"`
+	Memory        corectx.MemoryConfig  `yaml:"memory"`
+	Testing       corectx.TestingConfig `yaml:"testing"`
+}
+
+// wizard asks questions on in and writes prompts to out. Answers are checked
+// against the context schema as they are entered; an invalid answer is
+// explained and asked again, and an empty answer takes the default.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prompts for label until check accepts the answer.
+func (w *wizard) ask(label, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", label)
+		}
+		line, err := w.in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		cerr := check(answer)
+		if cerr == nil {
+			return answer, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("%s: %w", label, cerr)
+		}
+		fmt.Fprintf(w.out, "  ✗ %v\n", cerr)
+	}
+}
+
+// askString asks for a string and validates it at the schema path.
+func (w *wizard) askString(label, def, path string) (string, error) {
+	return w.ask(label, def, func(s string) error { return coreval.ValidateContextField(path, s) })
+}
+
+// askList asks for a comma-separated list; "-" answers with an empty list.
+func (w *wizard) askList(label string, def []string, path string) ([]string, error) {
+	answer, err := w.ask(label+" (comma-separated, - for none)", strings.Join(def, ","), func(s string) error {
+		return coreval.ValidateContextField(path, splitList(s))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return splitList(answer), nil
+}
+
+func (w *wizard) askInt(label string, def int, path string) (int, error) {
+	answer, err := w.ask(label, strconv.Itoa(def), func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", s)
+		}
+		return coreval.ValidateContextField(path, n)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
+
+func (w *wizard) askFloat(label string, def float64, path string, check func(float64) error) (float64, error) {
+	answer, err := w.ask(label, strconv.FormatFloat(def, 'f', -1, 64), func(s string) error {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		if check != nil {
+			if err := check(f); err != nil {
+				return err
+			}
+		}
+		return coreval.ValidateContextField(path, f)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(answer, 64)
+}
+
+func (w *wizard) askBool(label string, def bool) (bool, error) {
+	d := "n"
+	if def {
+		d = "y"
+	}
+	answer, err := w.ask(label+" (y/n)", d, func(s string) error {
+		switch strings.ToLower(s) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("answer y or n")
+	})
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// splitList splits a comma-separated answer, dropping empty items.
+func splitList(s string) []string {
+	items := []string{}
+	if strings.TrimSpace(s) == "-" {
+		return items
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseWizardTools turns tool answers into context tools. Each item is a
+// built-in tool name or a custom tool written as name=uri.
+func parseWizardTools(items []string) ([]corectx.Tool, error) {
+	var tools []corectx.Tool
+	seen := map[string]bool{}
+	for _, item := range items {
+		var t corectx.Tool
+		if name, uri, ok := strings.Cut(item, "="); ok {
+			t = corectx.Tool{Name: strings.TrimSpace(name), URI: strings.TrimSpace(uri)}
+			if t.Name == "" || t.URI == "" {
+				return nil, fmt.Errorf("custom tool %q needs both a name and a uri", item)
+			}
+		} else if builtin, ok := agentToolCatalog[item]; ok {
+			t = corectx.Tool{Name: builtin.Name, URI: builtin.URI, Description: builtin.Description, Database: builtin.Database}
+		} else {
+			return nil, fmt.Errorf("unknown tool %q (built-ins: %s; use name=uri for others)", item, strings.Join(builtinToolNames(), ", "))
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("tool %q is listed twice", t.Name)
+		}
+		seen[t.Name] = true
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+func builtinToolNames() []string {
+	names := make([]string, 0, len(agentToolCatalog))
+	for name := range agentToolCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateContextInteractive runs the context wizard for component name under
+// root. It writes contexts/<name>/<name>.ctx, a prompt in
+// prompts/<name>/agent_response.md and an eval suite in
+// tests/<name>/evals/behavior.yaml, and returns the files written. Existing
+// files are never overwritten.
+func GenerateContextInteractive(root, name string, in io.Reader, out io.Writer) ([]string, error) {
+	if err := validateAgentName(name); err != nil {
+		return nil, err
+	}
+	files := []string{
+		filepath.Join("contexts", name, strings.ToLower(name)+".ctx"),
+		filepath.Join("prompts", name, "agent_response.md"),
+		filepath.Join("tests", name, "evals", "behavior.yaml"),
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(root, f)); err == nil {
+			return nil, fmt.Errorf("%s already exists", f)
+		}
+	}
+
+	w := &wizard{in: bufio.NewReader(in), out: out}
+	fmt.Fprintf(out, "Creating context %s. Press Enter to accept the default in brackets.\n", name)
+	c, err := w.run(name)
+	if err != nil {
+		return nil, err
+	}
+
+	by, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(by, &m); err != nil {
+		return nil, err
+	}
+	if err := coreval.ValidateContextMap(m); err != nil {
+		return nil, err
+	}
+	contents := [][]byte{by, []byte(wizardPrompt(c)), nil}
+	if contents[2], err = wizardEvalSuite(c); err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, contents[i], 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f, err)
+		}
+	}
+	return files, nil
+}
+
+// run asks every question in turn.
+func (w *wizard) run(name string) (*wizardContext, error) {
+	c := &wizardContext{SchemaVersion: coreval.CurrentContextVersion, Name: name, Version: "1.0.0"}
+	var err error
+	if c.Description, err = w.askString("Description", fmt.Sprintf("Conversational agent for %s", name), "description"); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(w.out, "\nPersona")
+	if c.Role.Persona, err = w.askString("Persona", "", "role.persona"); err != nil {
+		return nil, err
+	}
+	if c.Role.Capabilities, err = w.askList("Capabilities", []string{"conversation", "context_awareness"}, "role.capabilities"); err != nil {
+		return nil, err
+	}
+	if c.Role.Limitations, err = w.askList("Limitations", []string{"no_personal_data", "no_harmful_content"}, "role.limitations"); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(w.out, "\nTools (built-ins: %s; custom tools as name=uri)\n", strings.Join(builtinToolNames(), ", "))
+	toolsAnswer, err := w.ask("Tools (comma-separated, - for none)", "-", func(s string) error {
+		tools, err := parseWizardTools(splitList(s))
+		if err != nil {
+			return err
+		}
+		return coreval.ValidateContextField("tools", tools)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.Tools, err = parseWizardTools(splitList(toolsAnswer)); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(w.out, "\nGuardrails")
+	if c.Guardrails.Tone, err = w.askString("Tone", "professional", "guardrails.tone"); err != nil {
+		return nil, err
+	}
+	if c.Guardrails.Format, err = w.ask("Response format (json, markdown, text)", "text", func(s string) error {
+		switch s {
+		case "json", "markdown", "text":
+			return nil
+		}
+		return fmt.Errorf("format must be json, markdown or text")
+	}); err != nil {
+		return nil, err
+	}
+	if c.Guardrails.MaxTokens, err = w.askInt("Max tokens", 500, "guardrails.max_tokens"); err != nil {
+		return nil, err
+	}
+	if c.Guardrails.Temperature, err = w.askFloat("Temperature", 0.1, "guardrails.temperature", func(f float64) error {
+		if f > 2 {
+			return fmt.Errorf("temperature must be between 0 and 2")
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	piiMode, err := w.askString("PII handling (off, allow, redact, block)", "redact", "guardrails.pii.mode")
+	if err != nil {
+		return nil, err
+	}
+	if piiMode != "off" {
+		c.Guardrails.PII = &corectx.PIIGuardrails{Mode: piiMode}
+	}
+
+	fmt.Fprintln(w.out, "\nMemory")
+	if c.Memory.Episodic, err = w.askBool("Remember earlier conversations", true); err != nil {
+		return nil, err
+	}
+	if c.Memory.Episodic {
+		if c.Memory.MaxHistory, err = w.askInt("Max history (turns)", 10, "memory.max_history"); err != nil {
+			return nil, err
+		}
+		if c.Memory.Privacy, err = w.askString("Privacy", "user_isolated", "memory.privacy"); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintln(w.out, "\nTesting")
+	if c.Testing.DriftThreshold, err = w.askFloat("Drift threshold", 0.85, "testing.drift_threshold", func(f float64) error {
+		if f < 0 || f > 1 {
+			return fmt.Errorf("drift threshold must be between 0 and 1")
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if c.Testing.BusinessRules, err = w.askList("Business rules", []string{"always_helpful", "professional_tone"}, "testing.business_rules"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// wizardPrompt is the prompt template matching the generated context. It is
+// rendered with the resolved context, retrieved results, the conversation
+// summary and history, and user_input.
+func wizardPrompt(c *wizardContext) string {
+	var sb strings.Builder
+	sb.WriteString("You are {{ .context.Role.Persona }}.\n")
+	sb.WriteString("{{- if .context.Role.Capabilities }}\n\nYou can help with: {{ join .context.Role.Capabilities \", \" }}.\n{{- end }}\n")
+	sb.WriteString("{{- if .context.Role.Limitations }}\nYou must respect these limitations: {{ join .context.Role.Limitations \", \" }}.\n{{- end }}\n")
+	if len(c.Tools) > 0 {
+		sb.WriteString("\n## Tools\n{{- range .context.Tools }}\n- {{ .Name }}{{ if .Description }}: {{ .Description }}{{ end }}\n{{- end }}\n")
+	}
+	sb.WriteString("{{- if .results }}\n\n## Relevant information\n{{- range .results }}\n- {{ .Content }}\n{{- end }}\n{{- end }}\n")
+	sb.WriteString("{{- if .summary }}\n\n## Conversation so far\n{{ .summary }}\n{{- end }}\n")
+	sb.WriteString("{{- if .history }}\n\n## Recent messages\n{{- range .history }}\nUser: {{ .Query }}\nAssistant: {{ .Response }}\n{{- end }}\n{{- end }}\n")
+	sb.WriteString("\n## Response guidelines\n")
+	sb.WriteString("- Tone: {{ .context.Guardrails.Tone }}\n")
+	switch c.Guardrails.Format {
+	case "json":
+		sb.WriteString("- Reply with a single JSON object and nothing else.\n")
+	case "markdown":
+		sb.WriteString("- Format the reply as Markdown.\n")
+	default:
+		sb.WriteString("- Reply in plain text.\n")
+	}
+	sb.WriteString("- Keep the reply under {{ .context.Guardrails.MaxTokens }} tokens.\n")
+	sb.WriteString("\nUser: {{ .user_input }}\n")
+	return sb.String()
+}
+
+// wizardEvalSuite is an eval suite (see ctx eval run) with one case per
+// capability, graded against the persona, tone and business rules.
+func wizardEvalSuite(c *wizardContext) ([]byte, error) {
+	rubric := fmt.Sprintf("The answer stays in character as %s, uses a %s tone", c.Role.Persona, c.Guardrails.Tone)
+	if len(c.Testing.BusinessRules) > 0 {
+		rubric += " and follows these rules: " + strings.Join(c.Testing.BusinessRules, ", ")
+	}
+	graders := []map[string]interface{}{{"type": "llm_judge", "rubric": rubric + ".", "threshold": c.Testing.DriftThreshold}}
+	if c.Guardrails.Format == "json" {
+		graders = append(graders, map[string]interface{}{"type": "regex", "pattern": `^\s*\{`})
+	}
+	cases := []map[string]interface{}{{"name": "greeting", "input": "Hello, what can you help me with?"}}
+	for _, capability := range c.Role.Capabilities {
+		cases = append(cases, map[string]interface{}{
+			"name":  "capability_" + strings.ToLower(strings.ReplaceAll(capability, " ", "_")),
+			"input": fmt.Sprintf("Can you help me with %s?", strings.ReplaceAll(capability, "_", " ")),
+		})
+	}
+	suite := struct {
+		Name      string                   `yaml:"name"`
+		Component string                   `yaml:"component"`
+		Graders   []map[string]interface{} `yaml:"graders"`
+		Cases     []map[string]interface{} `yaml:"cases"`
+	}{Name: "behavior", Component: c.Name, Graders: graders, Cases: cases}
+	return yaml.Marshal(suite)
+}
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/contexis-cmp/contexis/src/cli/config"
+	"github.com/spf13/cobra"
+)
+
+// componentDirs lists the top-level directories a generator may create for a component.
+// Drift baselines live under tests/<component>/baselines and are removed with tests/.
+var componentDirs = []string{"contexts", "prompts", "memory", "tools", "tests"}
+
+// reservedComponentDirs maps names that are never components to the shared
+// project directory they would select under componentDirs.
+var reservedComponentDirs = map[string]string{
+	"tenants":    "contexts/tenants",
+	"_shared":    "prompts/_shared",
+	"documents":  "memory/documents",
+	"embeddings": "memory/embeddings",
+	"reports":    "tests/reports",
+	"cassettes":  "tests/cassettes",
+}
+
+// GetDestroyCommand returns the `destroy` command.
+//
+// The `destroy` command is the inverse of `ctx generate`: it removes a component's
+// contexts/, prompts/, memory/, tools/ and tests/ directories, tenant context
+// overrides, and its entries in context.lock.json. Use --dry-run to preview.
+func GetDestroyCommand() *cobra.Command {
+	var (
+		dryRun bool
+		yes    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "destroy [component]",
+		Short: "Remove a generated component and its artifacts",
+		Long: `Remove everything a generator created for a component.
+
+Examples:
+  ctx destroy CustomerDocs --dry-run
+  ctx destroy SupportBot --yes`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := config.ValidateProjectName(name); err != nil {
+				return fmt.Errorf("invalid component name: %w", err)
+			}
+			root, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			plan, err := DestroyComponent(root, name, true)
+			if err != nil {
+				return err
+			}
+			if len(plan) == 0 {
+				return fmt.Errorf("component '%s' not found", name)
+			}
+			for _, p := range plan {
+				fmt.Fprintf(out, "      remove  %s\n", p)
+			}
+			if dryRun {
+				fmt.Fprintln(out, "dry run: no files were removed")
+				return nil
+			}
+			if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("Remove component '%s'? [y/N]: ", name)) {
+				fmt.Fprintln(out, "aborted")
+				return nil
+			}
+			if _, err := DestroyComponent(root, name, false); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Component '%s' destroyed\n", name)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be removed without deleting anything")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
+
+// DestroyComponent removes (or, when dryRun is set, only lists) the artifacts of a
+// component under root. It returns the project-relative paths affected. Reserved
+// names such as "tenants" are rejected before anything is touched.
+func DestroyComponent(root, name string, dryRun bool) ([]string, error) {
+	if dir, ok := reservedComponentDirs[strings.ToLower(name)]; ok {
+		return nil, fmt.Errorf("'%s' is not a component: %s is shared by the project", name, dir)
+	}
+	var affected []string
+	for _, d := range componentDirs {
+		p := filepath.Join(root, d, name)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		affected = append(affected, filepath.ToSlash(filepath.Join(d, name)))
+		if !dryRun {
+			if err := os.RemoveAll(p); err != nil {
+				return affected, fmt.Errorf("remove %s: %w", p, err)
+			}
+		}
+	}
+	overrides, _ := filepath.Glob(filepath.Join(root, "contexts", "tenants", "*", name+".ctx"))
+	for _, p := range overrides {
+		rel, _ := filepath.Rel(root, p)
+		affected = append(affected, filepath.ToSlash(rel))
+		if !dryRun {
+			if err := os.Remove(p); err != nil {
+				return affected, fmt.Errorf("remove %s: %w", p, err)
+			}
+		}
+	}
+	changed, err := pruneLockFile(filepath.Join(root, "context.lock.json"), name, dryRun)
+	if err != nil {
+		return affected, err
+	}
+	if changed {
+		affected = append(affected, "context.lock.json (entries for "+name+")")
+	}
+	return affected, nil
+}
+
+// pruneLockFile removes a component's entries from the lock file, reporting whether any existed.
+func pruneLockFile(path, name string, dryRun bool) (bool, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return false, nil
+	}
+	var lock LockFile
+	if err := json.Unmarshal(by, &lock); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	_, inCtx := lock.Contexts[name]
+	_, inPrompts := lock.Prompts[name]
+	_, inMem := lock.Memory[name]
+	if !inCtx && !inPrompts && !inMem {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+	delete(lock.Contexts, name)
+	delete(lock.Prompts, name)
+	delete(lock.Memory, name)
+	out, _ := json.MarshalIndent(lock, "", "  ")
+	return true, os.WriteFile(path, out, 0o644)
+}
+
+// confirm prints prompt and reports whether the user answered yes.
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
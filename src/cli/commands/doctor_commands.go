@@ -0,0 +1,785 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	coreval "github.com/contexis-cmp/contexis/src/core/schema"
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimemodelcache "github.com/contexis-cmp/contexis/src/runtime/modelcache"
+	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Doctor check statuses.
+const (
+	DoctorOK   = "ok"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+	DoctorSkip = "skip"
+)
+
+// Exit codes of `ctx doctor`.
+const (
+	DoctorExitFailed   = 1 // at least one check failed
+	DoctorExitWarnings = 2 // only warnings, with --strict
+)
+
+// minPythonVersion is the oldest interpreter the local model dependencies support.
+var minPythonVersion = []int{3, 9}
+
+// localPythonModules are the imports the local (transformers) backend needs.
+var localPythonModules = []string{"transformers", "torch", "sentence_transformers"}
+
+// DoctorCheck is the outcome of one diagnostic.
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// DoctorReport is the result of RunDoctor.
+type DoctorReport struct {
+	Checks   []DoctorCheck `json:"checks"`
+	Failures int           `json:"failures"`
+	Warnings int           `json:"warnings"`
+}
+
+// DoctorOptions tune RunDoctor.
+type DoctorOptions struct {
+	// Offline skips checks that call provider APIs.
+	Offline bool
+	// Timeout bounds each external command and network call (default 10s).
+	Timeout time.Duration
+}
+
+// ExitError makes main exit with Code after printing Err.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// GetDoctorCommand returns the `doctor` command, which diagnoses the local
+// environment and the project in the working directory.
+func GetDoctorCommand() *cobra.Command {
+	var (
+		opts   DoctorOptions
+		asJSON bool
+		strict bool
+	)
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment and project for common problems",
+		Long: `Check Go and Python versions, model cache availability, provider credentials,
+context schemas, prompt templates and context.lock.json, printing a fix for each
+problem found.
+
+Exit codes: 0 healthy, 1 a check failed, 2 warnings only (with --strict).`,
+		Example: `  ctx doctor
+  ctx doctor --offline --strict   # in CI`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := mustGetwd()
+			// Check the configuration `ctx serve` would run with
+			if os.Getenv("CMP_PROJECT_ROOT") == "" {
+				_ = os.Setenv("CMP_PROJECT_ROOT", root)
+			}
+			if os.Getenv("CMP_PYTHON_BIN") == "" {
+				if cand := pyenv.VenvPython(root); fileExists(cand) {
+					_ = os.Setenv("CMP_PYTHON_BIN", cand)
+				}
+			}
+			if os.Getenv("CMP_LOCAL_MODELS") == "" {
+				_ = os.Setenv("CMP_LOCAL_MODELS", "true")
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			report := RunDoctor(ctx, root, opts)
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printDoctorReport(out, report)
+			}
+			switch {
+			case report.Failures > 0:
+				return &ExitError{Code: DoctorExitFailed, Err: fmt.Errorf("%d doctor check(s) failed", report.Failures)}
+			case strict && report.Warnings > 0:
+				return &ExitError{Code: DoctorExitWarnings, Err: fmt.Errorf("%d doctor warning(s)", report.Warnings)}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "Skip checks that call provider APIs")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 10*time.Second, "Timeout for each external command or API call")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Exit with code 2 when there are warnings")
+	return cmd
+}
+
+// RunDoctor runs every diagnostic against the project at root and the
+// current environment.
+func RunDoctor(ctx context.Context, root string, opts DoctorOptions) DoctorReport {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	d := &doctor{root: root, opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+	checks := []func(context.Context) []DoctorCheck{
+		d.checkGo,
+		d.checkPython,
+		d.checkEnv,
+		d.checkProject,
+		d.checkConfig,
+		d.checkContexts,
+		d.checkTemplates,
+		d.checkLockFile,
+		d.checkModelCache,
+		d.checkProvider,
+		d.checkHFToken,
+		d.checkRouting,
+	}
+	var report DoctorReport
+	for _, run := range checks {
+		for _, c := range run(ctx) {
+			switch c.Status {
+			case DoctorFail:
+				report.Failures++
+			case DoctorWarn:
+				report.Warnings++
+			}
+			report.Checks = append(report.Checks, c)
+		}
+	}
+	return report
+}
+
+func printDoctorReport(w io.Writer, report DoctorReport) {
+	for _, c := range report.Checks {
+		fmt.Fprintf(w, "[%-4s] %-12s %s\n", c.Status, c.Name, c.Message)
+		if c.Fix != "" && c.Status != DoctorOK {
+			fmt.Fprintf(w, "       %-12s fix: %s\n", "", c.Fix)
+		}
+	}
+	fmt.Fprintf(w, "\n%d failed, %d warning(s), %d checks\n", report.Failures, report.Warnings, len(report.Checks))
+}
+
+type doctor struct {
+	root   string
+	opts   DoctorOptions
+	client *http.Client
+}
+
+func check(name, status, message, fix string) []DoctorCheck {
+	return []DoctorCheck{{Name: name, Status: status, Message: message, Fix: fix}}
+}
+
+// localBackendNeedsPython reports whether the provider `ctx serve` picks is
+// the transformers backend, which runs through Python.
+func localBackendNeedsPython() bool {
+	if runtimemodel.ProviderKindFromEnv() != "local" {
+		return false
+	}
+	b := strings.ToLower(os.Getenv("CMP_LOCAL_BACKEND"))
+	return b == "" || b == "transformers"
+}
+
+// checkGo reports the Go toolchain, which `ctx test` needs, against the
+// project's go.mod when there is one.
+func (d *doctor) checkGo(ctx context.Context) []DoctorCheck {
+	const fix = "install Go from https://go.dev/dl/"
+	if _, err := exec.LookPath("go"); err != nil {
+		return check("go", DoctorWarn, "go not found in PATH (needed by `ctx test`)", fix)
+	}
+	cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	out, err := exec.CommandContext(cctx, "go", "env", "GOVERSION").Output()
+	if err != nil {
+		return check("go", DoctorWarn, "could not run `go env GOVERSION`: "+err.Error(), fix)
+	}
+	have := strings.TrimSpace(string(out))
+	by, err := os.ReadFile(filepath.Join(d.root, "go.mod"))
+	if err != nil {
+		return check("go", DoctorOK, have, "")
+	}
+	if m := regexp.MustCompile(`(?m)^go\s+(\d+(?:\.\d+)*)`).FindSubmatch(by); m != nil {
+		want := string(m[1])
+		if compareVersions(parseVersion(strings.TrimPrefix(have, "go")), parseVersion(want)) < 0 {
+			return check("go", DoctorFail, fmt.Sprintf("%s is older than go %s required by go.mod", have, want), fix)
+		}
+	}
+	return check("go", DoctorOK, have, "")
+}
+
+// checkPython finds the interpreter the local backend uses and the modules
+// `ctx setup` installs.
+func (d *doctor) checkPython(ctx context.Context) []DoctorCheck {
+	const fix = "run `ctx setup` to create .venv with the local model dependencies"
+	// Missing Python only matters when local models run through transformers
+	bad := DoctorWarn
+	if localBackendNeedsPython() {
+		bad = DoctorFail
+	}
+	py := pyenv.Bin(d.root)
+	if _, err := exec.LookPath(py); err != nil {
+		return check("python", bad, fmt.Sprintf("python interpreter %q not found", py), fix)
+	}
+	script := `import importlib.util, json, sys
+mods = ` + pythonList(localPythonModules) + `
+print(json.dumps({"version": list(sys.version_info[:3]), "missing": [m for m in mods if importlib.util.find_spec(m) is None]}))`
+	cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	out, err := exec.CommandContext(cctx, py, "-c", script).Output()
+	if err != nil {
+		return check("python", bad, fmt.Sprintf("%s failed to run: %v", py, err), fix)
+	}
+	var info struct {
+		Version []int    `json:"version"`
+		Missing []string `json:"missing"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(out), &info); err != nil || len(info.Version) < 2 {
+		return check("python", bad, fmt.Sprintf("unexpected output from %s", py), fix)
+	}
+	version := joinVersion(info.Version)
+	if compareVersions(info.Version, minPythonVersion) < 0 {
+		return check("python", bad, fmt.Sprintf("python %s is older than %s", version, joinVersion(minPythonVersion)), "install Python "+joinVersion(minPythonVersion)+"+ and run `ctx setup`")
+	}
+	if len(info.Missing) > 0 {
+		return check("python", bad, fmt.Sprintf("python %s is missing %s", version, strings.Join(info.Missing, ", ")), fix)
+	}
+	return check("python", DoctorOK, fmt.Sprintf("python %s (%s) with %s", version, py, strings.Join(localPythonModules, ", ")), "")
+}
+
+func pythonList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, s := range items {
+		quoted[i] = strconv.Quote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// checkEnv validates the CMP_* variables the runtime reads, which are
+// otherwise silently ignored when malformed.
+func (d *doctor) checkEnv(ctx context.Context) []DoctorCheck {
+	var problems []string
+	for _, name := range []string{"CMP_LOCAL_MODELS", "CMP_MOCK_PROVIDERS", "CMP_AUTH_ENABLED", "CMP_PI_ENFORCEMENT"} {
+		if v := os.Getenv(name); v != "" && v != "true" && v != "false" {
+			problems = append(problems, fmt.Sprintf("%s=%q must be true or false", name, v))
+		}
+	}
+	for _, name := range []string{"CMP_LOCAL_TIMEOUT_SECONDS", "CMP_PROVIDER_MAX_ATTEMPTS", "CMP_PROVIDER_BREAKER_FAILURES", "CMP_LLAMACPP_CTX_SIZE", "CMP_LLAMACPP_THREADS"} {
+		if v := os.Getenv(name); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+				problems = append(problems, fmt.Sprintf("%s=%q must be a positive integer", name, v))
+			}
+		}
+	}
+	for _, name := range []string{"CMP_PROVIDER_RETRY_BASE_DELAY", "CMP_PROVIDER_RETRY_MAX_DELAY", "CMP_PROVIDER_BREAKER_OPEN_TIMEOUT"} {
+		if v := os.Getenv(name); v != "" {
+			if dur, err := time.ParseDuration(v); err != nil || dur < 0 {
+				problems = append(problems, fmt.Sprintf("%s=%q must be a duration such as 500ms", name, v))
+			}
+		}
+	}
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv("CMP_PROVIDER_MODE"))); v {
+	case "", runtimemodel.ModeLive, runtimemodel.ModeRecord, runtimemodel.ModeReplay:
+	default:
+		problems = append(problems, fmt.Sprintf("CMP_PROVIDER_MODE=%q must be live, record or replay", v))
+	}
+	switch v := strings.ToLower(os.Getenv("CMP_LOCAL_BACKEND")); v {
+	case "", "transformers", "llamacpp", "llama.cpp":
+	default:
+		problems = append(problems, fmt.Sprintf("CMP_LOCAL_BACKEND=%q must be transformers or llamacpp", v))
+	}
+	if py := os.Getenv("CMP_PYTHON_BIN"); py != "" && strings.ContainsRune(py, filepath.Separator) && !fileExists(py) {
+		problems = append(problems, fmt.Sprintf("CMP_PYTHON_BIN=%q does not exist", py))
+	}
+	if v := os.Getenv("CMP_PROJECT_ROOT"); v != "" {
+		if abs, err := filepath.Abs(v); err != nil || !sameDir(abs, d.root) {
+			problems = append(problems, fmt.Sprintf("CMP_PROJECT_ROOT=%q is not the current project", v))
+		}
+	}
+	if env := os.Getenv("CMP_ENV"); env != "" && !fileExists(filepath.Join(d.root, "config", "environments", env+".yaml")) {
+		problems = append(problems, fmt.Sprintf("CMP_ENV=%q has no config/environments/%s.yaml", env, env))
+	}
+	if os.Getenv("HF_MODEL_ID") != "" && os.Getenv("HF_TOKEN") == "" {
+		problems = append(problems, "HF_MODEL_ID is set without HF_TOKEN")
+	}
+	if len(problems) > 0 {
+		return check("env", DoctorFail, strings.Join(problems, "; "), "fix or unset these variables (see .env.example)")
+	}
+	return check("env", DoctorOK, "environment variables are well-formed", "")
+}
+
+// checkConfig loads the active environment config the way `ctx serve` does,
+// expanding ${VAR} placeholders and validating every section.
+func (d *doctor) checkConfig(ctx context.Context) []DoctorCheck {
+	env, err := runtimeconfig.Load(d.root)
+	if err != nil {
+		return check("config", DoctorFail, err.Error(), "fix the YAML syntax")
+	}
+	if env.Path == "" {
+		return nil
+	}
+	rel, _ := filepath.Rel(d.root, env.Path)
+	if err := env.Validate(); err != nil {
+		msg := strings.ReplaceAll(err.Error(), "\n", "; ")
+		return check("config", DoctorFail, rel+": "+msg, "set the missing variables or fix the values (see docs/config/environment-variables.md)")
+	}
+	return check("config", DoctorOK, rel+" is valid", "")
+}
+
+// checkProject looks for the directories `ctx init` creates.
+func (d *doctor) checkProject(ctx context.Context) []DoctorCheck {
+	if !dirExists(filepath.Join(d.root, "contexts")) {
+		return check("project", DoctorFail, "contexts/ not found; this is not a Contexis project directory", "run ctx from the project root, or create a project with `ctx init <name>`")
+	}
+	var missing []string
+	for _, dir := range []string{"prompts", "memory", "config"} {
+		if !dirExists(filepath.Join(d.root, dir)) {
+			missing = append(missing, dir+"/")
+		}
+	}
+	if len(missing) > 0 {
+		return check("project", DoctorWarn, "missing "+strings.Join(missing, ", "), "create the directories, or compare with a project from `ctx init`")
+	}
+	return check("project", DoctorOK, "project layout found at "+d.root, "")
+}
+
+// checkContexts validates every .ctx file against the context schema and
+// warns about files written for an older schema version. Tenant overrides
+// under contexts/tenants are partial and not checked.
+func (d *doctor) checkContexts(ctx context.Context) []DoctorCheck {
+	dir := filepath.Join(d.root, "contexts")
+	if !dirExists(dir) {
+		return check("contexts", DoctorSkip, "no contexts/ directory", "")
+	}
+	var out []DoctorCheck
+	count := 0
+	_ = filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if e.IsDir() {
+			if path != dir && e.Name() == "tenants" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".ctx" {
+			return nil
+		}
+		count++
+		rel, _ := filepath.Rel(d.root, path)
+		rel = filepath.ToSlash(rel)
+		by, err := os.ReadFile(path)
+		if err != nil {
+			out = append(out, DoctorCheck{Name: "contexts", Status: DoctorFail, Message: rel + ": " + err.Error()})
+			return nil
+		}
+		var m map[string]interface{}
+		if err := yaml.Unmarshal(by, &m); err != nil {
+			out = append(out, DoctorCheck{Name: "contexts", Status: DoctorFail, Message: rel + ": invalid YAML: " + err.Error(), Fix: "fix the YAML syntax"})
+			return nil
+		}
+		if err := coreval.ValidateContextMap(m); err != nil {
+			out = append(out, DoctorCheck{Name: "contexts", Status: DoctorFail, Message: rel + ": " + err.Error(), Fix: "edit the file, or rebuild it with `ctx generate context <Name>`"})
+		} else if v, _ := coreval.ContextVersion(m); v < coreval.CurrentContextVersion {
+			out = append(out, DoctorCheck{Name: "contexts", Status: DoctorWarn, Message: fmt.Sprintf("%s: schema_version %d, the current version is %d", rel, v, coreval.CurrentContextVersion), Fix: "run `ctx context migrate-schema`"})
+		}
+		return nil
+	})
+	if count == 0 {
+		return check("contexts", DoctorWarn, "no .ctx files in contexts/", "generate a component with `ctx generate rag|agent|workflow <Name>`")
+	}
+	if len(out) == 0 {
+		return check("contexts", DoctorOK, fmt.Sprintf("%d context file(s) match the schema", count), "")
+	}
+	return out
+}
+
+// checkTemplates requires prompt templates for each component with a
+// context directory and compiles them with their partials and includes.
+func (d *doctor) checkTemplates(ctx context.Context) []DoctorCheck {
+	entries, err := os.ReadDir(filepath.Join(d.root, "contexts"))
+	if err != nil {
+		return check("templates", DoctorSkip, "no contexts/ directory", "")
+	}
+	eng := runtimeprompt.NewEngine(d.root)
+	var out []DoctorCheck
+	count := 0
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == "tenants" {
+			continue
+		}
+		comp := e.Name()
+		compDir := filepath.Join(d.root, "prompts", comp)
+		var files []string
+		_ = filepath.WalkDir(compDir, func(path string, de fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if de.IsDir() {
+				if de.Name() == runtimeprompt.PartialsDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) == ".md" {
+				rel, _ := filepath.Rel(compDir, path)
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if len(files) == 0 {
+			out = append(out, DoctorCheck{Name: "templates", Status: DoctorFail,
+				Message: fmt.Sprintf("component %s has no prompt templates in prompts/%s/", comp, comp),
+				Fix:     fmt.Sprintf("add prompts/%s/agent_response.md, or regenerate the component with `ctx generate`", comp)})
+			continue
+		}
+		for _, f := range files {
+			count++
+			if err := eng.Compile(comp, f); err != nil {
+				out = append(out, DoctorCheck{Name: "templates", Status: DoctorFail,
+					Message: fmt.Sprintf("prompts/%s/%s: %v", comp, filepath.ToSlash(f), err),
+					Fix:     "fix the template syntax or the missing partial"})
+			}
+		}
+	}
+	if len(out) == 0 {
+		return check("templates", DoctorOK, fmt.Sprintf("%d prompt template(s) compile", count), "")
+	}
+	return out
+}
+
+// checkLockFile compares context.lock.json with the current contexts,
+// prompts and memory.
+func (d *doctor) checkLockFile(ctx context.Context) []DoctorCheck {
+	const fix = "review the changes and run `ctx lock generate`"
+	by, err := os.ReadFile(filepath.Join(d.root, "context.lock.json"))
+	if os.IsNotExist(err) {
+		return check("lockfile", DoctorWarn, "context.lock.json not found", "run `ctx lock generate` and commit the file")
+	}
+	if err != nil {
+		return check("lockfile", DoctorFail, err.Error(), "")
+	}
+	var recorded LockFile
+	if err := json.Unmarshal(by, &recorded); err != nil {
+		return check("lockfile", DoctorFail, "context.lock.json is not valid JSON: "+err.Error(), "run `ctx lock generate`")
+	}
+	current := ComputeLockFile(d.root)
+	if len(recorded.Contexts)+len(recorded.Prompts)+len(recorded.Memory) == 0 {
+		if len(current.Contexts)+len(current.Prompts)+len(current.Memory) == 0 {
+			return check("lockfile", DoctorOK, "context.lock.json is up to date", "")
+		}
+		return check("lockfile", DoctorWarn, "context.lock.json has no entries yet", "run `ctx lock generate` and commit the file")
+	}
+	diffs := diffLockFiles(recorded, current)
+	if len(diffs) == 0 {
+		return check("lockfile", DoctorOK, "context.lock.json is up to date", "")
+	}
+	msg := strings.Join(diffs, ", ")
+	if len(diffs) > 5 {
+		msg = strings.Join(diffs[:5], ", ") + fmt.Sprintf(" and %d more", len(diffs)-5)
+	}
+	return check("lockfile", DoctorFail, "context.lock.json is out of date: "+msg, fix)
+}
+
+// diffLockFiles lists the entries of current that differ from recorded.
+func diffLockFiles(recorded, current LockFile) []string {
+	var diffs []string
+	compare := func(kind string, was, now map[string]string) {
+		keys := map[string]bool{}
+		for k := range was {
+			keys[k] = true
+		}
+		for k := range now {
+			keys[k] = true
+		}
+		names := make([]string, 0, len(keys))
+		for k := range keys {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			a, inWas := was[k]
+			b, inNow := now[k]
+			switch {
+			case !inWas:
+				diffs = append(diffs, kind+"/"+k+" added")
+			case !inNow:
+				diffs = append(diffs, kind+"/"+k+" removed")
+			case a != b:
+				diffs = append(diffs, kind+"/"+k+" changed")
+			}
+		}
+	}
+	compare("contexts", recorded.Contexts, current.Contexts)
+	flatten := func(m map[string]map[string]string) map[string]string {
+		out := map[string]string{}
+		for _, files := range m {
+			for rel, sha := range files {
+				out[rel] = sha
+			}
+		}
+		return out
+	}
+	compare("prompts", flatten(recorded.Prompts), flatten(current.Prompts))
+	compare("memory", recorded.Memory, current.Memory)
+	return diffs
+}
+
+// checkModelCache opens the configured model cache and, for the local
+// transformers backend, looks for CMP_LOCAL_MODEL_ID in it.
+func (d *doctor) checkModelCache(ctx context.Context) []DoctorCheck {
+	cache, err := runtimemodelcache.Open(d.root)
+	if err != nil {
+		return check("model-cache", DoctorFail, "model_cache configuration: "+err.Error(), "fix model_cache in config/environments/$CMP_ENV.yaml")
+	}
+	entries, err := cache.List()
+	if err != nil {
+		return check("model-cache", DoctorFail, err.Error(), "run `ctx models verify` or remove the damaged entry with `ctx models remove`")
+	}
+	rel, _ := filepath.Rel(d.root, cache.Dir())
+	if strings.HasPrefix(rel, "..") {
+		rel = cache.Dir()
+	}
+	if id := os.Getenv("CMP_LOCAL_MODEL_ID"); id != "" && localBackendNeedsPython() {
+		for _, e := range entries {
+			if e.ID == id {
+				return check("model-cache", DoctorOK, fmt.Sprintf("%s is cached in %s", id, rel), "")
+			}
+		}
+		return check("model-cache", DoctorWarn, fmt.Sprintf("%s is not in %s; the first request will download it", id, rel), "run `ctx models pull "+id+"`")
+	}
+	return check("model-cache", DoctorOK, fmt.Sprintf("%d model(s) in %s", len(entries), rel), "")
+}
+
+// checkProvider checks the provider `ctx serve` falls back to, pinging
+// remote ones unless offline.
+func (d *doctor) checkProvider(ctx context.Context) []DoctorCheck {
+	switch kind := runtimemodel.ProviderKindFromEnv(); kind {
+	case "":
+		return check("provider", DoctorWarn, "no model provider configured", "set CMP_LOCAL_MODELS=true (after `ctx setup`), OLLAMA_HOST, HF_TOKEN and HF_MODEL_ID, or HF_ENDPOINT")
+	case "mock":
+		return check("provider", DoctorOK, "mock provider (scripted responses)", "")
+	case "ollama":
+		prov := runtimemodel.NewOllamaProviderFromEnv()
+		if d.opts.Offline {
+			return check("provider", DoctorSkip, "ollama (not pinged: --offline)", "")
+		}
+		cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+		if err := prov.Ping(cctx); err != nil {
+			return check("provider", DoctorFail, err.Error(), "start Ollama (`ollama serve`) and pull the model, or fix OLLAMA_HOST/OLLAMA_MODEL")
+		}
+		return check("provider", DoctorOK, "ollama is reachable and has the model", "")
+	case "local":
+		if _, err := runtimemodel.NewLocalProviderFromEnv(); err != nil {
+			return check("provider", DoctorFail, "local provider: "+err.Error(), "run `ctx setup` and point CMP_PYTHON_SCRIPT at local_provider.py, or fix the CMP_LOCAL_BACKEND/CMP_LLAMACPP_* settings")
+		}
+		return check("provider", DoctorOK, "local models ("+firstNonEmpty(os.Getenv("CMP_LOCAL_BACKEND"), "transformers")+")", "")
+	default:
+		prov, err := runtimemodel.NewHuggingFaceAPIProviderFromEnv()
+		if err != nil {
+			return check("provider", DoctorFail, "huggingface: "+err.Error(), "fix HF_TOKEN, HF_MODEL_ID, HF_ENDPOINT and HF_ENDPOINT_TYPE")
+		}
+		label := kind + " " + prov.EndpointType() + " (" + prov.Model() + ")"
+		if prov.EndpointType() == runtimemodel.HFInferenceAPI {
+			return check("provider", DoctorOK, label, "")
+		}
+		if d.opts.Offline {
+			return check("provider", DoctorSkip, label+" (not pinged: --offline)", "")
+		}
+		cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+		defer cancel()
+		if err := prov.Ping(cctx); err != nil {
+			return check("provider", DoctorFail, err.Error(), "start or resume the endpoint, or fix HF_ENDPOINT/HF_ENDPOINT_TYPE")
+		}
+		return check("provider", DoctorOK, label+" is healthy", "")
+	}
+}
+
+// checkHFToken validates HF_TOKEN, used for inference and model pulls,
+// against the Hub's whoami endpoint.
+func (d *doctor) checkHFToken(ctx context.Context) []DoctorCheck {
+	token := os.Getenv("HF_TOKEN")
+	if token == "" {
+		return nil
+	}
+	if d.opts.Offline {
+		return check("hf-token", DoctorSkip, "HF_TOKEN set (not validated: --offline)", "")
+	}
+	hub := strings.TrimRight(firstNonEmpty(os.Getenv("CMP_HF_HUB_URL"), runtimemodelcache.DefaultHubURL), "/")
+	cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(cctx, http.MethodGet, hub+"/api/whoami-v2", nil)
+	if err != nil {
+		return check("hf-token", DoctorFail, "invalid CMP_HF_HUB_URL", "")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return check("hf-token", DoctorWarn, "could not reach the Hugging Face Hub to validate HF_TOKEN", "check network access, or pass --offline")
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return check("hf-token", DoctorFail, "HF_TOKEN was rejected by the Hugging Face Hub", "create a token at https://huggingface.co/settings/tokens and update HF_TOKEN")
+	case resp.StatusCode != http.StatusOK:
+		return check("hf-token", DoctorWarn, fmt.Sprintf("Hugging Face Hub returned status %d", resp.StatusCode), "retry later")
+	}
+	var who struct {
+		Name string `json:"name"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&who)
+	return check("hf-token", DoctorOK, "HF_TOKEN is valid ("+firstNonEmpty(who.Name, "unknown user")+")", "")
+}
+
+// checkRouting builds the providers in config/providers/routing.yaml, which
+// checks their credentials are set, and pings Ollama providers, dedicated HF
+// Inference Endpoints and TGI servers.
+func (d *doctor) checkRouting(ctx context.Context) []DoctorCheck {
+	cfg, err := runtimemodel.LoadRoutingConfig(d.root)
+	if err != nil {
+		return check("routing", DoctorFail, err.Error(), "fix "+runtimemodel.RoutingFile)
+	}
+	if cfg == nil {
+		return nil
+	}
+	if _, err := runtimemodel.NewRouter(cfg, nil); err != nil {
+		return check("routing", DoctorFail, runtimemodel.RoutingFile+": "+err.Error(), "set the provider credentials or fix "+runtimemodel.RoutingFile)
+	}
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []DoctorCheck
+	for _, name := range names {
+		spec := cfg.Providers[name]
+		if d.opts.Offline {
+			continue
+		}
+		var (
+			ping func(context.Context) error
+			fix  string
+		)
+		switch strings.ToLower(spec.Type) {
+		case "ollama":
+			ping, fix = runtimemodel.NewOllamaProvider(spec.Endpoint, spec.Model, nil, "").Ping, "start Ollama at the provider's endpoint and pull the model"
+		case "huggingface", "hf":
+			prov, err := runtimemodel.NewHuggingFaceEndpointProvider(spec.EndpointType, os.Getenv(firstNonEmpty(spec.TokenEnv, "HF_TOKEN")), spec.Endpoint, spec.Model)
+			if err != nil {
+				continue
+			}
+			ping, fix = prov.Ping, "start or resume the provider's Inference Endpoint or TGI server"
+		default:
+			continue
+		}
+		cctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+		err := ping(cctx)
+		cancel()
+		if err != nil {
+			out = append(out, DoctorCheck{Name: "routing", Status: DoctorFail, Message: "provider " + name + ": " + err.Error(), Fix: fix})
+		}
+	}
+	if len(out) == 0 {
+		return check("routing", DoctorOK, fmt.Sprintf("%d routed provider(s) configured", len(cfg.Providers)), "")
+	}
+	return out
+}
+
+func parseVersion(s string) []int {
+	var out []int
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(strings.TrimLeft(part, "v"))
+		if err != nil {
+			// e.g. "1.23rc1": keep the leading digits
+			digits := strings.TrimRightFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+			if n, err = strconv.Atoi(digits); err != nil {
+				break
+			}
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// compareVersions compares dotted versions numerically; missing parts are 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func joinVersion(v []int) string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+func dirExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
+
+func sameDir(a, b string) bool {
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return ra == rb
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
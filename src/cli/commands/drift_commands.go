@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+	"github.com/spf13/cobra"
+)
+
+// DriftHistory is the `ctx drift history --json` payload.
+type DriftHistory struct {
+	Series      map[string][]runtimedrift.Point `json:"series"`
+	Regressions []runtimedrift.Regression       `json:"regressions"`
+}
+
+// GetDriftCommand returns the `drift` command for recorded drift scores.
+func GetDriftCommand() *cobra.Command {
+	driftCmd := &cobra.Command{Use: "drift", Short: "Inspect drift detection history"}
+	driftCmd.AddCommand(newDriftHistoryCmd())
+	return driftCmd
+}
+
+// newDriftHistoryCmd returns the `history` subcommand which prints recorded
+// drift scores and the regressions between runs.
+func newDriftHistoryCmd() *cobra.Command {
+	var (
+		component string
+		last      int
+		tolerance float64
+		asJSON    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show drift score trends and regressions from " + runtimedrift.HistoryFile,
+		Example: `  ctx drift history
+  ctx drift history --component SupportBot --last 20
+  ctx drift history --tolerance 0.1 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := runtimedrift.LoadHistory(mustGetwd())
+			if err != nil {
+				return err
+			}
+			res := DriftHistory{Series: map[string][]runtimedrift.Point{}}
+			var points []runtimedrift.Point
+			var keys []string
+			for _, p := range history {
+				if p.Kind != runtimedrift.KindDrift || (component != "" && !strings.EqualFold(component, p.Component)) {
+					continue
+				}
+				if _, ok := res.Series[p.Component]; !ok {
+					keys = append(keys, p.Component)
+				}
+				res.Series[p.Component] = append(res.Series[p.Component], p)
+				points = append(points, p)
+			}
+			sort.Strings(keys)
+			res.Regressions = runtimedrift.Regressions(points, tolerance)
+			for _, k := range keys {
+				if pts := res.Series[k]; last > 0 && len(pts) > last {
+					res.Series[k] = pts[len(pts)-last:]
+				}
+			}
+			if last > 0 && len(res.Regressions) > last {
+				res.Regressions = res.Regressions[len(res.Regressions)-last:]
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				if res.Regressions == nil {
+					res.Regressions = []runtimedrift.Regression{}
+				}
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(res)
+			}
+			if len(keys) == 0 {
+				fmt.Fprintln(out, "no drift runs recorded")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "COMPONENT\tTIME\tSOURCE\tSCORE\tDELTA\tPASSED")
+			for _, k := range keys {
+				prev := -1.0
+				for _, p := range res.Series[k] {
+					delta := "-"
+					if prev >= 0 {
+						delta = fmt.Sprintf("%+.3f", p.Score-prev)
+					}
+					source := p.Schedule
+					if source == "" {
+						source = "ctx test"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%s\t%d/%d\n", k, p.Time.Format("2006-01-02 15:04"), source, p.Score, delta, p.Passed, p.Total)
+					prev = p.Score
+				}
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if len(res.Regressions) == 0 {
+				fmt.Fprintln(out, "\nNo regressions.")
+				return nil
+			}
+			fmt.Fprintln(out, "\nRegressions:")
+			tw = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tCOMPONENT\tTEST\tPREVIOUS\tCURRENT\tDELTA")
+			for _, r := range res.Regressions {
+				test := r.Case
+				if test == "" {
+					test = "(score)"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%.3f\t%+.3f\n", r.Time.Format("2006-01-02 15:04"), r.Component, test, r.Previous, r.Current, r.Current-r.Previous)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&component, "component", "", "Only show this component")
+	cmd.Flags().IntVar(&last, "last", 10, "Number of runs to show per component (0 for all)")
+	cmd.Flags().Float64Var(&tolerance, "tolerance", 0.05, "Similarity drop of a test case reported as a regression")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the history as JSON")
+	return cmd
+}
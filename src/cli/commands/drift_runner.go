@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+	runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 	"gopkg.in/yaml.v3"
 )
 
@@ -57,6 +63,8 @@ type DriftRunReport struct {
 	Failed    int               `json:"failed"`
 	Total     int               `json:"total"`
 	Results   []DriftTestResult `json:"results"`
+	// DurationMs is the wall time of the whole spec.
+	DurationMs int64 `json:"duration_ms"`
 }
 
 type DriftTestResult struct {
@@ -65,6 +73,35 @@ type DriftTestResult struct {
 	Similarity float64  `json:"similarity,omitempty"`
 	Threshold  float64  `json:"threshold,omitempty"`
 	Reasons    []string `json:"reasons,omitempty"`
+	// Warnings do not fail the case, e.g. an expired document it matched.
+	Warnings []string `json:"warnings,omitempty"`
+	// Baseline is the similarity recorded in the baseline before this run.
+	Baseline   float64 `json:"baseline,omitempty"`
+	DurationMs int64   `json:"duration_ms"`
+
+	// expiredMatch names the expired document that matched the input better
+	// than any document in effect.
+	expiredMatch string
+}
+
+// DriftSummary is the ctx.drift/v1 payload of `ctx test --drift-detection`.
+type DriftSummary struct {
+	Passed     bool             `json:"passed"`
+	Components []DriftRunReport `json:"components"`
+}
+
+// SummarizeDrift reports whether every test case of reports passed.
+func SummarizeDrift(reports []DriftRunReport) DriftSummary {
+	sum := DriftSummary{Passed: true, Components: reports}
+	for _, rep := range reports {
+		if rep.Failed > 0 {
+			sum.Passed = false
+		}
+	}
+	if sum.Components == nil {
+		sum.Components = []DriftRunReport{}
+	}
+	return sum
 }
 
 // RunDriftDetection discovers and executes all rag_drift_test.yaml specs
@@ -74,52 +111,141 @@ type DriftOptions struct {
 	UseSemantic     bool
 	ComponentFilter string
 	WriteJUnit      bool
+	// Concurrency is the number of specs run at once; 0 uses the number of CPUs.
+	Concurrency int
+	// Timeout bounds each spec; 0 uses testing.max_test_duration of the
+	// active environment config, if set.
+	Timeout time.Duration
 }
 
 func RunDriftDetection(ctx context.Context, projectRoot string, opts DriftOptions) error {
+	_, err := RunDriftDetectionReport(ctx, projectRoot, opts)
+	return err
+}
+
+// RunDriftDetectionReport is RunDriftDetection returning the per-component
+// reports as well.
+func RunDriftDetectionReport(ctx context.Context, projectRoot string, opts DriftOptions) ([]DriftRunReport, error) {
 	if projectRoot == "" {
 		var err error
 		projectRoot, err = os.Getwd()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	specs, err := findDriftSpecs(projectRoot)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(specs) == 0 {
-		return errors.New("no drift specs found (looking for tests/**/rag_drift_test.yaml)")
+		return nil, errors.New("no drift specs found (looking for tests/**/rag_drift_test.yaml)")
+	}
+
+	fmt.Println("Running drift detection tests...")
+	fmt.Println()
+
+	index, overallErr := runDriftSpecs(ctx, projectRoot, specs, opts)
+	points := make([]runtimedrift.Point, 0, len(index))
+	for _, rep := range index {
+		points = append(points, driftPoint(rep))
+	}
+	if _, err := runtimedrift.Record(projectRoot, points); err != nil {
+		return index, fmt.Errorf("record drift history: %w", err)
+	}
+	for _, rep := range index {
+		// Print detailed drift detection results
+		printDriftResult(rep)
 	}
 
+	// Print summary
+	fmt.Println()
+	printDriftSummary(index)
+	return index, overallErr
+}
+
+// driftPoint is the history point of a drift run: its score is the fraction
+// of test cases that passed, and each case keeps its similarity.
+func driftPoint(rep DriftRunReport) runtimedrift.Point {
+	p := runtimedrift.Point{
+		Kind: runtimedrift.KindDrift, Component: rep.Component,
+		Passed: rep.Passed, Failed: rep.Failed, Total: rep.Total,
+		Cases: make(map[string]float64, len(rep.Results)),
+	}
+	if rep.Total > 0 {
+		p.Score = float64(rep.Passed) / float64(rep.Total)
+	}
+	for _, r := range rep.Results {
+		if r.Status != "ERROR" {
+			p.Cases[r.Name] = r.Similarity
+		}
+	}
+	return p
+}
+
+// runDriftSpecs runs the drift specs that match opts, opts.Concurrency at a
+// time, and writes the JSON (and optionally JUnit) reports. Reports keep the
+// order of specs.
+func runDriftSpecs(ctx context.Context, projectRoot string, specs []string, opts DriftOptions) ([]DriftRunReport, error) {
 	// Ensure output directory exists
 	outDir := opts.OutDir
 	if outDir == "" {
 		outDir = filepath.Join(projectRoot, "tests", "reports")
 	}
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return fmt.Errorf("create report dir: %w", err)
+		return nil, fmt.Errorf("create report dir: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		var err error
+		if timeout, err = maxTestDuration(projectRoot); err != nil {
+			return nil, err
+		}
 	}
 
-	var (
-		overallErr error
-		index      = make([]DriftRunReport, 0, len(specs))
-	)
-
-	fmt.Println("Running drift detection tests...")
-	fmt.Println()
-
+	var selected []string
 	for _, specPath := range specs {
-		comp := componentFromSpecPath(specPath)
-		if opts.ComponentFilter != "" && !strings.EqualFold(opts.ComponentFilter, comp) {
+		if opts.ComponentFilter != "" && !strings.EqualFold(opts.ComponentFilter, componentFromSpecPath(specPath)) {
 			continue
 		}
-		rep, err := runSingleSpec(ctx, projectRoot, specPath, opts)
-		if err != nil {
-			overallErr = err
+		selected = append(selected, specPath)
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(selected) {
+		workers = len(selected)
+	}
+	reports := make([]DriftRunReport, len(selected))
+	errs := make([]error, len(selected))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reports[i], errs[i] = runTimedSpec(ctx, projectRoot, selected[i], opts, timeout)
+			}
+		}()
+	}
+	for i := range selected {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var (
+		overallErr error
+		index      = make([]DriftRunReport, 0, len(selected))
+	)
+	for i, rep := range reports {
+		if errs[i] != nil {
+			overallErr = errs[i]
 		}
 		// Write JSON report per component
+		comp := componentFromSpecPath(selected[i])
 		if comp == "" {
 			comp = "unknown"
 		}
@@ -128,14 +254,8 @@ func RunDriftDetection(ctx context.Context, projectRoot string, opts DriftOption
 			_ = os.WriteFile(outFile, by, 0o644)
 		}
 		index = append(index, rep)
-		// Print detailed drift detection results
-		printDriftResult(rep)
 	}
 
-	// Print summary
-	fmt.Println()
-	printDriftSummary(index)
-
 	// Write index summary
 	if by, mErr := json.MarshalIndent(index, "", "  "); mErr == nil {
 		_ = os.WriteFile(filepath.Join(outDir, "drift_index.json"), by, 0o644)
@@ -143,8 +263,44 @@ func RunDriftDetection(ctx context.Context, projectRoot string, opts DriftOption
 	if opts.WriteJUnit {
 		_ = writeJUnit(filepath.Join(outDir, "junit-drift.xml"), index)
 	}
+	return index, overallErr
+}
 
-	return overallErr
+// runTimedSpec runs one spec within timeout (none when 0) and records its
+// wall time.
+func runTimedSpec(ctx context.Context, projectRoot, specPath string, opts DriftOptions, timeout time.Duration) (DriftRunReport, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	rep, err := runSingleSpec(ctx, projectRoot, specPath, opts)
+	rep.DurationMs = time.Since(start).Milliseconds()
+	return rep, err
+}
+
+// maxTestDuration reads testing.max_test_duration from the active
+// environment config; 0 means specs run without a time limit.
+func maxTestDuration(projectRoot string) (time.Duration, error) {
+	env, err := runtimeconfig.Load(projectRoot)
+	if err != nil {
+		return 0, err
+	}
+	var cfg struct {
+		MaxTestDuration string `yaml:"max_test_duration"`
+	}
+	if err := env.Section("testing", &cfg); err != nil {
+		return 0, err
+	}
+	if cfg.MaxTestDuration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(cfg.MaxTestDuration)
+	if err != nil {
+		return 0, fmt.Errorf("testing.max_test_duration: %w", err)
+	}
+	return d, nil
 }
 
 // printDriftResult prints detailed information about a drift detection result
@@ -166,6 +322,13 @@ func printDriftResult(rep DriftRunReport) {
 	if rep.SpecPath != "" {
 		fmt.Printf("   📄 Spec: %s\n", rep.SpecPath)
 	}
+	fmt.Printf("   ⏱️  Duration: %s\n", time.Duration(rep.DurationMs)*time.Millisecond)
+
+	for _, result := range rep.Results {
+		for _, w := range result.Warnings {
+			fmt.Printf("   ⚠️  %s: %s\n", result.Name, w)
+		}
+	}
 
 	// Print detailed test results for failed tests
 	if rep.Failed > 0 {
@@ -194,11 +357,15 @@ func printDriftSummary(reports []DriftRunReport) {
 	totalTests := 0
 	totalPassed := 0
 	totalFailed := 0
+	var slowest DriftRunReport
 
 	for _, rep := range reports {
 		totalTests += rep.Total
 		totalPassed += rep.Passed
 		totalFailed += rep.Failed
+		if rep.DurationMs >= slowest.DurationMs {
+			slowest = rep
+		}
 	}
 
 	fmt.Printf("📊 Drift Detection Summary:\n")
@@ -206,6 +373,9 @@ func printDriftSummary(reports []DriftRunReport) {
 	fmt.Printf("   Total Tests: %d\n", totalTests)
 	fmt.Printf("   Passed: %d\n", totalPassed)
 	fmt.Printf("   Failed: %d\n", totalFailed)
+	if slowest.Component != "" {
+		fmt.Printf("   Slowest: %s (%s)\n", slowest.Component, time.Duration(slowest.DurationMs)*time.Millisecond)
+	}
 
 	if totalFailed == 0 {
 		fmt.Printf("   🎉 Overall Status: ALL DRIFT TESTS PASSED\n")
@@ -247,22 +417,45 @@ func runSingleSpec(ctx context.Context, projectRoot, specPath string, opts Drift
 
 	// Load documents for naive similarity
 	docs := loadComponentDocuments(projectRoot, component)
+	now := time.Now()
 
 	report := DriftRunReport{Component: component, SpecPath: specPath}
 	// Load baseline (if any)
 	baseSim := loadBaseline(projectRoot, component)
 
 	for _, tc := range spec.TestCases {
+		if err := ctx.Err(); err != nil {
+			// Cases left when the spec ran out of time are not evaluated
+			report.Results = append(report.Results, DriftTestResult{Name: tc.Name, Status: "ERROR", Reasons: []string{specTimeoutReason(err)}})
+			report.Failed++
+			report.Total++
+			continue
+		}
+		start := time.Now()
 		var r DriftTestResult
 		if opts.UseSemantic {
-			r = evaluateTestCaseSemantic(projectRoot, component, tc, spec)
+			r = evaluateTestCaseSemantic(ctx, projectRoot, component, tc, spec)
 		} else {
-			r = evaluateTestCase(tc, spec, docs)
+			r = evaluateTestCase(tc, spec, docs, now)
+		}
+		r.DurationMs = time.Since(start).Milliseconds()
+		if err := ctx.Err(); err != nil {
+			r.Status = "ERROR"
+			r.Reasons = append(r.Reasons, specTimeoutReason(err))
 		}
 
+		prev, hasBaseline := baseSim[tc.Name]
+		r.Baseline = prev
+		if r.expiredMatch != "" {
+			if hasBaseline && !opts.UpdateBaseline {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("baseline %.3f may rely on expired document %s; re-record it with --update-baseline", prev, r.expiredMatch))
+			} else {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("expired document %s matches better than the documents in effect and was ignored", r.expiredMatch))
+			}
+		}
 		// Compare against baseline if present and not updating
 		if !opts.UpdateBaseline {
-			if prev, ok := baseSim[tc.Name]; ok {
+			if hasBaseline {
 				delta := prev - r.Similarity
 				// default alert threshold 0.15 unless overridden via env
 				alert := 0.15
@@ -290,22 +483,39 @@ func runSingleSpec(ctx context.Context, projectRoot, specPath string, opts Drift
 	if opts.UpdateBaseline {
 		sims := make(map[string]float64, len(report.Results))
 		for _, r := range report.Results {
-			sims[r.Name] = r.Similarity
+			if r.Status != "ERROR" {
+				sims[r.Name] = r.Similarity
+			}
 		}
 		_ = saveBaseline(projectRoot, component, sims)
 	}
 	return report, nil
 }
 
+// specTimeoutReason describes why a case was cut short.
+func specTimeoutReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "spec exceeded max_test_duration"
+	}
+	return "spec canceled: " + err.Error()
+}
+
 func componentFromSpecPath(p string) string {
 	// tests/<Component>/rag_drift_test.yaml
 	dir := filepath.Dir(p)
 	return filepath.Base(dir)
 }
 
-func loadComponentDocuments(root, component string) []string {
+// driftDocument is a component document with the metadata of its front matter.
+type driftDocument struct {
+	path string
+	text string
+	meta map[string]interface{}
+}
+
+func loadComponentDocuments(root, component string) []driftDocument {
 	// Read markdown and text files under memory/<Component>/documents
-	var docs []string
+	var docs []driftDocument
 	base := filepath.Join(root, "memory", component, "documents")
 	_ = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -317,7 +527,9 @@ func loadComponentDocuments(root, component string) []string {
 		lower := strings.ToLower(path)
 		if strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".txt") {
 			if by, err := os.ReadFile(path); err == nil {
-				docs = append(docs, string(by))
+				rel, _ := filepath.Rel(base, path)
+				text, meta := runtimememory.ParseFrontMatter(string(by))
+				docs = append(docs, driftDocument{path: filepath.ToSlash(rel), text: text, meta: meta})
 			}
 		}
 		return nil
@@ -325,16 +537,30 @@ func loadComponentDocuments(root, component string) []string {
 	return docs
 }
 
-func evaluateTestCase(tc driftTestCase, spec driftTestSpec, documents []string) DriftTestResult {
+// evaluateTestCase scores tc against the documents in effect at now, as
+// retrieval would; expired and not yet valid documents are left out.
+func evaluateTestCase(tc driftTestCase, spec driftTestSpec, documents []driftDocument, now time.Time) DriftTestResult {
 	res := DriftTestResult{Name: tc.Name}
 	// Compute naive similarity as best match among docs
-	best := 0.0
+	best, bestExpired := 0.0, 0.0
+	var texts []string
 	for _, doc := range documents {
-		sim := jaccardSimilarity(tokenize(tc.Input), tokenize(doc))
+		sim := jaccardSimilarity(tokenize(tc.Input), tokenize(doc.text))
+		if !runtimememory.DocumentInEffect(doc.meta, now) {
+			if runtimememory.DocumentExpired(doc.meta, now) && sim > bestExpired {
+				bestExpired = sim
+				res.expiredMatch = fmt.Sprintf("%s (valid_until %v)", doc.path, doc.meta[runtimememory.MetaValidUntil])
+			}
+			continue
+		}
+		texts = append(texts, doc.text)
 		if sim > best {
 			best = sim
 		}
 	}
+	if bestExpired <= best {
+		res.expiredMatch = ""
+	}
 	res.Similarity = best
 	// Determine threshold
 	thr := tc.ExpectedSimilarity
@@ -352,7 +578,7 @@ func evaluateTestCase(tc driftTestCase, spec driftTestSpec, documents []string)
 		reasons = append(reasons, fmt.Sprintf("similarity %.3f < threshold %.3f", best, thr))
 	}
 	// For keyword checks, use concatenation of all documents (cheap heuristic)
-	joined := strings.ToLower(strings.Join(documents, "\n"))
+	joined := strings.ToLower(strings.Join(texts, "\n"))
 	for _, kw := range tc.RequiredKeywords {
 		if !containsWord(joined, strings.ToLower(kw)) {
 			reasons = append(reasons, fmt.Sprintf("missing required keyword: %q", kw))
@@ -380,7 +606,7 @@ func evaluateTestCase(tc driftTestCase, spec driftTestSpec, documents []string)
 }
 
 // evaluateTestCaseSemantic attempts to use tools/<Component>/semantic_search.py to compute similarity
-func evaluateTestCaseSemantic(projectRoot, component string, tc driftTestCase, spec driftTestSpec) DriftTestResult {
+func evaluateTestCaseSemantic(ctx context.Context, projectRoot, component string, tc driftTestCase, spec driftTestSpec) DriftTestResult {
 	r := DriftTestResult{Name: tc.Name}
 	// Build a small python snippet to import the tool and run a search
 	className := fmt.Sprintf("%sSemanticSearch", component)
@@ -394,10 +620,10 @@ func evaluateTestCaseSemantic(projectRoot, component string, tc driftTestCase, s
 		"print(json.dumps(res[0]['similarity'] if res else 0.0))",
 	}, ";")
 
-	out, err := runPython(py)
+	out, err := runPython(ctx, projectRoot, py)
 	if err != nil {
 		// fallback to naive if python fails
-		return evaluateTestCase(tc, spec, loadComponentDocuments(projectRoot, component))
+		return evaluateTestCase(tc, spec, loadComponentDocuments(projectRoot, component), time.Now())
 	}
 	sim := parseJSONFloat(strings.TrimSpace(out))
 	r.Similarity = sim
@@ -452,8 +678,9 @@ func parseFloat(s string) (float64, error) { return strconv.ParseFloat(strings.T
 
 func escapePyString(s string) string { return strings.ReplaceAll(s, "'", "\\'") }
 
-func runPython(code string) (string, error) {
-	cmd := exec.Command("python3", "-c", code)
+// runPython runs code with the project's interpreter (see pyenv.Bin).
+func runPython(ctx context.Context, projectRoot, code string) (string, error) {
+	cmd := pyenv.CommandContext(ctx, projectRoot, "-c", code)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return string(out), err
@@ -473,7 +700,7 @@ func parseJSONFloat(s string) float64 {
 	return 0
 }
 
-// Optional JUnit writer for CI integrations
+// Optional JUnit writer for CI integrations: one testsuite per component.
 func writeJUnit(path string, reports []DriftRunReport) error {
 	var total, failures int
 	for _, r := range reports {
@@ -482,21 +709,23 @@ func writeJUnit(path string, reports []DriftRunReport) error {
 	}
 	b := &strings.Builder{}
 	fmt.Fprintf(b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
-	fmt.Fprintf(b, "<testsuite name=\"drift\" tests=\"%d\" failures=\"%d\">\n", total, failures)
+	fmt.Fprintf(b, "<testsuites name=\"drift\" tests=\"%d\" failures=\"%d\">\n", total, failures)
 	for _, r := range reports {
+		fmt.Fprintf(b, "  <testsuite name=\"%s\" tests=\"%d\" failures=\"%d\" time=\"%.3f\">\n", xmlEscape(r.Component), r.Total, r.Failed, float64(r.DurationMs)/1000)
 		for _, t := range r.Results {
-			fmt.Fprintf(b, "  <testcase classname=\"%s\" name=\"%s\">\n", xmlEscape(r.Component), xmlEscape(t.Name))
+			fmt.Fprintf(b, "    <testcase classname=\"%s\" name=\"%s\" time=\"%.3f\">\n", xmlEscape(r.Component), xmlEscape(t.Name), float64(t.DurationMs)/1000)
 			if t.Status != "PASSED" {
 				msg := ""
 				if len(t.Reasons) > 0 {
 					msg = strings.Join(t.Reasons, "; ")
 				}
-				fmt.Fprintf(b, "    <failure message=\"%s\"/>\n", xmlEscape(msg))
+				fmt.Fprintf(b, "      <failure message=\"%s\"/>\n", xmlEscape(msg))
 			}
-			fmt.Fprintf(b, "  </testcase>\n")
+			fmt.Fprintf(b, "    </testcase>\n")
 		}
+		fmt.Fprintf(b, "  </testsuite>\n")
 	}
-	fmt.Fprintf(b, "</testsuite>\n")
+	fmt.Fprintf(b, "</testsuites>\n")
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
 	}
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	runtimeeval "github.com/contexis-cmp/contexis/src/runtime/eval"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	"github.com/spf13/cobra"
+)
+
+// EvalOptions configures RunEvals.
+type EvalOptions struct {
+	// OutDir defaults to tests/reports/evals under the project root.
+	OutDir          string
+	ComponentFilter string
+	SpecFilter      string
+	WriteJUnit      bool
+	// JudgeProviders names routing.yaml providers for llm_judge graders; empty
+	// uses the default routing chain.
+	JudgeProviders []string
+	// Handler serves the chat requests; nil builds the runtime server handler.
+	Handler http.Handler
+	// Judge overrides the judge provider (used by tests).
+	Judge runtimemodel.Provider
+}
+
+// GetEvalCommand returns the `eval` command for evaluation suites.
+func GetEvalCommand() *cobra.Command {
+	evalCmd := &cobra.Command{Use: "eval", Short: "Run evaluation suites and track score trends"}
+	evalCmd.AddCommand(newEvalRunCmd(), newEvalTrendCmd())
+	return evalCmd
+}
+
+// newEvalRunCmd returns the `run` subcommand which executes tests/<Component>/evals/*.yaml.
+func newEvalRunCmd() *cobra.Command {
+	var opts EvalOptions
+	var judge string
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run evaluation suites from tests/<Component>/evals",
+		Example: `  ctx eval run
+  ctx eval run --component SupportBot --junit
+  ctx eval run --judge-provider primary,backup`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if judge != "" {
+				opts.JudgeProviders = strings.Split(judge, ",")
+			}
+			reports, err := RunEvals(cmd.Context(), mustGetwd(), opts, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			for _, r := range reports {
+				if r.Failed > 0 {
+					return fmt.Errorf("evaluation failed")
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.OutDir, "out", "", "Output directory for reports (default tests/reports/evals)")
+	cmd.Flags().StringVar(&opts.ComponentFilter, "component", "", "Limit to a single component")
+	cmd.Flags().StringVar(&opts.SpecFilter, "spec", "", "Limit to a single suite name")
+	cmd.Flags().BoolVar(&opts.WriteJUnit, "junit", false, "Write JUnit XML report for CI integration")
+	cmd.Flags().StringVar(&judge, "judge-provider", "", "Comma-separated routing.yaml providers for llm_judge graders")
+	return cmd
+}
+
+// RunEvals discovers and runs evaluation suites, writes JSON (and optionally
+// JUnit) reports, appends to the trend history and prints a summary to out.
+func RunEvals(ctx context.Context, projectRoot string, opts EvalOptions, out io.Writer) ([]*runtimeeval.Report, error) {
+	paths, err := runtimeeval.FindSpecs(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	var specs []*runtimeeval.Spec
+	for _, p := range paths {
+		spec, err := runtimeeval.LoadSpec(p)
+		if err != nil {
+			return nil, err
+		}
+		if opts.ComponentFilter != "" && !strings.EqualFold(opts.ComponentFilter, spec.Component) {
+			continue
+		}
+		if opts.SpecFilter != "" && opts.SpecFilter != spec.Name {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no evaluation suites found (looking for tests/<Component>/evals/*.yaml)")
+	}
+
+	outDir := opts.OutDir
+	if outDir == "" {
+		outDir = filepath.Join(projectRoot, runtimeeval.ReportsDir)
+	}
+	history, err := runtimeeval.LoadHistory(outDir)
+	if err != nil {
+		return nil, err
+	}
+	previous := runtimeeval.Latest(history)
+
+	env, err := evalEnv(projectRoot, opts)
+	if err != nil {
+		return nil, err
+	}
+	handler := opts.Handler
+	if handler == nil {
+		handler = runtimeserver.NewHandler(projectRoot)
+	}
+	runner := &runtimeeval.Runner{Respond: chatResponder(handler), Env: env}
+
+	var reports []*runtimeeval.Report
+	for _, spec := range specs {
+		rep, err := runner.Run(ctx, spec)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, rep)
+		printEvalReport(out, rep, previous)
+	}
+
+	if err := runtimeeval.WriteReports(outDir, reports); err != nil {
+		return reports, err
+	}
+	if err := runtimeeval.AppendHistory(outDir, reports); err != nil {
+		return reports, err
+	}
+	if opts.WriteJUnit {
+		if err := runtimeeval.WriteJUnit(filepath.Join(outDir, "junit-eval.xml"), reports); err != nil {
+			return reports, err
+		}
+	}
+	fmt.Fprintf(out, "Reports written to %s\n", outDir)
+	return reports, nil
+}
+
+// evalEnv resolves the judge providers from routing.yaml, falling back to the
+// environment provider.
+func evalEnv(projectRoot string, opts EvalOptions) (runtimeeval.Env, error) {
+	if opts.Judge != nil {
+		return runtimeeval.Env{Judge: opts.Judge}, nil
+	}
+	fallback, _ := runtimemodel.FromEnv()
+	cfg, err := runtimemodel.LoadRoutingConfig(projectRoot)
+	if err != nil {
+		return runtimeeval.Env{}, err
+	}
+	router, err := runtimemodel.NewRouter(cfg, fallback)
+	if err != nil {
+		return runtimeeval.Env{}, err
+	}
+	env := runtimeeval.Env{Provider: func(name string) runtimemodel.Provider {
+		if fp := router.Named(name); fp != nil {
+			return fp
+		}
+		return nil
+	}}
+	judge := router.For("", "")
+	if len(opts.JudgeProviders) > 0 {
+		if judge = router.Named(opts.JudgeProviders...); judge == nil {
+			return env, fmt.Errorf("unknown judge provider %q", strings.Join(opts.JudgeProviders, ","))
+		}
+	}
+	if judge != nil {
+		env.Judge = judge
+	}
+	return env, nil
+}
+
+// chatResponder answers cases in-process through the chat endpoint, so every
+// runtime policy (guardrails, middleware, routing) applies as in production.
+func chatResponder(handler http.Handler) runtimeeval.Responder {
+	return func(ctx context.Context, spec *runtimeeval.Spec, c runtimeeval.Case) (string, error) {
+		data := map[string]interface{}{}
+		for k, v := range c.Data {
+			data[k] = v
+		}
+		data["user_input"] = c.Input
+		promptFile := spec.PromptFile
+		if promptFile == "" {
+			promptFile = "agent_response.md"
+		}
+		body, _ := json.Marshal(runtimeserver.ChatRequest{
+			TenantID: spec.TenantID, Context: spec.Context, Component: spec.Component,
+			Query: c.Input, Data: data, PromptFile: promptFile,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		if key := os.Getenv("CMP_API_KEY"); key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			return "", fmt.Errorf("chat returned %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+		var resp runtimeserver.ChatResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			return "", fmt.Errorf("decode chat response: %w", err)
+		}
+		return resp.Rendered, nil
+	}
+}
+
+func printEvalReport(out io.Writer, rep *runtimeeval.Report, previous map[string]runtimeeval.TrendPoint) {
+	fmt.Fprintf(out, "Evaluation: %s/%s\n", rep.Component, rep.Spec)
+	trend := ""
+	if prev, ok := previous[rep.Point().Key()]; ok {
+		trend = fmt.Sprintf(" (%+.3f vs previous run)", rep.Score-prev.Score)
+	}
+	fmt.Fprintf(out, "   Score: %.3f%s\n", rep.Score, trend)
+	fmt.Fprintf(out, "   Results: %d passed, %d failed, %d total\n", rep.Passed, rep.Failed, rep.Total)
+	for _, c := range rep.Cases {
+		if c.Status == runtimeeval.StatusPassed {
+			continue
+		}
+		fmt.Fprintf(out, "   %s %s (score %.3f)\n", c.Status, c.Name, c.Score)
+		if c.Error != "" {
+			fmt.Fprintf(out, "      %s\n", c.Error)
+		}
+		for _, g := range c.Graders {
+			if !g.Passed {
+				fmt.Fprintf(out, "      %s: %.3f < %.3f %s\n", g.Type, g.Score, g.Threshold, g.Reason)
+			}
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// newEvalTrendCmd returns the `trend` subcommand which prints recorded suite scores.
+func newEvalTrendCmd() *cobra.Command {
+	var (
+		outDir    string
+		component string
+		last      int
+		asJSON    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "trend",
+		Short: "Show evaluation score trends from tests/reports/evals/history.jsonl",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outDir == "" {
+				outDir = filepath.Join(mustGetwd(), runtimeeval.ReportsDir)
+			}
+			history, err := runtimeeval.LoadHistory(outDir)
+			if err != nil {
+				return err
+			}
+			bySuite := map[string][]runtimeeval.TrendPoint{}
+			var keys []string
+			for _, p := range history {
+				if component != "" && !strings.EqualFold(component, p.Component) {
+					continue
+				}
+				if _, ok := bySuite[p.Key()]; !ok {
+					keys = append(keys, p.Key())
+				}
+				bySuite[p.Key()] = append(bySuite[p.Key()], p)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if pts := bySuite[k]; last > 0 && len(pts) > last {
+					bySuite[k] = pts[len(pts)-last:]
+				}
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(bySuite)
+			}
+			if len(keys) == 0 {
+				fmt.Fprintln(out, "no evaluation runs recorded")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "SUITE\tTIME\tSCORE\tDELTA\tPASSED")
+			for _, k := range keys {
+				prev := -1.0
+				for _, p := range bySuite[k] {
+					delta := "-"
+					if prev >= 0 {
+						delta = fmt.Sprintf("%+.3f", p.Score-prev)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%.3f\t%s\t%d/%d\n", k, p.Time.Format("2006-01-02 15:04"), p.Score, delta, p.Passed, p.Total)
+					prev = p.Score
+				}
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&outDir, "out", "", "Report directory (default tests/reports/evals)")
+	cmd.Flags().StringVar(&component, "component", "", "Only show this component")
+	cmd.Flags().IntVar(&last, "last", 10, "Number of runs to show per suite (0 for all)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the trend as JSON")
+	return cmd
+}
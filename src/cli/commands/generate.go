@@ -21,11 +21,16 @@ Available generators:
   agent     - Conversational agents with tools
   workflow  - Multi-step AI processing pipelines
   plugin    - Scaffolds a plugin template
+  context   - Interactive wizard for a schema-checked context, prompt and evals
+  tool      - Custom tool (Go or Python) served over HTTP and registered in a context
 
 Examples:
   ctx generate rag CustomerDocs --db=sqlite --embeddings=openai
   ctx generate agent SupportBot --tools=web_search,database --memory=episodic
-  ctx generate workflow ContentPipeline --steps=research,write,review`,
+  ctx generate agent MyAPIBot --from-openapi api.yaml
+  ctx generate workflow ContentPipeline --steps=research,write,review
+  ctx generate context BillingAssistant
+  ctx generate tool OrderLookup --component SupportBot --lang go --schema params.json`,
 	Args: cobra.ExactArgs(2),
 	RunE: runGenerate,
 }
@@ -43,7 +48,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		zap.String("name", name))
 
 	// Validate generator type
-	validTypes := []string{"rag", "agent", "workflow", "plugin"}
+	validTypes := []string{"rag", "agent", "workflow", "plugin", "context", "tool"}
 	isValid := false
 	for _, validType := range validTypes {
 		if generatorType == validType {
@@ -69,6 +74,14 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	tools, _ := cmd.Flags().GetString("tools")
 	memory, _ := cmd.Flags().GetString("memory")
 	steps, _ := cmd.Flags().GetString("steps")
+	fromOpenAPI, _ := cmd.Flags().GetString("from-openapi")
+	lang, _ := cmd.Flags().GetString("lang")
+	schema, _ := cmd.Flags().GetString("schema")
+	component, _ := cmd.Flags().GetString("component")
+	port, _ := cmd.Flags().GetInt("port")
+
+	// Snapshot the project so structured output can list what was written
+	before := snapshotFiles(mustGetwd())
 
 	// Generate based on type
 	var result error
@@ -76,11 +89,32 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	case "rag":
 		result = generateRAG(ctx, name, dbType, embeddings)
 	case "agent":
-		result = GenerateAgent(ctx, name, tools, memory)
+		if fromOpenAPI != "" {
+			result = GenerateAgentFromOpenAPI(ctx, name, tools, memory, fromOpenAPI)
+		} else {
+			result = GenerateAgent(ctx, name, tools, memory)
+		}
 	case "workflow":
 		result = GenerateWorkflow(ctx, name, steps)
 	case "plugin":
 		result = GeneratePlugin(ctx, name)
+	case "context":
+		var files []string
+		if files, result = GenerateContextInteractive(mustGetwd(), name, cmd.InOrStdin(), cmd.OutOrStdout()); result == nil && OutputFormat(cmd) == OutputText {
+			fmt.Fprintln(cmd.OutOrStdout(), "\nCreated:")
+			for _, f := range files {
+				fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", f)
+			}
+		}
+	case "tool":
+		var files []string
+		opts := ToolOptions{Name: name, Component: component, Lang: lang, SchemaPath: schema, Port: port}
+		if files, result = GenerateTool(mustGetwd(), opts); result == nil && OutputFormat(cmd) == OutputText {
+			fmt.Fprintln(cmd.OutOrStdout(), "Created:")
+			for _, f := range files {
+				fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", f)
+			}
+		}
 	default:
 		result = fmt.Errorf("generator type '%s' not implemented yet", generatorType)
 	}
@@ -88,7 +122,23 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	// Complete the operation
 	done()
 
-	return result
+	if result != nil {
+		return result
+	}
+	_, err := EmitResult(cmd, SchemaGenerate, GenerateResult{
+		Type:  generatorType,
+		Name:  name,
+		Files: changedFiles(before, snapshotFiles(mustGetwd())),
+	}, nil)
+	return err
+}
+
+// GenerateResult is the ctx.generate/v1 payload of `ctx generate`; Files
+// are the project files the generator created or changed.
+type GenerateResult struct {
+	Type  string   `json:"type"`
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
 }
 
 func init() {
@@ -98,4 +148,9 @@ func init() {
 	GenerateCmd.Flags().String("tools", "", "Comma-separated list of tools for agent")
 	GenerateCmd.Flags().String("memory", "episodic", "Memory type for agent (episodic, none)")
 	GenerateCmd.Flags().String("steps", "", "Comma-separated list of workflow steps")
+	GenerateCmd.Flags().String("from-openapi", "", "OpenAPI spec (YAML or JSON) whose operations become agent tools")
+	GenerateCmd.Flags().String("lang", "python", "Language of a generated tool (go, python)")
+	GenerateCmd.Flags().String("schema", "", "JSON Schema file of a generated tool's arguments")
+	GenerateCmd.Flags().String("component", "", "Component whose context registers a generated tool")
+	GenerateCmd.Flags().Int("port", 0, "Port a generated tool serves on (default 8790, or the next port after the context's local tools)")
 }
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
 	"gopkg.in/yaml.v3"
 )
 
@@ -53,7 +54,10 @@ type GoTestsReport struct {
 	Results []GoSuiteResult `json:"results"`
 }
 
-func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) error {
+// EnableProviderRecording switches this process and the test processes it starts
+// to CMP_PROVIDER_MODE=record. The cassette directory is pinned to an absolute
+// path because `go test` runs each package from its own directory.
+func EnableProviderRecording(projectRoot string) error {
 	if projectRoot == "" {
 		var err error
 		projectRoot, err = os.Getwd()
@@ -61,9 +65,34 @@ func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) er
 			return err
 		}
 	}
+	if os.Getenv("CMP_CASSETTE_DIR") == "" {
+		if err := os.Setenv("CMP_CASSETTE_DIR", filepath.Join(projectRoot, runtimemodel.DefaultCassetteDir)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Recording provider outputs to %s\n", runtimemodel.CassettePath())
+	return os.Setenv("CMP_PROVIDER_MODE", runtimemodel.ModeRecord)
+}
+
+func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) error {
+	_, err := RunGoTestsReport(ctx, projectRoot, opts)
+	return err
+}
+
+// RunGoTestsReport is RunGoTests returning the per-suite results as well; the
+// report is returned even when a suite failed.
+func RunGoTestsReport(ctx context.Context, projectRoot string, opts TestRunOptions) (GoTestsReport, error) {
+	var report GoTestsReport
+	if projectRoot == "" {
+		var err error
+		projectRoot, err = os.Getwd()
+		if err != nil {
+			return report, err
+		}
+	}
 	cfg, err := loadTestConfig(projectRoot)
 	if err != nil {
-		return fmt.Errorf("failed to load test config: %w", err)
+		return report, fmt.Errorf("failed to load test config: %w", err)
 	}
 
 	outDir := opts.OutDir
@@ -71,7 +100,7 @@ func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) er
 		outDir = filepath.Join(projectRoot, "tests", "reports")
 	}
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return err
+		return report, err
 	}
 	covDir := filepath.Join(projectRoot, "tests", "coverage")
 	if opts.Coverage {
@@ -81,10 +110,9 @@ func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) er
 	// Determine suites to run
 	suites := determineSuites(cfg, opts)
 	if len(suites) == 0 {
-		return errors.New("no test suites selected")
+		return report, errors.New("no test suites selected")
 	}
 
-	var report GoTestsReport
 	overallPassed := true
 
 	fmt.Println("Running CMP tests...")
@@ -115,9 +143,9 @@ func RunGoTests(ctx context.Context, projectRoot string, opts TestRunOptions) er
 	}
 
 	if !overallPassed {
-		return errors.New("one or more Go test suites failed or did not meet coverage thresholds")
+		return report, errors.New("one or more Go test suites failed or did not meet coverage thresholds")
 	}
-	return nil
+	return report, nil
 }
 
 func determineSuites(cfg testConfig, opts TestRunOptions) []string {
@@ -3,7 +3,6 @@ package commands
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
 	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
@@ -23,20 +22,17 @@ func GetHFCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
-			if os.Getenv("HF_TOKEN") == "" || os.Getenv("HF_MODEL_ID") == "" {
-				return fmt.Errorf("HF_TOKEN and HF_MODEL_ID must be set")
-			}
-
-			logger.LogInfo(ctx, "Testing Hugging Face model",
-				zap.String("model", os.Getenv("HF_MODEL_ID")),
-				zap.String("prompt", args[0]))
-
 			prov, err := runtimemodel.NewHuggingFaceAPIProviderFromEnv()
 			if err != nil {
 				logger.LogErrorColored(ctx, "Failed to create HF provider", err)
 				return err
 			}
 
+			logger.LogInfo(ctx, "Testing Hugging Face model",
+				zap.String("model", prov.Model()),
+				zap.String("endpoint_type", prov.EndpointType()),
+				zap.String("prompt", args[0]))
+
 			out, err := prov.Generate(context.Background(), args[0], runtimemodel.Params{MaxNewTokens: 128})
 			if err != nil {
 				logger.LogErrorColored(ctx, "Failed to generate response", err)
@@ -45,7 +41,7 @@ func GetHFCommand() *cobra.Command {
 
 			logger.LogSuccess(ctx, "HF model test completed",
 				zap.Int("response_length", len(out)),
-				zap.String("model", os.Getenv("HF_MODEL_ID")))
+				zap.String("model", prov.Model()))
 
 			fmt.Println(out)
 			return nil
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+)
+
+// HTMLReportFile is the dashboard written by `ctx test --report html`.
+const HTMLReportFile = "report.html"
+
+// htmlReport is the data behind the dashboard.
+type htmlReport struct {
+	Generated time.Time
+	Drift     []DriftRunReport
+	Cases     []string // union of test case names, in first-seen order
+	Trends    []driftTrend
+	Tests     *GoTestsReport
+}
+
+// driftTrend is the score history of one component's drift tests.
+type driftTrend struct {
+	Component string
+	Points    []runtimedrift.Point
+}
+
+// WriteHTMLReport renders outDir/report.html from the JSON reports of the
+// latest drift and Go test runs in outDir (drift_index.json, go_tests.json)
+// and the drift score history of the project, and returns its path. Sections
+// without a report are left out; it fails when there is nothing to render.
+func WriteHTMLReport(projectRoot, outDir string) (string, error) {
+	if projectRoot == "" {
+		var err error
+		projectRoot, err = os.Getwd()
+		if err != nil {
+			return "", err
+		}
+	}
+	if outDir == "" {
+		outDir = filepath.Join(projectRoot, "tests", "reports")
+	}
+	data := htmlReport{Generated: time.Now().UTC()}
+	if ok, err := readJSONReport(filepath.Join(outDir, "drift_index.json"), &data.Drift); err != nil {
+		return "", err
+	} else if ok {
+		seen := map[string]bool{}
+		for _, rep := range data.Drift {
+			for _, r := range rep.Results {
+				if !seen[r.Name] {
+					seen[r.Name] = true
+					data.Cases = append(data.Cases, r.Name)
+				}
+			}
+		}
+		history, err := runtimedrift.LoadHistory(projectRoot)
+		if err != nil {
+			return "", err
+		}
+		data.Trends = driftTrends(data.Drift, history, data.Generated)
+	}
+	var tests GoTestsReport
+	if ok, err := readJSONReport(filepath.Join(outDir, "go_tests.json"), &tests); err != nil {
+		return "", err
+	} else if ok {
+		data.Tests = &tests
+	}
+	if data.Drift == nil && data.Tests == nil {
+		return "", fmt.Errorf("no reports in %s: run ctx test or ctx test --drift-detection first", outDir)
+	}
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("render report: %w", err)
+	}
+	path := filepath.Join(outDir, HTMLReportFile)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// readJSONReport decodes path into v, reporting false when it does not exist.
+func readJSONReport(path string, v interface{}) (bool, error) {
+	by, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(by, v); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// driftTrends returns the recorded drift scores of each reported component;
+// a component without history gets the score of its report.
+func driftTrends(reports []DriftRunReport, history []runtimedrift.Point, now time.Time) []driftTrend {
+	trends := make([]driftTrend, 0, len(reports))
+	for _, rep := range reports {
+		t := driftTrend{Component: rep.Component}
+		for _, p := range history {
+			if p.Kind == runtimedrift.KindDrift && p.Component == rep.Component {
+				t.Points = append(t.Points, p)
+			}
+		}
+		if len(t.Points) == 0 {
+			current := driftPoint(rep)
+			current.Time = now
+			t.Points = append(t.Points, current)
+		}
+		trends = append(trends, t)
+	}
+	return trends
+}
+
+// sparkline draws scores in [0, 1] as an inline SVG polyline.
+func sparkline(points []runtimedrift.Point) template.HTML {
+	const w, h = 240.0, 40.0
+	var coords []string
+	for i, p := range points {
+		x := w / 2
+		if len(points) > 1 {
+			x = w * float64(i) / float64(len(points)-1)
+		}
+		y := h - h*math.Max(0, math.Min(1, p.Score))
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return template.HTML(fmt.Sprintf(`<svg class="spark" width="%.0f" height="%.0f" viewBox="-2 -2 %.0f %.0f"><polyline fill="none" stroke="#2563eb" stroke-width="2" points="%s"/></svg>`,
+		w, h, w+4, h+4, strings.Join(coords, " ")))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"sparkline": sparkline,
+	"pct":       func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"sim":       func(f float64) string { return fmt.Sprintf("%.3f", f) },
+	"delta": func(r DriftTestResult) string {
+		if r.Baseline == 0 {
+			return "—"
+		}
+		return fmt.Sprintf("%+.3f", r.Similarity-r.Baseline)
+	},
+	"seconds": func(ms int64) string { return fmt.Sprintf("%.2fs", float64(ms)/1000) },
+	"result": func(rep DriftRunReport, name string) *DriftTestResult {
+		for i := range rep.Results {
+			if rep.Results[i].Name == name {
+				return &rep.Results[i]
+			}
+		}
+		return nil
+	},
+	"lower": strings.ToLower,
+	"score": func(p runtimedrift.Point) float64 { return p.Score },
+	"last":  func(ps []runtimedrift.Point) runtimedrift.Point { return ps[len(ps)-1] },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>CMP test report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2937; }
+h1 { margin-bottom: 0; }
+.meta { color: #6b7280; margin-top: .25rem; }
+table { border-collapse: collapse; margin: 1rem 0 2rem; }
+th, td { border: 1px solid #e5e7eb; padding: .35rem .6rem; text-align: left; font-size: .9rem; }
+th { background: #f9fafb; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.passed { background: #dcfce7; color: #166534; }
+.failed { background: #fee2e2; color: #991b1b; }
+.error { background: #fef3c7; color: #92400e; }
+.none { color: #9ca3af; }
+.bar { background: #e5e7eb; width: 120px; height: .6rem; display: inline-block; vertical-align: middle; }
+.bar span { background: #2563eb; height: 100%; display: block; }
+</style>
+</head>
+<body>
+<h1>CMP test report</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 UTC"}}</p>
+{{- if .Drift}}
+<h2>Drift detection</h2>
+<table>
+<tr><th>Component</th>{{range .Cases}}<th>{{.}}</th>{{end}}<th>Passed</th><th>Duration</th></tr>
+{{- range $rep := .Drift}}
+<tr><th>{{$rep.Component}}</th>
+{{- range $.Cases}}{{with result $rep .}}<td class="{{lower .Status}}" title="{{range .Reasons}}{{.}}&#10;{{end}}">{{.Status}}</td>{{else}}<td class="none">—</td>{{end}}{{end}}
+<td class="num">{{$rep.Passed}}/{{$rep.Total}}</td><td class="num">{{seconds $rep.DurationMs}}</td></tr>
+{{- end}}
+</table>
+<h3>Similarity vs baseline</h3>
+<table>
+<tr><th>Component</th><th>Test</th><th>Similarity</th><th>Baseline</th><th>Delta</th><th>Threshold</th><th>Status</th></tr>
+{{- range $rep := .Drift}}{{range $rep.Results}}
+<tr><td>{{$rep.Component}}</td><td>{{.Name}}</td><td class="num">{{sim .Similarity}}</td><td class="num">{{if .Baseline}}{{sim .Baseline}}{{else}}—{{end}}</td><td class="num">{{delta .}}</td><td class="num">{{sim .Threshold}}</td><td class="{{lower .Status}}">{{.Status}}</td></tr>
+{{- end}}{{end}}
+</table>
+<h3>Score trend</h3>
+<table>
+<tr><th>Component</th><th>Runs</th><th>Pass rate</th><th>Latest</th></tr>
+{{- range .Trends}}
+<tr><td>{{.Component}}</td><td class="num">{{len .Points}}</td><td>{{sparkline .Points}}</td><td class="num">{{pct (score (last .Points))}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Tests}}
+<h2>Go test suites</h2>
+<table>
+<tr><th>Suite</th><th>Status</th><th>Coverage</th><th>Threshold</th><th>Details</th></tr>
+{{- range .Results}}
+<tr><td>{{.Suite}}</td>{{if .Passed}}<td class="passed">PASSED</td>{{else}}<td class="failed">FAILED</td>{{end}}
+<td class="num">{{if .CoveragePct}}<span class="bar"><span style="width: {{printf "%.0f" .CoveragePct}}%"></span></span> {{printf "%.1f%%" .CoveragePct}}{{else}}—{{end}}</td>
+<td class="num">{{if .Threshold}}{{.Threshold}}%{{else}}—{{end}}</td><td>{{.Error}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
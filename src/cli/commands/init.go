@@ -139,7 +139,21 @@ func runInit(cmd *cobra.Command, args []string) error {
 	// Show development flow
 	showDevelopmentFlow(projectName)
 
-	return nil
+	_, err := EmitResult(cmd, SchemaInit, InitResult{
+		Project:          projectName,
+		Path:             projectPath,
+		FrameworkVersion: FrameworkVersion,
+		Files:            changedFiles(nil, snapshotFiles(projectPath)),
+	}, nil)
+	return err
+}
+
+// InitResult is the ctx.init/v1 payload of `ctx init`.
+type InitResult struct {
+	Project          string   `json:"project"`
+	Path             string   `json:"path"`
+	FrameworkVersion string   `json:"framework_version"`
+	Files            []string `json:"files"`
 }
 
 func validateProjectName(name string) error {
@@ -156,10 +170,41 @@ func validateProjectName(name string) error {
 	return nil
 }
 
+// devServerConfig and prodServerConfig are the server sections of the
+// scaffolded environment configs; `ctx upgrade` adds them to older projects.
+const devServerConfig = `server:
+  cors:
+    allowed_origins: []        # e.g. ["http://localhost:3000"]; "*" allows any origin
+    allowed_methods: [GET, POST, PUT, PATCH, DELETE, OPTIONS]
+    # allowed_headers default to Authorization, Content-Type, X-Tenant-ID, X-Request-ID,
+    # traceparent, Idempotency-Key and X-Priority; a list here replaces them
+    allow_credentials: false    # only with listed origins, never with "*"
+    max_age: 600
+  security_headers:
+    hsts_max_age: 31536000     # sent on HTTPS requests only; 0 disables
+    frame_options: DENY
+    referrer_policy: no-referrer
+  max_body_bytes: 1048576
+  request_timeout: 0s          # per-request deadline; 0 disables
+  read_header_timeout: 10s
+  read_timeout: 30s
+  idle_timeout: 120s
+`
+
+const prodServerConfig = `server:
+  cors:
+    allowed_origins: []        # list the browser origins allowed to call the API
+    allow_credentials: false
+  security_headers:
+    hsts_max_age: 31536000
+  max_body_bytes: 1048576
+`
+
 func createConfigFiles(projectPath string, config ProjectConfig) error {
 	// Create context.lock.json
 	lockContent := `{
   "version": "1.0.0",
+  "framework_version": "` + FrameworkVersion + `",
   "project": "` + config.Name + `",
   "created": "` + getCurrentTimestamp() + `",
   "contexts": {},
@@ -221,6 +266,8 @@ logging:
   format: json
   output: stdout
 
+# HTTP Server Configuration
+` + devServerConfig + `
 # Development Features
 features:
   hot_reload: true
@@ -260,7 +307,7 @@ model_cache:
 database:
   provider: postgresql
   host: ${DB_HOST}
-  port: ${DB_PORT}
+  port: ${DB_PORT:-5432}
   name: ${DB_NAME}
   user: ${DB_USER}
   password: ${DB_PASSWORD}
@@ -269,7 +316,7 @@ database:
 # AI Provider Configuration
 providers:
   openai:
-    api_key: ${OPENAI_API_KEY}
+    api_key: ${OPENAI_API_KEY}    # or a secret store reference, e.g. secret://vault/secret/data/app#openai_api_key
     model: gpt-4
     temperature: 0.1
     max_tokens: 1000
@@ -300,6 +347,13 @@ logging:
   output: file
   file_path: ./logs/app.log
 
+# HTTP Server Configuration
+` + prodServerConfig + `
+# Security Configuration (ctx serve --env production refuses to start without auth)
+security:
+  auth_enabled: true
+  prompt_injection: true
+
 # Production Features
 features:
   hot_reload: false
@@ -313,7 +367,8 @@ features:
 
 func createBasicTemplates(projectPath string, config ProjectConfig) error {
 	// Create default context
-	defaultContext := `name: "Default Agent"
+	defaultContext := `schema_version: 2
+name: "Default Agent"
 version: "1.0.0"
 description: "Default agent for ` + config.Name + `"
 
@@ -678,7 +733,7 @@ pytest-asyncio>=0.21.0
 # --- Local-first Provider ---
 # CMP_LOCAL_MODELS=true
 # CMP_OFFLINE_MODE=true
-# CMP_PYTHON_BIN=.venv/bin/python
+# CMP_PYTHON_BIN=.venv/bin/python   # .venv\Scripts\python.exe on Windows
 # CMP_LOCAL_TIMEOUT_SECONDS=600
 # CMP_LOCAL_MODEL_ID=microsoft/Phi-3-mini-4k-instruct
 # CMP_MODEL_CACHE_DIR=./data/models
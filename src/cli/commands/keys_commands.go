@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"github.com/spf13/cobra"
+)
+
+// GetKeysCommand returns the `keys` command for managing API keys stored in
+// data/auth/api_keys.json. A running server picks up changes without a restart.
+func GetKeysCommand() *cobra.Command {
+	keysCmd := &cobra.Command{Use: "keys", Short: "Create, rotate, scope and revoke API keys"}
+	keysCmd.AddCommand(newKeysListCmd(), newKeysCreateCmd(), newKeysUpdateCmd(), newKeysRotateCmd(), newKeysRevokeCmd())
+	return keysCmd
+}
+
+func openKeyManager() (*runtimesecurity.KeyManager, error) {
+	return runtimesecurity.NewKeyManager(mustGetwd(), nil)
+}
+
+func newKeysListCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List managed API keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := openKeyManager()
+			if err != nil {
+				return err
+			}
+			keys := km.List()
+			out := cmd.OutOrStdout()
+			if asJSON {
+				for i := range keys {
+					keys[i].Hash, keys[i].PreviousHash = "", ""
+				}
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(keys)
+			}
+			if len(keys) == 0 {
+				fmt.Fprintln(out, "no managed API keys")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tTENANT\tROLES\tSCOPES\tSTATUS\tCREATED")
+			now := time.Now()
+			for _, k := range keys {
+				status := "active"
+				switch {
+				case k.RevokedAt != nil:
+					status = "revoked"
+				case !k.Active(now):
+					status = "expired"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.KeyID, orDash(k.Name), k.Prefix, orDash(k.TenantID),
+					orDash(strings.Join(k.Roles, ",")), orDash(strings.Join(k.Scopes, ",")), status, k.CreatedAt.Local().Format(time.DateTime))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print keys as JSON")
+	return cmd
+}
+
+// keySpecFlags binds the grant flags shared by create and update.
+type keySpecFlags struct {
+	name    string
+	tenant  string
+	roles   []string
+	scopes  []string
+	expires time.Duration
+}
+
+func (f *keySpecFlags) bind(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.name, "name", "", "Human-readable key name")
+	cmd.Flags().StringVar(&f.tenant, "tenant", "", "Bind the key to a tenant")
+	cmd.Flags().StringSliceVar(&f.roles, "role", nil, "Grant a role (admin, operator, chat, readonly); repeatable")
+	cmd.Flags().StringSliceVar(&f.scopes, "scope", nil, "Grant a scope such as chat:execute; repeatable")
+	cmd.Flags().DurationVar(&f.expires, "expires-in", 0, "Expire the key after this duration (e.g. 2160h)")
+}
+
+func (f *keySpecFlags) expiresAt() *time.Time {
+	if f.expires <= 0 {
+		return nil
+	}
+	t := time.Now().UTC().Add(f.expires)
+	return &t
+}
+
+func newKeysCreateCmd() *cobra.Command {
+	var flags keySpecFlags
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print its secret once",
+		Example: `  ctx keys create --name ci --tenant acme --role chat
+  ctx keys create --name ops --role admin --expires-in 720h`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(flags.roles) == 0 && len(flags.scopes) == 0 {
+				return fmt.Errorf("grant at least one --role or --scope")
+			}
+			km, err := openKeyManager()
+			if err != nil {
+				return err
+			}
+			k, secret, err := km.Create(runtimesecurity.KeySpec{Name: flags.name, TenantID: flags.tenant, Roles: flags.roles, Scopes: flags.scopes, ExpiresAt: flags.expiresAt()})
+			if err != nil {
+				return err
+			}
+			printSecret(cmd.OutOrStdout(), k, secret)
+			return nil
+		},
+	}
+	flags.bind(cmd)
+	return cmd
+}
+
+func newKeysUpdateCmd() *cobra.Command {
+	var flags keySpecFlags
+	cmd := &cobra.Command{
+		Use:   "update <id>",
+		Short: "Change the name, tenant, roles, scopes or expiry of a key",
+		Example: `  ctx keys update key_1a2b3c4d5e6f --role operator
+  ctx keys update key_1a2b3c4d5e6f --tenant beta --scope memory:read`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := openKeyManager()
+			if err != nil {
+				return err
+			}
+			k, err := km.Get(args[0])
+			if err != nil {
+				return err
+			}
+			spec := runtimesecurity.KeySpec{Name: k.Name, TenantID: k.TenantID, Roles: k.Roles, Scopes: k.Scopes, ExpiresAt: k.ExpiresAt}
+			fl := cmd.Flags()
+			if fl.Changed("name") {
+				spec.Name = flags.name
+			}
+			if fl.Changed("tenant") {
+				spec.TenantID = flags.tenant
+			}
+			if fl.Changed("role") {
+				spec.Roles = flags.roles
+			}
+			if fl.Changed("scope") {
+				spec.Scopes = flags.scopes
+			}
+			if fl.Changed("expires-in") {
+				spec.ExpiresAt = flags.expiresAt()
+			}
+			if k, err = km.Update(k.KeyID, spec); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "updated %s: tenant=%s roles=%s scopes=%s\n", k.KeyID, orDash(k.TenantID),
+				orDash(strings.Join(k.Roles, ",")), orDash(strings.Join(k.Scopes, ",")))
+			return nil
+		},
+	}
+	flags.bind(cmd)
+	return cmd
+}
+
+func newKeysRotateCmd() *cobra.Command {
+	var grace time.Duration
+	cmd := &cobra.Command{
+		Use:   "rotate <id>",
+		Short: "Issue a new secret for a key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := openKeyManager()
+			if err != nil {
+				return err
+			}
+			k, secret, err := km.Rotate(args[0], grace)
+			if err != nil {
+				return err
+			}
+			printSecret(cmd.OutOrStdout(), k, secret)
+			if grace > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "The previous secret remains valid until %s.\n", k.PreviousExpiresAt.Local().Format(time.DateTime))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&grace, "grace", 0, "Keep accepting the previous secret for this long")
+	return cmd
+}
+
+func newKeysRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Permanently disable a key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			km, err := openKeyManager()
+			if err != nil {
+				return err
+			}
+			k, err := km.Revoke(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "revoked %s\n", k.KeyID)
+			return nil
+		},
+	}
+}
+
+func printSecret(w io.Writer, k runtimesecurity.APIKey, secret string) {
+	fmt.Fprintf(w, "Key ID: %s\nSecret: %s\n", k.KeyID, secret)
+	fmt.Fprintln(w, "Store the secret now; it is not saved and cannot be shown again.")
+}
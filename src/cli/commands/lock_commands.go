@@ -16,9 +16,22 @@ import (
 )
 
 type LockFile struct {
-	Contexts map[string]string            `json:"contexts"` // name -> sha
-	Prompts  map[string]map[string]string `json:"prompts"`  // component -> relPath -> sha
-	Memory   map[string]string            `json:"memory"`   // component -> sha of content files
+	Version string `json:"version,omitempty"`
+	// FrameworkVersion is the framework release whose layout the project
+	// follows; `ctx upgrade` migrates older projects and bumps it.
+	FrameworkVersion string                       `json:"framework_version,omitempty"`
+	Project          string                       `json:"project,omitempty"`
+	Created          string                       `json:"created,omitempty"`
+	Contexts         map[string]string            `json:"contexts"` // name -> sha
+	Prompts          map[string]map[string]string `json:"prompts"`  // component -> relPath -> sha
+	Memory           map[string]string            `json:"memory"`   // component -> sha of content files
+	Tools            json.RawMessage              `json:"tools,omitempty"`
+}
+
+// LockResult is the ctx.lock/v1 payload of `ctx lock generate`.
+type LockResult struct {
+	Path string   `json:"path"`
+	Lock LockFile `json:"lock"`
 }
 
 func GetLockCommand() *cobra.Command {
@@ -33,95 +46,114 @@ func newLockGenerateCmd() *cobra.Command {
 		Short: "Compute SHAs for contexts, prompts, and memory",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			root, _ := os.Getwd()
-			lock := LockFile{Contexts: map[string]string{}, Prompts: map[string]map[string]string{}, Memory: map[string]string{}}
-
-			// Contexts: directories under contexts/ (skip tenants)
-			ctxSvc := runtimecontext.NewContextService(root)
-			entries, _ := os.ReadDir(filepath.Join(root, "contexts"))
-			for _, e := range entries {
-				if !e.IsDir() {
-					continue
-				}
-				if e.Name() == "tenants" {
-					continue
-				}
-				name := e.Name()
-				ctxModel, err := ctxSvc.ResolveContext("", name)
-				if err != nil {
-					continue
-				}
-				sha, _ := ctxModel.GetSHA()
-				lock.Contexts[name] = sha
-			}
+			lock := ComputeLockFile(root)
 
-			// Prompts: compute file shas per component
-			promptsDir := filepath.Join(root, "prompts")
-			_ = filepath.WalkDir(promptsDir, func(path string, d fs.DirEntry, err error) error {
-				if err != nil {
-					return nil
-				}
-				if d.IsDir() {
-					return nil
-				}
-				rel, _ := filepath.Rel(promptsDir, path)
-				parts := strings.Split(rel, string(filepath.Separator))
-				if len(parts) < 2 {
-					return nil
-				}
-				comp := parts[0]
-				by, err := os.ReadFile(path)
-				if err != nil {
-					return nil
-				}
-				h := sha256.Sum256(by)
-				if _, ok := lock.Prompts[comp]; !ok {
-					lock.Prompts[comp] = map[string]string{}
-				}
-				lock.Prompts[comp][filepath.ToSlash(rel)] = hex.EncodeToString(h[:])
-				return nil
-			})
-
-			// Memory: hash files under memory/<component>/ (non-recursive summary)
-			memDir := filepath.Join(root, "memory")
-			comps, _ := os.ReadDir(memDir)
-			for _, c := range comps {
-				if !c.IsDir() {
-					continue
-				}
-				compDir := filepath.Join(memDir, c.Name())
-				var files []string
-				filepath.WalkDir(compDir, func(path string, d fs.DirEntry, err error) error {
-					if err != nil {
-						return nil
-					}
-					if d.IsDir() {
-						return nil
-					}
-					files = append(files, path)
-					return nil
-				})
-				sort.Strings(files)
-				h := sha256.New()
-				for _, f := range files {
-					by, err := os.ReadFile(f)
-					if err != nil {
-						continue
-					}
-					h.Write([]byte(f))
-					h.Write([]byte{0})
-					h.Write(by)
+			// Keep the project metadata recorded by `ctx init` and `ctx upgrade`
+			out := filepath.Join(root, "context.lock.json")
+			if by, err := os.ReadFile(out); err == nil {
+				var prev LockFile
+				if json.Unmarshal(by, &prev) == nil {
+					lock.Version, lock.FrameworkVersion = prev.Version, prev.FrameworkVersion
+					lock.Project, lock.Created, lock.Tools = prev.Project, prev.Created, prev.Tools
 				}
-				lock.Memory[c.Name()] = hex.EncodeToString(h.Sum(nil))
 			}
-
-			// Write lock file
-			out := filepath.Join(root, "context.lock.json")
 			by, _ := json.MarshalIndent(lock, "", "  ")
 			if err := os.WriteFile(out, by, 0o644); err != nil {
 				return err
 			}
+			if ok, err := EmitResult(cmd, SchemaLock, LockResult{Path: out, Lock: lock}, nil); ok {
+				return err
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "wrote %s\n", out)
 			return nil
 		},
 	}
 }
+
+// ComputeLockFile hashes the project's contexts, prompts and memory as
+// `ctx lock generate` records them.
+func ComputeLockFile(root string) LockFile {
+	lock := LockFile{Contexts: map[string]string{}, Prompts: map[string]map[string]string{}, Memory: map[string]string{}}
+
+	// Contexts: directories under contexts/ (skip tenants)
+	ctxSvc := runtimecontext.NewContextService(root)
+	entries, _ := os.ReadDir(filepath.Join(root, "contexts"))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if e.Name() == "tenants" {
+			continue
+		}
+		name := e.Name()
+		ctxModel, err := ctxSvc.ResolveContext("", name)
+		if err != nil {
+			continue
+		}
+		sha, _ := ctxModel.GetSHA()
+		lock.Contexts[name] = sha
+	}
+
+	// Prompts: compute file shas per component
+	promptsDir := filepath.Join(root, "prompts")
+	_ = filepath.WalkDir(promptsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(promptsDir, path)
+		parts := strings.Split(rel, string(filepath.Separator))
+		if len(parts) < 2 {
+			return nil
+		}
+		comp := parts[0]
+		by, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		h := sha256.Sum256(by)
+		if _, ok := lock.Prompts[comp]; !ok {
+			lock.Prompts[comp] = map[string]string{}
+		}
+		lock.Prompts[comp][filepath.ToSlash(rel)] = hex.EncodeToString(h[:])
+		return nil
+	})
+
+	// Memory: hash files under memory/<component>/ (non-recursive summary)
+	memDir := filepath.Join(root, "memory")
+	comps, _ := os.ReadDir(memDir)
+	for _, c := range comps {
+		if !c.IsDir() {
+			continue
+		}
+		compDir := filepath.Join(memDir, c.Name())
+		var files []string
+		filepath.WalkDir(compDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			files = append(files, path)
+			return nil
+		})
+		sort.Strings(files)
+		h := sha256.New()
+		for _, f := range files {
+			by, err := os.ReadFile(f)
+			if err != nil {
+				continue
+			}
+			// Hash paths relative to the component so checkouts elsewhere match
+			rel, _ := filepath.Rel(compDir, f)
+			h.Write([]byte(filepath.ToSlash(rel)))
+			h.Write([]byte{0})
+			h.Write(by)
+		}
+		lock.Memory[c.Name()] = hex.EncodeToString(h.Sum(nil))
+	}
+	return lock
+}
@@ -3,19 +3,29 @@ package commands
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
 	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
-// GetMemoryCommand returns the `memory` command with subcommands for ingest, seed, search, and optimize.
+// GetMemoryCommand returns the `memory` command with subcommands for ingest, seed, search, optimize,
+// snapshot versioning (versions, tag, rollback), portable export/import, and
+// re-encryption with a new key (rotate-key).
 //
 // Notable DX helpers:
 //   - `ctx memory seed --component <Name>`: bulk-ingests all supported documents under memory/<Name>/documents
@@ -26,6 +36,13 @@ func GetMemoryCommand() *cobra.Command {
 	memCmd.AddCommand(newMemorySeedCmd())
 	memCmd.AddCommand(newMemorySearchCmd())
 	memCmd.AddCommand(newMemoryOptimizeCmd())
+	memCmd.AddCommand(newMemoryGCCmd())
+	memCmd.AddCommand(newMemoryVersionsCmd())
+	memCmd.AddCommand(newMemoryTagCmd())
+	memCmd.AddCommand(newMemoryRollbackCmd())
+	memCmd.AddCommand(newMemoryExportCmd())
+	memCmd.AddCommand(newMemoryImportCmd())
+	memCmd.AddCommand(newMemoryRotateKeyCmd())
 	return memCmd
 }
 
@@ -38,18 +55,39 @@ func newMemoryIngestCmd() *cobra.Command {
 		model        string
 		inputPath    string
 		allDocuments bool
+		concurrency  int
+		batchSize    int
+		quiet        bool
+		format       string
+		contextName  string
 	)
 	cmd := &cobra.Command{
 		Use:   "ingest",
 		Short: "Ingest documents into a memory store",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			// Ctrl-C stops embedding; completed batches are still saved
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if !quiet {
+				ctx = runtimememory.WithEmbedProgress(ctx, newProgressPrinter(cmd.ErrOrStderr(), time.Second))
+			}
 			if component == "" {
 				return fmt.Errorf("--component is required")
 			}
 			if provider == "" {
 				provider = "sqlite"
 			}
+			if format != "" {
+				f, err := runtimememory.ParseFormat(format)
+				if err != nil {
+					return err
+				}
+				format = f
+			}
 
 			logger.LogInfo(ctx, "Starting memory ingestion",
 				zap.String("component", component),
@@ -64,6 +102,22 @@ func newMemoryIngestCmd() *cobra.Command {
 				Settings:       map[string]string{},
 				TenantID:       tenant,
 			}
+			if concurrency > 0 {
+				cfg.Settings["embedding_concurrency"] = strconv.Itoa(concurrency)
+			}
+			if batchSize > 0 {
+				cfg.Settings["embedding_batch_size"] = strconv.Itoa(batchSize)
+			}
+			// A context's retrieval.chunking overrides the component's chunk settings
+			if contextName != "" {
+				c, err := runtimecontext.NewContextService(cfg.RootDir).ResolveContext(tenant, contextName)
+				if err != nil {
+					return fmt.Errorf("load context %s: %w", contextName, err)
+				}
+				if c.Retrieval != nil && c.Retrieval.Chunking != nil {
+					cfg.Retrieval = &runtimememory.RetrievalOptions{ChunkSize: c.Retrieval.Chunking.Size, ChunkOverlap: c.Retrieval.Chunking.Overlap}
+				}
+			}
 			store, err := runtimememory.NewStore(cfg)
 			if err != nil {
 				logger.LogErrorColored(ctx, "Failed to create memory store", err)
@@ -76,44 +130,73 @@ func newMemoryIngestCmd() *cobra.Command {
 				// Read all files under memory/<component>/documents recursively
 				docsDir := runtimememory.DerivePath(cfg.RootDir, component, tenant, "documents")
 				logger.LogInfo(ctx, "Scanning documents directory", zap.String("path", docsDir))
-				err := filepath.Walk(docsDir, func(path string, info os.FileInfo, err error) error {
-					if err != nil {
+				files, err := runtimememory.ScanDocuments(docsDir)
+				if err != nil {
+					logger.LogErrorColored(ctx, "Failed to read documents directory", err)
+					return fmt.Errorf("failed to read documents directory %s: %w", docsDir, err)
+				}
+				paths := make([]string, 0, len(files))
+				for p := range files {
+					paths = append(paths, p)
+				}
+				sources, skipped, err := runtimememory.LoadSourcesWith(docsDir, paths, runtimememory.ReadOptions{
+					Format: format,
+					OCR:    runtimememory.ComponentOCRConfig(cfg.RootDir, component),
+				})
+				if err != nil {
+					logger.LogErrorColored(ctx, "Failed to read documents directory", err)
+					return fmt.Errorf("failed to read documents directory %s: %w", docsDir, err)
+				}
+				for _, p := range skipped {
+					logger.WithContext(ctx).Info("skipping document that cannot be converted (pdftotext or OCR unavailable)", zap.String("file", p))
+				}
+				if len(sources) == 0 {
+					logger.LogInfo(ctx, "No supported documents found ("+strings.Join(runtimememory.DocumentExtensions(), ", ")+")")
+				}
+				// Stores that track sources embed only changed chunks and drop deleted files
+				if ss, ssErr := runtimememory.AsSourceStore(store); ssErr == nil {
+					logger.LogInfo(ctx, "Syncing documents", zap.Int("count", len(sources)))
+					res, err := ss.SyncSources(ctx, sources)
+					out := MemoryIngestResult{Component: component, Tenant: tenant, Provider: provider, Version: res.Version, Documents: len(sources), Sync: &res}
+					if errors.Is(err, context.Canceled) {
+						if ok, _ := EmitResult(cmd, SchemaMemoryIngest, out, err); ok {
+							return err
+						}
+						printIngestResult(cmd.OutOrStdout(), res)
+						fmt.Fprintf(cmd.OutOrStdout(), "interrupted: %d chunks not embedded; run ingest again to resume\n", res.ChunksPending)
 						return err
 					}
-					if info.IsDir() {
-						return nil
+					if err != nil {
+						logger.LogErrorColored(ctx, "Failed to ingest documents", err)
+						return err
 					}
-					low := strings.ToLower(info.Name())
-					if strings.HasSuffix(low, ".txt") || strings.HasSuffix(low, ".md") {
-						b, rErr := os.ReadFile(path)
-						if rErr != nil {
-							return rErr
-						}
-						docs = append(docs, string(b))
-					} else if strings.HasSuffix(low, ".pdf") {
-						if bin, lookErr := exec.LookPath("pdftotext"); lookErr == nil {
-							cmd := exec.Command(bin, "-layout", path, "-")
-							out, cErr := cmd.Output()
-							if cErr == nil {
-								docs = append(docs, string(out))
-							}
-						} else {
-							logger.WithContext(ctx).Info("skipping PDF (pdftotext not found)", zap.String("file", path))
-						}
+					logger.LogSuccess(ctx, "Memory ingestion completed",
+						zap.String("version", res.Version),
+						zap.Int("added", len(res.Added)),
+						zap.Int("updated", len(res.Updated)),
+						zap.Int("removed", len(res.Removed)),
+						zap.Int("unchanged", len(res.Unchanged)),
+						zap.Int("chunks_embedded", res.ChunksEmbedded),
+						zap.Int("chunks_reused", res.ChunksReused),
+						zap.Int("chunks_deleted", res.ChunksDeleted),
+						zap.Int("duplicates_exact", res.DuplicatesExact),
+						zap.Int("duplicates_near", res.DuplicatesNear))
+					if ok, err := EmitResult(cmd, SchemaMemoryIngest, out, nil); ok {
+						return err
 					}
+					printIngestResult(cmd.OutOrStdout(), res)
 					return nil
-				})
-				if err != nil {
-					logger.LogErrorColored(ctx, "Failed to read documents directory", err)
-					return fmt.Errorf("failed to read documents directory %s: %w", docsDir, err)
 				}
-				if len(docs) == 0 {
-					logger.LogInfo(ctx, "No supported documents found (txt, md, pdf)")
+				for _, src := range sources {
+					docs = append(docs, src.Documents()...)
 				}
 			} else {
-				// load documents from file (one per line) or stdin
+				// load documents from a parsed file, or from a file (one per line) or stdin
 				var rErr error
-				docs, rErr = readLines(inputPath)
+				docs, rErr = readInputDocuments(inputPath, runtimememory.ReadOptions{
+					Format: format,
+					OCR:    runtimememory.ComponentOCRConfig(cfg.RootDir, component),
+				})
 				if rErr != nil {
 					logger.LogErrorColored(ctx, "Failed to read documents", rErr)
 					return rErr
@@ -121,7 +204,15 @@ func newMemoryIngestCmd() *cobra.Command {
 			}
 
 			logger.LogInfo(ctx, "Ingesting documents", zap.Int("count", len(docs)))
-			ver, err := store.IngestDocuments(context.Background(), docs)
+			ver, err := store.IngestDocuments(ctx, docs)
+			out := MemoryIngestResult{Component: component, Tenant: tenant, Provider: provider, Version: ver, Documents: len(docs)}
+			if errors.Is(err, context.Canceled) && ver != "" {
+				if ok, _ := EmitResult(cmd, SchemaMemoryIngest, out, err); ok {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "interrupted; completed batches saved as version: %s\n", ver)
+				return err
+			}
 			if err != nil {
 				logger.LogErrorColored(ctx, "Failed to ingest documents", err)
 				return err
@@ -131,6 +222,9 @@ func newMemoryIngestCmd() *cobra.Command {
 				zap.String("version", ver),
 				zap.Int("documents_ingested", len(docs)))
 
+			if ok, err := EmitResult(cmd, SchemaMemoryIngest, out, nil); ok {
+				return err
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "ingested version: %s\n", ver)
 			return nil
 		},
@@ -139,11 +233,38 @@ func newMemoryIngestCmd() *cobra.Command {
 	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
 	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
 	cmd.Flags().StringVar(&model, "model", "bge-small-en", "Embedding model identifier")
-	cmd.Flags().StringVar(&inputPath, "input", "", "Path to file with documents (one per line). If empty, read from stdin")
-	cmd.Flags().BoolVar(&allDocuments, "all", false, "Ingest all documents under memory/<component>/documents (txt, md, pdf)")
+	cmd.Flags().StringVar(&inputPath, "input", "", "Path to file with documents (one per line, or parsed by its html, docx, csv, jsonl, pdf or image extension). If empty, read from stdin")
+	cmd.Flags().BoolVar(&allDocuments, "all", false, "Ingest all documents under memory/<component>/documents (txt, md, pdf, html, docx, csv, jsonl; images with OCR)")
+	cmd.Flags().StringVar(&format, "format", "", "Parse documents as this format instead of by extension (text, pdf, html, docx, csv, jsonl, image)")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0, "Embedding workers (default: embedding_model.concurrency or number of CPUs)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 0, "Chunks per embedding batch, capped at the provider limit (default: embedding_model.batch_size or the limit)")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "Do not print embedding progress")
+	cmd.Flags().StringVar(&contextName, "context", "", "Chunk documents with this context's retrieval.chunking settings")
 	return cmd
 }
 
+// newProgressPrinter returns an embedding progress callback that writes a status
+// line with ETA to w at most once per interval, and always on completion.
+func newProgressPrinter(w io.Writer, interval time.Duration) func(runtimememory.EmbedProgress) {
+	var last time.Time
+	return func(p runtimememory.EmbedProgress) {
+		if p.Done < p.Total && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		const width = 30
+		filled := width * p.Done / p.Total
+		line := fmt.Sprintf("embedding [%s%s] %d/%d chunks (%d%%)",
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), p.Done, p.Total, 100*p.Done/p.Total)
+		if eta := p.ETA(); eta > 0 {
+			line += fmt.Sprintf(" ETA %s", eta.Round(time.Second))
+		} else {
+			line += fmt.Sprintf(" in %s", p.Elapsed.Round(time.Millisecond))
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
 // newMemorySeedCmd returns the `seed` subcommand which bulk-ingests all supported documents for a component.
 // This is the DX-equivalent of Rails' db:seed for memory documents.
 func newMemorySeedCmd() *cobra.Command {
@@ -231,6 +352,14 @@ func newMemorySearchCmd() *cobra.Command {
 			logger.LogSuccess(ctx, "Memory search completed",
 				zap.Int("results_count", len(results)))
 
+			if results == nil {
+				results = []runtimememory.SearchResult{}
+			}
+			if ok, err := EmitResult(cmd, SchemaMemorySearch, MemorySearchResult{
+				Component: component, Tenant: tenant, Provider: provider, Query: query, Results: results,
+			}, nil); ok {
+				return err
+			}
 			for _, r := range results {
 				fmt.Fprintf(cmd.OutOrStdout(), "%.3f\t%s\n", r.Score, strings.ReplaceAll(strings.TrimSpace(r.Content), "\n", " "))
 			}
@@ -255,6 +384,14 @@ func newMemoryOptimizeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "optimize",
 		Short: "Optimize a memory store",
+		Long: `Compact a memory store and build its search index.
+
+For the sqlite provider, duplicate records and stale embeddings are dropped,
+records ingested by older versions get their content hash stored, and with
+search.index: hnsw in memory_config.yaml the HNSW graph is built and saved next
+to the store, so servers load it instead of building it on their first search.
+Run it after switching an existing store to hnsw and after large ingestions.
+Snapshots are kept; see ctx memory gc.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := runtimememory.Config{Provider: provider, RootDir: mustGetwd(), ComponentName: component, TenantID: tenant}
 			store, err := runtimememory.NewStore(cfg)
@@ -262,7 +399,11 @@ func newMemoryOptimizeCmd() *cobra.Command {
 				return err
 			}
 			defer store.Close()
-			return store.Optimize(context.Background(), version)
+			if err := store.Optimize(context.Background(), version); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Optimized %s memory of %s\n", provider, component)
+			return nil
 		},
 	}
 	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite, episodic)")
@@ -272,6 +413,213 @@ func newMemoryOptimizeCmd() *cobra.Command {
 	return cmd
 }
 
+// newMemoryGCCmd returns the `gc` subcommand which compacts a store and drops
+// its old versions.
+func newMemoryGCCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+		keepLast  int
+	)
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Compact a memory store and drop old versions",
+		Long: `Reclaim the space repeated ingestions leave behind.
+
+The store is compacted: for the sqlite provider duplicate records and stale
+embeddings (chunks embedded again under new model settings) are dropped, and
+snapshots older than the --keep-last most recent ones are deleted, except the
+current and tagged ones. Snapshot files missing from the version index and
+temp files left by interrupted writes are removed for every provider.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if component == "" {
+				return fmt.Errorf("--component is required")
+			}
+			cfg := runtimememory.Config{Provider: provider, RootDir: mustGetwd(), ComponentName: component, TenantID: tenant}
+			store, err := runtimememory.NewStore(cfg)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			rep, err := runtimememory.GC(cmd.Context(), store, runtimememory.GCOptions{KeepLast: keepLast})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %d snapshots, %d records and %d files from %s memory of %s, reclaiming %s\n",
+				rep.Snapshots, rep.Records, rep.Files, provider, component, formatBytes(rep.Bytes))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite, episodic)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	cmd.Flags().IntVar(&keepLast, "keep-last", 0, "Number of most recent versions to keep (0 keeps all)")
+	return cmd
+}
+
+// openSnapshotStore opens a component store and returns its snapshot API.
+func openSnapshotStore(provider, component, tenant string) (runtimememory.MemoryStore, runtimememory.SnapshotStore, error) {
+	if component == "" {
+		return nil, nil, fmt.Errorf("--component is required")
+	}
+	cfg := runtimememory.Config{Provider: provider, RootDir: mustGetwd(), ComponentName: component, TenantID: tenant}
+	store, err := runtimememory.NewStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	snaps, err := runtimememory.AsSnapshotStore(store)
+	if err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return store, snaps, nil
+}
+
+// newMemoryVersionsCmd returns the `versions` subcommand which lists memory snapshots.
+func newMemoryVersionsCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+	)
+	cmd := &cobra.Command{
+		Use:   "versions",
+		Short: "List memory versions (snapshots) for a component",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, snaps, err := openSnapshotStore(provider, component, tenant)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			list, err := snaps.ListSnapshots(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if len(list) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "no memory versions recorded")
+				return nil
+			}
+			for _, s := range list {
+				marker := " "
+				if s.Current {
+					marker = "*"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s\t%s\t%d records\t%s\n",
+					marker, s.Version, s.CreatedAt.Format("2006-01-02 15:04:05"), s.Records, strings.Join(s.Tags, ","))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	return cmd
+}
+
+// newMemoryTagCmd returns the `tag` subcommand which names a memory version.
+func newMemoryTagCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+		version   string
+		tag       string
+	)
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Tag a memory version (e.g., release-1.2)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, snaps, err := openSnapshotStore(provider, component, tenant)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			if err := snaps.TagSnapshot(cmd.Context(), version, tag); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "tagged %s as %s\n", version, tag)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	cmd.Flags().StringVar(&version, "version", "", "Memory version (full ID, unique prefix, or existing tag)")
+	cmd.Flags().StringVar(&tag, "tag", "", "Tag name")
+	return cmd
+}
+
+// newMemoryRollbackCmd returns the `rollback` subcommand which atomically restores a memory version.
+func newMemoryRollbackCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+		version   string
+	)
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Roll a component's memory back to a previous version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			store, snaps, err := openSnapshotStore(provider, component, tenant)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			restored, err := snaps.Rollback(ctx, version)
+			if err != nil {
+				logger.LogErrorColored(ctx, "Failed to roll back memory", err)
+				return err
+			}
+			logger.LogSuccess(ctx, "Memory rolled back",
+				zap.String("component", component),
+				zap.String("version", restored))
+			fmt.Fprintf(cmd.OutOrStdout(), "current version: %s\n", restored)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	cmd.Flags().StringVar(&version, "version", "", "Memory version to restore (full ID, unique prefix, or tag)")
+	return cmd
+}
+
+// readInputDocuments reads the documents of --input. A file is parsed when a
+// format is set or its extension names a structured format (pdf, html, docx,
+// csv, jsonl, image); otherwise each line is a document.
+func readInputDocuments(path string, opts runtimememory.ReadOptions) ([]string, error) {
+	if opts.Format == "" && path != "" {
+		if f := runtimememory.DocumentFormat(path); f != runtimememory.FormatText {
+			opts.Format = f
+		}
+	}
+	switch {
+	case opts.Format == "":
+		return readLines(path)
+	case path == "":
+		by, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		src, err := runtimememory.ParseDocument(opts.Format, by)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w; use --input", err)
+		}
+		return src.Documents(), nil
+	}
+	src, ok, err := runtimememory.ReadSource(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %s: pdftotext or OCR (document_processing.ocr, tesseract) unavailable", path)
+	}
+	return src.Documents(), nil
+}
+
 func readLines(path string) ([]string, error) {
 	var f *os.File
 	var err error
@@ -299,3 +647,241 @@ func mustGetwd() string {
 	wd, _ := os.Getwd()
 	return wd
 }
+
+// MemoryIngestResult is the ctx.memory.ingest/v1 payload. Sync holds the
+// per-file summary when the store tracks sources (`--all` on sqlite).
+type MemoryIngestResult struct {
+	Component string                      `json:"component"`
+	Tenant    string                      `json:"tenant,omitempty"`
+	Provider  string                      `json:"provider"`
+	Version   string                      `json:"version"`
+	Documents int                         `json:"documents"`
+	Sync      *runtimememory.IngestResult `json:"sync,omitempty"`
+}
+
+// MemorySearchResult is the ctx.memory.search/v1 payload.
+type MemorySearchResult struct {
+	Component string                       `json:"component"`
+	Tenant    string                       `json:"tenant,omitempty"`
+	Provider  string                       `json:"provider"`
+	Query     string                       `json:"query"`
+	Results   []runtimememory.SearchResult `json:"results"`
+}
+
+// printIngestResult writes the per-file summary of an incremental ingestion.
+func printIngestResult(w io.Writer, res runtimememory.IngestResult) {
+	fmt.Fprintf(w, "added: %d, updated: %d, removed: %d, unchanged: %d\n",
+		len(res.Added), len(res.Updated), len(res.Removed), len(res.Unchanged))
+	fmt.Fprintf(w, "chunks embedded: %d, reused: %d, deleted: %d\n",
+		res.ChunksEmbedded, res.ChunksReused, res.ChunksDeleted)
+	if res.DuplicatesExact+res.DuplicatesNear > 0 {
+		fmt.Fprintf(w, "duplicates: %d exact, %d near (%s)",
+			res.DuplicatesExact, res.DuplicatesNear, res.DedupeAction)
+		if res.ChunksMerged > 0 {
+			fmt.Fprintf(w, ", %d chunks merged", res.ChunksMerged)
+		}
+		fmt.Fprintln(w)
+	}
+	if res.Changed() {
+		fmt.Fprintf(w, "ingested version: %s\n", res.Version)
+	} else {
+		fmt.Fprintf(w, "no changes; current version: %s\n", res.Version)
+	}
+}
+
+// openPortableStore opens a component's memory store for export or import.
+func openPortableStore(provider, component, tenant, model string) (runtimememory.MemoryStore, runtimememory.PortableStore, error) {
+	if component == "" {
+		return nil, nil, fmt.Errorf("--component is required")
+	}
+	cfg := runtimememory.Config{Provider: provider, RootDir: mustGetwd(), ComponentName: component, TenantID: tenant, EmbeddingModel: model}
+	store, err := runtimememory.NewStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	ps, err := runtimememory.AsPortableStore(store)
+	if err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return store, ps, nil
+}
+
+// memoryFileExt returns the default file extension for an export format.
+func memoryFileExt(format string) string {
+	switch format {
+	case runtimememory.FormatLangChain:
+		return ".langchain.jsonl"
+	case runtimememory.FormatLlamaIndex:
+		return ".llamaindex.jsonl"
+	}
+	return ".memsnap"
+}
+
+// newMemoryExportCmd returns the `export` subcommand which writes a portable .memsnap file.
+func newMemoryExportCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+		model     string
+		out       string
+		format    string
+	)
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export a component's memory (chunks, embeddings, model info) to a portable snapshot",
+		Example: `  ctx memory export --component CustomerDocs
+  ctx memory export --component CustomerDocs --format langchain-jsonl
+  ctx memory export --component CustomerDocs --format llamaindex --out nodes.jsonl`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(runtimememory.InteropFormats, format) {
+				return fmt.Errorf("unknown --format %q (want %s)", format, strings.Join(runtimememory.InteropFormats, ", "))
+			}
+			store, ps, err := openPortableStore(provider, component, tenant, model)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			snap, err := ps.Export(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				out = component + memoryFileExt(format)
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			if err := runtimememory.WriteInterop(f, snap, format); err != nil {
+				f.Close()
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "exported %d chunks from %d documents (%s, %d dims) to %s\n",
+				len(snap.Chunks), len(snap.Documents), snap.EmbeddingModel, snap.Dimensions, out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (e.g., CustomerDocs, SupportBot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	cmd.Flags().StringVar(&model, "model", "bge-small-en", "Embedding model identifier")
+	cmd.Flags().StringVar(&out, "out", "", "Output file (default: <component>.memsnap, .langchain.jsonl or .llamaindex.jsonl)")
+	cmd.Flags().StringVar(&format, "format", runtimememory.FormatMemSnap, "Output format: memsnap, langchain-jsonl or llamaindex")
+	return cmd
+}
+
+// newMemoryImportCmd returns the `import` subcommand which loads a .memsnap file into a store.
+func newMemoryImportCmd() *cobra.Command {
+	var (
+		provider  string
+		component string
+		tenant    string
+		model     string
+		in        string
+		format    string
+		reEmbed   bool
+	)
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a portable memory snapshot, replacing the component's memory",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if in == "" && len(args) > 0 {
+				in = args[0]
+			}
+			if in == "" {
+				return fmt.Errorf("--in is required")
+			}
+			f, err := os.Open(in)
+			if err != nil {
+				return err
+			}
+			snap, err := runtimememory.ReadInterop(f, format)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			if component == "" {
+				component = snap.Component
+			}
+			if component == "" {
+				return fmt.Errorf("--component is required: %s does not name one", in)
+			}
+			store, ps, err := openPortableStore(provider, component, tenant, model)
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			ver, err := ps.Import(cmd.Context(), snap, runtimememory.ImportOptions{ReEmbed: reEmbed})
+			if err != nil {
+				if errors.Is(err, runtimememory.ErrIncompatibleSnapshot) {
+					return fmt.Errorf("%w (pass --re-embed to recompute embeddings with the target model)", err)
+				}
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "imported %d chunks into %s as version: %s\n", len(snap.Chunks), component, ver)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "sqlite", "Memory provider (sqlite)")
+	cmd.Flags().StringVar(&component, "component", "", "Component name (default: the component recorded in the snapshot)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant ID")
+	cmd.Flags().StringVar(&model, "model", "bge-small-en", "Embedding model identifier of the target store")
+	cmd.Flags().StringVar(&in, "in", "", "Snapshot file to import (or pass it as an argument)")
+	cmd.Flags().StringVar(&format, "format", runtimememory.FormatMemSnap, "Input format: memsnap, langchain-jsonl or llamaindex")
+	cmd.Flags().BoolVar(&reEmbed, "re-embed", false, "Recompute embeddings when the snapshot's model or dimensions differ")
+	return cmd
+}
+
+// newMemoryRotateKeyCmd returns the `rotate-key` subcommand which re-encrypts
+// memory with the current CMP_EPISODIC_KEY.
+func newMemoryRotateKeyCmd() *cobra.Command {
+	var component string
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Re-encrypt memory at rest with the current CMP_EPISODIC_KEY",
+		Long: `Re-encrypt stored memory with the current key.
+
+Set CMP_EPISODIC_KEY to the new key and CMP_EPISODIC_PREVIOUS_KEYS to the old
+one, run this command, then drop the old key. Records and episodic entries
+that are still plaintext are encrypted too when the component's
+memory_config.yaml sets encryption: true.`,
+		Example: `  CMP_EPISODIC_KEY=$NEW_KEY CMP_EPISODIC_PREVIOUS_KEYS=$OLD_KEY ctx memory rotate-key
+  ctx memory rotate-key --component SupportBot`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := mustGetwd()
+			keys, err := runtimesecurity.LoadKeyRing(cmd.Context())
+			if err != nil {
+				return err
+			}
+			components := []string{component}
+			if component == "" {
+				entries, err := os.ReadDir(filepath.Join(root, "memory"))
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				components = components[:0]
+				for _, e := range entries {
+					if e.IsDir() {
+						components = append(components, e.Name())
+					}
+				}
+			}
+			for _, c := range components {
+				res, err := runtimememory.RotateKeys(root, c, keys)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: re-encrypted %d values in %d files\n", c, res.Values, res.Files)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "current key: %s\n", keys.Current.ID)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&component, "component", "", "Component name (default: all components)")
+	return cmd
+}
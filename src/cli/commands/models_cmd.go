@@ -2,33 +2,189 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
+	runtimemodelcache "github.com/contexis-cmp/contexis/src/runtime/modelcache"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 	"github.com/spf13/cobra"
 )
 
-// GetModelsCommand provides model utilities like warmup for local models
+// GetModelsCommand provides local model management: listing, pulling,
+// removing and verifying models in the model_cache directory, and warmup.
 func GetModelsCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "models",
-		Short: "Model utilities",
+		Short: "Manage local models",
 	}
-	cmd.AddCommand(getWarmupCmd())
+	cmd.AddCommand(getModelsListCmd(), getModelsPullCmd(), getModelsRemoveCmd(), getModelsVerifyCmd(), getWarmupCmd())
 	return cmd
 }
 
+func openModelCache() (*runtimemodelcache.Cache, error) {
+	return runtimemodelcache.Open(mustGetwd())
+}
+
+func getModelsListCmd() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached models and their sizes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openModelCache()
+			if err != nil {
+				return err
+			}
+			entries, err := cache.List()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Fprintf(out, "no models in %s\n", cache.Dir())
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "MODEL\tREVISION\tFILES\tSIZE\tSTATUS\tPULLED")
+			for _, e := range entries {
+				status, pulled, rev := "untracked", "-", e.Revision
+				if e.Managed {
+					status, pulled = "managed", e.PulledAt.Format("2006-01-02 15:04")
+				}
+				if rev == "" {
+					rev = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", e.ID, rev, e.Files, formatBytes(e.Size), status, pulled)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return cmd
+}
+
+func getModelsPullCmd() *cobra.Command {
+	var revision, include string
+	cmd := &cobra.Command{
+		Use:   "pull <model-id>",
+		Short: "Download a model from the Hugging Face Hub with checksum verification",
+		Long: `Download a model snapshot into the model cache. Interrupted downloads resume,
+files already present are skipped, and every file is checked against the Hub's
+checksum unless model_cache.verify_checksums is false.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openModelCache()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			opts := runtimemodelcache.PullOptions{Revision: revision, Progress: func(file string, size int64, skipped bool) {
+				if skipped {
+					fmt.Fprintf(out, "  %s (%s, cached)\n", file, formatBytes(size))
+					return
+				}
+				fmt.Fprintf(out, "  %s (%s)\n", file, formatBytes(size))
+			}}
+			for _, p := range strings.Split(include, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					opts.Include = append(opts.Include, p)
+				}
+			}
+			fmt.Fprintf(out, "Pulling %s into %s\n", args[0], cache.Dir())
+			mod, err := cache.Pull(cmd.Context(), args[0], opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Pulled %s@%s (%d files, %s)\n", mod.ID, mod.Commit[:min(12, len(mod.Commit))], len(mod.Files), formatBytes(mod.Size))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&revision, "revision", "main", "Branch, tag or commit to pull")
+	cmd.Flags().StringVar(&include, "include", "", "Comma-separated glob patterns of files to pull (e.g. \"*.json,*.safetensors\")")
+	return cmd
+}
+
+func getModelsRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <model-id>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a model from the cache",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openModelCache()
+			if err != nil {
+				return err
+			}
+			if err := cache.Remove(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func getModelsVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify [model-id]",
+		Short: "Verify cached model files against the manifest",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := openModelCache()
+			if err != nil {
+				return err
+			}
+			id := ""
+			if len(args) == 1 {
+				id = args[0]
+			}
+			problems, err := cache.Verify(id)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for _, p := range problems {
+				fmt.Fprintf(out, "%s: %s: %s\n", p.Model, p.File, p.Issue)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d file(s) failed verification; re-run ctx models pull to repair", len(problems))
+			}
+			fmt.Fprintln(out, "All cached model files verified")
+			return nil
+		},
+	}
+}
+
+// formatBytes renders a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 func getWarmupCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "warmup",
 		Short: "Pre-download and initialize local models",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			py := os.Getenv("CMP_PYTHON_BIN")
-			if py == "" {
-				py = "python3"
-			}
+			py := pyenv.Bin(os.Getenv("CMP_PROJECT_ROOT"))
 			// Resolve script path similarly to runtime provider
 			candidates := []string{}
 			if override := os.Getenv("CMP_PYTHON_SCRIPT"); override != "" {
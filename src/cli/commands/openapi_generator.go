@@ -0,0 +1,416 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPISpec is the subset of an OpenAPI 3 (or Swagger 2) document used to
+// generate agent tools.
+type OpenAPISpec struct {
+	Title      string
+	BaseURL    string
+	Operations []OpenAPIOperation
+}
+
+// OpenAPIOperation is one API operation exposed to the agent as a tool.
+type OpenAPIOperation struct {
+	ToolName    string
+	OperationID string
+	Method      string
+	Path        string
+	Summary     string
+	Parameters  []corectx.ToolParameter
+}
+
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// LoadOpenAPISpec reads an OpenAPI 3 or Swagger 2 document (YAML or JSON) and
+// lists its operations in path order. Local $ref parameters are resolved.
+func LoadOpenAPISpec(path string) (*OpenAPISpec, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(by, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if doc["openapi"] == nil && doc["swagger"] == nil {
+		return nil, fmt.Errorf("%s is not an OpenAPI document (missing openapi/swagger version)", path)
+	}
+	spec := &OpenAPISpec{BaseURL: openAPIBaseURL(doc)}
+	if info, ok := doc["info"].(map[string]interface{}); ok {
+		spec.Title, _ = info["title"].(string)
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s defines no paths", path)
+	}
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+	used := map[string]int{}
+	for _, p := range keys {
+		item, _ := paths[p].(map[string]interface{})
+		shared := openAPIParams(doc, item["parameters"])
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			o := OpenAPIOperation{Method: strings.ToUpper(method), Path: p}
+			o.OperationID, _ = op["operationId"].(string)
+			o.Summary, _ = op["summary"].(string)
+			if o.Summary == "" {
+				o.Summary, _ = op["description"].(string)
+			}
+			o.Parameters = mergeParams(shared, openAPIParams(doc, op["parameters"]))
+			if body, ok := op["requestBody"].(map[string]interface{}); ok {
+				required, _ := body["required"].(bool)
+				o.Parameters = append(o.Parameters, corectx.ToolParameter{Name: "body", In: "body", Type: "object", Required: required, Description: "Request body"})
+			}
+			name := o.OperationID
+			if name == "" {
+				name = method + "_" + p
+			}
+			o.ToolName = snakeCase(name)
+			if n := used[o.ToolName]; n > 0 {
+				o.ToolName = fmt.Sprintf("%s_%d", o.ToolName, n+1)
+			}
+			used[o.ToolName]++
+			spec.Operations = append(spec.Operations, o)
+		}
+	}
+	if len(spec.Operations) == 0 {
+		return nil, fmt.Errorf("%s defines no operations", path)
+	}
+	return spec, nil
+}
+
+// openAPIBaseURL returns servers[0].url (OpenAPI 3) or scheme://host/basePath
+// (Swagger 2).
+func openAPIBaseURL(doc map[string]interface{}) string {
+	if servers, ok := doc["servers"].([]interface{}); ok && len(servers) > 0 {
+		if s, ok := servers[0].(map[string]interface{}); ok {
+			u, _ := s["url"].(string)
+			return strings.TrimRight(u, "/")
+		}
+	}
+	host, _ := doc["host"].(string)
+	if host == "" {
+		return ""
+	}
+	scheme := "https"
+	if schemes, ok := doc["schemes"].([]interface{}); ok && len(schemes) > 0 {
+		if s, ok := schemes[0].(string); ok {
+			scheme = s
+		}
+	}
+	base, _ := doc["basePath"].(string)
+	return strings.TrimRight(scheme+"://"+host+base, "/")
+}
+
+// openAPIParams converts a parameters list, resolving #/components/parameters
+// and #/parameters references. Swagger 2 body parameters become "body".
+func openAPIParams(doc map[string]interface{}, raw interface{}) []corectx.ToolParameter {
+	list, _ := raw.([]interface{})
+	var out []corectx.ToolParameter
+	for _, entry := range list {
+		p, _ := entry.(map[string]interface{})
+		if ref, ok := p["$ref"].(string); ok {
+			p = resolveLocalRef(doc, ref)
+		}
+		if p == nil {
+			continue
+		}
+		tp := corectx.ToolParameter{}
+		tp.Name, _ = p["name"].(string)
+		tp.In, _ = p["in"].(string)
+		tp.Required, _ = p["required"].(bool)
+		tp.Description, _ = p["description"].(string)
+		tp.Type, _ = p["type"].(string)
+		if schema, ok := p["schema"].(map[string]interface{}); ok && tp.Type == "" {
+			tp.Type, _ = schema["type"].(string)
+		}
+		if tp.In == "body" {
+			tp.Name, tp.Type = "body", "object"
+		}
+		if tp.Name == "" || tp.In == "" {
+			continue
+		}
+		out = append(out, tp)
+	}
+	return out
+}
+
+// mergeParams lets operation parameters override path-level ones by name and location.
+func mergeParams(shared, own []corectx.ToolParameter) []corectx.ToolParameter {
+	out := append([]corectx.ToolParameter(nil), own...)
+	for _, s := range shared {
+		overridden := false
+		for _, o := range own {
+			if o.Name == s.Name && o.In == s.In {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func resolveLocalRef(doc map[string]interface{}, ref string) map[string]interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur interface{} = doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+	}
+	m, _ := cur.(map[string]interface{})
+	return m
+}
+
+// snakeCase turns operation IDs and paths into Python-friendly identifiers,
+// e.g. "listPets" -> "list_pets", "get_/pets/{petId}" -> "get_pets_pet_id".
+func snakeCase(s string) string {
+	var b strings.Builder
+	prevLower := false
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if prevLower {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			prevLower = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			prevLower = true
+		default:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+			prevLower = false
+		}
+	}
+	out := strings.Trim(b.String(), "_")
+	if out == "" || unicode.IsDigit(rune(out[0])) {
+		out = "op_" + out
+	}
+	return out
+}
+
+// pythonKeywords are reserved words that cannot be used as argument names.
+var pythonKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true, "raise": true, "return": true,
+	"try": true, "while": true, "with": true, "yield": true, "None": true, "True": true, "False": true,
+}
+
+// pythonIdent returns a parameter name usable as a Python argument.
+func pythonIdent(name string) string {
+	id := snakeCase(name)
+	if pythonKeywords[id] {
+		id += "_"
+	}
+	return id
+}
+
+// Tools converts the operations into .ctx tool definitions.
+func (s *OpenAPISpec) Tools() []Tool {
+	tools := make([]Tool, 0, len(s.Operations))
+	for _, o := range s.Operations {
+		desc := o.Summary
+		if desc == "" {
+			desc = fmt.Sprintf("%s %s", o.Method, o.Path)
+		}
+		params := make([]corectx.ToolParameter, len(o.Parameters))
+		for i, p := range o.Parameters {
+			p.Description = yamlSafe(p.Description)
+			params[i] = p
+		}
+		tools = append(tools, Tool{
+			Name:        o.ToolName,
+			URI:         s.BaseURL + o.Path,
+			Description: yamlSafe(desc),
+			Method:      o.Method,
+			Parameters:  params,
+		})
+	}
+	return tools
+}
+
+// yamlSafe flattens text for the double-quoted scalars of the .ctx template.
+func yamlSafe(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// GenerateAgentFromOpenAPI generates an agent whose tools are the operations
+// of an OpenAPI spec: each operation is recorded in the .ctx tools section and
+// gets a Python stub in tools/<name>/<name>_api.py that calls it via APITool.
+func GenerateAgentFromOpenAPI(ctx context.Context, name, tools, memory, specPath string) error {
+	spec, err := LoadOpenAPISpec(specPath)
+	if err != nil {
+		logger.LogErrorColored(ctx, "failed to load OpenAPI spec", err, zap.String("spec", specPath))
+		return fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	logger.LogInfo(ctx, "Loaded OpenAPI spec",
+		zap.String("title", spec.Title),
+		zap.String("base_url", spec.BaseURL),
+		zap.Int("operations", len(spec.Operations)))
+
+	// The generated stubs build on the generic API tool
+	toolList := strings.Split(tools, ",")
+	hasAPI := false
+	for _, t := range toolList {
+		if strings.TrimSpace(t) == "api" {
+			hasAPI = true
+		}
+	}
+	if !hasAPI {
+		if strings.TrimSpace(tools) == "" {
+			tools = "api"
+		} else {
+			tools += ",api"
+		}
+	}
+	if err := generateAgent(ctx, name, tools, memory, spec.Tools()); err != nil {
+		return err
+	}
+	if err := generateOpenAPIStubs(ctx, name, specPath, spec); err != nil {
+		logger.LogErrorColored(ctx, "failed to generate API tool stubs", err)
+		return fmt.Errorf("failed to generate API tool stubs: %w", err)
+	}
+	return nil
+}
+
+// generateOpenAPIStubs writes one Python function per operation.
+func generateOpenAPIStubs(ctx context.Context, name, specPath string, spec *OpenAPISpec) error {
+	type stubParam struct {
+		Name, Arg, In string
+		Required      bool
+	}
+	type stubOp struct {
+		OpenAPIOperation
+		Args        []stubParam
+		PathFormat  string
+		Description string
+		Query       string // Python dict literal of the query parameters
+		HasBody     bool
+	}
+	data := struct {
+		Agent, Spec, Title, BaseURL, EnvPrefix string
+		Operations                             []stubOp
+	}{
+		Agent:     name,
+		Spec:      filepath.Base(specPath),
+		Title:     spec.Title,
+		BaseURL:   spec.BaseURL,
+		EnvPrefix: strings.ToUpper(snakeCase(name)),
+	}
+	for _, o := range spec.Operations {
+		desc := strings.ReplaceAll(strings.Join(strings.Fields(o.Summary), " "), "\"\"\"", "'''")
+		so := stubOp{OpenAPIOperation: o, PathFormat: o.Path, Description: desc}
+		var required, optional []stubParam
+		var query []string
+		for _, p := range o.Parameters {
+			if p.In == "header" || p.In == "cookie" {
+				continue
+			}
+			sp := stubParam{Name: p.Name, Arg: pythonIdent(p.Name), In: p.In, Required: p.Required || p.In == "path"}
+			switch p.In {
+			case "path":
+				so.PathFormat = strings.ReplaceAll(so.PathFormat, "{"+p.Name+"}", "{"+sp.Arg+"}")
+			case "query":
+				query = append(query, fmt.Sprintf("%q: %s", p.Name, sp.Arg))
+			case "body":
+				so.HasBody = true
+			}
+			if sp.Required {
+				required = append(required, sp)
+			} else {
+				optional = append(optional, sp)
+			}
+		}
+		so.Args = append(required, optional...)
+		so.Query = "{" + strings.Join(query, ", ") + "}"
+		data.Operations = append(data.Operations, so)
+	}
+	tmpl, err := template.New("openapi_stub").Parse(openAPIStubTemplate)
+	if err != nil {
+		return err
+	}
+	outputPath := filepath.Join("tools", name, snakeCase(name)+"_api.py")
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, data); err != nil {
+		return err
+	}
+	logger.WithContext(ctx).Info("API tool stubs generated", zap.String("path", outputPath), zap.Int("operations", len(data.Operations)))
+	return nil
+}
+
+const openAPIStubTemplate = `#!/usr/bin/env python3
+"""
+{{ if .Title }}{{ .Title }} tools{{ else }}API tools{{ end }} for {{ .Agent }}
+Generated from {{ .Spec }} by ctx generate agent --from-openapi; one function per
+operation, matching the tool definitions in contexts/{{ .Agent }}.
+"""
+
+import os
+from typing import Any, Dict, Optional
+
+from api import APITool, APIResponse
+
+BASE_URL = os.environ.get("{{ .EnvPrefix }}_API_BASE_URL", "{{ .BaseURL }}").rstrip("/")
+
+_client = APITool(api_key=os.environ.get("{{ .EnvPrefix }}_API_KEY"))
+
+
+def _compact(values: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    params = {k: v for k, v in values.items() if v is not None}
+    return params or None
+{{ range .Operations }}
+
+def {{ .ToolName }}({{ range $i, $a := .Args }}{{ if $i }}, {{ end }}{{ $a.Arg }}{{ if not $a.Required }}=None{{ end }}{{ end }}) -> APIResponse:
+    """{{ if .Description }}{{ .Description }}
+
+    {{ end }}{{ .Method }} {{ .Path }}
+    """
+    return _client._make_request(
+        "{{ .Method }}",
+        BASE_URL + f"{{ .PathFormat }}",
+{{- if .HasBody }}
+        data=body,
+{{- end }}
+        params=_compact({{ .Query }}),
+    )
+{{ end }}`
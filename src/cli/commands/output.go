@@ -0,0 +1,189 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the global --output flag.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Schemas of the structured results. Fields are only ever added to a
+// schema; a breaking change gets a new version.
+const (
+	SchemaInit         = "ctx.init/v1"
+	SchemaGenerate     = "ctx.generate/v1"
+	SchemaMemoryIngest = "ctx.memory.ingest/v1"
+	SchemaMemorySearch = "ctx.memory.search/v1"
+	SchemaTest         = "ctx.test/v1"
+	SchemaDrift        = "ctx.drift/v1"
+	SchemaLock         = "ctx.lock/v1"
+	SchemaVersion      = "ctx.version/v1"
+	SchemaBench        = "ctx.bench/v1"
+	SchemaAuditSearch  = "ctx.audit.search/v1"
+	SchemaPromptFuncs  = "ctx.prompt.functions/v1"
+	SchemaSessionShow  = "ctx.sessions.show/v1"
+	SchemaPromptTest   = "ctx.prompt.test/v1"
+	SchemaError        = "ctx.error/v1"
+)
+
+// Result is the document written to stdout with --output json or yaml. OK is
+// false exactly when the command exits non-zero.
+type Result struct {
+	Schema string      `json:"schema"`
+	OK     bool        `json:"ok"`
+	Error  string      `json:"error,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+var (
+	// resultOut is the real stdout once PrepareOutput moved everything else
+	// to stderr; nil in text mode.
+	resultOut     io.Writer
+	resultFormat  string
+	resultWritten bool
+)
+
+// AddOutputFlag registers the global --output flag on the root command.
+func AddOutputFlag(root *cobra.Command) {
+	root.PersistentFlags().StringP("output", "o", OutputText, "Output format: text, json or yaml")
+}
+
+// OutputFormat returns the --output format of cmd, text when the flag is not
+// registered.
+func OutputFormat(cmd *cobra.Command) string {
+	if f := cmd.Flags().Lookup("output"); f != nil {
+		return f.Value.String()
+	}
+	return OutputText
+}
+
+// PrepareOutput validates --output. For json and yaml it keeps stdout for the
+// result and sends progress, logs and prompts to stderr, so callers must set
+// up their loggers afterwards.
+func PrepareOutput(cmd *cobra.Command) error {
+	switch format := OutputFormat(cmd); format {
+	case OutputText:
+		return nil
+	case OutputJSON, OutputYAML:
+		resultOut, resultFormat = os.Stdout, format
+		os.Stdout = os.Stderr
+		return nil
+	default:
+		return fmt.Errorf("invalid --output %q: must be text, json or yaml", format)
+	}
+}
+
+// EmitResult writes the structured result of cmd, with err as its error, and
+// reports whether it did. In text mode it writes nothing and the caller
+// prints its usual output.
+func EmitResult(cmd *cobra.Command, schema string, data interface{}, err error) (bool, error) {
+	format := OutputFormat(cmd)
+	if format != OutputJSON && format != OutputYAML {
+		return false, nil
+	}
+	w := resultOut
+	if w == nil {
+		w = cmd.OutOrStdout()
+	}
+	r := Result{Schema: schema, OK: err == nil, Data: data}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	resultWritten = true
+	return true, WriteResult(w, format, r)
+}
+
+// ReportError writes a ctx.error/v1 result for a failed command in json or
+// yaml mode, unless the command already wrote its own result. It reports
+// whether err was written.
+func ReportError(err error) bool {
+	if resultOut == nil || resultWritten {
+		return resultWritten
+	}
+	resultWritten = true
+	_ = WriteResult(resultOut, resultFormat, Result{Schema: SchemaError, Error: err.Error()})
+	return true
+}
+
+// WriteResult encodes r as json or yaml.
+func WriteResult(w io.Writer, format string, r Result) error {
+	by, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if format == OutputYAML {
+		// Round-trip through JSON so the YAML keys match the JSON field names
+		var v interface{}
+		if err := json.Unmarshal(by, &v); err != nil {
+			return err
+		}
+		if by, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+		_, err = w.Write(by)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", by)
+	return err
+}
+
+// fileStamp identifies a version of a file for snapshotFiles.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotSkipDirs are not walked by snapshotFiles: they hold caches and
+// environments rather than scaffolded files.
+var snapshotSkipDirs = map[string]bool{".git": true, ".venv": true, "data": true, "node_modules": true}
+
+// snapshotFiles records the files under root so changedFiles can list what
+// a generator wrote.
+func snapshotFiles(root string) map[string]fileStamp {
+	out := map[string]fileStamp{}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && snapshotSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		out[filepath.ToSlash(rel)] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return out
+}
+
+// changedFiles lists the files of after that are new or differ from before.
+func changedFiles(before, after map[string]fileStamp) []string {
+	files := []string{}
+	for rel, st := range after {
+		if prev, ok := before[rel]; !ok || prev != st {
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
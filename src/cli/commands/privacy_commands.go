@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	"github.com/spf13/cobra"
+)
+
+// GetPrivacyCommand returns the `privacy` command for data-subject export and erasure.
+func GetPrivacyCommand() *cobra.Command {
+	privacyCmd := &cobra.Command{Use: "privacy", Short: "Export or delete a user's data (GDPR requests)"}
+	privacyCmd.AddCommand(newPrivacyExportCmd(), newPrivacyDeleteCmd())
+	return privacyCmd
+}
+
+// newPrivacyExportCmd returns the `export` subcommand which writes a user's
+// episodic memory, transcripts and audit events as JSON.
+func newPrivacyExportCmd() *cobra.Command {
+	var (
+		tenant string
+		out    string
+	)
+	cmd := &cobra.Command{
+		Use:   "export <user-id>",
+		Short: "Export everything stored about a user as JSON",
+		Example: `  ctx privacy export u-123 --tenant acme
+  ctx privacy export u-123 --tenant acme --out u-123.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			export, err := runtimeprivacy.ExportUser(mustGetwd(), tenant, args[0])
+			if err != nil {
+				return err
+			}
+			by, err := json.MarshalIndent(export, "", "  ")
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				_, err = cmd.OutOrStdout().Write(append(by, '\n'))
+				return err
+			}
+			if err := os.WriteFile(out, append(by, '\n'), 0o600); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "exported %d memory components, %d conversations, %d transcript turns and %d audit events to %s\n",
+				len(export.Episodic), len(export.Conversations), len(export.Transcripts), len(export.Audit), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant of the user")
+	cmd.Flags().StringVar(&out, "out", "", "Write the export to this file instead of stdout")
+	return cmd
+}
+
+// newPrivacyDeleteCmd returns the `delete` subcommand which erases a user's
+// episodic memory and transcripts. Audit events are retained.
+func newPrivacyDeleteCmd() *cobra.Command {
+	var (
+		tenant string
+		yes    bool
+	)
+	cmd := &cobra.Command{
+		Use:     "delete <user-id>",
+		Short:   "Delete a user's memory and transcripts",
+		Example: `  ctx privacy delete u-123 --tenant acme --yes`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			if err := runtimeprivacy.ValidateUserID(args[0]); err != nil {
+				return err
+			}
+			if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("Delete all memory and transcripts of user %s? [y/N]: ", args[0])) {
+				fmt.Fprintln(out, "aborted")
+				return nil
+			}
+			d, err := runtimeprivacy.DeleteUser(mustGetwd(), tenant, args[0])
+			if err != nil {
+				return err
+			}
+			components := "none"
+			if len(d.Components) > 0 {
+				components = strings.Join(d.Components, ", ")
+			}
+			fmt.Fprintf(out, "deleted episodic memory (%s) and %d transcript turns of user %s\n", components, d.Turns, d.UserID)
+			if d.AuditRetained > 0 {
+				fmt.Fprintf(out, "%d audit events were retained\n", d.AuditRetained)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant of the user")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
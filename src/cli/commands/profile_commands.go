@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ProfileTypes are the profiles `ctx profile` can capture.
+var ProfileTypes = []string{"cpu", "heap"}
+
+// GetProfileCommand returns the `profile` command, which captures a pprof
+// profile from the admin listener of a running server.
+func GetProfileCommand() *cobra.Command {
+	var (
+		addr     string
+		kind     string
+		duration time.Duration
+		out      string
+	)
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Capture a CPU or heap profile from a running server",
+		Long: `Capture a pprof profile from the admin listener of a running server and write
+it locally. The server must run with CMP_ADMIN_ADDR and CMP_PPROF_ENABLED=true.
+A CPU profile samples the server for --duration; a heap profile is a snapshot of
+live allocations.
+
+--addr defaults to CMP_ADMIN_ADDR, or http://localhost:9090.`,
+		Example: `  ctx profile --duration 30s --type cpu
+  ctx profile --type heap --addr http://10.0.0.5:9090 --out heap.pprof`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			base := profileBaseURL(addr)
+			if kind == "cpu" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Capturing a %s CPU profile from %s...\n", duration, base)
+			}
+			data, err := CaptureProfile(cmd.Context(), base, kind, duration)
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				out = fmt.Sprintf("%s-%s.pprof", kind, time.Now().Format("20060102-150405"))
+			}
+			if dir := filepath.Dir(out); dir != "." {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return err
+				}
+			}
+			if err := os.WriteFile(out, data, 0o644); err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "Wrote %s (%d bytes)\n\n", out, len(data))
+			fmt.Fprintln(w, "Inspect it with:")
+			fmt.Fprintf(w, "  go tool pprof -top %s\n", out)
+			fmt.Fprintf(w, "  go tool pprof -http=:8081 %s   # flame graph in the browser\n", out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "", "Admin listener of the server (default: CMP_ADMIN_ADDR or http://localhost:9090)")
+	cmd.Flags().StringVar(&kind, "type", "cpu", "Profile type: "+strings.Join(ProfileTypes, "|"))
+	cmd.Flags().DurationVar(&duration, "duration", 30*time.Second, "CPU sampling duration")
+	cmd.Flags().StringVar(&out, "out", "", "Output file (default: <type>-<timestamp>.pprof)")
+	_ = cmd.RegisterFlagCompletionFunc("type", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return ProfileTypes, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// profileBaseURL resolves the admin listener URL from --addr or CMP_ADMIN_ADDR.
+// A bare ":9090" listen address means the local host.
+func profileBaseURL(addr string) string {
+	if addr == "" {
+		addr = os.Getenv("CMP_ADMIN_ADDR")
+	}
+	if addr == "" {
+		return "http://localhost:9090"
+	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimRight(addr, "/")
+}
+
+// CaptureProfile downloads a profile of the given type from the pprof endpoints
+// under base. duration applies to CPU profiles, rounded up to whole seconds.
+func CaptureProfile(ctx context.Context, base, kind string, duration time.Duration) ([]byte, error) {
+	var path string
+	timeout := 30 * time.Second
+	switch kind {
+	case "cpu":
+		secs := int((duration + time.Second - 1) / time.Second)
+		if secs < 1 {
+			return nil, fmt.Errorf("--duration must be at least 1s")
+		}
+		path = fmt.Sprintf("/debug/pprof/profile?seconds=%d", secs)
+		timeout += time.Duration(secs) * time.Second
+	case "heap":
+		path = "/debug/pprof/heap"
+	default:
+		return nil, fmt.Errorf("unknown profile type %q (want %s)", kind, strings.Join(ProfileTypes, "|"))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("capture %s profile: %w", kind, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("capture %s profile: %s has no pprof endpoints; start the server with CMP_ADMIN_ADDR and CMP_PPROF_ENABLED=true", kind, base)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("capture %s profile: %s: %s", kind, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -4,15 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"text/tabwriter"
 
 	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
 	"github.com/spf13/cobra"
 )
 
 func GetPromptCommand() *cobra.Command {
-	pc := &cobra.Command{Use: "prompt", Short: "Prompt operations (render, validate)"}
+	pc := &cobra.Command{Use: "prompt", Short: "Prompt operations (render, validate, test, functions, experiments)"}
 	pc.AddCommand(newPromptRenderCmd())
 	pc.AddCommand(newPromptValidateCmd())
+	pc.AddCommand(newPromptTestCmd())
+	pc.AddCommand(newPromptFunctionsCmd())
+	pc.AddCommand(newPromptExperimentsCmd())
 	return pc
 }
 
@@ -48,6 +52,26 @@ func newPromptRenderCmd() *cobra.Command {
 	return cmd
 }
 
+func newPromptFunctionsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "functions",
+		Short: "List the helper functions available to prompt templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fns := runtimeprompt.Functions()
+			if ok, err := EmitResult(cmd, SchemaPromptFuncs, fns, nil); ok {
+				return err
+			}
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "FUNCTION\tDESCRIPTION\tEXAMPLE")
+			for _, f := range fns {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Signature, f.Description, f.Example)
+			}
+			return tw.Flush()
+		},
+	}
+}
+
 func newPromptValidateCmd() *cobra.Command {
 	var format string
 	var inputPath string
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	runtimeexperiment "github.com/contexis-cmp/contexis/src/runtime/experiment"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+	"github.com/spf13/cobra"
+)
+
+// newPromptExperimentsCmd returns the `experiments` subcommand for prompt A/B
+// experiments defined in config/experiments.yaml.
+func newPromptExperimentsCmd() *cobra.Command {
+	cmd := &cobra.Command{Use: "experiments", Short: "Prompt A/B experiments (list, report)"}
+	cmd.AddCommand(newPromptExperimentsListCmd())
+	cmd.AddCommand(newPromptExperimentsReportCmd())
+	return cmd
+}
+
+func newPromptExperimentsListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List experiments, their variants, weights and prompt versions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := runtimeexperiment.LoadConfig(mustGetwd())
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if len(cfg.Experiments) == 0 {
+				fmt.Fprintf(out, "no experiments defined in %s\n", runtimeexperiment.ConfigFile)
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "EXPERIMENT\tCOMPONENT\tPROMPT\tASSIGN BY\tVARIANT\tWEIGHT\tFILE\tVERSION")
+			for _, e := range cfg.Experiments {
+				name := e.Name
+				if e.Paused {
+					name += " (paused)"
+				}
+				for _, v := range e.Variants {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", name, e.Component, e.Prompt, e.AssignBy, v.Name, v.Weight, v.File, v.Version)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+// newPromptExperimentsReportCmd summarizes the outcome log
+// (data/experiments/outcomes.jsonl) per experiment variant.
+func newPromptExperimentsReportCmd() *cobra.Command {
+	var (
+		experiment string
+		from       string
+		to         string
+		asJSON     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Compare success rate, latency, tokens and citations per variant",
+		Example: `  ctx prompt experiments report
+  ctx prompt experiments report --experiment tone --from 2025-01-01 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end, err := runtimeusage.ParseRange(from, to)
+			if err != nil {
+				return err
+			}
+			outcomes, err := runtimeexperiment.NewLog(mustGetwd()).Query(runtimeexperiment.Filter{From: start, To: end, Experiment: experiment})
+			if err != nil {
+				return err
+			}
+			rows := runtimeexperiment.Summarize(outcomes)
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(rows)
+			}
+			if len(rows) == 0 {
+				fmt.Fprintln(out, "no experiment outcomes recorded for the selected period")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "EXPERIMENT\tVARIANT\tVERSION\tREQUESTS\tSUCCESS\tCITED\tAVG LATENCY\tAVG PROMPT\tAVG COMPLETION")
+			for _, s := range rows {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f%%\t%.1f%%\t%.0fms\t%.1f\t%.1f\n",
+					s.Experiment, s.Variant, orDash(s.Version), s.Requests, s.SuccessRate*100, s.CitationRate*100,
+					s.AvgLatencyMS, s.AvgPromptTokens, s.AvgCompletionTokens)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&experiment, "experiment", "", "Only include this experiment")
+	cmd.Flags().StringVar(&from, "from", "", "Start date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "End date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return cmd
+}
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// PromptFixturesDir holds the prompt snapshot fixtures of a component, under
+// tests/<Component>/. Each <name>.yaml (or .json) fixture names a template
+// under prompts/<Component>/ and the data to render it with; the expected
+// output is committed next to it as <name>.golden.
+const PromptFixturesDir = "prompt_fixtures"
+
+// Snapshot statuses.
+const (
+	SnapshotPassed  = "PASSED"
+	SnapshotFailed  = "FAILED"  // the rendering differs from the golden file
+	SnapshotMissing = "MISSING" // no golden file yet
+	SnapshotUpdated = "UPDATED" // the golden file was written by --update
+	SnapshotError   = "ERROR"   // the fixture could not be read or rendered
+)
+
+// promptFixture is a tests/<Component>/prompt_fixtures/<name>.yaml file.
+type promptFixture struct {
+	// Template is relative to prompts/<Component>/; defaults to <name>.md.
+	Template string                 `yaml:"template"`
+	Data     map[string]interface{} `yaml:"data"`
+}
+
+// PromptSnapshotResult is the outcome of one fixture.
+type PromptSnapshotResult struct {
+	Component string `json:"component"`
+	Fixture   string `json:"fixture"`
+	Template  string `json:"template,omitempty"`
+	Golden    string `json:"golden"`
+	Status    string `json:"status"`
+	// Diff is a unified diff from the golden file to the rendering.
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// PromptSnapshotReport is the ctx.prompt.test/v1 payload.
+type PromptSnapshotReport struct {
+	Passed  bool                   `json:"passed"`
+	Results []PromptSnapshotResult `json:"results"`
+}
+
+// PromptSnapshotOptions selects the fixtures to run.
+type PromptSnapshotOptions struct {
+	// Component limits the run to one component; empty runs them all.
+	Component string
+	// Update rewrites the golden files with the current renderings.
+	Update bool
+}
+
+// errSnapshotsFailed is returned when any fixture did not pass.
+var errSnapshotsFailed = errors.New("prompt snapshots do not match their golden files")
+
+func newPromptTestCmd() *cobra.Command {
+	var opts PromptSnapshotOptions
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Compare rendered prompts with their golden snapshots",
+		Long: `Renders the prompt templates of each component with the fixtures in
+tests/<Component>/prompt_fixtures/ and compares the output with the committed
+<fixture>.golden files. Differences are shown as diffs and fail the command;
+--update rewrites the golden files instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rep, err := RunPromptSnapshots(mustGetwd(), opts)
+			if err == nil && !rep.Passed {
+				err = errSnapshotsFailed
+			}
+			if ok, emitErr := EmitResult(cmd, SchemaPromptTest, rep, err); ok {
+				if emitErr != nil {
+					return emitErr
+				}
+				return err
+			}
+			printPromptSnapshots(cmd.OutOrStdout(), rep)
+			if errors.Is(err, errSnapshotsFailed) {
+				return fmt.Errorf("%w; review the diffs and rerun with --update to accept them", err)
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&opts.Component, "component", "", "Only test this component")
+	cmd.Flags().BoolVar(&opts.Update, "update", false, "Write the current renderings to the golden files")
+	return cmd
+}
+
+// RunPromptSnapshots renders every fixture under tests/*/prompt_fixtures/
+// and compares it with its golden file, or writes the golden file with
+// opts.Update. Fixture problems are reported per result; the error is for
+// projects without fixtures.
+func RunPromptSnapshots(projectRoot string, opts PromptSnapshotOptions) (PromptSnapshotReport, error) {
+	rep := PromptSnapshotReport{Passed: true, Results: []PromptSnapshotResult{}}
+	dirs, err := filepath.Glob(filepath.Join(projectRoot, "tests", "*", PromptFixturesDir))
+	if err != nil {
+		return rep, err
+	}
+	eng := runtimeprompt.NewEngine(projectRoot)
+	for _, dir := range dirs {
+		component := filepath.Base(filepath.Dir(dir))
+		if opts.Component != "" && !strings.EqualFold(opts.Component, component) {
+			continue
+		}
+		fixtures, err := promptFixtureFiles(dir)
+		if err != nil {
+			return rep, err
+		}
+		for _, path := range fixtures {
+			res := runPromptFixture(eng, projectRoot, component, path, opts.Update)
+			if res.Status != SnapshotPassed && res.Status != SnapshotUpdated {
+				rep.Passed = false
+			}
+			rep.Results = append(rep.Results, res)
+		}
+	}
+	if len(rep.Results) == 0 {
+		where := filepath.Join("tests", "<Component>", PromptFixturesDir)
+		if opts.Component != "" {
+			where = filepath.Join("tests", opts.Component, PromptFixturesDir)
+		}
+		return rep, fmt.Errorf("no prompt fixtures found in %s", where)
+	}
+	return rep, nil
+}
+
+// promptFixtureFiles lists the fixtures of a directory in name order.
+func promptFixtureFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				out = append(out, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func runPromptFixture(eng *runtimeprompt.Engine, projectRoot, component, path string, update bool) PromptSnapshotResult {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	golden := strings.TrimSuffix(path, filepath.Ext(path)) + ".golden"
+	res := PromptSnapshotResult{Component: component, Fixture: relPath(projectRoot, path), Golden: relPath(projectRoot, golden)}
+	fail := func(err error) PromptSnapshotResult {
+		res.Status, res.Error = SnapshotError, err.Error()
+		return res
+	}
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return fail(err)
+	}
+	var fx promptFixture
+	if err := yaml.Unmarshal(by, &fx); err != nil {
+		return fail(fmt.Errorf("parse fixture: %w", err))
+	}
+	if fx.Template == "" {
+		fx.Template = name + ".md"
+	}
+	res.Template = fx.Template
+	got, err := eng.RenderFile(component, fx.Template, fx.Data)
+	if err != nil {
+		return fail(err)
+	}
+	if update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			return fail(err)
+		}
+		res.Status = SnapshotUpdated
+		return res
+	}
+	want, err := os.ReadFile(golden)
+	if os.IsNotExist(err) {
+		res.Status = SnapshotMissing
+		return res
+	}
+	if err != nil {
+		return fail(err)
+	}
+	// Goldens checked out with CRLF line endings still match
+	if expected := strings.ReplaceAll(string(want), "\r\n", "\n"); expected != got {
+		res.Status = SnapshotFailed
+		res.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines([]byte(expected)),
+			B:        diffLines([]byte(got)),
+			FromFile: res.Golden,
+			ToFile:   "rendered " + fx.Template,
+			Context:  3,
+		})
+		return res
+	}
+	res.Status = SnapshotPassed
+	return res
+}
+
+// relPath returns path relative to root with forward slashes, or path when it
+// is not under root.
+func relPath(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func printPromptSnapshots(out io.Writer, rep PromptSnapshotReport) {
+	counts := map[string]int{}
+	for _, r := range rep.Results {
+		counts[r.Status]++
+		switch r.Status {
+		case SnapshotPassed:
+			fmt.Fprintf(out, "✅ %s\n", r.Fixture)
+		case SnapshotUpdated:
+			fmt.Fprintf(out, "📝 %s: updated %s\n", r.Fixture, r.Golden)
+		case SnapshotMissing:
+			fmt.Fprintf(out, "❌ %s: no golden file %s\n", r.Fixture, r.Golden)
+		case SnapshotError:
+			fmt.Fprintf(out, "💥 %s: %s\n", r.Fixture, r.Error)
+		default:
+			fmt.Fprintf(out, "❌ %s: rendering differs from %s\n", r.Fixture, r.Golden)
+			for _, line := range diffLines([]byte(r.Diff)) {
+				fmt.Fprintf(out, "   %s", line)
+			}
+		}
+	}
+	fmt.Fprintf(out, "\n%d fixtures: %d passed, %d failed, %d missing, %d errors, %d updated\n", len(rep.Results),
+		counts[SnapshotPassed], counts[SnapshotFailed], counts[SnapshotMissing], counts[SnapshotError], counts[SnapshotUpdated])
+}
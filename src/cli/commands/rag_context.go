@@ -16,7 +16,8 @@ func generateRAGContext(ctx context.Context, config RAGConfig) error {
 
 	contextPath := fmt.Sprintf("contexts/%s/rag_agent.ctx", config.Name)
 
-	contextTemplate := `name: "{{.Name}} RAG Agent"
+	contextTemplate := `schema_version: 2
+name: "{{.Name}} RAG Agent"
 version: "{{.Version}}"
 description: "{{.Description}}"
 
@@ -44,14 +45,19 @@ guardrails:
   format: "markdown"
   max_tokens: 1000
   temperature: 0.1
-  similarity_threshold: 0.7
 
-memory:
-  vector_store: "{{.DBType}}"
-  embedding_model: "{{.Embeddings}}"
-  max_results: 5
-  chunk_size: 700
-  chunk_overlap: 120
+# How memory is searched for answers; overrides memory_config.yaml
+retrieval:
+  top_k: 5
+  hybrid: true
+  chunking:         # used by ctx memory ingest --context
+    size: 700
+    overlap: 120
+  # reranker:
+  #   provider: "cross_encoder"
+  #   model: "cross-encoder/ms-marco-MiniLM-L-6-v2"
+  # filters:
+  #   language: "en"
 
 testing:
   drift_threshold: 0.85
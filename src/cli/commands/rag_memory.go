@@ -26,16 +26,30 @@ embedding_model:
   name: "{{.Embeddings}}"
   dimensions: 384
   max_length: 512
+  # language: en                                        # language of the model above
+  # multilingual: paraphrase-multilingual-MiniLM-L12-v2 # chunks in other languages
 
 document_processing:
   chunk_size: 700
   chunk_overlap: 120
-  supported_formats: ["txt", "md", "pdf", "docx"]
+  supported_formats: ["txt", "md", "pdf", "html", "docx", "csv", "jsonl"]
+  # Recognize scanned PDFs and images (png, jpg, tiff) with tesseract
+  # ocr:
+  #   enabled: true
+  #   languages: [eng]
+  #   min_confidence: 60
   
 indexing:
   batch_size: 100
   parallel_workers: 4
   similarity_metric: "cosine"
+
+# Optional second-stage reranking of search results
+# rerank:
+#   provider: "cross_encoder"   # cross_encoder | cohere | voyage
+#   model: "cross-encoder/ms-marco-MiniLM-L-6-v2"
+#   candidates: 20              # results fetched before reranking
+#   top_n: 5                    # results kept after reranking
 `
 
 	tmpl, err := template.New("memory_config").Parse(memoryConfigTemplate)
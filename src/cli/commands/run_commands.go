@@ -10,11 +10,12 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimedispatch "github.com/contexis-cmp/contexis/src/runtime/dispatch"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -33,16 +34,23 @@ type RunRequest struct {
 // RunResponse represents the response structure for the run command
 type RunResponse struct {
 	Rendered string `json:"rendered"`
+	// Route is set when the server dispatched the query to a component.
+	Route *runtimedispatch.Decision `json:"route,omitempty"`
 }
 
+// defaultServerAddr is where `ctx serve` listens by default; `ctx run` uses
+// a healthy server there before starting its own.
+const defaultServerAddr = "localhost:8000"
+
 // GetRunCommand returns the `run` command.
 //
-// The `run` command executes a one-off query against a context. If a server
-// is not already running at the provided `--addr`, it starts a temporary
-// server in the background using local-first defaults, sends the request,
-// then shuts the server down. It auto-detects `.venv/bin/python`, sets
-// `CMP_LOCAL_MODELS=true`, and exports `CMP_PROJECT_ROOT` to ensure the local
-// provider and templates resolve correctly.
+// The `run` command executes a one-off query against a context. It sends the
+// query to the server given with `--addr`, or to a healthy `ctx serve` on the
+// default address. Only when neither is available does it start a temporary
+// server on a free local port using local-first defaults, then shut it down.
+// It auto-detects the project virtualenv, sets `CMP_LOCAL_MODELS=true`, and
+// exports `CMP_PROJECT_ROOT` to ensure the local provider and templates
+// resolve correctly.
 func GetRunCommand() *cobra.Command {
 	var (
 		addr       string
@@ -58,10 +66,17 @@ func GetRunCommand() *cobra.Command {
 		Use:   "run [context] [query]",
 		Short: "Run a query against a context directly",
 		Long: `Execute a query against a context without manually starting the server.
-This command will temporarily start the server, send the query, and return the response.
+The query goes to the server given with --addr, which must be healthy, or to a
+running 'ctx serve' on localhost:8000. Otherwise a temporary server is started on a
+free port for this query. Set CMP_API_KEY when the server requires authentication.
+
+With the context "auto", the server's dispatcher (config/routes.yaml) picks the
+component for the query.
 
 Examples:
   ctx run SupportBot "What is your return policy?"
+  ctx run SupportBot "What is your return policy?" --addr https://staging.example.com
+  ctx run auto "Where is my order #1234?"
   ctx run CustomerDocs "How do I reset my password?" --component CustomerDocs
   ctx run WorkflowProcessor "Process data" --data '{"action":"process"}'`,
 		Args: cobra.ExactArgs(2),
@@ -83,8 +98,8 @@ Examples:
 				return fmt.Errorf("failed to get project root: %w", err)
 			}
 
-			// Set default component if not provided
-			if component == "" {
+			// Set default component if not provided; auto leaves the choice to the dispatcher
+			if component == "" && contextName != runtimedispatch.AutoContext {
 				component = contextName
 			}
 
@@ -119,12 +134,12 @@ Examples:
 			}
 
 			// Execute the query
-			return executeQuery(cmd.Context(), projectRoot, addr, req, debug, timeout)
+			return executeQuery(cmd.Context(), cmd.OutOrStdout(), projectRoot, addr, req, debug, timeout)
 		},
 	}
 
 	// Add flags
-	cmd.Flags().StringVar(&addr, "addr", ":8000", "Server address to use")
+	cmd.Flags().StringVar(&addr, "addr", "", "Address or URL of a running server (default: "+defaultServerAddr+" if healthy, else a temporary server)")
 	cmd.Flags().StringVar(&tenantID, "tenant", "", "Tenant ID for multi-tenant setups")
 	cmd.Flags().StringVar(&component, "component", "", "Component name (defaults to context name)")
 	cmd.Flags().IntVar(&topK, "top-k", 5, "Number of memory results to retrieve")
@@ -136,9 +151,9 @@ Examples:
 	return cmd
 }
 
-// executeQuery starts a server if necessary, then sends the query and streams the response.
+// executeQuery finds or starts a server, then sends the query and writes the response to out.
 // It ensures local-first env defaults and project-root-aware resolution for contexts and prompts.
-func executeQuery(ctx context.Context, projectRoot, addr string, req RunRequest, debug bool, timeout int) error {
+func executeQuery(ctx context.Context, out io.Writer, projectRoot, addr string, req RunRequest, debug bool, timeout int) error {
 	// Ensure environment defaults for local-first and project root
 	if os.Getenv("CMP_PROJECT_ROOT") == "" {
 		_ = os.Setenv("CMP_PROJECT_ROOT", projectRoot)
@@ -148,21 +163,31 @@ func executeQuery(ctx context.Context, projectRoot, addr string, req RunRequest,
 	}
 	// Auto-detect project virtualenv python if not explicitly set
 	if os.Getenv("CMP_PYTHON_BIN") == "" {
-		cand := filepath.Join(projectRoot, ".venv", "bin", "python")
+		cand := pyenv.VenvPython(projectRoot)
 		if _, err := os.Stat(cand); err == nil {
 			_ = os.Setenv("CMP_PYTHON_BIN", cand)
 		}
 	}
 
-	// Check if server is already running
-	if isServerRunning(addr) {
+	// An explicit address must be served already; never start a server for it
+	if addr != "" {
+		if !isServerRunning(addr) {
+			return fmt.Errorf("no healthy server at %s (start one with `ctx serve`, or omit --addr)", serverURL(addr))
+		}
+		return sendQuery(ctx, out, addr, req, debug, timeout)
+	}
+	if isServerRunning(defaultServerAddr) {
 		if debug {
-			logger.LogInfo(ctx, "Server already running, using existing instance", zap.String("addr", addr))
+			logger.LogInfo(ctx, "Server already running, using existing instance", zap.String("addr", defaultServerAddr))
 		}
-		return sendQuery(ctx, addr, req, debug, timeout)
+		return sendQuery(ctx, out, defaultServerAddr, req, debug, timeout)
 	}
 
-	// Start server in background
+	// Start a temporary server on a free port so it cannot clash with a dev server
+	addr, err := freeLocalAddr()
+	if err != nil {
+		return fmt.Errorf("failed to reserve a port: %w", err)
+	}
 	if debug {
 		logger.LogInfo(context.Background(), "Starting server", zap.String("addr", addr))
 	}
@@ -185,7 +210,8 @@ func executeQuery(ctx context.Context, projectRoot, addr string, req RunRequest,
 	}
 
 	serverCmd := exec.CommandContext(ctx, executable, "serve", "--addr", addr)
-	serverCmd.Stdout = os.Stdout
+	// Server logs go to stderr so stdout carries only the response
+	serverCmd.Stdout = os.Stderr
 	serverCmd.Stderr = os.Stderr
 
 	if err := serverCmd.Start(); err != nil {
@@ -203,7 +229,7 @@ func executeQuery(ctx context.Context, projectRoot, addr string, req RunRequest,
 	}
 
 	// Send query
-	err = sendQuery(ctx, addr, req, debug, timeout)
+	err = sendQuery(ctx, out, addr, req, debug, timeout)
 
 	// Clean up server
 	if debug {
@@ -215,19 +241,36 @@ func executeQuery(ctx context.Context, projectRoot, addr string, req RunRequest,
 	return err
 }
 
-// isServerRunning checks if a server is already running on the given address
-func isServerRunning(addr string) bool {
-	// Normalize address
+// serverURL normalizes a --addr value (":8000", "8000", "host:port" or a
+// URL) to a base URL without trailing slash.
+func serverURL(addr string) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return strings.TrimRight(addr, "/")
+	}
 	if !strings.Contains(addr, ":") {
 		addr = "localhost:" + addr
 	}
 	if strings.HasPrefix(addr, ":") {
 		addr = "localhost" + addr
 	}
+	return "http://" + addr
+}
+
+// freeLocalAddr returns a loopback address with a port nothing listens on.
+func freeLocalAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	return ln.Addr().String(), nil
+}
 
+// isServerRunning checks if a server is already running on the given address
+func isServerRunning(addr string) bool {
 	// Try to connect
 	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get("http://" + addr + "/healthz")
+	resp, err := client.Get(serverURL(addr) + "/healthz")
 	if err != nil {
 		return false
 	}
@@ -237,19 +280,11 @@ func isServerRunning(addr string) bool {
 
 // waitForServer waits for the server to be ready
 func waitForServer(addr string, timeout time.Duration) error {
-	// Normalize address
-	if !strings.Contains(addr, ":") {
-		addr = "localhost:" + addr
-	}
-	if strings.HasPrefix(addr, ":") {
-		addr = "localhost" + addr
-	}
-
 	client := &http.Client{Timeout: 1 * time.Second}
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
-		resp, err := client.Get("http://" + addr + "/healthz")
+		resp, err := client.Get(serverURL(addr) + "/healthz")
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
@@ -263,14 +298,8 @@ func waitForServer(addr string, timeout time.Duration) error {
 }
 
 // sendQuery sends the query to the server
-func sendQuery(ctx context.Context, addr string, req RunRequest, debug bool, timeout int) error {
-	// Normalize address
-	if !strings.Contains(addr, ":") {
-		addr = "localhost:" + addr
-	}
-	if strings.HasPrefix(addr, ":") {
-		addr = "localhost" + addr
-	}
+func sendQuery(ctx context.Context, out io.Writer, addr string, req RunRequest, debug bool, timeout int) error {
+	url := serverURL(addr) + "/api/v1/chat"
 
 	// Prepare request
 	jsonData, err := json.Marshal(req)
@@ -279,12 +308,12 @@ func sendQuery(ctx context.Context, addr string, req RunRequest, debug bool, tim
 	}
 
 	if debug {
-		logger.LogInfo(ctx, "Sending request", zap.String("url", "http://"+addr+"/api/v1/chat"))
+		logger.LogInfo(ctx, "Sending request", zap.String("url", url))
 		logger.LogDebugWithContext(ctx, "Request payload", zap.String("data", string(jsonData)))
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "http://"+addr+"/api/v1/chat", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -294,9 +323,9 @@ func sendQuery(ctx context.Context, addr string, req RunRequest, debug bool, tim
 	if v := os.Getenv("CMP_TENANT_ID"); v != "" {
 		httpReq.Header.Set("X-Tenant-ID", v)
 	}
-	// Add Host if addr is not localhost (avoid some proxies misrouting)
-	if host, port, err := net.SplitHostPort(strings.TrimPrefix(addr, "http://")); err == nil && host != "localhost" && host != "127.0.0.1" {
-		httpReq.Host = fmt.Sprintf("%s:%s", host, port)
+	// Servers with auth enabled need a key; eval and doctor read the same variable
+	if key := os.Getenv("CMP_API_KEY"); key != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
 	}
 
 	// Send request
@@ -313,13 +342,18 @@ func sendQuery(ctx context.Context, addr string, req RunRequest, debug bool, tim
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
+	// The server's correlation ID finds this request in its logs and audit trail
+	requestID := resp.Header.Get("X-Request-ID")
 	if debug {
-		logger.LogInfo(ctx, "Response received", zap.Int("status", resp.StatusCode))
+		logger.LogInfo(ctx, "Response received", zap.Int("status", resp.StatusCode), zap.String("server_request_id", requestID))
 		logger.LogDebugWithContext(ctx, "Response body", zap.String("body", string(body)))
 	}
 
 	// Handle errors
 	if resp.StatusCode != http.StatusOK {
+		if requestID != "" {
+			return fmt.Errorf("server returned error %d (request ID %s): %s", resp.StatusCode, requestID, strings.TrimSpace(string(body)))
+		}
 		return fmt.Errorf("server returned error %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -329,8 +363,12 @@ func sendQuery(ctx context.Context, addr string, req RunRequest, debug bool, tim
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	if debug && runResp.Route != nil {
+		logger.LogInfo(ctx, "Query dispatched", zap.String("route", runResp.Route.Route), zap.String("component", runResp.Route.Component), zap.String("method", runResp.Route.Method))
+	}
+
 	// Output the response
-	fmt.Println(runResp.Rendered)
+	fmt.Fprintln(out, runResp.Rendered)
 
 	return nil
 }
@@ -1,24 +1,43 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 // GetServeCommand returns the `serve` command.
 //
 // The `serve` command runs the Contexis HTTP server with sensible local-first
-// defaults. It auto-detects a project virtualenv (`.venv/bin/python`) to use
-// for the local Python provider, sets `CMP_LOCAL_MODELS=true` when unset, and
-// exports `CMP_PROJECT_ROOT` to the current working directory for resolving
-// contexts, prompts and memory paths. A warning is printed if `contexts/`
-// is not found at the project root.
+// defaults. It auto-detects a project virtualenv (`.venv/bin/python`, or
+// `.venv\Scripts\python.exe` on Windows) to use for the local Python provider,
+// sets `CMP_LOCAL_MODELS=true` when unset, and exports `CMP_PROJECT_ROOT` to
+// the current working directory for resolving contexts, prompts and memory
+// paths. A warning is printed if `contexts/` is not found at the project root.
+// With `--watch`, edited documents under memory/<Component>/documents are
+// re-ingested incrementally while serving.
+//
+// The settings of the environment config, config/environments/<--env or
+// $CMP_ENV>.yaml, are exported before the server starts, and a production
+// server refuses to start without authentication or with a sqlite database.
 func GetServeCommand() *cobra.Command {
-	var addr string
+	var (
+		env           string
+		addr          string
+		watch         bool
+		watchInterval time.Duration
+	)
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Run a simple HTTP server for chat",
@@ -26,30 +45,102 @@ func GetServeCommand() *cobra.Command {
 			// Auto-detect project virtualenv python if not explicitly set
 			if os.Getenv("CMP_PYTHON_BIN") == "" {
 				if wd, err := os.Getwd(); err == nil {
-					cand := filepath.Join(wd, ".venv", "bin", "python")
+					cand := pyenv.VenvPython(wd)
 					if _, err := os.Stat(cand); err == nil {
 						_ = os.Setenv("CMP_PYTHON_BIN", cand)
 					}
 				}
 			}
-			// Default to local-first provider if unset
-			if os.Getenv("CMP_LOCAL_MODELS") == "" {
-				_ = os.Setenv("CMP_LOCAL_MODELS", "true")
-			}
 			// Ensure project root is set for provider/template resolution
 			if os.Getenv("CMP_PROJECT_ROOT") == "" {
 				if wd, err := os.Getwd(); err == nil {
 					_ = os.Setenv("CMP_PROJECT_ROOT", wd)
 				}
 			}
-			// Warn if contexts directory is missing
 			root := os.Getenv("CMP_PROJECT_ROOT")
+			if env != "" {
+				_ = os.Setenv("CMP_ENV", env)
+			}
+			if err := applyEnvironment(root, env != ""); err != nil {
+				return err
+			}
+			// Default to local-first provider if unset
+			if os.Getenv("CMP_LOCAL_MODELS") == "" {
+				_ = os.Setenv("CMP_LOCAL_MODELS", "true")
+			}
+			// Warn if contexts directory is missing
 			if _, err := os.Stat(filepath.Join(root, "contexts")); err != nil {
 				fmt.Fprintln(cmd.OutOrStdout(), "warning: 'contexts/' not found in project root; ensure you are in the project directory or set CMP_PROJECT_ROOT")
 			}
+			if watch {
+				w := &runtimememory.DocumentWatcher{Root: root, Interval: watchInterval, OnEvent: logWatchEvent}
+				go func() { _ = w.Run(cmd.Context()) }()
+				fmt.Fprintf(cmd.OutOrStdout(), "watching memory/*/documents for changes (every %s)\n", watchInterval)
+			}
 			return runtimeserver.Serve(addr)
 		},
 	}
+	cmd.Flags().StringVar(&env, "env", "", "Environment config to load from config/environments (default $CMP_ENV, else development)")
 	cmd.Flags().StringVar(&addr, "addr", ":8000", "Listen address")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Re-ingest changed files under memory/<Component>/documents automatically (dev mode)")
+	cmd.Flags().DurationVar(&watchInterval, "watch-interval", 2*time.Second, "Polling interval for --watch")
 	return cmd
 }
+
+// applyEnvironment exports the settings of the active environment config and
+// re-creates the logger from its logging section. With required the config
+// file must exist. A production environment must pass
+// runtimeconfig.CheckProduction.
+func applyEnvironment(root string, required bool) error {
+	name := runtimeconfig.Env()
+	if path := runtimeconfig.Path(root, name); required && !fileExists(path) {
+		return fmt.Errorf("environment %q: %s not found", name, path)
+	}
+	env, err := runtimeconfig.Load(root)
+	if err != nil {
+		return err
+	}
+	if err := env.Apply(); err != nil {
+		return err
+	}
+	if level, format := os.Getenv("CMP_LOG_LEVEL"), os.Getenv("CMP_LOG_FORMAT"); level != "" || format != "" {
+		if format == "" {
+			format = "json"
+		}
+		if err := logger.InitLogger(level, format); err != nil {
+			return err
+		}
+	}
+	if name == runtimeconfig.Production {
+		return runtimeconfig.CheckProduction()
+	}
+	return nil
+}
+
+// logWatchEvent logs the outcome of an incremental re-ingestion, including the new
+// snapshot version.
+func logWatchEvent(ev runtimememory.WatchEvent) {
+	ctx := context.Background()
+	fields := []zap.Field{
+		zap.String("component", ev.Component),
+		zap.String("tenant", ev.TenantID),
+		zap.String("added", strings.Join(ev.Result.Added, ",")),
+		zap.String("updated", strings.Join(ev.Result.Updated, ",")),
+		zap.String("removed", strings.Join(ev.Result.Removed, ",")),
+	}
+	if ev.Err != nil {
+		logger.LogErrorColored(ctx, "Memory re-ingestion failed", ev.Err, fields...)
+		return
+	}
+	for _, p := range ev.Skipped {
+		logger.WithContext(ctx).Info("skipping document that cannot be converted (pdftotext or OCR unavailable)", zap.String("file", p))
+	}
+	if !ev.Result.Changed() {
+		return
+	}
+	logger.LogSuccess(ctx, "Memory re-ingested", append(fields,
+		zap.String("version", ev.Result.Version),
+		zap.Int("chunks_embedded", ev.Result.ChunksEmbedded),
+		zap.Int("chunks_reused", ev.Result.ChunksReused),
+		zap.Int("chunks_deleted", ev.Result.ChunksDeleted))...)
+}
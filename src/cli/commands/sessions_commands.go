@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	"github.com/spf13/cobra"
+)
+
+// SessionShowResult is the ctx.sessions.show/v1 payload.
+type SessionShowResult struct {
+	SessionID string                `json:"session_id"`
+	TenantID  string                `json:"tenant_id,omitempty"`
+	Turns     []runtimeprivacy.Turn `json:"turns"`
+}
+
+// GetSessionsCommand returns the `sessions` command for reviewing recorded
+// chat sessions.
+func GetSessionsCommand() *cobra.Command {
+	sessionsCmd := &cobra.Command{Use: "sessions", Short: "Review recorded chat sessions"}
+	sessionsCmd.AddCommand(newSessionsShowCmd())
+	return sessionsCmd
+}
+
+// newSessionsShowCmd returns the `show` subcommand which prints the transcript
+// of a session from data/sessions.
+func newSessionsShowCmd() *cobra.Command {
+	var tenant string
+	cmd := &cobra.Command{
+		Use:   "show <session-id>",
+		Short: "Show the transcript of a chat session",
+		Long: `Prints the turns the server recorded for a session (with CMP_TRANSCRIPTS=true):
+query, answer, model, injected sources, latency and the hash of the rendered
+prompt. Requests sent without a session ID are recorded under their request ID.`,
+		Example: `  ctx sessions show s-42 --tenant acme
+  ctx sessions show s-42 --tenant acme -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			turns, found, err := runtimeprivacy.NewTranscripts(mustGetwd()).ReadSession(tenant, args[0])
+			if err == nil && !found {
+				err = fmt.Errorf("no transcript for session %s in %s", args[0], runtimeprivacy.SessionsDir)
+			}
+			res := SessionShowResult{SessionID: args[0], TenantID: tenant, Turns: turns}
+			if ok, emitErr := EmitResult(cmd, SchemaSessionShow, res, err); ok {
+				if emitErr != nil {
+					return emitErr
+				}
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			printSession(cmd.OutOrStdout(), res)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Tenant of the session")
+	return cmd
+}
+
+func printSession(out io.Writer, res SessionShowResult) {
+	fmt.Fprintf(out, "Session %s", res.SessionID)
+	if res.TenantID != "" {
+		fmt.Fprintf(out, " (tenant %s)", res.TenantID)
+	}
+	fmt.Fprintf(out, ": %d turns\n", len(res.Turns))
+	for _, t := range res.Turns {
+		meta := []string{t.Time.Format("2006-01-02 15:04:05Z07:00")}
+		if t.UserID != "" {
+			meta = append(meta, "user "+t.UserID)
+		}
+		if t.Component != "" {
+			meta = append(meta, t.Component)
+		}
+		if t.Provider != "" {
+			meta = append(meta, strings.TrimSuffix(t.Provider+"/"+t.Model, "/"))
+		}
+		meta = append(meta, fmt.Sprintf("%dms", t.LatencyMS))
+		if len(t.PromptHash) >= 12 {
+			meta = append(meta, "prompt "+t.PromptHash[:12])
+		}
+		fmt.Fprintf(out, "\n[%s] %s\n", t.RequestID, strings.Join(meta, " · "))
+		fmt.Fprintf(out, "  Q: %s\n", indentContinuation(t.Query))
+		fmt.Fprintf(out, "  A: %s\n", indentContinuation(t.Response))
+		if len(t.Sources) > 0 {
+			parts := make([]string, 0, len(t.Sources))
+			for _, s := range t.Sources {
+				p := fmt.Sprintf("%s (%.2f", s.ID, s.Score)
+				if s.Cited {
+					p += ", cited"
+				}
+				parts = append(parts, p+")")
+			}
+			fmt.Fprintf(out, "  sources: %s\n", strings.Join(parts, ", "))
+		}
+	}
+}
+
+// indentContinuation indents the lines after the first to line up under a
+// "  Q: " prefix.
+func indentContinuation(s string) string {
+	return strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n     ")
+}
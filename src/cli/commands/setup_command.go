@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -41,11 +42,11 @@ func GetSetupCommand() *cobra.Command {
 				return err
 			}
 
-			venvPython := filepath.Join(wd, ".venv", "bin", "python")
+			venvPython := pyenv.VenvPython(wd)
 			// 1) Create venv if missing
 			if _, err := os.Stat(venvPython); err != nil {
 				logger.LogInfo(ctx, "Creating Python virtual environment", zap.String("path", filepath.Join(wd, ".venv")))
-				c := exec.Command("python3", "-m", "venv", ".venv")
+				c := exec.Command(pyenv.System(), "-m", "venv", ".venv")
 				c.Stdout = os.Stdout
 				c.Stderr = os.Stderr
 				c.Dir = wd
@@ -0,0 +1,649 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"gopkg.in/yaml.v3"
+)
+
+// ToolOptions configure `ctx generate tool`.
+type ToolOptions struct {
+	// Name of the tool, e.g. MyTool; the tool is registered as my_tool.
+	Name string
+	// Component whose context registers the tool; may be empty when the
+	// project has a single component.
+	Component string
+	// Lang is go or python.
+	Lang string
+	// SchemaPath is a JSON Schema file of the tool's arguments; empty uses a
+	// schema with a single string argument.
+	SchemaPath string
+	// Port the tool serves on, registered in its uri; 0 picks DefaultToolPort
+	// or the port after those of the context's other local tools.
+	Port int
+}
+
+// DefaultToolPort is the port of the first generated tool of a context.
+const DefaultToolPort = 8790
+
+var localToolRe = regexp.MustCompile(`^http://(?:127\.0\.0\.1|localhost):(\d+)/`)
+
+var toolNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// defaultToolSchema describes the arguments of a tool generated without --schema.
+var defaultToolSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"input": map[string]interface{}{"type": "string", "description": "Input for the tool"},
+	},
+	"required": []interface{}{"input"},
+}
+
+// GenerateTool scaffolds a custom tool under root: an implementation that
+// serves the tool over HTTP in tools/<Component>/<tool>/, the JSON Schema of
+// its arguments, a unit-test stub, and the tool's entry in the component's
+// context. It returns the files written. Existing files are not overwritten.
+func GenerateTool(root string, opts ToolOptions) ([]string, error) {
+	tool := snakeCase(opts.Name)
+	if !toolNameRe.MatchString(tool) {
+		return nil, fmt.Errorf("invalid tool name %q", opts.Name)
+	}
+	lang := strings.ToLower(opts.Lang)
+	if lang != "go" && lang != "python" {
+		return nil, fmt.Errorf("unsupported --lang %q (go or python)", opts.Lang)
+	}
+	schema := defaultToolSchema
+	if opts.SchemaPath != "" {
+		schema = nil
+		by, err := os.ReadFile(opts.SchemaPath)
+		if err != nil {
+			return nil, fmt.Errorf("read schema: %w", err)
+		}
+		if err := json.Unmarshal(by, &schema); err != nil {
+			return nil, fmt.Errorf("parse schema %s: %w", opts.SchemaPath, err)
+		}
+		if t, _ := schema["type"].(string); t != "object" {
+			return nil, fmt.Errorf("schema %s must describe an object (\"type\": \"object\")", opts.SchemaPath)
+		}
+	}
+	component, ctxPath, err := toolContext(root, opts.Component)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Port == 0 {
+		opts.Port = nextToolPort(filepath.Join(root, ctxPath))
+	}
+	desc, _ := schema["description"].(string)
+	if desc == "" {
+		desc = fmt.Sprintf("Custom %s tool", tool)
+	}
+
+	data := toolTemplateData{
+		Tool:        tool,
+		Component:   component,
+		Description: strings.Join(strings.Fields(desc), " "),
+		EnvPrefix:   strings.ToUpper(tool),
+		ClassName:   goIdent(tool),
+		Port:        opts.Port,
+		Fields:      toolFields(schema),
+	}
+	var required, pyArgs []string
+	for _, f := range data.Fields {
+		if f.Required {
+			required = append(required, strconv.Quote(f.Name))
+			if f.PyExample != "" {
+				pyArgs = append(pyArgs, strconv.Quote(f.Name)+": "+f.PyExample)
+			}
+		}
+	}
+	data.GoRequired = strings.Join(required, ", ")
+	data.PyArgs = "{" + strings.Join(pyArgs, ", ") + "}"
+	dir := filepath.Join("tools", component, tool)
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{filepath.Join(dir, "schema.json"): append(schemaJSON, '\n')}
+	sources := map[string]string{tool + ".go": goToolTemplate, tool + "_test.go": goToolTestTemplate}
+	if lang == "python" {
+		sources = map[string]string{tool + ".py": pythonToolTemplate, "test_" + tool + ".py": pythonToolTestTemplate}
+	}
+	for name, src := range sources {
+		out, err := renderToolTemplate(src, data)
+		if err != nil {
+			return nil, err
+		}
+		if lang == "go" {
+			if out, err = format.Source(out); err != nil {
+				return nil, fmt.Errorf("generated %s does not parse: %w", name, err)
+			}
+		}
+		files[filepath.Join(dir, name)] = out
+	}
+
+	ctxBytes, err := registerTool(filepath.Join(root, ctxPath), corectx.Tool{
+		Name:        tool,
+		URI:         fmt.Sprintf("http://127.0.0.1:%d/", opts.Port),
+		Description: data.Description,
+		Method:      "POST",
+		Schema:      schema,
+	})
+	if err != nil {
+		return nil, err
+	}
+	written := make([]string, 0, len(files)+1)
+	for f := range files {
+		if _, err := os.Stat(filepath.Join(root, f)); err == nil {
+			return nil, fmt.Errorf("%s already exists", f)
+		}
+		written = append(written, f)
+	}
+	sort.Strings(written)
+	if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+		return nil, err
+	}
+	for _, f := range written {
+		if err := os.WriteFile(filepath.Join(root, f), files[f], 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ctxPath), ctxBytes, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", ctxPath, err)
+	}
+	return append(written, ctxPath), nil
+}
+
+// toolContext finds the context file of component, or of the only component
+// when component is empty.
+func toolContext(root, component string) (string, string, error) {
+	if component == "" {
+		entries, _ := os.ReadDir(filepath.Join(root, "contexts"))
+		var names []string
+		for _, e := range entries {
+			if e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+		if len(names) != 1 {
+			return "", "", fmt.Errorf("--component is required (components: %s)", strings.Join(names, ", "))
+		}
+		component = names[0]
+	}
+	dir := filepath.Join("contexts", component)
+	matches, _ := filepath.Glob(filepath.Join(root, dir, "*.ctx"))
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("no context found in %s", dir)
+	case 1:
+		return component, filepath.Join(dir, filepath.Base(matches[0])), nil
+	}
+	for _, m := range matches {
+		if base := filepath.Base(m); base == strings.ToLower(component)+".ctx" || base == snakeCase(component)+".ctx" {
+			return component, filepath.Join(dir, base), nil
+		}
+	}
+	return "", "", fmt.Errorf("%s has several contexts; cannot tell which registers the tool", dir)
+}
+
+// nextToolPort returns a port not used by the local tools of a context.
+func nextToolPort(ctxPath string) int {
+	var c struct {
+		Tools []corectx.Tool `yaml:"tools"`
+	}
+	by, _ := os.ReadFile(ctxPath)
+	_ = yaml.Unmarshal(by, &c)
+	port := DefaultToolPort
+	for _, t := range c.Tools {
+		if m := localToolRe.FindStringSubmatch(t.URI); m != nil {
+			if p, _ := strconv.Atoi(m[1]); p >= port {
+				port = p + 1
+			}
+		}
+	}
+	return port
+}
+
+// registerTool returns the context file at path with t appended to its tools.
+// The entry is spliced into the text after the last tool, so the rest of the
+// file is kept byte for byte; files without a block list of tools are
+// re-encoded from their YAML node tree, which keeps comments and key order.
+func registerTool(path string, t corectx.Tool) ([]byte, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(by, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a context", path)
+	}
+	m := doc.Content[0]
+	var tools *yaml.Node
+	next := 0 // line of the key after tools; 0 when tools is last
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == "tools" {
+			tools = m.Content[i+1]
+			if i+2 < len(m.Content) {
+				next = m.Content[i+2].Line
+			}
+		}
+	}
+	if tools != nil && tools.Kind != yaml.SequenceNode && tools.Tag != "!!null" {
+		return nil, fmt.Errorf("%s: tools is not a list", path)
+	}
+	if tools != nil && tools.Kind == yaml.SequenceNode {
+		var existing []corectx.Tool
+		if err := tools.Decode(&existing); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, e := range existing {
+			if e.Name == t.Name {
+				return nil, fmt.Errorf("%s already has a tool named %s", path, t.Name)
+			}
+		}
+	}
+	entry, err := yamlMarshalIndent(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if tools != nil && tools.Kind == yaml.SequenceNode && tools.Style&yaml.FlowStyle == 0 && len(tools.Content) > 0 {
+		lines := strings.SplitAfter(string(by), "\n")
+		// insert after the last line of the list, before blank lines and the
+		// comments of the next key
+		at := len(lines)
+		if next > 0 {
+			at = next - 1
+		}
+		for at > 0 {
+			if l := strings.TrimSpace(lines[at-1]); l != "" && !strings.HasPrefix(l, "#") {
+				break
+			}
+			at--
+		}
+		indent := strings.Repeat(" ", tools.Content[0].Column-1)
+		var b strings.Builder
+		for i, l := range strings.SplitAfter(strings.TrimSuffix(string(entry), "\n"), "\n") {
+			if i == 0 {
+				b.WriteString(indent[:len(indent)-2] + "- " + l)
+			} else {
+				b.WriteString(indent + l)
+			}
+		}
+		b.WriteString("\n")
+		if at > 0 && !strings.HasSuffix(lines[at-1], "\n") {
+			lines[at-1] += "\n"
+		}
+		out := strings.Join(lines[:at], "") + b.String() + strings.Join(lines[at:], "")
+		return []byte(out), nil
+	}
+
+	var n yaml.Node
+	if err := n.Encode(t); err != nil {
+		return nil, err
+	}
+	if tools == nil {
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "tools"}, &yaml.Node{})
+		tools = m.Content[len(m.Content)-1]
+	}
+	if tools.Kind != yaml.SequenceNode {
+		*tools = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	}
+	tools.Style = 0
+	tools.Content = append(tools.Content, &n)
+	return yamlMarshalIndent(&doc)
+}
+
+// yamlMarshalIndent encodes v as YAML indented by two spaces.
+func yamlMarshalIndent(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toolTemplateData fills the tool templates.
+type toolTemplateData struct {
+	Tool, Component, Description string
+	// EnvPrefix names the address variable; ClassName the Python test case.
+	EnvPrefix, ClassName string
+	// GoRequired lists the required arguments as Go strings; PyArgs is a
+	// Python dict of example values of the required arguments.
+	GoRequired, PyArgs string
+	Port               int
+	Fields             []toolField
+}
+
+// toolField is an argument of the tool.
+type toolField struct {
+	Name, GoName, GoType, Description string
+	Required                          bool
+	// GoExample and PyExample are literals for the test stubs; empty when the
+	// type has no obvious example.
+	GoExample, PyExample string
+}
+
+func toolFields(schema map[string]interface{}) []toolField {
+	props, _ := schema["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	if req, ok := schema["required"].([]interface{}); ok {
+		for _, r := range req {
+			required[fmt.Sprint(r)] = true
+		}
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	fields := make([]toolField, 0, len(names))
+	for _, name := range names {
+		prop, _ := props[name].(map[string]interface{})
+		typ, _ := prop["type"].(string)
+		desc, _ := prop["description"].(string)
+		f := toolField{Name: name, GoName: goIdent(name), Required: required[name], Description: strings.Join(strings.Fields(desc), " ")}
+		switch typ {
+		case "string":
+			f.GoType, f.GoExample, f.PyExample = "string", `"example"`, `"example"`
+		case "integer":
+			f.GoType, f.GoExample, f.PyExample = "int", "1", "1"
+		case "number":
+			f.GoType, f.GoExample, f.PyExample = "float64", "1.5", "1.5"
+		case "boolean":
+			f.GoType, f.GoExample, f.PyExample = "bool", "true", "True"
+		case "array":
+			f.GoType, f.PyExample = "[]interface{}", "[]"
+			if items, _ := prop["items"].(map[string]interface{}); items["type"] == "string" {
+				f.GoType, f.GoExample, f.PyExample = "[]string", `[]string{"example"}`, `["example"]`
+			}
+		case "object":
+			f.GoType, f.PyExample = "map[string]interface{}", "{}"
+		default:
+			f.GoType = "interface{}"
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// goIdent returns an exported Go field name for a JSON property.
+func goIdent(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "Arg" + id
+	}
+	return id
+}
+
+func renderToolTemplate(src string, data toolTemplateData) ([]byte, error) {
+	tmpl, err := template.New("tool").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const goToolTemplate = `// Command {{ .Tool }} is the {{ .Tool }} tool of {{ .Component }}, generated by
+// ctx generate tool. Implement Run; main serves it over HTTP for the runtime,
+// which POSTs the arguments described in schema.json as a JSON object to the
+// uri registered in contexts/{{ .Component }}.
+//
+// Run it with: go run ./tools/{{ .Component }}/{{ .Tool }}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Params are the arguments of the tool, as described in schema.json.
+type Params struct {
+{{- range .Fields }}
+{{- if .Description }}
+	// {{ .Description }}
+{{- end }}
+	{{ .GoName }} {{ .GoType }} ` + "`" + `json:"{{ .Name }}{{ if not .Required }},omitempty{{ end }}"` + "`" + `
+{{- end }}
+}
+
+// required lists the arguments the runtime must send.
+var required = []string{ {{- .GoRequired -}} }
+
+// Run executes the tool. Its result is returned to the model as JSON.
+func Run(ctx context.Context, p Params) (interface{}, error) {
+	// TODO: implement {{ .Tool }}: {{ .Description }}
+	return map[string]interface{}{"params": p}, nil
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	var args map[string]json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&args); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid arguments: " + err.Error()})
+		return
+	}
+	for _, name := range required {
+		if _, ok := args[name]; !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("missing argument %q", name)})
+			return
+		}
+	}
+	var p Params
+	by, _ := json.Marshal(args)
+	if err := json.Unmarshal(by, &p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid arguments: " + err.Error()})
+		return
+	}
+	out, err := Run(r.Context(), p)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func main() {
+	addr := os.Getenv("{{ .EnvPrefix }}_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:{{ .Port }}"
+	}
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(handler), ReadHeaderTimeout: 10 * time.Second}
+	log.Printf("{{ .Tool }} listening on %s", addr)
+	log.Fatal(srv.ListenAndServe())
+}
+`
+
+const goToolTestTemplate = `package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	out, err := Run(context.Background(), Params{
+{{- range .Fields }}{{ if and .Required .GoExample }}
+		{{ .GoName }}: {{ .GoExample }},
+{{- end }}{{ end }}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// TODO: check the result of {{ .Tool }}
+	_ = out
+}
+
+func TestHandlerRejectsMissingArguments(t *testing.T) {
+	if len(required) == 0 {
+		t.Skip("the tool has no required arguments")
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body)
+	}
+}
+`
+
+const pythonToolTemplate = `#!/usr/bin/env python3
+"""
+{{ .Tool }} tool of {{ .Component }}, generated by ctx generate tool.
+
+Implement run(); the server below exposes it over HTTP for the runtime, which
+POSTs the arguments described in schema.json as a JSON object to the uri
+registered in contexts/{{ .Component }}.
+
+Run it with: python3 tools/{{ .Component }}/{{ .Tool }}/{{ .Tool }}.py
+"""
+
+import json
+import os
+from http.server import BaseHTTPRequestHandler, HTTPServer
+from typing import Any, Dict
+
+SCHEMA_PATH = os.path.join(os.path.dirname(os.path.abspath(__file__)), "schema.json")
+
+with open(SCHEMA_PATH, encoding="utf-8") as f:
+    SCHEMA = json.load(f)
+
+
+def validate(args: Dict[str, Any]) -> None:
+    """Checks that the required arguments of schema.json are present."""
+    if not isinstance(args, dict):
+        raise ValueError("arguments must be a JSON object")
+    missing = [name for name in SCHEMA.get("required", []) if name not in args]
+    if missing:
+        raise ValueError("missing argument(s): " + ", ".join(missing))
+
+
+def run(args: Dict[str, Any]) -> Any:
+    """{{ .Description }}
+
+    Arguments:
+{{- range .Fields }}
+        {{ .Name }}{{ if .Required }} (required){{ end }}{{ if .Description }}: {{ .Description }}{{ end }}
+{{- end }}
+    """
+    # TODO: implement {{ .Tool }}
+    return {"params": args}
+
+
+class Handler(BaseHTTPRequestHandler):
+    def do_POST(self) -> None:
+        length = int(self.headers.get("Content-Length") or 0)
+        try:
+            args = json.loads(self.rfile.read(length) or b"{}")
+            validate(args)
+        except ValueError as e:
+            self._reply(400, {"error": str(e)})
+            return
+        try:
+            self._reply(200, run(args))
+        except Exception as e:  # reported to the model as the observation
+            self._reply(500, {"error": str(e)})
+
+    def _reply(self, status: int, body: Any) -> None:
+        data = json.dumps(body).encode("utf-8")
+        self.send_response(status)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+
+def main() -> None:
+    host, _, port = os.environ.get("{{ .EnvPrefix }}_ADDR", "127.0.0.1:{{ .Port }}").rpartition(":")
+    server = HTTPServer((host or "127.0.0.1", int(port)), Handler)
+    print(f"{{ .Tool }} listening on {host}:{port}")
+    server.serve_forever()
+
+
+if __name__ == "__main__":
+    main()
+`
+
+const pythonToolTestTemplate = `import os
+import sys
+import unittest
+
+sys.path.insert(0, os.path.dirname(os.path.abspath(__file__)))
+
+import {{ .Tool }}  # noqa: E402
+
+
+class Test{{ .ClassName }}(unittest.TestCase):
+    def test_run(self):
+        args = {{ .PyArgs }}
+        {{ .Tool }}.validate(args)
+        result = {{ .Tool }}.run(args)
+        # TODO: check the result of {{ .Tool }}
+        self.assertIsNotNone(result)
+
+    def test_validate_rejects_missing_arguments(self):
+        if {{ .Tool }}.SCHEMA.get("required"):
+            with self.assertRaises(ValueError):
+                {{ .Tool }}.validate({})
+
+
+if __name__ == "__main__":
+    unittest.main()
+`
@@ -0,0 +1,458 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
+	runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+	runtimenotifications "github.com/contexis-cmp/contexis/src/runtime/notifications"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// FrameworkVersion is the framework release of this ctx binary. New projects
+// record it in context.lock.json and `ctx upgrade` migrates older ones to it.
+const FrameworkVersion = buildinfo.FrameworkVersion
+
+const lockFileName = "context.lock.json"
+
+// Codemod is one migration of the files scaffolded by older framework
+// releases. Apply stages its edits on the plan and must be idempotent: a
+// project that already has the change is left alone.
+type Codemod struct {
+	ID string
+	// Version is the first release whose layout needs the change; projects
+	// recording this version or later skip it.
+	Version     string
+	Description string
+	Apply       func(p *UpgradePlan) error
+}
+
+// Codemods run in order. Append new migrations at the end.
+var Codemods = []Codemod{
+	{
+		ID:          "server-config",
+		Version:     "0.2.0",
+		Description: "add the server section (CORS, security headers, limits, timeouts) to the environment configs",
+		Apply:       upgradeServerConfig,
+	},
+	{
+		ID:          "schedule-alerts",
+		Version:     "0.2.0",
+		Description: "move drift alert targets from config/schedules.yaml to config/notifications.yaml",
+		Apply:       upgradeScheduleAlerts,
+	},
+	{
+		ID:          "lock-memory-hashes",
+		Version:     "0.2.0",
+		Description: "rehash memory in context.lock.json independently of the checkout path",
+		Apply:       upgradeLockMemoryHashes,
+	},
+}
+
+// FileChange is a staged edit of a project file.
+type FileChange struct {
+	Path    string // project-relative, slash separated
+	Old     []byte
+	New     []byte
+	Created bool
+}
+
+// UpgradePlan is the set of edits that bring a project to FrameworkVersion.
+// Nothing is written until Apply.
+type UpgradePlan struct {
+	Root string
+	// From is the framework version recorded by the project, "" when the
+	// lock file predates version tracking.
+	From string
+	To   string
+	// Applied lists the codemods that changed something.
+	Applied []Codemod
+	// Notes are follow-ups the codemods could not do automatically.
+	Notes []string
+
+	files map[string]*FileChange
+	order []string
+}
+
+// GetUpgradeCommand returns the `upgrade` command.
+func GetUpgradeCommand() *cobra.Command {
+	var (
+		dryRun bool
+		yes    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Migrate the project's scaffolded files to this framework version",
+		Long: `Detect the framework version recorded in context.lock.json and apply the
+codemods that migrate config files and directories scaffolded by older
+releases, then record the new version.
+
+Examples:
+  ctx upgrade --dry-run
+  ctx upgrade --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			plan, err := PlanUpgrade(root)
+			if err != nil {
+				return err
+			}
+			from := plan.From
+			if from == "" {
+				from = "unrecorded"
+			}
+			if len(plan.Changes()) == 0 {
+				fmt.Fprintf(out, "project is up to date (framework %s)\n", plan.To)
+				return nil
+			}
+			fmt.Fprintf(out, "upgrading from %s to %s\n", from, plan.To)
+			for _, c := range plan.Applied {
+				fmt.Fprintf(out, "      apply  %s: %s\n", c.ID, c.Description)
+			}
+			for _, n := range plan.Notes {
+				fmt.Fprintf(out, "       note  %s\n", n)
+			}
+			if dryRun {
+				fmt.Fprint(out, plan.Diff())
+				fmt.Fprintln(out, "dry run: no files were changed")
+				return nil
+			}
+			for _, c := range plan.Changes() {
+				action := "update"
+				if c.Created {
+					action = "create"
+				}
+				fmt.Fprintf(out, "     %s  %s\n", action, c.Path)
+			}
+			if !yes && !confirm(cmd.InOrStdin(), out, fmt.Sprintf("Upgrade project to %s? [y/N]: ", plan.To)) {
+				fmt.Fprintln(out, "aborted")
+				return nil
+			}
+			if err := plan.Apply(); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Project upgraded to %s\n", plan.To)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the diff without changing any file")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	return cmd
+}
+
+// PlanUpgrade stages the codemods the project under root needs and the bump
+// of its recorded framework version.
+func PlanUpgrade(root string) (*UpgradePlan, error) {
+	p := &UpgradePlan{Root: root, To: FrameworkVersion, files: map[string]*FileChange{}}
+	lock, err := p.readLock()
+	if err != nil {
+		return nil, err
+	}
+	p.From = lock.FrameworkVersion
+	if p.From != "" && compareVersions(parseVersion(p.From), parseVersion(p.To)) > 0 {
+		return nil, fmt.Errorf("project uses framework %s, newer than this ctx (%s): upgrade ctx first", p.From, p.To)
+	}
+	for _, c := range Codemods {
+		if p.From != "" && compareVersions(parseVersion(p.From), parseVersion(c.Version)) >= 0 {
+			continue
+		}
+		before := p.changeCount()
+		if err := c.Apply(p); err != nil {
+			return nil, fmt.Errorf("codemod %s: %w", c.ID, err)
+		}
+		if p.changeCount() != before {
+			p.Applied = append(p.Applied, c)
+		}
+	}
+	if p.From != p.To {
+		lock, err := p.readLock()
+		if err != nil {
+			return nil, err
+		}
+		lock.FrameworkVersion = p.To
+		if err := p.writeLock(lock); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Changes returns the staged edits in the order they were made.
+func (p *UpgradePlan) Changes() []FileChange {
+	var out []FileChange
+	for _, path := range p.order {
+		if c := p.files[path]; !bytes.Equal(c.Old, c.New) || c.Created {
+			out = append(out, *c)
+		}
+	}
+	return out
+}
+
+// Diff renders the staged edits as a unified diff.
+func (p *UpgradePlan) Diff() string {
+	var b strings.Builder
+	for _, c := range p.Changes() {
+		from := "a/" + c.Path
+		if c.Created {
+			from = "/dev/null"
+		}
+		text, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(c.Old),
+			B:        diffLines(c.New),
+			FromFile: from,
+			ToFile:   "b/" + c.Path,
+			Context:  3,
+		})
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// diffLines splits content into lines that keep their newline.
+func diffLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Apply writes the staged edits.
+func (p *UpgradePlan) Apply() error {
+	for _, c := range p.Changes() {
+		path := filepath.Join(p.Root, filepath.FromSlash(c.Path))
+		mode := os.FileMode(0o644)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, c.New, mode); err != nil {
+			return fmt.Errorf("write %s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// read returns the staged content of a project file, reporting false when
+// it does not exist.
+func (p *UpgradePlan) read(rel string) ([]byte, bool, error) {
+	if c, ok := p.files[rel]; ok {
+		return c.New, true, nil
+	}
+	by, err := os.ReadFile(filepath.Join(p.Root, filepath.FromSlash(rel)))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return by, true, nil
+}
+
+// write stages new content for a project file.
+func (p *UpgradePlan) write(rel string, data []byte) error {
+	if c, ok := p.files[rel]; ok {
+		c.New = data
+		return nil
+	}
+	old, exists, err := p.read(rel)
+	if err != nil {
+		return err
+	}
+	p.files[rel] = &FileChange{Path: rel, Old: old, New: data, Created: !exists}
+	p.order = append(p.order, rel)
+	return nil
+}
+
+func (p *UpgradePlan) changeCount() int {
+	n := 0
+	for _, c := range p.files {
+		if !bytes.Equal(c.Old, c.New) || c.Created {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *UpgradePlan) readLock() (LockFile, error) {
+	var lock LockFile
+	by, ok, err := p.read(lockFileName)
+	if err != nil {
+		return lock, err
+	}
+	if !ok {
+		return lock, fmt.Errorf("%s not found: run ctx upgrade from the project root", lockFileName)
+	}
+	if err := json.Unmarshal(by, &lock); err != nil {
+		return lock, fmt.Errorf("parse %s: %w", lockFileName, err)
+	}
+	return lock, nil
+}
+
+func (p *UpgradePlan) writeLock(lock LockFile) error {
+	by, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return p.write(lockFileName, append(by, '\n'))
+}
+
+// upgradeServerConfig adds the scaffolded server section to environment
+// configs created before it existed, ahead of their features section.
+func upgradeServerConfig(p *UpgradePlan) error {
+	for _, env := range []struct{ path, section string }{
+		{"config/environments/development.yaml", devServerConfig},
+		{"config/environments/production.yaml", prodServerConfig},
+	} {
+		by, ok, err := p.read(env.path)
+		if err != nil || !ok {
+			return err
+		}
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(by, &doc); err != nil {
+			p.Notes = append(p.Notes, fmt.Sprintf("%s is not valid YAML; add the server section by hand", env.path))
+			continue
+		}
+		if _, ok := doc["server"]; ok {
+			continue
+		}
+		block := "# HTTP Server Configuration\n" + env.section + "\n"
+		if err := p.write(env.path, insertBeforeKey(by, "features", block)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertBeforeKey inserts block ahead of a top-level key and the comments
+// directly above it, or at the end when the key is missing.
+func insertBeforeKey(content []byte, key, block string) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, key+":") {
+			continue
+		}
+		for i > 0 && strings.HasPrefix(lines[i-1], "#") {
+			i--
+		}
+		return []byte(strings.Join(lines[:i], "") + block + strings.Join(lines[i:], ""))
+	}
+	s := string(content)
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return []byte(s + "\n" + block)
+}
+
+// upgradeScheduleAlerts moves the alerts section that config/schedules.yaml
+// had before notifications got their own config; the schedule loader now
+// ignores it.
+func upgradeScheduleAlerts(p *UpgradePlan) error {
+	by, ok, err := p.read(runtimedrift.ScheduleFile)
+	if err != nil || !ok {
+		return err
+	}
+	var legacy struct {
+		Alerts []struct {
+			Type string `yaml:"type"`
+			URL  string `yaml:"url"`
+		} `yaml:"alerts"`
+	}
+	if err := yaml.Unmarshal(by, &legacy); err != nil || len(legacy.Alerts) == 0 {
+		return nil
+	}
+	if _, exists, err := p.read(runtimenotifications.ConfigFile); err != nil || exists {
+		if exists {
+			p.Notes = append(p.Notes, fmt.Sprintf("%s still lists alerts: move them to the channels of %s with events [%s]",
+				runtimedrift.ScheduleFile, runtimenotifications.ConfigFile, runtimenotifications.EventDriftAlert))
+		}
+		return err
+	}
+
+	var cfg runtimenotifications.Config
+	seen := map[string]int{}
+	for _, a := range legacy.Alerts {
+		name := "drift-" + a.Type
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, seen[name])
+		}
+		cfg.Channels = append(cfg.Channels, runtimenotifications.Channel{
+			Name: name, Type: a.Type, URL: a.URL, Events: []string{runtimenotifications.EventDriftAlert},
+		})
+	}
+	var b bytes.Buffer
+	b.WriteString("# Notification channels for drift alerts, budget overruns and failed jobs\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(struct {
+		Channels []runtimenotifications.Channel `yaml:"channels"`
+	}{cfg.Channels}); err != nil {
+		return err
+	}
+	if err := p.write(runtimenotifications.ConfigFile, b.Bytes()); err != nil {
+		return err
+	}
+	return p.write(runtimedrift.ScheduleFile, removeKey(by, "alerts"))
+}
+
+// removeKey drops a top-level key and its nested lines, keeping the blank
+// lines and comments that follow it.
+func removeKey(content []byte, key string) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+":") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return content
+	}
+	end := start + 1
+	for j := start + 1; j < len(lines); j++ {
+		line := lines[j]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "-") {
+			break
+		}
+		end = j + 1
+	}
+	return []byte(strings.Join(lines[:start], "") + strings.Join(lines[end:], ""))
+}
+
+// upgradeLockMemoryHashes rehashes the recorded memory components: older
+// releases hashed absolute file paths, so no other checkout could match.
+func upgradeLockMemoryHashes(p *UpgradePlan) error {
+	lock, err := p.readLock()
+	if err != nil || len(lock.Memory) == 0 {
+		return err
+	}
+	current := ComputeLockFile(p.Root).Memory
+	changed := false
+	for comp, sha := range lock.Memory {
+		if now, ok := current[comp]; ok && now != sha {
+			lock.Memory[comp] = now
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return p.writeLock(lock)
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+	"github.com/spf13/cobra"
+)
+
+// GetUsageCommand returns the `usage` command for token usage and cost reporting.
+func GetUsageCommand() *cobra.Command {
+	usageCmd := &cobra.Command{Use: "usage", Short: "Token usage and cost reporting"}
+	usageCmd.AddCommand(newUsageReportCmd())
+	return usageCmd
+}
+
+// newUsageReportCmd returns the `report` subcommand which aggregates the usage ledger
+// (data/usage/usage.jsonl) per day, tenant and component.
+func newUsageReportCmd() *cobra.Command {
+	var (
+		from      string
+		to        string
+		tenant    string
+		component string
+		asJSON    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize token usage and cost per tenant/component/day",
+		Example: `  ctx usage report --from 2025-01-01 --to 2025-01-31
+  ctx usage report --tenant acme --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, end, err := runtimeusage.ParseRange(from, to)
+			if err != nil {
+				return err
+			}
+			records, err := runtimeusage.NewLedger(mustGetwd()).Query(runtimeusage.Filter{From: start, To: end, TenantID: tenant, Component: component})
+			if err != nil {
+				return err
+			}
+			report := runtimeusage.Aggregate(records)
+			report.From, report.To = from, to
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			if len(report.Rows) == 0 {
+				fmt.Fprintln(out, "no usage recorded for the selected period")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "DAY\tTENANT\tCOMPONENT\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL\tCOST")
+			for _, s := range append(report.Rows, report.Total) {
+				day := s.Day
+				if day == "" {
+					day = "TOTAL"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%.4f\n",
+					day, orDash(s.TenantID), orDash(s.Component), s.Requests, s.PromptTokens, s.CompletionTokens, s.TotalTokens, s.Cost)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Start date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "End date, inclusive (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "Only include this tenant")
+	cmd.Flags().StringVar(&component, "component", "", "Only include this component")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return cmd
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,6 +1,19 @@
 package commands
 
 import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "os/signal"
+    "syscall"
+    "time"
+
+    runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+    runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+    runtimenotifications "github.com/contexis-cmp/contexis/src/runtime/notifications"
     runtimeworker "github.com/contexis-cmp/contexis/src/runtime/worker"
     "github.com/spf13/cobra"
 )
@@ -15,7 +28,204 @@ func GetWorkerCommand() *cobra.Command {
         },
     }
     cmd.Flags().StringVar(&addr, "addr", ":9000", "Listen address for worker HTTP endpoints")
+    cmd.AddCommand(newWorkerStartCmd())
+    return cmd
+}
+
+// newWorkerStartCmd returns the `start` subcommand which consumes the job
+// queue in data/jobs.
+func newWorkerStartCmd() *cobra.Command {
+    var (
+        addr        string
+        concurrency int
+        poll        time.Duration
+        types       []string
+        noSchedule  bool
+    )
+    cmd := &cobra.Command{
+        Use:   "start",
+        Short: "Run queued jobs (memory ingestion and retention, evaluations, drift detection) and schedules",
+        Example: `  ctx worker start
+  ctx worker start --concurrency 4 --type memory.ingest`,
+        RunE: func(cmd *cobra.Command, args []string) error {
+            root := mustGetwd()
+            notifier, err := runtimenotifications.Load(root)
+            if err != nil {
+                return err
+            }
+            handlers := map[string]runtimeworker.Handler{
+                runtimejobs.TypeMemoryIngest: runtimeworker.MemoryIngestHandler(root),
+                runtimejobs.TypeEvalRun:      evalJobHandler(root, notifier),
+                runtimejobs.TypeDriftRun:     driftJobHandler(root, notifier),
+                runtimejobs.TypeMemoryPrune:  runtimeworker.MemoryPruneHandler(root),
+            }
+            if len(types) > 0 {
+                selected := map[string]runtimeworker.Handler{}
+                for _, t := range types {
+                    h, ok := handlers[t]
+                    if !ok {
+                        return fmt.Errorf("unknown job type %q", t)
+                    }
+                    selected[t] = h
+                }
+                handlers = selected
+            }
+            ctx := cmd.Context()
+            if ctx == nil {
+                ctx = context.Background()
+            }
+            // Ctrl-C releases running jobs back to the queue
+            ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+            defer stop()
+            if addr != "" {
+                go func() {
+                    if err := runtimeworker.Serve(addr); err != nil {
+                        fmt.Fprintf(cmd.ErrOrStderr(), "worker health endpoint: %v\n", err)
+                    }
+                }()
+            }
+            queue := runtimejobs.NewQueue(root)
+            if !noSchedule {
+                cfg, err := runtimedrift.LoadConfig(root)
+                if err != nil {
+                    return err
+                }
+                if cfg != nil {
+                    fmt.Fprintf(cmd.OutOrStdout(), "Scheduling %d runs from %s\n", len(cfg.Schedules), runtimedrift.ScheduleFile)
+                    scheduler := &runtimedrift.Scheduler{Queue: queue, Config: cfg}
+                    go func() { _ = scheduler.Run(ctx) }()
+                }
+            }
+            fmt.Fprintf(cmd.OutOrStdout(), "Worker consuming %s with concurrency %d (Ctrl-C to stop)\n", runtimejobs.Dir, concurrency)
+            pool := &runtimeworker.Pool{
+                Queue:        queue,
+                Handlers:     handlers,
+                Concurrency:  concurrency,
+                PollInterval: poll,
+                OnFailed: func(job runtimejobs.Job) {
+                    if err := notifier.Notify(ctx, jobFailedEvent(job)); err != nil {
+                        fmt.Fprintf(cmd.ErrOrStderr(), "job failure notification: %v\n", err)
+                    }
+                },
+            }
+            return pool.Run(ctx)
+        },
+    }
+    cmd.Flags().StringVar(&addr, "addr", ":9000", "Listen address for health and metrics (empty to disable)")
+    cmd.Flags().IntVar(&concurrency, "concurrency", 1, "Jobs run in parallel")
+    cmd.Flags().DurationVar(&poll, "poll-interval", time.Second, "How often idle workers check for new jobs")
+    cmd.Flags().StringSliceVar(&types, "type", nil, "Only run jobs of these types (default: all)")
+    cmd.Flags().BoolVar(&noSchedule, "no-schedule", false, "Do not queue the scheduled runs in "+runtimedrift.ScheduleFile)
     return cmd
 }
 
+// ScoreJobResult is the result of eval.run and drift.run jobs: one score per
+// suite or component, recorded in the drift history. Reports are written as
+// by `ctx eval run` and `ctx test --drift-detection`.
+type ScoreJobResult struct {
+    Scores []runtimedrift.Point `json:"scores"`
+    Alerts []runtimedrift.Alert `json:"alerts,omitempty"`
+    // AlertError reports alerts that could not be delivered; the run itself succeeded.
+    AlertError string `json:"alert_error,omitempty"`
+}
+
+// evalJobHandler runs eval.run jobs. Failing suites are a result, not a job failure.
+func evalJobHandler(root string, notifier *runtimenotifications.Notifier) runtimeworker.Handler {
+    return func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+        var p runtimejobs.EvalRunPayload
+        if err := decodeJobPayload(job, &p); err != nil {
+            return nil, err
+        }
+        reports, err := RunEvals(ctx, root, EvalOptions{ComponentFilter: p.Component, SpecFilter: p.Spec}, io.Discard)
+        if err != nil {
+            return nil, err
+        }
+        points := make([]runtimedrift.Point, 0, len(reports))
+        for _, r := range reports {
+            points = append(points, runtimedrift.Point{
+                Kind: runtimedrift.KindEval, Schedule: p.Schedule, Component: r.Component, Spec: r.Spec,
+                Score: r.Score, Passed: r.Passed, Failed: r.Failed, Total: r.Total, Threshold: p.Threshold,
+            })
+        }
+        return recordScores(ctx, root, notifier, points)
+    }
+}
+
+// driftJobHandler runs drift.run jobs and records their scores (see driftPoint).
+func driftJobHandler(root string, notifier *runtimenotifications.Notifier) runtimeworker.Handler {
+    return func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+        var p runtimejobs.DriftRunPayload
+        if err := decodeJobPayload(job, &p); err != nil {
+            return nil, err
+        }
+        specs, err := findDriftSpecs(root)
+        if err != nil {
+            return nil, err
+        }
+        if len(specs) == 0 {
+            return nil, runtimeworker.Permanent(fmt.Errorf("no drift specs found (looking for tests/**/rag_drift_test.yaml)"))
+        }
+        reports, err := runDriftSpecs(ctx, root, specs, DriftOptions{ComponentFilter: p.Component})
+        if err != nil {
+            return nil, err
+        }
+        points := make([]runtimedrift.Point, 0, len(reports))
+        for _, r := range reports {
+            point := driftPoint(r)
+            point.Schedule, point.Threshold = p.Schedule, p.Threshold
+            points = append(points, point)
+        }
+        return recordScores(ctx, root, notifier, points)
+    }
+}
+
+func decodeJobPayload(job runtimejobs.Job, v interface{}) error {
+    if len(job.Payload) == 0 {
+        return nil
+    }
+    if err := json.Unmarshal(job.Payload, v); err != nil {
+        return runtimeworker.Permanent(fmt.Errorf("invalid payload: %w", err))
+    }
+    return nil
+}
+
+// recordScores appends scores to the drift history and sends drift.alert
+// notifications for scores that dropped below their threshold.
+func recordScores(ctx context.Context, root string, notifier *runtimenotifications.Notifier, points []runtimedrift.Point) (ScoreJobResult, error) {
+    res := ScoreJobResult{Scores: points}
+    var err error
+    if res.Alerts, err = runtimedrift.Record(root, points); err != nil {
+        return res, err
+    }
+    var errs []error
+    for _, a := range res.Alerts {
+        if err := notifier.Notify(ctx, a.Event()); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    if err := errors.Join(errs...); err != nil {
+        res.AlertError = err.Error()
+    }
+    return res, nil
+}
 
+// jobFailedEvent describes a job that failed for good. Failures are keyed by
+// job type and tenant, so a run of failing jobs notifies once per cooldown.
+func jobFailedEvent(job runtimejobs.Job) runtimenotifications.Event {
+    fields := map[string]string{
+        "job":      job.ID,
+        "type":     job.Type,
+        "attempts": fmt.Sprintf("%d/%d", job.Attempts, job.MaxAttempts),
+    }
+    if job.TenantID != "" {
+        fields["tenant"] = job.TenantID
+    }
+    return runtimenotifications.Event{
+        Type:     runtimenotifications.EventJobFailed,
+        Severity: runtimenotifications.SeverityWarning,
+        Title:    fmt.Sprintf("%s job failed", job.Type),
+        Text:     fmt.Sprintf("Job %s (%s) failed after %d attempt(s): %s", job.ID, job.Type, job.Attempts, job.Error),
+        Fields:   fields,
+        Key:      runtimenotifications.EventJobFailed + "/" + job.Type + "/" + job.TenantID,
+    }
+}
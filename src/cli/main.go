@@ -29,11 +29,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/contexis-cmp/contexis/src/cli/commands"
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -48,15 +50,32 @@ var rootCmd = &cobra.Command{
 	
 The Context-Memory-Prompt (CMP) architecture treats AI components as version-controlled,
 first-class citizens, bringing architectural discipline to AI application engineering.`,
-	Version: "0.2.0",
+	Version: buildinfo.Get().String(),
+	// With --output json|yaml, stdout carries only the result document, so
+	// loggers are re-created once os.Stdout points at stderr
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := commands.PrepareOutput(cmd); err != nil {
+			return err
+		}
+		if commands.OutputFormat(cmd) == commands.OutputText {
+			return nil
+		}
+		if err := logger.InitColoredLogger("info"); err != nil {
+			return err
+		}
+		return logger.InitLogger("info", "json")
+	},
 }
 
 // init initializes the CLI by adding all subcommands to the root command.
 // This function is called automatically when the package is imported.
 func init() {
+	commands.AddOutputFlag(rootCmd)
+
 	// Add subcommands
 	rootCmd.AddCommand(commands.InitCmd)
 	rootCmd.AddCommand(commands.GenerateCmd)
+	rootCmd.AddCommand(commands.GetDestroyCommand())
 	
 	// Plugin commands (use current working directory as project root)
 	if cwd, err := os.Getwd(); err == nil {
@@ -71,6 +90,33 @@ func init() {
 	// Memory command
 	rootCmd.AddCommand(commands.GetMemoryCommand())
 	
+	// Usage reporting
+	rootCmd.AddCommand(commands.GetUsageCommand())
+	
+	// OpenAPI document and generated clients
+	rootCmd.AddCommand(commands.GetAPICommand())
+	
+	// Data-subject export and erasure
+	rootCmd.AddCommand(commands.GetPrivacyCommand())
+	
+	// Chat session transcripts
+	rootCmd.AddCommand(commands.GetSessionsCommand())
+	
+	// Out-of-band approvals
+	rootCmd.AddCommand(commands.GetApprovalsCommand())
+	
+	// API key management
+	rootCmd.AddCommand(commands.GetKeysCommand())
+	
+	// Audit log search
+	rootCmd.AddCommand(commands.GetAuditCommand())
+	
+	// Evaluation suites
+	rootCmd.AddCommand(commands.GetEvalCommand())
+	
+	// Drift history
+	rootCmd.AddCommand(commands.GetDriftCommand())
+	
 	// Prompt command
 	rootCmd.AddCommand(commands.GetPromptCommand())
 	
@@ -82,14 +128,23 @@ func init() {
 	// Build/Deploy commands
 	rootCmd.AddCommand(commands.GetBuildCommand())
 	rootCmd.AddCommand(commands.GetDeployCommand())
-	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(commands.GetVersionCommand())
 	rootCmd.AddCommand(commands.GetServeCommand())
 	rootCmd.AddCommand(commands.GetRunCommand())
+	rootCmd.AddCommand(commands.GetBenchCommand())
+	rootCmd.AddCommand(commands.GetProfileCommand())
 	rootCmd.AddCommand(commands.GetWorkerCommand())
 	rootCmd.AddCommand(commands.GetHFCommand())
 	rootCmd.AddCommand(commands.GetModelsCommand())
 	rootCmd.AddCommand(commands.GetMigrateCommand())
 	rootCmd.AddCommand(commands.GetSetupCommand())
+	rootCmd.AddCommand(commands.GetDoctorCommand())
+	rootCmd.AddCommand(commands.GetUpgradeCommand())
+
+	// Replace cobra's default completion command and complete project names
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(commands.GetCompletionCommand())
+	commands.RegisterCompletions(rootCmd)
 }
 
 // main is the entry point for the Contexis CLI application.
@@ -108,6 +163,21 @@ func main() {
 	ctx := context.WithValue(context.Background(), "request_id", generateRequestID())
 
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		// With --output json|yaml the error goes out as a ctx.error/v1 result
+		if commands.ReportError(err) {
+			code := 1
+			var exitErr *commands.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.Code
+			}
+			os.Exit(code)
+		}
+		// Commands such as doctor report their own findings and pick the exit code
+		var exitErr *commands.ExitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitErr.Code)
+		}
 		log.Error("command execution failed", zap.Error(err))
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -141,6 +211,20 @@ var testCmd = &cobra.Command{
 		useSemantic, _ := cmd.Flags().GetBool("semantic")
 		component, _ := cmd.Flags().GetString("component")
 		writeJUnit, _ := cmd.Flags().GetBool("junit")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		reportFormat, _ := cmd.Flags().GetString("report")
+		if reportFormat != "" && reportFormat != "html" {
+			fmt.Fprintf(os.Stderr, "invalid --report %q: must be html\n", reportFormat)
+			os.Exit(1)
+		}
+
+		// Refresh provider cassettes: model calls go live and are recorded
+		if record, _ := cmd.Flags().GetBool("record"); record {
+			if err := commands.EnableProviderRecording(""); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to enable recording: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		if driftOnly {
 			// Execute drift detection tests
@@ -150,8 +234,15 @@ var testCmd = &cobra.Command{
 				UseSemantic:     useSemantic,
 				ComponentFilter: component,
 				WriteJUnit:      writeJUnit,
+				Concurrency:     concurrency,
 			}
-			if err := commands.RunDriftDetection(cmd.Context(), "", opts); err != nil {
+			reports, err := commands.RunDriftDetectionReport(cmd.Context(), "", opts)
+			writeTestReport(reportFormat, outDir)
+			if err == nil {
+				_, err = commands.EmitResult(cmd, commands.SchemaDrift, commands.SummarizeDrift(reports), nil)
+			}
+			if err != nil {
+				commands.ReportError(err)
 				fmt.Fprintf(os.Stderr, "Drift detection failed: %v\n", err)
 				os.Exit(1)
 			}
@@ -177,13 +268,32 @@ var testCmd = &cobra.Command{
 			Coverage:   coverage,
 			WriteJUnit: writeJUnit,
 		}
-		if err := commands.RunGoTests(cmd.Context(), "", gOpts); err != nil {
+		report, err := commands.RunGoTestsReport(cmd.Context(), "", gOpts)
+		writeTestReport(reportFormat, outDir)
+		if _, werr := commands.EmitResult(cmd, commands.SchemaTest, report, err); werr != nil && err == nil {
+			err = werr
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Go tests failed: %v\n", err)
 			os.Exit(1)
 		}
 	},
 }
 
+// writeTestReport renders the latest reports in outDir in the --report
+// format, if one was requested.
+func writeTestReport(format, outDir string) {
+	if format == "" {
+		return
+	}
+	path, err := commands.WriteHTMLReport("", outDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "HTML report failed: %v\n", err)
+		return
+	}
+	fmt.Printf("📄 HTML report: %s\n", path)
+}
+
 // initTestFlags initializes the command-line flags for the test command.
 // This function is called automatically when the package is imported.
 func init() {
@@ -194,6 +304,8 @@ func init() {
 	testCmd.Flags().Bool("semantic", false, "Use semantic similarity via tools/<Component>/semantic_search.py if available")
 	testCmd.Flags().String("component", "", "Limit to a single component (e.g., CustomerDocs)")
 	testCmd.Flags().Bool("junit", false, "Write JUnit XML report for CI integration")
+	testCmd.Flags().String("report", "", "Also render a readable report of the results (html)")
+	testCmd.Flags().Int("concurrency", 0, "Drift specs run in parallel (default: number of CPUs)")
 	
 	// Go test selection
 	testCmd.Flags().Bool("all", false, "Run all Go test suites (unit, integration, e2e)")
@@ -202,15 +314,5 @@ func init() {
 	testCmd.Flags().Bool("e2e", false, "Run Go end-to-end tests only")
 	testCmd.Flags().String("category", "", "Run tests for a configured category from tests/test_config.yaml")
 	testCmd.Flags().Bool("coverage", false, "Collect coverage and enforce thresholds from tests/test_config.yaml")
-}
-
-// versionCmd displays version information for the Contexis CLI.
-// It shows the current version number and can be extended to include
-// additional build information like commit hash and build date.
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Show version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Contexis CMP Framework v0.2.0")
-	},
+	testCmd.Flags().Bool("record", false, "Record provider outputs to tests/cassettes for CMP_PROVIDER_MODE=replay")
 }
@@ -14,6 +14,10 @@ import (
 // capabilities, available tools, guardrails, memory behavior, and testing
 // configuration. Contexts are typically loaded from `.ctx` YAML files.
 type Context struct {
+	// SchemaVersion is the .ctx schema the file was written for; files
+	// without one are version 1.
+	SchemaVersion int `json:"schema_version,omitempty" yaml:"schema_version,omitempty"`
+
 	Name        string `json:"name" yaml:"name"`
 	Version     string `json:"version" yaml:"version"`
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
@@ -23,6 +27,10 @@ type Context struct {
 	Guardrails Guardrails    `json:"guardrails,omitempty" yaml:"guardrails,omitempty"`
 	Memory     MemoryConfig  `json:"memory,omitempty" yaml:"memory,omitempty"`
 	Testing    TestingConfig `json:"testing,omitempty" yaml:"testing,omitempty"`
+	// Retrieval declares how memory is searched for answers (RAG).
+	Retrieval *RetrievalConfig `json:"retrieval,omitempty" yaml:"retrieval,omitempty"`
+	// Agent answers chat requests with a tool-using loop instead of one generation.
+	Agent *AgentConfig `json:"agent,omitempty" yaml:"agent,omitempty"`
 
 	CreatedAt time.Time         `json:"created_at" yaml:"created_at"`
 	UpdatedAt time.Time         `json:"updated_at" yaml:"updated_at"`
@@ -37,9 +45,77 @@ type Role struct {
 }
 
 // Tool represents an external function or integration available to the agent.
+// HTTP tools (e.g. generated from an OpenAPI spec) also declare their method
+// and parameters; URI is then the endpoint URL with {param} placeholders.
+// Tools without parameters may instead describe their arguments with a JSON
+// Schema (e.g. scaffolded by ctx generate tool), sent as a JSON body.
+// Command tools run as a local subprocess, and webhook and database tools are
+// executed by the runtime itself, instead of calling a URI.
 type Tool struct {
+	Name        string                 `json:"name" yaml:"name"`
+	URI         string                 `json:"uri,omitempty" yaml:"uri,omitempty"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Method      string                 `json:"method,omitempty" yaml:"method,omitempty"`
+	Parameters  []ToolParameter        `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty" yaml:"schema,omitempty"`
+	// Command is the argv of a command tool, run from the project root; it
+	// reads its arguments as a JSON object on stdin and writes the observation.
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// Webhook posts the arguments to a URL, signed, with retries.
+	Webhook *WebhookConfig `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	// Database lets the model run parameterized SQL against a database.
+	Database *DatabaseConfig `json:"database,omitempty" yaml:"database,omitempty"`
+	// Policy widens or limits what the runtime lets the tool do.
+	Policy *ToolPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// WebhookConfig is the endpoint of a webhook tool. The arguments are sent as
+// a JSON body (as the query for GET) and, with a secret, signed with
+// HMAC-SHA256. Secret and header values may be secret:// references or
+// env:NAME, resolved on every call.
+type WebhookConfig struct {
+	URL              string            `json:"url" yaml:"url"`
+	Method           string            `json:"method,omitempty" yaml:"method,omitempty"` // default POST
+	Headers          map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	SecretRef        string            `json:"secret_ref,omitempty" yaml:"secret_ref,omitempty"`
+	SignatureHeader  string            `json:"signature_header,omitempty" yaml:"signature_header,omitempty"` // default X-CMP-Signature
+	Retries          *int              `json:"retries,omitempty" yaml:"retries,omitempty"`                   // retries of transient failures; default 2
+	TimeoutSeconds   int               `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`   // per attempt; default 10
+	MaxResponseBytes int               `json:"max_response_bytes,omitempty" yaml:"max_response_bytes,omitempty"`
+}
+
+// DatabaseConfig is the connection of a database tool, queried through the
+// sqlite3, psql or mysql client. DSN is the database file for sqlite, relative
+// to the project root, and a postgres:// or mysql:// URL otherwise; it may be
+// a secret:// reference or env:NAME.
+type DatabaseConfig struct {
+	Driver     string `json:"driver" yaml:"driver"` // sqlite|postgres|mysql
+	DSN        string `json:"dsn" yaml:"dsn"`
+	ReadOnly   *bool  `json:"read_only,omitempty" yaml:"read_only,omitempty"`     // default true
+	MaxRows    int    `json:"max_rows,omitempty" yaml:"max_rows,omitempty"`       // default 100
+	MaxColumns int    `json:"max_columns,omitempty" yaml:"max_columns,omitempty"` // default 20
+}
+
+// ToolPolicy is the execution policy of a tool. The runtime denies what it
+// does not allow: HTTP tools reach only their own host and method unless
+// domains and methods list others, command tools only get the paths and
+// environment variables listed here.
+type ToolPolicy struct {
+	Domains        []string `json:"domains,omitempty" yaml:"domains,omitempty"` // hosts; *.example.com includes subdomains
+	Methods        []string `json:"methods,omitempty" yaml:"methods,omitempty"` // HTTP methods
+	Paths          []string `json:"paths,omitempty" yaml:"paths,omitempty"`     // project files or directories path arguments may name
+	Env            []string `json:"env,omitempty" yaml:"env,omitempty"`         // environment variables passed to command tools
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" yaml:"timeout_seconds,omitempty"`
+	MaxMemoryMB    int      `json:"max_memory_mb,omitempty" yaml:"max_memory_mb,omitempty"`     // command tools: address space
+	MaxCPUSeconds  int      `json:"max_cpu_seconds,omitempty" yaml:"max_cpu_seconds,omitempty"` // command tools: CPU time
+}
+
+// ToolParameter describes one argument of an HTTP tool.
+type ToolParameter struct {
 	Name        string `json:"name" yaml:"name"`
-	URI         string `json:"uri" yaml:"uri"`
+	In          string `json:"in" yaml:"in"` // path, query, header, body
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"`
+	Required    bool   `json:"required,omitempty" yaml:"required,omitempty"`
 	Description string `json:"description,omitempty" yaml:"description,omitempty"`
 }
 
@@ -49,6 +125,58 @@ type Guardrails struct {
 	Format      string  `json:"format,omitempty" yaml:"format,omitempty"`
 	MaxTokens   int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
 	Temperature float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+
+	// PII overrides the runtime PII policy (CMP_PII_MODE) for this context.
+	PII *PIIGuardrails `json:"pii,omitempty" yaml:"pii,omitempty"`
+	// Response declares a JSON Schema that model output must satisfy.
+	Response *ResponseGuardrails `json:"response,omitempty" yaml:"response,omitempty"`
+	// OutputFilters run in order on every response after inference.
+	OutputFilters []OutputFilter `json:"output_filters,omitempty" yaml:"output_filters,omitempty"`
+	// Grounding checks that answers are supported by the retrieved memory chunks.
+	Grounding *GroundingGuardrails `json:"grounding,omitempty" yaml:"grounding,omitempty"`
+}
+
+// PIIGuardrails configures PII handling for queries and responses.
+type PIIGuardrails struct {
+	Mode  string   `json:"mode,omitempty" yaml:"mode,omitempty"`   // off|allow|redact|block
+	Types []string `json:"types,omitempty" yaml:"types,omitempty"` // e.g. email, phone, ssn, credit_card
+}
+
+// ResponseGuardrails configures structured output validation. When Schema is set,
+// model output is validated against it and repaired up to MaxRepairs times.
+type ResponseGuardrails struct {
+	Schema     map[string]interface{} `json:"schema,omitempty" yaml:"schema,omitempty"`
+	MaxRepairs *int                   `json:"max_repairs,omitempty" yaml:"max_repairs,omitempty"` // default 2
+}
+
+// OutputFilter configures one response filter: a deny-list of words, regular
+// expressions, or a moderation API, and what to do when it matches.
+type OutputFilter struct {
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	Type   string `json:"type" yaml:"type"`                         // deny_list|regex|moderation
+	Action string `json:"action,omitempty" yaml:"action,omitempty"` // block|redact|annotate; default block
+
+	Words         []string `json:"words,omitempty" yaml:"words,omitempty"`                   // deny_list
+	WordsFile     string   `json:"words_file,omitempty" yaml:"words_file,omitempty"`         // deny_list; one word per line, project-relative
+	Patterns      []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`             // regex
+	CaseSensitive bool     `json:"case_sensitive,omitempty" yaml:"case_sensitive,omitempty"` // deny_list and regex
+	Replacement   string   `json:"replacement,omitempty" yaml:"replacement,omitempty"`       // redact; default [filtered]
+
+	Endpoint   string   `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`       // moderation; OpenAI-compatible /v1/moderations URL
+	Model      string   `json:"model,omitempty" yaml:"model,omitempty"`             // moderation
+	Categories []string `json:"categories,omitempty" yaml:"categories,omitempty"`   // moderation; empty means any flagged category
+	Threshold  float64  `json:"threshold,omitempty" yaml:"threshold,omitempty"`     // moderation; score above which a category counts
+	FailClosed bool     `json:"fail_closed,omitempty" yaml:"fail_closed,omitempty"` // moderation; block when the API fails
+}
+
+// GroundingGuardrails configures the post-inference grounding check. Each
+// sentence of the answer is a claim scored against the retrieved chunks; the
+// answer's score is the share of claims that are supported.
+type GroundingGuardrails struct {
+	Method         string  `json:"method,omitempty" yaml:"method,omitempty"`                   // overlap|embedding|judge; default overlap
+	Threshold      float64 `json:"threshold,omitempty" yaml:"threshold,omitempty"`             // minimum answer score; default 0.6
+	ClaimThreshold float64 `json:"claim_threshold,omitempty" yaml:"claim_threshold,omitempty"` // minimum claim score; default 0.5
+	Action         string  `json:"action,omitempty" yaml:"action,omitempty"`                   // flag|block; default flag
 }
 
 // MemoryConfig defines conversational memory behavior for an agent.
@@ -56,6 +184,50 @@ type MemoryConfig struct {
 	Episodic   bool   `json:"episodic" yaml:"episodic"`
 	MaxHistory int    `json:"max_history" yaml:"max_history"`
 	Privacy    string `json:"privacy" yaml:"privacy"`
+	// Summarization folds older turns of long sessions into a summary.
+	Summarization *SummarizationConfig `json:"summarization,omitempty" yaml:"summarization,omitempty"`
+}
+
+// SummarizationConfig configures when conversation turns are summarized with
+// the context's provider. Either trigger starts a summary; the last KeepRecent
+// turns stay verbatim.
+type SummarizationConfig struct {
+	Enabled       bool `json:"enabled" yaml:"enabled"`
+	TriggerTurns  int  `json:"trigger_turns,omitempty" yaml:"trigger_turns,omitempty"`   // default 20
+	TriggerTokens int  `json:"trigger_tokens,omitempty" yaml:"trigger_tokens,omitempty"` // estimated tokens; 0 disables
+	KeepRecent    int  `json:"keep_recent,omitempty" yaml:"keep_recent,omitempty"`       // default max_history, else 4
+}
+
+// RetrievalConfig declares how the runtime searches memory for this context.
+// Fields left unset fall back to the request and the component's
+// memory_config.yaml.
+type RetrievalConfig struct {
+	TopK     int               `json:"top_k,omitempty" yaml:"top_k,omitempty"`       // results injected when the request sets none; default 5
+	Hybrid   *bool             `json:"hybrid,omitempty" yaml:"hybrid,omitempty"`     // hybrid keyword+vector search, or vector only
+	Reranker *RerankerConfig   `json:"reranker,omitempty" yaml:"reranker,omitempty"` // second-stage reranking
+	Filters  map[string]string `json:"filters,omitempty" yaml:"filters,omitempty"`   // result metadata that must match, e.g. language: de
+	Chunking *ChunkingConfig   `json:"chunking,omitempty" yaml:"chunking,omitempty"` // applied when ingesting for this context
+}
+
+// RerankerConfig selects the reranker of a RetrievalConfig.
+type RerankerConfig struct {
+	Provider   string `json:"provider" yaml:"provider"` // cross_encoder|cohere|voyage|none
+	Model      string `json:"model,omitempty" yaml:"model,omitempty"`
+	TopN       int    `json:"top_n,omitempty" yaml:"top_n,omitempty"`           // kept after reranking; default top_k
+	Candidates int    `json:"candidates,omitempty" yaml:"candidates,omitempty"` // fetched before reranking; default 4x top_k
+}
+
+// ChunkingConfig sets how documents are split before embedding, in characters.
+type ChunkingConfig struct {
+	Size    int `json:"size" yaml:"size"`
+	Overlap int `json:"overlap,omitempty" yaml:"overlap,omitempty"`
+}
+
+// AgentConfig enables the agent loop: the model may call the context's HTTP
+// tools and memory search, seeing each result, until it answers.
+type AgentConfig struct {
+	Enabled       bool `json:"enabled" yaml:"enabled"`
+	MaxIterations int  `json:"max_iterations,omitempty" yaml:"max_iterations,omitempty"` // model turns that may call tools; default 5
 }
 
 // TestingConfig defines testing parameters such as drift thresholds
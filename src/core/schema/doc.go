@@ -1,5 +1,6 @@
 // Package schema provides validation utilities for Contexis context files.
 //
-// It includes lightweight YAML checks and optional JSON Schema validation
-// for `.ctx` files used to define agent contexts.
+// It includes lightweight YAML checks, JSON Schema validation for each
+// `.ctx` schema version, and the migration of older files to the current
+// version.
 package schema
@@ -0,0 +1,293 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContextMigration is the result of upgrading a `.ctx` file to
+// CurrentContextVersion.
+type ContextMigration struct {
+	From, To int
+	// Content is the upgraded file, the input itself when From == To.
+	Content []byte
+	// Notes explain each change that is not just the new schema_version.
+	Notes []string
+}
+
+// MigrateContext upgrades a `.ctx` file from the version it declares to
+// CurrentContextVersion. Edits are made line by line, so comments, blank
+// lines and quoting are kept; files with flow-style mappings in the way are
+// re-encoded instead. Tenant overrides and other partial files migrate too,
+// since the file is not validated.
+func MigrateContext(data []byte) (ContextMigration, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return ContextMigration{}, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return ContextMigration{}, fmt.Errorf("not a context: the document is not a mapping")
+	}
+	var m map[string]interface{}
+	if err := doc.Decode(&m); err != nil {
+		return ContextMigration{}, err
+	}
+	from, err := ContextVersion(m)
+	if err != nil {
+		return ContextMigration{}, err
+	}
+	res := ContextMigration{From: from, To: CurrentContextVersion, Content: data}
+	if from == CurrentContextVersion {
+		return res, nil
+	}
+	e := &contextEditor{doc: &doc, root: doc.Content[0], lines: strings.SplitAfter(string(data), "\n")}
+	for v := from; v < CurrentContextVersion; v++ {
+		if err := contextMigrations[v](e); err != nil {
+			return ContextMigration{}, fmt.Errorf("migrate schema_version %d to %d: %w", v, v+1, err)
+		}
+	}
+	e.setVersion(CurrentContextVersion)
+	if res.Content, err = e.render(); err != nil {
+		return ContextMigration{}, err
+	}
+	res.Notes = e.notes
+	return res, nil
+}
+
+// contextMigrations[v] upgrades a file from schema version v to v+1.
+var contextMigrations = map[int]func(e *contextEditor) error{
+	1: migrateContextV1,
+}
+
+// migrateContextV1 upgrades to version 2, which rejects the guardrails and
+// memory keys version 1 ignored. memory.type: episodic, written by older
+// agent generators, becomes the memory.episodic flag it was meant to set.
+func migrateContextV1(e *contextEditor) error {
+	if mem := e.child(e.root, "memory"); mem != nil && mem.Kind == yaml.MappingNode {
+		if t := e.child(mem, "type"); t != nil && t.Kind == yaml.ScalarNode && t.Value == "episodic" && e.child(mem, "episodic") == nil {
+			e.replace(mem, "type", "episodic", "true")
+			e.notef("memory.type: episodic is now memory.episodic: true; it was ignored before, so episodic memory turns on")
+		}
+	}
+	reasons := map[string]string{
+		"memory.type":                     "episodic memory is enabled with memory.episodic",
+		"memory.vector_store":             "the vector store is set in memory/<Component>/memory_config.yaml",
+		"memory.embedding_model":          "the embedding model is set in memory/<Component>/memory_config.yaml",
+		"guardrails.similarity_threshold": "set drift_thresholds.similarity_threshold in tests/<Component>/rag_drift_test.yaml",
+	}
+	for _, section := range []string{"guardrails", "memory"} {
+		known, err := schemaProperties(2, section)
+		if err != nil {
+			return err
+		}
+		e.prune(section, known, func(key string) string {
+			if r, ok := reasons[section+"."+key]; ok {
+				return fmt.Sprintf("removed %s.%s, which had no effect: %s", section, key, r)
+			}
+			return fmt.Sprintf("removed unknown key %s.%s, which had no effect", section, key)
+		})
+	}
+	return nil
+}
+
+// schemaProperties lists the properties a schema version allows in a
+// top-level section.
+func schemaProperties(version int, section string) (map[string]bool, error) {
+	var s struct {
+		Properties map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(contextSchemas[version], &s); err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for k := range s.Properties[section].Properties {
+		out[k] = true
+	}
+	return out, nil
+}
+
+// contextEditor applies edits to the node tree of a context file and, while
+// every edit is on whole lines of block-style YAML, to its lines as well.
+type contextEditor struct {
+	doc   *yaml.Node
+	root  *yaml.Node
+	lines []string
+	edits []lineEdit
+	// reencode is set by an edit that cannot be made on lines.
+	reencode bool
+	notes    []string
+}
+
+// lineEdit replaces lines [start, end] (1-based) with text; end < start
+// inserts text before start.
+type lineEdit struct {
+	start, end int
+	text       string
+}
+
+func (e *contextEditor) notef(format string, args ...interface{}) {
+	e.notes = append(e.notes, fmt.Sprintf(format, args...))
+}
+
+// child returns the value of key in mapping m, or nil.
+func (e *contextEditor) child(m *yaml.Node, key string) *yaml.Node {
+	if i := pairIndex(m, key); i >= 0 {
+		return m.Content[i+1]
+	}
+	return nil
+}
+
+func pairIndex(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastLine is the last line a node spans; block scalars report -1, as their
+// extent is unknown.
+func lastLine(n *yaml.Node) int {
+	if n.Kind == yaml.ScalarNode && n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return -1
+	}
+	end := n.Line
+	for _, c := range n.Content {
+		l := lastLine(c)
+		if l < 0 {
+			return -1
+		}
+		if l > end {
+			end = l
+		}
+	}
+	return end
+}
+
+// remove deletes key from mapping m.
+func (e *contextEditor) remove(m *yaml.Node, key string) {
+	i := pairIndex(m, key)
+	if i < 0 {
+		return
+	}
+	k, v := m.Content[i], m.Content[i+1]
+	end := lastLine(v)
+	if m.Style&yaml.FlowStyle != 0 || end < 0 || e.sharesLine(m, i) {
+		e.reencode = true
+	} else {
+		e.edits = append(e.edits, lineEdit{start: k.Line, end: end})
+	}
+	m.Content = append(m.Content[:i], m.Content[i+2:]...)
+}
+
+// sharesLine reports whether the pair at i shares a line with another pair.
+func (e *contextEditor) sharesLine(m *yaml.Node, i int) bool {
+	line := m.Content[i].Line
+	for j := 0; j+1 < len(m.Content); j += 2 {
+		if j != i && (m.Content[j].Line == line || lastLine(m.Content[j+1]) == line) {
+			return true
+		}
+	}
+	return false
+}
+
+// replace renames key in mapping m and sets its scalar value.
+func (e *contextEditor) replace(m *yaml.Node, key, newKey, value string) {
+	i := pairIndex(m, key)
+	k, v := m.Content[i], m.Content[i+1]
+	if m.Style&yaml.FlowStyle != 0 || v.Line != k.Line || v.Kind != yaml.ScalarNode || e.sharesLine(m, i) {
+		e.reencode = true
+	} else {
+		indent := strings.Repeat(" ", k.Column-1)
+		e.edits = append(e.edits, lineEdit{start: k.Line, end: k.Line, text: indent + newKey + ": " + value + "\n"})
+	}
+	k.Value = newKey
+	var n yaml.Node
+	_ = yaml.Unmarshal([]byte(value), &n)
+	*v = *n.Content[0]
+}
+
+// prune removes the keys of a top-level section that known does not list,
+// explaining each with note. A section left empty is removed.
+func (e *contextEditor) prune(section string, known map[string]bool, note func(key string) string) {
+	m := e.child(e.root, section)
+	if m == nil || m.Kind != yaml.MappingNode {
+		return
+	}
+	var unknown []string
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if !known[m.Content[i].Value] {
+			unknown = append(unknown, m.Content[i].Value)
+		}
+	}
+	for _, key := range unknown {
+		e.notes = append(e.notes, note(key))
+	}
+	if len(unknown) > 0 && len(unknown)*2 == len(m.Content) {
+		e.remove(e.root, section)
+		return
+	}
+	for _, key := range unknown {
+		e.remove(m, key)
+	}
+}
+
+// setVersion sets schema_version, adding it as the first key.
+func (e *contextEditor) setVersion(v int) {
+	value := strconv.Itoa(v)
+	if pairIndex(e.root, "schema_version") >= 0 {
+		e.replace(e.root, "schema_version", "schema_version", value)
+		return
+	}
+	if e.root.Style&yaml.FlowStyle != 0 || len(e.root.Content) == 0 || e.root.Content[0].Column != 1 {
+		e.reencode = true
+	} else {
+		line := e.root.Content[0].Line
+		e.edits = append(e.edits, lineEdit{start: line, end: line - 1, text: "schema_version: " + value + "\n"})
+	}
+	e.root.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "schema_version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: value},
+	}, e.root.Content...)
+}
+
+// render returns the edited file: the edited lines, or the re-encoded node
+// tree when an edit could not be made on lines.
+func (e *contextEditor) render() ([]byte, error) {
+	if e.reencode {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(e.doc); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	lines := e.lines
+	// Later edits first, so earlier line numbers stay valid
+	sort.SliceStable(e.edits, func(i, j int) bool { return e.edits[i].start > e.edits[j].start })
+	for _, ed := range e.edits {
+		out := append([]string{}, lines[:ed.start-1]...)
+		if ed.text != "" {
+			out = append(out, ed.text)
+		}
+		tail := ed.end
+		if tail < ed.start-1 {
+			tail = ed.start - 1
+		}
+		lines = append(out, lines[tail:]...)
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
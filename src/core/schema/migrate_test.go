@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestContextVersion(t *testing.T) {
+	for _, tc := range []struct {
+		yaml string
+		want int
+		err  string
+	}{
+		{"name: a\n", 1, ""},
+		{"schema_version: 2\n", 2, ""},
+		{"schema_version: 1.0\n", 1, ""},
+		{"schema_version: 0\n", 0, "positive integer"},
+		{"schema_version: two\n", 0, "positive integer"},
+		{"schema_version: 99\n", 0, "upgrade ctx"},
+	} {
+		var m map[string]interface{}
+		if err := yaml.Unmarshal([]byte(tc.yaml), &m); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ContextVersion(m)
+		if got != tc.want || (err == nil) != (tc.err == "") || (err != nil && !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("ContextVersion(%q) = %d, %v", tc.yaml, got, err)
+		}
+	}
+}
+
+func TestValidateContextMap_PerVersion(t *testing.T) {
+	legacy := map[string]interface{}{
+		"name": "Bot", "version": "1.0.0",
+		"role":       map[string]interface{}{"persona": "helper"},
+		"guardrails": map[string]interface{}{"similarity_threshold": 0.7},
+	}
+	if err := ValidateContextMap(legacy); err != nil {
+		t.Fatalf("version 1 accepts unknown guardrails: %v", err)
+	}
+	legacy["schema_version"] = 2
+	if err := ValidateContextMap(legacy); err == nil {
+		t.Fatal("expected version 2 to reject guardrails.similarity_threshold")
+	}
+	delete(legacy, "guardrails")
+	if err := ValidateContextMap(legacy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateContext_KeepsFormatting(t *testing.T) {
+	in := `# Support agent
+# owned by the support team
+
+name: "Bot"
+version: "1.0.0"
+role:
+  persona: "helper"   # keep
+guardrails:
+  tone: "professional"
+  similarity_threshold: 0.7
+memory:
+  type: "episodic"
+  max_history: 10
+  vector_store: "sqlite"
+tools: []
+`
+	res, err := MigrateContext([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# Support agent
+# owned by the support team
+
+schema_version: 2
+name: "Bot"
+version: "1.0.0"
+role:
+  persona: "helper"   # keep
+guardrails:
+  tone: "professional"
+memory:
+  episodic: true
+  max_history: 10
+tools: []
+`
+	if res.From != 1 || res.To != CurrentContextVersion || string(res.Content) != want {
+		t.Fatalf("MigrateContext = %d -> %d:\n%s", res.From, res.To, res.Content)
+	}
+	if len(res.Notes) != 3 || !strings.Contains(res.Notes[0], "memory.episodic: true") || !strings.Contains(res.Notes[1], "rag_drift_test.yaml") {
+		t.Fatalf("notes = %q", res.Notes)
+	}
+	if err := ValidateContextYAML(res.Content); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := MigrateContext(res.Content)
+	if err != nil || again.From != CurrentContextVersion || string(again.Content) != want || len(again.Notes) != 0 {
+		t.Fatalf("expected a current file to be left alone, got %+v, %v", again, err)
+	}
+}
+
+func TestMigrateContext_EmptiedSectionsAndFlowStyle(t *testing.T) {
+	res, err := MigrateContext([]byte("name: Bot\nmemory:\n  vector_store: sqlite\n  embedding_model: x\nrole:\n  persona: p\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Content) != "schema_version: 2\nname: Bot\nrole:\n  persona: p\n" {
+		t.Fatalf("content:\n%s", res.Content)
+	}
+
+	res, err = MigrateContext([]byte("name: Bot\nmemory: {type: none, max_history: 3}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Content) != "schema_version: 2\nname: Bot\nmemory: {max_history: 3}\n" {
+		t.Fatalf("content:\n%s", res.Content)
+	}
+
+	if _, err := MigrateContext([]byte("- not a context\n")); err == nil {
+		t.Fatal("expected a list to be rejected")
+	}
+}
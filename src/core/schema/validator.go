@@ -3,8 +3,7 @@ package schema
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/xeipuuv/gojsonschema"
 	"gopkg.in/yaml.v3"
@@ -12,49 +11,73 @@ import (
 
 // ValidateContextYAML performs a lightweight validation of a `.ctx` YAML file.
 //
-// The function ensures required top-level fields exist and have expected types.
-// If a JSON Schema is present at `src/core/schema/context_schema.json`, it
-// validates against that schema; otherwise it falls back to basic checks.
+// The function rejects schema versions this release does not know and
+// ensures required top-level fields exist and have expected types. Use
+// ValidateContextMap for a full check against the JSON Schema.
 func ValidateContextYAML(yamlBytes []byte) error {
 	var m map[string]interface{}
 	if err := yaml.Unmarshal(yamlBytes, &m); err != nil {
 		return fmt.Errorf("invalid YAML: %w", err)
 	}
-	// Load schema relative to project root if possible
-	schemaPath := filepath.Join("src", "core", "schema", "context_schema.json")
-	if _, err := os.Stat(schemaPath); err != nil {
-		// fallback: basic checks if schema missing
-		if v, ok := m["name"].(string); !ok || v == "" {
-			return fmt.Errorf("field 'name' is required and must be a non-empty string")
-		}
-		if v, ok := m["version"].(string); !ok || v == "" {
-			return fmt.Errorf("field 'version' is required and must be a non-empty string")
-		}
-		role, ok := m["role"].(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("field 'role' is required and must be an object")
-		}
-		if v, ok := role["persona"].(string); !ok || v == "" {
-			return fmt.Errorf("field 'role.persona' is required and must be a non-empty string")
-		}
-		return nil
+	if _, err := ContextVersion(m); err != nil {
+		return err
+	}
+	if v, ok := m["name"].(string); !ok || v == "" {
+		return fmt.Errorf("field 'name' is required and must be a non-empty string")
 	}
-	sl := gojsonschema.NewReferenceLoader("file://" + schemaPath)
-	// convert YAML to JSON for validation
-	by, err := json.Marshal(m)
+	if v, ok := m["version"].(string); !ok || v == "" {
+		return fmt.Errorf("field 'version' is required and must be a non-empty string")
+	}
+	role, ok := m["role"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("field 'role' is required and must be an object")
+	}
+	if v, ok := role["persona"].(string); !ok || v == "" {
+		return fmt.Errorf("field 'role.persona' is required and must be a non-empty string")
+	}
+	return nil
+}
+
+// ValidateContextMap validates a decoded context against the schema of the
+// version it declares, compiled into the binary so it works outside the
+// project root.
+func ValidateContextMap(m map[string]interface{}) error {
+	version, err := ContextVersion(m)
 	if err != nil {
 		return err
 	}
-	dl := gojsonschema.NewBytesLoader(by)
-	res, err := gojsonschema.Validate(sl, dl)
+	res, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(contextSchemas[version]), gojsonschema.NewGoLoader(m))
 	if err != nil {
 		return fmt.Errorf("schema validation error: %w", err)
 	}
 	if !res.Valid() {
-		if len(res.Errors()) > 0 {
-			return fmt.Errorf("schema invalid: %s", res.Errors()[0].String())
+		return fmt.Errorf("schema invalid: %s", res.Errors()[0].String())
+	}
+	return nil
+}
+
+// ValidateContextField validates a single value against the part of the
+// current context schema at path, a dotted property path such as
+// guardrails.max_tokens. Paths the schema does not describe accept any value.
+func ValidateContextField(path string, v interface{}) error {
+	var node map[string]interface{}
+	if err := json.Unmarshal(contextSchemas[CurrentContextVersion], &node); err != nil {
+		return err
+	}
+	for _, key := range strings.Split(path, ".") {
+		props, _ := node["properties"].(map[string]interface{})
+		sub, ok := props[key].(map[string]interface{})
+		if !ok {
+			return nil
 		}
-		return fmt.Errorf("schema invalid")
+		node = sub
+	}
+	res, err := gojsonschema.Validate(gojsonschema.NewGoLoader(node), gojsonschema.NewGoLoader(v))
+	if err != nil {
+		return fmt.Errorf("schema validation error: %w", err)
+	}
+	if !res.Valid() {
+		return fmt.Errorf("%s: %s", path, res.Errors()[0].Description())
 	}
 	return nil
 }
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	_ "embed"
+	"fmt"
+)
+
+// CurrentContextVersion is the schema_version of the `.ctx` files this
+// release writes. Files without schema_version are version 1.
+//
+// Version 2 requires schema_version and rejects unknown guardrails and
+// memory keys, which version 1 ignored: the memory.type, memory.vector_store,
+// memory.embedding_model and guardrails.similarity_threshold keys that older
+// generators wrote had no effect. MigrateContext upgrades older files.
+const CurrentContextVersion = 2
+
+var (
+	//go:embed context_schema_v1.json
+	contextSchemaV1 []byte
+	//go:embed context_schema_v2.json
+	contextSchemaV2 []byte
+)
+
+// contextSchemas are the JSON Schemas of each context schema version.
+var contextSchemas = map[int][]byte{
+	1: contextSchemaV1,
+	2: contextSchemaV2,
+}
+
+// ContextVersion returns the schema_version a decoded context declares, 1
+// when it has none. Versions newer than this release supports are errors.
+func ContextVersion(m map[string]interface{}) (int, error) {
+	v, ok := m["schema_version"]
+	if !ok || v == nil {
+		return 1, nil
+	}
+	n, ok := v.(int)
+	if !ok {
+		if f, isFloat := v.(float64); isFloat && f == float64(int(f)) {
+			n, ok = int(f), true
+		}
+	}
+	if !ok || n < 1 {
+		return 0, fmt.Errorf("schema_version must be a positive integer, got %v", v)
+	}
+	if n > CurrentContextVersion {
+		return 0, fmt.Errorf("context schema_version %d is newer than this ctx supports (%d): upgrade ctx", n, CurrentContextVersion)
+	}
+	return n, nil
+}
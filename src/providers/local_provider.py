@@ -40,6 +40,7 @@ class LocalAIProvider:
         
         self._model = None
         self._tokenizer = None
+        self.last_usage: Optional[Dict[str, int]] = None
         
     def _load_model(self):
         """Load the Phi-3.5-Mini model."""
@@ -127,6 +128,13 @@ class LocalAIProvider:
                     **generation_config,
                 )
             
+            # Token usage measured with the model tokenizer
+            prompt_tokens = int(inputs["input_ids"].shape[-1])
+            self.last_usage = {
+                "prompt_tokens": prompt_tokens,
+                "completion_tokens": max(int(outputs[0].shape[-1]) - prompt_tokens, 0),
+            }
+
             # Decode the generated tokens
             generated_text = self._tokenizer.decode(outputs[0], skip_special_tokens=True)
             
@@ -244,7 +252,7 @@ def create_local_embeddings_provider(config: Dict[str, Any]) -> LocalEmbeddingsP
 
 # Minimal CLI runner: read JSON from stdin and write JSON to stdout
 # Input: {"prompt": "...", "params": {"MaxNewTokens": 256, ...}}
-# Output: {"output": "..."}
+# Output: {"output": "...", "usage": {"prompt_tokens": N, "completion_tokens": M}}
 
 def _main():
     try:
@@ -263,9 +271,20 @@ def _main():
             "max_tokens": int(os.getenv("CMP_LOCAL_MAX_TOKENS", str(max_new_tokens))),
             "model_cache": {"directory": os.getenv("CMP_MODEL_CACHE_DIR", "./data/models")},
         }
+        # Sampling parameters from the Go Params struct; zero means the default
+        gen_kwargs: Dict[str, Any] = {"max_new_tokens": max_new_tokens}
+        if float(params.get("Temperature") or 0) > 0:
+            gen_kwargs["temperature"] = float(params["Temperature"])
+        if float(params.get("TopP") or 0) > 0:
+            gen_kwargs["top_p"] = float(params["TopP"])
+        if float(params.get("RepetitionPen") or 0) > 0:
+            gen_kwargs["repetition_penalty"] = float(params["RepetitionPen"])
         provider = LocalAIProvider(config)
-        output = provider.generate(prompt, max_new_tokens=max_new_tokens)
-        print(json.dumps({"output": output}))
+        output = provider.generate(prompt, **gen_kwargs)
+        result: Dict[str, Any] = {"output": output}
+        if provider.last_usage:
+            result["usage"] = provider.last_usage
+        print(json.dumps(result))
     except Exception as e:
         print(json.dumps({"error": str(e)}))
         sys.exit(1)
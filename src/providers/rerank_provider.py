@@ -0,0 +1,36 @@
+#!/usr/bin/env python3
+"""
+Cross-encoder reranker for Contexis memory search.
+
+Reads {"query": "...", "documents": ["..."], "model": "..."} from stdin and
+writes {"scores": [...]} (one relevance score per document) to stdout.
+"""
+
+import json
+import os
+import sys
+
+
+def _main():
+    try:
+        raw = sys.stdin.read()
+        data = json.loads(raw) if raw else {}
+        query = data.get("query", "")
+        documents = data.get("documents", [])
+        model_name = data.get("model") or "cross-encoder/ms-marco-MiniLM-L-6-v2"
+
+        from sentence_transformers import CrossEncoder
+
+        model = CrossEncoder(
+            model_name,
+            device=os.getenv("CMP_LOCAL_DEVICE", None) if os.getenv("CMP_LOCAL_DEVICE", "auto") != "auto" else None,
+        )
+        scores = model.predict([(query, doc) for doc in documents]) if documents else []
+        print(json.dumps({"scores": [float(s) for s in scores]}))
+    except Exception as e:
+        print(json.dumps({"error": str(e)}))
+        sys.exit(1)
+
+
+if __name__ == "__main__":
+    _main()
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultMaxIterations bounds a Loop whose MaxIterations is unset.
+const DefaultMaxIterations = 5
+
+// Steps counts executed tool calls by tool and result (ok, error or denied).
+var Steps = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_agent_steps_total",
+	Help: "Tool calls executed by agent loops, by tool and result.",
+}, []string{"tool", "result"})
+
+// Step is one tool call of an agent run and its observation.
+type Step struct {
+	Iteration int `json:"iteration"`
+	// Thought is text the model wrote alongside its tool calls.
+	Thought     string                 `json:"thought,omitempty"`
+	Tool        string                 `json:"tool"`
+	Arguments   map[string]interface{} `json:"arguments,omitempty"`
+	Observation string                 `json:"observation,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	// Denied reports that the tool's policy did not allow the call.
+	Denied     bool  `json:"denied,omitempty"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Result is the outcome of a Loop run.
+type Result struct {
+	Answer     string
+	Steps      []Step
+	Iterations int
+	// Exhausted reports that the iteration limit was reached and the model was
+	// asked to answer without further tool calls.
+	Exhausted bool
+}
+
+// Loop iterates model turns and tool calls until the model answers.
+type Loop struct {
+	Provider runtimemodel.Provider
+	Tools    *Toolbox
+	// MaxIterations is the number of model turns that may call tools.
+	MaxIterations int
+	// Params are passed to every model turn; tools are set by the loop.
+	Params runtimemodel.Params
+	// Authorize, when set, is called before each tool call. A call it returns
+	// an error for is not executed and the error is shown as its observation;
+	// errors wrapping ErrDenied count as denied.
+	Authorize func(ctx context.Context, call runtimemodel.ToolCall) error
+	// Observe, when set, is called with each observation before the model
+	// sees it and returns the text to show instead, e.g. to withhold output
+	// that tries to steer the model.
+	Observe func(ctx context.Context, call runtimemodel.ToolCall, observation string) string
+	// OnStep is called after each tool call, e.g. to audit or stream it.
+	OnStep func(ctx context.Context, step Step)
+}
+
+// Run starts from prompt and returns the model's final answer. Tool failures
+// are shown to the model as observations rather than ending the run; model
+// errors are returned with the steps taken so far.
+func (l *Loop) Run(ctx context.Context, prompt string) (Result, error) {
+	var res Result
+	max := l.MaxIterations
+	if max <= 0 {
+		max = DefaultMaxIterations
+	}
+	params := l.Params
+	params.Tools, params.ToolChoice = l.Tools.Specs(), runtimemodel.ToolChoiceAuto
+	var transcript strings.Builder
+	transcript.WriteString(prompt)
+	for res.Iterations < max {
+		res.Iterations++
+		c, err := runtimemodel.Complete(ctx, l.Provider, transcript.String(), params)
+		if err != nil && !errors.Is(err, runtimemodel.ErrInvalidToolCall) {
+			return res, err
+		}
+		if len(c.ToolCalls) == 0 {
+			res.Answer = c.Text
+			return res, nil
+		}
+		for i, call := range c.ToolCalls {
+			step := Step{Iteration: res.Iterations, Tool: call.Name, Arguments: call.Arguments}
+			if i == 0 {
+				step.Thought = strings.TrimSpace(c.Text)
+			}
+			var obs string
+			var callErr error
+			if l.Authorize != nil {
+				callErr = l.Authorize(ctx, call)
+			}
+			start := time.Now()
+			if callErr == nil {
+				obs, callErr = l.Tools.call(ctx, call)
+			}
+			step.DurationMS = time.Since(start).Milliseconds()
+			if l.Observe != nil && obs != "" {
+				obs = l.Observe(ctx, call, obs)
+			}
+			step.Observation = obs
+			result := "ok"
+			if callErr != nil {
+				step.Error, result = callErr.Error(), "error"
+				if errors.Is(callErr, ErrDenied) {
+					step.Denied, result = true, "denied"
+				}
+			}
+			Steps.WithLabelValues(call.Name, result).Inc()
+			res.Steps = append(res.Steps, step)
+			if l.OnStep != nil {
+				l.OnStep(ctx, step)
+			}
+			writeObservation(&transcript, step)
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+		}
+	}
+	res.Exhausted = true
+	transcript.WriteString("\n\nNo more tool calls are available. Answer now from the observations above.\n")
+	params.ToolChoice = runtimemodel.ToolChoiceNone
+	c, err := runtimemodel.Complete(ctx, l.Provider, transcript.String(), params)
+	if err != nil {
+		return res, err
+	}
+	res.Answer = c.Text
+	return res, nil
+}
+
+// writeObservation appends a tool call and its result to the transcript.
+func writeObservation(sb *strings.Builder, step Step) {
+	args, _ := json.Marshal(step.Arguments)
+	fmt.Fprintf(sb, "\n\nTool call %d: %s %s\n", step.Iteration, step.Tool, args)
+	if step.Error != "" {
+		fmt.Fprintf(sb, "Error: %s\n", step.Error)
+	}
+	if step.Observation != "" {
+		fmt.Fprintf(sb, "Observation: %s\n", step.Observation)
+	}
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+// scripted answers with its outputs in order, repeating the last one.
+type scripted struct {
+	outs    []string
+	prompts []string
+}
+
+func (p *scripted) Generate(_ context.Context, prompt string, _ runtimemodel.Params) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	out := p.outs[0]
+	if len(p.outs) > 1 {
+		p.outs = p.outs[1:]
+	}
+	return out, nil
+}
+
+func echoTools() *Toolbox {
+	tools := NewToolbox()
+	tools.Add(runtimemodel.Tool{Name: "lookup"}, func(_ context.Context, args map[string]interface{}) (string, error) {
+		return "order " + args["id"].(string) + " shipped", nil
+	})
+	return tools
+}
+
+func TestLoop_ToolCallThenAnswer(t *testing.T) {
+	p := &scripted{outs: []string{
+		`{"tool_calls": [{"name": "lookup", "arguments": {"id": "A1"}}, {"name": "refund", "arguments": {}}]}`,
+		`{"content": "Your order A1 has shipped."}`,
+	}}
+	var seen []Step
+	loop := &Loop{Provider: p, Tools: echoTools(), OnStep: func(_ context.Context, s Step) { seen = append(seen, s) }}
+	res, err := loop.Run(context.Background(), "Where is order A1?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Answer != "Your order A1 has shipped." || res.Iterations != 2 || res.Exhausted {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(res.Steps) != 2 || len(seen) != 2 || res.Steps[0].Observation != "order A1 shipped" || res.Steps[1].Error != `unknown tool "refund"` {
+		t.Fatalf("unexpected steps %+v", res.Steps)
+	}
+	last := p.prompts[1]
+	if !strings.Contains(last, `Tool call 1: lookup {"id":"A1"}`+"\nObservation: order A1 shipped") || !strings.Contains(last, `Error: unknown tool "refund"`) {
+		t.Fatalf("observations missing from the transcript:\n%s", last)
+	}
+}
+
+func TestLoop_MaxIterationsForcesAnswer(t *testing.T) {
+	p := &scripted{outs: []string{
+		`{"tool_calls": [{"name": "lookup", "arguments": {"id": "A1"}}]}`,
+		`{"tool_calls": [{"name": "lookup", "arguments": {"id": "A1"}}]}`,
+		"It has shipped.",
+	}}
+	res, err := (&Loop{Provider: p, Tools: echoTools(), MaxIterations: 2}).Run(context.Background(), "Where is order A1?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Exhausted || len(res.Steps) != 2 || res.Answer != "It has shipped." {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if last := p.prompts[len(p.prompts)-1]; !strings.Contains(last, "No more tool calls") || strings.Contains(last, "You can call these tools") {
+		t.Fatalf("final turn should offer no tools:\n%s", last)
+	}
+}
+
+func TestLoop_AuthorizeDeniesCall(t *testing.T) {
+	p := &scripted{outs: []string{
+		`{"tool_calls": [{"name": "lookup", "arguments": {"id": "A1"}}]}`,
+		`{"content": "I cannot look that up."}`,
+	}}
+	executed := false
+	tools := NewToolbox()
+	tools.Add(runtimemodel.Tool{Name: "lookup"}, func(context.Context, map[string]interface{}) (string, error) {
+		executed = true
+		return "order A1 shipped", nil
+	})
+	loop := &Loop{Provider: p, Tools: tools, Authorize: func(_ context.Context, call runtimemodel.ToolCall) error {
+		return fmt.Errorf("%w: %s needs an approval", ErrDenied, call.Name)
+	}}
+	res, err := loop.Run(context.Background(), "Where is order A1?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed || len(res.Steps) != 1 || !res.Steps[0].Denied || res.Steps[0].Observation != "" {
+		t.Fatalf("expected the call to be denied without running, got %+v", res.Steps)
+	}
+	if !strings.Contains(p.prompts[1], "Error: denied by tool policy: lookup needs an approval") {
+		t.Fatalf("the denial was not shown to the model:\n%s", p.prompts[1])
+	}
+}
+
+func TestLoop_ObserveReplacesObservation(t *testing.T) {
+	p := &scripted{outs: []string{
+		`{"tool_calls": [{"name": "lookup", "arguments": {"id": "A1"}}]}`,
+		`{"content": "No answer."}`,
+	}}
+	loop := &Loop{Provider: p, Tools: echoTools(), Observe: func(_ context.Context, call runtimemodel.ToolCall, obs string) string {
+		return "[withheld " + call.Name + ": " + obs + "]"
+	}}
+	res, err := loop.Run(context.Background(), "Where is order A1?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Steps[0].Observation != "[withheld lookup: order A1 shipped]" || !strings.Contains(p.prompts[1], "Observation: [withheld lookup: order A1 shipped]") {
+		t.Fatalf("expected the replaced observation, got %+v\n%s", res.Steps, p.prompts[1])
+	}
+}
+
+func TestAddContextTools_HTTP(t *testing.T) {
+	var got struct {
+		method, path, query, header string
+		body                        map[string]interface{}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path, got.query, got.header = r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Locale")
+		by, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(by, &got.body)
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status":"updated"}`))
+	}))
+	defer srv.Close()
+	tools := NewToolbox()
+	skipped := tools.AddContextTools([]corectx.Tool{
+		{Name: "update_order", URI: srv.URL + "/orders/{id}", Method: "post", Parameters: []corectx.ToolParameter{
+			{Name: "id", In: "path"},
+			{Name: "notify", In: "query", Type: "boolean"},
+			{Name: "X-Locale", In: "header"},
+			{Name: "body", In: "body", Required: true},
+		}},
+		{Name: "semantic_search", URI: "mcp://search.semantic"},
+	}, "", srv.Client())
+	if len(skipped) != 1 || skipped[0] != "semantic_search" || tools.Len() != 1 {
+		t.Fatalf("expected only the HTTP tool, skipped %v", skipped)
+	}
+	if req := tools.Specs()[0].Parameters["required"].([]interface{}); len(req) != 2 {
+		t.Fatalf("path and required parameters should be required: %v", req)
+	}
+	out, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "update_order", Arguments: map[string]interface{}{
+		"id": "A 1", "notify": true, "X-Locale": "de", "body": map[string]interface{}{"status": "cancelled"},
+	}})
+	if err != nil || out != `{"status":"updated"}` {
+		t.Fatalf("call = %q, %v", out, err)
+	}
+	if got.method != http.MethodPost || got.path != "/orders/A 1" || got.query != "notify=true" || got.header != "de" || got.body["status"] != "cancelled" {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "update_order", Arguments: map[string]interface{}{"id": "A1"}}); err == nil || !strings.Contains(err.Error(), `missing argument "body"`) {
+		t.Fatalf("expected a missing argument error, got %v", err)
+	}
+	out, err = tools.call(context.Background(), runtimemodel.ToolCall{Name: "update_order", Arguments: map[string]interface{}{"id": "missing", "body": map[string]interface{}{}}})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") || !strings.Contains(out, "not found") {
+		t.Fatalf("expected the error response as observation, got %q, %v", out, err)
+	}
+}
+
+func TestAddContextTools_Schema(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"status":"shipped"}`))
+	}))
+	defer srv.Close()
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"order_id": map[string]interface{}{"type": "string"}, "limit": map[string]interface{}{"type": "integer"}},
+		"required":   []interface{}{"order_id"},
+	}
+	tools := NewToolbox()
+	tools.AddContextTools([]corectx.Tool{{Name: "order_lookup", URI: srv.URL + "/", Method: "POST", Schema: schema}}, "", srv.Client())
+	if props := tools.Specs()[0].Parameters["properties"].(map[string]interface{}); len(props) != 2 {
+		t.Fatalf("expected the tool's schema, got %v", tools.Specs()[0].Parameters)
+	}
+	out, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "order_lookup", Arguments: map[string]interface{}{"order_id": "A1", "limit": 2}})
+	if err != nil || out != `{"status":"shipped"}` {
+		t.Fatalf("call = %q, %v", out, err)
+	}
+	if body["order_id"] != "A1" || body["limit"] != float64(2) {
+		t.Fatalf("expected the arguments as the JSON body, got %v", body)
+	}
+}
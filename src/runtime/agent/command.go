@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+// maxStderrBytes caps the stderr of a failed command tool in its error.
+const maxStderrBytes = 2 << 10
+
+// commandHandler runs a command tool: a subprocess started from root for
+// every call, with the arguments as a JSON object on stdin, stdout as the
+// observation, the environment of its policy and its resource limits. The
+// process group is killed when the call times out.
+func commandHandler(t corectx.Tool, root string, p policy) Handler {
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		if err := p.checkPaths(root, args); err != nil {
+			return "", err
+		}
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		in, err := json.Marshal(args)
+		if err != nil {
+			return "", fmt.Errorf("encode arguments: %w", err)
+		}
+		cmd, err := limitedCommand(ctx, t.Command, p)
+		if err != nil {
+			return "", err
+		}
+		stdout, stderr := &cappedBuffer{max: MaxObservationBytes + 1}, &cappedBuffer{max: maxStderrBytes}
+		cmd.Dir, cmd.Env = root, p.environ(t.Name)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(in), stdout, stderr
+		cmd.WaitDelay = time.Second
+		err = cmd.Run()
+		if ctx.Err() != nil {
+			return stdout.String(), ctx.Err()
+		}
+		if err != nil {
+			var exit *exec.ExitError
+			if msg := strings.TrimSpace(stderr.String()); errors.As(err, &exit) && msg != "" {
+				return stdout.String(), fmt.Errorf("%s: %v: %s", t.Name, err, msg)
+			}
+			return stdout.String(), fmt.Errorf("%s: %w", t.Name, err)
+		}
+		return stdout.String(), nil
+	}
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest,
+// so a chatty tool cannot grow the runtime's memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
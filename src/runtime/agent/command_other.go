@@ -0,0 +1,19 @@
+//go:build !unix
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// limitedCommand returns the command of argv. Resource limits need ulimit,
+// so tools that set them are denied on this platform.
+func limitedCommand(ctx context.Context, argv []string, p policy) (*exec.Cmd, error) {
+	if p.maxMemoryMB > 0 || p.maxCPUSeconds > 0 {
+		return nil, fmt.Errorf("%w: resource limits are not supported on %s", ErrDenied, runtime.GOOS)
+	}
+	return exec.CommandContext(ctx, argv[0], argv[1:]...), nil
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package agent
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// limitedCommand returns the command of argv. Resource limits are set by a
+// shell that applies them with ulimit and then execs the tool, and the tool
+// gets its own process group so that a timeout kills its children too.
+func limitedCommand(ctx context.Context, argv []string, p policy) (*exec.Cmd, error) {
+	var limits string
+	if p.maxMemoryMB > 0 {
+		limits += "ulimit -v " + strconv.Itoa(p.maxMemoryMB*1024) + " && "
+	}
+	if p.maxCPUSeconds > 0 {
+		limits += "ulimit -t " + strconv.Itoa(p.maxCPUSeconds) + " && "
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if limits != "" {
+		cmd = exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", limits + `exec "$@"`, "sh"}, argv...)...)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	return cmd, nil
+}
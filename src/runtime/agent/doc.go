@@ -0,0 +1,9 @@
+// Package agent runs tool-using agent loops.
+//
+// A Loop gives the model the tools of a Toolbox and iterates: the model
+// proposes tool calls, the toolbox executes them, and each observation is
+// appended to the transcript for the next turn, until the model answers or
+// the iteration limit forces an answer. Every executed call is reported as a
+// Step, which callers audit and return as a trace. Toolboxes are built from
+// the HTTP tools of a context and from handlers registered by the runtime.
+package agent
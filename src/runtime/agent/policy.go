@@ -0,0 +1,195 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+// DefaultToolTimeout bounds a tool call whose policy sets no timeout.
+const DefaultToolTimeout = 30 * time.Second
+
+// ErrDenied is returned for tool calls outside the tool's policy.
+var ErrDenied = errors.New("denied by tool policy")
+
+// policy is the effective execution policy of a context tool: what its
+// corectx.ToolPolicy allows, and nothing else.
+type policy struct {
+	domains []string
+	methods []string
+	paths   []string // absolute
+	env     []string
+	timeout time.Duration
+	// maxMemoryMB and maxCPUSeconds limit command tools; 0 is unlimited.
+	maxMemoryMB   int
+	maxCPUSeconds int
+}
+
+// toolPolicy derives the policy of t. HTTP and webhook tools without domains
+// or methods may only call their own host with their declared method; paths
+// resolve against root.
+func toolPolicy(t corectx.Tool, root string) policy {
+	p := policy{timeout: DefaultToolTimeout}
+	if tp := t.Policy; tp != nil {
+		p.domains = append(p.domains, tp.Domains...)
+		p.env = append(p.env, tp.Env...)
+		for _, m := range tp.Methods {
+			p.methods = append(p.methods, strings.ToUpper(m))
+		}
+		for _, path := range tp.Paths {
+			p.paths = append(p.paths, resolvePath(root, path))
+		}
+		if tp.TimeoutSeconds > 0 {
+			p.timeout = time.Duration(tp.TimeoutSeconds) * time.Second
+		}
+		p.maxMemoryMB, p.maxCPUSeconds = tp.MaxMemoryMB, tp.MaxCPUSeconds
+	}
+	if len(t.Command) > 0 {
+		return p
+	}
+	uri, method := t.URI, strings.ToUpper(t.Method)
+	if method == "" {
+		method = "GET"
+	}
+	if w := t.Webhook; w != nil {
+		uri, method = w.URL, strings.ToUpper(w.Method)
+		if method == "" {
+			method = "POST"
+		}
+	}
+	if len(p.domains) == 0 {
+		if u, err := url.Parse(uri); err == nil && u.Host != "" {
+			p.domains = []string{u.Host}
+		}
+	}
+	if len(p.methods) == 0 {
+		p.methods = []string{method}
+	}
+	return p
+}
+
+// checkRequest denies requests to hosts or with methods the policy does not list.
+func (p policy) checkRequest(method string, u *url.URL) error {
+	if !p.allowsMethod(method) {
+		return fmt.Errorf("%w: method %s is not allowed", ErrDenied, method)
+	}
+	if !p.allowsHost(u) {
+		return fmt.Errorf("%w: host %s is not allowed", ErrDenied, u.Host)
+	}
+	return nil
+}
+
+func (p policy) allowsMethod(method string) bool {
+	for _, m := range p.methods {
+		if m == strings.ToUpper(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHost matches u against the domains: an entry with a port matches that
+// host and port, one without any port, and *.example.com any subdomain.
+func (p policy) allowsHost(u *url.URL) bool {
+	for _, d := range p.domains {
+		d = strings.ToLower(d)
+		host := strings.ToLower(u.Hostname())
+		if strings.Contains(strings.TrimPrefix(d, "*."), ":") {
+			host = strings.ToLower(u.Host)
+		}
+		if host == d || (strings.HasPrefix(d, "*.") && strings.HasSuffix(host, d[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPaths denies path arguments (path, file, dir and *_path, *_file,
+// *_dir) outside the allowed paths.
+func (p policy) checkPaths(root string, args map[string]interface{}) error {
+	for k, v := range args {
+		if !isPathArgument(k) {
+			continue
+		}
+		var values []interface{}
+		switch x := v.(type) {
+		case []interface{}:
+			values = x
+		default:
+			values = []interface{}{x}
+		}
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%w: %s must be a path", ErrDenied, k)
+			}
+			if !p.allowsPath(resolvePath(root, s)) {
+				return fmt.Errorf("%w: path %s is not allowed", ErrDenied, s)
+			}
+		}
+	}
+	return nil
+}
+
+func isPathArgument(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"path", "file", "dir", "directory"} {
+		if name == s || strings.HasSuffix(name, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p policy) allowsPath(path string) bool {
+	for _, allowed := range p.paths {
+		if rel, err := filepath.Rel(allowed, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath returns the absolute, symlink-free form of path relative to
+// root. Symlinks are resolved for the longest existing prefix, so a path
+// that does not exist yet cannot escape through a linked directory.
+func resolvePath(root, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path, _ = filepath.Abs(path)
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// environ is the environment of a command tool: the basics a process needs,
+// the variables its policy lists and the policy itself, for the tool to
+// enforce on its own requests.
+func (p policy) environ(name string) []string {
+	var env []string
+	for _, k := range append([]string{"PATH", "HOME", "LANG", "TMPDIR", "SYSTEMROOT"}, p.env...) {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return append(env,
+		"CMP_TOOL_NAME="+name,
+		"CMP_TOOL_ALLOWED_PATHS="+strings.Join(p.paths, string(os.PathListSeparator)),
+		"CMP_TOOL_ALLOWED_DOMAINS="+strings.Join(p.domains, ","),
+		"CMP_TOOL_ALLOWED_METHODS="+strings.Join(p.methods, ","),
+	)
+}
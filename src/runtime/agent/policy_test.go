@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+func TestPolicy_Hosts(t *testing.T) {
+	p := toolPolicy(corectx.Tool{URI: "https://api.example.com/v1", Policy: &corectx.ToolPolicy{Domains: []string{"*.example.com", "localhost:8790"}}}, "")
+	for host, want := range map[string]bool{
+		"api.example.com": true, "a.b.example.com": true, "example.com": false, "evilexample.com": false,
+		"localhost:8790": true, "localhost:8791": false,
+	} {
+		if got := p.allowsHost(&url.URL{Host: host}); got != want {
+			t.Errorf("allowsHost(%s) = %v, want %v", host, got, want)
+		}
+	}
+	if p := toolPolicy(corectx.Tool{URI: "http://127.0.0.1:8790/"}, ""); p.allowsHost(&url.URL{Host: "127.0.0.1:9000"}) || !p.allowsMethod("get") || p.allowsMethod("POST") {
+		t.Fatalf("derived policy should allow only the tool's host and method: %+v", p)
+	}
+}
+
+func TestHTTPTool_Policy(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("other")) }))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer srv.Close()
+	tools := NewToolbox()
+	tools.AddContextTools([]corectx.Tool{
+		{Name: "fetch", URI: srv.URL + "/"},
+		{Name: "update", URI: srv.URL + "/", Method: "POST", Policy: &corectx.ToolPolicy{Methods: []string{"GET"}}},
+	}, "", srv.Client())
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "fetch"}); !errors.Is(err, ErrDenied) || !strings.Contains(err.Error(), "redirect denied") {
+		t.Fatalf("expected the redirect to another host to be denied, got %v", err)
+	}
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "update"}); !errors.Is(err, ErrDenied) || !strings.Contains(err.Error(), "method POST") {
+		t.Fatalf("expected POST to be denied, got %v", err)
+	}
+}
+
+func TestCommandTool_Policy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("command tools run with sh in this test")
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(os.TempDir(), filepath.Join(root, "data", "tmp")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOOL_TOKEN", "t0k")
+	t.Setenv("OTHER_SECRET", "s3cret")
+	tools := NewToolbox()
+	tools.AddContextTools([]corectx.Tool{
+		{Name: "echo", Command: []string{"sh", "-c", `cat; echo " $TOOL_TOKEN$OTHER_SECRET $CMP_TOOL_NAME"; pwd`},
+			Policy: &corectx.ToolPolicy{Paths: []string{"data"}, Env: []string{"TOOL_TOKEN"}}},
+		{Name: "spin", Command: []string{"sh", "-c", "while :; do :; done"}, Policy: &corectx.ToolPolicy{MaxCPUSeconds: 1}},
+		{Name: "sleep", Command: []string{"sleep", "5"}},
+	}, root, nil)
+
+	out, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "echo", Arguments: map[string]interface{}{"path": "data/notes.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	realRoot, _ := filepath.EvalSymlinks(root)
+	if want := `{"path":"data/notes.txt"} t0k echo` + "\n" + realRoot + "\n"; out != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+	for _, path := range []interface{}{"../etc/passwd", "/etc/passwd", "data/tmp/x", []interface{}{"data/a", "main.go"}} {
+		if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "echo", Arguments: map[string]interface{}{"file_path": path}}); !errors.Is(err, ErrDenied) {
+			t.Fatalf("expected %v to be denied, got %v", path, err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "spin"}); err == nil || time.Since(start) > 10*time.Second {
+		t.Fatalf("expected the CPU limit to stop the tool, got %v after %s", err, time.Since(start))
+	}
+	tools.timeouts["sleep"] = 100 * time.Millisecond
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "sleep"}); err == nil || err.Error() != "sleep timed out after 100ms" || time.Since(start) > 15*time.Second {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestLoop_DeniedStep(t *testing.T) {
+	tools := NewToolbox()
+	tools.AddContextTools([]corectx.Tool{{Name: "read", Command: []string{"cat"}}}, t.TempDir(), nil)
+	p := &scripted{outs: []string{`{"tool_calls": [{"name": "read", "arguments": {"path": "/etc/passwd"}}]}`, `{"content": "I cannot read that file."}`}}
+	res, err := (&Loop{Provider: p, Tools: tools}).Run(context.Background(), "Show /etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Steps) != 1 || !res.Steps[0].Denied || !strings.HasPrefix(res.Steps[0].Error, "denied by tool policy") {
+		t.Fatalf("expected a denied step, got %+v", res.Steps)
+	}
+}
@@ -0,0 +1,542 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+// Database tool defaults, for settings a tool's database leaves unset.
+const (
+	DefaultSQLMaxRows    = 100
+	DefaultSQLMaxColumns = 20
+)
+
+const (
+	// maxSchemaBytes caps the schema shown in a database tool's description.
+	maxSchemaBytes = 4 << 10
+	// schemaTTL is how long an introspected schema is reused.
+	schemaTTL = 5 * time.Minute
+	// schemaTimeout bounds the introspection of a schema.
+	schemaTimeout = 10 * time.Second
+)
+
+// readOnlyVerbs are the statements a read-only database tool runs. The
+// clients also open read-only connections, which catches writes hidden in
+// e.g. a WITH clause.
+var readOnlyVerbs = map[string]bool{"SELECT": true, "WITH": true, "EXPLAIN": true, "VALUES": true, "SHOW": true, "DESCRIBE": true, "DESC": true, "TABLE": true}
+
+// blockedSQLFunctions are functions that reach outside the database: the
+// sqlite3 shell's file functions, extension loading and the FTS3 tokenizer
+// pointer. The sqlite3 client also runs with -safe, which refuses them.
+var blockedSQLFunctions = map[string]bool{"WRITEFILE": true, "READFILE": true, "EDIT": true, "LOAD_EXTENSION": true, "FTS3_TOKENIZER": true}
+
+// sqlFuncCall matches a name followed by an opening parenthesis.
+var sqlFuncCall = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_$]*)\s*\(`)
+
+// sqlParamsSchema is the argument schema of database tools.
+var sqlParamsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"query":  map[string]interface{}{"type": "string", "description": "One SQL statement; write every value as a ? placeholder, string literals are rejected"},
+		"params": map[string]interface{}{"type": "array", "description": "Values of the ? placeholders, in order"},
+	},
+	"required": []interface{}{"query"},
+}
+
+// sqlTool queries a database through its command line client: sqlite3, psql
+// or mysql. Values are bound by the client rather than spliced into the SQL:
+// sqlite3 binds them from its parameter table, psql quotes them as
+// variables and mysql reads them from user variables.
+type sqlTool struct {
+	name     string
+	driver   string
+	dsn      string
+	readOnly bool
+	maxRows  int
+	maxCols  int
+	root     string
+	policy   policy
+}
+
+func newSQLTool(t corectx.Tool, root string, p policy) *sqlTool {
+	db := t.Database
+	s := &sqlTool{name: t.Name, driver: strings.ToLower(db.Driver), dsn: db.DSN, readOnly: true, maxRows: DefaultSQLMaxRows, maxCols: DefaultSQLMaxColumns, root: root, policy: p}
+	if db.ReadOnly != nil {
+		s.readOnly = *db.ReadOnly
+	}
+	if db.MaxRows > 0 {
+		s.maxRows = db.MaxRows
+	}
+	if db.MaxColumns > 0 {
+		s.maxCols = db.MaxColumns
+	}
+	return s
+}
+
+// sqlResult is the observation of a query.
+type sqlResult struct {
+	Columns   []string   `json:"columns"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// handler runs the query argument with its params.
+func (s *sqlTool) handler() Handler {
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		query, _ := args["query"].(string)
+		if strings.TrimSpace(query) == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		var params []interface{}
+		switch v := args["params"].(type) {
+		case nil:
+		case []interface{}:
+			params = v
+		default:
+			return "", fmt.Errorf("params must be a list")
+		}
+		stmt, err := parseStatement(query, s.driver)
+		if err != nil {
+			return "", err
+		}
+		if s.readOnly && !readOnlyVerbs[stmt.verb] {
+			return "", fmt.Errorf("%w: %s is read-only; %s statements are not allowed", ErrDenied, s.name, stmt.verb)
+		}
+		if len(params) != stmt.placeholders {
+			return "", fmt.Errorf("query has %d placeholders but %d params", stmt.placeholders, len(params))
+		}
+		res, err := s.run(ctx, stmt, params)
+		if err != nil {
+			return "", err
+		}
+		by, _ := json.Marshal(res)
+		return string(by), nil
+	}
+}
+
+// statement is a checked SQL statement: one line, no comments, literals or
+// client commands, with its ? placeholders located.
+type statement struct {
+	parts        []string // text around the placeholders
+	placeholders int
+	verb         string
+}
+
+// parseStatement checks query and locates its placeholders. Anything the
+// clients could read as more than one statement or as a client command
+// (sqlite3 dot commands, psql and mysql backslash commands) is refused, as
+// are string literals, which must be passed as params, and calls to
+// blockedSQLFunctions.
+func parseStatement(query, driver string) (statement, error) {
+	var st statement
+	var cur strings.Builder
+	r := []rune(strings.TrimSpace(query))
+	ended := false
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		if ended && !unicode.IsSpace(c) {
+			return st, fmt.Errorf("%w: only one statement may be run", ErrDenied)
+		}
+		switch {
+		case c == '\'' || (c == '"' && driver == "mysql"):
+			return st, fmt.Errorf("%w: string literals are not allowed; use ? placeholders and params", ErrDenied)
+		case c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(r) && r[j] != c; j++ {
+			}
+			if j == len(r) {
+				return st, fmt.Errorf("%w: unterminated identifier", ErrDenied)
+			}
+			if strings.ContainsAny(string(r[i:j]), "\r\n\x00") {
+				return st, fmt.Errorf("%w: identifiers must be on one line", ErrDenied)
+			}
+			cur.WriteString(string(r[i : j+1]))
+			i = j
+		case c == '-' && i+1 < len(r) && r[i+1] == '-', c == '/' && i+1 < len(r) && r[i+1] == '*', c == '#' && driver == "mysql":
+			return st, fmt.Errorf("%w: comments are not allowed", ErrDenied)
+		case c == '\\':
+			return st, fmt.Errorf("%w: backslashes are not allowed", ErrDenied)
+		case c == '$' && driver == "postgres":
+			return st, fmt.Errorf("%w: use ? placeholders rather than $n or dollar quoting", ErrDenied)
+		case c == ';':
+			ended = true
+		case c == '?':
+			st.parts = append(st.parts, cur.String())
+			cur.Reset()
+			st.placeholders++
+		case unicode.IsSpace(c):
+			cur.WriteByte(' ')
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	st.parts = append(st.parts, cur.String())
+	// Quoted names call the same functions, so the quotes are dropped
+	bare := strings.NewReplacer(`"`, " ", "`", " ").Replace(strings.Join(st.parts, "?"))
+	for _, m := range sqlFuncCall.FindAllStringSubmatch(bare, -1) {
+		if name := strings.ToUpper(m[1]); blockedSQLFunctions[name] {
+			return st, fmt.Errorf("%w: %s() is not allowed", ErrDenied, strings.ToLower(name))
+		}
+	}
+	verb := strings.TrimLeft(st.parts[0], " (")
+	if i := strings.IndexFunc(verb, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		verb = verb[:i]
+	}
+	st.verb = strings.ToUpper(verb)
+	if st.verb == "" {
+		return st, fmt.Errorf("%w: a statement must start with a keyword", ErrDenied)
+	}
+	return st, nil
+}
+
+// sqlValue renders a param as an SQL expression that cannot be read as
+// anything but one value: numbers as formatted by Go, text as hex.
+func sqlValue(v interface{}, driver string) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if x {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "", fmt.Errorf("params must be finite numbers")
+		}
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return strconv.FormatInt(int64(x), 10), nil
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case string:
+		h := hex.EncodeToString([]byte(x))
+		if driver == "mysql" {
+			return "CONVERT(X'" + h + "' USING utf8mb4)", nil
+		}
+		return "CAST(X'" + h + "' AS TEXT)", nil
+	}
+	return "", fmt.Errorf("params must be strings, numbers, booleans or null, got %T", v)
+}
+
+// script renders stmt with params bound for the client, and the extra
+// arguments it needs.
+func (s *sqlTool) script(stmt statement, params []interface{}) (string, []string, error) {
+	var sb, sql strings.Builder
+	var args []string
+	if s.driver == "sqlite" && len(params) > 0 {
+		sb.WriteString(".parameter init\n")
+	}
+	for i, p := range params {
+		n := strconv.Itoa(i + 1)
+		sql.WriteString(stmt.parts[i])
+		if s.driver == "postgres" {
+			// psql quotes the variable as a literal
+			var v string
+			switch x := p.(type) {
+			case nil:
+				sql.WriteString("NULL")
+				continue
+			case string:
+				v = x
+			default:
+				lit, err := sqlValue(x, s.driver)
+				if err != nil {
+					return "", nil, err
+				}
+				v = lit
+			}
+			if strings.ContainsRune(v, 0) {
+				return "", nil, fmt.Errorf("params must not contain NUL characters")
+			}
+			args = append(args, "-v", "p"+n+"="+v)
+			sql.WriteString(":'p" + n + "'")
+			continue
+		}
+		lit, err := sqlValue(p, s.driver)
+		if err != nil {
+			return "", nil, err
+		}
+		if s.driver == "sqlite" {
+			fmt.Fprintf(&sb, "INSERT INTO temp.sqlite_parameters(key, value) VALUES('?%s', %s);\n", n, lit)
+			sql.WriteString("?" + n)
+		} else {
+			fmt.Fprintf(&sb, "SET @p%s = %s;\n", n, lit)
+			sql.WriteString("@p" + n)
+		}
+	}
+	sql.WriteString(stmt.parts[len(stmt.parts)-1])
+	sb.WriteString(strings.TrimSpace(sql.String()) + ";\n")
+	return sb.String(), args, nil
+}
+
+// run executes stmt and reads at most maxRows rows of its result.
+func (s *sqlTool) run(ctx context.Context, stmt statement, params []interface{}) (sqlResult, error) {
+	input, extra, err := s.script(stmt, params)
+	if err != nil {
+		return sqlResult{}, err
+	}
+	return s.exec(ctx, input, extra)
+}
+
+// exec runs a script with the client and parses its output. Once maxRows rows
+// are read the client is stopped rather than read to the end.
+func (s *sqlTool) exec(ctx context.Context, input string, extra []string) (sqlResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd, err := s.command(ctx, extra)
+	if err != nil {
+		return sqlResult{}, err
+	}
+	stderr := &cappedBuffer{max: maxStderrBytes}
+	cmd.Stdin, cmd.Stderr = strings.NewReader(input), stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return sqlResult{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return sqlResult{}, fmt.Errorf("%s: %w", s.name, err)
+	}
+	res, readErr := s.readRows(stdout)
+	if res.Truncated {
+		cancel()
+	}
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	if res.Truncated {
+		return res, nil
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return sqlResult{}, fmt.Errorf("%s: %s", s.name, msg)
+		}
+		return sqlResult{}, fmt.Errorf("%s: %w", s.name, err)
+	}
+	if readErr != nil {
+		return sqlResult{}, fmt.Errorf("%s: read result: %w", s.name, readErr)
+	}
+	return res, nil
+}
+
+// readRows parses the header and rows of the client's output: CSV for
+// sqlite3 and psql, tab-separated with backslash escapes for mysql.
+func (s *sqlTool) readRows(r io.Reader) (sqlResult, error) {
+	res := sqlResult{Columns: []string{}, Rows: [][]string{}}
+	header := true
+	next := csvRecords(r)
+	if s.driver == "mysql" {
+		next = tsvRecords(r)
+	}
+	for {
+		rec, err := next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		if len(rec) > s.maxCols {
+			rec, res.Truncated = rec[:s.maxCols], true
+		}
+		if header {
+			res.Columns, header = rec, false
+			continue
+		}
+		if len(res.Rows) == s.maxRows {
+			res.Truncated = true
+			return res, nil
+		}
+		res.Rows = append(res.Rows, rec)
+	}
+}
+
+func csvRecords(r io.Reader) func() ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return cr.Read
+}
+
+func tsvRecords(r io.Reader) func() ([]string, error) {
+	br := bufio.NewReader(r)
+	unescape := strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\0`, "\x00", `\\`, `\`)
+	return func() ([]string, error) {
+		line, err := br.ReadString('\n')
+		if line == "" && err != nil {
+			return nil, err
+		}
+		fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+		for i, f := range fields {
+			fields[i] = unescape.Replace(f)
+		}
+		return fields, nil
+	}
+}
+
+// command returns the client command of the tool's database.
+func (s *sqlTool) command(ctx context.Context, extra []string) (*exec.Cmd, error) {
+	dsn, err := resolveRef(ctx, s.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s dsn: %w", s.name, err)
+	}
+	env := os.Environ()
+	var argv []string
+	switch s.driver {
+	case "sqlite":
+		if !filepath.IsAbs(dsn) {
+			dsn = filepath.Join(s.root, dsn)
+		}
+		argv = []string{sqlBin("sqlite3", "CMP_SQLITE3_BIN"), "-bail", "-batch", "-csv", "-header", "-safe"}
+		if s.readOnly {
+			argv = append(argv, "-readonly")
+		}
+		argv = append(argv, dsn)
+	case "postgres":
+		u, err := url.Parse(dsn)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			return nil, fmt.Errorf("%s: dsn must be a postgres:// URL", s.name)
+		}
+		// The password goes in the environment rather than the visible argv
+		if pw, ok := u.User.Password(); ok {
+			env = append(env, "PGPASSWORD="+pw)
+			u.User = url.User(u.User.Username())
+		}
+		if s.readOnly {
+			env = append(env, "PGOPTIONS=-c default_transaction_read_only=on")
+		}
+		argv = append([]string{sqlBin("psql", "CMP_PSQL_BIN"), "-X", "-q", "--csv", "-v", "ON_ERROR_STOP=1", "-d", u.String(), "-f", "-"}, extra...)
+	case "mysql":
+		u, err := url.Parse(dsn)
+		if err != nil || u.Scheme != "mysql" {
+			return nil, fmt.Errorf("%s: dsn must be a mysql:// URL", s.name)
+		}
+		argv = []string{sqlBin("mysql", "CMP_MYSQL_BIN"), "--no-defaults", "--batch", "--column-names", "-h", u.Hostname()}
+		if port := u.Port(); port != "" {
+			argv = append(argv, "-P", port)
+		}
+		if u.User != nil {
+			argv = append(argv, "-u", u.User.Username())
+			if pw, ok := u.User.Password(); ok {
+				env = append(env, "MYSQL_PWD="+pw)
+			}
+		}
+		if mode := u.Query().Get("ssl-mode"); mode != "" {
+			argv = append(argv, "--ssl-mode="+mode)
+		}
+		if s.readOnly {
+			argv = append(argv, "--init-command=SET SESSION TRANSACTION READ ONLY")
+		}
+		argv = append(argv, strings.TrimPrefix(u.Path, "/"))
+	default:
+		return nil, fmt.Errorf("%s: unsupported database driver %q (sqlite, postgres, mysql)", s.name, s.driver)
+	}
+	cmd, err := limitedCommand(ctx, argv, s.policy)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir, cmd.Env = s.root, env
+	cmd.WaitDelay = time.Second
+	return cmd, nil
+}
+
+// sqlBin returns the client binary: the env override when set, else name.
+func sqlBin(name, env string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	return name
+}
+
+// schemaQueries list the columns of the user tables as table, column, type.
+var schemaQueries = map[string]string{
+	"sqlite": "SELECT m.name, p.name, p.type FROM sqlite_master m JOIN pragma_table_info(m.name) p " +
+		"WHERE m.type IN ('table', 'view') AND m.name NOT LIKE 'sqlite_%' ORDER BY m.name, p.cid;\n",
+	"postgres": "SELECT CASE WHEN table_schema = 'public' THEN table_name ELSE table_schema || '.' || table_name END, column_name, data_type " +
+		"FROM information_schema.columns WHERE table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY table_schema, table_name, ordinal_position;\n",
+	"mysql": "SELECT table_name, column_name, column_type FROM information_schema.columns " +
+		"WHERE table_schema = DATABASE() ORDER BY table_name, ordinal_position;\n",
+}
+
+type cachedSchema struct {
+	text    string
+	expires time.Time
+}
+
+var (
+	schemaMu    sync.Mutex
+	schemaCache = map[string]cachedSchema{}
+)
+
+// describe returns the tool's description with the database schema, one
+// "table(column type, ...)" line per table, so the model can write queries.
+// Schemas are cached for schemaTTL per database.
+func (s *sqlTool) describe(description string) string {
+	key := s.driver + "\x00" + s.root + "\x00" + s.dsn
+	schemaMu.Lock()
+	c, ok := schemaCache[key]
+	schemaMu.Unlock()
+	if !ok || time.Now().After(c.expires) {
+		c = cachedSchema{text: s.introspect(), expires: time.Now().Add(schemaTTL)}
+		schemaMu.Lock()
+		schemaCache[key] = c
+		schemaMu.Unlock()
+	}
+	mode := "read-only "
+	if !s.readOnly {
+		mode = ""
+	}
+	out := fmt.Sprintf("Runs one SQL statement against a %s%s database. Write every value as a ? placeholder and pass the values in params. At most %d rows are returned.\n%s", mode, s.driver, s.maxRows, c.text)
+	if description != "" {
+		out = description + "\n" + out
+	}
+	return out
+}
+
+func (s *sqlTool) introspect() string {
+	ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+	defer cancel()
+	introspection := *s
+	introspection.readOnly, introspection.maxRows, introspection.maxCols = true, 1<<20, 3
+	res, err := introspection.exec(ctx, schemaQueries[s.driver], nil)
+	if err != nil {
+		return "Schema unavailable: " + err.Error()
+	}
+	var tables []string
+	last := ""
+	for _, row := range res.Rows {
+		if len(row) < 3 {
+			continue
+		}
+		col := strings.TrimSpace(row[1] + " " + row[2])
+		if n := len(tables); n > 0 && row[0] == last {
+			tables[n-1] += ", " + col
+		} else {
+			last = row[0]
+			tables = append(tables, last+"("+col)
+		}
+	}
+	if len(tables) == 0 {
+		return "Schema: no tables"
+	}
+	out := "Schema:\n" + strings.Join(tables, ")\n") + ")"
+	if len(out) > maxSchemaBytes {
+		out = strings.ToValidUTF8(out[:maxSchemaBytes], "") + "\n[schema truncated]"
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+func TestParseStatement(t *testing.T) {
+	st, err := parseStatement("select *\n  from \"odd?name\" where id = ? and status in (?, ?);  ", "sqlite")
+	if err != nil || st.verb != "SELECT" || st.placeholders != 3 || st.parts[0] != `select *   from "odd?name" where id = ` {
+		t.Fatalf("parse = %+v, %v", st, err)
+	}
+	for query, driver := range map[string]string{
+		"SELECT * FROM t WHERE name = 'x'": "sqlite",
+		`SELECT * FROM t WHERE name = "x"`: "mysql",
+		"SELECT 1; DROP TABLE t":           "sqlite",
+		"SELECT 1 -- ;\n.shell id":         "sqlite",
+		"SELECT 1 /* x */":                 "sqlite",
+		`SELECT 1 \! id`:                   "postgres",
+		"SELECT $1":                        "postgres",
+		"SELECT 1 # x":                     "mysql",
+		".shell id":                        "sqlite",
+		"SELECT \"a\n.shell id\n\" FROM t": "sqlite",
+		"SELECT writefile(?, ?)":           "sqlite",
+		"SELECT length(READFILE (?))":      "sqlite",
+		"SELECT edit(?)":                   "sqlite",
+		`SELECT "load_extension"(?)`:       "sqlite",
+		"SELECT fts3_tokenizer(?)":         "sqlite",
+	} {
+		if _, err := parseStatement(query, driver); !errors.Is(err, ErrDenied) {
+			t.Errorf("%s (%s): expected a denial, got %v", query, driver, err)
+		}
+	}
+}
+
+func TestSQLScript(t *testing.T) {
+	st, _ := parseStatement("SELECT * FROM t WHERE a = ? AND b = ? AND c = ?", "")
+	params := []interface{}{"O'Brien", float64(42), nil}
+	for driver, want := range map[string][]string{
+		"sqlite": {".parameter init\nINSERT INTO temp.sqlite_parameters(key, value) VALUES('?1', CAST(X'4f27427269656e' AS TEXT));\n" +
+			"INSERT INTO temp.sqlite_parameters(key, value) VALUES('?2', 42);\nINSERT INTO temp.sqlite_parameters(key, value) VALUES('?3', NULL);\n" +
+			"SELECT * FROM t WHERE a = ?1 AND b = ?2 AND c = ?3;\n"},
+		"postgres": {"SELECT * FROM t WHERE a = :'p1' AND b = :'p2' AND c = NULL;\n", "-v", "p1=O'Brien", "-v", "p2=42"},
+		"mysql": {"SET @p1 = CONVERT(X'4f27427269656e' USING utf8mb4);\nSET @p2 = 42;\nSET @p3 = NULL;\n" +
+			"SELECT * FROM t WHERE a = @p1 AND b = @p2 AND c = @p3;\n"},
+	} {
+		script, args, err := (&sqlTool{driver: driver}).script(st, params)
+		if err != nil || script != want[0] || strings.Join(args, " ") != strings.Join(want[1:], " ") {
+			t.Errorf("%s: script %q, args %q, %v", driver, script, args, err)
+		}
+	}
+}
+
+func TestDatabaseTool_SQLite(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	root := t.TempDir()
+	setup := "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, status TEXT);\n" +
+		"INSERT INTO orders VALUES (1, 'O''Brien', 'shipped'), (2, 'Ng', 'open'), (3, 'Ng', 'open');\n"
+	cmd := exec.Command("sqlite3", filepath.Join(root, "shop.db"))
+	cmd.Stdin = strings.NewReader(setup)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	readOnly := false
+	tools := NewToolbox()
+	tools.AddContextTools([]corectx.Tool{
+		{Name: "shop", Description: "Orders of the shop", Database: &corectx.DatabaseConfig{Driver: "sqlite", DSN: "shop.db", MaxRows: 1}},
+		{Name: "shop_admin", Database: &corectx.DatabaseConfig{Driver: "sqlite", DSN: "shop.db", ReadOnly: &readOnly}},
+	}, root, nil)
+	if d := tools.Specs()[0].Description; !strings.HasPrefix(d, "Orders of the shop\n") || !strings.Contains(d, "orders(id INTEGER, customer TEXT, status TEXT)") {
+		t.Fatalf("expected the schema in the description, got %q", d)
+	}
+
+	call := func(name, query string, params ...interface{}) (string, error) {
+		return tools.call(context.Background(), runtimemodel.ToolCall{Name: name, Arguments: map[string]interface{}{"query": query, "params": params}})
+	}
+	out, err := call("shop", "SELECT id, status FROM orders WHERE customer = ?", "O'Brien")
+	if err != nil || out != `{"columns":["id","status"],"rows":[["1","shipped"]]}` {
+		t.Fatalf("query = %s, %v", out, err)
+	}
+	if out, err = call("shop", "SELECT id FROM orders WHERE status = ? ORDER BY id", "open"); err != nil || out != `{"columns":["id"],"rows":[["2"]],"truncated":true}` {
+		t.Fatalf("expected the row cap, got %s, %v", out, err)
+	}
+	if _, err = call("shop", "DELETE FROM orders WHERE id = ?", float64(1)); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected a read-only denial, got %v", err)
+	}
+	if _, err = call("shop", "WITH x AS (SELECT 1) INSERT INTO orders (customer) SELECT ? FROM x", "Eve"); err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Fatalf("expected the read-only connection to refuse the write, got %v", err)
+	}
+	if _, err = call("shop", "SELECT * FROM missing"); err == nil || !strings.Contains(err.Error(), "no such table") {
+		t.Fatalf("expected the client's error, got %v", err)
+	}
+	if _, err = call("shop", "SELECT ?"); err == nil || !strings.Contains(err.Error(), "1 placeholders but 0 params") {
+		t.Fatalf("expected a params mismatch, got %v", err)
+	}
+	if out, err = call("shop_admin", "UPDATE orders SET status = ? WHERE id = ?", "cancelled", float64(2)); err != nil {
+		t.Fatalf("update = %s, %v", out, err)
+	}
+	if out, _ = call("shop", "SELECT status FROM orders WHERE id = ?", float64(2)); !strings.Contains(out, "cancelled") {
+		t.Fatalf("expected the update, got %s", out)
+	}
+	if _, err = call("shop", "SELECT writefile(?, ?), length(readfile(?))", "x.txt", "pwned", "x.txt"); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected the file functions to be refused, got %v", err)
+	}
+	// The client runs in safe mode, which refuses them even past the parser
+	raw := newSQLTool(corectx.Tool{Name: "raw", Database: &corectx.DatabaseConfig{Driver: "sqlite", DSN: "shop.db", ReadOnly: &readOnly}}, root, policy{})
+	if _, err = raw.exec(context.Background(), "SELECT writefile('x.txt', 'pwned');\n", nil); err == nil || !strings.Contains(err.Error(), "safe mode") {
+		t.Fatalf("expected safe mode to refuse writefile(), got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "x.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file to be written, got %v", err)
+	}
+}
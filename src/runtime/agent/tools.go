@@ -0,0 +1,249 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+// MaxObservationBytes caps how much of a tool's output is shown to the model.
+const MaxObservationBytes = 8 << 10
+
+// Handler executes a tool call and returns its observation.
+type Handler func(ctx context.Context, args map[string]interface{}) (string, error)
+
+// Toolbox is the set of tools a Loop offers the model.
+type Toolbox struct {
+	specs    []runtimemodel.Tool
+	handlers map[string]Handler
+	timeouts map[string]time.Duration
+}
+
+// NewToolbox returns an empty toolbox.
+func NewToolbox() *Toolbox {
+	return &Toolbox{handlers: map[string]Handler{}, timeouts: map[string]time.Duration{}}
+}
+
+// Add registers a tool with the DefaultToolTimeout; a tool of the same name
+// is replaced.
+func (b *Toolbox) Add(spec runtimemodel.Tool, h Handler) {
+	if _, ok := b.handlers[spec.Name]; ok {
+		for i := range b.specs {
+			if b.specs[i].Name == spec.Name {
+				b.specs[i] = spec
+			}
+		}
+	} else {
+		b.specs = append(b.specs, spec)
+	}
+	b.handlers[spec.Name] = h
+	b.timeouts[spec.Name] = DefaultToolTimeout
+}
+
+// Len returns the number of tools.
+func (b *Toolbox) Len() int { return len(b.specs) }
+
+// Specs describes the tools for the model.
+func (b *Toolbox) Specs() []runtimemodel.Tool {
+	return append([]runtimemodel.Tool(nil), b.specs...)
+}
+
+// call executes a tool call within its timeout, truncating its observation.
+func (b *Toolbox) call(ctx context.Context, call runtimemodel.ToolCall) (string, error) {
+	h, ok := b.handlers[call.Name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Name)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, b.timeouts[call.Name])
+	defer cancel()
+	out, err := h(callCtx, call.Arguments)
+	if ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%s timed out after %s", call.Name, b.timeouts[call.Name])
+	}
+	if len(out) > MaxObservationBytes {
+		out = strings.ToValidUTF8(out[:MaxObservationBytes], "") + "\n[truncated]"
+	}
+	return out, err
+}
+
+// AddContextTools registers the context's HTTP tools, those whose URI is an
+// http(s) URL, its webhooks, databases and command tools, run from root, each
+// under its policy.
+// It returns the names of the tools it cannot execute.
+func (b *Toolbox) AddContextTools(tools []corectx.Tool, root string, client *http.Client) (skipped []string) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for _, t := range tools {
+		p := toolPolicy(t, root)
+		var h Handler
+		switch {
+		case len(t.Command) > 0:
+			h = commandHandler(t, root, p)
+		case t.Webhook != nil:
+			h = webhookHandler(t, p, client)
+		case t.Database != nil:
+			db := newSQLTool(t, root, p)
+			b.Add(runtimemodel.Tool{Name: t.Name, Description: db.describe(t.Description), Parameters: sqlParamsSchema}, db.handler())
+			b.timeouts[t.Name] = p.timeout
+			continue
+		case strings.HasPrefix(t.URI, "http://"), strings.HasPrefix(t.URI, "https://"):
+			h = httpHandler(t, p, client)
+		default:
+			skipped = append(skipped, t.Name)
+			continue
+		}
+		schema := parameterSchema(t.Parameters)
+		if len(t.Parameters) == 0 && t.Schema != nil {
+			schema = t.Schema
+		}
+		b.Add(runtimemodel.Tool{Name: t.Name, Description: t.Description, Parameters: schema}, h)
+		b.timeouts[t.Name] = p.timeout
+	}
+	return skipped
+}
+
+// parameterSchema is the JSON Schema of an HTTP tool's arguments.
+func parameterSchema(params []corectx.ToolParameter) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []interface{}
+	for _, p := range params {
+		prop := map[string]interface{}{}
+		if p.Type != "" {
+			prop["type"] = p.Type
+		}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		props[p.Name] = prop
+		if p.Required || p.In == "path" {
+			required = append(required, p.Name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// policedClient returns a copy of client that follows redirects only to hosts
+// of the policy.
+func policedClient(client *http.Client, p policy) *http.Client {
+	policed := *client
+	policed.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !p.allowsHost(req.URL) {
+			return fmt.Errorf("%w: host %s is not allowed", ErrDenied, req.URL.Host)
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &policed
+}
+
+// redirectError reports a redirect the policy denied as such.
+func redirectError(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) && errors.Is(err, ErrDenied) {
+		return fmt.Errorf("redirect %w", uerr.Err)
+	}
+	return err
+}
+
+// httpHandler calls an HTTP tool. Arguments fill the {name} placeholders of
+// the URI and the query, header and body parameters. Tools without declared
+// parameters send their arguments as the query (GET, DELETE) or a JSON body.
+// Requests must use a method and host of the tool's policy, redirects a host.
+func httpHandler(t corectx.Tool, p policy, client *http.Client) Handler {
+	method := strings.ToUpper(t.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	policed := policedClient(client, p)
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		uri, query, header := t.URI, url.Values{}, http.Header{}
+		var body interface{}
+		for _, p := range t.Parameters {
+			v, ok := args[p.Name]
+			if !ok || v == nil {
+				if p.Required || p.In == "path" {
+					return "", fmt.Errorf("missing argument %q", p.Name)
+				}
+				continue
+			}
+			switch p.In {
+			case "path":
+				uri = strings.ReplaceAll(uri, "{"+p.Name+"}", url.PathEscape(fmt.Sprint(v)))
+			case "header":
+				header.Set(p.Name, fmt.Sprint(v))
+			case "body":
+				body = v
+			default:
+				query.Set(p.Name, fmt.Sprint(v))
+			}
+		}
+		if len(t.Parameters) == 0 && len(args) > 0 {
+			if method == http.MethodGet || method == http.MethodDelete {
+				for k, v := range args {
+					query.Set(k, fmt.Sprint(v))
+				}
+			} else {
+				body = args
+			}
+		}
+		if len(query) > 0 {
+			sep := "?"
+			if strings.Contains(uri, "?") {
+				sep = "&"
+			}
+			uri += sep + query.Encode()
+		}
+		var reader io.Reader
+		if body != nil {
+			by, err := json.Marshal(body)
+			if err != nil {
+				return "", fmt.Errorf("encode body: %w", err)
+			}
+			reader = bytes.NewReader(by)
+			header.Set("Content-Type", "application/json")
+		}
+		req, err := http.NewRequestWithContext(ctx, method, uri, reader)
+		if err != nil {
+			return "", err
+		}
+		if err := p.checkRequest(method, req.URL); err != nil {
+			return "", err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := policed.Do(req)
+		if err != nil {
+			return "", redirectError(err)
+		}
+		defer resp.Body.Close()
+		by, err := io.ReadAll(io.LimitReader(resp.Body, MaxObservationBytes+1))
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode >= 400 {
+			return string(by), fmt.Errorf("%s %s: HTTP %d", method, t.Name, resp.StatusCode)
+		}
+		return string(by), nil
+	}
+}
@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	"github.com/contexis-cmp/contexis/src/runtime/secrets"
+)
+
+// Webhook defaults, for settings a tool's webhook leaves unset.
+const (
+	DefaultWebhookRetries          = 2
+	DefaultWebhookTimeout          = 10 * time.Second
+	DefaultWebhookMaxResponseBytes = 1 << 20
+	DefaultWebhookSignatureHeader  = "X-CMP-Signature"
+)
+
+// errAttemptTimeout is returned for a webhook attempt without a response in time.
+var errAttemptTimeout = errors.New("no response")
+
+// webhookBackoff spaces the retries of a webhook.
+var webhookBackoff = runtimemodel.ResilienceConfig{BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// webhookHandler calls a webhook tool. Each delivery carries an
+// X-CMP-Delivery ID, the same on every retry, and an X-CMP-Timestamp; with a
+// secret, the signature header is "sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>". Network errors, 408, 429 and 5xx responses are
+// retried; responses over the size limit fail the call.
+func webhookHandler(t corectx.Tool, p policy, client *http.Client) Handler {
+	client = policedClient(client, p)
+	w := *t.Webhook
+	method := strings.ToUpper(w.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	retries := DefaultWebhookRetries
+	if w.Retries != nil && *w.Retries >= 0 {
+		retries = *w.Retries
+	}
+	timeout := DefaultWebhookTimeout
+	if w.TimeoutSeconds > 0 {
+		timeout = time.Duration(w.TimeoutSeconds) * time.Second
+	}
+	maxBytes := DefaultWebhookMaxResponseBytes
+	if w.MaxResponseBytes > 0 {
+		maxBytes = w.MaxResponseBytes
+	}
+	sigHeader := w.SignatureHeader
+	if sigHeader == "" {
+		sigHeader = DefaultWebhookSignatureHeader
+	}
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		target, err := url.Parse(w.URL)
+		if err != nil {
+			return "", fmt.Errorf("webhook url: %w", err)
+		}
+		var body []byte
+		if method == http.MethodGet {
+			q := target.Query()
+			for k, v := range args {
+				q.Set(k, fmt.Sprint(v))
+			}
+			target.RawQuery = q.Encode()
+		} else {
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			if body, err = json.Marshal(args); err != nil {
+				return "", fmt.Errorf("encode body: %w", err)
+			}
+		}
+		if err := p.checkRequest(method, target); err != nil {
+			return "", err
+		}
+		header := http.Header{}
+		for k, v := range w.Headers {
+			if v, err = resolveRef(ctx, v); err != nil {
+				return "", fmt.Errorf("webhook header %s: %w", k, err)
+			}
+			header.Set(k, v)
+		}
+		var secret string
+		if w.SecretRef != "" {
+			if secret, err = resolveRef(ctx, w.SecretRef); err != nil {
+				return "", fmt.Errorf("webhook secret: %w", err)
+			}
+			if secret == "" {
+				return "", fmt.Errorf("webhook secret %s is empty", w.SecretRef)
+			}
+		}
+		delivery := make([]byte, 16)
+		_, _ = rand.Read(delivery)
+		header.Set("X-CMP-Delivery", hex.EncodeToString(delivery))
+		if body != nil {
+			header.Set("Content-Type", "application/json")
+		}
+
+		for attempt := 0; ; attempt++ {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			header.Set("X-CMP-Timestamp", ts)
+			if secret != "" {
+				header.Set(sigHeader, "sha256="+SignWebhook(secret, ts, body))
+			}
+			out, retryAfter, err := deliver(ctx, client, method, target.String(), header, body, timeout, maxBytes)
+			retryable := runtimemodel.IsRetryable(err) || (errors.Is(err, errAttemptTimeout) && ctx.Err() == nil)
+			if err == nil || attempt >= retries || !retryable {
+				if err != nil {
+					return out, fmt.Errorf("%s %s: %w", method, t.Name, redirectError(err))
+				}
+				return out, nil
+			}
+			delay := webhookBackoff.Backoff(attempt + 1)
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// deliver makes one webhook request within timeout. Error responses are
+// returned as a runtimemodel.StatusError with the response body.
+func deliver(ctx context.Context, client *http.Client, method, target string, header http.Header, body []byte, timeout time.Duration, maxBytes int) (string, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header = header.Clone()
+	resp, err := client.Do(req)
+	if err != nil && ctx.Err() != nil && ctx.Err() != context.Canceled {
+		return "", 0, fmt.Errorf("%w within %s", errAttemptTimeout, timeout)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	by, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode >= 400 {
+		se := runtimemodel.NewStatusError("webhook", resp)
+		return string(by), se.RetryAfter, se
+	}
+	if len(by) > maxBytes {
+		return "", 0, fmt.Errorf("response exceeds %d bytes", maxBytes)
+	}
+	return string(by), 0, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>" with secret,
+// the signature receivers of a webhook tool verify.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolveRef returns the value of a secret:// reference or env:NAME; other
+// values are returned as they are.
+func resolveRef(ctx context.Context, v string) (string, error) {
+	switch {
+	case secrets.IsRef(v):
+		return secrets.Default().Resolve(ctx, v)
+	case strings.HasPrefix(v, "env:"):
+		return os.Getenv(strings.TrimPrefix(v, "env:")), nil
+	}
+	return v, nil
+}
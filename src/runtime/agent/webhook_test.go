@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+func TestWebhookTool(t *testing.T) {
+	t.Setenv("HOOK_SECRET", "s3cret")
+	t.Setenv("HOOK_TOKEN", "Bearer t0k")
+	var deliveries []string
+	fail := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries = append(deliveries, r.Header.Get("X-CMP-Delivery"))
+		if r.Header.Get("X-Hook-Sig") != "sha256="+SignWebhook("s3cret", r.Header.Get("X-CMP-Timestamp"), body) || r.Header.Get("Authorization") != "Bearer t0k" {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/big":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case fail > 0:
+			fail--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"ok":true,"got":` + string(body) + `}`))
+		}
+	}))
+	defer srv.Close()
+	retries := 1
+	hook := func(name, path string) corectx.Tool {
+		return corectx.Tool{Name: name, Webhook: &corectx.WebhookConfig{
+			URL: srv.URL + path, SecretRef: "env:HOOK_SECRET", SignatureHeader: "X-Hook-Sig",
+			Headers: map[string]string{"Authorization": "Bearer t0k"}, MaxResponseBytes: 50,
+		}}
+	}
+	notify, flaky, big := hook("notify", "/"), hook("flaky", "/"), hook("big", "/big")
+	notify.Webhook.Headers["Authorization"] = "env:HOOK_TOKEN"
+	flaky.Webhook.Retries = &retries
+	defer func(b runtimemodel.ResilienceConfig) { webhookBackoff = b }(webhookBackoff)
+	webhookBackoff.BaseDelay, webhookBackoff.MaxDelay = time.Millisecond, time.Millisecond
+	tools := NewToolbox()
+	if skipped := tools.AddContextTools([]corectx.Tool{notify, flaky, big}, "", srv.Client()); len(skipped) != 0 {
+		t.Fatalf("skipped %v", skipped)
+	}
+
+	out, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "notify", Arguments: map[string]interface{}{"text": "hi"}})
+	if err != nil || out != `{"ok":true,"got":{"text":"hi"}}` {
+		t.Fatalf("call = %q, %v", out, err)
+	}
+	if len(deliveries) != 3 || deliveries[0] == "" || deliveries[0] != deliveries[2] {
+		t.Fatalf("expected two retries of one delivery, got %v", deliveries)
+	}
+
+	fail = 5
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "flaky"}); err == nil || !strings.Contains(err.Error(), "503") || fail != 3 {
+		t.Fatalf("expected a 503 after one retry, got %v (%d left)", err, fail)
+	}
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "big"}); err == nil || !strings.Contains(err.Error(), "response exceeds 50 bytes") {
+		t.Fatalf("expected the response size limit, got %v", err)
+	}
+
+	t.Setenv("HOOK_SECRET", "")
+	if _, err := tools.call(context.Background(), runtimemodel.ToolCall{Name: "notify"}); err == nil || !strings.Contains(err.Error(), "env:HOOK_SECRET is empty") {
+		t.Fatalf("expected unsigned deliveries to be refused, got %v", err)
+	}
+}
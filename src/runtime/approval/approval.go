@@ -0,0 +1,255 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir is the project-relative directory holding one JSON file per request.
+const Dir = "data/approvals"
+
+// DefaultTimeout is how long a request waits for a decision.
+const DefaultTimeout = 5 * time.Minute
+
+// Request statuses.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusDenied    = "denied"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	// ErrNotFound is returned for unknown request IDs.
+	ErrNotFound = errors.New("approval request not found")
+	// ErrDecided is returned when deciding a request that is no longer pending.
+	ErrDecided = errors.New("approval request already decided")
+)
+
+var idRe = regexp.MustCompile(`^apr_[0-9a-f]{16}$`)
+
+// Request is a sensitive action awaiting an out-of-band decision.
+type Request struct {
+	ID        string                 `json:"id"`
+	Action    string                 `json:"action"`
+	TenantID  string                 `json:"tenant_id,omitempty"`
+	Component string                 `json:"component,omitempty"`
+	Context   string                 `json:"context,omitempty"`
+	Query     string                 `json:"query,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Requester string                 `json:"requester,omitempty"` // API key ID of the caller
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Status    string                 `json:"status"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	DecidedAt *time.Time             `json:"decided_at,omitempty"`
+	DecidedBy string                 `json:"decided_by,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+}
+
+// Store persists approval requests as files so decisions can be made from
+// another process (e.g. the CLI) as well as through the API.
+type Store struct {
+	dir string
+	// PollInterval is how often Wait re-reads a request decided elsewhere.
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// NewStore returns the approval store of a project root.
+func NewStore(root string) *Store {
+	return &Store{dir: filepath.Join(root, Dir), PollInterval: 500 * time.Millisecond, waiters: map[string][]chan struct{}{}}
+}
+
+// Create files a new pending request that expires after ttl.
+func (s *Store) Create(req Request, ttl time.Duration) (Request, error) {
+	if ttl <= 0 {
+		ttl = DefaultTimeout
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return Request{}, err
+	}
+	now := time.Now().UTC()
+	req.ID = "apr_" + hex.EncodeToString(b[:])
+	req.Status = StatusPending
+	req.CreatedAt = now
+	req.ExpiresAt = now.Add(ttl)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return req, s.write(req)
+}
+
+// Get returns a request. Pending requests past their deadline are reported as expired.
+func (s *Store) Get(id string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(id)
+}
+
+// List returns requests oldest first, optionally only those with status.
+func (s *Store) List(status string) ([]Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Request
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || !idRe.MatchString(id) {
+			continue
+		}
+		req, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || req.Status == status {
+			out = append(out, req)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Decide approves or denies a pending request and wakes its waiter.
+func (s *Store) Decide(id string, approve bool, by, reason string) (Request, error) {
+	status := StatusDenied
+	if approve {
+		status = StatusApproved
+	}
+	return s.finish(id, status, by, reason)
+}
+
+// Cancel marks a pending request cancelled, e.g. when its caller went away.
+func (s *Store) Cancel(id, reason string) (Request, error) {
+	return s.finish(id, StatusCancelled, "", reason)
+}
+
+func (s *Store) finish(id, status, by, reason string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, err := s.read(id)
+	if err != nil {
+		return Request{}, err
+	}
+	if req.Status != StatusPending {
+		return req, fmt.Errorf("%w: %s is %s", ErrDecided, id, req.Status)
+	}
+	now := time.Now().UTC()
+	req.Status, req.DecidedAt, req.DecidedBy, req.Reason = status, &now, by, reason
+	if err := s.write(req); err != nil {
+		return Request{}, err
+	}
+	for _, ch := range s.waiters[id] {
+		close(ch)
+	}
+	delete(s.waiters, id)
+	return req, nil
+}
+
+// Wait blocks until the request is decided or expires and returns it. If ctx
+// ends first the request is cancelled and ctx's error returned.
+func (s *Store) Wait(ctx context.Context, id string) (Request, error) {
+	wake := make(chan struct{})
+	s.mu.Lock()
+	s.waiters[id] = append(s.waiters[id], wake)
+	s.mu.Unlock()
+	defer s.unwait(id, wake)
+	poll := s.PollInterval
+	if poll <= 0 {
+		poll = 500 * time.Millisecond
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		req, err := s.Get(id)
+		if err != nil {
+			return Request{}, err
+		}
+		if req.Status != StatusPending {
+			return req, nil
+		}
+		select {
+		case <-ctx.Done():
+			_, _ = s.Cancel(id, "requester disconnected")
+			return req, ctx.Err()
+		case <-wake:
+		case <-ticker.C:
+		case <-time.After(time.Until(req.ExpiresAt)):
+		}
+	}
+}
+
+func (s *Store) unwait(id string, wake chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws := s.waiters[id]
+	for i, ch := range ws {
+		if ch == wake {
+			s.waiters[id] = append(ws[:i], ws[i+1:]...)
+			break
+		}
+	}
+	if len(s.waiters[id]) == 0 {
+		delete(s.waiters, id)
+	}
+}
+
+// read loads a request, marking it expired once past its deadline. Callers hold s.mu.
+func (s *Store) read(id string) (Request, error) {
+	if !idRe.MatchString(id) {
+		return Request{}, ErrNotFound
+	}
+	by, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return Request{}, ErrNotFound
+	}
+	if err != nil {
+		return Request{}, err
+	}
+	var req Request
+	if err := json.Unmarshal(by, &req); err != nil {
+		return Request{}, fmt.Errorf("parse approval %s: %w", id, err)
+	}
+	if req.Status == StatusPending && !time.Now().Before(req.ExpiresAt) {
+		req.Status = StatusExpired
+		_ = s.write(req)
+	}
+	return req, nil
+}
+
+// write stores a request atomically. Callers hold s.mu.
+func (s *Store) write(req Request) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	by, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, req.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, by, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,59 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStore_DecideFromAnotherProcess(t *testing.T) {
+	root := t.TempDir()
+	server := NewStore(root)
+	server.PollInterval = 10 * time.Millisecond
+	req, err := server.Create(Request{Action: "payment", TenantID: "acme"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second store on the same root stands in for the CLI
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		if _, err := NewStore(root).Decide(req.ID, true, "alice", "ok"); err != nil {
+			t.Error(err)
+		}
+	}()
+	got, err := server.Wait(context.Background(), req.ID)
+	if err != nil || got.Status != StatusApproved || got.DecidedBy != "alice" || got.DecidedAt == nil {
+		t.Fatalf("Wait = %+v, %v", got, err)
+	}
+	if _, err := server.Decide(req.ID, false, "bob", ""); !errors.Is(err, ErrDecided) {
+		t.Fatalf("expected ErrDecided, got %v", err)
+	}
+}
+
+func TestStore_ExpiryAndCancel(t *testing.T) {
+	s := NewStore(t.TempDir())
+	req, _ := s.Create(Request{Action: "payment"}, 20*time.Millisecond)
+	got, err := s.Wait(context.Background(), req.ID)
+	if err != nil || got.Status != StatusExpired {
+		t.Fatalf("Wait = %+v, %v", got, err)
+	}
+
+	req, _ = s.Create(Request{Action: "payment"}, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Wait(ctx, req.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if got, _ := s.Get(req.ID); got.Status != StatusCancelled {
+		t.Fatalf("abandoned request status = %s", got.Status)
+	}
+	pending, _ := s.List(StatusPending)
+	all, _ := s.List("")
+	if len(pending) != 0 || len(all) != 2 {
+		t.Fatalf("List pending=%d all=%d", len(pending), len(all))
+	}
+	if _, err := s.Get("../../etc/passwd"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for invalid id, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package approval implements out-of-band approval of sensitive actions.
+//
+// When a chat request performs an action listed in CMP_OOB_REQUIRED_ACTIONS,
+// the runtime files an approval Request under data/approvals and parks the
+// request until an operator approves or denies it (via /api/v1/approvals or
+// `ctx approvals`), or the request expires.
+package approval
@@ -0,0 +1,120 @@
+// Package buildinfo reports the version and build metadata of the ctx binary,
+// the same for `ctx version`, the server's /version endpoint and startup logs.
+//
+// Release builds inject the values with -ldflags:
+//
+//	go build -ldflags "-X github.com/contexis-cmp/contexis/src/runtime/buildinfo.Version=0.2.0 \
+//	  -X github.com/contexis-cmp/contexis/src/runtime/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/contexis-cmp/contexis/src/runtime/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./src/cli
+//
+// `make build`, the Dockerfile and GoReleaser do this. Without ldflags the
+// commit and date come from the VCS stamp the Go toolchain embeds, and the
+// version from the module version (go install) or FrameworkVersion.
+package buildinfo
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// FrameworkVersion is the framework release of this source tree. New
+// projects record it in context.lock.json, and it is the version of builds
+// without injected metadata.
+const FrameworkVersion = "0.2.0"
+
+// Set with -ldflags -X at build time.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info is the build metadata of the running binary.
+type Info struct {
+	Version          string `json:"version" yaml:"version"`
+	Commit           string `json:"commit" yaml:"commit"`
+	Date             string `json:"build_date" yaml:"build_date"`
+	Modified         bool   `json:"modified,omitempty" yaml:"modified,omitempty"` // built from a dirty tree
+	GoVersion        string `json:"go_version" yaml:"go_version"`
+	Platform         string `json:"platform" yaml:"platform"`
+	FrameworkVersion string `json:"framework_version" yaml:"framework_version"`
+}
+
+// Get returns the build metadata, preferring injected values over the
+// toolchain's build stamp.
+func Get() Info {
+	info := Info{
+		Version:          strings.TrimPrefix(Version, "v"),
+		Commit:           Commit,
+		Date:             Date,
+		GoVersion:        runtime.Version(),
+		Platform:         runtime.GOOS + "/" + runtime.GOARCH,
+		FrameworkVersion: FrameworkVersion,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		// Pseudo-versions stamped for untagged builds say less than FrameworkVersion
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" && !pseudoVersion.MatchString(bi.Main.Version) {
+			info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = shortCommit(s.Value)
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = Commit == "" && s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = FrameworkVersion
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// pseudoVersion matches Go module pseudo-versions such as
+// v0.0.0-20261016142806-667e6d06a1d4+dirty.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}(\+dirty)?$`)
+
+func shortCommit(rev string) string {
+	if len(rev) > 12 {
+		return rev[:12]
+	}
+	return rev
+}
+
+// String is the one-line form, e.g.
+// "0.2.0 (commit 1a2b3c4, built 2026-01-01T00:00:00Z, go1.24.6 linux/amd64)".
+func (i Info) String() string {
+	commit := i.Commit
+	if i.Modified {
+		commit += "-dirty"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s)", i.Version, commit, i.Date, i.GoVersion, i.Platform)
+}
+
+// Fields returns the metadata as log fields for startup messages.
+func (i Info) Fields() []zap.Field {
+	return []zap.Field{
+		zap.String("version", i.Version),
+		zap.String("commit", i.Commit),
+		zap.String("build_date", i.Date),
+		zap.String("go_version", i.GoVersion),
+	}
+}
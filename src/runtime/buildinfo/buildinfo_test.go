@@ -0,0 +1,48 @@
+package buildinfo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGet_InjectedValues(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+	Version, Commit, Date = "v1.2.3", "abc1234", "2026-01-02T03:04:05Z"
+
+	info := Get()
+	if info.Version != "1.2.3" || info.Commit != "abc1234" || info.Date != "2026-01-02T03:04:05Z" || info.Modified {
+		t.Fatalf("unexpected %+v", info)
+	}
+	if info.FrameworkVersion != FrameworkVersion || !strings.HasPrefix(info.GoVersion, "go") || !strings.Contains(info.Platform, "/") {
+		t.Fatalf("unexpected runtime fields %+v", info)
+	}
+	if s := info.String(); !strings.HasPrefix(s, "1.2.3 (commit abc1234, built 2026-01-02T03:04:05Z, go") {
+		t.Fatalf("String() = %q", s)
+	}
+	if len(info.Fields()) != 4 {
+		t.Fatalf("unexpected log fields %v", info.Fields())
+	}
+}
+
+func TestGet_Fallbacks(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, Date = v, c, d }(Version, Commit, Date)
+	Version, Commit, Date = "", "", ""
+
+	// Test binaries carry neither a module version nor a VCS stamp
+	info := Get()
+	if info.Version != FrameworkVersion || info.Commit == "" || info.Date == "" {
+		t.Fatalf("unexpected fallbacks %+v", info)
+	}
+}
+
+func TestPseudoVersion(t *testing.T) {
+	for v, want := range map[string]bool{
+		"v0.0.0-20261016142806-667e6d06a1d4+dirty": true,
+		"v0.2.1-0.20261016142806-667e6d06a1d4":     true,
+		"v0.2.0":                                   false,
+	} {
+		if got := pseudoVersion.MatchString(v); got != want {
+			t.Errorf("pseudoVersion(%q) = %v", v, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package runtimeconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Production is the environment whose servers must pass CheckProduction.
+const Production = "production"
+
+// Logging is the logging section.
+type Logging struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+	Output string `yaml:"output"`
+}
+
+// Database is the database section.
+type Database struct {
+	Provider string `yaml:"provider"`
+	Path     string `yaml:"path"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Name     string `yaml:"name"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	PoolSize int    `yaml:"pool_size"`
+}
+
+// Security is the security section: authentication and the protections
+// applied to chat requests. Unset fields leave the runtime defaults.
+type Security struct {
+	AuthEnabled     *bool  `yaml:"auth_enabled"`
+	AuthMode        string `yaml:"auth_mode"`
+	PromptInjection *bool  `yaml:"prompt_injection"`
+	PIIMode         string `yaml:"pii_mode"`
+	RequireCitation *bool  `yaml:"require_citation"`
+}
+
+// featureVars are the features exported by Apply.
+var featureVars = map[string]string{
+	"local_models":   "CMP_LOCAL_MODELS",
+	"mock_providers": "CMP_MOCK_PROVIDERS",
+	"offline_mode":   "CMP_OFFLINE_MODE",
+}
+
+// Apply exports the settings of e that the runtime reads from environment
+// variables: logging, database, features, security and the huggingface
+// provider. Variables that are already set take precedence over the file. It
+// fails when one of these sections has problems.
+func (e *Environment) Apply() error {
+	set := func(name, value string) {
+		if value != "" && os.Getenv(name) == "" {
+			_ = os.Setenv(name, value)
+		}
+	}
+	setBool := func(name string, v *bool) {
+		if v != nil {
+			set(name, strconv.FormatBool(*v))
+		}
+	}
+
+	var logging Logging
+	if err := e.Section("logging", &logging); err != nil {
+		return err
+	}
+	set("CMP_LOG_LEVEL", logging.Level)
+	set("CMP_LOG_FORMAT", logging.Format)
+
+	var db Database
+	if err := e.Section("database", &db); err != nil {
+		return err
+	}
+	set("CMP_DB_PROVIDER", db.Provider)
+	set("CMP_DB_PATH", db.Path)
+
+	var features map[string]bool
+	if err := e.Section("features", &features); err != nil {
+		return err
+	}
+	for key, name := range featureVars {
+		if v, ok := features[key]; ok {
+			setBool(name, &v)
+		}
+	}
+
+	var sec Security
+	if err := e.Section("security", &sec); err != nil {
+		return err
+	}
+	setBool("CMP_AUTH_ENABLED", sec.AuthEnabled)
+	set("CMP_AUTH_MODE", sec.AuthMode)
+	setBool("CMP_PI_ENFORCEMENT", sec.PromptInjection)
+	set("CMP_PII_MODE", sec.PIIMode)
+	setBool("CMP_REQUIRE_CITATION", sec.RequireCitation)
+
+	var hf struct {
+		APIKey       string `yaml:"api_key"`
+		Model        string `yaml:"model"`
+		Endpoint     string `yaml:"endpoint"`
+		EndpointType string `yaml:"endpoint_type"`
+	}
+	if _, err := e.Provider("huggingface", &hf); err != nil {
+		return err
+	}
+	set("HF_TOKEN", hf.APIKey)
+	set("HF_MODEL_ID", hf.Model)
+	set("HF_ENDPOINT", hf.Endpoint)
+	set("HF_ENDPOINT_TYPE", hf.EndpointType)
+	return nil
+}
+
+// CheckProduction reports the settings a production server needs that the
+// exported environment lacks: authentication (CMP_AUTH_ENABLED=true or
+// CMP_AUTH_MODE=oidc) and a database other than sqlite (CMP_DB_PROVIDER).
+func CheckProduction() error {
+	var problems []error
+	if os.Getenv("CMP_AUTH_ENABLED") != "true" && !strings.EqualFold(os.Getenv("CMP_AUTH_MODE"), "oidc") {
+		problems = append(problems, errors.New("authentication is disabled: set security.auth_enabled: true or CMP_AUTH_ENABLED=true"))
+	}
+	if p := strings.ToLower(os.Getenv("CMP_DB_PROVIDER")); p == "" || p == "sqlite" {
+		problems = append(problems, errors.New("the database is sqlite: set database.provider (postgres or mysql) or CMP_DB_PROVIDER"))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("missing production settings: %w", errors.Join(problems...))
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package runtimeconfig
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed environment_schema.json
+var schemaJSON []byte
+
+// sectionSchemas holds one JSON Schema per known top-level section, each
+// carrying the shared definitions.
+var sectionSchemas = func() map[string][]byte {
+	var doc struct {
+		Definitions json.RawMessage            `json:"definitions"`
+		Sections    map[string]json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal(schemaJSON, &doc); err != nil {
+		panic("runtimeconfig: invalid environment_schema.json: " + err.Error())
+	}
+	out := make(map[string][]byte, len(doc.Sections))
+	for name, s := range doc.Sections {
+		var m map[string]json.RawMessage
+		_ = json.Unmarshal(s, &m)
+		m["definitions"] = doc.Definitions
+		out[name], _ = json.Marshal(m)
+	}
+	return out
+}()
+
+// Env returns the active environment name: CMP_ENV, default development.
+func Env() string {
+	if env := os.Getenv("CMP_ENV"); env != "" {
+		return env
+	}
+	return "development"
+}
+
+// Path returns the config file of env under the project root.
+func Path(root, env string) string {
+	return filepath.Join(root, "config", "environments", env+".yaml")
+}
+
+// Environment is a parsed and interpolated environment config file.
+type Environment struct {
+	Name string // environment name, e.g. production
+	Path string // empty when the file does not exist
+
+	sections map[string]*yaml.Node
+	problems map[string]error // per section: unset variables or schema violations
+}
+
+// Load reads the active environment config of the project at root. A
+// missing file yields an empty Environment, so every section decodes to its
+// zero value.
+func Load(root string) (*Environment, error) {
+	env := Env()
+	e, err := LoadFile(Path(root, env))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Environment{Name: env}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.Name = env
+	return e, nil
+}
+
+// LoadFile reads, interpolates and validates one environment config file.
+// Only unreadable files and invalid YAML are errors here; problems within a
+// section are reported when that section is used, or by Validate.
+func LoadFile(path string) (*Environment, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parse(by)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	e.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	e.Path = path
+	return e, nil
+}
+
+func parse(by []byte) (*Environment, error) {
+	e := &Environment{sections: map[string]*yaml.Node{}, problems: map[string]error{}}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(by, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return e, nil
+	}
+	top := doc.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of sections", top.Line)
+	}
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		name, value := top.Content[i].Value, top.Content[i+1]
+		e.sections[name] = value
+		if name == "providers" && value.Kind == yaml.MappingNode {
+			// Providers are checked one by one: an unset key of one provider
+			// does not block the others
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				single := &yaml.Node{Kind: yaml.MappingNode, Content: value.Content[j : j+2]}
+				e.check(name+"."+value.Content[j].Value, name, single)
+			}
+			continue
+		}
+		e.check(name, name, value)
+	}
+	return e, nil
+}
+
+// check interpolates n and validates it against the schema of section,
+// recording any problem under key.
+func (e *Environment) check(key, section string, n *yaml.Node) {
+	if err := interpolateNode(n, nil); err != nil {
+		e.problems[key] = err
+		return
+	}
+	if err := validateSection(section, n); err != nil {
+		e.problems[key] = err
+	}
+}
+
+// validateSection checks a section against its schema; sections without a
+// schema (project-specific ones) and empty sections pass.
+func validateSection(name string, n *yaml.Node) error {
+	schema, ok := sectionSchemas[name]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	by, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	res, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(by))
+	if err != nil {
+		return fmt.Errorf("schema validation error: %w", err)
+	}
+	if res.Valid() {
+		return nil
+	}
+	var msgs []string
+	for _, re := range res.Errors() {
+		field := name
+		if f := re.Field(); f != "(root)" {
+			field += "." + f
+		}
+		msgs = append(msgs, field+": "+re.Description())
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Has reports whether the config defines section name.
+func (e *Environment) Has(name string) bool {
+	_, ok := e.sections[name]
+	return ok
+}
+
+// Section decodes section name into out, leaving out untouched when the
+// section is absent. It fails when the section references unset variables
+// or violates its schema.
+func (e *Environment) Section(name string, out interface{}) error {
+	n, ok := e.sections[name]
+	if !ok {
+		return nil
+	}
+	for _, key := range e.problemKeys() {
+		if key == name || strings.HasPrefix(key, name+".") {
+			return fmt.Errorf("%s: %s: %w", e.describe(), key, e.problems[key])
+		}
+	}
+	if err := n.Decode(out); err != nil {
+		return fmt.Errorf("%s: %s: %w", e.describe(), name, err)
+	}
+	return nil
+}
+
+// Validate reports the problems of every section, sorted by section name.
+func (e *Environment) Validate() error {
+	var errs []error
+	for _, name := range e.problemKeys() {
+		err := e.problems[name]
+		var mv *MissingVarError
+		if !errors.As(err, &mv) {
+			// schema messages already name the field
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return errors.Join(errs...)
+}
+
+func (e *Environment) problemKeys() []string {
+	keys := make([]string, 0, len(e.problems))
+	for key := range e.problems {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e *Environment) describe() string {
+	if e.Path != "" {
+		return e.Path
+	}
+	return e.Name + ".yaml"
+}
+
+// Features is the features section. Consumers read the flags they need;
+// environment variables such as CMP_MOCK_PROVIDERS take precedence there.
+type Features struct {
+	HotReload       bool `yaml:"hot_reload"`
+	DebugMode       bool `yaml:"debug_mode"`
+	MockProviders   bool `yaml:"mock_providers"`
+	EnableTelemetry bool `yaml:"enable_telemetry"`
+	LocalModels     bool `yaml:"local_models"`
+	OfflineMode     bool `yaml:"offline_mode"`
+}
+
+// Features returns the features section.
+func (e *Environment) Features() (Features, error) {
+	var f Features
+	err := e.Section("features", &f)
+	return f, err
+}
+
+// Provider decodes providers.<name> into out, reporting whether it is
+// configured. Problems in other providers do not affect it.
+func (e *Environment) Provider(name string, out interface{}) (bool, error) {
+	providers, ok := e.sections["providers"]
+	if !ok || providers.Kind != yaml.MappingNode {
+		return false, nil
+	}
+	for i := 0; i+1 < len(providers.Content); i += 2 {
+		if providers.Content[i].Value != name {
+			continue
+		}
+		n := providers.Content[i+1]
+		if n.Tag == "!!null" {
+			return false, nil
+		}
+		key := "providers." + name
+		if err := e.problems[key]; err != nil {
+			return false, fmt.Errorf("%s: %s: %w", e.describe(), key, err)
+		}
+		if err := n.Decode(out); err != nil {
+			return false, fmt.Errorf("%s: %s: %w", e.describe(), key, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,297 @@
+package runtimeconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/runtime/secrets"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "db.internal", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	cases := []struct{ in, want string }{
+		{"${HOST}", "db.internal"},
+		{"postgres://${HOST}:${PORT:-5432}/app", "postgres://db.internal:5432/app"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${EMPTY}", ""},
+		{"cost: $$${HOST}", "cost: $${HOST}"},
+		{"$${HOST}", "${HOST}"},
+		{"no placeholders", "no placeholders"},
+	}
+	for _, c := range cases {
+		got, err := Interpolate(c.in, lookup)
+		if err != nil || got != c.want {
+			t.Errorf("Interpolate(%q) = %q, %v; want %q", c.in, got, err, c.want)
+		}
+	}
+
+	_, err := Interpolate("${A}/${B:-b}/${C}", lookup)
+	var mv *MissingVarError
+	if !errors.As(err, &mv) || strings.Join(mv.Vars, ",") != "A,C" {
+		t.Fatalf("expected A and C missing, got %v", err)
+	}
+	for _, bad := range []string{"${", "${1X}", "${A B}"} {
+		if _, err := Interpolate(bad, lookup); err == nil || errors.As(err, &mv) {
+			t.Errorf("Interpolate(%q): expected a syntax error, got %v", bad, err)
+		}
+	}
+}
+
+func writeEnv(t *testing.T, env, content string) string {
+	t.Helper()
+	root := t.TempDir()
+	path := Path(root, env)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+const productionYAML = `environment: production
+# comments are not expanded: ${NOT_SET_EITHER}
+database:
+  provider: postgresql
+  host: ${DB_HOST}
+  port: ${DB_PORT:-5432}
+  password: "${DB_PASSWORD}"
+providers:
+  openai:
+    api_key: ${OPENAI_API_KEY}
+  ollama:
+    host: ${OLLAMA_ADDR:-localhost:11434}
+vector_db:
+  api_key: ${PINECONE_API_KEY}
+features:
+  mock_providers: ${MOCK:-false}
+`
+
+func TestLoad_InterpolatesAndTypesValues(t *testing.T) {
+	root := writeEnv(t, "production", productionYAML)
+	t.Setenv("CMP_ENV", "production")
+	t.Setenv("DB_HOST", "db.internal")
+	t.Setenv("DB_PASSWORD", "12345")
+	t.Setenv("MOCK", "true")
+
+	e, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var db struct {
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Password string `yaml:"password"`
+	}
+	if err := e.Section("database", &db); err != nil {
+		t.Fatal(err)
+	}
+	if db.Host != "db.internal" || db.Port != 5432 || db.Password != "12345" {
+		t.Fatalf("unexpected database section %+v", db)
+	}
+	if f, err := e.Features(); err != nil || !f.MockProviders {
+		t.Fatalf("features: %+v, %v", f, err)
+	}
+
+	// OPENAI_API_KEY and PINECONE_API_KEY are unset: only their sections fail
+	var ollama struct {
+		Host string `yaml:"host"`
+	}
+	if ok, err := e.Provider("ollama", &ollama); !ok || err != nil || ollama.Host != "localhost:11434" {
+		t.Fatalf("ollama: %v %v %+v", ok, err, ollama)
+	}
+	var openai map[string]string
+	if _, err := e.Provider("openai", &openai); err == nil || !strings.Contains(err.Error(), "OPENAI_API_KEY") {
+		t.Fatalf("expected unset OPENAI_API_KEY, got %v", err)
+	}
+	if err := e.Section("providers", &openai); err == nil {
+		t.Fatal("expected the providers section to report the unset key")
+	}
+	err = e.Validate()
+	if err == nil || !strings.Contains(err.Error(), "PINECONE_API_KEY") || !strings.Contains(err.Error(), "providers.openai") {
+		t.Fatalf("Validate: %v", err)
+	}
+	if strings.Contains(err.Error(), "NOT_SET_EITHER") {
+		t.Fatalf("comment was interpolated: %v", err)
+	}
+}
+
+func TestLoad_SchemaViolations(t *testing.T) {
+	root := writeEnv(t, "development", `database:
+  port: ${DB_PORT}
+logging:
+  level: verbose
+server:
+  read_timeout: soon
+custom:
+  anything: goes
+`)
+	t.Setenv("CMP_ENV", "")
+	t.Setenv("DB_PORT", "not-a-port")
+	e, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Validate()
+	if err == nil {
+		t.Fatal("expected schema violations")
+	}
+	for _, want := range []string{"database.port", "logging.level", "server.read_timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %s in %v", want, err)
+		}
+	}
+	var custom map[string]string
+	if err := e.Section("custom", &custom); err != nil || custom["anything"] != "goes" {
+		t.Fatalf("sections without a schema decode as-is: %v %v", custom, err)
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	e, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server struct{ MaxBodyBytes int64 }
+	if err := e.Section("server", &server); err != nil || e.Validate() != nil || e.Has("server") {
+		t.Fatalf("empty environment: %v", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Setenv("CHUNK", "256")
+	var m map[string]interface{}
+	if err := Unmarshal([]byte("chunk_size: ${CHUNK}\npath: ${DATA_DIR:-./data}/kb\n"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["chunk_size"] != 256 || m["path"] != "./data/kb" {
+		t.Fatalf("unexpected %v", m)
+	}
+}
+
+// staticBackend serves fixed secrets for the secret:// tests.
+type staticBackend map[string]string
+
+func (b staticBackend) Fetch(ctx context.Context, path string) (secrets.Secret, error) {
+	v, ok := b[path]
+	if !ok {
+		return secrets.Secret{}, errors.New("no such secret")
+	}
+	return secrets.Secret{Data: map[string]string{"value": v}}, nil
+}
+
+func TestLoad_ResolvesSecretReferences(t *testing.T) {
+	r := secrets.NewResolver()
+	r.Register("test", staticBackend{"openai": "sk-from-store", "port": "8443"})
+	secrets.SetDefault(r)
+	defer secrets.SetDefault(nil)
+
+	root := writeEnv(t, "production", `providers:
+  openai:
+    api_key: secret://test/openai#value
+  anthropic:
+    api_key: secret://test/missing
+database:
+  password: ${DB_PASSWORD_REF:-secret://test/port}
+`)
+	t.Setenv("CMP_ENV", "production")
+	e, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var openai struct {
+		APIKey string `yaml:"api_key"`
+	}
+	if ok, err := e.Provider("openai", &openai); !ok || err != nil || openai.APIKey != "sk-from-store" {
+		t.Fatalf("openai: %v %v %+v", ok, err, openai)
+	}
+	var db struct {
+		Password string `yaml:"password"`
+	}
+	if err := e.Section("database", &db); err != nil || db.Password != "8443" {
+		t.Fatalf("database: %v %+v", err, db)
+	}
+	if _, err := e.Provider("anthropic", &openai); err == nil || !strings.Contains(err.Error(), "secret://test/missing") {
+		t.Fatalf("expected the failed reference, got %v", err)
+	}
+}
+
+func TestApply_ExportsSettings(t *testing.T) {
+	root := writeEnv(t, "production", `database:
+  provider: postgresql
+  host: db
+logging:
+  level: warn
+features:
+  local_models: false
+security:
+  auth_enabled: true
+  pii_mode: redact
+providers:
+  huggingface:
+    api_key: hf-token
+    model: org/model
+    endpoint: http://tgi:8080
+    endpoint_type: tgi
+`)
+	for _, name := range []string{"CMP_LOG_LEVEL", "CMP_LOG_FORMAT", "CMP_DB_PROVIDER", "CMP_DB_PATH", "CMP_LOCAL_MODELS", "CMP_MOCK_PROVIDERS", "CMP_OFFLINE_MODE",
+		"CMP_AUTH_ENABLED", "CMP_AUTH_MODE", "CMP_PI_ENFORCEMENT", "CMP_REQUIRE_CITATION", "HF_TOKEN", "HF_MODEL_ID", "HF_ENDPOINT", "HF_ENDPOINT_TYPE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("CMP_ENV", "production")
+	t.Setenv("CMP_PII_MODE", "block")
+	e, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Apply(); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for name, want := range map[string]string{"CMP_LOG_LEVEL": "warn", "CMP_DB_PROVIDER": "postgresql", "CMP_LOCAL_MODELS": "false", "CMP_MOCK_PROVIDERS": "",
+		"CMP_AUTH_ENABLED": "true", "CMP_PII_MODE": "block", "HF_TOKEN": "hf-token", "HF_MODEL_ID": "org/model",
+		"HF_ENDPOINT": "http://tgi:8080", "HF_ENDPOINT_TYPE": "tgi"} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if err := CheckProduction(); err != nil {
+		t.Fatalf("CheckProduction: %v", err)
+	}
+}
+
+func TestCheckProduction(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "")
+	t.Setenv("CMP_AUTH_MODE", "")
+	t.Setenv("CMP_DB_PROVIDER", "sqlite")
+	err := CheckProduction()
+	if err == nil || !strings.Contains(err.Error(), "authentication is disabled") || !strings.Contains(err.Error(), "the database is sqlite") {
+		t.Fatalf("expected both problems, got %v", err)
+	}
+	t.Setenv("CMP_AUTH_MODE", "oidc")
+	t.Setenv("CMP_DB_PROVIDER", "mysql")
+	if err := CheckProduction(); err != nil {
+		t.Fatalf("CheckProduction: %v", err)
+	}
+}
+
+func TestApply_SectionProblems(t *testing.T) {
+	root := writeEnv(t, "production", "database:\n  provider: postgres\n  host: ${DB_HOST_NOT_SET}\n")
+	t.Setenv("CMP_ENV", "production")
+	t.Setenv("CMP_DB_PROVIDER", "")
+	e, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Apply(); err == nil || !strings.Contains(err.Error(), "DB_HOST_NOT_SET") {
+		t.Fatalf("expected the unset variable, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Package runtimeconfig loads the environment configuration of a project,
+// config/environments/$CMP_ENV.yaml (default development).
+//
+// Scalar values may reference environment variables as ${VAR}, or as
+// ${VAR:-default} to fall back when VAR is unset or empty; $${ writes a
+// literal ${. Placeholders are expanded after parsing, so comments are never
+// expanded and a variable's value cannot change the YAML structure. An
+// unquoted scalar that contained a placeholder is re-typed after expansion,
+// so `port: ${DB_PORT}` decodes as an integer.
+//
+// A value that is, after expansion, a secret:// reference is replaced by the
+// secret it names (see package secrets), e.g.
+// `api_key: secret://vault/secret/data/contexis#openai_api_key`.
+//
+// Each known top-level section (server, database, providers, ...) is checked
+// against its JSON Schema in environment_schema.json. Problems are tracked
+// per section: a server reading the `server:` section is not blocked by an
+// unset ${PINECONE_API_KEY} under `vector_db:`. Validate reports every
+// problem at once, which `ctx doctor` uses.
+package runtimeconfig
@@ -0,0 +1,174 @@
+package runtimeconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/contexis-cmp/contexis/src/runtime/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+// LookupFunc resolves a variable name, reporting whether it is set.
+type LookupFunc func(name string) (string, bool)
+
+// MissingVarError reports placeholders without a default whose variables are
+// not set.
+type MissingVarError struct {
+	Vars []string
+}
+
+func (e *MissingVarError) Error() string {
+	return "environment variable(s) not set: " + strings.Join(e.Vars, ", ")
+}
+
+// Interpolate expands ${VAR} and ${VAR:-default} in s using lookup
+// (os.LookupEnv when nil). Unset variables without a default are collected
+// into a *MissingVarError; the result then has them replaced by "".
+func Interpolate(s string, lookup LookupFunc) (string, error) {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	var (
+		b       strings.Builder
+		missing []string
+	)
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		// $${ escapes a literal ${
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q", s)
+		}
+		expr := s[i+2 : i+end]
+		name, def, hasDef := strings.Cut(expr, ":-")
+		if !validVarName(name) {
+			return "", fmt.Errorf("invalid placeholder ${%s}", expr)
+		}
+		b.WriteString(s[:i])
+		v, ok := lookup(name)
+		switch {
+		case hasDef && v == "":
+			b.WriteString(def)
+		case ok:
+			b.WriteString(v)
+		default:
+			missing = append(missing, name)
+		}
+		s = s[i+end+1:]
+	}
+	if len(missing) > 0 {
+		return b.String(), &MissingVarError{Vars: missing}
+	}
+	return b.String(), nil
+}
+
+func validVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// interpolateNode expands placeholders in every scalar under n, including
+// mapping keys. Unquoted scalars that changed lose their tag so the decoder
+// resolves the expanded value (e.g. 5432 becomes an int).
+func interpolateNode(n *yaml.Node, lookup LookupFunc) error {
+	var missing []string
+	var walk func(*yaml.Node) error
+	walk = func(n *yaml.Node) error {
+		if n.Kind == yaml.ScalarNode {
+			if !strings.Contains(n.Value, "${") {
+				return resolveSecret(n)
+			}
+			v, err := Interpolate(n.Value, lookup)
+			var mv *MissingVarError
+			if errors.As(err, &mv) {
+				missing = append(missing, mv.Vars...)
+			} else if err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			n.Value = v
+			if n.Style == 0 && n.Tag == "!!str" {
+				n.Tag = ""
+			}
+			return resolveSecret(n)
+		}
+		for _, c := range n.Content {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(n); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return &MissingVarError{Vars: dedupe(missing)}
+	}
+	return nil
+}
+
+// resolveSecret replaces a secret:// reference with the secret's value,
+// which is always a string.
+func resolveSecret(n *yaml.Node) error {
+	if !secrets.IsRef(n.Value) {
+		return nil
+	}
+	v, err := secrets.Default().Resolve(context.Background(), n.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	n.Value, n.Tag, n.Style = v, "!!str", yaml.DoubleQuotedStyle
+	return nil
+}
+
+// Unmarshal decodes YAML into out like yaml.Unmarshal, expanding
+// placeholders from the process environment and secret:// references first. Component files such as
+// memory_config.yaml use it to reference secrets and paths.
+func Unmarshal(data []byte, out interface{}) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if err := interpolateNode(&doc, nil); err != nil {
+		return err
+	}
+	return doc.Decode(out)
+}
+
+func dedupe(names []string) []string {
+	seen := map[string]bool{}
+	out := names[:0]
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
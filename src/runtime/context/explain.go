@@ -0,0 +1,372 @@
+package runtimecontext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	coreval "github.com/contexis-cmp/contexis/src/core/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Conflict kinds reported by Explain.
+const (
+	// ConflictIncludes: two includes of the same file set a field to different
+	// values, so the result depends on include order.
+	ConflictIncludes = "includes"
+	// ConflictType: a field changes between a map and a scalar or list, which
+	// drops the earlier value entirely.
+	ConflictType = "type"
+)
+
+// FileRef is a file loaded while resolving a context. Explanation.Files
+// lists them in load order; a file's base and includes merge below it.
+type FileRef struct {
+	File   string `json:"file"`
+	Via    string `json:"via"` // context, extends or include
+	Parent string `json:"parent,omitempty"`
+}
+
+// FieldSource records which file set a merged field. Path is dotted, e.g.
+// role.persona. Lists are unioned, so MergedFrom names the other files that
+// contributed items.
+type FieldSource struct {
+	Path       string      `json:"path"`
+	Value      interface{} `json:"value"`
+	File       string      `json:"file"`
+	Overrides  []string    `json:"overrides,omitempty"`
+	MergedFrom []string    `json:"merged_from,omitempty"`
+}
+
+// Conflict is an override that is probably unintended.
+type Conflict struct {
+	Path    string   `json:"path"`
+	Kind    string   `json:"kind"`
+	Files   []string `json:"files"` // earlier file, then the file whose value won
+	Message string   `json:"message"`
+
+	parent string // file whose includes conflict; its own value resolves it
+}
+
+// Explanation is a fully merged context with the provenance of each field.
+type Explanation struct {
+	Context   string                 `json:"context"`
+	TenantID  string                 `json:"tenant_id,omitempty"`
+	File      string                 `json:"file"`
+	Files     []FileRef              `json:"files"`
+	Merged    map[string]interface{} `json:"merged"`
+	Fields    []FieldSource          `json:"fields"`
+	Conflicts []Conflict             `json:"conflicts,omitempty"`
+	// Error is set when the merged context fails validation.
+	Error string `json:"error,omitempty"`
+}
+
+// Explain resolves a context like ResolveContext, without caching, and
+// reports which file contributed each field and any conflicting overrides.
+func (s *ContextService) Explain(tenantID, contextName string) (*Explanation, error) {
+	if contextName == "" {
+		return nil, fmt.Errorf("context name is required")
+	}
+	var path string
+	for _, p := range s.candidatePaths(tenantID, contextName) {
+		if _, err := os.Stat(p); err == nil {
+			path = p
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("read context '%s': %w", p, err)
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("context '%s' not found", contextName)
+	}
+	tr := &mergeTrace{root: s.projectRoot, origins: map[string]*FieldSource{}, scopeOf: map[string][]string{}}
+	tr.enter(path, "context", "", -1)
+	merged, err := s.loadAndMergeYAML(path, nil, tr)
+	if err != nil {
+		return nil, fmt.Errorf("merge failed for '%s': %w", tr.rel(path), err)
+	}
+	ex := &Explanation{Context: contextName, TenantID: tenantID, File: tr.rel(path), Files: tr.files, Merged: merged, Conflicts: tr.conflicts}
+	for p, src := range tr.origins {
+		v, ok := lookupPath(merged, p)
+		if !ok {
+			continue
+		}
+		src.Value = v
+		ex.Fields = append(ex.Fields, *src)
+	}
+	sort.Slice(ex.Fields, func(i, j int) bool { return ex.Fields[i].Path < ex.Fields[j].Path })
+	ex.Error = validateMerged(path, merged)
+	return ex, nil
+}
+
+// validateMerged runs the checks ResolveContext applies and returns the first
+// failure, or "".
+func validateMerged(path string, merged map[string]interface{}) string {
+	if raw, err := os.ReadFile(path); err == nil {
+		if err := coreval.ValidateContextYAML(raw); err != nil {
+			return fmt.Sprintf("schema validation failed: %v", err)
+		}
+	}
+	by, err := yamlToJSON(merged)
+	if err != nil {
+		return err.Error()
+	}
+	model, err := corectx.FromJSON(by)
+	if err != nil {
+		return fmt.Sprintf("parse context model: %v", err)
+	}
+	if err := model.Validate(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Source returns the provenance of a field, or nil.
+func (e *Explanation) Source(path string) *FieldSource {
+	for i := range e.Fields {
+		if e.Fields[i].Path == path {
+			return &e.Fields[i]
+		}
+	}
+	return nil
+}
+
+// AnnotatedYAML renders the merged context with a comment naming the file
+// behind each field.
+func (e *Explanation) AnnotatedYAML() ([]byte, error) {
+	node, err := e.annotate("", e.Merged)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+func (e *Explanation) annotate(prefix string, m map[string]interface{}) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range sortedKeys(m) {
+		path := joinPath(prefix, k)
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: k}
+		var val *yaml.Node
+		if sub, ok := m[k].(map[string]interface{}); ok && len(sub) > 0 {
+			var err error
+			if val, err = e.annotate(path, sub); err != nil {
+				return nil, err
+			}
+		} else {
+			val = &yaml.Node{}
+			if err := val.Encode(m[k]); err != nil {
+				return nil, err
+			}
+			if src := e.Source(path); src != nil {
+				key.LineComment = src.describe()
+			}
+		}
+		node.Content = append(node.Content, key, val)
+	}
+	return node, nil
+}
+
+// describe is the provenance comment for a field.
+func (f *FieldSource) describe() string {
+	s := f.File
+	if len(f.Overrides) > 0 {
+		s += " (overrides " + strings.Join(f.Overrides, ", ") + ")"
+	}
+	if len(f.MergedFrom) > 0 {
+		s += " (merged with " + strings.Join(f.MergedFrom, ", ") + ")"
+	}
+	return s
+}
+
+// mergeTrace follows loadAndMergeYAML, which loads a file's base, then its
+// includes, then its own fields, and records the file behind each leaf
+// field. Maps are recursed into; scalars and lists are leaves. All methods
+// are no-ops on a nil trace.
+type mergeTrace struct {
+	root      string
+	files     []FileRef
+	scopes    []string                // include being loaded at each nesting level ("parent#index"), "" for extends
+	origins   map[string]*FieldSource // by dotted path
+	scopeOf   map[string][]string     // scopes in effect when each origin was set
+	conflicts []Conflict
+}
+
+func (t *mergeTrace) rel(path string) string {
+	if path == "" {
+		return ""
+	}
+	if r, err := filepath.Rel(t.root, path); err == nil && !strings.HasPrefix(r, "..") {
+		return filepath.ToSlash(r)
+	}
+	return path
+}
+
+// enter records that file is loaded via extends or include (index >= 0) of parent.
+func (t *mergeTrace) enter(file, via, parent string, index int) {
+	if t == nil {
+		return
+	}
+	t.files = append(t.files, FileRef{File: t.rel(file), Via: via, Parent: t.rel(parent)})
+	scope := ""
+	if index >= 0 {
+		scope = fmt.Sprintf("%s#%d", t.rel(parent), index)
+	}
+	t.scopes = append(t.scopes, scope)
+}
+
+func (t *mergeTrace) leave() {
+	if t == nil || len(t.scopes) == 0 {
+		return
+	}
+	t.scopes = t.scopes[:len(t.scopes)-1]
+}
+
+// apply records a file's own fields, which override everything loaded so far.
+func (t *mergeTrace) apply(file string, fields map[string]interface{}) {
+	if t == nil {
+		return
+	}
+	name := t.rel(file)
+	for _, k := range sortedKeys(fields) {
+		if k == "extends" || k == "include" {
+			continue
+		}
+		t.set(k, fields[k], name)
+	}
+}
+
+func (t *mergeTrace) set(path string, v interface{}, file string) {
+	if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+		if prev, ok := t.origins[path]; ok {
+			t.addConflict(Conflict{Path: path, Kind: ConflictType, Files: []string{prev.File, file},
+				Message: fmt.Sprintf("%s replaces the %s from %s with a map", file, kindOf(prev.Value), prev.File)})
+			delete(t.origins, path)
+		}
+		for _, k := range sortedKeys(m) {
+			t.set(joinPath(path, k), m[k], file)
+		}
+		return
+	}
+	// A scalar or list replacing a map drops all of the map's fields
+	var dropped []string
+	for p, src := range t.origins {
+		if strings.HasPrefix(p, path+".") {
+			dropped = append(dropped, src.File)
+			delete(t.origins, p)
+		}
+	}
+	if len(dropped) > 0 {
+		t.addConflict(Conflict{Path: path, Kind: ConflictType, Files: []string{uniqueStrings(dropped)[0], file},
+			Message: fmt.Sprintf("%s replaces a map with a %s, dropping fields set by %s", file, kindOf(v), strings.Join(uniqueStrings(dropped), ", "))})
+	}
+	scope := append([]string(nil), t.scopes...)
+	src := &FieldSource{Path: path, Value: v, File: file}
+	if prev, ok := t.origins[path]; ok {
+		_, isList := v.([]interface{})
+		_, wasList := prev.Value.([]interface{})
+		switch {
+		case isList && wasList:
+			src.MergedFrom = append(append([]string(nil), prev.MergedFrom...), prev.File)
+			src.Overrides = prev.Overrides
+		case reflect.DeepEqual(prev.Value, v):
+			// Restating the same value keeps the original attribution
+			return
+		default:
+			src.Overrides = append(append([]string(nil), prev.Overrides...), prev.File)
+			if parent, ok := siblingIncludes(t.scopeOf[path], scope); ok {
+				t.addConflict(Conflict{Path: path, Kind: ConflictIncludes, Files: []string{prev.File, file}, parent: parent,
+					Message: fmt.Sprintf("%s and %s (both included by %s) set different values; %s wins by include order", prev.File, file, parent, file)})
+			}
+		}
+		// A file's own value settles a conflict between its includes
+		kept := t.conflicts[:0]
+		for _, c := range t.conflicts {
+			if !(c.Path == path && c.Kind == ConflictIncludes && c.parent == file) {
+				kept = append(kept, c)
+			}
+		}
+		t.conflicts = kept
+	}
+	t.origins[path] = src
+	t.scopeOf[path] = scope
+}
+
+func (t *mergeTrace) addConflict(c Conflict) {
+	t.conflicts = append(t.conflicts, c)
+}
+
+// siblingIncludes reports whether two scope stacks first differ at includes
+// of the same parent, returning that parent.
+func siblingIncludes(a, b []string) (string, bool) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		pa, pb := a[i], b[i]
+		if j := strings.LastIndex(pa, "#"); j >= 0 {
+			pa = pa[:j]
+		}
+		if j := strings.LastIndex(pb, "#"); j >= 0 {
+			pb = pb[:j]
+		}
+		if a[i] != "" && b[i] != "" && pa == pb {
+			return pa, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	default:
+		return "scalar"
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func lookupPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var cur interface{} = m
+	for _, k := range strings.Split(path, ".") {
+		mm, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = mm[k]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package runtimecontext
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplain_ProvenanceAndConflicts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, filepath.Join("contexts", "Foo", "base.yaml"), `name: "Base"
+version: "1.0.0"
+role:
+  persona: "Base Persona"
+  capabilities: ["a"]
+guardrails:
+  tone: "neutral"
+`)
+	writeFile(t, root, filepath.Join("contexts", "Foo", "formal.yaml"), `guardrails:
+  tone: "formal"
+  max_tokens: 500
+`)
+	writeFile(t, root, filepath.Join("contexts", "Foo", "casual.yaml"), `guardrails:
+  tone: "casual"
+  format: "markdown"
+`)
+	writeFile(t, root, filepath.Join("contexts", "Foo", "Foo.ctx"), `extends: base.yaml
+include: [formal.yaml, casual.yaml]
+name: "Foo"
+version: "1.0.0"
+role:
+  persona: "Main Persona"
+  capabilities: ["b"]
+`)
+
+	ex, err := NewContextService(root).Explain("", "Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.File != "contexts/Foo/Foo.ctx" || len(ex.Files) != 4 || ex.Files[1].Via != "extends" || ex.Files[2].Parent != "contexts/Foo/Foo.ctx" {
+		t.Fatalf("unexpected files %+v", ex.Files)
+	}
+	persona := ex.Source("role.persona")
+	if persona == nil || persona.File != "contexts/Foo/Foo.ctx" || persona.Value != "Main Persona" || len(persona.Overrides) != 1 || persona.Overrides[0] != "contexts/Foo/base.yaml" {
+		t.Fatalf("role.persona source = %+v", persona)
+	}
+	caps := ex.Source("role.capabilities")
+	if caps == nil || len(caps.Value.([]interface{})) != 2 || len(caps.MergedFrom) != 1 {
+		t.Fatalf("role.capabilities source = %+v", caps)
+	}
+	if src := ex.Source("guardrails.max_tokens"); src == nil || src.File != "contexts/Foo/formal.yaml" {
+		t.Fatalf("guardrails.max_tokens source = %+v", src)
+	}
+	if len(ex.Conflicts) != 1 || ex.Conflicts[0].Path != "guardrails.tone" || ex.Conflicts[0].Kind != ConflictIncludes || ex.Conflicts[0].Files[1] != "contexts/Foo/casual.yaml" {
+		t.Fatalf("conflicts = %+v", ex.Conflicts)
+	}
+	if ex.Error != "" {
+		t.Fatalf("unexpected validation error %s", ex.Error)
+	}
+	out, err := ex.AnnotatedYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "persona: Main Persona # contexts/Foo/Foo.ctx (overrides contexts/Foo/base.yaml)") {
+		t.Fatalf("missing provenance comment:\n%s", out)
+	}
+
+	// The including file's own value settles the conflict
+	writeFile(t, root, filepath.Join("contexts", "Foo", "Foo.ctx"), `extends: base.yaml
+include: [formal.yaml, casual.yaml]
+name: "Foo"
+version: "1.0.0"
+role:
+  persona: "Main Persona"
+guardrails:
+  tone: "friendly"
+`)
+	if ex, err = NewContextService(root).Explain("", "Foo"); err != nil || len(ex.Conflicts) != 0 {
+		t.Fatalf("conflicts = %+v, %v", ex.Conflicts, err)
+	}
+}
+
+func TestExplain_TypeConflictAndCycle(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, filepath.Join("contexts", "Foo", "base.yaml"), `name: "Base"
+version: "1.0.0"
+role:
+  persona: "Base"
+memory:
+  episodic: true
+`)
+	writeFile(t, root, filepath.Join("contexts", "Foo", "Foo.ctx"), `extends: base.yaml
+name: "Foo"
+version: "1.0.0"
+role:
+  persona: "Foo"
+memory: "none"
+`)
+	ex, err := NewContextService(root).Explain("", "Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Conflicts) != 1 || ex.Conflicts[0].Kind != ConflictType || ex.Conflicts[0].Path != "memory" {
+		t.Fatalf("conflicts = %+v", ex.Conflicts)
+	}
+
+	writeFile(t, root, filepath.Join("contexts", "Foo", "base.yaml"), "extends: Foo.ctx\nname: Base\n")
+	if _, err := NewContextService(root).Explain("", "Foo"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if _, err := NewContextService(root).ResolveContext("", "Foo"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -52,45 +53,14 @@ func (s *ContextService) ResolveContext(tenantID, contextName string) (*corectx.
 	var loaded *corectx.Context
 	var loadErr error
 	for _, p := range candidatePaths {
-		data, err := os.ReadFile(p)
+		ctxModel, err := s.loadFile(p)
 		if err != nil {
 			if errors.Is(err, fs.ErrNotExist) {
 				continue
 			}
-			loadErr = fmt.Errorf("read context '%s': %w", p, err)
+			loadErr = err
 			continue
 		}
-
-		// Validate against schema (lightweight)
-		if err := coreval.ValidateContextYAML(data); err != nil {
-			loadErr = fmt.Errorf("schema validation failed for '%s': %w", p, err)
-			continue
-		}
-
-		// Process extends/include and decode into model
-		mergedMap, err := s.loadAndMergeYAML(p, 0)
-		if err != nil {
-			loadErr = fmt.Errorf("merge failed for '%s': %w", p, err)
-			continue
-		}
-
-		jsonBytes, err := yamlToJSON(mergedMap)
-		if err != nil {
-			loadErr = fmt.Errorf("yaml->json failed for '%s': %w", p, err)
-			continue
-		}
-
-		ctxModel, err := corectx.FromJSON(jsonBytes)
-		if err != nil {
-			loadErr = fmt.Errorf("parse context model for '%s': %w", p, err)
-			continue
-		}
-
-		if err := ctxModel.Validate(); err != nil {
-			loadErr = fmt.Errorf("context validation for '%s': %w", p, err)
-			continue
-		}
-
 		loaded = ctxModel
 		break
 	}
@@ -109,6 +79,44 @@ func (s *ContextService) ResolveContext(tenantID, contextName string) (*corectx.
 	return loaded, nil
 }
 
+// loadFile reads, validates and merges the context file at path. A missing
+// file returns an error wrapping fs.ErrNotExist.
+func (s *ContextService) loadFile(p string) (*corectx.Context, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("read context '%s': %w", p, err)
+	}
+
+	// Validate against schema (lightweight)
+	if err := coreval.ValidateContextYAML(data); err != nil {
+		return nil, fmt.Errorf("schema validation failed for '%s': %w", p, err)
+	}
+
+	// Process extends/include and decode into model
+	mergedMap, err := s.loadAndMergeYAML(p, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("merge failed for '%s': %w", p, err)
+	}
+
+	jsonBytes, err := yamlToJSON(mergedMap)
+	if err != nil {
+		return nil, fmt.Errorf("yaml->json failed for '%s': %w", p, err)
+	}
+
+	ctxModel, err := corectx.FromJSON(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse context model for '%s': %w", p, err)
+	}
+
+	if err := ctxModel.Validate(); err != nil {
+		return nil, fmt.Errorf("context validation for '%s': %w", p, err)
+	}
+	return ctxModel, nil
+}
+
 // ReloadContext clears the cache so subsequent calls re-read from disk.
 // If a path is supplied, this is currently a no-op beyond clearing the cache.
 func (s *ContextService) ReloadContext(_ string) error {
@@ -118,6 +126,37 @@ func (s *ContextService) ReloadContext(_ string) error {
 	return nil
 }
 
+// ContextNames lists the contexts that can be resolved for tenantID: each
+// directory under contexts/ holding a .ctx file, plus the tenant's overrides.
+// Names are sorted; whether each one resolves is not checked.
+func (s *ContextService) ContextNames(tenantID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.projectRoot, "contexts"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == "tenants" {
+			continue
+		}
+		if ctxs, _ := filepath.Glob(filepath.Join(s.projectRoot, "contexts", e.Name(), "*.ctx")); len(ctxs) > 0 {
+			names = append(names, e.Name())
+		}
+	}
+	if tenantID != "" {
+		overrides, _ := filepath.Glob(filepath.Join(s.projectRoot, "contexts", "tenants", sanitizePath(tenantID), "*.ctx"))
+		for _, o := range overrides {
+			names = append(names, strings.TrimSuffix(filepath.Base(o), ".ctx"))
+		}
+	}
+	names = uniqueStrings(names)
+	sort.Strings(names)
+	return names, nil
+}
+
 // candidatePaths computes possible file paths for a given tenant and context.
 func (s *ContextService) candidatePaths(tenantID, contextName string) []string {
 	var paths []string
@@ -143,6 +182,10 @@ func (s *ContextService) candidatePaths(tenantID, contextName string) []string {
 			return nil
 		}
 		if d.IsDir() {
+			// Registered versions are only served through rollouts
+			if path != globalDir && d.Name() == VersionsDir {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if strings.HasSuffix(strings.ToLower(d.Name()), ".ctx") {
@@ -155,8 +198,15 @@ func (s *ContextService) candidatePaths(tenantID, contextName string) []string {
 }
 
 // loadAndMergeYAML loads a YAML file, processes extends/include recursively, and returns a merged map.
-func (s *ContextService) loadAndMergeYAML(path string, depth int) (map[string]interface{}, error) {
-	if depth > 5 {
+// stack lists the files whose extends/include led here; tr, when set, records
+// where each merged field came from (see Explain).
+func (s *ContextService) loadAndMergeYAML(path string, stack []string, tr *mergeTrace) (map[string]interface{}, error) {
+	for i, p := range stack {
+		if p == path {
+			return nil, fmt.Errorf("extends/include cycle: %s", strings.Join(append(stack[i:], path), " -> "))
+		}
+	}
+	if len(stack) > 5 {
 		return nil, fmt.Errorf("maximum extends/include depth exceeded at %s", path)
 	}
 	raw, err := os.ReadFile(path)
@@ -167,6 +217,7 @@ func (s *ContextService) loadAndMergeYAML(path string, depth int) (map[string]in
 	if err := yaml.Unmarshal(raw, &current); err != nil {
 		return nil, err
 	}
+	stack = append(stack, path)
 
 	// Read meta: extends and include
 	extendsVal, _ := current["extends"].(string)
@@ -175,11 +226,13 @@ func (s *ContextService) loadAndMergeYAML(path string, depth int) (map[string]in
 	var base map[string]interface{}
 	if extendsVal != "" {
 		basePath := s.resolveRelative(path, extendsVal)
+		tr.enter(basePath, "extends", path, -1)
 		var err error
-		base, err = s.loadAndMergeYAML(basePath, depth+1)
+		base, err = s.loadAndMergeYAML(basePath, stack, tr)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load base '%s': %w", basePath, err)
 		}
+		tr.leave()
 	}
 
 	// Apply includes
@@ -188,21 +241,24 @@ func (s *ContextService) loadAndMergeYAML(path string, depth int) (map[string]in
 		merged = deepCopyMap(base)
 	}
 	if len(includeVal) > 0 {
-		for _, inc := range includeVal {
+		for i, inc := range includeVal {
 			incStr, _ := inc.(string)
 			if incStr == "" {
 				continue
 			}
 			incPath := s.resolveRelative(path, incStr)
-			frag, err := s.loadAndMergeYAML(incPath, depth+1)
+			tr.enter(incPath, "include", path, i)
+			frag, err := s.loadAndMergeYAML(incPath, stack, tr)
 			if err != nil {
 				return nil, fmt.Errorf("failed to load include '%s': %w", incPath, err)
 			}
+			tr.leave()
 			merged = DeepMerge(merged, frag)
 		}
 	}
 
 	// Merge current over the accumulated base/includes
+	tr.apply(path, current)
 	merged = DeepMerge(merged, current)
 	// Remove meta keys from final
 	delete(merged, "extends")
@@ -0,0 +1,313 @@
+package runtimecontext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenantOverridableFields lists the fields a tenant override may set, as
+// dotted paths; a path also covers the fields below it. Identity (name,
+// version) and testing stay with the base context.
+var TenantOverridableFields = []string{
+	"description",
+	"role.persona",
+	"role.capabilities",
+	"role.limitations",
+	"tools",
+	"guardrails",
+	"memory",
+	"retrieval",
+}
+
+var tenantIDRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidateTenantID rejects tenant IDs that are not safe as a directory name.
+func ValidateTenantID(tenantID string) error {
+	if !tenantIDRe.MatchString(tenantID) || strings.Contains(tenantID, "..") {
+		return fmt.Errorf("invalid tenant id %q (letters, digits, '.', '_' and '-', up to 64)", tenantID)
+	}
+	return nil
+}
+
+// TenantOverride is a tenant-specific context at
+// contexts/tenants/<tenant>/<context>.ctx.
+type TenantOverride struct {
+	TenantID string `json:"tenant_id"`
+	Context  string `json:"context"`
+	File     string `json:"file"`
+	// Fields are the paths whose effective value comes from the override.
+	Fields []string `json:"fields,omitempty"`
+	// Violations are overridden fields outside TenantOverridableFields.
+	Violations []string `json:"violations,omitempty"`
+	// Error is set when the override cannot be resolved or is invalid.
+	Error string `json:"error,omitempty"`
+}
+
+// Valid reports whether the override resolves and only sets allowed fields.
+func (o *TenantOverride) Valid() bool {
+	return o.Error == "" && len(o.Violations) == 0
+}
+
+// FieldChange is a difference between the base and a tenant's effective
+// context. Kind is added, changed or removed.
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Kind   string      `json:"kind"`
+	Base   interface{} `json:"base,omitempty"`
+	Tenant interface{} `json:"tenant,omitempty"`
+}
+
+// TenantOverridePath is where a tenant's override of a context lives.
+func (s *ContextService) TenantOverridePath(tenantID, contextName string) string {
+	return filepath.Join(s.projectRoot, "contexts", "tenants", sanitizePath(tenantID), contextName+".ctx")
+}
+
+// HasTenantOverride reports whether tenantID has its own file for contextName.
+func (s *ContextService) HasTenantOverride(tenantID, contextName string) bool {
+	if tenantID == "" {
+		return false
+	}
+	_, err := os.Stat(s.TenantOverridePath(tenantID, contextName))
+	return err == nil
+}
+
+// basePath returns the shared context file for contextName.
+func (s *ContextService) basePath(contextName string) (string, error) {
+	for _, p := range s.candidatePaths("", contextName) {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("context '%s' not found", contextName)
+}
+
+// ScaffoldTenantOverride writes an override for tenantID that extends the
+// base context and restates the fields every .ctx needs, and returns its
+// path. An existing override is left alone.
+func (s *ContextService) ScaffoldTenantOverride(tenantID, contextName string) (string, error) {
+	if err := ValidateTenantID(tenantID); err != nil {
+		return "", err
+	}
+	base, err := s.basePath(contextName)
+	if err != nil {
+		return "", err
+	}
+	path := s.TenantOverridePath(tenantID, contextName)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("tenant override %s already exists", s.rel(path))
+	}
+	merged, err := s.loadAndMergeYAML(base, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("merge failed for '%s': %w", s.rel(base), err)
+	}
+	extends, err := filepath.Rel(filepath.Dir(path), base)
+	if err != nil {
+		return "", err
+	}
+	// Overrides are committed, so keep the reference portable across platforms
+	extends = filepath.ToSlash(extends)
+	persona, _ := lookupPath(merged, "role.persona")
+	header := struct {
+		Extends string                 `yaml:"extends"`
+		Name    interface{}            `yaml:"name"`
+		Version interface{}            `yaml:"version"`
+		Role    map[string]interface{} `yaml:"role"`
+	}{filepath.ToSlash(extends), merged["name"], merged["version"], map[string]interface{}{"persona": persona}}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Overrides of %s for tenant %s.\n", s.rel(base), tenantID)
+	fmt.Fprintf(&buf, "# Fields set here replace the base values; lists are merged.\n")
+	fmt.Fprintf(&buf, "# Tenants may override: %s.\n", strings.Join(TenantOverridableFields, ", "))
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(header); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	buf.WriteString("# guardrails:\n#   tone: friendly\n#   max_tokens: 300\n")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CheckTenantOverride resolves a tenant's override and reports the fields it
+// sets and any it is not allowed to set. The returned error is for a missing
+// override; resolution problems are reported in TenantOverride.Error.
+func (s *ContextService) CheckTenantOverride(tenantID, contextName string) (*TenantOverride, error) {
+	path := s.TenantOverridePath(tenantID, contextName)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no override of '%s' for tenant %s", contextName, tenantID)
+		}
+		return nil, err
+	}
+	o := &TenantOverride{TenantID: tenantID, Context: contextName, File: s.rel(path)}
+	if err := s.checkExtendsBase(path, contextName); err != nil {
+		o.Error = err.Error()
+		return o, nil
+	}
+	ex, err := s.Explain(tenantID, contextName)
+	if err != nil {
+		o.Error = err.Error()
+		return o, nil
+	}
+	if ex.File != o.File {
+		// Explain fell back to the base context
+		o.Error = fmt.Sprintf("override did not resolve; got %s", ex.File)
+		return o, nil
+	}
+	o.Error = ex.Error
+	dir := filepath.ToSlash(filepath.Dir(o.File)) + "/"
+	for _, f := range ex.Fields {
+		if !strings.HasPrefix(f.File, dir) {
+			continue
+		}
+		o.Fields = append(o.Fields, f.Path)
+		if !tenantOverridable(f.Path) {
+			o.Violations = append(o.Violations, f.Path)
+		}
+	}
+	return o, nil
+}
+
+// checkExtendsBase requires an override to extend the base context, so the
+// tenant's result is the base plus the override.
+func (s *ContextService) checkExtendsBase(path, contextName string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+	base, err := s.basePath(contextName)
+	if err != nil {
+		return err
+	}
+	extends, _ := m["extends"].(string)
+	if extends == "" {
+		return fmt.Errorf("override must extend the base context (extends: %s)", s.rel(base))
+	}
+	if got := s.resolveRelative(path, extends); got != base {
+		return fmt.Errorf("override extends %s, not the base context %s", s.rel(got), s.rel(base))
+	}
+	return nil
+}
+
+func tenantOverridable(path string) bool {
+	for _, allowed := range TenantOverridableFields {
+		if path == allowed || strings.HasPrefix(path, allowed+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTenantOverrides checks every tenant override, or only tenantID's when
+// set, sorted by tenant then context.
+func (s *ContextService) ListTenantOverrides(tenantID string) ([]TenantOverride, error) {
+	pattern := filepath.Join(s.projectRoot, "contexts", "tenants", "*", "*.ctx")
+	if tenantID != "" {
+		pattern = filepath.Join(s.projectRoot, "contexts", "tenants", sanitizePath(tenantID), "*.ctx")
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var out []TenantOverride
+	for _, m := range matches {
+		tenant := filepath.Base(filepath.Dir(m))
+		o, err := s.CheckTenantOverride(tenant, strings.TrimSuffix(filepath.Base(m), ".ctx"))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *o)
+	}
+	return out, nil
+}
+
+// DiffTenant compares the base context with a tenant's effective context,
+// field by field.
+func (s *ContextService) DiffTenant(tenantID, contextName string) ([]FieldChange, error) {
+	base, err := s.basePath(contextName)
+	if err != nil {
+		return nil, err
+	}
+	baseMap, err := s.loadAndMergeYAML(base, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("merge failed for '%s': %w", s.rel(base), err)
+	}
+	ex, err := s.Explain(tenantID, contextName)
+	if err != nil {
+		return nil, err
+	}
+	before, after := map[string]interface{}{}, map[string]interface{}{}
+	flattenLeaves("", baseMap, before)
+	flattenLeaves("", ex.Merged, after)
+	var changes []FieldChange
+	for p, v := range after {
+		prev, ok := before[p]
+		switch {
+		case !ok:
+			changes = append(changes, FieldChange{Path: p, Kind: "added", Tenant: v})
+		case !reflect.DeepEqual(prev, v):
+			changes = append(changes, FieldChange{Path: p, Kind: "changed", Base: prev, Tenant: v})
+		}
+	}
+	for p, v := range before {
+		if _, ok := after[p]; !ok {
+			changes = append(changes, FieldChange{Path: p, Kind: "removed", Base: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// RemoveTenantOverride deletes a tenant's override, and the tenant's
+// directory once it is empty.
+func (s *ContextService) RemoveTenantOverride(tenantID, contextName string) error {
+	path := s.TenantOverridePath(tenantID, contextName)
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no override of '%s' for tenant %s", contextName, tenantID)
+		}
+		return err
+	}
+	_ = os.Remove(filepath.Dir(path)) // fails while other overrides remain
+	return nil
+}
+
+func (s *ContextService) rel(path string) string {
+	return (&mergeTrace{root: s.projectRoot}).rel(path)
+}
+
+// flattenLeaves maps dotted paths to the scalars and lists in m.
+func flattenLeaves(prefix string, m map[string]interface{}, out map[string]interface{}) {
+	for k, v := range m {
+		path := joinPath(prefix, k)
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			flattenLeaves(path, sub, out)
+			continue
+		}
+		out[path] = v
+	}
+}
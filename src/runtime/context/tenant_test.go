@@ -0,0 +1,89 @@
+package runtimecontext
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTenantOverride_ScaffoldCheckDiffRemove(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, filepath.Join("contexts", "Foo", "foo.ctx"), `name: "Foo"
+version: "1.0.0"
+role:
+  persona: "Base Persona"
+  capabilities: ["a"]
+guardrails:
+  tone: "neutral"
+testing:
+  drift_threshold: 0.8
+`)
+	svc := NewContextService(root)
+	if _, err := svc.ScaffoldTenantOverride("../evil", "Foo"); err == nil {
+		t.Fatal("expected invalid tenant id error")
+	}
+	path, err := svc.ScaffoldTenantOverride("acme", "Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(raw), "extends: ../../Foo/foo.ctx") {
+		t.Fatalf("unexpected scaffold:\n%s", raw)
+	}
+	if _, err := svc.ScaffoldTenantOverride("acme", "Foo"); err == nil {
+		t.Fatal("expected error for an existing override")
+	}
+
+	// The scaffold resolves to the base context unchanged
+	o, err := svc.CheckTenantOverride("acme", "Foo")
+	if err != nil || !o.Valid() || len(o.Fields) != 0 {
+		t.Fatalf("check = %+v, %v", o, err)
+	}
+	if changes, err := svc.DiffTenant("acme", "Foo"); err != nil || len(changes) != 0 {
+		t.Fatalf("diff = %+v, %v", changes, err)
+	}
+
+	override := strings.Replace(string(raw), "persona: Base Persona", "persona: Acme Persona\n  capabilities: [b]", 1)
+	writeFile(t, root, filepath.Join("contexts", "tenants", "acme", "Foo.ctx"), override+`guardrails:
+  tone: "casual"
+  max_tokens: 200
+testing:
+  drift_threshold: 0.5
+`)
+	o, err = svc.CheckTenantOverride("acme", "Foo")
+	if err != nil || o.Error != "" {
+		t.Fatalf("check = %+v, %v", o, err)
+	}
+	if len(o.Violations) != 1 || o.Violations[0] != "testing.drift_threshold" || len(o.Fields) != 5 {
+		t.Fatalf("fields %v, violations %v", o.Fields, o.Violations)
+	}
+	changes, err := svc.DiffTenant("acme", "Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, c := range changes {
+		kinds[c.Path] = c.Kind
+	}
+	if kinds["guardrails.tone"] != "changed" || kinds["guardrails.max_tokens"] != "added" || kinds["role.capabilities"] != "changed" || len(changes) != 5 {
+		t.Fatalf("diff = %+v", changes)
+	}
+
+	// Overrides must extend the base context
+	writeFile(t, root, filepath.Join("contexts", "tenants", "beta", "Foo.ctx"), "name: Foo\nversion: \"1.0.0\"\nrole:\n  persona: Standalone\n")
+	list, err := svc.ListTenantOverrides("")
+	if err != nil || len(list) != 2 || list[1].TenantID != "beta" || !strings.Contains(list[1].Error, "must extend") {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+
+	if err := svc.RemoveTenantOverride("acme", "Foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "contexts", "tenants", "acme")); !os.IsNotExist(err) {
+		t.Fatalf("expected empty tenant directory removed, got %v", err)
+	}
+	if err := svc.RemoveTenantOverride("acme", "Foo"); err == nil {
+		t.Fatal("expected error removing a missing override")
+	}
+}
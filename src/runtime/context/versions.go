@@ -0,0 +1,123 @@
+package runtimecontext
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"gopkg.in/yaml.v3"
+)
+
+// VersionsDir (contexts/<Name>/versions) holds the registered versions of a
+// context, one self-contained <version>.ctx per version. They are served only
+// through rollouts, never by ResolveContext.
+const VersionsDir = "versions"
+
+var versionRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.+-]{0,63}$`)
+
+// ValidateVersion rejects context versions that are not safe as a file name.
+func ValidateVersion(version string) error {
+	if !versionRe.MatchString(version) || strings.Contains(version, "..") {
+		return fmt.Errorf("invalid context version %q (letters, digits, '.', '_', '+' and '-', up to 64)", version)
+	}
+	return nil
+}
+
+// VersionPath is where a registered version of a context lives.
+func (s *ContextService) VersionPath(contextName, version string) string {
+	return filepath.Join(s.projectRoot, "contexts", sanitizePath(contextName), VersionsDir, version+".ctx")
+}
+
+// RegisterVersion snapshots a context file as a version of contextName and
+// returns the version, taken from the file's version field. path defaults to
+// the shared context file. extends and include are merged into the snapshot,
+// so later edits to the files it was built from do not change a registered
+// version. An existing version is only replaced when replace is set.
+func (s *ContextService) RegisterVersion(contextName, path string, replace bool) (string, error) {
+	if path == "" {
+		base, err := s.basePath(contextName)
+		if err != nil {
+			return "", err
+		}
+		path = base
+	}
+	ctxModel, err := s.loadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if ctxModel.Name != contextName {
+		return "", fmt.Errorf("%s defines context '%s', not '%s'", path, ctxModel.Name, contextName)
+	}
+	if err := ValidateVersion(ctxModel.Version); err != nil {
+		return "", err
+	}
+	target := s.VersionPath(contextName, ctxModel.Version)
+	if _, err := os.Stat(target); err == nil && !replace {
+		return "", fmt.Errorf("version %s of context '%s' is already registered", ctxModel.Version, contextName)
+	}
+	merged, err := s.loadAndMergeYAML(path, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("merge failed for '%s': %w", path, err)
+	}
+	body, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	header := fmt.Sprintf("# Version %s of %s, registered from %s. Do not edit: register a new version instead.\n", ctxModel.Version, contextName, s.rel(path))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(target, append([]byte(header), body...), 0o644); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	delete(s.cache, contextName+"@"+ctxModel.Version)
+	s.mu.Unlock()
+	return ctxModel.Version, nil
+}
+
+// ResolveVersion loads a registered version of contextName.
+func (s *ContextService) ResolveVersion(contextName, version string) (*corectx.Context, error) {
+	if err := ValidateVersion(version); err != nil {
+		return nil, err
+	}
+	key := contextName + "@" + version
+	s.mu.RLock()
+	if ctx, ok := s.cache[key]; ok {
+		s.mu.RUnlock()
+		return ctx, nil
+	}
+	s.mu.RUnlock()
+
+	loaded, err := s.loadFile(s.VersionPath(contextName, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("version %s of context '%s' is not registered", version, contextName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cache[key] = loaded
+	s.mu.Unlock()
+	return loaded, nil
+}
+
+// Versions lists the registered versions of contextName, sorted by name.
+func (s *ContextService) Versions(contextName string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.projectRoot, "contexts", sanitizePath(contextName), VersionsDir, "*.ctx"))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(files))
+	for _, f := range files {
+		versions = append(versions, strings.TrimSuffix(filepath.Base(f), ".ctx"))
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
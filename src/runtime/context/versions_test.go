@@ -0,0 +1,52 @@
+package runtimecontext
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterVersion_SnapshotsMergedContext(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, filepath.Join("contexts", "base.yaml"), "role:\n  persona: \"Base Persona\"\n  capabilities: [\"search\"]\n")
+	writeFile(t, root, filepath.Join("contexts", "Foo", "foo.ctx"), "extends: ../base.yaml\nname: \"Foo\"\nversion: \"1.2.0\"\nrole:\n  persona: \"Foo Persona\"\n")
+	draft := writeFile(t, root, filepath.Join("drafts", "foo.ctx"), "name: \"Foo\"\nversion: \"1.3.0\"\nrole:\n  persona: \"New Persona\"\n")
+
+	svc := NewContextService(root)
+	if v, err := svc.RegisterVersion("Foo", "", false); err != nil || v != "1.2.0" {
+		t.Fatalf("RegisterVersion = %q, %v", v, err)
+	}
+	if v, err := svc.RegisterVersion("Foo", draft, false); err != nil || v != "1.3.0" {
+		t.Fatalf("RegisterVersion(draft) = %q, %v", v, err)
+	}
+	if _, err := svc.RegisterVersion("Foo", draft, false); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected a registered version not to be replaced, got %v", err)
+	}
+	if _, err := svc.RegisterVersion("Bar", draft, false); err == nil {
+		t.Fatal("expected a file of another context to be refused")
+	}
+	if versions, _ := svc.Versions("Foo"); strings.Join(versions, ",") != "1.2.0,1.3.0" {
+		t.Fatalf("Versions = %v", versions)
+	}
+
+	// The snapshot keeps the base it extended when the base changes
+	writeFile(t, root, filepath.Join("contexts", "base.yaml"), "role:\n  capabilities: [\"search\", \"refunds\"]\n")
+	v1, err := NewContextService(root).ResolveVersion("Foo", "1.2.0")
+	if err != nil || v1.Role.Persona != "Foo Persona" || len(v1.Role.Capabilities) != 1 {
+		t.Fatalf("ResolveVersion = %+v, %v", v1, err)
+	}
+	if _, err := svc.ResolveVersion("Foo", "2.0.0"); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected an unregistered version, got %v", err)
+	}
+	if _, err := svc.ResolveVersion("Foo", "../foo"); err == nil {
+		t.Fatal("expected an unsafe version to be rejected")
+	}
+
+	// Registered versions never stand in for the context file
+	if err := svc.ReloadContext(""); err != nil {
+		t.Fatal(err)
+	}
+	if ctx, err := svc.ResolveContext("", "Foo"); err != nil || ctx.Version != "1.2.0" || len(ctx.Role.Capabilities) != 2 {
+		t.Fatalf("ResolveContext = %+v, %v", ctx, err)
+	}
+}
@@ -0,0 +1,253 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the project-relative path of the route definitions.
+const ConfigFile = "config/routes.yaml"
+
+// AutoContext is the context name that asks for dispatch, as in `ctx run auto`.
+const AutoContext = "auto"
+
+// Dispatch methods. A Decision's Method is also MethodDefault when no rule matched.
+const (
+	MethodKeyword = "keyword"
+	MethodModel   = "model"
+	MethodDefault = "default"
+)
+
+// Dispatches counts dispatched queries by route and the method that chose it.
+var Dispatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_dispatch_total",
+	Help: "Queries dispatched to a component, by route and method.",
+}, []string{"route", "method"})
+
+// Route forwards matching queries to a component's pipeline.
+type Route struct {
+	Name      string `yaml:"name"`      // default: component
+	Component string `yaml:"component"` // required
+	Context   string `yaml:"context"`   // default: component
+	// Description tells the classification model what the route handles.
+	Description string   `yaml:"description"`
+	Keywords    []string `yaml:"keywords"` // whole words or phrases, case-insensitive
+	Patterns    []string `yaml:"patterns"` // regular expressions, case-insensitive
+
+	keywords []*regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+// Dispatcher configures how a route is chosen.
+type Dispatcher struct {
+	Method string `yaml:"method"` // keyword|model; default keyword
+	// Context selects the provider chain (config/providers/routing.yaml) of
+	// the model method.
+	Context string `yaml:"context"`
+	// Default names the route used when nothing matches; without it such
+	// queries are rejected.
+	Default string `yaml:"default"`
+}
+
+// Config is the parsed config/routes.yaml.
+type Config struct {
+	Dispatcher Dispatcher `yaml:"dispatcher"`
+	Routes     []Route    `yaml:"routes"`
+}
+
+// LoadConfig reads and validates the routes under root. A missing file yields
+// an empty config.
+func LoadConfig(root string) (*Config, error) {
+	c := &Config{}
+	by, err := os.ReadFile(filepath.Join(root, ConfigFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(by, c); err != nil {
+		return &Config{}, fmt.Errorf("parse %s: %w", ConfigFile, err)
+	}
+	if err := c.resolve(); err != nil {
+		return &Config{}, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return c, nil
+}
+
+// resolve applies defaults and compiles the match rules.
+func (c *Config) resolve() error {
+	switch c.Dispatcher.Method {
+	case "":
+		c.Dispatcher.Method = MethodKeyword
+	case MethodKeyword, MethodModel:
+	default:
+		return fmt.Errorf("unsupported dispatcher method %q (want keyword or model)", c.Dispatcher.Method)
+	}
+	names := map[string]bool{}
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if r.Component == "" {
+			return fmt.Errorf("route %d: component is required", i+1)
+		}
+		if r.Name == "" {
+			r.Name = r.Component
+		}
+		if r.Context == "" {
+			r.Context = r.Component
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate route %q", r.Name)
+		}
+		names[r.Name] = true
+		for _, k := range r.Keywords {
+			if k = strings.TrimSpace(k); k != "" {
+				r.keywords = append(r.keywords, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(k)+`\b`))
+			}
+		}
+		for _, p := range r.Patterns {
+			re, err := regexp.Compile("(?i)" + p)
+			if err != nil {
+				return fmt.Errorf("route %q: pattern %q: %w", r.Name, p, err)
+			}
+			r.patterns = append(r.patterns, re)
+		}
+	}
+	if d := c.Dispatcher.Default; d != "" && !names[d] {
+		return fmt.Errorf("default route %q is not declared", d)
+	}
+	return nil
+}
+
+// Enabled reports whether any route is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Routes) > 0
+}
+
+// Decision is the route a query was dispatched to.
+type Decision struct {
+	Route     string `json:"route"`
+	Component string `json:"component"`
+	Context   string `json:"context"`
+	Method    string `json:"method"`
+	// Score is the number of keyword and pattern matches of the keyword method.
+	Score int `json:"score,omitempty"`
+}
+
+// ClassifyFunc sends a prompt to the classification model and returns its reply.
+type ClassifyFunc func(ctx context.Context, prompt string) (string, error)
+
+// Dispatch picks the route for query. The model method asks classify, which
+// may be nil, and falls back to the keyword rules when the model fails or
+// names no route; the default route is used when no rule matches. The error
+// from a failed classification is returned alongside a fallback decision.
+func (c *Config) Dispatch(ctx context.Context, query string, classify ClassifyFunc) (Decision, error) {
+	if !c.Enabled() {
+		return Decision{}, fmt.Errorf("no routes configured in %s", ConfigFile)
+	}
+	var classifyErr error
+	if c.Dispatcher.Method == MethodModel {
+		if classify == nil {
+			classifyErr = fmt.Errorf("no model available to classify the query")
+		} else if r, err := c.classify(ctx, query, classify); err != nil {
+			classifyErr = err
+		} else if r != nil {
+			return c.decide(r, MethodModel, 0), nil
+		}
+	}
+	if r, score := c.match(query); r != nil {
+		return c.decide(r, MethodKeyword, score), classifyErr
+	}
+	if c.Dispatcher.Default != "" {
+		return c.decide(c.route(c.Dispatcher.Default), MethodDefault, 0), classifyErr
+	}
+	if classifyErr != nil {
+		return Decision{}, classifyErr
+	}
+	return Decision{}, fmt.Errorf("no route matches the query and no default route is configured")
+}
+
+func (c *Config) decide(r *Route, method string, score int) Decision {
+	Dispatches.WithLabelValues(r.Name, method).Inc()
+	return Decision{Route: r.Name, Component: r.Component, Context: r.Context, Method: method, Score: score}
+}
+
+func (c *Config) route(name string) *Route {
+	for i := range c.Routes {
+		if strings.EqualFold(c.Routes[i].Name, name) {
+			return &c.Routes[i]
+		}
+	}
+	return nil
+}
+
+// match returns the route with the most keyword and pattern matches; ties go
+// to the route declared first.
+func (c *Config) match(query string) (*Route, int) {
+	var best *Route
+	bestScore := 0
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		score := 0
+		for _, re := range r.keywords {
+			score += len(re.FindAllStringIndex(query, -1))
+		}
+		for _, re := range r.patterns {
+			score += len(re.FindAllStringIndex(query, -1))
+		}
+		if score > bestScore {
+			best, bestScore = r, score
+		}
+	}
+	return best, bestScore
+}
+
+const classifyPrompt = `You route user queries to the assistant best suited to answer them.
+
+Assistants:
+%s
+Query:
+%s
+
+Reply with the name of one assistant only, or "none" if no assistant fits.`
+
+// classify asks the model for a route name. It returns nil when the reply
+// names no declared route.
+func (c *Config) classify(ctx context.Context, query string, classify ClassifyFunc) (*Route, error) {
+	var sb strings.Builder
+	for _, r := range c.Routes {
+		fmt.Fprintf(&sb, "- %s", r.Name)
+		if r.Description != "" {
+			fmt.Fprintf(&sb, ": %s", r.Description)
+		}
+		if len(r.Keywords) > 0 {
+			fmt.Fprintf(&sb, " (topics: %s)", strings.Join(r.Keywords, ", "))
+		}
+		sb.WriteByte('\n')
+	}
+	out, err := classify(ctx, fmt.Sprintf(classifyPrompt, sb.String(), query))
+	if err != nil {
+		return nil, fmt.Errorf("classify query: %w", err)
+	}
+	answer := strings.Trim(strings.TrimSpace(out), "\"'`.")
+	if r := c.route(answer); r != nil {
+		return r, nil
+	}
+	// Tolerate a sentence around the name; the longest name mentioned wins
+	var found *Route
+	lower := strings.ToLower(out)
+	for i := range c.Routes {
+		r := &c.Routes[i]
+		if strings.Contains(lower, strings.ToLower(r.Name)) && (found == nil || len(r.Name) > len(found.Name)) {
+			found = r
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,112 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRoutes(t *testing.T, config string) *Config {
+	t.Helper()
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+	if err := os.WriteFile(filepath.Join(root, ConfigFile), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+const supportRoutes = `dispatcher:
+  method: %s
+  default: general
+routes:
+  - name: orders
+    component: OrderBot
+    description: Order status, shipping and returns
+    keywords: [order, shipping, tracking number]
+    patterns: ['#\d{4,}']
+  - name: billing
+    component: BillingBot
+    context: BillingAssistant
+    keywords: [invoice, refund, charge]
+  - name: general
+    component: SupportBot
+`
+
+func TestDispatch_Keywords(t *testing.T) {
+	cfg := writeRoutes(t, strings.Replace(supportRoutes, "%s", "keyword", 1))
+	for query, want := range map[string]string{
+		"Where is my ORDER #12345?":                     "orders",
+		"I was charged twice, I need a refund":          "billing",
+		"Refund the charge on the invoice for my order": "billing",
+		"How do I change my password?":                  "general",
+	} {
+		d, err := cfg.Dispatch(context.Background(), query, nil)
+		if err != nil || d.Route != want {
+			t.Fatalf("%q: got %+v %v, want %s", query, d, err, want)
+		}
+	}
+	d, _ := cfg.Dispatch(context.Background(), "refund please", nil)
+	if d.Component != "BillingBot" || d.Context != "BillingAssistant" || d.Method != MethodKeyword || d.Score != 1 {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	d, _ = cfg.Dispatch(context.Background(), "hello", nil)
+	if d.Context != "SupportBot" || d.Method != MethodDefault {
+		t.Fatalf("unexpected default decision %+v", d)
+	}
+}
+
+func TestDispatch_ModelFallsBackToRules(t *testing.T) {
+	cfg := writeRoutes(t, strings.Replace(supportRoutes, "%s", "model", 1))
+	var prompt string
+	classify := func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return "The best fit is Billing.", nil
+	}
+	d, err := cfg.Dispatch(context.Background(), "Where is my order?", classify)
+	if err != nil || d.Route != "billing" || d.Method != MethodModel {
+		t.Fatalf("unexpected decision %+v %v", d, err)
+	}
+	if !strings.Contains(prompt, "- orders: Order status, shipping and returns (topics: order, shipping, tracking number)") {
+		t.Fatalf("unexpected prompt %q", prompt)
+	}
+	none := func(context.Context, string) (string, error) { return "none", nil }
+	if d, err := cfg.Dispatch(context.Background(), "Where is my order?", none); err != nil || d.Route != "orders" || d.Method != MethodKeyword {
+		t.Fatalf("unexpected fallback %+v %v", d, err)
+	}
+	failing := func(context.Context, string) (string, error) { return "", errors.New("down") }
+	if d, err := cfg.Dispatch(context.Background(), "hello", failing); err == nil || d.Route != "general" {
+		t.Fatalf("expected the default route and the model error, got %+v %v", d, err)
+	}
+}
+
+func TestLoadConfig_Validation(t *testing.T) {
+	if cfg, err := LoadConfig(t.TempDir()); err != nil || cfg.Enabled() {
+		t.Fatalf("missing file: %+v %v", cfg, err)
+	}
+	cfg := writeRoutes(t, "routes:\n  - component: SupportBot\n")
+	if _, err := cfg.Dispatch(context.Background(), "hello", nil); err == nil || !strings.Contains(err.Error(), "no route matches") {
+		t.Fatalf("expected no match without a default, got %v", err)
+	}
+	for _, bad := range []string{
+		"routes:\n  - name: a\n",
+		"dispatcher:\n  method: embedding\nroutes:\n  - component: A\n",
+		"dispatcher:\n  default: missing\nroutes:\n  - component: A\n",
+		"routes:\n  - component: A\n  - component: A\n",
+		"routes:\n  - component: A\n    patterns: ['(']\n",
+	} {
+		root := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+		_ = os.WriteFile(filepath.Join(root, ConfigFile), []byte(bad), 0o644)
+		if _, err := LoadConfig(root); err == nil {
+			t.Fatalf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package dispatch routes queries to components.
+//
+// config/routes.yaml declares the components behind a single entry point and
+// how a dispatcher picks one for each query: keyword and pattern rules, or a
+// model that classifies the query against the route descriptions, with the
+// rules and then a default route as fallbacks. Chat requests without a
+// component, and `ctx run auto`, are dispatched this way.
+package dispatch
@@ -0,0 +1,70 @@
+package drift
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/runtime/notifications"
+)
+
+// Alert reports a score that dropped below its threshold.
+type Alert struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Component string    `json:"component"`
+	Spec      string    `json:"spec,omitempty"`
+	Score     float64   `json:"score"`
+	Threshold float64   `json:"threshold"`
+	Previous  *float64  `json:"previous,omitempty"`
+}
+
+// Message is a one-line human readable summary.
+func (a Alert) Message() string {
+	subject := a.Component
+	if a.Spec != "" {
+		subject += "/" + a.Spec
+	}
+	msg := fmt.Sprintf("%s score for %s dropped to %.2f (threshold %.2f", a.Kind, subject, a.Score, a.Threshold)
+	if a.Previous != nil {
+		msg += fmt.Sprintf(", previously %.2f", *a.Previous)
+	}
+	msg += ")"
+	if a.Schedule != "" {
+		msg += " in schedule " + a.Schedule
+	}
+	return msg
+}
+
+// Event is the alert as a drift.alert notification. Repeats are keyed by
+// series, so a flapping score notifies once per cooldown.
+func (a Alert) Event() notifications.Event {
+	subject := a.Component
+	if a.Spec != "" {
+		subject += "/" + a.Spec
+	}
+	fields := map[string]string{
+		"kind":      a.Kind,
+		"component": a.Component,
+		"score":     fmt.Sprintf("%.2f", a.Score),
+		"threshold": fmt.Sprintf("%.2f", a.Threshold),
+	}
+	if a.Spec != "" {
+		fields["spec"] = a.Spec
+	}
+	if a.Schedule != "" {
+		fields["schedule"] = a.Schedule
+	}
+	if a.Previous != nil {
+		fields["previous"] = fmt.Sprintf("%.2f", *a.Previous)
+	}
+	return notifications.Event{
+		Type:     notifications.EventDriftAlert,
+		Severity: notifications.SeverityWarning,
+		Title:    fmt.Sprintf("%s score dropped for %s", a.Kind, subject),
+		Text:     a.Message(),
+		Fields:   fields,
+		Time:     a.Time,
+		Key:      notifications.EventDriftAlert + "/" + a.Kind + "/" + subject,
+	}
+}
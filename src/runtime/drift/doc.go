@@ -0,0 +1,11 @@
+// Package drift schedules drift detection and evaluation runs and tracks
+// their scores. The same schedules also queue episodic memory retention.
+//
+// Schedules in config/schedules.yaml are cron expressions; `ctx worker start`
+// queues a job for each run. Scores of scheduled runs and of
+// `ctx test --drift-detection` are appended to data/drift/history.jsonl, with
+// the similarity of every drift test case; `ctx drift history` shows their
+// trends and regressions. Drift scores are exported as the cmp_drift_score
+// gauge, and scores that drop below a schedule's threshold raise drift.alert
+// notifications.
+package drift
@@ -0,0 +1,166 @@
+package drift
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	"github.com/contexis-cmp/contexis/src/runtime/notifications"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCron_Next(t *testing.T) {
+	base := time.Date(2026, 3, 14, 10, 7, 30, 0, time.UTC) // a Saturday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 3, 15, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2026, 3, 16, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 7", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)}, // day 13 or Sunday
+	}
+	for _, c := range cases {
+		cron, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := cron.Next(base); !got.Equal(c.want) {
+			t.Errorf("%s: next = %v, want %v", c.expr, got, c.want)
+		}
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	if cfg, err := LoadConfig(root); cfg != nil || err != nil {
+		t.Fatalf("missing config = %v, %v", cfg, err)
+	}
+	write := func(s string) {
+		_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+		_ = os.WriteFile(filepath.Join(root, ScheduleFile), []byte(s), 0o644)
+	}
+	write(`schedules:
+  - name: nightly
+    cron: "0 2 * * *"
+    threshold: 0.8
+`)
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Schedules[0].Kind != KindDrift || cfg.Schedules[0].Threshold != 0.8 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	write("schedules:\n  - name: bad\n    cron: \"0 2 * *\"\n")
+	if _, err := LoadConfig(root); err == nil || !strings.Contains(err.Error(), "schedule bad") {
+		t.Fatalf("expected a cron error, got %v", err)
+	}
+	write("schedules:\n  - name: bad\n    cron: \"@daily\"\n    kind: canary\n")
+	if _, err := LoadConfig(root); err == nil {
+		t.Fatal("expected a kind error")
+	}
+}
+
+func TestRecord_AlertsOnDrop(t *testing.T) {
+	root := t.TempDir()
+	point := func(score float64) Point {
+		return Point{Kind: KindDrift, Schedule: "nightly", Component: "SupportBot", Score: score, Threshold: 0.8}
+	}
+	for i, c := range []struct {
+		score float64
+		alert bool
+	}{{0.9, false}, {0.6, true}, {0.5, false}, {0.85, false}, {0.7, true}} {
+		alerts, err := Record(root, []Point{point(c.score)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(alerts) == 1) != c.alert {
+			t.Fatalf("run %d (score %.2f): alerts = %+v", i, c.score, alerts)
+		}
+	}
+	if got := testutil.ToFloat64(Score.WithLabelValues("SupportBot")); got != 0.7 {
+		t.Fatalf("cmp_drift_score = %v", got)
+	}
+	history, err := LoadHistory(root)
+	if err != nil || len(history) != 5 || !history[1].Alerted || history[2].Alerted {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+}
+
+func TestRegressions_AndPublishScores(t *testing.T) {
+	root := t.TempDir()
+	point := func(score, refunds float64) Point {
+		return Point{Kind: KindDrift, Component: "Docs", Score: score, Cases: map[string]float64{"refunds": refunds, "shipping": 0.5}}
+	}
+	if _, err := Record(root, []Point{point(1, 0.8), point(1, 0.78), point(0.5, 0.4), point(1, 0.9)}); err != nil {
+		t.Fatal(err)
+	}
+	history, _ := LoadHistory(root)
+	regs := Regressions(history, 0.05)
+	// The 0.02 dip is within tolerance; the third run drops both
+	if len(regs) != 2 || regs[0].Case != "" || regs[0].Previous != 1 || regs[1].Case != "refunds" || regs[1].Current != 0.4 {
+		t.Fatalf("regressions = %+v", regs)
+	}
+
+	Score.WithLabelValues("Docs").Set(0)
+	if err := PublishScores(root); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(Score.WithLabelValues("Docs")); got != 1 {
+		t.Fatalf("cmp_drift_score = %v", got)
+	}
+}
+
+func TestAlert_Event(t *testing.T) {
+	prev := 0.9
+	a := Alert{Kind: KindEval, Component: "SupportBot", Spec: "behavior", Score: 0.5, Threshold: 0.8, Previous: &prev}
+	ev := a.Event()
+	if ev.Type != notifications.EventDriftAlert || ev.Fields["previous"] != "0.90" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if !strings.Contains(ev.Text, "SupportBot/behavior dropped to 0.50 (threshold 0.80, previously 0.90)") {
+		t.Fatalf("text = %q", ev.Text)
+	}
+	// The same series keeps its key whatever the score, for rate limiting
+	a.Score = 0.4
+	if a.Event().Key != ev.Key {
+		t.Fatalf("keys differ: %q vs %q", a.Event().Key, ev.Key)
+	}
+}
+
+func TestScheduler_EnqueuesEachRunOnce(t *testing.T) {
+	q := runtimejobs.NewQueue(t.TempDir())
+	sch := Schedule{Name: "hourly-evals", Kind: KindEval, Spec: "behavior", Threshold: 0.9}
+	at := time.Date(2026, 3, 14, 11, 0, 0, 0, time.UTC)
+	first, err := (&Scheduler{Queue: q}).Enqueue(sch, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second worker's scheduler firing for the same run gets the same job
+	second, err := (&Scheduler{Queue: q}).Enqueue(sch, at)
+	if err != nil || second.ID != first.ID {
+		t.Fatalf("second enqueue = %+v, %v", second, err)
+	}
+	if first.Type != runtimejobs.TypeEvalRun || !strings.Contains(string(first.Payload), `"schedule":"hourly-evals"`) {
+		t.Fatalf("unexpected job %+v", first)
+	}
+	jobs, _ := q.List("", "")
+	if len(jobs) != 1 {
+		t.Fatalf("expected one job, got %d", len(jobs))
+	}
+	prune, err := (&Scheduler{Queue: q}).Enqueue(Schedule{Name: "memory-retention", Kind: KindRetention}, at)
+	if err != nil || prune.Type != runtimejobs.TypeMemoryPrune || runtimejobs.ValidatePayload(prune.Type, prune.Payload) != nil {
+		t.Fatalf("retention job = %+v, %v", prune, err)
+	}
+}
@@ -0,0 +1,194 @@
+package drift
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HistoryFile is the project-relative path of the score history.
+const HistoryFile = "data/drift/history.jsonl"
+
+// Score is the latest drift score (passing fraction of drift test cases) by
+// component. The server registers it; `ctx worker start` updates it.
+var Score = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cmp_drift_score",
+	Help: "Latest drift score by component (optional).",
+}, []string{"component"})
+
+// Point is one scored run of a component's drift tests or evaluation suite.
+type Point struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Component string    `json:"component"`
+	Spec      string    `json:"spec,omitempty"`
+	Score     float64   `json:"score"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Total     int       `json:"total"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Alerted   bool      `json:"alerted,omitempty"`
+	// Cases holds the similarity of each drift test case of the run.
+	Cases map[string]float64 `json:"cases,omitempty"`
+}
+
+// Key identifies the series a point belongs to.
+func (p Point) Key() string { return p.Kind + "/" + p.Component + "/" + p.Spec }
+
+// Below reports whether the point scored under its threshold.
+func (p Point) Below() bool { return p.Threshold > 0 && p.Score < p.Threshold }
+
+var historyMu sync.Mutex
+
+// LoadHistory returns the recorded points oldest first.
+func LoadHistory(root string) ([]Point, error) {
+	f, err := os.Open(filepath.Join(root, HistoryFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Point
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var p Point
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, sc.Err()
+}
+
+// Record appends points to the history, updates the drift gauge and returns
+// an alert for each point that dropped below its threshold. Points of a series
+// that was already below do not alert again until it recovers.
+func Record(root string, points []Point) ([]Alert, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history, err := LoadHistory(root)
+	if err != nil {
+		return nil, err
+	}
+	last := map[string]Point{}
+	for _, p := range history {
+		last[p.Key()] = p
+	}
+	var alerts []Alert
+	for i := range points {
+		p := &points[i]
+		if p.Time.IsZero() {
+			p.Time = time.Now().UTC()
+		}
+		if p.Kind == KindDrift {
+			Score.WithLabelValues(p.Component).Set(p.Score)
+		}
+		prev, seen := last[p.Key()]
+		if p.Below() && !(seen && prev.Below()) {
+			a := Alert{Time: p.Time, Kind: p.Kind, Schedule: p.Schedule, Component: p.Component, Spec: p.Spec, Score: p.Score, Threshold: p.Threshold}
+			if seen {
+				a.Previous = &prev.Score
+			}
+			p.Alerted = true
+			alerts = append(alerts, a)
+		}
+		last[p.Key()] = *p
+	}
+	path := filepath.Join(root, HistoryFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return alerts, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return alerts, err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, p := range points {
+		if err := enc.Encode(p); err != nil {
+			return alerts, err
+		}
+	}
+	return alerts, nil
+}
+
+// Latest returns the most recent point of each series.
+func Latest(points []Point) map[string]Point {
+	out := make(map[string]Point, len(points))
+	for _, p := range points {
+		if prev, ok := out[p.Key()]; !ok || !p.Time.Before(prev.Time) {
+			out[p.Key()] = p
+		}
+	}
+	return out
+}
+
+// PublishScores sets the cmp_drift_score gauge to the latest recorded drift
+// score of each component, so a freshly started process exports them.
+func PublishScores(root string) error {
+	history, err := LoadHistory(root)
+	if err != nil {
+		return err
+	}
+	for _, p := range Latest(history) {
+		if p.Kind == KindDrift {
+			Score.WithLabelValues(p.Component).Set(p.Score)
+		}
+	}
+	return nil
+}
+
+// Regression is a drop between two consecutive runs of a series: of the
+// score when Case is empty, otherwise of a test case's similarity.
+type Regression struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Component string    `json:"component"`
+	Spec      string    `json:"spec,omitempty"`
+	Case      string    `json:"case,omitempty"`
+	Previous  float64   `json:"previous"`
+	Current   float64   `json:"current"`
+}
+
+// Regressions returns, in recorded order, every score drop and every case
+// similarity drop larger than tolerance between consecutive runs of a series.
+func Regressions(points []Point, tolerance float64) []Regression {
+	var out []Regression
+	last := map[string]Point{}
+	for _, p := range points {
+		prev, seen := last[p.Key()]
+		last[p.Key()] = p
+		if !seen {
+			continue
+		}
+		reg := Regression{Time: p.Time, Kind: p.Kind, Component: p.Component, Spec: p.Spec}
+		if p.Score < prev.Score {
+			r := reg
+			r.Previous, r.Current = prev.Score, p.Score
+			out = append(out, r)
+		}
+		names := make([]string, 0, len(p.Cases))
+		for name := range p.Cases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			before, ok := prev.Cases[name]
+			if now := p.Cases[name]; ok && before-now > tolerance {
+				r := reg
+				r.Case, r.Previous, r.Current = name, before, now
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
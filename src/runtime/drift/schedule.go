@@ -0,0 +1,114 @@
+package drift
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScheduleFile is the project-relative path of the schedule configuration.
+const ScheduleFile = "config/schedules.yaml"
+
+// Schedule kinds.
+const (
+	KindDrift = "drift"
+	KindEval  = "eval"
+	// KindRetention prunes episodic memory by the components' retention policies.
+	KindRetention = "retention"
+)
+
+var scheduleNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config is the parsed config/schedules.yaml:
+//
+//	schedules:
+//	  - name: nightly-drift
+//	    cron: "0 2 * * *"
+//	    kind: drift          # drift | eval | retention
+//	    component: SupportBot # optional, default all
+//	    threshold: 0.8        # alert when the score drops below
+//	  - name: hourly-evals
+//	    cron: "@hourly"
+//	    kind: eval
+//	    spec: behavior
+//	    threshold: 0.9
+//	  - name: memory-retention
+//	    cron: "30 3 * * *"
+//	    kind: retention
+//
+// Alerts go to the channels subscribed to drift.alert in
+// config/notifications.yaml.
+type Config struct {
+	Schedules []Schedule `yaml:"schedules"`
+}
+
+// Schedule runs drift detection, evaluation suites or memory retention periodically.
+type Schedule struct {
+	Name      string  `yaml:"name"`
+	Cron      string  `yaml:"cron"`
+	Kind      string  `yaml:"kind"`
+	Component string  `yaml:"component"`
+	Spec      string  `yaml:"spec"`
+	Threshold float64 `yaml:"threshold"`
+
+	cron *Cron
+}
+
+// Next returns the schedule's first run time after t.
+func (s Schedule) Next(t time.Time) time.Time {
+	return s.cron.Next(t)
+}
+
+// LoadConfig reads config/schedules.yaml under root. It returns nil when the
+// file does not exist (nothing scheduled).
+func LoadConfig(root string) (*Config, error) {
+	by, err := os.ReadFile(filepath.Join(root, ScheduleFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := yaml.Unmarshal(by, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ScheduleFile, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", ScheduleFile, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	seen := map[string]bool{}
+	for i := range c.Schedules {
+		s := &c.Schedules[i]
+		if !scheduleNameRe.MatchString(s.Name) {
+			return fmt.Errorf("schedule %d: invalid name %q", i+1, s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("duplicate schedule %q", s.Name)
+		}
+		seen[s.Name] = true
+		switch s.Kind {
+		case "":
+			s.Kind = KindDrift
+		case KindDrift, KindEval, KindRetention:
+		default:
+			return fmt.Errorf("schedule %s: kind must be %s, %s or %s", s.Name, KindDrift, KindEval, KindRetention)
+		}
+		if s.Threshold < 0 || s.Threshold > 1 {
+			return fmt.Errorf("schedule %s: threshold must be between 0 and 1", s.Name)
+		}
+		cron, err := ParseCron(s.Cron)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+		s.cron = cron
+	}
+	return nil
+}
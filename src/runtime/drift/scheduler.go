@@ -0,0 +1,95 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	"go.uber.org/zap"
+)
+
+// Scheduler queues a drift.run, eval.run or memory.prune job each time a
+// schedule is due.
+// Jobs are keyed by schedule and run time, so any number of workers may run
+// a scheduler and each run is queued once.
+type Scheduler struct {
+	Queue  *runtimejobs.Queue
+	Config *Config
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Run queues due jobs until ctx is cancelled. Runs missed while no scheduler
+// was running are not made up.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.Config == nil || len(s.Config.Schedules) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+	now := s.now()
+	next := make([]time.Time, len(s.Config.Schedules))
+	for i, sch := range s.Config.Schedules {
+		next[i] = sch.Next(now)
+	}
+	for {
+		var due time.Time
+		for _, t := range next {
+			if !t.IsZero() && (due.IsZero() || t.Before(due)) {
+				due = t
+			}
+		}
+		if due.IsZero() {
+			<-ctx.Done()
+			return nil
+		}
+		timer := time.NewTimer(due.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		for i, sch := range s.Config.Schedules {
+			if next[i].IsZero() || next[i].After(due) {
+				continue
+			}
+			if _, err := s.Enqueue(sch, next[i]); err != nil {
+				logger.GetLogger().Error("queue scheduled job", zap.String("schedule", sch.Name), zap.Error(err))
+			}
+			next[i] = sch.Next(next[i])
+		}
+	}
+}
+
+// Enqueue queues the job of a schedule's run at the given time.
+func (s *Scheduler) Enqueue(sch Schedule, at time.Time) (runtimejobs.Job, error) {
+	job := runtimejobs.Job{Key: fmt.Sprintf("schedule:%s:%d", sch.Name, at.Unix()), MaxAttempts: 1}
+	var payload interface{}
+	switch sch.Kind {
+	case KindRetention:
+		job.Type = runtimejobs.TypeMemoryPrune
+		payload = runtimejobs.MemoryPrunePayload{Component: sch.Component, Schedule: sch.Name}
+	case KindEval:
+		job.Type = runtimejobs.TypeEvalRun
+		payload = runtimejobs.EvalRunPayload{Component: sch.Component, Spec: sch.Spec, Schedule: sch.Name, Threshold: sch.Threshold}
+	default:
+		job.Type = runtimejobs.TypeDriftRun
+		payload = runtimejobs.DriftRunPayload{Component: sch.Component, Schedule: sch.Name, Threshold: sch.Threshold}
+	}
+	by, err := json.Marshal(payload)
+	if err != nil {
+		return runtimejobs.Job{}, err
+	}
+	job.Payload = by
+	return s.Queue.Submit(job)
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,9 @@
+// Package eval runs evaluation suites against components.
+//
+// Suites live in tests/<Component>/evals/*.yaml. Each case sends an input
+// through the chat pipeline and scores the answer with one or more graders:
+// exact match, regular expression, embedding similarity, an LLM judge backed by
+// any configured provider, or a plugin registered with RegisterGrader. Reports
+// are written as JSON and JUnit under tests/reports/evals, and every run appends
+// to a history file so score trends can be tracked over time.
+package eval
@@ -0,0 +1,189 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+type judgeProvider struct {
+	out    string
+	prompt string
+}
+
+func (j *judgeProvider) Generate(_ context.Context, input string, _ runtimemodel.Params) (string, error) {
+	j.prompt = input
+	return j.out, nil
+}
+
+const specYAML = `graders:
+  - type: exact
+cases:
+  - name: refund
+    input: How long do refunds take?
+    expected: Refunds take 30 days.
+  - name: shipping
+    input: Do you ship abroad?
+    expected: "(?i)international"
+    graders:
+      - type: regex
+      - type: llm_judge
+        rubric: Mentions international shipping.
+        threshold: 0.5
+`
+
+func writeSpec(t *testing.T, root string) string {
+	t.Helper()
+	path := filepath.Join(root, "tests", "SupportBot", "evals", "basics.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(specYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSpecDefaultsAndDiscovery(t *testing.T) {
+	root := t.TempDir()
+	path := writeSpec(t, root)
+	found, err := FindSpecs(root)
+	if err != nil || len(found) != 1 || found[0] != path {
+		t.Fatalf("FindSpecs = %v, %v", found, err)
+	}
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != "basics" || spec.Component != "SupportBot" || spec.Context != "SupportBot" {
+		t.Fatalf("unexpected defaults %+v", spec)
+	}
+	if got := spec.Cases[1].Graders[1].String("rubric"); got != "Mentions international shipping." {
+		t.Fatalf("grader option not decoded: %q", got)
+	}
+
+	bad := filepath.Join(root, "tests", "SupportBot", "evals", "bad.yaml")
+	_ = os.WriteFile(bad, []byte("cases:\n  - name: x\n    graders:\n      - type: nope\n"), 0o644)
+	if _, err := LoadSpec(bad); err == nil || !strings.Contains(err.Error(), "unknown grader") {
+		t.Fatalf("expected unknown grader error, got %v", err)
+	}
+}
+
+func TestRunnerScoresCases(t *testing.T) {
+	spec, err := LoadSpec(writeSpec(t, t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	judge := &judgeProvider{out: `Sure. {"score": 0.8, "reason": "mentions it"}`}
+	answers := map[string]string{
+		"refund":   "  refunds take 30 days. ",
+		"shipping": "We offer International delivery.",
+	}
+	r := &Runner{
+		Respond: func(_ context.Context, _ *Spec, c Case) (string, error) { return answers[c.Name], nil },
+		Env:     Env{Judge: judge},
+	}
+	rep, err := r.Run(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Passed != 2 || rep.Failed != 0 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	ship := rep.Cases[1]
+	if len(ship.Graders) != 2 || ship.Graders[1].Score != 0.8 || ship.Graders[1].Threshold != 0.5 {
+		t.Fatalf("unexpected grader results %+v", ship.Graders)
+	}
+	if ship.Score != 0.9 {
+		t.Fatalf("case score = %v, want 0.9", ship.Score)
+	}
+	if !strings.Contains(judge.prompt, "Mentions international shipping.") || !strings.Contains(judge.prompt, "International delivery") {
+		t.Fatalf("judge prompt missing rubric or answer: %s", judge.prompt)
+	}
+
+	answers["refund"] = "No refunds."
+	r.Env.Judge = nil
+	rep, _ = r.Run(context.Background(), spec)
+	if rep.Cases[0].Status != StatusFailed || rep.Cases[1].Status != StatusError {
+		t.Fatalf("expected FAILED and ERROR, got %s and %s", rep.Cases[0].Status, rep.Cases[1].Status)
+	}
+}
+
+func TestSimilarityAndPluginGraders(t *testing.T) {
+	RegisterGrader("length", 0.5, func(spec GraderSpec, _ Env) (Grader, error) {
+		return GraderFunc(func(_ context.Context, _ Case, response string) (Score, error) {
+			if len(response) <= 10 {
+				return Score{Value: 1}, nil
+			}
+			return Score{Value: 0, Reason: "too long"}, nil
+		}), nil
+	})
+	spec := &Spec{Name: "s", Component: "C", Cases: []Case{{
+		Name: "c", Expected: "reset your password from the settings page",
+		Graders: []GraderSpec{{Type: "similarity", Threshold: 0.5}, {Type: "length"}},
+	}}}
+	r := &Runner{Respond: func(context.Context, *Spec, Case) (string, error) {
+		return "reset your password from the settings page", nil
+	}}
+	rep, err := r.Run(context.Background(), spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, length := rep.Cases[0].Graders[0], rep.Cases[0].Graders[1]
+	if sim.Score < 0.99 || !sim.Passed {
+		t.Fatalf("identical text should be similar: %+v", sim)
+	}
+	if length.Passed || length.Reason != "too long" || rep.Failed != 1 {
+		t.Fatalf("plugin grader not applied: %+v", length)
+	}
+}
+
+func TestParseJudgement(t *testing.T) {
+	s, err := parseJudgement(`{"score": "1.5", "reason": "great"}`)
+	if err != nil || s.Value != 1 || s.Reason != "great" {
+		t.Fatalf("parseJudgement = %+v, %v", s, err)
+	}
+	if _, err := parseJudgement("no verdict"); err == nil {
+		t.Fatal("expected error without JSON")
+	}
+}
+
+func TestReportsAndHistory(t *testing.T) {
+	dir := t.TempDir()
+	rep := &Report{Spec: "basics", Component: "SupportBot", Passed: 1, Failed: 1, Total: 2, Score: 0.5, Cases: []CaseResult{
+		{Name: "ok", Status: StatusPassed, Score: 1},
+		{Name: "bad <case>", Status: StatusFailed, Graders: []GraderResult{{Type: "exact", Threshold: 1, Reason: "mismatch"}}},
+	}}
+	if err := WriteReports(dir, []*Report{rep}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "eval_SupportBot_basics.json")); err != nil {
+		t.Fatal(err)
+	}
+	junit := filepath.Join(dir, "junit-eval.xml")
+	if err := WriteJUnit(junit, []*Report{rep}); err != nil {
+		t.Fatal(err)
+	}
+	by, _ := os.ReadFile(junit)
+	if !strings.Contains(string(by), `name="bad &lt;case&gt;"`) || !strings.Contains(string(by), "exact score 0.000 &lt; 1.000: mismatch") {
+		t.Fatalf("unexpected junit:\n%s", by)
+	}
+
+	for _, score := range []float64{0.5, 0.75} {
+		rep.Score = score
+		if err := AppendHistory(dir, []*Report{rep}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	points, err := LoadHistory(dir)
+	if err != nil || len(points) != 2 {
+		t.Fatalf("LoadHistory = %v, %v", points, err)
+	}
+	if latest := Latest(points)["SupportBot/basics"]; latest.Score != 0.75 || latest.PassRate != 0.5 {
+		t.Fatalf("unexpected latest point %+v", latest)
+	}
+}
@@ -0,0 +1,251 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+// Score is a grader verdict in [0, 1].
+type Score struct {
+	Value  float64
+	Reason string
+}
+
+// Grader scores a response for a case.
+type Grader interface {
+	Grade(ctx context.Context, c Case, response string) (Score, error)
+}
+
+// GraderFunc adapts a function to the Grader interface.
+type GraderFunc func(ctx context.Context, c Case, response string) (Score, error)
+
+func (f GraderFunc) Grade(ctx context.Context, c Case, response string) (Score, error) {
+	return f(ctx, c, response)
+}
+
+// Embedder turns text into a vector for similarity grading.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+}
+
+// Env carries the shared dependencies graders are built with.
+type Env struct {
+	// Judge serves llm_judge graders; nil makes them fail with an error.
+	Judge runtimemodel.Provider
+	// Provider resolves the provider option of llm_judge graders by name (for
+	// example from routing.yaml); it may be nil.
+	Provider func(name string) runtimemodel.Provider
+	// Embedder serves similarity graders; nil uses the memory store's local
+	// hashing embedding.
+	Embedder Embedder
+}
+
+// GraderFactory builds a grader from its spec.
+type GraderFactory func(spec GraderSpec, env Env) (Grader, error)
+
+// grader is a registered factory with its default pass threshold.
+type grader struct {
+	factory   GraderFactory
+	threshold float64
+}
+
+var (
+	gradersMu sync.RWMutex
+	graders   = map[string]grader{}
+)
+
+// RegisterGrader makes a grader type available to evaluation specs. threshold
+// is the pass mark used when a spec does not set one. Registering an existing
+// type replaces it.
+func RegisterGrader(name string, threshold float64, factory GraderFactory) {
+	gradersMu.Lock()
+	defer gradersMu.Unlock()
+	graders[strings.ToLower(name)] = grader{factory: factory, threshold: threshold}
+}
+
+func lookupGrader(name string) (grader, bool) {
+	gradersMu.RLock()
+	defer gradersMu.RUnlock()
+	g, ok := graders[strings.ToLower(name)]
+	return g, ok
+}
+
+func init() {
+	RegisterGrader("exact", 1, newExactGrader)
+	RegisterGrader("regex", 1, newRegexGrader)
+	RegisterGrader("similarity", 0.7, newSimilarityGrader)
+	RegisterGrader("llm_judge", 0.7, newJudgeGrader)
+}
+
+// newExactGrader matches the response against the expected answer after trimming
+// whitespace. Options: case_sensitive, contains (substring instead of equality).
+func newExactGrader(spec GraderSpec, _ Env) (Grader, error) {
+	caseSensitive, contains := spec.Bool("case_sensitive"), spec.Bool("contains")
+	return GraderFunc(func(_ context.Context, c Case, response string) (Score, error) {
+		want, got := strings.TrimSpace(c.Expected), strings.TrimSpace(response)
+		if !caseSensitive {
+			want, got = strings.ToLower(want), strings.ToLower(got)
+		}
+		ok := want == got
+		if contains {
+			ok = strings.Contains(got, want)
+		}
+		if ok {
+			return Score{Value: 1}, nil
+		}
+		return Score{Value: 0, Reason: "response does not match expected answer"}, nil
+	}), nil
+}
+
+// newRegexGrader passes when the response matches pattern, defaulting to the
+// case's expected answer. Option: negate (fail on match).
+func newRegexGrader(spec GraderSpec, _ Env) (Grader, error) {
+	var static *regexp.Regexp
+	if p := spec.String("pattern"); p != "" {
+		rx, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("regex: %w", err)
+		}
+		static = rx
+	}
+	negate := spec.Bool("negate")
+	return GraderFunc(func(_ context.Context, c Case, response string) (Score, error) {
+		rx := static
+		if rx == nil {
+			var err error
+			if rx, err = regexp.Compile(c.Expected); err != nil {
+				return Score{}, fmt.Errorf("regex: %w", err)
+			}
+		}
+		if rx.MatchString(response) != negate {
+			return Score{Value: 1}, nil
+		}
+		if negate {
+			return Score{Value: 0, Reason: fmt.Sprintf("response matches %q", rx.String())}, nil
+		}
+		return Score{Value: 0, Reason: fmt.Sprintf("response does not match %q", rx.String())}, nil
+	}), nil
+}
+
+// newSimilarityGrader scores the cosine similarity between the response and the
+// expected answer embeddings.
+func newSimilarityGrader(_ GraderSpec, env Env) (Grader, error) {
+	return GraderFunc(func(ctx context.Context, c Case, response string) (Score, error) {
+		if env.Embedder == nil {
+			return Score{Value: runtimememory.Similarity(c.Expected, response, 256)}, nil
+		}
+		a, err := env.Embedder.Embed(ctx, c.Expected)
+		if err != nil {
+			return Score{}, err
+		}
+		b, err := env.Embedder.Embed(ctx, response)
+		if err != nil {
+			return Score{}, err
+		}
+		return Score{Value: cosine(a, b)}, nil
+	}), nil
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+const judgePrompt = `You are grading the answer of an AI assistant.
+
+Rubric: %s
+
+Question:
+%s
+
+Reference answer:
+%s
+
+Assistant answer:
+%s
+
+Rate how well the assistant answer satisfies the rubric on a scale from 0 to 1.
+Reply with JSON only: {"score": <number between 0 and 1>, "reason": "<one sentence>"}`
+
+const defaultRubric = "The answer is correct, complete and consistent with the reference answer."
+
+// newJudgeGrader asks the judge model to score the response. Options: rubric,
+// provider (a named provider instead of the default judge).
+func newJudgeGrader(spec GraderSpec, env Env) (Grader, error) {
+	rubric := spec.String("rubric")
+	if rubric == "" {
+		rubric = defaultRubric
+	}
+	judge := env.Judge
+	if name := spec.String("provider"); name != "" {
+		if env.Provider == nil {
+			return nil, fmt.Errorf("llm_judge: provider %q not available", name)
+		}
+		if judge = env.Provider(name); judge == nil {
+			return nil, fmt.Errorf("llm_judge: unknown provider %q", name)
+		}
+	}
+	return GraderFunc(func(ctx context.Context, c Case, response string) (Score, error) {
+		if judge == nil {
+			return Score{}, errors.New("llm_judge: no judge provider configured")
+		}
+		prompt := fmt.Sprintf(judgePrompt, rubric, c.Input, c.Expected, response)
+		out, err := judge.Generate(ctx, prompt, runtimemodel.Params{Temperature: 0, MaxNewTokens: 128})
+		if err != nil {
+			return Score{}, fmt.Errorf("llm_judge: %w", err)
+		}
+		return parseJudgement(out)
+	}), nil
+}
+
+// parseJudgement extracts {"score", "reason"} from a judge reply, tolerating
+// surrounding prose.
+func parseJudgement(out string) (Score, error) {
+	start, end := strings.Index(out, "{"), strings.LastIndex(out, "}")
+	if start < 0 || end <= start {
+		return Score{}, fmt.Errorf("llm_judge: no JSON verdict in %q", out)
+	}
+	var verdict struct {
+		Score  json.RawMessage `json:"score"`
+		Reason string          `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(out[start:end+1]), &verdict); err != nil {
+		return Score{}, fmt.Errorf("llm_judge: invalid verdict: %w", err)
+	}
+	v, err := strconv.ParseFloat(strings.Trim(string(verdict.Score), `"`), 64)
+	if err != nil {
+		return Score{}, fmt.Errorf("llm_judge: invalid score %s", verdict.Score)
+	}
+	return Score{Value: clamp(v), Reason: verdict.Reason}, nil
+}
+
+func clamp(v float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
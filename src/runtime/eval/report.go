@@ -0,0 +1,188 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ReportsDir is the project-relative directory for evaluation reports.
+const ReportsDir = "tests/reports/evals"
+
+// HistoryFile is the JSONL file under ReportsDir that records one TrendPoint
+// per suite run.
+const HistoryFile = "history.jsonl"
+
+// TrendPoint summarizes one suite run for score trends.
+type TrendPoint struct {
+	Time      time.Time `json:"time"`
+	Component string    `json:"component"`
+	Spec      string    `json:"spec"`
+	Score     float64   `json:"score"`
+	PassRate  float64   `json:"pass_rate"`
+	Passed    int       `json:"passed"`
+	Total     int       `json:"total"`
+}
+
+// Key identifies the suite a point belongs to.
+func (p TrendPoint) Key() string { return p.Component + "/" + p.Spec }
+
+// Point returns the trend point for a report.
+func (r Report) Point() TrendPoint {
+	return TrendPoint{Time: r.StartedAt, Component: r.Component, Spec: r.Spec, Score: r.Score, PassRate: r.PassRate(), Passed: r.Passed, Total: r.Total}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ReportFileName returns the JSON report file name for a suite.
+func ReportFileName(component, spec string) string {
+	return fmt.Sprintf("eval_%s_%s.json", unsafeFileChars.ReplaceAllString(component, "_"), unsafeFileChars.ReplaceAllString(spec, "_"))
+}
+
+// WriteReports writes one JSON report per suite plus eval_index.json to dir.
+func WriteReports(dir string, reports []*Report) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create report dir: %w", err)
+	}
+	for _, r := range reports {
+		if err := writeJSON(filepath.Join(dir, ReportFileName(r.Component, r.Spec)), r); err != nil {
+			return err
+		}
+	}
+	return writeJSON(filepath.Join(dir, "eval_index.json"), reports)
+}
+
+func writeJSON(path string, v interface{}) error {
+	by, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, by, 0o644)
+}
+
+// WriteJUnit writes a JUnit XML report with one testsuite per evaluation suite.
+func WriteJUnit(path string, reports []*Report) error {
+	var total, failures, errs int
+	for _, r := range reports {
+		for _, c := range r.Cases {
+			total++
+			switch c.Status {
+			case StatusFailed:
+				failures++
+			case StatusError:
+				errs++
+			}
+		}
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(b, "<testsuites name=\"eval\" tests=\"%d\" failures=\"%d\" errors=\"%d\">\n", total, failures, errs)
+	for _, r := range reports {
+		fmt.Fprintf(b, "  <testsuite name=\"%s\" tests=\"%d\" failures=\"%d\">\n", xmlEscape(r.Component+"/"+r.Spec), r.Total, r.Failed)
+		for _, c := range r.Cases {
+			fmt.Fprintf(b, "    <testcase classname=\"%s\" name=\"%s\" time=\"%.3f\">\n", xmlEscape(r.Component), xmlEscape(c.Name), float64(c.DurationMs)/1000)
+			switch c.Status {
+			case StatusFailed:
+				fmt.Fprintf(b, "      <failure message=\"%s\"/>\n", xmlEscape(failureMessage(c)))
+			case StatusError:
+				fmt.Fprintf(b, "      <error message=\"%s\"/>\n", xmlEscape(c.Error))
+			}
+			fmt.Fprintf(b, "    </testcase>\n")
+		}
+		fmt.Fprintf(b, "  </testsuite>\n")
+	}
+	fmt.Fprintf(b, "</testsuites>\n")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+func failureMessage(c CaseResult) string {
+	var parts []string
+	for _, g := range c.Graders {
+		if g.Passed {
+			continue
+		}
+		msg := fmt.Sprintf("%s score %.3f < %.3f", g.Type, g.Score, g.Threshold)
+		if g.Reason != "" {
+			msg += ": " + g.Reason
+		}
+		parts = append(parts, msg)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func xmlEscape(s string) string {
+	r := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		"\"", "&quot;",
+		"'", "&apos;",
+	)
+	return r.Replace(s)
+}
+
+// AppendHistory records the reports' trend points in dir/history.jsonl.
+func AppendHistory(dir string, reports []*Report) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, HistoryFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, r := range reports {
+		if err := enc.Encode(r.Point()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadHistory reads dir/history.jsonl in recorded order. A missing file yields
+// no points.
+func LoadHistory(dir string) ([]TrendPoint, error) {
+	f, err := os.Open(filepath.Join(dir, HistoryFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var points []TrendPoint
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var p TrendPoint
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", HistoryFile, err)
+		}
+		points = append(points, p)
+	}
+	return points, sc.Err()
+}
+
+// Latest returns the most recent point per suite key.
+func Latest(points []TrendPoint) map[string]TrendPoint {
+	out := make(map[string]TrendPoint, len(points))
+	for _, p := range points {
+		if prev, ok := out[p.Key()]; !ok || !p.Time.Before(prev.Time) {
+			out[p.Key()] = p
+		}
+	}
+	return out
+}
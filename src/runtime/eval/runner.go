@@ -0,0 +1,149 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Case and suite statuses, matching the drift report conventions.
+const (
+	StatusPassed = "PASSED"
+	StatusFailed = "FAILED"
+	StatusError  = "ERROR"
+)
+
+// Responder produces the component's answer for a case.
+type Responder func(ctx context.Context, spec *Spec, c Case) (string, error)
+
+// GraderResult is one grader's verdict on a case.
+type GraderResult struct {
+	Type      string  `json:"type"`
+	Score     float64 `json:"score"`
+	Threshold float64 `json:"threshold"`
+	Passed    bool    `json:"passed"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// CaseResult is the outcome of a case. Score is the weighted mean of its graders.
+type CaseResult struct {
+	Name       string         `json:"name"`
+	Status     string         `json:"status"`
+	Score      float64        `json:"score"`
+	Response   string         `json:"response,omitempty"`
+	Graders    []GraderResult `json:"graders,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+// Report is the outcome of a suite. Score is the mean case score.
+type Report struct {
+	Spec      string       `json:"spec"`
+	Component string       `json:"component"`
+	SpecPath  string       `json:"spec_path"`
+	StartedAt time.Time    `json:"started_at"`
+	Passed    int          `json:"passed"`
+	Failed    int          `json:"failed"`
+	Total     int          `json:"total"`
+	Score     float64      `json:"score"`
+	Cases     []CaseResult `json:"cases"`
+}
+
+// PassRate is the fraction of passing cases.
+func (r Report) PassRate() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Passed) / float64(r.Total)
+}
+
+// Runner executes suites with a responder and grader environment.
+type Runner struct {
+	Respond Responder
+	Env     Env
+}
+
+// Run executes every case in spec. Responder and grader errors mark the case as
+// ERROR and do not stop the suite.
+func (r *Runner) Run(ctx context.Context, spec *Spec) (*Report, error) {
+	if r.Respond == nil {
+		return nil, fmt.Errorf("eval: no responder configured")
+	}
+	rep := &Report{Spec: spec.Name, Component: spec.Component, SpecPath: spec.Path, StartedAt: time.Now().UTC()}
+	var total float64
+	for _, c := range spec.Cases {
+		res := r.runCase(ctx, spec, c)
+		if res.Status == StatusPassed {
+			rep.Passed++
+		} else {
+			rep.Failed++
+		}
+		rep.Total++
+		total += res.Score
+		rep.Cases = append(rep.Cases, res)
+		if ctx.Err() != nil {
+			return rep, ctx.Err()
+		}
+	}
+	if rep.Total > 0 {
+		rep.Score = total / float64(rep.Total)
+	}
+	return rep, nil
+}
+
+func (r *Runner) runCase(ctx context.Context, spec *Spec, c Case) CaseResult {
+	start := time.Now()
+	res := CaseResult{Name: c.Name, Status: StatusPassed}
+
+	response, err := r.Respond(ctx, spec, c)
+	if err != nil {
+		res.Status, res.Error = StatusError, err.Error()
+		res.DurationMs = time.Since(start).Milliseconds()
+		return res
+	}
+	res.Response = response
+
+	specs := c.Graders
+	if len(specs) == 0 {
+		specs = spec.Graders
+	}
+	var weighted, weights float64
+	for _, gs := range specs {
+		reg, ok := lookupGrader(gs.Type)
+		if !ok {
+			res.Status, res.Error = StatusError, fmt.Sprintf("unknown grader %q", gs.Type)
+			break
+		}
+		g, err := reg.factory(gs, r.Env)
+		var score Score
+		if err == nil {
+			score, err = g.Grade(ctx, c, response)
+		}
+		if err != nil {
+			res.Status, res.Error = StatusError, err.Error()
+			break
+		}
+		gr := GraderResult{Type: gs.Type, Score: score.Value, Threshold: gs.Threshold, Reason: score.Reason}
+		if gr.Threshold == 0 {
+			gr.Threshold = reg.threshold
+		}
+		gr.Passed = gr.Score >= gr.Threshold
+		if !gr.Passed && res.Status == StatusPassed {
+			res.Status = StatusFailed
+		}
+		w := gs.Weight
+		if w <= 0 {
+			w = 1
+		}
+		weighted += w * gr.Score
+		weights += w
+		res.Graders = append(res.Graders, gr)
+	}
+	if res.Status == StatusError {
+		res.Score = 0
+	} else if weights > 0 {
+		res.Score = weighted / weights
+	}
+	res.DurationMs = time.Since(start).Milliseconds()
+	return res
+}
@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is an evaluation suite loaded from tests/<Component>/evals/<name>.yaml.
+type Spec struct {
+	Name      string `yaml:"name"`
+	Component string `yaml:"component"`
+	// Context defaults to Component.
+	Context    string `yaml:"context"`
+	TenantID   string `yaml:"tenant_id"`
+	PromptFile string `yaml:"prompt_file"`
+	// Graders apply to cases that do not declare their own.
+	Graders []GraderSpec `yaml:"graders"`
+	Cases   []Case       `yaml:"cases"`
+
+	// Path is the file the spec was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Case is a single evaluation input with its expected answer.
+type Case struct {
+	Name     string                 `yaml:"name"`
+	Input    string                 `yaml:"input"`
+	Data     map[string]interface{} `yaml:"data"`
+	Expected string                 `yaml:"expected"`
+	Graders  []GraderSpec           `yaml:"graders"`
+}
+
+// GraderSpec configures one grader. Type selects a registered grader; the
+// remaining keys are grader options (pattern, rubric, case_sensitive, ...).
+type GraderSpec struct {
+	Type string `yaml:"type"`
+	// Threshold is the minimum score to pass; zero uses the grader default.
+	Threshold float64 `yaml:"threshold"`
+	// Weight scales the grader in the case score; zero counts as 1.
+	Weight  float64                `yaml:"weight"`
+	Options map[string]interface{} `yaml:",inline"`
+}
+
+// String returns the option key as a string, or "".
+func (g GraderSpec) String(key string) string {
+	if v, ok := g.Options[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Bool returns the option key as a bool.
+func (g GraderSpec) Bool(key string) bool {
+	v, _ := g.Options[key].(bool)
+	return v
+}
+
+// LoadSpec reads and validates an evaluation suite. Name and Component default to
+// the file name and the tests/<Component>/evals directory.
+func LoadSpec(path string) (*Spec, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := yaml.Unmarshal(by, &spec); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	spec.Path = path
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if spec.Component == "" {
+		spec.Component = componentFromPath(path)
+	}
+	if spec.Context == "" {
+		spec.Context = spec.Component
+	}
+	if len(spec.Cases) == 0 {
+		return nil, fmt.Errorf("%s: no cases", path)
+	}
+	for i, c := range spec.Cases {
+		if c.Name == "" {
+			return nil, fmt.Errorf("%s: case %d has no name", path, i+1)
+		}
+		if len(c.Graders) == 0 && len(spec.Graders) == 0 {
+			return nil, fmt.Errorf("%s: case %q has no graders", path, c.Name)
+		}
+		for _, g := range append(c.Graders, spec.Graders...) {
+			if _, ok := lookupGrader(g.Type); !ok {
+				return nil, fmt.Errorf("%s: case %q: unknown grader %q", path, c.Name, g.Type)
+			}
+		}
+	}
+	return &spec, nil
+}
+
+// FindSpecs returns the evaluation suites under root/tests, sorted by path.
+func FindSpecs(root string) ([]string, error) {
+	var matches []string
+	err := filepath.WalkDir(filepath.Join(root, "tests"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != filepath.Join(root, "tests") && d.Name() == "reports" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if filepath.Base(filepath.Dir(path)) == "evals" && (ext == ".yaml" || ext == ".yml") {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// componentFromPath maps tests/<Component>/evals/<name>.yaml to <Component>.
+func componentFromPath(p string) string {
+	return filepath.Base(filepath.Dir(filepath.Dir(p)))
+}
@@ -0,0 +1,8 @@
+// Package experiment runs prompt A/B experiments.
+//
+// config/experiments.yaml declares, per component and prompt, the variants
+// under prompts/<Component>/variants/ and their traffic weights. Each request
+// is assigned a variant deterministically from its session, user or tenant,
+// so the same caller keeps seeing the same prompt. Outcomes are appended to a
+// JSONL log under data/experiments and summarized per variant for reports.
+package experiment
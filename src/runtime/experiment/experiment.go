@@ -0,0 +1,404 @@
+package experiment
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the project-relative path of the experiment definitions.
+const ConfigFile = "config/experiments.yaml"
+
+// OutcomesFile is the project-relative path of the outcome log.
+const OutcomesFile = "data/experiments/outcomes.jsonl"
+
+// VariantsDir (prompts/<Component>/variants) holds variant prompt files.
+const VariantsDir = "variants"
+
+// Assignment units: the request attribute that keeps a caller on one variant.
+const (
+	BySession = "session"
+	ByUser    = "user"
+	ByTenant  = "tenant"
+)
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	Name string `yaml:"name"`
+	// File is relative to prompts/<Component>/. It defaults to
+	// variants/<name>.md when that exists, and to the experiment's prompt
+	// otherwise, which makes the variant a control.
+	File   string `yaml:"file"`
+	Weight int    `yaml:"weight"`
+	// Version is a hash of the prompt file, computed at load.
+	Version string `yaml:"-"`
+}
+
+// Experiment splits traffic for one component prompt between variants.
+type Experiment struct {
+	Name      string    `yaml:"name"`
+	Component string    `yaml:"component"`
+	Prompt    string    `yaml:"prompt"`    // prompt file the experiment replaces; default agent_response.md
+	AssignBy  string    `yaml:"assign_by"` // session|user|tenant; default session
+	Paused    bool      `yaml:"paused"`    // serve the base prompt to everyone
+	Variants  []Variant `yaml:"variants"`
+
+	total int
+}
+
+// Config is the parsed config/experiments.yaml.
+type Config struct {
+	Experiments []Experiment `yaml:"experiments"`
+
+	active map[string]*Experiment // component + "\x00" + prompt
+}
+
+// LoadConfig reads and validates the experiments under root. A missing file
+// yields an empty config.
+func LoadConfig(root string) (*Config, error) {
+	c := &Config{}
+	by, err := os.ReadFile(filepath.Join(root, ConfigFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(by, c); err != nil {
+		return &Config{}, fmt.Errorf("parse %s: %w", ConfigFile, err)
+	}
+	if err := c.resolve(root); err != nil {
+		return &Config{}, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return c, nil
+}
+
+// resolve applies defaults, checks variant files and indexes active experiments.
+func (c *Config) resolve(root string) error {
+	c.active = map[string]*Experiment{}
+	names := map[string]bool{}
+	for i := range c.Experiments {
+		e := &c.Experiments[i]
+		if e.Name == "" || e.Component == "" {
+			return fmt.Errorf("experiment %d: name and component are required", i+1)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("duplicate experiment %q", e.Name)
+		}
+		names[e.Name] = true
+		if e.Prompt == "" {
+			e.Prompt = "agent_response.md"
+		}
+		switch e.AssignBy {
+		case "":
+			e.AssignBy = BySession
+		case BySession, ByUser, ByTenant:
+		default:
+			return fmt.Errorf("experiment %q: unsupported assign_by %q (want session, user or tenant)", e.Name, e.AssignBy)
+		}
+		if len(e.Variants) < 2 {
+			return fmt.Errorf("experiment %q: at least two variants are required", e.Name)
+		}
+		dir := filepath.Join(root, "prompts", e.Component)
+		seen := map[string]bool{}
+		e.total = 0
+		for j := range e.Variants {
+			v := &e.Variants[j]
+			if v.Name == "" || seen[v.Name] {
+				return fmt.Errorf("experiment %q: variant names must be set and unique", e.Name)
+			}
+			seen[v.Name] = true
+			if v.Weight < 0 {
+				return fmt.Errorf("experiment %q: variant %q has a negative weight", e.Name, v.Name)
+			}
+			e.total += v.Weight
+			if v.File == "" {
+				v.File = e.Prompt
+				if _, err := os.Stat(filepath.Join(dir, VariantsDir, v.Name+".md")); err == nil {
+					v.File = VariantsDir + "/" + v.Name + ".md"
+				}
+			}
+			clean := filepath.ToSlash(filepath.Clean(v.File))
+			if filepath.IsAbs(v.File) || clean == ".." || strings.HasPrefix(clean, "../") {
+				return fmt.Errorf("experiment %q: variant %q file %q is outside prompts/%s", e.Name, v.Name, v.File, e.Component)
+			}
+			v.File = clean
+			b, err := os.ReadFile(filepath.Join(dir, clean))
+			if err != nil {
+				return fmt.Errorf("experiment %q: variant %q: %w", e.Name, v.Name, err)
+			}
+			v.Version = contentVersion(b)
+		}
+		if e.total == 0 {
+			return fmt.Errorf("experiment %q: variant weights sum to zero", e.Name)
+		}
+		if e.Paused {
+			continue
+		}
+		key := e.Component + "\x00" + e.Prompt
+		if other, ok := c.active[key]; ok {
+			return fmt.Errorf("experiments %q and %q both target prompts/%s/%s", other.Name, e.Name, e.Component, e.Prompt)
+		}
+		c.active[key] = e
+	}
+	return nil
+}
+
+// contentVersion identifies a prompt revision by a short content hash.
+func contentVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Unit carries the request attributes variants are assigned by.
+type Unit struct {
+	SessionID string
+	UserID    string
+	TenantID  string
+	RequestID string
+}
+
+// Key returns the assignment key for by, falling back to the next most
+// stable attribute that is set.
+func (u Unit) Key(by string) string {
+	order := []string{u.SessionID, u.UserID, u.TenantID}
+	switch by {
+	case ByUser:
+		order = []string{u.UserID, u.SessionID, u.TenantID}
+	case ByTenant:
+		order = []string{u.TenantID, u.UserID, u.SessionID}
+	}
+	for _, k := range order {
+		if k != "" {
+			return k
+		}
+	}
+	return u.RequestID
+}
+
+// Assignment is the variant a request was served.
+type Assignment struct {
+	Experiment string `json:"experiment"`
+	Variant    string `json:"variant"`
+	Version    string `json:"version"`
+	// PromptFile is the variant's prompt, relative to prompts/<Component>/.
+	PromptFile string `json:"-"`
+}
+
+// Assign picks the variant of the active experiment on component's prompt
+// for u. ok is false when no experiment runs on that prompt.
+func (c *Config) Assign(component, prompt string, u Unit) (a Assignment, ok bool) {
+	if c == nil {
+		return a, false
+	}
+	e, ok := c.active[component+"\x00"+prompt]
+	if !ok {
+		return a, false
+	}
+	sum := sha256.Sum256([]byte(e.Name + "\x00" + u.Key(e.AssignBy)))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(e.total))
+	for _, v := range e.Variants {
+		if bucket < v.Weight {
+			Assignments.WithLabelValues(e.Name, v.Name).Inc()
+			return Assignment{Experiment: e.Name, Variant: v.Name, Version: v.Version, PromptFile: v.File}, true
+		}
+		bucket -= v.Weight
+	}
+	return a, false
+}
+
+// Outcome is the result of one request served by an experiment variant.
+type Outcome struct {
+	Time             time.Time `json:"time"`
+	RequestID        string    `json:"request_id,omitempty"`
+	TenantID         string    `json:"tenant_id,omitempty"`
+	Component        string    `json:"component,omitempty"`
+	Experiment       string    `json:"experiment"`
+	Variant          string    `json:"variant"`
+	Version          string    `json:"version,omitempty"`
+	Status           int       `json:"status"`
+	LatencyMS        int64     `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cited            bool      `json:"cited,omitempty"`
+}
+
+// Succeeded reports whether the request was answered.
+func (o Outcome) Succeeded() bool { return o.Status > 0 && o.Status < 400 }
+
+// Filter selects outcomes. Zero values match everything; To is exclusive.
+type Filter struct {
+	From       time.Time
+	To         time.Time
+	Experiment string
+}
+
+func (f Filter) match(o Outcome) bool {
+	if !f.From.IsZero() && o.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !o.Time.Before(f.To) {
+		return false
+	}
+	return f.Experiment == "" || o.Experiment == f.Experiment
+}
+
+// Log is an append-only JSONL file of outcomes.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLog returns the outcome log for a project root.
+func NewLog(root string) *Log {
+	return &Log{path: filepath.Join(root, OutcomesFile)}
+}
+
+// Append writes an outcome to the log and updates the outcome metrics.
+func (l *Log) Append(o Outcome) error {
+	result := "error"
+	if o.Succeeded() {
+		result = "success"
+	}
+	Outcomes.WithLabelValues(o.Experiment, o.Variant, result).Inc()
+	Latency.WithLabelValues(o.Experiment, o.Variant).Observe(float64(o.LatencyMS) / 1000)
+	by, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(by, '\n'))
+	return err
+}
+
+// Query returns the outcomes matching f, oldest first.
+func (l *Log) Query(f Filter) ([]Outcome, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var out []Outcome
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var o Outcome
+		if err := json.Unmarshal([]byte(line), &o); err != nil {
+			return nil, fmt.Errorf("parse experiment outcomes: %w", err)
+		}
+		if f.match(o) {
+			out = append(out, o)
+		}
+	}
+	return out, sc.Err()
+}
+
+// Summary aggregates the outcomes of one variant revision. Rates and averages
+// cover answered requests only.
+type Summary struct {
+	Experiment          string  `json:"experiment"`
+	Variant             string  `json:"variant"`
+	Version             string  `json:"version,omitempty"`
+	Requests            int     `json:"requests"`
+	Succeeded           int     `json:"succeeded"`
+	SuccessRate         float64 `json:"success_rate"`
+	CitationRate        float64 `json:"citation_rate"`
+	AvgLatencyMS        float64 `json:"avg_latency_ms"`
+	AvgPromptTokens     float64 `json:"avg_prompt_tokens"`
+	AvgCompletionTokens float64 `json:"avg_completion_tokens"`
+
+	cited, latency, promptTokens, completionTokens int64
+}
+
+// Summarize groups outcomes per experiment, variant and version.
+func Summarize(outcomes []Outcome) []Summary {
+	rows := map[[3]string]*Summary{}
+	for _, o := range outcomes {
+		key := [3]string{o.Experiment, o.Variant, o.Version}
+		s, ok := rows[key]
+		if !ok {
+			s = &Summary{Experiment: key[0], Variant: key[1], Version: key[2]}
+			rows[key] = s
+		}
+		s.Requests++
+		if !o.Succeeded() {
+			continue
+		}
+		s.Succeeded++
+		if o.Cited {
+			s.cited++
+		}
+		s.latency += o.LatencyMS
+		s.promptTokens += int64(o.PromptTokens)
+		s.completionTokens += int64(o.CompletionTokens)
+	}
+	out := make([]Summary, 0, len(rows))
+	for _, s := range rows {
+		s.SuccessRate = float64(s.Succeeded) / float64(s.Requests)
+		if n := float64(s.Succeeded); n > 0 {
+			s.CitationRate = float64(s.cited) / n
+			s.AvgLatencyMS = float64(s.latency) / n
+			s.AvgPromptTokens = float64(s.promptTokens) / n
+			s.AvgCompletionTokens = float64(s.completionTokens) / n
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Experiment != b.Experiment {
+			return a.Experiment < b.Experiment
+		}
+		if a.Variant != b.Variant {
+			return a.Variant < b.Variant
+		}
+		return a.Version < b.Version
+	})
+	return out
+}
+
+// Prometheus metrics for comparing variants on dashboards
+var (
+	Assignments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_experiment_assignments_total",
+		Help: "Requests assigned to each prompt experiment variant.",
+	}, []string{"experiment", "variant"})
+	Outcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_experiment_outcomes_total",
+		Help: "Prompt experiment outcomes by variant and result (success|error).",
+	}, []string{"experiment", "variant", "result"})
+	Latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_experiment_latency_seconds",
+		Help:    "Chat latency by prompt experiment variant.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"experiment", "variant"})
+)
@@ -0,0 +1,143 @@
+package experiment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeProject(t *testing.T, config string) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "prompts", "SupportBot")
+	_ = os.MkdirAll(filepath.Join(dir, VariantsDir), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "agent_response.md"), []byte("Answer: {{.user_query}}"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, VariantsDir, "friendly.md"), []byte("Hi! Answer kindly: {{.user_query}}"), 0o644)
+	_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, ConfigFile), []byte(config), 0o644)
+	return root
+}
+
+const toneExperiment = `experiments:
+  - name: tone
+    component: SupportBot
+    variants:
+      - name: control
+        weight: 1
+      - name: friendly
+        weight: 1
+`
+
+func TestAssign_DeterministicWeightedSplit(t *testing.T) {
+	cfg, err := LoadConfig(writeProject(t, toneExperiment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		u := Unit{SessionID: fmt.Sprintf("session-%d", i), RequestID: fmt.Sprintf("req-%d", i)}
+		a, ok := cfg.Assign("SupportBot", "agent_response.md", u)
+		if !ok {
+			t.Fatal("experiment not active")
+		}
+		if again, _ := cfg.Assign("SupportBot", "agent_response.md", Unit{SessionID: u.SessionID}); again != a {
+			t.Fatalf("session %s assigned %+v then %+v", u.SessionID, a, again)
+		}
+		counts[a.Variant]++
+		switch a.Variant {
+		case "control":
+			if a.PromptFile != "agent_response.md" {
+				t.Fatalf("control prompt = %q", a.PromptFile)
+			}
+		case "friendly":
+			if a.PromptFile != "variants/friendly.md" {
+				t.Fatalf("friendly prompt = %q", a.PromptFile)
+			}
+		}
+		if len(a.Version) != 12 {
+			t.Fatalf("version = %q", a.Version)
+		}
+	}
+	if counts["control"] < 400 || counts["friendly"] < 400 {
+		t.Fatalf("unbalanced split %v", counts)
+	}
+	if _, ok := cfg.Assign("SupportBot", "search_response.md", Unit{}); ok {
+		t.Fatal("other prompts must not be assigned")
+	}
+	var nilCfg *Config
+	if _, ok := nilCfg.Assign("SupportBot", "agent_response.md", Unit{}); ok {
+		t.Fatal("nil config must not assign")
+	}
+
+	// Assigning by user ignores the session
+	cfg, err = LoadConfig(writeProject(t, strings.Replace(toneExperiment, "component: SupportBot", "component: SupportBot\n    assign_by: user", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := cfg.Assign("SupportBot", "agent_response.md", Unit{UserID: "u1", SessionID: "a"})
+	for i := 0; i < 20; i++ {
+		if a, _ := cfg.Assign("SupportBot", "agent_response.md", Unit{UserID: "u1", SessionID: fmt.Sprint(i)}); a != first {
+			t.Fatalf("user u1 moved from %+v to %+v", first, a)
+		}
+	}
+}
+
+func TestLoadConfig_Validation(t *testing.T) {
+	if cfg, err := LoadConfig(t.TempDir()); err != nil || len(cfg.Experiments) != 0 {
+		t.Fatalf("missing file: %+v, %v", cfg, err)
+	}
+	for name, tc := range map[string]struct{ config, want string }{
+		"missing file":   {strings.Replace(toneExperiment, "name: friendly", "name: formal", 1) + "        file: variants/formal.md\n", "formal"},
+		"escaping path":  {toneExperiment + "        file: ../Other/agent_response.md\n", "outside prompts/SupportBot"},
+		"zero weights":   {strings.ReplaceAll(toneExperiment, "weight: 1", "weight: 0"), "sum to zero"},
+		"single variant": {"experiments:\n  - name: solo\n    component: SupportBot\n    variants:\n      - name: control\n        weight: 1\n", "at least two"},
+		"bad assign_by":  {strings.Replace(toneExperiment, "component: SupportBot", "component: SupportBot\n    assign_by: device", 1), "unsupported assign_by"},
+		"overlap":        {toneExperiment + strings.Replace(strings.TrimPrefix(toneExperiment, "experiments:\n"), "name: tone", "name: tone2", 1), "both target"},
+	} {
+		if _, err := LoadConfig(writeProject(t, tc.config)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+	}
+	// A paused experiment loads but assigns nothing
+	cfg, err := LoadConfig(writeProject(t, strings.Replace(toneExperiment, "component: SupportBot", "component: SupportBot\n    paused: true", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Assign("SupportBot", "agent_response.md", Unit{SessionID: "s"}); ok {
+		t.Fatal("paused experiment assigned a variant")
+	}
+}
+
+func TestLog_QuerySummarize(t *testing.T) {
+	l := NewLog(t.TempDir())
+	now := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, o := range []Outcome{
+		{Time: now, Experiment: "tone", Variant: "control", Version: "aaa", Status: 200, LatencyMS: 100, PromptTokens: 10, CompletionTokens: 4, Cited: true},
+		{Time: now, Experiment: "tone", Variant: "control", Version: "aaa", Status: 502, LatencyMS: 900},
+		{Time: now, Experiment: "tone", Variant: "friendly", Version: "bbb", Status: 200, LatencyMS: 200, PromptTokens: 12, CompletionTokens: 8},
+		{Time: now, Experiment: "tone", Variant: "friendly", Version: "bbb", Status: 200, LatencyMS: 400, PromptTokens: 12, CompletionTokens: 6, Cited: true},
+		{Time: now.Add(48 * time.Hour), Experiment: "length", Variant: "short", Status: 200},
+	} {
+		if err := l.Append(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := l.Query(Filter{Experiment: "tone", To: now.Add(time.Hour)})
+	if err != nil || len(got) != 4 {
+		t.Fatalf("Query = %d outcomes, %v", len(got), err)
+	}
+	rows := Summarize(got)
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", rows)
+	}
+	control, friendly := rows[0], rows[1]
+	if control.Variant != "control" || control.Requests != 2 || control.SuccessRate != 0.5 || control.AvgLatencyMS != 100 || control.CitationRate != 1 {
+		t.Fatalf("unexpected control row %+v", control)
+	}
+	if friendly.Requests != 2 || friendly.SuccessRate != 1 || friendly.AvgLatencyMS != 300 || friendly.AvgCompletionTokens != 7 || friendly.CitationRate != 0.5 {
+		t.Fatalf("unexpected friendly row %+v", friendly)
+	}
+}
@@ -0,0 +1,324 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Grounding methods and actions accepted under guardrails.grounding.
+const (
+	// GroundingOverlap scores a claim by the share of its terms found in a chunk.
+	GroundingOverlap = "overlap"
+	// GroundingEmbedding scores a claim by its embedding similarity to the
+	// closest sentence of a chunk.
+	GroundingEmbedding = "embedding"
+	// GroundingJudge asks a model whether each claim is supported.
+	GroundingJudge = "judge"
+
+	ActionFlag = "flag"
+
+	DefaultGroundingThreshold = 0.6
+	DefaultClaimThreshold     = 0.5
+)
+
+// GroundingScores observes the grounding score of checked answers by method.
+var GroundingScores = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "cmp_grounding_score",
+	Help:    "Share of answer claims supported by the retrieved chunks.",
+	Buckets: []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+}, []string{"method"})
+
+// UngroundedResponses counts answers scoring below the grounding threshold by action.
+var UngroundedResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_ungrounded_responses_total",
+	Help: "Answers below the grounding threshold, by method and action.",
+}, []string{"method", "action"})
+
+// ClaimCheck is the verdict on one claim of an answer.
+type ClaimCheck struct {
+	Claim     string  `json:"claim"`
+	Score     float64 `json:"score"`
+	Supported bool    `json:"supported"`
+	// Source is the 1-based index of the best supporting chunk, 0 when none.
+	Source int `json:"source,omitempty"`
+}
+
+// GroundingResult is the outcome of checking an answer against its sources.
+type GroundingResult struct {
+	Method    string       `json:"method"`
+	Score     float64      `json:"score"`
+	Threshold float64      `json:"threshold"`
+	Grounded  bool         `json:"grounded"`
+	Action    string       `json:"action"`
+	Claims    []ClaimCheck `json:"claims,omitempty"`
+}
+
+// Blocked reports whether the answer must be withheld.
+func (r GroundingResult) Blocked() bool {
+	return !r.Grounded && r.Action == ActionBlock
+}
+
+// JudgeFunc sends a prompt to the judge model and returns its reply.
+type JudgeFunc func(ctx context.Context, prompt string) (string, error)
+
+// GroundingCheck verifies that answers are supported by retrieved chunks.
+type GroundingCheck struct {
+	method         string
+	action         string
+	threshold      float64
+	claimThreshold float64
+}
+
+// NewGroundingCheck reads guardrails.grounding. It returns nil when the
+// context does not configure a grounding check.
+func NewGroundingCheck(ctx *corectx.Context) (*GroundingCheck, error) {
+	if ctx == nil || ctx.Guardrails.Grounding == nil {
+		return nil, nil
+	}
+	cfg := ctx.Guardrails.Grounding
+	g := &GroundingCheck{method: cfg.Method, action: cfg.Action, threshold: cfg.Threshold, claimThreshold: cfg.ClaimThreshold}
+	if g.method == "" {
+		g.method = GroundingOverlap
+	}
+	if g.action == "" {
+		g.action = ActionFlag
+	}
+	if g.threshold <= 0 {
+		g.threshold = DefaultGroundingThreshold
+	}
+	if g.claimThreshold <= 0 {
+		g.claimThreshold = DefaultClaimThreshold
+	}
+	switch g.method {
+	case GroundingOverlap, GroundingEmbedding, GroundingJudge:
+	default:
+		return nil, fmt.Errorf("grounding: unknown method %q", g.method)
+	}
+	switch g.action {
+	case ActionFlag, ActionBlock:
+	default:
+		return nil, fmt.Errorf("grounding: unknown action %q", g.action)
+	}
+	return g, nil
+}
+
+// Blocks reports whether low-grounded answers are withheld rather than flagged.
+func (g *GroundingCheck) Blocks() bool {
+	return g != nil && g.action == ActionBlock
+}
+
+// Check scores answer against chunks. judge serves the judge method and may
+// be nil otherwise. An answer without claims is grounded; claims with no
+// chunks to support them are not.
+func (g *GroundingCheck) Check(ctx context.Context, answer string, chunks []string, judge JudgeFunc) (GroundingResult, error) {
+	res := GroundingResult{Method: g.method, Threshold: g.threshold, Action: g.action, Score: 1}
+	claims := splitClaims(answer)
+	if len(claims) > 0 {
+		var scores []float64
+		var sources []int
+		var err error
+		switch g.method {
+		case GroundingJudge:
+			if judge == nil {
+				return res, fmt.Errorf("grounding: no judge model available")
+			}
+			scores, sources, err = judgeClaims(ctx, judge, claims, chunks)
+		default:
+			scores, sources = g.scoreLocally(claims, chunks)
+		}
+		if err != nil {
+			return res, err
+		}
+		supported := 0
+		for i, c := range claims {
+			check := ClaimCheck{Claim: c, Score: scores[i], Supported: scores[i] >= g.claimThreshold}
+			if check.Supported {
+				supported++
+				check.Source = sources[i]
+			}
+			res.Claims = append(res.Claims, check)
+		}
+		res.Score = float64(supported) / float64(len(claims))
+	}
+	res.Grounded = res.Score >= g.threshold
+	GroundingScores.WithLabelValues(g.method).Observe(res.Score)
+	if !res.Grounded {
+		UngroundedResponses.WithLabelValues(g.method, g.action).Inc()
+	}
+	return res, nil
+}
+
+// scoreLocally returns each claim's best score over the chunks and the chunk
+// that produced it.
+func (g *GroundingCheck) scoreLocally(claims, chunks []string) ([]float64, []int) {
+	scores := make([]float64, len(claims))
+	sources := make([]int, len(claims))
+	chunkTerms := make([]map[string]bool, len(chunks))
+	for i, c := range chunks {
+		chunkTerms[i] = termSet(c)
+	}
+	for i, claim := range claims {
+		for j, chunk := range chunks {
+			var s float64
+			if g.method == GroundingEmbedding {
+				for _, sentence := range splitSentences(chunk) {
+					if v := runtimememory.Similarity(claim, sentence, 384); v > s {
+						s = v
+					}
+				}
+			} else {
+				s = termCoverage(claim, chunkTerms[j])
+			}
+			if s > scores[i] {
+				scores[i], sources[i] = s, j+1
+			}
+		}
+	}
+	return scores, sources
+}
+
+// termCoverage is the share of the claim's content terms present in a chunk.
+func termCoverage(claim string, chunk map[string]bool) float64 {
+	terms := termSet(claim)
+	if len(terms) == 0 {
+		return 0
+	}
+	found := 0
+	for t := range terms {
+		if chunk[t] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(terms))
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"can": true, "for": true, "from": true, "has": true, "have": true, "in": true, "is": true, "it": true,
+	"its": true, "of": true, "on": true, "or": true, "that": true, "the": true, "their": true, "this": true,
+	"to": true, "was": true, "were": true, "will": true, "with": true, "you": true, "your": true,
+}
+
+// termSet returns the lower-cased content words of text.
+func termSet(text string) map[string]bool {
+	terms := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[w] {
+			terms[w] = true
+		}
+	}
+	return terms
+}
+
+var citationMarker = regexp.MustCompile(`\[\d+\]`)
+
+// minClaimTerms skips fragments too short to state a fact ("Sure.", "Hope this helps!").
+const minClaimTerms = 3
+
+// splitClaims returns the sentences of an answer that make a claim, with
+// citation markers and list bullets removed.
+func splitClaims(answer string) []string {
+	var claims []string
+	for _, s := range splitSentences(citationMarker.ReplaceAllString(answer, "")) {
+		s = strings.TrimSpace(strings.TrimLeft(s, "-*•# \t"))
+		if len(termSet(s)) >= minClaimTerms {
+			claims = append(claims, s)
+		}
+	}
+	return claims
+}
+
+// splitSentences splits text at line breaks and at sentence-ending
+// punctuation followed by whitespace.
+func splitSentences(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		start := 0
+		for i := 0; i < len(line); i++ {
+			switch line[i] {
+			case '.', '!', '?':
+				if i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t' {
+					if s := strings.TrimSpace(line[start : i+1]); s != "" {
+						out = append(out, s)
+					}
+					start = i + 1
+				}
+			}
+		}
+		if s := strings.TrimSpace(line[start:]); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+const groundingJudgePrompt = `You are checking whether the claims of an AI assistant's answer are supported by the sources it was given.
+
+Sources:
+%s
+Claims:
+%s
+For each claim, rate from 0 to 1 how strongly the sources support it (1: stated or directly implied by a source, 0: absent from or contradicted by the sources) and give the number of the best supporting source, or 0.
+Reply with JSON only: {"claims": [{"claim": <claim number>, "score": <number between 0 and 1>, "source": <source number>}]}`
+
+// judgeClaims asks the judge model to score every claim in one call.
+func judgeClaims(ctx context.Context, judge JudgeFunc, claims, chunks []string) ([]float64, []int, error) {
+	var src, cl strings.Builder
+	for i, c := range chunks {
+		fmt.Fprintf(&src, "[%d] %s\n", i+1, strings.TrimSpace(c))
+	}
+	for i, c := range claims {
+		fmt.Fprintf(&cl, "%d. %s\n", i+1, c)
+	}
+	out, err := judge(ctx, fmt.Sprintf(groundingJudgePrompt, src.String(), cl.String()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("grounding judge: %w", err)
+	}
+	start, end := strings.Index(out, "{"), strings.LastIndex(out, "}")
+	if start < 0 || end <= start {
+		return nil, nil, fmt.Errorf("grounding judge: no JSON verdict in %q", out)
+	}
+	var verdict struct {
+		Claims []struct {
+			Claim  int             `json:"claim"`
+			Score  json.RawMessage `json:"score"`
+			Source int             `json:"source"`
+		} `json:"claims"`
+	}
+	if err := json.Unmarshal([]byte(out[start:end+1]), &verdict); err != nil {
+		return nil, nil, fmt.Errorf("grounding judge: invalid verdict: %w", err)
+	}
+	// Claims the judge leaves out count as unsupported
+	scores := make([]float64, len(claims))
+	sources := make([]int, len(claims))
+	for _, v := range verdict.Claims {
+		if v.Claim < 1 || v.Claim > len(claims) {
+			continue
+		}
+		s, err := strconv.ParseFloat(strings.Trim(string(v.Score), `"`), 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("grounding judge: invalid score %s", v.Score)
+		}
+		if s < 0 {
+			s = 0
+		} else if s > 1 {
+			s = 1
+		}
+		scores[v.Claim-1] = s
+		if v.Source >= 1 && v.Source <= len(chunks) {
+			sources[v.Claim-1] = v.Source
+		}
+	}
+	return scores, sources, nil
+}
@@ -0,0 +1,100 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+func groundingFor(t *testing.T, cfg corectx.GroundingGuardrails) *GroundingCheck {
+	t.Helper()
+	g, err := NewGroundingCheck(&corectx.Context{Guardrails: corectx.Guardrails{Grounding: &cfg}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+var refundChunks = []string{
+	"Shipping is free on orders over 50 euros.",
+	"Refunds are issued to the original payment method within 14 days of the return.",
+}
+
+func TestGrounding_Overlap(t *testing.T) {
+	g := groundingFor(t, corectx.GroundingGuardrails{})
+	res, err := g.Check(context.Background(), "Sure! Refunds go to the original payment method within 14 days [2].", refundChunks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Grounded || res.Score != 1 || len(res.Claims) != 1 || res.Claims[0].Source != 2 || res.Method != GroundingOverlap {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	res, _ = g.Check(context.Background(), "Refunds are issued within 14 days.\n- Every customer also receives a lifetime warranty on electronics.", refundChunks, nil)
+	if res.Grounded || res.Score != 0.5 || res.Blocked() || !res.Claims[0].Supported || res.Claims[1].Supported || res.Claims[1].Source != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	// Nothing retrieved: no claim can be supported
+	if res, _ := g.Check(context.Background(), "Refunds are issued within 14 days.", nil, nil); res.Grounded || res.Score != 0 {
+		t.Fatalf("unexpected result without chunks %+v", res)
+	}
+	// Nothing claimed
+	if res, _ := g.Check(context.Background(), "Happy to help!", refundChunks, nil); !res.Grounded || len(res.Claims) != 0 {
+		t.Fatalf("unexpected result without claims %+v", res)
+	}
+}
+
+func TestGrounding_EmbeddingAndBlock(t *testing.T) {
+	g := groundingFor(t, corectx.GroundingGuardrails{Method: GroundingEmbedding, Action: ActionBlock, Threshold: 0.9})
+	if !g.Blocks() {
+		t.Fatal("expected a blocking check")
+	}
+	res, err := g.Check(context.Background(), "Shipping is free on orders over 50 euros.", refundChunks, nil)
+	if err != nil || !res.Grounded || res.Claims[0].Source != 1 {
+		t.Fatalf("unexpected result %+v %v", res, err)
+	}
+	res, _ = g.Check(context.Background(), "Express delivery takes two business days.", refundChunks, nil)
+	if res.Grounded || !res.Blocked() {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestGrounding_Judge(t *testing.T) {
+	g := groundingFor(t, corectx.GroundingGuardrails{Method: GroundingJudge})
+	var prompt string
+	judge := func(_ context.Context, p string) (string, error) {
+		prompt = p
+		return `Verdict: {"claims": [{"claim": 1, "score": 0.95, "source": 2}, {"claim": 2, "score": "0.1", "source": 0}]}`, nil
+	}
+	res, err := g.Check(context.Background(), "Refunds take two weeks. Warranty covers water damage.", refundChunks, judge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Score != 0.5 || res.Grounded || res.Claims[0].Source != 2 || res.Claims[1].Supported {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if !strings.Contains(prompt, "[2] Refunds are issued") || !strings.Contains(prompt, "2. Warranty covers water damage.") {
+		t.Fatalf("unexpected judge prompt %q", prompt)
+	}
+	if _, err := g.Check(context.Background(), "Refunds take two weeks.", refundChunks, nil); err == nil {
+		t.Fatal("expected an error without a judge")
+	}
+	failing := func(context.Context, string) (string, error) { return "", errors.New("down") }
+	if _, err := g.Check(context.Background(), "Refunds take two weeks.", refundChunks, failing); err == nil {
+		t.Fatal("expected the judge error")
+	}
+}
+
+func TestNewGroundingCheck_Config(t *testing.T) {
+	if g, err := NewGroundingCheck(&corectx.Context{}); g != nil || err != nil || g.Blocks() {
+		t.Fatalf("no grounding: %v %v", g, err)
+	}
+	for _, cfg := range []corectx.GroundingGuardrails{{Method: "nli"}, {Action: "redact"}} {
+		if _, err := NewGroundingCheck(&corectx.Context{Guardrails: corectx.Guardrails{Grounding: &cfg}}); err == nil {
+			t.Fatalf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package guardrails
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Output filter types and actions accepted under guardrails.output_filters.
+const (
+	FilterDenyList   = "deny_list"
+	FilterRegex      = "regex"
+	FilterModeration = "moderation"
+
+	ActionBlock    = "block"
+	ActionRedact   = "redact"
+	ActionAnnotate = "annotate"
+
+	// DefaultReplacement replaces redacted matches when a filter sets none.
+	DefaultReplacement = "[filtered]"
+	// DefaultModerationEndpoint is the OpenAI moderation API.
+	DefaultModerationEndpoint = "https://api.openai.com/v1/moderations"
+)
+
+// OutputFilterHits counts filter matches on responses by filter and action.
+var OutputFilterHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_output_filter_hits_total",
+	Help: "Responses matched by an output filter, by filter, type and action.",
+}, []string{"filter", "type", "action"})
+
+// FilterHit records one filter that matched a response.
+type FilterHit struct {
+	Filter string `json:"filter"`
+	Type   string `json:"type"`
+	Action string `json:"action"`
+	// Labels are the matched words, patterns or moderation categories.
+	Labels []string `json:"labels,omitempty"`
+	Count  int      `json:"count"`
+}
+
+// FilterResult is the outcome of running the output filters on a response.
+type FilterResult struct {
+	Text    string
+	Blocked bool
+	// BlockedBy names the filter that blocked the response.
+	BlockedBy string
+	Hits      []FilterHit
+	// Failures are fail-open moderation errors; the response was passed through.
+	Failures []error
+}
+
+// span is a byte range of a response matched by a filter.
+type span struct{ start, end int }
+
+// outputFilter finds the parts of a response a filter objects to. Filters
+// that judge the whole text (moderation) return labels without spans.
+type outputFilter interface {
+	match(ctx context.Context, text string) ([]span, []string, error)
+}
+
+type configuredFilter struct {
+	name, typ, action, replacement string
+	failClosed                     bool
+	impl                           outputFilter
+}
+
+// OutputFilters runs a context's response filters in declaration order.
+type OutputFilters struct {
+	filters []configuredFilter
+}
+
+// NewOutputFilters compiles guardrails.output_filters. words_file paths are
+// resolved against root. It returns nil when the context declares no filters.
+func NewOutputFilters(root string, ctx *corectx.Context) (*OutputFilters, error) {
+	if ctx == nil || len(ctx.Guardrails.OutputFilters) == 0 {
+		return nil, nil
+	}
+	p := &OutputFilters{}
+	for i, cfg := range ctx.Guardrails.OutputFilters {
+		f := configuredFilter{name: cfg.Name, typ: cfg.Type, action: cfg.Action, replacement: cfg.Replacement, failClosed: cfg.FailClosed}
+		if f.name == "" {
+			f.name = fmt.Sprintf("%s_%d", cfg.Type, i+1)
+		}
+		if f.action == "" {
+			f.action = ActionBlock
+		}
+		if f.replacement == "" {
+			f.replacement = DefaultReplacement
+		}
+		switch f.action {
+		case ActionBlock, ActionRedact, ActionAnnotate:
+		default:
+			return nil, fmt.Errorf("output filter %s: unknown action %q", f.name, f.action)
+		}
+		var err error
+		switch cfg.Type {
+		case FilterDenyList:
+			f.impl, err = newDenyList(root, cfg)
+		case FilterRegex:
+			f.impl, err = newRegexFilter(cfg)
+		case FilterModeration:
+			f.impl = newModerationFilter(cfg)
+		default:
+			err = fmt.Errorf("unknown type %q", cfg.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("output filter %s: %w", f.name, err)
+		}
+		p.filters = append(p.filters, f)
+	}
+	return p, nil
+}
+
+// Apply runs every filter on text. A blocking match stops the pipeline;
+// redactions are applied before the next filter runs.
+func (p *OutputFilters) Apply(ctx context.Context, text string) FilterResult {
+	res := FilterResult{Text: text}
+	if p == nil {
+		return res
+	}
+	for _, f := range p.filters {
+		action := f.action
+		spans, labels, err := f.impl.match(ctx, res.Text)
+		if err != nil {
+			if !f.failClosed {
+				res.Failures = append(res.Failures, fmt.Errorf("output filter %s: %w", f.name, err))
+				continue
+			}
+			// Output the filter could not check is blocked when it fails closed
+			spans, labels, action = nil, []string{"unavailable"}, ActionBlock
+		}
+		if len(labels) == 0 {
+			continue
+		}
+		count := len(spans)
+		if count == 0 {
+			count = 1
+		}
+		res.Hits = append(res.Hits, FilterHit{Filter: f.name, Type: f.typ, Action: action, Labels: labels, Count: count})
+		OutputFilterHits.WithLabelValues(f.name, f.typ, action).Inc()
+		switch action {
+		case ActionBlock:
+			res.Blocked, res.BlockedBy = true, f.name
+			return res
+		case ActionRedact:
+			res.Text = redactSpans(res.Text, spans, f.replacement)
+		}
+	}
+	return res
+}
+
+// Len returns the number of configured filters.
+func (p *OutputFilters) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.filters)
+}
+
+// redactSpans replaces spans with replacement; no spans replaces the whole text.
+func redactSpans(text string, spans []span, replacement string) string {
+	if len(spans) == 0 {
+		return replacement
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var sb strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			// overlaps the previous match, which already replaced it
+			if s.end > last {
+				last = s.end
+			}
+			continue
+		}
+		sb.WriteString(text[last:s.start])
+		sb.WriteString(replacement)
+		last = s.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// denyList matches whole words and phrases, case-insensitively unless configured.
+type denyList struct {
+	re            *regexp.Regexp
+	caseSensitive bool
+}
+
+func newDenyList(root string, cfg corectx.OutputFilter) (*denyList, error) {
+	words := append([]string(nil), cfg.Words...)
+	if cfg.WordsFile != "" {
+		path := cfg.WordsFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		fileWords, err := readWords(path)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, fileWords...)
+	}
+	alts := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		alt := regexp.QuoteMeta(w)
+		// \b only makes sense next to word characters, e.g. not for "f***"
+		if r, _ := utf8.DecodeRuneInString(w); isWordRune(r) {
+			alt = `\b` + alt
+		}
+		if r, _ := utf8.DecodeLastRuneInString(w); isWordRune(r) {
+			alt += `\b`
+		}
+		alts = append(alts, alt)
+	}
+	if len(alts) == 0 {
+		return nil, fmt.Errorf("deny_list needs words or words_file")
+	}
+	// Longest first, so phrases win over the words they contain
+	sort.SliceStable(alts, func(i, j int) bool { return len(alts[i]) > len(alts[j]) })
+	expr := `(?:` + strings.Join(alts, "|") + `)`
+	if !cfg.CaseSensitive {
+		expr = `(?i)` + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &denyList{re: re, caseSensitive: cfg.CaseSensitive}, nil
+}
+
+func (d *denyList) match(_ context.Context, text string) ([]span, []string, error) {
+	locs := d.re.FindAllStringIndex(text, -1)
+	spans := make([]span, 0, len(locs))
+	seen := map[string]bool{}
+	var labels []string
+	for _, l := range locs {
+		spans = append(spans, span{l[0], l[1]})
+		w := text[l[0]:l[1]]
+		if !d.caseSensitive {
+			w = strings.ToLower(w)
+		}
+		if !seen[w] {
+			seen[w] = true
+			labels = append(labels, w)
+		}
+	}
+	return spans, labels, nil
+}
+
+// isWordRune matches RE2's \b, which only knows ASCII word characters.
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// readWords reads one word or phrase per line; blank lines and # comments are skipped.
+func readWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, sc.Err()
+}
+
+// regexFilter matches any of a list of regular expressions.
+type regexFilter struct {
+	patterns []string
+	res      []*regexp.Regexp
+}
+
+func newRegexFilter(cfg corectx.OutputFilter) (*regexFilter, error) {
+	if len(cfg.Patterns) == 0 {
+		return nil, fmt.Errorf("regex needs patterns")
+	}
+	f := &regexFilter{patterns: cfg.Patterns}
+	for _, p := range cfg.Patterns {
+		expr := p
+		if !cfg.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		f.res = append(f.res, re)
+	}
+	return f, nil
+}
+
+func (f *regexFilter) match(_ context.Context, text string) ([]span, []string, error) {
+	var spans []span
+	var labels []string
+	for i, re := range f.res {
+		locs := re.FindAllStringIndex(text, -1)
+		for _, l := range locs {
+			if l[1] > l[0] {
+				spans = append(spans, span{l[0], l[1]})
+			}
+		}
+		if len(locs) > 0 {
+			labels = append(labels, f.patterns[i])
+		}
+	}
+	return spans, labels, nil
+}
+
+// moderationFilter asks an OpenAI-compatible moderation API to classify the
+// whole response. The key comes from CMP_MODERATION_API_KEY or OPENAI_API_KEY.
+type moderationFilter struct {
+	endpoint   string
+	model      string
+	apiKey     string
+	categories []string
+	threshold  float64
+	client     *http.Client
+}
+
+func newModerationFilter(cfg corectx.OutputFilter) *moderationFilter {
+	f := &moderationFilter{
+		endpoint:   cfg.Endpoint,
+		model:      cfg.Model,
+		apiKey:     os.Getenv("CMP_MODERATION_API_KEY"),
+		categories: cfg.Categories,
+		threshold:  cfg.Threshold,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if f.endpoint == "" {
+		f.endpoint = DefaultModerationEndpoint
+	}
+	if f.apiKey == "" {
+		f.apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	return f
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged        bool               `json:"flagged"`
+		Categories     map[string]bool    `json:"categories"`
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+func (f *moderationFilter) match(ctx context.Context, text string) ([]span, []string, error) {
+	payload := map[string]interface{}{"input": text}
+	if f.model != "" {
+		payload["model"] = f.model
+	}
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+f.apiKey)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, nil, fmt.Errorf("moderation api: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, nil, fmt.Errorf("moderation api: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, r := range out.Results {
+		for cat, flagged := range r.Categories {
+			if f.threshold > 0 {
+				flagged = r.CategoryScores[cat] >= f.threshold
+			}
+			if flagged && f.wants(cat) {
+				seen[cat] = true
+			}
+		}
+	}
+	labels := make([]string, 0, len(seen))
+	for cat := range seen {
+		labels = append(labels, cat)
+	}
+	sort.Strings(labels)
+	return nil, labels, nil
+}
+
+// wants reports whether a flagged category is one this filter acts on.
+func (f *moderationFilter) wants(category string) bool {
+	if len(f.categories) == 0 {
+		return true
+	}
+	for _, c := range f.categories {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package guardrails
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+func filtersFor(t *testing.T, root string, cfgs ...corectx.OutputFilter) *OutputFilters {
+	t.Helper()
+	ctx := &corectx.Context{Guardrails: corectx.Guardrails{OutputFilters: cfgs}}
+	p, err := NewOutputFilters(root, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOutputFilters_DenyList(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "words.txt"), []byte("# profanity\nheck\n\nf***\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := filtersFor(t, root, corectx.OutputFilter{Type: FilterDenyList, Words: []string{"darn", "darn it"}, WordsFile: "words.txt", Action: ActionRedact, Replacement: "***"})
+	res := p.Apply(context.Background(), "Darn it, what the heck. Darned f*** checks out.")
+	if res.Blocked || res.Text != "***, what the ***. Darned *** checks out." {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if len(res.Hits) != 1 || res.Hits[0].Filter != "deny_list_1" || res.Hits[0].Count != 3 ||
+		strings.Join(res.Hits[0].Labels, ",") != "darn it,heck,f***" {
+		t.Fatalf("unexpected hits %+v", res.Hits)
+	}
+	if res := p.Apply(context.Background(), "nothing to see"); len(res.Hits) != 0 || res.Text != "nothing to see" {
+		t.Fatalf("clean text changed: %+v", res)
+	}
+}
+
+func TestOutputFilters_RegexAndActions(t *testing.T) {
+	p := filtersFor(t, "",
+		corectx.OutputFilter{Name: "tickets", Type: FilterRegex, Patterns: []string{`TICKET-\d+`}, Action: ActionAnnotate},
+		corectx.OutputFilter{Name: "cards", Type: FilterRegex, Patterns: []string{`\b\d{4}-\d{4}\b`}, CaseSensitive: true},
+	)
+	res := p.Apply(context.Background(), "see ticket-12")
+	if res.Blocked || res.Text != "see ticket-12" || len(res.Hits) != 1 || res.Hits[0].Action != ActionAnnotate {
+		t.Fatalf("annotate: %+v", res)
+	}
+	res = p.Apply(context.Background(), "card 1234-5678")
+	if !res.Blocked || res.BlockedBy != "cards" {
+		t.Fatalf("block: %+v", res)
+	}
+
+	bad := &corectx.Context{Guardrails: corectx.Guardrails{OutputFilters: []corectx.OutputFilter{{Type: FilterRegex, Patterns: []string{"("}}}}}
+	if _, err := NewOutputFilters("", bad); err == nil {
+		t.Fatal("expected an invalid pattern error")
+	}
+	if p, err := NewOutputFilters("", &corectx.Context{}); p != nil || err != nil || p.Len() != 0 {
+		t.Fatalf("no filters: %v %v", p, err)
+	}
+}
+
+func TestOutputFilters_Moderation(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer mod-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"results":[{"flagged":true,"categories":{"harassment":true,"violence":false},"category_scores":{"harassment":0.4,"violence":0.9}}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("CMP_MODERATION_API_KEY", "mod-key")
+
+	flagged := filtersFor(t, "", corectx.OutputFilter{Name: "mod", Type: FilterModeration, Endpoint: srv.URL, Action: ActionRedact})
+	res := flagged.Apply(context.Background(), "some answer")
+	if res.Text != DefaultReplacement || len(res.Hits) != 1 || res.Hits[0].Labels[0] != "harassment" {
+		t.Fatalf("flagged: %+v", res)
+	}
+	scored := filtersFor(t, "", corectx.OutputFilter{Type: FilterModeration, Endpoint: srv.URL, Categories: []string{"violence"}, Threshold: 0.8})
+	if res := scored.Apply(context.Background(), "some answer"); !res.Blocked {
+		t.Fatalf("threshold: %+v", res)
+	}
+
+	up = false
+	if res := flagged.Apply(context.Background(), "some answer"); res.Blocked || len(res.Failures) != 1 || res.Text != "some answer" {
+		t.Fatalf("fail open: %+v", res)
+	}
+	closed := filtersFor(t, "", corectx.OutputFilter{Type: FilterModeration, Endpoint: srv.URL, Action: ActionAnnotate, FailClosed: true})
+	if res := closed.Apply(context.Background(), "some answer"); !res.Blocked || res.Hits[0].Labels[0] != "unavailable" {
+		t.Fatalf("fail closed: %+v", res)
+	}
+}
@@ -0,0 +1,103 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// DefaultMaxRepairs is the number of repair attempts when a context does not set max_repairs.
+const DefaultMaxRepairs = 2
+
+// ResponseSchemaError reports model output that does not satisfy the declared response schema.
+type ResponseSchemaError struct {
+	Violations []string `json:"violations"`
+}
+
+func (e *ResponseSchemaError) Error() string {
+	return "response does not match schema: " + strings.Join(e.Violations, "; ")
+}
+
+// ResponseSchema returns the declared response schema and repair budget for a context,
+// or a nil schema when output validation is not configured.
+func ResponseSchema(ctx *corectx.Context) (map[string]interface{}, int) {
+	if ctx == nil || ctx.Guardrails.Response == nil || len(ctx.Guardrails.Response.Schema) == 0 {
+		return nil, 0
+	}
+	repairs := DefaultMaxRepairs
+	if r := ctx.Guardrails.Response.MaxRepairs; r != nil && *r >= 0 {
+		repairs = *r
+	}
+	return ctx.Guardrails.Response.Schema, repairs
+}
+
+// ValidateResponseSchema checks response against schema. Markdown code fences around
+// the JSON are tolerated. It returns the compact JSON document on success, or a
+// *ResponseSchemaError listing the violations.
+func ValidateResponseSchema(schema map[string]interface{}, response string) (string, error) {
+	doc := extractJSON(response)
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return "", &ResponseSchemaError{Violations: []string{fmt.Sprintf("invalid json: %v", err)}}
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("encode response schema: %w", err)
+	}
+	res, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schemaJSON), gojsonschema.NewGoLoader(v))
+	if err != nil {
+		return "", fmt.Errorf("response schema invalid: %w", err)
+	}
+	if !res.Valid() {
+		violations := make([]string, 0, len(res.Errors()))
+		for _, e := range res.Errors() {
+			violations = append(violations, e.String())
+		}
+		return "", &ResponseSchemaError{Violations: violations}
+	}
+	compact, _ := json.Marshal(v)
+	return string(compact), nil
+}
+
+// RepairPrompt builds a follow-up prompt asking the model to correct invalid output.
+func RepairPrompt(original, response string, schema map[string]interface{}, violations []string) string {
+	schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+	var sb strings.Builder
+	sb.WriteString(original)
+	sb.WriteString("\n\nYour previous answer was not valid for the required JSON schema.\n\nPrevious answer:\n")
+	sb.WriteString(response)
+	sb.WriteString("\n\nProblems:\n")
+	for _, v := range violations {
+		sb.WriteString("- " + v + "\n")
+	}
+	sb.WriteString("\nRequired JSON schema:\n")
+	sb.Write(schemaJSON)
+	sb.WriteString("\n\nRespond again with only a JSON document that satisfies the schema.")
+	return sb.String()
+}
+
+// extractJSON strips surrounding prose and markdown fences from a model answer.
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "```"); i >= 0 {
+		rest := s[i+3:]
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+			rest = rest[nl+1:]
+		}
+		if j := strings.Index(rest, "```"); j >= 0 {
+			return strings.TrimSpace(rest[:j])
+		}
+	}
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return s
+	}
+	end := strings.LastIndexAny(s, "}]")
+	if end < start {
+		return s
+	}
+	return s[start : end+1]
+}
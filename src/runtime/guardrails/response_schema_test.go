@@ -0,0 +1,50 @@
+package guardrails
+
+import (
+	"errors"
+	"testing"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+)
+
+var answerSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []interface{}{"answer", "confidence"},
+	"properties": map[string]interface{}{
+		"answer":     map[string]interface{}{"type": "string"},
+		"confidence": map[string]interface{}{"type": "number"},
+	},
+}
+
+func TestValidateResponseSchema(t *testing.T) {
+	out, err := ValidateResponseSchema(answerSchema, "Here you go:\n```json\n{\"answer\": \"yes\", \"confidence\": 0.9}\n```")
+	if err != nil {
+		t.Fatalf("expected valid response, got %v", err)
+	}
+	if out != `{"answer":"yes","confidence":0.9}` {
+		t.Fatalf("unexpected normalized output %q", out)
+	}
+	_, err = ValidateResponseSchema(answerSchema, `{"answer": 42}`)
+	var schemaErr *ResponseSchemaError
+	if !errors.As(err, &schemaErr) || len(schemaErr.Violations) != 2 {
+		t.Fatalf("expected two violations, got %v", err)
+	}
+	if _, err := ValidateResponseSchema(answerSchema, "not json"); !errors.As(err, &schemaErr) {
+		t.Fatalf("expected schema error for non-JSON output, got %v", err)
+	}
+}
+
+func TestResponseSchemaDefaults(t *testing.T) {
+	if s, _ := ResponseSchema(&corectx.Context{}); s != nil {
+		t.Fatalf("expected no schema")
+	}
+	zero := 0
+	ctx := &corectx.Context{Guardrails: corectx.Guardrails{Response: &corectx.ResponseGuardrails{Schema: answerSchema}}}
+	if _, n := ResponseSchema(ctx); n != DefaultMaxRepairs {
+		t.Fatalf("expected default repairs, got %d", n)
+	}
+	ctx.Guardrails.Response.MaxRepairs = &zero
+	if _, n := ResponseSchema(ctx); n != 0 {
+		t.Fatalf("expected explicit zero repairs, got %d", n)
+	}
+}
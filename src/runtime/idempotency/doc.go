@@ -0,0 +1,11 @@
+// Package idempotency stores the responses of requests sent with an
+// Idempotency-Key header, so a client retrying a chat or ingest request gets
+// the first response back instead of paying for inference or ingesting the
+// documents twice.
+//
+// Like the job queue, records are JSON files under data/idempotency, one per
+// key, so replicas sharing the project volume see each other's keys. A key is
+// reserved atomically before the request runs; a concurrent duplicate is told
+// the first request is still in progress. Records expire after the dedupe
+// window.
+package idempotency
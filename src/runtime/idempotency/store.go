@@ -0,0 +1,228 @@
+package idempotency
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir is the project-relative directory holding one JSON file per key.
+const Dir = "data/idempotency"
+
+// DefaultWindow is how long a stored response is replayed.
+const DefaultWindow = 24 * time.Hour
+
+// PendingTimeout is how long a reservation lasts without a response, so the
+// key of a request whose server crashed can be retried.
+const PendingTimeout = 10 * time.Minute
+
+// pruneInterval is how often Begin removes expired records.
+const pruneInterval = time.Hour
+
+var (
+	// ErrInProgress is returned for a key whose first request has not finished.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch is returned when a key is reused for a different request.
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Record is the file stored for a key. Response is nil while the first
+// request is running.
+type Record struct {
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Store persists idempotency records under a project root.
+type Store struct {
+	dir    string
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewStore returns the idempotency store of a project root. Responses are
+// replayed for window; zero means DefaultWindow.
+func NewStore(root string, window time.Duration) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Store{dir: filepath.Join(root, Dir), window: window, now: time.Now}
+}
+
+// Window is the dedupe window of the store.
+func (s *Store) Window() time.Duration { return s.window }
+
+// Begin reserves key for a request identified by fingerprint. It returns the
+// stored response when the key was already answered for the same request,
+// ErrInProgress or ErrMismatch, or nil when the caller now holds the key and
+// must Complete or Release it.
+func (s *Store) Begin(key, fingerprint string) (*Response, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+	s.maybePrune()
+	now := s.now().UTC()
+	pending := Record{Fingerprint: fingerprint, CreatedAt: now, ExpiresAt: now.Add(PendingTimeout)}
+	path := s.path(key)
+	for attempt := 0; attempt < 3; attempt++ {
+		created, err := s.create(path, pending)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return nil, nil
+		}
+		rec, err := s.read(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !now.Before(rec.ExpiresAt) {
+			_ = os.Remove(path)
+			continue
+		}
+		if rec.Fingerprint != fingerprint {
+			return nil, ErrMismatch
+		}
+		if rec.Response == nil {
+			return nil, ErrInProgress
+		}
+		return rec.Response, nil
+	}
+	return nil, fmt.Errorf("reserve idempotency key: %s keeps changing", path)
+}
+
+// Complete stores the response of the request that holds key.
+func (s *Store) Complete(key string, resp Response) error {
+	path := s.path(key)
+	rec, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	now := s.now().UTC()
+	rec.Response = &resp
+	rec.ExpiresAt = now.Add(s.window)
+	tmp, err := s.writeTemp(rec)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Release drops the reservation of key, so the request can be retried.
+func (s *Store) Release(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Prune removes expired records and returns how many were removed.
+func (s *Store) Prune() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		rec, err := s.read(path)
+		if err != nil || !now.Before(rec.ExpiresAt) {
+			if os.Remove(path) == nil {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) maybePrune() {
+	s.mu.Lock()
+	due := s.now().Sub(s.lastPrune) >= pruneInterval
+	if due {
+		s.lastPrune = s.now()
+	}
+	s.mu.Unlock()
+	if due {
+		_, _ = s.Prune()
+	}
+}
+
+// path maps a key to its file; keys are hashed since they are client input.
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// create writes rec to path unless the file exists. The record is written to
+// a temporary file and hard-linked into place, so readers never see a
+// partially written reservation.
+func (s *Store) create(path string, rec Record) (bool, error) {
+	tmp, err := s.writeTemp(rec)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	err = os.Link(tmp, path)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) writeTemp(rec Record) (string, error) {
+	by, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	tmp := filepath.Join(s.dir, ".tmp-"+hex.EncodeToString(b[:]))
+	if err := os.WriteFile(tmp, by, 0o600); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+func (s *Store) read(path string) (Record, error) {
+	var rec Record
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(by, &rec); err != nil {
+		return rec, fmt.Errorf("parse idempotency record %s: %w", filepath.Base(path), err)
+	}
+	return rec, nil
+}
@@ -0,0 +1,67 @@
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStore_ReserveReplayAndMismatch(t *testing.T) {
+	s := NewStore(t.TempDir(), time.Hour)
+	if resp, err := s.Begin("k1", "fp"); resp != nil || err != nil {
+		t.Fatalf("first begin = %+v, %v", resp, err)
+	}
+	if _, err := s.Begin("k1", "fp"); !errors.Is(err, ErrInProgress) {
+		t.Fatalf("concurrent begin = %v", err)
+	}
+	want := Response{Status: http.StatusCreated, Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"ok":true}`)}
+	if err := s.Complete("k1", want); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Begin("k1", "fp")
+	if err != nil || resp == nil || resp.Status != want.Status || string(resp.Body) != string(want.Body) || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("replay = %+v, %v", resp, err)
+	}
+	if _, err := s.Begin("k1", "other"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("reuse with another request = %v", err)
+	}
+
+	// Released keys run again
+	if resp, err := s.Begin("k2", "fp"); resp != nil || err != nil {
+		t.Fatalf("begin k2 = %+v, %v", resp, err)
+	}
+	if err := s.Release("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := s.Begin("k2", "fp"); resp != nil || err != nil {
+		t.Fatalf("begin after release = %+v, %v", resp, err)
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	s := NewStore(t.TempDir(), time.Hour)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	_, _ = s.Begin("done", "fp")
+	_ = s.Complete("done", Response{Status: http.StatusOK})
+	_, _ = s.Begin("abandoned", "fp")
+
+	// An abandoned reservation can be taken over after PendingTimeout
+	clock = clock.Add(PendingTimeout)
+	if resp, err := s.Begin("abandoned", "fp"); resp != nil || err != nil {
+		t.Fatalf("begin abandoned key = %+v, %v", resp, err)
+	}
+	if resp, _ := s.Begin("done", "fp"); resp == nil {
+		t.Fatal("expected a replay inside the window")
+	}
+
+	clock = clock.Add(time.Hour)
+	if n, err := s.Prune(); err != nil || n != 2 {
+		t.Fatalf("prune = %d, %v", n, err)
+	}
+	if resp, err := s.Begin("done", "fp"); resp != nil || err != nil {
+		t.Fatalf("begin after the window = %+v, %v", resp, err)
+	}
+}
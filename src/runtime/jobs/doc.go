@@ -0,0 +1,9 @@
+// Package jobs implements the queue of long-running operations.
+//
+// The server files jobs (bulk memory ingestion, memory retention, evaluation runs) under
+// data/jobs and returns immediately; `ctx worker start` claims them, runs
+// them and records their result. A claim is a lease that the worker renews
+// while the job runs, so jobs of a crashed worker are picked up again once
+// their lease expires. Failed jobs are retried with backoff up to their
+// attempt limit.
+package jobs
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+)
+
+var componentRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Document is a document to ingest; ID is its source path.
+type Document struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// MemoryIngestPayload is the payload of a memory.ingest job. Either Documents
+// are ingested (replacing documents with the same ID), or with Sync the store
+// is made to match memory/<component>/documents like `ctx memory ingest --all`.
+type MemoryIngestPayload struct {
+	Component string     `json:"component"`
+	Documents []Document `json:"documents,omitempty"`
+	Sync      bool       `json:"sync,omitempty"`
+}
+
+// Validate checks the payload before it is queued.
+func (p MemoryIngestPayload) Validate() error {
+	if !componentRe.MatchString(p.Component) {
+		return fmt.Errorf("invalid component name %q", p.Component)
+	}
+	if p.Sync == (len(p.Documents) > 0) {
+		return fmt.Errorf("either documents or sync is required")
+	}
+	seen := map[string]bool{}
+	for _, d := range p.Documents {
+		if !runtimememory.ValidSourcePath(d.ID) {
+			return fmt.Errorf("invalid document id %q", d.ID)
+		}
+		if seen[d.ID] {
+			return fmt.Errorf("duplicate document id %q", d.ID)
+		}
+		if strings.TrimSpace(d.Content) == "" {
+			return fmt.Errorf("document content is required: %s", d.ID)
+		}
+		seen[d.ID] = true
+	}
+	return nil
+}
+
+// EvalRunPayload is the payload of an eval.run job. Empty filters run every
+// suite, like `ctx eval run`. Scores are recorded in the drift history and
+// alert when below Threshold.
+type EvalRunPayload struct {
+	Component string  `json:"component,omitempty"`
+	Spec      string  `json:"spec,omitempty"`
+	Schedule  string  `json:"schedule,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// Validate checks the payload before it is queued.
+func (p EvalRunPayload) Validate() error {
+	if p.Component != "" && !componentRe.MatchString(p.Component) {
+		return fmt.Errorf("invalid component name %q", p.Component)
+	}
+	return validateThreshold(p.Threshold)
+}
+
+// DriftRunPayload is the payload of a drift.run job. An empty Component runs
+// every drift spec, like `ctx test --drift-detection`.
+type DriftRunPayload struct {
+	Component string  `json:"component,omitempty"`
+	Schedule  string  `json:"schedule,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// Validate checks the payload before it is queued.
+func (p DriftRunPayload) Validate() error {
+	if p.Component != "" && !componentRe.MatchString(p.Component) {
+		return fmt.Errorf("invalid component name %q", p.Component)
+	}
+	return validateThreshold(p.Threshold)
+}
+
+// MemoryPrunePayload is the payload of a memory.prune job. An empty Component
+// prunes the episodic memory of every component with a retention policy.
+type MemoryPrunePayload struct {
+	Component string `json:"component,omitempty"`
+	Schedule  string `json:"schedule,omitempty"`
+}
+
+// Validate checks the payload before it is queued.
+func (p MemoryPrunePayload) Validate() error {
+	if p.Component != "" && !componentRe.MatchString(p.Component) {
+		return fmt.Errorf("invalid component name %q", p.Component)
+	}
+	return nil
+}
+
+func validateThreshold(t float64) error {
+	if t < 0 || t > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// ValidatePayload decodes and checks the payload of a job of a known type.
+func ValidatePayload(jobType string, payload json.RawMessage) error {
+	var v interface{ Validate() error }
+	switch jobType {
+	case TypeMemoryIngest:
+		v = &MemoryIngestPayload{}
+	case TypeEvalRun:
+		v = &EvalRunPayload{}
+	case TypeDriftRun:
+		v = &DriftRunPayload{}
+	case TypeMemoryPrune:
+		v = &MemoryPrunePayload{}
+	default:
+		return fmt.Errorf("unknown job type %q (known: %s)", jobType, strings.Join(Types, ", "))
+	}
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", jobType, err)
+	}
+	return v.Validate()
+}
@@ -0,0 +1,454 @@
+package jobs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dir is the project-relative directory holding one JSON file per job.
+const Dir = "data/jobs"
+
+// DefaultMaxAttempts is how often a job runs before it is marked failed.
+const DefaultMaxAttempts = 3
+
+// DefaultLease is how long a claim lasts without renewal.
+const DefaultLease = time.Minute
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Job types handled by `ctx worker start`.
+const (
+	// TypeMemoryIngest ingests documents into a component's memory store.
+	TypeMemoryIngest = "memory.ingest"
+	// TypeEvalRun runs evaluation suites.
+	TypeEvalRun = "eval.run"
+	// TypeDriftRun runs drift detection.
+	TypeDriftRun = "drift.run"
+	// TypeMemoryPrune applies episodic memory retention policies.
+	TypeMemoryPrune = "memory.prune"
+)
+
+// Types lists the job types that can be submitted.
+var Types = []string{TypeMemoryIngest, TypeEvalRun, TypeDriftRun, TypeMemoryPrune}
+
+var (
+	// ErrNotFound is returned for unknown job IDs.
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that already finished.
+	ErrFinished = errors.New("job already finished")
+	// ErrLeaseLost is returned to a worker whose claim on a job was taken
+	// over or cancelled.
+	ErrLeaseLost = errors.New("job lease lost")
+)
+
+var idRe = regexp.MustCompile(`^job_[0-9a-f]{16}$`)
+
+const (
+	lockFile    = ".lock"
+	lockTimeout = 5 * time.Second
+	// staleLock is the age after which a lock left by a crashed process is broken.
+	staleLock = 30 * time.Second
+)
+
+// Job is a queued operation. Payload is interpreted by the handler of Type;
+// Result is what the handler returned on success.
+type Job struct {
+	ID             string          `json:"id"`
+	Type           string          `json:"type"`
+	Key            string          `json:"key,omitempty"` // deduplicates submissions
+	TenantID       string          `json:"tenant_id,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	Submitter      string          `json:"submitter,omitempty"` // API key ID of the caller
+	Worker         string          `json:"worker,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	RunAfter       time.Time       `json:"run_after"`
+	LeaseExpiresAt *time.Time      `json:"lease_expires_at,omitempty"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job reached a final status.
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// KnownType reports whether t is one of Types.
+func KnownType(t string) bool {
+	for _, k := range Types {
+		if k == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Queue persists jobs as files so the server and any number of worker
+// processes share one queue. Claims are serialized with a lock file.
+type Queue struct {
+	dir string
+	// Backoff returns the delay before a job is retried after its n-th
+	// failed attempt.
+	Backoff func(attempt int) time.Duration
+
+	mu sync.Mutex
+}
+
+// NewQueue returns the job queue of a project root.
+func NewQueue(root string) *Queue {
+	return &Queue{dir: filepath.Join(root, Dir), Backoff: defaultBackoff}
+}
+
+// defaultBackoff waits 5s, 20s, 80s, ... capped at 10 minutes.
+func defaultBackoff(attempt int) time.Duration {
+	d := 5 * time.Second
+	for i := 1; i < attempt && d < 10*time.Minute; i++ {
+		d *= 4
+	}
+	if d > 10*time.Minute {
+		d = 10 * time.Minute
+	}
+	return d
+}
+
+// Submit queues a job of job.Type with job.Payload for tenant job.TenantID.
+// When job.Key is set and a job with that key exists, that job is returned
+// instead, so schedulers on several workers queue each run once.
+func (q *Queue) Submit(job Job) (Job, error) {
+	if job.Type == "" {
+		return Job{}, fmt.Errorf("job type is required")
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	job.ID = "job_" + hex.EncodeToString(b[:])
+	job.Status = StatusQueued
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	job.Attempts, job.Worker, job.Error, job.Result = 0, "", "", nil
+	job.CreatedAt, job.UpdatedAt, job.RunAfter = now, now, now
+	job.LeaseExpiresAt, job.FinishedAt = nil, nil
+	unlock, err := q.lock()
+	if err != nil {
+		return Job{}, err
+	}
+	defer unlock()
+	if job.Key != "" {
+		all, err := q.all()
+		if err != nil {
+			return Job{}, err
+		}
+		for _, j := range all {
+			if j.Key == job.Key {
+				return j, nil
+			}
+		}
+	}
+	return job, q.write(job)
+}
+
+// Get returns a job.
+func (q *Queue) Get(id string) (Job, error) {
+	return q.read(id)
+}
+
+// List returns jobs oldest first, optionally only those with status or of a tenant.
+func (q *Queue) List(status, tenantID string) ([]Job, error) {
+	all, err := q.all()
+	if err != nil {
+		return nil, err
+	}
+	out := []Job{}
+	for _, j := range all {
+		if (status == "" || j.Status == status) && (tenantID == "" || j.TenantID == tenantID) {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+// Claim leases the oldest runnable job of one of types (any type when empty)
+// to worker. A job is runnable when it is queued and due, or running with an
+// expired lease. ok is false when no job is runnable.
+func (q *Queue) Claim(worker string, types []string, lease time.Duration) (job Job, ok bool, err error) {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	unlock, err := q.lock()
+	if err != nil {
+		return Job{}, false, err
+	}
+	defer unlock()
+	all, err := q.all()
+	if err != nil {
+		return Job{}, false, err
+	}
+	now := time.Now().UTC()
+	for _, j := range all {
+		if len(types) > 0 && !contains(types, j.Type) {
+			continue
+		}
+		switch {
+		case j.Status == StatusQueued && !now.Before(j.RunAfter):
+		case j.Status == StatusRunning && j.LeaseExpiresAt != nil && now.After(*j.LeaseExpiresAt):
+			// The worker holding it went away mid-run
+			if j.Attempts >= j.MaxAttempts {
+				j.Status, j.Error, j.FinishedAt, j.LeaseExpiresAt = StatusFailed, "lease expired: worker "+j.Worker+" stopped renewing", &now, nil
+				j.UpdatedAt = now
+				if err := q.write(j); err != nil {
+					return Job{}, false, err
+				}
+				continue
+			}
+		default:
+			continue
+		}
+		expires := now.Add(lease)
+		j.Status, j.Worker, j.LeaseExpiresAt, j.UpdatedAt = StatusRunning, worker, &expires, now
+		j.Attempts++
+		if err := q.write(j); err != nil {
+			return Job{}, false, err
+		}
+		return j, true, nil
+	}
+	return Job{}, false, nil
+}
+
+// Renew extends worker's lease on a running job. It returns ErrLeaseLost when
+// the job was cancelled or claimed by another worker.
+func (q *Queue) Renew(id, worker string, lease time.Duration) error {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	_, err := q.update(id, worker, func(j *Job, now time.Time) {
+		expires := now.Add(lease)
+		j.LeaseExpiresAt = &expires
+	})
+	return err
+}
+
+// Complete marks worker's running job succeeded with result.
+func (q *Queue) Complete(id, worker string, result interface{}) (Job, error) {
+	var raw json.RawMessage
+	if result != nil {
+		by, err := json.Marshal(result)
+		if err != nil {
+			return Job{}, fmt.Errorf("encode job result: %w", err)
+		}
+		raw = by
+	}
+	return q.update(id, worker, func(j *Job, now time.Time) {
+		j.Status, j.Result, j.Error = StatusSucceeded, raw, ""
+		j.FinishedAt, j.LeaseExpiresAt = &now, nil
+	})
+}
+
+// Fail records a failed attempt of worker's running job. The job is queued
+// again after the backoff unless retry is false or it ran MaxAttempts times.
+func (q *Queue) Fail(id, worker string, cause error, retry bool) (Job, error) {
+	backoff := q.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+	return q.update(id, worker, func(j *Job, now time.Time) {
+		j.Error, j.LeaseExpiresAt = cause.Error(), nil
+		if retry && j.Attempts < j.MaxAttempts {
+			j.Status, j.RunAfter = StatusQueued, now.Add(backoff(j.Attempts))
+			return
+		}
+		j.Status, j.FinishedAt = StatusFailed, &now
+	})
+}
+
+// Release returns worker's running job to the queue without counting the
+// attempt, e.g. when the worker shuts down mid-run.
+func (q *Queue) Release(id, worker string) (Job, error) {
+	return q.update(id, worker, func(j *Job, now time.Time) {
+		j.Status, j.RunAfter, j.Worker, j.LeaseExpiresAt = StatusQueued, now, "", nil
+		if j.Attempts > 0 {
+			j.Attempts--
+		}
+	})
+}
+
+// Cancel stops a queued or running job. A running job's worker notices on its
+// next lease renewal.
+func (q *Queue) Cancel(id, reason string) (Job, error) {
+	unlock, err := q.lock()
+	if err != nil {
+		return Job{}, err
+	}
+	defer unlock()
+	j, err := q.read(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if j.Finished() {
+		return j, fmt.Errorf("%w: %s is %s", ErrFinished, id, j.Status)
+	}
+	now := time.Now().UTC()
+	j.Status, j.Error, j.FinishedAt, j.LeaseExpiresAt, j.UpdatedAt = StatusCancelled, reason, &now, nil, now
+	return j, q.write(j)
+}
+
+// update applies fn to a job running under worker's lease.
+func (q *Queue) update(id, worker string, fn func(j *Job, now time.Time)) (Job, error) {
+	unlock, err := q.lock()
+	if err != nil {
+		return Job{}, err
+	}
+	defer unlock()
+	j, err := q.read(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if j.Status != StatusRunning || j.Worker != worker {
+		return j, fmt.Errorf("%w: %s is %s", ErrLeaseLost, id, j.Status)
+	}
+	now := time.Now().UTC()
+	fn(&j, now)
+	j.UpdatedAt = now
+	return j, q.write(j)
+}
+
+// lock serializes queue changes across goroutines and processes. Stale locks
+// left by a crashed process are broken after staleLock.
+func (q *Queue) lock() (func(), error) {
+	q.mu.Lock()
+	if err := os.MkdirAll(q.dir, 0o755); err != nil {
+		q.mu.Unlock()
+		return nil, err
+	}
+	path := filepath.Join(q.dir, lockFile)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() {
+				_ = os.Remove(path)
+				q.mu.Unlock()
+			}, nil
+		}
+		if !os.IsExist(err) {
+			q.mu.Unlock()
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			q.mu.Unlock()
+			return nil, fmt.Errorf("job queue is locked: %s", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// all returns every job oldest first.
+func (q *Queue) all() ([]Job, error) {
+	entries, err := os.ReadDir(q.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Job
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || !idRe.MatchString(id) {
+			continue
+		}
+		j, err := q.read(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].CreatedAt.Before(out[k].CreatedAt) })
+	return out, nil
+}
+
+func (q *Queue) read(id string) (Job, error) {
+	if !idRe.MatchString(id) {
+		return Job{}, ErrNotFound
+	}
+	by, err := os.ReadFile(filepath.Join(q.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return Job{}, ErrNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	var j Job
+	if err := json.Unmarshal(by, &j); err != nil {
+		return Job{}, fmt.Errorf("parse job %s: %w", id, err)
+	}
+	// Job files are indented; hand payloads and results out compact
+	j.Payload, j.Result = compact(j.Payload), compact(j.Result)
+	return j, nil
+}
+
+func compact(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// write stores a job atomically. Callers hold the queue lock.
+func (q *Queue) write(j Job) error {
+	by, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, j.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, by, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
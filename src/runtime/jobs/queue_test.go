@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueue_ClaimRetryComplete(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	q.Backoff = func(int) time.Duration { return 0 }
+	job, err := q.Submit(Job{Type: TypeMemoryIngest, TenantID: "acme", Payload: json.RawMessage(`{"component":"Docs"}`), MaxAttempts: 2})
+	if err != nil || job.Status != StatusQueued {
+		t.Fatalf("submit = %+v, %v", job, err)
+	}
+	if _, ok, _ := q.Claim("w1", []string{TypeEvalRun}, time.Minute); ok {
+		t.Fatal("claimed a job of another type")
+	}
+	claimed, ok, err := q.Claim("w1", nil, time.Minute)
+	if err != nil || !ok || claimed.ID != job.ID || claimed.Attempts != 1 || claimed.Status != StatusRunning {
+		t.Fatalf("claim = %+v, %v, %v", claimed, ok, err)
+	}
+	if _, ok, _ := q.Claim("w2", nil, time.Minute); ok {
+		t.Fatal("claimed a job leased to another worker")
+	}
+	if err := q.Renew(job.ID, "w2", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("renew by another worker = %v", err)
+	}
+
+	failed, err := q.Fail(job.ID, "w1", errors.New("boom"), true)
+	if err != nil || failed.Status != StatusQueued || failed.Error != "boom" {
+		t.Fatalf("fail = %+v, %v", failed, err)
+	}
+	claimed, ok, _ = q.Claim("w2", nil, time.Minute)
+	if !ok || claimed.Attempts != 2 {
+		t.Fatalf("retry claim = %+v, %v", claimed, ok)
+	}
+	done, err := q.Complete(job.ID, "w2", map[string]int{"chunks": 3})
+	if err != nil || done.Status != StatusSucceeded || string(done.Result) != `{"chunks":3}` || done.FinishedAt == nil {
+		t.Fatalf("complete = %+v, %v", done, err)
+	}
+	if _, err := q.Cancel(job.ID, "late"); !errors.Is(err, ErrFinished) {
+		t.Fatalf("cancel finished job = %v", err)
+	}
+	list, err := q.List(StatusSucceeded, "acme")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+}
+
+func TestQueue_AttemptsAndExpiredLeases(t *testing.T) {
+	q := NewQueue(t.TempDir())
+	job, _ := q.Submit(Job{Type: TypeEvalRun, MaxAttempts: 2})
+
+	// A worker that stops renewing loses the job to the next claim
+	if _, ok, _ := q.Claim("crashed", nil, time.Millisecond); !ok {
+		t.Fatal("expected a claim")
+	}
+	time.Sleep(5 * time.Millisecond)
+	claimed, ok, _ := q.Claim("w2", nil, time.Minute)
+	if !ok || claimed.Worker != "w2" || claimed.Attempts != 2 {
+		t.Fatalf("reclaim = %+v, %v", claimed, ok)
+	}
+	if _, err := q.Complete(job.ID, "crashed", nil); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("complete by the old worker = %v", err)
+	}
+
+	// The last attempt fails for good
+	failed, err := q.Fail(job.ID, "w2", errors.New("boom"), true)
+	if err != nil || failed.Status != StatusFailed || failed.FinishedAt == nil {
+		t.Fatalf("fail = %+v, %v", failed, err)
+	}
+
+	// Cancelled jobs are never claimed
+	other, _ := q.Submit(Job{Type: TypeEvalRun})
+	if _, err := q.Cancel(other.ID, "not needed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := q.Claim("w3", nil, time.Minute); ok {
+		t.Fatal("claimed a finished job")
+	}
+	if _, err := q.Get("job_unknown"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get unknown = %v", err)
+	}
+}
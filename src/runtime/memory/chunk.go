@@ -0,0 +1,68 @@
+package runtimememory
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkSettings reads document_processing.chunk_size and chunk_overlap
+// (characters). A zero size keeps each document as a single chunk.
+func chunkSettings(settings map[string]string) (size, overlap int) {
+	size, _ = strconv.Atoi(settings["chunk_size"])
+	overlap, _ = strconv.Atoi(settings["chunk_overlap"])
+	if size < 0 {
+		size = 0
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	return size, overlap
+}
+
+// ChunkText splits text into chunks of at most size characters, preferring
+// paragraph and then word boundaries, with overlap characters repeated from the
+// end of the previous chunk. size <= 0 returns the trimmed text as one chunk.
+func ChunkText(text string, size, overlap int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if size <= 0 || utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+	var chunks []string
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, strings.TrimSpace(string(runes[start:])))
+			break
+		}
+		end = breakPoint(runes, start, end)
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakPoint moves end back to the last paragraph break, or failing that the last
+// whitespace, in the second half of runes[start:end].
+func breakPoint(runes []rune, start, end int) int {
+	floor := start + (end-start)/2
+	for i := end; i > floor; i-- {
+		if runes[i-1] == '\n' && i >= 2 && runes[i-2] == '\n' {
+			return i
+		}
+	}
+	for i := end; i > floor; i-- {
+		if runes[i-1] == ' ' || runes[i-1] == '\n' || runes[i-1] == '\t' {
+			return i
+		}
+	}
+	return end
+}
@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
-	"gopkg.in/yaml.v3"
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
 )
 
 // LoadComponentMemoryConfig loads memory_config.yaml for a component if present and merges into cfg.Settings.
+// ${VAR} and ${VAR:-default} placeholders are expanded from the environment.
 func LoadComponentMemoryConfig(cfg *Config) error {
 	path := filepath.Join(cfg.RootDir, "memory", cfg.ComponentName, "memory_config.yaml")
 	by, err := os.ReadFile(path)
@@ -17,7 +20,7 @@ func LoadComponentMemoryConfig(cfg *Config) error {
 		return nil
 	}
 	var m map[string]interface{}
-	if err := yaml.Unmarshal(by, &m); err != nil {
+	if err := runtimeconfig.Unmarshal(by, &m); err != nil {
 		return fmt.Errorf("parse memory_config.yaml: %w", err)
 	}
 	if cfg.Settings == nil {
@@ -39,14 +42,164 @@ func LoadComponentMemoryConfig(cfg *Config) error {
 		if d, ok := em["dimensions"].(int); ok {
 			cfg.Settings["embedding_dim"] = fmt.Sprintf("%d", d)
 		}
+		// provider: openai|cohere|voyage embeds with the hosted API (default local)
+		for _, key := range []string{"provider", "endpoint", "api_key_env"} {
+			if v, ok := em[key].(string); ok {
+				cfg.Settings["embedding_"+key] = v
+			}
+		}
+		if n, ok := em["requests_per_minute"].(int); ok {
+			cfg.Settings["embedding_requests_per_minute"] = fmt.Sprintf("%d", n)
+		}
+		// explicit settings (e.g. CLI flags) take precedence for the ingest pool
+		if n, ok := em["concurrency"].(int); ok && cfg.Settings["embedding_concurrency"] == "" {
+			cfg.Settings["embedding_concurrency"] = fmt.Sprintf("%d", n)
+		}
+		if n, ok := em["batch_size"].(int); ok && cfg.Settings["embedding_batch_size"] == "" {
+			cfg.Settings["embedding_batch_size"] = fmt.Sprintf("%d", n)
+		}
+		// language routing: chunks not in `language` go to `multilingual`
+		// unless `languages` names a model for their language
+		if l, ok := em["language"].(string); ok {
+			cfg.Settings["embedding_language"] = l
+		}
+		if mdl, ok := em["multilingual"].(string); ok {
+			cfg.Settings["embedding_multilingual"] = mdl
+		}
+		if langs, ok := em["languages"].(map[string]interface{}); ok {
+			pairs := make([]string, 0, len(langs))
+			for l, mdl := range langs {
+				pairs = append(pairs, fmt.Sprintf("%s=%v", l, mdl))
+			}
+			sort.Strings(pairs)
+			cfg.Settings["embedding_languages"] = strings.Join(pairs, ",")
+		}
+	}
+	if dp, ok := m["document_processing"].(map[string]interface{}); ok {
+		if n, ok := dp["chunk_size"].(int); ok {
+			cfg.Settings["chunk_size"] = fmt.Sprintf("%d", n)
+		}
+		if n, ok := dp["chunk_overlap"].(int); ok {
+			cfg.Settings["chunk_overlap"] = fmt.Sprintf("%d", n)
+		}
+		if ocr, ok := dp["ocr"].(map[string]interface{}); ok {
+			if en, ok := ocr["enabled"].(bool); ok && en {
+				cfg.Settings["ocr_enabled"] = "true"
+			}
+			// languages: [eng, deu] or "eng+deu"
+			switch langs := ocr["languages"].(type) {
+			case string:
+				cfg.Settings["ocr_languages"] = langs
+			case []interface{}:
+				names := make([]string, 0, len(langs))
+				for _, l := range langs {
+					names = append(names, fmt.Sprint(l))
+				}
+				cfg.Settings["ocr_languages"] = strings.Join(names, "+")
+			}
+			switch c := ocr["min_confidence"].(type) {
+			case int, float64:
+				cfg.Settings["ocr_min_confidence"] = fmt.Sprint(c)
+			}
+			if n, ok := ocr["dpi"].(int); ok {
+				cfg.Settings["ocr_dpi"] = fmt.Sprintf("%d", n)
+			}
+		}
+	}
+	if sr, ok := m["search"].(map[string]interface{}); ok {
+		if mode, ok := sr["mode"].(string); ok {
+			cfg.Settings["search_mode"] = mode
+		}
+		if k, ok := sr["rrf_k"].(int); ok {
+			cfg.Settings["rrf_k"] = fmt.Sprintf("%d", k)
+		}
+		// index: hnsw searches an approximate nearest neighbor graph
+		if idx, ok := sr["index"].(string); ok {
+			cfg.Settings["search_index"] = strings.ToLower(idx)
+		}
+		if h, ok := sr["hnsw"].(map[string]interface{}); ok {
+			for _, key := range []string{"m", "ef_construction", "ef_search"} {
+				if n, ok := h[key].(int); ok {
+					cfg.Settings["hnsw_"+key] = fmt.Sprintf("%d", n)
+				}
+			}
+		}
+	}
+	// deduplication: action keep|skip|merge; near_threshold enables near-duplicate detection
+	if dd, ok := m["deduplication"].(map[string]interface{}); ok {
+		if a, ok := dd["action"].(string); ok {
+			cfg.Settings["dedupe_action"] = strings.ToLower(a)
+		}
+		switch t := dd["near_threshold"].(type) {
+		case int, float64:
+			cfg.Settings["dedupe_near_threshold"] = fmt.Sprint(t)
+		}
+	}
+	if rr, ok := m["rerank"].(map[string]interface{}); ok {
+		if p, ok := rr["provider"].(string); ok {
+			cfg.Settings["rerank_provider"] = p
+		}
+		if mdl, ok := rr["model"].(string); ok {
+			cfg.Settings["rerank_model"] = mdl
+		}
+		if n, ok := rr["top_n"].(int); ok {
+			cfg.Settings["rerank_top_n"] = fmt.Sprintf("%d", n)
+		}
+		if n, ok := rr["candidates"].(int); ok {
+			cfg.Settings["rerank_candidates"] = fmt.Sprintf("%d", n)
+		}
+	}
+	// multi_query: search paraphrases of the query too and merge the results
+	if mq, ok := m["multi_query"].(map[string]interface{}); ok {
+		if en, ok := mq["enabled"].(bool); ok && en {
+			cfg.Settings["multi_query_enabled"] = "true"
+		}
+		if mode, ok := mq["mode"].(string); ok {
+			cfg.Settings["multi_query_mode"] = mode
+		}
+		if n, ok := mq["queries"].(int); ok {
+			cfg.Settings["multi_query_queries"] = fmt.Sprintf("%d", n)
+		}
+		switch d := mq["max_extra_latency"].(type) {
+		case string:
+			cfg.Settings["multi_query_max_extra_latency"] = d
+		case int:
+			// plain numbers are milliseconds
+			cfg.Settings["multi_query_max_extra_latency"] = fmt.Sprintf("%dms", d)
+		}
+	}
+	// freshness: exclude documents out of their validity window, boost recent ones
+	if fr, ok := m["freshness"].(map[string]interface{}); ok {
+		if ex, ok := fr["exclude_inactive"].(bool); ok && !ex {
+			cfg.Settings["freshness_exclude_inactive"] = "false"
+		}
+		for _, key := range []string{"boost", "half_life_days"} {
+			switch v := fr[key].(type) {
+			case int, float64:
+				cfg.Settings["freshness_"+key] = fmt.Sprint(v)
+			}
+		}
 	}
 	if ep, ok := m["episodic"].(map[string]interface{}); ok {
 		if en, ok := ep["enabled"].(bool); ok && en {
 			cfg.Provider = "episodic"
 		}
-		if _, ok := ep["encryption"].(bool); ok {
+		if en, ok := ep["encryption"].(bool); ok && en {
 			cfg.Settings["episodic_encryption"] = "true"
 		}
+		if n, ok := ep["max_entries_per_user"].(int); ok {
+			cfg.Settings["episodic_max_entries"] = fmt.Sprintf("%d", n)
+		}
+		if n, ok := ep["max_bytes_per_user"].(int); ok {
+			cfg.Settings["episodic_max_bytes"] = fmt.Sprintf("%d", n)
+		}
+	}
+	// encryption: true encrypts document content and episodic entries at rest
+	if en, ok := m["encryption"].(bool); ok && en {
+		cfg.Settings["encryption"] = "true"
+	}
+	if n, ok := m["retention_days"].(int); ok {
+		cfg.Settings["retention_days"] = fmt.Sprintf("%d", n)
 	}
 	return nil
 }
@@ -0,0 +1,472 @@
+package runtimememory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+// ConversationTurn is one exchange of a chat session.
+type ConversationTurn struct {
+	Time     time.Time `json:"time"`
+	Query    string    `json:"query"`
+	Response string    `json:"response"`
+}
+
+// Conversation is the state of a session as injected into prompts: the
+// summary of older turns and the turns that follow it.
+type Conversation struct {
+	Summary string `json:"summary,omitempty"`
+	// Summarized is the number of turns the summary covers.
+	Summarized int                `json:"summarized,omitempty"`
+	Turns      []ConversationTurn `json:"turns"`
+	// Seq is the number of turns the session had when it was loaded, the
+	// version AppendAt expects.
+	Seq int `json:"seq"`
+}
+
+// Recent returns the last n turns, or all of them when n <= 0.
+func (c Conversation) Recent(n int) []ConversationTurn {
+	if n <= 0 || len(c.Turns) <= n {
+		return c.Turns
+	}
+	return c.Turns[len(c.Turns)-n:]
+}
+
+// SummaryPolicy decides when older turns of a session are summarized.
+type SummaryPolicy struct {
+	// TriggerTurns summarizes once more than this many turns follow the summary.
+	TriggerTurns int
+	// TriggerTokens summarizes once those turns hold more estimated tokens.
+	TriggerTokens int
+	// KeepRecent turns stay verbatim after summarizing.
+	KeepRecent int
+}
+
+// Due reports whether turns call for a new summary under the policy.
+func (p SummaryPolicy) Due(turns []ConversationTurn) bool {
+	if len(turns) <= p.KeepRecent {
+		return false
+	}
+	if p.TriggerTurns > 0 && len(turns) > p.TriggerTurns {
+		return true
+	}
+	if p.TriggerTokens > 0 {
+		tokens := 0
+		for _, t := range turns {
+			tokens += runtimemodel.EstimateTokens(t.Query) + runtimemodel.EstimateTokens(t.Response)
+		}
+		return tokens > p.TriggerTokens
+	}
+	return false
+}
+
+// SummarizeFunc sends a prompt to the summarization model and returns its reply.
+type SummarizeFunc func(ctx context.Context, prompt string) (string, error)
+
+// conversationRecord is one line of a session log: a turn, or a summary of
+// the first Through turns of the session.
+type conversationRecord struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"` // turn|summary
+	Query    string    `json:"query,omitempty"`
+	Response string    `json:"response,omitempty"`
+	Summary  string    `json:"summary,omitempty"`
+	Through  int       `json:"through,omitempty"`
+	// Dropped is set on the summary that starts a compacted log: the number
+	// of turns before it that Compact removed.
+	Dropped int `json:"dropped,omitempty"`
+}
+
+// ErrConversationConflict is returned by AppendAt when the session gained
+// turns since it was loaded.
+var ErrConversationConflict = errors.New("conversation changed since it was loaded")
+
+// sessionIDRe restricts session IDs to values that are safe as file names.
+var sessionIDRe = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]{0,127}$`)
+
+// ConversationStore keeps the turns and summaries of one chat session in
+// memory/<component>/[tenant_<id>/][users/<user>/]conversations/<session>.jsonl,
+// so user erasure removes a user's sessions with the rest of their memory.
+type ConversationStore struct {
+	root      string
+	component string
+	tenantID  string
+	userID    string
+	path      string
+	sealer    *sealer
+}
+
+// OpenConversation returns the store of a session. Turns are encrypted at
+// rest when the component's memory_config.yaml enables encryption.
+func OpenConversation(root, component, tenantID, userID, sessionID string) (*ConversationStore, error) {
+	if !sessionIDRe.MatchString(sessionID) {
+		return nil, fmt.Errorf("invalid session id %q", sessionID)
+	}
+	cfg := Config{RootDir: root, ComponentName: component, TenantID: tenantID}
+	if err := LoadComponentMemoryConfig(&cfg); err != nil {
+		return nil, err
+	}
+	s, err := newSealer(cfg.Settings["encryption"] == "true" || cfg.Settings["episodic_encryption"] == "true")
+	if err != nil {
+		return nil, fmt.Errorf("conversation encryption: %w", err)
+	}
+	dir := DerivePath(root, component, tenantID, "conversations")
+	if userID != "" {
+		dir = filepath.Join(userDir(root, component, tenantID, userID), "conversations")
+	}
+	return &ConversationStore{
+		root: root, component: component, tenantID: tenantID, userID: userID,
+		path: filepath.Join(dir, sessionID+".jsonl"), sealer: s,
+	}, nil
+}
+
+// Load returns the latest summary and the turns after it.
+func (c *ConversationStore) Load() (Conversation, error) {
+	var conv Conversation
+	f, err := os.Open(c.path)
+	if os.IsNotExist(err) {
+		return conv, nil
+	}
+	if err != nil {
+		return conv, err
+	}
+	defer f.Close()
+	var (
+		turns   []ConversationTurn
+		dropped int
+	)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec conversationRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return conv, fmt.Errorf("parse %s: %w", c.path, err)
+		}
+		switch rec.Type {
+		case "turn":
+			t := ConversationTurn{Time: rec.Time}
+			if t.Query, err = c.sealer.open(rec.Query); err == nil {
+				t.Response, err = c.sealer.open(rec.Response)
+			}
+			if err != nil {
+				return conv, fmt.Errorf("decrypt %s: %w", c.path, err)
+			}
+			turns = append(turns, t)
+		case "summary":
+			dropped += rec.Dropped
+			// Concurrent summaries may land out of order; the widest wins
+			if rec.Through < conv.Summarized {
+				continue
+			}
+			if conv.Summary, err = c.sealer.open(rec.Summary); err != nil {
+				return conv, fmt.Errorf("decrypt %s: %w", c.path, err)
+			}
+			conv.Summarized = rec.Through
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return conv, err
+	}
+	conv.Seq = dropped + len(turns)
+	if conv.Summarized > conv.Seq {
+		conv.Summarized = conv.Seq
+	}
+	conv.Turns = turns[max(conv.Summarized-dropped, 0):]
+	return conv, nil
+}
+
+// Append records a turn.
+func (c *ConversationStore) Append(turn ConversationTurn) error {
+	return c.AppendAt(turn, -1)
+}
+
+// AppendAt records a turn only if the session still has seq turns, the
+// Conversation.Seq it was loaded with, and returns ErrConversationConflict
+// otherwise; a negative seq skips the check. It catches writers Lock does
+// not serialize, such as other server processes sharing the memory
+// directory.
+func (c *ConversationStore) AppendAt(turn ConversationTurn, seq int) error {
+	rec := conversationRecord{Time: turn.Time, Type: "turn"}
+	var err error
+	if rec.Query, err = c.sealer.seal(turn.Query); err != nil {
+		return err
+	}
+	if rec.Response, err = c.sealer.seal(turn.Response); err != nil {
+		return err
+	}
+	return c.write(rec, seq)
+}
+
+// write appends a record, when seq >= 0 only to a session with seq turns.
+func (c *ConversationStore) write(rec conversationRecord, seq int) error {
+	by, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := lockEpisodes(c.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if seq >= 0 {
+		records, _, err := c.readRecords()
+		if err != nil {
+			return err
+		}
+		if got := turnCount(records); got != seq {
+			return fmt.Errorf("%w: %d turns, expected %d", ErrConversationConflict, got, seq)
+		}
+	}
+	f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(by, '\n'))
+	return err
+}
+
+const summaryPrompt = `Summarize the conversation below between a user and an AI assistant so the assistant can continue it without the full transcript.
+Keep facts, decisions, names, numbers and open questions; drop greetings and repetition. Write at most one short paragraph.
+%s
+Conversation:
+%s
+Summary:`
+
+// Summarize folds the turns before the last policy.KeepRecent into the
+// session summary when the policy calls for it, and returns the updated
+// conversation and whether a summary was written. Sessions of a user also
+// get the summary in their episodic memory, so later searches can find it.
+func (c *ConversationStore) Summarize(ctx context.Context, conv Conversation, policy SummaryPolicy, summarize SummarizeFunc) (Conversation, bool, error) {
+	if summarize == nil || !policy.Due(conv.Turns) {
+		return conv, false, nil
+	}
+	older := conv.Turns[:len(conv.Turns)-policy.KeepRecent]
+	var previous, transcript strings.Builder
+	if conv.Summary != "" {
+		fmt.Fprintf(&previous, "\nSummary of the earlier conversation:\n%s\n", conv.Summary)
+	}
+	for _, t := range older {
+		fmt.Fprintf(&transcript, "User: %s\nAssistant: %s\n", t.Query, t.Response)
+	}
+	out, err := summarize(ctx, fmt.Sprintf(summaryPrompt, previous.String(), transcript.String()))
+	if err != nil {
+		return conv, false, fmt.Errorf("summarize conversation: %w", err)
+	}
+	summary := strings.TrimSpace(out)
+	if summary == "" {
+		return conv, false, fmt.Errorf("summarize conversation: empty summary")
+	}
+	through := conv.Summarized + len(older)
+	sealed, err := c.sealer.seal(summary)
+	if err != nil {
+		return conv, false, err
+	}
+	if err := c.write(conversationRecord{Time: time.Now().UTC(), Type: "summary", Summary: sealed, Through: through}, -1); err != nil {
+		return conv, false, err
+	}
+	if c.userID != "" {
+		episodes, err := NewStore(Config{Provider: "episodic", RootDir: c.root, ComponentName: c.component, TenantID: c.tenantID, UserID: c.userID})
+		if err != nil {
+			return conv, false, err
+		}
+		defer episodes.Close()
+		entry := fmt.Sprintf("Conversation summary (session %s): %s", strings.TrimSuffix(filepath.Base(c.path), ".jsonl"), strings.Join(strings.Fields(summary), " "))
+		if _, err := episodes.IngestDocuments(ctx, []string{entry}); err != nil {
+			return conv, false, err
+		}
+	}
+	ConversationSummaries.WithLabelValues(c.component).Inc()
+	return Conversation{Summary: summary, Summarized: through, Turns: conv.Turns[len(older):], Seq: conv.Seq}, true, nil
+}
+
+// readRecords parses the session log without decrypting it, returning each
+// record with its line. A missing log has no records.
+func (c *ConversationStore) readRecords() ([]conversationRecord, []string, error) {
+	by, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		records []conversationRecord
+		lines   []string
+	)
+	for _, line := range strings.Split(string(by), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		var rec conversationRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", c.path, err)
+		}
+		records = append(records, rec)
+		lines = append(lines, line)
+	}
+	return records, lines, nil
+}
+
+// history returns the latest summary and every turn in the session log,
+// including the ones the summary covers.
+func (c *ConversationStore) history() (Conversation, error) {
+	var conv Conversation
+	records, _, err := c.readRecords()
+	if err != nil {
+		return conv, err
+	}
+	conv.Turns = []ConversationTurn{}
+	for _, rec := range records {
+		switch rec.Type {
+		case "turn":
+			t := ConversationTurn{Time: rec.Time}
+			if t.Query, err = c.sealer.open(rec.Query); err == nil {
+				t.Response, err = c.sealer.open(rec.Response)
+			}
+			if err != nil {
+				return conv, fmt.Errorf("decrypt %s: %w", c.path, err)
+			}
+			conv.Turns = append(conv.Turns, t)
+		case "summary":
+			if rec.Through < conv.Summarized {
+				continue
+			}
+			if conv.Summary, err = c.sealer.open(rec.Summary); err != nil {
+				return conv, fmt.Errorf("decrypt %s: %w", c.path, err)
+			}
+			conv.Summarized = rec.Through
+		}
+	}
+	conv.Seq = turnCount(records)
+	return conv, nil
+}
+
+// turnCount is the number of turns of a session, compacted ones included.
+func turnCount(records []conversationRecord) int {
+	n := 0
+	for _, rec := range records {
+		switch rec.Type {
+		case "turn":
+			n++
+		case "summary":
+			n += rec.Dropped
+		}
+	}
+	return n
+}
+
+// Compact rewrites the session log without the turns and summaries the
+// latest summary replaces, so long sessions stop growing. The summary moves
+// to the start of the log and the turns after it are kept as they are,
+// encrypted or not. It returns the number of records removed.
+func (c *ConversationStore) Compact() (int, error) {
+	unlock, err := lockEpisodes(c.path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	records, lines, err := c.readRecords()
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+	latest, dropped := -1, 0
+	for i, rec := range records {
+		dropped += rec.Dropped
+		if rec.Type == "summary" && (latest < 0 || rec.Through >= records[latest].Through) {
+			latest = i
+		}
+	}
+	if latest < 0 || records[latest].Through <= dropped {
+		return 0, nil // nothing new to drop
+	}
+	head := records[latest]
+	head.Dropped = head.Through
+	by, err := json.Marshal(head)
+	if err != nil {
+		return 0, err
+	}
+	kept := []string{string(by)}
+	seq := dropped
+	for i, rec := range records {
+		if rec.Type == "turn" {
+			if seq >= head.Through {
+				kept = append(kept, lines[i])
+			}
+			seq++
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(kept, "\n")+"\n"), 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return len(records) - len(kept), nil
+}
+
+// sessionLocks holds the in-process lock of each session log in use.
+var sessionLocks = struct {
+	sync.Mutex
+	m map[string]*sessionLock
+}{m: map[string]*sessionLock{}}
+
+type sessionLock struct {
+	token chan struct{} // full while the session is locked
+	refs  int           // holders and waiters
+}
+
+// Lock serializes the requests of a session within the process, so each
+// sees the turns of the one before; it waits until the session is free or
+// ctx is done. The returned func releases the lock and may be called more
+// than once.
+func (c *ConversationStore) Lock(ctx context.Context) (func(), error) {
+	sessionLocks.Lock()
+	l := sessionLocks.m[c.path]
+	if l == nil {
+		l = &sessionLock{token: make(chan struct{}, 1)}
+		sessionLocks.m[c.path] = l
+	}
+	l.refs++
+	sessionLocks.Unlock()
+	drop := func() {
+		sessionLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(sessionLocks.m, c.path)
+		}
+		sessionLocks.Unlock()
+	}
+	select {
+	case l.token <- struct{}{}:
+	case <-ctx.Done():
+		drop()
+		return nil, fmt.Errorf("wait for session %s: %w", strings.TrimSuffix(filepath.Base(c.path), ".jsonl"), ctx.Err())
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.token
+			drop()
+		})
+	}, nil
+}
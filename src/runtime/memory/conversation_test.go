@@ -0,0 +1,244 @@
+package runtimememory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+func TestConversation_SummarizesOlderTurns(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+	store, err := OpenConversation(root, "SupportBot", "acme", "u-1", "s-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenConversation(root, "SupportBot", "", "", "../escape"); err == nil {
+		t.Fatal("expected an invalid session error")
+	}
+	for i := 1; i <= 5; i++ {
+		if err := store.Append(ConversationTurn{Time: time.Now(), Query: fmt.Sprintf("q%d", i), Response: fmt.Sprintf("a%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conv, err := store.Load()
+	if err != nil || len(conv.Turns) != 5 || conv.Summary != "" || len(conv.Recent(2)) != 2 || conv.Recent(2)[0].Query != "q4" {
+		t.Fatalf("load: %+v %v", conv, err)
+	}
+
+	policy := SummaryPolicy{TriggerTurns: 4, KeepRecent: 2}
+	var prompts []string
+	summarize := func(_ context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return fmt.Sprintf(" summary %d\n", len(prompts)), nil
+	}
+	conv, done, err := store.Summarize(ctx, conv, policy, summarize)
+	if err != nil || !done || conv.Summary != "summary 1" || conv.Summarized != 3 || len(conv.Turns) != 2 || conv.Turns[0].Query != "q4" {
+		t.Fatalf("summarize: %+v %v %v", conv, done, err)
+	}
+	if !strings.Contains(prompts[0], "User: q3\nAssistant: a3") || strings.Contains(prompts[0], "q4") {
+		t.Fatalf("unexpected prompt %q", prompts[0])
+	}
+	if again, err := store.Load(); err != nil || again.Summary != "summary 1" || len(again.Turns) != 2 {
+		t.Fatalf("reload: %+v %v", again, err)
+	}
+	// Below the trigger nothing is summarized
+	if _, done, _ := store.Summarize(ctx, conv, policy, summarize); done {
+		t.Fatal("summarized below the trigger")
+	}
+
+	// The next summary folds in the previous one
+	for i := 6; i <= 8; i++ {
+		_ = store.Append(ConversationTurn{Time: time.Now(), Query: fmt.Sprintf("q%d", i), Response: fmt.Sprintf("a%d", i)})
+	}
+	conv, _ = store.Load()
+	conv, done, err = store.Summarize(ctx, conv, policy, summarize)
+	if err != nil || !done || conv.Summarized != 6 || conv.Turns[0].Query != "q7" || !strings.Contains(prompts[1], "summary 1") {
+		t.Fatalf("second summary: %+v %v %v", conv, done, err)
+	}
+
+	// Summaries also land in the user's episodic memory
+	episodes, err := ExportUserEpisodes(root, "acme", "u-1")
+	if err != nil || len(episodes) != 1 || len(episodes[0].Entries) != 2 || episodes[0].Entries[0] != "Conversation summary (session s-1): summary 1" {
+		t.Fatalf("episodes: %+v %v", episodes, err)
+	}
+
+	failing := func(context.Context, string) (string, error) { return "", errors.New("down") }
+	_ = store.Append(ConversationTurn{Query: "q9"})
+	_ = store.Append(ConversationTurn{Query: "q10"})
+	_ = store.Append(ConversationTurn{Query: "q11"})
+	conv, _ = store.Load()
+	if _, _, err := store.Summarize(ctx, conv, policy, failing); err == nil {
+		t.Fatal("expected the summarizer error")
+	}
+	if kept, _ := store.Load(); len(kept.Turns) != 5 {
+		t.Fatalf("failed summary changed the log: %+v", kept)
+	}
+}
+
+func TestConversation_TokenTriggerAndEncryption(t *testing.T) {
+	root := t.TempDir()
+	writeMemoryConfig(t, root, "SupportBot", "encryption: true\n")
+	t.Setenv("CMP_EPISODIC_KEY", "conversation-key")
+	store, err := OpenConversation(root, "SupportBot", "", "", "s-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("refund policy details ", 20)
+	_ = store.Append(ConversationTurn{Query: "what is the refund policy?", Response: long})
+	_ = store.Append(ConversationTurn{Query: "thanks", Response: "you're welcome"})
+	conv, err := store.Load()
+	if err != nil || conv.Turns[0].Response != long {
+		t.Fatalf("load: %+v %v", conv, err)
+	}
+	by, _ := os.ReadFile(store.path)
+	if strings.Contains(string(by), "refund") {
+		t.Fatalf("plaintext in the conversation log: %s", by)
+	}
+	summarize := func(context.Context, string) (string, error) { return "asked about refunds", nil }
+	if _, done, _ := store.Summarize(context.Background(), conv, SummaryPolicy{TriggerTokens: 1000, KeepRecent: 1}, summarize); done {
+		t.Fatal("summarized below the token trigger")
+	}
+	conv, done, err := store.Summarize(context.Background(), conv, SummaryPolicy{TriggerTokens: 50, KeepRecent: 1}, summarize)
+	if err != nil || !done || conv.Summary != "asked about refunds" || len(conv.Turns) != 1 {
+		t.Fatalf("summarize: %+v %v %v", conv, done, err)
+	}
+
+	// Key rotation covers conversation logs
+	t.Setenv("CMP_EPISODIC_KEY", "next-key")
+	t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "conversation-key")
+	ring, _ := runtimesecurity.LoadKeyRing(context.Background())
+	if res, err := RotateKeys(root, "SupportBot", ring); err != nil || res.Files != 1 || res.Values != 5 {
+		t.Fatalf("rotate: %+v %v", res, err)
+	}
+	t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "")
+	store, _ = OpenConversation(root, "SupportBot", "", "", "s-2")
+	if conv, err := store.Load(); err != nil || conv.Summary != "asked about refunds" || conv.Turns[0].Query != "thanks" {
+		t.Fatalf("load after rotation: %+v %v", conv, err)
+	}
+}
+
+func TestConversation_AppendAtAndCompact(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+	store, err := OpenConversation(root, "SupportBot", "", "u-1", "s-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	turn := func(i int) ConversationTurn {
+		return ConversationTurn{Time: time.Now(), Query: fmt.Sprintf("q%d", i), Response: fmt.Sprintf("a%d", i)}
+	}
+	for i := 1; i <= 5; i++ {
+		_ = store.Append(turn(i))
+	}
+	conv, err := store.Load()
+	if err != nil || conv.Seq != 5 {
+		t.Fatalf("load: %+v %v", conv, err)
+	}
+
+	// Another process records a turn after the load
+	other, _ := OpenConversation(root, "SupportBot", "", "u-1", "s-3")
+	_ = other.Append(turn(6))
+	if err := store.AppendAt(turn(7), conv.Seq); !errors.Is(err, ErrConversationConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	conv, _ = store.Load()
+	if conv.Seq != 6 {
+		t.Fatalf("the conflicting turn was written: %+v", conv)
+	}
+	if err := store.AppendAt(turn(7), conv.Seq); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := SummaryPolicy{TriggerTurns: 4, KeepRecent: 2}
+	summarize := func(_ context.Context, prompt string) (string, error) {
+		return fmt.Sprintf("summary of %d bytes", len(prompt)), nil
+	}
+	conv, _ = store.Load()
+	if _, done, err := store.Summarize(ctx, conv, policy, summarize); err != nil || !done {
+		t.Fatalf("summarize: %v %v", done, err)
+	}
+	if removed, err := store.Compact(); err != nil || removed != 5 {
+		t.Fatalf("compact: %d %v", removed, err)
+	}
+	if by, _ := os.ReadFile(store.path); strings.Count(string(by), "\n") != 3 || strings.Contains(string(by), "q5") {
+		t.Fatalf("compacted log:\n%s", by)
+	}
+	conv, err = store.Load()
+	if err != nil || conv.Seq != 7 || conv.Summarized != 5 || len(conv.Turns) != 2 || conv.Turns[0].Query != "q6" || conv.Summary == "" {
+		t.Fatalf("load after compaction: %+v %v", conv, err)
+	}
+	if removed, err := store.Compact(); err != nil || removed != 0 {
+		t.Fatalf("second compaction: %d %v", removed, err)
+	}
+
+	// A compacted log keeps its turn count for AppendAt and compacts again
+	for i := 8; i <= 10; i++ {
+		if err := store.AppendAt(turn(i), i-1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conv, _ = store.Load()
+	if _, done, err := store.Summarize(ctx, conv, policy, summarize); err != nil || !done {
+		t.Fatalf("summarize: %v %v", done, err)
+	}
+	if removed, err := store.Compact(); err != nil || removed != 4 {
+		t.Fatalf("compact: %d %v", removed, err)
+	}
+	conv, _ = store.Load()
+	if conv.Seq != 10 || conv.Summarized != 8 || len(conv.Turns) != 2 || conv.Turns[0].Query != "q9" {
+		t.Fatalf("load after recompaction: %+v", conv)
+	}
+}
+
+func TestConversation_LockSerializesSession(t *testing.T) {
+	root := t.TempDir()
+	store, _ := OpenConversation(root, "SupportBot", "", "u-1", "s-4")
+	unlock, err := store.Lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	same, _ := OpenConversation(root, "SupportBot", "", "u-1", "s-4")
+	if _, err := same.Lock(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the locked session to time out, got %v", err)
+	}
+	// Other sessions are not held up
+	other, _ := OpenConversation(root, "SupportBot", "", "u-1", "s-5")
+	if unlockOther, err := other.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	} else {
+		unlockOther()
+	}
+
+	acquired := make(chan func())
+	go func() {
+		u, _ := same.Lock(context.Background())
+		acquired <- u
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a locked session")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	unlock() // releasing twice is harmless
+	select {
+	case u := <-acquired:
+		u()
+	case <-time.After(time.Second):
+		t.Fatal("the waiter did not get the session")
+	}
+	sessionLocks.Lock()
+	defer sessionLocks.Unlock()
+	if len(sessionLocks.m) != 0 {
+		t.Fatalf("session locks leaked: %v", sessionLocks.m)
+	}
+}
@@ -0,0 +1,244 @@
+package runtimememory
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Dedupe actions for chunks that duplicate a stored chunk, set with
+// deduplication.action in memory_config.yaml.
+const (
+	// DedupeKeep stores duplicates and only counts them (the default).
+	DedupeKeep = "keep"
+	// DedupeSkip drops duplicates.
+	DedupeSkip = "skip"
+	// DedupeMerge drops duplicates and lists their source in the
+	// duplicate_sources metadata of the chunk they duplicate.
+	DedupeMerge = "merge"
+)
+
+const (
+	// minhash signature: 16 LSH bands of 4 rows
+	minhashSize  = 64
+	minhashBands = 16
+	minhashRows  = minhashSize / minhashBands
+	// shingleWords is the length of the word sequences compared by minhash.
+	shingleWords = 3
+)
+
+// dedupeConfig is the deduplication of a store.
+type dedupeConfig struct {
+	action string
+	// threshold is the estimated Jaccard similarity of word shingles above
+	// which a chunk is a near duplicate; 0 detects exact duplicates only.
+	threshold float64
+}
+
+func dedupeSettings(settings map[string]string) dedupeConfig {
+	cfg := dedupeConfig{action: DedupeKeep}
+	switch a := strings.ToLower(settings["dedupe_action"]); a {
+	case DedupeSkip, DedupeMerge:
+		cfg.action = a
+	}
+	if t, err := strconv.ParseFloat(settings["dedupe_near_threshold"], 64); err == nil && t > 0 && t <= 1 {
+		cfg.threshold = t
+	}
+	return cfg
+}
+
+// deduper finds chunks that duplicate the records added to it: exactly, by
+// content hash, or nearly, by minhash similarity. Candidates for the
+// similarity check come from locality-sensitive hashing of the signatures, so
+// a chunk is compared with few records rather than all of them.
+type deduper struct {
+	cfg   dedupeConfig
+	exact map[string]int   // content hash -> record index
+	bands map[uint64][]int // band hash -> record indexes
+	sigs  map[int][]uint64 // record index -> signature
+}
+
+func newDeduper(cfg dedupeConfig) *deduper {
+	return &deduper{cfg: cfg, exact: map[string]int{}, bands: map[uint64][]int{}, sigs: map[int][]uint64{}}
+}
+
+// add indexes the record at index i.
+func (d *deduper) add(i int, rec vecRecord) {
+	if _, ok := d.exact[rec.Hash]; !ok {
+		d.exact[rec.Hash] = i
+	}
+	if d.cfg.threshold == 0 {
+		return
+	}
+	sig := minhash(rec.Content)
+	if sig == nil {
+		return
+	}
+	d.sigs[i] = sig
+	for b := 0; b < minhashBands; b++ {
+		k := bandKey(sig, b)
+		d.bands[k] = append(d.bands[k], i)
+	}
+}
+
+// match returns the index of the record rec duplicates and whether it is a
+// near rather than an exact duplicate, or -1.
+func (d *deduper) match(rec vecRecord) (int, bool) {
+	if i, ok := d.exact[rec.Hash]; ok {
+		return i, false
+	}
+	if d.cfg.threshold == 0 {
+		return -1, false
+	}
+	sig := minhash(rec.Content)
+	if sig == nil {
+		return -1, false
+	}
+	best, bestSim := -1, 0.0
+	seen := map[int]bool{}
+	for b := 0; b < minhashBands; b++ {
+		for _, i := range d.bands[bandKey(sig, b)] {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			if sim := similarity(sig, d.sigs[i]); sim >= d.cfg.threshold && (sim > bestSim || (sim == bestSim && i < best)) {
+				best, bestSim = i, sim
+			}
+		}
+	}
+	if best < 0 {
+		return -1, false
+	}
+	return best, true
+}
+
+// minhash returns the signature of the word shingles of text, ignoring case
+// and punctuation, or nil when text has no words.
+func minhash(text string) []uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil
+	}
+	n := shingleWords
+	if len(words) < n {
+		n = len(words)
+	}
+	sig := make([]uint64, minhashSize)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for start := 0; start+n <= len(words); start++ {
+		h := fnv.New64a()
+		for _, w := range words[start : start+n] {
+			h.Write([]byte(w))
+			h.Write([]byte{0})
+		}
+		x := h.Sum64()
+		for i := range sig {
+			if v := mix64(x + uint64(i)*0x9e3779b97f4a7c15); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// mix64 is the splitmix64 finalizer, which derives the independent hash
+// functions of the signature from one shingle hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func bandKey(sig []uint64, band int) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(band))
+	h.Write(buf[:])
+	for _, v := range sig[band*minhashRows : (band+1)*minhashRows] {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// similarity estimates the Jaccard similarity of two signatures.
+func similarity(a, b []uint64) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// duplicateSources returns the duplicate_sources metadata of rec.
+func duplicateSources(rec vecRecord) []string {
+	switch v := rec.Metadata["duplicate_sources"].(type) {
+	case []string:
+		return v
+	case []interface{}: // read back from the store file
+		out := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// setDuplicateSources replaces the duplicate_sources metadata of rec. The
+// metadata map is copied, as it may be shared with other records.
+func setDuplicateSources(rec *vecRecord, sources []string) {
+	rec.Metadata = copyMetadata(rec.Metadata)
+	if len(sources) == 0 {
+		delete(rec.Metadata, "duplicate_sources")
+		return
+	}
+	if rec.Metadata == nil {
+		rec.Metadata = map[string]interface{}{}
+	}
+	sort.Strings(sources)
+	rec.Metadata["duplicate_sources"] = sources
+}
+
+// mergeDuplicate lists source in the duplicate_sources metadata of kept.
+func mergeDuplicate(kept *vecRecord, source string) {
+	if source == "" || source == kept.Source {
+		return
+	}
+	sources := duplicateSources(*kept)
+	for _, s := range sources {
+		if s == source {
+			return
+		}
+	}
+	setDuplicateSources(kept, append(append([]string{}, sources...), source))
+}
+
+// unmergeSources drops the given sources from the duplicate_sources metadata
+// of rec: sources being re-chunked merge again if they still duplicate it.
+func unmergeSources(rec *vecRecord, sources map[string]bool) {
+	ds := duplicateSources(*rec)
+	var keep []string
+	for _, s := range ds {
+		if !sources[s] {
+			keep = append(keep, s)
+		}
+	}
+	if len(keep) != len(ds) {
+		setDuplicateSources(rec, keep)
+	}
+}
@@ -0,0 +1,108 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const policy = "Refunds are issued to the original payment method within five business days after the returned item has been received and inspected by our warehouse team."
+
+func newDedupeStore(t *testing.T, config string) SourceStore {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Docs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store.(SourceStore)
+}
+
+func TestMinhash_NearDuplicates(t *testing.T) {
+	d := newDeduper(dedupeConfig{action: DedupeSkip, threshold: 0.7})
+	d.add(0, vecRecord{Hash: "a", Content: policy})
+	near := strings.Replace(policy, "five", "5", 1) + " Thanks!"
+	if i, isNear := d.match(vecRecord{Hash: "b", Content: near}); i != 0 || !isNear {
+		t.Fatalf("expected a near duplicate of record 0, got %d, %v", i, isNear)
+	}
+	if i, _ := d.match(vecRecord{Hash: "c", Content: "Shipping to Canada takes two weeks and costs extra for oversized parcels."}); i != -1 {
+		t.Fatalf("unrelated text matched record %d", i)
+	}
+	if i, isNear := d.match(vecRecord{Hash: "a", Content: policy}); i != 0 || isNear {
+		t.Fatalf("expected an exact duplicate, got %d, %v", i, isNear)
+	}
+}
+
+func TestUpdateSources_Dedupe(t *testing.T) {
+	ctx := context.Background()
+	export2 := strings.Replace(policy, "five", "5", 1)
+	sources := []Source{
+		{Path: "export1/refunds.md", Content: policy},
+		{Path: "export2/refunds.md", Content: export2},
+		{Path: "export3/refunds.md", Content: policy},
+	}
+
+	keep := newDedupeStore(t, "deduplication:\n  near_threshold: 0.7\n")
+	res, err := keep.SyncSources(ctx, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 3 || res.DuplicatesExact != 1 || res.DuplicatesNear != 1 || res.DedupeAction != DedupeKeep {
+		t.Fatalf("keep: %+v", res)
+	}
+
+	skip := newDedupeStore(t, "deduplication:\n  action: skip\n  near_threshold: 0.7\n")
+	if res, err = skip.SyncSources(ctx, sources); err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 1 || res.DuplicatesExact != 1 || res.DuplicatesNear != 1 {
+		t.Fatalf("skip: %+v", res)
+	}
+	// Removing the kept copy lets the next sync store a duplicate instead
+	if res, err = skip.SyncSources(ctx, sources[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 1 || res.ChunksDeleted != 1 || res.DuplicatesNear != 1 {
+		t.Fatalf("skip after removal: %+v", res)
+	}
+
+	merge := newDedupeStore(t, "deduplication:\n  action: merge\n  near_threshold: 0.7\n")
+	if res, err = merge.SyncSources(ctx, sources); err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 1 || res.ChunksMerged != 1 {
+		t.Fatalf("merge: %+v", res)
+	}
+	records, err := merge.(*sqliteVectorStore).readRecords()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records = %+v, %v", records, err)
+	}
+	if got := strings.Join(duplicateSources(records[0]), ","); got != "export2/refunds.md,export3/refunds.md" {
+		t.Fatalf("duplicate_sources = %s", got)
+	}
+	// Unchanged sources leave the merged metadata alone
+	if res, err = merge.SyncSources(ctx, sources); err != nil || res.Changed() {
+		t.Fatalf("second sync: %+v, %v", res, err)
+	}
+	// Re-ingesting the kept source alone keeps the sources merged into it
+	if res, err = merge.UpdateSources(ctx, sources[:1], nil); err != nil || res.Changed() {
+		t.Fatalf("update of the kept source: %+v, %v", res, err)
+	}
+	if res, err = merge.UpdateSources(ctx, nil, []string{"export3/refunds.md"}); err != nil || res.ChunksMerged != 1 {
+		t.Fatalf("removing a merged source: %+v, %v", res, err)
+	}
+	records, _ = merge.(*sqliteVectorStore).readRecords()
+	if got := strings.Join(duplicateSources(records[0]), ","); got != "export2/refunds.md" {
+		t.Fatalf("duplicate_sources after removal = %s", got)
+	}
+}
@@ -0,0 +1,184 @@
+package runtimememory
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// embedFunc embeds one batch of texts, returning one vector per text.
+type embedFunc func(ctx context.Context, texts []string) ([][]float64, error)
+
+// EmbedProgress reports how many chunks of an ingestion have been embedded.
+type EmbedProgress struct {
+	Done    int
+	Total   int
+	Elapsed time.Duration
+}
+
+// ETA estimates the remaining time from the average rate so far.
+func (p EmbedProgress) ETA() time.Duration {
+	if p.Done == 0 || p.Done >= p.Total {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) / float64(p.Done) * float64(p.Total-p.Done))
+}
+
+type progressKey struct{}
+
+// WithEmbedProgress returns a context whose ingestions call fn after each
+// embedded batch. Calls are serialized.
+func WithEmbedProgress(ctx context.Context, fn func(EmbedProgress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) func(EmbedProgress) {
+	fn, _ := ctx.Value(progressKey{}).(func(EmbedProgress))
+	return fn
+}
+
+// embedBatchLimits caps batch sizes at the documented per-request input limits
+// of hosted embedding APIs, keyed by model name prefix.
+var embedBatchLimits = []struct {
+	prefix   string
+	limit    int
+	provider string
+}{
+	{"text-embedding-", 2048, "openai"},
+	{"embed-", 96, "cohere"},
+	{"voyage-", 128, "voyage"},
+}
+
+// embedProvider names the embedding provider of model for metrics; models
+// without a hosted provider are "local".
+func embedProvider(model string) string {
+	for _, l := range embedBatchLimits {
+		if strings.HasPrefix(strings.ToLower(model), l.prefix) {
+			return l.provider
+		}
+	}
+	return "local"
+}
+
+// defaultEmbedBatchSize is used for local models without a provider limit.
+const defaultEmbedBatchSize = 64
+
+// embedSettings reads embedding_concurrency and embedding_batch_size, defaulting
+// to GOMAXPROCS workers and the batch limit of the configured embedding provider
+// or, failing that, of the model's provider.
+func embedSettings(settings map[string]string, model string) (concurrency, batchSize int) {
+	concurrency, _ = strconv.Atoi(settings["embedding_concurrency"])
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	limit := defaultEmbedBatchSize
+	provider := strings.ToLower(settings["embedding_provider"])
+	for _, l := range embedBatchLimits {
+		if l.provider == provider || strings.HasPrefix(strings.ToLower(model), l.prefix) {
+			limit = l.limit
+			break
+		}
+	}
+	batchSize, _ = strconv.Atoi(settings["embedding_batch_size"])
+	if batchSize <= 0 || batchSize > limit {
+		batchSize = limit
+	}
+	return concurrency, batchSize
+}
+
+// embedParallel embeds texts in batches on a pool of workers. Vectors of batches
+// that completed are returned even when ctx is cancelled or a batch fails; done
+// marks which texts have a vector.
+func embedParallel(ctx context.Context, texts []string, concurrency, batchSize int, embed embedFunc) (vectors [][]float64, done []bool, err error) {
+	vectors = make([][]float64, len(texts))
+	done = make([]bool, len(texts))
+	if len(texts) == 0 {
+		return vectors, done, nil
+	}
+	if batchSize <= 0 {
+		batchSize = defaultEmbedBatchSize
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	batches := make(chan int)
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(texts); start += batchSize {
+			select {
+			case batches <- start:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		finished int
+		began    = time.Now()
+		progress = progressFrom(ctx)
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				if ctx.Err() != nil {
+					return
+				}
+				end := start + batchSize
+				if end > len(texts) {
+					end = len(texts)
+				}
+				vecs, err := embed(ctx, texts[start:end])
+				if err == nil && len(vecs) != end-start {
+					err = fmt.Errorf("embedding batch returned %d vectors for %d texts", len(vecs), end-start)
+				}
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil && ctx.Err() == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+					return
+				}
+				for i, v := range vecs {
+					vectors[start+i] = v
+					done[start+i] = true
+				}
+				finished += end - start
+				if progress != nil {
+					progress(EmbedProgress{Done: finished, Total: len(texts), Elapsed: time.Since(began)})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+	return vectors, done, firstErr
+}
+
+// naiveEmbedBatch embeds texts with the local hashing embedding.
+func naiveEmbedBatch(dim int) embedFunc {
+	return func(ctx context.Context, texts []string) ([][]float64, error) {
+		out := make([][]float64, len(texts))
+		for i, t := range texts {
+			out[i] = naiveEmbed(t, dim)
+		}
+		return out, nil
+	}
+}
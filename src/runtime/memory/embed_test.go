@@ -0,0 +1,87 @@
+package runtimememory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEmbedSettings(t *testing.T) {
+	if c, b := embedSettings(map[string]string{"embedding_concurrency": "3", "embedding_batch_size": "500"}, "embed-english-v3.0"); c != 3 || b != 96 {
+		t.Fatalf("cohere settings = %d, %d", c, b)
+	}
+	if _, b := embedSettings(map[string]string{}, "text-embedding-3-small"); b != 2048 {
+		t.Fatalf("openai batch size = %d", b)
+	}
+	if c, b := embedSettings(nil, "bge-small-en"); c < 1 || b != defaultEmbedBatchSize {
+		t.Fatalf("local settings = %d, %d", c, b)
+	}
+}
+
+func TestSQLiteVectorStore_InterruptedIngestResumes(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs", Settings: map[string]string{
+		"embedding_concurrency": "1",
+		"embedding_batch_size":  "2",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	vs := store.(*sqliteVectorStore)
+	ctx, cancel := context.WithCancel(context.Background())
+	var batches int32
+	naive := vs.embed
+	vs.embed = func(ctx context.Context, texts []string) ([][]float64, error) {
+		if atomic.AddInt32(&batches, 1) == 2 {
+			cancel() // Ctrl-C while the second batch is embedding
+		}
+		return naive(ctx, texts)
+	}
+
+	var sources []Source
+	for i := 0; i < 10; i++ {
+		sources = append(sources, Source{Path: fmt.Sprintf("doc%d.md", i), Content: fmt.Sprintf("document number %d", i)})
+	}
+	var progress []EmbedProgress
+	res, err := vs.SyncSources(WithEmbedProgress(ctx, func(p EmbedProgress) { progress = append(progress, p) }), sources)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+	if res.ChunksEmbedded != 4 || res.ChunksPending != 6 || res.Version == "" {
+		t.Fatalf("expected two completed batches to persist, got %+v", res)
+	}
+	if len(progress) != 2 || progress[1].Done != 4 || progress[1].Total != 10 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+	if recs, _ := vs.readRecords(); len(recs) != 4 {
+		t.Fatalf("expected 4 persisted records, got %d", len(recs))
+	}
+
+	vs.embed = naive
+	res, err = vs.SyncSources(context.Background(), sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 6 || res.ChunksReused != 4 || res.ChunksPending != 0 {
+		t.Fatalf("resume should embed only the rest, got %+v", res)
+	}
+}
+
+func TestEmbedParallel_BatchError(t *testing.T) {
+	texts := make([]string, 20)
+	boom := errors.New("rate limited")
+	_, done, err := embedParallel(context.Background(), texts, 4, 3, func(ctx context.Context, batch []string) ([][]float64, error) {
+		return nil, boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected batch error, got %v", err)
+	}
+	for _, d := range done {
+		if d {
+			t.Fatal("no text should be marked done")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+func TestEncryptionAtRest_SqliteAndEpisodic(t *testing.T) {
+	root := t.TempDir()
+	writeMemoryConfig(t, root, "SupportBot", "encryption: true\n")
+	t.Setenv("CMP_EPISODIC_KEY", "")
+	if _, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"}); err == nil || !strings.Contains(err.Error(), "CMP_EPISODIC_KEY") {
+		t.Fatalf("expected a missing key error, got %v", err)
+	}
+	t.Setenv("CMP_EPISODIC_KEY", "first-key")
+	ctx := context.Background()
+
+	docs, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.IngestDocuments(ctx, []string{"refunds are issued within 14 days"}); err != nil {
+		t.Fatal(err)
+	}
+	episodes, err := NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot", UserID: "u-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := episodes.IngestDocuments(ctx, []string{"customer asked about refunds"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "memory", "SupportBot")
+	assertNoPlaintext := func() {
+		t.Helper()
+		_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Base(path) != "memory_config.yaml" {
+				if by, _ := os.ReadFile(path); strings.Contains(string(by), "refunds") {
+					t.Errorf("plaintext in %s", path)
+				}
+			}
+			return nil
+		})
+	}
+	assertNoPlaintext()
+	search := func() {
+		t.Helper()
+		if res, err := docs.Search(ctx, "refunds issued", 1); err != nil || len(res) != 1 || !strings.Contains(res[0].Content, "14 days") {
+			t.Fatalf("sqlite search: %+v %v", res, err)
+		}
+		if res, err := episodes.Search(ctx, "refunds", 1); err != nil || len(res) != 1 || res[0].Content != "customer asked about refunds" {
+			t.Fatalf("episodic search: %+v %v", res, err)
+		}
+	}
+	search()
+
+	// Rotate: data stays readable with the old key as a previous key, then without it
+	t.Setenv("CMP_EPISODIC_KEY", "second-key")
+	t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "first-key")
+	docs, _ = NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	episodes, _ = NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot", UserID: "u-1"})
+	ring, err := runtimesecurity.LoadKeyRing(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := RotateKeys(root, "SupportBot", ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// store, its snapshot and the episodic log
+	if res.Files != 3 || res.Values != 3 {
+		t.Fatalf("unexpected rotation %+v", res)
+	}
+	if again, _ := RotateKeys(root, "SupportBot", ring); again.Values != 0 {
+		t.Fatalf("second rotation changed %+v", again)
+	}
+	t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "")
+	docs, _ = NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	episodes, _ = NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot", UserID: "u-1"})
+	search()
+	assertNoPlaintext()
+}
+
+func TestRotateKeys_EncryptsExistingPlaintext(t *testing.T) {
+	root := t.TempDir()
+	ctx := context.Background()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.IngestDocuments(ctx, []string{"parental leave policy"}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMemoryConfig(t, root, "Docs", "encryption: true\n")
+	t.Setenv("CMP_EPISODIC_KEY", "0123456789abcdef0123456789abcdef")
+	store, err = NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Plaintext written before encryption was enabled stays readable
+	if res, err := store.Search(ctx, "parental leave", 1); err != nil || len(res) != 1 {
+		t.Fatalf("search before rotation: %+v %v", res, err)
+	}
+	ring, _ := runtimesecurity.LoadKeyRing(ctx)
+	if res, err := RotateKeys(root, "Docs", ring); err != nil || res.Values == 0 {
+		t.Fatalf("rotate: %+v %v", res, err)
+	}
+	by, _ := os.ReadFile(DerivePath(root, "Docs", "", "vector_store.jsonl"))
+	if strings.Contains(string(by), "parental") {
+		t.Fatalf("plaintext left after rotation: %s", by)
+	}
+	if res, err := store.Search(ctx, "parental leave", 1); err != nil || len(res) != 1 {
+		t.Fatalf("search after rotation: %+v %v", res, err)
+	}
+}
@@ -1,33 +1,99 @@
 package runtimememory
 
 import (
+    "context"
     "errors"
+    "fmt"
+    "strings"
+    "sync"
 
     runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
 )
 
-// securityKeyProvider returns a closure that yields the AES key bytes for episodic encryption.
-func securityKeyProvider() func() ([]byte, error) {
-    provider := runtimesecurity.EnvKeyProvider{}
-    return func() ([]byte, error) {
-        // tenant-aware keying could be added by passing through context/tenant id here
-        key, err := provider.GetKey("")
-        if err != nil {
+// sealer encrypts store content at rest with the key ring from
+// CMP_EPISODIC_KEY. Values that are not sealed pass through open unchanged,
+// so stores written before encryption was enabled stay readable.
+type sealer struct {
+    encrypt bool
+
+    once sync.Once
+    keys *runtimesecurity.KeyRing
+    err  error
+}
+
+// newSealer loads the key ring up front when encrypt is set, so a missing
+// key fails when the store is opened rather than on the first write.
+func newSealer(encrypt bool) (*sealer, error) {
+    s := &sealer{encrypt: encrypt}
+    if encrypt {
+        if _, err := s.ring(); err != nil {
             return nil, err
         }
-        if len(key) != 32 {
-            return nil, errors.New("episodic key must be 32 bytes")
-        }
-        return key, nil
     }
+    return s, nil
 }
 
-func encryptBytes(key []byte, plaintext []byte) ([]byte, error) {
-    return runtimesecurity.EncryptGCM(key, plaintext)
+func (s *sealer) ring() (*runtimesecurity.KeyRing, error) {
+    s.once.Do(func() {
+        s.keys, s.err = runtimesecurity.LoadKeyRing(context.Background())
+    })
+    return s.keys, s.err
 }
 
-func decryptBytes(key []byte, data []byte) ([]byte, error) {
-    return runtimesecurity.DecryptGCM(key, data)
+// seal encrypts plaintext when encryption is enabled.
+func (s *sealer) seal(plaintext string) (string, error) {
+    if !s.encrypt {
+        return plaintext, nil
+    }
+    keys, err := s.ring()
+    if err != nil {
+        return "", err
+    }
+    return keys.Seal([]byte(plaintext))
+}
+
+// open decrypts a sealed value and returns anything else as-is.
+func (s *sealer) open(value string) (string, error) {
+    if !runtimesecurity.IsSealed(value) {
+        return value, nil
+    }
+    keys, err := s.ring()
+    if err != nil {
+        return "", err
+    }
+    plaintext, err := keys.Open(value)
+    return string(plaintext), err
 }
 
+// errUnreadableEntry marks log lines that do not decrypt with any key of the
+// ring, such as fragments of old raw ciphertext that contained a newline.
+var errUnreadableEntry = errors.New("unreadable episodic entry")
 
+// openEpisode returns the entries of one episodic log line: a sealed batch,
+// a batch encrypted before values carried a key ID, or a plaintext entry.
+func (s *sealer) openEpisode(line string) ([]string, error) {
+    written, payload := parseEpisode(line)
+    if runtimesecurity.IsSealed(payload) {
+        plaintext, err := s.open(payload)
+        if err != nil {
+            return nil, err
+        }
+        return strings.Split(plaintext, "\n"), nil
+    }
+    if !s.encrypt {
+        return []string{payload}, nil
+    }
+    keys, err := s.ring()
+    if err != nil {
+        return nil, err
+    }
+    ciphertext, err := episodeCiphertext(written, payload)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", errUnreadableEntry, err)
+    }
+    plaintext, err := keys.OpenRaw(ciphertext)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", errUnreadableEntry, err)
+    }
+    return strings.Split(string(plaintext), "\n"), nil
+}
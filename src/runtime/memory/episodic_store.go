@@ -3,6 +3,7 @@ package runtimememory
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,10 +14,14 @@ import (
 type episodicStore struct {
 	logPath string
     encrypt bool
+	sealer  *sealer
 }
 
 func newEpisodicStore(cfg Config) (MemoryStore, error) {
 	logPath := DerivePath(cfg.RootDir, cfg.ComponentName, cfg.TenantID, "episodic/episodes.log")
+	if cfg.UserID != "" {
+		logPath = filepath.Join(userDir(cfg.RootDir, cfg.ComponentName, cfg.TenantID, cfg.UserID), "episodic", "episodes.log")
+	}
 	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
 		return nil, err
 	}
@@ -28,11 +33,12 @@ func newEpisodicStore(cfg Config) (MemoryStore, error) {
 		}
 		_ = f.Close()
 	}
-	enc := false
-	if v, ok := cfg.Settings["episodic_encryption"]; ok && v == "true" {
-		enc = true
+	enc := cfg.Settings["episodic_encryption"] == "true" || cfg.Settings["encryption"] == "true"
+	s, err := newSealer(enc)
+	if err != nil {
+		return nil, fmt.Errorf("episodic encryption: %w", err)
 	}
-	return &episodicStore{logPath: logPath, encrypt: enc}, nil
+	return &episodicStore{logPath: logPath, encrypt: enc, sealer: s}, nil
 }
 
 func (e *episodicStore) Close() error { return nil }
@@ -41,33 +47,31 @@ func (e *episodicStore) IngestDocuments(ctx context.Context, documents []string)
 	if len(documents) == 0 {
 		return "", fmt.Errorf("no episodic entries to ingest")
 	}
+	// Retention rewrites the log; hold its lock so appends are not lost
+	unlock, err := lockEpisodes(e.logPath)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 	f, err := os.OpenFile(e.logPath, os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
+	now := time.Now().UTC()
     if e.encrypt {
-        // Encrypt entire batch as one record to keep ordering; store as binary blob per line (base64 not used here)
-        keyProvider := securityKeyProvider()
-        key, kerr := keyProvider()
-        if kerr != nil {
-            return "", kerr
-        }
-        payload := strings.Join(documents, "\n")
-        ciphertext, cerr := encryptBytes(key, []byte(payload))
-        if cerr != nil {
-            return "", cerr
-        }
-        if _, err := f.Write(ciphertext); err != nil {
+        // Encrypt entire batch as one record to keep ordering
+        sealed, err := e.sealer.seal(strings.Join(documents, "\n"))
+        if err != nil {
             return "", err
         }
-        if _, err := f.Write([]byte("\n")); err != nil {
+        if _, err := f.Write(formatEpisode(now, sealed)); err != nil {
             return "", err
         }
     } else {
         for _, d := range documents {
             line := strings.TrimSpace(d)
-            if _, err := fmt.Fprintf(f, "%s\n", line); err != nil {
+            if _, err := f.Write(formatEpisode(now, line)); err != nil {
                 return "", err
             }
         }
@@ -90,32 +94,21 @@ func (e *episodicStore) Search(ctx context.Context, query string, topK int) ([]S
 	results := make([]SearchResult, 0, topK)
 	idx := 0
 	for scanner.Scan() {
-        line := scanner.Text()
-        if e.encrypt {
-            // Decrypt batch line
-            key, kerr := securityKeyProvider()()
-            if kerr != nil {
-                return nil, kerr
-            }
-            plaintext, derr := decryptBytes(key, []byte(line))
-            if derr != nil {
-                continue
-            }
-            // expand into individual lines for scoring
-            for _, sub := range strings.Split(string(plaintext), "\n") {
-                score := simpleMatchScore(strings.ToLower(sub), q)
-                if score > 0 {
-                    results = append(results, SearchResult{ID: fmt.Sprintf("%d", idx), Content: sub, Score: score})
-                }
-                idx++
-            }
+        // Encrypted lines hold a batch; expand into individual entries for scoring
+        entries, err := e.sealer.openEpisode(scanner.Text())
+        if errors.Is(err, errUnreadableEntry) {
             continue
         }
-		score := simpleMatchScore(strings.ToLower(line), q)
-		if score > 0 {
-			results = append(results, SearchResult{ID: fmt.Sprintf("%d", idx), Content: line, Score: score})
-		}
-		idx++
+        if err != nil {
+            return nil, err
+        }
+        for _, entry := range entries {
+            score := simpleMatchScore(strings.ToLower(entry), q)
+            if score > 0 {
+                results = append(results, SearchResult{ID: fmt.Sprintf("%d", idx), Content: entry, Score: score})
+            }
+            idx++
+        }
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
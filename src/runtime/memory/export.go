@@ -0,0 +1,191 @@
+package runtimememory
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// MemorySnapshotFormat identifies the portable snapshot format written by Export.
+const MemorySnapshotFormat = "contexis.memsnap/v1"
+
+// ErrIncompatibleSnapshot is returned by Import when a snapshot's embeddings do
+// not match the target store's model or dimensions.
+var ErrIncompatibleSnapshot = errors.New("incompatible memory snapshot")
+
+// MemorySnapshot is a portable copy of a memory store: its chunks with their
+// embeddings, the documents they came from, and the embedding model they were
+// produced with. Files are gzip-compressed JSON (conventionally *.memsnap).
+type MemorySnapshot struct {
+	Format         string    `json:"format"`
+	Component      string    `json:"component"`
+	TenantID       string    `json:"tenant_id,omitempty"`
+	Provider       string    `json:"provider"`
+	EmbeddingModel string    `json:"embedding_model"`
+	Dimensions     int       `json:"dimensions"`
+	Version        string    `json:"version,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	// Documents lists the source files of the chunks, sorted.
+	Documents []string        `json:"documents,omitempty"`
+	Chunks    []SnapshotChunk `json:"chunks"`
+}
+
+// SnapshotChunk is one stored record with its embedding.
+type SnapshotChunk struct {
+	ID      string    `json:"id"`
+	Content string    `json:"content"`
+	Source  string    `json:"source,omitempty"`
+	Vector  []float64 `json:"vector"`
+}
+
+// ImportOptions controls how a snapshot is loaded into a store.
+type ImportOptions struct {
+	// ReEmbed recomputes embeddings with the target store's model instead of
+	// rejecting a snapshot produced by a different model or dimension.
+	ReEmbed bool
+}
+
+// PortableStore is implemented by memory stores that can be exported to and
+// imported from a MemorySnapshot.
+type PortableStore interface {
+	// Export returns the current contents of the store.
+	Export(ctx context.Context) (*MemorySnapshot, error)
+
+	// Import replaces the store contents with snap and records a new memory
+	// version. Snapshots from an incompatible embedding model are rejected
+	// unless opts.ReEmbed is set.
+	Import(ctx context.Context, snap *MemorySnapshot, opts ImportOptions) (string, error)
+}
+
+// AsPortableStore returns the export/import API of a store, or an error if the
+// provider does not support it.
+func AsPortableStore(store MemoryStore) (PortableStore, error) {
+	store = unwrapStore(store)
+	ps, ok := store.(PortableStore)
+	if !ok {
+		return nil, fmt.Errorf("memory provider does not support export/import")
+	}
+	return ps, nil
+}
+
+// WriteMemorySnapshot encodes snap to w.
+func WriteMemorySnapshot(w io.Writer, snap *MemorySnapshot) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadMemorySnapshot decodes a snapshot written by WriteMemorySnapshot.
+func ReadMemorySnapshot(r io.Reader) (*MemorySnapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read memory snapshot: %w", err)
+	}
+	defer zr.Close()
+	var snap MemorySnapshot
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("read memory snapshot: %w", err)
+	}
+	if snap.Format != MemorySnapshotFormat {
+		return nil, fmt.Errorf("unsupported memory snapshot format %q (want %s)", snap.Format, MemorySnapshotFormat)
+	}
+	return &snap, nil
+}
+
+// CheckCompatible reports why snap's embeddings cannot be searched by a store
+// using model and dim, or nil if they can. An empty model matches any.
+func (snap *MemorySnapshot) CheckCompatible(model string, dim int) error {
+	if snap.Dimensions != dim {
+		return fmt.Errorf("snapshot embeddings have %d dimensions, store expects %d", snap.Dimensions, dim)
+	}
+	if snap.EmbeddingModel != "" && model != "" && snap.EmbeddingModel != model {
+		return fmt.Errorf("snapshot was embedded with %q, store uses %q", snap.EmbeddingModel, model)
+	}
+	for _, c := range snap.Chunks {
+		if len(c.Vector) != dim {
+			return fmt.Errorf("chunk %s has %d dimensions, store expects %d", c.ID, len(c.Vector), dim)
+		}
+	}
+	return nil
+}
+
+// Export implements PortableStore.
+func (s *sqliteVectorStore) Export(ctx context.Context) (*MemorySnapshot, error) {
+	records, err := s.readRecords()
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.currentVersion("")
+	if err != nil {
+		return nil, err
+	}
+	snap := &MemorySnapshot{
+		Format:         MemorySnapshotFormat,
+		Component:      s.component,
+		TenantID:       s.tenantID,
+		Provider:       "sqlite",
+		EmbeddingModel: s.model,
+		Dimensions:     s.embeddingDim,
+		Version:        version,
+		CreatedAt:      time.Now().UTC(),
+		Chunks:         make([]SnapshotChunk, 0, len(records)),
+	}
+	docs := map[string]bool{}
+	for _, rec := range records {
+		vb, err := base64.StdEncoding.DecodeString(rec.Vector)
+		if err != nil {
+			return nil, fmt.Errorf("decode vector of %s: %w", rec.ID, err)
+		}
+		snap.Chunks = append(snap.Chunks, SnapshotChunk{ID: rec.ID, Content: rec.Content, Source: rec.Source, Vector: bytesToFloat64s(vb)})
+		if rec.Source != "" && !docs[rec.Source] {
+			docs[rec.Source] = true
+			snap.Documents = append(snap.Documents, rec.Source)
+		}
+	}
+	sort.Strings(snap.Documents)
+	return snap, nil
+}
+
+// Import implements PortableStore.
+func (s *sqliteVectorStore) Import(ctx context.Context, snap *MemorySnapshot, opts ImportOptions) (string, error) {
+	compatErr := snap.CheckCompatible(s.model, s.embeddingDim)
+	if compatErr != nil && !opts.ReEmbed {
+		return "", fmt.Errorf("%w: %v", ErrIncompatibleSnapshot, compatErr)
+	}
+	records := make([]vecRecord, len(snap.Chunks))
+	var pending []int
+	for i, c := range snap.Chunks {
+		records[i] = s.newRecord(c.Content, nil)
+		records[i].ID, records[i].Source = c.ID, c.Source
+		if compatErr != nil {
+			pending = append(pending, i)
+			continue
+		}
+		records[i].Vector = base64.StdEncoding.EncodeToString(float64sToBytes(c.Vector))
+	}
+	records, _, err := s.embedPending(ctx, records, pending)
+	if err != nil {
+		return "", err
+	}
+	contents := make([]string, len(records))
+	for i, rec := range records {
+		contents[i] = rec.Content
+	}
+	version := contentSHA(contents, s.model)
+	if err := s.writeRecords(records); err != nil {
+		return "", err
+	}
+	if err := s.snapshots.record(version, len(records)); err != nil {
+		return "", err
+	}
+	return version, nil
+}
@@ -0,0 +1,133 @@
+package runtimememory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dev, err := NewStore(Config{Provider: "sqlite", RootDir: t.TempDir(), ComponentName: "CustomerDocs", EmbeddingModel: "bge-small-en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	ss, _ := AsSourceStore(dev)
+	if _, err := ss.SyncSources(ctx, []Source{
+		{Path: "returns.md", Content: "Returns are accepted within 30 days."},
+		{Path: "shipping.md", Content: "Shipping takes 3-5 business days."},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := AsPortableStore(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := ps.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Component != "CustomerDocs" || snap.EmbeddingModel != "bge-small-en" || snap.Dimensions != 384 || len(snap.Chunks) != 2 || len(snap.Documents) != 2 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	var buf bytes.Buffer
+	if err := WriteMemorySnapshot(&buf, snap); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadMemorySnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prod, err := NewStore(Config{Provider: "sqlite", RootDir: t.TempDir(), ComponentName: "CustomerDocs", EmbeddingModel: "bge-small-en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prod.Close()
+	pps, _ := AsPortableStore(prod)
+	ver, err := pps.Import(ctx, read, ImportOptions{})
+	if err != nil || ver == "" {
+		t.Fatalf("Import = %q, %v", ver, err)
+	}
+	want, _ := dev.Search(ctx, "return policy", 1)
+	got, _ := prod.Search(ctx, "return policy", 1)
+	if len(got) != 1 || got[0].Content != want[0].Content || got[0].Score != want[0].Score {
+		t.Fatalf("imported search = %+v, want %+v", got, want)
+	}
+	// Imported chunks keep their sources, so re-ingesting the same files is a no-op
+	res, err := prod.(SourceStore).UpdateSources(ctx, []Source{{Path: "returns.md", Content: "Returns are accepted within 30 days."}}, nil)
+	if err != nil || res.Changed() {
+		t.Fatalf("re-ingest after import = %+v, %v", res, err)
+	}
+}
+
+func TestSQLiteImport_IncompatibleModel(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: t.TempDir(), ComponentName: "Docs", EmbeddingModel: "bge-small-en",
+		Settings: map[string]string{"embedding_dim": "128"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	snap := &MemorySnapshot{Format: MemorySnapshotFormat, EmbeddingModel: "text-embedding-3-small", Dimensions: 3,
+		Chunks: []SnapshotChunk{{ID: "a", Content: "Returns are accepted within 30 days.", Vector: []float64{1, 0, 0}}}}
+	ps, _ := AsPortableStore(store)
+	if _, err := ps.Import(ctx, snap, ImportOptions{}); !errors.Is(err, ErrIncompatibleSnapshot) {
+		t.Fatalf("expected incompatible snapshot error, got %v", err)
+	}
+	if _, err := ps.Import(ctx, snap, ImportOptions{ReEmbed: true}); err != nil {
+		t.Fatalf("re-embed import: %v", err)
+	}
+	res, err := store.Search(ctx, "returns", 1)
+	if err != nil || len(res) != 1 {
+		t.Fatalf("search after re-embed = %+v, %v", res, err)
+	}
+}
+
+func TestInterop_RoundTrip(t *testing.T) {
+	snap := &MemorySnapshot{Format: MemorySnapshotFormat, Component: "CustomerDocs", EmbeddingModel: "bge-small-en", Dimensions: 2, Version: "abc",
+		Documents: []string{"returns.md"},
+		Chunks: []SnapshotChunk{
+			{ID: "a", Content: "Returns are accepted within 30 days.", Source: "returns.md", Vector: []float64{1, 0}},
+			{ID: "b", Content: "Refunds take a week.", Source: "returns.md", Vector: []float64{0, 1}},
+		}}
+	for _, format := range []string{FormatLangChain, FormatLlamaIndex} {
+		var buf bytes.Buffer
+		if err := WriteInterop(&buf, snap, format); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+			t.Fatalf("%s: expected one line per chunk, got %d", format, lines)
+		}
+		got, err := ReadInterop(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if got.Component != "CustomerDocs" || got.EmbeddingModel != "bge-small-en" || got.Dimensions != 2 || len(got.Documents) != 1 ||
+			len(got.Chunks) != 2 || got.Chunks[1].ID != "b" || got.Chunks[1].Source != "returns.md" || got.Chunks[1].Vector[1] != 1 {
+			t.Fatalf("%s: round trip = %+v", format, got)
+		}
+		if err := got.CheckCompatible("bge-small-en", 2); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+	}
+
+	// Files written by the Python libraries need not carry our metadata
+	got, err := ReadInterop(strings.NewReader(`{"page_content":"Shipping takes 3-5 days.","metadata":{"source":"shipping.md"}}`+"\n"), FormatLangChain)
+	if err != nil || got.Chunks[0].ID != "chunk-1" || got.Chunks[0].Source != "shipping.md" || got.Dimensions != 0 {
+		t.Fatalf("plain LangChain document = %+v, %v", got, err)
+	}
+	got, err = ReadInterop(strings.NewReader(`{"id_":"n1","text":"Hi","relationships":{"1":{"node_id":"doc-1"}}}`), FormatLlamaIndex)
+	if err != nil || got.Chunks[0].Source != "doc-1" {
+		t.Fatalf("plain TextNode = %+v, %v", got, err)
+	}
+	if _, err := ReadInterop(strings.NewReader(""), FormatLangChain); err == nil {
+		t.Fatal("expected an empty file to be rejected")
+	}
+	if err := WriteInterop(&bytes.Buffer{}, snap, "parquet"); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
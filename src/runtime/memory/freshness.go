@@ -0,0 +1,183 @@
+package runtimememory
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Validity metadata of a document, read from its front matter. Dates are
+// YYYY-MM-DD (a whole day, UTC) or RFC 3339 timestamps.
+const (
+	// MetaValidFrom is when the document comes into force.
+	MetaValidFrom = "valid_from"
+	// MetaValidUntil is the last day (or instant) the document applies.
+	MetaValidUntil = "valid_until"
+	// MetaEffectiveDate is when the content took effect; it dates the
+	// document for freshness and, when in the future, hides it like valid_from.
+	MetaEffectiveDate = "effective_date"
+)
+
+const dateLayout = "2006-01-02"
+
+// ParseFrontMatter splits a YAML front matter block ("---" lines around a
+// mapping at the very start of text) from a Markdown or text document. Scalar
+// values become metadata, dates formatted as YYYY-MM-DD or RFC 3339; lists and
+// nested mappings are dropped. Text without a valid block is returned as is.
+func ParseFrontMatter(text string) (string, map[string]interface{}) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(text, "\ufeff"), "---")
+	if !ok {
+		return text, nil
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	if !strings.HasPrefix(rest, "\n") && !strings.HasPrefix(rest, "\r\n") {
+		return text, nil
+	}
+	var (
+		block []string
+		body  string
+		found bool
+	)
+	lines := strings.SplitAfter(rest[strings.Index(rest, "\n")+1:], "\n")
+	for i, line := range lines {
+		if t := strings.TrimRight(line, " \t\r\n"); t == "---" || t == "..." {
+			body, found = strings.Join(lines[i+1:], ""), true
+			break
+		}
+		block = append(block, line)
+	}
+	if !found {
+		return text, nil
+	}
+	var fm map[string]interface{}
+	if err := yaml.Unmarshal([]byte(strings.Join(block, "")), &fm); err != nil {
+		return text, nil
+	}
+	meta := make(map[string]interface{}, len(fm))
+	for k, v := range fm {
+		switch v := v.(type) {
+		case time.Time:
+			if v.Equal(v.Truncate(24*time.Hour)) && v.Location() == time.UTC {
+				meta[k] = v.Format(dateLayout)
+			} else {
+				meta[k] = v.Format(time.RFC3339)
+			}
+		case string, bool, int, float64:
+			meta[k] = v
+		}
+	}
+	if len(meta) == 0 {
+		meta = nil
+	}
+	return strings.TrimLeft(body, "\r\n"), meta
+}
+
+// metaTime reads a validity date from metadata. Whole days are returned as
+// the start of the day in UTC, with day set.
+func metaTime(meta map[string]interface{}, key string) (t time.Time, day, ok bool) {
+	s, _ := meta[key].(string)
+	if s = strings.TrimSpace(s); s == "" {
+		return t, false, false
+	}
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t, true, true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, true
+	}
+	return t, false, false
+}
+
+// DocumentExpired reports whether the document's valid_until has passed at
+// now. A valid_until date covers that whole day.
+func DocumentExpired(meta map[string]interface{}, now time.Time) bool {
+	until, day, ok := metaTime(meta, MetaValidUntil)
+	if !ok {
+		return false
+	}
+	if day {
+		until = until.Add(24 * time.Hour)
+	}
+	return !now.Before(until)
+}
+
+// DocumentInEffect reports whether a document applies at now: it has not
+// expired and neither its valid_from nor its effective_date is in the future.
+// Undated documents are always in effect.
+func DocumentInEffect(meta map[string]interface{}, now time.Time) bool {
+	if DocumentExpired(meta, now) {
+		return false
+	}
+	for _, key := range []string{MetaValidFrom, MetaEffectiveDate} {
+		if from, _, ok := metaTime(meta, key); ok && now.Before(from) {
+			return false
+		}
+	}
+	return true
+}
+
+// documentDate is the date a document's freshness is measured from: its
+// effective_date, else its valid_from.
+func documentDate(meta map[string]interface{}) (time.Time, bool) {
+	if t, _, ok := metaTime(meta, MetaEffectiveDate); ok {
+		return t, true
+	}
+	t, _, ok := metaTime(meta, MetaValidFrom)
+	return t, ok
+}
+
+// FreshnessConfig controls time-aware retrieval (memory_config.yaml: freshness).
+// Documents that are not in effect are excluded from search unless
+// IncludeInactive is set. With a Boost, the score of a dated document is
+// multiplied by 1 + Boost * 0.5^(age / HalfLife), so a document dated today
+// gains the full boost and one HalfLife old gains half of it; undated
+// documents keep their score.
+type FreshnessConfig struct {
+	IncludeInactive bool
+	Boost           float64
+	HalfLife        time.Duration
+}
+
+// defaultFreshnessHalfLife is the HalfLife when only a boost is configured.
+const defaultFreshnessHalfLife = 180 * 24 * time.Hour
+
+// freshnessConfigFromSettings reads freshness_* keys merged by
+// LoadComponentMemoryConfig.
+func freshnessConfigFromSettings(settings map[string]string) (FreshnessConfig, error) {
+	cfg := FreshnessConfig{IncludeInactive: settings["freshness_exclude_inactive"] == "false", HalfLife: defaultFreshnessHalfLife}
+	if v := settings["freshness_boost"]; v != "" {
+		b, err := strconv.ParseFloat(v, 64)
+		if err != nil || b < 0 {
+			return cfg, fmt.Errorf("invalid freshness boost: %s", v)
+		}
+		cfg.Boost = b
+	}
+	if v := settings["freshness_half_life_days"]; v != "" {
+		d, err := strconv.ParseFloat(v, 64)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid freshness half_life_days: %s", v)
+		}
+		cfg.HalfLife = time.Duration(d * float64(24*time.Hour))
+	}
+	return cfg, nil
+}
+
+// factor is the score multiplier of a document with meta at now.
+func (c FreshnessConfig) factor(meta map[string]interface{}, now time.Time) float64 {
+	if c.Boost == 0 {
+		return 1
+	}
+	date, ok := documentDate(meta)
+	if !ok {
+		return 1
+	}
+	age := now.Sub(date)
+	if age < 0 {
+		age = 0
+	}
+	return 1 + c.Boost*math.Pow(0.5, float64(age)/float64(c.HalfLife))
+}
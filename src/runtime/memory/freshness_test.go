@@ -0,0 +1,111 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	body, meta := ParseFrontMatter("---\ntitle: Pricing\nvalid_from: 2025-01-01\nvalid_until: \"2025-06-30\"\neffective_date: 2025-01-01T09:00:00+02:00\ntags: [a, b]\n---\n\n# Pricing\nPro costs $20.\n")
+	if body != "# Pricing\nPro costs $20.\n" {
+		t.Fatalf("body = %q", body)
+	}
+	want := map[string]interface{}{"title": "Pricing", "valid_from": "2025-01-01", "valid_until": "2025-06-30", "effective_date": "2025-01-01T09:00:00+02:00"}
+	if len(meta) != len(want) {
+		t.Fatalf("meta = %v", meta)
+	}
+	for k, v := range want {
+		if meta[k] != v {
+			t.Fatalf("meta[%s] = %v, want %v", k, meta[k], v)
+		}
+	}
+	for _, text := range []string{"# No front matter\n", "---\nunterminated: true\n", "--- \n: [broken\n---\nbody\n", "---- rule\n"} {
+		if body, meta := ParseFrontMatter(text); body != text || meta != nil {
+			t.Fatalf("ParseFrontMatter(%q) = %q, %v", text, body, meta)
+		}
+	}
+}
+
+func TestDocumentValidity(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		meta    map[string]interface{}
+		expired bool
+		active  bool
+	}{
+		{nil, false, true},
+		{map[string]interface{}{"valid_until": "2025-07-01"}, false, true}, // through the end of the day
+		{map[string]interface{}{"valid_until": "2025-06-30"}, true, false},
+		{map[string]interface{}{"valid_until": "2025-07-01T11:00:00Z"}, true, false},
+		{map[string]interface{}{"valid_from": "2025-07-02"}, false, false},
+		{map[string]interface{}{"effective_date": "2025-08-01"}, false, false},
+		{map[string]interface{}{"valid_from": "2025-01-01", "valid_until": "2025-12-31"}, false, true},
+		{map[string]interface{}{"valid_until": "someday"}, false, true},
+	} {
+		if got := DocumentExpired(tc.meta, now); got != tc.expired {
+			t.Errorf("DocumentExpired(%v) = %v", tc.meta, got)
+		}
+		if got := DocumentInEffect(tc.meta, now); got != tc.active {
+			t.Errorf("DocumentInEffect(%v) = %v", tc.meta, got)
+		}
+	}
+}
+
+func TestSearch_ExcludesInactiveAndBoostsFreshDocuments(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Pricing")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte("freshness:\n  boost: 1\n  half_life_days: 30\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Pricing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	vs := store.(*sqliteVectorStore)
+	if vs.freshness != (FreshnessConfig{Boost: 1, HalfLife: 30 * 24 * time.Hour}) {
+		t.Fatalf("freshness = %+v", vs.freshness)
+	}
+	vs.now = func() time.Time { return time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC) }
+
+	docs := map[string]string{
+		"2024.md":    "---\nvalid_until: 2024-12-31\n---\nThe Pro plan price is $15 per month.",
+		"2025.md":    "---\neffective_date: 2025-01-01\n---\nThe Pro plan price is $20 per month.",
+		"2025b.md":   "---\neffective_date: 2025-06-01\n---\nThe Pro plan price is $20 per month, billed yearly.",
+		"2026.md":    "---\nvalid_from: 2026-01-01\n---\nThe Pro plan price is $25 per month.",
+		"undated.md": "The Pro plan price includes support.",
+	}
+	var sources []Source
+	for path, text := range docs {
+		src, err := ParseDocument(FormatText, []byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src.Path = path
+		sources = append(sources, src)
+	}
+	if _, err := vs.UpdateSources(context.Background(), sources, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(context.Background(), "Pro plan price per month", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("expected the expired and future documents to be excluded, got %+v", res)
+	}
+	if res[0].Metadata["effective_date"] != "2025-06-01" {
+		t.Fatalf("expected the freshest document first, got %+v", res)
+	}
+
+	vs.freshness = FreshnessConfig{IncludeInactive: true}
+	if res, err := store.Search(context.Background(), "Pro plan price per month", 5); err != nil || len(res) != 5 {
+		t.Fatalf("Search with IncludeInactive = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,211 @@
+package runtimememory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// staleTempAge is the age after which a temp file is taken to be left by an
+// interrupted write rather than one in progress.
+const staleTempAge = time.Hour
+
+// GCOptions select what garbage collection removes from a store.
+type GCOptions struct {
+	// KeepLast is the number of most recent snapshots kept; older ones are
+	// dropped unless current or tagged. 0 keeps every snapshot.
+	KeepLast int
+}
+
+// GCReport counts what garbage collection removed.
+type GCReport struct {
+	Snapshots int   `json:"snapshots_removed"`
+	Records   int   `json:"records_removed"`
+	Files     int   `json:"files_removed"`
+	Bytes     int64 `json:"bytes_reclaimed"`
+}
+
+// Collector is implemented by memory stores that can compact their files and
+// drop old versions.
+type Collector interface {
+	GC(ctx context.Context, opts GCOptions) (GCReport, error)
+}
+
+// GC compacts a store and drops its old versions, or returns an error if the
+// provider does not support garbage collection.
+func GC(ctx context.Context, store MemoryStore, opts GCOptions) (GCReport, error) {
+	if opts.KeepLast < 0 {
+		return GCReport{}, fmt.Errorf("keep-last must not be negative")
+	}
+	c, ok := unwrapStore(store).(Collector)
+	if !ok {
+		return GCReport{}, fmt.Errorf("memory provider does not support garbage collection")
+	}
+	return c.GC(ctx, opts)
+}
+
+// GC implements Collector: the store file is compacted, snapshots beyond
+// opts.KeepLast are dropped and files left over by interrupted writes removed.
+func (s *sqliteVectorStore) GC(ctx context.Context, opts GCOptions) (GCReport, error) {
+	rep, err := s.compact()
+	if err != nil {
+		return rep, err
+	}
+	snapshots, files, freed, err := s.snapshots.prune(opts.KeepLast)
+	rep.Snapshots += snapshots
+	rep.Files += files
+	rep.Bytes += freed
+	return rep, err
+}
+
+// compact rewrites the store file without duplicate records and stale
+// embeddings: records embedded under other model or dimension settings whose
+// chunk has since been embedded again. Records written before content hashing
+// get their hash stored. A saved HNSW graph is removed unless the store uses
+// the hnsw index, as are stale temp files next to the store.
+func (s *sqliteVectorStore) compact() (GCReport, error) {
+	var rep GCReport
+	view, err := s.view()
+	if err != nil {
+		return rep, err
+	}
+	before := view.info.Size()
+	records, err := s.readRecords()
+	if err != nil {
+		return rep, err
+	}
+	current := func(rec vecRecord) bool { return rec.Hash == s.chunkHash(rec.Content, rec.Model) }
+	fresh := map[string]bool{}
+	for _, rec := range records {
+		if current(rec) {
+			fresh[rec.Source+"\x00"+rec.Content] = true
+		}
+	}
+	seen := map[string]bool{}
+	kept := make([]vecRecord, 0, len(records))
+	for _, rec := range records {
+		key := rec.Source + "\x00" + rec.Hash
+		if seen[key] || (!current(rec) && fresh[rec.Source+"\x00"+rec.Content]) {
+			rep.Records++
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, rec)
+	}
+	if rep.Records > 0 || view.unhashed > 0 {
+		if err := s.writeRecords(kept); err != nil {
+			return rep, err
+		}
+		s.observeRecords(kept)
+		if info, err := os.Stat(s.filePath); err == nil && info.Size() < before {
+			rep.Bytes += before - info.Size()
+		}
+	}
+	dir := filepath.Dir(s.filePath)
+	if s.index != SearchIndexHNSW {
+		n, err := removeFile(filepath.Join(dir, indexFile))
+		if err != nil {
+			return rep, err
+		}
+		if n > 0 {
+			rep.Files++
+			rep.Bytes += n
+		}
+	}
+	files, freed, err := removeStaleTemps(dir)
+	rep.Files += files
+	rep.Bytes += freed
+	return rep, err
+}
+
+// GC implements Collector. The log keeps no versions, so opts does not apply:
+// blank lines are dropped and temp files left by retention removed.
+func (e *episodicStore) GC(ctx context.Context, _ GCOptions) (GCReport, error) {
+	var rep GCReport
+	unlock, err := lockEpisodes(e.logPath)
+	if err != nil {
+		return rep, err
+	}
+	defer unlock()
+	f, err := os.Open(e.logPath)
+	if err != nil {
+		return rep, err
+	}
+	var b strings.Builder
+	blank := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			blank += len(sc.Bytes()) + 1
+			continue
+		}
+		b.WriteString(sc.Text())
+		b.WriteByte('\n')
+	}
+	_ = f.Close()
+	if err := sc.Err(); err != nil {
+		return rep, err
+	}
+	if blank > 0 {
+		if err := writeFileAtomic(e.logPath, []byte(b.String())); err != nil {
+			return rep, err
+		}
+		rep.Bytes += int64(blank)
+	}
+	files, freed, err := removeStaleTemps(filepath.Dir(e.logPath))
+	rep.Files += files
+	rep.Bytes += freed
+	return rep, err
+}
+
+// removeStaleTemps removes the temp files of atomic writes (".tmp-*" and
+// "*.tmp") in dir that are older than staleTempAge.
+func removeStaleTemps(dir string) (int, int64, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var (
+		files int
+		freed int64
+	)
+	for _, e := range entries {
+		if e.IsDir() || !(strings.HasPrefix(e.Name(), ".tmp-") || strings.HasSuffix(e.Name(), ".tmp")) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		n, err := removeFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return files, freed, err
+		}
+		files++
+		freed += n
+	}
+	return files, freed, nil
+}
+
+// removeFile removes path and returns its size; a missing file is 0.
+func removeFile(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,96 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteGC_CompactsAndDropsOldSnapshots(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	var versions []string
+	for _, doc := range []string{"Returns are accepted within 30 days.", "Shipping is free over $50.", "Error E42 means no toner."} {
+		v, err := store.IngestDocuments(ctx, []string{doc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	snaps, _ := AsSnapshotStore(store)
+	if err := snaps.TagSnapshot(ctx, versions[0], "stable"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a duplicated record, an orphaned snapshot and a temp file left by a crash
+	storePath := DerivePath(root, "CustomerDocs", "", "vector_store.jsonl")
+	by, _ := os.ReadFile(storePath)
+	first := strings.SplitAfter(string(by), "\n")[0]
+	if err := os.WriteFile(storePath, append(by, first...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snapDir := filepath.Join(filepath.Dir(storePath), "snapshots")
+	os.WriteFile(filepath.Join(snapDir, "0000orphan.jsonl"), []byte(first), 0o644)
+	tmp := filepath.Join(snapDir, ".tmp-index.json123")
+	os.WriteFile(tmp, []byte("{}"), 0o644)
+	old := time.Now().Add(-2 * staleTempAge)
+	os.Chtimes(tmp, old, old)
+
+	rep, err := GC(ctx, store, GCOptions{KeepLast: 1})
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if rep.Snapshots != 1 || rep.Records != 1 || rep.Files != 2 || rep.Bytes <= int64(len(first)) {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	list, _ := snaps.ListSnapshots(ctx)
+	if len(list) != 2 || list[0].Version != versions[0] || list[1].Version != versions[2] {
+		t.Fatalf("expected the tagged and the current snapshot to be kept, got %+v", list)
+	}
+	if _, err := os.Stat(filepath.Join(snapDir, versions[1]+".jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("expected the dropped snapshot's file to be removed: %v", err)
+	}
+	if res, _ := store.Search(ctx, "toner", 5); len(res) != 3 {
+		t.Fatalf("expected the compacted store to keep its 3 records, got %d", len(res))
+	}
+
+	if rep, err := GC(ctx, store, GCOptions{KeepLast: 1}); err != nil || rep != (GCReport{}) {
+		t.Fatalf("expected nothing left to collect, got %+v, %v", rep, err)
+	}
+	if _, err := GC(ctx, store, GCOptions{KeepLast: -1}); err == nil {
+		t.Fatal("expected a negative keep-last to be rejected")
+	}
+}
+
+func TestEpisodicGC_DropsBlankLines(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.IngestDocuments(ctx, []string{"user asked about refunds"}); err != nil {
+		t.Fatal(err)
+	}
+	logPath := DerivePath(root, "SupportBot", "", "episodic/episodes.log")
+	f, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString("\n  \n")
+	f.Close()
+
+	rep, err := GC(ctx, store, GCOptions{KeepLast: 3})
+	if err != nil || rep.Bytes != 4 || rep.Snapshots != 0 {
+		t.Fatalf("GC = %+v, %v", rep, err)
+	}
+	if res, _ := store.Search(ctx, "refunds", 5); len(res) != 1 {
+		t.Fatalf("expected the entry to survive, got %d", len(res))
+	}
+}
@@ -0,0 +1,239 @@
+package runtimememory
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// HNSW defaults; see memory_config.yaml search.hnsw.
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 64
+)
+
+// Search index types (memory_config.yaml search.index).
+const (
+	SearchIndexFlat = "flat"
+	SearchIndexHNSW = "hnsw"
+)
+
+// hnswParams configures the approximate nearest neighbor graph.
+type hnswParams struct {
+	M              int `json:"m"`
+	EfConstruction int `json:"ef_construction"`
+	EfSearch       int `json:"ef_search"`
+}
+
+// searchIndexSettings returns the configured index type and HNSW parameters.
+func searchIndexSettings(settings map[string]string) (string, hnswParams) {
+	p := hnswParams{M: defaultHNSWM, EfConstruction: defaultHNSWEfConstruction, EfSearch: defaultHNSWEfSearch}
+	for key, out := range map[string]*int{"hnsw_m": &p.M, "hnsw_ef_construction": &p.EfConstruction, "hnsw_ef_search": &p.EfSearch} {
+		if v, err := strconv.Atoi(settings[key]); err == nil && v > 0 {
+			*out = v
+		}
+	}
+	if p.M < 2 {
+		p.M = 2
+	}
+	if settings["search_index"] == SearchIndexHNSW {
+		return SearchIndexHNSW, p
+	}
+	return SearchIndexFlat, p
+}
+
+// hnswIndex is a hierarchical navigable small world graph over unit vectors
+// (Malkov & Yashunin). Nodes are positions in vecs; Neighbors[n][l] are the
+// neighbors of node n on layer l. Distances are 1 - cosine similarity.
+type hnswIndex struct {
+	Params    hnswParams  `json:"params"`
+	Entry     int         `json:"entry"`
+	MaxLevel  int         `json:"max_level"`
+	Neighbors [][][]int32 `json:"neighbors"`
+
+	vecs [][]float64
+}
+
+// buildHNSW indexes vecs, which must be normalized. Levels are drawn from a
+// fixed seed so the same vectors always produce the same graph.
+func buildHNSW(vecs [][]float64, p hnswParams) *hnswIndex {
+	h := &hnswIndex{Params: p, Entry: -1, Neighbors: make([][][]int32, len(vecs)), vecs: vecs}
+	rng := rand.New(rand.NewSource(1))
+	mL := 1 / math.Log(float64(p.M))
+	for n := range vecs {
+		level := int(-math.Log(1-rng.Float64()) * mL)
+		h.insert(n, level)
+	}
+	return h
+}
+
+func (h *hnswIndex) dist(q []float64, n int) float64 {
+	return 1 - dot(q, h.vecs[n])
+}
+
+func (h *hnswIndex) maxNeighbors(layer int) int {
+	if layer == 0 {
+		return 2 * h.Params.M
+	}
+	return h.Params.M
+}
+
+func (h *hnswIndex) insert(n, level int) {
+	h.Neighbors[n] = make([][]int32, level+1)
+	if h.Entry < 0 {
+		h.Entry, h.MaxLevel = n, level
+		return
+	}
+	q := h.vecs[n]
+	ep := h.Entry
+	for l := h.MaxLevel; l > level; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	for l := min(level, h.MaxLevel); l >= 0; l-- {
+		found := h.searchLayer(q, ep, h.Params.EfConstruction, l)
+		links := found
+		if len(links) > h.Params.M {
+			links = links[:h.Params.M]
+		}
+		h.Neighbors[n][l] = make([]int32, len(links))
+		for i, c := range links {
+			h.Neighbors[n][l][i] = int32(c.node)
+			h.link(c.node, n, l)
+		}
+		ep = found[0].node
+	}
+	if level > h.MaxLevel {
+		h.Entry, h.MaxLevel = n, level
+	}
+}
+
+// link adds n to the neighbors of m on layer l, keeping the closest ones when
+// m has too many.
+func (h *hnswIndex) link(m, n, l int) {
+	links := append(h.Neighbors[m][l], int32(n))
+	if len(links) > h.maxNeighbors(l) {
+		base := h.vecs[m]
+		sort.Slice(links, func(i, j int) bool {
+			return h.dist(base, int(links[i])) < h.dist(base, int(links[j]))
+		})
+		links = links[:h.maxNeighbors(l)]
+	}
+	h.Neighbors[m][l] = links
+}
+
+// greedy walks layer l towards q and returns the closest node reached.
+func (h *hnswIndex) greedy(q []float64, ep, l int) int {
+	best := h.dist(q, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range h.Neighbors[ep][l] {
+			if d := h.dist(q, int(nb)); d < best {
+				best, ep, changed = d, int(nb), true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef nodes of layer l closest to q, nearest first.
+func (h *hnswIndex) searchLayer(q []float64, ep, ef, l int) []hnswCandidate {
+	visited := map[int]bool{ep: true}
+	start := hnswCandidate{node: ep, dist: h.dist(q, ep)}
+	candidates := &candidateHeap{items: []hnswCandidate{start}}
+	results := &candidateHeap{items: []hnswCandidate{start}, farthest: true}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if c.dist > results.items[0].dist {
+			break
+		}
+		for _, nb := range h.Neighbors[c.node][l] {
+			n := int(nb)
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			d := h.dist(q, n)
+			if results.Len() < ef || d < results.items[0].dist {
+				heap.Push(candidates, hnswCandidate{node: n, dist: d})
+				heap.Push(results, hnswCandidate{node: n, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	out := results.items
+	sort.Slice(out, func(i, j int) bool { return out[i].dist < out[j].dist })
+	return out
+}
+
+// search returns the nodes of up to k approximate nearest neighbors of the
+// normalized vector q, exploring at least ef candidates.
+func (h *hnswIndex) search(q []float64, k, ef int) []int {
+	if h.Entry < 0 || k <= 0 {
+		return nil
+	}
+	ep := h.Entry
+	for l := h.MaxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	found := h.searchLayer(q, ep, max(ef, k), 0)
+	if len(found) > k {
+		found = found[:k]
+	}
+	out := make([]int, len(found))
+	for i, c := range found {
+		out[i] = c.node
+	}
+	return out
+}
+
+type hnswCandidate struct {
+	node int
+	dist float64
+}
+
+// candidateHeap is a min-heap by distance, or a max-heap when farthest is set.
+type candidateHeap struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.farthest {
+		return c.items[i].dist > c.items[j].dist
+	}
+	return c.items[i].dist < c.items[j].dist
+}
+func (c *candidateHeap) Swap(i, j int)      { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x interface{}) { c.items = append(c.items, x.(hnswCandidate)) }
+func (c *candidateHeap) Pop() interface{} {
+	last := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return last
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// normalize returns v scaled to unit length; a zero vector is returned as is.
+func normalize(v []float64) []float64 {
+	n := math.Sqrt(dot(v, v))
+	if n == 0 {
+		return v
+	}
+	out := make([]float64, len(v))
+	for i := range v {
+		out[i] = v[i] / n
+	}
+	return out
+}
@@ -0,0 +1,180 @@
+package runtimememory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHNSW_RecallAgainstBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	vecs := make([][]float64, 2000)
+	for i := range vecs {
+		v := make([]float64, 32)
+		for j := range v {
+			v[j] = rng.NormFloat64()
+		}
+		vecs[i] = normalize(v)
+	}
+	p := hnswParams{M: defaultHNSWM, EfConstruction: defaultHNSWEfConstruction, EfSearch: defaultHNSWEfSearch}
+	graph := buildHNSW(vecs, p)
+
+	const k = 10
+	hits, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := vecs[rng.Intn(len(vecs))]
+		exact := make([]int, len(vecs))
+		for i := range exact {
+			exact[i] = i
+		}
+		sort.Slice(exact, func(i, j int) bool { return dot(query, vecs[exact[i]]) > dot(query, vecs[exact[j]]) })
+		want := map[int]bool{}
+		for _, n := range exact[:k] {
+			want[n] = true
+		}
+		for _, n := range graph.search(query, k, p.EfSearch) {
+			if want[n] {
+				hits++
+			}
+		}
+		total += k
+	}
+	if recall := float64(hits) / float64(total); recall < 0.9 {
+		t.Fatalf("recall@%d = %.2f, want >= 0.9", k, recall)
+	}
+}
+
+func TestSQLiteHNSWIndex_OptimizeSavesGraph(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Docs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte("search:\n  index: hnsw\n  hnsw:\n    m: 8\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	docs := make([]string, 300)
+	for i := range docs {
+		docs[i] = fmt.Sprintf("Policy %d covers topic number %d in depth", i, i*7)
+	}
+	docs = append(docs, "Returns are accepted within 30 days of purchase.")
+	ctx := context.Background()
+	if _, err := store.IngestDocuments(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(ctx, "Returns are accepted within 30 days of purchase.", 3)
+	if err != nil || len(res) != 3 || res[0].Content != docs[len(docs)-1] {
+		t.Fatalf("search = %+v, %v", res, err)
+	}
+
+	if err := store.Optimize(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	s := store.(*sqliteVectorStore)
+	view, err := readStoreView(s.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []int
+	for i := range view.records {
+		nodes = append(nodes, i)
+	}
+	if view.loadGraph(s.filePath, s.embeddingDim, nodes, s.hnsw) == nil {
+		t.Fatal("expected the saved graph to match the store")
+	}
+
+	// Ingesting again makes the saved graph stale; search rebuilds it
+	if _, err := store.IngestDocuments(ctx, []string{"Shipping takes 3-5 business days."}); err != nil {
+		t.Fatal(err)
+	}
+	view, _ = readStoreView(s.filePath)
+	if view.loadGraph(s.filePath, s.embeddingDim, append(nodes, len(nodes)), s.hnsw) != nil {
+		t.Fatal("expected the saved graph to be stale")
+	}
+	res, err = store.Search(ctx, "Shipping takes 3-5 business days.", 1)
+	if err != nil || len(res) != 1 || res[0].Content != "Shipping takes 3-5 business days." {
+		t.Fatalf("search after ingest = %+v, %v", res, err)
+	}
+}
+
+func TestSQLiteHNSWIndex_HostedEmbeddings(t *testing.T) {
+	// Vectors of 3 dims while embedding_dim keeps its default of 384
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := struct {
+			Embeddings [][]float64 `json:"embeddings"`
+		}{}
+		for _, text := range req.Texts {
+			switch {
+			case strings.Contains(text, "refund"):
+				out.Embeddings = append(out.Embeddings, []float64{1, 0, 0})
+			case strings.Contains(text, "ship"):
+				out.Embeddings = append(out.Embeddings, []float64{0, 1, 0})
+			default:
+				out.Embeddings = append(out.Embeddings, []float64{0, 0, 1})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+	t.Setenv("COHERE_API_KEY", "k")
+	t.Setenv("CMP_COHERE_EMBED_URL", srv.URL)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Docs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := "embedding_model:\n  name: embed-english-v3.0\n  provider: cohere\n  requests_per_minute: 6000\nsearch:\n  index: hnsw\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	s := store.(*sqliteVectorStore)
+	if s.index != SearchIndexHNSW || s.embeddingDim == 3 {
+		t.Fatalf("expected the hnsw index with the default embedding_dim, got %s, %d", s.index, s.embeddingDim)
+	}
+	ctx := context.Background()
+	if _, err := store.IngestDocuments(ctx, []string{"We ship in 3 days", "A refund is issued in 5 days", "Our office is in Berlin"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(ctx, "how long does a refund take", 1)
+	if err != nil || len(res) != 1 || res[0].Content != "A refund is issued in 5 days" {
+		t.Fatalf("search = %+v, %v", res, err)
+	}
+
+	// Optimize saves the graph of the store's vectors, not of embedding_dim
+	if err := store.Optimize(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	view, err := readStoreView(s.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.loadGraph(s.filePath, 3, []int{0, 1, 2}, s.hnsw) == nil {
+		t.Fatal("expected the saved graph over the 3-dim vectors")
+	}
+	if res, err = store.Search(ctx, "when do you ship", 1); err != nil || len(res) != 1 || res[0].Content != "We ship in 3 days" {
+		t.Fatalf("search after optimize = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,265 @@
+package runtimememory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	"golang.org/x/time/rate"
+)
+
+// hostedEmbedAPIs are the hosted embedding providers selected with
+// embedding_model.provider, with their API key variable, endpoint (overridable
+// with the CMP_*_EMBED_URL variable) and the input type of stored chunks and of
+// queries.
+var hostedEmbedAPIs = map[string]struct {
+	keyEnv, urlEnv, endpoint string
+	documentType, queryType  string
+}{
+	"openai": {"OPENAI_API_KEY", "CMP_OPENAI_EMBED_URL", "https://api.openai.com/v1/embeddings", "", ""},
+	"cohere": {"COHERE_API_KEY", "CMP_COHERE_EMBED_URL", "https://api.cohere.com/v1/embed", "search_document", "search_query"},
+	"voyage": {"VOYAGE_API_KEY", "CMP_VOYAGE_EMBED_URL", "https://api.voyageai.com/v1/embeddings", "document", "query"},
+}
+
+// hostedEmbedder calls a hosted embedding API. Requests of all workers of a
+// provider and key share one rate limiter.
+type hostedEmbedder struct {
+	provider string
+	endpoint string
+	apiKey   string
+	client   *http.Client
+	limiter  *embedLimiter
+	retry    runtimemodel.ResilienceConfig
+}
+
+// newHostedEmbedder returns the hosted embedder configured by the
+// embedding_provider setting, or nil for the local embedding.
+func newHostedEmbedder(settings map[string]string) (*hostedEmbedder, error) {
+	provider := strings.ToLower(settings["embedding_provider"])
+	if provider == "" || provider == "local" {
+		return nil, nil
+	}
+	api, ok := hostedEmbedAPIs[provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported embedding provider: %s", provider)
+	}
+	keyEnv := api.keyEnv
+	if v := settings["embedding_api_key_env"]; v != "" {
+		keyEnv = v
+	}
+	key := os.Getenv(keyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s is required for %s embeddings", keyEnv, provider)
+	}
+	endpoint := settings["embedding_endpoint"]
+	if endpoint == "" {
+		endpoint = envOr(api.urlEnv, api.endpoint)
+	}
+	rpm, _ := strconv.Atoi(settings["embedding_requests_per_minute"])
+	return &hostedEmbedder{
+		provider: provider,
+		endpoint: endpoint,
+		apiKey:   key,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		limiter:  limiterFor(provider+"\n"+key, rpm),
+		retry:    runtimemodel.ResilienceConfigFromEnv(),
+	}, nil
+}
+
+// embedFunc returns the embedding function of model for stored chunks, or for
+// queries, which some providers embed differently.
+func (h *hostedEmbedder) embedFunc(model string, query bool) embedFunc {
+	call := func(ctx context.Context, texts []string) ([][]float64, error) {
+		return h.request(ctx, model, texts, query)
+	}
+	return h.resilient(model, call)
+}
+
+// request embeds texts in one API call.
+func (h *hostedEmbedder) request(ctx context.Context, model string, texts []string, query bool) ([][]float64, error) {
+	api := hostedEmbedAPIs[h.provider]
+	payload := map[string]interface{}{"model": model}
+	inputType := api.documentType
+	if query {
+		inputType = api.queryType
+	}
+	if inputType != "" {
+		payload["input_type"] = inputType
+	}
+	if h.provider == "cohere" {
+		payload["texts"] = texts
+	} else {
+		payload["input"] = texts
+	}
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, runtimemodel.NewStatusError(h.provider+" embedding", resp)
+	}
+	var out struct {
+		Embeddings [][]float64 `json:"embeddings"` // cohere
+		Data       []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"` // openai, voyage
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.Embeddings != nil {
+		return out.Embeddings, nil
+	}
+	vecs := make([][]float64, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vecs) {
+			return nil, fmt.Errorf("%s embedding api returned index %d for %d texts", h.provider, d.Index, len(texts))
+		}
+		vecs[d.Index] = d.Embedding
+	}
+	for i, v := range vecs {
+		if v == nil {
+			return nil, fmt.Errorf("%s embedding api returned no vector for text %d", h.provider, i)
+		}
+	}
+	return vecs, nil
+}
+
+// resilient waits for the rate limiter before each call, retries transient
+// failures with backoff, and splits a batch the API rejected (400, 413) into
+// halves, so one oversized input does not fail the texts batched with it.
+func (h *hostedEmbedder) resilient(model string, call embedFunc) embedFunc {
+	var embed embedFunc
+	embed = func(ctx context.Context, texts []string) ([][]float64, error) {
+		var err error
+		for attempt := 1; attempt <= h.retry.MaxAttempts; attempt++ {
+			if err := h.limiter.wait(ctx); err != nil {
+				return nil, err
+			}
+			var vecs [][]float64
+			if vecs, err = call(ctx, texts); err == nil {
+				return vecs, nil
+			}
+			var se *runtimemodel.StatusError
+			throttled := errors.As(err, &se) && se.Code == http.StatusTooManyRequests
+			if !runtimemodel.IsRetryable(err) || attempt == h.retry.MaxAttempts {
+				break
+			}
+			delay := h.retry.Backoff(attempt)
+			reason := "network"
+			if se != nil {
+				reason = strconv.Itoa(se.Code)
+				if se.RetryAfter > delay {
+					delay = se.RetryAfter
+				}
+			}
+			if throttled {
+				// every worker of this provider waits, not just this one
+				h.limiter.pause(delay)
+			}
+			runtimemodel.ProviderRetries.WithLabelValues(model, reason).Inc()
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+		var se *runtimemodel.StatusError
+		if len(texts) > 1 && errors.As(err, &se) && (se.Code == http.StatusBadRequest || se.Code == http.StatusRequestEntityTooLarge) {
+			mid := len(texts) / 2
+			head, err := embed(ctx, texts[:mid])
+			if err != nil {
+				return nil, err
+			}
+			tail, err := embed(ctx, texts[mid:])
+			if err != nil {
+				return nil, err
+			}
+			return append(head, tail...), nil
+		}
+		return nil, err
+	}
+	return embed
+}
+
+// embedLimiter spaces requests to a provider and holds them all back after a
+// 429 response.
+type embedLimiter struct {
+	limiter *rate.Limiter // nil: no request rate configured
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+var embedLimiters = struct {
+	sync.Mutex
+	m map[string]*embedLimiter
+}{m: map[string]*embedLimiter{}}
+
+// limiterFor returns the shared limiter of a provider and key, allowing rpm
+// requests per minute (0: unlimited until the provider throttles).
+func limiterFor(key string, rpm int) *embedLimiter {
+	embedLimiters.Lock()
+	defer embedLimiters.Unlock()
+	l := embedLimiters.m[key]
+	if l == nil {
+		l = &embedLimiter{}
+		embedLimiters.m[key] = l
+	}
+	l.mu.Lock()
+	if rpm > 0 && (l.limiter == nil || l.limiter.Limit() != rate.Limit(float64(rpm)/60)) {
+		l.limiter = rate.NewLimiter(rate.Limit(float64(rpm)/60), 1)
+	}
+	l.mu.Unlock()
+	return l
+}
+
+func (l *embedLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	until, limiter := l.until, l.limiter
+	l.mu.Unlock()
+	if d := time.Until(until); d > 0 {
+		if err := sleepContext(ctx, d); err != nil {
+			return err
+		}
+	}
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
+
+func (l *embedLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
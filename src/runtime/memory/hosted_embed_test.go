@@ -0,0 +1,180 @@
+package runtimememory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEmbeddingAPI answers OpenAI-style embedding requests with a vector per
+// text whose first component is the text length. It throttles the first
+// request and rejects batches larger than maxBatch.
+type fakeEmbeddingAPI struct {
+	mu       sync.Mutex
+	maxBatch int
+	batches  [][]string
+	calls    int
+}
+
+func (f *fakeEmbeddingAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	f.calls++
+	first := f.calls == 1
+	if !first && len(req.Input) <= f.maxBatch {
+		f.batches = append(f.batches, req.Input)
+	}
+	f.mu.Unlock()
+	switch {
+	case r.Header.Get("Authorization") != "Bearer test-key":
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	case first:
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	case len(req.Input) > f.maxBatch:
+		http.Error(w, "too many inputs", http.StatusBadRequest)
+		return
+	}
+	type datum struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	}
+	out := struct {
+		Data []datum `json:"data"`
+	}{}
+	// answer out of order; vectors are placed by index
+	for i := len(req.Input) - 1; i >= 0; i-- {
+		out.Data = append(out.Data, datum{Index: i, Embedding: []float64{float64(len(req.Input[i])), 1, 0}})
+	}
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func TestHostedEmbedder_RetriesThrottlingAndSplitsRejectedBatches(t *testing.T) {
+	t.Setenv("CMP_PROVIDER_RETRY_BASE_DELAY", "1ms")
+	t.Setenv("CMP_PROVIDER_RETRY_MAX_DELAY", "5ms")
+	t.Setenv("TEST_EMBED_KEY", "test-key")
+	api := &fakeEmbeddingAPI{maxBatch: 2}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	h, err := newHostedEmbedder(map[string]string{"embedding_provider": "openai", "embedding_endpoint": srv.URL, "embedding_api_key_env": "TEST_EMBED_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	vecs, err := h.embedFunc("text-embedding-3-small", false)(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs {
+		if v[0] != float64(len(texts[i])) {
+			t.Fatalf("vector %d belongs to another text: %v", i, v)
+		}
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for _, b := range api.batches {
+		if len(b) > 2 {
+			t.Fatalf("accepted batch of %d texts", len(b))
+		}
+	}
+	if got := strings.Join(flatten(api.batches), ","); got != "a,bb,ccc,dddd,eeeee" {
+		t.Fatalf("sub-batches embedded %s", got)
+	}
+}
+
+func flatten(batches [][]string) []string {
+	var out []string
+	for _, b := range batches {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestHostedEmbedder_Settings(t *testing.T) {
+	if _, err := newHostedEmbedder(map[string]string{"embedding_provider": "nope"}); err == nil {
+		t.Fatal("expected an unknown provider to fail")
+	}
+	t.Setenv("COHERE_API_KEY", "")
+	if _, err := newHostedEmbedder(map[string]string{"embedding_provider": "cohere"}); err == nil || !strings.Contains(err.Error(), "COHERE_API_KEY") {
+		t.Fatalf("expected a missing key error, got %v", err)
+	}
+	if h, err := newHostedEmbedder(map[string]string{}); h != nil || err != nil {
+		t.Fatalf("expected the local embedding by default, got %v, %v", h, err)
+	}
+	if _, batch := embedSettings(map[string]string{"embedding_provider": "cohere"}, "custom-model"); batch != 96 {
+		t.Fatalf("expected the cohere batch limit, got %d", batch)
+	}
+}
+
+func TestSQLiteStore_HostedEmbeddings(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts     []string `json:"texts"`
+			InputType string   `json:"input_type"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := struct {
+			Embeddings [][]float64 `json:"embeddings"`
+		}{}
+		for _, text := range req.Texts {
+			v := []float64{0, 0}
+			if strings.Contains(text, "refund") {
+				v[0] = 1
+			} else {
+				v[1] = 1
+			}
+			out.Embeddings = append(out.Embeddings, v)
+		}
+		if req.InputType == "search_query" {
+			mu.Lock()
+			queries = append(queries, req.Texts...)
+			mu.Unlock()
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+	t.Setenv("COHERE_API_KEY", "k")
+	t.Setenv("CMP_COHERE_EMBED_URL", srv.URL)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Docs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := "embedding_model:\n  name: embed-english-v3.0\n  provider: cohere\n  dimensions: 2\n  requests_per_minute: 6000\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	if _, err := store.IngestDocuments(ctx, []string{"Shipping takes 3 days", "Refunds: a refund is issued in 5 days"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(ctx, "how long does a refund take", 1)
+	if err != nil || len(res) != 1 || !strings.HasPrefix(res[0].Content, "Refunds") {
+		t.Fatalf("search = %+v, %v", res, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 1 || queries[0] != "how long does a refund take" {
+		t.Fatalf("expected the query embedded as search_query, got %v", queries)
+	}
+}
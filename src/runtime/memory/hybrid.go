@@ -0,0 +1,131 @@
+package runtimememory
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Search modes for vector stores (memory_config.yaml: search.mode).
+const (
+	SearchModeVector  = "vector"
+	SearchModeKeyword = "keyword"
+	SearchModeHybrid  = "hybrid"
+)
+
+// defaultRRFK is the reciprocal rank fusion constant from Cormack et al.
+const defaultRRFK = 60
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// tokenize lowercases text and splits it into letter/digit runs, so identifiers
+// such as "SKU-1042" or "E42" remain searchable.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// bm25Scores scores each document against query with Okapi BM25. Documents that
+// share no terms with the query score 0.
+func bm25Scores(query string, docs []string) []float64 {
+	scores := make([]float64, len(docs))
+	qTerms := tokenize(query)
+	if len(qTerms) == 0 || len(docs) == 0 {
+		return scores
+	}
+	tfs := make([]map[string]int, len(docs))
+	lengths := make([]int, len(docs))
+	df := map[string]int{}
+	total := 0
+	for i, d := range docs {
+		toks := tokenize(d)
+		lengths[i] = len(toks)
+		total += len(toks)
+		tf := map[string]int{}
+		for _, t := range toks {
+			tf[t]++
+		}
+		for t := range tf {
+			df[t]++
+		}
+		tfs[i] = tf
+	}
+	avgLen := float64(total) / float64(len(docs))
+	if avgLen == 0 {
+		return scores
+	}
+	n := float64(len(docs))
+	seen := map[string]bool{}
+	for _, q := range qTerms {
+		if seen[q] || df[q] == 0 {
+			continue
+		}
+		seen[q] = true
+		idf := math.Log(1 + (n-float64(df[q])+0.5)/(float64(df[q])+0.5))
+		for i := range docs {
+			f := float64(tfs[i][q])
+			if f == 0 {
+				continue
+			}
+			norm := f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/avgLen))
+			scores[i] += idf * norm
+		}
+	}
+	return scores
+}
+
+// rankOf returns the 1-based rank of each index when ordered by descending score.
+// Items with a score <= 0 are unranked (rank 0).
+func rankOf(scores []float64) []int {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	ranks := make([]int, len(scores))
+	for r, i := range idx {
+		if scores[i] > 0 {
+			ranks[i] = r + 1
+		}
+	}
+	return ranks
+}
+
+// fuseRRF combines rankings with reciprocal rank fusion: Σ 1/(k + rank).
+func fuseRRF(k int, rankings ...[]int) []float64 {
+	if len(rankings) == 0 {
+		return nil
+	}
+	fused := make([]float64, len(rankings[0]))
+	for _, ranks := range rankings {
+		for i, r := range ranks {
+			if r > 0 {
+				fused[i] += 1 / float64(k+r)
+			}
+		}
+	}
+	return fused
+}
+
+// searchMode reads the configured mode and RRF constant from store settings.
+func searchMode(settings map[string]string) (string, int) {
+	mode := SearchModeVector
+	switch strings.ToLower(settings["search_mode"]) {
+	case SearchModeHybrid:
+		mode = SearchModeHybrid
+	case SearchModeKeyword:
+		mode = SearchModeKeyword
+	}
+	k := defaultRRFK
+	if v, err := strconv.Atoi(settings["rrf_k"]); err == nil && v > 0 {
+		k = v
+	}
+	return mode, k
+}
@@ -0,0 +1,58 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBM25AndRRF(t *testing.T) {
+	docs := []string{"Error code E42: out of toner", "Shipping takes 3-5 business days", "Returns within 30 days"}
+	kw := bm25Scores("what does E42 mean", docs)
+	if kw[0] <= 0 || kw[1] != 0 || kw[2] != 0 {
+		t.Fatalf("unexpected BM25 scores %v", kw)
+	}
+	ranks := rankOf([]float64{0.2, 0.9, 0})
+	if ranks[0] != 2 || ranks[1] != 1 || ranks[2] != 0 {
+		t.Fatalf("unexpected ranks %v", ranks)
+	}
+	fused := fuseRRF(60, []int{1, 2}, []int{2, 1})
+	if fused[0] != fused[1] {
+		t.Fatalf("symmetric rankings should fuse equally: %v", fused)
+	}
+}
+
+func TestSQLiteHybridSearch_ExactKeyword(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "CustomerDocs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte("search:\n  mode: hybrid\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	docs := []string{
+		"Returns are accepted within 30 days of purchase.",
+		"SKU-88213 ships with a two year warranty.",
+		"Shipping takes 3-5 business days.",
+	}
+	if _, err := store.IngestDocuments(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(context.Background(), "warranty for SKU-88213", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Content != docs[1] {
+		t.Fatalf("expected SKU document first, got %+v", res)
+	}
+	if res[0].Metadata["search_mode"] != SearchModeHybrid {
+		t.Fatalf("expected hybrid metadata, got %v", res[0].Metadata)
+	}
+}
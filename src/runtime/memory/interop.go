@@ -0,0 +1,185 @@
+package runtimememory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats accepted by WriteInterop and ReadInterop.
+const (
+	// FormatMemSnap is the native gzip-compressed MemorySnapshot.
+	FormatMemSnap = "memsnap"
+	// FormatLangChain is JSON Lines of LangChain documents, one chunk per line:
+	// {"id", "page_content", "metadata", "embedding"}.
+	FormatLangChain = "langchain-jsonl"
+	// FormatLlamaIndex is JSON Lines of LlamaIndex TextNode dicts, one chunk
+	// per line, loadable with TextNode.from_dict.
+	FormatLlamaIndex = "llamaindex"
+)
+
+// InteropFormats lists the export/import formats in the order they are documented.
+var InteropFormats = []string{FormatMemSnap, FormatLangChain, FormatLlamaIndex}
+
+// Metadata keys written on every exported chunk. ReadInterop restores the
+// source and embedding model from them; other metadata is not kept.
+const (
+	metaSource         = "source"
+	metaChunkID        = "chunk_id"
+	metaComponent      = "component"
+	metaTenantID       = "tenant_id"
+	metaEmbeddingModel = "embedding_model"
+	metaMemoryVersion  = "memory_version"
+)
+
+// langChainDocument is the shape of langchain_core.documents.Document plus
+// the chunk embedding.
+type langChainDocument struct {
+	ID          string         `json:"id,omitempty"`
+	PageContent string         `json:"page_content"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	Embedding   []float64      `json:"embedding,omitempty"`
+	Type        string         `json:"type,omitempty"`
+}
+
+// llamaIndexNode is the dict form of llama_index.core.schema.TextNode.
+type llamaIndexNode struct {
+	ID            string                        `json:"id_"`
+	Text          string                        `json:"text"`
+	Metadata      map[string]any                `json:"metadata,omitempty"`
+	Embedding     []float64                     `json:"embedding,omitempty"`
+	Relationships map[string]llamaIndexRelation `json:"relationships,omitempty"`
+	ClassName     string                        `json:"class_name,omitempty"`
+}
+
+type llamaIndexRelation struct {
+	NodeID    string `json:"node_id"`
+	ClassName string `json:"class_name,omitempty"`
+}
+
+// llamaIndexSource is the NodeRelationship.SOURCE key, linking a node to the
+// document it was parsed from (its ref_doc_id).
+const llamaIndexSource = "1"
+
+// WriteInterop encodes snap to w in format.
+func WriteInterop(w io.Writer, snap *MemorySnapshot, format string) error {
+	if format == FormatMemSnap {
+		return WriteMemorySnapshot(w, snap)
+	}
+	if format != FormatLangChain && format != FormatLlamaIndex {
+		return unknownFormat(format)
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, c := range snap.Chunks {
+		meta := map[string]any{
+			metaChunkID:        c.ID,
+			metaComponent:      snap.Component,
+			metaEmbeddingModel: snap.EmbeddingModel,
+		}
+		if c.Source != "" {
+			meta[metaSource] = c.Source
+		}
+		if snap.TenantID != "" {
+			meta[metaTenantID] = snap.TenantID
+		}
+		if snap.Version != "" {
+			meta[metaMemoryVersion] = snap.Version
+		}
+		var rec any
+		if format == FormatLangChain {
+			rec = langChainDocument{ID: c.ID, PageContent: c.Content, Metadata: meta, Embedding: c.Vector, Type: "Document"}
+		} else {
+			node := llamaIndexNode{ID: c.ID, Text: c.Content, Metadata: meta, Embedding: c.Vector, ClassName: "TextNode"}
+			if c.Source != "" {
+				node.Relationships = map[string]llamaIndexRelation{llamaIndexSource: {NodeID: c.Source, ClassName: "RelatedNodeInfo"}}
+			}
+			rec = node
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadInterop decodes a file written in format by WriteInterop or by the
+// matching Python library. Chunks without an id are numbered by line; the
+// embedding model is taken from the first chunk's metadata and the
+// dimensions from its embedding, so files without embeddings can only be
+// imported by re-embedding them.
+func ReadInterop(r io.Reader, format string) (*MemorySnapshot, error) {
+	if format == FormatMemSnap {
+		return ReadMemorySnapshot(r)
+	}
+	if format != FormatLangChain && format != FormatLlamaIndex {
+		return nil, unknownFormat(format)
+	}
+	snap := &MemorySnapshot{Format: MemorySnapshotFormat}
+	docs := map[string]bool{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var (
+			c    SnapshotChunk
+			meta map[string]any
+		)
+		if format == FormatLangChain {
+			var d langChainDocument
+			if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			c = SnapshotChunk{ID: d.ID, Content: d.PageContent, Vector: d.Embedding}
+			meta = d.Metadata
+		} else {
+			var n llamaIndexNode
+			if err := json.Unmarshal(sc.Bytes(), &n); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			c = SnapshotChunk{ID: n.ID, Content: n.Text, Vector: n.Embedding}
+			c.Source = n.Relationships[llamaIndexSource].NodeID
+			meta = n.Metadata
+		}
+		if s, ok := meta[metaSource].(string); ok && c.Source == "" {
+			c.Source = s
+		}
+		if c.ID == "" {
+			if id, ok := meta[metaChunkID].(string); ok {
+				c.ID = id
+			} else {
+				c.ID = fmt.Sprintf("chunk-%d", line)
+			}
+		}
+		if len(snap.Chunks) == 0 {
+			snap.Component, _ = meta[metaComponent].(string)
+			snap.TenantID, _ = meta[metaTenantID].(string)
+			snap.EmbeddingModel, _ = meta[metaEmbeddingModel].(string)
+			snap.Dimensions = len(c.Vector)
+		}
+		snap.Chunks = append(snap.Chunks, c)
+		if c.Source != "" && !docs[c.Source] {
+			docs[c.Source] = true
+			snap.Documents = append(snap.Documents, c.Source)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(snap.Chunks) == 0 {
+		return nil, fmt.Errorf("no chunks in %s file", format)
+	}
+	sort.Strings(snap.Documents)
+	return snap, nil
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown memory format %q (want %s)", format, strings.Join(InteropFormats, ", "))
+}
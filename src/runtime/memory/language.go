@@ -0,0 +1,171 @@
+package runtimememory
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// languageSample caps how much of a text is inspected by DetectLanguage.
+const languageSample = 4096
+
+// scriptLanguages maps scripts used by a single major language to its ISO 639-1
+// code. Han is checked after kana so Japanese text is not reported as Chinese.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// stopwords are frequent function words of languages written in Latin script.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "for", "with", "that", "this", "it", "you", "be", "on", "not", "have", "was", "what", "how", "can", "do", "does", "your"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "für", "den", "dem", "von", "zu", "auf", "sind", "wie", "ich", "sie", "es", "wir", "auch", "werden", "kann", "oder"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "du", "en", "pour", "que", "qui", "dans", "pas", "sur", "avec", "vous", "nous", "ce", "sont", "au", "être", "peut"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "de", "del", "que", "en", "por", "para", "con", "no", "se", "su", "como", "está", "son", "puede", "al", "lo"},
+	"it": {"il", "lo", "la", "gli", "le", "e", "è", "un", "una", "di", "del", "che", "per", "con", "non", "sono", "come", "della", "nel", "si", "può", "anche", "al"},
+	"pt": {"o", "a", "os", "as", "e", "é", "um", "uma", "de", "do", "da", "que", "em", "para", "com", "não", "se", "são", "como", "pode", "dos", "das", "no", "na"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "op", "te", "met", "voor", "zijn", "die", "er", "ook", "wordt", "kan", "hoe", "wat", "ik", "je"},
+}
+
+// stopwordLanguages indexes stopwords by word.
+var stopwordLanguages = func() map[string][]string {
+	idx := map[string][]string{}
+	for lang, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// DetectLanguage returns the ISO 639-1 code of the language text is written in,
+// or "" when it cannot be told (too short, mixed or unsupported). Text in a
+// script used by a single language is identified by script; Latin-script text
+// by its most frequent stopwords, which must occur at least twice and more often
+// than those of any other language.
+func DetectLanguage(text string) string {
+	if len(text) > languageSample {
+		text = text[:languageSample]
+	}
+	scripts := map[string]int{}
+	latin, letters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	if latin*2 < letters {
+		// kana alongside kanji is Japanese whatever the proportion
+		if scripts["ja"] > 0 {
+			return "ja"
+		}
+		best, n := "", 0
+		for _, s := range scriptLanguages {
+			if scripts[s.lang] > n {
+				best, n = s.lang, scripts[s.lang]
+			}
+		}
+		return best
+	}
+	scores := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for _, lang := range stopwordLanguages[w] {
+			scores[lang]++
+		}
+	}
+	langs := make([]string, 0, len(scores))
+	for lang := range scores {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if scores[langs[i]] != scores[langs[j]] {
+			return scores[langs[i]] > scores[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	if len(langs) == 0 || scores[langs[0]] < 2 || (len(langs) > 1 && scores[langs[1]] == scores[langs[0]]) {
+		return ""
+	}
+	return langs[0]
+}
+
+// embeddingRoutes chooses the embedding model of a chunk or query by its
+// language (memory_config.yaml: embedding_model.language, .multilingual and
+// .languages). Text in the default model's language, or of unknown language,
+// uses the default model.
+type embeddingRoutes struct {
+	model        string
+	language     string
+	multilingual string
+	languages    map[string]string
+}
+
+// DefaultEmbeddingLanguage is the language the default embedding model is
+// assumed to be trained for.
+const DefaultEmbeddingLanguage = "en"
+
+// embeddingRoutesFromSettings reads embedding_language, embedding_multilingual
+// and embedding_languages ("de=model,ja=model") merged by LoadComponentMemoryConfig.
+func embeddingRoutesFromSettings(settings map[string]string, model string) embeddingRoutes {
+	r := embeddingRoutes{model: model, language: settings["embedding_language"], multilingual: settings["embedding_multilingual"]}
+	if r.language == "" {
+		r.language = DefaultEmbeddingLanguage
+	}
+	for _, pair := range strings.Split(settings["embedding_languages"], ",") {
+		lang, m, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(lang) == "" || strings.TrimSpace(m) == "" {
+			continue
+		}
+		if r.languages == nil {
+			r.languages = map[string]string{}
+		}
+		r.languages[strings.TrimSpace(lang)] = strings.TrimSpace(m)
+	}
+	return r
+}
+
+// routed reports whether any language is embedded with another model.
+func (r embeddingRoutes) routed() bool {
+	return r.multilingual != "" || len(r.languages) > 0
+}
+
+// modelFor returns the embedding model for text in lang.
+func (r embeddingRoutes) modelFor(lang string) string {
+	if lang == "" || lang == r.language {
+		return r.model
+	}
+	if m, ok := r.languages[lang]; ok {
+		return m
+	}
+	if r.multilingual != "" {
+		return r.multilingual
+	}
+	return r.model
+}
@@ -0,0 +1,96 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"How long does shipping take to your country?":            "en",
+		"Wie lange dauert der Versand und ist er kostenlos?":      "de",
+		"Les retours sont acceptés pendant 30 jours avec le reçu": "fr",
+		"¿Cuánto tarda el envío de los pedidos a España?":         "es",
+		"De retourtermijn is dertig dagen en het is gratis":       "nl",
+		"配送には通常3日かかります":                                           "ja",
+		"退货需要在三十天内完成":                                             "zh",
+		"Доставка занимает три дня":                               "ru",
+		"refunds":  "",
+		"12345 !!": "",
+	}
+	for text, want := range cases {
+		if got := DetectLanguage(text); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestEmbeddingRoutes(t *testing.T) {
+	r := embeddingRoutesFromSettings(map[string]string{
+		"embedding_multilingual": "paraphrase-multilingual-MiniLM-L12-v2",
+		"embedding_languages":    "ja=multilingual-e5-small, bad",
+	}, "all-MiniLM-L6-v2")
+	for lang, want := range map[string]string{
+		"":   "all-MiniLM-L6-v2",
+		"en": "all-MiniLM-L6-v2",
+		"de": "paraphrase-multilingual-MiniLM-L12-v2",
+		"ja": "multilingual-e5-small",
+	} {
+		if got := r.modelFor(lang); got != want {
+			t.Errorf("modelFor(%q) = %q, want %q", lang, got, want)
+		}
+	}
+	if plain := embeddingRoutesFromSettings(nil, "m"); plain.routed() || plain.modelFor("de") != "m" {
+		t.Fatalf("unrouted config should always use the default model: %+v", plain)
+	}
+}
+
+func TestSQLiteStore_RoutesChunksByLanguage(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Support")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "embedding_model:\n  name: all-MiniLM-L6-v2\n  multilingual: paraphrase-multilingual-MiniLM-L12-v2\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Provider: "sqlite", RootDir: root, ComponentName: "Support"}
+	if err := LoadComponentMemoryConfig(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	sources := []Source{
+		{Path: "en.md", Content: "Refunds are issued within 14 days of the return."},
+		{Path: "de.md", Content: "Die Rückerstattung erfolgt innerhalb von 14 Tagen nach der Rücksendung."},
+	}
+	if _, err := store.(SourceStore).SyncSources(context.Background(), sources); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.Search(context.Background(), "Wie lange dauert die Rückerstattung und ist sie kostenlos?", 2)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Search = %v, %v", results, err)
+	}
+	models := map[string]interface{}{}
+	for _, r := range results {
+		if r.Metadata["query_language"] != "de" {
+			t.Fatalf("expected the query language on results, got %v", r.Metadata)
+		}
+		models[r.Metadata["language"].(string)] = r.Metadata["embedding_model"]
+	}
+	if models["en"] != "all-MiniLM-L6-v2" || models["de"] != "paraphrase-multilingual-MiniLM-L12-v2" {
+		t.Fatalf("unexpected routing %v", models)
+	}
+
+	// unchanged chunks keep their routed embeddings on the next sync
+	res, err := store.(SourceStore).SyncSources(context.Background(), sources)
+	if err != nil || res.ChunksReused != 2 || res.ChunksEmbedded != 0 {
+		t.Fatalf("resync = %+v, %v", res, err)
+	}
+}
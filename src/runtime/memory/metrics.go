@@ -0,0 +1,111 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus metrics for memory index health. The server registers them;
+// the index gauges are refreshed on every ingest and search.
+var (
+	IndexDocuments = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_memory_documents",
+		Help: "Documents in the memory store by component and tenant.",
+	}, []string{"component", "tenant"})
+	IndexChunks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_memory_chunks",
+		Help: "Chunks in the memory store by component and tenant.",
+	}, []string{"component", "tenant"})
+	StoreBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_memory_store_bytes",
+		Help: "Size of the memory store file by component and tenant.",
+	}, []string{"component", "tenant"})
+	IngestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_memory_ingest_duration_seconds",
+		Help:    "Duration of memory ingestion by component.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"component"})
+	IngestBatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_memory_ingest_batch_size",
+		Help:    "Texts per embedding batch during ingestion, by component.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"component"})
+	EmbeddingLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_memory_embedding_latency_seconds",
+		Help:    "Latency of embedding calls by provider and model.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider", "model"})
+	SearchDepth = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_memory_search_depth",
+		Help:    "Results returned per memory search by component.",
+		Buckets: []float64{0, 1, 2, 3, 5, 10, 20, 50},
+	}, []string{"component"})
+	DuplicateChunks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_memory_duplicate_chunks_total",
+		Help: "Duplicate chunks found during ingestion, by component and kind (exact or near).",
+	}, []string{"component", "kind"})
+	RerankFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_memory_rerank_fallbacks_total",
+		Help: "Searches served in retrieval order because the reranker failed, by component and rerank provider.",
+	}, []string{"component", "provider"})
+	ConversationSummaries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_memory_conversation_summaries_total",
+		Help: "Conversation summaries written for long sessions, by component.",
+	}, []string{"component"})
+	ConversationConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_memory_conversation_conflicts_total",
+		Help: "Conversation turns appended after another process changed the session, by component.",
+	}, []string{"component"})
+
+	// EpisodicPruned is registered by the worker, which runs retention.
+	EpisodicPruned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_memory_episodic_pruned_total",
+		Help: "Episodic memory entries removed by retention, by component and reason (age or size).",
+	}, []string{"component", "reason"})
+)
+
+// instrumentEmbed wraps embed to record batch sizes and embedding latency.
+func instrumentEmbed(embed embedFunc, component, model string) embedFunc {
+	provider := embedProvider(model)
+	if model == "" {
+		model = "default"
+	}
+	return func(ctx context.Context, texts []string) ([][]float64, error) {
+		start := time.Now()
+		vecs, err := embed(ctx, texts)
+		EmbeddingLatency.WithLabelValues(provider, model).Observe(time.Since(start).Seconds())
+		IngestBatchSize.WithLabelValues(component).Observe(float64(len(texts)))
+		return vecs, err
+	}
+}
+
+func observeIngest(component string, start time.Time) {
+	IngestDuration.WithLabelValues(component).Observe(time.Since(start).Seconds())
+}
+
+// observeIndex updates the index gauges from the store's records.
+func (s *sqliteVectorStore) observeIndex(chunks int, sources map[string]bool, unsourced int) {
+	IndexChunks.WithLabelValues(s.component, s.tenantID).Set(float64(chunks))
+	IndexDocuments.WithLabelValues(s.component, s.tenantID).Set(float64(len(sources) + unsourced))
+	if info, err := os.Stat(s.filePath); err == nil {
+		StoreBytes.WithLabelValues(s.component, s.tenantID).Set(float64(info.Size()))
+	}
+}
+
+// observeRecords updates the index gauges after records were written. Records
+// ingested without a source path count as one document each.
+func (s *sqliteVectorStore) observeRecords(records []vecRecord) {
+	sources := map[string]bool{}
+	unsourced := 0
+	for _, rec := range records {
+		if rec.Source == "" {
+			unsourced++
+			continue
+		}
+		sources[rec.Source] = true
+	}
+	s.observeIndex(len(records), sources, unsourced)
+}
@@ -0,0 +1,40 @@
+package runtimememory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSQLiteVectorStore_RecordsIndexMetrics(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "MetricsDocs", TenantID: "acme", EmbeddingModel: "text-embedding-3-small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ss := store.(SourceStore)
+	if _, err := ss.UpdateSources(context.Background(), []Source{{Path: "a.md", Content: "Returns are accepted within 30 days."}, {Path: "b.md", Content: "Shipping takes 3-5 business days."}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(IndexDocuments.WithLabelValues("MetricsDocs", "acme")); got != 2 {
+		t.Fatalf("documents = %v", got)
+	}
+	if got := testutil.ToFloat64(IndexChunks.WithLabelValues("MetricsDocs", "acme")); got != 2 {
+		t.Fatalf("chunks = %v", got)
+	}
+	if got := testutil.ToFloat64(StoreBytes.WithLabelValues("MetricsDocs", "acme")); got <= 0 {
+		t.Fatalf("store bytes = %v", got)
+	}
+	if testutil.CollectAndCount(IngestBatchSize) == 0 || testutil.CollectAndCount(EmbeddingLatency) == 0 || testutil.CollectAndCount(IngestDuration) == 0 {
+		t.Fatal("expected ingest and embedding observations")
+	}
+
+	if _, err := store.Search(context.Background(), "returns", 1); err != nil {
+		t.Fatal(err)
+	}
+	if testutil.CollectAndCount(SearchDepth) == 0 {
+		t.Fatal("expected a search depth observation")
+	}
+}
@@ -0,0 +1,259 @@
+package runtimememory
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Multi-query modes (memory_config.yaml: multi_query.mode).
+const (
+	MultiQueryHeuristic = "heuristic"
+	MultiQueryProvider  = "provider"
+)
+
+// ParaphraseFunc sends a prompt to the component's model and returns its reply.
+type ParaphraseFunc func(ctx context.Context, prompt string) (string, error)
+
+// MultiQueryConfig configures query expansion (memory_config.yaml: multi_query).
+// Search runs the query and up to Queries paraphrases of it, and merges the
+// results. Paraphrases still running MaxExtraLatency after the original query
+// returned are dropped.
+type MultiQueryConfig struct {
+	Mode            string // heuristic (default) or provider
+	Queries         int    // paraphrases searched besides the query (default 3)
+	MaxExtraLatency time.Duration
+}
+
+// Defaults for MultiQueryConfig.
+const (
+	defaultMultiQueries         = 3
+	defaultMultiQueryExtraDelay = 300 * time.Millisecond
+)
+
+// multiQueryConfigFromSettings reads multi_query_* keys merged by
+// LoadComponentMemoryConfig; ok is false when expansion is off.
+func multiQueryConfigFromSettings(settings map[string]string) (cfg MultiQueryConfig, ok bool, err error) {
+	if settings["multi_query_enabled"] != "true" {
+		return cfg, false, nil
+	}
+	cfg = MultiQueryConfig{Mode: strings.ToLower(settings["multi_query_mode"]), Queries: defaultMultiQueries, MaxExtraLatency: defaultMultiQueryExtraDelay}
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = MultiQueryHeuristic
+	case MultiQueryHeuristic, MultiQueryProvider:
+	default:
+		return cfg, false, fmt.Errorf("unsupported multi_query mode: %s", cfg.Mode)
+	}
+	if n, err := strconv.Atoi(settings["multi_query_queries"]); err == nil && n > 0 {
+		cfg.Queries = n
+	}
+	if v := settings["multi_query_max_extra_latency"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, false, fmt.Errorf("invalid multi_query max_extra_latency: %w", err)
+		}
+		cfg.MaxExtraLatency = d
+	}
+	return cfg, true, nil
+}
+
+// multiQueryStore decorates a MemoryStore so Search also searches paraphrases
+// of the query and merges the results, keeping each chunk's best score.
+type multiQueryStore struct {
+	MemoryStore
+	cfg        MultiQueryConfig
+	paraphrase ParaphraseFunc
+}
+
+// Unwrap returns the underlying store.
+func (s *multiQueryStore) Unwrap() MemoryStore { return s.MemoryStore }
+
+type searchOutcome struct {
+	results []SearchResult
+	err     error
+}
+
+func (s *multiQueryStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	// Paraphrasing and the extra searches overlap the original search
+	ectx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	extra := make(chan searchOutcome, s.cfg.Queries)
+	launched := make(chan int, 1)
+	go func() {
+		variants := s.paraphrases(ectx, query)
+		launched <- len(variants)
+		for _, v := range variants {
+			go func(v string) {
+				res, err := s.MemoryStore.Search(ectx, v, topK)
+				extra <- searchOutcome{res, err}
+			}(v)
+		}
+	}()
+	results, err := s.MemoryStore.Search(ctx, query, topK)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.NewTimer(s.cfg.MaxExtraLatency)
+	defer deadline.Stop()
+	lists := [][]SearchResult{results}
+	pending := -1
+	for pending != 0 {
+		select {
+		case n := <-launched:
+			pending += n + 1
+		case out := <-extra:
+			pending--
+			// A failed paraphrase only loses its extra recall
+			if out.err == nil {
+				lists = append(lists, out.results)
+			}
+		case <-deadline.C:
+			pending = 0
+		}
+	}
+	return mergeResults(lists, topK), nil
+}
+
+// mergeResults deduplicates results by ID, or by content for stores without
+// IDs, keeps the best score of each and returns the top n by score.
+func mergeResults(lists [][]SearchResult, n int) []SearchResult {
+	index := map[string]int{}
+	var out []SearchResult
+	for _, list := range lists {
+		for _, res := range list {
+			key := res.ID
+			if key == "" {
+				key = "\x00" + res.Content
+			}
+			if i, ok := index[key]; ok {
+				if res.Score > out[i].Score {
+					out[i] = res
+				}
+				continue
+			}
+			index[key] = len(out)
+			out = append(out, res)
+		}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Score > out[b].Score })
+	return truncateResults(out, n)
+}
+
+// paraphrases returns up to cfg.Queries rewrites of query that differ from it.
+// Provider mode falls back to the heuristics when the model is unavailable.
+func (s *multiQueryStore) paraphrases(ctx context.Context, query string) []string {
+	var variants []string
+	if s.cfg.Mode == MultiQueryProvider && s.paraphrase != nil {
+		reply, err := s.paraphrase(ctx, paraphrasePrompt(query, s.cfg.Queries))
+		if err == nil {
+			variants = parseParaphrases(reply)
+		}
+	}
+	if len(variants) == 0 {
+		variants = heuristicParaphrases(query)
+	}
+	seen := map[string]bool{normalizeQuery(query): true}
+	out := make([]string, 0, s.cfg.Queries)
+	for _, v := range variants {
+		key := normalizeQuery(v)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, v)
+		if len(out) == s.cfg.Queries {
+			break
+		}
+	}
+	return out
+}
+
+func paraphrasePrompt(query string, n int) string {
+	return fmt.Sprintf("Rewrite the question below as %d different search queries that would find documents answering it. "+
+		"Use other words and spell out what a vague question is likely asking. Reply with one query per line and nothing else.\n\nQuestion: %s\n", n, query)
+}
+
+// listMarker matches a bullet or number starting a line of a model reply.
+var listMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// parseParaphrases reads one query per line, dropping list markers and quotes.
+func parseParaphrases(reply string) []string {
+	var out []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = listMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		if line = strings.TrimSpace(strings.Trim(line, `"'`)); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// queryStopwords are dropped from heuristic paraphrases besides the language
+// stopwords: English question words, auxiliaries, pronouns and fillers that
+// carry no topic.
+var queryStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "are": true, "was": true, "were": true, "be": true, "do": true, "does": true,
+	"did": true, "can": true, "could": true, "should": true, "would": true, "will": true, "i": true, "me": true, "my": true,
+	"we": true, "our": true, "you": true, "your": true, "it": true, "its": true, "this": true, "that": true, "there": true,
+	"what": true, "which": true, "who": true, "how": true, "why": true, "when": true, "where": true, "to": true, "of": true,
+	"for": true, "in": true, "on": true, "at": true, "with": true, "about": true, "and": true, "or": true, "if": true,
+	"any": true, "some": true, "please": true, "tell": true, "know": true, "get": true, "have": true, "has": true, "so": true,
+}
+
+// heuristicParaphrases rewrites query without a model: its keywords, their
+// stems, and its two longest keywords as a short query.
+func heuristicParaphrases(query string) []string {
+	var keywords []string
+	for _, w := range tokenize(query) {
+		if !queryStopwords[w] && stopwordLanguages[w] == nil {
+			keywords = append(keywords, w)
+		}
+	}
+	if len(keywords) == 0 {
+		return nil
+	}
+	stems := make([]string, len(keywords))
+	for i, w := range keywords {
+		stems[i] = stemWord(w)
+	}
+	longest := append([]string(nil), keywords...)
+	sort.SliceStable(longest, func(a, b int) bool { return len(longest[a]) > len(longest[b]) })
+	if len(longest) > 2 {
+		longest = longest[:2]
+	}
+	return []string{strings.Join(keywords, " "), strings.Join(stems, " "), strings.Join(longest, " ")}
+}
+
+// stemWord strips a common English inflection from w.
+func stemWord(w string) string {
+	if strings.HasSuffix(w, "ss") {
+		return w
+	}
+	for _, suffix := range []string{"ing", "ies", "ed", "s"} {
+		if strings.HasSuffix(w, suffix) && len(w)-len(suffix) >= 3 {
+			stem := w[:len(w)-len(suffix)]
+			switch n := len(stem); {
+			case suffix == "ies":
+				return stem + "y"
+			case suffix != "s" && stem[n-1] == stem[n-2] && !strings.ContainsRune("lsz", rune(stem[n-1])):
+				// running -> run, stopped -> stop
+				return stem[:n-1]
+			}
+			return stem
+		}
+	}
+	return w
+}
+
+// normalizeQuery is the form two queries are compared in.
+func normalizeQuery(q string) string {
+	return strings.Join(tokenize(q), " ")
+}
@@ -0,0 +1,124 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedStore answers Search from a table of results per query.
+type scriptedStore struct {
+	MemoryStore
+	mu      sync.Mutex
+	results map[string][]SearchResult
+	delay   map[string]time.Duration
+	queries []string
+}
+
+func (s *scriptedStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	s.mu.Unlock()
+	select {
+	case <-time.After(s.delay[query]):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return truncateResults(s.results[query], topK), nil
+}
+
+func TestMultiQueryStore_MergesParaphraseResults(t *testing.T) {
+	inner := &scriptedStore{
+		results: map[string][]SearchResult{
+			"how do I send my shoes back?": {{ID: "a", Content: "Contact support.", Score: 0.3}},
+			"send shoes back":              {{ID: "b", Content: "Returns are accepted within 30 days.", Score: 0.8}, {ID: "a", Content: "Contact support.", Score: 0.5}},
+			"return policy for shoes":      {{ID: "b", Content: "Returns are accepted within 30 days.", Score: 0.9}, {ID: "c", Content: "Shoes ship free.", Score: 0.4}},
+		},
+		delay: map[string]time.Duration{"shipping slow": time.Second},
+	}
+	var prompt string
+	store := &multiQueryStore{MemoryStore: inner, cfg: MultiQueryConfig{Mode: MultiQueryProvider, Queries: 3, MaxExtraLatency: 200 * time.Millisecond},
+		paraphrase: func(ctx context.Context, p string) (string, error) {
+			prompt = p
+			return "1. return policy for shoes\n- \"send shoes back\"\nshipping slow\nreturn policy for shoes\n", nil
+		}}
+
+	start := time.Now()
+	res, err := store.Search(context.Background(), "how do I send my shoes back?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 800*time.Millisecond {
+		t.Fatalf("expected the slow paraphrase to be dropped, took %v", elapsed)
+	}
+	if !strings.Contains(prompt, "3 different search queries") || !strings.Contains(prompt, "shoes back?") {
+		t.Fatalf("unexpected prompt %q", prompt)
+	}
+	if len(res) != 2 || res[0].ID != "b" || res[0].Score != 0.9 || res[1].ID != "a" || res[1].Score != 0.5 {
+		t.Fatalf("merged results = %+v", res)
+	}
+	// The dropped "shipping slow" search may still be running, so only the
+	// searches whose results were merged are certain to have been recorded
+	inner.mu.Lock()
+	searched := strings.Join(inner.queries, "|")
+	inner.mu.Unlock()
+	for _, q := range []string{"how do I send my shoes back?", "send shoes back", "return policy for shoes"} {
+		if !strings.Contains(searched, q) {
+			t.Fatalf("expected %q to be searched, got %q", q, searched)
+		}
+	}
+}
+
+func TestHeuristicParaphrases(t *testing.T) {
+	got := heuristicParaphrases("How can I return my running shoes?")
+	if strings.Join(got, "|") != "return running shoes|return run shoe|running return" {
+		t.Fatalf("heuristicParaphrases = %q", got)
+	}
+	if got := heuristicParaphrases("what is it?"); got != nil {
+		t.Fatalf("expected no keywords, got %q", got)
+	}
+	// Without a model the provider mode falls back to the heuristics, skipping duplicates
+	s := &multiQueryStore{cfg: MultiQueryConfig{Mode: MultiQueryProvider, Queries: 3}}
+	if got := s.paraphrases(context.Background(), "return shoes"); strings.Join(got, "|") != "return shoe" {
+		t.Fatalf("paraphrases = %q", got)
+	}
+}
+
+func TestNewStore_MultiQueryConfig(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "CustomerDocs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(yml string) {
+		if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(yml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("multi_query:\n  enabled: true\n  queries: 2\n  max_extra_latency: 150\n")
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	mq, ok := store.(*multiQueryStore)
+	if !ok || mq.cfg != (MultiQueryConfig{Mode: MultiQueryHeuristic, Queries: 2, MaxExtraLatency: 150 * time.Millisecond}) {
+		t.Fatalf("store = %#v", store)
+	}
+	docs := []string{"Returns are accepted within 30 days.", "Shipping takes 3-5 business days."}
+	if _, err := store.IngestDocuments(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := store.Search(context.Background(), "Can I return things?", 1); err != nil || len(res) != 1 {
+		t.Fatalf("Search = %+v, %v", res, err)
+	}
+
+	write("multi_query:\n  enabled: true\n  mode: oracle\n")
+	if _, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs"}); err == nil {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+}
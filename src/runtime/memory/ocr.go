@@ -0,0 +1,218 @@
+package runtimememory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OCRConfig configures text recognition for scanned documents
+// (memory_config.yaml: document_processing.ocr). Images and PDFs without a text
+// layer are recognized with tesseract; PDF pages are rasterized with pdftoppm.
+type OCRConfig struct {
+	Enabled bool
+	// Languages are tesseract language codes joined by "+", e.g. "eng+deu".
+	Languages string
+	// MinConfidence drops recognized words below this confidence (0-100).
+	MinConfidence float64
+	// DPI is the resolution PDF pages are rasterized at.
+	DPI int
+}
+
+// Defaults for OCRConfig fields left unset.
+const (
+	DefaultOCRLanguages = "eng"
+	DefaultOCRDPI       = 300
+)
+
+// ocrConfigFromSettings reads ocr_* keys merged by LoadComponentMemoryConfig.
+func ocrConfigFromSettings(settings map[string]string) OCRConfig {
+	c := OCRConfig{Enabled: settings["ocr_enabled"] == "true", Languages: settings["ocr_languages"]}
+	c.MinConfidence, _ = strconv.ParseFloat(settings["ocr_min_confidence"], 64)
+	c.DPI, _ = strconv.Atoi(settings["ocr_dpi"])
+	return c
+}
+
+// ComponentOCRConfig returns the OCR settings of a component's memory_config.yaml.
+func ComponentOCRConfig(root, component string) OCRConfig {
+	cfg := Config{RootDir: root, ComponentName: component}
+	_ = LoadComponentMemoryConfig(&cfg)
+	return ocrConfigFromSettings(cfg.Settings)
+}
+
+func (c OCRConfig) languages() string {
+	if c.Languages == "" {
+		return DefaultOCRLanguages
+	}
+	return c.Languages
+}
+
+func (c OCRConfig) dpi() int {
+	if c.DPI <= 0 {
+		return DefaultOCRDPI
+	}
+	return c.DPI
+}
+
+// ocrBin returns the path of an OCR tool: CMP_TESSERACT_BIN or CMP_PDFTOPPM_BIN
+// when set, otherwise the tool on PATH.
+func ocrBin(name, env string) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return v, nil
+	}
+	return exec.LookPath(name)
+}
+
+// ocrResult is recognized text with the metadata recorded on its chunks.
+type ocrResult struct {
+	text    string
+	words   int
+	dropped int
+	confSum float64
+}
+
+func (r *ocrResult) add(o ocrResult) {
+	if o.text != "" {
+		if r.text != "" {
+			r.text += "\n\n"
+		}
+		r.text += o.text
+	}
+	r.words += o.words
+	r.dropped += o.dropped
+	r.confSum += o.confSum
+}
+
+// metadata describes the recognition for search results.
+func (r ocrResult) metadata(c OCRConfig) map[string]interface{} {
+	conf := 0.0
+	if r.words > 0 {
+		conf = math.Round(r.confSum/float64(r.words)*10) / 10
+	}
+	return map[string]interface{}{
+		"ocr":                true,
+		"ocr_languages":      c.languages(),
+		"ocr_confidence":     conf,
+		"ocr_min_confidence": c.MinConfidence,
+		"ocr_words_dropped":  r.dropped,
+	}
+}
+
+// ocrImage recognizes the text of an image file.
+func ocrImage(path string, c OCRConfig) (ocrResult, error) {
+	bin, err := ocrBin("tesseract", "CMP_TESSERACT_BIN")
+	if err != nil {
+		return ocrResult{}, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(bin, path, "stdout", "-l", c.languages(), "tsv")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return ocrResult{}, fmt.Errorf("tesseract %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTesseractTSV(out, c.MinConfidence), nil
+}
+
+// ocrPDF rasterizes the pages of a PDF and recognizes them in order.
+func ocrPDF(path string, c OCRConfig) (ocrResult, error) {
+	bin, err := ocrBin("pdftoppm", "CMP_PDFTOPPM_BIN")
+	if err != nil {
+		return ocrResult{}, err
+	}
+	dir, err := os.MkdirTemp("", "cmp-ocr-")
+	if err != nil {
+		return ocrResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	if out, err := exec.Command(bin, "-r", strconv.Itoa(c.dpi()), "-png", path, filepath.Join(dir, "page")).CombinedOutput(); err != nil {
+		return ocrResult{}, fmt.Errorf("pdftoppm %s: %w: %s", filepath.Base(path), err, strings.TrimSpace(string(out)))
+	}
+	pages, err := filepath.Glob(filepath.Join(dir, "page*.png"))
+	if err != nil {
+		return ocrResult{}, err
+	}
+	// page-1.png … page-10.png: order by page number, not name
+	sort.Slice(pages, func(i, j int) bool {
+		return pageNumber(pages[i]) < pageNumber(pages[j])
+	})
+	var res ocrResult
+	for _, page := range pages {
+		r, err := ocrImage(page, c)
+		if err != nil {
+			return ocrResult{}, err
+		}
+		res.add(r)
+	}
+	return res, nil
+}
+
+func pageNumber(path string) int {
+	name := strings.TrimSuffix(filepath.Base(path), ".png")
+	n, _ := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	return n
+}
+
+// parseTesseractTSV rebuilds text from tesseract's TSV output, keeping words at
+// or above minConfidence. Lines are kept and paragraphs separated by a blank line.
+func parseTesseractTSV(tsv []byte, minConfidence float64) ocrResult {
+	var (
+		res        ocrResult
+		paragraphs []string
+		lines      []string
+		words      []string
+		para, line string
+	)
+	flushLine := func() {
+		if len(words) > 0 {
+			lines = append(lines, strings.Join(words, " "))
+			words = nil
+		}
+	}
+	flushPara := func() {
+		flushLine()
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+			lines = nil
+		}
+	}
+	sc := bufio.NewScanner(bytes.NewReader(tsv))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		// level page block par line word left top width height conf text
+		f := strings.Split(sc.Text(), "\t")
+		if len(f) < 12 || f[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(f[11])
+		conf, err := strconv.ParseFloat(f[10], 64)
+		if text == "" || err != nil || conf < 0 {
+			continue
+		}
+		if p := f[1] + "/" + f[2] + "/" + f[3]; p != para {
+			flushPara()
+			para = p
+		}
+		if l := para + "/" + f[4]; l != line {
+			flushLine()
+			line = l
+		}
+		if conf < minConfidence {
+			res.dropped++
+			continue
+		}
+		words = append(words, text)
+		res.words++
+		res.confSum += conf
+	}
+	flushPara()
+	res.text = strings.Join(paragraphs, "\n\n")
+	return res
+}
@@ -0,0 +1,89 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const scanTSV = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n" +
+	"1\t1\t0\t0\t0\t0\t0\t0\t800\t600\t-1\t\n" +
+	"5\t1\t1\t1\t1\t1\t10\t10\t50\t20\t96.5\tWarranty\n" +
+	"5\t1\t1\t1\t1\t2\t70\t10\t50\t20\t91.0\tterms\n" +
+	"5\t1\t1\t1\t2\t1\t10\t40\t50\t20\t88.0\tTwo\n" +
+	"5\t1\t1\t1\t2\t2\t70\t40\t50\t20\t12.0\t~#\n" +
+	"5\t1\t1\t1\t2\t3\t90\t40\t50\t20\t90.5\tyears\n" +
+	"5\t1\t2\t1\t1\t1\t10\t90\t50\t20\t93.0\tRepairs\n"
+
+func TestParseTesseractTSV_DropsLowConfidenceWords(t *testing.T) {
+	r := parseTesseractTSV([]byte(scanTSV), 60)
+	if r.text != "Warranty terms\nTwo years\n\nRepairs" || r.words != 5 || r.dropped != 1 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	meta := r.metadata(OCRConfig{Languages: "eng+deu", MinConfidence: 60})
+	if meta["ocr_confidence"] != 91.8 || meta["ocr_languages"] != "eng+deu" || meta["ocr_words_dropped"] != 1 {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+}
+
+func TestOCR_ImagesIngestedWithConfidenceMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tesseract is a shell script")
+	}
+	root := t.TempDir()
+	tsv := filepath.Join(root, "scan.tsv")
+	if err := os.WriteFile(tsv, []byte(scanTSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// tesseract <image> stdout -l <langs> tsv; the fake records its language argument
+	bin := filepath.Join(root, "tesseract")
+	script := "#!/bin/sh\necho \"$4\" > " + filepath.Join(root, "langs") + "\ncat " + tsv + "\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CMP_TESSERACT_BIN", bin)
+	docs := filepath.Join(root, "memory", "Scans", "documents")
+	if err := os.MkdirAll(docs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(docs, "warranty.png"), []byte("\x89PNG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, skipped, err := LoadSources(docs, []string{"warranty.png"}); err != nil || len(skipped) != 1 {
+		t.Fatalf("images need OCR enabled: skipped %v, %v", skipped, err)
+	}
+	yml := "document_processing:\n  ocr:\n    enabled: true\n    languages: [eng, deu]\n    min_confidence: 60\n"
+	if err := os.WriteFile(filepath.Join(root, "memory", "Scans", "memory_config.yaml"), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ocr := ComponentOCRConfig(root, "Scans")
+	if !ocr.Enabled || ocr.Languages != "eng+deu" || ocr.MinConfidence != 60 {
+		t.Fatalf("unexpected config %+v", ocr)
+	}
+	sources, _, err := LoadSourcesWith(docs, []string{"warranty.png"}, ReadOptions{OCR: ocr})
+	if err != nil || len(sources) != 1 || sources[0].Content != "Warranty terms\nTwo years\n\nRepairs" {
+		t.Fatalf("LoadSourcesWith = %+v, %v", sources, err)
+	}
+	if by, _ := os.ReadFile(filepath.Join(root, "langs")); string(by) != "eng+deu\n" {
+		t.Fatalf("tesseract languages = %q", by)
+	}
+
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Scans"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.(SourceStore).SyncSources(context.Background(), sources); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.Search(context.Background(), "warranty repairs", 1)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search = %v, %v", results, err)
+	}
+	if meta := results[0].Metadata; meta["ocr"] != true || meta["ocr_confidence"] != 91.8 || meta["ocr_min_confidence"] != 60.0 {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+}
@@ -0,0 +1,450 @@
+package runtimememory
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document formats understood by ReadSource.
+const (
+	FormatText  = "text" // plain text and Markdown; YAML front matter becomes metadata
+	FormatPDF   = "pdf"  // converted with pdftotext
+	FormatHTML  = "html"
+	FormatDOCX  = "docx"
+	FormatCSV   = "csv"   // one document per row
+	FormatJSONL = "jsonl" // one document per line
+	FormatImage = "image" // recognized with OCR when enabled
+)
+
+// documentFormats maps file extensions to their format.
+var documentFormats = map[string]string{
+	".txt": FormatText, ".md": FormatText, ".markdown": FormatText,
+	".pdf":  FormatPDF,
+	".html": FormatHTML, ".htm": FormatHTML,
+	".docx":  FormatDOCX,
+	".csv":   FormatCSV,
+	".jsonl": FormatJSONL, ".ndjson": FormatJSONL,
+	".png": FormatImage, ".jpg": FormatImage, ".jpeg": FormatImage, ".tif": FormatImage, ".tiff": FormatImage,
+}
+
+// DocumentExtensions lists the supported document extensions, sorted.
+func DocumentExtensions() []string {
+	exts := make([]string, 0, len(documentFormats))
+	for ext := range documentFormats {
+		exts = append(exts, strings.TrimPrefix(ext, "."))
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// DocumentFormat returns the format of a document file from its extension, or
+// "" when the extension is not supported.
+func DocumentFormat(name string) string {
+	return documentFormats[strings.ToLower(filepath.Ext(name))]
+}
+
+// ParseFormat validates a format name. Extensions are accepted too, so "md"
+// and "htm" name the text and HTML formats.
+func ParseFormat(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	switch name {
+	case FormatText, FormatPDF, FormatHTML, FormatDOCX, FormatCSV, FormatJSONL, FormatImage:
+		return name, nil
+	}
+	if f := documentFormats["."+name]; f != "" {
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported document format %q (text, pdf, html, docx, csv, jsonl, image)", name)
+}
+
+// ReadOptions controls how ReadSource converts a document.
+type ReadOptions struct {
+	// Format overrides the format of the file extension.
+	Format string
+	// OCR recognizes images and PDFs without a text layer when enabled.
+	OCR OCRConfig
+}
+
+// ReadSource reads the document at path. CSV and JSONL files yield one document
+// per row in Rows, and recognized documents carry OCR metadata. ok is false
+// when the document cannot be converted in this environment: pdftotext or the
+// OCR tools are missing, OCR is disabled for an image, or nothing was
+// recognized. The returned Source has no Path.
+func ReadSource(path string, opts ReadOptions) (src Source, ok bool, err error) {
+	format := opts.Format
+	if format == "" {
+		format = DocumentFormat(path)
+	}
+	switch format {
+	case FormatPDF:
+		bin, lookErr := exec.LookPath("pdftotext")
+		if lookErr != nil {
+			return src, false, nil
+		}
+		out, err := exec.Command(bin, "-layout", path, "-").Output()
+		if err != nil {
+			return src, false, nil
+		}
+		// Scanned PDFs have no text layer
+		if strings.TrimSpace(string(out)) == "" && opts.OCR.Enabled {
+			r, err := ocrPDF(path, opts.OCR)
+			return ocrSource(r, err, opts.OCR)
+		}
+		return Source{Content: string(out)}, true, nil
+	case FormatImage:
+		if !opts.OCR.Enabled {
+			return src, false, nil
+		}
+		r, err := ocrImage(path, opts.OCR)
+		return ocrSource(r, err, opts.OCR)
+	}
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return src, false, err
+	}
+	src, err = ParseDocument(format, by)
+	if err != nil {
+		return src, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return src, true, nil
+}
+
+// ocrSource turns a recognition into a source; failed or empty recognitions
+// are skipped like unconvertible PDFs.
+func ocrSource(r ocrResult, err error, c OCRConfig) (Source, bool, error) {
+	if err != nil || r.text == "" {
+		return Source{}, false, nil
+	}
+	return Source{Content: r.text, Metadata: r.metadata(c)}, true, nil
+}
+
+// ParseDocument converts the raw bytes of a document to text. The YAML front
+// matter of text and Markdown documents is returned as metadata. PDFs need a
+// file and are not supported here.
+func ParseDocument(format string, data []byte) (Source, error) {
+	var (
+		text string
+		rows []string
+		err  error
+	)
+	var meta map[string]interface{}
+	switch format {
+	case FormatText, "":
+		// Front matter (e.g. valid_until) becomes metadata of the document
+		text, meta = ParseFrontMatter(string(data))
+	case FormatHTML:
+		text, err = htmlText(data)
+	case FormatDOCX:
+		text, err = docxText(data)
+	case FormatCSV:
+		rows, err = csvRows(data)
+	case FormatJSONL:
+		rows, err = jsonlRows(data)
+	default:
+		err = fmt.Errorf("%s documents must be read from a file", format)
+	}
+	if err != nil {
+		return Source{}, err
+	}
+	if rows != nil {
+		text = strings.Join(rows, "\n\n")
+	}
+	return Source{Content: text, Rows: rows, Metadata: meta}, nil
+}
+
+// htmlBoilerplate are elements whose content is not part of the document text.
+var htmlBoilerplate = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Iframe: true, atom.Svg: true,
+}
+
+// htmlBlocks are elements that start a new line.
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Br: true, atom.Tr: true, atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Dt: true, atom.Dd: true, atom.Hr: true, atom.Figcaption: true,
+}
+
+// htmlText extracts the readable text of an HTML page. Navigation, headers,
+// footers, scripts and styles are dropped, and when the page has a <main> or
+// <article> element only its content is kept. Headings and list items keep
+// their Markdown markers.
+func htmlText(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	root := doc
+	if main := findHTML(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Main || n.DataAtom == atom.Article {
+			return true
+		}
+		for _, a := range n.Attr {
+			if a.Key == "role" && a.Val == "main" {
+				return true
+			}
+		}
+		return false
+	}); main != nil {
+		root = main
+	}
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		end := ""
+		switch n.Type {
+		case html.TextNode:
+			// Source line breaks are layout; lines come from block elements
+			sb.WriteString(strings.Map(func(r rune) rune {
+				if r == '\n' || r == '\r' {
+					return ' '
+				}
+				return r
+			}, n.Data))
+			return
+		case html.ElementNode:
+			if htmlBoilerplate[n.DataAtom] {
+				return
+			}
+			switch n.DataAtom {
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				sb.WriteString("\n\n" + strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+				end = "\n\n"
+			case atom.Li:
+				sb.WriteString("\n- ")
+			case atom.Td, atom.Th:
+				sb.WriteString(" ")
+			default:
+				if htmlBlocks[n.DataAtom] {
+					sb.WriteString("\n\n")
+					end = "\n\n"
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		sb.WriteString(end)
+	}
+	walk(root)
+	return tidyLines(sb.String()), nil
+}
+
+// findHTML returns the first node, in document order, matching match.
+func findHTML(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findHTML(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// tidyLines collapses runs of whitespace within lines and keeps at most one
+// blank line between paragraphs.
+func tidyLines(s string) string {
+	var out []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// docxText extracts the paragraphs of word/document.xml from a DOCX file.
+// Paragraphs styled as headings get Markdown heading markers.
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("not a docx file: %w", err)
+	}
+	var body io.ReadCloser
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			if body, err = f.Open(); err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+	if body == nil {
+		return "", fmt.Errorf("not a docx file: word/document.xml is missing")
+	}
+	defer body.Close()
+	var (
+		paragraphs []string
+		para       strings.Builder
+		heading    int
+		inText     bool
+	)
+	dec := xml.NewDecoder(body)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("read word/document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				heading = 0
+			case "pStyle":
+				for _, a := range t.Attr {
+					if a.Name.Local == "val" && strings.HasPrefix(strings.ToLower(a.Value), "heading") {
+						if n := strings.TrimLeft(a.Value[len("heading"):], " "); len(n) == 1 && n[0] >= '1' && n[0] <= '6' {
+							heading = int(n[0] - '0')
+						}
+					}
+				}
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if heading > 0 {
+					text = strings.Repeat("#", heading) + " " + text
+				}
+				paragraphs = append(paragraphs, text)
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+// csvRows turns each CSV record after the header into a document of
+// "column: value" lines. Empty values and empty records are left out.
+func csvRows(data []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows := []string{}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for i, v := range rec {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			name := fmt.Sprintf("column %d", i+1)
+			if i < len(header) && strings.TrimSpace(header[i]) != "" {
+				name = strings.TrimSpace(header[i])
+			}
+			lines = append(lines, name+": "+v)
+		}
+		if len(lines) > 0 {
+			rows = append(rows, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// jsonlRows turns each JSON line into a document. Objects become "key: value"
+// lines in key order, with nested values as JSON; strings are used as is.
+func jsonlRows(data []byte) ([]string, error) {
+	rows := []string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		var row string
+		switch v := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			var lines []string
+			for _, k := range keys {
+				if s := jsonValueText(v[k]); s != "" {
+					lines = append(lines, k+": "+s)
+				}
+			}
+			row = strings.Join(lines, "\n")
+		default:
+			row = jsonValueText(v)
+		}
+		if row != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows, sc.Err()
+}
+
+// jsonValueText renders a JSON value for a document: strings unquoted, null
+// empty, and anything else as compact JSON.
+func jsonValueText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	}
+	by, _ := json.Marshal(v)
+	return string(by)
+}
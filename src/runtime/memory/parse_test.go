@@ -0,0 +1,112 @@
+package runtimememory
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDocument_HTMLStripsBoilerplate(t *testing.T) {
+	page := `<html><head><title>Help</title><style>p{}</style></head><body>
+<nav><a href="/">Home</a></nav>
+<main><h2>Returns</h2><p>Returns are   accepted
+within 30 days.</p><ul><li>Keep the receipt</li><li>Use the original box</li></ul>
+<script>track()</script></main>
+<footer>Copyright ACME</footer></body></html>`
+	src, err := ParseDocument(FormatHTML, []byte(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## Returns\n\nReturns are accepted within 30 days.\n\n- Keep the receipt\n- Use the original box"
+	if src.Content != want {
+		t.Fatalf("html text = %q", src.Content)
+	}
+}
+
+func TestParseDocument_DOCX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("word/document.xml")
+	f.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Warranty</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Devices are covered </w:t></w:r><w:r><w:t>for two years.</w:t></w:r></w:p>
+<w:p></w:p>
+</w:body></w:document>`))
+	zw.Close()
+	src, err := ParseDocument(FormatDOCX, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Content != "# Warranty\n\nDevices are covered for two years." {
+		t.Fatalf("docx text = %q", src.Content)
+	}
+	if _, err := ParseDocument(FormatDOCX, []byte("plain text")); err == nil {
+		t.Fatal("expected an error for a file that is not a docx")
+	}
+}
+
+func TestParseDocument_RowsPerDocument(t *testing.T) {
+	src, err := ParseDocument(FormatCSV, []byte("\ufeffsku,name,notes\nA1,Kettle,\"Boils in 2 minutes, quietly\"\n,,\nB2,Toaster\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(src.Rows) != 2 || src.Rows[0] != "sku: A1\nname: Kettle\nnotes: Boils in 2 minutes, quietly" || src.Rows[1] != "sku: B2\nname: Toaster" {
+		t.Fatalf("csv rows = %q", src.Rows)
+	}
+	src, err = ParseDocument(FormatJSONL, []byte(`{"question":"How long is shipping?","answer":"3 days","tags":["shipping"]}`+"\n\n"+`"Free returns"`+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(src.Rows) != 2 || src.Rows[0] != "answer: 3 days\nquestion: How long is shipping?\ntags: [\"shipping\"]" || src.Rows[1] != "Free returns" {
+		t.Fatalf("jsonl rows = %q", src.Rows)
+	}
+	if _, err := ParseDocument(FormatJSONL, []byte("{}\n{oops\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a line error, got %v", err)
+	}
+}
+
+func TestLoadSourcesWith_FormatsAndRowChunks(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"faq.csv":    "q,a\nShipping?,3 days\nReturns?,30 days\n",
+		"page.htm":   "<p>Opening hours are 9 to 5.</p>",
+		"export.txt": "<p>Exported as text</p>",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ScanDocuments(dir)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("ScanDocuments = %v, %v", files, err)
+	}
+	sources, _, err := LoadSources(dir, []string{"faq.csv", "page.htm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources[0].Rows) != 2 || sources[1].Content != "Opening hours are 9 to 5." {
+		t.Fatalf("unexpected sources %+v", sources)
+	}
+	forced, _, err := LoadSourcesWith(dir, []string{"export.txt"}, ReadOptions{Format: FormatHTML})
+	if err != nil || forced[0].Content != "Exported as text" {
+		t.Fatalf("format override = %+v, %v", forced, err)
+	}
+
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: t.TempDir(), ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	res, err := store.(SourceStore).SyncSources(context.Background(), sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ChunksEmbedded != 3 {
+		t.Fatalf("expected one chunk per row plus the page, got %+v", res)
+	}
+}
@@ -0,0 +1,273 @@
+package runtimememory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
+	"go.uber.org/zap"
+)
+
+// Reranker reorders search results for a query, typically with a more expensive
+// relevance model than the first-stage retriever.
+type Reranker interface {
+	// Scores returns one relevance score per document, in input order.
+	Scores(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// RerankConfig configures the optional rerank stage (memory_config.yaml: rerank).
+type RerankConfig struct {
+	Provider   string // cross_encoder|cohere|voyage
+	Model      string
+	TopN       int // results returned after reranking (defaults to the requested top-k)
+	Candidates int // results fetched from the store before reranking (default 4x top-k)
+}
+
+// rerankConfigFromSettings reads rerank_* keys merged by LoadComponentMemoryConfig.
+func rerankConfigFromSettings(settings map[string]string) RerankConfig {
+	rc := RerankConfig{Provider: strings.ToLower(settings["rerank_provider"]), Model: settings["rerank_model"]}
+	rc.TopN, _ = strconv.Atoi(settings["rerank_top_n"])
+	rc.Candidates, _ = strconv.Atoi(settings["rerank_candidates"])
+	return rc
+}
+
+// NewReranker builds a Reranker for the configured provider. It returns nil when
+// reranking is disabled.
+func NewReranker(rc RerankConfig) (Reranker, error) {
+	switch rc.Provider {
+	case "", "none":
+		return nil, nil
+	case "cross_encoder", "local":
+		return newCrossEncoderReranker(rc.Model)
+	case "cohere":
+		key := os.Getenv("COHERE_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("COHERE_API_KEY is required for the cohere reranker")
+		}
+		model := rc.Model
+		if model == "" {
+			model = "rerank-english-v3.0"
+		}
+		return &apiReranker{provider: "cohere", endpoint: envOr("CMP_COHERE_RERANK_URL", "https://api.cohere.com/v1/rerank"), apiKey: key, model: model, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "voyage":
+		key := os.Getenv("VOYAGE_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("VOYAGE_API_KEY is required for the voyage reranker")
+		}
+		model := rc.Model
+		if model == "" {
+			model = "rerank-2"
+		}
+		return &apiReranker{provider: "voyage", endpoint: envOr("CMP_VOYAGE_RERANK_URL", "https://api.voyageai.com/v1/rerank"), apiKey: key, model: model, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported rerank provider: %s", rc.Provider)
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// apiReranker calls a hosted rerank API. Cohere and Voyage share the request
+// shape and differ only in the name of the results array.
+type apiReranker struct {
+	provider string
+	endpoint string
+	apiKey   string
+	model    string
+	client   *http.Client
+}
+
+type rerankAPIResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+func (r *apiReranker) Scores(ctx context.Context, query string, documents []string) ([]float64, error) {
+	body, _ := json.Marshal(map[string]interface{}{"model": r.model, "query": query, "documents": documents})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s rerank api error: %s", r.provider, resp.Status)
+	}
+	var out struct {
+		Results []rerankAPIResult `json:"results"` // cohere
+		Data    []rerankAPIResult `json:"data"`    // voyage
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	results := out.Results
+	if len(results) == 0 {
+		results = out.Data
+	}
+	scores := make([]float64, len(documents))
+	for _, res := range results {
+		if res.Index >= 0 && res.Index < len(scores) {
+			scores[res.Index] = res.RelevanceScore
+		}
+	}
+	return scores, nil
+}
+
+// crossEncoderReranker scores pairs with a local sentence-transformers CrossEncoder
+// through src/providers/rerank_provider.py (same discovery as the local model provider).
+type crossEncoderReranker struct {
+	pythonBin  string
+	scriptPath string
+	model      string
+	timeout    time.Duration
+}
+
+func newCrossEncoderReranker(model string) (Reranker, error) {
+	py := pyenv.Bin(os.Getenv("CMP_PROJECT_ROOT"))
+	if model == "" {
+		model = "cross-encoder/ms-marco-MiniLM-L-6-v2"
+	}
+	candidates := []string{}
+	if override := os.Getenv("CMP_RERANK_SCRIPT"); override != "" {
+		candidates = append(candidates, override)
+	}
+	if root := os.Getenv("CMP_PROJECT_ROOT"); root != "" {
+		candidates = append(candidates, filepath.Join(root, "src", "providers", "rerank_provider.py"))
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(cwd, "src", "providers", "rerank_provider.py"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return &crossEncoderReranker{pythonBin: py, scriptPath: path, model: model, timeout: 120 * time.Second}, nil
+		}
+	}
+	return nil, fmt.Errorf("rerank script not found in candidates: %v", candidates)
+}
+
+func (r *crossEncoderReranker) Scores(ctx context.Context, query string, documents []string) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	payload, _ := json.Marshal(map[string]interface{}{"query": query, "documents": documents, "model": r.model})
+	cmd := exec.CommandContext(ctx, r.pythonBin, r.scriptPath)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cross-encoder reranker error: %s", strings.TrimSpace(stderr.String()))
+	}
+	var resp struct {
+		Scores []float64 `json:"scores"`
+		Error  string    `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse reranker output: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("cross-encoder reranker error: %s", resp.Error)
+	}
+	if len(resp.Scores) != len(documents) {
+		return nil, fmt.Errorf("reranker returned %d scores for %d documents", len(resp.Scores), len(documents))
+	}
+	return resp.Scores, nil
+}
+
+// rerankingStore decorates a MemoryStore so Search over-fetches candidates and
+// reorders them with a Reranker. Reranker failures fall back to retrieval order
+// and are logged and counted in cmp_memory_rerank_fallbacks_total.
+type rerankingStore struct {
+	MemoryStore
+	reranker  Reranker
+	cfg       RerankConfig
+	component string
+}
+
+// Unwrap returns the underlying store.
+func (s *rerankingStore) Unwrap() MemoryStore { return s.MemoryStore }
+
+func (s *rerankingStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	candidates := s.cfg.Candidates
+	if candidates < topK {
+		candidates = topK * 4
+	}
+	results, err := s.MemoryStore.Search(ctx, query, candidates)
+	if err != nil || len(results) < 2 {
+		return truncateResults(results, topK), err
+	}
+	reranked, err := rerankResults(ctx, s.reranker, query, results, s.topN(topK))
+	if err != nil {
+		RerankFallbacks.WithLabelValues(s.component, s.cfg.Provider).Inc()
+		logger.WithContext(ctx).Warn("rerank failed, serving retrieval order",
+			zap.String("component", s.component), zap.String("provider", s.cfg.Provider), zap.Error(err))
+	}
+	return reranked, nil
+}
+
+func (s *rerankingStore) topN(topK int) int {
+	if s.cfg.TopN > 0 && s.cfg.TopN < topK {
+		return s.cfg.TopN
+	}
+	return topK
+}
+
+// rerankResults reorders results by reranker score and keeps the first n. The
+// retrieval score is preserved in metadata as retrieval_score. When the
+// reranker fails the first n results are returned in retrieval order along
+// with the error.
+func rerankResults(ctx context.Context, r Reranker, query string, results []SearchResult, n int) ([]SearchResult, error) {
+	docs := make([]string, len(results))
+	for i, res := range results {
+		docs[i] = res.Content
+	}
+	scores, err := r.Scores(ctx, query, docs)
+	if err == nil && len(scores) != len(results) {
+		err = fmt.Errorf("reranker returned %d scores for %d documents", len(scores), len(results))
+	}
+	if err != nil {
+		return truncateResults(results, n), err
+	}
+	out := make([]SearchResult, len(results))
+	for i, res := range results {
+		meta := make(map[string]interface{}, len(res.Metadata)+1)
+		for k, v := range res.Metadata {
+			meta[k] = v
+		}
+		meta["retrieval_score"] = res.Score
+		res.Metadata = meta
+		res.Score = scores[i]
+		out[i] = res
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Score > out[b].Score })
+	return truncateResults(out, n), nil
+}
+
+func truncateResults(results []SearchResult, n int) []SearchResult {
+	if n > 0 && len(results) > n {
+		return results[:n]
+	}
+	return results
+}
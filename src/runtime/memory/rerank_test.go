@@ -0,0 +1,127 @@
+package runtimememory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRerankingStore_CohereAPI(t *testing.T) {
+	var gotDocs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotDocs = body.Documents
+		// rank the shipping document highest regardless of retrieval order
+		var results []map[string]interface{}
+		for i, d := range body.Documents {
+			score := 0.1
+			if strings.Contains(d, "Shipping") {
+				score = 0.9
+			}
+			results = append(results, map[string]interface{}{"index": i, "relevance_score": score})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	}))
+	defer srv.Close()
+	t.Setenv("COHERE_API_KEY", "test")
+	t.Setenv("CMP_COHERE_RERANK_URL", srv.URL)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "CustomerDocs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfgYAML := "rerank:\n  provider: cohere\n  candidates: 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(cfgYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	docs := []string{"Returns are accepted within 30 days.", "Shipping takes 3-5 business days.", "Gift cards never expire."}
+	if _, err := store.IngestDocuments(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(context.Background(), "returns", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotDocs) != 3 {
+		t.Fatalf("expected 3 candidates sent to reranker, got %d", len(gotDocs))
+	}
+	if len(res) != 1 || res[0].Content != docs[1] || res[0].Score != 0.9 {
+		t.Fatalf("expected reranked shipping doc, got %+v", res)
+	}
+	if _, ok := res[0].Metadata["retrieval_score"]; !ok {
+		t.Fatalf("expected retrieval_score metadata")
+	}
+	if _, err := AsSnapshotStore(store); err != nil {
+		t.Fatalf("reranking store should expose snapshots: %v", err)
+	}
+}
+
+func TestNewReranker_Unsupported(t *testing.T) {
+	if r, err := NewReranker(RerankConfig{}); r != nil || err != nil {
+		t.Fatalf("expected disabled reranker")
+	}
+	if _, err := NewReranker(RerankConfig{Provider: "bogus"}); err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+}
+
+func TestRerankingStore_FallsBackToRetrievalOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	t.Setenv("COHERE_API_KEY", "test")
+	t.Setenv("CMP_COHERE_RERANK_URL", srv.URL)
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "FallbackDocs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte("rerank:\n  provider: cohere\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "FallbackDocs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.IngestDocuments(context.Background(), []string{"Returns are accepted within 30 days.", "Gift cards never expire."}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := store.Search(context.Background(), "returns", 1)
+	if err != nil || len(res) != 1 {
+		t.Fatalf("expected retrieval results despite the rerank failure, got %+v %v", res, err)
+	}
+	if got := testutil.ToFloat64(RerankFallbacks.WithLabelValues("FallbackDocs", "cohere")); got != 1 {
+		t.Fatalf("expected one counted fallback, got %v", got)
+	}
+
+	// Without COHERE_API_KEY the reranker is skipped instead of failing the store
+	t.Setenv("COHERE_API_KEY", "")
+	store2, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "FallbackDocs"})
+	if err != nil {
+		t.Fatalf("misconfigured reranker broke the store: %v", err)
+	}
+	defer store2.Close()
+	if res, err := store2.Search(context.Background(), "returns", 1); err != nil || len(res) != 1 {
+		t.Fatalf("search without reranker: %+v %v", res, err)
+	}
+}
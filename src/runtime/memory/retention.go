@@ -0,0 +1,267 @@
+package runtimememory
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Episodic log lines start with the time they were written:
+// "<RFC3339>\t<entry>". Encrypted entries are base64 ciphertext. Lines
+// written before timestamps were added have no prefix and hold the raw
+// entry; retention only removes them through the size caps.
+
+func formatEpisode(t time.Time, payload string) []byte {
+	return []byte(t.UTC().Format(time.RFC3339) + "\t" + payload + "\n")
+}
+
+// parseEpisode splits a log line into its write time and entry. The time is
+// zero for lines without a timestamp.
+func parseEpisode(line string) (time.Time, string) {
+	i := strings.IndexByte(line, '\t')
+	if i < 0 || i > len(time.RFC3339)+6 {
+		return time.Time{}, line
+	}
+	t, err := time.Parse(time.RFC3339, line[:i])
+	if err != nil {
+		return time.Time{}, line
+	}
+	return t, line[i+1:]
+}
+
+// episodeCiphertext returns the ciphertext of an encrypted entry; timestamped
+// lines hold it base64-encoded, older lines as raw bytes.
+func episodeCiphertext(written time.Time, entry string) ([]byte, error) {
+	if written.IsZero() {
+		return []byte(entry), nil
+	}
+	return base64.StdEncoding.DecodeString(entry)
+}
+
+// RetentionPolicy limits how much episodic memory a component keeps. Zero
+// values disable a limit. The caps apply to each log: per user for
+// user-scoped stores, otherwise per tenant.
+type RetentionPolicy struct {
+	MaxAge     time.Duration
+	MaxEntries int   // an encrypted batch counts as one entry
+	MaxBytes   int64 // size of the log file
+}
+
+// Enabled reports whether any limit is set.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxEntries > 0 || p.MaxBytes > 0
+}
+
+// LoadRetentionPolicy reads the policy from the component's
+// memory_config.yaml: retention_days, episodic.max_entries_per_user and
+// episodic.max_bytes_per_user.
+func LoadRetentionPolicy(root, component string) (RetentionPolicy, error) {
+	cfg := Config{RootDir: root, ComponentName: component}
+	if err := LoadComponentMemoryConfig(&cfg); err != nil {
+		return RetentionPolicy{}, err
+	}
+	var p RetentionPolicy
+	days, err := positiveSetting(cfg.Settings, "retention_days")
+	if err != nil {
+		return p, err
+	}
+	entries, err := positiveSetting(cfg.Settings, "episodic_max_entries")
+	if err != nil {
+		return p, err
+	}
+	if p.MaxBytes, err = positiveSetting(cfg.Settings, "episodic_max_bytes"); err != nil {
+		return p, err
+	}
+	p.MaxAge = time.Duration(days) * 24 * time.Hour
+	p.MaxEntries = int(entries)
+	return p, nil
+}
+
+func positiveSetting(settings map[string]string, key string) (int64, error) {
+	v, ok := settings[key]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// PruneResult counts the entries retention removed from a component.
+type PruneResult struct {
+	Component string `json:"component"`
+	Logs      int    `json:"logs"`
+	ByAge     int    `json:"pruned_age"`
+	BySize    int    `json:"pruned_size"`
+}
+
+// PruneEpisodic applies the component's retention policy to every episodic
+// log it has, across tenants and users. Entries older than the policy's
+// MaxAge go first, then the oldest entries until the size caps hold.
+func PruneEpisodic(root, component string, now time.Time) (PruneResult, error) {
+	res := PruneResult{Component: component}
+	policy, err := LoadRetentionPolicy(root, component)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", component, err)
+	}
+	if !policy.Enabled() {
+		return res, nil
+	}
+	logs, err := episodicLogs(filepath.Join(root, "memory", component))
+	if err != nil {
+		return res, err
+	}
+	for _, path := range logs {
+		age, size, err := pruneLog(path, policy, now)
+		if err != nil {
+			return res, fmt.Errorf("prune %s: %w", path, err)
+		}
+		res.Logs++
+		res.ByAge += age
+		res.BySize += size
+	}
+	if res.ByAge > 0 {
+		EpisodicPruned.WithLabelValues(component, "age").Add(float64(res.ByAge))
+	}
+	if res.BySize > 0 {
+		EpisodicPruned.WithLabelValues(component, "size").Add(float64(res.BySize))
+	}
+	return res, nil
+}
+
+// PruneAllEpisodic runs PruneEpisodic for every component under memory/.
+func PruneAllEpisodic(root string, now time.Time) ([]PruneResult, error) {
+	entries, err := os.ReadDir(filepath.Join(root, "memory"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []PruneResult
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		res, err := PruneEpisodic(root, e.Name(), now)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// episodicLogs finds the episodic/episodes.log files under a component directory.
+func episodicLogs(dir string) ([]string, error) {
+	var logs []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() && d.Name() == "episodes.log" && filepath.Base(filepath.Dir(path)) == "episodic" {
+			logs = append(logs, path)
+		}
+		return nil
+	})
+	sort.Strings(logs)
+	return logs, err
+}
+
+// pruneLog rewrites one log without the entries the policy drops and returns
+// how many went for age and for size.
+func pruneLog(path string, policy RetentionPolicy, now time.Time) (byAge, bySize int, err error) {
+	unlock, err := lockEpisodes(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var kept []string
+	var size int64
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if written, _ := parseEpisode(line); policy.MaxAge > 0 && !written.IsZero() && now.Sub(written) > policy.MaxAge {
+			byAge++
+			continue
+		}
+		kept = append(kept, line)
+		size += int64(len(line)) + 1
+	}
+	_ = f.Close()
+	if err := sc.Err(); err != nil {
+		return 0, 0, err
+	}
+	for len(kept) > 0 && ((policy.MaxEntries > 0 && len(kept) > policy.MaxEntries) || (policy.MaxBytes > 0 && size > policy.MaxBytes)) {
+		size -= int64(len(kept[0])) + 1
+		kept = kept[1:]
+		bySize++
+	}
+	if byAge == 0 && bySize == 0 {
+		return 0, 0, nil
+	}
+	var b strings.Builder
+	for _, line := range kept {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, 0, err
+	}
+	return byAge, bySize, nil
+}
+
+const (
+	episodesLockTimeout = 5 * time.Second
+	// episodesStaleLock is the age after which a lock left by a crashed process is broken.
+	episodesStaleLock = 30 * time.Second
+)
+
+// lockEpisodes takes the lock file next to an episodic log, shared by
+// appends and retention across processes.
+func lockEpisodes(logPath string) (func(), error) {
+	path := logPath + ".lock"
+	deadline := time.Now().Add(episodesLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > episodesStaleLock {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("episodic log is locked: %s", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,119 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func writeMemoryConfig(t *testing.T, root, component, content string) {
+	t.Helper()
+	dir := filepath.Join(root, "memory", component)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneEpisodic_AgeAndSizeCaps(t *testing.T) {
+	root := t.TempDir()
+	writeMemoryConfig(t, root, "SupportBot", "retention_days: 30\nepisodic:\n  enabled: true\n  max_entries_per_user: 2\n")
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// A shared log with an expired entry, a legacy entry and two recent ones
+	shared := DerivePath(root, "SupportBot", "acme", "episodic/episodes.log")
+	_ = os.MkdirAll(filepath.Dir(shared), 0o755)
+	lines := string(formatEpisode(now.AddDate(0, 0, -45), "old conversation")) +
+		"legacy entry without a timestamp\n" +
+		string(formatEpisode(now.AddDate(0, 0, -2), "last week")) +
+		string(formatEpisode(now.AddDate(0, 0, -1), "yesterday"))
+	if err := os.WriteFile(shared, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A user log written through the store
+	store, err := NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot", TenantID: "acme", UserID: "u-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.IngestDocuments(context.Background(), []string{"asked about refunds"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	before := testutil.ToFloat64(EpisodicPruned.WithLabelValues("SupportBot", "age"))
+	res, err := PruneEpisodic(root, "SupportBot", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Logs != 2 || res.ByAge != 1 || res.BySize != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	by, _ := os.ReadFile(shared)
+	if got := string(by); strings.Contains(got, "old conversation") || strings.Contains(got, "legacy") || !strings.Contains(got, "yesterday") {
+		t.Fatalf("unexpected log after pruning:\n%s", got)
+	}
+	if d := testutil.ToFloat64(EpisodicPruned.WithLabelValues("SupportBot", "age")) - before; d != 1 {
+		t.Fatalf("age metric moved by %v", d)
+	}
+	got, err := ExportUserEpisodes(root, "acme", "u-1")
+	if err != nil || len(got) != 1 || len(got[0].Entries) != 1 {
+		t.Fatalf("user memory: %+v %v", got, err)
+	}
+
+	// Nothing left to prune; the log is not rewritten
+	if res, err := PruneEpisodic(root, "SupportBot", now); err != nil || res.ByAge+res.BySize != 0 {
+		t.Fatalf("second run: %+v %v", res, err)
+	}
+	// Components without a policy are left alone
+	writeMemoryConfig(t, root, "Docs", "vector_store:\n  type: sqlite\n")
+	if res, err := PruneEpisodic(root, "Docs", now); err != nil || res.Logs != 0 {
+		t.Fatalf("no policy: %+v %v", res, err)
+	}
+	writeMemoryConfig(t, root, "Broken", "retention_days: -1\n")
+	if _, err := PruneAllEpisodic(root, now); err == nil || !strings.Contains(err.Error(), "retention_days") {
+		t.Fatalf("expected a policy error, got %v", err)
+	}
+}
+
+func TestEpisodicStore_EncryptedEntriesSurvivePruning(t *testing.T) {
+	t.Setenv("CMP_EPISODIC_KEY", "0123456789abcdef0123456789abcdef")
+	root := t.TempDir()
+	writeMemoryConfig(t, root, "SupportBot", "episodic:\n  enabled: true\n  encryption: true\n  max_bytes_per_user: 250\n")
+	cfg := Config{RootDir: root, ComponentName: "SupportBot"}
+	if err := LoadComponentMemoryConfig(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, batch := range [][]string{{"first refund request"}, {"second refund request"}, {"third refund request"}} {
+		if _, err := store.IngestDocuments(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := PruneEpisodic(root, "SupportBot", time.Now())
+	if err != nil || res.BySize == 0 {
+		t.Fatalf("expected size pruning: %+v %v", res, err)
+	}
+	results, err := store.Search(context.Background(), "refund", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("unexpected results after pruning %+v", results)
+	}
+	for _, r := range results {
+		if r.Content == "first refund request" {
+			t.Fatalf("oldest batch kept: %+v", results)
+		}
+	}
+}
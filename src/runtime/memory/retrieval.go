@@ -0,0 +1,91 @@
+package runtimememory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// RetrievalOptions override the search and chunking settings of a component's
+// memory_config.yaml for one caller, such as the retrieval section of the
+// context answering a request. Zero fields keep the component's settings.
+type RetrievalOptions struct {
+	// Hybrid selects hybrid (true) or vector (false) search.
+	Hybrid *bool
+	// Rerank replaces the component's reranker; provider "none" disables it.
+	Rerank *RerankConfig
+	// Filters keep only results whose metadata value equals the given one.
+	Filters map[string]string
+	// ChunkSize and ChunkOverlap are in characters, as document_processing.
+	ChunkSize    int
+	ChunkOverlap int
+	// Paraphrase asks the model for query paraphrases when the component's
+	// multi_query mode is provider; without it paraphrases are heuristic.
+	Paraphrase ParaphraseFunc
+}
+
+// apply merges the options into settings flattened from memory_config.yaml.
+func (o *RetrievalOptions) apply(settings map[string]string) {
+	if o == nil {
+		return
+	}
+	if o.Hybrid != nil {
+		settings["search_mode"] = SearchModeVector
+		if *o.Hybrid {
+			settings["search_mode"] = SearchModeHybrid
+		}
+	}
+	if rc := o.Rerank; rc != nil {
+		settings["rerank_provider"] = rc.Provider
+		settings["rerank_model"] = rc.Model
+		settings["rerank_top_n"] = strconv.Itoa(rc.TopN)
+		settings["rerank_candidates"] = strconv.Itoa(rc.Candidates)
+	}
+	if o.ChunkSize > 0 {
+		settings["chunk_size"] = strconv.Itoa(o.ChunkSize)
+		settings["chunk_overlap"] = strconv.Itoa(o.ChunkOverlap)
+	}
+}
+
+// filterOverfetch is how many candidates per requested result a filtered
+// search fetches before dropping non-matching ones.
+const filterOverfetch = 4
+
+// filteringStore decorates a MemoryStore so Search keeps only results whose
+// metadata matches every filter.
+type filteringStore struct {
+	MemoryStore
+	filters map[string]string
+}
+
+// Unwrap returns the underlying store.
+func (s *filteringStore) Unwrap() MemoryStore { return s.MemoryStore }
+
+func (s *filteringStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	results, err := s.MemoryStore.Search(ctx, query, topK*filterOverfetch)
+	if err != nil {
+		return nil, err
+	}
+	kept := results[:0]
+	for _, res := range results {
+		if matchesFilters(res.Metadata, s.filters) {
+			kept = append(kept, res)
+		}
+	}
+	return truncateResults(kept, topK), nil
+}
+
+// matchesFilters reports whether meta has every filter key with the filter's
+// value, compared as text.
+func matchesFilters(meta map[string]interface{}, filters map[string]string) bool {
+	for k, want := range filters {
+		v, ok := meta[k]
+		if !ok || fmt.Sprint(v) != want {
+			return false
+		}
+	}
+	return true
+}
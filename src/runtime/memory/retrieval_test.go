@@ -0,0 +1,67 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetrievalOptions_OverrideComponentConfig(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "Docs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "search:\n  mode: hybrid\ndocument_processing:\n  chunk_size: 500\n  chunk_overlap: 50\nrerank:\n  provider: cohere\n"
+	if err := os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	off := false
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs", Retrieval: &RetrievalOptions{
+		Hybrid:    &off,
+		Rerank:    &RerankConfig{Provider: "none"},
+		ChunkSize: 40,
+	}})
+	if err != nil {
+		t.Fatalf("reranker disabled by the options should not need COHERE_API_KEY: %v", err)
+	}
+	defer store.Close()
+	vs, ok := store.(*sqliteVectorStore)
+	if !ok {
+		t.Fatalf("expected the bare store without reranking, got %T", store)
+	}
+	if vs.searchMode != SearchModeVector || vs.chunkSize != 40 || vs.chunkOverlap != 0 {
+		t.Fatalf("options not applied: mode %s, chunks %d/%d", vs.searchMode, vs.chunkSize, vs.chunkOverlap)
+	}
+}
+
+func TestRetrievalOptions_FiltersByMetadata(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs", Retrieval: &RetrievalOptions{
+		Filters: map[string]string{"language": "de"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	sources := []Source{
+		{Path: "en.md", Content: "Returns are accepted within 30 days of the purchase."},
+		{Path: "de.md", Content: "Die Rückgabe ist innerhalb von 30 Tagen nach dem Kauf möglich."},
+		{Path: "en2.md", Content: "The warranty is two years for all of the devices."},
+	}
+	ss, err := AsSourceStore(store)
+	if err != nil {
+		t.Fatalf("filtered stores should still sync sources: %v", err)
+	}
+	if _, err := ss.SyncSources(context.Background(), sources); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.Search(context.Background(), "returns within 30 days", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Metadata["language"] != "de" {
+		t.Fatalf("expected only the German chunk, got %+v", results)
+	}
+}
@@ -0,0 +1,238 @@
+package runtimememory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// RotateResult counts what RotateKeys re-encrypted in one component.
+type RotateResult struct {
+	Component string `json:"component"`
+	Files     int    `json:"files"`
+	Values    int    `json:"values"`
+}
+
+// RotateKeys re-encrypts a component's memory with the current key of keys:
+// sqlite records and their snapshots, and the episodic and conversation logs
+// of every tenant and user. Values sealed with a previous key are resealed;
+// plaintext left from before encryption was enabled, and episodic batches
+// written before values carried a key ID, are sealed when the component's
+// memory_config.yaml enables encryption. Afterwards the previous keys are no
+// longer needed.
+func RotateKeys(root, component string, keys *runtimesecurity.KeyRing) (RotateResult, error) {
+	res := RotateResult{Component: component}
+	cfg := Config{RootDir: root, ComponentName: component}
+	if err := LoadComponentMemoryConfig(&cfg); err != nil {
+		return res, err
+	}
+	encrypt := cfg.Settings["encryption"] == "true"
+	episodicEncrypt := encrypt || cfg.Settings["episodic_encryption"] == "true"
+
+	dir := filepath.Join(root, "memory", component)
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		var (
+			n   int
+			err error
+		)
+		switch {
+		case filepath.Base(path) == "vector_store.jsonl",
+			filepath.Base(filepath.Dir(path)) == "snapshots" && strings.HasSuffix(path, ".jsonl"):
+			n, err = rotateRecords(path, encrypt, keys)
+		case filepath.Base(path) == "episodes.log" && filepath.Base(filepath.Dir(path)) == "episodic":
+			n, err = rotateEpisodes(path, episodicEncrypt, keys)
+		case filepath.Base(filepath.Dir(path)) == "conversations" && strings.HasSuffix(path, ".jsonl"):
+			n, err = rotateConversation(path, episodicEncrypt, keys)
+		default:
+			continue
+		}
+		if err != nil {
+			return res, fmt.Errorf("rotate %s: %w", path, err)
+		}
+		if n > 0 {
+			res.Files++
+			res.Values += n
+		}
+	}
+	return res, nil
+}
+
+// rotateRecords reseals the content of the records in a sqlite store file.
+func rotateRecords(path string, encrypt bool, keys *runtimesecurity.KeyRing) (int, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var out strings.Builder
+	changed := 0
+	for _, line := range strings.Split(string(by), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var rec vecRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return 0, fmt.Errorf("parse record: %w", err)
+		}
+		content, ok, err := rotateValue(rec.Content, encrypt, keys)
+		if err != nil {
+			return 0, fmt.Errorf("record %s: %w", rec.ID, err)
+		}
+		if ok {
+			rec.Content = content
+			changed++
+			enc, err := json.Marshal(rec)
+			if err != nil {
+				return 0, err
+			}
+			line = string(enc)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, writeFileAtomic(path, []byte(out.String()))
+}
+
+// rotateEpisodes reseals the lines of an episodic log under its lock.
+func rotateEpisodes(path string, encrypt bool, keys *runtimesecurity.KeyRing) (int, error) {
+	unlock, err := lockEpisodes(path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	_ = f.Close()
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	var out strings.Builder
+	changed := 0
+	for i, line := range lines {
+		written, payload := parseEpisode(line)
+		if !runtimesecurity.IsSealed(payload) && encrypt && strings.TrimSpace(payload) != "" {
+			// A batch encrypted before values carried a key ID, or an entry
+			// written before encryption was enabled
+			if ciphertext, err := episodeCiphertext(written, payload); err == nil {
+				if plaintext, err := keys.OpenRaw(ciphertext); err == nil {
+					payload = string(plaintext)
+				} else if !utf8.ValidString(payload) {
+					return 0, fmt.Errorf("line %d: %w", i+1, err)
+				}
+			}
+		}
+		sealed, ok, err := rotateValue(payload, encrypt, keys)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if ok {
+			changed++
+			if written.IsZero() {
+				line = sealed
+			} else {
+				line = strings.TrimSuffix(string(formatEpisode(written, sealed)), "\n")
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, writeFileAtomic(path, []byte(out.String()))
+}
+
+// rotateConversation reseals the turns and summaries of a conversation log.
+func rotateConversation(path string, encrypt bool, keys *runtimesecurity.KeyRing) (int, error) {
+	unlock, err := lockEpisodes(path)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var out strings.Builder
+	changed := 0
+	for i, line := range strings.Split(string(by), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var rec conversationRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		n := 0
+		for _, v := range []*string{&rec.Query, &rec.Response, &rec.Summary} {
+			sealed, ok, err := rotateValue(*v, encrypt, keys)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if ok {
+				*v = sealed
+				n++
+			}
+		}
+		if n > 0 {
+			changed += n
+			enc, err := json.Marshal(rec)
+			if err != nil {
+				return 0, err
+			}
+			line = string(enc)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, writeFileAtomic(path, []byte(out.String()))
+}
+
+// rotateValue returns v sealed with the current key and whether it changed.
+func rotateValue(v string, encrypt bool, keys *runtimesecurity.KeyRing) (string, bool, error) {
+	if runtimesecurity.IsSealed(v) {
+		return keys.Reseal(v)
+	}
+	if !encrypt || v == "" {
+		return v, false, nil
+	}
+	sealed, err := keys.Seal([]byte(v))
+	return sealed, err == nil, err
+}
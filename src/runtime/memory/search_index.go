@@ -0,0 +1,221 @@
+package runtimememory
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// indexFile holds the HNSW graph saved by `ctx memory optimize`, next to the
+// store file.
+const indexFile = "vector_index.json"
+
+// storeViews caches the parsed records of each store file, so searches decode
+// the JSON and vectors of a store once rather than on every query. A view is
+// replaced when its file is rewritten.
+var storeViews = struct {
+	sync.Mutex
+	m map[string]*storeView
+}{m: map[string]*storeView{}}
+
+// storeView is a parsed store file.
+type storeView struct {
+	info      os.FileInfo
+	records   []viewRecord
+	sources   map[string]bool
+	unsourced int
+	// unhashed counts records written before content hashing
+	unhashed int
+
+	mu     sync.Mutex
+	graphs map[int]viewGraph // by vector length
+}
+
+// viewGraph is the HNSW graph over the records with vectors of one length.
+type viewGraph struct {
+	graph *hnswIndex
+	nodes []int // graph node -> record index
+}
+
+// viewRecord is a record with its vector decoded and normalized; content is
+// still sealed when the store is encrypted.
+type viewRecord struct {
+	id, content, source, model string
+	meta                       map[string]interface{}
+	vec                        []float64 // nil when the vector cannot be decoded
+}
+
+// view returns the parsed records of the store file.
+func (s *sqliteVectorStore) view() (*storeView, error) {
+	info, err := os.Stat(s.filePath)
+	if err != nil {
+		return nil, err
+	}
+	storeViews.Lock()
+	v := storeViews.m[s.filePath]
+	storeViews.Unlock()
+	if v != nil && sameFileState(v.info, info) {
+		return v, nil
+	}
+	v, err = readStoreView(s.filePath)
+	if err != nil {
+		return nil, err
+	}
+	storeViews.Lock()
+	storeViews.m[s.filePath] = v
+	storeViews.Unlock()
+	return v, nil
+}
+
+// sameFileState reports whether a file is unchanged: store files are replaced
+// atomically, so a rewrite shows up as another inode or modification time.
+func sameFileState(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func readStoreView(path string) (*storeView, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	v := &storeView{info: info, sources: map[string]bool{}}
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scan.Scan() {
+		var rec vecRecord
+		if err := json.Unmarshal(scan.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Source != "" {
+			v.sources[rec.Source] = true
+		} else {
+			v.unsourced++
+		}
+		if rec.Hash == "" {
+			v.unhashed++
+		}
+		vr := viewRecord{id: rec.ID, content: rec.Content, source: rec.Source, model: rec.Model, meta: rec.Metadata}
+		if vb, err := base64.StdEncoding.DecodeString(rec.Vector); err == nil {
+			if vec := bytesToFloat64s(vb); vec != nil {
+				vr.vec = normalize(vec)
+			}
+		}
+		v.records = append(v.records, vr)
+	}
+	return v, scan.Err()
+}
+
+// ann returns the HNSW graph over the records with dim-sized vectors: the
+// graph saved next to the store when it matches the file, or a new one.
+// Graphs are cached per dim, since the vectors of a store follow its
+// embedder rather than the configured embedding_dim.
+func (v *storeView) ann(path string, dim int, p hnswParams) (*hnswIndex, []int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if g, ok := v.graphs[dim]; ok && g.graph.Params.M == p.M && g.graph.Params.EfConstruction == p.EfConstruction {
+		return g.graph, g.nodes
+	}
+	var nodes []int
+	var vecs [][]float64
+	for i, rec := range v.records {
+		if len(rec.vec) == dim {
+			nodes = append(nodes, i)
+			vecs = append(vecs, rec.vec)
+		}
+	}
+	graph := v.loadGraph(path, dim, nodes, p)
+	if graph == nil {
+		graph = buildHNSW(vecs, p)
+	}
+	graph.vecs = vecs
+	if v.graphs == nil {
+		v.graphs = map[int]viewGraph{}
+	}
+	v.graphs[dim] = viewGraph{graph: graph, nodes: nodes}
+	return graph, nodes
+}
+
+// vectorDim returns the most common vector length of the records, or
+// fallback when no record has a vector.
+func (v *storeView) vectorDim(fallback int) int {
+	counts := map[int]int{}
+	best := 0
+	for _, rec := range v.records {
+		if n := len(rec.vec); n > 0 {
+			counts[n]++
+			if counts[n] > counts[best] || (counts[n] == counts[best] && n < best) {
+				best = n
+			}
+		}
+	}
+	if best == 0 {
+		return fallback
+	}
+	return best
+}
+
+// savedGraph is the layout of indexFile.
+type savedGraph struct {
+	StoreSize    int64      `json:"store_size"`
+	StoreModTime time.Time  `json:"store_mod_time"`
+	Dim          int        `json:"dim"`
+	IDs          []string   `json:"ids"`
+	Graph        *hnswIndex `json:"graph"`
+}
+
+// loadGraph reads the saved graph, or returns nil when it is missing or was
+// built for another version of the store, another dim or other parameters.
+func (v *storeView) loadGraph(path string, dim int, nodes []int, p hnswParams) *hnswIndex {
+	by, err := os.ReadFile(filepath.Join(filepath.Dir(path), indexFile))
+	if err != nil {
+		return nil
+	}
+	var saved savedGraph
+	if json.Unmarshal(by, &saved) != nil || saved.Graph == nil {
+		return nil
+	}
+	g := saved.Graph
+	if saved.StoreSize != v.info.Size() || !saved.StoreModTime.Equal(v.info.ModTime()) || saved.Dim != dim ||
+		g.Params.M != p.M || g.Params.EfConstruction != p.EfConstruction ||
+		len(saved.IDs) != len(nodes) || len(g.Neighbors) != len(nodes) || g.Entry >= len(nodes) ||
+		(g.Entry >= 0 && len(g.Neighbors[g.Entry]) <= g.MaxLevel) {
+		return nil
+	}
+	for i, n := range nodes {
+		if saved.IDs[i] != v.records[n].id {
+			return nil
+		}
+		// a damaged file must not send searches out of range
+		for l, layer := range g.Neighbors[i] {
+			for _, nb := range layer {
+				if int(nb) < 0 || int(nb) >= len(nodes) || len(g.Neighbors[nb]) <= l {
+					return nil
+				}
+			}
+		}
+	}
+	return g
+}
+
+// saveGraph writes the graph over the view's dim-sized vectors next to the
+// store file.
+func (v *storeView) saveGraph(path string, dim int, graph *hnswIndex, nodes []int) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = v.records[n].id
+	}
+	by, err := json.Marshal(savedGraph{StoreSize: v.info.Size(), StoreModTime: v.info.ModTime(), Dim: dim, IDs: ids, Graph: graph})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(filepath.Dir(path), indexFile), by)
+}
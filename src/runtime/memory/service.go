@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	"go.uber.org/zap"
 )
 
 // Config for memory service instantiation.
@@ -16,20 +19,77 @@ type Config struct {
 	EmbeddingModel string            // model identifier for vector stores
 	Settings       map[string]string // provider-specific settings
 	TenantID       string            // tenant isolation
+	UserID         string            // episodic only: keeps the store under users/<id> for export and erasure
+	Retrieval      *RetrievalOptions // overrides memory_config.yaml search and chunking settings
 }
 
 // NewStore creates a MemoryStore based on config.
 func NewStore(cfg Config) (MemoryStore, error) {
 	// Merge component memory_config.yaml if present
 	_ = LoadComponentMemoryConfig(&cfg)
+	if cfg.Retrieval != nil {
+		if cfg.Settings == nil {
+			cfg.Settings = map[string]string{}
+		}
+		cfg.Retrieval.apply(cfg.Settings)
+	}
+	var (
+		store MemoryStore
+		err   error
+	)
 	switch strings.ToLower(cfg.Provider) {
 	case "sqlite":
-		return newSQLiteVectorStore(cfg)
+		store, err = newSQLiteVectorStore(cfg)
 	case "episodic":
-		return newEpisodicStore(cfg)
+		store, err = newEpisodicStore(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported memory provider: %s", cfg.Provider)
 	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Retrieval != nil && len(cfg.Retrieval.Filters) > 0 {
+		store = &filteringStore{MemoryStore: store, filters: cfg.Retrieval.Filters}
+	}
+	// Optional query expansion configured per component, beneath the reranker
+	// so paraphrase results are reranked against the original query
+	mq, ok, err := multiQueryConfigFromSettings(cfg.Settings)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if ok {
+		mqs := &multiQueryStore{MemoryStore: store, cfg: mq}
+		if cfg.Retrieval != nil {
+			mqs.paraphrase = cfg.Retrieval.Paraphrase
+		}
+		store = mqs
+	}
+	// Optional rerank stage configured per component. A reranker that cannot be
+	// configured (e.g. a missing API key) is skipped rather than failing retrieval.
+	rc := rerankConfigFromSettings(cfg.Settings)
+	reranker, err := NewReranker(rc)
+	if err != nil {
+		logger.GetLogger().Warn("reranker disabled, serving retrieval order",
+			zap.String("component", cfg.ComponentName), zap.String("provider", rc.Provider), zap.Error(err))
+		return store, nil
+	}
+	if reranker != nil {
+		return &rerankingStore{MemoryStore: store, reranker: reranker, cfg: rc, component: cfg.ComponentName}, nil
+	}
+	return store, nil
+}
+
+// unwrapStore returns the store beneath any decorators (filters, query
+// expansion, reranking).
+func unwrapStore(store MemoryStore) MemoryStore {
+	for {
+		w, ok := store.(interface{ Unwrap() MemoryStore })
+		if !ok {
+			return store
+		}
+		store = w.Unwrap()
+	}
 }
 
 // DerivePath returns a tenant-aware path under memory/.
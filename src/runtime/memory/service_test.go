@@ -48,3 +48,61 @@ func TestSQLiteVectorStore_IngestAndSearch_FileBackend(t *testing.T) {
 		t.Fatalf("expected results, got none")
 	}
 }
+
+func TestSQLiteVectorStore_DeltaIngestion(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	cfg := Config{Provider: "sqlite", RootDir: root, ComponentName: "CustomerDocs", Settings: map[string]string{"chunk_size": "60"}}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+	ss, err := AsSourceStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returns := "Returns are accepted within 30 days.\n\nRefunds are issued to the original payment method."
+	first, err := ss.SyncSources(ctx, []Source{
+		{Path: "returns.md", Content: returns},
+		{Path: "shipping.md", Content: "Shipping takes 3-5 business days."},
+	})
+	if err != nil {
+		t.Fatalf("SyncSources: %v", err)
+	}
+	if len(first.Added) != 2 || first.ChunksEmbedded != 3 || first.Version == "" {
+		t.Fatalf("unexpected first sync %+v", first)
+	}
+
+	// Only the edited paragraph is re-embedded; the deleted file's chunk is dropped
+	edited := "Returns are accepted within 30 days.\n\nRefunds are issued as store credit."
+	second, err := ss.SyncSources(ctx, []Source{{Path: "returns.md", Content: edited}})
+	if err != nil {
+		t.Fatalf("SyncSources: %v", err)
+	}
+	if len(second.Updated) != 1 || len(second.Removed) != 1 || second.Removed[0] != "shipping.md" {
+		t.Fatalf("unexpected file counts %+v", second)
+	}
+	if second.ChunksEmbedded != 1 || second.ChunksReused != 1 || second.ChunksDeleted != 2 {
+		t.Fatalf("unexpected chunk counts %+v", second)
+	}
+
+	third, err := ss.SyncSources(ctx, []Source{{Path: "returns.md", Content: edited}})
+	if err != nil {
+		t.Fatalf("SyncSources: %v", err)
+	}
+	if third.Changed() || len(third.Unchanged) != 1 || third.Version != second.Version {
+		t.Fatalf("unchanged sync should be a no-op, got %+v", third)
+	}
+
+	// Re-ingesting identical plain documents does not duplicate them
+	before, _ := store.Search(ctx, "store credit", 10)
+	if _, err := store.IngestDocuments(ctx, []string{"Refunds are issued as store credit."}); err != nil {
+		t.Fatalf("IngestDocuments: %v", err)
+	}
+	after, _ := store.Search(ctx, "store credit", 10)
+	if len(after) != len(before) {
+		t.Fatalf("duplicate chunk ingested: %d -> %d", len(before), len(after))
+	}
+}
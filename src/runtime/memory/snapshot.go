@@ -0,0 +1,286 @@
+package runtimememory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot describes a stored memory version.
+type Snapshot struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Records   int       `json:"records"`
+	Tags      []string  `json:"tags,omitempty"`
+	Current   bool      `json:"current"`
+}
+
+// SnapshotStore is implemented by memory stores that keep a snapshot per ingested
+// version and can atomically roll back to one of them.
+type SnapshotStore interface {
+	// ListSnapshots returns snapshots oldest first.
+	ListSnapshots(ctx context.Context) ([]Snapshot, error)
+
+	// TagSnapshot attaches a human-readable tag to a version.
+	TagSnapshot(ctx context.Context, version, tag string) error
+
+	// Rollback restores the store to a version (full ID, unique prefix, or tag).
+	Rollback(ctx context.Context, version string) (string, error)
+}
+
+// AsSnapshotStore returns the snapshot API of a store, or an error if the provider
+// does not support versioning.
+func AsSnapshotStore(store MemoryStore) (SnapshotStore, error) {
+	store = unwrapStore(store)
+	ss, ok := store.(SnapshotStore)
+	if !ok {
+		return nil, fmt.Errorf("memory provider does not support snapshots")
+	}
+	return ss, nil
+}
+
+// snapshotManifest is persisted as snapshots/index.json next to the store.
+type snapshotManifest struct {
+	Current   string     `json:"current"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// snapshotter manages full copies of a file-backed store under dir.
+type snapshotter struct {
+	dir       string
+	storePath string
+}
+
+func (s snapshotter) manifestPath() string { return filepath.Join(s.dir, "index.json") }
+
+func (s snapshotter) load() (snapshotManifest, error) {
+	var m snapshotManifest
+	by, err := os.ReadFile(s.manifestPath())
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(by, &m); err != nil {
+		return m, fmt.Errorf("parse snapshot index: %w", err)
+	}
+	return m, nil
+}
+
+func (s snapshotter) save(m snapshotManifest) error {
+	by, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.manifestPath(), by)
+}
+
+// record copies the current store file into a snapshot for version and makes it current.
+func (s snapshotter) record(version string, records int) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	if err := copyFileAtomic(s.storePath, filepath.Join(s.dir, version+".jsonl")); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range m.Snapshots {
+		if m.Snapshots[i].Version == version {
+			m.Snapshots[i].Records = records
+			found = true
+		}
+	}
+	if !found {
+		m.Snapshots = append(m.Snapshots, Snapshot{Version: version, CreatedAt: time.Now().UTC(), Records: records})
+	}
+	m.Current = version
+	return s.save(m)
+}
+
+func (s snapshotter) list() ([]Snapshot, error) {
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Snapshot, len(m.Snapshots))
+	for i, snap := range m.Snapshots {
+		snap.Current = snap.Version == m.Current
+		out[i] = snap
+	}
+	return out, nil
+}
+
+// resolve maps a full version, tag, or unique version prefix to a snapshot index.
+func (m snapshotManifest) resolve(ref string) (int, error) {
+	if ref == "" {
+		return -1, fmt.Errorf("version is required")
+	}
+	match := -1
+	for i, snap := range m.Snapshots {
+		if snap.Version == ref {
+			return i, nil
+		}
+		for _, t := range snap.Tags {
+			if t == ref {
+				return i, nil
+			}
+		}
+		if strings.HasPrefix(snap.Version, ref) {
+			if match >= 0 {
+				return -1, fmt.Errorf("version prefix %q is ambiguous", ref)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return -1, fmt.Errorf("memory version %q not found", ref)
+	}
+	return match, nil
+}
+
+func (s snapshotter) tag(version, tag string) error {
+	if strings.TrimSpace(tag) == "" {
+		return fmt.Errorf("tag is required")
+	}
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	idx, err := m.resolve(version)
+	if err != nil {
+		return err
+	}
+	// tags are unique across snapshots: move an existing tag to the new version
+	for i := range m.Snapshots {
+		kept := m.Snapshots[i].Tags[:0]
+		for _, t := range m.Snapshots[i].Tags {
+			if t != tag {
+				kept = append(kept, t)
+			}
+		}
+		m.Snapshots[i].Tags = kept
+	}
+	m.Snapshots[idx].Tags = append(m.Snapshots[idx].Tags, tag)
+	return s.save(m)
+}
+
+// rollback atomically replaces the store file with a snapshot. Snapshots are only
+// deleted by prune, so rolling forward again is possible until the next gc.
+func (s snapshotter) rollback(ref string) (string, error) {
+	m, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	idx, err := m.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	version := m.Snapshots[idx].Version
+	if err := copyFileAtomic(filepath.Join(s.dir, version+".jsonl"), s.storePath); err != nil {
+		return "", fmt.Errorf("restore snapshot %s: %w", version, err)
+	}
+	m.Current = version
+	return version, s.save(m)
+}
+
+// prune drops the snapshots older than the keepLast most recent ones, except
+// the current and tagged ones; keepLast 0 keeps them all. Snapshot files the
+// index does not list and stale temp files are removed as well. It returns
+// the snapshots dropped, the other files removed and the bytes freed.
+func (s snapshotter) prune(keepLast int) (snapshots, files int, freed int64, err error) {
+	m, err := s.load()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	dropped := map[string]bool{}
+	kept := m.Snapshots[:0]
+	for i, snap := range m.Snapshots {
+		if keepLast > 0 && i < len(m.Snapshots)-keepLast && snap.Version != m.Current && len(snap.Tags) == 0 {
+			dropped[snap.Version] = true
+			continue
+		}
+		kept = append(kept, snap)
+	}
+	if len(dropped) > 0 {
+		m.Snapshots = kept
+		if err := s.save(m); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	listed := map[string]bool{}
+	for _, snap := range kept {
+		listed[snap.Version] = true
+	}
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return len(dropped), 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	for _, e := range entries {
+		version, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if e.IsDir() || !ok || strings.HasPrefix(version, ".tmp-") || listed[version] {
+			continue
+		}
+		n, err := removeFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return len(dropped), files, freed, err
+		}
+		freed += n
+		if !dropped[version] {
+			files++
+		}
+	}
+	n, size, err := removeStaleTemps(s.dir)
+	return len(dropped), files + n, freed + size, err
+}
+
+// copyFileAtomic copies src to dst via a temp file and rename.
+func copyFileAtomic(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-"+filepath.Base(dst))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// writeFileAtomic writes data to path via a temp file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,63 @@
+package runtimememory
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLiteSnapshots_TagAndRollback(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: t.TempDir(), ComponentName: "CustomerDocs"})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer store.Close()
+	v1, err := store.IngestDocuments(ctx, []string{"Returns are accepted within 30 days."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := store.IngestDocuments(ctx, []string{"Error code E42 means the printer is out of toner."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := AsSnapshotStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := snaps.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Records != 1 || list[1].Records != 2 || !list[1].Current {
+		t.Fatalf("unexpected snapshots: %+v", list)
+	}
+	if err := snaps.TagSnapshot(ctx, v1[:12], "stable"); err != nil {
+		t.Fatalf("TagSnapshot: %v", err)
+	}
+	restored, err := snaps.Rollback(ctx, "stable")
+	if err != nil || restored != v1 {
+		t.Fatalf("Rollback = %q, %v", restored, err)
+	}
+	res, _ := store.Search(ctx, "printer toner", 5)
+	if len(res) != 1 {
+		t.Fatalf("expected only v1 records after rollback, got %d", len(res))
+	}
+	// v2 embeddings are preserved and can be restored
+	if _, err := snaps.Rollback(ctx, v2); err != nil {
+		t.Fatalf("roll forward: %v", err)
+	}
+	if res, _ := store.Search(ctx, "printer toner", 5); len(res) != 2 {
+		t.Fatalf("expected v2 records after roll forward, got %d", len(res))
+	}
+}
+
+func TestEpisodicStore_NoSnapshots(t *testing.T) {
+	store, err := NewStore(Config{Provider: "episodic", RootDir: t.TempDir(), ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := AsSnapshotStore(store); err == nil {
+		t.Fatalf("expected episodic store to report no snapshot support")
+	}
+}
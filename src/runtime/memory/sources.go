@@ -0,0 +1,182 @@
+package runtimememory
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source is a document file ingested as a unit. Path is relative to the
+// component's documents directory, using forward slashes.
+type Source struct {
+	Path    string
+	Content string
+	// Rows, when set, are ingested as separate documents (the rows of a CSV or
+	// JSONL file) instead of chunking Content as a whole.
+	Rows []string
+	// Metadata is attached to every record chunked from the source.
+	Metadata map[string]interface{}
+}
+
+// Documents returns the documents of the source: its rows, or its content.
+func (s Source) Documents() []string {
+	if s.Rows != nil {
+		return s.Rows
+	}
+	return []string{s.Content}
+}
+
+// chunks splits the source's documents into chunks; rows never share a chunk.
+func (s Source) chunks(size, overlap int) []string {
+	var out []string
+	for _, doc := range s.Documents() {
+		out = append(out, ChunkText(doc, size, overlap)...)
+	}
+	return out
+}
+
+// ValidSourcePath reports whether p is a clean relative source path, using
+// forward slashes, that stays inside the documents directory.
+func ValidSourcePath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, "\\") || path.Clean(p) != p {
+		return false
+	}
+	return p != "." && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// SourceStore is implemented by memory stores that remember which source file
+// each record came from, so files can be re-ingested or removed individually.
+type SourceStore interface {
+	// UpdateSources re-chunks each updated source, embedding only chunks whose
+	// content hash is not already stored for it, and drops the records of removed
+	// paths. A new memory version is recorded when anything changed. When ctx is
+	// cancelled mid-way the completed batches are still persisted and the result
+	// is returned together with ctx's error.
+	UpdateSources(ctx context.Context, updated []Source, removed []string) (IngestResult, error)
+
+	// SyncSources makes the store match sources exactly: like UpdateSources, and
+	// stored sources missing from the set are removed.
+	SyncSources(ctx context.Context, sources []Source) (IngestResult, error)
+}
+
+// IngestResult summarizes a per-source ingestion. Version is the new memory
+// version, or the current one when nothing changed.
+type IngestResult struct {
+	Version   string   `json:"version"`
+	Added     []string `json:"added,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+	// Chunk counts: newly embedded, kept from the previous version, and deleted.
+	ChunksEmbedded int `json:"chunks_embedded"`
+	ChunksReused   int `json:"chunks_reused"`
+	ChunksDeleted  int `json:"chunks_deleted"`
+	// ChunksPending counts chunks left unembedded because ingestion was interrupted.
+	ChunksPending int `json:"chunks_pending,omitempty"`
+	// Duplicate chunks found, by exact content or near-duplicate similarity,
+	// and the deduplication action applied to them (keep, skip or merge).
+	DuplicatesExact int    `json:"duplicates_exact,omitempty"`
+	DuplicatesNear  int    `json:"duplicates_near,omitempty"`
+	DedupeAction    string `json:"dedupe_action,omitempty"`
+	// ChunksMerged counts stored chunks whose duplicate_sources changed.
+	ChunksMerged int `json:"chunks_merged,omitempty"`
+}
+
+// Changed reports whether the ingestion modified the store.
+func (r IngestResult) Changed() bool {
+	return r.ChunksEmbedded > 0 || r.ChunksDeleted > 0 || r.ChunksMerged > 0
+}
+
+// AsSourceStore returns the per-source ingestion API of a store, or an error if
+// the provider does not support it.
+func AsSourceStore(store MemoryStore) (SourceStore, error) {
+	store = unwrapStore(store)
+	ss, ok := store.(SourceStore)
+	if !ok {
+		return nil, fmt.Errorf("memory provider does not support incremental ingestion")
+	}
+	return ss, nil
+}
+
+// FileStamp identifies a version of a document file on disk.
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// IsDocument reports whether a file name has a supported document extension
+// (see DocumentExtensions).
+func IsDocument(name string) bool {
+	return DocumentFormat(name) != ""
+}
+
+// ScanDocuments lists the supported documents under dir keyed by their Source
+// path. A missing directory yields no documents.
+func ScanDocuments(dir string) (map[string]FileStamp, error) {
+	out := map[string]FileStamp{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !IsDocument(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		out[filepath.ToSlash(rel)] = FileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadDocument returns the text of a document file, converted according to its
+// extension (see ReadSource); ok is false when a PDF cannot be converted in
+// this environment.
+func ReadDocument(path string) (text string, ok bool, err error) {
+	src, ok, err := ReadSource(path, ReadOptions{})
+	return src.Content, ok, err
+}
+
+// LoadSources reads the given Source paths from dir, skipping documents that
+// cannot be converted. Results are sorted by path.
+func LoadSources(dir string, paths []string) ([]Source, []string, error) {
+	return LoadSourcesWith(dir, paths, ReadOptions{})
+}
+
+// LoadSourcesWith is LoadSources with every document read according to opts.
+func LoadSourcesWith(dir string, paths []string, opts ReadOptions) ([]Source, []string, error) {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	var (
+		sources []Source
+		skipped []string
+	)
+	for _, p := range sorted {
+		src, ok, err := ReadSource(filepath.Join(dir, filepath.FromSlash(p)), opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			skipped = append(skipped, p)
+			continue
+		}
+		src.Path = p
+		sources = append(sources, src)
+	}
+	return sources, skipped, nil
+}
@@ -2,6 +2,7 @@ package runtimememory
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -9,24 +10,57 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"time"
 )
 
 type sqliteVectorStore struct {
+	component    string
+	tenantID     string
 	filePath     string
 	embeddingDim int
 	model        string
+	routes       embeddingRoutes
+	snapshots    snapshotter
+	searchMode   string
+	rrfK         int
+	index        string // SearchIndexFlat or SearchIndexHNSW
+	hnsw         hnswParams
+	chunkSize    int
+	chunkOverlap int
+	dedupe       dedupeConfig
+	embed        embedFunc
+	hosted       *hostedEmbedder // nil for the local hashing embedding
+	concurrency  int
+	batchSize    int
+	sealer       *sealer // encrypts record content at rest
+	freshness    FreshnessConfig
+	now          func() time.Time // clock for document validity; time.Now when nil
 }
 
 type vecRecord struct {
 	ID      string `json:"id"`
 	Content string `json:"content"`
 	Vector  string `json:"vector_b64"`
+	// Source is the document path the record was chunked from (per-source ingestion).
+	Source string `json:"source,omitempty"`
+	// Hash identifies the chunk content and embedding settings, so unchanged
+	// chunks are not re-embedded.
+	Hash string `json:"hash,omitempty"`
+	// Metadata is copied from the source (e.g. OCR confidence) into search
+	// results, with the detected language of the chunk.
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Model is the embedding model the chunk was routed to by its language;
+	// empty for the store's default model.
+	Model string `json:"model,omitempty"`
 }
 
 // item represents a scored vector match used internally for ranking
 type item struct {
 	id, content string
 	score       float64
+	meta        map[string]interface{}
 }
 
 func newSQLiteVectorStore(cfg Config) (MemoryStore, error) {
@@ -44,84 +78,663 @@ func newSQLiteVectorStore(cfg Config) (MemoryStore, error) {
 			return nil, err
 		}
 	}
-	return &sqliteVectorStore{filePath: filePath, embeddingDim: dim, model: cfg.EmbeddingModel}, nil
+	mode, rrfK := searchMode(cfg.Settings)
+	index, hnsw := searchIndexSettings(cfg.Settings)
+	chunkSize, chunkOverlap := chunkSettings(cfg.Settings)
+	concurrency, batchSize := embedSettings(cfg.Settings, cfg.EmbeddingModel)
+	sealer, err := newSealer(cfg.Settings["encryption"] == "true")
+	if err != nil {
+		return nil, fmt.Errorf("memory encryption: %w", err)
+	}
+	hosted, err := newHostedEmbedder(cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+	freshness, err := freshnessConfigFromSettings(cfg.Settings)
+	if err != nil {
+		return nil, err
+	}
+	embed := naiveEmbedBatch(dim)
+	if hosted != nil {
+		embed = hosted.embedFunc(cfg.EmbeddingModel, false)
+	}
+	return &sqliteVectorStore{
+		sealer:       sealer,
+		component:    cfg.ComponentName,
+		tenantID:     cfg.TenantID,
+		embed:        instrumentEmbed(embed, cfg.ComponentName, cfg.EmbeddingModel),
+		hosted:       hosted,
+		concurrency:  concurrency,
+		batchSize:    batchSize,
+		searchMode:   mode,
+		rrfK:         rrfK,
+		index:        index,
+		hnsw:         hnsw,
+		chunkSize:    chunkSize,
+		chunkOverlap: chunkOverlap,
+		dedupe:       dedupeSettings(cfg.Settings),
+		filePath:     filePath,
+		embeddingDim: dim,
+		model:        cfg.EmbeddingModel,
+		routes:       embeddingRoutesFromSettings(cfg.Settings, cfg.EmbeddingModel),
+		snapshots:    snapshotter{dir: filepath.Join(filepath.Dir(filePath), "snapshots"), storePath: filePath},
+		freshness:    freshness,
+	}, nil
 }
 
 func (s *sqliteVectorStore) Close() error { return nil }
 
+// IngestDocuments appends documents as records. Documents whose content hash is
+// already stored are skipped, so re-ingesting the same input embeds nothing; the
+// current version is returned when nothing new was added. Near duplicates are
+// skipped unless the dedupe action is keep. If ctx is cancelled while
+// embedding, the completed batches are persisted and ctx's error returned.
 func (s *sqliteVectorStore) IngestDocuments(ctx context.Context, documents []string) (string, error) {
 	if len(documents) == 0 {
 		return "", fmt.Errorf("no documents to ingest")
 	}
-	version := contentSHA(documents, s.model)
-	f, err := os.OpenFile(s.filePath, os.O_APPEND|os.O_WRONLY, 0o644)
+	defer observeIngest(s.component, time.Now())
+	existing, err := s.readRecords()
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
-	w := bufio.NewWriter(f)
+	dd := newDeduper(s.dedupe)
+	for i, rec := range existing {
+		dd.add(i, rec)
+	}
+	version := contentSHA(documents, s.model)
+	records := existing
+	var pending []int
 	for i, doc := range documents {
-		id := fmt.Sprintf("%s_%d", version, i)
-		vec := naiveEmbed(doc, s.embeddingDim)
-		rec := vecRecord{ID: id, Content: doc, Vector: base64.StdEncoding.EncodeToString(float64sToBytes(vec))}
-		by, _ := json.Marshal(rec)
-		if _, err := w.Write(by); err != nil {
-			return "", err
+		rec := s.newRecord(doc, nil)
+		if _, ok := dd.exact[rec.Hash]; ok {
+			continue
+		}
+		if j, _ := dd.match(rec); j >= 0 {
+			// documents have no source to merge, so merge skips them too
+			DuplicateChunks.WithLabelValues(s.component, "near").Inc()
+			if s.dedupe.action != DedupeKeep {
+				continue
+			}
 		}
-		if _, err := w.WriteString("\n"); err != nil {
-			return "", err
+		rec.ID = fmt.Sprintf("%s_%d", version, i)
+		dd.add(len(records), rec)
+		pending = append(pending, len(records))
+		records = append(records, rec)
+	}
+	records, embedded, embedErr := s.embedPending(ctx, records, pending)
+	if embedded == 0 {
+		if embedErr != nil {
+			return "", embedErr
 		}
+		return s.currentVersion(version)
 	}
-	if err := w.Flush(); err != nil {
+	if embedErr != nil {
+		// persist the completed batches under a version of what was stored
+		contents := make([]string, len(records))
+		for i, rec := range records {
+			contents[i] = rec.Content
+		}
+		version = contentSHA(contents, s.model)
+	}
+	if err := s.writeRecords(records); err != nil {
+		return "", err
+	}
+	s.observeRecords(records)
+	if err := s.snapshots.record(version, len(records)); err != nil {
 		return "", err
 	}
-	return version, nil
+	return version, embedErr
 }
 
-func (s *sqliteVectorStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
-	if topK <= 0 {
-		topK = 5
+// UpdateSources implements SourceStore. Chunks are matched to the stored records
+// of their source by content hash: unchanged chunks keep their embeddings, new
+// chunks are embedded, and chunks no longer present are deleted. The store file
+// is rewritten atomically and a snapshot recorded only when something changed.
+func (s *sqliteVectorStore) UpdateSources(ctx context.Context, updated []Source, removed []string) (IngestResult, error) {
+	var res IngestResult
+	defer observeIngest(s.component, time.Now())
+	existing, err := s.readRecords()
+	if err != nil {
+		return res, err
+	}
+	replace := make(map[string]bool, len(updated)+len(removed))
+	for _, src := range updated {
+		replace[src.Path] = true
+	}
+	for _, p := range removed {
+		replace[p] = true
+	}
+	// previous records of the replaced sources, by source and chunk hash
+	prev := map[string]map[string][]vecRecord{}
+	records := make([]vecRecord, 0, len(existing))
+	merged := map[string][]string{} // record ID -> stored duplicate_sources
+	for _, rec := range existing {
+		if ds := duplicateSources(rec); len(ds) > 0 {
+			merged[rec.ID] = ds
+		}
+		if rec.Source == "" || !replace[rec.Source] {
+			unmergeSources(&rec, replace)
+			records = append(records, rec)
+			continue
+		}
+		if prev[rec.Source] == nil {
+			prev[rec.Source] = map[string][]vecRecord{}
+		}
+		prev[rec.Source][rec.Hash] = append(prev[rec.Source][rec.Hash], rec)
+	}
+	dd := newDeduper(s.dedupe)
+	for i, rec := range records {
+		dd.add(i, rec)
+	}
+	res.DedupeAction = s.dedupe.action
+
+	var pending []int
+	for _, src := range updated {
+		old := prev[src.Path]
+		delete(prev, src.Path)
+		embedded := 0
+		for i, chunk := range src.chunks(s.chunkSize, s.chunkOverlap) {
+			rec := s.newRecord(chunk, src.Metadata)
+			if j, near := dd.match(rec); j >= 0 {
+				kind := "exact"
+				if near {
+					kind = "near"
+					res.DuplicatesNear++
+				} else {
+					res.DuplicatesExact++
+				}
+				DuplicateChunks.WithLabelValues(s.component, kind).Inc()
+				if s.dedupe.action == DedupeMerge {
+					mergeDuplicate(&records[j], src.Path)
+				}
+				if s.dedupe.action != DedupeKeep {
+					continue
+				}
+			}
+			if recs := old[rec.Hash]; len(recs) > 0 {
+				kept := recs[0]
+				unmergeSources(&kept, replace)
+				setDuplicateSources(&rec, duplicateSources(kept))
+				kept.Metadata = rec.Metadata
+				dd.add(len(records), kept)
+				records = append(records, kept)
+				old[rec.Hash] = recs[1:]
+				res.ChunksReused++
+				continue
+			}
+			rec.ID, rec.Source = fmt.Sprintf("%s_%d", rec.Hash[:16], i), src.Path
+			dd.add(len(records), rec)
+			pending = append(pending, len(records))
+			records = append(records, rec)
+			embedded++
+		}
+		deleted := 0
+		for _, recs := range old {
+			deleted += len(recs)
+		}
+		res.ChunksDeleted += deleted
+		switch {
+		case old == nil:
+			res.Added = append(res.Added, src.Path)
+		case embedded > 0 || deleted > 0:
+			res.Updated = append(res.Updated, src.Path)
+		default:
+			res.Unchanged = append(res.Unchanged, src.Path)
+		}
+	}
+	for _, p := range removed {
+		old, ok := prev[p]
+		if !ok {
+			continue
+		}
+		for _, recs := range old {
+			res.ChunksDeleted += len(recs)
+		}
+		res.Removed = append(res.Removed, p)
+	}
+
+	records, res.ChunksEmbedded, err = s.embedPending(ctx, records, pending)
+	res.ChunksPending = len(pending) - res.ChunksEmbedded
+	embedErr := err
+	for _, rec := range records {
+		if !slices.Equal(duplicateSources(rec), merged[rec.ID]) {
+			res.ChunksMerged++
+		}
+	}
+
+	contents := make([]string, len(records))
+	for i, rec := range records {
+		contents[i] = rec.Content
+	}
+	version := contentSHA(contents, s.model)
+	if !res.Changed() {
+		res.Version, err = s.currentVersion("")
+		if embedErr != nil {
+			return res, embedErr
+		}
+		return res, err
+	}
+	if err := s.writeRecords(records); err != nil {
+		return res, err
+	}
+	s.observeRecords(records)
+	if err := s.snapshots.record(version, len(records)); err != nil {
+		return res, err
+	}
+	res.Version = version
+	return res, embedErr
+}
+
+// SyncSources implements SourceStore: sources stored but missing from the given
+// set are removed.
+func (s *sqliteVectorStore) SyncSources(ctx context.Context, sources []Source) (IngestResult, error) {
+	existing, err := s.readRecords()
+	if err != nil {
+		return IngestResult{}, err
+	}
+	present := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		present[src.Path] = true
+	}
+	var removed []string
+	stale := map[string]bool{}
+	for _, rec := range existing {
+		if rec.Source != "" && !present[rec.Source] && !stale[rec.Source] {
+			stale[rec.Source] = true
+			removed = append(removed, rec.Source)
+		}
+	}
+	sort.Strings(removed)
+	return s.UpdateSources(ctx, sources, removed)
+}
+
+// chunkHash identifies a chunk's embedding: content, model and dimensions.
+// An empty model is the store's default model.
+func (s *sqliteVectorStore) chunkHash(content, model string) string {
+	if model == "" {
+		model = s.model
+	}
+	return contentSHA([]string{content, fmt.Sprintf("%d", s.embeddingDim)}, model)
+}
+
+// newRecord returns an unembedded record for a chunk, tagged with its detected
+// language and routed to that language's embedding model. meta is not modified.
+func (s *sqliteVectorStore) newRecord(content string, meta map[string]interface{}) vecRecord {
+	lang := DetectLanguage(content)
+	rec := vecRecord{Content: content, Metadata: meta}
+	if m := s.routes.modelFor(lang); m != s.model {
+		rec.Model = m
+	}
+	if lang != "" {
+		rec.Metadata = copyMetadata(meta)
+		if rec.Metadata == nil {
+			rec.Metadata = map[string]interface{}{}
+		}
+		rec.Metadata["language"] = lang
+	}
+	rec.Hash = s.chunkHash(content, rec.Model)
+	return rec
+}
+
+// embedderFor returns the embedding function of a model; empty is the default.
+func (s *sqliteVectorStore) embedderFor(model string) embedFunc {
+	if model == "" || model == s.model {
+		return s.embed
+	}
+	if s.hosted != nil {
+		return instrumentEmbed(s.hosted.embedFunc(model, false), s.component, model)
+	}
+	return instrumentEmbed(naiveEmbedBatch(s.embeddingDim), s.component, model)
+}
+
+// queryEmbedding embeds a search query with model; empty is the default.
+func (s *sqliteVectorStore) queryEmbedding(ctx context.Context, query, model string) ([]float64, error) {
+	if s.hosted == nil {
+		return naiveEmbed(query, s.embeddingDim), nil
+	}
+	if model == "" {
+		model = s.model
+	}
+	vecs, err := s.hosted.embedFunc(model, true)(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("embed query: got %d vectors", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+// embedPending embeds the records at the pending indexes on the worker pool.
+// Records whose batch did not complete are dropped, so an interrupted ingestion
+// keeps its finished batches and the next one embeds only the rest.
+func (s *sqliteVectorStore) embedPending(ctx context.Context, records []vecRecord, pending []int) ([]vecRecord, int, error) {
+	if len(pending) == 0 {
+		return records, 0, nil
+	}
+	// embed each model's chunks together, reporting progress over all of them
+	var models []string
+	byModel := map[string][]int{}
+	for _, idx := range pending {
+		m := records[idx].Model
+		if _, ok := byModel[m]; !ok {
+			models = append(models, m)
+		}
+		byModel[m] = append(byModel[m], idx)
+	}
+	progress, began, finished := progressFrom(ctx), time.Now(), 0
+	drop := map[int]bool{}
+	embedded := 0
+	var err error
+	for _, m := range models {
+		group := byModel[m]
+		texts := make([]string, len(group))
+		for i, idx := range group {
+			texts[i] = records[idx].Content
+		}
+		gctx := ctx
+		if progress != nil {
+			base := finished
+			gctx = WithEmbedProgress(ctx, func(p EmbedProgress) {
+				progress(EmbedProgress{Done: base + p.Done, Total: len(pending), Elapsed: time.Since(began)})
+			})
+		}
+		var vectors [][]float64
+		var done []bool
+		if err == nil {
+			vectors, done, err = embedParallel(gctx, texts, s.concurrency, s.batchSize, s.embedderFor(m))
+		} else {
+			done = make([]bool, len(group))
+		}
+		for i, idx := range group {
+			if !done[i] {
+				drop[idx] = true
+				continue
+			}
+			records[idx].Vector = base64.StdEncoding.EncodeToString(float64sToBytes(vectors[i]))
+			embedded++
+		}
+		finished += len(group)
+	}
+	if len(drop) == 0 {
+		return records, embedded, err
+	}
+	kept := records[:0]
+	for i, rec := range records {
+		if !drop[i] {
+			kept = append(kept, rec)
+		}
+	}
+	return kept, embedded, err
+}
+
+// currentVersion returns the current snapshot version, or fallback when none is recorded.
+func (s *sqliteVectorStore) currentVersion(fallback string) (string, error) {
+	m, err := s.snapshots.load()
+	if err != nil {
+		return "", err
 	}
-	qvec := naiveEmbed(query, s.embeddingDim)
+	if m.Current == "" {
+		return fallback, nil
+	}
+	return m.Current, nil
+}
+
+// readRecords loads all records with their content decrypted. Records written
+// before content hashing get their hash computed from the stored content.
+func (s *sqliteVectorStore) readRecords() ([]vecRecord, error) {
 	f, err := os.Open(s.filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	items := make([]item, 0, 64)
+	var records []vecRecord
 	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 64*1024), 16*1024*1024)
 	for scan.Scan() {
-		var rec vecRecord
-		if err := json.Unmarshal([]byte(scan.Text()), &rec); err != nil {
+		if len(scan.Bytes()) == 0 {
 			continue
 		}
-		vb, err := base64.StdEncoding.DecodeString(rec.Vector)
+		var rec vecRecord
+		if err := json.Unmarshal(scan.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", s.filePath, err)
+		}
+		content, err := s.sealer.open(rec.Content)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt record %s: %w", rec.ID, err)
+		}
+		rec.Content = content
+		if rec.Hash == "" {
+			rec.Hash = s.chunkHash(rec.Content, rec.Model)
+		}
+		records = append(records, rec)
+	}
+	return records, scan.Err()
+}
+
+// writeRecords replaces the store file, encrypting content when configured.
+func (s *sqliteVectorStore) writeRecords(records []vecRecord) error {
+	var buf bytes.Buffer
+	for _, rec := range records {
+		content, err := s.sealer.seal(rec.Content)
+		if err != nil {
+			return err
+		}
+		rec.Content = content
+		by, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		buf.Write(by)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(s.filePath, buf.Bytes())
+}
+
+// ListSnapshots implements SnapshotStore.
+func (s *sqliteVectorStore) ListSnapshots(ctx context.Context) ([]Snapshot, error) {
+	return s.snapshots.list()
+}
+
+// TagSnapshot implements SnapshotStore.
+func (s *sqliteVectorStore) TagSnapshot(ctx context.Context, version, tag string) error {
+	return s.snapshots.tag(version, tag)
+}
+
+// Rollback implements SnapshotStore.
+func (s *sqliteVectorStore) Rollback(ctx context.Context, version string) (string, error) {
+	return s.snapshots.rollback(version)
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n := 0
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scan.Scan() {
+		if len(scan.Bytes()) > 0 {
+			n++
+		}
+	}
+	return n, scan.Err()
+}
+
+// Search scores the query against the cached records of the store file. With
+// the HNSW index in vector mode only the approximate nearest neighbors are
+// scored; keyword and hybrid search rank every chunk.
+func (s *sqliteVectorStore) Search(ctx context.Context, query string, topK int) ([]SearchResult, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	// chunks are compared with the query embedded by their own model
+	qvecs := map[string][]float64{}
+	queryVector := func(model string) ([]float64, error) {
+		if v, ok := qvecs[model]; ok {
+			return v, nil
+		}
+		v, err := s.queryEmbedding(ctx, query, model)
+		if err != nil {
+			return nil, err
+		}
+		qvecs[model] = v
+		return v, nil
+	}
+	var queryLang string
+	if s.routes.routed() {
+		queryLang = DetectLanguage(query)
+	}
+	view, err := s.view()
+	if err != nil {
+		return nil, err
+	}
+	candidates := view.records
+	if s.index == SearchIndexHNSW && s.searchMode == SearchModeVector {
+		qvec, err := queryVector("")
 		if err != nil {
+			return nil, err
+		}
+		graph, nodes := view.ann(s.filePath, len(qvec), s.hnsw)
+		found := graph.search(normalize(qvec), topK, s.hnsw.EfSearch)
+		candidates = make([]viewRecord, len(found))
+		for i, n := range found {
+			candidates[i] = view.records[nodes[n]]
+		}
+	}
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	items := make([]item, 0, min(len(candidates), 64))
+	for _, rec := range candidates {
+		// Expired and not yet valid documents are never retrieved
+		if !s.freshness.IncludeInactive && !DocumentInEffect(rec.meta, now) {
 			continue
 		}
-		v := bytesToFloat64s(vb)
-		if len(v) != len(qvec) {
+		qvec, err := queryVector(rec.model)
+		if err != nil {
+			return nil, err
+		}
+		if len(rec.vec) != len(qvec) {
 			continue
 		}
-		score := cosine(qvec, v)
-		items = append(items, item{id: rec.ID, content: rec.Content, score: score})
+		content, err := s.sealer.open(rec.content)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt record %s: %w", rec.id, err)
+		}
+		score := cosine(qvec, rec.vec)
+		meta := copyMetadata(rec.meta)
+		if s.routes.routed() {
+			if meta == nil {
+				meta = map[string]interface{}{}
+			}
+			meta["embedding_model"] = s.model
+			if rec.model != "" {
+				meta["embedding_model"] = rec.model
+			}
+			if queryLang != "" {
+				meta["query_language"] = queryLang
+			}
+		}
+		items = append(items, item{id: rec.id, content: content, score: score, meta: meta})
 	}
-	if err := scan.Err(); err != nil {
-		return nil, err
+	s.observeIndex(len(view.records), view.sources, view.unsourced)
+	if s.searchMode != SearchModeVector {
+		items = s.rescoreKeyword(query, items)
+	}
+	if s.freshness.Boost > 0 {
+		for i := range items {
+			items[i].score *= s.freshness.factor(items[i].meta, now)
+		}
 	}
 	selectTopK(items, topK)
 	results := make([]SearchResult, 0, min(topK, len(items)))
 	for i := 0; i < min(topK, len(items)); i++ {
 		it := items[i]
-		results = append(results, SearchResult{ID: it.id, Content: it.content, Score: it.score})
+		results = append(results, SearchResult{ID: it.id, Content: it.content, Score: it.score, Metadata: it.meta})
 	}
+	SearchDepth.WithLabelValues(s.component).Observe(float64(len(results)))
 	return results, nil
 }
 
-func (s *sqliteVectorStore) Optimize(ctx context.Context, _ string) error { return nil }
+// rescoreKeyword applies BM25 keyword scoring (keyword mode) or fuses BM25 and
+// vector rankings with RRF (hybrid mode). Items matching neither are dropped.
+func (s *sqliteVectorStore) rescoreKeyword(query string, items []item) []item {
+	docs := make([]string, len(items))
+	vec := make([]float64, len(items))
+	for i, it := range items {
+		docs[i] = it.content
+		vec[i] = it.score
+	}
+	kw := bm25Scores(query, docs)
+	final := kw
+	if s.searchMode == SearchModeHybrid {
+		final = fuseRRF(s.rrfK, rankOf(vec), rankOf(kw))
+	}
+	out := items[:0]
+	for i, it := range items {
+		if final[i] <= 0 {
+			continue
+		}
+		if it.meta == nil {
+			it.meta = map[string]interface{}{}
+		}
+		it.meta["vector_score"], it.meta["keyword_score"], it.meta["search_mode"] = vec[i], kw[i], s.searchMode
+		it.score = final[i]
+		out = append(out, it)
+	}
+	return out
+}
+
+// copyMetadata returns a copy of a record's metadata, or nil when it has none.
+func copyMetadata(m map[string]interface{}) map[string]interface{} {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Optimize compacts the store file and builds its search index. Duplicate
+// records and stale embeddings are dropped, and records written before content
+// hashing get their hash stored, so they are no longer hashed on every ingest.
+// With the HNSW index the graph is built and saved next to the store, so
+// servers load it instead of building it on their first search; otherwise a
+// saved graph is removed. Snapshots are kept; GC drops old ones.
+func (s *sqliteVectorStore) Optimize(ctx context.Context, _ string) error {
+	if _, err := s.compact(); err != nil {
+		return err
+	}
+	if s.index != SearchIndexHNSW {
+		return nil
+	}
+	view, err := s.view()
+	if err != nil {
+		return err
+	}
+	dim := view.vectorDim(s.embeddingDim)
+	graph, nodes := view.ann(s.filePath, dim, s.hnsw)
+	return view.saveGraph(s.filePath, dim, graph, nodes)
+}
 
 // --- helpers ---
 
+// Similarity returns the cosine similarity of a and b under the store's local
+// hashing embedding, so callers outside the store can score text consistently.
+func Similarity(a, b string, dim int) float64 {
+	if dim <= 0 {
+		dim = 256
+	}
+	return cosine(naiveEmbed(a, dim), naiveEmbed(b, dim))
+}
+
 func naiveEmbed(text string, dim int) []float64 {
 	vec := make([]float64, dim)
 	var h uint64 = 1469598103934665603
@@ -0,0 +1,151 @@
+package runtimememory
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UserEpisodes is the episodic memory kept for one user in one component.
+type UserEpisodes struct {
+	Component string   `json:"component"`
+	Entries   []string `json:"entries"`
+}
+
+// UserConversation is one chat session of a user: its latest summary and
+// every turn still in the session log.
+type UserConversation struct {
+	Component string             `json:"component"`
+	SessionID string             `json:"session_id"`
+	Summary   string             `json:"summary,omitempty"`
+	Turns     []ConversationTurn `json:"turns"`
+}
+
+// userDir is the directory of a user's stores: memory/<component>/[tenant_<id>/]users/<user>.
+func userDir(root, component, tenantID, userID string) string {
+	return DerivePath(root, component, tenantID, filepath.Join("users", sanitize(userID)))
+}
+
+// ExportUserEpisodes returns the episodic memory stored for userID in every
+// component, decrypting logs of components with episodic encryption.
+func ExportUserEpisodes(root, tenantID, userID string) ([]UserEpisodes, error) {
+	components, err := userComponents(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UserEpisodes, 0, len(components))
+	for _, component := range components {
+		cfg := Config{RootDir: root, ComponentName: component}
+		if err := LoadComponentMemoryConfig(&cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		path := filepath.Join(userDir(root, component, tenantID, userID), "episodic", "episodes.log")
+		entries, err := readEpisodes(path, cfg.Settings["episodic_encryption"] == "true" || cfg.Settings["encryption"] == "true")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		out = append(out, UserEpisodes{Component: component, Entries: entries})
+	}
+	return out, nil
+}
+
+// ExportUserConversations returns the chat sessions stored for userID in
+// every component, decrypting them like OpenConversation does.
+func ExportUserConversations(root, tenantID, userID string) ([]UserConversation, error) {
+	components, err := userComponents(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := []UserConversation{}
+	for _, component := range components {
+		paths, err := filepath.Glob(filepath.Join(userDir(root, component, tenantID, userID), "conversations", "*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			session := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+			store, err := OpenConversation(root, component, tenantID, userID, session)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", component, err)
+			}
+			conv, err := store.history()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", component, err)
+			}
+			out = append(out, UserConversation{Component: component, SessionID: session, Summary: conv.Summary, Turns: conv.Turns})
+		}
+	}
+	return out, nil
+}
+
+// DeleteUserEpisodes removes every store kept for userID and returns the
+// components that had one.
+func DeleteUserEpisodes(root, tenantID, userID string) ([]string, error) {
+	components, err := userComponents(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, component := range components {
+		if err := os.RemoveAll(userDir(root, component, tenantID, userID)); err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+	}
+	return components, nil
+}
+
+// userComponents lists the components with a user directory for userID.
+func userComponents(root, tenantID, userID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, "memory"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var components []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if fi, err := os.Stat(userDir(root, e.Name(), tenantID, userID)); err == nil && fi.IsDir() {
+			components = append(components, e.Name())
+		}
+	}
+	sort.Strings(components)
+	return components, nil
+}
+
+// readEpisodes reads an episodic log; encrypted logs hold one encrypted batch per line.
+func readEpisodes(path string, encrypted bool) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := newSealer(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	entries := []string{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		batch, err := s.openEpisode(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", path, err)
+		}
+		for _, entry := range batch {
+			if strings.TrimSpace(entry) != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, sc.Err()
+}
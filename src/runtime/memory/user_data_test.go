@@ -0,0 +1,82 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestUserEpisodes_ExportAndDelete(t *testing.T) {
+	root := t.TempDir()
+	ingest := func(component, user string, docs ...string) {
+		t.Helper()
+		store, err := NewStore(Config{Provider: "episodic", RootDir: root, ComponentName: component, TenantID: "acme", UserID: user})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		if _, err := store.IngestDocuments(context.Background(), docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ingest("SupportBot", "u-1", "asked about refunds", "order 12345")
+	ingest("SalesBot", "u-1", "wants a demo")
+	ingest("SupportBot", "u-2", "someone else")
+
+	got, err := ExportUserEpisodes(root, "acme", "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Component != "SalesBot" || len(got[1].Entries) != 2 || got[1].Entries[1] != "order 12345" {
+		t.Fatalf("unexpected export %+v", got)
+	}
+	if other, _ := ExportUserEpisodes(root, "globex", "u-1"); len(other) != 0 {
+		t.Fatalf("other tenant sees the user's memory: %+v", other)
+	}
+
+	deleted, err := DeleteUserEpisodes(root, "acme", "u-1")
+	if err != nil || len(deleted) != 2 {
+		t.Fatalf("delete: %v %v", deleted, err)
+	}
+	if got, _ := ExportUserEpisodes(root, "acme", "u-1"); len(got) != 0 {
+		t.Fatalf("memory left after delete: %+v", got)
+	}
+	if got, _ := ExportUserEpisodes(root, "acme", "u-2"); len(got) != 1 {
+		t.Fatalf("other user's memory deleted: %+v", got)
+	}
+	if _, err := os.Stat(DerivePath(root, "SupportBot", "acme", "users")); err != nil {
+		t.Fatalf("users directory removed: %v", err)
+	}
+}
+
+func TestExportUserConversations_IncludesSummarizedTurns(t *testing.T) {
+	root := t.TempDir()
+	store, err := OpenConversation(root, "SupportBot", "acme", "u-1", "s-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []string{"one", "two", "three"} {
+		if err := store.Append(ConversationTurn{Query: q, Response: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conv, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summarize := func(context.Context, string) (string, error) { return "asked twice", nil }
+	if _, ok, err := store.Summarize(context.Background(), conv, SummaryPolicy{TriggerTurns: 1, KeepRecent: 1}, summarize); err != nil || !ok {
+		t.Fatalf("summarize: %v %v", ok, err)
+	}
+
+	got, err := ExportUserConversations(root, "acme", "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].SessionID != "s-1" || got[0].Summary != "asked twice" || len(got[0].Turns) != 3 || got[0].Turns[0].Query != "one" {
+		t.Fatalf("unexpected export %+v", got)
+	}
+	if other, _ := ExportUserConversations(root, "acme", "u-2"); len(other) != 0 {
+		t.Fatalf("other user sees the sessions: %+v", other)
+	}
+}
@@ -0,0 +1,190 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WatchEvent reports one incremental ingestion by a DocumentWatcher.
+type WatchEvent struct {
+	Component string
+	TenantID  string
+	Result    IngestResult
+	Skipped   []string
+	Err       error
+}
+
+// DocumentWatcher polls memory/<Component>/documents (and tenant_<id>/documents)
+// under Root and re-ingests only the files that were added, changed or deleted
+// since the previous poll.
+type DocumentWatcher struct {
+	Root string
+	// Interval between polls; defaults to 2s.
+	Interval time.Duration
+	// Provider is the memory provider used for ingestion; defaults to sqlite.
+	Provider string
+	// OnEvent is called after each ingestion attempt.
+	OnEvent func(WatchEvent)
+
+	state map[docsDir]map[string]FileStamp
+}
+
+type docsDir struct {
+	component string
+	tenantID  string
+	path      string
+}
+
+// Run polls until ctx is done. The first poll syncs each store with the files on
+// disk; only chunks whose content changed since the last ingestion are embedded.
+func (w *DocumentWatcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	w.Poll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			w.Poll(ctx)
+		}
+	}
+}
+
+// Poll scans all document directories once and ingests the differences against
+// the previous scan. Directories seen for the first time are synced in full.
+// Events are returned (and passed to OnEvent) for ingestions that changed a store
+// or failed.
+func (w *DocumentWatcher) Poll(ctx context.Context) []WatchEvent {
+	if w.state == nil {
+		w.state = map[docsDir]map[string]FileStamp{}
+	}
+	var events []WatchEvent
+	for _, dir := range w.discover() {
+		files, err := ScanDocuments(dir.path)
+		if err != nil {
+			events = append(events, w.emit(WatchEvent{Component: dir.component, TenantID: dir.tenantID, Err: err}))
+			continue
+		}
+		prev, known := w.state[dir]
+		if !known {
+			all := make([]string, 0, len(files))
+			for p := range files {
+				all = append(all, p)
+			}
+			ev := w.ingest(ctx, dir, all, nil, true)
+			if ev.Err == nil {
+				w.state[dir] = files
+			}
+			if ev.Err != nil || ev.Result.Changed() {
+				events = append(events, w.emit(ev))
+			}
+			continue
+		}
+		var changed, removed []string
+		for p, st := range files {
+			if old, ok := prev[p]; !ok || !old.ModTime.Equal(st.ModTime) || old.Size != st.Size {
+				changed = append(changed, p)
+			}
+		}
+		for p := range prev {
+			if _, ok := files[p]; !ok {
+				removed = append(removed, p)
+			}
+		}
+		if len(changed) == 0 && len(removed) == 0 {
+			continue
+		}
+		sort.Strings(removed)
+		ev := w.ingest(ctx, dir, changed, removed, false)
+		if ev.Err == nil {
+			w.state[dir] = files
+		}
+		if ev.Err != nil || ev.Result.Changed() {
+			events = append(events, w.emit(ev))
+		}
+	}
+	return events
+}
+
+func (w *DocumentWatcher) emit(ev WatchEvent) WatchEvent {
+	if w.OnEvent != nil {
+		w.OnEvent(ev)
+	}
+	return ev
+}
+
+// ingest updates the store of dir with the changed and removed files, or syncs it
+// with the changed files when sync is set.
+func (w *DocumentWatcher) ingest(ctx context.Context, dir docsDir, changed, removed []string, sync bool) WatchEvent {
+	ev := WatchEvent{Component: dir.component, TenantID: dir.tenantID}
+	sources, skipped, err := LoadSourcesWith(dir.path, changed, ReadOptions{OCR: ComponentOCRConfig(w.Root, dir.component)})
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	ev.Skipped = skipped
+	if len(sources) == 0 && len(removed) == 0 && !sync {
+		return ev
+	}
+	provider := w.Provider
+	if provider == "" {
+		provider = "sqlite"
+	}
+	store, err := NewStore(Config{Provider: provider, RootDir: w.Root, ComponentName: dir.component, TenantID: dir.tenantID})
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	defer store.Close()
+	ss, err := AsSourceStore(store)
+	if err != nil {
+		ev.Err = err
+		return ev
+	}
+	if sync {
+		ev.Result, ev.Err = ss.SyncSources(ctx, sources)
+	} else {
+		ev.Result, ev.Err = ss.UpdateSources(ctx, sources, removed)
+	}
+	return ev
+}
+
+// discover lists the document directories of every component and tenant.
+func (w *DocumentWatcher) discover() []docsDir {
+	var dirs []docsDir
+	components, _ := os.ReadDir(filepath.Join(w.Root, "memory"))
+	for _, c := range components {
+		if !c.IsDir() {
+			continue
+		}
+		base := filepath.Join(w.Root, "memory", c.Name())
+		if isDir(filepath.Join(base, "documents")) {
+			dirs = append(dirs, docsDir{component: c.Name(), path: filepath.Join(base, "documents")})
+		}
+		entries, _ := os.ReadDir(base)
+		for _, e := range entries {
+			if e.IsDir() && strings.HasPrefix(e.Name(), "tenant_") && isDir(filepath.Join(base, e.Name(), "documents")) {
+				dirs = append(dirs, docsDir{
+					component: c.Name(),
+					tenantID:  strings.TrimPrefix(e.Name(), "tenant_"),
+					path:      filepath.Join(base, e.Name(), "documents"),
+				})
+			}
+		}
+	}
+	return dirs
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
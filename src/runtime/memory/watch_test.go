@@ -0,0 +1,102 @@
+package runtimememory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	if got := ChunkText("  short text ", 100, 10); len(got) != 1 || got[0] != "short text" {
+		t.Fatalf("short text = %q", got)
+	}
+	text := strings.Repeat("alpha beta gamma delta ", 20) + "\n\n" + strings.Repeat("epsilon zeta ", 20)
+	chunks := ChunkText(text, 120, 20)
+	if len(chunks) < 4 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if len([]rune(c)) > 120 {
+			t.Fatalf("chunk longer than size: %d", len([]rune(c)))
+		}
+		if strings.HasPrefix(c, "lpha") || strings.HasSuffix(c, "gam") {
+			t.Fatalf("chunk split inside a word: %q", c)
+		}
+	}
+}
+
+func TestDocumentWatcher_IngestsOnlyChanges(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	docs := filepath.Join(root, "memory", "Docs", "documents")
+	if err := os.MkdirAll(filepath.Join(docs, "faq"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(docs, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("returns.md", "Returns are accepted within 30 days.")
+	write("faq/printer.txt", "Error E42 means the printer is out of toner.")
+	write("notes.xlsx", "ignored")
+
+	store, err := NewStore(Config{Provider: "sqlite", RootDir: root, ComponentName: "Docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ss, err := AsSourceStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources, _, err := LoadSources(docs, []string{"returns.md", "faq/printer.txt"})
+	if err != nil || len(sources) != 2 {
+		t.Fatalf("LoadSources = %d, %v", len(sources), err)
+	}
+	if _, err := ss.UpdateSources(ctx, sources, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []WatchEvent
+	w := &DocumentWatcher{Root: root, OnEvent: func(ev WatchEvent) { seen = append(seen, ev) }}
+	if evs := w.Poll(ctx); len(evs) != 0 {
+		t.Fatalf("startup sync of unchanged files should not ingest, got %+v", evs)
+	}
+
+	write("returns.md", "Returns are accepted within 60 days of delivery.")
+	if err := os.Remove(filepath.Join(docs, "faq", "printer.txt")); err != nil {
+		t.Fatal(err)
+	}
+	evs := w.Poll(ctx)
+	if len(evs) != 1 || len(seen) != 1 {
+		t.Fatalf("expected one event, got %+v", evs)
+	}
+	ev := evs[0]
+	if ev.Err != nil || ev.Component != "Docs" || ev.Result.Version == "" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if strings.Join(ev.Result.Updated, ",") != "returns.md" || strings.Join(ev.Result.Removed, ",") != "faq/printer.txt" {
+		t.Fatalf("unexpected changes updated=%v removed=%v", ev.Result.Updated, ev.Result.Removed)
+	}
+
+	res, err := store.Search(ctx, "returns accepted days", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || !strings.Contains(res[0].Content, "60 days") {
+		t.Fatalf("expected only the updated returns record, got %+v", res)
+	}
+	snaps, _ := AsSnapshotStore(store)
+	list, _ := snaps.ListSnapshots(ctx)
+	if len(list) != 2 || list[1].Version != ev.Result.Version || !list[1].Current {
+		t.Fatalf("expected a new current snapshot, got %+v", list)
+	}
+
+	if evs := w.Poll(ctx); len(evs) != 0 {
+		t.Fatalf("unchanged poll should be a no-op, got %+v", evs)
+	}
+}
@@ -0,0 +1,234 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Provider modes selected with CMP_PROVIDER_MODE.
+const (
+	// ModeLive calls providers directly (default).
+	ModeLive = "live"
+	// ModeRecord calls providers and stores their outputs in the cassette.
+	ModeRecord = "record"
+	// ModeReplay serves outputs from the cassette without calling providers.
+	ModeReplay = "replay"
+)
+
+// DefaultCassetteDir is the project-relative directory for cassette files.
+const DefaultCassetteDir = "tests/cassettes"
+
+// ErrCassetteMiss is returned in replay mode when no recording matches a call.
+var ErrCassetteMiss = errors.New("no cassette recording for prompt")
+
+// ProviderMode returns the mode from CMP_PROVIDER_MODE, defaulting to live.
+func ProviderMode() string {
+	switch m := strings.ToLower(strings.TrimSpace(os.Getenv("CMP_PROVIDER_MODE"))); m {
+	case ModeRecord, ModeReplay:
+		return m
+	}
+	return ModeLive
+}
+
+// CassettePath returns the cassette file used for record/replay:
+// $CMP_CASSETTE_DIR/$CMP_CASSETTE.json. The directory defaults to
+// tests/cassettes under CMP_PROJECT_ROOT (or the working directory), the name
+// to "default".
+func CassettePath() string {
+	dir := os.Getenv("CMP_CASSETTE_DIR")
+	if dir == "" {
+		root := os.Getenv("CMP_PROJECT_ROOT")
+		if root == "" {
+			root, _ = os.Getwd()
+		}
+		dir = filepath.Join(root, DefaultCassetteDir)
+	}
+	name := os.Getenv("CMP_CASSETTE")
+	if name == "" {
+		name = "default"
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// Recording is a captured generation call.
+type Recording struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+}
+
+// Cassette maps prompt hashes to recorded responses and is persisted as JSON.
+// It is safe for concurrent use; cassettes are shared per file within a process.
+type Cassette struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]Recording
+}
+
+var (
+	cassettesMu sync.Mutex
+	cassettes   = map[string]*Cassette{}
+)
+
+// OpenCassette loads the cassette at path, or starts an empty one when the file
+// does not exist.
+func OpenCassette(path string) (*Cassette, error) {
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+	if c, ok := cassettes[path]; ok {
+		return c, nil
+	}
+	c := &Cassette{path: path, entries: map[string]Recording{}}
+	by, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(by) > 0 {
+		if err := json.Unmarshal(by, &c.entries); err != nil {
+			return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+		}
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+// CassetteKey hashes a prompt and its sampling parameters.
+func CassetteKey(input string, params Params) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%g|%g|%d|%g\n", params.Temperature, params.TopP, params.MaxNewTokens, params.RepetitionPen)
+	// Only hashed when set, so cassettes recorded before these existed still match
+	if len(params.Stop) > 0 || params.ResponseFormat != nil || len(params.Tools) > 0 || params.ToolChoice != "" {
+		by, _ := json.Marshal([]interface{}{params.Stop, params.ResponseFormat, params.Tools, params.ToolChoice})
+		h.Write(append(by, '\n'))
+	}
+	h.Write([]byte(input))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Lookup returns the recording for key.
+func (c *Cassette) Lookup(key string) (Recording, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[key]
+	return r, ok
+}
+
+// Record stores a recording and rewrites the cassette file.
+func (c *Cassette) Record(key string, r Recording) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = r
+	by, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, by, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// RecordingProvider records or replays the calls of the wrapped provider.
+type RecordingProvider struct {
+	inner    Provider
+	mode     string
+	cassette *Cassette
+}
+
+// NewRecordingProvider wraps inner in record or replay mode. inner may be nil in
+// replay mode.
+func NewRecordingProvider(inner Provider, mode string, cassette *Cassette) *RecordingProvider {
+	return &RecordingProvider{inner: inner, mode: mode, cassette: cassette}
+}
+
+// WithProviderMode applies CMP_PROVIDER_MODE to p. In live mode p is returned
+// unchanged; in replay mode a nil p still serves recorded outputs.
+func WithProviderMode(p Provider) (Provider, error) {
+	mode := ProviderMode()
+	if mode == ModeLive || (p == nil && mode == ModeRecord) {
+		return p, nil
+	}
+	c, err := OpenCassette(CassettePath())
+	if err != nil {
+		return p, err
+	}
+	return NewRecordingProvider(p, mode, c), nil
+}
+
+// GenerateWithTools replays or records the wrapped provider's completion,
+// stored as JSON in the recording's response.
+func (r *RecordingProvider) GenerateWithTools(ctx context.Context, input string, params Params) (Completion, error) {
+	key := CassetteKey(input, params)
+	var c Completion
+	if r.mode == ModeReplay {
+		rec, ok := r.cassette.Lookup(key)
+		if !ok {
+			return c, fmt.Errorf("%w (key %s, cassette %s)", ErrCassetteMiss, key[:12], r.cassette.path)
+		}
+		if err := json.Unmarshal([]byte(rec.Response), &c); err != nil {
+			return c, fmt.Errorf("cassette entry %s is not a completion: %w", key[:12], err)
+		}
+		return c, nil
+	}
+	c, err := Complete(ctx, r.inner, input, params)
+	if err != nil {
+		return c, err
+	}
+	by, _ := json.Marshal(c)
+	if err := r.cassette.Record(key, Recording{Prompt: input, Response: string(by)}); err != nil {
+		return c, fmt.Errorf("write cassette: %w", err)
+	}
+	return c, nil
+}
+
+func (r *RecordingProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	return r.GenerateStream(ctx, input, params, nil)
+}
+
+// GenerateStream replays a recording as a single chunk, or records the wrapped
+// provider's output (streamed when it supports streaming).
+func (r *RecordingProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	key := CassetteKey(input, params)
+	if r.mode == ModeReplay {
+		rec, ok := r.cassette.Lookup(key)
+		if !ok {
+			return "", fmt.Errorf("%w (key %s, cassette %s)", ErrCassetteMiss, key[:12], r.cassette.path)
+		}
+		if onToken != nil {
+			if err := onToken(rec.Response); err != nil {
+				return "", err
+			}
+		}
+		return rec.Response, nil
+	}
+	var (
+		out string
+		err error
+	)
+	if sp, ok := r.inner.(StreamingProvider); ok && onToken != nil {
+		out, err = sp.GenerateStream(ctx, input, params, onToken)
+	} else {
+		out, err = r.inner.Generate(ctx, input, params)
+		if err == nil && onToken != nil {
+			err = onToken(out)
+		}
+	}
+	if err != nil {
+		return out, err
+	}
+	if err := r.cassette.Record(key, Recording{Prompt: input, Response: out}); err != nil {
+		return out, fmt.Errorf("write cassette: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type countingProvider struct {
+	out   string
+	calls int
+}
+
+func (c *countingProvider) Generate(context.Context, string, Params) (string, error) {
+	c.calls++
+	return c.out, nil
+}
+
+func TestRecordingProvider_RecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CMP_CASSETTE_DIR", dir)
+	t.Setenv("CMP_CASSETTE", "record_replay")
+	t.Setenv("CMP_PROVIDER_MODE", "record")
+
+	inner := &countingProvider{out: "recorded answer"}
+	p, err := WithProviderMode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Params{Temperature: 0.2, MaxNewTokens: 64}
+	if out, err := p.Generate(context.Background(), "prompt A", params); err != nil || out != "recorded answer" {
+		t.Fatalf("record Generate = %q, %v", out, err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected live call while recording, got %d", inner.calls)
+	}
+
+	// A fresh process reads the file back; drop the in-process cache to simulate it
+	path := filepath.Join(dir, "record_replay.json")
+	cassettesMu.Lock()
+	delete(cassettes, path)
+	cassettesMu.Unlock()
+
+	t.Setenv("CMP_PROVIDER_MODE", "replay")
+	replay, err := WithProviderMode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []string
+	out, err := replay.(StreamingProvider).GenerateStream(context.Background(), "prompt A", params, func(s string) error {
+		chunks = append(chunks, s)
+		return nil
+	})
+	if err != nil || out != "recorded answer" || len(chunks) != 1 {
+		t.Fatalf("replay = %q, %v, chunks %v", out, err, chunks)
+	}
+	if _, err := replay.Generate(context.Background(), "prompt A", Params{Temperature: 0.9}); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("different params should miss, got %v", err)
+	}
+}
+
+func TestWithProviderMode_LiveIsPassthrough(t *testing.T) {
+	t.Setenv("CMP_PROVIDER_MODE", "")
+	inner := &countingProvider{}
+	p, err := WithProviderMode(inner)
+	if err != nil || p != Provider(inner) {
+		t.Fatalf("live mode should return the provider unchanged, got %T, %v", p, err)
+	}
+	if p, _ := WithProviderMode(nil); p != nil {
+		t.Fatalf("live mode with no provider should stay nil, got %T", p)
+	}
+}
+
+func TestRouterReplayNeedsNoCredentials(t *testing.T) {
+	t.Setenv("CMP_CASSETTE_DIR", t.TempDir())
+	t.Setenv("CMP_PROVIDER_MODE", "replay")
+	t.Setenv("HF_TOKEN", "")
+	cfg := &RoutingConfig{
+		Providers: map[string]ProviderSpec{"hf": {Type: "huggingface", Model: "m"}},
+		Default:   []string{"hf"},
+	}
+	r, err := NewRouter(cfg, nil)
+	if err != nil {
+		t.Fatalf("replay routing should not require HF_TOKEN: %v", err)
+	}
+	if _, err := r.For("", "").Generate(context.Background(), "unrecorded", Params{}); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("expected cassette miss, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrProviderBusy is returned without calling a provider when its concurrency
+// limit is reached and no slot freed up within the provider's max wait.
+var ErrProviderBusy = errors.New("provider busy")
+
+// AllProvidersBusy reports whether err is ErrProviderBusy for every provider
+// it covers. A fallback chain joins the error of each attempted provider, so
+// errors.Is alone would also match a chain where only one of them was busy.
+func AllProvidersBusy(err error) bool {
+	if err == nil {
+		return false
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := joined.Unwrap()
+		for _, e := range errs {
+			if !AllProvidersBusy(e) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	}
+	if errors.Is(err, ErrProviderBusy) {
+		return true
+	}
+	return AllProvidersBusy(errors.Unwrap(err))
+}
+
+// defaultProviderMaxWait is how long a call waits for a slot when max_wait is
+// not set.
+const defaultProviderMaxWait = 30 * time.Second
+
+var (
+	// ProviderQueueDepth is the number of calls waiting for a slot, by provider.
+	ProviderQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_provider_queue_depth",
+		Help: "Calls waiting for a concurrency slot of the provider.",
+	}, []string{"provider"})
+	// ProviderQueueWait observes how long calls waited for a slot, by provider.
+	ProviderQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_provider_queue_wait_seconds",
+		Help:    "Time calls to a concurrency-limited provider waited for a slot.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 4, 8),
+	}, []string{"provider"})
+	// ProviderBusyRejections counts calls that gave up waiting for a slot.
+	ProviderBusyRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_provider_busy_rejections_total",
+		Help: "Calls rejected because the provider had no free slot within its max wait.",
+	}, []string{"provider"})
+)
+
+// concurrencyLimit caps the calls in flight to one provider. Calls over the
+// cap wait in arrival order for up to maxWait, or until their context ends
+// when maxWait is 0.
+type concurrencyLimit struct {
+	provider string
+	slots    chan struct{}
+	maxWait  time.Duration
+}
+
+// newConcurrencyLimit returns the limit of a provider, or nil when max is 0.
+// maxWait is a Go duration; empty means defaultProviderMaxWait.
+func newConcurrencyLimit(provider string, max int, maxWait string) (*concurrencyLimit, error) {
+	if max < 0 {
+		return nil, fmt.Errorf("max_concurrent must not be negative")
+	}
+	if max == 0 {
+		return nil, nil
+	}
+	l := &concurrencyLimit{provider: provider, slots: make(chan struct{}, max), maxWait: defaultProviderMaxWait}
+	if maxWait != "" {
+		d, err := time.ParseDuration(maxWait)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid max_wait %q", maxWait)
+		}
+		l.maxWait = d
+	}
+	return l, nil
+}
+
+// envConcurrencyLimit returns the limit of the environment provider, set with
+// CMP_PROVIDER_MAX_CONCURRENT and CMP_PROVIDER_MAX_WAIT. Invalid values are
+// ignored.
+func envConcurrencyLimit() *concurrencyLimit {
+	n, err := strconv.Atoi(os.Getenv("CMP_PROVIDER_MAX_CONCURRENT"))
+	if err != nil {
+		return nil
+	}
+	l, err := newConcurrencyLimit(DefaultProviderName, n, os.Getenv("CMP_PROVIDER_MAX_WAIT"))
+	if err != nil {
+		l, _ = newConcurrencyLimit(DefaultProviderName, n, "")
+	}
+	return l
+}
+
+// acquire takes a slot, waiting for one if all are taken. The returned
+// function frees the slot. A nil limit never blocks.
+func (l *concurrencyLimit) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	defer func() { ProviderQueueWait.WithLabelValues(l.provider).Observe(time.Since(start).Seconds()) }()
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+	ProviderQueueDepth.WithLabelValues(l.provider).Inc()
+	defer ProviderQueueDepth.WithLabelValues(l.provider).Dec()
+	var timeout <-chan time.Time
+	if l.maxWait > 0 {
+		t := time.NewTimer(l.maxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		ProviderBusyRejections.WithLabelValues(l.provider).Inc()
+		return nil, fmt.Errorf("%w: no free slot within %s", ErrProviderBusy, l.maxWait)
+	}
+}
+
+func (l *concurrencyLimit) release() { <-l.slots }
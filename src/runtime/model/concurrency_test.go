@@ -0,0 +1,143 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// gatedProvider signals each call on started and answers once release is closed.
+type gatedProvider struct {
+	out     string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p gatedProvider) Generate(ctx context.Context, _ string, _ Params) (string, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+		return p.out, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestConcurrencyLimit_QueuesThenFallsBackWhenBusy(t *testing.T) {
+	limit, err := newConcurrencyLimit("local", 1, "50ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate := gatedProvider{out: "local", started: make(chan struct{}, 2), release: make(chan struct{})}
+	local := target{name: "local", provider: gate, limit: limit}
+	r := testRouter(map[string]target{
+		"local":  local,
+		"remote": {name: "remote", provider: staticProvider{out: "remote"}},
+	}, []string{"local", "remote"}, nil)
+
+	first := make(chan string)
+	go func() {
+		out, _ := r.For("", "").Generate(context.Background(), "q", Params{})
+		first <- out
+	}()
+	<-gate.started
+
+	// The slot is taken for longer than max_wait, so the chain moves on
+	fp := r.For("", "")
+	out, err := fp.Generate(context.Background(), "q", Params{})
+	if err != nil || out != "remote" || fp.Served().Attempts != 2 {
+		t.Fatalf("expected the fallback to serve, got %q, %v, %+v", out, err, fp.Served())
+	}
+	if _, err := r.Named("local").Generate(context.Background(), "q", Params{}); !errors.Is(err, ErrProviderBusy) {
+		t.Fatalf("expected ErrProviderBusy, got %v", err)
+	}
+
+	// A call that finds the slot taken gets it once the first call finishes
+	second := make(chan error)
+	go func() {
+		_, err := r.Named("local").Generate(context.Background(), "q", Params{})
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(gate.release)
+	if out := <-first; out != "local" {
+		t.Fatalf("first call = %q", out)
+	}
+	if err := <-second; err != nil {
+		t.Fatalf("queued call failed: %v", err)
+	}
+}
+
+func TestConcurrencyLimit_WaitsUntilDeadlineWithoutMaxWait(t *testing.T) {
+	limit, err := newConcurrencyLimit("local", 1, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := limit.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limit.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request deadline, got %v", err)
+	}
+}
+
+func TestAllProvidersBusy(t *testing.T) {
+	busy := fmt.Errorf("%w: no free slot within 50ms", ErrProviderBusy)
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"single busy", fmt.Errorf("local: %w", busy), true},
+		{"all busy", errors.Join(fmt.Errorf("local: %w", busy), fmt.Errorf("remote: %w", busy)), true},
+		{"busy then failed", errors.Join(fmt.Errorf("local: %w", busy), errors.New("remote: 500")), false},
+		{"failed", errors.New("remote: 500"), false},
+		{"nil", nil, false},
+	}
+	for _, c := range cases {
+		if got := AllProvidersBusy(c.err); got != c.want {
+			t.Errorf("%s: AllProvidersBusy = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestRoutingConfig_ConcurrencyLimits(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, RoutingFile)
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	yaml := "providers:\n  a:\n    type: mock\n    max_concurrent: 2\n    max_wait: 5s\n  b:\n    type: mock\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadRoutingConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRouter(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := r.targets["a"].limit; l == nil || cap(l.slots) != 2 || l.maxWait != 5*time.Second {
+		t.Fatalf("unexpected limit %+v", l)
+	}
+	if r.targets["b"].limit != nil {
+		t.Fatal("providers without max_concurrent are unlimited")
+	}
+	variant, err := r.variant("a", "other-model")
+	if err != nil || variant.limit != r.targets["a"].limit {
+		t.Fatalf("a model override must share the provider's slots: %v", err)
+	}
+
+	cfg.Providers["b"] = ProviderSpec{Type: "mock", MaxConcurrent: 1, MaxWait: "soon"}
+	if _, err := NewRouter(cfg, nil); err == nil {
+		t.Fatal("expected an invalid max_wait to be rejected")
+	}
+}
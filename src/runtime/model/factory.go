@@ -4,16 +4,62 @@ import "os"
 
 // FromEnv returns a Provider when environment variables are configured,
 // or nil when no provider is configured. Supported variables:
-//   - Local first via CMP_LOCAL_MODELS=true (uses local provider)
-//   - HF_TOKEN, HF_MODEL_ID[, HF_ENDPOINT] for Hugging Face Inference API.
+//   - CMP_MOCK_PROVIDERS=true (or features.mock_providers) for scripted mock
+//     responses from CMP_MOCK_SCRIPT / config/providers/mock.yaml
+//   - Ollama when OLLAMA_HOST is set or providers.ollama is configured in the
+//     active environment config (model from OLLAMA_MODEL or providers.ollama.model)
+//   - Local first via CMP_LOCAL_MODELS=true (uses local provider; set
+//     CMP_LOCAL_BACKEND=llamacpp to run GGUF models with llama.cpp)
+//   - HF_TOKEN, HF_MODEL_ID[, HF_ENDPOINT] for Hugging Face Inference API, or
+//     HF_ENDPOINT[, HF_ENDPOINT_TYPE=endpoint|tgi] for a dedicated Inference
+//     Endpoint or TGI server, with retries and a circuit breaker (see
+//     ResilienceConfigFromEnv).
+//
+// CMP_PROVIDER_MODE=record|replay wraps the provider with a cassette (see
+// WithProviderMode); replay works without any provider configured.
 func FromEnv() (Provider, error) {
+	if mockProvidersEnabled() {
+		prov, err := NewMockProviderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return WithProviderMode(prov)
+	}
+	if prov := NewOllamaProviderFromEnv(); prov != nil {
+		return WithProviderMode(prov)
+	}
 	if os.Getenv("CMP_LOCAL_MODELS") == "true" {
 		if prov, err := NewLocalProviderFromEnv(); err == nil {
-			return prov, nil
+			return WithProviderMode(prov)
 		}
 	}
-	if os.Getenv("HF_TOKEN") != "" && os.Getenv("HF_MODEL_ID") != "" {
-		return NewHuggingFaceAPIProviderFromEnv()
+	if hfConfigured() {
+		prov, err := NewHuggingFaceAPIProviderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return WithProviderMode(WithResilience(prov, prov.Model(), ResilienceConfigFromEnv()))
 	}
-	return nil, nil
+	return WithProviderMode(nil)
+}
+
+// ProviderKindFromEnv reports which provider FromEnv selects, without creating
+// it: "mock", "ollama", "local", "huggingface", or "" when none is configured.
+func ProviderKindFromEnv() string {
+	switch {
+	case mockProvidersEnabled():
+		return "mock"
+	case ollamaConfigured():
+		return "ollama"
+	case os.Getenv("CMP_LOCAL_MODELS") == "true":
+		return "local"
+	case hfConfigured():
+		return "huggingface"
+	}
+	return ""
+}
+
+func ollamaConfigured() bool {
+	_, ok := loadOllamaConfig()
+	return ok
 }
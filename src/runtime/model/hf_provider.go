@@ -1,88 +1,331 @@
 package model
 
 import (
+    "bufio"
     "bytes"
     "context"
     "encoding/json"
     "fmt"
     "net/http"
+    "net/url"
     "os"
+    "strings"
     "time"
 )
 
-// HuggingFaceAPIProvider calls the HF Inference API for text generation.
+// Hugging Face endpoint types, selected with HF_ENDPOINT_TYPE or a routed
+// provider's endpoint_type.
+const (
+    // HFInferenceAPI is the serverless Inference API: HF_ENDPOINT is a base URL
+    // and requests go to <endpoint>/<model>.
+    HFInferenceAPI = "inference-api"
+    // HFInferenceEndpoint is a dedicated Inference Endpoint: HF_ENDPOINT is the
+    // endpoint URL, which serves a single model.
+    HFInferenceEndpoint = "endpoint"
+    // HFTGI is a self-hosted Text Generation Inference server: requests go to
+    // <endpoint>/generate and <endpoint>/generate_stream.
+    HFTGI = "tgi"
+)
+
+// DefaultHFEndpoint is the serverless Inference API base URL.
+const DefaultHFEndpoint = "https://api-inference.huggingface.co/models"
+
+// HuggingFaceAPIProvider calls the HF Inference API, a dedicated Inference
+// Endpoint or a TGI server for text generation.
 type HuggingFaceAPIProvider struct {
-    client   *http.Client
-    token    string
-    endpoint string
-    modelID  string
+    client       *http.Client
+    token        string
+    endpoint     string
+    endpointType string
+    modelID      string
 }
 
+// NewHuggingFaceAPIProviderFromEnv builds the provider from HF_TOKEN,
+// HF_MODEL_ID, HF_ENDPOINT and HF_ENDPOINT_TYPE. The Inference API needs a
+// token and model; a dedicated endpoint or TGI server needs only HF_ENDPOINT.
 func NewHuggingFaceAPIProviderFromEnv() (*HuggingFaceAPIProvider, error) {
     token := os.Getenv("HF_TOKEN")
     modelID := os.Getenv("HF_MODEL_ID")
     endpoint := os.Getenv("HF_ENDPOINT")
-    if endpoint == "" {
-        endpoint = "https://api-inference.huggingface.co/models"
+    endpointType, err := HFEndpointType(os.Getenv("HF_ENDPOINT_TYPE"), endpoint)
+    if err != nil {
+        return nil, err
     }
-    if token == "" || modelID == "" {
+    if endpointType == HFInferenceAPI && (token == "" || modelID == "") {
         return nil, fmt.Errorf("HF_TOKEN and HF_MODEL_ID are required")
     }
+    return NewHuggingFaceEndpointProvider(endpointType, token, endpoint, modelID)
+}
+
+// hfConfigured reports whether the environment selects the HF provider.
+func hfConfigured() bool {
+    if os.Getenv("HF_TOKEN") != "" && os.Getenv("HF_MODEL_ID") != "" {
+        return true
+    }
+    t, err := HFEndpointType(os.Getenv("HF_ENDPOINT_TYPE"), os.Getenv("HF_ENDPOINT"))
+    return err == nil && t != HFInferenceAPI
+}
+
+// HFEndpointType validates endpointType, or infers it from endpoint when empty:
+// URLs under endpoints.huggingface.cloud are dedicated Inference Endpoints and
+// anything else is the Inference API. A TGI server must be named explicitly.
+func HFEndpointType(endpointType, endpoint string) (string, error) {
+    switch t := strings.ToLower(endpointType); t {
+    case HFInferenceAPI, HFInferenceEndpoint, HFTGI:
+        if t != HFInferenceAPI && endpoint == "" {
+            return "", fmt.Errorf("HF endpoint type %s needs an endpoint URL", t)
+        }
+        return t, nil
+    case "":
+        if u, err := url.Parse(endpoint); err == nil && strings.HasSuffix(u.Hostname(), ".endpoints.huggingface.cloud") {
+            return HFInferenceEndpoint, nil
+        }
+        return HFInferenceAPI, nil
+    default:
+        return "", fmt.Errorf("unsupported HF endpoint type %q (%s|%s|%s)", endpointType, HFInferenceAPI, HFInferenceEndpoint, HFTGI)
+    }
+}
+
+// NewHuggingFaceAPIProvider returns a provider for modelID. An empty endpoint
+// uses the public Inference API; the endpoint type is inferred from it (see
+// HFEndpointType).
+func NewHuggingFaceAPIProvider(token, endpoint, modelID string) *HuggingFaceAPIProvider {
+    endpointType, _ := HFEndpointType("", endpoint)
+    p, _ := NewHuggingFaceEndpointProvider(endpointType, token, endpoint, modelID)
+    return p
+}
+
+// NewHuggingFaceEndpointProvider returns a provider for an endpoint of the
+// given type. The token may be empty for endpoints that do not require one;
+// modelID is informational for dedicated endpoints and TGI, which serve one model.
+func NewHuggingFaceEndpointProvider(endpointType, token, endpoint, modelID string) (*HuggingFaceAPIProvider, error) {
+    endpointType, err := HFEndpointType(endpointType, endpoint)
+    if err != nil {
+        return nil, err
+    }
+    if endpoint == "" {
+        endpoint = DefaultHFEndpoint
+    }
     return &HuggingFaceAPIProvider{
-        client:   &http.Client{Timeout: 60 * time.Second},
-        token:    token,
-        endpoint: endpoint,
-        modelID:  modelID,
+        client:       &http.Client{Timeout: 60 * time.Second},
+        token:        token,
+        endpoint:     strings.TrimRight(endpoint, "/"),
+        endpointType: endpointType,
+        modelID:      modelID,
     }, nil
 }
 
+// EndpointType returns HFInferenceAPI, HFInferenceEndpoint or HFTGI.
+func (p *HuggingFaceAPIProvider) EndpointType() string { return p.endpointType }
+
+// Model returns the model ID, or the endpoint host when a dedicated endpoint
+// or TGI server was configured without one.
+func (p *HuggingFaceAPIProvider) Model() string {
+    if p.modelID != "" {
+        return p.modelID
+    }
+    if u, err := url.Parse(p.endpoint); err == nil && u.Host != "" {
+        return u.Host
+    }
+    return p.endpoint
+}
+
+// Ping checks that a dedicated endpoint or TGI server reports itself healthy,
+// without generating anything. Endpoints scaled to zero or still loading the
+// model answer 503. The serverless Inference API has no health route, so Ping
+// returns nil for it.
+func (p *HuggingFaceAPIProvider) Ping(ctx context.Context) error {
+    if p.endpointType == HFInferenceAPI {
+        return nil
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"/health", nil)
+    if err != nil {
+        return err
+    }
+    p.authorize(req)
+    resp, err := p.client.Do(req)
+    if err != nil {
+        return fmt.Errorf("hf %s unreachable at %s: %w", p.endpointType, p.endpoint, err)
+    }
+    resp.Body.Close()
+    switch {
+    case resp.StatusCode == http.StatusServiceUnavailable:
+        return fmt.Errorf("hf %s at %s is not ready (scaled to zero or loading the model)", p.endpointType, p.endpoint)
+    case resp.StatusCode >= 300:
+        return fmt.Errorf("hf %s at %s returned status %d", p.endpointType, p.endpoint, resp.StatusCode)
+    }
+    return nil
+}
+
 type hfRequest struct {
     Inputs string                 `json:"inputs"`
     Params map[string]interface{} `json:"parameters,omitempty"`
+    Stream bool                   `json:"stream,omitempty"`
+}
+
+// hfGeneration is one generated text. The Inference API and endpoint roots
+// return a list of them; TGI's /generate returns a single object.
+type hfGeneration struct {
+    GeneratedText string     `json:"generated_text"`
+    Details       *hfDetails `json:"details,omitempty"`
 }
 
-type hfResponse []struct {
-    GeneratedText string `json:"generated_text"`
+type hfDetails struct {
+    GeneratedTokens int `json:"generated_tokens"`
 }
 
 func (p *HuggingFaceAPIProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
-    body := hfRequest{Inputs: input}
-    prm := map[string]interface{}{}
+    resp, err := p.do(ctx, newHFRequest(input, params, false))
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    var raw json.RawMessage
+    if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+        return "", err
+    }
+    var out []hfGeneration
+    if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '{' {
+        out = make([]hfGeneration, 1)
+        err = json.Unmarshal(raw, &out[0])
+    } else {
+        err = json.Unmarshal(raw, &out)
+    }
+    if err != nil {
+        return "", err
+    }
+    if len(out) == 0 {
+        return "", fmt.Errorf("empty response from hf")
+    }
+    // Hosted endpoints report generated tokens; the prompt side is estimated
+    if d := out[0].Details; d != nil && d.GeneratedTokens > 0 {
+        ReportUsage(ctx, Usage{PromptTokens: EstimateTokens(input), CompletionTokens: d.GeneratedTokens, Estimated: true})
+    }
+    return out[0].GeneratedText, nil
+}
+
+// hfStreamEvent is one server-sent event from a streaming text-generation request.
+type hfStreamEvent struct {
+    Token struct {
+        Text    string `json:"text"`
+        Special bool   `json:"special"`
+    } `json:"token"`
+    // Details arrive with the last token
+    Details *hfDetails `json:"details,omitempty"`
+    Error   string     `json:"error,omitempty"`
+}
+
+// GenerateStream requests server-sent events and calls onToken for each generated token.
+func (p *HuggingFaceAPIProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+    resp, err := p.do(ctx, newHFRequest(input, params, true))
+    if err != nil {
+        return "", err
+    }
+    defer resp.Body.Close()
+    var sb strings.Builder
+    sc := bufio.NewScanner(resp.Body)
+    sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if !strings.HasPrefix(line, "data:") {
+            continue
+        }
+        var ev hfStreamEvent
+        if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+            return sb.String(), fmt.Errorf("decode hf stream: %w", err)
+        }
+        if ev.Error != "" {
+            return sb.String(), fmt.Errorf("hf api error: %s", ev.Error)
+        }
+        if ev.Details != nil && ev.Details.GeneratedTokens > 0 {
+            ReportUsage(ctx, Usage{PromptTokens: EstimateTokens(input), CompletionTokens: ev.Details.GeneratedTokens, Estimated: true})
+        }
+        if ev.Token.Special || ev.Token.Text == "" {
+            continue
+        }
+        sb.WriteString(ev.Token.Text)
+        if err := onToken(ev.Token.Text); err != nil {
+            return sb.String(), err
+        }
+    }
+    if err := sc.Err(); err != nil {
+        return sb.String(), err
+    }
+    return sb.String(), nil
+}
+
+// newHFRequest maps params onto text-generation parameters. Values TGI
+// rejects are left out rather than sent: top_p must be below 1 and both
+// temperature and repetition_penalty above 0.
+func newHFRequest(input string, params Params, stream bool) hfRequest {
+    body := hfRequest{Inputs: input, Stream: stream}
+    // Answers never repeat the prompt, whatever the endpoint's default
+    prm := map[string]interface{}{"details": true, "return_full_text": false}
     if params.MaxNewTokens > 0 {
         prm["max_new_tokens"] = params.MaxNewTokens
     }
     if params.Temperature > 0 {
         prm["temperature"] = params.Temperature
     }
-    if params.TopP > 0 {
+    if params.TopP > 0 && params.TopP < 1 {
         prm["top_p"] = params.TopP
     }
-    if len(prm) > 0 {
-        body.Params = prm
+    if params.RepetitionPen > 0 {
+        prm["repetition_penalty"] = params.RepetitionPen
+    }
+    if len(params.Stop) > 0 {
+        prm["stop"] = params.Stop
+    }
+    // Text Generation Inference constrains output with a JSON grammar
+    if params.ResponseFormat.JSON() {
+        prm["grammar"] = map[string]interface{}{"type": "json", "value": params.ResponseFormat.schema()}
+    }
+    body.Params = prm
+    return body
+}
+
+// url returns the generation URL for the endpoint type.
+func (p *HuggingFaceAPIProvider) url(stream bool) string {
+    switch p.endpointType {
+    case HFInferenceEndpoint:
+        return p.endpoint
+    case HFTGI:
+        if stream {
+            return p.endpoint + "/generate_stream"
+        }
+        return p.endpoint + "/generate"
+    }
+    return fmt.Sprintf("%s/%s", p.endpoint, p.modelID)
+}
+
+func (p *HuggingFaceAPIProvider) authorize(req *http.Request) {
+    if p.token != "" {
+        req.Header.Set("Authorization", "Bearer "+p.token)
+    }
+}
+
+// do posts body to the model endpoint and returns the response on a 2xx status.
+func (p *HuggingFaceAPIProvider) do(ctx context.Context, body hfRequest) (*http.Response, error) {
+    target := p.url(body.Stream)
+    if p.endpointType == HFTGI {
+        // The route selects streaming
+        body.Stream = false
     }
     by, _ := json.Marshal(body)
-    req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s", p.endpoint, p.modelID), bytes.NewReader(by))
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(by))
     if err != nil {
-        return "", err
+        return nil, err
     }
-    req.Header.Set("Authorization", "Bearer "+p.token)
+    p.authorize(req)
     req.Header.Set("Content-Type", "application/json")
+    setTraceHeaders(ctx, req)
     resp, err := p.client.Do(req)
     if err != nil {
-        return "", err
+        return nil, err
     }
-    defer resp.Body.Close()
     if resp.StatusCode >= 300 {
-        return "", fmt.Errorf("hf api error: %s", resp.Status)
-    }
-    var out hfResponse
-    if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-        return "", err
-    }
-    if len(out) == 0 {
-        return "", fmt.Errorf("empty response from hf")
+        resp.Body.Close()
+        return nil, NewStatusError("hf", resp)
     }
-    return out[0].GeneratedText, nil
+    return resp, nil
 }
-
-
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeTGI serves the TGI routes, recording the last generation request.
+func fakeTGI(t *testing.T, got *hfRequest, paths *[]string) *httptest.Server {
+	t.Helper()
+	healthy := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/health":
+			// Loading the model on the first check
+			if !healthy {
+				healthy = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/generate":
+			_ = json.NewDecoder(r.Body).Decode(got)
+			fmt.Fprint(w, `{"generated_text":"Hi!","details":{"generated_tokens":3}}`)
+		case "/generate_stream":
+			_ = json.NewDecoder(r.Body).Decode(got)
+			fmt.Fprintln(w, `data:{"token":{"text":"H","special":false}}`)
+			fmt.Fprintln(w, `data:{"token":{"text":"i!","special":false}}`)
+			fmt.Fprintln(w, `data:{"token":{"text":"</s>","special":true},"generated_text":"Hi!","details":{"generated_tokens":3}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHuggingFaceProvider_TGI(t *testing.T) {
+	var (
+		got   hfRequest
+		paths []string
+	)
+	srv := fakeTGI(t, &got, &paths)
+	t.Setenv("CMP_PROJECT_ROOT", t.TempDir())
+	t.Setenv("HF_TOKEN", "")
+	t.Setenv("HF_MODEL_ID", "")
+	t.Setenv("HF_ENDPOINT", srv.URL+"/")
+	t.Setenv("HF_ENDPOINT_TYPE", "tgi")
+	if kind := ProviderKindFromEnv(); kind != "huggingface" {
+		t.Fatalf("ProviderKindFromEnv = %q", kind)
+	}
+	prov, err := NewHuggingFaceAPIProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prov.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Fatalf("expected a loading server not to be ready, got %v", err)
+	}
+	if err := prov.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, meter := WithUsageMeter(context.Background())
+	out, err := prov.Generate(ctx, "hello", Params{MaxNewTokens: 32, Temperature: 0.3, TopP: 1, RepetitionPen: 1.2, Stop: []string{"\nUser:"}})
+	if err != nil || out != "Hi!" {
+		t.Fatalf("Generate = %q, %v", out, err)
+	}
+	prm := got.Params
+	if prm["max_new_tokens"] != float64(32) || prm["temperature"] != 0.3 || prm["repetition_penalty"] != 1.2 || prm["return_full_text"] != false {
+		t.Fatalf("unexpected parameters %+v", prm)
+	}
+	if _, ok := prm["top_p"]; ok {
+		t.Fatal("expected top_p 1 to be left out, as TGI rejects it")
+	}
+	if stop, _ := prm["stop"].([]interface{}); len(stop) != 1 || stop[0] != "\nUser:" {
+		t.Fatalf("stop = %v", prm["stop"])
+	}
+	if u := meter.Total(); u.CompletionTokens != 3 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	var toks []string
+	ctx, meter = WithUsageMeter(context.Background())
+	out, err = prov.GenerateStream(ctx, "hello", Params{TopP: 0.9}, func(tok string) error {
+		toks = append(toks, tok)
+		return nil
+	})
+	if err != nil || out != "Hi!" || strings.Join(toks, "|") != "H|i!" || got.Stream || got.Params["top_p"] != 0.9 {
+		t.Fatalf("GenerateStream = %q %v, %v (request %+v)", out, toks, err, got)
+	}
+	if u := meter.Total(); u.CompletionTokens != 3 {
+		t.Fatalf("stream usage = %+v", u)
+	}
+	if want := "GET /health,GET /health,POST /generate,POST /generate_stream"; strings.Join(paths, ",") != want {
+		t.Fatalf("paths = %v, want %s", paths, want)
+	}
+}
+
+func TestHuggingFaceProvider_InferenceEndpoint(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		fmt.Fprint(w, `[{"generated_text":"Hello"}]`)
+	}))
+	defer srv.Close()
+	prov, err := NewHuggingFaceEndpointProvider(HFInferenceEndpoint, "tok", srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := prov.Generate(context.Background(), "hi", Params{})
+	if err != nil || out != "Hello" || gotPath != "/" && gotPath != "" || gotAuth != "Bearer tok" {
+		t.Fatalf("Generate = %q, %v (path %q, auth %q)", out, err, gotPath, gotAuth)
+	}
+	if prov.Model() != strings.TrimPrefix(srv.URL, "http://") {
+		t.Fatalf("Model = %q", prov.Model())
+	}
+
+	for _, tc := range []struct{ typ, endpoint, want string }{
+		{"", "", HFInferenceAPI},
+		{"", "https://api-inference.huggingface.co/models", HFInferenceAPI},
+		{"", "https://abc123.us-east-1.aws.endpoints.huggingface.cloud", HFInferenceEndpoint},
+		{"TGI", "http://tgi:8080", HFTGI},
+		{"tgi", "", ""},
+		{"sagemaker", "http://x", ""},
+	} {
+		got, err := HFEndpointType(tc.typ, tc.endpoint)
+		if got != tc.want || (tc.want == "") != (err != nil) {
+			t.Errorf("HFEndpointType(%q, %q) = %q, %v; want %q", tc.typ, tc.endpoint, got, err, tc.want)
+		}
+	}
+}
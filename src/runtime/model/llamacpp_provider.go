@@ -0,0 +1,345 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LlamaCppProvider runs GGUF models with llama.cpp. By default it manages a
+// llama-server subprocess bound to localhost; CMP_LLAMACPP_URL points it at a
+// server that is already running instead.
+type LlamaCppProvider struct {
+	client  *http.Client
+	model   string // GGUF path passed to llama-server
+	bin     string // empty when using an external server
+	args    []string
+	startup time.Duration
+	ctxSize int // --ctx-size of the managed server, 0 when unknown
+
+	mu   sync.Mutex
+	url  string
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the managed process exits
+}
+
+// newLlamaCppProviderFromEnv configures a llama.cpp provider. model overrides
+// CMP_LLAMACPP_MODEL when set. Relative model paths resolve against
+// CMP_PROJECT_ROOT.
+func newLlamaCppProviderFromEnv(model string) (*LlamaCppProvider, error) {
+	p := &LlamaCppProvider{
+		client:  &http.Client{Timeout: resolveLocalTimeout()},
+		url:     strings.TrimRight(os.Getenv("CMP_LLAMACPP_URL"), "/"),
+		startup: 2 * time.Minute,
+	}
+	if p.url != "" {
+		p.model = model
+		return p, nil
+	}
+	if model == "" {
+		model = os.Getenv("CMP_LLAMACPP_MODEL")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("CMP_LLAMACPP_MODEL (a .gguf file) or CMP_LLAMACPP_URL is required for the llamacpp backend")
+	}
+	if !filepath.IsAbs(model) {
+		model = filepath.Join(os.Getenv("CMP_PROJECT_ROOT"), model)
+	}
+	if _, err := os.Stat(model); err != nil {
+		return nil, fmt.Errorf("llama.cpp model: %w", err)
+	}
+	p.model = model
+	p.bin = os.Getenv("CMP_LLAMACPP_SERVER_BIN")
+	if p.bin == "" {
+		p.bin = "llama-server"
+	}
+	p.args = []string{"--model", model, "--host", "127.0.0.1"}
+	ctxSize := os.Getenv("CMP_LLAMACPP_CTX_SIZE")
+	if ctxSize == "" {
+		ctxSize = "4096"
+	}
+	p.args = append(p.args, "--ctx-size", ctxSize)
+	p.ctxSize, _ = strconv.Atoi(ctxSize)
+	if v := os.Getenv("CMP_LLAMACPP_GPU_LAYERS"); v != "" {
+		p.args = append(p.args, "--n-gpu-layers", v)
+	}
+	if v := os.Getenv("CMP_LLAMACPP_THREADS"); v != "" {
+		p.args = append(p.args, "--threads", v)
+	}
+	p.args = append(p.args, strings.Fields(os.Getenv("CMP_LLAMACPP_ARGS"))...)
+	if v, err := time.ParseDuration(os.Getenv("CMP_LLAMACPP_STARTUP_TIMEOUT")); err == nil && v > 0 {
+		p.startup = v
+	}
+	return p, nil
+}
+
+// ensureServer returns the server URL, starting llama-server on first use and
+// again if the managed process has exited.
+func (p *LlamaCppProvider) ensureServer(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bin == "" {
+		return p.url, nil // external server
+	}
+	if p.cmd != nil {
+		select {
+		case <-p.done:
+			p.cmd = nil
+		default:
+			return p.url, nil
+		}
+	}
+	port, err := freePort()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(p.bin, append(p.args, "--port", strconv.Itoa(port))...)
+	stderr := &tailBuffer{max: 4096}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("start llama-server: %w", err)
+	}
+	done := make(chan struct{})
+	go func() { _ = cmd.Wait(); close(done) }()
+	url := "http://127.0.0.1:" + strconv.Itoa(port)
+
+	// Loading a model can take a while; /health returns 200 once it is ready
+	deadline := time.Now().Add(p.startup)
+	for {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/health", nil)
+		if resp, err := p.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				break
+			}
+		}
+		select {
+		case <-done:
+			return "", fmt.Errorf("llama-server exited: %s", strings.TrimSpace(lastLines(stderr.String(), 5)))
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+			return "", ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+			return "", fmt.Errorf("llama-server not ready after %s", p.startup)
+		}
+	}
+	p.cmd, p.done, p.url = cmd, done, url
+	return url, nil
+}
+
+// Close stops the managed llama-server, if any.
+func (p *LlamaCppProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return nil
+	}
+	_ = p.cmd.Process.Kill()
+	<-p.done
+	p.cmd = nil
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// tailBuffer keeps the last max bytes written, so a long-running server's
+// log cannot grow without bound.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// llamaCompletion is the llama-server /completion request.
+type llamaCompletion struct {
+	Prompt        string                 `json:"prompt"`
+	NPredict      int                    `json:"n_predict,omitempty"`
+	Temperature   float64                `json:"temperature,omitempty"`
+	TopP          float64                `json:"top_p,omitempty"`
+	RepeatPenalty float64                `json:"repeat_penalty,omitempty"`
+	Stop          []string               `json:"stop,omitempty"`
+	JSONSchema    map[string]interface{} `json:"json_schema,omitempty"` // compiled to a grammar by llama-server
+	Stream        bool                   `json:"stream"`
+	CachePrompt   bool                   `json:"cache_prompt"`
+}
+
+// llamaResult is a /completion response, or one streamed chunk of it.
+type llamaResult struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+}
+
+func (p *LlamaCppProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	resp, err := p.complete(ctx, input, params, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out llamaResult
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode llama.cpp response: %w", err)
+	}
+	p.reportUsage(ctx, out)
+	return out.Content, nil
+}
+
+// GenerateStream reads the server-sent events of a streaming completion.
+func (p *LlamaCppProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	resp, err := p.complete(ctx, input, params, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var sb strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev llamaResult
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			return sb.String(), fmt.Errorf("decode llama.cpp stream: %w", err)
+		}
+		if ev.Content != "" {
+			sb.WriteString(ev.Content)
+			if err := onToken(ev.Content); err != nil {
+				return sb.String(), err
+			}
+		}
+		if ev.Stop {
+			p.reportUsage(ctx, ev)
+			break
+		}
+	}
+	return sb.String(), sc.Err()
+}
+
+func (p *LlamaCppProvider) complete(ctx context.Context, input string, params Params, stream bool) (*http.Response, error) {
+	base, err := p.ensureServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	completion := llamaCompletion{
+		Prompt:        input,
+		NPredict:      params.MaxNewTokens,
+		Temperature:   params.Temperature,
+		TopP:          params.TopP,
+		RepeatPenalty: params.RepetitionPen,
+		Stop:          params.Stop,
+		Stream:        stream,
+		CachePrompt:   true,
+	}
+	if params.ResponseFormat.JSON() {
+		completion.JSONSchema = params.ResponseFormat.schema()
+	}
+	body, _ := json.Marshal(completion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/completion", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setTraceHeaders(ctx, req)
+	client := p.client
+	if stream {
+		// A client timeout would cut long streams off; ctx bounds them instead
+		client = &http.Client{Transport: p.client.Transport}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, NewStatusError("llama.cpp", resp)
+	}
+	return resp, nil
+}
+
+// ContextWindow returns the context size the managed server was started with.
+// It is unknown for an external server.
+func (p *LlamaCppProvider) ContextWindow() int { return p.ctxSize }
+
+// CountTokens tokenizes text with the model's tokenizer through /tokenize.
+func (p *LlamaCppProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	base, err := p.ensureServer(ctx)
+	if err != nil {
+		return 0, err
+	}
+	body, _ := json.Marshal(map[string]string{"content": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/tokenize", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, NewStatusError("llama.cpp", resp)
+	}
+	var out struct {
+		Tokens []json.RawMessage `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("decode llama.cpp tokens: %w", err)
+	}
+	return len(out.Tokens), nil
+}
+
+// reportUsage reports the token counts llama-server measured with the model's tokenizer.
+func (p *LlamaCppProvider) reportUsage(ctx context.Context, r llamaResult) {
+	if r.TokensPredicted > 0 || r.TokensEvaluated > 0 {
+		ReportUsage(ctx, Usage{PromptTokens: r.TokensEvaluated, CompletionTokens: r.TokensPredicted})
+	}
+}
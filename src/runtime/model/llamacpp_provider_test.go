@@ -0,0 +1,93 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLlamaCppProvider_CompletionAndStream(t *testing.T) {
+	var got llamaCompletion
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/completion" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if !got.Stream {
+			fmt.Fprint(w, `{"content":"Hello there","stop":true,"tokens_predicted":2,"tokens_evaluated":5}`)
+			return
+		}
+		for _, tok := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"content\":%q,\"stop\":false}\n\n", tok)
+		}
+		fmt.Fprint(w, "data: {\"content\":\"\",\"stop\":true,\"tokens_predicted\":2,\"tokens_evaluated\":5}\n\n")
+	}))
+	defer srv.Close()
+	t.Setenv("CMP_LOCAL_BACKEND", "llamacpp")
+	t.Setenv("CMP_LLAMACPP_URL", srv.URL)
+
+	prov, err := NewLocalProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, meter := WithUsageMeter(context.Background())
+	out, err := prov.Generate(ctx, "Hi", Params{MaxNewTokens: 16, Temperature: 0.2, RepetitionPen: 1.1})
+	if err != nil || out != "Hello there" {
+		t.Fatalf("Generate = %q, %v", out, err)
+	}
+	if got.NPredict != 16 || got.Temperature != 0.2 || got.RepeatPenalty != 1.1 || !got.CachePrompt {
+		t.Fatalf("params not mapped: %+v", got)
+	}
+	if u := meter.Total(); u.PromptTokens != 5 || u.CompletionTokens != 2 || u.Estimated {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	var toks []string
+	out, err = prov.(StreamingProvider).GenerateStream(context.Background(), "Hi", Params{}, func(tok string) error {
+		toks = append(toks, tok)
+		return nil
+	})
+	if err != nil || out != "Hello" || strings.Join(toks, "|") != "Hel|lo" {
+		t.Fatalf("GenerateStream = %q %v, %v", out, toks, err)
+	}
+}
+
+func TestLlamaCppProvider_ManagedServerFailure(t *testing.T) {
+	falseBin, err := exec.LookPath("false")
+	if err != nil {
+		t.Skip("false not available")
+	}
+	model := filepath.Join(t.TempDir(), "tiny-q4_k_m.gguf")
+	_ = os.WriteFile(model, []byte("GGUF"), 0o644)
+	t.Setenv("CMP_LLAMACPP_SERVER_BIN", falseBin)
+	t.Setenv("CMP_LLAMACPP_MODEL", model)
+
+	prov, err := newLlamaCppProviderFromEnv("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prov.Close()
+	if strings.Join(prov.args, " ") != "--model "+model+" --host 127.0.0.1 --ctx-size 4096" {
+		t.Fatalf("args = %v", prov.args)
+	}
+	if _, err := prov.Generate(context.Background(), "Hi", Params{}); err == nil || !strings.Contains(err.Error(), "llama-server exited") {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+
+	t.Setenv("CMP_LLAMACPP_MODEL", filepath.Join(t.TempDir(), "missing.gguf"))
+	if _, err := newLlamaCppProviderFromEnv(""); err == nil {
+		t.Fatal("expected missing model error")
+	}
+	t.Setenv("CMP_LOCAL_BACKEND", "vllm")
+	if _, err := NewLocalProviderFromEnv(); err == nil {
+		t.Fatal("expected unsupported backend error")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"time"
+
+	"github.com/contexis-cmp/contexis/src/runtime/pyenv"
 )
 
 // localPythonProvider shells out to the Python LocalAIProvider to generate text.
@@ -17,6 +19,7 @@ type localPythonProvider struct {
 	pythonBin  string
 	scriptPath string
 	timeout    time.Duration
+	modelID    string // overrides CMP_LOCAL_MODEL_ID when set (model routing)
 }
 
 type localReq struct {
@@ -27,13 +30,15 @@ type localReq struct {
 type localResp struct {
 	Output string `json:"output"`
 	Error  string `json:"error,omitempty"`
+	// Usage is counted with the model tokenizer when the script reports it
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
 }
 
-func newLocalPythonProviderFromEnv() (Provider, error) {
-	py := os.Getenv("CMP_PYTHON_BIN")
-	if py == "" {
-		py = "python3"
-	}
+func newLocalPythonProviderFromEnv() (*localPythonProvider, error) {
+	py := pyenv.Bin(os.Getenv("CMP_PROJECT_ROOT"))
 	// Resolve script path robustly for both repo root and generated project dirs
 	if override := os.Getenv("CMP_PYTHON_SCRIPT"); override != "" {
 		if _, err := os.Stat(override); err == nil {
@@ -82,6 +87,10 @@ func (p *localPythonProvider) Generate(ctx context.Context, input string, params
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
+	if p.modelID != "" {
+		cmd.Env = append(os.Environ(), "CMP_LOCAL_MODEL_ID="+p.modelID)
+	}
+
 	// Set working directory to project root if provided
 	if cwd := os.Getenv("CMP_PROJECT_ROOT"); cwd != "" {
 		if abs, err := filepath.Abs(cwd); err == nil {
@@ -119,5 +128,9 @@ func (p *localPythonProvider) Generate(ctx context.Context, input string, params
 	if resp.Error != "" {
 		return "", fmt.Errorf(resp.Error)
 	}
-	return resp.Output, nil
+	if resp.Usage != nil {
+		ReportUsage(ctx, Usage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens})
+	}
+	// transformers has no string stop criteria in the script, so stop here
+	return truncateAtStop(resp.Output, params.Stop), nil
 }
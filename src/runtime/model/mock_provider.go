@@ -0,0 +1,248 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+	"gopkg.in/yaml.v3"
+)
+
+// MockScriptFile is the project-relative path of the default mock provider script.
+const MockScriptFile = "config/providers/mock.yaml"
+
+// MockRule scripts the answer for prompts matching a pattern.
+type MockRule struct {
+	Name string `yaml:"name"`
+	// Match is a regular expression tested against the rendered prompt; empty
+	// matches every prompt.
+	Match string `yaml:"match"`
+	// Response is returned verbatim; Responses, when set, are returned in turn.
+	Response  string   `yaml:"response"`
+	Responses []string `yaml:"responses"`
+	// Latency delays the answer, plus a random amount up to Jitter.
+	Latency string `yaml:"latency"`
+	Jitter  string `yaml:"jitter"`
+	// TokenLatency delays each streamed word.
+	TokenLatency string `yaml:"token_latency"`
+	// Error fails the call with this message, with probability ErrorRate
+	// (default 1 when Error is set).
+	Error     string  `yaml:"error"`
+	ErrorRate float64 `yaml:"error_rate"`
+}
+
+// MockScript is the YAML script read by the mock provider. Rules are tried in
+// order; Default answers prompts no rule matches.
+type MockScript struct {
+	Default MockRule   `yaml:"default"`
+	Rules   []MockRule `yaml:"rules"`
+	// Seed makes latency jitter and error injection reproducible; zero seeds
+	// from the clock.
+	Seed int64 `yaml:"seed"`
+}
+
+// LoadMockScript reads a mock script file.
+func LoadMockScript(path string) (*MockScript, error) {
+	by, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script MockScript
+	if err := yaml.Unmarshal(by, &script); err != nil {
+		return nil, fmt.Errorf("parse mock script %s: %w", path, err)
+	}
+	return &script, nil
+}
+
+// mockRule is a compiled MockRule.
+type mockRule struct {
+	MockRule
+	match        *regexp.Regexp
+	latency      time.Duration
+	jitter       time.Duration
+	tokenLatency time.Duration
+	next         int
+}
+
+// MockProvider returns scripted responses without a model, for local development
+// and load testing. It supports streaming, latency and error injection.
+type MockProvider struct {
+	mu    sync.Mutex
+	rules []*mockRule
+	def   *mockRule
+	rng   *rand.Rand
+}
+
+// NewMockProvider compiles script. A nil script answers every prompt with a fixed
+// mock response.
+func NewMockProvider(script *MockScript) (*MockProvider, error) {
+	if script == nil {
+		script = &MockScript{}
+	}
+	seed := script.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p := &MockProvider{rng: rand.New(rand.NewSource(seed))}
+	def, err := compileMockRule(script.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	if def.Response == "" && len(def.Responses) == 0 && def.Error == "" {
+		def.Response = "This is a mock response."
+	}
+	p.def = def
+	for i, r := range script.Rules {
+		rule, err := compileMockRule(r)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+func compileMockRule(r MockRule) (*mockRule, error) {
+	rule := &mockRule{MockRule: r}
+	if r.Match != "" {
+		rx, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match: %w", err)
+		}
+		rule.match = rx
+	}
+	for _, d := range []struct {
+		value string
+		dst   *time.Duration
+	}{{r.Latency, &rule.latency}, {r.Jitter, &rule.jitter}, {r.TokenLatency, &rule.tokenLatency}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", d.value, err)
+		}
+		*d.dst = v
+	}
+	switch {
+	case rule.Error != "" && rule.ErrorRate == 0:
+		rule.ErrorRate = 1
+	case rule.Error == "" && rule.ErrorRate > 0:
+		rule.Error = "mock provider error"
+	}
+	return rule, nil
+}
+
+// NewMockProviderFromEnv loads the script from CMP_MOCK_SCRIPT, or
+// config/providers/mock.yaml under CMP_PROJECT_ROOT when it exists.
+func NewMockProviderFromEnv() (*MockProvider, error) {
+	path := os.Getenv("CMP_MOCK_SCRIPT")
+	if path == "" {
+		candidate := filepath.Join(os.Getenv("CMP_PROJECT_ROOT"), MockScriptFile)
+		if _, err := os.Stat(candidate); err != nil {
+			return NewMockProvider(nil)
+		}
+		path = candidate
+	}
+	script, err := LoadMockScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMockProvider(script)
+}
+
+// mockProvidersEnabled reports whether the mock provider replaces real providers:
+// CMP_MOCK_PROVIDERS=true, or features.mock_providers in the active environment
+// config (config/environments/$CMP_ENV.yaml, default development).
+func mockProvidersEnabled() bool {
+	if v := os.Getenv("CMP_MOCK_PROVIDERS"); v != "" {
+		return v == "true"
+	}
+	env, err := runtimeconfig.Load(os.Getenv("CMP_PROJECT_ROOT"))
+	if err != nil {
+		return false
+	}
+	features, err := env.Features()
+	return err == nil && features.MockProviders
+}
+
+// pick selects the rule for input and draws its response, delay and failure.
+func (p *MockProvider) pick(input string) (rule *mockRule, response string, delay time.Duration, fail bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rule = p.def
+	for _, r := range p.rules {
+		if r.match == nil || r.match.MatchString(input) {
+			rule = r
+			break
+		}
+	}
+	response = rule.Response
+	if n := len(rule.Responses); n > 0 {
+		response = rule.Responses[rule.next%n]
+		rule.next++
+	}
+	delay = rule.latency
+	if rule.jitter > 0 {
+		delay += time.Duration(p.rng.Int63n(int64(rule.jitter) + 1))
+	}
+	fail = rule.ErrorRate > 0 && p.rng.Float64() < rule.ErrorRate
+	return rule, response, delay, fail
+}
+
+func (p *MockProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	return p.GenerateStream(ctx, input, params, nil)
+}
+
+// GenerateStream emits the scripted response word by word, waiting TokenLatency
+// between words.
+func (p *MockProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	rule, response, delay, fail := p.pick(input)
+	response = truncateAtStop(response, params.Stop)
+	if err := sleepCtx(ctx, delay); err != nil {
+		return "", err
+	}
+	if fail {
+		return "", errors.New(rule.Error)
+	}
+	if onToken == nil {
+		return response, nil
+	}
+	for i, word := range strings.SplitAfter(response, " ") {
+		if i > 0 {
+			if err := sleepCtx(ctx, rule.tokenLatency); err != nil {
+				return "", err
+			}
+		}
+		if err := onToken(word); err != nil {
+			return "", err
+		}
+	}
+	return response, nil
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const mockYAML = `seed: 7
+default:
+  response: fallback answer
+rules:
+  - name: refunds
+    match: "(?i)refund"
+    responses: ["first refund answer", "second refund answer"]
+  - name: outage
+    match: outage
+    error: upstream unavailable
+  - name: slow
+    match: slow
+    response: eventually
+    latency: 50ms
+`
+
+func writeMockScript(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mock.yaml")
+	if err := os.WriteFile(path, []byte(mockYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMockProvider_ScriptedResponses(t *testing.T) {
+	script, err := LoadMockScript(writeMockScript(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewMockProvider(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, want := range []string{"first refund answer", "second refund answer", "first refund answer"} {
+		if out, _ := p.Generate(ctx, "Can I get a REFUND?", Params{}); out != want {
+			t.Fatalf("got %q, want %q", out, want)
+		}
+	}
+	if out, _ := p.Generate(ctx, "hello", Params{}); out != "fallback answer" {
+		t.Fatalf("default response = %q", out)
+	}
+	if _, err := p.Generate(ctx, "report outage", Params{}); err == nil || err.Error() != "upstream unavailable" {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Generate(short, "slow", Params{}); err == nil {
+		t.Fatal("latency should respect context deadline")
+	}
+
+	var chunks []string
+	out, err := p.GenerateStream(ctx, "hello", Params{}, func(s string) error {
+		chunks = append(chunks, s)
+		return nil
+	})
+	if err != nil || strings.Join(chunks, "") != out || len(chunks) != 2 {
+		t.Fatalf("stream = %q, %v, chunks %q", out, err, chunks)
+	}
+}
+
+func TestMockProvider_InvalidScript(t *testing.T) {
+	if _, err := NewMockProvider(&MockScript{Rules: []MockRule{{Name: "bad", Match: "("}}}); err == nil || !strings.Contains(err.Error(), "rule bad") {
+		t.Fatalf("expected rule error, got %v", err)
+	}
+}
+
+func TestFromEnv_MockProviders(t *testing.T) {
+	t.Setenv("CMP_PROVIDER_MODE", "")
+	t.Setenv("CMP_MOCK_PROVIDERS", "true")
+	t.Setenv("CMP_MOCK_SCRIPT", writeMockScript(t))
+	p, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := p.Generate(context.Background(), "refund please", Params{}); out != "first refund answer" {
+		t.Fatalf("FromEnv mock = %q", out)
+	}
+
+	// The environment config feature flag enables it too
+	root := t.TempDir()
+	envDir := filepath.Join(root, "config", "environments")
+	_ = os.MkdirAll(envDir, 0o755)
+	_ = os.WriteFile(filepath.Join(envDir, "test.yaml"), []byte("features:\n  mock_providers: true\n"), 0o644)
+	t.Setenv("CMP_MOCK_PROVIDERS", "")
+	t.Setenv("CMP_MOCK_SCRIPT", "")
+	t.Setenv("CMP_PROJECT_ROOT", root)
+	t.Setenv("CMP_ENV", "test")
+	if !mockProvidersEnabled() {
+		t.Fatal("features.mock_providers should enable the mock provider")
+	}
+	if p, _ := FromEnv(); p == nil {
+		t.Fatal("expected mock provider from feature flag")
+	}
+}
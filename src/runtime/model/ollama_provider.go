@@ -0,0 +1,356 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+)
+
+// DefaultOllamaModel is used when neither OLLAMA_MODEL nor providers.ollama.model is set.
+const DefaultOllamaModel = "llama3.2"
+
+// OllamaConfig is the providers.ollama section of config/environments/$CMP_ENV.yaml.
+type OllamaConfig struct {
+	Host      string                 `yaml:"host"`
+	Model     string                 `yaml:"model"`
+	Options   map[string]interface{} `yaml:"options"`    // passed through as Ollama model options
+	KeepAlive string                 `yaml:"keep_alive"` // how long Ollama keeps the model loaded, e.g. "10m"
+}
+
+// OllamaProvider generates text through the Ollama HTTP API.
+type OllamaProvider struct {
+	client    *http.Client
+	host      string
+	model     string
+	options   map[string]interface{}
+	keepAlive string
+}
+
+// NewOllamaProvider returns a provider for model on host (e.g. localhost:11434).
+func NewOllamaProvider(host, model string, options map[string]interface{}, keepAlive string) *OllamaProvider {
+	if model == "" {
+		model = DefaultOllamaModel
+	}
+	return &OllamaProvider{client: &http.Client{}, host: normalizeOllamaHost(host), model: model, options: options, keepAlive: keepAlive}
+}
+
+// loadOllamaConfig returns providers.ollama from the active environment config
+// under CMP_PROJECT_ROOT, overridden by OLLAMA_HOST and OLLAMA_MODEL. ok is
+// false when Ollama is configured in neither place.
+func loadOllamaConfig() (cfg OllamaConfig, ok bool) {
+	if env, err := runtimeconfig.Load(os.Getenv("CMP_PROJECT_ROOT")); err == nil {
+		ok, _ = env.Provider("ollama", &cfg)
+	}
+	if v := os.Getenv("OLLAMA_HOST"); v != "" {
+		cfg.Host, ok = v, true
+	}
+	if v := os.Getenv("OLLAMA_MODEL"); v != "" {
+		cfg.Model = v
+	}
+	return cfg, ok
+}
+
+// NewOllamaProviderFromEnv returns an Ollama provider when OLLAMA_HOST is set
+// or providers.ollama is configured, and nil otherwise.
+func NewOllamaProviderFromEnv() *OllamaProvider {
+	cfg, ok := loadOllamaConfig()
+	if !ok {
+		return nil
+	}
+	return NewOllamaProvider(cfg.Host, cfg.Model, cfg.Options, cfg.KeepAlive)
+}
+
+// Ping checks that the Ollama server answers and has the provider's model,
+// without generating anything.
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.host+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama unreachable at %s: %w", p.host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama at %s returned status %d", p.host, resp.StatusCode)
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("ollama at %s: decode model list: %w", p.host, err)
+	}
+	for _, m := range tags.Models {
+		// Ollama lists "llama3.2:latest" for a pulled "llama3.2"
+		if m.Name == p.model || strings.TrimSuffix(m.Name, ":latest") == p.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama at %s does not have model %q (run: ollama pull %s)", p.host, p.model, p.model)
+}
+
+// normalizeOllamaHost accepts the forms OLLAMA_HOST allows ("host", "host:port",
+// "http://host:port") and returns a base URL, defaulting to port 11434.
+func normalizeOllamaHost(host string) string {
+	if host == "" {
+		host = "127.0.0.1:11434"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return strings.TrimRight(host, "/")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "11434")
+	}
+	// A server listening on all interfaces is reached through loopback
+	if u.Hostname() == "0.0.0.0" {
+		u.Host = net.JoinHostPort("127.0.0.1", u.Port())
+	}
+	return strings.TrimRight(u.String(), "/")
+}
+
+type ollamaRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	Stream    bool                   `json:"stream"`
+	Format    interface{}            `json:"format,omitempty"` // "json" or a JSON Schema
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatRequest is an /api/chat request, used for native tool calling.
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ollamaMessage        `json:"messages"`
+	Tools     []ollamaTool           `json:"tools,omitempty"`
+	Stream    bool                   `json:"stream"`
+	Format    interface{}            `json:"format,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Function struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
+}
+
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function Tool   `json:"function"`
+}
+
+// ollamaChatResponse is a non-streamed /api/chat response.
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// ollamaResponse is a /api/generate response, or one streamed line of it.
+type ollamaResponse struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error,omitempty"`
+}
+
+// requestOptions merges the configured options with params, which take precedence.
+func (p *OllamaProvider) requestOptions(params Params) map[string]interface{} {
+	opts := map[string]interface{}{}
+	for k, v := range p.options {
+		opts[k] = v
+	}
+	if params.Temperature > 0 {
+		opts["temperature"] = params.Temperature
+	}
+	if params.TopP > 0 {
+		opts["top_p"] = params.TopP
+	}
+	if params.MaxNewTokens > 0 {
+		opts["num_predict"] = params.MaxNewTokens
+	}
+	if params.RepetitionPen > 0 {
+		opts["repeat_penalty"] = params.RepetitionPen
+	}
+	if len(params.Stop) > 0 {
+		opts["stop"] = params.Stop
+	}
+	return opts
+}
+
+// ContextWindow returns the num_ctx model option, or 0 when it is not set and
+// Ollama uses the model's default.
+func (p *OllamaProvider) ContextWindow() int {
+	switch v := p.options["num_ctx"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// ollamaFormat maps a response format to Ollama's format field.
+func ollamaFormat(f *ResponseFormat) interface{} {
+	if !f.JSON() {
+		return nil
+	}
+	if f.Type == FormatJSONSchema && f.Schema != nil {
+		return f.Schema
+	}
+	return "json"
+}
+
+func (p *OllamaProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	resp, err := p.generate(ctx, input, params, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode ollama response: %w", err)
+	}
+	if out.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", out.Error)
+	}
+	p.reportUsage(ctx, out)
+	return out.Response, nil
+}
+
+// GenerateStream reads Ollama's newline-delimited JSON stream.
+func (p *OllamaProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	resp, err := p.generate(ctx, input, params, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var sb strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ev ollamaResponse
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return sb.String(), fmt.Errorf("decode ollama stream: %w", err)
+		}
+		if ev.Error != "" {
+			return sb.String(), fmt.Errorf("ollama error: %s", ev.Error)
+		}
+		if ev.Response != "" {
+			sb.WriteString(ev.Response)
+			if err := onToken(ev.Response); err != nil {
+				return sb.String(), err
+			}
+		}
+		if ev.Done {
+			p.reportUsage(ctx, ev)
+			break
+		}
+	}
+	return sb.String(), sc.Err()
+}
+
+func (p *OllamaProvider) generate(ctx context.Context, input string, params Params, stream bool) (*http.Response, error) {
+	return p.post(ctx, "/api/generate", ollamaRequest{
+		Model:     p.model,
+		Prompt:    input,
+		Stream:    stream,
+		Format:    ollamaFormat(params.ResponseFormat),
+		Options:   p.requestOptions(params),
+		KeepAlive: p.keepAlive,
+	})
+}
+
+// GenerateWithTools calls /api/chat with the tools, which Ollama passes to
+// models trained for function calling. Ollama has no tool choice, so a named
+// choice offers only that tool.
+func (p *OllamaProvider) GenerateWithTools(ctx context.Context, input string, params Params) (Completion, error) {
+	var tools []ollamaTool
+	for _, t := range forcedTools(params) {
+		tools = append(tools, ollamaTool{Type: "function", Function: t})
+	}
+	resp, err := p.post(ctx, "/api/chat", ollamaChatRequest{
+		Model:     p.model,
+		Messages:  []ollamaMessage{{Role: "user", Content: input}},
+		Tools:     tools,
+		Format:    ollamaFormat(params.ResponseFormat),
+		Options:   p.requestOptions(params),
+		KeepAlive: p.keepAlive,
+	})
+	if err != nil {
+		return Completion{}, err
+	}
+	defer resp.Body.Close()
+	var out ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Completion{}, fmt.Errorf("decode ollama response: %w", err)
+	}
+	if out.Error != "" {
+		return Completion{}, fmt.Errorf("ollama error: %s", out.Error)
+	}
+	p.reportUsage(ctx, ollamaResponse{PromptEvalCount: out.PromptEvalCount, EvalCount: out.EvalCount})
+	c := Completion{Text: out.Message.Content}
+	for _, tc := range out.Message.ToolCalls {
+		c.ToolCalls = append(c.ToolCalls, ToolCall{Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+	}
+	return c, nil
+}
+
+// post sends body to an Ollama API path and returns the response on a 2xx status.
+func (p *OllamaProvider) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	by, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host+path, bytes.NewReader(by))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setTraceHeaders(ctx, req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e ollamaResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			// e.g. `model "x" not found, try pulling it first`
+			return nil, fmt.Errorf("ollama error: %s", e.Error)
+		}
+		return nil, NewStatusError("ollama", resp)
+	}
+	return resp, nil
+}
+
+// reportUsage reports the token counts Ollama measured with the model's tokenizer.
+func (p *OllamaProvider) reportUsage(ctx context.Context, r ollamaResponse) {
+	if r.PromptEvalCount > 0 || r.EvalCount > 0 {
+		ReportUsage(ctx, Usage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount})
+	}
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func fakeOllama(t *testing.T, got *ollamaRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(got)
+		if got.Model != "llama3.2" && got.Model != "qwen2.5:0.5b" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found, try pulling it first"}`, got.Model)
+			return
+		}
+		if !got.Stream {
+			fmt.Fprint(w, `{"response":"Hi!","done":true,"prompt_eval_count":7,"eval_count":3}`)
+			return
+		}
+		fmt.Fprintln(w, `{"response":"H","done":false}`)
+		fmt.Fprintln(w, `{"response":"i!","done":false}`)
+		fmt.Fprintln(w, `{"response":"","done":true,"prompt_eval_count":7,"eval_count":3}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOllamaProvider_AutoDetectedFromEnv(t *testing.T) {
+	var got ollamaRequest
+	srv := fakeOllama(t, &got)
+	t.Setenv("CMP_PROJECT_ROOT", t.TempDir())
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	prov, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, meter := WithUsageMeter(context.Background())
+	out, err := prov.Generate(ctx, "hello", Params{MaxNewTokens: 32, Temperature: 0.3})
+	if err != nil || out != "Hi!" {
+		t.Fatalf("Generate = %q, %v", out, err)
+	}
+	if got.Model != DefaultOllamaModel || got.Options["num_predict"] != float64(32) || got.Options["temperature"] != 0.3 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if u := meter.Total(); u.PromptTokens != 7 || u.CompletionTokens != 3 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	var toks []string
+	out, err = prov.(StreamingProvider).GenerateStream(context.Background(), "hello", Params{}, func(tok string) error {
+		toks = append(toks, tok)
+		return nil
+	})
+	if err != nil || out != "Hi!" || strings.Join(toks, "|") != "H|i!" {
+		t.Fatalf("GenerateStream = %q %v, %v", out, toks, err)
+	}
+
+	t.Setenv("OLLAMA_MODEL", "missing")
+	if _, err := NewOllamaProviderFromEnv().Generate(context.Background(), "hello", Params{}); err == nil || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Fatalf("expected model not found error, got %v", err)
+	}
+}
+
+func TestOllamaProvider_EnvironmentConfig(t *testing.T) {
+	var got ollamaRequest
+	srv := fakeOllama(t, &got)
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	cfg := fmt.Sprintf("providers:\n  ollama:\n    host: %s\n    model: qwen2.5:0.5b\n    keep_alive: 10m\n    options:\n      num_ctx: 8192\n      temperature: 0.9\n", srv.URL)
+	_ = os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), []byte(cfg), 0o644)
+	t.Setenv("CMP_PROJECT_ROOT", root)
+
+	prov := NewOllamaProviderFromEnv()
+	if prov == nil {
+		t.Fatal("providers.ollama not detected")
+	}
+	if _, err := prov.Generate(context.Background(), "hello", Params{Temperature: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "qwen2.5:0.5b" || got.KeepAlive != "10m" || got.Options["num_ctx"] != float64(8192) || got.Options["temperature"] != 0.1 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if envModelID() != "qwen2.5:0.5b" {
+		t.Fatalf("envModelID = %q", envModelID())
+	}
+}
+
+func TestNormalizeOllamaHost(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                       "http://127.0.0.1:11434",
+		"0.0.0.0":                "http://127.0.0.1:11434",
+		"gpu-box:8080":           "http://gpu-box:8080",
+		"https://ollama.example": "https://ollama.example:11434",
+	} {
+		if got := normalizeOllamaHost(in); got != want {
+			t.Errorf("normalizeOllamaHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOllamaProvider_Ping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest"},{"name":"qwen2.5:0.5b"}]}`)
+	}))
+	defer srv.Close()
+	for model, ok := range map[string]bool{"": true, "qwen2.5:0.5b": true, "mistral": false} {
+		err := NewOllamaProvider(srv.URL, model, nil, "").Ping(context.Background())
+		if (err == nil) != ok {
+			t.Errorf("model %q: ping error = %v", model, err)
+		}
+	}
+	srv.Close()
+	if err := NewOllamaProvider(srv.URL, "", nil, "").Ping(context.Background()); err == nil {
+		t.Fatal("expected an error for an unreachable server")
+	}
+}
+
+func TestOllamaProvider_ForwardsTraceHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, `{"response":"Hi!","done":true}`)
+	}))
+	defer srv.Close()
+	prov := NewOllamaProvider(srv.URL, "llama3.2", nil, "")
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.WithValue(context.Background(), "request_id", "req-42"), sc)
+	if _, err := prov.Generate(ctx, "hello", Params{}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Request-ID") != "req-42" || got.Get("traceparent") != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("trace headers not forwarded: %v", got)
+	}
+}
@@ -1,14 +1,30 @@
 package model
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+)
 
 // Params defines model generation parameters that influence decoding.
 // Fields may be ignored by providers that do not support them.
 type Params struct {
-	Temperature   float64 // Sampling temperature
-	TopP          float64 // Nucleus sampling probability
-	MaxNewTokens  int     // Maximum number of new tokens to generate
-	RepetitionPen float64 // Repetition penalty
+	Temperature   float64  // Sampling temperature
+	TopP          float64  // Nucleus sampling probability
+	MaxNewTokens  int      // Maximum number of new tokens to generate
+	RepetitionPen float64  // Repetition penalty
+	Stop          []string // Sequences that end generation; not included in the output
+
+	// ResponseFormat constrains the output, e.g. to JSON (JSON mode)
+	ResponseFormat *ResponseFormat
+	// Tools are functions the model may call; see Complete (tools.go)
+	Tools []Tool
+	// ToolChoice is auto (default), none, required, or the name of the tool to call
+	ToolChoice string
 }
 
 // Provider defines an inference provider capable of generating text based
@@ -17,8 +33,44 @@ type Provider interface {
 	Generate(ctx context.Context, input string, params Params) (string, error)
 }
 
-// NewLocalProviderFromEnv returns a Provider backed by a local Python process
-// when local-first variables are enabled. Implementation provided in local_provider.go.
+// StreamingProvider is implemented by providers that can emit output incrementally.
+// onToken is called for each generated chunk; returning an error aborts generation.
+// The full output is returned once generation completes.
+type StreamingProvider interface {
+	Provider
+	GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error)
+}
+
+// NewLocalProviderFromEnv returns a Provider for local inference when
+// local-first variables are enabled. CMP_LOCAL_BACKEND selects the runtime:
+// transformers (default, local_provider.go) or llamacpp (llamacpp_provider.go).
 func NewLocalProviderFromEnv() (Provider, error) {
-	return newLocalPythonProviderFromEnv()
+	return newLocalProvider("")
+}
+
+// newLocalProvider builds the configured local backend. model, when set,
+// overrides the backend's environment-configured model.
+func newLocalProvider(model string) (Provider, error) {
+	switch backend := strings.ToLower(os.Getenv("CMP_LOCAL_BACKEND")); backend {
+	case "", "transformers":
+		prov, err := newLocalPythonProviderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		prov.modelID = model
+		return prov, nil
+	case "llamacpp", "llama.cpp":
+		return newLlamaCppProviderFromEnv(model)
+	default:
+		return nil, fmt.Errorf("unsupported CMP_LOCAL_BACKEND %q (transformers|llamacpp)", backend)
+	}
+}
+
+// setTraceHeaders forwards the server's request ID and W3C trace context in
+// ctx to a provider API call, so provider-side logs can be correlated.
+func setTraceHeaders(ctx context.Context, req *http.Request) {
+	if id, _ := ctx.Value("request_id").(string); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
 }
@@ -0,0 +1,304 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned without calling the provider while its model's
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// StatusError is an HTTP error response from a hosted provider.
+type StatusError struct {
+	Provider   string
+	Code       int
+	Status     string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("%s api error: %s", e.Provider, e.Status) }
+
+// NewStatusError builds a StatusError from a non-2xx response.
+func NewStatusError(provider string, resp *http.Response) *StatusError {
+	e := &StatusError{Provider: provider, Code: resp.StatusCode, Status: resp.Status}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return e
+}
+
+// IsRetryable reports whether err is a transient provider failure: a 408, 429
+// or 5xx response, or a network error. Cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.Code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Breaker states as reported by ProviderBreakerState.
+const (
+	BreakerClosed   = 0
+	BreakerHalfOpen = 1
+	BreakerOpen     = 2
+)
+
+var (
+	// ProviderRetries counts retried provider calls by model and reason (status code or "network").
+	ProviderRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_provider_retries_total",
+		Help: "Provider calls retried after a transient failure, by model and reason.",
+	}, []string{"model", "reason"})
+	// ProviderBreakerState is the circuit breaker state per model (0 closed, 1 half-open, 2 open).
+	ProviderBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_provider_breaker_state",
+		Help: "Circuit breaker state per model: 0 closed, 1 half-open, 2 open.",
+	}, []string{"model"})
+	// ProviderBreakerRejections counts calls short-circuited by an open breaker.
+	ProviderBreakerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_provider_breaker_rejections_total",
+		Help: "Provider calls rejected while the model's circuit breaker was open.",
+	}, []string{"model"})
+)
+
+// ResilienceConfig controls retries and circuit breaking around a provider.
+type ResilienceConfig struct {
+	MaxAttempts      int           // total attempts per call, including the first
+	BaseDelay        time.Duration // first backoff; doubles per retry with jitter
+	MaxDelay         time.Duration
+	FailureThreshold int           // consecutive transient failures that open the breaker
+	OpenTimeout      time.Duration // time open before a half-open probe is allowed
+}
+
+// DefaultResilienceConfig returns the defaults used when no overrides are set.
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second, FailureThreshold: 5, OpenTimeout: 30 * time.Second}
+}
+
+// ResilienceConfigFromEnv applies CMP_PROVIDER_MAX_ATTEMPTS, CMP_PROVIDER_RETRY_BASE_DELAY,
+// CMP_PROVIDER_RETRY_MAX_DELAY, CMP_PROVIDER_BREAKER_FAILURES and
+// CMP_PROVIDER_BREAKER_OPEN_TIMEOUT to the defaults.
+func ResilienceConfigFromEnv() ResilienceConfig {
+	c := DefaultResilienceConfig()
+	if v, err := strconv.Atoi(os.Getenv("CMP_PROVIDER_MAX_ATTEMPTS")); err == nil && v > 0 {
+		c.MaxAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CMP_PROVIDER_BREAKER_FAILURES")); err == nil && v > 0 {
+		c.FailureThreshold = v
+	}
+	for env, field := range map[string]*time.Duration{
+		"CMP_PROVIDER_RETRY_BASE_DELAY":     &c.BaseDelay,
+		"CMP_PROVIDER_RETRY_MAX_DELAY":      &c.MaxDelay,
+		"CMP_PROVIDER_BREAKER_OPEN_TIMEOUT": &c.OpenTimeout,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(env)); err == nil && d >= 0 {
+			*field = d
+		}
+	}
+	return c
+}
+
+// Backoff returns the delay before retry n (1-based), with up to 50% jitter.
+func (c ResilienceConfig) Backoff(n int) time.Duration {
+	d := c.BaseDelay << (n - 1)
+	if d > c.MaxDelay || d <= 0 {
+		d = c.MaxDelay
+	}
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// circuitBreaker tracks consecutive transient failures for one model.
+type circuitBreaker struct {
+	model string
+	mu    sync.Mutex
+	state int
+	fails int
+	until time.Time // open until
+	probe bool      // a half-open probe is in flight
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*circuitBreaker{}
+)
+
+// breakerFor returns the shared breaker for model, so every provider chain
+// serving the same model sees the same state.
+func breakerFor(model string) *circuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[model]
+	if !ok {
+		b = &circuitBreaker{model: model}
+		breakers[model] = b
+		ProviderBreakerState.WithLabelValues(model).Set(BreakerClosed)
+	}
+	return b
+}
+
+// allow reports whether a call may proceed. After the open timeout a single
+// half-open probe is let through; its outcome closes or reopens the breaker.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.until) {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probe = true
+		return true
+	case BreakerHalfOpen:
+		if b.probe {
+			return false
+		}
+		b.probe = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with a call outcome. Only transient failures
+// count; a 4xx for a bad request says nothing about the endpoint's health.
+func (b *circuitBreaker) record(err error, cfg ResilienceConfig, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probe = false
+	if err == nil || !IsRetryable(err) {
+		if err == nil || b.state == BreakerHalfOpen {
+			b.fails = 0
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	b.fails++
+	if b.state == BreakerHalfOpen || b.fails >= cfg.FailureThreshold {
+		b.until = now.Add(cfg.OpenTimeout)
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s int) {
+	b.state = s
+	ProviderBreakerState.WithLabelValues(b.model).Set(float64(s))
+}
+
+// ResilientProvider retries transient failures of the wrapped provider with
+// exponential backoff and stops calling it while its model's breaker is open.
+type ResilientProvider struct {
+	inner   Provider
+	model   string
+	cfg     ResilienceConfig
+	breaker *circuitBreaker
+}
+
+// WithResilience wraps p with retries and a circuit breaker shared by all
+// providers for model.
+func WithResilience(p Provider, model string, cfg ResilienceConfig) *ResilientProvider {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	return &ResilientProvider{inner: p, model: model, cfg: cfg, breaker: breakerFor(model)}
+}
+
+func (r *ResilientProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	return r.call(ctx, func() (string, bool, error) {
+		out, err := r.inner.Generate(ctx, input, params)
+		return out, false, err
+	})
+}
+
+// GenerateWithTools retries Complete on the wrapped provider.
+func (r *ResilientProvider) GenerateWithTools(ctx context.Context, input string, params Params) (Completion, error) {
+	var c Completion
+	_, err := r.call(ctx, func() (string, bool, error) {
+		var err error
+		c, err = Complete(ctx, r.inner, input, params)
+		return c.Text, false, err
+	})
+	if err != nil {
+		return Completion{}, err
+	}
+	return c, nil
+}
+
+// GenerateStream retries only until the first token has been emitted.
+func (r *ResilientProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	sp, ok := r.inner.(StreamingProvider)
+	if !ok {
+		return r.Generate(ctx, input, params)
+	}
+	return r.call(ctx, func() (string, bool, error) {
+		emitted := false
+		out, err := sp.GenerateStream(ctx, input, params, func(tok string) error {
+			emitted = true
+			return onToken(tok)
+		})
+		return out, emitted, err
+	})
+}
+
+// call runs fn under the breaker, retrying transient failures. fn reports
+// whether output already reached the caller, in which case it is not retried.
+func (r *ResilientProvider) call(ctx context.Context, fn func() (string, bool, error)) (string, error) {
+	var err error
+	for attempt := 1; attempt <= r.cfg.MaxAttempts; attempt++ {
+		if !r.breaker.allow(time.Now()) {
+			ProviderBreakerRejections.WithLabelValues(r.model).Inc()
+			if err != nil {
+				return "", fmt.Errorf("%w: %v", ErrCircuitOpen, err)
+			}
+			return "", fmt.Errorf("%w for model %s", ErrCircuitOpen, r.model)
+		}
+		var out string
+		var emitted bool
+		out, emitted, err = fn()
+		r.breaker.record(err, r.cfg, time.Now())
+		if err == nil || emitted || !IsRetryable(err) || attempt == r.cfg.MaxAttempts {
+			return out, err
+		}
+		delay := r.cfg.Backoff(attempt)
+		var se *StatusError
+		if errors.As(err, &se) && se.RetryAfter > delay && se.RetryAfter <= r.cfg.MaxDelay {
+			delay = se.RetryAfter
+		}
+		ProviderRetries.WithLabelValues(r.model, retryReason(err)).Inc()
+		if sleepCtx(ctx, delay) != nil {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+func retryReason(err error) string {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return strconv.Itoa(se.Code)
+	}
+	return "network"
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func fastResilience() ResilienceConfig {
+	return ResilienceConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond}
+}
+
+func TestResilientProvider_RetriesTransientHFErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "loading", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"generated_text":"ok"}]`)
+	}))
+	defer srv.Close()
+
+	cfg := fastResilience()
+	cfg.FailureThreshold = 10
+	p := WithResilience(NewHuggingFaceAPIProvider("tok", srv.URL, "retry-model"), "retry-model", cfg)
+	out, err := p.Generate(context.Background(), "hi", Params{})
+	if err != nil || out != "ok" || calls != 3 {
+		t.Fatalf("Generate = %q, %v after %d calls", out, err, calls)
+	}
+
+	// Client errors are returned as-is
+	bad := WithResilience(staticProvider{err: &StatusError{Provider: "hf", Code: 400, Status: "400 Bad Request"}}, "retry-model-4xx", cfg)
+	if _, err := bad.Generate(context.Background(), "hi", Params{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected 400 error, got %v", err)
+	}
+}
+
+func TestResilientProvider_BreakerOpensAndProbes(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var calls int32
+	inner := providerFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		if failing.Load() {
+			return "", &StatusError{Provider: "hf", Code: 502, Status: "502 Bad Gateway"}
+		}
+		return "ok", nil
+	})
+	cfg := fastResilience()
+	cfg.MaxAttempts = 1
+	p := WithResilience(inner, "breaker-model", cfg)
+
+	for i := 0; i < 2; i++ {
+		_, _ = p.Generate(context.Background(), "hi", Params{})
+	}
+	if _, err := p.Generate(context.Background(), "hi", Params{}); !errors.Is(err, ErrCircuitOpen) || calls != 2 {
+		t.Fatalf("expected open breaker without calling the provider, got %v after %d calls", err, calls)
+	}
+
+	// Another provider for the same model shares the breaker
+	if _, err := WithResilience(inner, "breaker-model", cfg).Generate(context.Background(), "hi", Params{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("breaker not shared: %v", err)
+	}
+
+	// A failed half-open probe reopens; a successful one closes
+	time.Sleep(cfg.OpenTimeout + 10*time.Millisecond)
+	if _, err := p.Generate(context.Background(), "hi", Params{}); errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Fatalf("expected a half-open probe, got %v after %d calls", err, calls)
+	}
+	if _, err := p.Generate(context.Background(), "hi", Params{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected breaker to reopen, got %v", err)
+	}
+	failing.Store(false)
+	time.Sleep(cfg.OpenTimeout + 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if out, err := p.Generate(context.Background(), "hi", Params{}); err != nil || out != "ok" {
+			t.Fatalf("expected breaker to close, got %q, %v", out, err)
+		}
+	}
+}
+
+type providerFunc func(context.Context) (string, error)
+
+func (f providerFunc) Generate(ctx context.Context, _ string, _ Params) (string, error) {
+	return f(ctx)
+}
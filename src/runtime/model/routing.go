@@ -0,0 +1,487 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RoutingFile is the project-relative path of the model routing configuration.
+const RoutingFile = "config/providers/routing.yaml"
+
+// DefaultProviderName refers to the provider configured through environment
+// variables (see FromEnv). It needs no entry under providers.
+const DefaultProviderName = "default"
+
+// ProviderSpec declares a named provider in routing.yaml.
+type ProviderSpec struct {
+	Type     string `yaml:"type"`      // huggingface|local|llamacpp|ollama|mock
+	Model    string `yaml:"model"`     // model ID passed to the provider
+	Endpoint string `yaml:"endpoint"`  // optional API base URL
+	TokenEnv string `yaml:"token_env"` // env var holding the API token (huggingface: HF_TOKEN)
+	Timeout  string `yaml:"timeout"`   // per-attempt timeout, e.g. "30s"
+	Script   string `yaml:"script"`    // mock script path, relative to the project root
+	// EndpointType is how a huggingface provider calls Endpoint: inference-api,
+	// endpoint (a dedicated Inference Endpoint) or tgi; see HFEndpointType.
+	EndpointType string `yaml:"endpoint_type"`
+	// ContextWindow is the model's context window in tokens; when 0 it comes
+	// from the provider (llama.cpp --ctx-size, Ollama num_ctx) if known.
+	ContextWindow int `yaml:"context_window"`
+	// MaxConcurrent caps the calls in flight to the provider; further calls
+	// wait for a slot for up to MaxWait (a Go duration, default 30s; "0"
+	// waits until the request deadline). 0 leaves the provider unlimited.
+	MaxConcurrent int    `yaml:"max_concurrent"`
+	MaxWait       string `yaml:"max_wait"`
+}
+
+// Route maps a component and/or context to an ordered provider chain: the first
+// entry is the primary, the rest are fallbacks tried on error or timeout.
+type Route struct {
+	Component string   `yaml:"component"`
+	Context   string   `yaml:"context"`
+	Providers []string `yaml:"providers"`
+}
+
+// RoutingConfig is the parsed config/providers/routing.yaml.
+type RoutingConfig struct {
+	Providers map[string]ProviderSpec `yaml:"providers"`
+	Default   []string                `yaml:"default"`
+	Routes    []Route                 `yaml:"routes"`
+}
+
+// LoadRoutingConfig reads the routing config under root. It returns nil when the
+// file does not exist.
+func LoadRoutingConfig(root string) (*RoutingConfig, error) {
+	by, err := os.ReadFile(filepath.Join(root, RoutingFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg RoutingConfig
+	if err := yaml.Unmarshal(by, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", RoutingFile, err)
+	}
+	return &cfg, nil
+}
+
+// ModelInfo identifies the provider and model that served a request.
+type ModelInfo struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	// Attempts counts providers tried, including the one that succeeded.
+	Attempts int `json:"attempts"`
+}
+
+// target is a provider in a routing chain.
+type target struct {
+	name     string
+	model    string
+	provider Provider
+	timeout  time.Duration
+	// window is the context window in tokens, 0 when unknown.
+	window    int
+	tokenizer Tokenizer
+	// limit is shared by the copies of a target in every chain; nil when
+	// the provider has no concurrency limit.
+	limit *concurrencyLimit
+}
+
+// Router resolves the provider chain for a component/context pair.
+type Router struct {
+	targets  map[string]target
+	specs    map[string]ProviderSpec
+	defaults []string
+	routes   []Route
+
+	mu       sync.Mutex
+	variants map[string]target // per-request model overrides, by provider and model
+}
+
+// NewRouter builds the providers declared in cfg. fallback is registered as the
+// "default" provider and serves requests when cfg is nil or declares no default
+// chain; it may be nil.
+func NewRouter(cfg *RoutingConfig, fallback Provider) (*Router, error) {
+	r := &Router{targets: map[string]target{}, specs: map[string]ProviderSpec{}}
+	if fallback != nil {
+		t := target{name: DefaultProviderName, model: envModelID(), provider: fallback, window: envContextWindow(), limit: envConcurrencyLimit()}
+		t.inspect(fallback)
+		r.targets[DefaultProviderName] = t
+	}
+	if cfg == nil {
+		r.defaults = []string{DefaultProviderName}
+		return r, nil
+	}
+	for name, spec := range cfg.Providers {
+		t, err := newTarget(name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %w", name, err)
+		}
+		r.targets[name] = t
+		r.specs[name] = spec
+	}
+	r.defaults = cfg.Default
+	if len(r.defaults) == 0 {
+		r.defaults = []string{DefaultProviderName}
+	}
+	r.routes = cfg.Routes
+	for _, chain := range append([][]string{r.defaults}, routeChains(cfg.Routes)...) {
+		for _, name := range chain {
+			if _, ok := r.targets[name]; !ok && name != DefaultProviderName {
+				return nil, fmt.Errorf("route references unknown provider %q", name)
+			}
+		}
+	}
+	return r, nil
+}
+
+func routeChains(routes []Route) [][]string {
+	out := make([][]string, 0, len(routes))
+	for _, rt := range routes {
+		out = append(out, rt.Providers)
+	}
+	return out
+}
+
+func newTarget(name string, spec ProviderSpec) (target, error) {
+	t := target{name: name, model: spec.Model, window: spec.ContextWindow}
+	if spec.Timeout != "" {
+		d, err := time.ParseDuration(spec.Timeout)
+		if err != nil {
+			return t, fmt.Errorf("invalid timeout: %w", err)
+		}
+		t.timeout = d
+	}
+	limit, err := newConcurrencyLimit(name, spec.MaxConcurrent, spec.MaxWait)
+	if err != nil {
+		return t, err
+	}
+	t.limit = limit
+	if ProviderMode() == ModeReplay {
+		// Recorded outputs stand in for the provider; no credentials are needed
+		prov, err := WithProviderMode(nil)
+		t.provider = prov
+		return t, err
+	}
+	switch strings.ToLower(spec.Type) {
+	case "huggingface", "hf":
+		tokenEnv := spec.TokenEnv
+		if tokenEnv == "" {
+			tokenEnv = "HF_TOKEN"
+		}
+		endpointType, err := HFEndpointType(spec.EndpointType, spec.Endpoint)
+		if err != nil {
+			return t, err
+		}
+		token := os.Getenv(tokenEnv)
+		if endpointType == HFInferenceAPI && (token == "" || spec.Model == "") {
+			return t, fmt.Errorf("%s and model are required for huggingface", tokenEnv)
+		}
+		prov, err := NewHuggingFaceEndpointProvider(endpointType, token, spec.Endpoint, spec.Model)
+		if err != nil {
+			return t, err
+		}
+		if t.model == "" {
+			t.model = prov.Model()
+		}
+		t.provider = WithResilience(prov, prov.Model(), ResilienceConfigFromEnv())
+	case "local":
+		prov, err := newLocalProvider(spec.Model)
+		if err != nil {
+			return t, err
+		}
+		t.provider = prov
+	case "ollama":
+		t.provider = NewOllamaProvider(spec.Endpoint, spec.Model, nil, "")
+	case "llamacpp":
+		prov, err := newLlamaCppProviderFromEnv(spec.Model)
+		if err != nil {
+			return t, err
+		}
+		t.provider = prov
+	case "mock":
+		var script *MockScript
+		if spec.Script != "" {
+			path := spec.Script
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(os.Getenv("CMP_PROJECT_ROOT"), path)
+			}
+			s, err := LoadMockScript(path)
+			if err != nil {
+				return t, err
+			}
+			script = s
+		}
+		prov, err := NewMockProvider(script)
+		if err != nil {
+			return t, err
+		}
+		t.provider = prov
+	default:
+		return t, fmt.Errorf("unsupported provider type %q", spec.Type)
+	}
+	t.inspect(t.provider)
+	prov, err := WithProviderMode(t.provider)
+	t.provider = prov
+	return t, err
+}
+
+// inspect picks up the tokenizer and context window of p, if it has them. A
+// window declared in routing.yaml or the environment takes precedence.
+func (t *target) inspect(p Provider) {
+	t.tokenizer, _ = p.(Tokenizer)
+	if cw, ok := p.(ContextWindower); ok && t.window == 0 {
+		t.window = cw.ContextWindow()
+	}
+}
+
+// envModelID returns the model configured for the environment provider.
+func envModelID() string {
+	if cfg, ok := loadOllamaConfig(); ok {
+		if cfg.Model == "" {
+			return DefaultOllamaModel
+		}
+		return cfg.Model
+	}
+	if os.Getenv("CMP_LOCAL_MODELS") == "true" {
+		if strings.HasPrefix(strings.ToLower(os.Getenv("CMP_LOCAL_BACKEND")), "llama") {
+			return filepath.Base(os.Getenv("CMP_LLAMACPP_MODEL"))
+		}
+		return os.Getenv("CMP_LOCAL_MODEL_ID")
+	}
+	return os.Getenv("HF_MODEL_ID")
+}
+
+// For returns the provider chain for a request, or nil when no provider is
+// available. The most specific route wins: component and context, then component,
+// then context. Each call returns a new chain, so Served is per request.
+func (r *Router) For(component, contextName string) *FallbackProvider {
+	return r.Named(r.route(component, contextName)...)
+}
+
+// route returns the provider names routed for a component/context pair.
+func (r *Router) route(component, contextName string) []string {
+	chain := r.defaults
+	best := -1
+	for _, rt := range r.routes {
+		score := 0
+		switch {
+		case rt.Component != "" && rt.Component != component, rt.Context != "" && rt.Context != contextName:
+			continue
+		case rt.Component != "" && rt.Context != "":
+			score = 3
+		case rt.Component != "":
+			score = 2
+		case rt.Context != "":
+			score = 1
+		}
+		if score > best {
+			best, chain = score, rt.Providers
+		}
+	}
+	return chain
+}
+
+// Override returns a chain serving a request that names its own provider and/or
+// model. The named provider replaces the routed chain, without fallbacks; a model
+// override applies to the named provider, or to the primary of the routed chain.
+// Only huggingface (Inference API), ollama and mock providers can switch models
+// per request, as local and llama.cpp models are loaded at startup and dedicated
+// HF endpoints serve one model. With neither set it is For.
+func (r *Router) Override(component, contextName, provider, model string) (*FallbackProvider, error) {
+	if provider == "" && model == "" {
+		return r.For(component, contextName), nil
+	}
+	if provider == "" {
+		if chain := r.route(component, contextName); len(chain) > 0 {
+			provider = chain[0]
+		}
+	}
+	t, ok := r.targets[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+	if model != "" && model != t.model {
+		var err error
+		if t, err = r.variant(provider, model); err != nil {
+			return nil, err
+		}
+	}
+	return &FallbackProvider{targets: []target{t}}, nil
+}
+
+// variant returns the named provider serving model, building it on first use.
+func (r *Router) variant(provider, model string) (target, error) {
+	spec, ok := r.specs[provider]
+	if !ok {
+		return target{}, fmt.Errorf("provider %q cannot switch models per request", provider)
+	}
+	switch strings.ToLower(spec.Type) {
+	case "huggingface", "hf":
+		// Dedicated endpoints and TGI servers serve the model they were deployed with
+		if t, _ := HFEndpointType(spec.EndpointType, spec.Endpoint); t != HFInferenceAPI {
+			return target{}, fmt.Errorf("provider %q (%s) serves a single model", provider, t)
+		}
+	case "ollama", "mock":
+	default:
+		return target{}, fmt.Errorf("provider %q (%s) cannot switch models per request", provider, spec.Type)
+	}
+	key := provider + "\x00" + model
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.variants[key]; ok {
+		return t, nil
+	}
+	spec.Model = model
+	if r.variants == nil {
+		r.variants = map[string]target{}
+	}
+	t, err := newTarget(provider, spec)
+	if err != nil {
+		return target{}, fmt.Errorf("provider %q: %w", provider, err)
+	}
+	// Every model of a provider is served by the same backend, so they share its slots
+	t.limit = r.targets[provider].limit
+	r.variants[key] = t
+	return t, nil
+}
+
+// Named returns a chain over the named providers, skipping unknown names, or nil
+// when none is available.
+func (r *Router) Named(chain ...string) *FallbackProvider {
+	fp := &FallbackProvider{}
+	for _, name := range chain {
+		if t, ok := r.targets[name]; ok {
+			fp.targets = append(fp.targets, t)
+		}
+	}
+	if len(fp.targets) == 0 {
+		return nil
+	}
+	return fp
+}
+
+// FallbackProvider tries its providers in order until one succeeds and records
+// which one served the request.
+type FallbackProvider struct {
+	targets []target
+	mu      sync.Mutex
+	served  ModelInfo
+}
+
+// Served reports the provider and model that produced the last successful output.
+func (f *FallbackProvider) Served() ModelInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.served
+}
+
+func (f *FallbackProvider) setServed(t target, attempts int) {
+	f.mu.Lock()
+	f.served = ModelInfo{Provider: t.name, Model: t.model, Attempts: attempts}
+	f.mu.Unlock()
+}
+
+func (f *FallbackProvider) Generate(ctx context.Context, input string, params Params) (string, error) {
+	var errs []error
+	for i, t := range f.targets {
+		out, err := meterCall(ctx, input, func() (string, error) {
+			return t.attempt(ctx, func(ctx context.Context) (string, error) {
+				return t.provider.Generate(ctx, input, params)
+			})
+		})
+		if err == nil {
+			f.setServed(t, i+1)
+			return out, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// GenerateWithTools tries each provider in turn with Complete, so every
+// provider in the chain uses native function calling when it has it.
+func (f *FallbackProvider) GenerateWithTools(ctx context.Context, input string, params Params) (Completion, error) {
+	var errs []error
+	for i, t := range f.targets {
+		var c Completion
+		_, err := meterCall(ctx, input, func() (string, error) {
+			return t.attempt(ctx, func(ctx context.Context) (string, error) {
+				var err error
+				c, err = Complete(ctx, t.provider, input, params)
+				return c.Text, err
+			})
+		})
+		if err == nil {
+			f.setServed(t, i+1)
+			return c, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return Completion{}, errors.Join(errs...)
+}
+
+// GenerateStream streams from the first provider that supports it. Once tokens
+// have been emitted, a failure is returned instead of falling back, since the
+// client has already received partial output.
+func (f *FallbackProvider) GenerateStream(ctx context.Context, input string, params Params, onToken func(string) error) (string, error) {
+	var errs []error
+	for i, t := range f.targets {
+		emitted := false
+		out, err := meterCall(ctx, input, func() (string, error) {
+			return t.attempt(ctx, func(ctx context.Context) (string, error) {
+				if sp, ok := t.provider.(StreamingProvider); ok {
+					return sp.GenerateStream(ctx, input, params, func(tok string) error {
+						emitted = true
+						return onToken(tok)
+					})
+				}
+				return t.provider.Generate(ctx, input, params)
+			})
+		})
+		if err == nil {
+			if !emitted && out != "" {
+				if err := onToken(out); err != nil {
+					return "", err
+				}
+			}
+			f.setServed(t, i+1)
+			return out, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+		if emitted || ctx.Err() != nil {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+// attempt calls fn once a concurrency slot is free, bounded by the per-attempt
+// timeout. Time spent waiting for the slot does not count towards the timeout.
+func (t target) attempt(ctx context.Context, fn func(context.Context) (string, error)) (string, error) {
+	release, err := t.limit.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
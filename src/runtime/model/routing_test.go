@@ -0,0 +1,183 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticProvider struct {
+	out   string
+	err   error
+	delay time.Duration
+}
+
+func (p staticProvider) Generate(ctx context.Context, _ string, _ Params) (string, error) {
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return p.out, p.err
+}
+
+func testRouter(targets map[string]target, defaults []string, routes []Route) *Router {
+	return &Router{targets: targets, defaults: defaults, routes: routes}
+}
+
+func TestRouterFor_MostSpecificRouteWins(t *testing.T) {
+	targets := map[string]target{
+		"a": {name: "a", provider: staticProvider{out: "a"}},
+		"b": {name: "b", provider: staticProvider{out: "b"}},
+		"c": {name: "c", provider: staticProvider{out: "c"}},
+		"d": {name: "d", provider: staticProvider{out: "d"}},
+	}
+	r := testRouter(targets, []string{"a"}, []Route{
+		{Context: "Ops", Providers: []string{"b"}},
+		{Component: "Support", Context: "Ops", Providers: []string{"d"}},
+		{Component: "Support", Providers: []string{"c"}},
+	})
+	cases := []struct{ component, context, want string }{
+		{"Other", "Other", "a"},
+		{"Other", "Ops", "b"},
+		{"Support", "Other", "c"},
+		{"Support", "Ops", "d"},
+	}
+	for _, tc := range cases {
+		out, err := r.For(tc.component, tc.context).Generate(context.Background(), "q", Params{})
+		if err != nil || out != tc.want {
+			t.Fatalf("For(%s,%s) = %q, %v; want %q", tc.component, tc.context, out, err, tc.want)
+		}
+	}
+}
+
+func TestFallbackProvider_FallsBackOnErrorAndTimeout(t *testing.T) {
+	targets := map[string]target{
+		"broken": {name: "broken", model: "m1", provider: staticProvider{err: errors.New("boom")}},
+		"slow":   {name: "slow", model: "m2", provider: staticProvider{out: "late", delay: time.Second}, timeout: 10 * time.Millisecond},
+		"ok":     {name: "ok", model: "m3", provider: staticProvider{out: "fine"}},
+	}
+	fp := testRouter(targets, []string{"broken", "slow", "ok"}, nil).For("", "")
+	out, err := fp.Generate(context.Background(), "q", Params{})
+	if err != nil || out != "fine" {
+		t.Fatalf("Generate = %q, %v", out, err)
+	}
+	if got := fp.Served(); got.Provider != "ok" || got.Model != "m3" || got.Attempts != 3 {
+		t.Fatalf("Served = %+v", got)
+	}
+
+	fp = testRouter(targets, []string{"broken", "slow"}, nil).For("", "")
+	if _, err := fp.Generate(context.Background(), "q", Params{}); err == nil || !strings.Contains(err.Error(), "broken: boom") {
+		t.Fatalf("expected joined errors, got %v", err)
+	}
+}
+
+func TestFallbackProvider_StreamsBufferedOutput(t *testing.T) {
+	fp := testRouter(map[string]target{"x": {name: "x", provider: staticProvider{out: "whole"}}}, []string{"x"}, nil).For("", "")
+	var toks []string
+	out, err := fp.GenerateStream(context.Background(), "q", Params{}, func(s string) error { toks = append(toks, s); return nil })
+	if err != nil || out != "whole" || len(toks) != 1 || toks[0] != "whole" {
+		t.Fatalf("GenerateStream = %q, %v, tokens %v", out, err, toks)
+	}
+}
+
+func TestNewRouter_ConfigValidation(t *testing.T) {
+	root := t.TempDir()
+	if cfg, err := LoadRoutingConfig(root); cfg != nil || err != nil {
+		t.Fatalf("missing file should yield nil config, got %v, %v", cfg, err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "config", "providers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "providers:\n  hf:\n    type: huggingface\n    model: org/model\n    token_env: TEST_ROUTING_TOKEN\n    timeout: 5s\nroutes:\n  - component: Support\n    providers: [hf, default]\n"
+	if err := os.WriteFile(filepath.Join(root, RoutingFile), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadRoutingConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRouter(cfg, nil); err == nil || !strings.Contains(err.Error(), "TEST_ROUTING_TOKEN") {
+		t.Fatalf("expected missing token error, got %v", err)
+	}
+	t.Setenv("TEST_ROUTING_TOKEN", "secret")
+	r, err := NewRouter(cfg, staticProvider{out: "env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fp := r.For("Support", ""); len(fp.targets) != 2 || fp.targets[0].timeout != 5*time.Second {
+		t.Fatalf("unexpected chain %+v", fp.targets)
+	}
+	cfg.Routes = append(cfg.Routes, Route{Component: "X", Providers: []string{"nope"}})
+	if _, err := NewRouter(cfg, nil); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("expected unknown provider error, got %v", err)
+	}
+}
+
+func TestRouterOverride_ProviderAndModel(t *testing.T) {
+	cfg := &RoutingConfig{
+		Providers: map[string]ProviderSpec{
+			"fast":  {Type: "mock", Model: "small"},
+			"smart": {Type: "mock", Model: "large"},
+		},
+		Default: []string{"fast", "smart"},
+	}
+	r, err := NewRouter(cfg, staticProvider{out: "env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := r.Override("Support", "", "", "")
+	if err != nil || len(fp.targets) != 2 {
+		t.Fatalf("no override should route normally, got %+v, %v", fp, err)
+	}
+	fp, err = r.Override("Support", "", "smart", "")
+	if err != nil || len(fp.targets) != 1 || fp.targets[0].model != "large" {
+		t.Fatalf("provider override = %+v, %v", fp, err)
+	}
+	fp, err = r.Override("Support", "", "", "tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fp.Generate(context.Background(), "q", Params{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fp.Served(); got.Provider != "fast" || got.Model != "tiny" {
+		t.Fatalf("model override served %+v", got)
+	}
+	if again, _ := r.Override("", "", "fast", "tiny"); again.targets[0].provider != fp.targets[0].provider {
+		t.Fatal("model variants should be reused")
+	}
+	if _, err := r.Override("", "", "nope", ""); err == nil {
+		t.Fatal("expected an unknown provider error")
+	}
+	if _, err := r.Override("", "", DefaultProviderName, "other"); err == nil || !strings.Contains(err.Error(), "cannot switch models") {
+		t.Fatalf("expected the environment provider to keep its model, got %v", err)
+	}
+}
+
+func TestNewRouter_HuggingFaceTGI(t *testing.T) {
+	t.Setenv("HF_TOKEN", "")
+	cfg := &RoutingConfig{Providers: map[string]ProviderSpec{
+		"tgi": {Type: "huggingface", Endpoint: "http://tgi:8080", EndpointType: "tgi"},
+	}, Default: []string{"tgi"}}
+	r, err := NewRouter(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected a TGI server to need no token or model, got %v", err)
+	}
+	if fp := r.For("", ""); fp.targets[0].model != "tgi:8080" {
+		t.Fatalf("expected the server to name the model, got %q", fp.targets[0].model)
+	}
+	if _, err := r.Override("", "", "tgi", "org/other"); err == nil || !strings.Contains(err.Error(), "single model") {
+		t.Fatalf("expected a TGI server to keep its model, got %v", err)
+	}
+	cfg.Providers["tgi"] = ProviderSpec{Type: "huggingface", EndpointType: "tgi"}
+	if _, err := NewRouter(cfg, nil); err == nil || !strings.Contains(err.Error(), "endpoint URL") {
+		t.Fatalf("expected a TGI provider without an endpoint to be refused, got %v", err)
+	}
+}
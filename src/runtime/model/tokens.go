@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"os"
+	"strconv"
+)
+
+// Tokenizer is implemented by providers that can count tokens with the
+// model's own tokenizer.
+type Tokenizer interface {
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// ContextWindower is implemented by providers that know the context window
+// of their model, in tokens.
+type ContextWindower interface {
+	ContextWindow() int
+}
+
+// envContextWindow returns CMP_MODEL_CONTEXT_WINDOW, the context window of the
+// environment provider, or 0 when unset.
+func envContextWindow() int {
+	n, err := strconv.Atoi(os.Getenv("CMP_MODEL_CONTEXT_WINDOW"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// ContextWindow returns the smallest context window declared by the chain's
+// providers, since any of them may end up serving the prompt, or 0 when none
+// is known.
+func (f *FallbackProvider) ContextWindow() int {
+	window := 0
+	for _, t := range f.targets {
+		if t.window > 0 && (window == 0 || t.window < window) {
+			window = t.window
+		}
+	}
+	return window
+}
+
+// CountTokens measures text with the primary provider's tokenizer. exact is
+// false when the provider has none, or it failed, and the count is estimated.
+func (f *FallbackProvider) CountTokens(ctx context.Context, text string) (n int, exact bool) {
+	if len(f.targets) > 0 && f.targets[0].tokenizer != nil {
+		if n, err := f.targets[0].tokenizer.CountTokens(ctx, text); err == nil {
+			return n, true
+		}
+	}
+	return EstimateTokens(text), false
+}
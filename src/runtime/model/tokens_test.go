@@ -0,0 +1,51 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFallbackProvider_ContextWindowAndTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tokenize" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"tokens":[1,2,3]}`)
+	}))
+	defer srv.Close()
+	t.Setenv("CMP_LLAMACPP_URL", srv.URL)
+	t.Setenv("CMP_MODEL_CONTEXT_WINDOW", "8192")
+
+	r, err := NewRouter(&RoutingConfig{
+		Providers: map[string]ProviderSpec{
+			"llama": {Type: "llamacpp", ContextWindow: 2048},
+			"small": {Type: "ollama", Model: "tiny"},
+		},
+		Default: []string{"llama", DefaultProviderName},
+		Routes:  []Route{{Component: "Small", Providers: []string{"small"}}},
+	}, staticProvider{out: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := r.For("Support", "")
+	if w := chain.ContextWindow(); w != 2048 {
+		t.Fatalf("ContextWindow = %d, want the smallest in the chain", w)
+	}
+	if n, exact := chain.CountTokens(context.Background(), "hello tokenizer"); n != 3 || !exact {
+		t.Fatalf("CountTokens = %d, %v", n, exact)
+	}
+	if w := r.Named(DefaultProviderName).ContextWindow(); w != 8192 {
+		t.Fatalf("environment window = %d", w)
+	}
+	small := r.For("Small", "")
+	if w := small.ContextWindow(); w != 0 {
+		t.Fatalf("unknown window = %d", w)
+	}
+	if n, exact := small.CountTokens(context.Background(), "hello tokenizer"); exact || n != EstimateTokens("hello tokenizer") {
+		t.Fatalf("expected an estimate, got %d, %v", n, exact)
+	}
+}
@@ -0,0 +1,264 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Response format types for Params.ResponseFormat.
+const (
+	FormatText       = "text"
+	FormatJSON       = "json_object" // any JSON object
+	FormatJSONSchema = "json_schema" // JSON matching ResponseFormat.Schema
+)
+
+// Tool choices for Params.ToolChoice. Any other value names the tool the
+// model must call.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// ErrInvalidToolCall is returned when the model's tool calls do not match
+// the tools offered or the tool choice.
+var ErrInvalidToolCall = errors.New("invalid tool call")
+
+// ResponseFormat asks the provider to constrain its output. Providers map it
+// to their JSON mode or grammar support; others ignore it.
+type ResponseFormat struct {
+	Type   string                 `json:"type"`
+	Schema map[string]interface{} `json:"schema,omitempty"` // JSON Schema, for json_schema
+}
+
+// JSON reports whether the format requests JSON output.
+func (f *ResponseFormat) JSON() bool {
+	return f != nil && (f.Type == FormatJSON || f.Type == FormatJSONSchema)
+}
+
+// schema returns the JSON Schema to enforce, or an empty object schema in
+// plain JSON mode.
+func (f *ResponseFormat) schema() map[string]interface{} {
+	if f.Type == FormatJSONSchema && f.Schema != nil {
+		return f.Schema
+	}
+	return map[string]interface{}{"type": "object"}
+}
+
+// Tool describes a function the model may call.
+type Tool struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description,omitempty" yaml:"description"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty" yaml:"parameters"` // JSON Schema of the arguments
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Completion is the result of a generation that may call tools.
+type Completion struct {
+	Text      string     `json:"text,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolProvider is implemented by providers with native function calling.
+// Callers should use Complete, which also covers providers without it.
+type ToolProvider interface {
+	Provider
+	GenerateWithTools(ctx context.Context, input string, params Params) (Completion, error)
+}
+
+var toolNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Complete generates a completion with params.Tools available to the model.
+// Providers implementing ToolProvider use their native function calling; for
+// the others the tools are described in the prompt and the model answers with
+// a JSON envelope, enforced by the provider's JSON mode where it has one.
+// Tool calls are checked against the offered tools and the tool choice.
+func Complete(ctx context.Context, p Provider, input string, params Params) (Completion, error) {
+	if len(params.Tools) == 0 || params.ToolChoice == ToolChoiceNone {
+		params.Tools, params.ToolChoice = nil, ""
+		out, err := p.Generate(ctx, input, params)
+		if err != nil {
+			return Completion{}, err
+		}
+		return Completion{Text: out}, nil
+	}
+	if err := validateTools(params); err != nil {
+		return Completion{}, err
+	}
+	var c Completion
+	if tp, ok := p.(ToolProvider); ok {
+		var err error
+		if c, err = tp.GenerateWithTools(ctx, input, params); err != nil {
+			return Completion{}, err
+		}
+	} else {
+		envelope := params
+		envelope.Tools, envelope.ToolChoice = nil, ""
+		envelope.ResponseFormat = &ResponseFormat{Type: FormatJSONSchema, Schema: envelopeSchema(params)}
+		out, err := p.Generate(ctx, ToolPrompt(input, params), envelope)
+		if err != nil {
+			return Completion{}, err
+		}
+		c = parseEnvelope(out)
+	}
+	for i := range c.ToolCalls {
+		if c.ToolCalls[i].ID == "" {
+			c.ToolCalls[i].ID = fmt.Sprintf("call_%d", i)
+		}
+	}
+	return c, checkToolCalls(c, params)
+}
+
+// validateTools checks tool names and that a named tool choice is offered.
+func validateTools(params Params) error {
+	seen := map[string]bool{}
+	for _, t := range params.Tools {
+		if !toolNameRe.MatchString(t.Name) {
+			return fmt.Errorf("invalid tool name %q (letters, digits, _ and -, up to 64)", t.Name)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("duplicate tool %q", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	switch params.ToolChoice {
+	case "", ToolChoiceAuto, ToolChoiceRequired:
+		return nil
+	}
+	if !seen[params.ToolChoice] {
+		return fmt.Errorf("tool choice %q is not one of the offered tools", params.ToolChoice)
+	}
+	return nil
+}
+
+// checkToolCalls verifies c against the offered tools and the tool choice.
+func checkToolCalls(c Completion, params Params) error {
+	offered := map[string]bool{}
+	for _, t := range params.Tools {
+		offered[t.Name] = true
+	}
+	for _, call := range c.ToolCalls {
+		if !offered[call.Name] {
+			return fmt.Errorf("%w: model called unknown tool %q", ErrInvalidToolCall, call.Name)
+		}
+		if params.ToolChoice != "" && params.ToolChoice != ToolChoiceAuto && params.ToolChoice != ToolChoiceRequired && call.Name != params.ToolChoice {
+			return fmt.Errorf("%w: model called %q instead of %q", ErrInvalidToolCall, call.Name, params.ToolChoice)
+		}
+	}
+	if len(c.ToolCalls) == 0 && params.ToolChoice != "" && params.ToolChoice != ToolChoiceAuto {
+		return fmt.Errorf("%w: model answered without calling a tool", ErrInvalidToolCall)
+	}
+	return nil
+}
+
+// forcedTools returns the tools to offer: only the chosen one when the tool
+// choice names a tool.
+func forcedTools(params Params) []Tool {
+	for _, t := range params.Tools {
+		if t.Name == params.ToolChoice {
+			return []Tool{t}
+		}
+	}
+	return params.Tools
+}
+
+// ToolPrompt appends tool descriptions and the JSON envelope instructions to
+// input, for providers without native function calling.
+func ToolPrompt(input string, params Params) string {
+	var sb strings.Builder
+	sb.WriteString(input)
+	sb.WriteString("\n\nYou can call these tools:\n")
+	for _, t := range forcedTools(params) {
+		fmt.Fprintf(&sb, "- %s", t.Name)
+		if t.Description != "" {
+			fmt.Fprintf(&sb, ": %s", t.Description)
+		}
+		if t.Parameters != nil {
+			by, _ := json.Marshal(t.Parameters)
+			fmt.Fprintf(&sb, "\n  arguments schema: %s", by)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nReply with one JSON object and nothing else.\n")
+	sb.WriteString(`To call tools: {"tool_calls": [{"name": "<tool>", "arguments": {...}}]}` + "\n")
+	switch params.ToolChoice {
+	case "", ToolChoiceAuto:
+		sb.WriteString(`To answer directly: {"content": "<answer>"}` + "\n")
+	case ToolChoiceRequired:
+		sb.WriteString("You must call at least one tool.\n")
+	default:
+		fmt.Fprintf(&sb, "You must call the %s tool.\n", params.ToolChoice)
+	}
+	return sb.String()
+}
+
+// envelopeSchema is the JSON Schema of the tool envelope for params.
+func envelopeSchema(params Params) map[string]interface{} {
+	var names []interface{}
+	for _, t := range forcedTools(params) {
+		names = append(names, t.Name)
+	}
+	calls := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name":      map[string]interface{}{"type": "string", "enum": names},
+				"arguments": map[string]interface{}{"type": "object"},
+			},
+			"required": []interface{}{"name", "arguments"},
+		},
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"tool_calls": calls, "content": map[string]interface{}{"type": "string"}},
+	}
+	if params.ToolChoice != "" && params.ToolChoice != ToolChoiceAuto {
+		calls["minItems"] = 1
+		schema["required"] = []interface{}{"tool_calls"}
+	}
+	return schema
+}
+
+// parseEnvelope reads a tool envelope from model output. Output that is not
+// an envelope is returned as text.
+func parseEnvelope(out string) Completion {
+	s := strings.TrimSpace(out)
+	start, end := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return Completion{Text: out}
+	}
+	var env struct {
+		ToolCalls []ToolCall `json:"tool_calls"`
+		Content   *string    `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(s[start:end+1]), &env); err != nil || (env.ToolCalls == nil && env.Content == nil) {
+		return Completion{Text: out}
+	}
+	c := Completion{ToolCalls: env.ToolCalls}
+	if env.Content != nil {
+		c.Text = *env.Content
+	}
+	return c
+}
+
+// truncateAtStop cuts out at the first stop sequence, for providers that
+// cannot stop generation themselves.
+func truncateAtStop(out string, stop []string) string {
+	for _, s := range stop {
+		if i := strings.Index(out, s); s != "" && i >= 0 {
+			out = out[:i]
+		}
+	}
+	return out
+}
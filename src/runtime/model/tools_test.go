@@ -0,0 +1,171 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var weatherTool = Tool{
+	Name:        "get_weather",
+	Description: "Current weather for a city",
+	Parameters: map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+		"required":   []interface{}{"city"},
+	},
+}
+
+// paramsProvider records the prompt and params it was called with.
+type paramsProvider struct {
+	out    string
+	prompt string
+	params Params
+}
+
+func (p *paramsProvider) Generate(_ context.Context, input string, params Params) (string, error) {
+	p.prompt, p.params = input, params
+	return p.out, nil
+}
+
+func TestComplete_PromptedToolCalls(t *testing.T) {
+	prov := &paramsProvider{out: "Sure.\n```json\n{\"tool_calls\": [{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Oslo\"}}]}\n```"}
+	c, err := Complete(context.Background(), prov, "What's the weather in Oslo?", Params{Tools: []Tool{weatherTool}, Stop: []string{"\n\n\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ToolCalls) != 1 || c.ToolCalls[0].Name != "get_weather" || c.ToolCalls[0].Arguments["city"] != "Oslo" || c.ToolCalls[0].ID != "call_0" {
+		t.Fatalf("tool calls = %+v", c.ToolCalls)
+	}
+	if !strings.Contains(prov.prompt, "- get_weather: Current weather for a city") || !strings.Contains(prov.prompt, `{"content": "<answer>"}`) {
+		t.Fatalf("tools not described in prompt:\n%s", prov.prompt)
+	}
+	if prov.params.Tools != nil || !prov.params.ResponseFormat.JSON() || len(prov.params.Stop) != 1 {
+		t.Fatalf("unexpected params %+v", prov.params)
+	}
+
+	prov.out = `{"content": "No tool needed."}`
+	if c, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}}); err != nil || c.Text != "No tool needed." || len(c.ToolCalls) != 0 {
+		t.Fatalf("Complete = %+v, %v", c, err)
+	}
+	prov.out = "plain answer"
+	if c, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}}); err != nil || c.Text != "plain answer" {
+		t.Fatalf("Complete = %+v, %v", c, err)
+	}
+	if _, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}, ToolChoice: ToolChoiceRequired}); !errors.Is(err, ErrInvalidToolCall) {
+		t.Fatalf("expected ErrInvalidToolCall, got %v", err)
+	}
+	prov.out = `{"tool_calls": [{"name": "delete_everything", "arguments": {}}]}`
+	if _, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}}); !errors.Is(err, ErrInvalidToolCall) {
+		t.Fatalf("expected unknown tool error, got %v", err)
+	}
+	if _, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}, ToolChoice: "search"}); err == nil {
+		t.Fatal("expected error for a tool choice that is not offered")
+	}
+
+	// Without tools, or with tool choice none, the provider generates text as usual
+	prov.out = "text"
+	if c, err = Complete(context.Background(), prov, "Hi", Params{Tools: []Tool{weatherTool}, ToolChoice: ToolChoiceNone}); err != nil || c.Text != "text" || prov.prompt != "Hi" || prov.params.ResponseFormat != nil {
+		t.Fatalf("Complete = %+v, %v (prompt %q)", c, err, prov.prompt)
+	}
+}
+
+func TestOllamaProvider_NativeToolsAndFormat(t *testing.T) {
+	var chat ollamaChatRequest
+	var gen ollamaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/chat":
+			_ = json.NewDecoder(r.Body).Decode(&chat)
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Oslo"}}}]},"done":true,"prompt_eval_count":20,"eval_count":9}`)
+		case "/api/generate":
+			_ = json.NewDecoder(r.Body).Decode(&gen)
+			fmt.Fprint(w, `{"response":"{\"ok\":true}","done":true}`)
+		}
+	}))
+	defer srv.Close()
+	prov := NewOllamaProvider(srv.URL, "llama3.2", nil, "")
+
+	ctx, meter := WithUsageMeter(context.Background())
+	c, err := Complete(ctx, WithResilience(prov, "llama3.2", DefaultResilienceConfig()), "Weather in Oslo?", Params{Tools: []Tool{weatherTool}, TopP: 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ToolCalls) != 1 || c.ToolCalls[0].Arguments["city"] != "Oslo" {
+		t.Fatalf("tool calls = %+v", c.ToolCalls)
+	}
+	if len(chat.Tools) != 1 || chat.Tools[0].Type != "function" || chat.Tools[0].Function.Name != "get_weather" || chat.Options["top_p"] != 0.9 {
+		t.Fatalf("unexpected chat request %+v", chat)
+	}
+	if u := meter.Total(); u.PromptTokens != 20 || u.CompletionTokens != 9 {
+		t.Fatalf("usage = %+v", u)
+	}
+
+	out, err := prov.Generate(context.Background(), "Status?", Params{Stop: []string{"END"}, ResponseFormat: &ResponseFormat{Type: FormatJSON}})
+	if err != nil || out != `{"ok":true}` {
+		t.Fatalf("Generate = %q, %v", out, err)
+	}
+	if gen.Format != "json" || fmt.Sprint(gen.Options["stop"]) != "[END]" {
+		t.Fatalf("unexpected generate request %+v", gen)
+	}
+}
+
+func TestProviders_MapStopAndResponseFormat(t *testing.T) {
+	schema := map[string]interface{}{"type": "object", "required": []interface{}{"answer"}}
+	params := Params{Stop: []string{"</s>"}, ResponseFormat: &ResponseFormat{Type: FormatJSONSchema, Schema: schema}}
+
+	hf := newHFRequest("hi", params, false)
+	grammar, _ := hf.Params["grammar"].(map[string]interface{})
+	if grammar["type"] != "json" || grammar["value"] == nil || fmt.Sprint(hf.Params["stop"]) != "[</s>]" {
+		t.Fatalf("hf parameters = %+v", hf.Params)
+	}
+
+	var got llamaCompletion
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, `{"content":"{\"answer\":1}","stop":true}`)
+	}))
+	defer srv.Close()
+	t.Setenv("CMP_LLAMACPP_URL", srv.URL)
+	llama, err := newLlamaCppProviderFromEnv("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llama.Generate(context.Background(), "hi", params); err != nil {
+		t.Fatal(err)
+	}
+	if got.JSONSchema["required"] == nil || fmt.Sprint(got.Stop) != "[</s>]" {
+		t.Fatalf("llama request = %+v", got)
+	}
+
+	mock, _ := NewMockProvider(&MockScript{Default: MockRule{Response: "answer</s>ignored"}})
+	if out, _ := mock.Generate(context.Background(), "hi", params); out != "answer" {
+		t.Fatalf("mock ignored stop sequence: %q", out)
+	}
+}
+
+func TestRecordingProvider_ReplaysCompletions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette, err := OpenCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Params{Tools: []Tool{weatherTool}}
+	live := &paramsProvider{out: `{"tool_calls": [{"name": "get_weather", "arguments": {"city": "Oslo"}}]}`}
+	if _, err := Complete(context.Background(), NewRecordingProvider(live, ModeRecord, cassette), "Weather?", params); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Complete(context.Background(), NewRecordingProvider(nil, ModeReplay, cassette), "Weather?", params)
+	if err != nil || len(c.ToolCalls) != 1 || c.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("replay = %+v, %v", c, err)
+	}
+	if CassetteKey("Weather?", params) == CassetteKey("Weather?", Params{}) {
+		t.Fatal("tools must be part of the cassette key")
+	}
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Usage counts tokens consumed by generation calls.
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	Estimated        bool `json:"estimated,omitempty"` // true when any count was estimated
+}
+
+// TotalTokens returns prompt plus completion tokens.
+func (u Usage) TotalTokens() int { return u.PromptTokens + u.CompletionTokens }
+
+// UsageMeter accumulates usage reported by providers for one request.
+type UsageMeter struct {
+	mu      sync.Mutex
+	total   Usage
+	reports int
+}
+
+type usageMeterKey struct{}
+
+// WithUsageMeter returns a context whose generation calls are metered.
+func WithUsageMeter(ctx context.Context) (context.Context, *UsageMeter) {
+	m := &UsageMeter{}
+	return context.WithValue(ctx, usageMeterKey{}, m), m
+}
+
+// ReportUsage adds usage to the request's meter, if any. Providers call it with
+// the counts returned by their API or tokenizer.
+func ReportUsage(ctx context.Context, u Usage) {
+	m, _ := ctx.Value(usageMeterKey{}).(*UsageMeter)
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total.PromptTokens += u.PromptTokens
+	m.total.CompletionTokens += u.CompletionTokens
+	m.total.Estimated = m.total.Estimated || u.Estimated
+	m.reports++
+}
+
+// Total returns the usage accumulated so far.
+func (m *UsageMeter) Total() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+func reportCount(ctx context.Context) int {
+	m, _ := ctx.Value(usageMeterKey{}).(*UsageMeter)
+	if m == nil {
+		return -1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reports
+}
+
+// meterCall runs a generation call and reports an estimate when the provider did
+// not report usage itself.
+func meterCall(ctx context.Context, input string, call func() (string, error)) (string, error) {
+	before := reportCount(ctx)
+	out, err := call()
+	if err == nil && before >= 0 && reportCount(ctx) == before {
+		ReportUsage(ctx, Usage{PromptTokens: EstimateTokens(input), CompletionTokens: EstimateTokens(out), Estimated: true})
+	}
+	return out, err
+}
+
+// EstimateTokens approximates the token count of text for BPE-style tokenizers:
+// roughly one token per four characters of a word, and one per punctuation mark.
+func EstimateTokens(text string) int {
+	tokens, word := 0, 0
+	flush := func() {
+		if word > 0 {
+			tokens += (word + 3) / 4
+			word = 0
+		}
+	}
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"context"
+	"testing"
+)
+
+type reportingProvider struct{}
+
+func (reportingProvider) Generate(ctx context.Context, _ string, _ Params) (string, error) {
+	ReportUsage(ctx, Usage{PromptTokens: 100, CompletionTokens: 7})
+	return "ok", nil
+}
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":                     0,
+		"hello":                2,
+		"a b c":                3,
+		"Hello, world!":        6,
+		"internationalization": 5,
+	}
+	for in, want := range cases {
+		if got := EstimateTokens(in); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestUsageMeter_ReportedAndEstimated(t *testing.T) {
+	ctx, meter := WithUsageMeter(context.Background())
+	reported := testRouter(map[string]target{"r": {name: "r", provider: reportingProvider{}}}, []string{"r"}, nil).For("", "")
+	if _, err := reported.Generate(ctx, "prompt", Params{}); err != nil {
+		t.Fatal(err)
+	}
+	if u := meter.Total(); u.PromptTokens != 100 || u.CompletionTokens != 7 || u.Estimated {
+		t.Fatalf("reported usage = %+v", u)
+	}
+	estimated := testRouter(map[string]target{"s": {name: "s", provider: staticProvider{out: "four word answer here"}}}, []string{"s"}, nil).For("", "")
+	if _, err := estimated.Generate(ctx, "hi", Params{}); err != nil {
+		t.Fatal(err)
+	}
+	if u := meter.Total(); u.PromptTokens != 101 || u.CompletionTokens != 12 || !u.Estimated {
+		t.Fatalf("accumulated usage = %+v", u)
+	}
+}
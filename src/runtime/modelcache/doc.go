@@ -0,0 +1,8 @@
+// Package modelcache manages the local model cache used by the local provider.
+//
+// Models are pulled from the Hugging Face Hub into the model_cache directory
+// (default data/models) using the Hub's snapshot layout, so the local Python
+// provider finds them offline. Every pulled file is recorded with its size and
+// checksum in data/models/manifest.json, which `ctx models verify` checks
+// against the files on disk.
+package modelcache
@@ -0,0 +1,556 @@
+package modelcache
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+)
+
+// DefaultDir is the project-relative cache directory when model_cache.directory is unset.
+const DefaultDir = "data/models"
+
+// ManifestFile is the manifest's name inside the cache directory.
+const ManifestFile = "manifest.json"
+
+// DefaultHubURL is the Hugging Face Hub used for pulls unless CMP_HF_HUB_URL is set.
+const DefaultHubURL = "https://huggingface.co"
+
+var (
+	// ErrNotFound is returned for models that are not in the cache.
+	ErrNotFound = errors.New("model not found in cache")
+	// ErrChecksum is returned when a downloaded file does not match the Hub's checksum.
+	ErrChecksum = errors.New("checksum mismatch")
+)
+
+var idRe = regexp.MustCompile(`^[A-Za-z0-9][\w.-]*(/[A-Za-z0-9][\w.-]*)?$`)
+
+// Config is the model_cache section of config/environments/$CMP_ENV.yaml.
+type Config struct {
+	Directory       string `yaml:"directory"`
+	AutoDownload    bool   `yaml:"auto_download"`
+	VerifyChecksums *bool  `yaml:"verify_checksums"` // default true
+}
+
+// LoadConfig reads the model_cache section of the active environment config.
+// CMP_MODEL_CACHE_DIR overrides the directory; relative directories are
+// resolved against root.
+func LoadConfig(root string) (Config, error) {
+	var cfg Config
+	env, err := runtimeconfig.Load(root)
+	if err != nil {
+		return cfg, err
+	}
+	if err := env.Section("model_cache", &cfg); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("CMP_MODEL_CACHE_DIR"); v != "" {
+		cfg.Directory = v
+	}
+	if cfg.Directory == "" {
+		cfg.Directory = DefaultDir
+	}
+	if !filepath.IsAbs(cfg.Directory) {
+		cfg.Directory = filepath.Join(root, cfg.Directory)
+	}
+	return cfg, nil
+}
+
+// File is a cached model file and its expected checksum. LFS files carry a
+// SHA-256; small files carry the git blob ID reported by the Hub.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	GitOID string `json:"git_oid,omitempty"`
+}
+
+// Model is a manifest entry for a pulled model.
+type Model struct {
+	ID       string    `json:"id"`
+	Revision string    `json:"revision"` // requested revision, e.g. main
+	Commit   string    `json:"commit"`   // resolved commit SHA
+	Path     string    `json:"path"`     // snapshot directory, relative to the cache
+	Files    []File    `json:"files"`
+	Size     int64     `json:"size"`
+	PulledAt time.Time `json:"pulled_at"`
+}
+
+// Manifest records the models pulled into a cache.
+type Manifest struct {
+	Models map[string]*Model `json:"models"`
+}
+
+// Entry is a model found in the cache. Untracked entries were downloaded by
+// other tools (e.g. transformers on first use) and have no manifest record.
+type Entry struct {
+	ID       string     `json:"id"`
+	Revision string     `json:"revision,omitempty"`
+	Files    int        `json:"files"`
+	Size     int64      `json:"size"`
+	Managed  bool       `json:"managed"`
+	PulledAt *time.Time `json:"pulled_at,omitempty"`
+}
+
+// Problem is a verification failure for one file.
+type Problem struct {
+	Model string `json:"model"`
+	File  string `json:"file"`
+	Issue string `json:"issue"`
+}
+
+// PullOptions tune a pull.
+type PullOptions struct {
+	Revision string   // default main
+	Include  []string // glob patterns matched against file paths; empty pulls every file
+	// Progress, when set, is called after each file with its path and size.
+	Progress func(file string, size int64, skipped bool)
+}
+
+// Cache is a model cache directory and its manifest.
+type Cache struct {
+	dir    string
+	verify bool
+	hubURL string
+	token  string
+	client *http.Client
+	mu     sync.Mutex
+}
+
+// Open returns the cache configured for the project at root.
+func Open(root string) (*Cache, error) {
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg.Directory, cfg.VerifyChecksums == nil || *cfg.VerifyChecksums), nil
+}
+
+// New returns a cache rooted at dir. verify controls checksum verification of
+// pulled files. HF_TOKEN, when set, authenticates Hub requests.
+func New(dir string, verify bool) *Cache {
+	hub := os.Getenv("CMP_HF_HUB_URL")
+	if hub == "" {
+		hub = DefaultHubURL
+	}
+	return &Cache{
+		dir:    dir,
+		verify: verify,
+		hubURL: strings.TrimRight(hub, "/"),
+		token:  os.Getenv("HF_TOKEN"),
+		client: &http.Client{},
+	}
+}
+
+// Dir returns the cache directory.
+func (c *Cache) Dir() string { return c.dir }
+
+// repoDir is the Hub cache directory name for a model ID.
+func repoDir(id string) string { return "models--" + strings.ReplaceAll(id, "/", "--") }
+
+func (c *Cache) readManifest() (*Manifest, error) {
+	m := &Manifest{Models: map[string]*Model{}}
+	by, err := os.ReadFile(filepath.Join(c.dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(by, m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	if m.Models == nil {
+		m.Models = map[string]*Model{}
+	}
+	return m, nil
+}
+
+func (c *Cache) writeManifest(m *Manifest) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	by, _ := json.MarshalIndent(m, "", "  ")
+	tmp := filepath.Join(c.dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, by, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(c.dir, ManifestFile))
+}
+
+// List returns pulled models and untracked model directories, sorted by ID.
+func (c *Cache) List() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, mod := range m.Models {
+		pulled := mod.PulledAt
+		out = append(out, Entry{ID: mod.ID, Revision: mod.Revision, Files: len(mod.Files), Size: mod.Size, Managed: true, PulledAt: &pulled})
+	}
+	dirs, err := os.ReadDir(c.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || !strings.HasPrefix(d.Name(), "models--") {
+			continue
+		}
+		id := strings.ReplaceAll(strings.TrimPrefix(d.Name(), "models--"), "--", "/")
+		if _, ok := m.Models[id]; ok {
+			continue
+		}
+		files, size := dirUsage(filepath.Join(c.dir, d.Name()))
+		if files == 0 {
+			continue // left behind by a failed pull
+		}
+		out = append(out, Entry{ID: id, Files: files, Size: size})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func dirUsage(dir string) (files int, size int64) {
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		// Hub caches link snapshot files to blobs; count the blobs only
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// hubSibling is a file in the Hub's model info response.
+type hubSibling struct {
+	RFilename string `json:"rfilename"`
+	Size      int64  `json:"size"`
+	BlobID    string `json:"blobId"`
+	LFS       *struct {
+		SHA256 string `json:"sha256"`
+		Size   int64  `json:"size"`
+	} `json:"lfs"`
+}
+
+type hubModelInfo struct {
+	SHA      string       `json:"sha"`
+	Siblings []hubSibling `json:"siblings"`
+}
+
+// Pull downloads a model snapshot into the cache and records it in the
+// manifest. Files already present with the expected checksum are skipped and
+// interrupted downloads resume from their partial file.
+func (c *Cache) Pull(ctx context.Context, id string, opts PullOptions) (*Model, error) {
+	if !idRe.MatchString(id) {
+		return nil, fmt.Errorf("invalid model id %q", id)
+	}
+	if opts.Revision == "" {
+		opts.Revision = "main"
+	}
+	var info hubModelInfo
+	infoURL := fmt.Sprintf("%s/api/models/%s/revision/%s?blobs=true", c.hubURL, id, url.PathEscape(opts.Revision))
+	if err := c.getJSON(ctx, infoURL, &info); err != nil {
+		return nil, fmt.Errorf("resolve %s@%s: %w", id, opts.Revision, err)
+	}
+	if info.SHA == "" {
+		return nil, fmt.Errorf("resolve %s@%s: hub returned no commit", id, opts.Revision)
+	}
+	mod := &Model{ID: id, Revision: opts.Revision, Commit: info.SHA, Path: filepath.ToSlash(filepath.Join(repoDir(id), "snapshots", info.SHA))}
+	for _, s := range info.Siblings {
+		if !included(s.RFilename, opts.Include) {
+			continue
+		}
+		if !safeRelPath(s.RFilename) {
+			return nil, fmt.Errorf("refusing unsafe file path %q", s.RFilename)
+		}
+		f := File{Path: s.RFilename, Size: s.Size, GitOID: s.BlobID}
+		if s.LFS != nil {
+			f.SHA256, f.Size, f.GitOID = s.LFS.SHA256, s.LFS.Size, ""
+		}
+		mod.Files = append(mod.Files, f)
+		mod.Size += f.Size
+	}
+	if len(mod.Files) == 0 {
+		return nil, fmt.Errorf("no files of %s match %v", id, opts.Include)
+	}
+	snapshot := filepath.Join(c.dir, filepath.FromSlash(mod.Path))
+	for _, f := range mod.Files {
+		dst := filepath.Join(snapshot, filepath.FromSlash(f.Path))
+		if c.check(dst, f) == "" {
+			if opts.Progress != nil {
+				opts.Progress(f.Path, f.Size, true)
+			}
+			continue
+		}
+		fileURL := fmt.Sprintf("%s/%s/resolve/%s/%s", c.hubURL, id, info.SHA, escapePath(f.Path))
+		if err := c.download(ctx, fileURL, dst, f); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(f.Path, f.Size, false)
+		}
+	}
+	// refs/<revision> lets transformers resolve the snapshot offline
+	refs := filepath.Join(c.dir, repoDir(id), "refs")
+	if err := os.MkdirAll(refs, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(refs, filepath.Base(opts.Revision)), []byte(info.SHA), 0o644); err != nil {
+		return nil, err
+	}
+	mod.PulledAt = time.Now().UTC()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	m.Models[id] = mod
+	return mod, c.writeManifest(m)
+}
+
+func (c *Cache) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hub returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Cache) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// download fetches u into dst via dst.incomplete, resuming a partial download
+// with a Range request, and verifies the result before moving it into place.
+func (c *Cache) download(ctx context.Context, u, dst string, f File) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	part := dst + ".incomplete"
+	var offset int64
+	if info, err := os.Stat(part); err == nil && info.Size() < f.Size {
+		offset = info.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		flags |= os.O_TRUNC // server ignored the range; start over
+	default:
+		return fmt.Errorf("download returned %s", resp.Status)
+	}
+	out, err := os.OpenFile(part, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if issue := c.check(part, f); issue != "" {
+		// A complete but corrupt file cannot be resumed
+		os.Remove(part)
+		return fmt.Errorf("%w: %s", ErrChecksum, issue)
+	}
+	return os.Rename(part, dst)
+}
+
+// check returns "" when the file at p matches f, or a short description of
+// the problem. Checksums are skipped when verification is disabled.
+func (c *Cache) check(p string, f File) string {
+	info, err := os.Stat(p)
+	if err != nil {
+		return "missing"
+	}
+	if info.Size() != f.Size {
+		return fmt.Sprintf("size %d, expected %d", info.Size(), f.Size)
+	}
+	if !c.verify {
+		return ""
+	}
+	return checksum(p, f)
+}
+
+func checksum(p string, f File) string {
+	in, err := os.Open(p)
+	if err != nil {
+		return "missing"
+	}
+	defer in.Close()
+	var h hash.Hash
+	want := f.SHA256
+	switch {
+	case f.SHA256 != "":
+		h = sha256.New()
+	case f.GitOID != "":
+		// git blob ID: sha1("blob <size>\x00" + content)
+		h = sha1.New()
+		fmt.Fprintf(h, "blob %d\x00", f.Size)
+		want = f.GitOID
+	default:
+		return ""
+	}
+	if _, err := io.Copy(h, in); err != nil {
+		return err.Error()
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return "checksum mismatch"
+	}
+	return ""
+}
+
+// Verify checks pulled files against the manifest. An empty id verifies every
+// pulled model. Files are always checksummed, regardless of verify_checksums.
+func (c *Cache) Verify(id string) ([]Problem, error) {
+	c.mu.Lock()
+	m, err := c.readManifest()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var models []*Model
+	if id != "" {
+		mod, ok := m.Models[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		models = append(models, mod)
+	} else {
+		for _, mod := range m.Models {
+			models = append(models, mod)
+		}
+		sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	}
+	strict := &Cache{dir: c.dir, verify: true}
+	var problems []Problem
+	for _, mod := range models {
+		for _, f := range mod.Files {
+			p := filepath.Join(c.dir, filepath.FromSlash(mod.Path), filepath.FromSlash(f.Path))
+			if issue := strict.check(p, f); issue != "" {
+				problems = append(problems, Problem{Model: mod.ID, File: f.Path, Issue: issue})
+			}
+		}
+	}
+	return problems, nil
+}
+
+// Remove deletes a model's files and manifest entry. Untracked models
+// downloaded by other tools can be removed too.
+func (c *Cache) Remove(id string) error {
+	if !idRe.MatchString(id) {
+		return fmt.Errorf("invalid model id %q", id)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, err := c.readManifest()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(c.dir, repoDir(id))
+	_, tracked := m.Models[id]
+	if _, err := os.Stat(dir); os.IsNotExist(err) && !tracked {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if !tracked {
+		return nil
+	}
+	delete(m.Models, id)
+	return c.writeManifest(m)
+}
+
+func included(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func safeRelPath(p string) bool {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, `\`) {
+		return false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,165 @@
+package modelcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// fakeHub serves a model with one LFS weights file and one small config file.
+func fakeHub(t *testing.T, weights, config []byte) (*httptest.Server, *int64) {
+	t.Helper()
+	var served int64
+	sum := sha256.Sum256(weights)
+	oid := sha1.Sum(append([]byte(fmt.Sprintf("blob %d\x00", len(config))), config...))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/models/acme/tiny/revision/main", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"sha": testCommit,
+			"siblings": []map[string]interface{}{
+				{"rfilename": "config.json", "size": len(config), "blobId": hex.EncodeToString(oid[:])},
+				{"rfilename": "model.safetensors", "size": 0, "lfs": map[string]interface{}{"sha256": hex.EncodeToString(sum[:]), "size": len(weights)}},
+			},
+		})
+	})
+	mux.HandleFunc("/acme/tiny/resolve/"+testCommit+"/", func(w http.ResponseWriter, r *http.Request) {
+		content := config
+		if strings.HasSuffix(r.URL.Path, "model.safetensors") {
+			content = weights
+		}
+		cw := &countingWriter{ResponseWriter: w, n: &served}
+		http.ServeContent(cw, r, "", time.Time{}, bytes.NewReader(content))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &served
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	atomic.AddInt64(c.n, int64(len(b)))
+	return c.ResponseWriter.Write(b)
+}
+
+func TestCache_PullVerifyRemove(t *testing.T) {
+	weights := bytes.Repeat([]byte("w"), 4096)
+	srv, served := fakeHub(t, weights, []byte(`{"model_type":"tiny"}`))
+	t.Setenv("CMP_HF_HUB_URL", srv.URL)
+	c := New(t.TempDir(), true)
+
+	// Resume an interrupted download of the weights
+	snapshot := filepath.Join(c.Dir(), "models--acme--tiny", "snapshots", testCommit)
+	_ = os.MkdirAll(snapshot, 0o755)
+	_ = os.WriteFile(filepath.Join(snapshot, "model.safetensors.incomplete"), weights[:3000], 0o644)
+
+	mod, err := c.Pull(context.Background(), "acme/tiny", PullOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mod.Files) != 2 || mod.Commit != testCommit {
+		t.Fatalf("unexpected model %+v", mod)
+	}
+	if got := atomic.LoadInt64(served); got >= int64(len(weights)) {
+		t.Fatalf("download did not resume: served %d bytes", got)
+	}
+	if by, _ := os.ReadFile(filepath.Join(snapshot, "model.safetensors")); !bytes.Equal(by, weights) {
+		t.Fatal("weights corrupted by resume")
+	}
+	if ref, _ := os.ReadFile(filepath.Join(c.Dir(), "models--acme--tiny", "refs", "main")); string(ref) != testCommit {
+		t.Fatalf("refs/main = %q", ref)
+	}
+
+	entries, err := c.List()
+	if err != nil || len(entries) != 1 || !entries[0].Managed || entries[0].Size != mod.Size {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+	if problems, err := c.Verify(""); err != nil || len(problems) != 0 {
+		t.Fatalf("Verify = %+v, %v", problems, err)
+	}
+
+	// Tampering is detected
+	_ = os.WriteFile(filepath.Join(snapshot, "config.json"), []byte(`{"model_type":"evil"}`), 0o644)
+	problems, _ := c.Verify("acme/tiny")
+	if len(problems) != 1 || problems[0].File != "config.json" {
+		t.Fatalf("expected tampered config to fail verification, got %+v", problems)
+	}
+	// A second pull repairs only the bad file
+	before := atomic.LoadInt64(served)
+	if _, err := c.Pull(context.Background(), "acme/tiny", PullOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt64(served)-before >= int64(len(weights)) {
+		t.Fatal("intact weights were downloaded again")
+	}
+
+	if err := c.Remove("acme/tiny"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Fatalf("expected empty cache, got %+v", entries)
+	}
+	if err := c.Remove("acme/tiny"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCache_ChecksumMismatchAndUntracked(t *testing.T) {
+	// The downloaded config does not match the blob ID the hub reported
+	tampered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			fmt.Fprintf(w, `{"sha":%q,"siblings":[{"rfilename":"config.json","size":2,"blobId":"deadbeef"}]}`, testCommit)
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}))
+	defer tampered.Close()
+	t.Setenv("CMP_HF_HUB_URL", tampered.URL)
+	c := New(t.TempDir(), true)
+	if _, err := c.Pull(context.Background(), "acme/tiny", PullOptions{}); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+
+	// Models downloaded by transformers show up as untracked
+	_ = os.MkdirAll(filepath.Join(c.Dir(), "models--org--other", "blobs"), 0o755)
+	_ = os.WriteFile(filepath.Join(c.Dir(), "models--org--other", "blobs", "x"), []byte("12345"), 0o644)
+	entries, _ := c.List()
+	if len(entries) != 1 || entries[0].ID != "org/other" || entries[0].Managed || entries[0].Size != 5 {
+		t.Fatalf("List = %+v", entries)
+	}
+	if _, err := c.Pull(context.Background(), "../etc", PullOptions{}); err == nil {
+		t.Fatal("expected invalid id error")
+	}
+}
+
+func TestLoadConfig_DirectoryFromEnvironmentConfig(t *testing.T) {
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), []byte("model_cache:\n  directory: ./cache\n  verify_checksums: false\n"), 0o644)
+	cfg, err := LoadConfig(root)
+	if err != nil || cfg.Directory != filepath.Join(root, "cache") || cfg.VerifyChecksums == nil || *cfg.VerifyChecksums {
+		t.Fatalf("LoadConfig = %+v, %v", cfg, err)
+	}
+	t.Setenv("CMP_MODEL_CACHE_DIR", "/srv/models")
+	if cfg, _ := LoadConfig(root); cfg.Directory != "/srv/models" {
+		t.Fatalf("CMP_MODEL_CACHE_DIR ignored: %s", cfg.Directory)
+	}
+}
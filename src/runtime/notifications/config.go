@@ -0,0 +1,157 @@
+package notifications
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the project-relative path of the notification configuration.
+const ConfigFile = "config/notifications.yaml"
+
+// Channel types.
+const (
+	TypeSlack   = "slack"
+	TypeTeams   = "teams"
+	TypeWebhook = "webhook"
+)
+
+// DefaultCooldown is how long a repeated alert is suppressed when the
+// configuration does not say.
+const DefaultCooldown = time.Hour
+
+var channelNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config is the parsed config/notifications.yaml:
+//
+//	channels:
+//	  - name: ops-slack
+//	    type: slack                  # slack | teams | webhook
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    events: [drift.alert, job.failed] # default: all events
+//	  - name: finance-teams
+//	    type: teams
+//	    url: ${TEAMS_WEBHOOK_URL}
+//	    events: [budget.exceeded]
+//	rate_limit:
+//	  cooldown: 1h      # repeats of the same alert per channel
+//	  max_per_hour: 20  # messages per channel, 0 for no limit
+//	templates:
+//	  budget.exceeded: "{{.Text}} (resets {{.Fields.reset}})"
+type Config struct {
+	Channels  []Channel         `yaml:"channels"`
+	RateLimit RateLimit         `yaml:"rate_limit"`
+	Templates map[string]string `yaml:"templates"`
+
+	cooldown  time.Duration
+	templates map[string]*template.Template
+}
+
+// Channel is one destination. URL may reference ${ENV} variables.
+type Channel struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"`
+	URL    string   `yaml:"url"`
+	Events []string `yaml:"events"`
+}
+
+// Wants reports whether the channel is subscribed to an event type.
+func (c Channel) Wants(eventType string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit bounds how often a channel is notified.
+type RateLimit struct {
+	Cooldown   string `yaml:"cooldown"`
+	MaxPerHour int    `yaml:"max_per_hour"`
+}
+
+// LoadConfig reads config/notifications.yaml under root. It returns nil when
+// the file does not exist (notifications disabled).
+func LoadConfig(root string) (*Config, error) {
+	by, err := os.ReadFile(filepath.Join(root, ConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := yaml.Unmarshal(by, &c); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ConfigFile, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	seen := map[string]bool{}
+	for i := range c.Channels {
+		ch := &c.Channels[i]
+		switch ch.Type {
+		case TypeSlack, TypeTeams, TypeWebhook:
+		default:
+			return fmt.Errorf("channel %d: type must be %s, %s or %s", i+1, TypeSlack, TypeTeams, TypeWebhook)
+		}
+		if ch.Name == "" {
+			ch.Name = ch.Type
+		}
+		if !channelNameRe.MatchString(ch.Name) {
+			return fmt.Errorf("channel %d: invalid name %q", i+1, ch.Name)
+		}
+		if seen[ch.Name] {
+			return fmt.Errorf("duplicate channel %q", ch.Name)
+		}
+		seen[ch.Name] = true
+		ch.URL = os.ExpandEnv(ch.URL)
+		u, err := url.Parse(ch.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			// Do not echo the URL, it usually embeds a secret token
+			return fmt.Errorf("channel %s: url must be an http(s) URL", ch.Name)
+		}
+		for _, e := range ch.Events {
+			if e != "*" && !KnownEvent(e) {
+				return fmt.Errorf("channel %s: unknown event %q", ch.Name, e)
+			}
+		}
+	}
+	c.cooldown = DefaultCooldown
+	if c.RateLimit.Cooldown != "" {
+		d, err := time.ParseDuration(c.RateLimit.Cooldown)
+		if err != nil || d < 0 {
+			return fmt.Errorf("rate_limit.cooldown: invalid duration %q", c.RateLimit.Cooldown)
+		}
+		c.cooldown = d
+	}
+	if c.RateLimit.MaxPerHour < 0 {
+		return fmt.Errorf("rate_limit.max_per_hour must not be negative")
+	}
+	c.templates = map[string]*template.Template{}
+	for event, text := range c.Templates {
+		if !KnownEvent(event) {
+			return fmt.Errorf("template for unknown event %q", event)
+		}
+		tmpl, err := template.New(event).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("template %s: %w", event, err)
+		}
+		c.templates[event] = tmpl
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+// Package notifications delivers operational alerts to chat tools.
+//
+// Channels in config/notifications.yaml are Slack incoming webhooks, Microsoft
+// Teams connectors or plain JSON webhooks, each subscribed to some event types
+// (drift alerts, budget overruns, failed background jobs). Messages are
+// rendered from per-event text templates, and repeats of the same alert are
+// suppressed for a cooldown so a flapping condition does not flood a channel.
+package notifications
@@ -0,0 +1,195 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capture records the JSON bodies posted to each path.
+type capture struct {
+	mu     sync.Mutex
+	bodies map[string][]map[string]interface{}
+	status int
+}
+
+func newCapture(t *testing.T) (*capture, *httptest.Server) {
+	c := &capture{bodies: map[string][]map[string]interface{}{}, status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&m)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.bodies[r.URL.Path] = append(c.bodies[r.URL.Path], m)
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func writeConfig(t *testing.T, root, body string) {
+	t.Helper()
+	_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+	if err := os.WriteFile(filepath.Join(root, ConfigFile), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	if n, err := Load(root); n != nil || err != nil {
+		t.Fatalf("missing config = %v, %v", n, err)
+	}
+	t.Setenv("TEST_TEAMS_URL", "https://example.webhook.office.com/webhookb2/secret")
+	writeConfig(t, root, `channels:
+  - type: teams
+    url: ${TEST_TEAMS_URL}
+    events: [budget.exceeded]
+rate_limit:
+  cooldown: 30m
+`)
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := cfg.Channels[0]
+	if ch.Name != "teams" || ch.URL != "https://example.webhook.office.com/webhookb2/secret" || cfg.cooldown != 30*time.Minute {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	if ch.Wants(EventDriftAlert) || !ch.Wants(EventBudgetExceeded) {
+		t.Fatalf("event filter not applied: %+v", ch)
+	}
+	for _, bad := range []string{
+		"channels:\n  - type: email\n    url: https://x.example.com\n",
+		"channels:\n  - type: slack\n    url: ${TEST_UNSET_URL}\n",
+		"channels:\n  - type: slack\n    url: https://x.example.com\n    events: [drift.alrt]\n",
+		"channels:\n  - type: slack\n    url: https://x.example.com\n  - type: slack\n    url: https://y.example.com\n",
+		"rate_limit:\n  cooldown: soon\n",
+		"templates:\n  drift.alert: \"{{.Text\"\n",
+	} {
+		writeConfig(t, root, bad)
+		if _, err := LoadConfig(root); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+	writeConfig(t, root, "channels:\n  - type: slack\n    url: ${TEST_UNSET_URL}/hook?token=s3cret\n")
+	if _, err := LoadConfig(root); err == nil || strings.Contains(err.Error(), "s3cret") {
+		t.Fatalf("error must not echo the url: %v", err)
+	}
+}
+
+func TestNotify_SlackAndTeams(t *testing.T) {
+	c, srv := newCapture(t)
+	root := t.TempDir()
+	writeConfig(t, root, `channels:
+  - name: ops
+    type: slack
+    url: `+srv.URL+`/slack
+  - name: finance
+    type: teams
+    url: `+srv.URL+`/teams
+    events: [budget.exceeded]
+templates:
+  budget.exceeded: "{{.Text}} (resets {{.Fields.reset}})"
+`)
+	n, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := Event{
+		Type: EventBudgetExceeded, Severity: SeverityCritical, Title: "Budget exhausted for tenant acme",
+		Text: "monthly_tokens budget exhausted", Fields: map[string]string{"tenant": "acme", "reset": "2026-11-01"},
+	}
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	slack := c.bodies["/slack"]
+	if len(slack) != 1 || slack[0]["text"] != ":rotating_light: *Budget exhausted for tenant acme*\nmonthly_tokens budget exhausted (resets 2026-11-01)" {
+		t.Fatalf("slack = %+v", slack)
+	}
+	teams := c.bodies["/teams"]
+	if len(teams) != 1 || teams[0]["@type"] != "MessageCard" || teams[0]["themeColor"] != "D70000" {
+		t.Fatalf("teams = %+v", teams)
+	}
+	facts := teams[0]["sections"].([]interface{})[0].(map[string]interface{})["facts"].([]interface{})
+	if len(facts) != 2 || facts[0].(map[string]interface{})["name"] != "reset" {
+		t.Fatalf("facts = %+v", facts)
+	}
+
+	// Only ops is subscribed to job failures
+	if err := n.Notify(context.Background(), Event{Type: EventJobFailed, Title: "job failed", Text: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.bodies["/slack"]) != 2 || len(c.bodies["/teams"]) != 1 {
+		t.Fatalf("unexpected deliveries %+v", c.bodies)
+	}
+}
+
+func TestNotify_RateLimits(t *testing.T) {
+	c, srv := newCapture(t)
+	root := t.TempDir()
+	writeConfig(t, root, `channels:
+  - type: webhook
+    url: `+srv.URL+`/hook
+rate_limit:
+  cooldown: 10m
+  max_per_hour: 3
+`)
+	n, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 14, 10, 0, 0, 0, time.UTC)
+	n.Now = func() time.Time { return now }
+	send := func(key string) {
+		t.Helper()
+		if err := n.Notify(context.Background(), Event{Type: EventDriftAlert, Title: key, Text: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send("a")
+	send("a") // within the cooldown
+	if got := len(c.bodies["/hook"]); got != 1 {
+		t.Fatalf("deliveries after a repeat = %d", got)
+	}
+	now = now.Add(11 * time.Minute)
+	send("a")
+	send("b")
+	send("c") // fourth within the hour
+	if got := len(c.bodies["/hook"]); got != 3 {
+		t.Fatalf("deliveries at the hourly limit = %d", got)
+	}
+	if msg := c.bodies["/hook"][0]["message"]; msg != "a" {
+		t.Fatalf("webhook message = %v", msg)
+	}
+	now = now.Add(time.Hour)
+	send("c")
+	if got := len(c.bodies["/hook"]); got != 4 {
+		t.Fatalf("deliveries after the window = %d", got)
+	}
+
+	// A failed delivery does not start the cooldown
+	c.status = http.StatusBadGateway
+	if err := n.Notify(context.Background(), Event{Type: EventDriftAlert, Title: "d"}); err == nil || !strings.Contains(err.Error(), "channel webhook") {
+		t.Fatalf("expected a delivery error, got %v", err)
+	}
+	c.status = http.StatusOK
+	send("d")
+	if got := len(c.bodies["/hook"]); got != 6 {
+		t.Fatalf("deliveries after a retry = %d", got)
+	}
+}
+
+func TestNotify_NilNotifier(t *testing.T) {
+	var n *Notifier
+	if err := n.Notify(context.Background(), Event{Type: EventJobFailed}); err != nil {
+		t.Fatal(err)
+	}
+}
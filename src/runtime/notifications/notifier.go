@@ -0,0 +1,274 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event types.
+const (
+	EventDriftAlert     = "drift.alert"
+	EventBudgetExceeded = "budget.exceeded"
+	EventJobFailed      = "job.failed"
+)
+
+// Events lists the event types channels can subscribe to.
+var Events = []string{EventDriftAlert, EventBudgetExceeded, EventJobFailed}
+
+// KnownEvent reports whether t is one of Events.
+func KnownEvent(t string) bool {
+	for _, e := range Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Severities.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Sent counts notifications by event type and outcome (sent, suppressed, failed).
+var Sent = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_notifications_total",
+	Help: "Notifications by event type and outcome (sent, suppressed, failed).",
+}, []string{"event", "result"})
+
+// Event is something worth telling a human about.
+type Event struct {
+	Type     string            `json:"type"`
+	Severity string            `json:"severity"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Time     time.Time         `json:"time"`
+	// Key identifies repeats of the same alert for rate limiting; it defaults
+	// to Type and Title.
+	Key string `json:"-"`
+}
+
+func (e Event) key() string {
+	if e.Key != "" {
+		return e.Key
+	}
+	return e.Type + "/" + e.Title
+}
+
+var defaultTemplate = template.Must(template.New("default").Parse("{{.Text}}"))
+
+// Notifier sends events to the configured channels. A nil Notifier drops
+// every event, so callers need not check whether notifications are enabled.
+// Rate limits are tracked per process.
+type Notifier struct {
+	cfg    *Config
+	client *http.Client
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time   // channel and event key -> last sent
+	sent map[string][]time.Time // channel -> sends in the past hour
+}
+
+// New returns a notifier for cfg, or nil when cfg is nil.
+func New(cfg *Config) *Notifier {
+	if cfg == nil {
+		return nil
+	}
+	return &Notifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		last:   map[string]time.Time{},
+		sent:   map[string][]time.Time{},
+	}
+}
+
+// Load reads config/notifications.yaml under root. It returns nil when the
+// file does not exist.
+func Load(root string) (*Notifier, error) {
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// Notify sends ev to every channel subscribed to its type, skipping channels
+// that recently received the same alert or reached their hourly limit.
+// Delivery errors name the channel but never its URL.
+func (n *Notifier) Notify(ctx context.Context, ev Event) error {
+	if n == nil {
+		return nil
+	}
+	if ev.Time.IsZero() {
+		ev.Time = n.now().UTC()
+	}
+	if ev.Severity == "" {
+		ev.Severity = SeverityWarning
+	}
+	msg, err := n.render(ev)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ch := range n.cfg.Channels {
+		if !ch.Wants(ev.Type) {
+			continue
+		}
+		if !n.allow(ch.Name, ev.key()) {
+			Sent.WithLabelValues(ev.Type, "suppressed").Inc()
+			continue
+		}
+		if err := n.post(ctx, ch.URL, payload(ch.Type, ev, msg)); err != nil {
+			n.forget(ch.Name, ev.key())
+			Sent.WithLabelValues(ev.Type, "failed").Inc()
+			errs = append(errs, fmt.Errorf("channel %s: %w", ch.Name, err))
+			continue
+		}
+		Sent.WithLabelValues(ev.Type, "sent").Inc()
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) render(ev Event) (string, error) {
+	tmpl := defaultTemplate
+	if t, ok := n.cfg.templates[ev.Type]; ok {
+		tmpl = t
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, ev); err != nil {
+		return "", fmt.Errorf("render %s template: %w", ev.Type, err)
+	}
+	return b.String(), nil
+}
+
+// allow reserves a send on channel for key, reporting false when the cooldown
+// or hourly limit applies.
+func (n *Notifier) allow(channel, key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := n.now()
+	k := channel + "\x00" + key
+	if last, ok := n.last[k]; ok && now.Sub(last) < n.cfg.cooldown {
+		return false
+	}
+	recent := n.sent[channel][:0]
+	for _, t := range n.sent[channel] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if max := n.cfg.RateLimit.MaxPerHour; max > 0 && len(recent) >= max {
+		n.sent[channel] = recent
+		return false
+	}
+	n.sent[channel] = append(recent, now)
+	n.last[k] = now
+	return true
+}
+
+// forget drops the cooldown of a failed delivery so the next occurrence is sent.
+func (n *Notifier) forget(channel, key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.last, channel+"\x00"+key)
+}
+
+func (n *Notifier) now() time.Time {
+	if n.Now != nil {
+		return n.Now()
+	}
+	return time.Now()
+}
+
+var slackIcons = map[string]string{
+	SeverityInfo:     ":information_source:",
+	SeverityWarning:  ":warning:",
+	SeverityCritical: ":rotating_light:",
+}
+
+var teamsColors = map[string]string{
+	SeverityInfo:     "0076D7",
+	SeverityWarning:  "FFA500",
+	SeverityCritical: "D70000",
+}
+
+// payload builds the request body for a channel type: a Slack message, a
+// Teams connector MessageCard, or the event itself with the rendered message.
+func payload(channelType string, ev Event, msg string) interface{} {
+	switch channelType {
+	case TypeSlack:
+		text := msg
+		if ev.Title != "" {
+			text = "*" + ev.Title + "*\n" + msg
+		}
+		if icon := slackIcons[ev.Severity]; icon != "" {
+			text = icon + " " + text
+		}
+		return map[string]string{"text": text}
+	case TypeTeams:
+		card := map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    ev.Title,
+			"themeColor": teamsColors[ev.Severity],
+			"title":      ev.Title,
+			"text":       msg,
+		}
+		if len(ev.Fields) > 0 {
+			names := make([]string, 0, len(ev.Fields))
+			for k := range ev.Fields {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			facts := make([]map[string]string, 0, len(names))
+			for _, k := range names {
+				facts = append(facts, map[string]string{"name": k, "value": ev.Fields[k]})
+			}
+			card["sections"] = []interface{}{map[string]interface{}{"facts": facts}}
+		}
+		return card
+	default:
+		return struct {
+			Event
+			Message string `json:"message"`
+		}{ev, msg}
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body interface{}) error {
+	by, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(by))
+	if err != nil {
+		return fmt.Errorf("invalid request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The error names the URL, which may hold a secret token
+		return fmt.Errorf("delivery failed")
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("delivery failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+// Package privacy implements data-subject requests: exporting everything the
+// runtime keeps about a user and erasing it.
+//
+// A user's data is
+//   - episodic memory stored per user (memory/<component>/[tenant_<id>/]users/<user>),
+//   - chat transcripts under data/transcripts, recorded when CMP_TRANSCRIPTS=true
+//     for requests that carry a user_id, and their turns in the per-session
+//     transcripts under data/sessions, and
+//   - audit events attributed to the user in the file audit sinks.
+//
+// Session transcripts also record requests without a user; they are read
+// with Transcripts.ReadSession for debugging and compliance review.
+//
+// Deletion removes memory and transcripts. Audit events are kept for
+// accountability; the API records every export and erasure in them.
+package privacy
@@ -0,0 +1,117 @@
+package privacy
+
+import (
+	"sort"
+	"time"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// ExportSchema identifies the Export document layout.
+const ExportSchema = "contexis.user_export/v1"
+
+// Export is everything the runtime keeps about one user of one tenant.
+type Export struct {
+	Schema        string                           `json:"schema"`
+	UserID        string                           `json:"user_id"`
+	TenantID      string                           `json:"tenant_id,omitempty"`
+	ExportedAt    time.Time                        `json:"exported_at"`
+	Episodic      []runtimememory.UserEpisodes     `json:"episodic_memory"`
+	Conversations []runtimememory.UserConversation `json:"conversations"`
+	Transcripts   []Turn                           `json:"transcripts"`
+	Audit         []runtimesecurity.AuditEvent     `json:"audit"`
+}
+
+// Deletion reports what DeleteUser removed.
+type Deletion struct {
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id,omitempty"`
+	// Components whose per-user episodic memory was removed.
+	Components []string `json:"episodic_components"`
+	Turns      int      `json:"transcript_turns"`
+	// AuditRetained counts audit events that were kept.
+	AuditRetained int `json:"audit_retained"`
+}
+
+// ExportUser collects a user's episodic memory, conversation sessions,
+// transcripts and audit events.
+func ExportUser(root, tenantID, userID string) (*Export, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return nil, err
+	}
+	episodes, err := runtimememory.ExportUserEpisodes(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	conversations, err := runtimememory.ExportUserConversations(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	turns, err := NewTranscripts(root).Read(tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := AuditEvents(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{
+		Schema: ExportSchema, UserID: userID, TenantID: tenantID, ExportedAt: time.Now().UTC(),
+		Episodic: episodes, Conversations: conversations, Transcripts: turns, Audit: events,
+	}, nil
+}
+
+// DeleteUser erases a user's episodic memory and transcripts. Audit events
+// are retained and only counted.
+func DeleteUser(root, tenantID, userID string) (*Deletion, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return nil, err
+	}
+	components, err := runtimememory.DeleteUserEpisodes(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	turns, err := NewTranscripts(root).Delete(tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := AuditEvents(root, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if components == nil {
+		components = []string{}
+	}
+	return &Deletion{UserID: userID, TenantID: tenantID, Components: components, Turns: turns, AuditRetained: len(events)}, nil
+}
+
+// AuditEvents returns the events attributed to a user in the project's file
+// audit sinks, including rotated files, oldest first. An event belongs to the
+// user when its user_id attribute matches or the user was the OIDC principal.
+func AuditEvents(root, tenantID, userID string) ([]runtimesecurity.AuditEvent, error) {
+	paths, err := runtimesecurity.AuditLogFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	events := []runtimesecurity.AuditEvent{}
+	for _, path := range paths {
+		if err := runtimesecurity.ScanAuditLog(path, func(e runtimesecurity.AuditEvent) {
+			if (tenantID == "" || e.TenantID == tenantID) && auditBelongsTo(e, userID) {
+				events = append(events, e)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events, nil
+}
+
+func auditBelongsTo(e runtimesecurity.AuditEvent, userID string) bool {
+	if id, _ := e.Attributes["user_id"].(string); id == userID {
+		return true
+	}
+	subject := "oidc:" + userID
+	return e.ActorKeyID == subject || (e.Principal != nil && e.Principal.KeyID == subject)
+}
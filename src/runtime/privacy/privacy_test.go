@@ -0,0 +1,147 @@
+package privacy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+func TestValidateUserID(t *testing.T) {
+	for _, ok := range []string{"u-1", "jane.doe@example.com", "auth0_5f1c"} {
+		if err := ValidateUserID(ok); err != nil {
+			t.Errorf("ValidateUserID(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "..", ".hidden", "a/b", "a\\b", strings.Repeat("x", 129)} {
+		if ValidateUserID(bad) == nil {
+			t.Errorf("ValidateUserID(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestExportAndDeleteUser(t *testing.T) {
+	root := t.TempDir()
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "episodic", RootDir: root, ComponentName: "SupportBot", TenantID: "acme", UserID: "u-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.IngestDocuments(context.Background(), []string{"prefers email"}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	conv, err := runtimememory.OpenConversation(root, "SupportBot", "acme", "u-1", "s-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, turn := range []runtimememory.ConversationTurn{{Query: "where is my order?", Response: "shipped"}, {Query: "when?", Response: "Monday"}} {
+		if err := conv.Append(turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr := NewTranscripts(root)
+	for _, turn := range []Turn{
+		{TenantID: "acme", UserID: "u-1", Query: "hi", Response: "hello"},
+		{TenantID: "acme", UserID: "u-1", Query: "refund?", Response: "30 days"},
+		{TenantID: "acme", UserID: "u-2", Query: "other", Response: "user"},
+	} {
+		if err := tr.Append(turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	audit := runtimesecurity.NewJSONFileSink(filepath.Join(root, runtimesecurity.DefaultAuditLog))
+	now := time.Now()
+	for _, e := range []runtimesecurity.AuditEvent{
+		{Timestamp: now, TenantID: "acme", Action: "chat:invoke", Attributes: map[string]interface{}{"user_id": "u-1"}},
+		{Timestamp: now, TenantID: "acme", Action: "chat:invoke", ActorKeyID: "oidc:u-1"},
+		{Timestamp: now, TenantID: "acme", Action: "chat:invoke", Attributes: map[string]interface{}{"user_id": "u-2"}},
+		{Timestamp: now, TenantID: "globex", Action: "chat:invoke", Attributes: map[string]interface{}{"user_id": "u-1"}},
+	} {
+		if err := audit.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export, err := ExportUser(root, "acme", "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Schema != ExportSchema || len(export.Episodic) != 1 || export.Episodic[0].Entries[0] != "prefers email" ||
+		len(export.Transcripts) != 2 || export.Transcripts[1].Response != "30 days" || len(export.Audit) != 2 {
+		t.Fatalf("unexpected export %+v", export)
+	}
+	if len(export.Conversations) != 1 || export.Conversations[0].Component != "SupportBot" || export.Conversations[0].SessionID != "s-1" ||
+		len(export.Conversations[0].Turns) != 2 || export.Conversations[0].Turns[1].Response != "Monday" {
+		t.Fatalf("unexpected conversations %+v", export.Conversations)
+	}
+
+	d, err := DeleteUser(root, "acme", "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Components) != 1 || d.Turns != 2 || d.AuditRetained != 2 {
+		t.Fatalf("unexpected deletion %+v", d)
+	}
+	export, err = ExportUser(root, "acme", "u-1")
+	if err != nil || len(export.Episodic) != 0 || len(export.Conversations) != 0 || len(export.Transcripts) != 0 {
+		t.Fatalf("data left after deletion: %+v %v", export, err)
+	}
+	if turns, _ := tr.Read("acme", "u-2"); len(turns) != 1 {
+		t.Fatalf("other user's transcript touched: %+v", turns)
+	}
+	if _, err := os.Stat(filepath.Join(root, runtimesecurity.DefaultAuditLog)); err != nil {
+		t.Fatalf("audit log removed: %v", err)
+	}
+}
+
+func TestSessionTranscripts(t *testing.T) {
+	root := t.TempDir()
+	tr := NewTranscripts(root)
+	for _, turn := range []Turn{
+		{TenantID: "acme", UserID: "u-1", SessionID: "s-1", Query: "hi", Response: "hello", Model: "gpt-4o", LatencyMS: 12},
+		{TenantID: "acme", UserID: "u-2", SessionID: "s-1", Query: "refund?", Response: "30 days", Sources: []TurnSource{{ID: "faq#2", Score: 0.8, Cited: true}}},
+		{TenantID: "acme", SessionID: "s-2", Query: "anonymous", Response: "ok"},
+	} {
+		if err := tr.Append(turn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Append(Turn{TenantID: "acme", Query: "nowhere"}); err == nil {
+		t.Fatal("expected an error for a turn without user or session")
+	}
+
+	turns, ok, err := tr.ReadSession("acme", "s-1")
+	if err != nil || !ok || len(turns) != 2 || turns[0].Model != "gpt-4o" || !turns[1].Sources[0].Cited {
+		t.Fatalf("unexpected session s-1: %+v %v %v", turns, ok, err)
+	}
+	if _, ok, err := tr.ReadSession("globex", "s-1"); ok || err != nil {
+		t.Fatalf("session visible from another tenant: %v %v", ok, err)
+	}
+	if _, _, err := tr.ReadSession("acme", "../s-1"); err == nil {
+		t.Fatal("expected an error for an invalid session id")
+	}
+
+	if _, err := tr.Delete("acme", "u-2"); err != nil {
+		t.Fatal(err)
+	}
+	turns, ok, err = tr.ReadSession("acme", "s-1")
+	if err != nil || !ok || len(turns) != 1 || turns[0].UserID != "u-1" {
+		t.Fatalf("session not scrubbed: %+v %v %v", turns, ok, err)
+	}
+	if _, err := tr.Delete("acme", "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := tr.ReadSession("acme", "s-1"); ok {
+		t.Fatal("empty session file left behind")
+	}
+	if turns, ok, _ := tr.ReadSession("acme", "s-2"); !ok || len(turns) != 1 {
+		t.Fatalf("anonymous session touched: %+v", turns)
+	}
+}
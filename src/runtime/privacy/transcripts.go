@@ -0,0 +1,284 @@
+package privacy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscriptsDir is the project-relative directory of per-user chat transcripts.
+const TranscriptsDir = "data/transcripts"
+
+// SessionsDir is the project-relative directory of per-session chat transcripts.
+const SessionsDir = "data/sessions"
+
+// defaultTenant names the transcript directory of requests without a tenant.
+const defaultTenant = "_default"
+
+// userIDRe and sessionIDRe restrict IDs to values that are safe as file
+// names. Session IDs may also be request IDs, which allow ':'.
+var (
+	userIDRe    = regexp.MustCompile(`^[A-Za-z0-9@+_-][A-Za-z0-9.@+_-]{0,127}$`)
+	sessionIDRe = regexp.MustCompile(`^[A-Za-z0-9@+_-][A-Za-z0-9.@+:_-]{0,127}$`)
+)
+
+// ValidateUserID reports whether id can be used for transcripts, export and deletion.
+func ValidateUserID(id string) error {
+	if !userIDRe.MatchString(id) {
+		return fmt.Errorf("invalid user id %q", id)
+	}
+	return nil
+}
+
+// ValidateSessionID reports whether id can name a session transcript.
+func ValidateSessionID(id string) error {
+	if !sessionIDRe.MatchString(id) {
+		return fmt.Errorf("invalid session id %q", id)
+	}
+	return nil
+}
+
+// TranscriptsEnabled reports whether chat turns are recorded (CMP_TRANSCRIPTS=true).
+func TranscriptsEnabled() bool {
+	return os.Getenv("CMP_TRANSCRIPTS") == "true"
+}
+
+// Turn is one chat exchange.
+type Turn struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	Context   string    `json:"context,omitempty"`
+	Component string    `json:"component,omitempty"`
+	Query     string    `json:"query"`
+	Response  string    `json:"response"`
+	// PromptHash is the hex SHA-256 of the rendered prompt sent to the model,
+	// so turns can be matched with prompt snapshots without storing prompts.
+	PromptHash string `json:"prompt_hash,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+	// Sources are the memory chunks injected into the prompt.
+	Sources []TurnSource `json:"sources,omitempty"`
+	// LatencyMS is the time the server took to answer, in milliseconds.
+	LatencyMS int64 `json:"latency_ms,omitempty"`
+}
+
+// TurnSource identifies a memory chunk a turn's prompt included.
+type TurnSource struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	Cited bool    `json:"cited,omitempty"`
+}
+
+// transcriptsMu serializes transcript access across stores of the same
+// project, e.g. the chat handler appending while a deletion runs.
+var transcriptsMu sync.Mutex
+
+// Transcripts stores turns as JSONL files per tenant and user, and per tenant
+// and session.
+type Transcripts struct {
+	root string
+}
+
+// NewTranscripts returns the transcript store of a project root.
+func NewTranscripts(root string) *Transcripts {
+	return &Transcripts{root: root}
+}
+
+func tenantDir(tenantID string) string {
+	if tenantID == "" {
+		return defaultTenant
+	}
+	return "tenant_" + strings.NewReplacer("/", "_", "\\", "_", "..", "").Replace(tenantID)
+}
+
+func (t *Transcripts) path(tenantID, userID string) string {
+	return filepath.Join(t.root, TranscriptsDir, tenantDir(tenantID), userID+".jsonl")
+}
+
+func (t *Transcripts) sessionPath(tenantID, sessionID string) string {
+	return filepath.Join(t.root, SessionsDir, tenantDir(tenantID), sessionID+".jsonl")
+}
+
+// Append records a turn in the transcript of its session and, when it has a
+// UserID, in the user's transcript. A turn needs at least one of them.
+func (t *Transcripts) Append(turn Turn) error {
+	if turn.UserID == "" && turn.SessionID == "" {
+		return fmt.Errorf("turn has neither a user nor a session id")
+	}
+	if turn.UserID != "" {
+		if err := ValidateUserID(turn.UserID); err != nil {
+			return err
+		}
+	}
+	if turn.SessionID != "" {
+		if err := ValidateSessionID(turn.SessionID); err != nil {
+			return err
+		}
+	}
+	by, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+	transcriptsMu.Lock()
+	defer transcriptsMu.Unlock()
+	if turn.UserID != "" {
+		if err := appendLine(t.path(turn.TenantID, turn.UserID), by); err != nil {
+			return err
+		}
+	}
+	if turn.SessionID != "" {
+		return appendLine(t.sessionPath(turn.TenantID, turn.SessionID), by)
+	}
+	return nil
+}
+
+func appendLine(path string, line []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Read returns the user's turns, oldest first.
+func (t *Transcripts) Read(tenantID, userID string) ([]Turn, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return nil, err
+	}
+	transcriptsMu.Lock()
+	defer transcriptsMu.Unlock()
+	return readTurns(t.path(tenantID, userID))
+}
+
+// ReadSession returns the turns of a session, oldest first; ok is false when
+// the session has no transcript.
+func (t *Transcripts) ReadSession(tenantID, sessionID string) (turns []Turn, ok bool, err error) {
+	if err := ValidateSessionID(sessionID); err != nil {
+		return nil, false, err
+	}
+	transcriptsMu.Lock()
+	defer transcriptsMu.Unlock()
+	path := t.sessionPath(tenantID, sessionID)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return []Turn{}, false, nil
+	}
+	turns, err = readTurns(path)
+	return turns, err == nil, err
+}
+
+func readTurns(path string) ([]Turn, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Turn{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	turns := []Turn{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var turn Turn
+		if err := json.Unmarshal([]byte(line), &turn); err != nil {
+			return nil, fmt.Errorf("parse transcript: %w", err)
+		}
+		turns = append(turns, turn)
+	}
+	return turns, sc.Err()
+}
+
+// Delete removes the user's transcript, and the user's turns from the session
+// transcripts of the tenant, and returns the number of turns the user's
+// transcript held.
+func (t *Transcripts) Delete(tenantID, userID string) (int, error) {
+	if err := ValidateUserID(userID); err != nil {
+		return 0, err
+	}
+	transcriptsMu.Lock()
+	defer transcriptsMu.Unlock()
+	if err := t.scrubSessions(tenantID, userID); err != nil {
+		return 0, err
+	}
+	path := t.path(tenantID, userID)
+	by, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	turns := 0
+	for _, line := range strings.Split(string(by), "\n") {
+		if strings.TrimSpace(line) != "" {
+			turns++
+		}
+	}
+	return turns, nil
+}
+
+// scrubSessions removes the user's turns from the session transcripts of the
+// tenant. Transcripts left empty are removed. transcriptsMu must be held.
+func (t *Transcripts) scrubSessions(tenantID, userID string) error {
+	paths, err := filepath.Glob(filepath.Join(t.root, SessionsDir, tenantDir(tenantID), "*.jsonl"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		turns, err := readTurns(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		var buf bytes.Buffer
+		kept := 0
+		for _, turn := range turns {
+			if turn.UserID == userID {
+				continue
+			}
+			by, err := json.Marshal(turn)
+			if err != nil {
+				return err
+			}
+			buf.Write(append(by, '\n'))
+			kept++
+		}
+		switch {
+		case kept == len(turns):
+			continue
+		case kept == 0:
+			err = os.Remove(path)
+		default:
+			// Write a sibling and rename, so a crash never leaves a half-written transcript
+			tmp := path + ".tmp"
+			if err = os.WriteFile(tmp, buf.Bytes(), 0o600); err == nil {
+				err = os.Rename(tmp, path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,96 +2,280 @@ package runtimeprompt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// DefaultRenderTimeout bounds the time a template may take to render.
+	DefaultRenderTimeout = 2 * time.Second
+	// DefaultMaxOutputBytes bounds the size of a rendered prompt.
+	DefaultMaxOutputBytes = 1 << 20
+)
+
+var (
+	// ErrRenderTimeout is returned when a template renders for longer than
+	// the engine's RenderTimeout.
+	ErrRenderTimeout = errors.New("template render timed out")
+	// ErrOutputTooLarge is returned when a template writes more than the
+	// engine's MaxOutputBytes.
+	ErrOutputTooLarge = errors.New("template output too large")
 )
 
 // Engine loads, compiles, caches, renders, and validates prompt templates.
+//
+// Templates may call shared partials with {{template "name" .}}. Partials live
+// in prompts/_shared/ and can be overridden per component in
+// prompts/<component>/_partials/. A template whose first line is
+// {{/* extends "base" */}} renders the base partial, with its own {{define}}
+// blocks replacing the base's {{block}} defaults. {{include "file.md" .}}
+// renders another file relative to the template.
+//
+// Templates are sandboxed: they get the helper library of Functions but not
+// the call builtin, may only include files inside prompts/, and rendering
+// stops with ErrRenderTimeout or ErrOutputTooLarge past the engine's limits.
 type Engine struct {
 	mu          sync.RWMutex
-	cache       map[string]*template.Template // key: canonical path
+	cache       map[string]*compiled // key: component and canonical path
 	projectRoot string
+
+	// RenderTimeout and MaxOutputBytes limit each render; zero disables the
+	// limit. NewEngine reads them from CMP_PROMPT_RENDER_TIMEOUT and
+	// CMP_PROMPT_MAX_OUTPUT_BYTES.
+	RenderTimeout  time.Duration
+	MaxOutputBytes int
+}
+
+// compiled is a parsed template set and the template to execute.
+type compiled struct {
+	set  *template.Template
+	name string
 }
 
 func NewEngine(projectRoot string) *Engine {
-	return &Engine{cache: make(map[string]*template.Template), projectRoot: projectRoot}
+	e := &Engine{
+		cache:          make(map[string]*compiled),
+		projectRoot:    projectRoot,
+		RenderTimeout:  DefaultRenderTimeout,
+		MaxOutputBytes: DefaultMaxOutputBytes,
+	}
+	if d, err := time.ParseDuration(os.Getenv("CMP_PROMPT_RENDER_TIMEOUT")); err == nil && d >= 0 {
+		e.RenderTimeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("CMP_PROMPT_MAX_OUTPUT_BYTES")); err == nil && n >= 0 {
+		e.MaxOutputBytes = n
+	}
+	return e
 }
 
 // RenderFile renders a template file in prompts/<component>/... with the provided data.
 func (e *Engine) RenderFile(component string, relPath string, data map[string]interface{}) (string, error) {
 	full := filepath.Join(e.projectRoot, "prompts", component, relPath)
-	tmpl, err := e.loadTemplate(full)
+	tmpl, err := e.loadTemplate(component, full, nil)
 	if err != nil {
 		return "", err
 	}
 	if data == nil {
 		data = map[string]interface{}{}
 	}
-	var sb strings.Builder
-	if err := tmpl.Execute(&sb, data); err != nil {
-		return "", fmt.Errorf("render template: %w", err)
+	deadline := time.Time{}
+	if e.RenderTimeout > 0 {
+		deadline = time.Now().Add(e.RenderTimeout)
+	}
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := e.execute(tmpl, data, deadline)
+		done <- result{out, err}
+	}()
+	var res result
+	if deadline.IsZero() {
+		res = <-done
+	} else {
+		// A template that stops writing (a long range that prints nothing)
+		// is abandoned here; its next write fails on the deadline.
+		timer := time.NewTimer(e.RenderTimeout)
+		defer timer.Stop()
+		select {
+		case res = <-done:
+		case <-timer.C:
+			res.err = ErrRenderTimeout
+		}
+	}
+	if res.err != nil {
+		return "", fmt.Errorf("render template: %w", res.err)
 	}
-	return sb.String(), nil
+	return res.out, nil
+}
+
+// execute renders a compiled template into a buffer bounded by the engine's
+// output limit and deadline.
+func (e *Engine) execute(tmpl *compiled, data interface{}, deadline time.Time) (string, error) {
+	w := &limitedWriter{max: e.MaxOutputBytes, deadline: deadline}
+	if err := tmpl.set.ExecuteTemplate(w, tmpl.name, data); err != nil {
+		// text/template wraps write errors; report the limit itself
+		if w.err != nil {
+			return "", w.err
+		}
+		return "", err
+	}
+	return w.sb.String(), nil
+}
+
+// limitedWriter fails writes beyond max bytes or after the deadline.
+type limitedWriter struct {
+	sb       strings.Builder
+	max      int
+	deadline time.Time
+	err      error
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	switch {
+	case w.err != nil:
+	case !w.deadline.IsZero() && time.Now().After(w.deadline):
+		w.err = ErrRenderTimeout
+	case w.max > 0 && w.sb.Len()+len(p) > w.max:
+		w.err = fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, w.max)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.sb.Write(p)
+}
+
+// Compile parses a template file with its partials and includes without
+// rendering it, reporting syntax errors and missing partials.
+func (e *Engine) Compile(component string, relPath string) error {
+	_, err := e.loadTemplate(component, filepath.Join(e.projectRoot, "prompts", component, relPath), nil)
+	return err
 }
 
-// loadTemplate compiles and caches a template by absolute path.
-func (e *Engine) loadTemplate(absPath string) (*template.Template, error) {
+// loadTemplate compiles and caches a template by absolute path, together with
+// the partials visible to component. including lists the files whose
+// include calls led here and is used to detect include cycles.
+func (e *Engine) loadTemplate(component, absPath string, including []string) (*compiled, error) {
+	key := component + "\x00" + absPath
 	e.mu.RLock()
-	if t, ok := e.cache[absPath]; ok {
+	if t, ok := e.cache[key]; ok {
 		e.mu.RUnlock()
 		return t, nil
 	}
 	e.mu.RUnlock()
 
+	if !e.insidePrompts(absPath) {
+		return nil, fmt.Errorf("template %s is outside the prompts directory", e.relName(absPath))
+	}
+	if i := indexOf(including, absPath); i >= 0 {
+		chain := append(append([]string{}, including[i:]...), absPath)
+		for j := range chain {
+			chain[j] = e.relName(chain[j])
+		}
+		return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+	}
 	b, err := os.ReadFile(absPath)
 	if err != nil {
 		return nil, fmt.Errorf("read template: %w", err)
 	}
-	baseFuncs := template.FuncMap{
-		"join":  strings.Join,
-		"upper": strings.ToUpper,
-		"lower": strings.ToLower,
-		"trim":  strings.TrimSpace,
-		"toJSON": func(v interface{}) string {
-			by, _ := json.Marshal(v)
-			return string(by)
-		},
-	}
-	// Extend with include that uses baseFuncs for included templates
+	including = append(append([]string{}, including...), absPath)
 	funcs := template.FuncMap{}
 	for k, v := range baseFuncs {
 		funcs[k] = v
 	}
 	funcs["include"] = func(rel string, data interface{}) (string, error) {
-		incPath := filepath.Join(filepath.Dir(absPath), rel)
-		b, err := os.ReadFile(incPath)
+		inc, err := e.loadTemplate(component, filepath.Join(filepath.Dir(absPath), rel), including)
 		if err != nil {
 			return "", err
 		}
-		t2, err := template.New(filepath.Base(incPath)).Funcs(baseFuncs).Parse(string(b))
-		if err != nil {
-			return "", err
+		// the includer's writer enforces the deadline and total size as well
+		deadline := time.Time{}
+		if e.RenderTimeout > 0 {
+			deadline = time.Now().Add(e.RenderTimeout)
 		}
-		var sb strings.Builder
-		if err := t2.Execute(&sb, data); err != nil {
-			return "", err
-		}
-		return sb.String(), nil
+		return e.execute(inc, data, deadline)
 	}
-	tmpl, err := template.New(filepath.Base(absPath)).Funcs(funcs).Parse(string(b))
+
+	file := e.relName(absPath)
+	root := template.New(filepath.Base(absPath)).Funcs(funcs)
+	parts, err := e.partials(component)
 	if err != nil {
+		return nil, err
+	}
+	if err := addPartials(root, parts); err != nil {
+		return nil, err
+	}
+	// Parsed after the partials so its {{define}} blocks override {{block}} defaults
+	if _, err := root.Parse(string(b)); err != nil {
 		return nil, fmt.Errorf("parse template: %w", err)
 	}
+	tmpl := &compiled{set: root, name: root.Name()}
+	if m := extendsRe.FindSubmatch(b); m != nil {
+		base := string(m[1])
+		if root.Lookup(base) == nil {
+			return nil, fmt.Errorf("%s: extends unknown partial %q (looked in %s)", file, base, partialDirsHint(component))
+		}
+		tmpl.name = base
+	}
+	if err := checkReferences(root, component, file); err != nil {
+		return nil, err
+	}
+	if err := checkSandbox(root, file); err != nil {
+		return nil, err
+	}
+	// Load constant includes now so missing files and cycles fail at load time
+	for _, rel := range literalIncludes(root) {
+		if _, err := e.loadTemplate(component, filepath.Join(filepath.Dir(absPath), rel), including); err != nil {
+			return nil, fmt.Errorf("%s: include %q: %w", file, rel, err)
+		}
+	}
 	e.mu.Lock()
-	e.cache[absPath] = tmpl
+	e.cache[key] = tmpl
 	e.mu.Unlock()
 	return tmpl, nil
 }
 
+// insidePrompts reports whether path is within the prompts directory.
+func (e *Engine) insidePrompts(path string) bool {
+	rel, err := filepath.Rel(filepath.Join(e.projectRoot, "prompts"), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkSandbox rejects the call builtin, which would let templates invoke
+// arbitrary function values found in their data.
+func checkSandbox(set *template.Template, file string) error {
+	var err error
+	for _, t := range set.Templates() {
+		if t.Tree == nil || err != nil {
+			continue
+		}
+		walkNodes(t.Tree.Root, func(n parse.Node) {
+			if id, ok := n.(*parse.IdentifierNode); ok && id.Ident == "call" && err == nil {
+				err = fmt.Errorf("%s: the call builtin is not allowed in prompt templates", file)
+			}
+		})
+	}
+	return err
+}
+
+// relName returns path relative to the project root for error messages.
+func (e *Engine) relName(path string) string {
+	if rel, err := filepath.Rel(e.projectRoot, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
 // OptimizeTokens trims content to at most maxTokens using a naive whitespace tokenization.
 func OptimizeTokens(content string, maxTokens int) string {
 	if maxTokens <= 0 {
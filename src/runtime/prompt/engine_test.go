@@ -3,6 +3,7 @@ package runtimeprompt
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -44,3 +45,71 @@ func TestValidateFormat(t *testing.T) {
 		t.Fatalf("md validate: %v", err)
 	}
 }
+
+func writePrompts(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, body := range files {
+		p := filepath.Join(root, "prompts", filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRenderFile_PartialsAndInheritance(t *testing.T) {
+	root := t.TempDir()
+	writePrompts(t, root, map[string]string{
+		"_shared/header.md":        "You are {{.name}}.",
+		"_shared/safety.md":        "Be safe.",
+		"_shared/layouts/base.md":  `{{template "header" .}}|{{block "body" .}}default body{{end}}|{{template "safety" .}}`,
+		"Comp/_partials/safety.md": "Be extra safe.",
+		"Comp/flat.md":             `{{template "header" .}} {{template "safety" .}}`,
+		"Comp/child.md":            "{{/* extends \"layouts/base\" */}}\n{{define \"body\"}}Answer: {{.q}}{{end}}",
+		"Comp/inherits_default.md": `{{/* extends "layouts/base" */}}`,
+		"Other/flat.md":            `{{template "header" .}} {{template "safety" .}}`,
+		"Comp/with_include.md":     `{{include "snippets/greet.md" .}}`,
+		"Comp/snippets/greet.md":   `Hi {{.name}}, {{template "safety" .}}`,
+	})
+	eng := NewEngine(root)
+	data := map[string]interface{}{"name": "Bot", "q": "42"}
+	for _, tc := range []struct{ component, file, want string }{
+		{"Comp", "flat.md", "You are Bot. Be extra safe."},
+		{"Other", "flat.md", "You are Bot. Be safe."},
+		{"Comp", "child.md", "You are Bot.|Answer: 42|Be extra safe."},
+		{"Comp", "inherits_default.md", "You are Bot.|default body|Be extra safe."},
+		{"Comp", "with_include.md", "Hi Bot, Be extra safe."},
+	} {
+		out, err := eng.RenderFile(tc.component, tc.file, data)
+		if err != nil || out != tc.want {
+			t.Errorf("%s/%s = %q, %v; want %q", tc.component, tc.file, out, err, tc.want)
+		}
+	}
+}
+
+func TestRenderFile_PartialErrors(t *testing.T) {
+	root := t.TempDir()
+	writePrompts(t, root, map[string]string{
+		"Comp/missing.md":     `{{template "nope" .}}`,
+		"Comp/extends.md":     `{{/* extends "nope" */}}`,
+		"Loop/_partials/a.md": `{{template "b" .}}`,
+		"Loop/_partials/b.md": `{{template "a" .}}`,
+		"Loop/p.md":           `{{template "a" .}}`,
+		"Comp/x.md":           `{{include "y.md" .}}`,
+		"Comp/y.md":           `{{include "x.md" .}}`,
+	})
+	eng := NewEngine(root)
+	for _, tc := range []struct{ component, file, want string }{
+		{"Comp", "missing.md", `unknown partial "nope" (looked in prompts/Comp/_partials/ or prompts/_shared/)`},
+		{"Comp", "extends.md", `extends unknown partial "nope"`},
+		{"Loop", "p.md", "partial cycle: a -> b -> a"},
+		{"Comp", "x.md", "include cycle: prompts/Comp/x.md -> prompts/Comp/y.md -> prompts/Comp/x.md"},
+	} {
+		_, err := eng.RenderFile(tc.component, tc.file, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s/%s: expected error containing %q, got %v", tc.component, tc.file, tc.want, err)
+		}
+	}
+}
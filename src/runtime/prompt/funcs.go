@@ -0,0 +1,223 @@
+package runtimeprompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// Function documents a template helper, as listed by `ctx prompt functions`.
+type Function struct {
+	Name        string `json:"name" yaml:"name"`
+	Signature   string `json:"signature" yaml:"signature"`
+	Description string `json:"description" yaml:"description"`
+	Example     string `json:"example" yaml:"example"`
+}
+
+// helper is a documented template function.
+type helper struct {
+	Function
+	fn interface{}
+}
+
+// helpers is the function library of prompt templates. Templates get only
+// these functions, include and the text/template builtins other than call.
+var helpers = []helper{
+	{Function{"join", "join LIST SEP", "Joins the items of a list with a separator.", `{{join .tags ", "}}`}, joinList},
+	{Function{"split", "split SEP S", "Splits a string at each separator.", `{{range split "," .csv}}...{{end}}`}, func(sep, s string) []string { return strings.Split(s, sep) }},
+	{Function{"upper", "upper S", "Upper-cases a string.", `{{upper .code}}`}, strings.ToUpper},
+	{Function{"lower", "lower S", "Lower-cases a string.", `{{.name | lower}}`}, strings.ToLower},
+	{Function{"trim", "trim S", "Removes leading and trailing white space.", `{{trim .query}}`}, strings.TrimSpace},
+	{Function{"replace", "replace OLD NEW S", "Replaces every occurrence of OLD with NEW.", `{{.text | replace "\n" " "}}`}, func(old, new, s string) string { return strings.ReplaceAll(s, old, new) }},
+	{Function{"contains", "contains SUBSTR S", "Reports whether S contains SUBSTR.", `{{if contains "refund" .query}}...{{end}}`}, func(substr, s string) bool { return strings.Contains(s, substr) }},
+	{Function{"truncate", "truncate N S", "Shortens S to at most N characters, ending in … when cut.", `{{.query | truncate 200}}`}, truncate},
+	{Function{"truncateWords", "truncateWords N S", "Shortens S to at most N words, ending in … when cut.", `{{.content | truncateWords 50}}`}, truncateWords},
+	{Function{"indent", "indent N S", "Indents every line of S by N spaces.", `{{.policy | indent 4}}`}, indent},
+	{Function{"default", "default DEFAULT VALUE", "Returns VALUE, or DEFAULT when VALUE is empty or missing.", `{{.user_name | default "there"}}`}, defaultValue},
+	{Function{"toJSON", "toJSON V", "Encodes a value as compact JSON.", `{{toJSON .data}}`}, toJSON},
+	{Function{"formatDate", "formatDate LAYOUT T", "Formats a time, RFC 3339 string or Unix seconds with a Go layout or date, datetime, rfc3339.", `{{formatDate "date" .created_at}}`}, formatDate},
+	{Function{"cite", "cite I", "Citation marker of the I-th (0-based) result: [1], [2], ...", `{{range $i, $r := .results}}{{cite $i}} {{$r.Content}}{{end}}`}, func(i int) string { return "[" + strconv.Itoa(i+1) + "]" }},
+	{Function{"source", "source R", "Source of a search result: its source or title metadata, else its ID.", `{{source $r}}`}, source},
+	{Function{"citations", "citations RESULTS", "Numbered list of the sources of search results, one per line.", `{{citations .results}}`}, citations},
+}
+
+// Functions returns the documented helper library, sorted by name.
+func Functions() []Function {
+	out := make([]Function, len(helpers))
+	for i, h := range helpers {
+		out[i] = h.Function
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// baseFuncs maps the helper names to their functions.
+var baseFuncs = func() template.FuncMap {
+	m := template.FuncMap{}
+	for _, h := range helpers {
+		m[h.Name] = h.fn
+	}
+	return m
+}()
+
+// joinList joins any list; items that are not strings are formatted with fmt.
+func joinList(list interface{}, sep string) (string, error) {
+	if ss, ok := list.([]string); ok {
+		return strings.Join(ss, sep), nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func truncate(n int, s string) string {
+	if n < 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n == 0 {
+		return ""
+	}
+	r := []rune(s)
+	return strings.TrimRight(string(r[:n-1]), " ") + "…"
+}
+
+func truncateWords(n int, s string) string {
+	words := strings.Fields(s)
+	if n < 0 || len(words) <= n {
+		return s
+	}
+	return strings.Join(words[:n], " ") + "…"
+}
+
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if rv.Len() == 0 {
+			return def
+		}
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return def
+		}
+	}
+	return v
+}
+
+func toJSON(v interface{}) string {
+	by, _ := json.Marshal(v)
+	return string(by)
+}
+
+// dateLayouts are the named layouts of formatDate.
+var dateLayouts = map[string]string{"date": "2006-01-02", "datetime": "2006-01-02 15:04", "rfc3339": time.RFC3339}
+
+func formatDate(layout string, v interface{}) (string, error) {
+	if l, ok := dateLayouts[layout]; ok {
+		layout = l
+	}
+	var t time.Time
+	switch x := v.(type) {
+	case time.Time:
+		t = x
+	case *time.Time:
+		if x == nil {
+			return "", nil
+		}
+		t = *x
+	case string:
+		if x == "" {
+			return "", nil
+		}
+		var err error
+		if t, err = time.Parse(time.RFC3339, x); err != nil {
+			if t, err = time.Parse("2006-01-02", x); err != nil {
+				return "", fmt.Errorf("formatDate: %q is not an RFC 3339 time or date", x)
+			}
+		}
+	case int:
+		t = time.Unix(int64(x), 0).UTC()
+	case int64:
+		t = time.Unix(x, 0).UTC()
+	case float64: // numbers decoded from JSON
+		t = time.Unix(int64(x), 0).UTC()
+	default:
+		return "", fmt.Errorf("formatDate: unsupported value %T", v)
+	}
+	return t.Format(layout), nil
+}
+
+// source names a search result: the source or title in its metadata, or its
+// ID. Results may be structs (runtimememory.SearchResult) or JSON objects.
+func source(r interface{}) string {
+	meta := field(r, "Metadata", "metadata")
+	for _, key := range []string{"source", "title"} {
+		if s := fmt.Sprint(field(meta, key, key)); s != "" && s != "<nil>" {
+			return s
+		}
+	}
+	if id := field(r, "ID", "id"); id != nil {
+		return fmt.Sprint(id)
+	}
+	return ""
+}
+
+// citations lists the sources of results as "[1] source" lines.
+func citations(results interface{}) (string, error) {
+	v := reflect.ValueOf(results)
+	if results == nil {
+		return "", nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("citations: expected a list of results, got %T", results)
+	}
+	lines := make([]string, v.Len())
+	for i := range lines {
+		lines[i] = fmt.Sprintf("[%d] %s", i+1, source(v.Index(i).Interface()))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// field reads a struct field or a map key; nil when absent.
+func field(v interface{}, structField, mapKey string) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if f := rv.FieldByName(structField); f.IsValid() && f.CanInterface() {
+			return f.Interface()
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			if x := rv.MapIndex(reflect.ValueOf(mapKey).Convert(rv.Type().Key())); x.IsValid() {
+				return x.Interface()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package runtimeprompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type result struct {
+	ID       string
+	Content  string
+	Metadata map[string]interface{}
+}
+
+func TestRenderFile_Helpers(t *testing.T) {
+	root := t.TempDir()
+	writePrompts(t, root, map[string]string{
+		"Comp/p.md": `{{.q | truncate 8}}|{{join .tags ","}}|{{formatDate "date" .at}}|{{.missing | default "none"}}|` +
+			`{{range $i, $r := .results}}{{cite $i}}{{end}}|{{citations .results}}|{{toJSON .tags}}`,
+	})
+	data := map[string]interface{}{
+		"q":    "how do refunds work",
+		"tags": []interface{}{"a", 2},
+		"at":   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		"results": []result{
+			{ID: "r1", Metadata: map[string]interface{}{"source": "policy.md"}},
+			{ID: "r2"},
+		},
+	}
+	out, err := NewEngine(root).RenderFile("Comp", "p.md", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "how do…|a,2|2026-03-01|none|[1][2]|[1] policy.md\n[2] r2|[\"a\",2]"
+	if out != want {
+		t.Fatalf("got  %q\nwant %q", out, want)
+	}
+}
+
+func TestRenderFile_Sandbox(t *testing.T) {
+	root := t.TempDir()
+	writePrompts(t, root, map[string]string{
+		"Comp/call.md":   `{{call .fn}}`,
+		"Comp/escape.md": `{{include "../../secret.txt" .}}`,
+		"Comp/big.md":    `{{range .items}}{{.}}{{end}}`,
+	})
+	eng := NewEngine(root)
+	if err := eng.Compile("Comp", "call.md"); err == nil || !strings.Contains(err.Error(), "call builtin") {
+		t.Fatalf("expected call to be rejected, got %v", err)
+	}
+	if err := eng.Compile("Comp", "escape.md"); err == nil || !strings.Contains(err.Error(), "outside the prompts directory") {
+		t.Fatalf("expected the include to be confined to prompts/, got %v", err)
+	}
+
+	eng.MaxOutputBytes = 100
+	items := make([]string, 50)
+	for i := range items {
+		items[i] = "0123456789"
+	}
+	if _, err := eng.RenderFile("Comp", "big.md", map[string]interface{}{"items": items}); !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+
+	eng.MaxOutputBytes = 0
+	eng.RenderTimeout = time.Nanosecond
+	if _, err := eng.RenderFile("Comp", "big.md", map[string]interface{}{"items": items}); !errors.Is(err, ErrRenderTimeout) {
+		t.Fatalf("expected ErrRenderTimeout, got %v", err)
+	}
+}
+
+func TestFunctionsDocumentEveryHelper(t *testing.T) {
+	fns := Functions()
+	if len(fns) != len(baseFuncs) {
+		t.Fatalf("documented %d of %d helpers", len(fns), len(baseFuncs))
+	}
+	for _, f := range fns {
+		if f.Signature == "" || f.Description == "" || f.Example == "" {
+			t.Fatalf("helper %s is not fully documented", f.Name)
+		}
+	}
+}
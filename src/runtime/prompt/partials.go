@@ -0,0 +1,227 @@
+package runtimeprompt
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// SharedDir (prompts/_shared) holds partials available to every component.
+const SharedDir = "_shared"
+
+// PartialsDir (prompts/<component>/_partials) holds component partials, which
+// override shared partials of the same name.
+const PartialsDir = "_partials"
+
+// extendsRe matches the inheritance directive on a template's first line:
+// {{/* extends "base" */}}. The named partial is rendered with the template's
+// {{define}} blocks in place of its {{block}} defaults.
+var extendsRe = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*extends\s+"([^"]+)"\s*\*/\s*-?\}\}`)
+
+// partial is a named template file available to {{template "name" .}}.
+type partial struct {
+	name string // path relative to its partials directory, without extension
+	path string
+}
+
+// partials returns the partials visible to component: shared ones first,
+// replaced by component overrides of the same name.
+func (e *Engine) partials(component string) (map[string]partial, error) {
+	out := map[string]partial{}
+	dirs := []string{filepath.Join(e.projectRoot, "prompts", SharedDir)}
+	if component != "" {
+		dirs = append(dirs, filepath.Join(e.projectRoot, "prompts", component, PartialsDir))
+	}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == dir {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, p)
+			name := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+			out[name] = partial{name: name, path: p}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("load partials: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// partialDirsHint describes where a component's partials are looked up.
+func partialDirsHint(component string) string {
+	return fmt.Sprintf("prompts/%s/%s/ or prompts/%s/", component, PartialsDir, SharedDir)
+}
+
+// addPartials parses every partial into root's template set, in name order.
+func addPartials(root *template.Template, parts map[string]partial) error {
+	names := make([]string, 0, len(parts))
+	for n := range parts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		b, err := os.ReadFile(parts[n].path)
+		if err != nil {
+			return fmt.Errorf("read partial %q: %w", n, err)
+		}
+		if _, err := root.New(n).Parse(string(b)); err != nil {
+			return fmt.Errorf("parse partial %q (%s): %w", n, parts[n].path, err)
+		}
+	}
+	return nil
+}
+
+// checkReferences reports {{template}} calls to undefined templates and
+// cycles between templates in the set.
+func checkReferences(set *template.Template, component, file string) error {
+	graph := map[string][]string{}
+	for _, t := range set.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		var refs []string
+		walkNodes(t.Tree.Root, func(n parse.Node) {
+			if tn, ok := n.(*parse.TemplateNode); ok {
+				refs = append(refs, tn.Name)
+			}
+		})
+		graph[t.Name()] = refs
+	}
+	for from, refs := range graph {
+		for _, to := range refs {
+			if _, ok := graph[to]; !ok {
+				return fmt.Errorf("%s: template %q references unknown partial %q (looked in %s)", file, from, to, partialDirsHint(component))
+			}
+		}
+	}
+	// Depth-first search; a back edge is a cycle
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(n string) error
+	visit = func(n string) error {
+		switch state[n] {
+		case visiting:
+			i := indexOf(stack, n)
+			return fmt.Errorf("%s: partial cycle: %s", file, strings.Join(append(stack[i:], n), " -> "))
+		case done:
+			return nil
+		}
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, to := range graph[n] {
+			if err := visit(to); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return nil
+	}
+	names := make([]string, 0, len(graph))
+	for n := range graph {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// literalIncludes returns the constant paths passed to include in the set.
+func literalIncludes(set *template.Template) []string {
+	var out []string
+	for _, t := range set.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		walkNodes(t.Tree.Root, func(n parse.Node) {
+			cmd, ok := n.(*parse.CommandNode)
+			if !ok || len(cmd.Args) < 2 {
+				return
+			}
+			if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && id.Ident == "include" {
+				if s, ok := cmd.Args[1].(*parse.StringNode); ok {
+					out = append(out, s.Text)
+				}
+			}
+		})
+	}
+	return out
+}
+
+// walkNodes calls fn for n and every node beneath it.
+func walkNodes(n parse.Node, fn func(parse.Node)) {
+	if n == nil {
+		return
+	}
+	fn(n)
+	switch x := n.(type) {
+	case *parse.ListNode:
+		if x == nil {
+			return
+		}
+		for _, c := range x.Nodes {
+			walkNodes(c, fn)
+		}
+	case *parse.ActionNode:
+		walkNodes(x.Pipe, fn)
+	case *parse.PipeNode:
+		if x == nil {
+			return
+		}
+		for _, c := range x.Cmds {
+			walkNodes(c, fn)
+		}
+	case *parse.CommandNode:
+		for _, a := range x.Args {
+			walkNodes(a, fn)
+		}
+	case *parse.IfNode:
+		walkBranch(&x.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&x.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&x.BranchNode, fn)
+	case *parse.TemplateNode:
+		walkNodes(x.Pipe, fn)
+	}
+}
+
+func walkBranch(b *parse.BranchNode, fn func(parse.Node)) {
+	walkNodes(b.Pipe, fn)
+	walkNodes(b.List, fn)
+	if b.ElseList != nil {
+		walkNodes(b.ElseList, fn)
+	}
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,92 @@
+// Package pyenv locates the Python interpreter used for local models,
+// reranking, drift scoring and `ctx setup` on every platform.
+//
+// Resolution order: CMP_PYTHON_BIN, the project's virtual environment
+// (.venv/bin/python, or .venv\Scripts\python.exe on Windows), then the first
+// interpreter on PATH. On Windows `python` is tried before `python3`, which
+// is often the Microsoft Store placeholder, and the `py` launcher comes last.
+package pyenv
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// EnvBin overrides interpreter discovery.
+const EnvBin = "CMP_PYTHON_BIN"
+
+// resolver holds the platform hooks so tests can resolve for another OS.
+type resolver struct {
+	goos     string
+	getenv   func(string) string
+	lookPath func(string) (string, error)
+	exists   func(string) bool
+}
+
+var host = resolver{goos: runtime.GOOS, getenv: os.Getenv, lookPath: exec.LookPath, exists: fileExists}
+
+// Bin returns the interpreter for the project at root (which may be empty).
+func Bin(root string) string { return host.bin(root) }
+
+// System returns the Python 3 interpreter on PATH, ignoring CMP_PYTHON_BIN
+// and virtual environments; `ctx setup` creates .venv with it. When none is
+// found it returns the platform's usual name so the error names a command.
+func System() string { return host.system() }
+
+// VenvPython returns the interpreter path of the virtual environment in
+// dir/.venv, whether or not it exists.
+func VenvPython(dir string) string { return host.venvPython(dir) }
+
+// Command returns an exec.Cmd running the project's interpreter with args.
+func Command(root string, args ...string) *exec.Cmd {
+	return exec.Command(Bin(root), args...)
+}
+
+// CommandContext is Command killed when ctx is done.
+func CommandContext(ctx context.Context, root string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, Bin(root), args...)
+}
+
+func (r resolver) bin(root string) string {
+	if bin := r.getenv(EnvBin); bin != "" {
+		// Windows users often leave out the extension of an explicit path
+		if r.goos == "windows" && filepath.Ext(bin) == "" && r.exists(bin+".exe") {
+			return bin + ".exe"
+		}
+		return bin
+	}
+	if root != "" {
+		if venv := r.venvPython(root); r.exists(venv) {
+			return venv
+		}
+	}
+	return r.system()
+}
+
+func (r resolver) system() string {
+	names := []string{"python3", "python"}
+	if r.goos == "windows" {
+		names = []string{"python", "python3", "py"}
+	}
+	for _, name := range names {
+		if _, err := r.lookPath(name); err == nil {
+			return name
+		}
+	}
+	return names[0]
+}
+
+func (r resolver) venvPython(dir string) string {
+	if r.goos == "windows" {
+		return filepath.Join(dir, ".venv", "Scripts", "python.exe")
+	}
+	return filepath.Join(dir, ".venv", "bin", "python")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
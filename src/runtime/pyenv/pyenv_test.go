@@ -0,0 +1,74 @@
+package pyenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fakeResolver(goos string, env map[string]string, onPath []string, files ...string) resolver {
+	return resolver{
+		goos:   goos,
+		getenv: func(k string) string { return env[k] },
+		lookPath: func(name string) (string, error) {
+			for _, p := range onPath {
+				if p == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		},
+		exists: func(path string) bool {
+			for _, f := range files {
+				if f == path {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func TestBin(t *testing.T) {
+	root := "proj"
+	cases := []struct {
+		name string
+		r    resolver
+		want string
+	}{
+		{"env override", fakeResolver("linux", map[string]string{EnvBin: "/opt/py/bin/python3.11"}, nil), "/opt/py/bin/python3.11"},
+		{"unix venv", fakeResolver("linux", nil, []string{"python3"}, filepath.Join(root, ".venv", "bin", "python")), filepath.Join(root, ".venv", "bin", "python")},
+		{"windows venv", fakeResolver("windows", nil, []string{"python"}, filepath.Join(root, ".venv", "Scripts", "python.exe")), filepath.Join(root, ".venv", "Scripts", "python.exe")},
+		{"windows venv ignores unix layout", fakeResolver("windows", nil, []string{"python"}, filepath.Join(root, ".venv", "bin", "python")), "python"},
+		{"windows exe suffix", fakeResolver("windows", map[string]string{EnvBin: `C:\Python311\python`}, nil, `C:\Python311\python.exe`), `C:\Python311\python.exe`},
+		{"unix path", fakeResolver("linux", nil, []string{"python", "python3"}), "python3"},
+		{"unix python only", fakeResolver("linux", nil, []string{"python"}), "python"},
+		{"windows prefers python", fakeResolver("windows", nil, []string{"python3", "python"}), "python"},
+		{"windows launcher", fakeResolver("windows", nil, []string{"py"}), "py"},
+		{"nothing installed", fakeResolver("windows", nil, nil), "python"},
+	}
+	for _, c := range cases {
+		if got := c.r.bin(root); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestBin_HostVenv(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(EnvBin, "")
+	venv := VenvPython(root)
+	if err := os.MkdirAll(filepath.Dir(venv), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(venv, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := Bin(root); got != venv {
+		t.Fatalf("Bin = %q, want %q", got, venv)
+	}
+	if got := System(); got == venv {
+		t.Fatalf("System must ignore the venv, got %q", got)
+	}
+}
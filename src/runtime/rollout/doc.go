@@ -0,0 +1,10 @@
+// Package rollout shifts chat traffic between registered versions of a context.
+//
+// config/rollouts.yaml pins each rolled-out context to a stable version under
+// contexts/<Name>/versions/ and, while a rollout is in progress, sends a
+// percentage of requests to a candidate version. Requests are assigned
+// deterministically from their session, user or tenant, and raising the
+// percentage only moves callers from stable to candidate. Outcomes are
+// appended to a JSONL log under data/rollouts and summarized per version, so
+// the candidate can be promoted or rolled back on its error rate and scores.
+package rollout
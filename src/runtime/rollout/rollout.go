@@ -0,0 +1,474 @@
+package rollout
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	runtimeexperiment "github.com/contexis-cmp/contexis/src/runtime/experiment"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the project-relative path of the rollout state.
+const ConfigFile = "config/rollouts.yaml"
+
+// OutcomesFile is the project-relative path of the outcome log.
+const OutcomesFile = "data/rollouts/outcomes.jsonl"
+
+// Tracks: the side of a rollout a request was served from.
+const (
+	Stable    = "stable"
+	Candidate = "candidate"
+)
+
+// Rollout routes the traffic of one context between registered versions.
+type Rollout struct {
+	Context string `yaml:"context" json:"context"`
+	Stable  string `yaml:"stable" json:"stable"`
+	// Candidate is set while a rollout is in progress.
+	Candidate string `yaml:"candidate,omitempty" json:"candidate,omitempty"`
+	// Percent is the share of requests served by the candidate, 0-100.
+	Percent  int       `yaml:"percent" json:"percent"`
+	AssignBy string    `yaml:"assign_by,omitempty" json:"assign_by,omitempty"` // session|user|tenant; default session
+	Updated  time.Time `yaml:"updated,omitempty" json:"updated,omitempty"`
+}
+
+// Config is the parsed config/rollouts.yaml.
+type Config struct {
+	Rollouts []Rollout `yaml:"rollouts"`
+}
+
+// LoadConfig reads and validates the rollouts under root. A missing file
+// yields an empty config.
+func LoadConfig(root string) (*Config, error) {
+	c := &Config{}
+	by, err := os.ReadFile(filepath.Join(root, ConfigFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := yaml.Unmarshal(by, c); err != nil {
+		return &Config{}, fmt.Errorf("parse %s: %w", ConfigFile, err)
+	}
+	if err := c.validate(root); err != nil {
+		return &Config{}, fmt.Errorf("%s: %w", ConfigFile, err)
+	}
+	return c, nil
+}
+
+// validate checks each rollout and that its versions are registered.
+func (c *Config) validate(root string) error {
+	svc := runtimecontext.NewContextService(root)
+	seen := map[string]bool{}
+	for i := range c.Rollouts {
+		r := &c.Rollouts[i]
+		if r.Context == "" || r.Stable == "" {
+			return fmt.Errorf("rollout %d: context and stable are required", i+1)
+		}
+		if seen[r.Context] {
+			return fmt.Errorf("duplicate rollout for context %q", r.Context)
+		}
+		seen[r.Context] = true
+		switch r.AssignBy {
+		case "", runtimeexperiment.BySession, runtimeexperiment.ByUser, runtimeexperiment.ByTenant:
+		default:
+			return fmt.Errorf("rollout %q: unsupported assign_by %q (want session, user or tenant)", r.Context, r.AssignBy)
+		}
+		if r.Percent < 0 || r.Percent > 100 {
+			return fmt.Errorf("rollout %q: percent must be between 0 and 100", r.Context)
+		}
+		if r.Candidate == r.Stable {
+			return fmt.Errorf("rollout %q: candidate and stable are both %s", r.Context, r.Stable)
+		}
+		for _, v := range []string{r.Stable, r.Candidate} {
+			if v == "" {
+				continue
+			}
+			if err := runtimecontext.ValidateVersion(v); err != nil {
+				return fmt.Errorf("rollout %q: %w", r.Context, err)
+			}
+			if _, err := os.Stat(svc.VersionPath(r.Context, v)); err != nil {
+				return fmt.Errorf("rollout %q: version %s is not registered (ctx context rollout register %s)", r.Context, v, r.Context)
+			}
+		}
+	}
+	return nil
+}
+
+// Save validates c and writes it to root.
+func (c *Config) Save(root string) error {
+	if err := c.validate(root); err != nil {
+		return err
+	}
+	sort.Slice(c.Rollouts, func(i, j int) bool { return c.Rollouts[i].Context < c.Rollouts[j].Context })
+	by, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, ConfigFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	header := "# Context rollouts, managed with `ctx context rollout`.\n"
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append([]byte(header), by...), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get returns the rollout of contextName, or nil.
+func (c *Config) Get(contextName string) *Rollout {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Rollouts {
+		if c.Rollouts[i].Context == contextName {
+			return &c.Rollouts[i]
+		}
+	}
+	return nil
+}
+
+// Start sends percent of the traffic of contextName to candidate. stable
+// defaults to the version the context is pinned to; a rollout already in
+// progress must be promoted or rolled back first.
+func (c *Config) Start(contextName, stable, candidate string, percent int, assignBy string, now time.Time) error {
+	r := c.Get(contextName)
+	if r != nil && r.Candidate != "" {
+		return fmt.Errorf("a rollout of %s to %s is in progress: promote or roll it back first", contextName, r.Candidate)
+	}
+	if stable == "" {
+		if r == nil {
+			return fmt.Errorf("context %s has no stable version yet: pass --stable", contextName)
+		}
+		stable = r.Stable
+	}
+	if candidate == "" {
+		return fmt.Errorf("a candidate version is required")
+	}
+	if err := checkPercent(percent); err != nil {
+		return err
+	}
+	if r == nil {
+		c.Rollouts = append(c.Rollouts, Rollout{Context: contextName})
+		r = &c.Rollouts[len(c.Rollouts)-1]
+	}
+	r.Stable, r.Candidate, r.Percent, r.Updated = stable, candidate, percent, now.UTC()
+	if assignBy != "" {
+		r.AssignBy = assignBy
+	}
+	return nil
+}
+
+// Shift changes the share of traffic served by the candidate of contextName.
+func (c *Config) Shift(contextName string, percent int, now time.Time) error {
+	r, err := c.inProgress(contextName)
+	if err != nil {
+		return err
+	}
+	if err := checkPercent(percent); err != nil {
+		return err
+	}
+	r.Percent, r.Updated = percent, now.UTC()
+	return nil
+}
+
+// Promote makes the candidate of contextName its stable version and serves it
+// to all traffic.
+func (c *Config) Promote(contextName string, now time.Time) (Rollout, error) {
+	r, err := c.inProgress(contextName)
+	if err != nil {
+		return Rollout{}, err
+	}
+	r.Stable, r.Candidate, r.Percent, r.Updated = r.Candidate, "", 0, now.UTC()
+	return *r, nil
+}
+
+// Rollback drops the candidate of contextName and serves the stable version
+// to all traffic.
+func (c *Config) Rollback(contextName string, now time.Time) (Rollout, error) {
+	r, err := c.inProgress(contextName)
+	if err != nil {
+		return Rollout{}, err
+	}
+	r.Candidate, r.Percent, r.Updated = "", 0, now.UTC()
+	return *r, nil
+}
+
+func (c *Config) inProgress(contextName string) (*Rollout, error) {
+	r := c.Get(contextName)
+	if r == nil || r.Candidate == "" {
+		return nil, fmt.Errorf("no rollout of %s is in progress", contextName)
+	}
+	return r, nil
+}
+
+func checkPercent(percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100, got %d", percent)
+	}
+	return nil
+}
+
+// Assignment is the context version a request was served.
+type Assignment struct {
+	Context string `json:"context"`
+	Version string `json:"version"`
+	Track   string `json:"track"`
+}
+
+// Assign picks the version of contextName for u. ok is false when the context
+// is not rolled out. A caller stays on the candidate as its percentage grows.
+func (c *Config) Assign(contextName string, u runtimeexperiment.Unit) (a Assignment, ok bool) {
+	r := c.Get(contextName)
+	if r == nil {
+		return a, false
+	}
+	a = Assignment{Context: r.Context, Version: r.Stable, Track: Stable}
+	if r.Candidate != "" && r.Percent > 0 {
+		by := r.AssignBy
+		if by == "" {
+			by = runtimeexperiment.BySession
+		}
+		sum := sha256.Sum256([]byte(r.Context + "\x00" + r.Candidate + "\x00" + u.Key(by)))
+		if int(binary.BigEndian.Uint64(sum[:8])%100) < r.Percent {
+			a.Version, a.Track = r.Candidate, Candidate
+		}
+	}
+	return a, true
+}
+
+// Source serves the rollouts of a running server and re-reads
+// config/rollouts.yaml when it changes, so traffic shifts apply without a
+// restart.
+type Source struct {
+	mu      sync.Mutex
+	root    string
+	modTime time.Time
+	size    int64
+	cfg     *Config
+}
+
+// NewSource returns the rollout source for a project root.
+func NewSource(root string) *Source {
+	return &Source{root: root, cfg: &Config{}}
+}
+
+// Config returns the current rollouts. When the file changed and no longer
+// loads, the previous rollouts are kept and the error is returned, once.
+func (s *Source) Config() (*Config, error) {
+	var modTime time.Time
+	var size int64 = -1
+	if info, err := os.Stat(filepath.Join(s.root, ConfigFile)); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if modTime.Equal(s.modTime) && size == s.size {
+		return s.cfg, nil
+	}
+	s.modTime, s.size = modTime, size
+	cfg, err := LoadConfig(s.root)
+	if err != nil {
+		return s.cfg, err
+	}
+	s.cfg = cfg
+	CandidatePercent.Reset()
+	for _, r := range cfg.Rollouts {
+		CandidatePercent.WithLabelValues(r.Context).Set(float64(r.Percent))
+	}
+	return s.cfg, nil
+}
+
+// Outcome is the result of one request served by a rolled-out context.
+type Outcome struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	Context   string    `json:"context"`
+	Version   string    `json:"version"`
+	Track     string    `json:"track"`
+	Status    int       `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	// EvalScore is the grounding score of the answer, when the version
+	// configures guardrails.grounding.
+	EvalScore *float64 `json:"eval_score,omitempty"`
+}
+
+// Failed reports whether the request ended in an error response.
+func (o Outcome) Failed() bool { return o.Status <= 0 || o.Status >= 400 }
+
+// Filter selects outcomes. Zero values match everything; To is exclusive.
+type Filter struct {
+	From    time.Time
+	To      time.Time
+	Context string
+}
+
+func (f Filter) match(o Outcome) bool {
+	if !f.From.IsZero() && o.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !o.Time.Before(f.To) {
+		return false
+	}
+	return f.Context == "" || o.Context == f.Context
+}
+
+// Log is an append-only JSONL file of outcomes.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLog returns the outcome log for a project root.
+func NewLog(root string) *Log {
+	return &Log{path: filepath.Join(root, OutcomesFile)}
+}
+
+// Append writes an outcome to the log and updates the outcome metrics.
+func (l *Log) Append(o Outcome) error {
+	result := "success"
+	if o.Failed() {
+		result = "error"
+	}
+	Requests.WithLabelValues(o.Context, o.Version, result).Inc()
+	if o.EvalScore != nil {
+		EvalScores.WithLabelValues(o.Context, o.Version).Observe(*o.EvalScore)
+	}
+	by, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(by, '\n'))
+	return err
+}
+
+// Query returns the outcomes matching f, oldest first.
+func (l *Log) Query(f Filter) ([]Outcome, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var out []Outcome
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var o Outcome
+		if err := json.Unmarshal([]byte(line), &o); err != nil {
+			return nil, fmt.Errorf("parse rollout outcomes: %w", err)
+		}
+		if f.match(o) {
+			out = append(out, o)
+		}
+	}
+	return out, sc.Err()
+}
+
+// Summary aggregates the outcomes of one context version. Latency and eval
+// scores cover answered requests only.
+type Summary struct {
+	Context      string  `json:"context"`
+	Version      string  `json:"version"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMS float64 `json:"avg_latency_ms"`
+	// Scored counts the answers with an eval score.
+	Scored       int     `json:"scored"`
+	AvgEvalScore float64 `json:"avg_eval_score"`
+
+	latency int64
+	score   float64
+}
+
+// Summarize groups outcomes per context and version.
+func Summarize(outcomes []Outcome) []Summary {
+	rows := map[[2]string]*Summary{}
+	for _, o := range outcomes {
+		key := [2]string{o.Context, o.Version}
+		s, ok := rows[key]
+		if !ok {
+			s = &Summary{Context: key[0], Version: key[1]}
+			rows[key] = s
+		}
+		s.Requests++
+		if o.Failed() {
+			s.Errors++
+			continue
+		}
+		s.latency += o.LatencyMS
+		if o.EvalScore != nil {
+			s.Scored++
+			s.score += *o.EvalScore
+		}
+	}
+	out := make([]Summary, 0, len(rows))
+	for _, s := range rows {
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+		if n := s.Requests - s.Errors; n > 0 {
+			s.AvgLatencyMS = float64(s.latency) / float64(n)
+		}
+		if s.Scored > 0 {
+			s.AvgEvalScore = s.score / float64(s.Scored)
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Context != out[j].Context {
+			return out[i].Context < out[j].Context
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// Prometheus metrics for watching a rollout on dashboards
+var (
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_rollout_requests_total",
+		Help: "Chat requests served by each rolled-out context version, by result (success|error).",
+	}, []string{"context", "version", "result"})
+	EvalScores = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cmp_rollout_eval_score",
+		Help:    "Grounding scores of answers by rolled-out context version.",
+		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	}, []string{"context", "version"})
+	CandidatePercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_rollout_candidate_percent",
+		Help: "Share of traffic sent to the candidate version of each rolled-out context.",
+	}, []string{"context"})
+)
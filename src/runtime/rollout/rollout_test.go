@@ -0,0 +1,173 @@
+package rollout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	runtimeexperiment "github.com/contexis-cmp/contexis/src/runtime/experiment"
+)
+
+func writeProject(t *testing.T, versions ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "contexts", "SupportBot", "versions")
+	_ = os.MkdirAll(dir, 0o755)
+	for _, v := range versions {
+		body := fmt.Sprintf("name: SupportBot\nversion: %q\nrole:\n  persona: Support agent %s\n", v, v)
+		_ = os.WriteFile(filepath.Join(dir, v+".ctx"), []byte(body), 0o644)
+	}
+	return root
+}
+
+func TestRolloutLifecycle(t *testing.T) {
+	root := writeProject(t, "1.2.0", "1.3.0")
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Start("SupportBot", "", "1.3.0", 10, "", now); err == nil || !strings.Contains(err.Error(), "--stable") {
+		t.Fatalf("expected the first rollout to need a stable version, got %v", err)
+	}
+	if err := cfg.Start("SupportBot", "1.2.0", "1.4.0", 10, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Save(root); err == nil || !strings.Contains(err.Error(), "1.4.0 is not registered") {
+		t.Fatalf("expected an unregistered candidate to be refused, got %v", err)
+	}
+
+	cfg = &Config{}
+	if err := cfg.Start("SupportBot", "1.2.0", "1.3.0", 10, runtimeexperiment.ByUser, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Start("SupportBot", "", "1.3.0", 20, "", now); err == nil {
+		t.Fatal("expected a second rollout to wait for the first")
+	}
+	if err := cfg.Shift("SupportBot", 101, now); err == nil {
+		t.Fatal("expected an out of range percent to be refused")
+	}
+	if err := cfg.Shift("SupportBot", 50, now); err != nil || cfg.Save(root) != nil {
+		t.Fatalf("Shift = %v", err)
+	}
+	loaded, err := LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := loaded.Get("SupportBot"); r == nil || r.Percent != 50 || r.AssignBy != runtimeexperiment.ByUser || !r.Updated.Equal(now) {
+		t.Fatalf("saved rollout = %+v", r)
+	}
+
+	if r, err := loaded.Rollback("SupportBot", now); err != nil || r.Stable != "1.2.0" || r.Candidate != "" || r.Percent != 0 {
+		t.Fatalf("Rollback = %+v, %v", r, err)
+	}
+	if _, err := loaded.Promote("SupportBot", now); err == nil {
+		t.Fatal("expected nothing to promote after a rollback")
+	}
+	if err := loaded.Start("SupportBot", "", "1.3.0", 100, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := loaded.Promote("SupportBot", now); err != nil || r.Stable != "1.3.0" || r.Candidate != "" {
+		t.Fatalf("Promote = %+v, %v", r, err)
+	}
+	if a, ok := loaded.Assign("SupportBot", runtimeexperiment.Unit{SessionID: "s"}); !ok || a.Version != "1.3.0" || a.Track != Stable {
+		t.Fatalf("expected the promoted version for everyone, got %+v", a)
+	}
+	if _, ok := loaded.Assign("Other", runtimeexperiment.Unit{}); ok {
+		t.Fatal("expected no assignment for a context without a rollout")
+	}
+}
+
+func TestAssign_ShiftOnlyMovesCallersToCandidate(t *testing.T) {
+	cfg := &Config{Rollouts: []Rollout{{Context: "SupportBot", Stable: "1.2.0", Candidate: "1.3.0", Percent: 10}}}
+	onCandidate := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		u := runtimeexperiment.Unit{SessionID: fmt.Sprintf("session-%d", i)}
+		a, ok := cfg.Assign("SupportBot", u)
+		if !ok {
+			t.Fatal("rollout not active")
+		}
+		if again, _ := cfg.Assign("SupportBot", u); again != a {
+			t.Fatalf("session %s assigned %+v then %+v", u.SessionID, a, again)
+		}
+		if a.Track == Candidate {
+			onCandidate[u.SessionID] = true
+		}
+	}
+	if n := len(onCandidate); n < 60 || n > 140 {
+		t.Fatalf("expected about 10%% on the candidate, got %d of 1000", n)
+	}
+	cfg.Rollouts[0].Percent = 50
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		u := runtimeexperiment.Unit{SessionID: fmt.Sprintf("session-%d", i)}
+		a, _ := cfg.Assign("SupportBot", u)
+		if onCandidate[u.SessionID] && a.Track != Candidate {
+			t.Fatalf("session %s moved back to stable", u.SessionID)
+		}
+		if a.Track == Candidate {
+			moved++
+		}
+	}
+	if moved < 420 || moved > 580 {
+		t.Fatalf("expected about 50%% on the candidate, got %d of 1000", moved)
+	}
+}
+
+func TestSource_ReloadsChangedConfig(t *testing.T) {
+	root := writeProject(t, "1.2.0", "1.3.0")
+	src := NewSource(root)
+	if cfg, err := src.Config(); err != nil || len(cfg.Rollouts) != 0 {
+		t.Fatalf("Config = %+v, %v", cfg, err)
+	}
+	cfg := &Config{Rollouts: []Rollout{{Context: "SupportBot", Stable: "1.2.0", Candidate: "1.3.0", Percent: 25}}}
+	if err := cfg.Save(root); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := src.Config(); err != nil || got.Get("SupportBot") == nil || got.Get("SupportBot").Percent != 25 {
+		t.Fatalf("expected the saved rollout, got %+v, %v", got, err)
+	}
+
+	_ = os.WriteFile(filepath.Join(root, ConfigFile), []byte("rollouts:\n  - context: SupportBot\n    stable: 9.9.9\n"), 0o644)
+	if got, err := src.Config(); err == nil || got.Get("SupportBot").Percent != 25 {
+		t.Fatalf("expected the previous rollout and an error, got %+v, %v", got, err)
+	}
+	if _, err := src.Config(); err != nil {
+		t.Fatalf("expected the error to be reported once, got %v", err)
+	}
+}
+
+func TestLogAndSummarize(t *testing.T) {
+	l := NewLog(t.TempDir())
+	score := func(v float64) *float64 { return &v }
+	now := time.Now().UTC()
+	for _, o := range []Outcome{
+		{Time: now, Context: "SupportBot", Version: "1.2.0", Track: Stable, Status: 200, LatencyMS: 100, EvalScore: score(0.8)},
+		{Time: now, Context: "SupportBot", Version: "1.2.0", Track: Stable, Status: 200, LatencyMS: 300},
+		{Time: now, Context: "SupportBot", Version: "1.3.0", Track: Candidate, Status: 200, LatencyMS: 200, EvalScore: score(0.5)},
+		{Time: now, Context: "SupportBot", Version: "1.3.0", Track: Candidate, Status: 502, LatencyMS: 900, EvalScore: score(0)},
+		{Time: now, Context: "Other", Version: "1.0.0", Track: Stable, Status: 200},
+	} {
+		if err := l.Append(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	outcomes, err := l.Query(Filter{Context: "SupportBot"})
+	if err != nil || len(outcomes) != 4 {
+		t.Fatalf("Query = %d, %v", len(outcomes), err)
+	}
+	rows := Summarize(outcomes)
+	if len(rows) != 2 {
+		t.Fatalf("Summarize = %+v", rows)
+	}
+	stable, candidate := rows[0], rows[1]
+	if stable.Version != "1.2.0" || stable.Requests != 2 || stable.ErrorRate != 0 || stable.AvgLatencyMS != 200 || stable.Scored != 1 || stable.AvgEvalScore != 0.8 {
+		t.Fatalf("stable = %+v", stable)
+	}
+	if candidate.Errors != 1 || candidate.ErrorRate != 0.5 || candidate.AvgLatencyMS != 200 || candidate.Scored != 1 || candidate.AvgEvalScore != 0.5 {
+		t.Fatalf("candidate = %+v", candidate)
+	}
+}
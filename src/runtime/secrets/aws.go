@@ -0,0 +1,162 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS reads secrets from AWS Secrets Manager. Paths are secret names or
+// ARNs. Requests are signed with Signature Version 4 from the standard
+// AWS_* credentials, so no SDK is needed.
+type AWS struct {
+	Region          string // AWS_REGION or AWS_DEFAULT_REGION
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Endpoint        string // CMP_AWS_SECRETS_ENDPOINT, e.g. a LocalStack URL; default the regional endpoint
+	Client          *http.Client
+
+	now func() time.Time
+}
+
+// NewAWSFromEnv configures Secrets Manager from the AWS_* environment variables.
+func NewAWSFromEnv() *AWS {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return &AWS{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        os.Getenv("CMP_AWS_SECRETS_ENDPOINT"),
+		Client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch reads the current version of a secret. A SecretString holding a
+// JSON object yields its keys; any other string is the single value.
+func (a *AWS) Fetch(ctx context.Context, path string) (Secret, error) {
+	if a.Region == "" || a.AccessKeyID == "" || a.SecretAccessKey == "" {
+		return Secret{}, fmt.Errorf("aws: AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + a.Region + ".amazonaws.com"
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": path})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return Secret{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	a.sign(req, body, now().UTC())
+	client := a.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Secret{}, fmt.Errorf("aws: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &e)
+		return Secret{}, fmt.Errorf("aws: %s: %s %s", resp.Status, e.Type, e.Message)
+	}
+	var out struct {
+		SecretString string `json:"SecretString"`
+		VersionID    string `json:"VersionId"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return Secret{}, fmt.Errorf("aws: %w", err)
+	}
+	s := Secret{Data: map[string]string{}, Version: out.VersionID}
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(out.SecretString), &obj) == nil {
+		for k, v := range obj {
+			s.Data[k] = stringValue(v)
+		}
+	} else {
+		s.Data[""] = out.SecretString
+	}
+	return s, nil
+}
+
+// sign adds a Signature Version 4 Authorization header for Secrets Manager.
+func (a *AWS) sign(req *http.Request, body []byte, t time.Time) {
+	const service = "secretsmanager"
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if a.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.SessionToken)
+	}
+	headers := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if a.SessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	sort.Strings(headers)
+	var canonHeaders strings.Builder
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signed := strings.Join(headers, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonHeaders.String(),
+		signed,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + a.Region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	key := hmacSHA256([]byte("AWS4"+a.SecretAccessKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+a.AccessKeyID+"/"+scope+", SignedHeaders="+signed+", Signature="+signature)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,17 @@
+// Package secrets resolves secret:// references in configuration files
+// against an external secret store, so API keys need not live in env files.
+//
+// A reference names a backend, the secret's path in it and optionally one
+// key of the secret:
+//
+//	secret://vault/secret/data/contexis#openai_api_key   HashiCorp Vault (KV v1 or v2)
+//	secret://aws/prod/contexis#openai_api_key            AWS Secrets Manager
+//	secret://sops/config/secrets.enc.yaml#openai.api_key SOPS-encrypted file
+//
+// Fetched secrets are cached for CMP_SECRETS_TTL (default 5m), or for the
+// Vault lease when it is shorter. An expired secret is fetched again, so a
+// rotated value is picked up without a restart; rotation hooks registered
+// with OnRotate run when the store reports a new version. When a refresh
+// fails, the last value keeps being served for up to CMP_SECRETS_MAX_STALE
+// (default 1h) so a short store outage does not take the server down.
+package secrets
@@ -0,0 +1,249 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheme prefixes every secret reference.
+const Scheme = "secret://"
+
+const (
+	// DefaultTTL is how long a fetched secret is used before it is fetched again.
+	DefaultTTL = 5 * time.Minute
+	// DefaultMaxStale is how long an expired secret is served while its store fails.
+	DefaultMaxStale = time.Hour
+	// errorTTL keeps a failing reference from hitting the store on every config load.
+	errorTTL = 30 * time.Second
+)
+
+var (
+	// ErrUnknownBackend is returned for references to a backend that is not registered.
+	ErrUnknownBackend = errors.New("unknown secrets backend")
+	// ErrKeyNotFound is returned when a secret has no value under the referenced key.
+	ErrKeyNotFound = errors.New("secret key not found")
+)
+
+// Secret is one secret read from a store. Single-value secrets use the key "".
+type Secret struct {
+	Data    map[string]string
+	Version string        // store version, used to detect rotation
+	TTL     time.Duration // lease reported by the store; 0 uses the resolver TTL
+}
+
+// Backend reads secrets from one store.
+type Backend interface {
+	Fetch(ctx context.Context, path string) (Secret, error)
+}
+
+// Ref is a parsed secret:// reference.
+type Ref struct {
+	Backend string
+	Path    string
+	Key     string // optional; selects one value of a multi-value secret
+}
+
+func (r Ref) String() string {
+	s := Scheme + r.Backend + "/" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// IsRef reports whether s is a secret reference.
+func IsRef(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// ParseRef parses secret://<backend>/<path>[#<key>].
+func ParseRef(s string) (Ref, error) {
+	if !IsRef(s) {
+		return Ref{}, fmt.Errorf("not a secret reference: %q", s)
+	}
+	rest, key, _ := strings.Cut(strings.TrimPrefix(s, Scheme), "#")
+	backend, path, _ := strings.Cut(rest, "/")
+	if backend == "" || path == "" {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: want secret://<backend>/<path>[#key]", s)
+	}
+	return Ref{Backend: backend, Path: path, Key: key}, nil
+}
+
+// RotateFunc is called when a refreshed secret has a new version.
+type RotateFunc func(backend, path, oldVersion, newVersion string)
+
+type entry struct {
+	secret  Secret
+	err     error
+	fetched time.Time
+	expires time.Time
+}
+
+// Resolver resolves references through its backends and caches the results.
+type Resolver struct {
+	TTL      time.Duration
+	MaxStale time.Duration
+
+	backends map[string]Backend
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]*entry
+	onRotate []RotateFunc
+}
+
+// NewResolver returns a resolver without backends.
+func NewResolver() *Resolver {
+	return &Resolver{
+		TTL:      DefaultTTL,
+		MaxStale: DefaultMaxStale,
+		backends: map[string]Backend{},
+		now:      time.Now,
+		cache:    map[string]*entry{},
+	}
+}
+
+// NewResolverFromEnv returns a resolver with the vault, aws and sops backends
+// and the CMP_SECRETS_TTL and CMP_SECRETS_MAX_STALE settings.
+func NewResolverFromEnv() *Resolver {
+	r := NewResolver()
+	if d, err := time.ParseDuration(os.Getenv("CMP_SECRETS_TTL")); err == nil && d >= 0 {
+		r.TTL = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("CMP_SECRETS_MAX_STALE")); err == nil && d >= 0 {
+		r.MaxStale = d
+	}
+	r.Register("vault", NewVaultFromEnv())
+	r.Register("aws", NewAWSFromEnv())
+	r.Register("sops", NewSOPSFromEnv())
+	return r
+}
+
+// Register adds or replaces the backend for name.
+func (r *Resolver) Register(name string, b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[name] = b
+}
+
+// OnRotate registers fn to run when a secret changes version on refresh.
+func (r *Resolver) OnRotate(fn RotateFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRotate = append(r.onRotate, fn)
+}
+
+// Resolve returns the value a reference points to.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	parsed, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	s, err := r.secret(ctx, parsed.Backend, parsed.Path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", parsed, err)
+	}
+	if parsed.Key == "" {
+		if v, ok := s.Data[""]; ok {
+			return v, nil
+		}
+		if len(s.Data) == 1 {
+			for _, v := range s.Data {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("%s: secret has keys %s; add #<key>", parsed, strings.Join(keys(s.Data), ", "))
+	}
+	v, ok := s.Data[parsed.Key]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", parsed, ErrKeyNotFound)
+	}
+	return v, nil
+}
+
+// secret returns the cached secret, fetching it when missing or expired.
+func (r *Resolver) secret(ctx context.Context, backend, path string) (Secret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.backends[backend]
+	if !ok {
+		return Secret{}, fmt.Errorf("%w %q", ErrUnknownBackend, backend)
+	}
+	id := backend + "/" + path
+	now := r.now()
+	e := r.cache[id]
+	if e != nil && now.Before(e.expires) {
+		return e.secret, e.err
+	}
+	s, err := b.Fetch(ctx, path)
+	if err != nil {
+		// Serve the last good value through a store outage
+		if e != nil && e.err == nil && now.Before(e.fetched.Add(r.ttl(e.secret)+r.MaxStale)) {
+			e.expires = now.Add(errorTTL)
+			return e.secret, nil
+		}
+		r.cache[id] = &entry{err: err, fetched: now, expires: now.Add(errorTTL)}
+		return Secret{}, err
+	}
+	if e != nil && e.err == nil && e.secret.Version != s.Version {
+		for _, fn := range r.onRotate {
+			fn(backend, path, e.secret.Version, s.Version)
+		}
+	}
+	r.cache[id] = &entry{secret: s, fetched: now, expires: now.Add(r.ttl(s))}
+	return s, nil
+}
+
+func (r *Resolver) ttl(s Secret) time.Duration {
+	if s.TTL > 0 && s.TTL < r.TTL {
+		return s.TTL
+	}
+	return r.TTL
+}
+
+// Invalidate drops every cached secret, so the next Resolve fetches again.
+// Call it after rotating a secret to pick up the new value at once.
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache = map[string]*entry{}
+}
+
+func keys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultResolver *Resolver
+)
+
+// Default returns the process-wide resolver, built from the environment on
+// first use. The config loader resolves secret:// values through it.
+func Default() *Resolver {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultResolver == nil {
+		defaultResolver = NewResolverFromEnv()
+	}
+	return defaultResolver
+}
+
+// SetDefault replaces the process-wide resolver; nil rebuilds it from the
+// environment on next use.
+func SetDefault(r *Resolver) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultResolver = r
+}
@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBackend serves a secret whose value and version tests change.
+type fakeBackend struct {
+	secret Secret
+	err    error
+	calls  int
+}
+
+func (f *fakeBackend) Fetch(ctx context.Context, path string) (Secret, error) {
+	f.calls++
+	return f.secret, f.err
+}
+
+func TestParseRef(t *testing.T) {
+	r, err := ParseRef("secret://vault/secret/data/app#api_key")
+	if err != nil || r.Backend != "vault" || r.Path != "secret/data/app" || r.Key != "api_key" {
+		t.Fatalf("unexpected %+v, %v", r, err)
+	}
+	if r.String() != "secret://vault/secret/data/app#api_key" {
+		t.Fatalf("round trip: %s", r)
+	}
+	for _, bad := range []string{"vault/x", "secret://vault", "secret:///x"} {
+		if _, err := ParseRef(bad); err == nil {
+			t.Errorf("ParseRef(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestResolver_CachesRefreshesAndServesStale(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &fakeBackend{secret: Secret{Data: map[string]string{"api_key": "v1", "org": "acme"}, Version: "1"}}
+	r := NewResolver()
+	r.now = func() time.Time { return clock }
+	r.TTL, r.MaxStale = time.Minute, 10*time.Minute
+	r.Register("fake", b)
+	var rotated []string
+	r.OnRotate(func(backend, path, oldVersion, newVersion string) {
+		rotated = append(rotated, path+":"+oldVersion+"->"+newVersion)
+	})
+	ctx := context.Background()
+
+	if v, err := r.Resolve(ctx, "secret://fake/app#api_key"); err != nil || v != "v1" {
+		t.Fatalf("resolve: %q %v", v, err)
+	}
+	if v, _ := r.Resolve(ctx, "secret://fake/app#org"); v != "acme" || b.calls != 1 {
+		t.Fatalf("expected a cached read, got %q after %d fetches", v, b.calls)
+	}
+	if _, err := r.Resolve(ctx, "secret://fake/app#missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("missing key: %v", err)
+	}
+	if _, err := r.Resolve(ctx, "secret://fake/app"); err == nil || !strings.Contains(err.Error(), "api_key, org") {
+		t.Fatalf("ambiguous secret: %v", err)
+	}
+
+	// Rotation is picked up once the TTL expires
+	b.secret = Secret{Data: map[string]string{"api_key": "v2"}, Version: "2"}
+	if v, _ := r.Resolve(ctx, "secret://fake/app#api_key"); v != "v1" {
+		t.Fatalf("rotated before expiry: %q", v)
+	}
+	clock = clock.Add(2 * time.Minute)
+	if v, _ := r.Resolve(ctx, "secret://fake/app"); v != "v2" || len(rotated) != 1 || rotated[0] != "app:1->2" {
+		t.Fatalf("after rotation: %q, hooks %v", v, rotated)
+	}
+
+	// A store outage serves the last value until MaxStale runs out
+	b.err = errors.New("connection refused")
+	clock = clock.Add(2 * time.Minute)
+	if v, err := r.Resolve(ctx, "secret://fake/app"); err != nil || v != "v2" {
+		t.Fatalf("stale read: %q %v", v, err)
+	}
+	clock = clock.Add(time.Hour)
+	if _, err := r.Resolve(ctx, "secret://fake/app"); err == nil {
+		t.Fatal("expected the outage to surface after MaxStale")
+	}
+	calls := b.calls
+	if _, err := r.Resolve(ctx, "secret://fake/app"); err == nil || b.calls != calls {
+		t.Fatalf("failures should be cached briefly: %d -> %d", calls, b.calls)
+	}
+
+	if _, err := r.Resolve(ctx, "secret://nope/app"); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("unknown backend: %v", err)
+	}
+}
+
+func TestVault_KVv2(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" || r.URL.Path != "/v1/secret/data/contexis" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"lease_duration":0,"data":{"data":{"openai_api_key":"sk-1","retries":3},"metadata":{"version":4}}}`))
+	}))
+	defer srv.Close()
+
+	v := &Vault{Addr: srv.URL, Token: "s.token"}
+	s, err := v.Fetch(context.Background(), "secret/data/contexis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Data["openai_api_key"] != "sk-1" || s.Data["retries"] != "3" || s.Version != "4" {
+		t.Fatalf("unexpected secret %+v", s)
+	}
+	v.Token = "wrong"
+	if _, err := v.Fetch(context.Background(), "secret/data/contexis"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected a permission error, got %v", err)
+	}
+}
+
+func TestAWS_GetSecretValue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20260101/eu-west-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"IncompleteSignatureException","message":"bad"}`))
+			return
+		}
+		var in map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in["SecretId"] == "prod/plain" {
+			_, _ = w.Write([]byte(`{"SecretString":"just-a-token","VersionId":"b"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString":"{\"api_key\":\"sk-aws\"}","VersionId":"a"}`))
+	}))
+	defer srv.Close()
+
+	a := &AWS{Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "session", Endpoint: srv.URL,
+		now: func() time.Time { return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC) }}
+	s, err := a.Fetch(context.Background(), "prod/contexis")
+	if err != nil || s.Data["api_key"] != "sk-aws" || s.Version != "a" {
+		t.Fatalf("json secret: %+v %v", s, err)
+	}
+	s, err = a.Fetch(context.Background(), "prod/plain")
+	if err != nil || s.Data[""] != "just-a-token" {
+		t.Fatalf("plain secret: %+v %v", s, err)
+	}
+	a.SessionToken = ""
+	if _, err := a.Fetch(context.Background(), "prod/contexis"); err == nil || !strings.Contains(err.Error(), "IncompleteSignatureException") {
+		t.Fatalf("expected the service error, got %v", err)
+	}
+}
+
+func TestSOPS_Decrypt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of sops")
+	}
+	root := t.TempDir()
+	bin := filepath.Join(root, "sops")
+	script := "#!/bin/sh\necho '{\"openai\":{\"api_key\":\"sk-sops\"},\"debug\":false}'\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secrets.enc.yaml"), []byte("openai:\n  api_key: ENC[AES256_GCM,data:...]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewResolver()
+	r.Register("sops", &SOPS{Bin: bin, Root: root})
+	v, err := r.Resolve(context.Background(), "secret://sops/secrets.enc.yaml#openai.api_key")
+	if err != nil || v != "sk-sops" {
+		t.Fatalf("resolve: %q %v", v, err)
+	}
+	if _, err := r.Resolve(context.Background(), "secret://sops/missing.enc.yaml#x"); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SOPS decrypts SOPS-encrypted YAML, JSON, dotenv or INI files with the sops
+// binary. Paths are relative to the project root; nested keys are joined
+// with dots (openai.api_key).
+type SOPS struct {
+	Bin  string // CMP_SOPS_BIN, default sops
+	Root string // CMP_PROJECT_ROOT, default the working directory
+}
+
+// NewSOPSFromEnv configures SOPS from CMP_SOPS_BIN and CMP_PROJECT_ROOT.
+func NewSOPSFromEnv() *SOPS {
+	return &SOPS{Bin: os.Getenv("CMP_SOPS_BIN"), Root: os.Getenv("CMP_PROJECT_ROOT")}
+}
+
+// Fetch decrypts the file. Its version is the digest of the encrypted
+// content, so re-encrypting a rotated value counts as a rotation.
+func (s *SOPS) Fetch(ctx context.Context, path string) (Secret, error) {
+	file := filepath.FromSlash(path)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(s.Root, file)
+	}
+	encrypted, err := os.ReadFile(file)
+	if err != nil {
+		return Secret{}, fmt.Errorf("sops: %w", err)
+	}
+	bin := s.Bin
+	if bin == "" {
+		bin = "sops"
+	}
+	cmd := exec.CommandContext(ctx, bin, "--decrypt", "--output-type", "json", file)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return Secret{}, fmt.Errorf("sops: decrypt %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		return Secret{}, fmt.Errorf("sops: %s: %w", path, err)
+	}
+	sum := sha256.Sum256(encrypted)
+	out := Secret{Data: map[string]string{}, Version: hex.EncodeToString(sum[:8])}
+	flatten("", doc, out.Data)
+	return out, nil
+}
+
+func flatten(prefix string, m map[string]interface{}, out map[string]string) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if inner, ok := v.(map[string]interface{}); ok {
+			flatten(k, inner, out)
+			continue
+		}
+		out[k] = stringValue(v)
+	}
+}
+
+// digest identifies the content of a secret that has no store version.
+func digest(data map[string]string) string {
+	h := sha256.New()
+	names := make([]string, 0, len(data))
+	for k := range data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(h, "%s=%s\n", k, data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
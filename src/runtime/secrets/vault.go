@@ -0,0 +1,97 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets over the Vault HTTP API. Paths are API paths below
+// /v1, e.g. secret/data/contexis for the KV v2 engine mounted at secret/.
+type Vault struct {
+	Addr      string // VAULT_ADDR
+	Token     string // VAULT_TOKEN
+	Namespace string // VAULT_NAMESPACE (Vault Enterprise)
+	Client    *http.Client
+}
+
+// NewVaultFromEnv configures Vault from VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE.
+func NewVaultFromEnv() *Vault {
+	return &Vault{
+		Addr:      os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch reads a KV v1 or v2 secret.
+func (v *Vault) Fetch(ctx context.Context, path string) (Secret, error) {
+	if v.Addr == "" || v.Token == "" {
+		return Secret{}, fmt.Errorf("vault: VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(v.Addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return Secret{}, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Secret{}, fmt.Errorf("vault: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return Secret{}, fmt.Errorf("vault: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return Secret{}, fmt.Errorf("vault: %w", err)
+	}
+	data, version := out.Data, ""
+	// KV v2 nests the values under data.data and the version under data.metadata
+	if inner, ok := out.Data["data"].(map[string]interface{}); ok {
+		if meta, ok := out.Data["metadata"].(map[string]interface{}); ok {
+			data = inner
+			version = fmt.Sprint(meta["version"])
+		}
+	}
+	s := Secret{Data: make(map[string]string, len(data)), Version: version, TTL: time.Duration(out.LeaseDuration) * time.Second}
+	for k, val := range data {
+		s.Data[k] = stringValue(val)
+	}
+	if s.Version == "" {
+		s.Version = digest(s.Data)
+	}
+	return s, nil
+}
+
+// stringValue renders a JSON value as a config string.
+func stringValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		by, _ := json.Marshal(t)
+		return string(by)
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -3,26 +3,47 @@ package security
 import (
     "context"
     "encoding/json"
+    "io"
     "os"
     "sync"
     "time"
 
     "github.com/contexis-cmp/contexis/src/cli/logger"
+    "go.uber.org/zap"
+)
+
+// AuditSchemaVersion identifies the AuditEvent record layout shared by all sinks.
+const AuditSchemaVersion = "contexis.audit/v1"
+
+// Audit decisions derived from AuditEvent.Result.
+const (
+    DecisionAllow = "allow"
+    DecisionDeny  = "deny"
 )
 
 // AuditEvent represents a compliance-grade audit record
 type AuditEvent struct {
+    Schema      string                 `json:"schema"`
     Timestamp   time.Time              `json:"timestamp"`
     RequestID   string                 `json:"request_id"`
     TenantID    string                 `json:"tenant_id"`
     ActorKeyID  string                 `json:"actor_key_id"`
+    Principal   *AuditPrincipal        `json:"principal,omitempty"`
     Action      string                 `json:"action"`
     Resource    string                 `json:"resource"`
     Result      string                 `json:"result"` // allowed|denied|error|success|failure
+    Decision    string                 `json:"decision,omitempty"` // allow|deny
     Reason      string                 `json:"reason,omitempty"`
     Attributes  map[string]interface{} `json:"attributes,omitempty"`
 }
 
+// AuditPrincipal is the authenticated caller an event is attributed to
+type AuditPrincipal struct {
+    KeyID    string   `json:"key_id"`
+    TenantID string   `json:"tenant_id,omitempty"`
+    Scopes   []string `json:"scopes,omitempty"`
+}
+
 // AuditSink writes audit events to durable storage
 type AuditSink interface {
     Write(AuditEvent) error
@@ -59,14 +80,54 @@ type Auditor struct {
 
 func NewAuditor(sink AuditSink) *Auditor { return &Auditor{sink: sink} }
 
+// Record completes ev with the request ID and principal from ctx, so every sink
+// receives the same schema, then logs and stores it.
 func (a *Auditor) Record(ctx context.Context, ev AuditEvent) {
-    // log via structured logger
+    ev = normalizeAuditEvent(ctx, ev)
     logger.WithContext(ctx).Info("audit",
-        // minimal fixed fields
+        zap.String("action", ev.Action),
+        zap.String("resource", ev.Resource),
+        zap.String("result", ev.Result),
+        zap.String("actor_key_id", ev.ActorKeyID),
     )
     if a.sink != nil {
-        _ = a.sink.Write(ev)
+        if err := a.sink.Write(ev); err != nil {
+            logger.WithContext(ctx).Warn("audit sink write failed", zap.Error(err))
+        }
     }
 }
 
+// Close flushes and releases the sink, if it holds resources.
+func (a *Auditor) Close() error {
+    if c, ok := a.sink.(io.Closer); ok {
+        return c.Close()
+    }
+    return nil
+}
 
+func normalizeAuditEvent(ctx context.Context, ev AuditEvent) AuditEvent {
+    ev.Schema = AuditSchemaVersion
+    if ev.Timestamp.IsZero() {
+        ev.Timestamp = time.Now()
+    }
+    if ev.RequestID == "" {
+        ev.RequestID, _ = ctx.Value("request_id").(string)
+    }
+    if p, ok := FromPrincipal(ctx); ok && p != nil {
+        if ev.Principal == nil {
+            ev.Principal = &AuditPrincipal{KeyID: p.KeyID, TenantID: p.TenantID, Scopes: p.Scopes}
+        }
+        if ev.ActorKeyID == "" {
+            ev.ActorKeyID = p.KeyID
+        }
+    }
+    if ev.Decision == "" {
+        switch ev.Result {
+        case "allowed", "success":
+            ev.Decision = DecisionAllow
+        case "denied", "blocked", "exceeded":
+            ev.Decision = DecisionDeny
+        }
+    }
+    return ev
+}
@@ -0,0 +1,225 @@
+package security
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// AuditQuery selects audit events. Empty fields match everything; Action may
+// end in "*" to match a prefix (e.g. "chat:*").
+type AuditQuery struct {
+    TenantID   string
+    Action     string
+    Resource   string
+    Result     string // matches Result or Decision, e.g. denied or deny
+    Reason     string
+    ActorKeyID string
+    RequestID  string
+    Since      time.Time
+    Until      time.Time
+}
+
+// Match reports whether e satisfies q.
+func (q AuditQuery) Match(e AuditEvent) bool {
+    switch {
+    case q.TenantID != "" && e.TenantID != q.TenantID,
+        q.Resource != "" && e.Resource != q.Resource,
+        q.Result != "" && e.Result != q.Result && e.Decision != q.Result,
+        q.Reason != "" && e.Reason != q.Reason,
+        q.ActorKeyID != "" && e.ActorKeyID != q.ActorKeyID,
+        q.RequestID != "" && e.RequestID != q.RequestID,
+        !q.Since.IsZero() && e.Timestamp.Before(q.Since),
+        !q.Until.IsZero() && !e.Timestamp.Before(q.Until):
+        return false
+    }
+    if prefix, ok := strings.CutSuffix(q.Action, "*"); ok {
+        return strings.HasPrefix(e.Action, prefix)
+    }
+    return q.Action == "" || e.Action == q.Action
+}
+
+// AuditLogFiles lists the files written by the file sinks of config/audit.yaml,
+// or the default audit log, each preceded by its rotated backups (oldest
+// first). Network sinks (syslog, webhook, kafka) cannot be read back.
+func AuditLogFiles(root string) ([]string, error) {
+    logs, err := auditLogs(root)
+    if err != nil {
+        return nil, err
+    }
+    var files []string
+    for _, path := range logs {
+        backups, _ := filepath.Glob(path + ".*")
+        sort.Strings(backups)
+        files = append(files, backups...)
+        files = append(files, path)
+    }
+    return files, nil
+}
+
+// auditLogs returns the current file of each file sink, resolved against root.
+func auditLogs(root string) ([]string, error) {
+    cfg, err := LoadAuditConfig(root)
+    if err != nil {
+        return nil, err
+    }
+    var logs []string
+    if cfg == nil || len(cfg.Sinks) == 0 {
+        logs = append(logs, DefaultAuditLog)
+    } else {
+        for _, sc := range cfg.Sinks {
+            if t := strings.ToLower(sc.Type); t != "file" && t != "" {
+                continue
+            }
+            path := sc.Path
+            if path == "" {
+                path = DefaultAuditLog
+            }
+            logs = append(logs, path)
+        }
+    }
+    for i, path := range logs {
+        if !filepath.IsAbs(path) {
+            logs[i] = filepath.Join(root, path)
+        }
+    }
+    return logs, nil
+}
+
+// ScanAuditLog calls fn for each event of a JSONL audit file. A missing file
+// has no events; lines from other writers are skipped.
+func ScanAuditLog(path string, fn func(AuditEvent)) error {
+    f, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    return scanAuditLines(f, fn)
+}
+
+func scanAuditLines(r io.Reader, fn func(AuditEvent)) error {
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+    for sc.Scan() {
+        var e AuditEvent
+        if json.Unmarshal(sc.Bytes(), &e) == nil {
+            fn(e)
+        }
+    }
+    return sc.Err()
+}
+
+// SearchAudit returns the events of the audit files under root that match q,
+// oldest first. With limit > 0 only the last limit matches are returned.
+func SearchAudit(root string, q AuditQuery, limit int) ([]AuditEvent, error) {
+    files, err := AuditLogFiles(root)
+    if err != nil {
+        return nil, err
+    }
+    out := []AuditEvent{}
+    for _, path := range files {
+        err := ScanAuditLog(path, func(e AuditEvent) {
+            if q.Match(e) {
+                out = append(out, e)
+            }
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    // Several sinks, or events written out of order, are merged by time
+    sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp.Before(out[j].Timestamp) })
+    if limit > 0 && len(out) > limit {
+        out = out[len(out)-limit:]
+    }
+    return out, nil
+}
+
+// FollowAudit calls fn for matching events appended to the audit files under
+// root from now on, checking every poll interval, until ctx is done. A file
+// that was rotated or truncated is read again from its start.
+func FollowAudit(ctx context.Context, root string, q AuditQuery, poll time.Duration, fn func(AuditEvent)) error {
+    logs, err := auditLogs(root)
+    if err != nil {
+        return err
+    }
+    tails := make([]auditTail, len(logs))
+    for i, path := range logs {
+        tails[i].path = path
+        if info, err := os.Stat(path); err == nil {
+            tails[i].info, tails[i].offset = info, info.Size()
+        }
+    }
+    ticker := time.NewTicker(poll)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-ticker.C:
+        }
+        for i := range tails {
+            err := tails[i].read(func(e AuditEvent) {
+                if q.Match(e) {
+                    fn(e)
+                }
+            })
+            if err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// auditTail is the read position in a followed audit file.
+type auditTail struct {
+    path   string
+    info   os.FileInfo
+    offset int64
+}
+
+// read passes the complete lines appended since the last read to fn.
+func (t *auditTail) read(fn func(AuditEvent)) error {
+    f, err := os.Open(t.path)
+    if os.IsNotExist(err) {
+        t.info, t.offset = nil, 0
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    info, err := f.Stat()
+    if err != nil {
+        return err
+    }
+    if t.info == nil || !os.SameFile(t.info, info) || info.Size() < t.offset {
+        // Rotated, recreated or truncated
+        t.offset = 0
+    }
+    t.info = info
+    if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+        return err
+    }
+    by, err := io.ReadAll(f)
+    if err != nil {
+        return err
+    }
+    // A partially written last line is read on the next poll
+    end := bytes.LastIndexByte(by, '\n') + 1
+    if err := scanAuditLines(bytes.NewReader(by[:end]), fn); err != nil {
+        return err
+    }
+    t.offset += int64(end)
+    return nil
+}
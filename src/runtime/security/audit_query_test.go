@@ -0,0 +1,93 @@
+package security
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestSearchAudit_FiltersAcrossRotatedFiles(t *testing.T) {
+    root := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(root, "config"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    cfg := "sinks:\n  - type: file\n    path: logs/audit.log\n    max_size_mb: 1\n  - type: webhook\n    url: http://siem.invalid\n"
+    if err := os.WriteFile(filepath.Join(root, AuditConfigFile), []byte(cfg), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(root, "logs", "audit.log")
+    s := NewRotatingFileSink(path, 400, 0, 0)
+    start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+    for i := 0; i < 6; i++ {
+        result := "allowed"
+        if i%2 == 1 {
+            result = "denied"
+        }
+        ev := AuditEvent{Timestamp: start.Add(time.Duration(i) * time.Hour), TenantID: "acme", Action: "chat:invoke", Result: result}
+        if i == 5 {
+            ev.TenantID, ev.Action = "beta", "keys:create"
+        }
+        if err := s.Write(ev); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s.Close()
+    if backups, _ := filepath.Glob(path + ".*"); len(backups) == 0 {
+        t.Fatal("expected the log to rotate")
+    }
+
+    got, err := SearchAudit(root, AuditQuery{TenantID: "acme", Result: "denied"}, 0)
+    if err != nil || len(got) != 2 || !got[0].Timestamp.Equal(start.Add(time.Hour)) {
+        t.Fatalf("SearchAudit = %+v, %v", got, err)
+    }
+    if got, _ := SearchAudit(root, AuditQuery{Action: "keys:*"}, 0); len(got) != 1 || got[0].TenantID != "beta" {
+        t.Fatalf("prefix match = %+v", got)
+    }
+    if got, _ := SearchAudit(root, AuditQuery{Since: start.Add(2 * time.Hour), Until: start.Add(4 * time.Hour)}, 0); len(got) != 2 {
+        t.Fatalf("time range = %+v", got)
+    }
+    if got, _ := SearchAudit(root, AuditQuery{}, 2); len(got) != 2 || got[1].Action != "keys:create" {
+        t.Fatalf("limit should keep the latest events, got %+v", got)
+    }
+}
+
+func TestFollowAudit_StreamsAppendedEvents(t *testing.T) {
+    root := t.TempDir()
+    sink := NewJSONFileSink(filepath.Join(root, DefaultAuditLog))
+    _ = sink.Write(AuditEvent{Action: "chat:invoke", Result: "denied"})
+
+    var mu sync.Mutex
+    var got []AuditEvent
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan error)
+    go func() {
+        done <- FollowAudit(ctx, root, AuditQuery{Result: "denied"}, 10*time.Millisecond, func(e AuditEvent) {
+            mu.Lock()
+            got = append(got, e)
+            mu.Unlock()
+        })
+    }()
+    time.Sleep(30 * time.Millisecond)
+    _ = sink.Write(AuditEvent{Action: "chat:invoke", Result: "allowed"})
+    _ = sink.Write(AuditEvent{Action: "keys:create", Result: "denied"})
+    deadline := time.Now().Add(2 * time.Second)
+    for {
+        mu.Lock()
+        n := len(got)
+        mu.Unlock()
+        if n > 0 || time.Now().After(deadline) {
+            break
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    cancel()
+    if err := <-done; err != nil {
+        t.Fatal(err)
+    }
+    if len(got) != 1 || got[0].Action != "keys:create" {
+        t.Fatalf("expected only the new denied event, got %+v", got)
+    }
+}
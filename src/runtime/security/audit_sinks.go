@@ -0,0 +1,587 @@
+package security
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "gopkg.in/yaml.v3"
+)
+
+// AuditConfigFile is the project-relative path of the audit sink configuration.
+const AuditConfigFile = "config/audit.yaml"
+
+// DefaultAuditLog is the sink used when no audit configuration exists.
+const DefaultAuditLog = "audit.log"
+
+// ErrAuditBufferFull is returned when a batching sink cannot keep up and drops an event.
+var ErrAuditBufferFull = errors.New("audit buffer full")
+
+var (
+    // AuditEventsDropped counts events lost per sink type because delivery failed
+    // after all retries or the buffer was full.
+    AuditEventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "cmp_audit_events_dropped_total",
+        Help: "Audit events that could not be delivered, by sink type.",
+    }, []string{"sink"})
+    // AuditDeliveryRetries counts failed delivery attempts that were retried.
+    AuditDeliveryRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "cmp_audit_delivery_retries_total",
+        Help: "Retried audit batch deliveries, by sink type.",
+    }, []string{"sink"})
+)
+
+// AuditConfig is the parsed config/audit.yaml. Every event is written to all sinks.
+//
+//  sinks:
+//    - type: file
+//      path: logs/audit.log
+//      max_size_mb: 100
+//      max_age: 24h
+//      max_backups: 14
+//    - type: webhook
+//      url: https://siem.example.com/ingest
+//      headers: {Authorization: "Bearer ${SIEM_TOKEN}"}
+type AuditConfig struct {
+    Sinks []AuditSinkConfig `yaml:"sinks"`
+}
+
+// AuditSinkConfig configures one sink. Which fields apply depends on Type.
+type AuditSinkConfig struct {
+    Type string `yaml:"type"` // file|syslog|webhook|kafka
+
+    // file: rotate when the file exceeds MaxSizeMB or is older than MaxAge,
+    // keeping at most MaxBackups rotated files (0 keeps all)
+    Path       string `yaml:"path"`
+    MaxSizeMB  int    `yaml:"max_size_mb"`
+    MaxAge     string `yaml:"max_age"`
+    MaxBackups int    `yaml:"max_backups"`
+
+    // syslog: RFC 5424 messages over udp, tcp or a unix socket
+    Network string `yaml:"network"`
+    Address string `yaml:"address"`
+    Tag     string `yaml:"tag"`
+
+    // webhook: JSON array of events POSTed to URL; kafka: records produced to
+    // Topic through a Kafka REST proxy at URL. Values may reference ${ENV} vars.
+    URL     string            `yaml:"url"`
+    Topic   string            `yaml:"topic"`
+    Headers map[string]string `yaml:"headers"`
+    Timeout string            `yaml:"timeout"`
+
+    // Delivery settings for network sinks
+    BatchSize     int    `yaml:"batch_size"`
+    FlushInterval string `yaml:"flush_interval"`
+    MaxRetries    int    `yaml:"max_retries"`
+    RetryBackoff  string `yaml:"retry_backoff"`
+    BufferSize    int    `yaml:"buffer_size"`
+}
+
+// LoadAuditConfig reads config/audit.yaml under root. It returns nil when the file does not exist.
+func LoadAuditConfig(root string) (*AuditConfig, error) {
+    by, err := os.ReadFile(filepath.Join(root, AuditConfigFile))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var cfg AuditConfig
+    if err := yaml.Unmarshal(by, &cfg); err != nil {
+        return nil, fmt.Errorf("parse %s: %w", AuditConfigFile, err)
+    }
+    return &cfg, nil
+}
+
+// NewAuditSinkFromConfig builds the sinks described by cfg. Relative file paths
+// are resolved against root. Without configuration events go to DefaultAuditLog.
+func NewAuditSinkFromConfig(root string, cfg *AuditConfig) (AuditSink, error) {
+    if cfg == nil || len(cfg.Sinks) == 0 {
+        return NewJSONFileSink(DefaultAuditLog), nil
+    }
+    var sinks MultiSink
+    for i, sc := range cfg.Sinks {
+        sink, err := newAuditSink(root, sc)
+        if err != nil {
+            sinks.Close()
+            return nil, fmt.Errorf("audit sink %d (%s): %w", i, sc.Type, err)
+        }
+        sinks = append(sinks, sink)
+    }
+    if len(sinks) == 1 {
+        return sinks[0], nil
+    }
+    return sinks, nil
+}
+
+func newAuditSink(root string, sc AuditSinkConfig) (AuditSink, error) {
+    timeout, err := parseAuditDuration(sc.Timeout, 10*time.Second)
+    if err != nil {
+        return nil, err
+    }
+    var inner BatchWriter
+    switch strings.ToLower(sc.Type) {
+    case "file", "":
+        path := sc.Path
+        if path == "" {
+            path = DefaultAuditLog
+        }
+        if !filepath.IsAbs(path) {
+            path = filepath.Join(root, path)
+        }
+        maxAge, err := parseAuditDuration(sc.MaxAge, 0)
+        if err != nil {
+            return nil, err
+        }
+        return NewRotatingFileSink(path, int64(sc.MaxSizeMB)<<20, maxAge, sc.MaxBackups), nil
+    case "syslog":
+        if sc.Address == "" && sc.Network != "unix" {
+            return nil, errors.New("address is required")
+        }
+        inner = &SyslogSink{Network: sc.Network, Address: sc.Address, Tag: sc.Tag, Timeout: timeout}
+    case "webhook", "http":
+        if sc.URL == "" {
+            return nil, errors.New("url is required")
+        }
+        inner = &WebhookSink{URL: os.ExpandEnv(sc.URL), Headers: expandHeaders(sc.Headers), Client: &http.Client{Timeout: timeout}}
+    case "kafka":
+        if sc.URL == "" || sc.Topic == "" {
+            return nil, errors.New("url (Kafka REST proxy) and topic are required")
+        }
+        inner = &KafkaRESTSink{URL: os.ExpandEnv(sc.URL), Topic: sc.Topic, Headers: expandHeaders(sc.Headers), Client: &http.Client{Timeout: timeout}}
+    default:
+        return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+    }
+    opts := BatchOptions{Name: strings.ToLower(sc.Type), BatchSize: sc.BatchSize, MaxRetries: sc.MaxRetries, BufferSize: sc.BufferSize}
+    if opts.FlushInterval, err = parseAuditDuration(sc.FlushInterval, 0); err != nil {
+        return nil, err
+    }
+    if opts.RetryBackoff, err = parseAuditDuration(sc.RetryBackoff, 0); err != nil {
+        return nil, err
+    }
+    return NewBatchingSink(inner, opts), nil
+}
+
+func parseAuditDuration(s string, def time.Duration) (time.Duration, error) {
+    if s == "" {
+        return def, nil
+    }
+    d, err := time.ParseDuration(s)
+    if err != nil {
+        return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+    }
+    return d, nil
+}
+
+func expandHeaders(h map[string]string) map[string]string {
+    out := make(map[string]string, len(h))
+    for k, v := range h {
+        out[k] = os.ExpandEnv(v)
+    }
+    return out
+}
+
+// MultiSink writes every event to each of its sinks.
+type MultiSink []AuditSink
+
+func (m MultiSink) Write(e AuditEvent) error {
+    var errs []error
+    for _, s := range m {
+        if err := s.Write(e); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// Close closes every sink that holds resources.
+func (m MultiSink) Close() error {
+    var errs []error
+    for _, s := range m {
+        if c, ok := s.(io.Closer); ok {
+            errs = append(errs, c.Close())
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// RotatingFileSink appends events to a JSONL file and rotates it by size and age.
+// Rotated files are renamed to <path>.<UTC timestamp>.
+type RotatingFileSink struct {
+    mu         sync.Mutex
+    path       string
+    maxSize    int64
+    maxAge     time.Duration
+    maxBackups int
+
+    f      *os.File
+    size   int64
+    opened time.Time
+    now    func() time.Time
+}
+
+// NewRotatingFileSink returns a file sink. A zero maxSize or maxAge disables that trigger.
+func NewRotatingFileSink(path string, maxSize int64, maxAge time.Duration, maxBackups int) *RotatingFileSink {
+    return &RotatingFileSink{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+}
+
+func (s *RotatingFileSink) Write(e AuditEvent) error {
+    by, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    by = append(by, '\n')
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.f == nil {
+        if err := s.open(); err != nil {
+            return err
+        }
+    }
+    if s.size > 0 && ((s.maxSize > 0 && s.size+int64(len(by)) > s.maxSize) || (s.maxAge > 0 && s.now().Sub(s.opened) >= s.maxAge)) {
+        if err := s.rotate(); err != nil {
+            return err
+        }
+    }
+    n, err := s.f.Write(by)
+    s.size += int64(n)
+    return err
+}
+
+// Close closes the current file.
+func (s *RotatingFileSink) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.f == nil {
+        return nil
+    }
+    err := s.f.Close()
+    s.f = nil
+    return err
+}
+
+func (s *RotatingFileSink) open() error {
+    if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+        return err
+    }
+    f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil {
+        return err
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+    s.f, s.size, s.opened = f, info.Size(), s.now()
+    if info.Size() > 0 {
+        // Age an existing file from its last write so restarts don't postpone rotation forever
+        s.opened = info.ModTime()
+    }
+    return nil
+}
+
+func (s *RotatingFileSink) rotate() error {
+    if err := s.f.Close(); err != nil {
+        return err
+    }
+    s.f = nil
+    backup := s.path + "." + s.now().UTC().Format("20060102T150405.000000000")
+    if err := os.Rename(s.path, backup); err != nil {
+        return err
+    }
+    if s.maxBackups > 0 {
+        old, _ := filepath.Glob(s.path + ".*")
+        sort.Strings(old)
+        for len(old) > s.maxBackups {
+            _ = os.Remove(old[0])
+            old = old[1:]
+        }
+    }
+    return s.open()
+}
+
+// BatchWriter delivers a batch of events in one call. Network sinks implement it
+// and are wrapped in a BatchingSink.
+type BatchWriter interface {
+    WriteBatch(ctx context.Context, events []AuditEvent) error
+}
+
+// BatchOptions tunes a BatchingSink; zero values use the defaults noted.
+type BatchOptions struct {
+    Name          string        // sink type, used as the metrics label
+    BatchSize     int           // events per delivery (100)
+    FlushInterval time.Duration // max time an event waits in the buffer (1s)
+    MaxRetries    int           // retries per batch after the first attempt (3; negative disables)
+    RetryBackoff  time.Duration // initial backoff, doubled per retry (500ms)
+    BufferSize    int           // queued events before new ones are dropped (10000)
+}
+
+// BatchingSink queues events and delivers them in batches from a background
+// goroutine, retrying failed batches with exponential backoff. Write never
+// blocks request handling; it drops the event when the buffer is full.
+type BatchingSink struct {
+    inner BatchWriter
+    opts  BatchOptions
+    queue chan AuditEvent
+    stop  chan struct{}
+    done  chan struct{}
+    once  sync.Once
+}
+
+// NewBatchingSink starts delivering events written to the returned sink to inner.
+func NewBatchingSink(inner BatchWriter, opts BatchOptions) *BatchingSink {
+    if opts.BatchSize <= 0 {
+        opts.BatchSize = 100
+    }
+    if opts.FlushInterval <= 0 {
+        opts.FlushInterval = time.Second
+    }
+    if opts.MaxRetries < 0 {
+        opts.MaxRetries = 0
+    } else if opts.MaxRetries == 0 {
+        opts.MaxRetries = 3
+    }
+    if opts.RetryBackoff <= 0 {
+        opts.RetryBackoff = 500 * time.Millisecond
+    }
+    if opts.BufferSize <= 0 {
+        opts.BufferSize = 10000
+    }
+    s := &BatchingSink{inner: inner, opts: opts, queue: make(chan AuditEvent, opts.BufferSize), stop: make(chan struct{}), done: make(chan struct{})}
+    go s.run()
+    return s
+}
+
+func (s *BatchingSink) Write(e AuditEvent) error {
+    select {
+    case <-s.stop:
+        return errors.New("audit sink closed")
+    default:
+    }
+    select {
+    case s.queue <- e:
+        return nil
+    default:
+        AuditEventsDropped.WithLabelValues(s.opts.Name).Inc()
+        return ErrAuditBufferFull
+    }
+}
+
+// Close flushes queued events, including their retries, and stops the delivery goroutine.
+func (s *BatchingSink) Close() error {
+    s.once.Do(func() { close(s.stop) })
+    <-s.done
+    if c, ok := s.inner.(io.Closer); ok {
+        return c.Close()
+    }
+    return nil
+}
+
+func (s *BatchingSink) run() {
+    defer close(s.done)
+    ticker := time.NewTicker(s.opts.FlushInterval)
+    defer ticker.Stop()
+    batch := make([]AuditEvent, 0, s.opts.BatchSize)
+    flush := func() {
+        if len(batch) > 0 {
+            s.deliver(batch)
+            batch = make([]AuditEvent, 0, s.opts.BatchSize)
+        }
+    }
+    for {
+        select {
+        case e := <-s.queue:
+            batch = append(batch, e)
+            if len(batch) >= s.opts.BatchSize {
+                flush()
+            }
+        case <-ticker.C:
+            flush()
+        case <-s.stop:
+            for {
+                select {
+                case e := <-s.queue:
+                    batch = append(batch, e)
+                    if len(batch) >= s.opts.BatchSize {
+                        flush()
+                    }
+                default:
+                    flush()
+                    return
+                }
+            }
+        }
+    }
+}
+
+func (s *BatchingSink) deliver(batch []AuditEvent) {
+    backoff := s.opts.RetryBackoff
+    for attempt := 0; ; attempt++ {
+        if err := s.inner.WriteBatch(context.Background(), batch); err == nil {
+            return
+        }
+        if attempt >= s.opts.MaxRetries {
+            AuditEventsDropped.WithLabelValues(s.opts.Name).Add(float64(len(batch)))
+            return
+        }
+        AuditDeliveryRetries.WithLabelValues(s.opts.Name).Inc()
+        time.Sleep(backoff)
+        backoff *= 2
+    }
+}
+
+// WebhookSink POSTs batches as a JSON array of events.
+type WebhookSink struct {
+    URL     string
+    Headers map[string]string
+    Client  *http.Client
+}
+
+func (s *WebhookSink) WriteBatch(ctx context.Context, events []AuditEvent) error {
+    by, err := json.Marshal(events)
+    if err != nil {
+        return err
+    }
+    return postAudit(ctx, s.Client, s.URL, "application/json", s.Headers, by)
+}
+
+// KafkaRESTSink produces batches to a topic through a Kafka REST proxy (v2 API),
+// keyed by tenant so a tenant's events stay ordered within a partition.
+type KafkaRESTSink struct {
+    URL     string
+    Topic   string
+    Headers map[string]string
+    Client  *http.Client
+}
+
+type kafkaRecord struct {
+    Key   string     `json:"key,omitempty"`
+    Value AuditEvent `json:"value"`
+}
+
+func (s *KafkaRESTSink) WriteBatch(ctx context.Context, events []AuditEvent) error {
+    records := make([]kafkaRecord, len(events))
+    for i, e := range events {
+        records[i] = kafkaRecord{Key: e.TenantID, Value: e}
+    }
+    by, err := json.Marshal(map[string]interface{}{"records": records})
+    if err != nil {
+        return err
+    }
+    url := strings.TrimRight(s.URL, "/") + "/topics/" + s.Topic
+    return postAudit(ctx, s.Client, url, "application/vnd.kafka.json.v2+json", s.Headers, by)
+}
+
+func postAudit(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+    if client == nil {
+        client = http.DefaultClient
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", contentType)
+    for k, v := range headers {
+        req.Header.Set(k, v)
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    _, _ = io.Copy(io.Discard, resp.Body)
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("audit endpoint returned %s", resp.Status)
+    }
+    return nil
+}
+
+// SyslogSink sends RFC 5424 messages (facility local0) whose message body is the
+// JSON event. TCP connections use octet-counting framing (RFC 6587).
+type SyslogSink struct {
+    Network string // udp (default), tcp or unix
+    Address string
+    Tag     string
+    Timeout time.Duration
+
+    mu   sync.Mutex
+    conn net.Conn
+}
+
+func (s *SyslogSink) WriteBatch(ctx context.Context, events []AuditEvent) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.conn == nil {
+        network := s.Network
+        if network == "" {
+            network = "udp"
+        }
+        d := net.Dialer{Timeout: s.Timeout}
+        conn, err := d.DialContext(ctx, network, s.Address)
+        if err != nil {
+            return err
+        }
+        s.conn = conn
+    }
+    host, _ := os.Hostname()
+    tag := s.Tag
+    if tag == "" {
+        tag = "contexis"
+    }
+    for _, e := range events {
+        by, err := json.Marshal(e)
+        if err != nil {
+            return err
+        }
+        severity := 6 // informational
+        if e.Decision == DecisionDeny || e.Result == "error" || e.Result == "failure" {
+            severity = 4 // warning
+        }
+        msg := fmt.Sprintf("<%d>1 %s %s %s %d audit - %s", 16*8+severity, e.Timestamp.UTC().Format(time.RFC3339Nano), orNil(host), tag, os.Getpid(), by)
+        if s.Network == "tcp" {
+            msg = fmt.Sprintf("%d %s", len(msg), msg)
+        }
+        if s.Timeout > 0 {
+            _ = s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+        }
+        if _, err := io.WriteString(s.conn, msg); err != nil {
+            // Reconnect on the next attempt
+            s.conn.Close()
+            s.conn = nil
+            return err
+        }
+    }
+    return nil
+}
+
+// Close closes the syslog connection.
+func (s *SyslogSink) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.conn == nil {
+        return nil
+    }
+    err := s.conn.Close()
+    s.conn = nil
+    return err
+}
+
+func orNil(s string) string {
+    if s == "" {
+        return "-"
+    }
+    return s
+}
@@ -0,0 +1,184 @@
+package security
+
+import (
+    "context"
+    "encoding/json"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestAuditor_NormalizesSchema(t *testing.T) {
+    var got []AuditEvent
+    a := NewAuditor(sinkFunc(func(e AuditEvent) error { got = append(got, e); return nil }))
+    ctx := context.WithValue(context.Background(), "request_id", "req-1")
+    ctx = WithPrincipal(ctx, &Principal{KeyID: "k1", TenantID: "acme", Scopes: []string{"chat:execute"}})
+    a.Record(ctx, AuditEvent{Action: "chat", Resource: "chat", Result: "denied"})
+    e := got[0]
+    if e.Schema != AuditSchemaVersion || e.RequestID != "req-1" || e.ActorKeyID != "k1" || e.Decision != DecisionDeny || e.Timestamp.IsZero() {
+        t.Fatalf("event not normalized: %+v", e)
+    }
+    if e.Principal == nil || e.Principal.TenantID != "acme" || len(e.Principal.Scopes) != 1 {
+        t.Fatalf("principal missing: %+v", e.Principal)
+    }
+}
+
+func TestRotatingFileSink_RotatesBySizeAndPrunes(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "logs", "audit.log")
+    s := NewRotatingFileSink(path, 300, 0, 2)
+    clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+    s.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+    for i := 0; i < 10; i++ {
+        if err := s.Write(AuditEvent{Action: "chat", Result: "allowed"}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    s.Close()
+    backups, _ := filepath.Glob(path + ".*")
+    if len(backups) != 2 {
+        t.Fatalf("expected 2 rotated files, got %v", backups)
+    }
+    for _, f := range append(backups, path) {
+        info, err := os.Stat(f)
+        if err != nil || info.Size() > 300 {
+            t.Fatalf("%s: size %d, err %v", f, info.Size(), err)
+        }
+    }
+}
+
+func TestRotatingFileSink_RotatesByAge(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "audit.log")
+    s := NewRotatingFileSink(path, 0, time.Hour, 0)
+    clock := time.Now()
+    s.now = func() time.Time { return clock }
+    _ = s.Write(AuditEvent{Action: "a"})
+    clock = clock.Add(2 * time.Hour)
+    _ = s.Write(AuditEvent{Action: "b"})
+    s.Close()
+    if backups, _ := filepath.Glob(path + ".*"); len(backups) != 1 {
+        t.Fatalf("expected one rotation, got %v", backups)
+    }
+}
+
+func TestBatchingSink_WebhookBatchesAndRetries(t *testing.T) {
+    var (
+        mu      sync.Mutex
+        calls   int
+        batches [][]AuditEvent
+    )
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        defer mu.Unlock()
+        calls++
+        if calls == 1 {
+            http.Error(w, "unavailable", http.StatusServiceUnavailable)
+            return
+        }
+        if r.Header.Get("Authorization") != "Bearer s3cret" {
+            t.Errorf("missing header: %v", r.Header)
+        }
+        var batch []AuditEvent
+        _ = json.NewDecoder(r.Body).Decode(&batch)
+        batches = append(batches, batch)
+    }))
+    defer srv.Close()
+    t.Setenv("SIEM_TOKEN", "s3cret")
+
+    sink, err := newAuditSink("", AuditSinkConfig{Type: "webhook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer ${SIEM_TOKEN}"},
+        BatchSize: 3, FlushInterval: "1h", RetryBackoff: "1ms"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 4; i++ {
+        _ = sink.Write(AuditEvent{Action: "chat", RequestID: string(rune('a' + i))})
+    }
+    // Close flushes the partial last batch
+    if err := sink.(*BatchingSink).Close(); err != nil {
+        t.Fatal(err)
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if calls != 3 || len(batches) != 2 || len(batches[0]) != 3 || len(batches[1]) != 1 {
+        t.Fatalf("calls=%d batches=%v", calls, batches)
+    }
+}
+
+func TestKafkaRESTSink_ProducesKeyedRecords(t *testing.T) {
+    var body map[string][]map[string]interface{}
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/topics/audit" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+            t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+        }
+        _ = json.NewDecoder(r.Body).Decode(&body)
+    }))
+    defer srv.Close()
+    k := &KafkaRESTSink{URL: srv.URL + "/", Topic: "audit"}
+    if err := k.WriteBatch(context.Background(), []AuditEvent{{TenantID: "acme", Action: "chat"}}); err != nil {
+        t.Fatal(err)
+    }
+    rec := body["records"]
+    if len(rec) != 1 || rec[0]["key"] != "acme" || rec[0]["value"].(map[string]interface{})["action"] != "chat" {
+        t.Fatalf("unexpected records %v", body)
+    }
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+    pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Skip("udp unavailable:", err)
+    }
+    defer pc.Close()
+    s := &SyslogSink{Address: pc.LocalAddr().String(), Tag: "ctx"}
+    defer s.Close()
+    if err := s.WriteBatch(context.Background(), []AuditEvent{{Action: "chat", Decision: DecisionDeny, Timestamp: time.Now()}}); err != nil {
+        t.Fatal(err)
+    }
+    buf := make([]byte, 4096)
+    _ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+    n, _, err := pc.ReadFrom(buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    msg := string(buf[:n])
+    if !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, " ctx ") || !strings.Contains(msg, `"action":"chat"`) {
+        t.Fatalf("unexpected syslog message %q", msg)
+    }
+}
+
+func TestLoadAuditConfig(t *testing.T) {
+    root := t.TempDir()
+    if cfg, err := LoadAuditConfig(root); cfg != nil || err != nil {
+        t.Fatalf("missing config = %v, %v", cfg, err)
+    }
+    _ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+    _ = os.WriteFile(filepath.Join(root, AuditConfigFile), []byte("sinks:\n  - type: file\n    path: logs/audit.log\n  - type: kafka\n    url: http://localhost:8082\n    topic: audit\n"), 0o644)
+    cfg, err := LoadAuditConfig(root)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sink, err := NewAuditSinkFromConfig(root, cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    multi, ok := sink.(MultiSink)
+    if !ok || len(multi) != 2 {
+        t.Fatalf("expected two sinks, got %T", sink)
+    }
+    if f := multi[0].(*RotatingFileSink); f.path != filepath.Join(root, "logs", "audit.log") {
+        t.Fatalf("file path not resolved against root: %s", f.path)
+    }
+    multi.Close()
+    if _, err := NewAuditSinkFromConfig(root, &AuditConfig{Sinks: []AuditSinkConfig{{Type: "kafka"}}}); err == nil {
+        t.Fatal("expected error for kafka sink without topic")
+    }
+}
+
+type sinkFunc func(AuditEvent) error
+
+func (f sinkFunc) Write(e AuditEvent) error { return f(e) }
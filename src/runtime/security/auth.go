@@ -10,6 +10,7 @@ import (
     "os"
     "strings"
     "sync"
+    "time"
 )
 
 // Principal represents an authenticated caller
@@ -17,6 +18,7 @@ type Principal struct {
     KeyID    string
     TenantID string
     Scopes   []string
+    Roles    []string // role names granted to the key or token, if any
 }
 
 // APIKey represents an API key entry in the keystore
@@ -26,6 +28,17 @@ type APIKey struct {
     TenantID  string   `json:"tenant_id"`  // associated tenant
     Scopes    []string `json:"scopes"`     // permissions like "chat:invoke", "context:read"
     RateLimit int      `json:"rate_limit"` // requests per minute (optional)
+
+    // Lifecycle fields of keys managed by KeyManager
+    Name              string     `json:"name,omitempty"`
+    Prefix            string     `json:"prefix,omitempty"` // leading characters of the secret, for identification
+    Roles             []string   `json:"roles,omitempty"`  // see Roles
+    CreatedAt         time.Time  `json:"created_at,omitempty"`
+    ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+    RotatedAt         *time.Time `json:"rotated_at,omitempty"`
+    RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+    PreviousHash      string     `json:"previous_hash,omitempty"` // secret before the last rotation
+    PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
 }
 
 // APIKeyStore provides lookup for API keys
@@ -118,25 +131,64 @@ func splitScopes(s string) []string {
 
 // Authenticate extracts the Bearer token and verifies it against the store
 func (s *APIKeyStore) Authenticate(r *http.Request) (*Principal, error) {
+    token, err := bearerToken(r)
+    if err != nil {
+        return nil, err
+    }
+    h := sha256Sum(token)
+    s.mu.RLock()
+    key, ok := s.byH[h]
+    s.mu.RUnlock()
+    if !ok {
+        return nil, errors.New("invalid token")
+    }
+    return &Principal{KeyID: key.KeyID, TenantID: key.TenantID, Scopes: key.Scopes, Roles: key.Roles}, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, error) {
     auth := r.Header.Get("Authorization")
     if auth == "" {
-        return nil, errors.New("missing Authorization header")
+        return "", errors.New("missing Authorization header")
     }
     if !strings.HasPrefix(strings.ToLower(auth), "bearer ") {
-        return nil, errors.New("unsupported auth scheme")
+        return "", errors.New("unsupported auth scheme")
     }
     token := strings.TrimSpace(auth[len("Bearer "):])
     if token == "" {
-        return nil, errors.New("empty bearer token")
+        return "", errors.New("empty bearer token")
     }
-    h := sha256Sum(token)
-    s.mu.RLock()
-    key, ok := s.byH[h]
-    s.mu.RUnlock()
-    if !ok {
-        return nil, errors.New("invalid token")
+    return token, nil
+}
+
+// Authenticator resolves the caller of an HTTP request to a Principal
+type Authenticator interface {
+    Authenticate(r *http.Request) (*Principal, error)
+}
+
+// NewAuthenticatorFromEnv selects the authenticator via CMP_AUTH_MODE:
+// "apikey" (default) uses NewAPIKeyStoreFromEnv, "oidc" uses NewOIDCAuthenticatorFromEnv
+func NewAuthenticatorFromEnv() (Authenticator, error) {
+    switch strings.ToLower(strings.TrimSpace(os.Getenv("CMP_AUTH_MODE"))) {
+    case "", "apikey":
+        return NewAPIKeyStoreFromEnv(), nil
+    case "oidc":
+        return NewOIDCAuthenticatorFromEnv()
+    default:
+        return nil, errors.New("unsupported CMP_AUTH_MODE: " + os.Getenv("CMP_AUTH_MODE"))
+    }
+}
+
+// DenyAllAuthenticator rejects every request; used when auth is misconfigured
+type DenyAllAuthenticator struct {
+    Err error
+}
+
+func (d DenyAllAuthenticator) Authenticate(*http.Request) (*Principal, error) {
+    if d.Err != nil {
+        return nil, d.Err
     }
-    return &Principal{KeyID: key.KeyID, TenantID: key.TenantID, Scopes: key.Scopes}, nil
+    return nil, errors.New("authentication unavailable")
 }
 
 type principalKey struct{}
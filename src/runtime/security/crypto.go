@@ -22,21 +22,11 @@ type EnvKeyProvider struct{}
 func (EnvKeyProvider) GetKey(tenantID string) ([]byte, error) {
     // For demo/dev: single key for all tenants; production should use KMS per tenant
     k := os.Getenv("CMP_EPISODIC_KEY")
-    if len(k) == 32 {
-        return []byte(k), nil
-    }
     if k == "" {
         return nil, errors.New("CMP_EPISODIC_KEY not set")
     }
     // If key length is not 32, derive a 32-byte key by hashing
-    h := sha256Sum(k)
-    // take first 32 bytes from hex-decoded hash
-    // h is hex, length 64; convert to bytes: naive approach here for brevity
-    // we can just use the hex string bytes but ensure 32 bytes
-    if len(h) < 32 {
-        return nil, errors.New("derived key too short")
-    }
-    return []byte(h[:32]), nil
+    return deriveKey(k), nil
 }
 
 // EncryptGCM encrypts plaintext with AES-GCM using the provided key
@@ -0,0 +1,175 @@
+package security
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "os"
+    "os/exec"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// InjectionSignal is a named heuristic with the score it contributes when matched
+type InjectionSignal struct {
+    Name   string
+    Weight float64
+    re     *regexp.Regexp
+}
+
+// defaultInjectionSignals are weighted so that no single heuristic reaches the
+// default threshold of 0.7. Two of the core heuristics (0.5 each) combine to 0.75
+// and cross it, like RiskHigh in ClassifyPromptRisk; the weaker signals (0.3-0.4)
+// need a core heuristic or a third match, e.g. 0.4 and 0.3 combine to 0.58.
+var defaultInjectionSignals = []InjectionSignal{
+    {Name: "ignore_previous", Weight: 0.5, re: reIgnorePrev},
+    {Name: "reveal_system", Weight: 0.5, re: reRevealSystem},
+    {Name: "authority_spoof", Weight: 0.5, re: reAsAdmin},
+    {Name: "change_rules", Weight: 0.5, re: reChangeRules},
+    {Name: "data_exfil", Weight: 0.5, re: reDataExfil},
+    {Name: "role_override", Weight: 0.4, re: regexp.MustCompile(`(?i)(you\s+are\s+now|pretend\s+to\s+be|act\s+as)\s+(an?\s+)?(unrestricted|jailbroken|dan|developer\s+mode)`)},
+    {Name: "fake_delimiter", Weight: 0.4, re: regexp.MustCompile(`(?i)(<\|?(system|im_start)\|?>|\[/?inst\]|###\s*system\s*:)`)},
+    {Name: "new_instructions", Weight: 0.3, re: regexp.MustCompile(`(?i)(new|updated)\s+instructions\s*:`)},
+}
+
+// denyListWeight is the score contribution of a deny-list phrase match
+const denyListWeight = 0.9
+
+// InjectionClassifier scores text with an external (usually model-based) classifier.
+// Scores are in [0,1]; errors are treated as "no opinion".
+type InjectionClassifier interface {
+    Score(ctx context.Context, text string) (float64, error)
+}
+
+// CommandInjectionClassifier runs an external classifier process. The process
+// receives {"text": "..."} on stdin and must print {"score": 0.0-1.0}.
+type CommandInjectionClassifier struct {
+    Command string
+    Args    []string
+    Timeout time.Duration
+}
+
+func (c CommandInjectionClassifier) Score(ctx context.Context, text string) (float64, error) {
+    timeout := c.Timeout
+    if timeout <= 0 {
+        timeout = 5 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+    payload, _ := json.Marshal(map[string]string{"text": text})
+    cmd := exec.CommandContext(ctx, c.Command, c.Args...)
+    cmd.Stdin = bytes.NewReader(payload)
+    out, err := cmd.Output()
+    if err != nil {
+        return 0, err
+    }
+    var resp struct {
+        Score float64 `json:"score"`
+    }
+    if err := json.Unmarshal(out, &resp); err != nil {
+        return 0, err
+    }
+    if resp.Score < 0 {
+        resp.Score = 0
+    }
+    if resp.Score > 1 {
+        resp.Score = 1
+    }
+    return resp.Score, nil
+}
+
+// InjectionResult is the analyzer verdict for a piece of text
+type InjectionResult struct {
+    Score   float64
+    Signals []string
+    // Detected is true when Score reaches the analyzer threshold
+    Detected bool
+    // Blocked is true when Detected and the analyzer mode is "block"
+    Blocked bool
+}
+
+// InjectionAnalyzer scores text for prompt-injection risk using heuristics, a
+// deny-list and an optional classifier. Mode is "block" or "flag".
+type InjectionAnalyzer struct {
+    Mode       string
+    Threshold  float64
+    Signals    []InjectionSignal
+    DenyList   []string
+    Classifier InjectionClassifier
+}
+
+// NewInjectionAnalyzerFromEnv configures an analyzer from:
+// CMP_PI_MODE: block|flag (default block)
+// CMP_PI_THRESHOLD: score in (0,1] at which input is treated as an injection (default 0.7)
+// CMP_PI_DENYLIST: comma-separated phrases that are always suspicious
+// CMP_PI_DENYLIST_FILE: file with one deny-list phrase per line ('#' comments allowed)
+// CMP_PI_CLASSIFIER_COMMAND: optional external classifier command line
+func NewInjectionAnalyzerFromEnv() *InjectionAnalyzer {
+    a := &InjectionAnalyzer{Mode: "block", Threshold: 0.7, Signals: defaultInjectionSignals}
+    if v := strings.ToLower(os.Getenv("CMP_PI_MODE")); v == "flag" || v == "block" {
+        a.Mode = v
+    }
+    if v := os.Getenv("CMP_PI_THRESHOLD"); v != "" {
+        if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 1 {
+            a.Threshold = f
+        }
+    }
+    for _, p := range strings.Split(os.Getenv("CMP_PI_DENYLIST"), ",") {
+        if p = strings.TrimSpace(p); p != "" {
+            a.DenyList = append(a.DenyList, strings.ToLower(p))
+        }
+    }
+    if path := os.Getenv("CMP_PI_DENYLIST_FILE"); path != "" {
+        if f, err := os.Open(path); err == nil {
+            sc := bufio.NewScanner(f)
+            for sc.Scan() {
+                line := strings.TrimSpace(sc.Text())
+                if line != "" && !strings.HasPrefix(line, "#") {
+                    a.DenyList = append(a.DenyList, strings.ToLower(line))
+                }
+            }
+            f.Close()
+        }
+    }
+    if fields := strings.Fields(os.Getenv("CMP_PI_CLASSIFIER_COMMAND")); len(fields) > 0 {
+        a.Classifier = CommandInjectionClassifier{Command: fields[0], Args: fields[1:]}
+    }
+    return a
+}
+
+// Analyze scores text. Heuristic and deny-list weights are combined as independent
+// evidence (1 - Π(1-w)); the classifier score, when available, wins if higher.
+func (a *InjectionAnalyzer) Analyze(ctx context.Context, text string) InjectionResult {
+    var res InjectionResult
+    if a == nil || strings.TrimSpace(text) == "" {
+        return res
+    }
+    remaining := 1.0
+    for _, s := range a.Signals {
+        if s.re != nil && s.re.MatchString(text) {
+            remaining *= 1 - s.Weight
+            res.Signals = append(res.Signals, s.Name)
+        }
+    }
+    lower := strings.ToLower(text)
+    for _, phrase := range a.DenyList {
+        if strings.Contains(lower, phrase) {
+            remaining *= 1 - denyListWeight
+            res.Signals = append(res.Signals, "deny_list")
+            break
+        }
+    }
+    res.Score = 1 - remaining
+    if a.Classifier != nil {
+        if s, err := a.Classifier.Score(ctx, text); err == nil && s > res.Score {
+            res.Score = s
+            res.Signals = append(res.Signals, "classifier")
+        }
+    }
+    res.Detected = res.Score >= a.Threshold
+    res.Blocked = res.Detected && a.Mode == "block"
+    return res
+}
@@ -0,0 +1,162 @@
+package security
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/contexis-cmp/contexis/src/runtime/secrets"
+)
+
+// SealedPrefix marks values encrypted by a KeyRing:
+// "enc:v1:<key id>:<base64 of nonce || ciphertext>".
+const SealedPrefix = "enc:v1:"
+
+// ErrUnknownKey is returned when a value was sealed with a key the ring does not hold.
+var ErrUnknownKey = errors.New("encryption key not available")
+
+// Key is an AES-256 key and the ID recorded with the values it seals.
+type Key struct {
+    ID       string
+    Material []byte
+}
+
+// NewKey derives a key from configured key material: 32 bytes are used as-is,
+// anything else is hashed (the same derivation as EnvKeyProvider).
+func NewKey(material string) Key {
+    k := deriveKey(material)
+    sum := sha256.Sum256(k)
+    return Key{ID: hex.EncodeToString(sum[:4]), Material: k}
+}
+
+func deriveKey(material string) []byte {
+    if len(material) == 32 {
+        return []byte(material)
+    }
+    return []byte(sha256Sum(material)[:32])
+}
+
+// KeyRing seals new values with Current and opens values sealed with Current
+// or any Previous key, so keys can be rotated without downtime.
+type KeyRing struct {
+    Current  Key
+    Previous []Key
+}
+
+// LoadKeyRing builds the ring from CMP_EPISODIC_KEY (current key) and
+// CMP_EPISODIC_PREVIOUS_KEYS (comma-separated keys still accepted for
+// decryption). Values may be secret:// references, so keys can be kept in
+// Vault, AWS Secrets Manager or a KMS-encrypted SOPS file.
+func LoadKeyRing(ctx context.Context) (*KeyRing, error) {
+    current, err := resolveKeyMaterial(ctx, os.Getenv("CMP_EPISODIC_KEY"))
+    if err != nil {
+        return nil, fmt.Errorf("CMP_EPISODIC_KEY: %w", err)
+    }
+    if current == "" {
+        return nil, errors.New("CMP_EPISODIC_KEY not set")
+    }
+    ring := &KeyRing{Current: NewKey(current)}
+    for _, v := range strings.Split(os.Getenv("CMP_EPISODIC_PREVIOUS_KEYS"), ",") {
+        v = strings.TrimSpace(v)
+        if v == "" {
+            continue
+        }
+        material, err := resolveKeyMaterial(ctx, v)
+        if err != nil {
+            return nil, fmt.Errorf("CMP_EPISODIC_PREVIOUS_KEYS: %w", err)
+        }
+        ring.Previous = append(ring.Previous, NewKey(material))
+    }
+    return ring, nil
+}
+
+func resolveKeyMaterial(ctx context.Context, v string) (string, error) {
+    if !secrets.IsRef(v) {
+        return v, nil
+    }
+    return secrets.Default().Resolve(ctx, v)
+}
+
+// IsSealed reports whether s was produced by KeyRing.Seal.
+func IsSealed(s string) bool {
+    return strings.HasPrefix(s, SealedPrefix)
+}
+
+// SealedKeyID returns the ID of the key a sealed value was encrypted with.
+func SealedKeyID(s string) string {
+    rest := strings.TrimPrefix(s, SealedPrefix)
+    if i := strings.IndexByte(rest, ':'); i >= 0 && IsSealed(s) {
+        return rest[:i]
+    }
+    return ""
+}
+
+// Seal encrypts plaintext with the current key.
+func (r *KeyRing) Seal(plaintext []byte) (string, error) {
+    ct, err := EncryptGCM(r.Current.Material, plaintext)
+    if err != nil {
+        return "", err
+    }
+    return SealedPrefix + r.Current.ID + ":" + base64.StdEncoding.EncodeToString(ct), nil
+}
+
+// Open decrypts a sealed value with the key it names.
+func (r *KeyRing) Open(sealed string) ([]byte, error) {
+    id := SealedKeyID(sealed)
+    if id == "" {
+        return nil, errors.New("value is not sealed")
+    }
+    key, ok := r.key(id)
+    if !ok {
+        return nil, fmt.Errorf("%w: key %s", ErrUnknownKey, id)
+    }
+    ct, err := base64.StdEncoding.DecodeString(sealed[len(SealedPrefix)+len(id)+1:])
+    if err != nil {
+        return nil, err
+    }
+    return DecryptGCM(key.Material, ct)
+}
+
+// OpenRaw decrypts nonce || ciphertext written before values carried a key
+// ID, trying each key of the ring.
+func (r *KeyRing) OpenRaw(data []byte) ([]byte, error) {
+    var err error
+    for _, k := range append([]Key{r.Current}, r.Previous...) {
+        var out []byte
+        if out, err = DecryptGCM(k.Material, data); err == nil {
+            return out, nil
+        }
+    }
+    return nil, err
+}
+
+// Reseal re-encrypts a sealed value with the current key. It reports false
+// when the value already uses the current key.
+func (r *KeyRing) Reseal(sealed string) (string, bool, error) {
+    if SealedKeyID(sealed) == r.Current.ID {
+        return sealed, false, nil
+    }
+    plaintext, err := r.Open(sealed)
+    if err != nil {
+        return "", false, err
+    }
+    out, err := r.Seal(plaintext)
+    return out, err == nil, err
+}
+
+func (r *KeyRing) key(id string) (Key, bool) {
+    if r.Current.ID == id {
+        return r.Current, true
+    }
+    for _, k := range r.Previous {
+        if k.ID == id {
+            return k, true
+        }
+    }
+    return Key{}, false
+}
@@ -0,0 +1,68 @@
+package security
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "github.com/contexis-cmp/contexis/src/runtime/secrets"
+)
+
+type staticSecrets map[string]string
+
+func (s staticSecrets) Fetch(ctx context.Context, path string) (secrets.Secret, error) {
+    v, ok := s[path]
+    if !ok {
+        return secrets.Secret{}, errors.New("no such secret")
+    }
+    return secrets.Secret{Data: map[string]string{"": v}}, nil
+}
+
+func TestKeyRing_SealOpenAndRotate(t *testing.T) {
+    t.Setenv("CMP_EPISODIC_KEY", "old-passphrase")
+    t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "")
+    old, err := LoadKeyRing(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    sealed, err := old.Seal([]byte("order 12345"))
+    if err != nil || !IsSealed(sealed) || SealedKeyID(sealed) != old.Current.ID {
+        t.Fatalf("seal: %q %v", sealed, err)
+    }
+    if pt, err := old.Open(sealed); err != nil || string(pt) != "order 12345" {
+        t.Fatalf("open: %q %v", pt, err)
+    }
+
+    // The new key comes from a secret store; the old one is kept for reading
+    r := secrets.NewResolver()
+    r.Register("test", staticSecrets{"episodic": "0123456789abcdef0123456789abcdef"})
+    secrets.SetDefault(r)
+    defer secrets.SetDefault(nil)
+    t.Setenv("CMP_EPISODIC_KEY", "secret://test/episodic")
+    t.Setenv("CMP_EPISODIC_PREVIOUS_KEYS", "old-passphrase")
+    ring, err := LoadKeyRing(context.Background())
+    if err != nil || ring.Current.ID == old.Current.ID || len(ring.Previous) != 1 {
+        t.Fatalf("ring: %+v %v", ring, err)
+    }
+    resealed, changed, err := ring.Reseal(sealed)
+    if err != nil || !changed || SealedKeyID(resealed) != ring.Current.ID {
+        t.Fatalf("reseal: %q %v %v", resealed, changed, err)
+    }
+    if _, changed, _ := ring.Reseal(resealed); changed {
+        t.Fatal("value under the current key was resealed")
+    }
+    if _, err := old.Open(resealed); !errors.Is(err, ErrUnknownKey) {
+        t.Fatalf("expected an unknown key, got %v", err)
+    }
+
+    // Raw ciphertext from before key IDs opens with any key of the ring
+    raw, _ := EncryptGCM(old.Current.Material, []byte("legacy"))
+    if pt, err := ring.OpenRaw(raw); err != nil || string(pt) != "legacy" {
+        t.Fatalf("open raw: %q %v", pt, err)
+    }
+
+    t.Setenv("CMP_EPISODIC_KEY", "")
+    if _, err := LoadKeyRing(context.Background()); err == nil {
+        t.Fatal("expected an error without CMP_EPISODIC_KEY")
+    }
+}
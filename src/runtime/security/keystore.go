@@ -0,0 +1,362 @@
+package security
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// KeysFile is the project-relative path of keys managed at runtime.
+const KeysFile = "data/auth/api_keys.json"
+
+// secretPrefix marks secrets issued by the KeyManager.
+const secretPrefix = "ctx_"
+
+var (
+    // ErrKeyNotFound is returned for unknown key IDs.
+    ErrKeyNotFound = errors.New("api key not found")
+    // ErrKeyRevoked is returned when changing a revoked key.
+    ErrKeyRevoked = errors.New("api key revoked")
+    // ErrGrantExceedsScopes is returned when a key would hold scopes its grantor lacks.
+    ErrGrantExceedsScopes = errors.New("grant exceeds the caller's scopes")
+)
+
+// Roles are named scope bundles that can be granted to managed keys.
+var Roles = map[string][]string{
+    "admin":    {"admin:*"},
+    "operator": {"chat:execute", "context:read", "memory:read", "memory:write", "usage:read", "sessions:read", "approvals:read", "approvals:write", "jobs:read", "jobs:write"},
+    "chat":     {"chat:execute", "context:read", "memory:read"},
+    "readonly": {"context:read", "memory:read", "usage:read", "approvals:read", "jobs:read"},
+}
+
+// KeySpec describes the grants of a key being created or updated.
+type KeySpec struct {
+    Name      string     `json:"name,omitempty"`
+    TenantID  string     `json:"tenant_id,omitempty"`
+    Roles     []string   `json:"roles,omitempty"`
+    Scopes    []string   `json:"scopes,omitempty"`
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// KeyManager stores API keys created at runtime. Only SHA-256 hashes of secrets
+// are persisted. Keys are re-read when the file changes, so keys created or
+// revoked by `ctx keys` take effect in a running server without a restart.
+// Requests whose token is not a managed key fall through to the env keys.
+type KeyManager struct {
+    path     string
+    fallback Authenticator
+
+    mu      sync.RWMutex
+    keys    map[string]*APIKey
+    modTime time.Time
+    size    int64
+}
+
+// NewKeyManager loads the managed keys of a project root. fallback, typically
+// the env-configured APIKeyStore, authenticates tokens that are not managed keys.
+func NewKeyManager(root string, fallback Authenticator) (*KeyManager, error) {
+    m := &KeyManager{path: filepath.Join(root, KeysFile), fallback: fallback, keys: map[string]*APIKey{}}
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if err := m.reload(true); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
+
+// Authenticate verifies the bearer token against managed keys, then the fallback.
+func (m *KeyManager) Authenticate(r *http.Request) (*Principal, error) {
+    token, err := bearerToken(r)
+    if err != nil {
+        return nil, err
+    }
+    if strings.HasPrefix(token, secretPrefix) {
+        m.mu.Lock()
+        _ = m.reload(false)
+        m.mu.Unlock()
+        h := sha256Sum(token)
+        now := time.Now()
+        m.mu.RLock()
+        defer m.mu.RUnlock()
+        for _, k := range m.keys {
+            if k.matches(h, now) {
+                return &Principal{KeyID: k.KeyID, TenantID: k.TenantID, Scopes: k.EffectiveScopes(), Roles: k.Roles}, nil
+            }
+        }
+    }
+    if m.fallback != nil {
+        return m.fallback.Authenticate(r)
+    }
+    return nil, errors.New("invalid token")
+}
+
+// List returns all managed keys, including revoked ones, oldest first.
+func (m *KeyManager) List() []APIKey {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _ = m.reload(false)
+    out := make([]APIKey, 0, len(m.keys))
+    for _, k := range m.keys {
+        out = append(out, *k)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+    return out
+}
+
+// Get returns a managed key.
+func (m *KeyManager) Get(id string) (APIKey, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _ = m.reload(false)
+    k, ok := m.keys[id]
+    if !ok {
+        return APIKey{}, ErrKeyNotFound
+    }
+    return *k, nil
+}
+
+// Create issues a new key and returns it with its secret. The secret is not
+// stored and cannot be retrieved again.
+func (m *KeyManager) Create(spec KeySpec) (APIKey, string, error) {
+    if err := validateRoles(spec.Roles); err != nil {
+        return APIKey{}, "", err
+    }
+    secret, err := newSecret()
+    if err != nil {
+        return APIKey{}, "", err
+    }
+    id, err := randomHex(6)
+    if err != nil {
+        return APIKey{}, "", err
+    }
+    k := &APIKey{
+        KeyID: "key_" + id, Name: spec.Name, Hash: sha256Sum(secret), Prefix: secret[:len(secretPrefix)+6],
+        TenantID: spec.TenantID, Roles: spec.Roles, Scopes: spec.Scopes, CreatedAt: time.Now().UTC(), ExpiresAt: spec.ExpiresAt,
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if err := m.reload(false); err != nil {
+        return APIKey{}, "", err
+    }
+    m.keys[k.KeyID] = k
+    if err := m.save(); err != nil {
+        delete(m.keys, k.KeyID)
+        return APIKey{}, "", err
+    }
+    return *k, secret, nil
+}
+
+// Rotate issues a new secret for a key. The previous secret stays valid for
+// grace (zero invalidates it immediately).
+func (m *KeyManager) Rotate(id string, grace time.Duration) (APIKey, string, error) {
+    secret, err := newSecret()
+    if err != nil {
+        return APIKey{}, "", err
+    }
+    k, err := m.update(id, func(k *APIKey) {
+        now := time.Now().UTC()
+        k.PreviousHash, k.PreviousExpiresAt = "", nil
+        if grace > 0 {
+            until := now.Add(grace)
+            k.PreviousHash, k.PreviousExpiresAt = k.Hash, &until
+        }
+        k.Hash, k.Prefix, k.RotatedAt = sha256Sum(secret), secret[:len(secretPrefix)+6], &now
+    })
+    if err != nil {
+        return APIKey{}, "", err
+    }
+    return k, secret, nil
+}
+
+// Update replaces the name, tenant, roles, scopes and expiry of a key.
+func (m *KeyManager) Update(id string, spec KeySpec) (APIKey, error) {
+    if err := validateRoles(spec.Roles); err != nil {
+        return APIKey{}, err
+    }
+    return m.update(id, func(k *APIKey) {
+        k.Name, k.TenantID, k.Roles, k.Scopes, k.ExpiresAt = spec.Name, spec.TenantID, spec.Roles, spec.Scopes, spec.ExpiresAt
+    })
+}
+
+// Revoke permanently disables a key. Revoked keys are kept for auditing.
+func (m *KeyManager) Revoke(id string) (APIKey, error) {
+    return m.update(id, func(k *APIKey) {
+        now := time.Now().UTC()
+        k.RevokedAt = &now
+        k.PreviousHash, k.PreviousExpiresAt = "", nil
+    })
+}
+
+func (m *KeyManager) update(id string, fn func(*APIKey)) (APIKey, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if err := m.reload(false); err != nil {
+        return APIKey{}, err
+    }
+    k, ok := m.keys[id]
+    if !ok {
+        return APIKey{}, ErrKeyNotFound
+    }
+    if k.RevokedAt != nil {
+        return APIKey{}, fmt.Errorf("%w: %s", ErrKeyRevoked, id)
+    }
+    prev := *k
+    fn(k)
+    if err := m.save(); err != nil {
+        *k = prev
+        return APIKey{}, err
+    }
+    return *k, nil
+}
+
+// reload re-reads the key file when it changed on disk. Callers hold m.mu.
+func (m *KeyManager) reload(force bool) error {
+    info, err := os.Stat(m.path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    if !force && info.ModTime().Equal(m.modTime) && info.Size() == m.size {
+        return nil
+    }
+    by, err := os.ReadFile(m.path)
+    if err != nil {
+        return err
+    }
+    var list []*APIKey
+    if err := json.Unmarshal(by, &list); err != nil {
+        return fmt.Errorf("parse %s: %w", KeysFile, err)
+    }
+    m.keys = make(map[string]*APIKey, len(list))
+    for _, k := range list {
+        m.keys[k.KeyID] = k
+    }
+    m.modTime, m.size = info.ModTime(), info.Size()
+    return nil
+}
+
+// save writes the key file atomically. Callers hold m.mu.
+func (m *KeyManager) save() error {
+    list := make([]*APIKey, 0, len(m.keys))
+    for _, k := range m.keys {
+        list = append(list, k)
+    }
+    sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+    by, err := json.MarshalIndent(list, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+        return err
+    }
+    tmp := m.path + ".tmp"
+    if err := os.WriteFile(tmp, by, 0o600); err != nil {
+        return err
+    }
+    if err := os.Rename(tmp, m.path); err != nil {
+        return err
+    }
+    if info, err := os.Stat(m.path); err == nil {
+        m.modTime, m.size = info.ModTime(), info.Size()
+    }
+    return nil
+}
+
+// EffectiveScopes returns the key's scopes plus those granted by its roles.
+func (k APIKey) EffectiveScopes() []string {
+    seen := map[string]bool{}
+    var out []string
+    add := func(s string) {
+        if !seen[s] {
+            seen[s] = true
+            out = append(out, s)
+        }
+    }
+    for _, r := range k.Roles {
+        for _, s := range Roles[r] {
+            add(s)
+        }
+    }
+    for _, s := range k.Scopes {
+        add(s)
+    }
+    return out
+}
+
+// Active reports whether the key can authenticate at t.
+func (k APIKey) Active(t time.Time) bool {
+    return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
+
+func (k *APIKey) matches(hash string, now time.Time) bool {
+    if !k.Active(now) {
+        return false
+    }
+    if k.Hash == hash {
+        return true
+    }
+    return k.PreviousHash == hash && k.PreviousExpiresAt != nil && now.Before(*k.PreviousExpiresAt)
+}
+
+// CheckGrant reports whether grantor may hand out a key with the roles and
+// scopes of spec. Holders of admin:* may grant anything except that tenant-bound
+// callers never grant admin:* or *; everyone else may only grant scopes they
+// hold themselves.
+func CheckGrant(grantor *Principal, spec KeySpec) error {
+    granted := APIKey{Roles: spec.Roles, Scopes: spec.Scopes}.EffectiveScopes()
+    for _, s := range granted {
+        if grantor.TenantID != "" && (s == "admin:*" || s == "*") {
+            return fmt.Errorf("%w: tenant-bound keys cannot grant %s", ErrGrantExceedsScopes, s)
+        }
+    }
+    if HasScope(grantor, "admin:*") {
+        return nil
+    }
+    for _, s := range granted {
+        if !HasScope(grantor, s) {
+            return fmt.Errorf("%w: %s", ErrGrantExceedsScopes, s)
+        }
+    }
+    return nil
+}
+
+func validateRoles(roles []string) error {
+    for _, r := range roles {
+        if _, ok := Roles[r]; !ok {
+            names := make([]string, 0, len(Roles))
+            for n := range Roles {
+                names = append(names, n)
+            }
+            sort.Strings(names)
+            return fmt.Errorf("unknown role %q (known: %s)", r, strings.Join(names, ", "))
+        }
+    }
+    return nil
+}
+
+func newSecret() (string, error) {
+    s, err := randomHex(24)
+    if err != nil {
+        return "", err
+    }
+    return secretPrefix + s, nil
+}
+
+func randomHex(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,81 @@
+package security
+
+import (
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func authAs(a Authenticator, token string) (*Principal, error) {
+    r := httptest.NewRequest("GET", "/", nil)
+    r.Header.Set("Authorization", "Bearer "+token)
+    return a.Authenticate(r)
+}
+
+func TestKeyManager_HashedAtRestAndReloaded(t *testing.T) {
+    root := t.TempDir()
+    t.Setenv("CMP_API_TOKENS", "envtok@acme:chat:execute")
+    server, err := NewKeyManager(root, NewAPIKeyStoreFromEnv())
+    if err != nil {
+        t.Fatal(err)
+    }
+    // A second manager on the same root stands in for the CLI
+    cli, _ := NewKeyManager(root, nil)
+    k, secret, err := cli.Create(KeySpec{Name: "ci", TenantID: "acme", Roles: []string{"chat"}, Scopes: []string{"usage:read"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    by, _ := os.ReadFile(filepath.Join(root, KeysFile))
+    if strings.Contains(string(by), secret) || !strings.Contains(string(by), sha256Sum(secret)) {
+        t.Fatal("secret must be stored only as a hash")
+    }
+    p, err := authAs(server, secret)
+    if err != nil || p.KeyID != k.KeyID || p.TenantID != "acme" || !HasScope(p, "chat:execute") || !HasScope(p, "usage:read") {
+        t.Fatalf("managed key auth = %+v, %v", p, err)
+    }
+    if p, err := authAs(server, "envtok"); err != nil || p.KeyID != "env-1" {
+        t.Fatalf("env key must still authenticate: %+v, %v", p, err)
+    }
+
+    if _, err := cli.Revoke(k.KeyID); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := authAs(server, secret); err == nil {
+        t.Fatal("revoked key still authenticates")
+    }
+    if _, err := cli.Update(k.KeyID, KeySpec{}); err == nil {
+        t.Fatal("expected error updating a revoked key")
+    }
+}
+
+func TestKeyManager_RotateWithGraceAndExpiry(t *testing.T) {
+    km, _ := NewKeyManager(t.TempDir(), nil)
+    k, old, _ := km.Create(KeySpec{Roles: []string{"readonly"}})
+    _, fresh, err := km.Rotate(k.KeyID, time.Hour)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, tok := range []string{old, fresh} {
+        if _, err := authAs(km, tok); err != nil {
+            t.Fatalf("secret %s rejected during grace: %v", tok[:10], err)
+        }
+    }
+    if _, _, err := km.Rotate(k.KeyID, 0); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := authAs(km, fresh); err == nil {
+        t.Fatal("rotation without grace must invalidate the previous secret")
+    }
+
+    past := time.Now().Add(-time.Minute)
+    _, expired, _ := km.Create(KeySpec{Roles: []string{"chat"}, ExpiresAt: &past})
+    if _, err := authAs(km, expired); err == nil {
+        t.Fatal("expired key authenticates")
+    }
+    if _, _, err := km.Create(KeySpec{Roles: []string{"superuser"}}); err == nil {
+        t.Fatal("expected unknown role error")
+    }
+}
@@ -0,0 +1,393 @@
+package security
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash"
+    "math/big"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// OIDCConfig configures JWT validation against an OpenID Connect issuer
+type OIDCConfig struct {
+    Issuer       string        // expected "iss" claim; also used for discovery
+    Audience     string        // expected "aud" claim (required)
+    JWKSURL      string        // explicit JWKS endpoint; discovered from issuer when empty
+    TenantClaim  string        // claim mapped to Principal.TenantID
+    ScopesClaim  string        // space-separated string or list mapped to Principal.Scopes
+    RolesClaim   string        // list of roles appended to Principal.Scopes
+    JWKSCacheTTL time.Duration // how long fetched keys are trusted before refresh
+    Leeway       time.Duration // clock skew tolerance for exp/nbf
+}
+
+// OIDCAuthenticator validates Bearer JWTs signed by keys published in a JWKS
+type OIDCAuthenticator struct {
+    cfg    OIDCConfig
+    client *http.Client
+
+    mu          sync.RWMutex
+    keys        map[string]crypto.PublicKey // kid -> key
+    fetchedAt   time.Time
+    lastAttempt time.Time
+    now         func() time.Time
+}
+
+// NewOIDCAuthenticatorFromEnv builds an OIDC authenticator from environment variables:
+// CMP_OIDC_ISSUER and CMP_OIDC_AUDIENCE (required), CMP_OIDC_JWKS_URL,
+// CMP_OIDC_TENANT_CLAIM (default tenant_id), CMP_OIDC_SCOPES_CLAIM (default scope),
+// CMP_OIDC_ROLES_CLAIM (default roles), CMP_OIDC_JWKS_TTL_SECONDS (default 3600)
+func NewOIDCAuthenticatorFromEnv() (*OIDCAuthenticator, error) {
+    cfg := OIDCConfig{
+        Issuer:       strings.TrimSpace(os.Getenv("CMP_OIDC_ISSUER")),
+        Audience:     strings.TrimSpace(os.Getenv("CMP_OIDC_AUDIENCE")),
+        JWKSURL:      strings.TrimSpace(os.Getenv("CMP_OIDC_JWKS_URL")),
+        TenantClaim:  os.Getenv("CMP_OIDC_TENANT_CLAIM"),
+        ScopesClaim:  os.Getenv("CMP_OIDC_SCOPES_CLAIM"),
+        RolesClaim:   os.Getenv("CMP_OIDC_ROLES_CLAIM"),
+        JWKSCacheTTL: time.Hour,
+    }
+    if v := os.Getenv("CMP_OIDC_JWKS_TTL_SECONDS"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil && n > 0 {
+            cfg.JWKSCacheTTL = time.Duration(n) * time.Second
+        }
+    }
+    return NewOIDCAuthenticator(cfg)
+}
+
+// NewOIDCAuthenticator creates an authenticator with defaults applied to cfg
+func NewOIDCAuthenticator(cfg OIDCConfig) (*OIDCAuthenticator, error) {
+    if cfg.Issuer == "" {
+        return nil, errors.New("oidc issuer is required")
+    }
+    // Without an audience, tokens the issuer minted for any other client would be accepted
+    if cfg.Audience == "" {
+        return nil, errors.New("oidc audience is required")
+    }
+    if cfg.TenantClaim == "" {
+        cfg.TenantClaim = "tenant_id"
+    }
+    if cfg.ScopesClaim == "" {
+        cfg.ScopesClaim = "scope"
+    }
+    if cfg.RolesClaim == "" {
+        cfg.RolesClaim = "roles"
+    }
+    if cfg.JWKSCacheTTL <= 0 {
+        cfg.JWKSCacheTTL = time.Hour
+    }
+    if cfg.Leeway <= 0 {
+        cfg.Leeway = 60 * time.Second
+    }
+    return &OIDCAuthenticator{
+        cfg:    cfg,
+        client: &http.Client{Timeout: 10 * time.Second},
+        keys:   make(map[string]crypto.PublicKey),
+        now:    time.Now,
+    }, nil
+}
+
+// Authenticate extracts the Bearer JWT, verifies signature and registered claims,
+// and maps the remaining claims onto a Principal
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+    token, err := bearerToken(r)
+    if err != nil {
+        return nil, err
+    }
+    parts := strings.Split(token, ".")
+    if len(parts) != 3 {
+        return nil, errors.New("malformed jwt")
+    }
+    var hdr struct {
+        Alg string `json:"alg"`
+        Kid string `json:"kid"`
+    }
+    if err := decodeSegment(parts[0], &hdr); err != nil {
+        return nil, fmt.Errorf("invalid jwt header: %w", err)
+    }
+    key, err := a.key(r.Context(), hdr.Kid)
+    if err != nil {
+        return nil, err
+    }
+    sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+    if err != nil {
+        return nil, errors.New("invalid jwt signature encoding")
+    }
+    if err := verifySignature(hdr.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+        return nil, err
+    }
+    var claims map[string]interface{}
+    if err := decodeSegment(parts[1], &claims); err != nil {
+        return nil, fmt.Errorf("invalid jwt claims: %w", err)
+    }
+    if err := a.validateClaims(claims); err != nil {
+        return nil, err
+    }
+    sub, _ := claims["sub"].(string)
+    tenant, _ := claims[a.cfg.TenantClaim].(string)
+    scopes := claimStrings(claims[a.cfg.ScopesClaim])
+    roles := claimStrings(claims[a.cfg.RolesClaim])
+    scopes = append(scopes, roles...)
+    return &Principal{KeyID: "oidc:" + sub, TenantID: tenant, Scopes: scopes, Roles: roles}, nil
+}
+
+func (a *OIDCAuthenticator) validateClaims(claims map[string]interface{}) error {
+    now := a.now()
+    if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(a.cfg.Issuer, "/") {
+        return errors.New("invalid token issuer")
+    }
+    found := false
+    for _, aud := range claimStrings(claims["aud"]) {
+        if aud == a.cfg.Audience {
+            found = true
+            break
+        }
+    }
+    if !found {
+        return errors.New("invalid token audience")
+    }
+    exp, ok := claims["exp"].(float64)
+    if !ok {
+        return errors.New("token missing exp")
+    }
+    if now.After(time.Unix(int64(exp), 0).Add(a.cfg.Leeway)) {
+        return errors.New("token expired")
+    }
+    if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.cfg.Leeway).Before(time.Unix(int64(nbf), 0)) {
+        return errors.New("token not yet valid")
+    }
+    return nil
+}
+
+// key returns the verification key for kid, refreshing the JWKS when the cache
+// is stale or the kid is unknown (refreshes on unknown kids are throttled)
+func (a *OIDCAuthenticator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+    a.mu.RLock()
+    k, ok := a.lookup(kid)
+    fresh := a.now().Sub(a.fetchedAt) < a.cfg.JWKSCacheTTL
+    recent := a.now().Sub(a.lastAttempt) < 30*time.Second
+    a.mu.RUnlock()
+    if ok && fresh {
+        return k, nil
+    }
+    if !ok && fresh && recent {
+        return nil, errors.New("unknown signing key")
+    }
+    if err := a.refresh(ctx); err != nil {
+        if ok {
+            // serve stale keys rather than failing closed on a transient JWKS outage
+            return k, nil
+        }
+        return nil, fmt.Errorf("fetch jwks: %w", err)
+    }
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if k, ok := a.lookup(kid); ok {
+        return k, nil
+    }
+    return nil, errors.New("unknown signing key")
+}
+
+// lookup must be called with a.mu held; an empty kid matches a single-key set
+func (a *OIDCAuthenticator) lookup(kid string) (crypto.PublicKey, bool) {
+    if k, ok := a.keys[kid]; ok {
+        return k, true
+    }
+    if kid == "" && len(a.keys) == 1 {
+        for _, k := range a.keys {
+            return k, true
+        }
+    }
+    return nil, false
+}
+
+func (a *OIDCAuthenticator) refresh(ctx context.Context) error {
+    a.mu.Lock()
+    a.lastAttempt = a.now()
+    a.mu.Unlock()
+    jwksURL := a.cfg.JWKSURL
+    if jwksURL == "" {
+        var disc struct {
+            JWKSURI string `json:"jwks_uri"`
+        }
+        if err := a.getJSON(ctx, strings.TrimSuffix(a.cfg.Issuer, "/")+"/.well-known/openid-configuration", &disc); err != nil {
+            return err
+        }
+        if disc.JWKSURI == "" {
+            return errors.New("discovery document has no jwks_uri")
+        }
+        jwksURL = disc.JWKSURI
+    }
+    var set struct {
+        Keys []jwk `json:"keys"`
+    }
+    if err := a.getJSON(ctx, jwksURL, &set); err != nil {
+        return err
+    }
+    keys := make(map[string]crypto.PublicKey, len(set.Keys))
+    for _, k := range set.Keys {
+        if k.Use != "" && k.Use != "sig" {
+            continue
+        }
+        pub, err := k.publicKey()
+        if err != nil {
+            continue
+        }
+        keys[k.Kid] = pub
+    }
+    if len(keys) == 0 {
+        return errors.New("jwks contains no usable keys")
+    }
+    a.mu.Lock()
+    a.keys = keys
+    a.fetchedAt = a.now()
+    a.mu.Unlock()
+    return nil
+}
+
+func (a *OIDCAuthenticator) getJSON(ctx context.Context, url string, out interface{}) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return err
+    }
+    resp, err := a.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("GET %s: %s", url, resp.Status)
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwk is a single JSON Web Key (RSA or EC)
+type jwk struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    Crv string `json:"crv"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+    switch k.Kty {
+    case "RSA":
+        n, err := base64.RawURLEncoding.DecodeString(k.N)
+        if err != nil {
+            return nil, err
+        }
+        e, err := base64.RawURLEncoding.DecodeString(k.E)
+        if err != nil {
+            return nil, err
+        }
+        return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+    case "EC":
+        var curve elliptic.Curve
+        switch k.Crv {
+        case "P-256":
+            curve = elliptic.P256()
+        case "P-384":
+            curve = elliptic.P384()
+        default:
+            return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+        }
+        x, err := base64.RawURLEncoding.DecodeString(k.X)
+        if err != nil {
+            return nil, err
+        }
+        y, err := base64.RawURLEncoding.DecodeString(k.Y)
+        if err != nil {
+            return nil, err
+        }
+        return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+    default:
+        return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+    }
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+    var h hash.Hash
+    var ch crypto.Hash
+    switch alg {
+    case "RS256", "ES256":
+        h, ch = sha256.New(), crypto.SHA256
+    case "RS384", "ES384":
+        h, ch = sha512.New384(), crypto.SHA384
+    case "RS512":
+        h, ch = sha512.New(), crypto.SHA512
+    default:
+        // rejects "none" and HMAC algorithms, which are unsafe with public JWKS
+        return fmt.Errorf("unsupported jwt alg %q", alg)
+    }
+    h.Write(signed)
+    digest := h.Sum(nil)
+    switch pub := key.(type) {
+    case *rsa.PublicKey:
+        if !strings.HasPrefix(alg, "RS") {
+            return errors.New("jwt alg does not match key type")
+        }
+        if err := rsa.VerifyPKCS1v15(pub, ch, digest, sig); err != nil {
+            return errors.New("invalid jwt signature")
+        }
+        return nil
+    case *ecdsa.PublicKey:
+        if !strings.HasPrefix(alg, "ES") {
+            return errors.New("jwt alg does not match key type")
+        }
+        size := (pub.Curve.Params().BitSize + 7) / 8
+        if len(sig) != 2*size {
+            return errors.New("invalid jwt signature")
+        }
+        r := new(big.Int).SetBytes(sig[:size])
+        s := new(big.Int).SetBytes(sig[size:])
+        if !ecdsa.Verify(pub, digest, r, s) {
+            return errors.New("invalid jwt signature")
+        }
+        return nil
+    default:
+        return errors.New("unsupported key type")
+    }
+}
+
+func decodeSegment(seg string, out interface{}) error {
+    by, err := base64.RawURLEncoding.DecodeString(seg)
+    if err != nil {
+        return err
+    }
+    return json.Unmarshal(by, out)
+}
+
+// claimStrings normalizes a claim that may be a space-separated string or a list
+func claimStrings(v interface{}) []string {
+    switch t := v.(type) {
+    case string:
+        return strings.Fields(t)
+    case []interface{}:
+        out := make([]string, 0, len(t))
+        for _, x := range t {
+            if s, ok := x.(string); ok && s != "" {
+                out = append(out, s)
+            }
+        }
+        return out
+    default:
+        return nil
+    }
+}
@@ -0,0 +1,197 @@
+package security
+
+import (
+    "crypto"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+    t.Helper()
+    hdr, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+    body, _ := json.Marshal(claims)
+    signed := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(body)
+    digest := sha256.Sum256([]byte(signed))
+    sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+    if err != nil {
+        t.Fatal(err)
+    }
+    return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// unsignedTestJWT builds a token with the given alg header; HMAC algs are keyed
+// with key, as an attacker would with the issuer's public key
+func unsignedTestJWT(alg, kid string, key []byte, claims map[string]interface{}) string {
+    hdr, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+    body, _ := json.Marshal(claims)
+    signed := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(body)
+    if alg == "none" {
+        return signed + "."
+    }
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(signed))
+    return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func testJWK(key *rsa.PrivateKey, kid string) map[string]string {
+    return map[string]string{
+        "kty": "RSA",
+        "kid": kid,
+        "use": "sig",
+        "n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+        "e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+    }
+}
+
+func newTestIssuer(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+    t.Helper()
+    var srv *httptest.Server
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/jwks"})
+    })
+    mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{testJWK(key, kid)}})
+    })
+    srv = httptest.NewServer(mux)
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func TestOIDCAuthenticator_ValidToken(t *testing.T) {
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil {
+        t.Fatal(err)
+    }
+    iss := newTestIssuer(t, key, "k1")
+    auth, err := NewOIDCAuthenticator(OIDCConfig{Issuer: iss.URL, Audience: "cmp"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tok := signTestJWT(t, key, "k1", map[string]interface{}{
+        "iss":       iss.URL,
+        "aud":       []string{"cmp"},
+        "sub":       "alice",
+        "exp":       time.Now().Add(time.Hour).Unix(),
+        "tenant_id": "acme",
+        "scope":     "chat:execute context:read",
+        "roles":     []string{"admin:*"},
+    })
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("Authorization", "Bearer "+tok)
+    p, err := auth.Authenticate(req)
+    if err != nil {
+        t.Fatalf("Authenticate: %v", err)
+    }
+    if p.TenantID != "acme" || p.KeyID != "oidc:alice" {
+        t.Fatalf("unexpected principal: %+v", p)
+    }
+    if !HasScope(p, "chat:execute") || !HasScope(p, "admin:*") {
+        t.Fatalf("expected scopes and roles mapped, got %v", p.Scopes)
+    }
+}
+
+func TestOIDCAuthenticator_RejectsInvalidTokens(t *testing.T) {
+    key, _ := rsa.GenerateKey(rand.Reader, 2048)
+    other, _ := rsa.GenerateKey(rand.Reader, 2048)
+    iss := newTestIssuer(t, key, "k1")
+    auth, _ := NewOIDCAuthenticator(OIDCConfig{Issuer: iss.URL, Audience: "cmp"})
+    base := func() map[string]interface{} {
+        return map[string]interface{}{"iss": iss.URL, "aud": "cmp", "sub": "bob", "exp": time.Now().Add(time.Hour).Unix()}
+    }
+    cases := map[string]string{}
+    expired := base()
+    expired["exp"] = time.Now().Add(-time.Hour).Unix()
+    cases["expired"] = signTestJWT(t, key, "k1", expired)
+    wrongAud := base()
+    wrongAud["aud"] = "other"
+    cases["audience"] = signTestJWT(t, key, "k1", wrongAud)
+    wrongIss := base()
+    wrongIss["iss"] = "https://evil.example"
+    cases["issuer"] = signTestJWT(t, key, "k1", wrongIss)
+    cases["signature"] = signTestJWT(t, other, "k1", base())
+    cases["alg none"] = unsignedTestJWT("none", "k1", nil, base())
+    cases["alg HS256"] = unsignedTestJWT("HS256", "k1", key.N.Bytes(), base())
+    for name, tok := range cases {
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Header.Set("Authorization", "Bearer "+tok)
+        if _, err := auth.Authenticate(req); err == nil {
+            t.Fatalf("%s: expected error", name)
+        }
+    }
+}
+
+func TestOIDCAuthenticator_RequiresAudience(t *testing.T) {
+    if _, err := NewOIDCAuthenticator(OIDCConfig{Issuer: "https://login.example.com"}); err == nil {
+        t.Fatal("expected an error without an audience")
+    }
+    t.Setenv("CMP_AUTH_MODE", "oidc")
+    t.Setenv("CMP_OIDC_ISSUER", "https://login.example.com")
+    t.Setenv("CMP_OIDC_AUDIENCE", "")
+    if _, err := NewAuthenticatorFromEnv(); err == nil {
+        t.Fatal("expected oidc mode without CMP_OIDC_AUDIENCE to fail")
+    }
+}
+
+func TestOIDCAuthenticator_RefreshesJWKSForUnknownKid(t *testing.T) {
+    oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+    newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+    var (
+        mu      sync.Mutex
+        keys    = []map[string]string{testJWK(oldKey, "k1")}
+        fetches int
+    )
+    jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        defer mu.Unlock()
+        fetches++
+        _ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+    }))
+    defer jwks.Close()
+    auth, err := NewOIDCAuthenticator(OIDCConfig{Issuer: "https://login.example.com", Audience: "cmp", JWKSURL: jwks.URL})
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Now()
+    auth.now = func() time.Time { return now }
+    authenticate := func(key *rsa.PrivateKey, kid string) error {
+        tok := signTestJWT(t, key, kid, map[string]interface{}{
+            "iss": "https://login.example.com", "aud": "cmp", "sub": "carol", "exp": now.Add(time.Hour).Unix(),
+        })
+        req := httptest.NewRequest(http.MethodGet, "/", nil)
+        req.Header.Set("Authorization", "Bearer "+tok)
+        _, err := auth.Authenticate(req)
+        return err
+    }
+    if err := authenticate(oldKey, "k1"); err != nil {
+        t.Fatalf("old key: %v", err)
+    }
+
+    // The issuer rotates to k2; the cached set is still fresh
+    mu.Lock()
+    keys = []map[string]string{testJWK(oldKey, "k1"), testJWK(newKey, "k2")}
+    mu.Unlock()
+    // Refreshes on unknown kids are throttled
+    if err := authenticate(newKey, "k2"); err == nil {
+        t.Fatal("expected the unknown kid to be rejected within the throttle window")
+    }
+    now = now.Add(31 * time.Second)
+    if err := authenticate(newKey, "k2"); err != nil {
+        t.Fatalf("new key after refresh: %v", err)
+    }
+    mu.Lock()
+    defer mu.Unlock()
+    if fetches != 2 {
+        t.Fatalf("expected one refresh for the unknown kid, got %d fetches", fetches)
+    }
+}
@@ -0,0 +1,209 @@
+package security
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "os"
+    "os/exec"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+)
+
+// PIIMatch is a detected PII span in byte offsets of the scanned text
+type PIIMatch struct {
+    Type  string `json:"type"`
+    Start int    `json:"start"`
+    End   int    `json:"end"`
+}
+
+// PIIDetector finds PII spans in text. Implementations include the built-in
+// regex detector and external NER plugins.
+type PIIDetector interface {
+    Detect(text string) []PIIMatch
+}
+
+// RegexPIIDetector detects common structured PII with regular expressions
+type RegexPIIDetector struct{}
+
+var reCreditCard = regexp.MustCompile(`\b(?:\d[ \-]?){13,16}\b`)
+
+func (RegexPIIDetector) Detect(text string) []PIIMatch {
+    var out []PIIMatch
+    for _, p := range []struct {
+        typ string
+        re  *regexp.Regexp
+    }{
+        {"email", reEmail},
+        {"ssn", reSSN},
+        {"credit_card", reCreditCard},
+        {"phone", rePhone},
+    } {
+        for _, loc := range p.re.FindAllStringIndex(text, -1) {
+            out = append(out, PIIMatch{Type: p.typ, Start: loc[0], End: loc[1]})
+        }
+    }
+    return out
+}
+
+// CommandPIIDetector delegates detection to an external NER process (e.g. a
+// spaCy/Presidio script). The process receives {"text": "..."} on stdin and must
+// print {"entities": [{"type": "person", "start": 0, "end": 5}]} with character offsets.
+type CommandPIIDetector struct {
+    Command string
+    Args    []string
+    Timeout time.Duration
+}
+
+func (d CommandPIIDetector) Detect(text string) []PIIMatch {
+    timeout := d.Timeout
+    if timeout <= 0 {
+        timeout = 5 * time.Second
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+    payload, _ := json.Marshal(map[string]string{"text": text})
+    cmd := exec.CommandContext(ctx, d.Command, d.Args...)
+    cmd.Stdin = bytes.NewReader(payload)
+    out, err := cmd.Output()
+    if err != nil {
+        // NER is best-effort; regex detection still applies
+        return nil
+    }
+    var resp struct {
+        Entities []PIIMatch `json:"entities"`
+    }
+    if err := json.Unmarshal(out, &resp); err != nil {
+        return nil
+    }
+    matches := make([]PIIMatch, 0, len(resp.Entities))
+    for _, e := range resp.Entities {
+        start, end := runeToByteOffset(text, e.Start), runeToByteOffset(text, e.End)
+        if start < 0 || end <= start {
+            continue
+        }
+        matches = append(matches, PIIMatch{Type: strings.ToLower(e.Type), Start: start, End: end})
+    }
+    return matches
+}
+
+func runeToByteOffset(s string, n int) int {
+    if n < 0 {
+        return -1
+    }
+    i := 0
+    for b := range s {
+        if i == n {
+            return b
+        }
+        i++
+    }
+    if i == n {
+        return len(s)
+    }
+    return -1
+}
+
+// PIIOutcome is the result of applying a PII policy to a piece of text
+type PIIOutcome struct {
+    Text     string
+    Matches  []PIIMatch
+    Blocked  bool
+    Redacted bool
+}
+
+// Types returns the distinct PII types found, sorted
+func (o PIIOutcome) Types() []string {
+    seen := map[string]bool{}
+    var out []string
+    for _, m := range o.Matches {
+        if !seen[m.Type] {
+            seen[m.Type] = true
+            out = append(out, m.Type)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// PIIEngine applies a PII mode (off|allow|redact|block) using one or more detectors.
+// "allow" detects and reports without modifying text.
+type PIIEngine struct {
+    Mode      string
+    Types     map[string]bool // when non-empty, only these types are acted on
+    Detectors []PIIDetector
+}
+
+// NewPIIEngine builds an engine with the regex detector plus the optional NER
+// plugin configured via CMP_PII_NER_COMMAND (whitespace-separated command line)
+func NewPIIEngine(mode string, types []string) *PIIEngine {
+    e := &PIIEngine{Mode: strings.ToLower(mode), Detectors: []PIIDetector{RegexPIIDetector{}}}
+    if len(types) > 0 {
+        e.Types = make(map[string]bool, len(types))
+        for _, t := range types {
+            e.Types[strings.ToLower(strings.TrimSpace(t))] = true
+        }
+    }
+    if fields := strings.Fields(os.Getenv("CMP_PII_NER_COMMAND")); len(fields) > 0 {
+        e.Detectors = append(e.Detectors, CommandPIIDetector{Command: fields[0], Args: fields[1:]})
+    }
+    return e
+}
+
+// Scan returns non-overlapping PII matches ordered by position
+func (e *PIIEngine) Scan(text string) []PIIMatch {
+    var all []PIIMatch
+    for _, d := range e.Detectors {
+        for _, m := range d.Detect(text) {
+            if len(e.Types) > 0 && !e.Types[m.Type] {
+                continue
+            }
+            all = append(all, m)
+        }
+    }
+    sort.SliceStable(all, func(i, j int) bool {
+        if all[i].Start == all[j].Start {
+            return all[i].End > all[j].End
+        }
+        return all[i].Start < all[j].Start
+    })
+    out := all[:0]
+    lastEnd := -1
+    for _, m := range all {
+        if m.Start < lastEnd {
+            continue
+        }
+        out = append(out, m)
+        lastEnd = m.End
+    }
+    return out
+}
+
+// Apply enforces the engine mode on text
+func (e *PIIEngine) Apply(text string) PIIOutcome {
+    if e == nil || e.Mode == "" || e.Mode == "off" {
+        return PIIOutcome{Text: text}
+    }
+    matches := e.Scan(text)
+    if len(matches) == 0 {
+        return PIIOutcome{Text: text}
+    }
+    switch e.Mode {
+    case "block":
+        return PIIOutcome{Text: text, Matches: matches, Blocked: true}
+    case "redact":
+        var sb strings.Builder
+        prev := 0
+        for _, m := range matches {
+            sb.WriteString(text[prev:m.Start])
+            sb.WriteString("[REDACTED_" + strings.ToUpper(m.Type) + "]")
+            prev = m.End
+        }
+        sb.WriteString(text[prev:])
+        return PIIOutcome{Text: sb.String(), Matches: matches, Redacted: true}
+    default:
+        return PIIOutcome{Text: text, Matches: matches}
+    }
+}
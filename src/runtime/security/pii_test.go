@@ -0,0 +1,40 @@
+package security
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestPIIEngine_Redact(t *testing.T) {
+    e := NewPIIEngine("redact", nil)
+    res := e.Apply("mail alice@example.com or call +1 555 123 4567, ssn 123-45-6789")
+    if !res.Redacted || res.Blocked {
+        t.Fatalf("expected redaction, got %+v", res)
+    }
+    for _, want := range []string{"[REDACTED_EMAIL]", "[REDACTED_PHONE]", "[REDACTED_SSN]"} {
+        if !strings.Contains(res.Text, want) {
+            t.Fatalf("missing %s in %q", want, res.Text)
+        }
+    }
+    if strings.Contains(res.Text, "alice@example.com") {
+        t.Fatalf("email not redacted: %q", res.Text)
+    }
+}
+
+func TestPIIEngine_BlockAndTypeFilter(t *testing.T) {
+    if res := NewPIIEngine("block", nil).Apply("reach me at bob@example.org"); !res.Blocked {
+        t.Fatalf("expected block")
+    }
+    // Only SSNs are in scope: an email must pass through untouched
+    res := NewPIIEngine("block", []string{"ssn"}).Apply("reach me at bob@example.org")
+    if res.Blocked || len(res.Matches) != 0 {
+        t.Fatalf("expected email to be ignored, got %+v", res)
+    }
+    if res := NewPIIEngine("off", nil).Apply("bob@example.org"); len(res.Matches) != 0 {
+        t.Fatalf("off mode must not scan")
+    }
+    res = NewPIIEngine("allow", nil).Apply("bob@example.org")
+    if len(res.Matches) != 1 || res.Text != "bob@example.org" {
+        t.Fatalf("allow mode should report without modifying: %+v", res)
+    }
+}
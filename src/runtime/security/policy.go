@@ -9,7 +9,7 @@ import (
 type Policy struct {
     RequireOOB map[string]bool
     NoUnsupportedClaims bool // if true, response must cite sources when memory results are used
-    PIIMode string // off|redact|block
+    PIIMode string // off|allow|redact|block
 }
 
 func DefaultPolicy() Policy {
@@ -28,7 +28,7 @@ func (p Policy) RequiresOutOfBand(action string) bool { return p.RequireOOB[acti
 
 // MergeEnv allows overriding policy using environment variables
 // CMP_OOB_REQUIRED_ACTIONS: comma-separated list of actions requiring OOB (e.g., "data_change,account_action,payment")
-// CMP_PII_MODE: one of off|redact|block
+// CMP_PII_MODE: one of off|allow|redact|block
 func (p Policy) MergeEnv() Policy {
     out := p
     if v := os.Getenv("CMP_OOB_REQUIRED_ACTIONS"); v != "" {
@@ -39,7 +39,7 @@ func (p Policy) MergeEnv() Policy {
     }
     if v := os.Getenv("CMP_PII_MODE"); v != "" {
         switch strings.ToLower(v) {
-        case "off", "redact", "block":
+        case "off", "allow", "redact", "block":
             out.PIIMode = strings.ToLower(v)
         }
     }
@@ -7,6 +7,12 @@ var (
         Name: "cmp_security_prompt_injection_detections_total",
         Help: "Total number of detected potential prompt injection attempts.",
     })
+    // InjectionDetections counts analyzer detections by source (query|memory)
+    // and action taken (blocked|flagged|dropped)
+    InjectionDetections = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "cmp_prompt_injection_detections_total",
+        Help: "Prompt-injection detections by input source and action taken.",
+    }, []string{"source", "action"})
     PolicyViolations = prometheus.NewCounter(prometheus.CounterOpts{
         Name: "cmp_security_policy_violations_total",
         Help: "Total number of responses blocked due to policy violations.",
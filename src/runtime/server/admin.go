@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+)
+
+// NewHandlers constructs the public API handler and the admin handler served
+// on CMP_ADMIN_ADDR. The admin handler hosts /metrics, /healthz, /readyz,
+// /version, the key admin API (/api/v1/admin/) and, with CMP_PPROF_ENABLED=true,
+// /debug/pprof/; the public handler serves none of them, so operational data
+// stays off the chat port.
+func NewHandlers(root string, provider runtimemodel.Provider, opts ...Option) (public, admin http.Handler) {
+	return newHandlers(root, provider, true, opts...)
+}
+
+// registerPprofRoutes exposes the runtime profiles (CPU, heap, goroutines, ...)
+// read by `ctx profile` and go tool pprof. They are only served on the admin
+// listener.
+func registerPprofRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority classes of chat requests, from highest to lowest priority.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// priorityHeader lets a client lower the priority class of its request.
+const priorityHeader = "X-Priority"
+
+var priorityClasses = []string{PriorityInteractive, PriorityBatch}
+
+// Defaults of the admission classes. Batch requests do not queue, so they are
+// shed as soon as every slot is taken.
+var defaultAdmissionClasses = map[string]admissionClass{
+	PriorityInteractive: {weight: 3, maxQueue: 64, maxWait: 30 * time.Second},
+	PriorityBatch:       {weight: 1, maxQueue: 0},
+}
+
+const defaultAdmissionRetryAfter = 2 * time.Second
+
+var (
+	// admissionInFlight is the number of chat requests holding a slot, by class.
+	admissionInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_admission_in_flight",
+		Help: "Chat requests holding an admission slot, by priority class.",
+	}, []string{"class"})
+	// admissionQueued is the number of chat requests waiting for a slot, by class.
+	admissionQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cmp_admission_queued",
+		Help: "Chat requests waiting for an admission slot, by priority class.",
+	}, []string{"class"})
+	// admissionShed counts requests refused with 429 (reason: queue_full|timeout).
+	admissionShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_admission_shed_total",
+		Help: "Chat requests refused because their priority class was saturated.",
+	}, []string{"class", "reason"})
+)
+
+// AdmissionConfig is server.admission: how many chat requests run at once and
+// how the slots are shared between priority classes. Admission control is off
+// while MaxConcurrent is 0.
+type AdmissionConfig struct {
+	MaxConcurrent int    `yaml:"max_concurrent"`
+	RetryAfter    string `yaml:"retry_after"` // Retry-After of shed requests (default 2s)
+	// DefaultClass is the class of requests from keys not listed in Keys
+	// (default interactive).
+	DefaultClass string                          `yaml:"default_class"`
+	Keys         map[string]string               `yaml:"keys"` // API key ID -> class
+	Classes      map[string]AdmissionClassConfig `yaml:"classes"`
+}
+
+// AdmissionClassConfig tunes one priority class. Unset fields keep the
+// class defaults; a configured 0 takes effect.
+type AdmissionClassConfig struct {
+	// Weight is the class's share of the slots that free up while several
+	// classes are waiting.
+	Weight *int `yaml:"weight"`
+	// MaxQueue is how many requests may wait for a slot; further requests
+	// are shed. 0 sheds every request that finds the slots taken.
+	MaxQueue *int `yaml:"max_queue"`
+	// MaxWait is how long a request may wait (Go duration); 0 waits until
+	// the request deadline.
+	MaxWait string `yaml:"max_wait"`
+	// MaxConcurrent caps the slots the class may hold; 0 allows all of them.
+	MaxConcurrent *int `yaml:"max_concurrent"`
+}
+
+// admission resolves server.admission, returning nil when admission control
+// is off.
+func (c HTTPConfig) admission() (*admissionController, error) {
+	a := c.Admission
+	if a.MaxConcurrent < 0 {
+		return nil, fmt.Errorf("server.admission.max_concurrent: must not be negative")
+	}
+	if a.MaxConcurrent == 0 {
+		return nil, nil
+	}
+	ac := &admissionController{capacity: a.MaxConcurrent, retryAfter: defaultAdmissionRetryAfter,
+		defaultClass: PriorityInteractive, keys: map[string]string{}, classes: map[string]*admissionClass{}}
+	if a.RetryAfter != "" {
+		d, err := time.ParseDuration(a.RetryAfter)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("server.admission.retry_after: invalid duration %q", a.RetryAfter)
+		}
+		ac.retryAfter = d
+	}
+	for name := range a.Classes {
+		if !isPriorityClass(name) {
+			return nil, fmt.Errorf("server.admission.classes: unknown class %q (want %s)", name, strings.Join(priorityClasses, " or "))
+		}
+	}
+	if a.DefaultClass != "" {
+		if !isPriorityClass(a.DefaultClass) {
+			return nil, fmt.Errorf("server.admission.default_class: unknown class %q", a.DefaultClass)
+		}
+		ac.defaultClass = a.DefaultClass
+	}
+	for key, class := range a.Keys {
+		if !isPriorityClass(class) {
+			return nil, fmt.Errorf("server.admission.keys.%s: unknown class %q", key, class)
+		}
+		ac.keys[key] = class
+	}
+	for rank, name := range priorityClasses {
+		cl, set := defaultAdmissionClasses[name], a.Classes[name]
+		cl.name, cl.rank = name, rank
+		if set.Weight != nil {
+			cl.weight = *set.Weight
+		}
+		if set.MaxQueue != nil {
+			cl.maxQueue = *set.MaxQueue
+		}
+		if set.MaxConcurrent != nil {
+			cl.maxConcurrent = *set.MaxConcurrent
+		}
+		if cl.weight < 0 || cl.maxQueue < 0 || cl.maxConcurrent < 0 {
+			return nil, fmt.Errorf("server.admission.classes.%s: weight, max_queue and max_concurrent must not be negative", name)
+		}
+		if set.MaxWait != "" {
+			d, err := time.ParseDuration(set.MaxWait)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("server.admission.classes.%s.max_wait: invalid duration %q", name, set.MaxWait)
+			}
+			cl.maxWait = d
+		}
+		ac.classes[name] = &cl
+	}
+	return ac, nil
+}
+
+func isPriorityClass(name string) bool {
+	for _, c := range priorityClasses {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// admissionController bounds the number of chat requests running at once.
+// Requests that find every slot taken wait in the queue of their priority
+// class; freed slots go to the waiting classes in proportion to their
+// weights, and requests of a class whose queue is full are shed.
+type admissionController struct {
+	capacity     int
+	retryAfter   time.Duration
+	defaultClass string
+	keys         map[string]string
+
+	mu      sync.Mutex
+	running int
+	classes map[string]*admissionClass
+}
+
+type admissionClass struct {
+	name          string
+	rank          int // index in priorityClasses; lower is more important
+	weight        int
+	maxQueue      int
+	maxWait       time.Duration
+	maxConcurrent int
+
+	running int
+	credit  int // smooth weighted round-robin state
+	queue   []*admissionWaiter
+}
+
+type admissionWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// errShed is returned by acquire for requests refused a slot.
+var errShed = errors.New("priority class saturated")
+
+// classify returns the priority class of r: the class of the caller's API key,
+// or the default class, lowered by the X-Priority header if it asks for a
+// lower class. The header cannot raise a request's priority.
+func (c *admissionController) classify(r *http.Request, p *runtimesecurity.Principal) string {
+	class := c.defaultClass
+	if p != nil {
+		if k, ok := c.keys[p.KeyID]; ok {
+			class = k
+		}
+	}
+	if h := c.classes[strings.ToLower(strings.TrimSpace(r.Header.Get(priorityHeader)))]; h != nil && h.rank > c.classes[class].rank {
+		class = h.name
+	}
+	return class
+}
+
+// admit reserves a slot for a chat request, waiting in the queue of its class
+// when the slots are taken. When the request is shed it writes 429 with
+// Retry-After (or 408 when the request deadline passed while queued) and
+// returns false; otherwise the caller must release the slot once done.
+func (c *admissionController) admit(w http.ResponseWriter, r *http.Request, p *runtimesecurity.Principal) (slot *admissionSlot, ok bool) {
+	if c == nil {
+		return &admissionSlot{}, true
+	}
+	class := c.classify(r, p)
+	w.Header().Set(priorityHeader, class)
+	release, reason, err := c.acquire(r.Context(), class, false)
+	switch {
+	case err == nil:
+		return &admissionSlot{c: c, class: class, held: release}, true
+	case errors.Is(err, context.DeadlineExceeded):
+		writeRequestTimeout(w)
+	case errors.Is(err, errShed):
+		admissionShed.WithLabelValues(class, reason).Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(c.retryAfter.Seconds(), 1)))))
+		http.Error(w, fmt.Sprintf("server busy: %s requests are being shed, retry later", class), http.StatusTooManyRequests)
+	}
+	// Otherwise the client went away while queued
+	return nil, false
+}
+
+// admissionSlot is the slot an admitted chat request holds. A request that
+// waits on something other than the server, such as an approval, suspends
+// its slot so other requests can run meanwhile, and resumes it before going
+// on.
+type admissionSlot struct {
+	c     *admissionController
+	class string
+
+	mu   sync.Mutex
+	held func() // releases the slot; nil while suspended
+}
+
+// release gives the slot back, if it is held.
+func (s *admissionSlot) release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held != nil {
+		s.held()
+		s.held = nil
+	}
+}
+
+// suspend gives the slot back until resume.
+func (s *admissionSlot) suspend() { s.release() }
+
+// resume takes a slot again. The request was admitted once, so it queues
+// past max_queue and max_wait rather than being shed, until ctx ends.
+func (s *admissionSlot) resume(ctx context.Context) error {
+	if s == nil || s.c == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.held != nil {
+		return nil
+	}
+	release, _, err := s.c.acquire(ctx, s.class, true)
+	if err != nil {
+		return err
+	}
+	s.held = release
+	return nil
+}
+
+type admissionSlotKey struct{}
+
+func withAdmissionSlot(ctx context.Context, s *admissionSlot) context.Context {
+	return context.WithValue(ctx, admissionSlotKey{}, s)
+}
+
+// admissionSlotFrom returns the slot of the request, or nil.
+func admissionSlotFrom(ctx context.Context) *admissionSlot {
+	s, _ := ctx.Value(admissionSlotKey{}).(*admissionSlot)
+	return s
+}
+
+// acquire takes a slot for class, queueing for it if allowed; resuming
+// requests always queue and wait until ctx ends. The reason of errShed is
+// queue_full or timeout; other errors are the context's.
+func (c *admissionController) acquire(ctx context.Context, class string, resuming bool) (func(), string, error) {
+	c.mu.Lock()
+	cl := c.classes[class]
+	if len(cl.queue) == 0 && c.hasSlot(cl) {
+		c.start(cl)
+		c.mu.Unlock()
+		return c.releaser(cl), "", nil
+	}
+	if !resuming && len(cl.queue) >= cl.maxQueue {
+		c.mu.Unlock()
+		return nil, "queue_full", errShed
+	}
+	wt := &admissionWaiter{ready: make(chan struct{})}
+	cl.queue = append(cl.queue, wt)
+	admissionQueued.WithLabelValues(class).Inc()
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if cl.maxWait > 0 && !resuming {
+		t := time.NewTimer(cl.maxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	reason, err := "", error(nil)
+	select {
+	case <-wt.ready:
+		return c.releaser(cl), "", nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		reason, err = "timeout", errShed
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if wt.granted {
+		// The slot was granted while giving up; use it after all
+		return c.releaser(cl), "", nil
+	}
+	for i, q := range cl.queue {
+		if q == wt {
+			cl.queue = append(cl.queue[:i], cl.queue[i+1:]...)
+			break
+		}
+	}
+	admissionQueued.WithLabelValues(class).Dec()
+	return nil, reason, err
+}
+
+// hasSlot reports whether cl may take a slot now. c.mu must be held.
+func (c *admissionController) hasSlot(cl *admissionClass) bool {
+	return c.running < c.capacity && (cl.maxConcurrent == 0 || cl.running < cl.maxConcurrent)
+}
+
+// start gives cl a slot. c.mu must be held.
+func (c *admissionController) start(cl *admissionClass) {
+	c.running++
+	cl.running++
+	admissionInFlight.WithLabelValues(cl.name).Inc()
+}
+
+func (c *admissionController) releaser(cl *admissionClass) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.running--
+			cl.running--
+			admissionInFlight.WithLabelValues(cl.name).Dec()
+			c.dispatch()
+		})
+	}
+}
+
+// dispatch hands free slots to waiting requests, picking the class by smooth
+// weighted round-robin; ties go to the higher priority. c.mu must be held.
+func (c *admissionController) dispatch() {
+	for c.running < c.capacity {
+		var next *admissionClass
+		total := 0
+		for _, name := range priorityClasses {
+			cl := c.classes[name]
+			if len(cl.queue) == 0 || !c.hasSlot(cl) {
+				continue
+			}
+			cl.credit += cl.weight
+			total += cl.weight
+			if next == nil || cl.credit > next.credit {
+				next = cl
+			}
+		}
+		if next == nil {
+			return
+		}
+		next.credit -= total
+		wt := next.queue[0]
+		next.queue = next.queue[1:]
+		admissionQueued.WithLabelValues(next.name).Dec()
+		wt.granted = true
+		c.start(next)
+		close(wt.ready)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimeagent "github.com/contexis-cmp/contexis/src/runtime/agent"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"go.uber.org/zap"
+)
+
+// agentEnabled reports whether the context answers with the agent loop.
+func agentEnabled(ctxModel *corectx.Context) bool {
+	return ctxModel.Agent != nil && ctxModel.Agent.Enabled
+}
+
+// newAgentLoop builds the agent loop of a chat request. The model may call the
+// context's HTTP, webhook, database and command tools, within their policies,
+// and memory_search when the request names a component. Every tool call passes
+// authorize first, e.g. to wait for an approval; with an injection analyzer
+// every observation is screened like retrieved chunks before the model sees
+// it. Tool calls are audited and streamed to WebSocket clients.
+func newAgentLoop(ctx context.Context, root string, openStore StoreOpener, ctxModel *corectx.Context, req ChatRequest, provider runtimemodel.Provider, params runtimemodel.Params, auditor *runtimesecurity.Auditor, authorize func(context.Context, runtimemodel.ToolCall) error, injection *runtimesecurity.InjectionAnalyzer, emit func(ChatEvent)) *runtimeagent.Loop {
+	tools := runtimeagent.NewToolbox()
+	if skipped := tools.AddContextTools(ctxModel.Tools, root, nil); len(skipped) > 0 {
+		logger.WithContext(ctx).Debug("agent tools without an HTTP endpoint or command are not offered", zap.Strings("tools", skipped))
+	}
+	if req.Component != "" {
+		tools.Add(runtimemodel.Tool{
+			Name:        "memory_search",
+			Description: "Search the knowledge base for passages relevant to a query",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{"type": "string"},
+					"top_k": map[string]interface{}{"type": "integer"},
+				},
+				"required": []interface{}{"query"},
+			},
+		}, memorySearchTool(root, openStore, ctxModel, req))
+	}
+	loop := &runtimeagent.Loop{
+		Provider:      provider,
+		Tools:         tools,
+		MaxIterations: ctxModel.Agent.MaxIterations,
+		Params:        params,
+		Authorize:     authorize,
+		OnStep: func(ctx context.Context, step runtimeagent.Step) {
+			recordAgentStep(ctx, auditor, req, step)
+			if emit != nil {
+				emit(ChatEvent{Type: EventToolCall, Name: step.Tool, Arguments: step.Arguments})
+				emit(ChatEvent{Type: EventToolResult, Name: step.Tool, Result: step.Observation, Message: step.Error})
+			}
+		},
+	}
+	if injection != nil {
+		loop.Observe = func(ctx context.Context, call runtimemodel.ToolCall, obs string) string {
+			verdict := injection.Analyze(ctx, obs)
+			if !verdict.Detected {
+				return obs
+			}
+			recordInjection(ctx, auditor, req.TenantID, "tool", verdict)
+			if verdict.Blocked {
+				return withheldObservation
+			}
+			return obs
+		}
+	}
+	return loop
+}
+
+// withheldObservation replaces a tool's output that the injection analyzer
+// blocked.
+const withheldObservation = "[output withheld: flagged as a possible prompt injection]"
+
+// memorySearchTool searches the request's component with the context's
+// retrieval settings and lists the passages found.
+func memorySearchTool(root string, openStore StoreOpener, ctxModel *corectx.Context, req ChatRequest) runtimeagent.Handler {
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		query, _ := args["query"].(string)
+		if strings.TrimSpace(query) == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		topK := retrievalTopK(ctxModel, req.TopK)
+		if n, ok := args["top_k"].(float64); ok && n > 0 {
+			topK = int(n)
+		}
+		store, err := openStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: req.Component, TenantID: req.TenantID, Retrieval: retrievalOptions(ctxModel)})
+		if err != nil {
+			return "", err
+		}
+		defer store.Close()
+		start := time.Now()
+		results, err := store.Search(ctx, query, topK)
+		memorySearchDuration.WithLabelValues(req.Component).Observe(time.Since(start).Seconds())
+		if err != nil {
+			return "", err
+		}
+		if len(results) == 0 {
+			return "no results", nil
+		}
+		var sb strings.Builder
+		for i, res := range results {
+			fmt.Fprintf(&sb, "[%d] %s\n", i+1, res.Content)
+		}
+		return sb.String(), nil
+	}
+}
+
+// recordAgentStep writes an audit event for a tool call of the agent loop.
+func recordAgentStep(ctx context.Context, auditor *runtimesecurity.Auditor, req ChatRequest, step runtimeagent.Step) {
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: req.TenantID,
+		Action: "agent:tool", Resource: step.Tool, Result: "success",
+		Attributes: map[string]interface{}{
+			"component": req.Component, "context": req.Context,
+			"iteration": step.Iteration, "duration_ms": step.DurationMS,
+		},
+	}
+	if step.Error != "" {
+		ev.Result, ev.Reason = "failure", step.Error
+		if step.Denied {
+			ev.Result = "denied"
+		}
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	auditor.Record(ctx, ev)
+}
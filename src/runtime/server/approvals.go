@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimeagent "github.com/contexis-cmp/contexis/src/runtime/agent"
+	runtimeapproval "github.com/contexis-cmp/contexis/src/runtime/approval"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"go.uber.org/zap"
+)
+
+// ApprovalDecision is the optional body of the approve/deny endpoints.
+type ApprovalDecision struct {
+	Reason string `json:"reason"`
+}
+
+// ApprovalList is the response payload of GET /api/v1/approvals.
+type ApprovalList struct {
+	Approvals []runtimeapproval.Request `json:"approvals"`
+}
+
+// approvalTimeout reads CMP_OOB_APPROVAL_TIMEOUT (a Go duration, default 5m).
+func approvalTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("CMP_OOB_APPROVAL_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return runtimeapproval.DefaultTimeout
+}
+
+// awaitApproval files an approval request for an action the client declared
+// in data.action and blocks until it is decided. It returns true when the
+// chat may proceed; otherwise the error response has been written.
+func awaitApproval(w http.ResponseWriter, r *http.Request, store *runtimeapproval.Store, auditor *runtimesecurity.Auditor, req ChatRequest, action string) bool {
+	ctx := r.Context()
+	decided, err := requestApproval(ctx, store, auditor, req, action, req.Data)
+	if err != nil {
+		if ctx.Err() == nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return false
+	}
+	w.Header().Set("X-Approval-ID", decided.ID)
+	if decided.Status == runtimeapproval.StatusApproved {
+		return true
+	}
+	http.Error(w, denialMessage(decided), http.StatusForbidden)
+	return false
+}
+
+// toolApprovalGate returns the Authorize hook of an agent loop: calls of tools
+// listed in CMP_OOB_REQUIRED_ACTIONS wait for an approval of the call and its
+// arguments, and are denied unless it is approved.
+func toolApprovalGate(store *runtimeapproval.Store, auditor *runtimesecurity.Auditor, pol runtimesecurity.Policy, req ChatRequest) func(context.Context, runtimemodel.ToolCall) error {
+	return func(ctx context.Context, call runtimemodel.ToolCall) error {
+		if !pol.RequiresOutOfBand(call.Name) {
+			return nil
+		}
+		decided, err := requestApproval(ctx, store, auditor, req, call.Name, call.Arguments)
+		if err != nil {
+			return err
+		}
+		if decided.Status != runtimeapproval.StatusApproved {
+			return fmt.Errorf("%w: %s", runtimeagent.ErrDenied, denialMessage(decided))
+		}
+		return nil
+	}
+}
+
+// requestApproval files an approval request for action and waits until it is
+// decided. The request's admission slot is given up while it waits, so parked
+// requests do not hold back others.
+func requestApproval(ctx context.Context, store *runtimeapproval.Store, auditor *runtimesecurity.Auditor, req ChatRequest, action string, args map[string]interface{}) (runtimeapproval.Request, error) {
+	pending := runtimeapproval.Request{
+		Action:    action,
+		TenantID:  req.TenantID,
+		Component: req.Component,
+		Context:   req.Context,
+		Query:     req.Query,
+		RequestID: requestIDFrom(ctx),
+		Arguments: args,
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		pending.Requester = p.KeyID
+	}
+	created, err := store.Create(pending, approvalTimeout())
+	if err != nil {
+		return runtimeapproval.Request{}, fmt.Errorf("failed to file approval request: %w", err)
+	}
+	recordApproval(ctx, auditor, created, "pending")
+	logger.WithContext(ctx).Info("sensitive action awaiting approval",
+		zap.String("approval_id", created.ID), zap.String("action", action), zap.String("tenant", req.TenantID))
+	if emit := chatEventSinkFrom(ctx); emit != nil {
+		emit(ChatEvent{Type: EventApprovalRequired, Name: action, Result: created})
+	}
+
+	slot := admissionSlotFrom(ctx)
+	slot.suspend()
+	decided, err := store.Wait(ctx, created.ID)
+	if err != nil {
+		return runtimeapproval.Request{}, err
+	}
+	if err := slot.resume(ctx); err != nil {
+		return runtimeapproval.Request{}, err
+	}
+	if decided.Status == runtimeapproval.StatusApproved {
+		recordApproval(ctx, auditor, decided, "allowed")
+		return decided, nil
+	}
+	recordApproval(ctx, auditor, decided, "denied")
+	runtimesecurity.BlockedResponses.Inc()
+	return decided, nil
+}
+
+// denialMessage explains why an approval request was not approved.
+func denialMessage(decided runtimeapproval.Request) string {
+	msg := "action denied by approver"
+	if decided.Status == runtimeapproval.StatusExpired {
+		msg = "approval request expired"
+	}
+	if decided.Reason != "" {
+		msg += ": " + decided.Reason
+	}
+	return msg
+}
+
+func recordApproval(ctx context.Context, auditor *runtimesecurity.Auditor, req runtimeapproval.Request, result string) {
+	auditor.Record(ctx, runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: req.TenantID, ActorKeyID: req.Requester,
+		Action: req.Action, Resource: "chat", Result: result, Reason: "oob_" + req.Status,
+		Attributes: map[string]interface{}{"approval_id": req.ID, "decided_by": req.DecidedBy},
+	})
+}
+
+// registerApprovalRoutes wires the approvals API. Listing requires approvals:read
+// and deciding approvals:write when auth is enabled; tenant-bound keys only see
+// and decide their own tenant's requests.
+func registerApprovalRoutes(mux *http.ServeMux, store *runtimeapproval.Store, guard *requestGuard) {
+	mux.HandleFunc("GET /api/v1/approvals", func(w http.ResponseWriter, r *http.Request) {
+		res := runtimesecurity.Resource{Type: "approvals", Tenant: r.URL.Query().Get("tenant_id")}
+		principal, ok := guard.authorize(w, r, "approvals:list", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		list, err := store.List(r.URL.Query().Get("status"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tenant := res.Tenant
+		if principal != nil && principal.TenantID != "" {
+			tenant = principal.TenantID
+		}
+		out := ApprovalList{Approvals: []runtimeapproval.Request{}}
+		for _, a := range list {
+			if tenant == "" || a.TenantID == tenant {
+				out.Approvals = append(out.Approvals, a)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+
+	mux.HandleFunc("GET /api/v1/approvals/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a, err := store.Get(r.PathValue("id"))
+		if err != nil {
+			writeApprovalError(w, err)
+			return
+		}
+		res := runtimesecurity.Resource{Type: "approvals", Name: a.Action, Tenant: a.TenantID}
+		if _, ok := guard.authorize(w, r, "approvals:read", res, runtimesecurity.ActionRead); !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a)
+	})
+
+	decide := func(approve bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			a, err := store.Get(r.PathValue("id"))
+			if err != nil {
+				writeApprovalError(w, err)
+				return
+			}
+			res := runtimesecurity.Resource{Type: "approvals", Name: a.Action, Tenant: a.TenantID}
+			principal, ok := guard.authorize(w, r, "approvals:decide", res, runtimesecurity.ActionWrite)
+			if !ok {
+				return
+			}
+			var body ApprovalDecision
+			if r.ContentLength != 0 {
+				if !decodeJSONBody(w, r, &body) {
+					return
+				}
+			}
+			by := "api"
+			if principal != nil {
+				by = principal.KeyID
+			}
+			a, err = store.Decide(a.ID, approve, by, body.Reason)
+			if err != nil {
+				writeApprovalError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(a)
+		}
+	}
+	mux.HandleFunc("POST /api/v1/approvals/{id}/approve", decide(true))
+	mux.HandleFunc("POST /api/v1/approvals/{id}/deny", decide(false))
+}
+
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, runtimeapproval.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, runtimeapproval.ErrDecided):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// defaultMaxNewTokens bounds the answer when the context sets no guardrails.max_tokens.
+const defaultMaxNewTokens = 256
+
+var promptChunksDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_prompt_chunks_dropped_total",
+	Help: "Retrieved memory chunks dropped to fit the model context window.",
+}, []string{"component"})
+
+// promptBudget fits a rendered prompt into the model context window, keeping
+// reserve tokens free for the answer. A zero window disables it.
+type promptBudget struct {
+	window  int
+	reserve int
+	count   func(string) int
+}
+
+// outputTokens returns the answer length to request: guardrails.max_tokens, or
+// defaultMaxNewTokens.
+func outputTokens(ctxModel *corectx.Context) int {
+	if ctxModel != nil && ctxModel.Guardrails.MaxTokens > 0 {
+		return ctxModel.Guardrails.MaxTokens
+	}
+	return defaultMaxNewTokens
+}
+
+// chatBudget measures prompts with the tokenizer of the chain's primary
+// provider against the smallest context window in the chain. Without a chain,
+// or a known window, prompts are not budgeted.
+func chatBudget(ctx context.Context, chain *runtimemodel.FallbackProvider, reserve int) promptBudget {
+	if chain == nil {
+		return promptBudget{}
+	}
+	return promptBudget{
+		window:  chain.ContextWindow(),
+		reserve: reserve,
+		count: func(prompt string) int {
+			n, _ := chain.CountTokens(ctx, prompt)
+			return n
+		},
+	}
+}
+
+// logDroppedChunks records the chunks left out of a prompt to fit the window.
+func logDroppedChunks(ctx context.Context, component string, f budgetFit, window, reserve int) {
+	promptChunksDropped.WithLabelValues(component).Add(float64(len(f.dropped)))
+	ids := make([]string, len(f.dropped))
+	scores := make([]float64, len(f.dropped))
+	for i, res := range f.dropped {
+		ids[i], scores[i] = res.ID, res.Score
+	}
+	logger.WithContext(ctx).Info("dropped retrieved chunks to fit the model context window",
+		zap.String("component", component),
+		zap.Strings("dropped_ids", ids),
+		zap.Float64s("dropped_scores", scores),
+		zap.Int("kept", len(f.kept)),
+		zap.Int("prompt_tokens", f.tokens),
+		zap.Int("reserved_tokens", reserve),
+		zap.Int("context_window", window),
+	)
+}
+
+// budgetFit is the outcome of fitting a prompt: the prompt rendered with the
+// kept results, the results dropped lowest score first, and its token count.
+type budgetFit struct {
+	rendered string
+	kept     []runtimememory.SearchResult
+	dropped  []runtimememory.SearchResult
+	tokens   int
+}
+
+// promptTooLargeError reports a prompt that exceeds the window even without
+// retrieved results.
+type promptTooLargeError struct {
+	tokens, window, reserve int
+}
+
+func (e *promptTooLargeError) Error() string {
+	return fmt.Sprintf("prompt of %d tokens exceeds the model context window of %d tokens (%d reserved for the answer)", e.tokens, e.window, e.reserve)
+}
+
+// fit renders results and, while the prompt does not fit, drops the
+// lowest-scoring result and renders again. The order of the kept results is
+// preserved.
+func (b promptBudget) fit(results []runtimememory.SearchResult, render func([]runtimememory.SearchResult) (string, error)) (budgetFit, error) {
+	f := budgetFit{kept: results}
+	var err error
+	if f.rendered, err = render(results); err != nil || b.window <= 0 {
+		return f, err
+	}
+	// Indices of results from lowest to highest score
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return results[order[i]].Score < results[order[j]].Score })
+	drop := map[int]bool{}
+	for {
+		f.tokens = b.count(f.rendered)
+		if f.tokens+b.reserve <= b.window {
+			return f, nil
+		}
+		if len(drop) == len(results) {
+			return f, &promptTooLargeError{tokens: f.tokens, window: b.window, reserve: b.reserve}
+		}
+		i := order[len(drop)]
+		drop[i] = true
+		f.dropped = append(f.dropped, results[i])
+		f.kept = make([]runtimememory.SearchResult, 0, len(results)-len(drop))
+		for j, res := range results {
+			if !drop[j] {
+				f.kept = append(f.kept, res)
+			}
+		}
+		if f.rendered, err = render(f.kept); err != nil {
+			return f, err
+		}
+	}
+}
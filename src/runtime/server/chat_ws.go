@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimeguardrails "github.com/contexis-cmp/contexis/src/runtime/guardrails"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Chat WebSocket event types sent by the server.
+const (
+	EventToken      = "token"
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventDone       = "done"
+	EventError      = "error"
+	// EventApprovalRequired is sent while a sensitive action waits for an
+	// out-of-band decision; Result carries the pending approval request.
+	EventApprovalRequired = "approval_required"
+)
+
+// ChatSocketMessage is a client frame on /api/v1/chat/ws. Type defaults to "chat";
+// ID is echoed on every event produced for the message.
+type ChatSocketMessage struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	ChatRequest
+}
+
+// ChatEvent is a server frame on /api/v1/chat/ws.
+type ChatEvent struct {
+	Type      string                 `json:"type"`
+	ID        string                 `json:"id,omitempty"`
+	Content   string                 `json:"content,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Result    interface{}            `json:"result,omitempty"`
+	Response  *ChatResponse          `json:"response,omitempty"`
+	Code      int                    `json:"code,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+const (
+	wsMaxMessageBytes = 1 << 20
+	wsWriteWait       = 10 * time.Second
+)
+
+type chatEventSinkKey struct{}
+
+// withChatEventSink attaches a sink that receives incremental chat events.
+func withChatEventSink(ctx context.Context, sink func(ChatEvent)) context.Context {
+	return context.WithValue(ctx, chatEventSinkKey{}, sink)
+}
+
+// chatEventSinkFrom returns the event sink for a streaming request, or nil.
+func chatEventSinkFrom(ctx context.Context) func(ChatEvent) {
+	sink, _ := ctx.Value(chatEventSinkKey{}).(func(ChatEvent))
+	return sink
+}
+
+// canStreamLive reports whether tokens may be forwarded before the full answer is
+// known. Output guardrails that can rewrite or reject the answer (response schema,
+// required citations, PII redaction/blocking, output filters, blocking grounding
+// checks) force buffered delivery instead.
+func canStreamLive(ctxModel *corectx.Context, pii *runtimesecurity.PIIEngine, filters *runtimeguardrails.OutputFilters, grounding *runtimeguardrails.GroundingCheck, requireCitation bool) bool {
+	if requireCitation || filters.Len() > 0 || grounding.Blocks() {
+		return false
+	}
+	if schema, _ := runtimeguardrails.ResponseSchema(ctxModel); schema != nil {
+		return false
+	}
+	switch pii.Mode {
+	case "", "off", "allow":
+		return true
+	}
+	return false
+}
+
+// generate runs inference, streaming tokens to onToken when both the caller and
+// the provider support it.
+func generate(ctx context.Context, provider runtimemodel.Provider, prompt string, params runtimemodel.Params, onToken func(string) error) (string, error) {
+	if sp, ok := provider.(runtimemodel.StreamingProvider); ok && onToken != nil {
+		return sp.GenerateStream(ctx, prompt, params, onToken)
+	}
+	return provider.Generate(ctx, prompt, params)
+}
+
+// wsPingInterval reads CMP_WS_PING_INTERVAL (Go duration, default 30s).
+func wsPingInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("CMP_WS_PING_INTERVAL")); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// newUpgrader accepts same-origin upgrades, plus the origins server.cors allows,
+// so browsers can open the socket from exactly the sites that may call the API.
+func newUpgrader(cors CORSConfig) *websocket.Upgrader {
+	u := &websocket.Upgrader{ReadBufferSize: 4096, WriteBufferSize: 4096}
+	u.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if o, err := url.Parse(origin); err == nil && strings.EqualFold(o.Host, r.Host) {
+			return true
+		}
+		return cors.allowsOrigin(origin)
+	}
+	return u
+}
+
+// registerChatSocket wires GET /api/v1/chat/ws. Authentication, rate limiting and
+// RBAC (chat:execute) run at upgrade time; each message is then served by the chat
+// handler, which applies the same checks and security policies per query.
+func registerChatSocket(mux *http.ServeMux, guard *requestGuard, cors CORSConfig) {
+	upgrader := newUpgrader(cors)
+	pingInterval := wsPingInterval()
+	mux.HandleFunc("GET /api/v1/chat/ws", func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.URL.Query().Get("tenant_id")
+		if tenantID == "" {
+			tenantID = r.Header.Get("X-Tenant-ID")
+		}
+		chatRes := runtimesecurity.Resource{Type: "chat", Name: "chat", Tenant: tenantID}
+		if _, ok := guard.authorize(w, r, "chat:connect", chatRes, runtimesecurity.ActionExecute); !ok {
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already written an error response
+			return
+		}
+		defer conn.Close()
+		s := &chatSocket{conn: conn, mux: mux, upgrade: r, tenantID: tenantID}
+		s.serve(r.Context(), pingInterval)
+	})
+}
+
+// chatSocket serves one WebSocket session. Messages are processed sequentially;
+// a reader goroutine keeps handling control frames (pong, close) while a query runs.
+type chatSocket struct {
+	conn     *websocket.Conn
+	mux      http.Handler
+	upgrade  *http.Request
+	tenantID string
+}
+
+func (s *chatSocket) serve(ctx context.Context, pingInterval time.Duration) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pongWait := 2 * pingInterval
+	s.conn.SetReadLimit(wsMaxMessageBytes)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	msgs := make(chan []byte)
+	go func() {
+		defer cancel()
+		defer close(msgs)
+		for {
+			_, data, err := s.conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+			select {
+			case msgs <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case data, ok := <-msgs:
+			if !ok {
+				return
+			}
+			if err := s.handle(ctx, data); err != nil {
+				logger.WithContext(ctx).Debug("chat websocket closed", zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// handle runs one client message through the chat pipeline and streams its events.
+// It returns an error only when the connection can no longer be written to.
+func (s *chatSocket) handle(ctx context.Context, data []byte) error {
+	var msg ChatSocketMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return s.send(ChatEvent{Type: EventError, Code: http.StatusBadRequest, Message: "invalid message: " + err.Error()})
+	}
+	if msg.Type != "" && msg.Type != "chat" {
+		return s.send(ChatEvent{Type: EventError, ID: msg.ID, Code: http.StatusBadRequest, Message: "unsupported message type: " + msg.Type})
+	}
+	if msg.TenantID == "" {
+		msg.TenantID = s.tenantID
+	}
+
+	var writeErr error
+	streamed := false
+	sink := func(ev ChatEvent) {
+		if writeErr != nil {
+			return
+		}
+		ev.ID = msg.ID
+		if ev.Type == EventToken {
+			streamed = true
+		}
+		writeErr = s.send(ev)
+	}
+	body, _ := json.Marshal(msg.ChatRequest)
+	reqCtx := withChatEventSink(context.WithValue(ctx, "request_id", generateRequestID()), sink)
+	r, err := http.NewRequestWithContext(reqCtx, http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		return s.send(ChatEvent{Type: EventError, ID: msg.ID, Code: http.StatusInternalServerError, Message: err.Error()})
+	}
+	for k, vs := range s.upgrade.Header {
+		switch http.CanonicalHeaderKey(k) {
+		case "Upgrade", "Connection", "Content-Length", "Content-Type":
+			continue
+		}
+		if strings.HasPrefix(http.CanonicalHeaderKey(k), "Sec-Websocket-") {
+			continue
+		}
+		r.Header[k] = vs
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.RemoteAddr = s.upgrade.RemoteAddr
+
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	s.mux.ServeHTTP(rec, r)
+	if writeErr != nil {
+		return writeErr
+	}
+	if rec.status >= 300 {
+		return s.send(ChatEvent{Type: EventError, ID: msg.ID, Code: rec.status, Message: strings.TrimSpace(rec.body.String())})
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err != nil {
+		return s.send(ChatEvent{Type: EventError, ID: msg.ID, Code: http.StatusInternalServerError, Message: "decode response: " + err.Error()})
+	}
+	// Buffered answers (no streaming provider, or output guardrails active) arrive as one token
+	if !streamed && resp.Rendered != "" {
+		if err := s.send(ChatEvent{Type: EventToken, ID: msg.ID, Content: resp.Rendered}); err != nil {
+			return err
+		}
+	}
+	return s.send(ChatEvent{Type: EventDone, ID: msg.ID, Response: &resp})
+}
+
+func (s *chatSocket) send(ev ChatEvent) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return s.conn.WriteJSON(ev)
+}
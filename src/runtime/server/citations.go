@@ -0,0 +1,58 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+)
+
+// Source attributes part of a chat answer to a retrieved memory chunk.
+type Source struct {
+	// Index is the 1-based position of the chunk in the search results; answers
+	// may cite it as "[n]".
+	Index int     `json:"index"`
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	// Start and End are byte offsets of the chunk content within the rendered prompt.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Cited reports whether the answer references this source.
+	Cited bool `json:"cited"`
+}
+
+// injectedSources returns the search results whose content was rendered into the prompt.
+func injectedSources(prompt string, results []runtimememory.SearchResult) []Source {
+	var sources []Source
+	for i, res := range results {
+		if strings.TrimSpace(res.Content) == "" {
+			continue
+		}
+		start := strings.Index(prompt, res.Content)
+		if start < 0 {
+			continue
+		}
+		sources = append(sources, Source{
+			Index: i + 1,
+			ID:    res.ID,
+			Score: res.Score,
+			Start: start,
+			End:   start + len(res.Content),
+		})
+	}
+	return sources
+}
+
+// markCited flags the sources referenced by answer, either by document ID or by a
+// "[n]" index marker, and reports whether at least one source was cited.
+func markCited(answer string, sources []Source) bool {
+	cited := false
+	for i := range sources {
+		if (sources[i].ID != "" && strings.Contains(answer, sources[i].ID)) ||
+			strings.Contains(answer, "["+strconv.Itoa(sources[i].Index)+"]") {
+			sources[i].Cited = true
+			cited = true
+		}
+	}
+	return cited
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	"github.com/contexis-cmp/contexis/src/runtime/secrets"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// ContextSummary is an entry of GET /api/v1/contexts. Name is the value to
+// pass as "context" in chat requests.
+type ContextSummary struct {
+	Name           string   `json:"name"`
+	Version        string   `json:"version"`
+	Description    string   `json:"description,omitempty"`
+	Persona        string   `json:"persona"`
+	Capabilities   []string `json:"capabilities,omitempty"`
+	Tools          []string `json:"tools,omitempty"`
+	TenantOverride bool     `json:"tenant_override,omitempty"`
+	SHA            string   `json:"sha"`
+}
+
+// ContextListResponse is the response payload for GET /api/v1/contexts.
+type ContextListResponse struct {
+	TenantID string           `json:"tenant_id,omitempty"`
+	Contexts []ContextSummary `json:"contexts"`
+}
+
+// ContextResponse is the response payload for GET /api/v1/contexts/{name}:
+// the resolved context with secrets redacted.
+type ContextResponse struct {
+	TenantID       string           `json:"tenant_id,omitempty"`
+	TenantOverride bool             `json:"tenant_override,omitempty"`
+	SHA            string           `json:"sha"`
+	Context        *corectx.Context `json:"context"`
+}
+
+// registerContextRoutes wires the context introspection endpoints. Both
+// require context:read when auth is enabled; tenant-bound keys only see their
+// own tenant's contexts. The tenant is taken from the tenant_id query parameter.
+func registerContextRoutes(mux *http.ServeMux, ctxSvc *runtimecontext.ContextService, guard *requestGuard) {
+	mux.HandleFunc("GET /api/v1/contexts", func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.URL.Query().Get("tenant_id")
+		res := runtimesecurity.Resource{Type: "context", Tenant: tenantID}
+		principal, ok := guard.authorize(w, r, "context:list", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			tenantID = principal.TenantID
+		}
+		names, err := ctxSvc.ContextNames(tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := ContextListResponse{TenantID: tenantID, Contexts: []ContextSummary{}}
+		for _, name := range names {
+			c, err := ctxSvc.ResolveContext(tenantID, name)
+			if err != nil {
+				// Invalid contexts cannot be used for chat either
+				continue
+			}
+			sha, _ := c.GetSHA()
+			summary := ContextSummary{
+				Name:           name,
+				Version:        c.Version,
+				Description:    c.Description,
+				Persona:        c.Role.Persona,
+				Capabilities:   c.Role.Capabilities,
+				TenantOverride: hasTenantOverride(ctxSvc, tenantID, name),
+				SHA:            sha,
+			}
+			for _, t := range c.Tools {
+				summary.Tools = append(summary.Tools, t.Name)
+			}
+			out.Contexts = append(out.Contexts, summary)
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("GET /api/v1/contexts/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !componentNameRe.MatchString(name) {
+			http.Error(w, "invalid context name", http.StatusBadRequest)
+			return
+		}
+		tenantID := r.URL.Query().Get("tenant_id")
+		res := runtimesecurity.Resource{Type: "context", Name: name, Tenant: tenantID}
+		principal, ok := guard.authorize(w, r, "context:read", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			tenantID = principal.TenantID
+		}
+		names, err := ctxSvc.ContextNames(tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !containsString(names, name) {
+			http.Error(w, "context not found", http.StatusNotFound)
+			return
+		}
+		c, err := ctxSvc.ResolveContext(tenantID, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		sha, _ := c.GetSHA()
+		writeJSON(w, http.StatusOK, ContextResponse{
+			TenantID:       tenantID,
+			TenantOverride: hasTenantOverride(ctxSvc, tenantID, name),
+			SHA:            sha,
+			Context:        redactContext(c),
+		})
+	})
+}
+
+func hasTenantOverride(ctxSvc *runtimecontext.ContextService, tenantID, name string) bool {
+	if tenantID == "" {
+		return false
+	}
+	_, err := os.Stat(ctxSvc.TenantOverridePath(tenantID, name))
+	return err == nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// secretNameRe matches metadata keys and URI query parameters whose values
+// are credentials.
+var secretNameRe = regexp.MustCompile(`(?i)(secret|token|password|passwd|api[_-]?key|credential|signature|private[_-]?key|authorization|(^|[_-])auth($|[_-]))`)
+
+const redacted = "REDACTED"
+
+// redactContext returns a copy of c without credentials: user info and
+// secret query parameters in tool URIs and database DSNs, secret webhook
+// headers, inline webhook signing secrets, and secret metadata values.
+func redactContext(c *corectx.Context) *corectx.Context {
+	cp := *c
+	cp.Tools = make([]corectx.Tool, len(c.Tools))
+	for i, t := range c.Tools {
+		t.URI = redactURI(t.URI)
+		if t.Webhook != nil {
+			w := *t.Webhook
+			w.URL = redactURI(w.URL)
+			if w.Headers != nil {
+				w.Headers = make(map[string]string, len(t.Webhook.Headers))
+				for k, v := range t.Webhook.Headers {
+					if secretNameRe.MatchString(k) && !isCredentialRef(v) {
+						v = redacted
+					}
+					w.Headers[k] = v
+				}
+			}
+			if w.SecretRef != "" && !isCredentialRef(w.SecretRef) {
+				w.SecretRef = redacted
+			}
+			t.Webhook = &w
+		}
+		if t.Database != nil {
+			d := *t.Database
+			d.DSN = redactDSN(d.Driver, d.DSN)
+			t.Database = &d
+		}
+		cp.Tools[i] = t
+	}
+	if c.Metadata != nil {
+		cp.Metadata = make(map[string]string, len(c.Metadata))
+		for k, v := range c.Metadata {
+			if secretNameRe.MatchString(k) {
+				v = redacted
+			}
+			cp.Metadata[k] = v
+		}
+	}
+	return &cp
+}
+
+// isCredentialRef reports whether v names a credential (a secret:// or env:
+// reference) rather than holding it.
+func isCredentialRef(v string) bool {
+	return secrets.IsRef(v) || strings.HasPrefix(v, "env:")
+}
+
+// redactDSN redacts the credentials of a database DSN. References and sqlite
+// paths are kept; a DSN that is not a scheme://host URL is redacted whole.
+func redactDSN(driver, dsn string) string {
+	if dsn == "" || isCredentialRef(dsn) || strings.EqualFold(driver, "sqlite") {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err != nil || u.Scheme == "" || u.Opaque != "" {
+		return redacted
+	}
+	return redactURI(dsn)
+}
+
+func redactURI(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return raw
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if secretNameRe.MatchString(k) {
+				q.Set(k, redacted)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
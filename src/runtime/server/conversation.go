@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+	"go.uber.org/zap"
+)
+
+// Defaults of memory.summarization.
+const (
+	defaultSummaryTriggerTurns = 20
+	defaultSummaryKeepRecent   = 4
+)
+
+// sessionOf returns the request's session, from session_id or the X-Session-ID header.
+func sessionOf(r *http.Request, req ChatRequest) string {
+	if req.SessionID != "" {
+		return req.SessionID
+	}
+	return r.Header.Get("X-Session-ID")
+}
+
+// summaryPolicy reads memory.summarization; ok is false when it is disabled.
+func summaryPolicy(ctxModel *corectx.Context) (p runtimememory.SummaryPolicy, ok bool) {
+	s := ctxModel.Memory.Summarization
+	if s == nil || !s.Enabled {
+		return p, false
+	}
+	p = runtimememory.SummaryPolicy{TriggerTurns: s.TriggerTurns, TriggerTokens: s.TriggerTokens, KeepRecent: s.KeepRecent}
+	if p.TriggerTurns <= 0 && p.TriggerTokens <= 0 {
+		p.TriggerTurns = defaultSummaryTriggerTurns
+	}
+	if p.KeepRecent <= 0 {
+		p.KeepRecent = ctxModel.Memory.MaxHistory
+	}
+	if p.KeepRecent <= 0 {
+		p.KeepRecent = defaultSummaryKeepRecent
+	}
+	return p, true
+}
+
+// conversationSummaryTimeout bounds a background summary of a session.
+const conversationSummaryTimeout = 2 * time.Minute
+
+// sessionMemory is the conversation of a chat request's session. The session
+// stays locked until the request ends, or until the background summary the
+// request's turn starts is written.
+type sessionMemory struct {
+	store       *runtimememory.ConversationStore
+	component   string
+	conv        runtimememory.Conversation
+	policy      runtimememory.SummaryPolicy
+	summarizing bool
+	summarize   runtimememory.SummarizeFunc
+	unlock      func()
+}
+
+// loadConversation locks and opens the session's conversation when the
+// context keeps history (memory.max_history or memory.summarization). It
+// returns nil when the request has no session, the context keeps no history
+// or the session cannot be read.
+func loadConversation(ctx context.Context, root string, ctxModel *corectx.Context, req ChatRequest, sessionID string, summarize runtimememory.SummarizeFunc) *sessionMemory {
+	policy, summarizing := summaryPolicy(ctxModel)
+	if sessionID == "" || req.Component == "" || (ctxModel.Memory.MaxHistory <= 0 && !summarizing) {
+		return nil
+	}
+	store, err := runtimememory.OpenConversation(root, req.Component, req.TenantID, req.UserID, sessionID)
+	if err != nil {
+		logger.WithContext(ctx).Warn("conversation memory unavailable", zap.Error(err))
+		return nil
+	}
+	unlock, err := store.Lock(ctx)
+	if err != nil {
+		logger.WithContext(ctx).Warn("conversation memory unavailable", zap.Error(err))
+		return nil
+	}
+	conv, err := store.Load()
+	if err != nil {
+		unlock()
+		logger.WithContext(ctx).Warn("conversation memory unreadable", zap.Error(err))
+		return nil
+	}
+	return &sessionMemory{store: store, component: req.Component, conv: conv, policy: policy, summarizing: summarizing, summarize: summarize, unlock: unlock}
+}
+
+// release unlocks the session unless a background summary took it over.
+func (s *sessionMemory) release() {
+	if s.unlock != nil {
+		s.unlock()
+		s.unlock = nil
+	}
+}
+
+// record appends the request's turn. When the turns after the summary now
+// exceed the trigger, older turns are summarized and the log compacted in
+// the background; the session stays locked meanwhile, so its next request
+// gets the summary without waiting on the model here.
+func (s *sessionMemory) record(ctx context.Context, turn runtimememory.ConversationTurn) {
+	err := s.store.AppendAt(turn, s.conv.Seq)
+	if errors.Is(err, runtimememory.ErrConversationConflict) {
+		// Another process recorded a turn meanwhile; keep this one after it
+		runtimememory.ConversationConflicts.WithLabelValues(s.component).Inc()
+		logger.WithContext(ctx).Warn("conversation changed concurrently", zap.Error(err))
+		err = s.store.Append(turn)
+	}
+	if err != nil {
+		logger.WithContext(ctx).Warn("conversation turn not recorded", zap.Error(err))
+		return
+	}
+	if !s.summarizing || s.summarize == nil || !s.policy.Due(append(s.conv.Turns, turn)) {
+		return
+	}
+	unlock := s.unlock
+	s.unlock = nil
+	bg := context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			if unlock != nil {
+				unlock()
+			}
+		}()
+		ctx, cancel := context.WithTimeout(bg, conversationSummaryTimeout)
+		defer cancel()
+		log := logger.WithContext(ctx)
+		conv, err := s.store.Load()
+		if err != nil {
+			log.Warn("conversation memory unreadable", zap.Error(err))
+			return
+		}
+		// On failure the turns stay unsummarized and are retried after the next turn
+		if _, done, err := s.store.Summarize(ctx, conv, s.policy, s.summarize); err != nil || !done {
+			if err != nil {
+				log.Warn("conversation summary failed", zap.Error(err))
+			}
+			return
+		}
+		if _, err := s.store.Compact(); err != nil {
+			log.Warn("conversation compaction failed", zap.Error(err))
+		}
+	}()
+}
+
+// conversationSummarizer summarizes with the component's provider chain and
+// records the tokens against the request, or returns nil without a provider.
+func conversationSummarizer(router *runtimemodel.Router, ledger *runtimeusage.Ledger, pricing *runtimeusage.Pricing, quotas *runtimeusage.QuotaManager, req ChatRequest) runtimememory.SummarizeFunc {
+	chain := router.For(req.Component, req.Context)
+	if chain == nil {
+		return nil
+	}
+	return func(ctx context.Context, prompt string) (string, error) {
+		mctx, meter := runtimemodel.WithUsageMeter(ctx)
+		out, err := chain.Generate(mctx, prompt, runtimemodel.Params{Temperature: 0, MaxNewTokens: 256})
+		recordUsage(ctx, ledger, pricing, quotas, req, chain.Served(), meter.Total())
+		return out, err
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/contexis-cmp/contexis/src/runtime/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NewGRPCServer returns a gRPC server exposing the Chat, Memory and Health services
+// with reflection enabled. Calls are dispatched in-process to handler, so the gRPC
+// API shares the HTTP security middleware (auth, rate limiting, RBAC, PII and
+// prompt-injection policies). Incoming metadata is forwarded as HTTP headers.
+func NewGRPCServer(handler http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	gw := &grpcGateway{handler: handler}
+	pb.RegisterChatServiceServer(s, gw)
+	pb.RegisterMemoryServiceServer(s, gw)
+	pb.RegisterHealthServiceServer(s, gw)
+	reflection.Register(s)
+	return s
+}
+
+type grpcGateway struct {
+	pb.UnimplementedChatServiceServer
+	pb.UnimplementedMemoryServiceServer
+	pb.UnimplementedHealthServiceServer
+	handler http.Handler
+}
+
+func (g *grpcGateway) Chat(ctx context.Context, in *pb.ChatRequest) (*pb.ChatResponse, error) {
+	req := ChatRequest{
+		TenantID:   in.GetTenantId(),
+		Context:    in.GetContext(),
+		Component:  in.GetComponent(),
+		Query:      in.GetQuery(),
+		TopK:       int(in.GetTopK()),
+		Data:       in.GetData().AsMap(),
+		PromptFile: in.GetPromptFile(),
+	}
+	var resp ChatResponse
+	if err := g.dispatch(ctx, http.MethodPost, "/api/v1/chat", req, &resp); err != nil {
+		return nil, err
+	}
+	out := &pb.ChatResponse{Rendered: resp.Rendered}
+	for _, s := range resp.Sources {
+		out.Sources = append(out.Sources, &pb.Source{
+			Index: int32(s.Index), Id: s.ID, Score: s.Score,
+			Start: int32(s.Start), End: int32(s.End), Cited: s.Cited,
+		})
+	}
+	return out, nil
+}
+
+func (g *grpcGateway) Search(ctx context.Context, in *pb.MemorySearchRequest) (*pb.MemorySearchResponse, error) {
+	if !componentNameRe.MatchString(in.GetComponent()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid component name")
+	}
+	req := MemorySearchRequest{TenantID: in.GetTenantId(), Query: in.GetQuery(), TopK: int(in.GetTopK())}
+	var resp MemorySearchResponse
+	if err := g.dispatch(ctx, http.MethodPost, "/api/v1/memory/"+in.GetComponent()+"/search", req, &resp); err != nil {
+		return nil, err
+	}
+	out := &pb.MemorySearchResponse{}
+	for _, r := range resp.Results {
+		res := &pb.SearchResult{Id: r.ID, Content: r.Content, Score: r.Score}
+		if len(r.Metadata) > 0 {
+			if md, err := structpb.NewStruct(r.Metadata); err == nil {
+				res.Metadata = md
+			}
+		}
+		out.Results = append(out.Results, res)
+	}
+	return out, nil
+}
+
+func (g *grpcGateway) Check(ctx context.Context, _ *pb.HealthCheckRequest) (*pb.HealthCheckResponse, error) {
+	if err := g.dispatch(ctx, http.MethodGet, "/readyz", nil, nil); err != nil {
+		return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &pb.HealthCheckResponse{Status: pb.HealthCheckResponse_SERVING}, nil
+}
+
+// dispatch serves an in-process HTTP request and decodes the JSON response into out.
+// HTTP error statuses are mapped to gRPC status codes.
+func (g *grpcGateway) dispatch(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(payload))
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			if strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") || k == "content-type" {
+				continue
+			}
+			for _, v := range vs {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	g.handler.ServeHTTP(rec, r)
+	// Return the request ID as response metadata, like the HTTP header
+	if id := rec.header.Get(requestIDHeader); id != "" {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestIDHeader), id))
+	}
+	if rec.status >= 300 {
+		return status.Error(grpcCodeFromHTTP(rec.status), strings.TrimSpace(rec.body.String()))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(rec.body.Bytes(), out); err != nil {
+		return status.Error(codes.Internal, "decode response: "+err.Error())
+	}
+	return nil
+}
+
+// grpcCodeFromHTTP maps HTTP status codes to their closest gRPC equivalent.
+func grpcCodeFromHTTP(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusFailedDependency, http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// bufferedResponse is a minimal in-memory http.ResponseWriter.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(code int)        { b.status = code }
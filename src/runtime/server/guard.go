@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// requestGuard applies authentication, rate limiting and RBAC to API requests.
+// It is shared by every authenticated endpoint so they enforce the same policy.
+type requestGuard struct {
+	enabled       bool
+	authenticator runtimesecurity.Authenticator
+	limiter       *runtimesecurity.RateLimiter
+	auditor       *runtimesecurity.Auditor
+}
+
+// authorize authenticates r and checks the principal may perform act on res. On
+// failure it writes the error response, records an audit event and returns false.
+// When auth is disabled it returns (nil, true).
+func (g *requestGuard) authorize(w http.ResponseWriter, r *http.Request, auditAction string, res runtimesecurity.Resource, act runtimesecurity.Action) (*runtimesecurity.Principal, bool) {
+	if !g.enabled {
+		return nil, true
+	}
+	deny := func(keyID, reason string) {
+		g.auditor.Record(r.Context(), runtimesecurity.AuditEvent{
+			Timestamp:  time.Now(),
+			RequestID:  requestIDFrom(r.Context()),
+			TenantID:   res.Tenant,
+			ActorKeyID: keyID,
+			Action:     auditAction,
+			Resource:   res.Type,
+			Result:     "denied",
+			Reason:     reason,
+		})
+	}
+	p, err := g.authenticator.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		deny("", "auth_failed")
+		return nil, false
+	}
+	// Rate limiting (per key/tenant/ip)
+	ip := runtimesecurity.ExtractIP(r)
+	if !g.limiter.Allow(runtimesecurity.LimiterKey{APIKeyID: p.KeyID, TenantID: p.TenantID, IP: ip}, 0) {
+		w.Header().Set("Retry-After", runtimesecurity.RetryAfter())
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		deny(p.KeyID, "rate_limited")
+		return nil, false
+	}
+	if !runtimesecurity.CheckPermission(p, res, act) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		deny(p.KeyID, "rbac")
+		return nil, false
+	}
+	return p, true
+}
+
+// permit checks a further permission of a principal returned by authorize,
+// without counting another request against its rate limit.
+func (g *requestGuard) permit(w http.ResponseWriter, r *http.Request, p *runtimesecurity.Principal, auditAction string, res runtimesecurity.Resource, act runtimesecurity.Action) bool {
+	if !g.enabled || p == nil || runtimesecurity.CheckPermission(p, res, act) {
+		return true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	g.auditor.Record(r.Context(), runtimesecurity.AuditEvent{
+		Timestamp:  time.Now(),
+		RequestID:  requestIDFrom(r.Context()),
+		TenantID:   res.Tenant,
+		ActorKeyID: p.KeyID,
+		Action:     auditAction,
+		Resource:   res.Type,
+		Result:     "denied",
+		Reason:     "rbac",
+	})
+	return false
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	runtimeconfig "github.com/contexis-cmp/contexis/src/runtime/config"
+)
+
+// CORSConfig controls cross-origin access for browser clients. CORS is off
+// unless at least one origin is allowed. Credentials are only allowed for
+// listed origins, never with "*".
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"` // exact origins, or "*" for any
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"` // seconds browsers may cache a preflight
+}
+
+// SecurityHeadersConfig controls the standard response hardening headers.
+type SecurityHeadersConfig struct {
+	Disabled              bool   `yaml:"disabled"`
+	HSTSMaxAge            *int   `yaml:"hsts_max_age"` // seconds; 0 disables HSTS
+	HSTSIncludeSubdomains *bool  `yaml:"hsts_include_subdomains"`
+	FrameOptions          string `yaml:"frame_options"`
+	ReferrerPolicy        string `yaml:"referrer_policy"`
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+}
+
+// HTTPConfig is the `server:` section of config/environments/$CMP_ENV.yaml.
+type HTTPConfig struct {
+	CORS            CORSConfig            `yaml:"cors"`
+	SecurityHeaders SecurityHeadersConfig `yaml:"security_headers"`
+
+	// Limits (see limits.go for defaults); timeouts are Go durations
+	MaxBodyBytes      int64  `yaml:"max_body_bytes"`
+	RequestTimeout    string `yaml:"request_timeout"`
+	ReadHeaderTimeout string `yaml:"read_header_timeout"`
+	ReadTimeout       string `yaml:"read_timeout"`
+	WriteTimeout      string `yaml:"write_timeout"`
+	IdleTimeout       string `yaml:"idle_timeout"`
+
+	// How long responses to requests with an Idempotency-Key are replayed
+	// (default 24h, "0" disables; see idempotency.go)
+	IdempotencyWindow string `yaml:"idempotency_window"`
+
+	// Priority classes and concurrency of chat requests (see admission.go)
+	Admission AdmissionConfig `yaml:"admission"`
+}
+
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "X-Tenant-ID", requestIDHeader, "traceparent", idempotencyKeyHeader, priorityHeader}
+)
+
+// LoadHTTPConfig reads the server section of the active environment config
+// (config/environments/$CMP_ENV.yaml, default development) and applies the
+// CMP_CORS_*, CMP_HSTS_MAX_AGE, CMP_MAX_BODY_BYTES, timeout and
+// CMP_IDEMPOTENCY_WINDOW and CMP_ADMISSION_MAX_CONCURRENT overrides. An
+// invalid CORS section is returned off, so cross-origin access fails closed.
+func LoadHTTPConfig(root string) (HTTPConfig, error) {
+	var cfg HTTPConfig
+	env, err := runtimeconfig.Load(root)
+	if err != nil {
+		return cfg, err
+	}
+	if err := env.Section("server", &cfg); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("CMP_CORS_ALLOWED_ORIGINS"); v != "" {
+		cfg.CORS.AllowedOrigins = splitList(v)
+	}
+	if v := os.Getenv("CMP_CORS_ALLOWED_METHODS"); v != "" {
+		cfg.CORS.AllowedMethods = splitList(v)
+	}
+	if v := os.Getenv("CMP_CORS_ALLOWED_HEADERS"); v != "" {
+		cfg.CORS.AllowedHeaders = splitList(v)
+	}
+	if v := os.Getenv("CMP_CORS_ALLOW_CREDENTIALS"); v != "" {
+		cfg.CORS.AllowCredentials = v == "true"
+	}
+	if v, err := strconv.Atoi(os.Getenv("CMP_HSTS_MAX_AGE")); err == nil {
+		cfg.SecurityHeaders.HSTSMaxAge = &v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("CMP_MAX_BODY_BYTES"), 10, 64); err == nil {
+		cfg.MaxBodyBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CMP_ADMISSION_MAX_CONCURRENT")); err == nil {
+		cfg.Admission.MaxConcurrent = v
+	}
+	for env, field := range map[string]*string{
+		"CMP_REQUEST_TIMEOUT":          &cfg.RequestTimeout,
+		"CMP_HTTP_READ_HEADER_TIMEOUT": &cfg.ReadHeaderTimeout,
+		"CMP_HTTP_READ_TIMEOUT":        &cfg.ReadTimeout,
+		"CMP_HTTP_WRITE_TIMEOUT":       &cfg.WriteTimeout,
+		"CMP_HTTP_IDLE_TIMEOUT":        &cfg.IdleTimeout,
+		"CMP_IDEMPOTENCY_WINDOW":       &cfg.IdempotencyWindow,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	if err := cfg.CORS.validate(); err != nil {
+		cfg.CORS = CORSConfig{}
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// validate rejects credentials for any origin: the browser would send the
+// caller's cookies and credentials to the server from every site.
+func (c CORSConfig) validate() error {
+	if !c.AllowCredentials {
+		return nil
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return fmt.Errorf("server.cors: allow_credentials cannot be combined with allowed_origins \"*\"; list the origins instead")
+		}
+	}
+	return nil
+}
+
+// allowsOrigin reports whether origin is listed, or any origin is allowed.
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.TrimRight(o, "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// withHTTPHeaders applies CORS and security headers ahead of routing and
+// answers CORS preflight requests itself.
+func withHTTPHeaders(cfg HTTPConfig, next http.Handler) http.Handler {
+	cors := cfg.CORS
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cors.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods, allowHeaders := strings.Join(methods, ", "), strings.Join(headers, ", ")
+	// Browser clients can always read the request ID
+	exposed := []string{requestIDHeader}
+	for _, h := range cors.ExposedHeaders {
+		if !strings.EqualFold(h, requestIDHeader) {
+			exposed = append(exposed, h)
+		}
+	}
+	exposeHeaders := strings.Join(exposed, ", ")
+	anyOrigin := false
+	origins := map[string]bool{}
+	for _, o := range cors.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
+		}
+		origins[strings.TrimRight(o, "/")] = true
+	}
+	sec := cfg.SecurityHeaders
+	hstsMaxAge := 31536000
+	if sec.HSTSMaxAge != nil {
+		hstsMaxAge = *sec.HSTSMaxAge
+	}
+	hsts := ""
+	if hstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(hstsMaxAge)
+		if sec.HSTSIncludeSubdomains == nil || *sec.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+	frameOptions := sec.FrameOptions
+	if frameOptions == "" {
+		frameOptions = "DENY"
+	}
+	referrer := sec.ReferrerPolicy
+	if referrer == "" {
+		referrer = "no-referrer"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if !sec.Disabled {
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", frameOptions)
+			h.Set("Referrer-Policy", referrer)
+			if sec.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", sec.ContentSecurityPolicy)
+			}
+			// HSTS is only meaningful over HTTPS, including behind a TLS-terminating proxy
+			if hsts != "" && (r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")) {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(origins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !anyOrigin && !origins[origin] {
+			if preflight {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		h.Set("Access-Control-Allow-Headers", allowHeaders)
+		if cors.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimeidempotency "github.com/contexis-cmp/contexis/src/runtime/idempotency"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotentReplays counts POST requests answered from the idempotency store.
+var idempotentReplays = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "cmp_idempotent_replays_total",
+	Help: "POST requests answered with the stored response of an earlier request with the same Idempotency-Key.",
+})
+
+// idempotencyWindow resolves server.idempotency_window; "0" turns idempotency
+// keys off.
+func (c HTTPConfig) idempotencyWindow() (time.Duration, error) {
+	if c.IdempotencyWindow == "" {
+		return runtimeidempotency.DefaultWindow, nil
+	}
+	d, err := time.ParseDuration(c.IdempotencyWindow)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("server.idempotency_window: invalid duration %q", c.IdempotencyWindow)
+	}
+	return d, nil
+}
+
+// withIdempotency answers a POST retried with the same Idempotency-Key from
+// the stored response of the first request, marked Idempotent-Replayed: true.
+// Keys are scoped to the caller's credentials and tenant. A duplicate of a
+// request still running gets 409, and a key reused for another request 422.
+// Server errors, throttled or timed out requests and requests that wrote no
+// response are not stored, so they can be retried.
+func withIdempotency(store *runtimeidempotency.Store, next http.Handler) http.Handler {
+	if store == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, fmt.Sprintf("%s exceeds %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				httpRejectedRequests.WithLabelValues("body_too_large").Inc()
+				http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scoped := idempotencyScope(r) + "\n" + key
+		stored, err := store.Begin(scoped, requestFingerprint(r, body))
+		switch {
+		case errors.Is(err, runtimeidempotency.ErrInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.Is(err, runtimeidempotency.ErrMismatch):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case err != nil:
+			// The store is an optimization; serve the request without it
+			logger.WithContext(r.Context()).Warn("idempotency store unavailable", zap.Error(err))
+			next.ServeHTTP(w, r)
+			return
+		case stored != nil:
+			idempotentReplays.Inc()
+			for k, v := range stored.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			_, _ = w.Write(stored.Body)
+			return
+		}
+
+		// Headers already set by outer middleware are set again on replay
+		before := map[string]bool{}
+		for k := range w.Header() {
+			before[k] = true
+		}
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if rec.header == nil || !storable(rec.status) {
+				_ = store.Release(scoped)
+				return
+			}
+			header := http.Header{}
+			for k, v := range rec.header {
+				if !before[k] {
+					header[k] = v
+				}
+			}
+			resp := runtimeidempotency.Response{Status: rec.status, Header: header, Body: rec.body.Bytes()}
+			if err := store.Complete(scoped, resp); err != nil {
+				logger.WithContext(r.Context()).Warn("idempotent response not stored", zap.Error(err))
+				_ = store.Release(scoped)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// storable reports whether a response with the given status is replayed;
+// retrying the others may succeed.
+func storable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+// idempotencyScope identifies the caller, so keys of different callers or
+// tenants never collide.
+func idempotencyScope(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.Header.Get("Authorization") + "\n" + r.Header.Get("X-Tenant-ID")))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestFingerprint identifies the request a key was first used for.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response for the idempotency store.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if w.header == nil {
+		w.status, w.header = code, w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.header == nil {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection (flushing streams).
+func (w *recordingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
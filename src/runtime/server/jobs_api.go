@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// JobSubmitRequest is the request payload for POST /api/v1/jobs.
+type JobSubmitRequest struct {
+	Type        string          `json:"type"`
+	TenantID    string          `json:"tenant_id"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int             `json:"max_attempts"`
+}
+
+// JobList is the response payload for GET /api/v1/jobs.
+type JobList struct {
+	Jobs []runtimejobs.Job `json:"jobs"`
+}
+
+// registerJobRoutes wires the job queue endpoints. Submitting and cancelling
+// require jobs:write, reading requires jobs:read; memory.ingest jobs also
+// require memory:write on the component. Tenant-bound keys submit for and see
+// only their own tenant's jobs.
+func registerJobRoutes(mux *http.ServeMux, queue *runtimejobs.Queue, guard *requestGuard) {
+	mux.HandleFunc("POST /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req JobSubmitRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		res := runtimesecurity.Resource{Type: "jobs", Name: req.Type, Tenant: req.TenantID}
+		principal, ok := guard.authorize(w, r, "jobs:submit", res, runtimesecurity.ActionWrite)
+		if !ok {
+			return
+		}
+		if err := runtimejobs.ValidatePayload(req.Type, req.Payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Jobs must not grant more than the equivalent direct call
+		switch req.Type {
+		case runtimejobs.TypeMemoryIngest:
+			var p runtimejobs.MemoryIngestPayload
+			_ = json.Unmarshal(req.Payload, &p)
+			memRes := runtimesecurity.Resource{Type: "memory", Name: p.Component, Tenant: req.TenantID}
+			if !guard.permit(w, r, principal, "memory:ingest", memRes, runtimesecurity.ActionWrite) {
+				return
+			}
+		case runtimejobs.TypeMemoryPrune:
+			var p runtimejobs.MemoryPrunePayload
+			_ = json.Unmarshal(req.Payload, &p)
+			memRes := runtimesecurity.Resource{Type: "memory", Name: p.Component, Tenant: req.TenantID}
+			if !guard.permit(w, r, principal, "memory:prune", memRes, runtimesecurity.ActionWrite) {
+				return
+			}
+		}
+		job := runtimejobs.Job{Type: req.Type, TenantID: req.TenantID, Payload: req.Payload, MaxAttempts: req.MaxAttempts}
+		if principal != nil {
+			job.Submitter = principal.KeyID
+			if principal.TenantID != "" {
+				job.TenantID = principal.TenantID
+			}
+		}
+		job, err := queue.Submit(job)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	})
+
+	mux.HandleFunc("GET /api/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		res := runtimesecurity.Resource{Type: "jobs", Tenant: r.URL.Query().Get("tenant_id")}
+		principal, ok := guard.authorize(w, r, "jobs:list", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		tenant := res.Tenant
+		if principal != nil && principal.TenantID != "" {
+			tenant = principal.TenantID
+		}
+		list, err := queue.List(r.URL.Query().Get("status"), tenant)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, JobList{Jobs: list})
+	})
+
+	mux.HandleFunc("GET /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := queue.Get(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		res := runtimesecurity.Resource{Type: "jobs", Name: job.Type, Tenant: job.TenantID}
+		if _, ok := guard.authorize(w, r, "jobs:read", res, runtimesecurity.ActionRead); !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, err := queue.Get(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		res := runtimesecurity.Resource{Type: "jobs", Name: job.Type, Tenant: job.TenantID}
+		principal, ok := guard.authorize(w, r, "jobs:cancel", res, runtimesecurity.ActionWrite)
+		if !ok {
+			return
+		}
+		reason := "cancelled via api"
+		if principal != nil {
+			reason = "cancelled by " + principal.KeyID
+		}
+		job, err = queue.Cancel(job.ID, reason)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+}
+
+func writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, runtimejobs.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, runtimejobs.ErrFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// CreatedKey is the response of key creation and rotation. Secret is only ever
+// returned here.
+type CreatedKey struct {
+	Key    runtimesecurity.APIKey `json:"key"`
+	Secret string                 `json:"secret"`
+}
+
+// KeyList is the response payload of GET /api/v1/admin/keys.
+type KeyList struct {
+	Keys []runtimesecurity.APIKey `json:"keys"`
+}
+
+// keyPatch updates only the fields present in the request body.
+type keyPatch struct {
+	Name      *string    `json:"name"`
+	TenantID  *string    `json:"tenant_id"`
+	Roles     *[]string  `json:"roles"`
+	Scopes    *[]string  `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// registerKeyRoutes wires the API key admin endpoints. They require keys:admin
+// (or admin:*) and an enabled API-key authenticator; tenant-bound admins can
+// only manage keys of their own tenant. Creating, updating and rotating a key
+// hands out its scopes, so the key may not exceed the caller's (see CheckGrant).
+func registerKeyRoutes(mux *http.ServeMux, keys *runtimesecurity.KeyManager, guard *requestGuard) {
+	// authorize checks the caller may administer keys of tenant and returns it;
+	// its TenantID is the tenant binding ("" for global admins).
+	authorize := func(w http.ResponseWriter, r *http.Request, auditAction, tenant string) (*runtimesecurity.Principal, bool) {
+		if !guard.enabled || keys == nil {
+			http.Error(w, "API key management requires CMP_AUTH_ENABLED=true with CMP_AUTH_MODE=apikey", http.StatusNotFound)
+			return nil, false
+		}
+		return guard.authorize(w, r, auditAction, runtimesecurity.Resource{Type: "keys", Tenant: tenant}, runtimesecurity.ActionAdmin)
+	}
+	// lookup authorizes the caller, then loads the key it names. Keys of other
+	// tenants are reported as missing to tenant-bound admins.
+	lookup := func(w http.ResponseWriter, r *http.Request, auditAction string) (runtimesecurity.APIKey, *runtimesecurity.Principal, bool) {
+		caller, ok := authorize(w, r, auditAction, "")
+		if !ok {
+			return runtimesecurity.APIKey{}, nil, false
+		}
+		k, err := keys.Get(r.PathValue("id"))
+		if err == nil && caller.TenantID != "" && k.TenantID != caller.TenantID {
+			err = runtimesecurity.ErrKeyNotFound
+		}
+		if err != nil {
+			writeKeyError(w, err)
+			return k, nil, false
+		}
+		return k, caller, true
+	}
+
+	mux.HandleFunc("GET /api/v1/admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := authorize(w, r, "keys:list", "")
+		if !ok {
+			return
+		}
+		out := KeyList{Keys: []runtimesecurity.APIKey{}}
+		for _, k := range keys.List() {
+			if caller.TenantID == "" || k.TenantID == caller.TenantID {
+				out.Keys = append(out.Keys, redactKey(k))
+			}
+		}
+		writeJSON(w, http.StatusOK, out)
+	})
+
+	mux.HandleFunc("POST /api/v1/admin/keys", func(w http.ResponseWriter, r *http.Request) {
+		var spec runtimesecurity.KeySpec
+		if !decodeJSONBody(w, r, &spec) {
+			return
+		}
+		caller, ok := authorize(w, r, "keys:create", spec.TenantID)
+		if !ok {
+			return
+		}
+		if caller.TenantID != "" {
+			// Tenant admins cannot mint global keys
+			spec.TenantID = caller.TenantID
+		}
+		if err := runtimesecurity.CheckGrant(caller, spec); err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		k, secret, err := keys.Create(spec)
+		if err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, CreatedKey{Key: redactKey(k), Secret: secret})
+	})
+
+	mux.HandleFunc("GET /api/v1/admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if k, _, ok := lookup(w, r, "keys:read"); ok {
+			writeJSON(w, http.StatusOK, redactKey(k))
+		}
+	})
+
+	mux.HandleFunc("PATCH /api/v1/admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		k, caller, ok := lookup(w, r, "keys:update")
+		if !ok {
+			return
+		}
+		// Callers may only change keys whose scopes they could grant themselves
+		if err := runtimesecurity.CheckGrant(caller, runtimesecurity.KeySpec{Roles: k.Roles, Scopes: k.Scopes}); err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		var patch keyPatch
+		if !decodeJSONBody(w, r, &patch) {
+			return
+		}
+		spec := runtimesecurity.KeySpec{Name: k.Name, TenantID: k.TenantID, Roles: k.Roles, Scopes: k.Scopes, ExpiresAt: k.ExpiresAt}
+		if patch.Name != nil {
+			spec.Name = *patch.Name
+		}
+		if patch.TenantID != nil {
+			if caller.TenantID != "" && *patch.TenantID != caller.TenantID {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			spec.TenantID = *patch.TenantID
+		}
+		if patch.Roles != nil {
+			spec.Roles = *patch.Roles
+		}
+		if patch.Scopes != nil {
+			spec.Scopes = *patch.Scopes
+		}
+		if patch.ExpiresAt != nil {
+			spec.ExpiresAt = patch.ExpiresAt
+		}
+		if err := runtimesecurity.CheckGrant(caller, spec); err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		updated, err := keys.Update(k.KeyID, spec)
+		if err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, redactKey(updated))
+	})
+
+	mux.HandleFunc("POST /api/v1/admin/keys/{id}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		k, caller, ok := lookup(w, r, "keys:rotate")
+		if !ok {
+			return
+		}
+		// The new secret carries the key's scopes to the caller
+		if err := runtimesecurity.CheckGrant(caller, runtimesecurity.KeySpec{Roles: k.Roles, Scopes: k.Scopes}); err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		var grace time.Duration
+		if g := r.URL.Query().Get("grace"); g != "" {
+			var err error
+			if grace, err = time.ParseDuration(g); err != nil || grace < 0 {
+				http.Error(w, "invalid grace duration", http.StatusBadRequest)
+				return
+			}
+		}
+		rotated, secret, err := keys.Rotate(k.KeyID, grace)
+		if err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, CreatedKey{Key: redactKey(rotated), Secret: secret})
+	})
+
+	mux.HandleFunc("DELETE /api/v1/admin/keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		k, caller, ok := lookup(w, r, "keys:revoke")
+		if !ok {
+			return
+		}
+		// A key that holds scopes beyond the caller's can only be revoked by a stronger key
+		if err := runtimesecurity.CheckGrant(caller, runtimesecurity.KeySpec{Roles: k.Roles, Scopes: k.Scopes}); err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		revoked, err := keys.Revoke(k.KeyID)
+		if err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, redactKey(revoked))
+	})
+}
+
+// redactKey drops secret hashes from API responses.
+func redactKey(k runtimesecurity.APIKey) runtimesecurity.APIKey {
+	k.Hash, k.PreviousHash = "", ""
+	return k
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, runtimesecurity.ErrKeyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, runtimesecurity.ErrKeyRevoked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, runtimesecurity.ErrGrantExceedsScopes):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Defaults for the HTTPConfig limits. Write and request timeouts are off by
+// default because chat requests may stream or wait for out-of-band approval.
+const (
+	defaultMaxBodyBytes      = 1 << 20
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+)
+
+// httpRejectedRequests counts requests refused by the limits (reason: body_too_large|timeout).
+var httpRejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cmp_http_rejected_requests_total",
+	Help: "Requests rejected for exceeding the body size limit or the request timeout.",
+}, []string{"reason"})
+
+// httpLimits is the resolved form of the HTTPConfig limits.
+type httpLimits struct {
+	maxBodyBytes      int64
+	requestTimeout    time.Duration
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+}
+
+// limits resolves the configured limits, applying defaults to unset values.
+func (c HTTPConfig) limits() (httpLimits, error) {
+	l := httpLimits{maxBodyBytes: c.MaxBodyBytes}
+	if l.maxBodyBytes == 0 {
+		l.maxBodyBytes = defaultMaxBodyBytes
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		def   time.Duration
+		out   *time.Duration
+	}{
+		{"request_timeout", c.RequestTimeout, 0, &l.requestTimeout},
+		{"read_header_timeout", c.ReadHeaderTimeout, defaultReadHeaderTimeout, &l.readHeaderTimeout},
+		{"read_timeout", c.ReadTimeout, defaultReadTimeout, &l.readTimeout},
+		{"write_timeout", c.WriteTimeout, 0, &l.writeTimeout},
+		{"idle_timeout", c.IdleTimeout, defaultIdleTimeout, &l.idleTimeout},
+	} {
+		*d.out = d.def
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return l, fmt.Errorf("server.%s: invalid duration %q", d.name, d.value)
+		}
+		*d.out = v
+	}
+	return l, nil
+}
+
+// apply sets the connection-level timeouts that protect against slow clients.
+func (l httpLimits) apply(srv *http.Server) {
+	srv.ReadHeaderTimeout = l.readHeaderTimeout
+	srv.ReadTimeout = l.readTimeout
+	srv.WriteTimeout = l.writeTimeout
+	srv.IdleTimeout = l.idleTimeout
+}
+
+// withLimits caps request bodies and bounds each request with a deadline that
+// memory search and inference inherit. WebSocket upgrades are exempt from the
+// deadline since the connection outlives the upgrade request.
+func withLimits(l httpLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.maxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, l.maxBodyBytes)
+		}
+		if l.requestTimeout <= 0 || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), l.requestTimeout)
+		defer cancel()
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+		if !tw.wrote && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			writeRequestTimeout(w)
+		}
+	})
+}
+
+// timeoutWriter records whether a response was started, so a handler that gave
+// up on an expired context can still be answered with 408.
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection (flushing streams).
+func (w *timeoutWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// decodeJSONBody decodes the request body into v. On failure it writes 413 for
+// oversized bodies, 408 for bodies not received in time and 400 otherwise.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &tooLarge):
+		httpRejectedRequests.WithLabelValues("body_too_large").Inc()
+		http.Error(w, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &netErr) && netErr.Timeout(), r.Context().Err() != nil:
+		writeRequestTimeout(w)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+// timedOut reports whether err, or the request itself, hit the request deadline.
+func timedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+func writeRequestTimeout(w http.ResponseWriter) {
+	httpRejectedRequests.WithLabelValues("timeout").Inc()
+	http.Error(w, "request timed out", http.StatusRequestTimeout)
+}
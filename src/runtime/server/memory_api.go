@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// MemorySearchRequest is the request payload for POST /api/v1/memory/{component}/search.
+type MemorySearchRequest struct {
+	TenantID string `json:"tenant_id"`
+	Query    string `json:"query"`
+	TopK     int    `json:"top_k"`
+}
+
+// MemorySearchResponse is the response payload for memory search.
+type MemorySearchResponse struct {
+	Results []runtimememory.SearchResult `json:"results"`
+}
+
+// MemoryDocument is a document to ingest. ID is its source path relative to
+// the component's documents, e.g. "faq/returns.md"; re-ingesting an ID
+// replaces the document.
+type MemoryDocument struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// MemoryIngestRequest is the request payload for POST /api/v1/memory/{component}/documents.
+type MemoryIngestRequest struct {
+	TenantID  string           `json:"tenant_id"`
+	Documents []MemoryDocument `json:"documents"`
+}
+
+// componentNameRe restricts component path segments to safe directory names.
+var componentNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// memoryWriteLocks serializes writes to each memory store, keyed by component
+// and tenant; stores are rewritten as a whole on ingest.
+var memoryWriteLocks sync.Map
+
+func lockMemoryStore(component, tenantID string) func() {
+	v, _ := memoryWriteLocks.LoadOrStore(component+"/"+tenantID, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// registerMemoryRoutes wires the memory REST endpoints. Search requires
+// memory:read on the component when auth is enabled, ingest and delete require
+// memory:write. Tenant-bound keys always use their own tenant's store.
+func registerMemoryRoutes(mux *http.ServeMux, root string, guard *requestGuard, openStore StoreOpener) {
+	mux.HandleFunc("POST /api/v1/memory/{component}/search", func(w http.ResponseWriter, r *http.Request) {
+		component := r.PathValue("component")
+		if !componentNameRe.MatchString(component) {
+			http.Error(w, "invalid component name", http.StatusBadRequest)
+			return
+		}
+		var req MemorySearchRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Query == "" {
+			http.Error(w, "query is required", http.StatusBadRequest)
+			return
+		}
+		res := runtimesecurity.Resource{Type: "memory", Name: component, Tenant: req.TenantID}
+		principal, ok := guard.authorize(w, r, "memory:search", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			req.TenantID = principal.TenantID
+		}
+		store, err := openStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: component, TenantID: req.TenantID})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer store.Close()
+		start := time.Now()
+		results, err := store.Search(r.Context(), req.Query, req.TopK)
+		memorySearchDuration.WithLabelValues(component).Observe(time.Since(start).Seconds())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if results == nil {
+			results = []runtimememory.SearchResult{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(MemorySearchResponse{Results: results})
+	})
+	mux.HandleFunc("POST /api/v1/memory/{component}/documents", func(w http.ResponseWriter, r *http.Request) {
+		component := r.PathValue("component")
+		if !componentNameRe.MatchString(component) {
+			http.Error(w, "invalid component name", http.StatusBadRequest)
+			return
+		}
+		var req MemoryIngestRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if len(req.Documents) == 0 {
+			http.Error(w, "documents is required", http.StatusBadRequest)
+			return
+		}
+		sources := make([]runtimememory.Source, 0, len(req.Documents))
+		seen := map[string]bool{}
+		for _, doc := range req.Documents {
+			if !runtimememory.ValidSourcePath(doc.ID) {
+				http.Error(w, "invalid document id: "+doc.ID, http.StatusBadRequest)
+				return
+			}
+			if seen[doc.ID] {
+				http.Error(w, "duplicate document id: "+doc.ID, http.StatusBadRequest)
+				return
+			}
+			if strings.TrimSpace(doc.Content) == "" {
+				http.Error(w, "document content is required: "+doc.ID, http.StatusBadRequest)
+				return
+			}
+			seen[doc.ID] = true
+			content, meta := runtimememory.ParseFrontMatter(doc.Content)
+			sources = append(sources, runtimememory.Source{Path: doc.ID, Content: content, Metadata: meta})
+		}
+		res := runtimesecurity.Resource{Type: "memory", Name: component, Tenant: req.TenantID}
+		principal, ok := guard.authorize(w, r, "memory:ingest", res, runtimesecurity.ActionWrite)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			req.TenantID = principal.TenantID
+		}
+		result, ok := updateMemorySources(w, r, root, openStore, component, req.TenantID, sources, nil)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/memory/{component}/documents/{id...}", func(w http.ResponseWriter, r *http.Request) {
+		component := r.PathValue("component")
+		if !componentNameRe.MatchString(component) {
+			http.Error(w, "invalid component name", http.StatusBadRequest)
+			return
+		}
+		id := r.PathValue("id")
+		if !runtimememory.ValidSourcePath(id) {
+			http.Error(w, "invalid document id", http.StatusBadRequest)
+			return
+		}
+		tenantID := r.URL.Query().Get("tenant_id")
+		res := runtimesecurity.Resource{Type: "memory", Name: component, Tenant: tenantID}
+		principal, ok := guard.authorize(w, r, "memory:delete", res, runtimesecurity.ActionWrite)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			tenantID = principal.TenantID
+		}
+		result, ok := updateMemorySources(w, r, root, openStore, component, tenantID, nil, []string{id})
+		if !ok {
+			return
+		}
+		if len(result.Removed) == 0 {
+			http.Error(w, "document not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+}
+
+// updateMemorySources applies a per-source update to a component's store and
+// writes an error response on failure.
+func updateMemorySources(w http.ResponseWriter, r *http.Request, root string, openStore StoreOpener, component, tenantID string, updated []runtimememory.Source, removed []string) (runtimememory.IngestResult, bool) {
+	unlock := lockMemoryStore(component, tenantID)
+	defer unlock()
+	store, err := openStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: component, TenantID: tenantID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return runtimememory.IngestResult{}, false
+	}
+	defer store.Close()
+	ss, err := runtimememory.AsSourceStore(store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return runtimememory.IngestResult{}, false
+	}
+	result, err := ss.UpdateSources(r.Context(), updated, removed)
+	if err != nil {
+		if timedOut(r.Context(), err) {
+			writeRequestTimeout(w)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return runtimememory.IngestResult{}, false
+	}
+	return result, true
+}
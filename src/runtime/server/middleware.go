@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// Middleware hooks into the chat pipeline for custom moderation, logging or response
+// transformation. Hooks run in registration order; returning an error aborts the
+// request (use Reject to choose the HTTP status). Embed BaseMiddleware to implement
+// only the hooks you need.
+type Middleware interface {
+	// OnRequest runs after authentication and may modify the request.
+	OnRequest(ctx context.Context, req *ChatRequest) error
+
+	// OnPromptRendered may inspect or rewrite the prompt before inference.
+	OnPromptRendered(ctx context.Context, req *ChatRequest, prompt string) (string, error)
+
+	// OnModelResponse may inspect or rewrite the answer before output guardrails
+	// (citations, PII) are applied. Without a model provider it receives the prompt.
+	OnModelResponse(ctx context.Context, req *ChatRequest, response string) (string, error)
+}
+
+// BaseMiddleware provides no-op hooks.
+type BaseMiddleware struct{}
+
+func (BaseMiddleware) OnRequest(context.Context, *ChatRequest) error { return nil }
+
+func (BaseMiddleware) OnPromptRendered(_ context.Context, _ *ChatRequest, prompt string) (string, error) {
+	return prompt, nil
+}
+
+func (BaseMiddleware) OnModelResponse(_ context.Context, _ *ChatRequest, response string) (string, error) {
+	return response, nil
+}
+
+// HookError rejects a request from middleware with an HTTP status.
+type HookError struct {
+	Status  int
+	Message string
+}
+
+func (e *HookError) Error() string { return e.Message }
+
+// Reject returns an error that aborts the request with status and message.
+func Reject(status int, message string) error {
+	return &HookError{Status: status, Message: message}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Middleware
+)
+
+// RegisterMiddleware adds middleware to every handler constructed afterwards,
+// including the one started by `ctx serve`. It is intended to be called from an
+// init function of a package blank-imported into a custom build.
+func RegisterMiddleware(m Middleware) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+func registeredMiddleware() []Middleware {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Middleware(nil), registry...)
+}
+
+// Option customizes a handler built by NewHandlerWithProvider.
+type Option func(*handlerOptions)
+
+type handlerOptions struct {
+	middleware []Middleware
+	auditSink  runtimesecurity.AuditSink
+	openStore  StoreOpener
+}
+
+// StoreOpener opens a component's memory store. The default is
+// runtimememory.NewStore, which serves the sqlite stores under the project root.
+type StoreOpener func(cfg runtimememory.Config) (runtimememory.MemoryStore, error)
+
+// WithMiddleware adds middleware to a single handler, after registered middleware.
+func WithMiddleware(m ...Middleware) Option {
+	return func(o *handlerOptions) { o.middleware = append(o.middleware, m...) }
+}
+
+// WithAuditSink sends audit events to sink instead of the sinks configured in
+// config/audit.yaml. The caller owns the sink and closes it on shutdown.
+func WithAuditSink(sink runtimesecurity.AuditSink) Option {
+	return func(o *handlerOptions) { o.auditSink = sink }
+}
+
+// WithStoreOpener serves chat retrieval and the memory API from the stores
+// returned by open, e.g. an embedded or remote vector store. The handler closes
+// each store it opens once the request is done.
+func WithStoreOpener(open StoreOpener) Option {
+	return func(o *handlerOptions) { o.openStore = open }
+}
+
+// middlewareChain runs hooks in order.
+type middlewareChain []Middleware
+
+func (c middlewareChain) onRequest(ctx context.Context, req *ChatRequest) error {
+	for _, m := range c {
+		if err := m.OnRequest(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c middlewareChain) onPromptRendered(ctx context.Context, req *ChatRequest, prompt string) (string, error) {
+	var err error
+	for _, m := range c {
+		if prompt, err = m.OnPromptRendered(ctx, req, prompt); err != nil {
+			return "", err
+		}
+	}
+	return prompt, nil
+}
+
+func (c middlewareChain) onModelResponse(ctx context.Context, req *ChatRequest, response string) (string, error) {
+	var err error
+	for _, m := range c {
+		if response, err = m.OnModelResponse(ctx, req, response); err != nil {
+			return "", err
+		}
+	}
+	return response, nil
+}
+
+// writeHookError writes a middleware error; errors without a HookError status are 500s.
+func writeHookError(w http.ResponseWriter, err error) {
+	var he *HookError
+	if errors.As(err, &he) && he.Status != 0 {
+		http.Error(w, he.Message, he.Status)
+		return
+	}
+	http.Error(w, "middleware error: "+err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"gopkg.in/yaml.v3"
+)
+
+// ModelOverridesFile is the project-relative path of the policy for per-request
+// provider, model and parameter overrides.
+const ModelOverridesFile = "config/model_overrides.yaml"
+
+// AnonymousRole is the role of callers when authentication is disabled.
+const AnonymousRole = "anonymous"
+
+// ModelParams are the sampling parameters a chat request may set.
+type ModelParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+}
+
+// names returns the parameters that are set.
+func (p *ModelParams) names() []string {
+	if p == nil {
+		return nil
+	}
+	var out []string
+	if p.Temperature != nil {
+		out = append(out, "temperature")
+	}
+	if p.TopP != nil {
+		out = append(out, "top_p")
+	}
+	if p.MaxTokens != 0 {
+		out = append(out, "max_tokens")
+	}
+	return out
+}
+
+func (p *ModelParams) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("params.temperature must be between 0 and 2")
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("params.top_p must be greater than 0 and at most 1")
+	}
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("params.max_tokens must be positive")
+	}
+	return nil
+}
+
+// apply sets the overridden parameters on params.
+func (p *ModelParams) apply(params runtimemodel.Params) runtimemodel.Params {
+	if p == nil {
+		return params
+	}
+	if p.Temperature != nil {
+		params.Temperature = *p.Temperature
+	}
+	if p.TopP != nil {
+		params.TopP = *p.TopP
+	}
+	if p.MaxTokens > 0 {
+		params.MaxNewTokens = p.MaxTokens
+	}
+	return params
+}
+
+// OverrideGrant lists what callers with a role may override. "*" in Providers
+// or Models allows any value.
+type OverrideGrant struct {
+	Providers []string `yaml:"providers"`
+	Models    []string `yaml:"models"`
+	Params    []string `yaml:"params"`     // temperature|top_p|max_tokens
+	MaxTokens int      `yaml:"max_tokens"` // upper bound for params.max_tokens; 0 means no bound
+}
+
+// ModelOverridePolicy is the parsed config/model_overrides.yaml.
+type ModelOverridePolicy struct {
+	Roles map[string]OverrideGrant `yaml:"roles"`
+}
+
+// LoadModelOverridePolicy reads the override policy under root. Without the file
+// no caller may override the routed model.
+func LoadModelOverridePolicy(root string) (*ModelOverridePolicy, error) {
+	pol := &ModelOverridePolicy{}
+	by, err := os.ReadFile(filepath.Join(root, ModelOverridesFile))
+	if os.IsNotExist(err) {
+		return pol, nil
+	}
+	if err != nil {
+		return pol, err
+	}
+	if err := yaml.Unmarshal(by, pol); err != nil {
+		return &ModelOverridePolicy{}, fmt.Errorf("parse %s: %w", ModelOverridesFile, err)
+	}
+	for role, g := range pol.Roles {
+		for _, p := range g.Params {
+			switch p {
+			case "temperature", "top_p", "max_tokens":
+			default:
+				return &ModelOverridePolicy{}, fmt.Errorf("%s: role %q: unknown param %q", ModelOverridesFile, role, p)
+			}
+		}
+	}
+	return pol, nil
+}
+
+// overrideRoles returns the roles a request's overrides are checked against.
+func overrideRoles(ctx context.Context) []string {
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		return p.Roles
+	}
+	return []string{AnonymousRole}
+}
+
+// check returns why req may not apply its overrides with the given roles, or ""
+// when it may. A request is allowed when any one of the roles grants all of it.
+func (pol *ModelOverridePolicy) check(roles []string, req ChatRequest) string {
+	params := req.Params.names()
+	reason := "no role allows model overrides"
+	for _, role := range roles {
+		g, ok := pol.Roles[role]
+		if !ok {
+			continue
+		}
+		switch {
+		case req.Provider != "" && !allowed(g.Providers, req.Provider):
+			reason = fmt.Sprintf("provider %q is not allowed", req.Provider)
+		case req.Model != "" && !allowed(g.Models, req.Model):
+			reason = fmt.Sprintf("model %q is not allowed", req.Model)
+		case !allowedParams(g.Params, params):
+			reason = fmt.Sprintf("params %s are not allowed", strings.Join(params, ", "))
+		case g.MaxTokens > 0 && req.Params.MaxTokens > g.MaxTokens:
+			reason = fmt.Sprintf("params.max_tokens exceeds %d", g.MaxTokens)
+		default:
+			return ""
+		}
+	}
+	return reason
+}
+
+func allowed(list []string, v string) bool {
+	for _, s := range list {
+		if s == "*" || s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func allowedParams(grant, params []string) bool {
+	for _, p := range params {
+		if !allowed(grant, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasModelOverride reports whether req names a provider, model or parameters.
+func hasModelOverride(req ChatRequest) bool {
+	return req.Provider != "" || req.Model != "" || len(req.Params.names()) > 0
+}
+
+// authorizeModelOverride enforces the override policy for req, writing a 400 for
+// invalid parameters or a 403 (with an audit event) for overrides the caller's
+// roles do not allow. It reports whether the request may proceed.
+func authorizeModelOverride(w http.ResponseWriter, r *http.Request, pol *ModelOverridePolicy, auditor *runtimesecurity.Auditor, req ChatRequest) bool {
+	if !hasModelOverride(req) {
+		return true
+	}
+	if err := req.Params.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	roles := overrideRoles(r.Context())
+	reason := pol.check(roles, req)
+	if reason == "" {
+		return true
+	}
+	params := req.Params.names()
+	sort.Strings(params)
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(r.Context()), TenantID: req.TenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "denied", Reason: "model_override",
+		Attributes: map[string]interface{}{"provider": req.Provider, "model": req.Model, "params": params, "roles": roles},
+	}
+	if p, ok := runtimesecurity.FromPrincipal(r.Context()); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	auditor.Record(r.Context(), ev)
+	runtimesecurity.PolicyViolations.Inc()
+	http.Error(w, "model override denied: "+reason, http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,443 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAICompatFile is the project-relative path of the model mapping of the
+// OpenAI-compatible endpoint. Without it the endpoint is not served.
+const OpenAICompatFile = "config/openai_compat.yaml"
+
+// OpenAIModel maps a model name sent by OpenAI clients onto a component.
+type OpenAIModel struct {
+	Name      string `yaml:"name"`
+	Component string `yaml:"component"`
+	// Context defaults to Component.
+	Context    string `yaml:"context"`
+	TenantID   string `yaml:"tenant_id"`
+	PromptFile string `yaml:"prompt_file"`
+	// ForwardParams passes temperature, top_p and max_tokens on as a model
+	// override, which config/model_overrides.yaml must allow. They are
+	// ignored otherwise.
+	ForwardParams bool `yaml:"forward_params"`
+}
+
+// OpenAICompatConfig is the parsed config/openai_compat.yaml.
+type OpenAICompatConfig struct {
+	Models []OpenAIModel `yaml:"models"`
+}
+
+// LoadOpenAICompat reads the model mapping under root. A missing file yields
+// an empty mapping.
+func LoadOpenAICompat(root string) (*OpenAICompatConfig, error) {
+	cfg := &OpenAICompatConfig{}
+	by, err := os.ReadFile(filepath.Join(root, OpenAICompatFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(by, cfg); err != nil {
+		return &OpenAICompatConfig{}, fmt.Errorf("parse %s: %w", OpenAICompatFile, err)
+	}
+	seen := map[string]bool{}
+	for i := range cfg.Models {
+		m := &cfg.Models[i]
+		if m.Name == "" || m.Component == "" {
+			return &OpenAICompatConfig{}, fmt.Errorf("%s: model %d: name and component are required", OpenAICompatFile, i+1)
+		}
+		if seen[m.Name] {
+			return &OpenAICompatConfig{}, fmt.Errorf("%s: duplicate model %q", OpenAICompatFile, m.Name)
+		}
+		seen[m.Name] = true
+		if m.Context == "" {
+			m.Context = m.Component
+		}
+	}
+	return cfg, nil
+}
+
+func (c *OpenAICompatConfig) model(name string) (OpenAIModel, bool) {
+	for _, m := range c.Models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return OpenAIModel{}, false
+}
+
+// openAIContent is message content: a string, or an array of parts of which
+// only text parts are supported.
+type openAIContent string
+
+func (c *openAIContent) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = openAIContent(s)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type != "text" {
+			return fmt.Errorf("unsupported content part type %q (only text is supported)", p.Type)
+		}
+		texts = append(texts, p.Text)
+	}
+	*c = openAIContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+type openAIMessage struct {
+	Role    string        `json:"role"`
+	Content openAIContent `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Stream        bool            `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	MaxTokens           int             `json:"max_tokens"`
+	MaxCompletionTokens int             `json:"max_completion_tokens"`
+	N                   int             `json:"n"`
+	User                string          `json:"user"`
+	Tools               json.RawMessage `json:"tools"`
+}
+
+type openAIResponseMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type openAIChoice struct {
+	Index   int                    `json:"index"`
+	Message *openAIResponseMessage `json:"message,omitempty"`
+	Delta   *openAIDelta           `json:"delta,omitempty"`
+	// FinishReason is null in stream chunks until the last one.
+	FinishReason *string `json:"finish_reason"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAICompletion struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// writeOpenAIError writes an error in the shape OpenAI clients parse.
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	typ := "api_error"
+	switch {
+	case status == http.StatusUnauthorized:
+		typ = "authentication_error"
+	case status == http.StatusForbidden:
+		typ = "permission_error"
+	case status == http.StatusNotFound:
+		typ = "not_found_error"
+	case status == http.StatusTooManyRequests:
+		typ = "rate_limit_error"
+	case status < 500:
+		typ = "invalid_request_error"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]openAIError{"error": {Message: message, Type: typ}})
+}
+
+// copyChatHeaders passes the chat handler's response headers (quota, rollout,
+// experiment, retry) on to the client.
+func copyChatHeaders(dst, src http.Header) {
+	for k, vs := range src {
+		switch k {
+		case "Content-Type", "Content-Length", "X-Content-Type-Options":
+			continue
+		}
+		dst[k] = vs
+	}
+}
+
+// registerOpenAIRoutes wires POST /v1/chat/completions and GET /v1/models
+// when config/openai_compat.yaml maps models. Completions are served
+// in-process by the chat handler on mux, so every runtime policy applies.
+func registerOpenAIRoutes(mux *http.ServeMux, cfg *OpenAICompatConfig, guard *requestGuard) {
+	if cfg == nil || len(cfg.Models) == 0 {
+		return
+	}
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+		chatRes := runtimesecurity.Resource{Type: "chat", Name: "chat", Tenant: r.Header.Get("X-Tenant-ID")}
+		if _, ok := guard.authorize(rec, r, "chat:models", chatRes, runtimesecurity.ActionExecute); !ok {
+			copyChatHeaders(w.Header(), rec.header)
+			writeOpenAIError(w, rec.status, strings.TrimSpace(rec.body.String()))
+			return
+		}
+		type model struct {
+			ID      string `json:"id"`
+			Object  string `json:"object"`
+			Created int64  `json:"created"`
+			OwnedBy string `json:"owned_by"`
+		}
+		list := struct {
+			Object string  `json:"object"`
+			Data   []model `json:"data"`
+		}{Object: "list", Data: []model{}}
+		for _, m := range cfg.Models {
+			list.Data = append(list.Data, model{ID: m.Name, Object: "model", OwnedBy: "contexis"})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var in openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeOpenAIError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+				return
+			}
+			writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+		m, ok := cfg.model(in.Model)
+		if !ok {
+			writeOpenAIError(w, http.StatusNotFound, fmt.Sprintf("The model `%s` does not exist", in.Model))
+			return
+		}
+		req, err := in.chatRequest(m)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.TenantID == "" {
+			req.TenantID = r.Header.Get("X-Tenant-ID")
+		}
+		c := &openAICompletion{ID: "chatcmpl-" + requestIDFrom(r.Context()), Created: time.Now().Unix(), Model: in.Model}
+		if in.Stream {
+			c.Object = "chat.completion.chunk"
+			streamOpenAICompletion(w, r, mux, req, c, in.StreamOptions != nil && in.StreamOptions.IncludeUsage)
+			return
+		}
+		c.Object = "chat.completion"
+		rec, resp, ok := serveChat(w, r, mux, req, nil)
+		if !ok {
+			return
+		}
+		copyChatHeaders(w.Header(), rec.header)
+		stop := "stop"
+		c.Choices = []openAIChoice{{Message: &openAIResponseMessage{Role: "assistant", Content: resp.Rendered}, FinishReason: &stop}}
+		c.Usage = openAIUsageOf(resp)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	})
+}
+
+// chatRequest maps an OpenAI request onto a chat request for m. The last user
+// message is the query; all messages are passed to the prompt as "messages".
+func (in openAIChatRequest) chatRequest(m OpenAIModel) (ChatRequest, error) {
+	if in.N > 1 {
+		return ChatRequest{}, errors.New("n > 1 is not supported")
+	}
+	if len(in.Tools) > 0 && string(in.Tools) != "null" && string(in.Tools) != "[]" {
+		return ChatRequest{}, errors.New("tools are not supported: declare them in the context instead")
+	}
+	query := ""
+	messages := make([]interface{}, 0, len(in.Messages))
+	for _, msg := range in.Messages {
+		if msg.Role == "user" {
+			query = string(msg.Content)
+		}
+		messages = append(messages, map[string]interface{}{"role": msg.Role, "content": string(msg.Content)})
+	}
+	if query == "" {
+		return ChatRequest{}, errors.New("messages must include a user message")
+	}
+	req := ChatRequest{
+		TenantID: m.TenantID, Context: m.Context, Component: m.Component, PromptFile: m.PromptFile,
+		Query: query, UserID: in.User,
+		Data: map[string]interface{}{"user_input": query, "messages": messages},
+	}
+	if m.ForwardParams {
+		p := &ModelParams{Temperature: in.Temperature, TopP: in.TopP, MaxTokens: in.MaxTokens}
+		if in.MaxCompletionTokens > 0 {
+			p.MaxTokens = in.MaxCompletionTokens
+		}
+		if len(p.names()) > 0 {
+			req.Params = p
+		}
+	}
+	return req, nil
+}
+
+// serveChat runs req through the chat handler on mux. On failure the error
+// has been written to w unless sink already streamed.
+func serveChat(w http.ResponseWriter, r *http.Request, mux http.Handler, req ChatRequest, sink func(ChatEvent)) (*bufferedResponse, *ChatResponse, bool) {
+	body, _ := json.Marshal(req)
+	ctx := r.Context()
+	if sink != nil {
+		ctx = withChatEventSink(ctx, sink)
+	}
+	inner, err := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return nil, nil, false
+	}
+	for k, vs := range r.Header {
+		switch k {
+		case "Content-Length", "Content-Type", "Accept-Encoding":
+			continue
+		}
+		inner.Header[k] = vs
+	}
+	inner.Header.Set("Content-Type", "application/json")
+	inner.RemoteAddr = r.RemoteAddr
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	mux.ServeHTTP(rec, inner)
+	if rec.status >= 300 {
+		copyChatHeaders(w.Header(), rec.header)
+		writeOpenAIError(w, rec.status, strings.TrimSpace(rec.body.String()))
+		return rec, nil, false
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(rec.body.Bytes(), &resp); err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "decode response: "+err.Error())
+		return rec, nil, false
+	}
+	return rec, &resp, true
+}
+
+// streamOpenAICompletion answers with server-sent chat.completion.chunk
+// events. Tokens are forwarded as the model produces them when the chat
+// pipeline streams; buffered answers arrive as one chunk.
+func streamOpenAICompletion(w http.ResponseWriter, r *http.Request, mux http.Handler, req ChatRequest, c *openAICompletion, includeUsage bool) {
+	rc := http.NewResponseController(w)
+	started, streamed := false, false
+	var writeErr error
+	send := func(v interface{}) {
+		if writeErr != nil {
+			return
+		}
+		by, _ := json.Marshal(v)
+		if _, writeErr = fmt.Fprintf(w, "data: %s\n\n", by); writeErr == nil {
+			_ = rc.Flush()
+		}
+	}
+	chunk := func(delta *openAIDelta, finish *string) openAICompletion {
+		out := *c
+		out.Choices = []openAIChoice{{Delta: delta, FinishReason: finish}}
+		return out
+	}
+	start := func(headers http.Header) {
+		if started {
+			return
+		}
+		started = true
+		copyChatHeaders(w.Header(), headers)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		send(chunk(&openAIDelta{Role: "assistant"}, nil))
+	}
+	// A gate in front of w: errors before the first token are written as JSON
+	gate := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	sink := func(ev ChatEvent) {
+		if ev.Type != EventToken || ev.Content == "" {
+			return
+		}
+		start(nil)
+		streamed = true
+		send(chunk(&openAIDelta{Content: ev.Content}, nil))
+	}
+	rec, resp, ok := serveChat(gate, r, mux, req, sink)
+	if !ok {
+		if !started {
+			copyChatHeaders(w.Header(), gate.header)
+			writeOpenAIError(w, gate.status, openAIErrorMessage(gate.body.Bytes()))
+			return
+		}
+		// The answer failed after tokens were sent: end the stream with the error
+		send(map[string]openAIError{"error": {Message: openAIErrorMessage(gate.body.Bytes()), Type: "api_error"}})
+		logger.WithContext(r.Context()).Warn("openai stream ended with an error", zap.Int("status", gate.status))
+		return
+	}
+	start(rec.header)
+	if !streamed && resp.Rendered != "" {
+		send(chunk(&openAIDelta{Content: resp.Rendered}, nil))
+	}
+	stop := "stop"
+	send(chunk(&openAIDelta{}, &stop))
+	if includeUsage {
+		final := *c
+		final.Choices = []openAIChoice{}
+		final.Usage = openAIUsageOf(resp)
+		send(final)
+	}
+	if writeErr == nil {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		_ = rc.Flush()
+	}
+}
+
+// openAIErrorMessage returns the message of an error body written by
+// writeOpenAIError.
+func openAIErrorMessage(body []byte) string {
+	var e struct {
+		Error openAIError `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+		return e.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func openAIUsageOf(resp *ChatResponse) *openAIUsage {
+	u := &openAIUsage{}
+	if resp.Usage != nil {
+		u.PromptTokens, u.CompletionTokens, u.TotalTokens = resp.Usage.PromptTokens, resp.Usage.CompletionTokens, resp.Usage.TotalTokens()
+	}
+	return u
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	runtimeapproval "github.com/contexis-cmp/contexis/src/runtime/approval"
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+)
+
+// apiOperation describes an endpoint of the HTTP API for the OpenAPI document.
+// Request and response are zero values of the Go types the handler decodes and
+// encodes; their schemas are derived from the json tags.
+type apiOperation struct {
+	method, path string
+	id, tag      string
+	summary      string
+	query        []string
+	request      interface{}
+	response     interface{}
+	optional     bool   // the request body may be omitted
+	status       int    // success status; 200 when unset
+	text         string // text/plain response instead of JSON
+}
+
+// apiOperations lists the documented endpoints. Keep it in sync with the routes
+// registered by NewHandlerWithProvider.
+var apiOperations = []apiOperation{
+	{method: "get", path: "/healthz", id: "health", tag: "health", summary: "Liveness probe", text: "ok"},
+	{method: "get", path: "/readyz", id: "ready", tag: "health", summary: "Readiness probe", text: "ready"},
+	{method: "get", path: "/version", id: "version", tag: "health", summary: "Build information", response: buildinfo.Info{}},
+	{method: "post", path: "/api/v1/chat", id: "chat", tag: "chat", summary: "Answer a query with a context", query: []string{"debug"}, request: ChatRequest{}, response: ChatResponse{}},
+	{method: "get", path: "/api/v1/contexts", id: "listContexts", tag: "contexts", summary: "List contexts", query: []string{"tenant_id"}, response: ContextListResponse{}},
+	{method: "get", path: "/api/v1/contexts/{name}", id: "getContext", tag: "contexts", summary: "Get a resolved context", query: []string{"tenant_id"}, response: ContextResponse{}},
+	{method: "post", path: "/api/v1/memory/{component}/search", id: "searchMemory", tag: "memory", summary: "Search a component's memory", request: MemorySearchRequest{}, response: MemorySearchResponse{}},
+	{method: "post", path: "/api/v1/memory/{component}/documents", id: "ingestDocuments", tag: "memory", summary: "Ingest or replace documents", request: MemoryIngestRequest{}, response: runtimememory.IngestResult{}},
+	{method: "delete", path: "/api/v1/memory/{component}/documents/{id}", id: "deleteDocument", tag: "memory", summary: "Remove a document", query: []string{"tenant_id"}, response: runtimememory.IngestResult{}},
+	{method: "get", path: "/api/v1/usage", id: "getUsage", tag: "usage", summary: "Token usage and cost per day, tenant and component", query: []string{"from", "to", "tenant_id", "component"}, response: runtimeusage.Report{}},
+	{method: "get", path: "/api/v1/approvals", id: "listApprovals", tag: "approvals", summary: "List approval requests", query: []string{"tenant_id", "status"}, response: ApprovalList{}},
+	{method: "get", path: "/api/v1/approvals/{id}", id: "getApproval", tag: "approvals", summary: "Get an approval request", response: runtimeapproval.Request{}},
+	{method: "post", path: "/api/v1/approvals/{id}/approve", id: "approve", tag: "approvals", summary: "Approve a pending action", request: ApprovalDecision{}, optional: true, response: runtimeapproval.Request{}},
+	{method: "post", path: "/api/v1/approvals/{id}/deny", id: "deny", tag: "approvals", summary: "Deny a pending action", request: ApprovalDecision{}, optional: true, response: runtimeapproval.Request{}},
+	{method: "get", path: "/api/v1/admin/keys", id: "listKeys", tag: "keys", summary: "List API keys", response: KeyList{}},
+	{method: "post", path: "/api/v1/admin/keys", id: "createKey", tag: "keys", summary: "Create an API key", request: runtimesecurity.KeySpec{}, response: CreatedKey{}, status: http.StatusCreated},
+	{method: "get", path: "/api/v1/admin/keys/{id}", id: "getKey", tag: "keys", summary: "Get an API key", response: runtimesecurity.APIKey{}},
+	{method: "patch", path: "/api/v1/admin/keys/{id}", id: "updateKey", tag: "keys", summary: "Update an API key", request: keyPatch{}, response: runtimesecurity.APIKey{}},
+	{method: "post", path: "/api/v1/admin/keys/{id}/rotate", id: "rotateKey", tag: "keys", summary: "Rotate an API key's secret", query: []string{"grace"}, response: CreatedKey{}},
+	{method: "delete", path: "/api/v1/admin/keys/{id}", id: "revokeKey", tag: "keys", summary: "Revoke an API key", response: runtimesecurity.APIKey{}},
+	{method: "post", path: "/api/v1/jobs", id: "submitJob", tag: "jobs", summary: "Submit a background job", request: JobSubmitRequest{}, response: runtimejobs.Job{}, status: http.StatusAccepted},
+	{method: "get", path: "/api/v1/jobs", id: "listJobs", tag: "jobs", summary: "List background jobs", query: []string{"tenant_id", "status"}, response: JobList{}},
+	{method: "get", path: "/api/v1/jobs/{id}", id: "getJob", tag: "jobs", summary: "Get a background job", response: runtimejobs.Job{}},
+	{method: "delete", path: "/api/v1/jobs/{id}", id: "cancelJob", tag: "jobs", summary: "Cancel a background job", response: runtimejobs.Job{}},
+	{method: "get", path: "/api/v1/users/{id}/export", id: "exportUser", tag: "privacy", summary: "Export a user's data", query: []string{"tenant_id"}, response: runtimeprivacy.Export{}},
+	{method: "delete", path: "/api/v1/users/{id}/data", id: "deleteUser", tag: "privacy", summary: "Erase a user's data", query: []string{"tenant_id"}, response: runtimeprivacy.Deletion{}},
+}
+
+var pathParamRe = regexp.MustCompile(`\{([A-Za-z_]+)\}`)
+
+// OpenAPI returns the OpenAPI 3.1 document of the HTTP API. Schemas are
+// generated from the request and response types, so the document follows the
+// code.
+func OpenAPI() map[string]interface{} {
+	g := &schemaGen{schemas: map[string]interface{}{}, names: map[reflect.Type]string{}, inputs: map[reflect.Type]bool{}}
+	for _, op := range apiOperations {
+		if op.request != nil {
+			g.markInput(reflect.TypeOf(op.request))
+		}
+	}
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		o := map[string]interface{}{
+			"operationId": op.id,
+			"summary":     op.summary,
+			"tags":        []string{op.tag},
+		}
+		var params []interface{}
+		for _, m := range pathParamRe.FindAllStringSubmatch(op.path, -1) {
+			params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+		}
+		for _, q := range op.query {
+			params = append(params, map[string]interface{}{"name": q, "in": "query", "schema": map[string]interface{}{"type": "string"}})
+		}
+		if op.method == "post" {
+			params = append(params, map[string]interface{}{
+				"name": idempotencyKeyHeader, "in": "header",
+				"description": "Replays the response of an earlier request with the same key instead of running it again",
+				"schema":      map[string]interface{}{"type": "string", "maxLength": maxIdempotencyKeyLength},
+			})
+		}
+		if len(params) > 0 {
+			o["parameters"] = params
+		}
+		if op.request != nil {
+			o["requestBody"] = map[string]interface{}{
+				"required": !op.optional,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.request))}},
+			}
+		}
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := map[string]interface{}{"description": http.StatusText(status)}
+		switch {
+		case op.text != "":
+			ok["content"] = map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "example": op.text}}}
+		case op.response != nil:
+			ok["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(op.response))}}
+		}
+		o["responses"] = map[string]interface{}{
+			strconv.Itoa(status): ok,
+			"default":            map[string]interface{}{"$ref": "#/components/responses/Error"},
+		}
+		item, _ := paths[op.path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[op.method] = o
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Contexis CMP runtime",
+			"version":     buildinfo.Get().Version,
+			"description": "HTTP API of `ctx serve`. Chat is also available as a WebSocket at /api/v1/chat/ws.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error message",
+					"content":     map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+				},
+			},
+			// Enforced when CMP_AUTH_ENABLED=true or CMP_AUTH_MODE=oidc
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []string{}}},
+	}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGen derives JSON Schemas from Go types the way encoding/json encodes
+// them. Named structs become components referenced with $ref.
+type schemaGen struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+	// inputs are structs only sent by clients; their fields are optional
+	// because the handlers default missing values.
+	inputs map[reflect.Type]bool
+}
+
+func (g *schemaGen) markInput(t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || g.inputs[t] || t == timeType {
+		return
+	}
+	g.inputs[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		g.markInput(t.Field(i).Type)
+	}
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "description": "nanoseconds"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return map[string]interface{}{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + g.component(t)}
+	}
+	// interface{} and anything encoding/json passes through
+	return map[string]interface{}{}
+}
+
+// genericSchemaNames are prefixed with their package to keep generated clients
+// clear of the built-in Request and Response types.
+var genericSchemaNames = map[string]bool{"Request": true, "Response": true, "Error": true}
+
+// component registers a named struct and returns its schema name. Types of
+// the same name from different packages are prefixed with their package.
+func (g *schemaGen) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.schemas[name]; taken || genericSchemaNames[name] {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.schemas[name] = map[string]interface{}{} // placeholder for recursive types
+	g.schemas[name] = g.object(t)
+	return name
+}
+
+// object is the schema of a struct's JSON fields, including those of embedded
+// structs.
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	var required []string
+	input := g.inputs[t]
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			ft := f.Type
+			if f.Anonymous && name == "" {
+				for ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = g.schema(ft)
+			if !input && !strings.Contains(opts, "omitempty") && ft.Kind() != reflect.Ptr {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+	out := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+	return out
+}
+
+// swaggerUI loads Swagger UI from a CDN. Deployments with a strict
+// Content-Security-Policy must allow unpkg.com for /docs to render.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Contexis CMP API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+<script>window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// registerOpenAPIRoutes serves the OpenAPI document and Swagger UI. Both are
+// public like /version: they describe the API, not its data.
+func registerOpenAPIRoutes(mux *http.ServeMux) {
+	doc, _ := json.MarshalIndent(OpenAPI(), "", "  ")
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+	})
+	mux.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(swaggerUI))
+	})
+}
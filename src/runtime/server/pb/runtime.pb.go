@@ -0,0 +1,687 @@
+// Contexis runtime gRPC API.
+//
+// The gRPC services mirror the HTTP API (/api/v1/chat, /api/v1/memory/{component}/search,
+// /readyz) and share its security middleware. Pass credentials as "authorization"
+// metadata ("Bearer <token>").
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.27.1
+// source: runtime.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type HealthCheckResponse_Status int32
+
+const (
+	HealthCheckResponse_STATUS_UNSPECIFIED HealthCheckResponse_Status = 0
+	HealthCheckResponse_SERVING            HealthCheckResponse_Status = 1
+	HealthCheckResponse_NOT_SERVING        HealthCheckResponse_Status = 2
+)
+
+// Enum value maps for HealthCheckResponse_Status.
+var (
+	HealthCheckResponse_Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "SERVING",
+		2: "NOT_SERVING",
+	}
+	HealthCheckResponse_Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"SERVING":            1,
+		"NOT_SERVING":        2,
+	}
+)
+
+func (x HealthCheckResponse_Status) Enum() *HealthCheckResponse_Status {
+	p := new(HealthCheckResponse_Status)
+	*p = x
+	return p
+}
+
+func (x HealthCheckResponse_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HealthCheckResponse_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_runtime_proto_enumTypes[0].Descriptor()
+}
+
+func (HealthCheckResponse_Status) Type() protoreflect.EnumType {
+	return &file_runtime_proto_enumTypes[0]
+}
+
+func (x HealthCheckResponse_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HealthCheckResponse_Status.Descriptor instead.
+func (HealthCheckResponse_Status) EnumDescriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{7, 0}
+}
+
+type ChatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TenantId      string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Context       string                 `protobuf:"bytes,2,opt,name=context,proto3" json:"context,omitempty"`
+	Component     string                 `protobuf:"bytes,3,opt,name=component,proto3" json:"component,omitempty"`
+	Query         string                 `protobuf:"bytes,4,opt,name=query,proto3" json:"query,omitempty"`
+	TopK          int32                  `protobuf:"varint,5,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"`
+	PromptFile    string                 `protobuf:"bytes,7,opt,name=prompt_file,json=promptFile,proto3" json:"prompt_file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatRequest) Reset() {
+	*x = ChatRequest{}
+	mi := &file_runtime_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatRequest) ProtoMessage() {}
+
+func (x *ChatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatRequest.ProtoReflect.Descriptor instead.
+func (*ChatRequest) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ChatRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetComponent() string {
+	if x != nil {
+		return x.Component
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ChatRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+func (x *ChatRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ChatRequest) GetPromptFile() string {
+	if x != nil {
+		return x.PromptFile
+	}
+	return ""
+}
+
+// Source attributes part of an answer to a retrieved memory chunk.
+type Source struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Score         float64                `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+	Start         int32                  `protobuf:"varint,4,opt,name=start,proto3" json:"start,omitempty"`
+	End           int32                  `protobuf:"varint,5,opt,name=end,proto3" json:"end,omitempty"`
+	Cited         bool                   `protobuf:"varint,6,opt,name=cited,proto3" json:"cited,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Source) Reset() {
+	*x = Source{}
+	mi := &file_runtime_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Source) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Source) ProtoMessage() {}
+
+func (x *Source) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Source.ProtoReflect.Descriptor instead.
+func (*Source) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Source) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Source) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Source) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Source) GetStart() int32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *Source) GetEnd() int32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *Source) GetCited() bool {
+	if x != nil {
+		return x.Cited
+	}
+	return false
+}
+
+type ChatResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rendered      string                 `protobuf:"bytes,1,opt,name=rendered,proto3" json:"rendered,omitempty"`
+	Sources       []*Source              `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatResponse) Reset() {
+	*x = ChatResponse{}
+	mi := &file_runtime_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatResponse) ProtoMessage() {}
+
+func (x *ChatResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatResponse.ProtoReflect.Descriptor instead.
+func (*ChatResponse) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChatResponse) GetRendered() string {
+	if x != nil {
+		return x.Rendered
+	}
+	return ""
+}
+
+func (x *ChatResponse) GetSources() []*Source {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+type MemorySearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TenantId      string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	Component     string                 `protobuf:"bytes,2,opt,name=component,proto3" json:"component,omitempty"`
+	Query         string                 `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	TopK          int32                  `protobuf:"varint,4,opt,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MemorySearchRequest) Reset() {
+	*x = MemorySearchRequest{}
+	mi := &file_runtime_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MemorySearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MemorySearchRequest) ProtoMessage() {}
+
+func (x *MemorySearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MemorySearchRequest.ProtoReflect.Descriptor instead.
+func (*MemorySearchRequest) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MemorySearchRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *MemorySearchRequest) GetComponent() string {
+	if x != nil {
+		return x.Component
+	}
+	return ""
+}
+
+func (x *MemorySearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *MemorySearchRequest) GetTopK() int32 {
+	if x != nil {
+		return x.TopK
+	}
+	return 0
+}
+
+type SearchResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Score         float64                `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+	Metadata      *structpb.Struct       `protobuf:"bytes,4,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResult) Reset() {
+	*x = SearchResult{}
+	mi := &file_runtime_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResult) ProtoMessage() {}
+
+func (x *SearchResult) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResult.ProtoReflect.Descriptor instead.
+func (*SearchResult) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SearchResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SearchResult) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *SearchResult) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *SearchResult) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type MemorySearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*SearchResult        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MemorySearchResponse) Reset() {
+	*x = MemorySearchResponse{}
+	mi := &file_runtime_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MemorySearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MemorySearchResponse) ProtoMessage() {}
+
+func (x *MemorySearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MemorySearchResponse.ProtoReflect.Descriptor instead.
+func (*MemorySearchResponse) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MemorySearchResponse) GetResults() []*SearchResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type HealthCheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckRequest) Reset() {
+	*x = HealthCheckRequest{}
+	mi := &file_runtime_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckRequest) ProtoMessage() {}
+
+func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
+func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{6}
+}
+
+type HealthCheckResponse struct {
+	state         protoimpl.MessageState     `protogen:"open.v1"`
+	Status        HealthCheckResponse_Status `protobuf:"varint,1,opt,name=status,proto3,enum=contexis.v1.HealthCheckResponse_Status" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckResponse) Reset() {
+	*x = HealthCheckResponse{}
+	mi := &file_runtime_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckResponse) ProtoMessage() {}
+
+func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_runtime_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
+func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
+	return file_runtime_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HealthCheckResponse) GetStatus() HealthCheckResponse_Status {
+	if x != nil {
+		return x.Status
+	}
+	return HealthCheckResponse_STATUS_UNSPECIFIED
+}
+
+var File_runtime_proto protoreflect.FileDescriptor
+
+const file_runtime_proto_rawDesc = "" +
+	"\n" +
+	"\rruntime.proto\x12\vcontexis.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xdb\x01\n" +
+	"\vChatRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x18\n" +
+	"\acontext\x18\x02 \x01(\tR\acontext\x12\x1c\n" +
+	"\tcomponent\x18\x03 \x01(\tR\tcomponent\x12\x14\n" +
+	"\x05query\x18\x04 \x01(\tR\x05query\x12\x13\n" +
+	"\x05top_k\x18\x05 \x01(\x05R\x04topK\x12+\n" +
+	"\x04data\x18\x06 \x01(\v2\x17.google.protobuf.StructR\x04data\x12\x1f\n" +
+	"\vprompt_file\x18\a \x01(\tR\n" +
+	"promptFile\"\x82\x01\n" +
+	"\x06Source\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x14\n" +
+	"\x05score\x18\x03 \x01(\x01R\x05score\x12\x14\n" +
+	"\x05start\x18\x04 \x01(\x05R\x05start\x12\x10\n" +
+	"\x03end\x18\x05 \x01(\x05R\x03end\x12\x14\n" +
+	"\x05cited\x18\x06 \x01(\bR\x05cited\"Y\n" +
+	"\fChatResponse\x12\x1a\n" +
+	"\brendered\x18\x01 \x01(\tR\brendered\x12-\n" +
+	"\asources\x18\x02 \x03(\v2\x13.contexis.v1.SourceR\asources\"{\n" +
+	"\x13MemorySearchRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1c\n" +
+	"\tcomponent\x18\x02 \x01(\tR\tcomponent\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\x12\x13\n" +
+	"\x05top_k\x18\x04 \x01(\x05R\x04topK\"\x83\x01\n" +
+	"\fSearchResult\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12\x14\n" +
+	"\x05score\x18\x03 \x01(\x01R\x05score\x123\n" +
+	"\bmetadata\x18\x04 \x01(\v2\x17.google.protobuf.StructR\bmetadata\"K\n" +
+	"\x14MemorySearchResponse\x123\n" +
+	"\aresults\x18\x01 \x03(\v2\x19.contexis.v1.SearchResultR\aresults\"\x14\n" +
+	"\x12HealthCheckRequest\"\x96\x01\n" +
+	"\x13HealthCheckResponse\x12?\n" +
+	"\x06status\x18\x01 \x01(\x0e2'.contexis.v1.HealthCheckResponse.StatusR\x06status\">\n" +
+	"\x06Status\x12\x16\n" +
+	"\x12STATUS_UNSPECIFIED\x10\x00\x12\v\n" +
+	"\aSERVING\x10\x01\x12\x0f\n" +
+	"\vNOT_SERVING\x10\x022J\n" +
+	"\vChatService\x12;\n" +
+	"\x04Chat\x12\x18.contexis.v1.ChatRequest\x1a\x19.contexis.v1.ChatResponse2^\n" +
+	"\rMemoryService\x12M\n" +
+	"\x06Search\x12 .contexis.v1.MemorySearchRequest\x1a!.contexis.v1.MemorySearchResponse2[\n" +
+	"\rHealthService\x12J\n" +
+	"\x05Check\x12\x1f.contexis.v1.HealthCheckRequest\x1a .contexis.v1.HealthCheckResponseB;Z9github.com/contexis-cmp/contexis/src/runtime/server/pb;pbb\x06proto3"
+
+var (
+	file_runtime_proto_rawDescOnce sync.Once
+	file_runtime_proto_rawDescData []byte
+)
+
+func file_runtime_proto_rawDescGZIP() []byte {
+	file_runtime_proto_rawDescOnce.Do(func() {
+		file_runtime_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_runtime_proto_rawDesc), len(file_runtime_proto_rawDesc)))
+	})
+	return file_runtime_proto_rawDescData
+}
+
+var file_runtime_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_runtime_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_runtime_proto_goTypes = []any{
+	(HealthCheckResponse_Status)(0), // 0: contexis.v1.HealthCheckResponse.Status
+	(*ChatRequest)(nil),             // 1: contexis.v1.ChatRequest
+	(*Source)(nil),                  // 2: contexis.v1.Source
+	(*ChatResponse)(nil),            // 3: contexis.v1.ChatResponse
+	(*MemorySearchRequest)(nil),     // 4: contexis.v1.MemorySearchRequest
+	(*SearchResult)(nil),            // 5: contexis.v1.SearchResult
+	(*MemorySearchResponse)(nil),    // 6: contexis.v1.MemorySearchResponse
+	(*HealthCheckRequest)(nil),      // 7: contexis.v1.HealthCheckRequest
+	(*HealthCheckResponse)(nil),     // 8: contexis.v1.HealthCheckResponse
+	(*structpb.Struct)(nil),         // 9: google.protobuf.Struct
+}
+var file_runtime_proto_depIdxs = []int32{
+	9, // 0: contexis.v1.ChatRequest.data:type_name -> google.protobuf.Struct
+	2, // 1: contexis.v1.ChatResponse.sources:type_name -> contexis.v1.Source
+	9, // 2: contexis.v1.SearchResult.metadata:type_name -> google.protobuf.Struct
+	5, // 3: contexis.v1.MemorySearchResponse.results:type_name -> contexis.v1.SearchResult
+	0, // 4: contexis.v1.HealthCheckResponse.status:type_name -> contexis.v1.HealthCheckResponse.Status
+	1, // 5: contexis.v1.ChatService.Chat:input_type -> contexis.v1.ChatRequest
+	4, // 6: contexis.v1.MemoryService.Search:input_type -> contexis.v1.MemorySearchRequest
+	7, // 7: contexis.v1.HealthService.Check:input_type -> contexis.v1.HealthCheckRequest
+	3, // 8: contexis.v1.ChatService.Chat:output_type -> contexis.v1.ChatResponse
+	6, // 9: contexis.v1.MemoryService.Search:output_type -> contexis.v1.MemorySearchResponse
+	8, // 10: contexis.v1.HealthService.Check:output_type -> contexis.v1.HealthCheckResponse
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_runtime_proto_init() }
+func file_runtime_proto_init() {
+	if File_runtime_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_runtime_proto_rawDesc), len(file_runtime_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_runtime_proto_goTypes,
+		DependencyIndexes: file_runtime_proto_depIdxs,
+		EnumInfos:         file_runtime_proto_enumTypes,
+		MessageInfos:      file_runtime_proto_msgTypes,
+	}.Build()
+	File_runtime_proto = out.File
+	file_runtime_proto_goTypes = nil
+	file_runtime_proto_depIdxs = nil
+}
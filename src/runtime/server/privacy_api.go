@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+// registerPrivacyRoutes wires the data-subject endpoints:
+// GET /api/v1/users/{id}/export returns the user's episodic memory, transcripts
+// and audit events; DELETE /api/v1/users/{id}/data erases memory and
+// transcripts. Both take tenant_id and require privacy:read or privacy:write
+// when auth is enabled. Tenant-bound keys only reach their own tenant's users.
+func registerPrivacyRoutes(mux *http.ServeMux, root string, guard *requestGuard) {
+	mux.HandleFunc("GET /api/v1/users/{id}/export", func(w http.ResponseWriter, r *http.Request) {
+		userID, tenantID, ok := privacySubject(w, r, guard, "privacy:export", runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		export, err := runtimeprivacy.ExportUser(root, tenantID, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		guard.auditor.Record(r.Context(), privacyEvent(r, "privacy:export", tenantID, userID, map[string]interface{}{
+			"transcript_turns": len(export.Transcripts), "audit_events": len(export.Audit),
+		}))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+userID+`-export.json"`)
+		_ = json.NewEncoder(w).Encode(export)
+	})
+
+	mux.HandleFunc("DELETE /api/v1/users/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+		userID, tenantID, ok := privacySubject(w, r, guard, "privacy:delete", runtimesecurity.ActionWrite)
+		if !ok {
+			return
+		}
+		deletion, err := runtimeprivacy.DeleteUser(root, tenantID, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		guard.auditor.Record(r.Context(), privacyEvent(r, "privacy:delete", tenantID, userID, map[string]interface{}{
+			"episodic_components": deletion.Components, "transcript_turns": deletion.Turns,
+		}))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(deletion)
+	})
+}
+
+// privacySubject validates the user ID and authorizes the caller for it,
+// returning the user and the tenant the request applies to.
+func privacySubject(w http.ResponseWriter, r *http.Request, guard *requestGuard, auditAction string, act runtimesecurity.Action) (string, string, bool) {
+	userID := r.PathValue("id")
+	if err := runtimeprivacy.ValidateUserID(userID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", "", false
+	}
+	tenantID := r.URL.Query().Get("tenant_id")
+	res := runtimesecurity.Resource{Type: "privacy", Name: userID, Tenant: tenantID}
+	principal, ok := guard.authorize(w, r, auditAction, res, act)
+	if !ok {
+		return "", "", false
+	}
+	if principal != nil && principal.TenantID != "" {
+		tenantID = principal.TenantID
+	}
+	return userID, tenantID, true
+}
+
+func privacyEvent(r *http.Request, action, tenantID, userID string, attrs map[string]interface{}) runtimesecurity.AuditEvent {
+	attrs["user_id"] = userID
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(r.Context()), TenantID: tenantID,
+		Action: action, Resource: "user", Result: "success", Attributes: attrs,
+	}
+	if p, ok := runtimesecurity.FromPrincipal(r.Context()); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	return ev
+}
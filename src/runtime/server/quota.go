@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimenotifications "github.com/contexis-cmp/contexis/src/runtime/notifications"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+	"go.uber.org/zap"
+)
+
+// quotaSubject returns the tenant and API key a request is billed to.
+func quotaSubject(ctx context.Context, tenantID string) (string, string) {
+	keyID := ""
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		keyID = p.KeyID
+		if tenantID == "" {
+			tenantID = p.TenantID
+		}
+	}
+	return tenantID, keyID
+}
+
+// setQuotaHeaders exposes the remaining monthly budget. Unlimited dimensions are omitted.
+func setQuotaHeaders(w http.ResponseWriter, st runtimeusage.QuotaStatus) {
+	if !st.Limited {
+		return
+	}
+	if st.RemainingTokens >= 0 {
+		w.Header().Set("X-Quota-Remaining-Tokens", strconv.Itoa(st.RemainingTokens))
+	}
+	if st.RemainingRequests >= 0 {
+		w.Header().Set("X-Quota-Remaining-Requests", strconv.Itoa(st.RemainingRequests))
+	}
+	w.Header().Set("X-Quota-Reset", st.Reset.Format(time.RFC3339))
+}
+
+// writeQuotaExceeded writes a 429 with the quota_exceeded error code and records
+// an audit event.
+func writeQuotaExceeded(ctx context.Context, w http.ResponseWriter, auditor *runtimesecurity.Auditor, tenantID string, st runtimeusage.QuotaStatus) {
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: tenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "denied", Reason: "quota_exceeded",
+		Attributes: map[string]interface{}{"scope": st.Scope, "subject": st.Subject, "limit": st.Limit},
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	auditor.Record(ctx, ev)
+
+	setQuotaHeaders(w, st)
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(st.Reset).Seconds())+1))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   "quota_exceeded",
+		"message": fmt.Sprintf("%s budget exhausted for %s %s", st.Limit, st.Scope, st.Subject),
+		"scope":   st.Scope,
+		"limit":   st.Limit,
+		"reset":   st.Reset.Format(time.RFC3339),
+	})
+}
+
+// notifyBudgetExceeded sends a budget.exceeded notification in the background.
+// Rejections of the same budget are keyed alike, so the channels hear about
+// an overrun once per cooldown rather than once per request.
+func notifyBudgetExceeded(notifier *runtimenotifications.Notifier, tenantID string, st runtimeusage.QuotaStatus) {
+	if notifier == nil {
+		return
+	}
+	fields := map[string]string{
+		"scope":   st.Scope,
+		"subject": st.Subject,
+		"limit":   st.Limit,
+		"reset":   st.Reset.Format(time.RFC3339),
+	}
+	if tenantID != "" {
+		fields["tenant"] = tenantID
+	}
+	ev := runtimenotifications.Event{
+		Type:     runtimenotifications.EventBudgetExceeded,
+		Severity: runtimenotifications.SeverityCritical,
+		Title:    fmt.Sprintf("Budget exhausted for %s %s", st.Scope, st.Subject),
+		Text:     fmt.Sprintf("The %s budget for %s %s is exhausted; chat requests are rejected until %s.", st.Limit, st.Scope, st.Subject, st.Reset.Format(time.RFC3339)),
+		Fields:   fields,
+		Key:      strings.Join([]string{runtimenotifications.EventBudgetExceeded, st.Scope, st.Subject, st.Limit, st.Reset.Format("2006-01")}, "/"),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := notifier.Notify(ctx, ev); err != nil {
+			logger.GetLogger().Warn("budget notification failed", zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the correlation ID in both directions; every
+// response includes it.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds client-supplied IDs, which end up in logs and audit
+// events.
+const maxRequestIDLen = 128
+
+// requestContext returns the request context carrying its correlation ID
+// and any W3C trace context from a traceparent header. A well-formed
+// incoming X-Request-ID is kept; otherwise the trace ID of traceparent is
+// used, and a new ID is generated when neither is present.
+func requestContext(r *http.Request) (context.Context, string) {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = ""
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			id = sc.TraceID().String()
+		}
+	}
+	if id == "" {
+		id = generateRequestID()
+	}
+	return context.WithValue(ctx, "request_id", id), id
+}
+
+// validRequestID accepts IDs of letters, digits and "-_.:", the characters
+// of UUIDs, trace IDs and our own timestamps, so they are safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
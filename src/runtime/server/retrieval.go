@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+)
+
+// retrievalOptions translates a context's retrieval section into overrides of
+// the component's memory_config.yaml, or nil when the context declares none.
+func retrievalOptions(ctxModel *corectx.Context) *runtimememory.RetrievalOptions {
+	rc := ctxModel.Retrieval
+	if rc == nil {
+		return nil
+	}
+	opts := &runtimememory.RetrievalOptions{Hybrid: rc.Hybrid, Filters: rc.Filters}
+	if r := rc.Reranker; r != nil {
+		opts.Rerank = &runtimememory.RerankConfig{Provider: r.Provider, Model: r.Model, TopN: r.TopN, Candidates: r.Candidates}
+	}
+	if c := rc.Chunking; c != nil {
+		opts.ChunkSize, opts.ChunkOverlap = c.Size, c.Overlap
+	}
+	return opts
+}
+
+// retrievalTopK returns the number of results to retrieve: the request's
+// top_k, else the context's retrieval.top_k, else 0 for the store default.
+func retrievalTopK(ctxModel *corectx.Context, requested int) int {
+	if requested > 0 {
+		return requested
+	}
+	if ctxModel.Retrieval != nil {
+		return ctxModel.Retrieval.TopK
+	}
+	return 0
+}
+
+// queryParaphraser asks the component's provider chain for query paraphrases
+// (memory_config.yaml multi_query mode provider) and records the tokens
+// against the request, or returns nil without a provider.
+func queryParaphraser(router *runtimemodel.Router, ledger *runtimeusage.Ledger, pricing *runtimeusage.Pricing, quotas *runtimeusage.QuotaManager, req ChatRequest) runtimememory.ParaphraseFunc {
+	chain := router.For(req.Component, req.Context)
+	if chain == nil {
+		return nil
+	}
+	return func(ctx context.Context, prompt string) (string, error) {
+		mctx, meter := runtimemodel.WithUsageMeter(ctx)
+		out, err := chain.Generate(mctx, prompt, runtimemodel.Params{Temperature: 0.3, MaxNewTokens: 128})
+		recordUsage(ctx, ledger, pricing, quotas, req, chain.Served(), meter.Total())
+		return out, err
+	}
+}
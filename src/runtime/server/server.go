@@ -1,8 +1,14 @@
 package server
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,17 +18,32 @@ import (
 	"strings"
 
 	"github.com/contexis-cmp/contexis/src/cli/logger"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	runtimeagent "github.com/contexis-cmp/contexis/src/runtime/agent"
+	runtimeapproval "github.com/contexis-cmp/contexis/src/runtime/approval"
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
 	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	runtimedispatch "github.com/contexis-cmp/contexis/src/runtime/dispatch"
+	runtimedrift "github.com/contexis-cmp/contexis/src/runtime/drift"
+	runtimeexperiment "github.com/contexis-cmp/contexis/src/runtime/experiment"
+	runtimeguardrails "github.com/contexis-cmp/contexis/src/runtime/guardrails"
+	runtimeidempotency "github.com/contexis-cmp/contexis/src/runtime/idempotency"
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
 	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
 	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimenotifications "github.com/contexis-cmp/contexis/src/runtime/notifications"
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
 	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
+	runtimerollout "github.com/contexis-cmp/contexis/src/runtime/rollout"
 	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 // ChatRequest is the request payload for POST /api/v1/chat.
@@ -34,11 +55,41 @@ type ChatRequest struct {
 	TopK       int                    `json:"top_k"`
 	Data       map[string]interface{} `json:"data"`
 	PromptFile string                 `json:"prompt_file"`
+	// SessionID and UserID keep a caller on one prompt experiment variant
+	// and context rollout version; the X-Session-ID header is used when
+	// session_id is empty.
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	// Provider, Model and Params override the routed model for this request,
+	// when config/model_overrides.yaml allows it for the caller's role.
+	Provider string       `json:"provider,omitempty"`
+	Model    string       `json:"model,omitempty"`
+	Params   *ModelParams `json:"params,omitempty"`
 }
 
 // ChatResponse is the response payload for POST /api/v1/chat.
 type ChatResponse struct {
 	Rendered string `json:"rendered"`
+	// Sources lists the memory chunks injected into the prompt.
+	Sources []Source `json:"sources,omitempty"`
+	// Usage reports the tokens consumed by inference, when a provider ran.
+	Usage *runtimemodel.Usage `json:"usage,omitempty"`
+	// Experiment identifies the prompt variant served, when an experiment runs.
+	Experiment *runtimeexperiment.Assignment `json:"experiment,omitempty"`
+	// Rollout identifies the context version served, when the context is
+	// rolled out (config/rollouts.yaml).
+	Rollout *runtimerollout.Assignment `json:"rollout,omitempty"`
+	// Filters lists the output filters that redacted or annotated the answer.
+	Filters []runtimeguardrails.FilterHit `json:"filters,omitempty"`
+	// Grounding scores how well the answer is supported by the sources, when
+	// the context configures guardrails.grounding.
+	Grounding *runtimeguardrails.GroundingResult `json:"grounding,omitempty"`
+	// Route reports the component a request without one was dispatched to.
+	Route *runtimedispatch.Decision `json:"route,omitempty"`
+	// Trace lists the tool calls of the agent loop, with ?debug=true.
+	Trace []runtimeagent.Step `json:"trace,omitempty"`
+	// Model reports the provider and model that generated the answer.
+	Model *runtimemodel.ModelInfo `json:"model,omitempty"`
 }
 
 // Prometheus metrics
@@ -62,10 +113,6 @@ var (
 		Help:    "Duration of memory search by component.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"component"})
-	driftScoreGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "cmp_drift_score",
-		Help: "Latest drift score by component (optional).",
-	}, []string{"component"})
 	hfInferenceLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "cmp_hf_inference_latency_seconds",
 		Help:    "Latency of Hugging Face inference calls.",
@@ -81,14 +128,61 @@ func init() {
 	prometheus.MustRegister(httpRequestsInFlight)
 	prometheus.MustRegister(httpRequestDuration)
 	prometheus.MustRegister(promptRenderDuration)
+	prometheus.MustRegister(promptChunksDropped)
 	prometheus.MustRegister(memorySearchDuration)
-	prometheus.MustRegister(driftScoreGauge)
+	prometheus.MustRegister(runtimedrift.Score)
 	prometheus.MustRegister(hfInferenceLatency)
 	prometheus.MustRegister(hfInferenceErrors)
+	prometheus.MustRegister(httpRejectedRequests)
+	prometheus.MustRegister(idempotentReplays)
+	prometheus.MustRegister(admissionInFlight)
+	prometheus.MustRegister(admissionQueued)
+	prometheus.MustRegister(admissionShed)
+	prometheus.MustRegister(runtimemodel.ProviderRetries)
+	prometheus.MustRegister(runtimemodel.ProviderBreakerState)
+	prometheus.MustRegister(runtimemodel.ProviderBreakerRejections)
+	prometheus.MustRegister(runtimemodel.ProviderQueueDepth)
+	prometheus.MustRegister(runtimemodel.ProviderQueueWait)
+	prometheus.MustRegister(runtimemodel.ProviderBusyRejections)
 	// Security telemetry
 	prometheus.MustRegister(runtimesecurity.PromptInjectionDetections)
+	prometheus.MustRegister(runtimesecurity.InjectionDetections)
 	prometheus.MustRegister(runtimesecurity.PolicyViolations)
 	prometheus.MustRegister(runtimesecurity.BlockedResponses)
+	prometheus.MustRegister(runtimesecurity.AuditEventsDropped)
+	prometheus.MustRegister(runtimesecurity.AuditDeliveryRetries)
+	prometheus.MustRegister(runtimeguardrails.OutputFilterHits)
+	prometheus.MustRegister(runtimeguardrails.GroundingScores)
+	prometheus.MustRegister(runtimeguardrails.UngroundedResponses)
+	// Usage accounting
+	prometheus.MustRegister(runtimeusage.TokensTotal)
+	prometheus.MustRegister(runtimeusage.CostTotal)
+	prometheus.MustRegister(runtimeusage.RequestsTotal)
+	// Prompt experiments
+	prometheus.MustRegister(runtimeexperiment.Assignments)
+	prometheus.MustRegister(runtimeexperiment.Outcomes)
+	prometheus.MustRegister(runtimeexperiment.Latency)
+	// Context rollouts
+	prometheus.MustRegister(runtimerollout.Requests)
+	prometheus.MustRegister(runtimerollout.EvalScores)
+	prometheus.MustRegister(runtimerollout.CandidatePercent)
+
+	prometheus.MustRegister(runtimedispatch.Dispatches)
+	prometheus.MustRegister(runtimeagent.Steps)
+	// Memory index health
+	prometheus.MustRegister(runtimememory.IndexDocuments)
+	prometheus.MustRegister(runtimememory.IndexChunks)
+	prometheus.MustRegister(runtimememory.StoreBytes)
+	prometheus.MustRegister(runtimememory.IngestDuration)
+	prometheus.MustRegister(runtimememory.IngestBatchSize)
+	prometheus.MustRegister(runtimememory.EmbeddingLatency)
+	prometheus.MustRegister(runtimememory.SearchDepth)
+	prometheus.MustRegister(runtimememory.ConversationSummaries)
+	prometheus.MustRegister(runtimememory.ConversationConflicts)
+	prometheus.MustRegister(runtimememory.DuplicateChunks)
+	prometheus.MustRegister(runtimememory.RerankFallbacks)
+	// Notifications
+	prometheus.MustRegister(runtimenotifications.Sent)
 }
 
 type statusWriter struct {
@@ -101,6 +195,19 @@ func (w *statusWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack lets WebSocket upgrades pass through the middleware.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the connection (flushing streams).
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 // generateRequestID returns a simple timestamp-based ID; in prod consider UUIDs
 func generateRequestID() string {
 	return time.Now().UTC().Format("20060102T150405.000000000Z07:00")
@@ -115,25 +222,134 @@ func NewHandler(root string) http.Handler {
 
 // NewHandlerWithProvider constructs an http.Handler and injects a model
 // Provider for inference (used by tests and custom wiring).
-func NewHandlerWithProvider(root string, provider runtimemodel.Provider) http.Handler {
+func NewHandlerWithProvider(root string, provider runtimemodel.Provider, opts ...Option) http.Handler {
+	h, _ := newHandlers(root, provider, false, opts...)
+	return h
+}
+
+// newHandlers builds the API. With split, health, readiness, version, metrics,
+// the admin API and (with CMP_PPROF_ENABLED) pprof are served by the second
+// handler only.
+func newHandlers(root string, provider runtimemodel.Provider, split bool, opts ...Option) (http.Handler, http.Handler) {
+	var options handlerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	hooks := middlewareChain(append(registeredMiddleware(), options.middleware...))
+	openStore := options.openStore
+	if openStore == nil {
+		openStore = runtimememory.NewStore
+	}
+	router, routeErr := newRouter(root, provider)
+	if routeErr != nil {
+		// Keep serving with the environment provider; routes are reported as invalid
+		logger.GetLogger().Error("model routing configuration invalid", zap.Error(routeErr))
+	}
+	ledger := runtimeusage.NewLedger(root)
+	pricing, pricingErr := runtimeusage.LoadPricing(root)
+	if pricingErr != nil {
+		logger.GetLogger().Error("pricing configuration invalid", zap.Error(pricingErr))
+	}
+	budgets, budgetErr := runtimeusage.LoadBudgets(root)
+	if budgetErr != nil {
+		logger.GetLogger().Error("budget configuration invalid", zap.Error(budgetErr))
+	}
+	quotas := runtimeusage.NewQuotaManager(budgets, ledger)
+	notifier, notifyErr := runtimenotifications.Load(root)
+	if notifyErr != nil {
+		logger.GetLogger().Error("notification configuration invalid", zap.Error(notifyErr))
+	}
+	experiments, expErr := runtimeexperiment.LoadConfig(root)
+	if expErr != nil {
+		logger.GetLogger().Error("experiment configuration invalid", zap.Error(expErr))
+	}
+	outcomes := runtimeexperiment.NewLog(root)
+	rollouts := runtimerollout.NewSource(root)
+	if _, err := rollouts.Config(); err != nil {
+		logger.GetLogger().Error("rollout configuration invalid", zap.Error(err))
+	}
+	deployments := runtimerollout.NewLog(root)
+	dispatcher, dispatchErr := runtimedispatch.LoadConfig(root)
+	if dispatchErr != nil {
+		logger.GetLogger().Error("dispatch routes invalid", zap.Error(dispatchErr))
+	}
+	openAICompat, openAIErr := LoadOpenAICompat(root)
+	if openAIErr != nil {
+		logger.GetLogger().Error("openai compatibility configuration invalid", zap.Error(openAIErr))
+	}
+	overrides, overridesErr := LoadModelOverridePolicy(root)
+	if overridesErr != nil {
+		// An unreadable policy allows no overrides
+		logger.GetLogger().Error("model override policy invalid", zap.Error(overridesErr))
+	}
+	// CORS, security headers, limits and admission (server section of config/environments/$CMP_ENV.yaml)
+	httpCfg, httpCfgErr := LoadHTTPConfig(root)
+	limits, limitsErr := httpCfg.limits()
+	window, windowErr := httpCfg.idempotencyWindow()
+	admission, admissionErr := httpCfg.admission()
+	if err := errors.Join(httpCfgErr, limitsErr, windowErr, admissionErr); err != nil {
+		logger.GetLogger().Error("server http configuration invalid", zap.Error(err))
+	}
+	// Export the recorded drift scores until the worker records new ones
+	if err := runtimedrift.PublishScores(root); err != nil {
+		logger.GetLogger().Error("drift history unreadable", zap.Error(err))
+	}
 	ctxSvc := runtimecontext.NewContextService(root)
 	eng := runtimeprompt.NewEngine(root)
 	// Security components (enabled via env toggles)
-	authEnabled := os.Getenv("CMP_AUTH_ENABLED") == "true"
+	authEnabled := os.Getenv("CMP_AUTH_ENABLED") == "true" || strings.EqualFold(os.Getenv("CMP_AUTH_MODE"), "oidc")
 	piEnabled := os.Getenv("CMP_PI_ENFORCEMENT") == "true"
+	injectionAnalyzer := runtimesecurity.NewInjectionAnalyzerFromEnv()
+	// Tool observations of the agent loop are screened like retrieved chunks
+	var agentInjection *runtimesecurity.InjectionAnalyzer
+	if piEnabled {
+		agentInjection = injectionAnalyzer
+	}
 	citationRequired := os.Getenv("CMP_REQUIRE_CITATION") == "true"
-	keyStore := runtimesecurity.NewAPIKeyStoreFromEnv()
+	authenticator, authErr := runtimesecurity.NewAuthenticatorFromEnv()
+	if authErr != nil {
+		// Fail closed: a misconfigured authenticator must not silently allow traffic
+		logger.GetLogger().Error("authenticator configuration invalid", zap.Error(authErr))
+		authenticator = runtimesecurity.DenyAllAuthenticator{Err: authErr}
+	}
+	// Keys managed at runtime (data/auth/api_keys.json) take precedence over env keys
+	var keys *runtimesecurity.KeyManager
+	if envKeys, ok := authenticator.(*runtimesecurity.APIKeyStore); ok {
+		var keysErr error
+		if keys, keysErr = runtimesecurity.NewKeyManager(root, envKeys); keysErr != nil {
+			logger.GetLogger().Error("managed API keys unreadable", zap.Error(keysErr))
+			authenticator = runtimesecurity.DenyAllAuthenticator{Err: keysErr}
+		} else {
+			authenticator = keys
+		}
+	}
 	rateLimiter := runtimesecurity.NewRateLimiter(10.0/1.0, 5)
-	auditor := runtimesecurity.NewAuditor(runtimesecurity.NewJSONFileSink("audit.log"))
+	auditSink := options.auditSink
+	if auditSink == nil {
+		auditSink = NewAuditSink(root)
+	}
+	auditor := runtimesecurity.NewAuditor(auditSink)
+	guard := &requestGuard{enabled: authEnabled, authenticator: authenticator, limiter: rateLimiter, auditor: auditor}
+	approvals := runtimeapproval.NewStore(root)
+	jobs := runtimejobs.NewQueue(root)
+	transcripts := runtimeprivacy.NewTranscripts(root)
 
 	mux := http.NewServeMux()
+	// Operational endpoints move to the admin listener when split (CMP_ADMIN_ADDR)
+	adminMux := mux
+	if split {
+		adminMux = http.NewServeMux()
+		if os.Getenv("CMP_PPROF_ENABLED") == "true" {
+			registerPprofRoutes(adminMux)
+		}
+	}
 
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+	adminMux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
-	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+	adminMux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
 		if ctxSvc == nil || eng == nil {
 			http.Error(w, "not ready", http.StatusServiceUnavailable)
 			return
@@ -142,142 +358,248 @@ func NewHandlerWithProvider(root string, provider runtimemodel.Provider) http.Ha
 		_, _ = w.Write([]byte("ready"))
 	})
 
-	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
-		_ = json.NewEncoder(w).Encode(map[string]string{
-			"version": "0.2.0",
-		})
+	adminMux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(buildinfo.Get())
 	})
 
 	// Expose Prometheus metrics
-	mux.Handle("/metrics", promhttp.Handler())
+	adminMux.Handle("/metrics", promhttp.Handler())
+
+	registerMemoryRoutes(mux, root, guard, openStore)
+	registerContextRoutes(mux, ctxSvc, guard)
+	registerChatSocket(mux, guard, httpCfg.CORS)
+	registerUsageRoutes(mux, ledger, guard)
+	registerApprovalRoutes(mux, approvals, guard)
+	registerKeyRoutes(adminMux, keys, guard)
+	registerJobRoutes(mux, jobs, guard)
+	registerPrivacyRoutes(mux, root, guard)
+	registerSessionRoutes(mux, transcripts, guard)
+	registerOpenAPIRoutes(mux)
+	registerOpenAIRoutes(mux, openAICompat, guard)
 
 	mux.HandleFunc("/api/v1/chat", func(w http.ResponseWriter, r *http.Request) {
+		received := time.Now()
 		var req ChatRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeJSONBody(w, r, &req) {
 			return
 		}
-		// Minimal PI guard (optional): classify risk and sanitize inputs
+		// Prompt-injection guard (optional): score the query and text data, then block or flag
 		if piEnabled {
-			extras := []string{}
+			parts := []string{req.Query}
 			for _, v := range req.Data {
 				if s, ok := v.(string); ok {
-					extras = append(extras, s)
+					parts = append(parts, s)
 				} else if m, ok := v.(map[string]interface{}); ok {
 					if t, ok := m["text"].(string); ok {
-						extras = append(extras, t)
+						parts = append(parts, t)
 					}
 				}
 			}
-			risk := runtimesecurity.ClassifyPromptRisk(req.Query, extras...)
-			if risk == runtimesecurity.RiskHigh {
-				runtimesecurity.PromptInjectionDetections.Inc()
-				auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-					Timestamp: time.Now(),
-					RequestID: r.Context().Value("request_id").(string),
-					TenantID:  req.TenantID,
-					Action:    "chat:invoke",
-					Resource:  "chat",
-					Result:    "denied",
-					Reason:    "prompt_injection",
-				})
-				http.Error(w, "request blocked by security policy", http.StatusForbidden)
-				return
+			res := injectionAnalyzer.Analyze(r.Context(), strings.Join(parts, "\n"))
+			if res.Detected {
+				recordInjection(r.Context(), auditor, req.TenantID, "query", res)
+				if res.Blocked {
+					http.Error(w, "request blocked by security policy", http.StatusForbidden)
+					return
+				}
 			}
 			req.Query = runtimesecurity.SanitizeUserInput(req.Query)
 		}
-		// Optional authentication & RBAC
-		var principal *runtimesecurity.Principal
-		if authEnabled {
-			p, err := keyStore.Authenticate(r)
-			if err != nil {
-				w.Header().Set("WWW-Authenticate", "Bearer")
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
-				auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-					Timestamp:  time.Now(),
-					RequestID:  r.Context().Value("request_id").(string),
-					TenantID:   req.TenantID,
-					ActorKeyID: "",
-					Action:     "chat:invoke",
-					Resource:   "chat",
-					Result:     "denied",
-					Reason:     "auth_failed",
-				})
+		// Optional authentication, rate limiting & RBAC (requires chat:execute)
+		chatRes := runtimesecurity.Resource{Type: "chat", Name: "chat", Tenant: req.TenantID}
+		principal, ok := guard.authorize(w, r, "chat:invoke", chatRes, runtimesecurity.ActionExecute)
+		if !ok {
+			return
+		}
+		if principal != nil {
+			r = r.WithContext(runtimesecurity.WithPrincipal(r.Context(), principal))
+		}
+		if err := hooks.onRequest(r.Context(), &req); err != nil {
+			writeHookError(w, err)
+			return
+		}
+		// Per-request provider, model and parameter overrides (config/model_overrides.yaml)
+		if !authorizeModelOverride(w, r, overrides, auditor, req) {
+			return
+		}
+		// Monthly token/request budgets per tenant and API key (config/budgets.yaml)
+		quotaTenant, quotaKey := quotaSubject(r.Context(), req.TenantID)
+		quota, qErr := quotas.Check(quotaTenant, quotaKey, time.Now())
+		if qErr != nil {
+			logger.WithContext(r.Context()).Error("quota check failed", zap.Error(qErr))
+		}
+		if quota.Exceeded {
+			writeQuotaExceeded(r.Context(), w, auditor, quotaTenant, quota)
+			notifyBudgetExceeded(notifier, quotaTenant, quota)
+			return
+		}
+		// Admission control (server.admission): wait for a slot or shed low-priority load
+		slot, admitted := admission.admit(w, r, principal)
+		if !admitted {
+			return
+		}
+		defer slot.release()
+		r = r.WithContext(withAdmissionSlot(r.Context(), slot))
+		// Intent dispatch (config/routes.yaml): requests without a component go to
+		// the component the dispatcher picks for the query
+		var route *runtimedispatch.Decision
+		if req.Component == "" && dispatcher.Enabled() &&
+			(req.Context == "" || req.Context == runtimedispatch.AutoContext || req.Context == dispatcher.Dispatcher.Context) {
+			d, dErr := dispatchQuery(r.Context(), dispatcher, router, ledger, pricing, quotas, req)
+			if d.Route == "" {
+				http.Error(w, "dispatch: "+dErr.Error(), http.StatusUnprocessableEntity)
 				return
 			}
-			principal = p
-			// Rate limiting (per key/tenant/ip)
-			ip := runtimesecurity.ExtractIP(r)
-			if !rateLimiter.Allow(runtimesecurity.LimiterKey{APIKeyID: p.KeyID, TenantID: p.TenantID, IP: ip}, 0) {
-				w.Header().Set("Retry-After", runtimesecurity.RetryAfter())
-				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
-				auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-					Timestamp:  time.Now(),
-					RequestID:  r.Context().Value("request_id").(string),
-					TenantID:   req.TenantID,
-					ActorKeyID: p.KeyID,
-					Action:     "chat:invoke",
-					Resource:   "chat",
-					Result:     "denied",
-					Reason:     "rate_limited",
-				})
-				return
+			if dErr != nil {
+				logger.WithContext(r.Context()).Warn("dispatch fell back to rules", zap.Error(dErr))
 			}
-			// RBAC: require chat:execute
-			res := runtimesecurity.Resource{Type: "chat", Name: "chat", Tenant: req.TenantID}
-			if !runtimesecurity.CheckPermission(principal, res, runtimesecurity.ActionExecute) {
-				http.Error(w, "forbidden", http.StatusForbidden)
-				auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-					Timestamp:  time.Now(),
-					RequestID:  r.Context().Value("request_id").(string),
-					TenantID:   req.TenantID,
-					ActorKeyID: principal.KeyID,
-					Action:     "chat:invoke",
-					Resource:   "chat",
-					Result:     "denied",
-					Reason:     "rbac",
-				})
-				return
+			route = &d
+			req.Component, req.Context = d.Component, d.Context
+			w.Header().Set("X-Dispatch-Route", d.Route)
+		}
+		// Context rollouts (config/rollouts.yaml) serve a registered version; tenants keep their overrides
+		rolloutCfg, rolloutErr := rollouts.Config()
+		if rolloutErr != nil {
+			logger.WithContext(r.Context()).Error("rollout configuration invalid, keeping the previous rollouts", zap.Error(rolloutErr))
+		}
+		var deployment *runtimerollout.Assignment
+		var deployOutcome *runtimerollout.Outcome
+		var ctxModel *corectx.Context
+		var err error
+		if a, ok := rolloutCfg.Assign(req.Context, experimentUnit(r, req)); ok && !ctxSvc.HasTenantOverride(req.TenantID, req.Context) {
+			deployment = &a
+			ctxModel, err = ctxSvc.ResolveVersion(a.Context, a.Version)
+			w.Header().Set("X-Context-Version", a.Version)
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			w = sw
+			deployOutcome = &runtimerollout.Outcome{
+				RequestID: requestIDFrom(r.Context()), TenantID: req.TenantID,
+				Context: a.Context, Version: a.Version, Track: a.Track,
 			}
-			// Bind principal to context
-			r = r.WithContext(runtimesecurity.WithPrincipal(r.Context(), principal))
+			start := time.Now()
+			defer func() {
+				deployOutcome.Time = time.Now().UTC()
+				deployOutcome.Status = sw.status
+				deployOutcome.LatencyMS = time.Since(start).Milliseconds()
+				if err := deployments.Append(*deployOutcome); err != nil {
+					logger.WithContext(r.Context()).Error("rollout outcome write failed", zap.Error(err))
+				}
+			}()
+		} else {
+			ctxModel, err = ctxSvc.ResolveContext(req.TenantID, req.Context)
 		}
-		ctxModel, err := ctxSvc.ResolveContext(req.TenantID, req.Context)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		// Action gating via policy (OOB confirmation)
+		// Action gating via policy: sensitive actions wait for an out-of-band approval.
+		// Tool calls of the agent loop are gated as they run; data.action declares
+		// an action the client is about to take
 		pol := runtimesecurity.DefaultPolicy().MergeEnv()
-		if act, ok := req.Data["action"].(string); ok && act != "" {
-			if pol.RequiresOutOfBand(act) {
-				oobHeader := r.Header.Get("X-OOB-Confirmed")
-				oobBody, _ := req.Data["oob_confirmed"].(bool)
-				if strings.ToLower(oobHeader) != "true" && !oobBody {
+		if act, ok := req.Data["action"].(string); ok && act != "" && pol.RequiresOutOfBand(act) {
+			if !awaitApproval(w, r, approvals, auditor, req, act) {
+				return
+			}
+		}
+		// PII policy on incoming query and string data (CMP_PII_MODE, overridable per context)
+		piiEngine := newPIIEngine(pol, ctxModel)
+		outputFilters, err := runtimeguardrails.NewOutputFilters(root, ctxModel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		groundingCheck, err := runtimeguardrails.NewGroundingCheck(ctxModel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if res := piiEngine.Apply(req.Query); len(res.Matches) > 0 {
+			recordPII(r.Context(), auditor, req.TenantID, "input", res)
+			if res.Blocked {
+				runtimesecurity.BlockedResponses.Inc()
+				http.Error(w, "request blocked: PII detected", http.StatusUnprocessableEntity)
+				return
+			}
+			req.Query = res.Text
+		}
+		for k, v := range req.Data {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			if res := piiEngine.Apply(s); len(res.Matches) > 0 {
+				recordPII(r.Context(), auditor, req.TenantID, "input", res)
+				if res.Blocked {
 					runtimesecurity.BlockedResponses.Inc()
-					auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-						Timestamp: time.Now(), RequestID: r.Context().Value("request_id").(string), TenantID: req.TenantID,
-						Action: act, Resource: "chat", Result: "denied", Reason: "oob_required",
-					})
-					http.Error(w, "out-of-band confirmation required", http.StatusForbidden)
+					http.Error(w, "request blocked: PII detected", http.StatusUnprocessableEntity)
 					return
 				}
+				req.Data[k] = res.Text
 			}
 		}
+		// Streaming clients (WebSocket) receive tool-call and token events as they happen
+		emit := chatEventSinkFrom(r.Context())
 		var results []runtimememory.SearchResult
+		var timing stageTiming
 		if req.Component != "" && req.Query != "" {
-			store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: req.Component, TenantID: req.TenantID})
+			// The context's retrieval section overrides the component's memory_config.yaml
+			retrieval := retrievalOptions(ctxModel)
+			if retrieval == nil {
+				retrieval = &runtimememory.RetrievalOptions{}
+			}
+			retrieval.Paraphrase = queryParaphraser(router, ledger, pricing, quotas, req)
+			store, err := openStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: req.Component, TenantID: req.TenantID, Retrieval: retrieval})
 			if err == nil {
 				defer store.Close()
+				topK := retrievalTopK(ctxModel, req.TopK)
+				if emit != nil {
+					emit(ChatEvent{Type: EventToolCall, Name: "memory_search", Arguments: map[string]interface{}{"component": req.Component, "query": req.Query, "top_k": topK}})
+				}
 				msStart := time.Now()
-				results, _ = store.Search(r.Context(), req.Query, req.TopK)
-				memorySearchDuration.WithLabelValues(req.Component).Observe(time.Since(msStart).Seconds())
+				var searchErr error
+				results, searchErr = store.Search(r.Context(), req.Query, topK)
+				timing.search = time.Since(msStart)
+				memorySearchDuration.WithLabelValues(req.Component).Observe(timing.search.Seconds())
+				if timedOut(r.Context(), searchErr) {
+					writeRequestTimeout(w)
+					return
+				}
+			}
+		}
+		// Retrieved chunks are untrusted too: drop (block mode) or flag poisoned memory
+		if piEnabled && len(results) > 0 {
+			kept := results[:0]
+			for _, res := range results {
+				verdict := injectionAnalyzer.Analyze(r.Context(), res.Content)
+				if !verdict.Detected {
+					kept = append(kept, res)
+					continue
+				}
+				recordInjection(r.Context(), auditor, req.TenantID, "memory", verdict)
+				if verdict.Blocked {
+					continue
+				}
+				if res.Metadata == nil {
+					res.Metadata = map[string]interface{}{}
+				}
+				res.Metadata["prompt_injection_score"] = verdict.Score
+				kept = append(kept, res)
 			}
+			results = kept
 		}
 		data := map[string]interface{}{
 			"context": ctxModel,
 			"results": results,
 		}
+		// Conversation memory: the session summary and recent turns replace the full history
+		sessionID := sessionOf(r, req)
+		session := loadConversation(r.Context(), root, ctxModel, req, sessionID, conversationSummarizer(router, ledger, pricing, quotas, req))
+		if session != nil {
+			defer session.release()
+			data["summary"] = session.conv.Summary
+			data["history"] = session.conv.Recent(ctxModel.Memory.MaxHistory)
+		}
 		for k, v := range req.Data {
 			data[k] = v
 		}
@@ -305,82 +627,280 @@ func NewHandlerWithProvider(root string, provider runtimemodel.Provider) http.Ha
 				return
 			}
 		}
+		// Prompt experiments (config/experiments.yaml) swap in the caller's variant
+		var assignment *runtimeexperiment.Assignment
+		var outcome *runtimeexperiment.Outcome
+		if a, ok := experiments.Assign(req.Component, promptFile, experimentUnit(r, req)); ok {
+			assignment, promptFile = &a, a.PromptFile
+			w.Header().Set("X-Prompt-Variant", a.Experiment+"/"+a.Variant)
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			w = sw
+			outcome = &runtimeexperiment.Outcome{
+				RequestID: requestIDFrom(r.Context()), TenantID: req.TenantID, Component: req.Component,
+				Experiment: a.Experiment, Variant: a.Variant, Version: a.Version,
+			}
+			start := time.Now()
+			defer func() {
+				outcome.Time = time.Now().UTC()
+				outcome.Status = sw.status
+				outcome.LatencyMS = time.Since(start).Milliseconds()
+				if err := outcomes.Append(*outcome); err != nil {
+					logger.WithContext(r.Context()).Error("experiment outcome write failed", zap.Error(err))
+				}
+			}()
+		}
+		// Route to the component's provider chain (config/providers/routing.yaml)
+		chain, err := router.Override(req.Component, req.Context, req.Provider, req.Model)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		genParams := req.Params.apply(runtimemodel.Params{MaxNewTokens: outputTokens(ctxModel)})
+		maxNewTokens := genParams.MaxNewTokens
 		prStart := time.Now()
-		rendered, err := eng.RenderFile(req.Component, promptFile, data)
-		promptRenderDuration.WithLabelValues(req.Component).Observe(time.Since(prStart).Seconds())
+		// Retrieved chunks that do not fit the model context window are dropped, lowest score first
+		fit, err := chatBudget(r.Context(), chain, maxNewTokens).fit(results, func(kept []runtimememory.SearchResult) (string, error) {
+			data["results"] = kept
+			return eng.RenderFile(req.Component, promptFile, data)
+		})
+		rendered := fit.rendered
+		results = fit.kept
+		timing.render = time.Since(prStart)
+		promptRenderDuration.WithLabelValues(req.Component).Observe(timing.render.Seconds())
+		var tooLarge *promptTooLargeError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		// PII handling per policy (env-driven)
-		pol = runtimesecurity.DefaultPolicy().MergeEnv()
-		if runtimesecurity.DetectPII(rendered) {
-			switch pol.PIIMode {
-			case "block":
-				runtimesecurity.BlockedResponses.Inc()
-				auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-					Timestamp: time.Now(), RequestID: r.Context().Value("request_id").(string), TenantID: req.TenantID,
-					Action: "chat:invoke", Resource: "chat", Result: "denied", Reason: "pii_detected",
-				})
-				http.Error(w, "response blocked: PII detected", http.StatusUnprocessableEntity)
-				return
-			case "redact":
-				rendered = runtimesecurity.RedactPII(rendered)
-			}
+		if len(fit.dropped) > 0 {
+			logDroppedChunks(r.Context(), req.Component, fit, chain.ContextWindow(), maxNewTokens)
 		}
-		// Output adjudication: ensure citations when required (optional)
-		if citationRequired {
-			if rc, _ := data["require_citation"].(bool); rc {
-				low := strings.ToLower(rendered)
-				if !strings.Contains(low, "source:") && !strings.Contains(low, "sources:") {
-					runtimesecurity.BlockedResponses.Inc()
-					auditor.Record(r.Context(), runtimesecurity.AuditEvent{
-						Timestamp: time.Now(), RequestID: r.Context().Value("request_id").(string), TenantID: req.TenantID,
-						Action: "chat:invoke", Resource: "chat", Result: "denied", Reason: "missing_citation",
-					})
-					http.Error(w, "response blocked: missing required citations", http.StatusUnprocessableEntity)
-					return
-				}
-			}
+		if rendered, err = hooks.onPromptRendered(r.Context(), &req, rendered); err != nil {
+			writeHookError(w, err)
+			return
+		}
+		sources := injectedSources(rendered, results)
+		if emit != nil && req.Component != "" && req.Query != "" {
+			emit(ChatEvent{Type: EventToolResult, Name: "memory_search", Result: sources})
 		}
+		// Transcripts identify the prompt by its hash
+		promptHash := fmt.Sprintf("%x", sha256.Sum256([]byte(rendered)))
 		// If a provider is configured, perform inference with rendered prompt
-		if provider != nil {
+		var usageOut *runtimemodel.Usage
+		var modelOut *runtimemodel.ModelInfo
+		var judge runtimeguardrails.JudgeFunc
+		var trace []runtimeagent.Step
+		recordInference := func() {}
+		if chain != nil {
 			// Tracing span for inference
 			tracer := otel.Tracer("contexis/runtime/inference")
 			ctx := r.Context()
-			ctx, span := tracer.Start(ctx, "huggingface.generate")
-			span.SetAttributes(
-				attribute.String("provider", "huggingface"),
-				attribute.String("model_id", os.Getenv("HF_MODEL_ID")),
-			)
+			ctx, span := tracer.Start(ctx, "model.generate")
+			// Token accounting covers every generation call, including schema repairs
+			ctx, meter := runtimemodel.WithUsageMeter(ctx)
+			recorded := false
+			recordInference = func() {
+				if !recorded {
+					recorded = true
+					recordUsage(r.Context(), ledger, pricing, quotas, req, chain.Served(), meter.Total())
+				}
+			}
+			// failed requests still consume budget for the tokens already generated
+			defer recordInference()
+			var onToken func(string) error
+			requireCitation, _ := data["require_citation"].(bool)
+			// Middleware may rewrite the answer, so tokens are buffered when any is installed
+			if emit != nil && len(hooks) == 0 && !agentEnabled(ctxModel) && canStreamLive(ctxModel, piiEngine, outputFilters, groundingCheck, requireCitation) {
+				onToken = func(tok string) error {
+					emit(ChatEvent{Type: EventToken, Content: tok})
+					return nil
+				}
+			}
 			infStart := time.Now()
-			out, infErr := provider.Generate(ctx, rendered, runtimemodel.Params{MaxNewTokens: 256})
-			hfInferenceLatency.WithLabelValues(os.Getenv("HF_MODEL_ID")).Observe(time.Since(infStart).Seconds())
+			var out string
+			var infErr error
+			if agentEnabled(ctxModel) {
+				// Agent loop: the model calls tools and sees their results until it answers
+				var run runtimeagent.Result
+				run, infErr = newAgentLoop(ctx, root, openStore, ctxModel, req, chain, genParams, auditor, toolApprovalGate(approvals, auditor, pol, req), agentInjection, emit).Run(ctx, rendered)
+				out, trace = run.Answer, run.Steps
+			} else {
+				out, infErr = generate(ctx, chain, rendered, genParams, onToken)
+			}
+			served := chain.Served()
+			modelOut = &served
+			hfInferenceLatency.WithLabelValues(served.Model).Observe(time.Since(infStart).Seconds())
 			if infErr != nil {
-				hfInferenceErrors.WithLabelValues("bad_gateway").Inc()
 				span.RecordError(infErr)
 				span.End()
+				if timedOut(ctx, infErr) {
+					writeRequestTimeout(w)
+					return
+				}
+				if errors.Is(infErr, runtimemodel.ErrCircuitOpen) {
+					hfInferenceErrors.WithLabelValues("circuit_open").Inc()
+					w.Header().Set("Retry-After", fmt.Sprint(int(runtimemodel.ResilienceConfigFromEnv().OpenTimeout.Seconds())))
+					http.Error(w, infErr.Error(), http.StatusServiceUnavailable)
+					return
+				}
+				if runtimemodel.AllProvidersBusy(infErr) {
+					// Every provider in the chain was at its concurrency limit
+					hfInferenceErrors.WithLabelValues("provider_busy").Inc()
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, infErr.Error(), http.StatusServiceUnavailable)
+					return
+				}
+				hfInferenceErrors.WithLabelValues("bad_gateway").Inc()
 				http.Error(w, infErr.Error(), http.StatusBadGateway)
 				return
 			}
+			span.SetAttributes(
+				attribute.String("provider", served.Provider),
+				attribute.String("model_id", served.Model),
+			)
+			recordModelServed(r.Context(), auditor, req, served)
+			// Structured output: validate against the context's response schema and repair
+			if schema, maxRepairs := runtimeguardrails.ResponseSchema(ctxModel); schema != nil {
+				valid, attempts, vErr := validateWithRepair(ctx, chain, rendered, out, schema, maxRepairs, genParams)
+				if vErr != nil {
+					span.RecordError(vErr)
+					span.End()
+					if _, ok := vErr.(*runtimeguardrails.ResponseSchemaError); !ok {
+						hfInferenceErrors.WithLabelValues("bad_gateway").Inc()
+						http.Error(w, vErr.Error(), http.StatusBadGateway)
+						return
+					}
+					runtimesecurity.BlockedResponses.Inc()
+					writeSchemaError(w, vErr.(*runtimeguardrails.ResponseSchemaError), attempts)
+					return
+				}
+				out = valid
+			}
+			timing.inference = time.Since(infStart)
 			span.End()
-			_ = json.NewEncoder(w).Encode(ChatResponse{Rendered: out})
+			rendered = out
+			// The grounding judge shares the chain and its token accounting
+			judge = func(_ context.Context, prompt string) (string, error) {
+				return chain.Generate(ctx, prompt, runtimemodel.Params{Temperature: 0, MaxNewTokens: 256})
+			}
+			u := meter.Total()
+			usageOut = &u
+			if outcome != nil {
+				outcome.PromptTokens, outcome.CompletionTokens = u.PromptTokens, u.CompletionTokens
+			}
+		}
+		if rendered, err = hooks.onModelResponse(r.Context(), &req, rendered); err != nil {
+			writeHookError(w, err)
+			return
+		}
+		// Output adjudication: the answer must cite an injected source when required (optional)
+		cited := markCited(rendered, sources)
+		if outcome != nil {
+			outcome.Cited = cited
+		}
+		if rc, _ := data["require_citation"].(bool); rc && !cited {
+			runtimesecurity.BlockedResponses.Inc()
+			auditor.Record(r.Context(), runtimesecurity.AuditEvent{
+				Timestamp: time.Now(), RequestID: requestIDFrom(r.Context()), TenantID: req.TenantID,
+				Action: "chat:invoke", Resource: "chat", Result: "denied", Reason: "missing_citation",
+				Attributes: map[string]interface{}{"injected_sources": len(sources)},
+			})
+			http.Error(w, "response blocked: missing required citations", http.StatusUnprocessableEntity)
+			return
+		}
+		// Grounding check (guardrails.grounding): the answer's claims must be supported by the chunks
+		var grounding *runtimeguardrails.GroundingResult
+		if groundingCheck != nil && judge != nil && req.Component != "" {
+			chunks := make([]string, 0, len(sources))
+			for _, src := range sources {
+				chunks = append(chunks, results[src.Index-1].Content)
+			}
+			res, gErr := groundingCheck.Check(r.Context(), rendered, chunks, judge)
+			if gErr != nil {
+				logger.WithContext(r.Context()).Warn("grounding check failed open", zap.Error(gErr))
+			} else {
+				grounding = &res
+				if deployOutcome != nil {
+					deployOutcome.EvalScore = &res.Score
+				}
+				recordGrounding(r.Context(), auditor, req.TenantID, res)
+				if res.Blocked() {
+					runtimesecurity.BlockedResponses.Inc()
+					http.Error(w, "response blocked: answer not grounded in sources", http.StatusUnprocessableEntity)
+					return
+				}
+			}
+		}
+		// PII policy on the outgoing response
+		if res := piiEngine.Apply(rendered); len(res.Matches) > 0 {
+			recordPII(r.Context(), auditor, req.TenantID, "output", res)
+			if res.Blocked {
+				runtimesecurity.BlockedResponses.Inc()
+				http.Error(w, "response blocked: PII detected", http.StatusUnprocessableEntity)
+				return
+			}
+			rendered = res.Text
+		}
+		// Output filters (guardrails.output_filters): deny-lists, patterns, moderation
+		filtered := outputFilters.Apply(r.Context(), rendered)
+		for _, ferr := range filtered.Failures {
+			logger.WithContext(r.Context()).Warn("output filter failed open", zap.Error(ferr))
+		}
+		recordFilterHits(r.Context(), auditor, req.TenantID, filtered)
+		if filtered.Blocked {
+			runtimesecurity.BlockedResponses.Inc()
+			http.Error(w, "response blocked: output filter "+filtered.BlockedBy, http.StatusUnprocessableEntity)
 			return
 		}
-		_ = json.NewEncoder(w).Encode(ChatResponse{Rendered: rendered})
+		rendered = filtered.Text
+		recordInference()
+		if session != nil {
+			session.record(r.Context(), runtimememory.ConversationTurn{Time: time.Now().UTC(), Query: req.Query, Response: rendered})
+		}
+		// Transcripts back session review and data-subject exports (CMP_TRANSCRIPTS=true)
+		if runtimeprivacy.TranscriptsEnabled() {
+			recordTranscript(r, transcripts, req, rendered, promptHash, modelOut, sources, time.Since(received))
+		}
+		if quota.Limited {
+			if st, err := quotas.Check(quotaTenant, quotaKey, time.Now()); err == nil {
+				setQuotaHeaders(w, st)
+			}
+		}
+		timing.set(w)
+		resp := ChatResponse{Rendered: rendered, Sources: sources, Usage: usageOut, Experiment: assignment, Rollout: deployment, Filters: filtered.Hits, Grounding: grounding, Route: route, Model: modelOut}
+		if r.URL.Query().Get("debug") == "true" {
+			resp.Trace = trace
+		}
+		_ = json.NewEncoder(w).Encode(resp)
 	})
 
-	// Wrap with metrics + tracing + logging context middleware
+	// Retried POSTs with an Idempotency-Key replay the first response
+	var idempotency *runtimeidempotency.Store
+	if window > 0 {
+		idempotency = runtimeidempotency.NewStore(root, window)
+	}
+	public := instrument(withHTTPHeaders(httpCfg, withLimits(limits, withIdempotency(idempotency, mux))))
+	if !split {
+		return public, nil
+	}
+	return public, instrument(adminMux)
+}
+
+// instrument wraps routes with the metrics, tracing and logging context middleware.
+func instrument(routes http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
 		httpRequestsInFlight.Inc()
 		start := time.Now()
 
-		// Correlation and tenant context
-		reqID := generateRequestID()
+		// Correlation and tenant context; clients get the ID back to quote in reports
+		ctx, reqID := requestContext(r)
+		sw.Header().Set(requestIDHeader, reqID)
 		tenantID := r.Header.Get("X-Tenant-ID")
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "request_id", reqID)
 		if tenantID != "" {
 			ctx = context.WithValue(ctx, "tenant_id", tenantID)
 		}
@@ -396,12 +916,16 @@ func NewHandlerWithProvider(root string, provider runtimemodel.Provider) http.Ha
 		defer span.End()
 
 		// Serve request with augmented context
-		mux.ServeHTTP(sw, r.WithContext(ctx))
+		routes.ServeHTTP(sw, r.WithContext(ctx))
 
 		duration := time.Since(start).Seconds()
 		httpRequestsInFlight.Dec()
 		httpRequestDuration.WithLabelValues(r.Method, r.URL.Path, http.StatusText(sw.status)).Observe(duration)
-		logger.WithContext(ctx).Info("request completed",
+		log := logger.WithContext(ctx)
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			log = log.With(zap.String("trace_id", sc.TraceID().String()))
+		}
+		log.Info("request completed",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", sw.status),
@@ -410,14 +934,313 @@ func NewHandlerWithProvider(root string, provider runtimemodel.Provider) http.Ha
 	})
 }
 
+// requestIDFrom returns the correlation ID set by the request middleware.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value("request_id").(string)
+	return id
+}
+
+// newPIIEngine resolves the PII policy for a request: the context's guardrails.pii
+// settings take precedence over the environment policy.
+func newPIIEngine(pol runtimesecurity.Policy, ctxModel *corectx.Context) *runtimesecurity.PIIEngine {
+	mode := pol.PIIMode
+	var types []string
+	if ctxModel != nil && ctxModel.Guardrails.PII != nil {
+		if ctxModel.Guardrails.PII.Mode != "" {
+			mode = ctxModel.Guardrails.PII.Mode
+		}
+		types = ctxModel.Guardrails.PII.Types
+	}
+	return runtimesecurity.NewPIIEngine(mode, types)
+}
+
+// recordPII writes one audit event per PII detection/redaction at a pipeline stage.
+func recordPII(ctx context.Context, auditor *runtimesecurity.Auditor, tenantID, stage string, res runtimesecurity.PIIOutcome) {
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: tenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "allowed", Reason: "pii_detected",
+		Attributes: map[string]interface{}{"stage": stage, "types": res.Types(), "count": len(res.Matches)},
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	if res.Blocked {
+		ev.Result = "denied"
+	} else if res.Redacted {
+		ev.Reason = "pii_redacted"
+	}
+	auditor.Record(ctx, ev)
+}
+
+// recordFilterHits writes one audit event per output filter that matched a response.
+func recordFilterHits(ctx context.Context, auditor *runtimesecurity.Auditor, tenantID string, res runtimeguardrails.FilterResult) {
+	for _, hit := range res.Hits {
+		ev := runtimesecurity.AuditEvent{
+			Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: tenantID,
+			Action: "chat:invoke", Resource: "chat", Result: "allowed", Reason: "output_filtered",
+			Attributes: map[string]interface{}{"filter": hit.Filter, "type": hit.Type, "action": hit.Action, "labels": hit.Labels, "count": hit.Count},
+		}
+		if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+			ev.ActorKeyID = p.KeyID
+		}
+		if hit.Action == runtimeguardrails.ActionBlock {
+			ev.Result = "denied"
+		}
+		auditor.Record(ctx, ev)
+	}
+}
+
+// recordGrounding audits answers that scored below the grounding threshold.
+func recordGrounding(ctx context.Context, auditor *runtimesecurity.Auditor, tenantID string, res runtimeguardrails.GroundingResult) {
+	if res.Grounded {
+		return
+	}
+	unsupported := 0
+	for _, c := range res.Claims {
+		if !c.Supported {
+			unsupported++
+		}
+	}
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: tenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "allowed", Reason: "ungrounded",
+		Attributes: map[string]interface{}{"method": res.Method, "score": res.Score, "threshold": res.Threshold, "claims": len(res.Claims), "unsupported": unsupported},
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	if res.Blocked() {
+		ev.Result = "denied"
+	}
+	auditor.Record(ctx, ev)
+}
+
+// validateWithRepair validates out against schema, re-prompting the provider with the
+// violations up to maxRepairs times. Repairs use the request's params, so a repaired
+// answer gets the same sampling and token budget as the original one. It returns the
+// valid JSON and the repairs used.
+func validateWithRepair(ctx context.Context, provider runtimemodel.Provider, prompt, out string, schema map[string]interface{}, maxRepairs int, params runtimemodel.Params) (string, int, error) {
+	valid, err := runtimeguardrails.ValidateResponseSchema(schema, out)
+	attempts := 0
+	for err != nil && attempts < maxRepairs {
+		schemaErr, ok := err.(*runtimeguardrails.ResponseSchemaError)
+		if !ok {
+			return "", attempts, err
+		}
+		attempts++
+		repaired, genErr := provider.Generate(ctx, runtimeguardrails.RepairPrompt(prompt, out, schema, schemaErr.Violations), params)
+		if genErr != nil {
+			return "", attempts, genErr
+		}
+		out = repaired
+		valid, err = runtimeguardrails.ValidateResponseSchema(schema, out)
+	}
+	return valid, attempts, err
+}
+
+// stageTiming holds the duration of the chat pipeline stages that ran.
+type stageTiming struct {
+	search, render, inference time.Duration
+}
+
+// set reports the stages in a Server-Timing header, e.g.
+// "memory;dur=12.4, render;dur=0.8, inference;dur=356.1" (milliseconds).
+func (t stageTiming) set(w http.ResponseWriter) {
+	var parts []string
+	for _, st := range []struct {
+		name string
+		d    time.Duration
+	}{{"memory", t.search}, {"render", t.render}, {"inference", t.inference}} {
+		if st.d > 0 {
+			parts = append(parts, fmt.Sprintf("%s;dur=%.1f", st.name, float64(st.d.Microseconds())/1000))
+		}
+	}
+	if len(parts) > 0 {
+		w.Header().Set("Server-Timing", strings.Join(parts, ", "))
+	}
+}
+
+// writeSchemaError returns a structured 422 for output that failed schema validation.
+func writeSchemaError(w http.ResponseWriter, err *runtimeguardrails.ResponseSchemaError, repairs int) {
+	body := map[string]interface{}{
+		"error":      "response_schema_violation",
+		"message":    err.Error(),
+		"violations": err.Violations,
+		"repairs":    repairs,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// newRouter loads config/providers/routing.yaml. On error it returns a router that
+// only uses the environment provider, along with the error.
+func newRouter(root string, provider runtimemodel.Provider) (*runtimemodel.Router, error) {
+	cfg, err := runtimemodel.LoadRoutingConfig(root)
+	if err == nil {
+		var router *runtimemodel.Router
+		if router, err = runtimemodel.NewRouter(cfg, provider); err == nil {
+			return router, nil
+		}
+	}
+	router, _ := runtimemodel.NewRouter(nil, provider)
+	return router, err
+}
+
+// recordUsage appends the request's token usage to the ledger and updates the
+// usage counters and quotas. Requests without metered tokens are skipped.
+func recordUsage(ctx context.Context, ledger *runtimeusage.Ledger, pricing *runtimeusage.Pricing, quotas *runtimeusage.QuotaManager, req ChatRequest, served runtimemodel.ModelInfo, u runtimemodel.Usage) {
+	if u.TotalTokens() == 0 {
+		return
+	}
+	rec := runtimeusage.Record{
+		Time: time.Now().UTC(), RequestID: requestIDFrom(ctx), TenantID: req.TenantID,
+		Component: req.Component, Context: req.Context, Provider: served.Provider, Model: served.Model,
+		PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, Estimated: u.Estimated,
+		Cost: pricing.Cost(served.Model, u.PromptTokens, u.CompletionTokens),
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		rec.APIKeyID = p.KeyID
+		if rec.TenantID == "" {
+			rec.TenantID = p.TenantID
+		}
+	}
+	runtimeusage.Observe(rec)
+	quotas.Add(rec)
+	if err := ledger.Append(rec); err != nil {
+		logger.WithContext(ctx).Error("usage ledger write failed", zap.Error(err))
+	}
+}
+
+// dispatchQuery picks the route for a request. The model method classifies the
+// query with the dispatcher context's provider chain; its tokens are recorded
+// against that context.
+func dispatchQuery(ctx context.Context, routes *runtimedispatch.Config, router *runtimemodel.Router, ledger *runtimeusage.Ledger, pricing *runtimeusage.Pricing, quotas *runtimeusage.QuotaManager, req ChatRequest) (runtimedispatch.Decision, error) {
+	var classify runtimedispatch.ClassifyFunc
+	if routes.Dispatcher.Method == runtimedispatch.MethodModel {
+		if chain := router.For("", routes.Dispatcher.Context); chain != nil {
+			classify = func(ctx context.Context, prompt string) (string, error) {
+				mctx, meter := runtimemodel.WithUsageMeter(ctx)
+				out, err := chain.Generate(mctx, prompt, runtimemodel.Params{Temperature: 0, MaxNewTokens: 16})
+				billed := req
+				billed.Component, billed.Context = "", routes.Dispatcher.Context
+				recordUsage(ctx, ledger, pricing, quotas, billed, chain.Served(), meter.Total())
+				return out, err
+			}
+		}
+	}
+	return routes.Dispatch(ctx, req.Query, classify)
+}
+
+// experimentUnit returns the attributes a request's experiment variant is assigned by.
+func experimentUnit(r *http.Request, req ChatRequest) runtimeexperiment.Unit {
+	u := runtimeexperiment.Unit{SessionID: req.SessionID, UserID: req.UserID, TenantID: req.TenantID, RequestID: requestIDFrom(r.Context())}
+	if u.SessionID == "" {
+		u.SessionID = r.Header.Get("X-Session-ID")
+	}
+	if p, ok := runtimesecurity.FromPrincipal(r.Context()); ok {
+		if u.UserID == "" {
+			u.UserID = p.KeyID
+		}
+		if u.TenantID == "" {
+			u.TenantID = p.TenantID
+		}
+	}
+	return u
+}
+
+// recordModelServed audits which provider and model answered a chat request.
+func recordModelServed(ctx context.Context, auditor *runtimesecurity.Auditor, req ChatRequest, served runtimemodel.ModelInfo) {
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: req.TenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "allowed", Reason: "model_served",
+		Attributes: map[string]interface{}{
+			"component": req.Component, "context": req.Context,
+			"provider": served.Provider, "model": served.Model, "attempts": served.Attempts,
+		},
+	}
+	if req.UserID != "" {
+		ev.Attributes["user_id"] = req.UserID
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	auditor.Record(ctx, ev)
+}
+
+// recordInjection updates metrics and writes an audit event for a prompt-injection detection.
+// Blocked queries are denied; blocked memory chunks and tool observations are dropped.
+func recordInjection(ctx context.Context, auditor *runtimesecurity.Auditor, tenantID, source string, res runtimesecurity.InjectionResult) {
+	action := "flagged"
+	if res.Blocked {
+		action = "blocked"
+		if source == "memory" || source == "tool" {
+			action = "dropped"
+		}
+	}
+	runtimesecurity.PromptInjectionDetections.Inc()
+	runtimesecurity.InjectionDetections.WithLabelValues(source, action).Inc()
+	ev := runtimesecurity.AuditEvent{
+		Timestamp: time.Now(), RequestID: requestIDFrom(ctx), TenantID: tenantID,
+		Action: "chat:invoke", Resource: "chat", Result: "allowed", Reason: "prompt_injection",
+		Attributes: map[string]interface{}{"source": source, "score": res.Score, "signals": res.Signals, "action": action},
+	}
+	if p, ok := runtimesecurity.FromPrincipal(ctx); ok {
+		ev.ActorKeyID = p.KeyID
+	}
+	if action == "blocked" {
+		ev.Result = "denied"
+	}
+	auditor.Record(ctx, ev)
+}
+
+// NewAuditSink builds the sinks from config/audit.yaml. An invalid configuration
+// is logged and falls back to the default audit log so events are not lost.
+func NewAuditSink(root string) runtimesecurity.AuditSink {
+	cfg, err := runtimesecurity.LoadAuditConfig(root)
+	if err == nil {
+		var sink runtimesecurity.AuditSink
+		if sink, err = runtimesecurity.NewAuditSinkFromConfig(root, cfg); err == nil {
+			return sink
+		}
+	}
+	logger.GetLogger().Error("audit configuration invalid", zap.Error(err))
+	return runtimesecurity.NewJSONFileSink(runtimesecurity.DefaultAuditLog)
+}
+
 // Serve starts the HTTP server and performs graceful shutdown on SIGINT/SIGTERM.
 func Serve(addr string) error {
 	if addr == "" {
 		addr = ":8000"
 	}
+	logger.GetLogger().Info("starting contexis server", buildinfo.Get().Fields()...)
 	root, _ := os.Getwd()
-	handler := NewHandler(root)
+	// Owned here so batched audit events are flushed on shutdown
+	auditSink := NewAuditSink(root)
+	if c, ok := auditSink.(io.Closer); ok {
+		defer c.Close()
+	}
+	prov, _ := runtimemodel.FromEnv()
+	// Stops managed model servers such as llama-server
+	if c, ok := prov.(io.Closer); ok {
+		defer c.Close()
+	}
+	// Optional admin listener (CMP_ADMIN_ADDR, e.g. ":9090") for metrics, probes,
+	// pprof and the admin API; the main listener then serves only the public API
+	adminAddr := os.Getenv("CMP_ADMIN_ADDR")
+	var handler, adminHandler http.Handler
+	if adminAddr != "" {
+		handler, adminHandler = NewHandlers(root, prov, WithAuditSink(auditSink))
+	} else {
+		handler = NewHandlerWithProvider(root, prov, WithAuditSink(auditSink))
+	}
 	srv := &http.Server{Addr: addr, Handler: handler}
+	// Slow-client protection; invalid values are reported by NewHandlerWithProvider
+	if httpCfg, err := LoadHTTPConfig(root); err == nil {
+		if limits, err := httpCfg.limits(); err == nil {
+			limits.apply(srv)
+		}
+	}
 	// graceful shutdown
 	go func() {
 		logger.GetLogger().Info("serving", zap.String("addr", addr))
@@ -425,12 +1248,51 @@ func Serve(addr string) error {
 			logger.GetLogger().Error("server error", zap.Error(err))
 		}
 	}()
+	var adminSrv *http.Server
+	gatewayHandler := handler
+	if adminHandler != nil {
+		adminSrv = &http.Server{Addr: adminAddr, Handler: adminHandler, ReadHeaderTimeout: defaultReadHeaderTimeout}
+		go func() {
+			logger.GetLogger().Info("serving admin", zap.String("addr", adminAddr))
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.GetLogger().Error("admin server error", zap.Error(err))
+			}
+		}()
+		// The gRPC health service checks /readyz, which only the admin handler serves
+		gw := http.NewServeMux()
+		gw.Handle("/", handler)
+		gw.Handle("/readyz", adminHandler)
+		gatewayHandler = gw
+	}
+
+	// Optional gRPC API alongside HTTP (CMP_GRPC_ADDR, e.g. ":9000")
+	var grpcSrv *grpc.Server
+	if grpcAddr := os.Getenv("CMP_GRPC_ADDR"); grpcAddr != "" {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return fmt.Errorf("grpc listen %s: %w", grpcAddr, err)
+		}
+		grpcSrv = NewGRPCServer(gatewayHandler)
+		go func() {
+			logger.GetLogger().Info("serving grpc", zap.String("addr", grpcAddr))
+			if err := grpcSrv.Serve(lis); err != nil {
+				logger.GetLogger().Error("grpc server error", zap.Error(err))
+			}
+		}()
+	}
 
 	// wait for termination signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if adminSrv != nil {
+		// Probes and metrics stay up until the public API has drained
+		defer adminSrv.Shutdown(ctx)
+	}
 	return srv.Shutdown(ctx)
 }
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	"go.uber.org/zap"
+)
+
+// SessionTranscript is the response payload for GET /api/v1/sessions/{id}/transcript.
+type SessionTranscript struct {
+	SessionID string                `json:"session_id"`
+	TenantID  string                `json:"tenant_id,omitempty"`
+	Turns     []runtimeprivacy.Turn `json:"turns"`
+}
+
+// registerSessionRoutes wires GET /api/v1/sessions/{id}/transcript, which
+// returns the recorded turns of a chat session (tenant_id selects the
+// tenant). Requires sessions:read when auth is enabled; tenant-bound keys only
+// read their own tenant's sessions.
+func registerSessionRoutes(mux *http.ServeMux, transcripts *runtimeprivacy.Transcripts, guard *requestGuard) {
+	mux.HandleFunc("GET /api/v1/sessions/{id}/transcript", func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.PathValue("id")
+		if err := runtimeprivacy.ValidateSessionID(sessionID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tenantID := r.URL.Query().Get("tenant_id")
+		res := runtimesecurity.Resource{Type: "sessions", Name: sessionID, Tenant: tenantID}
+		principal, ok := guard.authorize(w, r, "sessions:read", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			tenantID = principal.TenantID
+		}
+		turns, found, err := transcripts.ReadSession(tenantID, sessionID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "no transcript for session "+sessionID, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(SessionTranscript{SessionID: sessionID, TenantID: tenantID, Turns: turns})
+	})
+}
+
+// recordTranscript appends a chat turn to its session transcript, and to the
+// user's transcript when the request names a user. Requests without a
+// session_id or X-Session-ID header are recorded under their request ID.
+func recordTranscript(r *http.Request, transcripts *runtimeprivacy.Transcripts, req ChatRequest, answer, promptHash string, served *runtimemodel.ModelInfo, sources []Source, latency time.Duration) {
+	sessionID := req.SessionID
+	if sessionID == "" {
+		sessionID = r.Header.Get("X-Session-ID")
+	}
+	if sessionID == "" {
+		sessionID = requestIDFrom(r.Context())
+	}
+	turn := runtimeprivacy.Turn{
+		Time: time.Now().UTC(), RequestID: requestIDFrom(r.Context()), TenantID: req.TenantID, UserID: req.UserID,
+		SessionID: sessionID, Context: req.Context, Component: req.Component, Query: req.Query, Response: answer,
+		PromptHash: promptHash, LatencyMS: latency.Milliseconds(),
+	}
+	if served != nil {
+		turn.Provider, turn.Model = served.Provider, served.Model
+	}
+	for _, src := range sources {
+		turn.Sources = append(turn.Sources, runtimeprivacy.TurnSource{ID: src.ID, Score: src.Score, Cited: src.Cited})
+	}
+	if err := transcripts.Append(turn); err != nil {
+		logger.WithContext(r.Context()).Warn("transcript not recorded", zap.Error(err))
+	}
+}
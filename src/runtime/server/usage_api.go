@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+)
+
+// registerUsageRoutes wires GET /api/v1/usage, which aggregates token usage per
+// tenant, component and day. Query parameters: from, to (YYYY-MM-DD, inclusive),
+// tenant_id and component. Requires usage:read when auth is enabled; tenant-bound
+// keys only see their own tenant.
+func registerUsageRoutes(mux *http.ServeMux, ledger *runtimeusage.Ledger, guard *requestGuard) {
+	mux.HandleFunc("GET /api/v1/usage", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, to, err := runtimeusage.ParseRange(q.Get("from"), q.Get("to"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter := runtimeusage.Filter{From: from, To: to, TenantID: q.Get("tenant_id"), Component: q.Get("component")}
+		res := runtimesecurity.Resource{Type: "usage", Name: filter.Component, Tenant: filter.TenantID}
+		principal, ok := guard.authorize(w, r, "usage:read", res, runtimesecurity.ActionRead)
+		if !ok {
+			return
+		}
+		if principal != nil && principal.TenantID != "" {
+			filter.TenantID = principal.TenantID
+		}
+		records, err := ledger.Query(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report := runtimeusage.Aggregate(records)
+		report.From, report.To = q.Get("from"), q.Get("to")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
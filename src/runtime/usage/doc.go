@@ -0,0 +1,7 @@
+// Package usage records token usage for chat requests and aggregates it for
+// cost reporting.
+//
+// Each inference appends a Record to a JSONL ledger under data/usage. Reports
+// group records per tenant, component and day, priced with the optional
+// config/providers/pricing.yaml, and Prometheus counters feed cost dashboards.
+package usage
@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BudgetsFile is the project-relative path of the budget configuration.
+const BudgetsFile = "config/budgets.yaml"
+
+// Limit is a monthly budget. Zero fields are unlimited.
+type Limit struct {
+	MonthlyTokens   int `yaml:"monthly_tokens" json:"monthly_tokens,omitempty"`
+	MonthlyRequests int `yaml:"monthly_requests" json:"monthly_requests,omitempty"`
+}
+
+// Budgets configures monthly limits per tenant and per API key. Default applies
+// to tenants without their own entry.
+type Budgets struct {
+	Default *Limit           `yaml:"default"`
+	Tenants map[string]Limit `yaml:"tenants"`
+	APIKeys map[string]Limit `yaml:"api_keys"`
+}
+
+// LoadBudgets reads config/budgets.yaml under root. It returns nil when the file
+// does not exist (no quota enforcement).
+func LoadBudgets(root string) (*Budgets, error) {
+	by, err := os.ReadFile(filepath.Join(root, BudgetsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Budgets
+	if err := yaml.Unmarshal(by, &b); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", BudgetsFile, err)
+	}
+	return &b, nil
+}
+
+// QuotaStatus is the result of a quota check. Remaining values are -1 when the
+// corresponding budget is unlimited.
+type QuotaStatus struct {
+	Limited           bool      `json:"-"`
+	Exceeded          bool      `json:"exceeded"`
+	Scope             string    `json:"scope,omitempty"` // tenant|api_key
+	Subject           string    `json:"subject,omitempty"`
+	Limit             string    `json:"limit,omitempty"` // monthly_tokens|monthly_requests
+	RemainingTokens   int       `json:"remaining_tokens"`
+	RemainingRequests int       `json:"remaining_requests"`
+	Reset             time.Time `json:"reset"`
+}
+
+// QuotaManager enforces budgets against usage for the current calendar month
+// (UTC). Counters are seeded from the ledger and updated as records are added.
+type QuotaManager struct {
+	mu      sync.Mutex
+	budgets *Budgets
+	ledger  *Ledger
+	month   string
+	tenants map[string]*Summary
+	keys    map[string]*Summary
+}
+
+// NewQuotaManager returns a manager for budgets. A nil budgets value disables
+// enforcement.
+func NewQuotaManager(budgets *Budgets, ledger *Ledger) *QuotaManager {
+	return &QuotaManager{budgets: budgets, ledger: ledger}
+}
+
+// Enabled reports whether any budget is configured.
+func (q *QuotaManager) Enabled() bool { return q != nil && q.budgets != nil }
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// roll resets counters at the start of a month, reloading them from the ledger.
+func (q *QuotaManager) roll(now time.Time) error {
+	start := monthStart(now)
+	month := start.Format("2006-01")
+	if month == q.month {
+		return nil
+	}
+	records, err := q.ledger.Query(Filter{From: start, To: start.AddDate(0, 1, 0)})
+	if err != nil {
+		return err
+	}
+	q.month = month
+	q.tenants = map[string]*Summary{}
+	q.keys = map[string]*Summary{}
+	for _, r := range records {
+		q.addLocked(r)
+	}
+	return nil
+}
+
+func (q *QuotaManager) addLocked(r Record) {
+	if r.TenantID != "" {
+		if q.tenants[r.TenantID] == nil {
+			q.tenants[r.TenantID] = &Summary{}
+		}
+		q.tenants[r.TenantID].add(r)
+	}
+	if r.APIKeyID != "" {
+		if q.keys[r.APIKeyID] == nil {
+			q.keys[r.APIKeyID] = &Summary{}
+		}
+		q.keys[r.APIKeyID].add(r)
+	}
+}
+
+// Add counts a usage record against the current month's budgets.
+func (q *QuotaManager) Add(r Record) {
+	if !q.Enabled() {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.roll(r.Time); err != nil {
+		return
+	}
+	if monthStart(r.Time).Format("2006-01") == q.month {
+		q.addLocked(r)
+	}
+}
+
+// Check returns the quota status for a tenant and API key at now. The scope with
+// the least remaining budget is reported; Exceeded is set once any budget is used up.
+func (q *QuotaManager) Check(tenantID, apiKeyID string, now time.Time) (QuotaStatus, error) {
+	st := QuotaStatus{RemainingTokens: -1, RemainingRequests: -1, Reset: monthStart(now).AddDate(0, 1, 0)}
+	if !q.Enabled() {
+		return st, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.roll(now); err != nil {
+		return st, err
+	}
+	if tenantID != "" {
+		limit, ok := q.budgets.Tenants[tenantID]
+		if !ok && q.budgets.Default != nil {
+			limit, ok = *q.budgets.Default, true
+		}
+		if ok {
+			st.apply("tenant", tenantID, limit, q.tenants[tenantID])
+		}
+	}
+	if apiKeyID != "" {
+		if limit, ok := q.budgets.APIKeys[apiKeyID]; ok {
+			st.apply("api_key", apiKeyID, limit, q.keys[apiKeyID])
+		}
+	}
+	return st, nil
+}
+
+func (st *QuotaStatus) apply(scope, subject string, limit Limit, used *Summary) {
+	if used == nil {
+		used = &Summary{}
+	}
+	check := func(name string, max, spent int, remaining *int) {
+		if max <= 0 {
+			return
+		}
+		st.Limited = true
+		left := max - spent
+		if left < 0 {
+			left = 0
+		}
+		if *remaining < 0 || left < *remaining {
+			*remaining = left
+		}
+		if left == 0 && !st.Exceeded {
+			st.Exceeded, st.Scope, st.Subject, st.Limit = true, scope, subject, name
+		}
+	}
+	check("monthly_tokens", limit.MonthlyTokens, used.TotalTokens, &st.RemainingTokens)
+	check("monthly_requests", limit.MonthlyRequests, used.Requests, &st.RemainingRequests)
+}
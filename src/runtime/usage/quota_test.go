@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaManager_TenantAndKeyBudgets(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "default: {monthly_requests: 100}\ntenants:\n  acme: {monthly_tokens: 50}\napi_keys:\n  k1: {monthly_requests: 2}\n"
+	if err := os.WriteFile(filepath.Join(root, BudgetsFile), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	budgets, err := LoadBudgets(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger(root)
+	now := time.Date(2025, 5, 20, 12, 0, 0, 0, time.UTC)
+	// last month's usage does not count
+	_ = ledger.Append(Record{Time: now.AddDate(0, -1, 0), TenantID: "acme", PromptTokens: 500})
+	_ = ledger.Append(Record{Time: now, TenantID: "acme", APIKeyID: "k1", PromptTokens: 20, CompletionTokens: 10})
+
+	q := NewQuotaManager(budgets, ledger)
+	st, err := q.Check("acme", "k1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Exceeded || st.RemainingTokens != 20 || st.RemainingRequests != 1 || !st.Reset.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	q.Add(Record{Time: now, TenantID: "acme", APIKeyID: "k1", PromptTokens: 5})
+	st, _ = q.Check("acme", "k1", now)
+	if !st.Exceeded || st.Scope != "api_key" || st.Limit != "monthly_requests" {
+		t.Fatalf("expected api key request budget exceeded, got %+v", st)
+	}
+	// another key for the same tenant still has token budget left
+	st, _ = q.Check("acme", "k2", now)
+	if st.Exceeded || st.RemainingTokens != 15 || st.RemainingRequests != -1 {
+		t.Fatalf("unexpected tenant status %+v", st)
+	}
+	// tenants without an entry use the default
+	st, _ = q.Check("other", "", now)
+	if st.Exceeded || st.RemainingRequests != 100 {
+		t.Fatalf("unexpected default status %+v", st)
+	}
+	// counters reset with the month
+	st, _ = q.Check("acme", "k1", now.AddDate(0, 1, 0))
+	if st.Exceeded || st.RemainingTokens != 50 {
+		t.Fatalf("expected fresh budget next month, got %+v", st)
+	}
+}
+
+func TestQuotaManager_DisabledWithoutBudgets(t *testing.T) {
+	q := NewQuotaManager(nil, NewLedger(t.TempDir()))
+	st, err := q.Check("acme", "k1", time.Now())
+	if err != nil || st.Limited || st.Exceeded {
+		t.Fatalf("expected no enforcement, got %+v, %v", st, err)
+	}
+}
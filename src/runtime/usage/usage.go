@@ -0,0 +1,272 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+// LedgerFile is the project-relative path of the usage ledger.
+const LedgerFile = "data/usage/usage.jsonl"
+
+// PricingFile is the project-relative path of the optional model price list.
+const PricingFile = "config/providers/pricing.yaml"
+
+// DayFormat is the layout of Summary.Day and of date flags/query parameters.
+const DayFormat = "2006-01-02"
+
+// Record is the usage of one chat request.
+type Record struct {
+	Time             time.Time `json:"time"`
+	RequestID        string    `json:"request_id,omitempty"`
+	TenantID         string    `json:"tenant_id,omitempty"`
+	APIKeyID         string    `json:"api_key_id,omitempty"`
+	Component        string    `json:"component,omitempty"`
+	Context          string    `json:"context,omitempty"`
+	Provider         string    `json:"provider,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+	Cost             float64   `json:"cost,omitempty"`
+}
+
+// Filter selects ledger records. Zero values match everything; To is exclusive.
+type Filter struct {
+	From      time.Time
+	To        time.Time
+	TenantID  string
+	APIKeyID  string
+	Component string
+}
+
+func (f Filter) match(r Record) bool {
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !r.Time.Before(f.To) {
+		return false
+	}
+	if f.TenantID != "" && r.TenantID != f.TenantID {
+		return false
+	}
+	if f.APIKeyID != "" && r.APIKeyID != f.APIKeyID {
+		return false
+	}
+	return f.Component == "" || r.Component == f.Component
+}
+
+// Ledger is an append-only JSONL file of usage records.
+type Ledger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLedger returns the ledger for a project root.
+func NewLedger(root string) *Ledger {
+	return &Ledger{path: filepath.Join(root, LedgerFile)}
+}
+
+// Append writes a record to the ledger.
+func (l *Ledger) Append(r Record) error {
+	by, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(by, '\n'))
+	return err
+}
+
+// Query returns the records matching f, oldest first.
+func (l *Ledger) Query(f Filter) ([]Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var out []Record
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("parse usage ledger: %w", err)
+		}
+		if f.match(r) {
+			out = append(out, r)
+		}
+	}
+	return out, sc.Err()
+}
+
+// Summary aggregates usage for one tenant and component on one day (UTC). The
+// report total leaves Day, TenantID and Component empty.
+type Summary struct {
+	Day              string  `json:"day,omitempty"`
+	TenantID         string  `json:"tenant_id,omitempty"`
+	Component        string  `json:"component,omitempty"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (s *Summary) add(r Record) {
+	s.Requests++
+	s.PromptTokens += r.PromptTokens
+	s.CompletionTokens += r.CompletionTokens
+	s.TotalTokens += r.PromptTokens + r.CompletionTokens
+	s.Cost += r.Cost
+}
+
+// Report is the result of aggregating usage over a period.
+type Report struct {
+	From  string    `json:"from,omitempty"`
+	To    string    `json:"to,omitempty"`
+	Rows  []Summary `json:"rows"`
+	Total Summary   `json:"total"`
+}
+
+// Aggregate groups records per day, tenant and component.
+func Aggregate(records []Record) Report {
+	rows := map[[3]string]*Summary{}
+	var rep Report
+	for _, r := range records {
+		key := [3]string{r.Time.UTC().Format(DayFormat), r.TenantID, r.Component}
+		s, ok := rows[key]
+		if !ok {
+			s = &Summary{Day: key[0], TenantID: key[1], Component: key[2]}
+			rows[key] = s
+		}
+		s.add(r)
+		rep.Total.add(r)
+	}
+	rep.Rows = make([]Summary, 0, len(rows))
+	for _, s := range rows {
+		rep.Rows = append(rep.Rows, *s)
+	}
+	sort.Slice(rep.Rows, func(i, j int) bool {
+		a, b := rep.Rows[i], rep.Rows[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.TenantID != b.TenantID {
+			return a.TenantID < b.TenantID
+		}
+		return a.Component < b.Component
+	})
+	return rep
+}
+
+// ParseRange parses inclusive from/to dates (YYYY-MM-DD) into a Filter range.
+// Empty values leave that side open.
+func ParseRange(from, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = time.Parse(DayFormat, from); err != nil {
+			return start, end, fmt.Errorf("invalid from date %q (want YYYY-MM-DD)", from)
+		}
+	}
+	if to != "" {
+		if end, err = time.Parse(DayFormat, to); err != nil {
+			return start, end, fmt.Errorf("invalid to date %q (want YYYY-MM-DD)", to)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+// Price is the cost per 1000 prompt and completion tokens.
+type Price struct {
+	PromptPer1K     float64 `yaml:"prompt_per_1k" json:"prompt_per_1k"`
+	CompletionPer1K float64 `yaml:"completion_per_1k" json:"completion_per_1k"`
+}
+
+// Pricing maps model IDs to prices (config/providers/pricing.yaml).
+type Pricing struct {
+	Currency string           `yaml:"currency"`
+	Default  Price            `yaml:"default"`
+	Models   map[string]Price `yaml:"models"`
+}
+
+// LoadPricing reads the price list under root. A missing file yields zero prices.
+func LoadPricing(root string) (*Pricing, error) {
+	p := &Pricing{}
+	by, err := os.ReadFile(filepath.Join(root, PricingFile))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.Unmarshal(by, p); err != nil {
+		return &Pricing{}, fmt.Errorf("parse %s: %w", PricingFile, err)
+	}
+	return p, nil
+}
+
+// Cost returns the price of the given token counts for model.
+func (p *Pricing) Cost(model string, promptTokens, completionTokens int) float64 {
+	if p == nil {
+		return 0
+	}
+	price, ok := p.Models[model]
+	if !ok {
+		price = p.Default
+	}
+	return float64(promptTokens)/1000*price.PromptPer1K + float64(completionTokens)/1000*price.CompletionPer1K
+}
+
+// Prometheus counters for cost dashboards
+var (
+	TokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_tokens_total",
+		Help: "Tokens consumed by tenant, component, model and kind (prompt|completion).",
+	}, []string{"tenant", "component", "model", "kind"})
+	CostTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_usage_cost_total",
+		Help: "Estimated inference cost by tenant, component and model (pricing.yaml currency).",
+	}, []string{"tenant", "component", "model"})
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cmp_usage_requests_total",
+		Help: "Metered inference requests by tenant, component and model.",
+	}, []string{"tenant", "component", "model"})
+)
+
+// Observe updates the Prometheus counters for a record.
+func Observe(r Record) {
+	TokensTotal.WithLabelValues(r.TenantID, r.Component, r.Model, "prompt").Add(float64(r.PromptTokens))
+	TokensTotal.WithLabelValues(r.TenantID, r.Component, r.Model, "completion").Add(float64(r.CompletionTokens))
+	CostTotal.WithLabelValues(r.TenantID, r.Component, r.Model).Add(r.Cost)
+	RequestsTotal.WithLabelValues(r.TenantID, r.Component, r.Model).Inc()
+}
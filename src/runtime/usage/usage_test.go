@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger_AppendQueryAggregate(t *testing.T) {
+	l := NewLedger(t.TempDir())
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, r := range []Record{
+		{Time: day1, TenantID: "acme", Component: "Support", PromptTokens: 10, CompletionTokens: 5, Cost: 0.5},
+		{Time: day1.Add(time.Hour), TenantID: "acme", Component: "Support", PromptTokens: 20, CompletionTokens: 5, Cost: 1},
+		{Time: day2, TenantID: "beta", Component: "Search", PromptTokens: 7, CompletionTokens: 3},
+	} {
+		if err := l.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := l.Query(Filter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Query = %d records, %v", len(all), err)
+	}
+	rep := Aggregate(all)
+	if len(rep.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", rep.Rows)
+	}
+	first := rep.Rows[0]
+	if first.Day != "2025-03-01" || first.TenantID != "acme" || first.Requests != 2 || first.TotalTokens != 40 || first.Cost != 1.5 {
+		t.Fatalf("unexpected first row %+v", first)
+	}
+	if rep.Total.Requests != 3 || rep.Total.TotalTokens != 50 {
+		t.Fatalf("unexpected total %+v", rep.Total)
+	}
+
+	from, to, err := ParseRange("2025-03-02", "2025-03-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := l.Query(Filter{From: from, To: to})
+	if len(got) != 1 || got[0].TenantID != "beta" {
+		t.Fatalf("date filter returned %+v", got)
+	}
+	got, _ = l.Query(Filter{TenantID: "acme", Component: "Support"})
+	if len(got) != 2 {
+		t.Fatalf("tenant filter returned %+v", got)
+	}
+	if _, _, err := ParseRange("03/01/2025", ""); err == nil {
+		t.Fatal("expected invalid date error")
+	}
+}
+
+func TestPricing_Cost(t *testing.T) {
+	root := t.TempDir()
+	p, err := LoadPricing(root)
+	if err != nil || p.Cost("m", 1000, 1000) != 0 {
+		t.Fatalf("missing pricing should be free: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "config", "providers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "currency: USD\ndefault: {prompt_per_1k: 0.001, completion_per_1k: 0.002}\nmodels:\n  big: {prompt_per_1k: 0.01, completion_per_1k: 0.03}\n"
+	if err := os.WriteFile(filepath.Join(root, PricingFile), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err = LoadPricing(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := p.Cost("big", 2000, 1000); math.Abs(c-0.05) > 1e-9 {
+		t.Fatalf("big cost = %v", c)
+	}
+	if c := p.Cost("other", 1000, 500); math.Abs(c-0.002) > 1e-9 {
+		t.Fatalf("default cost = %v", c)
+	}
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+)
+
+// MemoryIngestHandler runs memory.ingest jobs against the sqlite stores of a
+// project root. The job's tenant selects the store. Re-running an interrupted
+// ingest only embeds the chunks that are still missing.
+func MemoryIngestHandler(root string) Handler {
+	return func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+		var p runtimejobs.MemoryIngestPayload
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return nil, Permanent(fmt.Errorf("invalid payload: %w", err))
+		}
+		if err := p.Validate(); err != nil {
+			return nil, Permanent(err)
+		}
+		store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: p.Component, TenantID: job.TenantID})
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		ss, err := runtimememory.AsSourceStore(store)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+		if !p.Sync {
+			sources := make([]runtimememory.Source, 0, len(p.Documents))
+			for _, d := range p.Documents {
+				content, meta := runtimememory.ParseFrontMatter(d.Content)
+				sources = append(sources, runtimememory.Source{Path: d.ID, Content: content, Metadata: meta})
+			}
+			return ss.UpdateSources(ctx, sources, nil)
+		}
+		docsDir := runtimememory.DerivePath(root, p.Component, job.TenantID, "documents")
+		files, err := runtimememory.ScanDocuments(docsDir)
+		if err != nil {
+			return nil, fmt.Errorf("read documents directory %s: %w", docsDir, err)
+		}
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sources, _, err := runtimememory.LoadSourcesWith(docsDir, paths, runtimememory.ReadOptions{OCR: runtimememory.ComponentOCRConfig(root, p.Component)})
+		if err != nil {
+			return nil, fmt.Errorf("read documents directory %s: %w", docsDir, err)
+		}
+		return ss.SyncSources(ctx, sources)
+	}
+}
+
+// MemoryPruneHandler runs memory.prune jobs: the retention policies in each
+// component's memory_config.yaml are applied to its episodic logs.
+func MemoryPruneHandler(root string) Handler {
+	return func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+		var p runtimejobs.MemoryPrunePayload
+		if len(job.Payload) > 0 {
+			if err := json.Unmarshal(job.Payload, &p); err != nil {
+				return nil, Permanent(fmt.Errorf("invalid payload: %w", err))
+			}
+		}
+		if err := p.Validate(); err != nil {
+			return nil, Permanent(err)
+		}
+		now := time.Now()
+		if p.Component != "" {
+			res, err := runtimememory.PruneEpisodic(root, p.Component, now)
+			if err != nil {
+				return nil, err
+			}
+			return []runtimememory.PruneResult{res}, nil
+		}
+		return runtimememory.PruneAllEpisodic(root, now)
+	}
+}
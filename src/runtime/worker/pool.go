@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/logger"
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	"go.uber.org/zap"
+)
+
+// Handler runs a job and returns its result, which is stored as JSON.
+// Handlers must stop when ctx is cancelled: on shutdown, or when the job was
+// cancelled or taken over by another worker.
+type Handler func(ctx context.Context, job runtimejobs.Job) (interface{}, error)
+
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// Permanent marks err as not worth retrying, e.g. an invalid payload.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Pool runs Concurrency loops that claim jobs from Queue and run the Handler
+// registered for their type. Jobs of types without a handler are left for
+// other workers.
+type Pool struct {
+	Queue       *runtimejobs.Queue
+	Handlers    map[string]Handler
+	Concurrency int
+	// PollInterval is how long an idle loop waits before claiming again.
+	PollInterval time.Duration
+	// Lease is how long a claim lasts; it is renewed at a third of that.
+	Lease time.Duration
+	// ID identifies this worker in job records; defaults to host-pid.
+	ID string
+	// OnFailed, when set, is called for jobs that failed for good: out of
+	// attempts or a permanent error. Retried attempts do not call it.
+	OnFailed func(job runtimejobs.Job)
+}
+
+// Run processes jobs until ctx is cancelled. Jobs still running then are
+// released back to the queue.
+func (p *Pool) Run(ctx context.Context) error {
+	if len(p.Handlers) == 0 {
+		return fmt.Errorf("no job handlers registered")
+	}
+	n := p.Concurrency
+	if n <= 0 {
+		n = 1
+	}
+	id := p.ID
+	if id == "" {
+		host, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	types := make([]string, 0, len(p.Handlers))
+	for t := range p.Handlers {
+		types = append(types, t)
+	}
+	logger.GetLogger().Info("starting contexis worker", append(buildinfo.Get().Fields(),
+		zap.String("worker", id), zap.Int("concurrency", n), zap.Strings("types", types))...)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			p.loop(ctx, worker, types)
+		}(fmt.Sprintf("%s/%d", id, i))
+	}
+	wg.Wait()
+	return nil
+}
+
+func (p *Pool) loop(ctx context.Context, worker string, types []string) {
+	poll := p.PollInterval
+	if poll <= 0 {
+		poll = time.Second
+	}
+	for ctx.Err() == nil {
+		job, ok, err := p.Queue.Claim(worker, types, p.Lease)
+		if err != nil {
+			logger.GetLogger().Error("claim job", zap.String("worker", worker), zap.Error(err))
+		}
+		if ok {
+			p.run(ctx, worker, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(poll):
+		}
+	}
+}
+
+// run executes a claimed job, renewing its lease until the handler returns.
+func (p *Pool) run(ctx context.Context, worker string, job runtimejobs.Job) {
+	log := logger.GetLogger().With(zap.String("job", job.ID), zap.String("type", job.Type), zap.String("worker", worker))
+	lease := p.Lease
+	if lease <= 0 {
+		lease = runtimejobs.DefaultLease
+	}
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lost bool
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				if err := p.Queue.Renew(job.ID, worker, lease); errors.Is(err, runtimejobs.ErrLeaseLost) {
+					lost = true
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	result, err := p.invoke(jobCtx, job)
+	cancel()
+	<-renewed
+	jobDuration.WithLabelValues(job.Type).Observe(time.Since(start).Seconds())
+
+	switch {
+	case lost:
+		log.Warn("job cancelled or taken over while running")
+	case ctx.Err() != nil:
+		if _, rerr := p.Queue.Release(job.ID, worker); rerr != nil {
+			log.Error("release job", zap.Error(rerr))
+		}
+	case err == nil:
+		if _, cerr := p.Queue.Complete(job.ID, worker, result); cerr != nil {
+			log.Error("complete job", zap.Error(cerr))
+			return
+		}
+		jobsProcessed.Inc()
+		log.Info("job succeeded", zap.Int("attempt", job.Attempts))
+	default:
+		var perm permanentError
+		failed, ferr := p.Queue.Fail(job.ID, worker, err, !errors.As(err, &perm))
+		if ferr != nil {
+			log.Error("record job failure", zap.Error(ferr))
+			return
+		}
+		jobsFailed.WithLabelValues(job.Type).Inc()
+		log.Warn("job attempt failed", zap.Int("attempt", job.Attempts), zap.String("status", failed.Status), zap.Error(err))
+		if failed.Status == runtimejobs.StatusFailed && p.OnFailed != nil {
+			p.OnFailed(failed)
+		}
+	}
+}
+
+// invoke calls the job's handler, turning panics into permanent failures.
+func (p *Pool) invoke(ctx context.Context, job runtimejobs.Job) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("handler panicked: %v", r))
+		}
+	}()
+	h, ok := p.Handlers[job.Type]
+	if !ok {
+		return nil, Permanent(fmt.Errorf("no handler for job type %q", job.Type))
+	}
+	return h(ctx, job)
+}
@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+)
+
+// waitFinished polls until the job reaches a final status.
+func waitFinished(t *testing.T, q *runtimejobs.Queue, id string) runtimejobs.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := q.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Finished() {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return runtimejobs.Job{}
+}
+
+func TestPool_RetriesAndPermanentFailures(t *testing.T) {
+	q := runtimejobs.NewQueue(t.TempDir())
+	q.Backoff = func(int) time.Duration { return 0 }
+	var calls, failures int32
+	pool := &Pool{Queue: q, Concurrency: 2, PollInterval: 5 * time.Millisecond, Lease: time.Second, ID: "test",
+		OnFailed: func(runtimejobs.Job) { atomic.AddInt32(&failures, 1) },
+		Handlers: map[string]Handler{
+			runtimejobs.TypeEvalRun: func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+				var p runtimejobs.EvalRunPayload
+				_ = json.Unmarshal(job.Payload, &p)
+				switch p.Component {
+				case "Flaky":
+					if atomic.AddInt32(&calls, 1) == 1 {
+						return nil, errors.New("transient")
+					}
+					return map[string]string{"ok": "yes"}, nil
+				case "Broken":
+					return nil, Permanent(errors.New("bad payload"))
+				}
+				panic("unexpected component")
+			},
+		}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = pool.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	flaky, _ := q.Submit(runtimejobs.Job{Type: runtimejobs.TypeEvalRun, Payload: json.RawMessage(`{"component":"Flaky"}`)})
+	broken, _ := q.Submit(runtimejobs.Job{Type: runtimejobs.TypeEvalRun, Payload: json.RawMessage(`{"component":"Broken"}`)})
+	panicky, _ := q.Submit(runtimejobs.Job{Type: runtimejobs.TypeEvalRun, Payload: json.RawMessage(`{"component":"Other"}`)})
+
+	if j := waitFinished(t, q, flaky.ID); j.Status != runtimejobs.StatusSucceeded || j.Attempts != 2 || string(j.Result) != `{"ok":"yes"}` {
+		t.Fatalf("flaky job = %+v", j)
+	}
+	if j := waitFinished(t, q, broken.ID); j.Status != runtimejobs.StatusFailed || j.Attempts != 1 || j.Error != "bad payload" {
+		t.Fatalf("broken job = %+v", j)
+	}
+	if j := waitFinished(t, q, panicky.ID); j.Status != runtimejobs.StatusFailed || j.Attempts != 1 {
+		t.Fatalf("panicking job = %+v", j)
+	}
+	cancel()
+	<-done
+	// The flaky job's retried attempt is not a final failure
+	if got := atomic.LoadInt32(&failures); got != 2 {
+		t.Fatalf("OnFailed calls = %d, want 2", got)
+	}
+}
+
+func TestPool_ReleasesJobsOnShutdown(t *testing.T) {
+	q := runtimejobs.NewQueue(t.TempDir())
+	started := make(chan struct{})
+	pool := &Pool{Queue: q, PollInterval: 5 * time.Millisecond, ID: "test",
+		Handlers: map[string]Handler{
+			runtimejobs.TypeEvalRun: func(ctx context.Context, job runtimejobs.Job) (interface{}, error) {
+				close(started)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}}
+	job, _ := q.Submit(runtimejobs.Job{Type: runtimejobs.TypeEvalRun})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = pool.Run(ctx)
+		close(done)
+	}()
+	<-started
+	cancel()
+	<-done
+	j, _ := q.Get(job.ID)
+	if j.Status != runtimejobs.StatusQueued || j.Attempts != 0 {
+		t.Fatalf("expected the job released, got %+v", j)
+	}
+}
+
+func TestMemoryIngestHandler(t *testing.T) {
+	root := t.TempDir()
+	h := MemoryIngestHandler(root)
+	payload, _ := json.Marshal(runtimejobs.MemoryIngestPayload{Component: "Docs", Documents: []runtimejobs.Document{
+		{ID: "faq/returns.md", Content: "Items can be returned within 30 days."},
+	}})
+	out, err := h(context.Background(), runtimejobs.Job{Type: runtimejobs.TypeMemoryIngest, TenantID: "acme", Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := out.(runtimememory.IngestResult); !ok || len(res.Added) != 1 {
+		t.Fatalf("unexpected result %+v", out)
+	}
+	_, err = h(context.Background(), runtimejobs.Job{Type: runtimejobs.TypeMemoryIngest, Payload: json.RawMessage(`{"component":"../x","sync":true}`)})
+	var perm permanentError
+	if !errors.As(err, &perm) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestMemoryPruneHandler(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "memory", "SupportBot")
+	_ = os.MkdirAll(filepath.Join(dir, "episodic"), 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "memory_config.yaml"), []byte("episodic:\n  max_entries_per_user: 1\n"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "episodic", "episodes.log"), []byte("first\nsecond\n"), 0o644)
+
+	h := MemoryPruneHandler(root)
+	out, err := h(context.Background(), runtimejobs.Job{Type: runtimejobs.TypeMemoryPrune})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := out.([]runtimememory.PruneResult); !ok || len(res) != 1 || res[0].BySize != 1 {
+		t.Fatalf("unexpected result %+v", out)
+	}
+	_, err = h(context.Background(), runtimejobs.Job{Type: runtimejobs.TypeMemoryPrune, Payload: json.RawMessage(`{"component":"../x"}`)})
+	var perm permanentError
+	if !errors.As(err, &perm) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
@@ -1,11 +1,16 @@
 package worker
 
 import (
+    "encoding/json"
     "net/http"
     "time"
 
+    "github.com/contexis-cmp/contexis/src/cli/logger"
+    "github.com/contexis-cmp/contexis/src/runtime/buildinfo"
+    runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
     "github.com/prometheus/client_golang/prometheus"
     "github.com/prometheus/client_golang/prometheus/promhttp"
+    "go.uber.org/zap"
 )
 
 var (
@@ -13,10 +18,22 @@ var (
         Name: "cmp_worker_jobs_processed_total",
         Help: "Total number of jobs processed by the worker.",
     })
+    jobsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "cmp_worker_jobs_failed_total",
+        Help: "Total number of failed job attempts by job type.",
+    }, []string{"type"})
+    jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "cmp_worker_job_duration_seconds",
+        Help:    "Duration of job attempts by job type.",
+        Buckets: prometheus.ExponentialBuckets(0.1, 4, 8),
+    }, []string{"type"})
 )
 
 func init() {
     prometheus.MustRegister(jobsProcessed)
+    prometheus.MustRegister(jobsFailed)
+    prometheus.MustRegister(jobDuration)
+    prometheus.MustRegister(runtimememory.EpisodicPruned)
 }
 
 // Serve starts a minimal HTTP endpoint for worker health and metrics
@@ -29,7 +46,12 @@ func Serve(addr string) error {
         w.WriteHeader(http.StatusOK)
         _, _ = w.Write([]byte("ok"))
     })
+    mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        _ = json.NewEncoder(w).Encode(buildinfo.Get())
+    })
     mux.Handle("/metrics", promhttp.Handler())
+    logger.GetLogger().Info("starting contexis worker endpoints", append(buildinfo.Get().Fields(), zap.String("addr", addr))...)
     srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
     return srv.ListenAndServe()
 }
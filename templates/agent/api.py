@@ -9,7 +9,8 @@ import json
 import logging
 from typing import Dict, Any, Optional, List
 from dataclasses import dataclass
-from urllib.parse import urljoin
+from urllib.parse import urljoin, urlparse
+import os
 import time
 
 logger = logging.getLogger(__name__)
@@ -43,6 +44,31 @@ class APITool:
                 'Authorization': f'Bearer {api_key}'
             })
     
+    def _check_policy(self, method: str, url: str) -> Optional[str]:
+        """
+        Check a request against the tool policy the runtime passes to command
+        tools (CMP_TOOL_ALLOWED_DOMAINS, CMP_TOOL_ALLOWED_METHODS). Without the
+        variables, e.g. when run by hand, every request is allowed.
+        
+        Returns:
+            The reason the request is denied, or None
+        """
+        domains = os.environ.get("CMP_TOOL_ALLOWED_DOMAINS")
+        if domains is not None:
+            parsed = urlparse(url)
+            host, netloc = (parsed.hostname or "").lower(), parsed.netloc.lower()
+            allowed = False
+            for d in filter(None, domains.lower().split(",")):
+                target = netloc if ":" in d else host
+                if target == d or (d.startswith("*.") and target.endswith(d[1:])):
+                    allowed = True
+            if not allowed:
+                return f"host {parsed.netloc} is not allowed"
+        methods = os.environ.get("CMP_TOOL_ALLOWED_METHODS")
+        if methods is not None and method.upper() not in methods.upper().split(","):
+            return f"method {method.upper()} is not allowed"
+        return None
+    
     def _make_request(self, method: str, endpoint: str, data: Optional[Dict] = None, 
                      params: Optional[Dict] = None) -> APIResponse:
         """
@@ -64,6 +90,16 @@ class APITool:
             time.sleep(self.rate_limit_delay)
             
             url = urljoin(self.base_url, endpoint)
+            denied = self._check_policy(method, url)
+            if denied:
+                logger.warning(f"Request denied by tool policy: {denied}")
+                return APIResponse(
+                    status_code=0,
+                    data={"error": f"denied by tool policy: {denied}"},
+                    headers={},
+                    response_time=0.0,
+                    success=False
+                )
             
             logger.info(f"Making {method} request to {url}")
             
@@ -77,6 +77,13 @@ class FileSystemTool:
         except ValueError:
             raise ValueError(f"Path {file_path} is outside allowed directory")
         
+        # Paths of the runtime's tool policy, set when run as a command tool
+        allowed_paths = os.environ.get("CMP_TOOL_ALLOWED_PATHS")
+        if allowed_paths is not None:
+            allowed = [Path(p).resolve() for p in allowed_paths.split(os.pathsep) if p]
+            if not any(resolved_path == a or a in resolved_path.parents for a in allowed):
+                raise ValueError(f"Path {file_path} is denied by tool policy")
+        
         return resolved_path
     
     def _check_file_size(self, file_path: Path) -> bool:
@@ -3,6 +3,7 @@ package integration
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/contexis-cmp/contexis/src/cli/commands"
@@ -163,6 +164,9 @@ func (suite *AgentGeneratorIntegrationTestSuite) TestAgentGenerationWithDifferen
 			toolDir := filepath.Join("tools", agentName)
 			fileCount := suite.utils.CountFilesInDirectory(t, toolDir)
 			expectedCount := len(tools) + 1 // +1 for requirements.txt
+			if slices.Contains(tools, "database") {
+				expectedCount-- // executed by the runtime, no tool file
+			}
 			assert.Equal(t, expectedCount, fileCount, "Tool directory should have correct number of files")
 		})
 	}
@@ -342,6 +346,11 @@ func (suite *AgentGeneratorIntegrationTestSuite) verifyToolFiles(t *testing.T, a
 		if tool == "" {
 			continue
 		}
+		if tool == "database" {
+			// Executed by the runtime from the context, no tool file
+			assert.NoFileExists(t, filepath.Join("tools", agentName, "database.py"))
+			continue
+		}
 
 		toolPath := filepath.Join("tools", agentName, tool+".py")
 		content := suite.utils.GetFileContent(t, toolPath)
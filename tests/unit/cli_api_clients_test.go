@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestGenerateAPIClients_PythonAndTypeScript(t *testing.T) {
+	by, _ := json.Marshal(runtimeserver.OpenAPI())
+	var doc map[string]interface{}
+	if err := json.Unmarshal(by, &doc); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	files, err := commands.GenerateAPIClients(doc, []string{"python", "ts"}, out, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 9 {
+		t.Fatalf("unexpected files %v", files)
+	}
+	read := func(name string) string {
+		by, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(by)
+	}
+	for name, want := range map[string][]string{
+		"python/contexis_client/client.py": {
+			`def chat(self, body: "ChatRequest", debug: Optional[str] = None) -> "ChatResponse":`,
+			`def get_usage(self, from_: Optional[str] = None,`,
+			`f"/api/v1/memory/{_q(component)}/search"`,
+		},
+		"python/contexis_client/models.py": {
+			"class ChatRequest(TypedDict):\n    component: NotRequired[str]",
+			`Report = TypedDict("Report", {`,
+			`    rendered: str`,
+		},
+		"python/pyproject.toml": {`version = "1.2.3"`},
+		"typescript/src/client.ts": {
+			`chat(body: m.ChatRequest, query: { "debug"?: string } = {}): Promise<m.ChatResponse>`,
+			"`/api/v1/jobs/${encodeURIComponent(id)}`",
+			`health(): Promise<string>`,
+		},
+		"typescript/src/models.ts": {"export interface ChatResponse {", "  rendered: string;", "  sources?: Source[];"},
+	} {
+		got := read(name)
+		for _, w := range want {
+			if !strings.Contains(got, w) {
+				t.Errorf("%s lacks %q", name, w)
+			}
+		}
+	}
+	if _, err := commands.GenerateAPIClients(doc, []string{"java"}, out, "1.2.3"); err == nil {
+		t.Fatal("expected an error for an unsupported language")
+	}
+}
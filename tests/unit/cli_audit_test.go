@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+)
+
+func TestAuditSearchCommand_FiltersAndFormats(t *testing.T) {
+	root := t.TempDir()
+	sink := runtimesecurity.NewJSONFileSink(filepath.Join(root, runtimesecurity.DefaultAuditLog))
+	now := time.Now().UTC()
+	for _, ev := range []runtimesecurity.AuditEvent{
+		{Timestamp: now.Add(-48 * time.Hour), TenantID: "acme", Action: "chat:invoke", Result: "denied", Reason: "quota_exceeded"},
+		{Timestamp: now.Add(-time.Hour), TenantID: "acme", Action: "chat:invoke", Result: "denied", Reason: "model_override", RequestID: "req-1"},
+		{Timestamp: now.Add(-time.Hour), TenantID: "acme", Action: "chat:invoke", Result: "allowed"},
+		{Timestamp: now.Add(-time.Hour), TenantID: "beta", Action: "chat:invoke", Result: "denied"},
+	} {
+		if err := sink.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	search := func(args ...string) string {
+		t.Helper()
+		cmd := commands.GetAuditCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"search"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	out := search("--tenant", "acme", "--action", "chat:invoke", "--since", "24h", "--result", "denied", "--format", "json")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var ev runtimesecurity.AuditEvent
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &ev) != nil || ev.RequestID != "req-1" {
+		t.Fatalf("unexpected json output:\n%s", out)
+	}
+	if out := search("--result", "denied"); !strings.Contains(out, "TENANT") || strings.Count(out, "denied") != 3 {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+	if out := search("--tenant", "beta", "--format", "csv"); !strings.HasPrefix(out, "time,tenant,") || strings.Count(out, "\n") != 2 {
+		t.Fatalf("unexpected csv:\n%s", out)
+	}
+	if out := search("--tenant", "nobody"); !strings.Contains(out, "no matching audit events") {
+		t.Fatalf("unexpected empty output:\n%s", out)
+	}
+}
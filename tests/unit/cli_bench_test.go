@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestBench_InProcessReportsPercentilesAndStages(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "Refunds take 14 days."})
+	rep, err := commands.RunBench(context.Background(), "", commands.BenchOptions{
+		Component: "SupportBot", Queries: []string{"refunds?", "shipping?"},
+		Concurrency: 4, Duration: time.Minute, Requests: 20, Handler: h,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Requests != 20 || rep.Errors != 0 || rep.StatusCodes["200"] != 20 || rep.Target != "in-process" {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	if rep.Latency.Count != 20 || rep.Latency.P50 > rep.Latency.P95 || rep.Latency.P95 > rep.Latency.Max || rep.Throughput <= 0 {
+		t.Fatalf("unexpected latency %+v (%.1f/s)", rep.Latency, rep.Throughput)
+	}
+	for _, stage := range []string{"render", "inference"} {
+		if rep.Stages[stage].Count != 20 {
+			t.Fatalf("stage %s missing: %+v", stage, rep.Stages)
+		}
+	}
+	if err := (commands.BenchGates{MaxErrorRate: 0.01}).Check(rep); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBench_CommandAgainstServerFailsErrorGate(t *testing.T) {
+	srv := httptest.NewServer(runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{err: errors.New("model down")}))
+	defer srv.Close()
+	dir := t.TempDir()
+	queries := filepath.Join(dir, "queries.txt")
+	if err := os.WriteFile(queries, []byte("# smoke\nrefunds?\n\nshipping?\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "bench.json")
+	cmd := commands.GetBenchCommand()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--component", "SupportBot", "--addr", srv.URL, "--queries", queries, "--requests", "6", "--concurrency", "2", "--out", out, "--max-error-rate", "0.1"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "error rate 1.000 exceeds 0.100") {
+		t.Fatalf("expected the error gate to fail, got %v", err)
+	}
+	by, rerr := os.ReadFile(out)
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	var rep commands.BenchReport
+	if err := json.Unmarshal(by, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Requests != 6 || rep.StatusCodes["502"] != 6 || rep.Target != srv.URL || len(rep.ErrorSamples) != 1 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+}
@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	"github.com/spf13/cobra"
+)
+
+// complete runs cobra's hidden __complete command in root and returns the
+// suggestions, without the trailing directive line.
+func complete(t *testing.T, root string, args ...string) []string {
+	t.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	rootCmd := &cobra.Command{Use: "ctx"}
+	rootCmd.AddCommand(commands.GetRunCommand(), commands.GetMemoryCommand(), commands.GetContextCommand(""), commands.GetCompletionCommand())
+	commands.RegisterCompletions(rootCmd)
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs(append([]string{cobra.ShellCompRequestCmd}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.HasPrefix(line, ":") {
+			got = append(got, line)
+		}
+	}
+	return got
+}
+
+func TestCompletion_ProjectNames(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	writeProjectFile(t, root, "contexts/SalesBot/salesbot.ctx", "name: SalesBot\nversion: '1.0.0'\n")
+	writeProjectFile(t, root, "contexts/tenants/acme/SupportBot.ctx", "extends: ../../SupportBot/support_bot.ctx\n")
+	writeProjectFile(t, root, "memory/CustomerDocs/documents/faq.md", "faq")
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"run", ""}, "SalesBot,SupportBot"},
+		{[]string{"run", "Su"}, "SupportBot"},
+		{[]string{"run", "SupportBot", ""}, ""},
+		{[]string{"memory", "search", "--component", "C"}, "CustomerDocs"},
+		{[]string{"memory", "ingest", "--provider", ""}, "sqlite,episodic"},
+		{[]string{"context", "tenant", "diff", ""}, "acme"},
+		{[]string{"context", "validate", "--tenant", ""}, "acme"},
+		{[]string{"completion", "p"}, "powershell"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(complete(t, root, c.args...), ","); got != c.want {
+			t.Errorf("%v: got %q, want %q", c.args, got, c.want)
+		}
+	}
+}
+
+func TestCompletion_Scripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		rootCmd := &cobra.Command{Use: "ctx"}
+		rootCmd.AddCommand(commands.GetCompletionCommand())
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs([]string{"completion", shell})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		if !strings.Contains(out.String(), "ctx") || out.Len() < 500 {
+			t.Fatalf("%s: unexpected script %q", shell, out.String())
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+const legacyAgentCtx = `name: "SupportBot"
+version: "1.0.0"
+role:
+  persona: "helper"
+guardrails:
+  tone: "friendly"
+memory:
+  type: "episodic"
+  max_history: 10
+`
+
+func TestContextMigrateSchema_DryRunThenApply(t *testing.T) {
+	root := t.TempDir()
+	writeProjectFile(t, root, "contexts/SupportBot/support_bot.ctx", legacyAgentCtx)
+	writeProjectFile(t, root, "contexts/Docs/rag_agent.ctx", "schema_version: 2\nname: Docs\nversion: '1.0.0'\nrole:\n  persona: librarian\n")
+	writeProjectFile(t, root, "contexts/tenants/acme/SupportBot.ctx", "guardrails:\n  similarity_threshold: 0.9\n  max_tokens: 200\n")
+
+	report := commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	if got := doctorFindings(report, "contexts"); len(got) != 1 || got[0].Status != commands.DoctorWarn || !strings.Contains(got[0].Fix, "migrate-schema") {
+		t.Fatalf("expected a warning about the old schema, got %+v", got)
+	}
+
+	run := func(stdin string, args ...string) string {
+		t.Helper()
+		cmd := commands.GetContextCommand(root)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetIn(strings.NewReader(stdin))
+		cmd.SetArgs(append([]string{"migrate-schema"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("migrate-schema %v: %v\n%s", args, err, out.String())
+		}
+		return out.String()
+	}
+
+	out := run("", "--dry-run")
+	for _, want := range []string{
+		"+++ b/contexts/SupportBot/support_bot.ctx",
+		"+schema_version: 2\n",
+		"-  type: \"episodic\"\n+  episodic: true\n",
+		"note  contexts/tenants/acme/SupportBot.ctx: removed guardrails.similarity_threshold",
+		"dry run: no files were changed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "contexts/Docs") {
+		t.Fatalf("current context should be left alone:\n%s", out)
+	}
+	if readProjectFile(t, root, "contexts/SupportBot/support_bot.ctx") != legacyAgentCtx {
+		t.Fatal("dry run modified a file")
+	}
+
+	if out := run("n\n", "SupportBot"); !strings.Contains(out, "aborted") {
+		t.Fatalf("expected the prompt to abort:\n%s", out)
+	}
+	if out := run("y\n", "SupportBot"); !strings.Contains(out, "Migrated 2 context file(s)") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if got := readProjectFile(t, root, "contexts/tenants/acme/SupportBot.ctx"); got != "schema_version: 2\nguardrails:\n  max_tokens: 200\n" {
+		t.Fatalf("tenant override:\n%s", got)
+	}
+	report = commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	if got := doctorFindings(report, "contexts"); len(got) != 1 || got[0].Status != commands.DoctorOK {
+		t.Fatalf("expected migrated contexts to pass, got %+v", got)
+	}
+	if out := run("", "--yes"); !strings.Contains(out, "contexts are up to date") {
+		t.Fatalf("expected nothing left to migrate:\n%s", out)
+	}
+}
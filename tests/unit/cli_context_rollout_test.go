@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimerollout "github.com/contexis-cmp/contexis/src/runtime/rollout"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestContextRollout_ShiftsChatTraffic(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	rollout := func(args ...string) (string, error) {
+		cmd := commands.GetContextCommand(root)
+		var out strings.Builder
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"rollout"}, args...))
+		cmd.SilenceUsage = true
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	draft := filepath.Join(root, "drafts", "support_bot.ctx")
+	writeFile(t, draft, "name: SupportBot\nversion: '1.1.0'\nrole:\n  persona: 'friendlier helper'\n")
+	for _, args := range [][]string{{"register", "SupportBot"}, {"register", "SupportBot", draft}} {
+		if _, err := rollout(args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	if _, err := rollout("start", "SupportBot", "--stable", "1.0.0", "--candidate", "2.0.0"); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected an unregistered candidate to be refused, got %v", err)
+	}
+	out, err := rollout("start", "SupportBot", "--stable", "1.0.0", "--candidate", "1.1.0", "--percent", "100")
+	if err != nil || !strings.Contains(out, "serves version 1.1.0 to 100%") {
+		t.Fatalf("start = %q, %v", out, err)
+	}
+
+	h := runtimeserver.NewHandlerWithProvider(root, nil)
+	chat := func(session string) runtimeserver.ChatResponse {
+		t.Helper()
+		rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", SessionID: session})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var got runtimeserver.ChatResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		if got.Rollout == nil || rr.Header().Get("X-Context-Version") != got.Rollout.Version {
+			t.Fatalf("missing rollout: %+v, header %q", got.Rollout, rr.Header().Get("X-Context-Version"))
+		}
+		return got
+	}
+	for i := 0; i < 5; i++ {
+		if got := chat(fmt.Sprintf("s%d", i)); got.Rollout.Version != "1.1.0" || got.Rollout.Track != runtimerollout.Candidate {
+			t.Fatalf("expected the candidate, got %+v", got.Rollout)
+		}
+	}
+
+	// The running handler picks up the rollback
+	if out, err := rollout("rollback", "SupportBot"); err != nil || !strings.Contains(out, "serves version 1.0.0 to all traffic") {
+		t.Fatalf("rollback = %q, %v", out, err)
+	}
+	if got := chat("s0"); got.Rollout.Version != "1.0.0" || got.Rollout.Track != runtimerollout.Stable {
+		t.Fatalf("expected the stable version, got %+v", got.Rollout)
+	}
+	if _, err := rollout("promote", "SupportBot"); err == nil {
+		t.Fatal("expected nothing to promote after the rollback")
+	}
+
+	out, err = rollout("status", "SupportBot", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status struct {
+		Rollouts []runtimerollout.Rollout `json:"rollouts"`
+		Versions []runtimerollout.Summary `json:"versions"`
+	}
+	if err := json.Unmarshal([]byte(out), &status); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(status.Rollouts) != 1 || status.Rollouts[0].Stable != "1.0.0" || len(status.Versions) != 2 ||
+		status.Versions[0].Requests != 1 || status.Versions[1].Requests != 5 || status.Versions[1].ErrorRate != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimecontext "github.com/contexis-cmp/contexis/src/runtime/context"
+	runtimeeval "github.com/contexis-cmp/contexis/src/runtime/eval"
+	runtimeprompt "github.com/contexis-cmp/contexis/src/runtime/prompt"
+)
+
+func TestGenerateContextInteractive_ValidatesAnswers(t *testing.T) {
+	root := t.TempDir()
+	answers := strings.Join([]string{
+		"",                             // description: default
+		"",                             // persona: required, asked again
+		"Patient billing specialist",   // persona
+		"invoices, refunds",            // capabilities
+		"-",                            // limitations: none
+		"web_search, crm",              // unknown tool, asked again
+		"web_search, crm=mcp://crm.v1", // tools
+		"friendly",                     // tone
+		"yaml",                         // invalid format, asked again
+		"markdown",                     // format
+		"-5",                           // below schema minimum, asked again
+		"300",                          // max tokens
+		"",                             // temperature: default
+		"mask",                         // not in the pii enum, asked again
+		"block",                        // pii
+		"y",                            // episodic memory
+		"",                             // max history: default
+		"",                             // privacy: default
+		"1.5",                          // out of range, asked again
+		"0.9",                          // drift threshold
+		"no_refunds_over_500",          // business rules
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	files, err := commands.GenerateContextInteractive(root, "BillingBot", strings.NewReader(answers), &out)
+	if err != nil {
+		t.Fatalf("wizard failed: %v\n%s", err, out.String())
+	}
+	if len(files) != 3 {
+		t.Fatalf("files = %v", files)
+	}
+	for _, want := range []string{"role.persona: String length must be greater than or equal to 1", "unknown tool \"crm\"", "format must be", "guardrails.max_tokens", "guardrails.pii.mode", "between 0 and 1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in wizard output:\n%s", want, out.String())
+		}
+	}
+
+	ctx, err := runtimecontext.NewContextService(root).ResolveContext("", "BillingBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Role.Persona != "Patient billing specialist" || len(ctx.Role.Capabilities) != 2 || len(ctx.Tools) != 2 || ctx.Tools[1].URI != "mcp://crm.v1" {
+		t.Fatalf("unexpected context %+v", ctx)
+	}
+	if ctx.Guardrails.MaxTokens != 300 || ctx.Guardrails.PII == nil || ctx.Guardrails.PII.Mode != "block" || ctx.Memory.MaxHistory != 10 || ctx.Testing.DriftThreshold != 0.9 {
+		t.Fatalf("unexpected guardrails/memory/testing %+v %+v %+v", ctx.Guardrails, ctx.Memory, ctx.Testing)
+	}
+
+	rendered, err := runtimeprompt.NewEngine(root).RenderFile("BillingBot", "agent_response.md", map[string]interface{}{"context": ctx, "user_input": "Where is my invoice?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rendered, "You are Patient billing specialist.") || !strings.Contains(rendered, "- crm") || !strings.Contains(rendered, "User: Where is my invoice?") {
+		t.Fatalf("unexpected prompt:\n%s", rendered)
+	}
+
+	spec, err := runtimeeval.LoadSpec(filepath.Join(root, files[2]))
+	if err != nil || spec.Component != "BillingBot" || len(spec.Cases) != 3 || spec.Graders[0].Type != "llm_judge" {
+		t.Fatalf("spec = %+v, %v", spec, err)
+	}
+
+	// Existing files are never overwritten
+	if _, err := commands.GenerateContextInteractive(root, "BillingBot", strings.NewReader(answers), &out); err == nil {
+		t.Fatal("expected an error for an existing context")
+	}
+}
@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+func TestDestroyComponent_DryRunThenRemove(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"contexts", "prompts", "memory", "tools", "tests"} {
+		if err := os.MkdirAll(filepath.Join(root, d, "SupportBot"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "prompts", "OtherBot"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lock := commands.LockFile{
+		Contexts: map[string]string{"SupportBot": "sha256:a", "OtherBot": "sha256:b"},
+		Prompts:  map[string]map[string]string{"SupportBot": {"SupportBot/agent_response.md": "x"}},
+		Memory:   map[string]string{},
+	}
+	by, _ := json.Marshal(lock)
+	if err := os.WriteFile(filepath.Join(root, "context.lock.json"), by, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := commands.DestroyComponent(root, "SupportBot", true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(plan) != 6 {
+		t.Fatalf("expected 5 dirs + lock entry in plan, got %v", plan)
+	}
+	if _, err := os.Stat(filepath.Join(root, "contexts", "SupportBot")); err != nil {
+		t.Fatalf("dry run must not delete files")
+	}
+
+	if _, err := commands.DestroyComponent(root, "SupportBot", false); err != nil {
+		t.Fatalf("destroy: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "contexts", "SupportBot")); !os.IsNotExist(err) {
+		t.Fatalf("expected contexts/SupportBot removed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "prompts", "OtherBot")); err != nil {
+		t.Fatalf("unrelated component must be kept")
+	}
+	by, _ = os.ReadFile(filepath.Join(root, "context.lock.json"))
+	var got commands.LockFile
+	if err := json.Unmarshal(by, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Contexts["SupportBot"]; ok {
+		t.Fatalf("lock entry not pruned")
+	}
+	if _, ok := got.Contexts["OtherBot"]; !ok {
+		t.Fatalf("unrelated lock entry removed")
+	}
+}
+
+func TestDestroyComponent_RejectsReservedNames(t *testing.T) {
+	root := t.TempDir()
+	override := filepath.Join(root, "contexts", "tenants", "acme", "SupportBot.ctx")
+	shared := filepath.Join(root, "prompts", "_shared", "header.md")
+	for _, p := range []string{override, shared} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"tenants", "_shared", "Tenants"} {
+		if _, err := commands.DestroyComponent(root, name, false); err == nil {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+	for _, p := range []string{override, shared} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("%s removed: %v", p, err)
+		}
+	}
+}
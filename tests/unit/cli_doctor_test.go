@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+// doctorFindings returns the checks of a report with the given name.
+func doctorFindings(report commands.DoctorReport, name string) []commands.DoctorCheck {
+	var out []commands.DoctorCheck
+	for _, c := range report.Checks {
+		if c.Name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func doctorEnv(t *testing.T, root string) {
+	t.Helper()
+	t.Setenv("CMP_PROJECT_ROOT", root)
+	t.Setenv("CMP_MOCK_PROVIDERS", "true")
+	t.Setenv("CMP_LOCAL_MODELS", "false")
+	t.Setenv("CMP_ENV", "")
+	t.Setenv("HF_TOKEN", "")
+	t.Setenv("HF_MODEL_ID", "")
+	t.Setenv("OLLAMA_HOST", "")
+	t.Setenv("CMP_PYTHON_BIN", "")
+	t.Setenv("CMP_MODEL_CACHE_DIR", filepath.Join(root, "data", "models"))
+}
+
+func TestDoctor_HealthyProjectThenProblems(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	for _, d := range []string{"memory", "config"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	doctorEnv(t, root)
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte("schema_version: 2\nname: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, _ := json.Marshal(commands.ComputeLockFile(root))
+	if err := os.WriteFile(filepath.Join(root, "context.lock.json"), lock, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	if report.Failures != 0 {
+		t.Fatalf("expected a healthy project, got %+v", report.Checks)
+	}
+	for _, name := range []string{"contexts", "templates", "lockfile", "provider"} {
+		if got := doctorFindings(report, name); len(got) != 1 || got[0].Status != commands.DoctorOK {
+			t.Fatalf("%s: %+v", name, got)
+		}
+	}
+
+	// Break the context schema, a template and the lock file
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte("name: SupportBot\nrole: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), []byte("{{ if }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CMP_PROVIDER_MODE", "rewind")
+
+	report = commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	ctxCheck := doctorFindings(report, "contexts")
+	if len(ctxCheck) != 1 || ctxCheck[0].Status != commands.DoctorFail || !strings.Contains(ctxCheck[0].Message, "contexts/SupportBot/support_bot.ctx") {
+		t.Fatalf("contexts: %+v", ctxCheck)
+	}
+	if tmpl := doctorFindings(report, "templates"); len(tmpl) != 1 || tmpl[0].Status != commands.DoctorFail {
+		t.Fatalf("templates: %+v", tmpl)
+	}
+	lockCheck := doctorFindings(report, "lockfile")
+	if len(lockCheck) != 1 || !strings.Contains(lockCheck[0].Message, "prompts/SupportBot/agent_response.md changed") || lockCheck[0].Fix == "" {
+		t.Fatalf("lockfile: %+v", lockCheck)
+	}
+	if env := doctorFindings(report, "env"); len(env) != 1 || !strings.Contains(env[0].Message, "CMP_PROVIDER_MODE") {
+		t.Fatalf("env: %+v", env)
+	}
+	if report.Failures < 4 {
+		t.Fatalf("expected at least 4 failures, got %d", report.Failures)
+	}
+}
+
+func TestDoctor_RejectedHFToken(t *testing.T) {
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/whoami-v2" || r.Header.Get("Authorization") != "Bearer hf_good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"octo"}`))
+	}))
+	defer hub.Close()
+	root := scaffoldTempRoot(t)
+	doctorEnv(t, root)
+	t.Setenv("CMP_HF_HUB_URL", hub.URL)
+
+	t.Setenv("HF_TOKEN", "hf_good")
+	report := commands.RunDoctor(context.Background(), root, commands.DoctorOptions{})
+	if got := doctorFindings(report, "hf-token"); len(got) != 1 || got[0].Status != commands.DoctorOK || !strings.Contains(got[0].Message, "octo") {
+		t.Fatalf("valid token: %+v", got)
+	}
+	t.Setenv("HF_TOKEN", "hf_revoked")
+	report = commands.RunDoctor(context.Background(), root, commands.DoctorOptions{})
+	if got := doctorFindings(report, "hf-token"); len(got) != 1 || got[0].Status != commands.DoctorFail {
+		t.Fatalf("revoked token: %+v", got)
+	}
+	// --offline does not call the Hub
+	report = commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	if got := doctorFindings(report, "hf-token"); len(got) != 1 || got[0].Status != commands.DoctorSkip {
+		t.Fatalf("offline: %+v", got)
+	}
+}
+
+func TestDoctor_ConfigPlaceholders(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	doctorEnv(t, root)
+	t.Setenv("CMP_ENV", "production")
+	t.Setenv("DB_HOST", "")
+	os.Unsetenv("DB_HOST")
+	prod := "database:\n  host: ${DB_HOST}\n  port: ${DB_PORT:-5432}\nlogging:\n  level: loud\n"
+	if err := os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "production.yaml"), []byte(prod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	got := doctorFindings(report, "config")
+	if len(got) != 1 || got[0].Status != commands.DoctorFail || !strings.Contains(got[0].Message, "DB_HOST") || !strings.Contains(got[0].Message, "logging.level") {
+		t.Fatalf("config: %+v", got)
+	}
+
+	t.Setenv("DB_HOST", "db.internal")
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "production.yaml"), []byte(strings.Replace(prod, "loud", "info", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report = commands.RunDoctor(context.Background(), root, commands.DoctorOptions{Offline: true})
+	if got := doctorFindings(report, "config"); len(got) != 1 || got[0].Status != commands.DoctorOK {
+		t.Fatalf("config after fix: %+v", got)
+	}
+}
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+// scaffoldDriftRoot writes a drift spec and documents for each component.
+func scaffoldDriftRoot(t *testing.T, components ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	spec := `test_cases:
+  - name: refunds
+    input: "refund policy for returns"
+    expected_similarity: 0.01
+  - name: shipping
+    input: "shipping times"
+    expected_similarity: 0.01
+`
+	for _, c := range components {
+		writeFile(t, filepath.Join(root, "tests", c, "rag_drift_test.yaml"), spec)
+		writeFile(t, filepath.Join(root, "memory", c, "documents", "policy.md"), "# Policy\nOur refund policy covers returns within 30 days. Shipping times are 3 days.\n")
+	}
+	return root
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriftDetection_ParallelSpecsKeepOrderAndReportTiming(t *testing.T) {
+	root := scaffoldDriftRoot(t, "Alpha", "Beta", "Gamma", "Delta")
+	opts := commands.DriftOptions{Concurrency: 3, WriteJUnit: true}
+	reports, err := commands.RunDriftDetectionReport(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range reports {
+		names = append(names, r.Component)
+		if r.Failed != 0 || r.Total != 2 || r.DurationMs < 0 {
+			t.Fatalf("unexpected report %+v", r)
+		}
+	}
+	if strings.Join(names, ",") != "Alpha,Beta,Delta,Gamma" {
+		t.Fatalf("reports out of spec order: %v", names)
+	}
+	by, err := os.ReadFile(filepath.Join(root, "tests", "reports", "junit-drift.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	junit := string(by)
+	for _, want := range []string{`<testsuites name="drift" tests="8" failures="0">`, `<testsuite name="Beta" tests="2" failures="0" time="`, `<testcase classname="Gamma" name="shipping" time="`} {
+		if !strings.Contains(junit, want) {
+			t.Fatalf("junit report lacks %s:\n%s", want, junit)
+		}
+	}
+}
+
+func TestDriftDetection_MaxTestDurationStopsSlowSpecs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreter is a shell script")
+	}
+	root := scaffoldDriftRoot(t, "Slow")
+	writeFile(t, filepath.Join(root, "config", "environments", "development.yaml"), "testing:\n  max_test_duration: 200ms\n")
+	// An interpreter that hangs stands in for a stuck semantic search
+	python := filepath.Join(root, "python")
+	writeFile(t, python, "#!/bin/sh\nexec sleep 10\n")
+	if err := os.Chmod(python, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CMP_PYTHON_BIN", python)
+	t.Setenv("CMP_ENV", "")
+
+	start := time.Now()
+	reports, err := commands.RunDriftDetectionReport(context.Background(), root, commands.DriftOptions{UseSemantic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("spec ran for %s despite max_test_duration", elapsed)
+	}
+	rep := reports[0]
+	if rep.Failed != 2 {
+		t.Fatalf("expected both cases to fail, got %+v", rep)
+	}
+	for _, r := range rep.Results {
+		if r.Status != "ERROR" || !strings.Contains(strings.Join(r.Reasons, ";"), "max_test_duration") {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+}
+
+func TestWriteHTMLReport_DriftAndCoverage(t *testing.T) {
+	root := scaffoldDriftRoot(t, "Alpha")
+	ctx := context.Background()
+	if _, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{UpdateBaseline: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "data", "drift", "history.jsonl"), `{"time":"2026-01-01T00:00:00Z","kind":"drift","component":"Alpha","score":0.5,"passed":1,"failed":1,"total":2}`+"\n")
+	writeFile(t, filepath.Join(root, "tests", "reports", "go_tests.json"), `{"results":[{"suite":"unit","passed":false,"coverage_pct":42.5,"threshold":80,"output_path":"go_unit.txt","error":"coverage <b>low</b>"}]}`)
+
+	path, err := commands.WriteHTMLReport(root, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(root, "tests", "reports", commands.HTMLReportFile) {
+		t.Fatalf("unexpected path %s", path)
+	}
+	by, _ := os.ReadFile(path)
+	html := string(by)
+	for _, want := range []string{
+		`<th>Alpha</th>`, `<td class="passed"`, // matrix
+		`&#43;0.000`, // delta against the baseline
+		`<polyline`,  // trend with the recorded point
+		`42.5%`, `coverage &lt;b&gt;low&lt;/b&gt;`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("report lacks %s:\n%s", want, html)
+		}
+	}
+	if _, err := commands.WriteHTMLReport(t.TempDir(), ""); err == nil {
+		t.Fatal("expected an error without reports")
+	}
+}
+
+func TestDriftHistory_RecordsRunsAndReportsRegressions(t *testing.T) {
+	root := scaffoldDriftRoot(t, "Alpha")
+	ctx := context.Background()
+	if _, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// The refund policy disappears from the documents
+	writeFile(t, filepath.Join(root, "memory", "Alpha", "documents", "policy.md"), "# Delivery\nShipping times are 3 days.\n")
+	if _, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	history := func(args ...string) string {
+		t.Helper()
+		cmd := commands.GetDriftCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(append([]string{"history"}, args...))
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	var res commands.DriftHistory
+	if err := json.Unmarshal([]byte(history("--component", "alpha", "--json")), &res); err != nil {
+		t.Fatal(err)
+	}
+	runs := res.Series["Alpha"]
+	if len(runs) != 2 || runs[0].Score != 1 || runs[1].Score != 0.5 || runs[1].Cases["shipping"] == 0 {
+		t.Fatalf("unexpected series %+v", res.Series)
+	}
+	if len(res.Regressions) != 2 || res.Regressions[0].Case != "" || res.Regressions[1].Case != "refunds" || res.Regressions[1].Current != 0 {
+		t.Fatalf("unexpected regressions %+v", res.Regressions)
+	}
+	if text := history(); !strings.Contains(text, "ctx test") || !strings.Contains(text, "Regressions:") || !strings.Contains(text, "refunds") {
+		t.Fatalf("unexpected history:\n%s", text)
+	}
+}
+
+func TestDriftDetection_WarnsWhenBaselineReliesOnExpiredDocument(t *testing.T) {
+	root := scaffoldDriftRoot(t, "Alpha")
+	ctx := context.Background()
+	if _, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{UpdateBaseline: true}); err != nil {
+		t.Fatal(err)
+	}
+	// The policy the baseline was recorded from has since expired
+	docs := filepath.Join(root, "memory", "Alpha", "documents")
+	writeFile(t, filepath.Join(docs, "policy.md"), "---\nvalid_until: 2020-12-31\n---\n# Policy\nOur refund policy covers returns within 30 days. Shipping times are 3 days.\n")
+	writeFile(t, filepath.Join(docs, "shipping.md"), "---\neffective_date: 2021-01-01\n---\nShipping times are 5 days.\n")
+	reports, err := commands.RunDriftDetectionReport(ctx, root, commands.DriftOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]commands.DriftTestResult{}
+	for _, r := range reports[0].Results {
+		byName[r.Name] = r
+	}
+	refunds := byName["refunds"]
+	if refunds.Status != "FAILED" || len(refunds.Warnings) != 1 || !strings.Contains(refunds.Warnings[0], "expired document policy.md (valid_until 2020-12-31)") {
+		t.Fatalf("expected the refunds case to fail with a warning, got %+v", refunds)
+	}
+	// The current shipping document matches better than the expired one
+	if shipping := byName["shipping"]; shipping.Status != "PASSED" || len(shipping.Warnings) != 0 {
+		t.Fatalf("unexpected shipping result %+v", shipping)
+	}
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimeeval "github.com/contexis-cmp/contexis/src/runtime/eval"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestRunEvals_WritesReportsAndTrend(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	evalDir := filepath.Join(root, "tests", "SupportBot", "evals")
+	if err := os.MkdirAll(evalDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	spec := `cases:
+  - name: greeting
+    input: hello
+    expected: Hello! How can I help?
+    graders:
+      - type: exact
+      - type: llm_judge
+`
+	if err := os.WriteFile(filepath.Join(evalDir, "smoke.yaml"), []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := commands.EvalOptions{
+		WriteJUnit: true,
+		Handler:    runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "Hello! How can I help?"}),
+		Judge:      fakeProvider{out: `{"score": 0.9, "reason": "polite"}`},
+	}
+
+	var out bytes.Buffer
+	for i := 0; i < 2; i++ {
+		out.Reset()
+		reports, err := commands.RunEvals(context.Background(), root, opts, &out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 1 || reports[0].Passed != 1 || reports[0].Score < 0.94 {
+			t.Fatalf("unexpected reports %+v", reports)
+		}
+	}
+	if !strings.Contains(out.String(), "+0.000 vs previous run") {
+		t.Fatalf("expected trend against previous run, got:\n%s", out.String())
+	}
+
+	reportsDir := filepath.Join(root, runtimeeval.ReportsDir)
+	for _, name := range []string{"eval_SupportBot_smoke.json", "eval_index.json", "junit-eval.xml"} {
+		if _, err := os.Stat(filepath.Join(reportsDir, name)); err != nil {
+			t.Fatalf("missing report %s: %v", name, err)
+		}
+	}
+	history, err := runtimeeval.LoadHistory(reportsDir)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected 2 history points, got %d (%v)", len(history), err)
+	}
+}
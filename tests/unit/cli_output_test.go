@@ -0,0 +1,144 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	"github.com/contexis-cmp/contexis/src/runtime/buildinfo"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// runStructured runs args under a root with the global --output flag, in
+// root as working directory, and returns stdout.
+func runStructured(t *testing.T, root string, args ...string) string {
+	t.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	rootCmd := &cobra.Command{Use: "ctx"}
+	commands.AddOutputFlag(rootCmd)
+	rootCmd.AddCommand(commands.GetLockCommand(), commands.GetMemoryCommand())
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&bytes.Buffer{})
+	rootCmd.SetArgs(args)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestOutput_LockJSON(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	var res struct {
+		commands.Result
+		Data commands.LockResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(runStructured(t, root, "lock", "generate", "-o", "json")), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Schema != commands.SchemaLock || !res.OK {
+		t.Fatalf("unexpected envelope %+v", res.Result)
+	}
+	if len(res.Data.Lock.Prompts["SupportBot"]) == 0 || res.Data.Path == "" {
+		t.Fatalf("unexpected payload %+v", res.Data)
+	}
+}
+
+func TestOutput_MemoryYAML(t *testing.T) {
+	t.Setenv("CMP_MOCK_PROVIDERS", "true")
+	root := scaffoldTempRoot(t)
+	writeProjectFile(t, root, "memory/SupportBot/documents/faq.md", "Returns are accepted within 30 days.")
+
+	var ingest map[string]interface{}
+	if err := yaml.Unmarshal([]byte(runStructured(t, root, "memory", "ingest", "--component", "SupportBot", "--all", "--quiet", "--output", "yaml")), &ingest); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ingest["data"].(map[string]interface{})
+	if ingest["schema"] != commands.SchemaMemoryIngest || ingest["ok"] != true || data["version"] == "" || data["documents"] != 1 {
+		t.Fatalf("unexpected ingest result %v", ingest)
+	}
+
+	var search struct {
+		commands.Result
+		Data commands.MemorySearchResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(runStructured(t, root, "memory", "search", "--component", "SupportBot", "--query", "returns", "-o", "json")), &search); err != nil {
+		t.Fatal(err)
+	}
+	if search.Schema != commands.SchemaMemorySearch || len(search.Data.Results) != 1 || search.Data.Query != "returns" {
+		t.Fatalf("unexpected search result %+v", search)
+	}
+}
+
+func TestOutput_MemoryIngestParsesFormats(t *testing.T) {
+	t.Setenv("CMP_MOCK_PROVIDERS", "true")
+	root := scaffoldTempRoot(t)
+	writeProjectFile(t, root, "faq.jsonl", `{"q":"Shipping?","a":"3 days"}`+"\n"+`{"q":"Returns?","a":"30 days"}`+"\n")
+	writeProjectFile(t, root, "page.txt", "<nav>Home</nav><p>Opening hours are 9 to 5.</p>")
+	ingest := func(args ...string) commands.MemoryIngestResult {
+		t.Helper()
+		var res struct {
+			commands.Result
+			Data commands.MemoryIngestResult `json:"data"`
+		}
+		args = append([]string{"memory", "ingest", "--component", "SupportBot", "--quiet", "-o", "json"}, args...)
+		if err := json.Unmarshal([]byte(runStructured(t, root, args...)), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Data
+	}
+	// One document per JSONL row, picked by extension
+	if res := ingest("--input", "faq.jsonl"); res.Documents != 2 {
+		t.Fatalf("expected a document per row, got %+v", res)
+	}
+	// --format html parses a file whose extension says text
+	if res := ingest("--input", "page.txt", "--format", "htm"); res.Documents != 1 {
+		t.Fatalf("expected one html document, got %+v", res)
+	}
+	var search struct {
+		Data commands.MemorySearchResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(runStructured(t, root, "memory", "search", "--component", "SupportBot", "--query", "Opening hours are 9 to 5.", "--top-k", "1", "-o", "json")), &search); err != nil {
+		t.Fatal(err)
+	}
+	if len(search.Data.Results) != 1 || search.Data.Results[0].Content != "Opening hours are 9 to 5." {
+		t.Fatalf("unexpected search result %+v", search.Data.Results)
+	}
+}
+
+func TestOutput_TextUnchanged(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	if out := runStructured(t, root, "lock", "generate"); !bytes.HasPrefix([]byte(out), []byte("wrote ")) {
+		t.Fatalf("text output changed: %q", out)
+	}
+}
+
+func TestVersion_StructuredOutput(t *testing.T) {
+	rootCmd := &cobra.Command{Use: "ctx"}
+	commands.AddOutputFlag(rootCmd)
+	rootCmd.AddCommand(commands.GetVersionCommand())
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"version", "-o", "json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Schema string         `json:"schema"`
+		Data   buildinfo.Info `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if res.Schema != commands.SchemaVersion || res.Data != buildinfo.Get() || res.Data.FrameworkVersion != commands.FrameworkVersion {
+		t.Fatalf("unexpected version result %+v", res)
+	}
+}
@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+// TestScaffoldAndDriftRun_Portable runs init, generate and the drift runner
+// the way `ctx init`, `ctx generate` and `ctx test` do; CI runs it on Windows.
+func TestScaffoldAndDriftRun_Portable(t *testing.T) {
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("CMP_PYTHON_BIN", filepath.Join(dir, "no-python"))
+
+	commands.InitCmd.SetArgs([]string{"demo"})
+	if err := commands.InitCmd.Execute(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	root := filepath.Join(dir, "demo")
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	commands.GenerateCmd.SetArgs([]string{"rag", "SupportDocs", "--db=sqlite"})
+	if err := commands.GenerateCmd.Execute(); err != nil {
+		t.Fatalf("generate rag: %v", err)
+	}
+
+	// Lock entries are slash-separated whatever the platform
+	lock := commands.ComputeLockFile(root)
+	if len(lock.Prompts["SupportDocs"]) == 0 {
+		t.Fatalf("no prompts recorded: %+v", lock.Prompts)
+	}
+	for rel := range lock.Prompts["SupportDocs"] {
+		if strings.Contains(rel, `\`) || !strings.HasPrefix(rel, "SupportDocs/") {
+			t.Fatalf("non-portable lock key %q", rel)
+		}
+	}
+	// A fresh project needs no upgrade
+	plan, err := commands.PlanUpgrade(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes()) != 0 {
+		t.Fatalf("fresh project needs an upgrade: %s", plan.Diff())
+	}
+
+	// Semantic scoring falls back to the naive scorer without Python
+	opts := commands.DriftOptions{UpdateBaseline: true, UseSemantic: true}
+	if err := commands.RunDriftDetection(context.Background(), root, opts); err != nil {
+		t.Fatalf("drift: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tests", "SupportDocs", "baselines", "drift_baseline.json")); err != nil {
+		t.Fatalf("baseline not written: %v", err)
+	}
+	by, err := os.ReadFile(filepath.Join(root, "tests", "reports", "drift_SupportDocs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep commands.DriftRunReport
+	if err := json.Unmarshal(by, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Component != "SupportDocs" || rep.Total == 0 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+}
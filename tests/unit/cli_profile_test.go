@@ -0,0 +1,41 @@
+package unit
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestCaptureProfile_FromAdminListener(t *testing.T) {
+	t.Setenv("CMP_PPROF_ENABLED", "true")
+	_, admin := runtimeserver.NewHandlers(scaffoldTempRoot(t), nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	srv := httptest.NewServer(admin)
+	defer srv.Close()
+
+	for _, kind := range commands.ProfileTypes {
+		data, err := commands.CaptureProfile(context.Background(), srv.URL, kind, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		// pprof profiles are gzip-compressed protobufs
+		if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+			t.Fatalf("%s: not a pprof profile (%d bytes)", kind, len(data))
+		}
+	}
+	if _, err := commands.CaptureProfile(context.Background(), srv.URL, "mutex", time.Second); err == nil {
+		t.Fatal("expected an error for an unknown profile type")
+	}
+
+	t.Setenv("CMP_PPROF_ENABLED", "")
+	_, admin = runtimeserver.NewHandlers(scaffoldTempRoot(t), nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	off := httptest.NewServer(admin)
+	defer off.Close()
+	if _, err := commands.CaptureProfile(context.Background(), off.URL, "heap", 0); err == nil || !strings.Contains(err.Error(), "CMP_PPROF_ENABLED") {
+		t.Fatalf("expected a hint to enable pprof, got %v", err)
+	}
+}
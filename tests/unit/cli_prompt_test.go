@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+func TestPromptSnapshots_UpdateThenDetectChanges(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), "Hello {{.user}}!\nRules:\n- be brief\n- be kind\n")
+	fixtures := filepath.Join(root, "tests", "SupportBot", commands.PromptFixturesDir)
+	writeFile(t, filepath.Join(fixtures, "agent_response.yaml"), "data:\n  user: Alice\n")
+	writeFile(t, filepath.Join(fixtures, "bob.json"), `{"template": "agent_response.md", "data": {"user": "Bob"}}`)
+
+	rep, err := commands.RunPromptSnapshots(root, commands.PromptSnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Passed || len(rep.Results) != 2 || rep.Results[0].Status != commands.SnapshotMissing {
+		t.Fatalf("expected missing goldens, got %+v", rep)
+	}
+
+	if rep, err = commands.RunPromptSnapshots(root, commands.PromptSnapshotOptions{Component: "supportbot", Update: true}); err != nil || !rep.Passed {
+		t.Fatalf("update = %+v, %v", rep, err)
+	}
+	by, err := os.ReadFile(filepath.Join(fixtures, "bob.golden"))
+	if err != nil || string(by) != "Hello Bob!\nRules:\n- be brief\n- be kind\n" {
+		t.Fatalf("golden = %q, %v", by, err)
+	}
+	if rep, err = commands.RunPromptSnapshots(root, commands.PromptSnapshotOptions{}); err != nil || !rep.Passed {
+		t.Fatalf("expected the goldens to match, got %+v, %v", rep, err)
+	}
+
+	// A refactor changes what the model sees
+	writeFile(t, filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), "Hello {{.user}}!\nRules:\n- be brief\n")
+	writeFile(t, filepath.Join(fixtures, "broken.yaml"), "template: missing.md\n")
+	rep, err = commands.RunPromptSnapshots(root, commands.PromptSnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, r := range rep.Results {
+		statuses = append(statuses, r.Status)
+	}
+	if rep.Passed || strings.Join(statuses, ",") != "FAILED,FAILED,ERROR" {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+	diff := rep.Results[0].Diff
+	if !strings.Contains(diff, "--- tests/SupportBot/prompt_fixtures/agent_response.golden") || !strings.Contains(diff, "\n-- be kind\n") {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	if _, err := commands.RunPromptSnapshots(root, commands.PromptSnapshotOptions{Component: "Other"}); err == nil {
+		t.Fatal("expected an error without fixtures")
+	}
+}
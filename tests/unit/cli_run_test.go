@@ -0,0 +1,91 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("CMP_PROJECT_ROOT", root)
+	t.Setenv("CMP_LOCAL_MODELS", "true")
+	t.Setenv("CMP_PYTHON_BIN", "python3")
+	cmd := commands.GetRunCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestRun_ExistingServer(t *testing.T) {
+	t.Setenv("CMP_API_KEY", "k1")
+	var got commands.RunRequest
+	var auth string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) })
+	mux.HandleFunc("/api/v1/chat", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(commands.RunResponse{Rendered: "30 days"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	out, err := runCommand(t, "SupportBot", "What is your return policy?", "--addr", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out) != "30 days" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if got.Context != "SupportBot" || got.Component != "SupportBot" || got.Query != "What is your return policy?" || auth != "Bearer k1" {
+		t.Fatalf("unexpected request %+v (auth %q)", got, auth)
+	}
+
+	// host:port works as well as a URL
+	if out, err := runCommand(t, "SupportBot", "hi", "--addr", strings.TrimPrefix(srv.URL, "http://")); err != nil || strings.TrimSpace(out) != "30 days" {
+		t.Fatalf("host:port: %q, %v", out, err)
+	}
+
+	// auto leaves the component to the server's dispatcher
+	if _, err := runCommand(t, "auto", "Where is my order?", "--addr", srv.URL); err != nil || got.Context != "auto" || got.Component != "" {
+		t.Fatalf("auto: unexpected request %+v, %v", got, err)
+	}
+}
+
+func TestRun_ExplicitAddrMustBeHealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := runCommand(t, "SupportBot", "hi", "--addr", srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "no healthy server at "+srv.URL) {
+		t.Fatalf("expected unhealthy server error, got %v", err)
+	}
+}
+
+func TestRun_ErrorShowsRequestID(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("ok")) })
+	mux.HandleFunc("/api/v1/chat", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-7")
+		http.Error(w, "inference failed", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	_, err := runCommand(t, "SupportBot", "hi", "--addr", srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "request ID req-7") {
+		t.Fatalf("expected the request ID in the error, got %v", err)
+	}
+}
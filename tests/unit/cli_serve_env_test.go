@@ -0,0 +1,34 @@
+package unit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+)
+
+func TestServe_EnvRefusesUnsafeProduction(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	t.Setenv("CMP_PROJECT_ROOT", root)
+	for _, name := range []string{"CMP_ENV", "CMP_LOG_LEVEL", "CMP_LOG_FORMAT", "CMP_DB_PROVIDER", "CMP_DB_PATH", "CMP_LOCAL_MODELS",
+		"CMP_MOCK_PROVIDERS", "CMP_OFFLINE_MODE", "CMP_AUTH_ENABLED", "CMP_AUTH_MODE", "CMP_PI_ENFORCEMENT", "CMP_PII_MODE",
+		"CMP_REQUIRE_CITATION", "HF_TOKEN", "HF_MODEL_ID", "CMP_PYTHON_BIN"} {
+		t.Setenv(name, "")
+	}
+	serve := func(args ...string) error {
+		cmd := commands.GetServeCommand()
+		cmd.SetArgs(args)
+		cmd.SilenceUsage = true
+		return cmd.Execute()
+	}
+
+	if err := serve("--env", "staging"); err == nil || !strings.Contains(err.Error(), "staging.yaml not found") {
+		t.Fatalf("expected a missing environment config, got %v", err)
+	}
+	writeFile(t, filepath.Join(root, "config", "environments", "production.yaml"), "database:\n  provider: sqlite\n  path: ./data/prod.db\n")
+	err := serve("--env", "production")
+	if err == nil || !strings.Contains(err.Error(), "authentication is disabled") || !strings.Contains(err.Error(), "the database is sqlite") {
+		t.Fatalf("expected production to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	"github.com/contexis-cmp/contexis/src/runtime/drift"
+	"github.com/contexis-cmp/contexis/src/runtime/notifications"
+)
+
+func writeProjectFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readProjectFile(t *testing.T, root, rel string) string {
+	t.Helper()
+	by, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(by)
+}
+
+func TestUpgrade_LegacyProject(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	writeProjectFile(t, root, "memory/SupportBot/documents/faq.md", "Returns are accepted within 30 days.")
+	writeProjectFile(t, root, "context.lock.json", `{
+  "version": "1.0.0",
+  "project": "legacy",
+  "created": "2025-01-16T10:00:00Z",
+  "contexts": {},
+  "memory": {"SupportBot": "hash-of-absolute-paths"},
+  "prompts": {},
+  "tools": {}
+}`)
+	writeProjectFile(t, root, "config/environments/development.yaml", `environment: development
+
+# Logging Configuration
+logging:
+  level: debug
+
+# Development Features
+features:
+  hot_reload: true
+`)
+	writeProjectFile(t, root, "config/schedules.yaml", `schedules:
+  - name: nightly-drift
+    cron: "0 2 * * *"
+    kind: drift
+    component: SupportBot
+alerts:
+  - type: slack
+    url: ${SLACK_WEBHOOK_URL}
+  - type: slack
+    url: https://hooks.example.com/second
+
+# keep this comment
+`)
+
+	plan, err := commands.PlanUpgrade(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.From != "" || plan.To != commands.FrameworkVersion || len(plan.Applied) != 3 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	diff := plan.Diff()
+	for _, want := range []string{
+		"+++ b/config/environments/development.yaml",
+		"+# HTTP Server Configuration\n+server:\n",
+		"--- /dev/null\n+++ b/config/notifications.yaml",
+		"-alerts:\n",
+		`+  "framework_version": "` + commands.FrameworkVersion + `",`,
+	} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("diff lacks %q:\n%s", want, diff)
+		}
+	}
+	// Planning writes nothing
+	if strings.Contains(readProjectFile(t, root, "config/environments/development.yaml"), "server:") {
+		t.Fatal("plan modified the project")
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	dev := readProjectFile(t, root, "config/environments/development.yaml")
+	if !strings.Contains(dev, "idle_timeout: 120s\n\n# Development Features\nfeatures:") {
+		t.Fatalf("server section not inserted before features:\n%s", dev)
+	}
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.example.com/first")
+	ncfg, err := notifications.LoadConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ncfg.Channels) != 2 || ncfg.Channels[1].Name != "drift-slack-2" || ncfg.Channels[0].Wants(notifications.EventJobFailed) {
+		t.Fatalf("unexpected channels %+v", ncfg.Channels)
+	}
+	sched := readProjectFile(t, root, "config/schedules.yaml")
+	if strings.Contains(sched, "alerts") || !strings.Contains(sched, "# keep this comment") {
+		t.Fatalf("unexpected schedules.yaml:\n%s", sched)
+	}
+	if cfg, err := drift.LoadConfig(root); err != nil || len(cfg.Schedules) != 1 {
+		t.Fatalf("schedules = %+v, %v", cfg, err)
+	}
+	var lock commands.LockFile
+	if err := json.Unmarshal([]byte(readProjectFile(t, root, "context.lock.json")), &lock); err != nil {
+		t.Fatal(err)
+	}
+	if lock.FrameworkVersion != commands.FrameworkVersion || lock.Project != "legacy" ||
+		lock.Memory["SupportBot"] != commands.ComputeLockFile(root).Memory["SupportBot"] {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+
+	// A second run has nothing to do
+	plan, err = commands.PlanUpgrade(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes()) != 0 {
+		t.Fatalf("expected no changes, got %+v", plan.Changes())
+	}
+}
+
+func TestUpgrade_RejectsNewerProject(t *testing.T) {
+	root := t.TempDir()
+	if _, err := commands.PlanUpgrade(root); err == nil || !strings.Contains(err.Error(), "context.lock.json not found") {
+		t.Fatalf("missing lock: %v", err)
+	}
+	writeProjectFile(t, root, "context.lock.json", `{"framework_version":"99.0.0","contexts":{},"prompts":{},"memory":{}}`)
+	if _, err := commands.PlanUpgrade(root); err == nil || !strings.Contains(err.Error(), "newer than this ctx") {
+		t.Fatalf("newer project: %v", err)
+	}
+}
@@ -418,18 +418,6 @@ import logging
 def web_search(query: str) -> list:
     """Dummy web_search function for tests"""
     logging.info("web_search called")
-    return []`,
-
-        "templates/agent/database.py": `#!/usr/bin/env python3
-"""
-database Tool for CMP Agents
-"""
-import sqlite3
-import logging
-
-def database_query(sql: str) -> list:
-    """Dummy database function for tests"""
-    logging.info("database_query called")
     return []`,
 
         "templates/agent/file_system.py": `#!/usr/bin/env python3
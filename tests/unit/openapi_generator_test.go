@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"gopkg.in/yaml.v3"
+)
+
+const petstoreSpec = `openapi: 3.0.0
+info:
+  title: Petstore
+servers:
+  - url: https://petstore.example.com/v1/
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List all "pets"
+      parameters:
+        - $ref: '#/components/parameters/limit'
+    post:
+      operationId: createPet
+      requestBody:
+        required: true
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema: {type: string}
+    get:
+      operationId: showPetById
+      summary: Info for a specific pet
+    delete:
+      summary: Remove a pet
+components:
+  parameters:
+    limit:
+      name: limit
+      in: query
+      schema: {type: integer}
+`
+
+func TestLoadOpenAPISpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.yaml")
+	if err := os.WriteFile(path, []byte(petstoreSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := commands.LoadOpenAPISpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Title != "Petstore" || spec.BaseURL != "https://petstore.example.com/v1" {
+		t.Fatalf("unexpected spec header %+v", spec)
+	}
+	var names []string
+	for _, op := range spec.Operations {
+		names = append(names, op.Method+" "+op.ToolName)
+	}
+	if got := strings.Join(names, ","); got != "GET list_pets,POST create_pet,GET show_pet_by_id,DELETE delete_pets_pet_id" {
+		t.Fatalf("operations = %s", got)
+	}
+	limit := spec.Operations[0].Parameters
+	if len(limit) != 1 || limit[0].Name != "limit" || limit[0].In != "query" || limit[0].Type != "integer" {
+		t.Fatalf("referenced parameter not resolved: %+v", limit)
+	}
+	if p := spec.Operations[3].Parameters; len(p) != 1 || p[0].Name != "petId" || !p[0].Required {
+		t.Fatalf("path-level parameter not inherited: %+v", p)
+	}
+	if p := spec.Operations[1].Parameters; len(p) != 1 || p[0].In != "body" || !p[0].Required {
+		t.Fatalf("request body not recorded: %+v", p)
+	}
+}
+
+func TestGenerateAgentFromOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "api.yaml")
+	if err := os.WriteFile(specPath, []byte(petstoreSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := commands.GenerateAgentFromOpenAPI(context.Background(), "PetBot", "", "episodic", specPath); err != nil {
+		t.Fatalf("GenerateAgentFromOpenAPI: %v", err)
+	}
+	by, err := os.ReadFile(filepath.Join("contexts", "PetBot", "petbot.ctx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c corectx.Context
+	if err := yaml.Unmarshal(by, &c); err != nil {
+		t.Fatalf("generated .ctx is not valid YAML: %v\n%s", err, by)
+	}
+	tools := map[string]corectx.Tool{}
+	for _, tool := range c.Tools {
+		tools[tool.Name] = tool
+	}
+	if _, ok := tools["api"]; !ok || len(c.Tools) != 5 {
+		t.Fatalf("expected the api tool and four operations, got %+v", c.Tools)
+	}
+	show := tools["show_pet_by_id"]
+	if show.Method != "GET" || show.URI != "https://petstore.example.com/v1/pets/{petId}" || len(show.Parameters) != 1 || show.Parameters[0].In != "path" {
+		t.Fatalf("unexpected tool definition %+v", show)
+	}
+	if tools["list_pets"].Description != `List all "pets"` {
+		t.Fatalf("description not preserved: %q", tools["list_pets"].Description)
+	}
+
+	stub, err := os.ReadFile(filepath.Join("tools", "PetBot", "pet_bot_api.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"def list_pets(limit=None) -> APIResponse:",
+		`params=_compact({"limit": limit}),`,
+		"def show_pet_by_id(pet_id) -> APIResponse:",
+		`BASE_URL + f"/pets/{pet_id}",`,
+		"def create_pet(body) -> APIResponse:",
+		"        data=body,\n",
+	} {
+		if !strings.Contains(string(stub), want) {
+			t.Fatalf("stub missing %q:\n%s", want, stub)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("tools", "PetBot", "api.py")); err != nil {
+		t.Fatalf("generic API tool not copied: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestNewHandlers_SplitsAdminEndpoints(t *testing.T) {
+	t.Setenv("CMP_PPROF_ENABLED", "true")
+	public, admin := runtimeserver.NewHandlers(scaffoldTempRoot(t), fakeProvider{out: "hello"}, runtimeserver.WithAuditSink(&recordingSink{}))
+	get := func(h http.Handler, path string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+	for _, path := range []string{"/metrics", "/healthz", "/readyz", "/version", "/debug/pprof/", "/api/v1/admin/keys"} {
+		if code := get(public, path); code != http.StatusNotFound {
+			t.Fatalf("public %s: expected 404, got %d", path, code)
+		}
+	}
+	for _, path := range []string{"/metrics", "/healthz", "/readyz", "/version", "/debug/pprof/"} {
+		if code := get(admin, path); code != http.StatusOK {
+			t.Fatalf("admin %s: expected 200, got %d", path, code)
+		}
+	}
+	if code := get(admin, "/openapi.json"); code != http.StatusNotFound {
+		t.Fatalf("admin listener should not serve the public API, got %d", code)
+	}
+	if rr := sendChat(t, public, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"}); rr.Code != http.StatusOK {
+		t.Fatalf("public chat: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Without the split, one handler serves everything but pprof
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	if get(h, "/metrics") != http.StatusOK || get(h, "/healthz") != http.StatusOK {
+		t.Fatal("combined handler should serve metrics and probes")
+	}
+	if code := get(h, "/debug/pprof/"); code != http.StatusNotFound {
+		t.Fatalf("pprof must not be served on the public port, got %d", code)
+	}
+
+	t.Setenv("CMP_PPROF_ENABLED", "")
+	_, admin = runtimeserver.NewHandlers(scaffoldTempRoot(t), nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	if code := get(admin, "/debug/pprof/"); code != http.StatusNotFound {
+		t.Fatalf("pprof should be off unless enabled, got %d", code)
+	}
+}
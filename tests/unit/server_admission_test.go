@@ -0,0 +1,151 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// gateProvider reports each prompt on started and answers once release is
+// signalled.
+type gateProvider struct {
+	started chan string
+	release chan struct{}
+}
+
+func (p *gateProvider) Generate(ctx context.Context, prompt string, _ runtimemodel.Params) (string, error) {
+	p.started <- prompt
+	select {
+	case <-p.release:
+		return "ok", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func admissionRoot(t *testing.T, cfg string) string {
+	t.Helper()
+	root := scaffoldTempRoot(t)
+	writeProjectFile(t, root, "prompts/SupportBot/agent_response.md", "Q: {{ .user_input }}")
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// chatWithPriority sends a chat request for query, with the X-Priority header
+// and bearer token when set.
+func chatWithPriority(h http.Handler, query, priority, token string) *httptest.ResponseRecorder {
+	by, _ := json.Marshal(runtimeserver.ChatRequest{TenantID: "t1", Context: "SupportBot", Component: "SupportBot", Query: query,
+		Data: map[string]interface{}{"user_input": query}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by))
+	if priority != "" {
+		req.Header.Set("X-Priority", priority)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdmission_ShedsBatchAndQueuesInteractiveByWeight(t *testing.T) {
+	cfg := "server:\n  admission:\n    max_concurrent: 1\n    retry_after: 3s\n    classes:\n      batch: {max_queue: 1}\n"
+	prov := &gateProvider{started: make(chan string, 4), release: make(chan struct{})}
+	h := runtimeserver.NewHandlerWithProvider(admissionRoot(t, cfg), prov)
+
+	done := make(chan *httptest.ResponseRecorder, 3)
+	go func() { done <- chatWithPriority(h, "first", "", "") }()
+	if got := <-prov.started; got != "Q: first" {
+		t.Fatalf("unexpected prompt %q", got)
+	}
+	go func() { done <- chatWithPriority(h, "queued batch", "batch", "") }()
+	time.Sleep(50 * time.Millisecond)
+
+	// The batch queue holds one request, so the next batch request is shed
+	rr := chatWithPriority(h, "shed", "batch", "")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "3" || rr.Header().Get("X-Priority") != "batch" {
+		t.Fatalf("expected 429 with Retry-After: 3, got %d %v %s", rr.Code, rr.Header(), rr.Body.String())
+	}
+
+	go func() { done <- chatWithPriority(h, "queued interactive", "", "") }()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case p := <-prov.started:
+		t.Fatalf("%q started while the slot was taken", p)
+	default:
+	}
+
+	// The interactive class outweighs batch, so it gets the freed slot first
+	var order []string
+	for i := 0; i < 3; i++ {
+		prov.release <- struct{}{}
+		if i < 2 {
+			order = append(order, <-prov.started)
+		}
+	}
+	if len(order) != 2 || order[0] != "Q: queued interactive" || order[1] != "Q: queued batch" {
+		t.Fatalf("unexpected order %q", order)
+	}
+	for i := 0; i < 3; i++ {
+		if rr := <-done; rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestAdmission_KeyClassCannotBeRaisedByHeader(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "chatkey@t1:chat:execute,evalkey@t1:chat:execute")
+	cfg := "server:\n  admission:\n    max_concurrent: 1\n    keys:\n      env-2: batch\n"
+	prov := &gateProvider{started: make(chan string, 2), release: make(chan struct{})}
+	h := runtimeserver.NewHandlerWithProvider(admissionRoot(t, cfg), prov)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- chatWithPriority(h, "first", "", "chatkey") }()
+	<-prov.started
+
+	rr := chatWithPriority(h, "eval", "interactive", "evalkey")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-Priority") != "batch" || rr.Header().Get("Retry-After") != "2" {
+		t.Fatalf("expected the batch key to be shed, got %d %v", rr.Code, rr.Header())
+	}
+
+	prov.release <- struct{}{}
+	if rr := <-done; rr.Code != http.StatusOK || rr.Header().Get("X-Priority") != "interactive" {
+		t.Fatalf("expected 200 for the interactive key, got %d %v", rr.Code, rr.Header())
+	}
+	go func() { prov.release <- struct{}{} }()
+	if rr := chatWithPriority(h, "eval", "", "evalkey"); rr.Code != http.StatusOK {
+		t.Fatalf("expected the batch key to be served once idle, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAdmission_ZeroMaxQueueOverridesDefault(t *testing.T) {
+	cfg := "server:\n  admission:\n    max_concurrent: 1\n    classes:\n      interactive: {max_queue: 0}\n"
+	prov := &gateProvider{started: make(chan string, 2), release: make(chan struct{})}
+	h := runtimeserver.NewHandlerWithProvider(admissionRoot(t, cfg), prov)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- chatWithPriority(h, "first", "", "") }()
+	<-prov.started
+
+	// interactive queues 64 requests by default; max_queue: 0 sheds instead
+	if rr := chatWithPriority(h, "second", "", ""); rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-Priority") != "interactive" {
+		t.Fatalf("expected the interactive request to be shed, got %d %v", rr.Code, rr.Header())
+	}
+	prov.release <- struct{}{}
+	if rr := <-done; rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	runtimeapproval "github.com/contexis-cmp/contexis/src/runtime/approval"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// parkChat sends a sensitive chat request in the background and returns the
+// approval it is waiting on together with a channel for its eventual response.
+func parkChat(t *testing.T, h http.Handler) (runtimeapproval.Request, <-chan *httptest.ResponseRecorder) {
+	t.Helper()
+	return parkChatRequest(t, h, runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot",
+		Query: "refund order 42", Data: map[string]interface{}{"action": "payment", "amount": 42}})
+}
+
+// parkChatRequest sends req in the background and returns the approval it
+// waits on.
+func parkChatRequest(t *testing.T, h http.Handler, req runtimeserver.ChatRequest) (runtimeapproval.Request, <-chan *httptest.ResponseRecorder) {
+	t.Helper()
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- sendChat(t, h, req)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/approvals?status=pending", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("list approvals: %d %s", rr.Code, rr.Body.String())
+		}
+		var list runtimeserver.ApprovalList
+		if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Approvals) == 1 {
+			return list.Approvals[0], done
+		}
+		if len(list.Approvals) > 1 {
+			t.Fatalf("expected one pending approval, got %+v", list.Approvals)
+		}
+		select {
+		case rr := <-done:
+			t.Fatalf("chat returned before approval: %d %s", rr.Code, rr.Body.String())
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	t.Fatal("chat request never parked for approval")
+	return runtimeapproval.Request{}, nil
+}
+
+func awaitChat(t *testing.T, done <-chan *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	t.Helper()
+	select {
+	case rr := <-done:
+		return rr
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat did not resume after decision")
+		return nil
+	}
+}
+
+func TestApprovals_ApproveResumesChat(t *testing.T) {
+	t.Setenv("CMP_OOB_REQUIRED_ACTIONS", "payment")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "refund issued"})
+
+	pending, done := parkChat(t, h)
+	if pending.Action != "payment" || pending.TenantID != "acme" || pending.Query != "refund order 42" || pending.Arguments["amount"] != float64(42) {
+		t.Fatalf("unexpected approval request %+v", pending)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/approve", strings.NewReader(`{"reason":"verified by phone"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", rr.Code, rr.Body.String())
+	}
+	chat := awaitChat(t, done)
+	if chat.Code != http.StatusOK || chat.Header().Get("X-Approval-ID") != pending.ID {
+		t.Fatalf("expected approved chat to complete, got %d %s", chat.Code, chat.Body.String())
+	}
+
+	// A decided request cannot be decided again
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/deny", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for decided request, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/approvals/apr_0000000000000000", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown request, got %d", rr.Code)
+	}
+}
+
+func TestApprovals_DenyCancelsChat(t *testing.T) {
+	t.Setenv("CMP_OOB_REQUIRED_ACTIONS", "payment")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "refund issued"})
+
+	pending, done := parkChat(t, h)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/deny", strings.NewReader(`{"reason":"not the account owner"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("deny: %d %s", rr.Code, rr.Body.String())
+	}
+	chat := awaitChat(t, done)
+	if chat.Code != http.StatusForbidden || !strings.Contains(chat.Body.String(), "not the account owner") {
+		t.Fatalf("expected denied chat to fail with 403, got %d %s", chat.Code, chat.Body.String())
+	}
+}
+
+func TestApprovals_GateAgentToolCalls(t *testing.T) {
+	t.Setenv("CMP_OOB_REQUIRED_ACTIONS", "refund_order")
+	var refunds atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refunds.Add(1)
+		w.Write([]byte(`{"refunded":true}`))
+	}))
+	defer api.Close()
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\n" +
+		"tools:\n  - name: refund_order\n    uri: " + api.URL + "/refunds\n    method: POST\n" +
+		"agent:\n  enabled: true\n  max_iterations: 2\n"
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	call := `{"tool_calls": [{"name": "refund_order", "arguments": {"order": "A1"}}]}`
+	prov := &seqProvider{outs: []string{call, `{"content": "Refunded."}`, call, `{"content": "The refund was not approved."}`}}
+	h := runtimeserver.NewHandlerWithProvider(root, prov)
+	// The client declares no action; the tool call itself is gated
+	req := runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot", Query: "refund order A1"}
+
+	pending, done := parkChatRequest(t, h, req)
+	if pending.Action != "refund_order" || pending.Arguments["order"] != "A1" || refunds.Load() != 0 {
+		t.Fatalf("expected the refund to wait for approval, got %+v after %d calls", pending, refunds.Load())
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/approve", nil))
+	if chat := awaitChat(t, done); chat.Code != http.StatusOK || !strings.Contains(chat.Body.String(), "Refunded.") || refunds.Load() != 1 {
+		t.Fatalf("expected the approved refund to run, got %d %s after %d calls", chat.Code, chat.Body.String(), refunds.Load())
+	}
+
+	pending, done = parkChatRequest(t, h, req)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/deny", strings.NewReader(`{"reason":"not the account owner"}`)))
+	chat := awaitChat(t, done)
+	if chat.Code != http.StatusOK || refunds.Load() != 1 {
+		t.Fatalf("expected the denied refund not to run, got %d %s after %d calls", chat.Code, chat.Body.String(), refunds.Load())
+	}
+	if last := prov.prompts[len(prov.prompts)-1]; !strings.Contains(last, "action denied by approver: not the account owner") {
+		t.Fatalf("the denial was not shown to the model:\n%s", last)
+	}
+}
+
+func TestApprovals_ParkedChatReleasesAdmissionSlot(t *testing.T) {
+	t.Setenv("CMP_OOB_REQUIRED_ACTIONS", "payment")
+	cfg := "server:\n  admission:\n    max_concurrent: 1\n    classes:\n      interactive: {max_wait: 1s}\n"
+	h := runtimeserver.NewHandlerWithProvider(admissionRoot(t, cfg), fakeProvider{out: "refund issued"})
+
+	pending, done := parkChat(t, h)
+	// The only slot is free while the first chat waits for its approval
+	rr := sendChat(t, h, runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot", Query: "hello"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the second chat to run, got %d %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/approvals/"+pending.ID+"/approve", nil))
+	if chat := awaitChat(t, done); chat.Code != http.StatusOK {
+		t.Fatalf("expected the approved chat to take a slot again, got %d %s", chat.Code, chat.Body.String())
+	}
+}
@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestChatBudget_DropsLowestScoringChunksToFitWindow(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nguardrails:\n  max_tokens: 20\n"
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), []byte("{{range .results}}- {{.Content}}\n{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filler := strings.Repeat("warehouse inventory shipping logistics schedule ", 12)
+	_, err = store.IngestDocuments(context.Background(), []string{"refunds are issued within 14 days of the return", "pallets: " + filler, "carriers: " + filler})
+	store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := func(window string) (int, runtimeserver.ChatResponse, string) {
+		t.Helper()
+		t.Setenv("CMP_MODEL_CONTEXT_WINDOW", window)
+		h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "Refunds take 14 days [1]."})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(`{"context":"SupportBot","component":"SupportBot","query":"refunds","top_k":3}`))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var got runtimeserver.ChatResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		return rr.Code, got, rr.Body.String()
+	}
+
+	// Without a known window every retrieved chunk is injected
+	if code, got, body := chat(""); code != http.StatusOK || len(got.Sources) != 3 {
+		t.Fatalf("expected 3 sources, got %d: %s", code, body)
+	}
+	// 60 tokens leave room for the best chunk next to the 20 reserved for the answer
+	code, got, body := chat("60")
+	if code != http.StatusOK || len(got.Sources) != 1 || got.Sources[0].Index != 1 {
+		t.Fatalf("expected only the best chunk, got %d: %s", code, body)
+	}
+	// A window smaller than the answer reserve cannot be met by dropping chunks
+	if code, _, body := chat("10"); code != http.StatusRequestEntityTooLarge || !strings.Contains(body, "exceeds the model context window of 10 tokens") {
+		t.Fatalf("expected 413, got %d: %s", code, body)
+	}
+}
@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestConversation_SummaryAndRecentTurnsInPrompt(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nmemory:\n  max_history: 2\n  summarization:\n    enabled: true\n    trigger_turns: 3\n"
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := "{{ if .summary }}SUMMARY: {{ .summary }}\n{{ end }}{{ range .history }}U: {{ .Query }} A: {{ .Response }}\n{{ end }}Q: {{ .user_input }}"
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prov := &seqProvider{outs: []string{"a1", "a2", "a3", "a4", "user asked q1 to q2", "a5"}}
+	h := runtimeserver.NewHandlerWithProvider(root, prov)
+	for i := 1; i <= 5; i++ {
+		body := fmt.Sprintf(`{"context":"SupportBot","component":"SupportBot","query":"q%d","session_id":"s-1","user_id":"u-1","data":{"user_input":"q%d"}}`, i, i)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("turn %d: expected 200, got %d: %s", i, w.Code, w.Body.String())
+		}
+	}
+	// Turn 4 sees the last two turns only
+	if got := prov.prompts[3]; got != "U: q2 A: a2\nU: q3 A: a3\nQ: q4" {
+		t.Fatalf("unexpected turn 4 prompt %q", got)
+	}
+	// Before turn 5, the four turns exceed the trigger: the first two are summarized
+	if !strings.Contains(prov.prompts[4], "User: q1\nAssistant: a1\nUser: q2\nAssistant: a2") {
+		t.Fatalf("unexpected summary prompt %q", prov.prompts[4])
+	}
+	if got := prov.prompts[5]; got != "SUMMARY: user asked q1 to q2\nU: q3 A: a3\nU: q4 A: a4\nQ: q5" {
+		t.Fatalf("unexpected turn 5 prompt %q", got)
+	}
+	// The summarized turns were compacted out of the session log
+	logs, _ := filepath.Glob(filepath.Join(root, "memory", "SupportBot", "users", "*", "conversations", "s-1.jsonl"))
+	if len(logs) != 1 {
+		t.Fatalf("expected one session log, got %v", logs)
+	}
+	if by, _ := os.ReadFile(logs[0]); strings.Count(string(by), "\n") != 4 {
+		t.Fatalf("expected the summary and three turns, got:\n%s", by)
+	}
+}
+
+// historyProvider answers after a delay and counts the history lines of each prompt.
+type historyProvider struct {
+	mu      sync.Mutex
+	history []int
+}
+
+func (p *historyProvider) Generate(_ context.Context, prompt string, _ runtimemodel.Params) (string, error) {
+	time.Sleep(10 * time.Millisecond)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.history = append(p.history, strings.Count(prompt, "U: "))
+	return "ok", nil
+}
+
+func TestConversation_ConcurrentRequestsOfASessionAreSerialized(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nmemory:\n  max_history: 10\n"
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := "{{ range .history }}U: {{ .Query }}\n{{ end }}Q: {{ .user_input }}"
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prov := &historyProvider{}
+	h := runtimeserver.NewHandlerWithProvider(root, prov)
+	var wg sync.WaitGroup
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"context":"SupportBot","component":"SupportBot","query":"q%d","session_id":"s-2","user_id":"u-1","data":{"user_input":"q%d"}}`, i, i)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader([]byte(body))))
+			if w.Code != http.StatusOK {
+				t.Errorf("request %d: expected 200, got %d: %s", i, w.Code, w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	// Each request saw every turn recorded before it
+	seen := map[int]bool{}
+	for _, n := range prov.history {
+		seen[n] = true
+	}
+	if len(prov.history) != 4 || len(seen) != 4 || !seen[0] || !seen[3] {
+		t.Fatalf("expected histories of 0 to 3 turns, got %v", prov.history)
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// scaffoldDispatchRoot adds a BillingBot component next to SupportBot and routes between them.
+func scaffoldDispatchRoot(t *testing.T, method string) string {
+	t.Helper()
+	root := scaffoldTempRoot(t)
+	for _, dir := range []string{"contexts/BillingBot", "prompts/BillingBot", "config"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		"contexts/BillingBot/billing_bot.ctx":  "name: BillingBot\nversion: '1.0.0'\nrole:\n  persona: 'billing'\n",
+		"prompts/BillingBot/agent_response.md": "BILLING",
+		"config/routes.yaml": "dispatcher:\n  method: " + method + "\n  default: support\nroutes:\n" +
+			"  - name: billing\n    component: BillingBot\n    description: Invoices and refunds\n    keywords: [invoice, refund]\n" +
+			"  - name: support\n    component: SupportBot\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func dispatchChat(t *testing.T, h http.Handler, body string) (*httptest.ResponseRecorder, runtimeserver.ChatResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader([]byte(body)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var got runtimeserver.ChatResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	return w, got
+}
+
+func TestDispatch_RoutesChatWithoutComponent(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldDispatchRoot(t, "keyword"), nil)
+	w, got := dispatchChat(t, h, `{"query":"I need a copy of my invoice"}`)
+	if w.Code != http.StatusOK || got.Rendered != "BILLING" || got.Route == nil || got.Route.Component != "BillingBot" {
+		t.Fatalf("unexpected response %d %+v", w.Code, got)
+	}
+	if w.Header().Get("X-Dispatch-Route") != "billing" {
+		t.Fatalf("missing route header: %v", w.Header())
+	}
+	w, got = dispatchChat(t, h, `{"context":"auto","query":"hello"}`)
+	if w.Code != http.StatusOK || got.Rendered != "TEMPLATE" || got.Route == nil || got.Route.Method != "default" {
+		t.Fatalf("unexpected default response %d %+v", w.Code, got)
+	}
+	// Requests naming a component are not dispatched
+	w, got = dispatchChat(t, h, `{"context":"SupportBot","component":"SupportBot","query":"refund"}`)
+	if w.Code != http.StatusOK || got.Rendered != "TEMPLATE" || got.Route != nil {
+		t.Fatalf("unexpected direct response %d %+v", w.Code, got)
+	}
+}
+
+func TestDispatch_ModelClassification(t *testing.T) {
+	prov := &seqProvider{outs: []string{"billing", "Your refund is on its way."}}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldDispatchRoot(t, "model"), prov)
+	w, got := dispatchChat(t, h, `{"context":"auto","query":"where is my money back?"}`)
+	if w.Code != http.StatusOK || got.Route == nil || got.Route.Route != "billing" || got.Route.Method != "model" {
+		t.Fatalf("unexpected response %d %+v", w.Code, got)
+	}
+	if len(prov.prompts) != 2 || prov.prompts[1] != "BILLING" {
+		t.Fatalf("unexpected prompts %q", prov.prompts)
+	}
+}
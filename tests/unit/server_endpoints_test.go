@@ -9,6 +9,7 @@ import (
     "os"
     "context"
 
+    "github.com/contexis-cmp/contexis/src/runtime/buildinfo"
     runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
     runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
 )
@@ -41,13 +42,16 @@ func TestVersion(t *testing.T) {
     if w.Code != http.StatusOK {
         t.Fatalf("expected 200, got %d", w.Code)
     }
-    var got map[string]string
+    var got buildinfo.Info
     if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
         t.Fatalf("invalid json: %v", err)
     }
-    if got["version"] == "" {
+    if got.Version == "" {
         t.Fatalf("missing version field")
     }
+    if got != buildinfo.Get() {
+        t.Fatalf("/version %+v differs from the build info %+v", got, buildinfo.Get())
+    }
 }
 
 func TestPIBlocking(t *testing.T) {
@@ -112,3 +116,50 @@ func TestCitationEnforcement(t *testing.T) {
 }
 
 
+func TestPIIContextOverride_BlocksQuery(t *testing.T) {
+    root := t.TempDir()
+    if err := os.MkdirAll(root+"/contexts/SupportBot", 0o755); err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(root+"/prompts/SupportBot", 0o755); err != nil { t.Fatal(err) }
+    ctxYAML := []byte("name: SupportBot\nversion: 1.0.0\nrole:\n  persona: test\nguardrails:\n  pii:\n    mode: block\n")
+    if err := os.WriteFile(root+"/contexts/SupportBot/supportbot.ctx", ctxYAML, 0o644); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(root+"/prompts/SupportBot/agent_response.md", []byte("ok"), 0o644); err != nil { t.Fatal(err) }
+    h := runtimeserver.NewHandlerWithProvider(root, nil)
+    body := []byte(`{"context":"SupportBot","query":"my email is jane@example.com","data":{}}`)
+    req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+    if w.Code != http.StatusUnprocessableEntity {
+        t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+    }
+}
+
+func TestCitations_ReturnsSources(t *testing.T) {
+    t.Setenv("CMP_REQUIRE_CITATION", "true")
+    root := t.TempDir()
+    if err := os.MkdirAll(root+"/contexts/SupportBot", 0o755); err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(root+"/prompts/SupportBot", 0o755); err != nil { t.Fatal(err) }
+    ctxYAML := []byte("name: SupportBot\nversion: 1.0.0\nrole:\n  persona: test\n")
+    if err := os.WriteFile(root+"/contexts/SupportBot/supportbot.ctx", ctxYAML, 0o644); err != nil { t.Fatal(err) }
+    tmpl := []byte("{{range .results}}- {{.Content}}\n{{end}}")
+    if err := os.WriteFile(root+"/prompts/SupportBot/agent_response.md", tmpl, 0o644); err != nil { t.Fatal(err) }
+    store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot", TenantID: "t1"})
+    if err != nil { t.Fatal(err) }
+    defer store.Close()
+    if _, err := store.IngestDocuments(context.Background(), []string{"returns within 30 days"}); err != nil { t.Fatal(err) }
+    h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "You can return items within 30 days [1]."})
+    body := []byte(`{"tenant_id":"t1","context":"SupportBot","component":"SupportBot","query":"returns","top_k":1,"data":{}}`)
+    req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, req)
+    if w.Code != http.StatusOK {
+        t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+    }
+    var got runtimeserver.ChatResponse
+    if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil { t.Fatal(err) }
+    if len(got.Sources) != 1 || !got.Sources[0].Cited || got.Sources[0].Index != 1 {
+        t.Fatalf("unexpected sources: %+v", got.Sources)
+    }
+    if got.Sources[0].End-got.Sources[0].Start != len("returns within 30 days") {
+        t.Fatalf("unexpected offsets: %+v", got.Sources[0])
+    }
+}
@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimeexperiment "github.com/contexis-cmp/contexis/src/runtime/experiment"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestChat_PromptExperimentAssignsStableVariant(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	variants := filepath.Join(root, "prompts", "SupportBot", "variants")
+	_ = os.MkdirAll(variants, 0o755)
+	_ = os.WriteFile(filepath.Join(variants, "friendly.md"), []byte("FRIENDLY"), 0o644)
+	cfg := "experiments:\n  - name: tone\n    component: SupportBot\n    variants:\n      - name: control\n        weight: 1\n      - name: friendly\n        weight: 1\n"
+	_ = os.MkdirAll(filepath.Join(root, "config"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, runtimeexperiment.ConfigFile), []byte(cfg), 0o644)
+	h := runtimeserver.NewHandlerWithProvider(root, nil)
+
+	served := map[string]string{}
+	for i := 0; i < 20; i++ {
+		session := fmt.Sprintf("s%d", i%5)
+		rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", SessionID: session})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var got runtimeserver.ChatResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		if got.Experiment == nil || got.Experiment.Experiment != "tone" || rr.Header().Get("X-Prompt-Variant") != "tone/"+got.Experiment.Variant {
+			t.Fatalf("missing assignment: %+v, header %q", got.Experiment, rr.Header().Get("X-Prompt-Variant"))
+		}
+		want := map[string]string{"control": "TEMPLATE", "friendly": "FRIENDLY"}[got.Experiment.Variant]
+		if got.Rendered != want {
+			t.Fatalf("variant %s rendered %q", got.Experiment.Variant, got.Rendered)
+		}
+		if prev, ok := served[session]; ok && prev != got.Experiment.Variant {
+			t.Fatalf("session %s moved from %s to %s", session, prev, got.Experiment.Variant)
+		}
+		served[session] = got.Experiment.Variant
+	}
+
+	outcomes, err := runtimeexperiment.NewLog(root).Query(runtimeexperiment.Filter{Experiment: "tone"})
+	if err != nil || len(outcomes) != 20 {
+		t.Fatalf("expected 20 outcomes, got %d, %v", len(outcomes), err)
+	}
+	total := 0
+	for _, s := range runtimeexperiment.Summarize(outcomes) {
+		if s.SuccessRate != 1 || s.Version == "" {
+			t.Fatalf("unexpected summary %+v", s)
+		}
+		total += s.Requests
+	}
+	if total != 20 {
+		t.Fatalf("summaries cover %d requests", total)
+	}
+
+	// Prompts outside the experiment are untouched
+	rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", PromptFile: "search_response.md"})
+	if rr.Header().Get("X-Prompt-Variant") != "" {
+		t.Fatalf("unexpected variant header %q", rr.Header().Get("X-Prompt-Variant"))
+	}
+}
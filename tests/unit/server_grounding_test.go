@@ -0,0 +1,92 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func scaffoldGroundingRoot(t *testing.T, grounding string) string {
+	t.Helper()
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nguardrails:\n  grounding:\n" + grounding
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := []byte("{{range .results}}- {{.Content}}\n{{end}}")
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), tmpl, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.IngestDocuments(context.Background(), []string{"refunds are issued within 14 days of the return"}); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func postGroundedChat(t *testing.T, h http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	body := []byte(`{"context":"SupportBot","component":"SupportBot","query":"refunds","top_k":1,"data":{}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGrounding_FlagsUngroundedAnswer(t *testing.T) {
+	root := scaffoldGroundingRoot(t, "    method: overlap\n")
+	audit := &recordingSink{}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "Refunds are issued within 14 days. Store credit never expires for members."}, runtimeserver.WithAuditSink(audit))
+	w := postGroundedChat(t, h)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got runtimeserver.ChatResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	g := got.Grounding
+	if g == nil || g.Grounded || g.Score != 0.5 || len(g.Claims) != 2 || !g.Claims[0].Supported || g.Claims[1].Supported {
+		t.Fatalf("unexpected grounding %+v", g)
+	}
+	found := false
+	for _, e := range audit.events {
+		if e.Reason == "ungrounded" && e.Result == "allowed" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected an ungrounded audit event, got %+v", audit.events)
+	}
+}
+
+func TestGrounding_BlocksUngroundedAnswer(t *testing.T) {
+	root := scaffoldGroundingRoot(t, "    action: block\n")
+	w := postGroundedChat(t, runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "Store credit never expires for members."}))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The judge method asks the same provider for a verdict
+	root = scaffoldGroundingRoot(t, "    method: judge\n    action: block\n")
+	prov := &seqProvider{outs: []string{"Refunds are issued within two weeks.", `{"claims": [{"claim": 1, "score": 0.9, "source": 1}]}`}}
+	w = postGroundedChat(t, runtimeserver.NewHandlerWithProvider(root, prov))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got runtimeserver.ChatResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Grounding == nil || !got.Grounding.Grounded || got.Grounding.Claims[0].Source != 1 || len(prov.prompts) != 2 {
+		t.Fatalf("unexpected grounding %+v", got.Grounding)
+	}
+}
@@ -0,0 +1,84 @@
+package unit
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	"github.com/contexis-cmp/contexis/src/runtime/server/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialGRPC(t *testing.T, root string) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := runtimeserver.NewGRPCServer(runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "GRPC OUT"}))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGRPC_ChatMemoryAndHealth(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.IngestDocuments(context.Background(), []string{"Returns within 30 days"}); err != nil {
+		t.Fatal(err)
+	}
+	conn := dialGRPC(t, root)
+	ctx := context.Background()
+
+	chat, err := pb.NewChatServiceClient(conn).Chat(ctx, &pb.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if chat.GetRendered() != "GRPC OUT" {
+		t.Fatalf("unexpected rendered %q", chat.GetRendered())
+	}
+	search, err := pb.NewMemoryServiceClient(conn).Search(ctx, &pb.MemorySearchRequest{Component: "SupportBot", Query: "returns", TopK: 1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(search.GetResults()) != 1 {
+		t.Fatalf("expected one result, got %v", search.GetResults())
+	}
+	health, err := pb.NewHealthServiceClient(conn).Check(ctx, &pb.HealthCheckRequest{})
+	if err != nil || health.GetStatus() != pb.HealthCheckResponse_SERVING {
+		t.Fatalf("Check = %v, %v", health, err)
+	}
+}
+
+func TestGRPC_SharesAuthMiddleware(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "tok1@acme:chat:execute")
+	conn := dialGRPC(t, scaffoldTempRoot(t))
+	client := pb.NewChatServiceClient(conn)
+	_, err := client.Chat(context.Background(), &pb.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer tok1")
+	if _, err := client.Chat(ctx, &pb.ChatRequest{TenantId: "acme", Context: "SupportBot", Component: "SupportBot"}); err != nil {
+		t.Fatalf("authorized Chat: %v", err)
+	}
+	_, err = pb.NewMemoryServiceClient(conn).Search(ctx, &pb.MemorySearchRequest{TenantId: "acme", Component: "SupportBot", Query: "x"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied without memory:read, got %v", err)
+	}
+}
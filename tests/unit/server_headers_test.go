@@ -0,0 +1,118 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestHTTPHeaders_CORSFromEnvironmentConfig(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	cfg := "server:\n  cors:\n    allowed_origins: [\"https://app.example.com\"]\n    allow_credentials: true\n    max_age: 600\n"
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "OK"})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/chat", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rr.Header().Get("Access-Control-Allow-Credentials") != "true" || rr.Header().Get("Access-Control-Max-Age") != "600" ||
+		rr.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("unexpected preflight response %d %v", rr.Code, rr.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1/chat", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected disallowed origin to be rejected, got %d %v", rr.Code, rr.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Fatalf("expected CORS headers on actual request, got %v", rr.Header())
+	}
+}
+
+func TestHTTPHeaders_RejectsCredentialsForAnyOrigin(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	cfg := "server:\n  cors:\n    allowed_origins: [\"*\"]\n    allow_credentials: true\n"
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtimeserver.LoadHTTPConfig(root); err == nil || !strings.Contains(err.Error(), "allow_credentials") {
+		t.Fatalf("expected credentials with \"*\" to be rejected, got %v", err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "OK"})
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" || rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("expected CORS to stay off, got %v", rr.Header())
+	}
+}
+
+func TestHTTPHeaders_DefaultAllowedHeaders(t *testing.T) {
+	t.Setenv("CMP_CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	// The shipped development config keeps the default allowed headers
+	root := scaffoldTempRoot(t)
+	by, err := os.ReadFile(filepath.Join("..", "..", "config", "environments", "development.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.MkdirAll(filepath.Join(root, "config", "environments"), 0o755)
+	if err := os.WriteFile(filepath.Join(root, "config", "environments", "development.yaml"), by, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "OK"})
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/chat", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	allowed := rr.Header().Get("Access-Control-Allow-Headers")
+	for _, want := range []string{"Idempotency-Key", "X-Priority", "X-Request-ID", "traceparent"} {
+		if !strings.Contains(allowed, want) {
+			t.Fatalf("expected %s in the allowed headers, got %q", want, allowed)
+		}
+	}
+}
+
+func TestHTTPHeaders_SecurityHeaders(t *testing.T) {
+	t.Setenv("CMP_HSTS_MAX_AGE", "3600")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" || rr.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatalf("missing security headers: %v", rr.Header())
+	}
+	if rr.Header().Get("Strict-Transport-Security") != "" || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("HSTS and CORS must not be sent over plain HTTP without config: %v", rr.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=3600; includeSubDomains" {
+		t.Fatalf("Strict-Transport-Security = %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func sendIdempotentChat(t *testing.T, h http.Handler, key, auth string, req runtimeserver.ChatRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	by, _ := json.Marshal(req)
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by))
+	r.Header.Set("Idempotency-Key", key)
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency_ReplaysChatResponses(t *testing.T) {
+	prov := &seqProvider{outs: []string{"first", "second", "third"}}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), prov)
+	req := runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "hi"}
+
+	first := sendIdempotentChat(t, h, "retry-1", "", req)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	replay := sendIdempotentChat(t, h, "retry-1", "", req)
+	if replay.Code != http.StatusOK || replay.Body.String() != first.Body.String() || replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected a replay, got %d %v: %s", replay.Code, replay.Header(), replay.Body.String())
+	}
+	if replay.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("replay lost the content type: %v", replay.Header())
+	}
+	if len(prov.prompts) != 1 {
+		t.Fatalf("expected one inference, got %d", len(prov.prompts))
+	}
+
+	other := req
+	other.Query = "something else"
+	if rr := sendIdempotentChat(t, h, "retry-1", "", other); rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a reused key, got %d", rr.Code)
+	}
+	// Keys of other callers do not collide
+	if rr := sendIdempotentChat(t, h, "retry-1", "Bearer someone-else", req); rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("replayed another caller's response: %d %s", rr.Code, rr.Body.String())
+	}
+	// Without a key every request runs
+	sendChat(t, h, req)
+	if len(prov.prompts) != 3 {
+		t.Fatalf("expected requests without a key to run, got %d inferences", len(prov.prompts))
+	}
+}
+
+func TestIdempotency_Disabled(t *testing.T) {
+	t.Setenv("CMP_IDEMPOTENCY_WINDOW", "0")
+	prov := &seqProvider{outs: []string{"first", "second"}}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), prov)
+	req := runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "hi"}
+	sendIdempotentChat(t, h, "k", "", req)
+	if rr := sendIdempotentChat(t, h, "k", "", req); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the request to run again, got %d %v", rr.Code, rr.Header())
+	}
+	if len(prov.prompts) != 2 {
+		t.Fatalf("expected two inferences, got %d", len(prov.prompts))
+	}
+}
@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	runtimejobs "github.com/contexis-cmp/contexis/src/runtime/jobs"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	runtimeworker "github.com/contexis-cmp/contexis/src/runtime/worker"
+)
+
+func TestJobsAPI_SubmitRunAndPoll(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "ops@:jobs:read|jobs:write|memory:write,jobsonly@:jobs:read|jobs:write,acmekey@acme:jobs:read|jobs:write|memory:write")
+	root := scaffoldTempRoot(t)
+	h := runtimeserver.NewHandlerWithProvider(root, nil)
+	payload, _ := json.Marshal(runtimejobs.MemoryIngestPayload{Component: "Docs", Documents: []runtimejobs.Document{
+		{ID: "faq/returns.md", Content: "Items can be returned within 30 days."},
+	}})
+	submit := runtimeserver.JobSubmitRequest{Type: runtimejobs.TypeMemoryIngest, Payload: payload}
+
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/jobs", "ops", runtimeserver.JobSubmitRequest{Type: "workflow.unknown"}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown type, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/jobs", "jobsonly", submit); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for ingest jobs without memory:write, got %d", rr.Code)
+	}
+	rr := adminRequest(t, h, http.MethodPost, "/api/v1/jobs", "acmekey", submit)
+	var job runtimejobs.Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil || rr.Code != http.StatusAccepted {
+		t.Fatalf("submit: %d %s", rr.Code, rr.Body.String())
+	}
+	if job.Status != runtimejobs.StatusQueued || job.TenantID != "acme" || job.Submitter == "" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	pool := &runtimeworker.Pool{Queue: runtimejobs.NewQueue(root), PollInterval: 5 * time.Millisecond,
+		Handlers: map[string]runtimeworker.Handler{runtimejobs.TypeMemoryIngest: runtimeworker.MemoryIngestHandler(root)}}
+	go func() {
+		_ = pool.Run(ctx)
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != runtimejobs.StatusSucceeded {
+		if time.Now().After(deadline) || job.Status == runtimejobs.StatusFailed {
+			t.Fatalf("job did not succeed: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		rr = adminRequest(t, h, http.MethodGet, "/api/v1/jobs/"+job.ID, "acmekey", nil)
+		_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	}
+	var result struct {
+		Added []string `json:"added"`
+	}
+	if err := json.Unmarshal(job.Result, &result); err != nil || len(result.Added) != 1 {
+		t.Fatalf("unexpected result %s", job.Result)
+	}
+
+	// Tenant-bound keys cannot see other tenants' jobs
+	other, _ := runtimejobs.NewQueue(root).Submit(runtimejobs.Job{Type: runtimejobs.TypeEvalRun, TenantID: "beta"})
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/jobs/"+other.ID, "acmekey", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another tenant's job, got %d", rr.Code)
+	}
+	rr = adminRequest(t, h, http.MethodGet, "/api/v1/jobs", "acmekey", nil)
+	var list runtimeserver.JobList
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Jobs) != 1 || list.Jobs[0].ID != job.ID {
+		t.Fatalf("unexpected tenant list %+v", list)
+	}
+
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/jobs/"+other.ID, "ops", nil); rr.Code != http.StatusOK {
+		t.Fatalf("cancel: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/jobs/"+job.ID, "ops", nil); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling a finished job, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/jobs/job_0000000000000000", "ops", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
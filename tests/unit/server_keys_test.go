@@ -0,0 +1,178 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func adminRequest(t *testing.T, h http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func chatAs(h http.Handler, token string) int {
+	by, _ := json.Marshal(runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestAdminKeys_Lifecycle(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "root@:admin:*,tenantadmin@beta:keys:admin")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+
+	rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "root", runtimesecurity.KeySpec{Name: "ci", TenantID: "acme", Roles: []string{"chat"}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	var created runtimeserver.CreatedKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Secret == "" || created.Key.Hash != "" || created.Key.Prefix == "" {
+		t.Fatalf("unexpected create response %+v", created)
+	}
+	if code := chatAs(h, created.Secret); code != http.StatusOK {
+		t.Fatalf("chat with new key: %d", code)
+	}
+
+	// Narrowing scopes takes effect immediately
+	rr = adminRequest(t, h, http.MethodPatch, "/api/v1/admin/keys/"+created.Key.KeyID, "root", map[string]interface{}{"roles": []string{"readonly"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	if code := chatAs(h, created.Secret); code != http.StatusForbidden {
+		t.Fatalf("expected 403 after scoping down, got %d", code)
+	}
+	_ = adminRequest(t, h, http.MethodPatch, "/api/v1/admin/keys/"+created.Key.KeyID, "root", map[string]interface{}{"roles": []string{"chat"}})
+
+	rr = adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys/"+created.Key.KeyID+"/rotate", "root", nil)
+	var rotated runtimeserver.CreatedKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &rotated)
+	if rr.Code != http.StatusOK || rotated.Secret == created.Secret {
+		t.Fatalf("rotate: %d %s", rr.Code, rr.Body.String())
+	}
+	if chatAs(h, created.Secret) != http.StatusUnauthorized || chatAs(h, rotated.Secret) != http.StatusOK {
+		t.Fatal("rotation did not replace the secret")
+	}
+
+	// Tenant admins neither see nor manage other tenants' keys
+	rr = adminRequest(t, h, http.MethodGet, "/api/v1/admin/keys", "tenantadmin", nil)
+	var list runtimeserver.KeyList
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if rr.Code != http.StatusOK || len(list.Keys) != 0 {
+		t.Fatalf("tenant admin list: %d %+v", rr.Code, list)
+	}
+	if rr = adminRequest(t, h, http.MethodDelete, "/api/v1/admin/keys/"+created.Key.KeyID, "tenantadmin", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 revoking another tenant's key, got %d", rr.Code)
+	}
+	if rr = adminRequest(t, h, http.MethodGet, "/api/v1/admin/keys", rotated.Secret, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for non-admin key, got %d", rr.Code)
+	}
+
+	if rr = adminRequest(t, h, http.MethodDelete, "/api/v1/admin/keys/"+created.Key.KeyID, "root", nil); rr.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", rr.Code, rr.Body.String())
+	}
+	if code := chatAs(h, rotated.Secret); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revoke, got %d", code)
+	}
+}
+
+func TestAdminKeys_DisabledWithoutAuth(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "", runtimesecurity.KeySpec{Roles: []string{"admin"}}); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected key management to be unavailable without auth, got %d", rr.Code)
+	}
+}
+
+func TestAdminKeys_GrantsLimitedToCallerScopes(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "root@:admin:*,keyadmin@beta:keys:admin|chat:execute,tenantroot@beta:admin:*,rotator@beta:keys:admin")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"}, runtimeserver.WithAuditSink(&recordingSink{}))
+
+	for _, spec := range []runtimesecurity.KeySpec{
+		{Name: "escalate", Roles: []string{"admin"}},
+		{Name: "wildcard", Scopes: []string{"*"}},
+		{Name: "wider", Roles: []string{"chat"}},
+	} {
+		if rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "keyadmin", spec); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d %s", spec.Name, rr.Code, rr.Body.String())
+		}
+	}
+	rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "keyadmin", runtimesecurity.KeySpec{Name: "bot", Scopes: []string{"chat:execute"}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("subset grant: %d %s", rr.Code, rr.Body.String())
+	}
+	var created runtimeserver.CreatedKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if rr := adminRequest(t, h, http.MethodPatch, "/api/v1/admin/keys/"+created.Key.KeyID, "keyadmin", map[string]interface{}{"roles": []string{"admin"}}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 widening a key, got %d", rr.Code)
+	}
+
+	// Tenant-bound admin:* keys still cannot hand out admin:*
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "tenantroot", runtimesecurity.KeySpec{Roles: []string{"admin"}}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a tenant-bound admin grant, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "tenantroot", runtimesecurity.KeySpec{Roles: []string{"operator"}}); rr.Code != http.StatusCreated {
+		t.Fatalf("tenant admin operator grant: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Rotating hands out the key's scopes, so it is held to the same rule
+	rr = adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "root", runtimesecurity.KeySpec{Name: "ops", TenantID: "beta", Roles: []string{"operator"}})
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys/"+created.Key.KeyID+"/rotate", "rotator", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 rotating a wider key, got %d", rr.Code)
+	}
+}
+
+func TestAdminKeys_ChangesLimitedToTargetScopes(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "root@:admin:*,keyadmin@beta:keys:admin|chat:execute,revoker@beta:keys:admin|chat:execute")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"}, runtimeserver.WithAuditSink(&recordingSink{}))
+
+	rr := adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "root", runtimesecurity.KeySpec{Name: "tenant-admin", TenantID: "beta", Roles: []string{"admin"}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	var admin runtimeserver.CreatedKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &admin)
+
+	// A keys admin cannot strip or revoke a key holding scopes it lacks
+	if rr := adminRequest(t, h, http.MethodPatch, "/api/v1/admin/keys/"+admin.Key.KeyID, "keyadmin", map[string]interface{}{"scopes": []string{"chat:execute"}, "roles": []string{}}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 stripping a wider key, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/admin/keys/"+admin.Key.KeyID, "keyadmin", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 revoking a wider key, got %d %s", rr.Code, rr.Body.String())
+	}
+	var kept runtimesecurity.APIKey
+	_ = json.Unmarshal(adminRequest(t, h, http.MethodGet, "/api/v1/admin/keys/"+admin.Key.KeyID, "root", nil).Body.Bytes(), &kept)
+	if kept.RevokedAt != nil || len(kept.Roles) != 1 || kept.Roles[0] != "admin" {
+		t.Fatalf("wider key changed: %+v", kept)
+	}
+
+	// Keys within the caller's scopes stay manageable
+	rr = adminRequest(t, h, http.MethodPost, "/api/v1/admin/keys", "revoker", runtimesecurity.KeySpec{Name: "bot", Scopes: []string{"chat:execute"}})
+	var bot runtimeserver.CreatedKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &bot)
+	if rr := adminRequest(t, h, http.MethodPatch, "/api/v1/admin/keys/"+bot.Key.KeyID, "revoker", map[string]interface{}{"name": "bot-2"}); rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/admin/keys/"+bot.Key.KeyID, "revoker", nil); rr.Code != http.StatusOK {
+		t.Fatalf("revoke: %d %s", rr.Code, rr.Body.String())
+	}
+}
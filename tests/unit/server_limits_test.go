@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// slowProvider blocks until the request context ends.
+type slowProvider struct{}
+
+func (slowProvider) Generate(ctx context.Context, _ string, _ runtimemodel.Params) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(5 * time.Second):
+		return "too late", nil
+	}
+}
+
+func TestLimits_RejectsOversizedBody(t *testing.T) {
+	t.Setenv("CMP_MAX_BODY_BYTES", "256")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+
+	body := `{"tenant_id":"t1","context":"SupportBot","component":"SupportBot","query":"` + strings.Repeat("x", 512) + `"}`
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := sendChat(t, h, runtimeserver.ChatRequest{TenantID: "t1", Context: "SupportBot", Component: "SupportBot"}); rr.Code != http.StatusOK {
+		t.Fatalf("small body rejected: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestLimits_RequestTimeoutReturns408(t *testing.T) {
+	t.Setenv("CMP_REQUEST_TIMEOUT", "50ms")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), slowProvider{})
+
+	start := time.Now()
+	rr := sendChat(t, h, runtimeserver.ChatRequest{TenantID: "t1", Context: "SupportBot", Component: "SupportBot", Query: "hi"})
+	if rr.Code != http.StatusRequestTimeout {
+		t.Fatalf("expected 408, got %d %s", rr.Code, rr.Body.String())
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("deadline was not propagated to inference")
+	}
+}
@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimesecurity "github.com/contexis-cmp/contexis/src/runtime/security"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// recordingSink keeps audit events in memory.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []runtimesecurity.AuditEvent
+}
+
+func (s *recordingSink) Write(e runtimesecurity.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestMemoryAPI_IngestSearchDelete(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "writer@:memory:read|memory:write,reader@:memory:read,acmekey@acme:memory:read|memory:write")
+	root := scaffoldTempRoot(t)
+	h := runtimeserver.NewHandlerWithProvider(root, nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	ingest := runtimeserver.MemoryIngestRequest{Documents: []runtimeserver.MemoryDocument{
+		{ID: "faq/returns.md", Content: "Items can be returned within 30 days of delivery."},
+		{ID: "faq/shipping.md", Content: "Orders ship within two business days."},
+	}}
+
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/documents", "reader", ingest); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without memory:write, got %d", rr.Code)
+	}
+	bad := runtimeserver.MemoryIngestRequest{Documents: []runtimeserver.MemoryDocument{{ID: "../escape.md", Content: "x"}}}
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/documents", "writer", bad); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsafe id, got %d", rr.Code)
+	}
+	rr := adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/documents", "writer", ingest)
+	var res runtimememory.IngestResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("ingest: %d %s", rr.Code, rr.Body.String())
+	}
+	if len(res.Added) != 2 || res.Version == "" {
+		t.Fatalf("unexpected ingest result %+v", res)
+	}
+
+	search := runtimeserver.MemorySearchRequest{Query: "return policy", TopK: 1}
+	rr = adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/search", "reader", search)
+	var found runtimeserver.MemorySearchResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &found)
+	if rr.Code != http.StatusOK || len(found.Results) != 1 {
+		t.Fatalf("search: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Tenant-bound keys write to and search their own tenant's store only
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/documents", "acmekey", runtimeserver.MemoryIngestRequest{TenantID: "beta", Documents: ingest.Documents}); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another tenant, got %d", rr.Code)
+	}
+	rr = adminRequest(t, h, http.MethodPost, "/api/v1/memory/Docs/search", "acmekey", search)
+	_ = json.Unmarshal(rr.Body.Bytes(), &found)
+	if rr.Code != http.StatusOK || len(found.Results) != 0 {
+		t.Fatalf("tenant search saw the global store: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/memory/Docs/documents/faq/returns.md", "reader", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without memory:write, got %d", rr.Code)
+	}
+	rr = adminRequest(t, h, http.MethodDelete, "/api/v1/memory/Docs/documents/faq/returns.md", "writer", nil)
+	res = runtimememory.IngestResult{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if rr.Code != http.StatusOK || len(res.Removed) != 1 {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/memory/Docs/documents/faq/returns.md", "writer", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting a missing document, got %d", rr.Code)
+	}
+}
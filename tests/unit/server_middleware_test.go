@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// recordingMiddleware rejects blocked queries, tags prompts and uppercases answers.
+type recordingMiddleware struct {
+	runtimeserver.BaseMiddleware
+	prompts []string
+}
+
+func (m *recordingMiddleware) OnRequest(_ context.Context, req *runtimeserver.ChatRequest) error {
+	if strings.Contains(req.Query, "forbidden") {
+		return runtimeserver.Reject(http.StatusForbidden, "blocked by moderation")
+	}
+	return nil
+}
+
+func (m *recordingMiddleware) OnPromptRendered(_ context.Context, _ *runtimeserver.ChatRequest, prompt string) (string, error) {
+	prompt += "\n[tagged]"
+	m.prompts = append(m.prompts, prompt)
+	return prompt, nil
+}
+
+func (m *recordingMiddleware) OnModelResponse(_ context.Context, _ *runtimeserver.ChatRequest, response string) (string, error) {
+	return strings.ToUpper(response), nil
+}
+
+func sendChat(t *testing.T, h http.Handler, req runtimeserver.ChatRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	by, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by)))
+	return w
+}
+
+func TestMiddleware_HooksRunInPipeline(t *testing.T) {
+	mw := &recordingMiddleware{}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "hello"}, runtimeserver.WithMiddleware(mw))
+
+	rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "hi"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"rendered":"HELLO"`) {
+		t.Fatalf("response not transformed: %s", rr.Body.String())
+	}
+	if len(mw.prompts) != 1 || mw.prompts[0] != "TEMPLATE\n[tagged]" {
+		t.Fatalf("unexpected prompts %q", mw.prompts)
+	}
+
+	rr = sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "something forbidden"})
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "blocked by moderation") {
+		t.Fatalf("expected 403 from middleware, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
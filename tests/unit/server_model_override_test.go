@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestModelOverride_PolicyAndEffectiveModel(t *testing.T) {
+	var mu sync.Mutex
+	var lastPath, lastBody string
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		by, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		lastPath, lastBody = r.URL.Path, string(by)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`[{"generated_text":"ANSWER"}]`))
+	}))
+	defer hf.Close()
+	t.Setenv("OVERRIDE_TEST_TOKEN", "tok")
+
+	root := scaffoldTempRoot(t)
+	writeFile(t, filepath.Join(root, "config", "providers", "routing.yaml"),
+		"providers:\n  hf:\n    type: huggingface\n    model: org/small\n    endpoint: "+hf.URL+"\n    token_env: OVERRIDE_TEST_TOKEN\ndefault: [hf]\n")
+	temp := 0.2
+	largeReq := runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Model: "org/large", Params: &runtimeserver.ModelParams{Temperature: &temp}}
+
+	// Without a policy every override is denied
+	h := runtimeserver.NewHandlerWithProvider(root, nil, runtimeserver.WithAuditSink(&recordingSink{}))
+	if rr := sendChat(t, h, largeReq); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a policy, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	var resp runtimeserver.ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Model == nil || resp.Model.Model != "org/small" {
+		t.Fatalf("expected the routed model in the response, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	writeFile(t, filepath.Join(root, runtimeserver.ModelOverridesFile),
+		"roles:\n  anonymous:\n    providers: [hf]\n    models: [org/large]\n    params: [temperature, max_tokens]\n    max_tokens: 64\n")
+	sink := &recordingSink{}
+	h = runtimeserver.NewHandlerWithProvider(root, nil, runtimeserver.WithAuditSink(sink))
+	rr = sendChat(t, h, largeReq)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	resp = runtimeserver.ChatResponse{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Model == nil || resp.Model.Provider != "hf" || resp.Model.Model != "org/large" {
+		t.Fatalf("unexpected effective model %+v", resp.Model)
+	}
+	mu.Lock()
+	if lastPath != "/org/large" || !strings.Contains(lastBody, `"temperature":0.2`) {
+		t.Fatalf("override not sent to the provider: %s %s", lastPath, lastBody)
+	}
+	mu.Unlock()
+
+	for name, req := range map[string]runtimeserver.ChatRequest{
+		"model":      {Context: "SupportBot", Component: "SupportBot", Model: "org/huge"},
+		"param":      {Context: "SupportBot", Component: "SupportBot", Params: &runtimeserver.ModelParams{TopP: &temp}},
+		"max_tokens": {Context: "SupportBot", Component: "SupportBot", Params: &runtimeserver.ModelParams{MaxTokens: 512}},
+	} {
+		if rr := sendChat(t, h, req); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d: %s", name, rr.Code, rr.Body.String())
+		}
+	}
+	denied := 0
+	sink.mu.Lock()
+	for _, ev := range sink.events {
+		if ev.Reason == "model_override" {
+			denied++
+		}
+	}
+	sink.mu.Unlock()
+	if denied != 3 {
+		t.Fatalf("expected 3 audited denials, got %d", denied)
+	}
+
+	hot := 5.0
+	if rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Params: &runtimeserver.ModelParams{Temperature: &hot}}); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid temperature, got %d", rr.Code)
+	}
+}
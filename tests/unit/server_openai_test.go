@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func openAIRequest(t *testing.T, h http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOpenAICompat_ChatCompletions(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	writeFile(t, filepath.Join(root, runtimeserver.OpenAICompatFile), "models:\n  - name: support-bot\n    component: SupportBot\n")
+	h := runtimeserver.NewHandlerWithProvider(root, streamProvider{tokens: []string{"Hel", "lo"}})
+
+	rr := openAIRequest(t, h, `{"model":"support-bot","messages":[{"role":"system","content":"be brief"},{"role":"user","content":[{"type":"text","text":"hi"}]}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var c struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message      struct{ Role, Content string } `json:"message"`
+			FinishReason string                         `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(c.ID, "chatcmpl-") || c.Object != "chat.completion" || c.Model != "support-bot" || len(c.Choices) != 1 ||
+		c.Choices[0].Message.Role != "assistant" || c.Choices[0].Message.Content != "Hello" || c.Choices[0].FinishReason != "stop" || c.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected completion %s", rr.Body.String())
+	}
+
+	// Streaming: one chunk per token, then the finish chunk, usage and [DONE]
+	rr = openAIRequest(t, h, `{"model":"support-bot","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	var events []string
+	for _, line := range strings.Split(rr.Body.String(), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			events = append(events, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(events) != 6 || events[5] != "[DONE]" {
+		t.Fatalf("unexpected events %q", events)
+	}
+	var content strings.Builder
+	for i, ev := range events[:5] {
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta        struct{ Role, Content string } `json:"delta"`
+				FinishReason *string                        `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *struct {
+				TotalTokens int `json:"total_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(ev), &chunk); err != nil || chunk.Object != "chat.completion.chunk" {
+			t.Fatalf("chunk %d: %s, %v", i, ev, err)
+		}
+		switch i {
+		case 0:
+			if chunk.Choices[0].Delta.Role != "assistant" {
+				t.Fatalf("expected the role first, got %s", ev)
+			}
+		case 3:
+			if chunk.Choices[0].FinishReason == nil || *chunk.Choices[0].FinishReason != "stop" {
+				t.Fatalf("expected the finish chunk, got %s", ev)
+			}
+		case 4:
+			if len(chunk.Choices) != 0 || chunk.Usage == nil || chunk.Usage.TotalTokens == 0 {
+				t.Fatalf("expected the usage chunk, got %s", ev)
+			}
+		default:
+			content.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if content.String() != "Hello" {
+		t.Fatalf("streamed %q", content.String())
+	}
+
+	for body, want := range map[string]int{
+		`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`:                                      http.StatusNotFound,
+		`{"model":"support-bot","messages":[{"role":"system","content":"hi"}]}`:                               http.StatusBadRequest,
+		`{"model":"support-bot","n":2,"messages":[{"role":"user","content":"hi"}]}`:                           http.StatusBadRequest,
+		`{"model":"support-bot","stream":true,"messages":[{"role":"user","content":[{"type":"image_url"}]}]}`: http.StatusBadRequest,
+	} {
+		rr := openAIRequest(t, h, body)
+		var e struct {
+			Error struct{ Message, Type string } `json:"error"`
+		}
+		if rr.Code != want || json.Unmarshal(rr.Body.Bytes(), &e) != nil || e.Error.Message == "" {
+			t.Fatalf("%s: expected an OpenAI error with %d, got %d: %s", body, want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"id":"support-bot"`) {
+		t.Fatalf("models = %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestOpenAICompat_DisabledWithoutMapping(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), nil)
+	if rr := openAIRequest(t, h, `{"model":"support-bot","messages":[{"role":"user","content":"hi"}]}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected no endpoint, got %d", rr.Code)
+	}
+}
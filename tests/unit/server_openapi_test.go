@@ -0,0 +1,54 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestOpenAPI_ServedFromGoTypes(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("openapi.json returned %d", rr.Code)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Paths["/api/v1/chat"]["post"].OperationID != "chat" || doc.Paths["/api/v1/memory/{component}/search"]["post"].OperationID != "searchMemory" {
+		t.Fatalf("unexpected document %+v", doc.Paths["/api/v1/chat"])
+	}
+	req, resp := doc.Components.Schemas["ChatRequest"], doc.Components.Schemas["ChatResponse"]
+	if req.Properties["top_k"]["type"] != "integer" || len(req.Required) != 0 {
+		t.Fatalf("request fields should be optional: %+v", req)
+	}
+	if strings.Join(resp.Required, ",") != "rendered" || resp.Properties["sources"]["type"] != "array" {
+		t.Fatalf("unexpected ChatResponse schema %+v", resp)
+	}
+	if _, ok := doc.Components.Schemas["ApprovalRequest"]; !ok {
+		t.Fatal("generic type names should be prefixed with their package")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `url: "openapi.json"`) {
+		t.Fatalf("swagger UI not served: %d", rr.Code)
+	}
+}
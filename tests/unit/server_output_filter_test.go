@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func scaffoldFilterRoot(t *testing.T, filters string) string {
+	t.Helper()
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nguardrails:\n  output_filters:\n" + filters
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestOutputFilters_RedactAndAnnotate(t *testing.T) {
+	root := scaffoldFilterRoot(t, `    - name: profanity
+      type: deny_list
+      words: [darn]
+      action: redact
+    - name: competitors
+      type: regex
+      patterns: ['acme\s+corp']
+      action: annotate
+`)
+	audit := &recordingSink{}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "Darn, try Acme Corp instead."}, runtimeserver.WithAuditSink(audit))
+	w := postChat(t, h)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got runtimeserver.ChatResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Rendered != "[filtered], try Acme Corp instead." {
+		t.Fatalf("unexpected rendered %q", got.Rendered)
+	}
+	if len(got.Filters) != 2 || got.Filters[0].Action != "redact" || got.Filters[1].Filter != "competitors" {
+		t.Fatalf("unexpected filter hits %+v", got.Filters)
+	}
+	hits := 0
+	for _, e := range audit.events {
+		if e.Reason == "output_filtered" {
+			hits++
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 output_filtered audit events, got %+v", audit.events)
+	}
+}
+
+func TestOutputFilters_Block(t *testing.T) {
+	root := scaffoldFilterRoot(t, "    - name: profanity\n      type: deny_list\n      words: [darn]\n")
+	w := postChat(t, runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "well, darn"}))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
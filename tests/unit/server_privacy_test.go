@@ -0,0 +1,62 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	runtimeprivacy "github.com/contexis-cmp/contexis/src/runtime/privacy"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestPrivacy_ExportAndDeleteUser(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "dpo@acme:privacy:read|privacy:write|chat:execute,reader@acme:privacy:read")
+	t.Setenv("CMP_TRANSCRIPTS", "true")
+	audit := &recordingSink{}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "Returns take 30 days."}, runtimeserver.WithAuditSink(audit))
+
+	chat := runtimeserver.ChatRequest{TenantID: "acme", UserID: "u-1", Context: "SupportBot", Component: "SupportBot", Query: "refund?"}
+	if rr := adminRequest(t, h, http.MethodPost, "/api/v1/chat", "dpo", chat); rr.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr := adminRequest(t, h, http.MethodGet, "/api/v1/users/u-1/export", "reader", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: %d %s", rr.Code, rr.Body.String())
+	}
+	var export runtimeprivacy.Export
+	_ = json.Unmarshal(rr.Body.Bytes(), &export)
+	if export.TenantID != "acme" || len(export.Transcripts) != 1 || export.Transcripts[0].Response != "Returns take 30 days." {
+		t.Fatalf("unexpected export %+v", export)
+	}
+
+	if rr := adminRequest(t, h, http.MethodDelete, "/api/v1/users/u-1/data", "reader", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("delete without privacy:write: %d", rr.Code)
+	}
+	rr = adminRequest(t, h, http.MethodDelete, "/api/v1/users/u-1/data", "dpo", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	var deletion runtimeprivacy.Deletion
+	_ = json.Unmarshal(rr.Body.Bytes(), &deletion)
+	if deletion.Turns != 1 {
+		t.Fatalf("unexpected deletion %+v", deletion)
+	}
+	rr = adminRequest(t, h, http.MethodGet, "/api/v1/users/u-1/export", "dpo", nil)
+	_ = json.Unmarshal(rr.Body.Bytes(), &export)
+	if len(export.Transcripts) != 0 {
+		t.Fatalf("transcript left after deletion: %+v", export.Transcripts)
+	}
+
+	found := false
+	for _, e := range audit.events {
+		found = found || (e.Action == "privacy:delete" && e.Attributes["user_id"] == "u-1")
+	}
+	if !found {
+		t.Fatalf("deletion not audited: %+v", audit.events)
+	}
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/users/..%2Fx/export", "dpo", nil); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsafe user id, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestQuota_RequestBudgetReturns429(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	if err := os.MkdirAll(filepath.Join(root, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config", "budgets.yaml"), []byte("tenants:\n  acme: {monthly_requests: 2}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "ok"})
+	req := runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot"}
+
+	for want := 1; want >= 0; want-- {
+		rr := sendChat(t, h, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("X-Quota-Remaining-Requests"); got != strconv.Itoa(want) {
+			t.Fatalf("remaining requests header = %q, want %d", got, want)
+		}
+		if rr.Header().Get("X-Quota-Reset") == "" {
+			t.Fatal("missing X-Quota-Reset header")
+		}
+	}
+	rr := sendChat(t, h, req)
+	if rr.Code != http.StatusTooManyRequests || !strings.Contains(rr.Body.String(), `"error":"quota_exceeded"`) {
+		t.Fatalf("expected 429 quota_exceeded, got %d %s", rr.Code, rr.Body.String())
+	}
+	// other tenants are unaffected
+	req.TenantID = "beta"
+	if rr := sendChat(t, h, req); rr.Code != http.StatusOK {
+		t.Fatalf("other tenant: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestQuota_ExceededNotifiesOncePerCooldown(t *testing.T) {
+	var mu sync.Mutex
+	var texts []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&m)
+		mu.Lock()
+		texts = append(texts, m["text"])
+		mu.Unlock()
+	}))
+	defer hook.Close()
+	root := scaffoldTempRoot(t)
+	if err := os.MkdirAll(filepath.Join(root, "config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config", "budgets.yaml"), []byte("tenants:\n  acme: {monthly_requests: 1}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SLACK_URL", hook.URL)
+	notifications := "channels:\n  - type: slack\n    url: ${TEST_SLACK_URL}\n    events: [budget.exceeded]\n"
+	if err := os.WriteFile(filepath.Join(root, "config", "notifications.yaml"), []byte(notifications), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "ok"})
+	req := runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot"}
+	if rr := sendChat(t, h, req); rr.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+	}
+	for i := 0; i < 3; i++ {
+		if rr := sendChat(t, h, req); rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", rr.Code)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(texts)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Give stray notifications a moment to arrive before counting
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 1 || !strings.Contains(texts[0], "Budget exhausted for tenant acme") {
+		t.Fatalf("notifications = %q", texts)
+	}
+}
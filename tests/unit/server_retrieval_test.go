@@ -0,0 +1,70 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestChatRetrieval_ContextDeclaresTopKAndFilters(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nretrieval:\n  top_k: 1\n  hybrid: false\n"
+	ctxPath := filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx")
+	if err := os.WriteFile(ctxPath, []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "prompts", "SupportBot", "agent_response.md"), []byte("{{range .results}}- {{.Content}}\n{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.IngestDocuments(context.Background(), []string{
+		"refunds are issued within 14 days of the return",
+		"Die Rückerstattung erfolgt innerhalb von 14 Tagen nach der Rücksendung",
+		"shipping takes three days",
+	})
+	store.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := func(body string) runtimeserver.ChatResponse {
+		t.Helper()
+		h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "ok"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("chat returned %d: %s", rr.Code, rr.Body.String())
+		}
+		var got runtimeserver.ChatResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &got)
+		return got
+	}
+
+	// retrieval.top_k applies when the request sets none; the request's wins
+	if got := chat(`{"context":"SupportBot","component":"SupportBot","query":"refunds"}`); len(got.Sources) != 1 {
+		t.Fatalf("expected the context's top_k of 1, got %d sources", len(got.Sources))
+	}
+	if got := chat(`{"context":"SupportBot","component":"SupportBot","query":"refunds","top_k":3}`); len(got.Sources) != 3 {
+		t.Fatalf("expected the request's top_k of 3, got %d sources", len(got.Sources))
+	}
+
+	ctxYAML += "  filters:\n    language: de\n"
+	if err := os.WriteFile(ctxPath, []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := chat(`{"context":"SupportBot","component":"SupportBot","query":"refunds","top_k":3}`)
+	if len(got.Sources) != 1 || !strings.HasSuffix(got.Sources[0].ID, "_1") {
+		t.Fatalf("expected only the German chunk, got %+v", got.Sources)
+	}
+}
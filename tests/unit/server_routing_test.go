@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestModelRouting_RoutesComponentAndFallsBack(t *testing.T) {
+	failing := false
+	hf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"generated_text":"FROM ROUTED MODEL"}]`))
+	}))
+	defer hf.Close()
+	t.Setenv("ROUTING_TEST_TOKEN", "tok")
+
+	root := scaffoldTempRoot(t)
+	for _, dir := range []string{"config/providers", "prompts/Other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "prompts", "Other", "agent_response.md"), []byte("OTHER"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	yml := "providers:\n  primary:\n    type: huggingface\n    model: org/support-model\n    endpoint: " + hf.URL + "\n    token_env: ROUTING_TEST_TOKEN\nroutes:\n  - component: SupportBot\n    providers: [primary, default]\n"
+	if err := os.WriteFile(filepath.Join(root, "config", "providers", "routing.yaml"), []byte(yml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "FROM DEFAULT"})
+
+	rr := sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "FROM ROUTED MODEL") {
+		t.Fatalf("expected routed model answer, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// Other components use the default chain (environment provider)
+	rr = sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "Other"})
+	if !strings.Contains(rr.Body.String(), "FROM DEFAULT") {
+		t.Fatalf("expected default provider answer, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	failing = true
+	rr = sendChat(t, h, runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "FROM DEFAULT") {
+		t.Fatalf("expected fallback answer, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
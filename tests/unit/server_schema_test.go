@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// seqProvider returns its outputs in order and records the prompts and params it received
+type seqProvider struct {
+	outs    []string
+	prompts []string
+	params  []runtimemodel.Params
+}
+
+func (p *seqProvider) Generate(_ context.Context, prompt string, params runtimemodel.Params) (string, error) {
+	p.prompts = append(p.prompts, prompt)
+	p.params = append(p.params, params)
+	out := p.outs[0]
+	if len(p.outs) > 1 {
+		p.outs = p.outs[1:]
+	}
+	return out, nil
+}
+
+func scaffoldSchemaRoot(t *testing.T, maxRepairs string) string {
+	t.Helper()
+	root := scaffoldTempRoot(t)
+	ctxYAML := "name: SupportBot\nversion: '1.0.0'\nrole:\n  persona: 'helper'\nguardrails:\n  format: json\n  response:\n" +
+		maxRepairs +
+		"    schema:\n      type: object\n      required: [answer]\n      properties:\n        answer: {type: string}\n"
+	if err := os.WriteFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), []byte(ctxYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func postChat(t *testing.T, h http.Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	by, _ := json.Marshal(runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseSchema_RepairsInvalidOutput(t *testing.T) {
+	prov := &seqProvider{outs: []string{"sure thing!", `{"answer": "ok"}`}}
+	w := postChat(t, runtimeserver.NewHandlerWithProvider(scaffoldSchemaRoot(t, ""), prov))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got runtimeserver.ChatResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Rendered != `{"answer":"ok"}` {
+		t.Fatalf("unexpected rendered %q", got.Rendered)
+	}
+	if len(prov.prompts) != 2 || !strings.Contains(prov.prompts[1], "invalid json") {
+		t.Fatalf("expected one repair prompt with violations, got %q", prov.prompts)
+	}
+}
+
+func TestResponseSchema_RepairUsesRequestParams(t *testing.T) {
+	root := scaffoldSchemaRoot(t, "")
+	writeFile(t, filepath.Join(root, runtimeserver.ModelOverridesFile),
+		"roles:\n  anonymous:\n    params: [temperature, max_tokens]\n")
+	prov := &seqProvider{outs: []string{"sure thing!", `{"answer": "ok"}`}}
+	temp := 0.3
+	rr := sendChat(t, runtimeserver.NewHandlerWithProvider(root, prov), runtimeserver.ChatRequest{
+		Context: "SupportBot", Component: "SupportBot", Params: &runtimeserver.ModelParams{Temperature: &temp, MaxTokens: 1200},
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(prov.params) != 2 || prov.params[1].MaxNewTokens != 1200 || prov.params[1].Temperature != 0.3 {
+		t.Fatalf("repair did not use the request params: %+v", prov.params)
+	}
+}
+
+func TestResponseSchema_StructuredErrorAfterRepairs(t *testing.T) {
+	prov := &seqProvider{outs: []string{`{"answer": 1}`}}
+	w := postChat(t, runtimeserver.NewHandlerWithProvider(scaffoldSchemaRoot(t, "    max_repairs: 1\n"), prov))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+	var body struct {
+		Error      string   `json:"error"`
+		Violations []string `json:"violations"`
+		Repairs    int      `json:"repairs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error != "response_schema_violation" || body.Repairs != 1 || len(body.Violations) == 0 {
+		t.Fatalf("unexpected error body %+v", body)
+	}
+}
@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+func TestSessions_Transcript(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "app@acme:chat:execute,ops@acme:sessions:read,other@globex:sessions:read")
+	t.Setenv("CMP_TRANSCRIPTS", "true")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "Returns take 30 days."})
+
+	for _, q := range []string{"refund?", "and exchanges?"} {
+		chat := runtimeserver.ChatRequest{TenantID: "acme", SessionID: "s-42", Context: "SupportBot", Component: "SupportBot", Query: q}
+		if rr := adminRequest(t, h, http.MethodPost, "/api/v1/chat", "app", chat); rr.Code != http.StatusOK {
+			t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/sessions/s-42/transcript", "app", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("transcript without sessions:read: %d", rr.Code)
+	}
+	rr := adminRequest(t, h, http.MethodGet, "/api/v1/sessions/s-42/transcript", "ops", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("transcript: %d %s", rr.Code, rr.Body.String())
+	}
+	var tr runtimeserver.SessionTranscript
+	_ = json.Unmarshal(rr.Body.Bytes(), &tr)
+	if tr.TenantID != "acme" || len(tr.Turns) != 2 || tr.Turns[1].Query != "and exchanges?" ||
+		tr.Turns[0].Response != "Returns take 30 days." || len(tr.Turns[0].PromptHash) != 64 || tr.Turns[0].RequestID == "" {
+		t.Fatalf("unexpected transcript %+v", tr)
+	}
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/sessions/s-42/transcript?tenant_id=acme", "other", nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 across tenants, got %d", rr.Code)
+	}
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/sessions/s-42/transcript", "other", nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 in the key's own tenant, got %d", rr.Code)
+	}
+
+	// Without a session ID the turn is recorded under the request ID.
+	by, _ := json.Marshal(runtimeserver.ChatRequest{TenantID: "acme", Context: "SupportBot", Component: "SupportBot", Query: "hi"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(by))
+	req.Header.Set("Authorization", "Bearer app")
+	req.Header.Set("X-Request-ID", "req-7")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if rr := adminRequest(t, h, http.MethodGet, "/api/v1/sessions/req-7/transcript", "ops", nil); rr.Code != http.StatusOK {
+		t.Fatalf("request-id transcript: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+)
+
+// idProvider answers with the request ID it sees in its context.
+type idProvider struct{}
+
+func (idProvider) Generate(ctx context.Context, _ string, _ runtimemodel.Params) (string, error) {
+	id, _ := ctx.Value("request_id").(string)
+	return "id=" + id, nil
+}
+
+func chatWithHeaders(t *testing.T, h http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "hi"})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/chat", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+	}
+	return rr
+}
+
+func TestRequestID_ReturnedAndPropagated(t *testing.T) {
+	audit := &recordingSink{}
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), idProvider{}, runtimeserver.WithAuditSink(audit))
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"client id", map[string]string{"X-Request-ID": "client-123"}, "client-123"},
+		{"traceparent", map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"unsafe id replaced", map[string]string{"X-Request-ID": "bad id\nforged"}, ""},
+		{"generated", nil, ""},
+	}
+	for _, c := range cases {
+		rr := chatWithHeaders(t, h, c.headers)
+		id := rr.Header().Get("X-Request-ID")
+		if id == "" || (c.want != "" && id != c.want) || strings.ContainsAny(id, " \n") {
+			t.Fatalf("%s: X-Request-ID = %q, want %q", c.name, id, c.want)
+		}
+		var resp runtimeserver.ChatResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.Rendered != "id="+id {
+			t.Fatalf("%s: provider saw %q, response header %q", c.name, resp.Rendered, id)
+		}
+	}
+
+	// Errors carry the ID too
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/unknown", nil))
+	if rr.Header().Get("X-Request-ID") == "" {
+		t.Fatalf("missing X-Request-ID on %d response", rr.Code)
+	}
+
+	// The audit trail records the same ID
+	found := false
+	for _, e := range audit.events {
+		found = found || e.RequestID == "client-123"
+	}
+	if !found {
+		t.Fatalf("no audit event for the client request ID: %+v", audit.events)
+	}
+}
+
+func TestRequestID_ExposedToBrowsers(t *testing.T) {
+	t.Setenv("CMP_CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Fatalf("X-Request-ID not exposed: %v", rr.Header())
+	}
+}
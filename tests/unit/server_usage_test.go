@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	runtimeusage "github.com/contexis-cmp/contexis/src/runtime/usage"
+)
+
+func TestUsage_RecordedAndReported(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	h := runtimeserver.NewHandlerWithProvider(root, fakeProvider{out: "an answer"})
+	for _, tenant := range []string{"acme", "acme", "beta"} {
+		rr := sendChat(t, h, runtimeserver.ChatRequest{TenantID: tenant, Context: "SupportBot", Component: "SupportBot"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("chat: %d %s", rr.Code, rr.Body.String())
+		}
+		var resp runtimeserver.ChatResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Usage == nil || resp.Usage.PromptTokens == 0 || resp.Usage.CompletionTokens == 0 || !resp.Usage.Estimated {
+			t.Fatalf("expected estimated usage in response, got %+v", resp.Usage)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/usage?tenant_id=acme", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("usage: %d %s", rr.Code, rr.Body.String())
+	}
+	var report runtimeusage.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].TenantID != "acme" || report.Rows[0].Component != "SupportBot" || report.Total.Requests != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/usage?from=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid date, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	runtimememory "github.com/contexis-cmp/contexis/src/runtime/memory"
+	runtimemodel "github.com/contexis-cmp/contexis/src/runtime/model"
+	runtimeserver "github.com/contexis-cmp/contexis/src/runtime/server"
+	"github.com/gorilla/websocket"
+)
+
+// streamProvider emits its tokens one by one through GenerateStream.
+type streamProvider struct{ tokens []string }
+
+func (p streamProvider) Generate(_ context.Context, _ string, _ runtimemodel.Params) (string, error) {
+	return strings.Join(p.tokens, ""), nil
+}
+
+func (p streamProvider) GenerateStream(_ context.Context, _ string, _ runtimemodel.Params, onToken func(string) error) (string, error) {
+	for _, tok := range p.tokens {
+		if err := onToken(tok); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(p.tokens, ""), nil
+}
+
+func dialChatSocket(t *testing.T, h http.Handler, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/chat/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readUntilDone collects events for one message until done or error.
+func readUntilDone(t *testing.T, conn *websocket.Conn) []runtimeserver.ChatEvent {
+	t.Helper()
+	var events []runtimeserver.ChatEvent
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var ev runtimeserver.ChatEvent
+		if err := conn.ReadJSON(&ev); err != nil {
+			t.Fatalf("read event: %v (got %v)", err, events)
+		}
+		events = append(events, ev)
+		if ev.Type == runtimeserver.EventDone || ev.Type == runtimeserver.EventError {
+			return events
+		}
+	}
+}
+
+func TestChatWebSocket_StreamsTokensAndToolEvents(t *testing.T) {
+	root := scaffoldTempRoot(t)
+	store, err := runtimememory.NewStore(runtimememory.Config{Provider: "sqlite", RootDir: root, ComponentName: "SupportBot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.IngestDocuments(context.Background(), []string{"Returns within 30 days"}); err != nil {
+		t.Fatal(err)
+	}
+	h := runtimeserver.NewHandlerWithProvider(root, streamProvider{tokens: []string{"Hel", "lo"}})
+	conn, _, err := dialChatSocket(t, h, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	for _, id := range []string{"q1", "q2"} {
+		msg := runtimeserver.ChatSocketMessage{ID: id, ChatRequest: runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot", Query: "returns", TopK: 1}}
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+		events := readUntilDone(t, conn)
+		var types, tokens []string
+		for _, ev := range events {
+			if ev.ID != id {
+				t.Fatalf("event for %q has id %q", id, ev.ID)
+			}
+			types = append(types, ev.Type)
+			if ev.Type == runtimeserver.EventToken {
+				tokens = append(tokens, ev.Content)
+			}
+		}
+		want := "tool_call,tool_result,token,token,done"
+		if strings.Join(types, ",") != want {
+			t.Fatalf("events = %v, want %s", types, want)
+		}
+		if strings.Join(tokens, "|") != "Hel|lo" {
+			t.Fatalf("tokens = %v", tokens)
+		}
+		if done := events[len(events)-1]; done.Response == nil || done.Response.Rendered != "Hello" {
+			t.Fatalf("unexpected done event: %+v", done)
+		}
+	}
+}
+
+func TestChatWebSocket_BufferedProviderAndErrors(t *testing.T) {
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "WHOLE"})
+	conn, _, err := dialChatSocket(t, h, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.WriteJSON(runtimeserver.ChatSocketMessage{ID: "a", ChatRequest: runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"}})
+	events := readUntilDone(t, conn)
+	if len(events) != 2 || events[0].Type != runtimeserver.EventToken || events[0].Content != "WHOLE" {
+		t.Fatalf("expected a single buffered token then done, got %+v", events)
+	}
+	_ = conn.WriteJSON(runtimeserver.ChatSocketMessage{ID: "b", ChatRequest: runtimeserver.ChatRequest{Context: "Missing"}})
+	events = readUntilDone(t, conn)
+	if last := events[len(events)-1]; last.Type != runtimeserver.EventError || last.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 error event, got %+v", last)
+	}
+}
+
+func TestChatWebSocket_AuthAtUpgrade(t *testing.T) {
+	t.Setenv("CMP_AUTH_ENABLED", "true")
+	t.Setenv("CMP_API_TOKENS", "tok1@acme:chat:execute")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+	_, resp, err := dialChatSocket(t, h, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 at upgrade, got resp=%v err=%v", resp, err)
+	}
+	conn, _, err := dialChatSocket(t, h, http.Header{"Authorization": {"Bearer tok1"}, "X-Tenant-ID": {"acme"}})
+	if err != nil {
+		t.Fatalf("authorized dial: %v", err)
+	}
+	_ = conn.WriteJSON(runtimeserver.ChatSocketMessage{ID: "x", ChatRequest: runtimeserver.ChatRequest{Context: "SupportBot", Component: "SupportBot"}})
+	events := readUntilDone(t, conn)
+	if last := events[len(events)-1]; last.Type != runtimeserver.EventDone {
+		t.Fatalf("expected done, got %+v", last)
+	}
+}
+
+func TestChatWebSocket_OriginFollowsCORS(t *testing.T) {
+	t.Setenv("CMP_CORS_ALLOWED_ORIGINS", "https://app.example.com")
+	h := runtimeserver.NewHandlerWithProvider(scaffoldTempRoot(t), fakeProvider{out: "OK"})
+	if _, _, err := dialChatSocket(t, h, http.Header{"Origin": {"https://app.example.com"}}); err != nil {
+		t.Fatalf("allowed origin: %v", err)
+	}
+	_, resp, err := dialChatSocket(t, h, http.Header{"Origin": {"https://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for an origin outside server.cors, got resp=%v err=%v", resp, err)
+	}
+}
@@ -0,0 +1,78 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/contexis-cmp/contexis/src/cli/commands"
+	corectx "github.com/contexis-cmp/contexis/src/core/context"
+	"gopkg.in/yaml.v3"
+)
+
+const toolContext = `name: "SupportBot"
+version: "1.0.0"
+
+tools:
+  - name: "web_search"
+    uri: "mcp://web.search"
+
+# answer politely
+guardrails:
+  tone: "friendly"
+`
+
+func TestGenerateTool(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"), toolContext)
+	schemaPath := filepath.Join(root, "params.json")
+	writeFile(t, schemaPath, `{"type": "object", "description": "Look up an order",
+		"properties": {"order_id": {"type": "string"}, "include-items": {"type": "boolean"}},
+		"required": ["order_id"]}`)
+
+	files, err := commands.GenerateTool(root, commands.ToolOptions{Name: "OrderLookup", Lang: "go", SchemaPath: schemaPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tools/SupportBot/order_lookup/order_lookup.go", "tools/SupportBot/order_lookup/order_lookup_test.go", "tools/SupportBot/order_lookup/schema.json", "contexts/SupportBot/support_bot.ctx"}
+	if strings.Join(files, ",") != filepath.FromSlash(strings.Join(want, ",")) {
+		t.Fatalf("files = %v", files)
+	}
+	src, _ := os.ReadFile(filepath.Join(root, "tools", "SupportBot", "order_lookup", "order_lookup.go"))
+	if !strings.Contains(string(src), "`json:\"include-items,omitempty\"`") || !strings.Contains(string(src), `var required = []string{"order_id"}`) {
+		t.Fatalf("unexpected implementation:\n%s", src)
+	}
+
+	if _, err := commands.GenerateTool(root, commands.ToolOptions{Name: "notes", Lang: "python"}); err != nil {
+		t.Fatal(err)
+	}
+	by, _ := os.ReadFile(filepath.Join(root, "contexts", "SupportBot", "support_bot.ctx"))
+	if !strings.HasSuffix(string(by), "\n\n# answer politely\nguardrails:\n  tone: \"friendly\"\n") {
+		t.Fatalf("the rest of the context should be kept:\n%s", by)
+	}
+	var c corectx.Context
+	if err := yaml.Unmarshal(by, &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Tools) != 3 {
+		t.Fatalf("tools = %+v", c.Tools)
+	}
+	lookup, notes := c.Tools[1], c.Tools[2]
+	if lookup.Name != "order_lookup" || lookup.URI != "http://127.0.0.1:8790/" || lookup.Method != "POST" || lookup.Schema["description"] != "Look up an order" {
+		t.Fatalf("order_lookup = %+v", lookup)
+	}
+	if notes.URI != "http://127.0.0.1:8791/" || notes.Schema["required"].([]interface{})[0] != "input" {
+		t.Fatalf("notes = %+v", notes)
+	}
+	if _, err := os.Stat(filepath.Join(root, "tools", "SupportBot", "notes", "test_notes.py")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := commands.GenerateTool(root, commands.ToolOptions{Name: "Notes", Lang: "go"}); err == nil || !strings.Contains(err.Error(), "already has a tool named notes") {
+		t.Fatalf("expected a duplicate tool error, got %v", err)
+	}
+	if _, err := commands.GenerateTool(root, commands.ToolOptions{Name: "other", Lang: "rust"}); err == nil {
+		t.Fatal("expected an unsupported language error")
+	}
+}